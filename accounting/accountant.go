@@ -0,0 +1,180 @@
+// Package accounting totals what clients use each tree for, the number of requests, their
+// size and the leaves queued, so that operators of servers shared between tree owners can
+// bill or limit them. Usage is counted in memory as requests are handled and added to the
+// totals in storage periodically.
+package accounting
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// StorageProviderFunc provides the admin storage usage is added to
+type StorageProviderFunc func() (storage.AdminStorage, error)
+
+// Accountant counts the usage of trees and periodically adds it to storage
+type Accountant struct {
+	// done is a channel that provides an exit signal
+	done chan struct{}
+	// storageProvider provides the storage usage is added to
+	storageProvider StorageProviderFunc
+	// period is how often usage is added to storage
+	period time.Duration
+	// timeSource allows us to mock this in tests
+	timeSource util.TimeSource
+
+	// Must hold this lock before accessing the fields below
+	mutex sync.Mutex
+	// pending is the usage of each tree that hasn't been added to storage yet
+	pending map[int64]*trillian.TreeUsage
+	// periodStart is when the requests in pending started being counted
+	periodStart time.Time
+	// recentQPS is the request rate of each tree over the last period
+	recentQPS map[int64]float64
+}
+
+// NewAccountant creates an Accountant that adds usage to storage every period once it's
+// running
+func NewAccountant(done chan struct{}, sp StorageProviderFunc, period time.Duration, timeSource util.TimeSource) *Accountant {
+	return &Accountant{
+		done:            done,
+		storageProvider: sp,
+		period:          period,
+		timeSource:      timeSource,
+		pending:         make(map[int64]*trillian.TreeUsage),
+		periodStart:     timeSource.Now(),
+		recentQPS:       make(map[int64]float64),
+	}
+}
+
+// Record counts a request for a tree, and the leaves it queued
+func (a *Accountant) Record(treeID int64, bytesIn, bytesOut, leavesQueued int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	usage, ok := a.pending[treeID]
+
+	if !ok {
+		usage = &trillian.TreeUsage{TreeId: treeID}
+		a.pending[treeID] = usage
+	}
+
+	usage.Requests++
+	usage.BytesIn += int64(bytesIn)
+	usage.BytesOut += int64(bytesOut)
+	usage.LeavesQueued += int64(leavesQueued)
+}
+
+// Pending returns the usage of a tree that hasn't been added to storage yet, with its
+// request rate over the last period. The tree's ID is only set if there is some.
+func (a *Accountant) Pending(treeID int64) trillian.TreeUsage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var usage trillian.TreeUsage
+
+	if pending, ok := a.pending[treeID]; ok {
+		usage = *pending
+	}
+
+	usage.RecentQps = a.recentQPS[treeID]
+	return usage
+}
+
+// Run adds usage to storage every period until told to exit, and once more as it does
+func (a *Accountant) Run(ctx context.Context) {
+	logging.Infof(ctx, "Tree accounting starting")
+
+	for {
+		select {
+		case <-a.done:
+			if err := a.Flush(ctx); err != nil {
+				logging.Warningf(ctx, "Failed to store tree usage while shutting down: %v", err)
+			}
+
+			logging.Infof(ctx, "Tree accounting shutting down")
+			return
+		case <-time.After(a.period):
+		}
+
+		if err := a.Flush(ctx); err != nil {
+			logging.Warningf(ctx, "Failed to store tree usage: %v", err)
+		}
+	}
+}
+
+// Flush adds the pending usage to storage and starts a new period. If storage fails the
+// usage is kept to be added by the next Flush.
+func (a *Accountant) Flush(ctx context.Context) error {
+	a.mutex.Lock()
+	pending := a.pending
+	now := a.timeSource.Now()
+	elapsed := now.Sub(a.periodStart).Seconds()
+	a.pending = make(map[int64]*trillian.TreeUsage)
+	a.periodStart = now
+	a.recentQPS = make(map[int64]float64)
+
+	for treeID, usage := range pending {
+		if elapsed > 0 {
+			a.recentQPS[treeID] = float64(usage.Requests) / elapsed
+		}
+	}
+	a.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := a.store(ctx, pending); err != nil {
+		a.restore(pending)
+		return err
+	}
+
+	logging.V(1).Infof(ctx, "Stored the usage of %d tree(s)", len(pending))
+	return nil
+}
+
+// store adds usage to storage in a single transaction
+func (a *Accountant) store(ctx context.Context, usage map[int64]*trillian.TreeUsage) error {
+	s, err := a.storageProvider()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range usage {
+		if err := tx.AddTreeUsage(u); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// restore puts usage that couldn't be stored back with the usage counted since
+func (a *Accountant) restore(usage map[int64]*trillian.TreeUsage) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for treeID, u := range usage {
+		if pending, ok := a.pending[treeID]; ok {
+			u.Requests += pending.Requests
+			u.BytesIn += pending.BytesIn
+			u.BytesOut += pending.BytesOut
+			u.LeavesQueued += pending.LeavesQueued
+		}
+
+		a.pending[treeID] = u
+	}
+}
@@ -0,0 +1,119 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var fakeTime = time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func storageProvider(s storage.AdminStorage) StorageProviderFunc {
+	return func() (storage.AdminStorage, error) {
+		return s, nil
+	}
+}
+
+func TestRecordAndPending(t *testing.T) {
+	a := NewAccountant(make(chan struct{}), nil, time.Minute, util.FakeTimeSource{FakeTime: fakeTime})
+	a.Record(1, 10, 100, 2)
+	a.Record(1, 20, 200, 0)
+	a.Record(2, 5, 50, 1)
+
+	want := trillian.TreeUsage{TreeId: 1, Requests: 2, BytesIn: 30, BytesOut: 300, LeavesQueued: 2}
+
+	if got := a.Pending(1); !proto.Equal(&got, &want) {
+		t.Errorf("Got pending usage %v, want %v", got, want)
+	}
+
+	if got := a.Pending(3); !proto.Equal(&got, &trillian.TreeUsage{}) {
+		t.Errorf("Got pending usage %v for an unused tree, want none", got)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().AddTreeUsage(&trillian.TreeUsage{TreeId: 1, Requests: 20, BytesIn: 200, BytesOut: 2000, LeavesQueued: 40}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	a := NewAccountant(make(chan struct{}), storageProvider(mockStorage), time.Minute, ts)
+
+	for i := 0; i < 20; i++ {
+		a.Record(1, 10, 100, 2)
+	}
+
+	ts.FakeTime = fakeTime.Add(10 * time.Second)
+
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush usage: %v", err)
+	}
+
+	// The stored usage is no longer pending but the rate it came in at is kept
+	want := trillian.TreeUsage{RecentQps: 2}
+
+	if got := a.Pending(1); !proto.Equal(&got, &want) {
+		t.Errorf("Got pending usage %v after flushing, want %v", got, want)
+	}
+
+	// Nothing is written if there's no usage
+	if err := a.Flush(context.Background()); err != nil {
+		t.Errorf("Failed to flush no usage: %v", err)
+	}
+}
+
+func TestFlushKeepsUsageStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().AddTreeUsage(gomock.Any()).Return(errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	a := NewAccountant(make(chan struct{}), storageProvider(mockStorage), time.Minute, util.FakeTimeSource{FakeTime: fakeTime})
+	a.Record(1, 10, 100, 2)
+
+	if err := a.Flush(context.Background()); err == nil {
+		t.Fatalf("Flushed usage when storage failed")
+	}
+
+	// Usage counted after the failed flush started is added to what couldn't be stored
+	a.Record(1, 10, 100, 2)
+	want := trillian.TreeUsage{TreeId: 1, Requests: 2, BytesIn: 20, BytesOut: 200, LeavesQueued: 4}
+
+	if got := a.Pending(1); !proto.Equal(&got, &want) {
+		t.Errorf("Got pending usage %v after storage failed, want %v", got, want)
+	}
+}
+
+func TestRunFlushesWhenDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().AddTreeUsage(&trillian.TreeUsage{TreeId: 1, Requests: 1, BytesIn: 10, BytesOut: 100}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	done := make(chan struct{})
+	a := NewAccountant(done, storageProvider(mockStorage), time.Hour, util.FakeTimeSource{FakeTime: fakeTime})
+	a.Record(1, 10, 100, 0)
+
+	close(done)
+	a.Run(context.Background())
+}
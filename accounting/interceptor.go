@@ -0,0 +1,102 @@
+package accounting
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// treeOf returns the tree a request is charged to. Requests that aren't for a single tree,
+// such as listing trees, aren't charged to any.
+func treeOf(req interface{}) (int64, bool) {
+	treeID, _, ok := auth.RequiredPermission(req)
+	return treeID, ok && treeID != auth.AllTrees
+}
+
+// leavesQueued returns the number of leaves a request submitted to a log
+func leavesQueued(req interface{}) int {
+	switch r := req.(type) {
+	case *trillian.QueueLeavesRequest:
+		return len(r.Leaves)
+	case *trillian.AddSequencedLeavesRequest:
+		return len(r.Leaves)
+	}
+
+	return 0
+}
+
+// size returns the encoded size of a request or response
+func size(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+
+	return 0
+}
+
+// UnaryServerInterceptor counts unary RPCs against the tree they're for. Leaves are only
+// counted if they were queued successfully.
+func (a *Accountant) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+
+		if treeID, ok := treeOf(req); ok {
+			if err != nil {
+				a.Record(treeID, size(req), 0, 0)
+			} else {
+				a.Record(treeID, size(req), size(resp), leavesQueued(req))
+			}
+		}
+
+		return resp, err
+	}
+}
+
+// accountedStream measures the messages sent and received on a stream, and finds the tree
+// it's for from the requests
+type accountedStream struct {
+	grpc.ServerStream
+	treeID   int64
+	charged  bool
+	bytesIn  int
+	bytesOut int
+}
+
+func (s *accountedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if !s.charged {
+		s.treeID, s.charged = treeOf(m)
+	}
+
+	s.bytesIn += size(m)
+	return nil
+}
+
+func (s *accountedStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+
+	s.bytesOut += size(m)
+	return nil
+}
+
+// StreamServerInterceptor counts each streaming RPC as one request against the tree its
+// first request is for, with the size of all the messages sent both ways
+func (a *Accountant) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		s := &accountedStream{ServerStream: stream}
+		err := handler(srv, s)
+
+		if s.charged {
+			a.Record(s.treeID, s.bytesIn, s.bytesOut, 0)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,93 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeStream receives a single request and discards what's sent
+type fakeStream struct {
+	grpc.ServerStream
+	req *trillian.StreamLeavesRequest
+}
+
+func (s fakeStream) RecvMsg(m interface{}) error {
+	*m.(*trillian.StreamLeavesRequest) = *s.req
+	return nil
+}
+
+func (s fakeStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := NewAccountant(make(chan struct{}), nil, time.Minute, util.FakeTimeSource{FakeTime: fakeTime})
+	interceptor := a.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+
+	req := &trillian.QueueLeavesRequest{LogId: 1, Leaves: []*trillian.LeafProto{{LeafData: []byte("one")}, {LeafData: []byte("two")}}}
+	resp := &trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}
+
+	interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, nil
+	})
+
+	// Leaves that failed to be queued aren't counted, and there's no response
+	interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("STORAGE")
+	})
+
+	// Requests for all trees aren't charged to any
+	interceptor(context.Background(), &trillian.ListTreesRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &trillian.ListTreesResponse{}, nil
+	})
+
+	want := trillian.TreeUsage{TreeId: 1, Requests: 2, BytesIn: int64(2 * proto.Size(req)), BytesOut: int64(proto.Size(resp)), LeavesQueued: 2}
+
+	if got := a.Pending(1); !proto.Equal(&got, &want) {
+		t.Errorf("Got usage %v, want %v", got, want)
+	}
+
+	if got := a.Pending(0); got.Requests != 0 {
+		t.Errorf("Got usage %v for all trees, want none", got)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	a := NewAccountant(make(chan struct{}), nil, time.Minute, util.FakeTimeSource{FakeTime: fakeTime})
+	req := &trillian.StreamLeavesRequest{LogId: 1, StartIndex: 5}
+	resp := &trillian.StreamLeavesResponse{Leaves: []*trillian.LeafProto{{LeafData: []byte("one")}}}
+
+	err := a.StreamServerInterceptor()(nil, fakeStream{req: req}, &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/StreamLeaves"}, func(srv interface{}, stream grpc.ServerStream) error {
+		var got trillian.StreamLeavesRequest
+
+		if err := stream.RecvMsg(&got); err != nil {
+			return err
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := stream.SendMsg(resp); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to stream: %v", err)
+	}
+
+	want := trillian.TreeUsage{TreeId: 1, Requests: 1, BytesIn: int64(proto.Size(req)), BytesOut: int64(3 * proto.Size(resp))}
+
+	if got := a.Pending(1); !proto.Equal(&got, &want) {
+		t.Errorf("Got usage %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// LogTrail is a Trail kept in a Trillian log, so that the trail itself can be verified not
+// to have been changed. Each event is a leaf holding the encoded AuditEvent, and its index
+// is the leaf's. Events are only read back once the log has sequenced them.
+type LogTrail struct {
+	client trillian.TrillianLogClient
+	logID  int64
+	hasher merkle.TreeHasher
+}
+
+// NewLogTrail creates a LogTrail that appends to log logID with client. The log's leaves are
+// hashed with hasher.
+func NewLogTrail(client trillian.TrillianLogClient, logID int64, hasher merkle.TreeHasher) *LogTrail {
+	return &LogTrail{client: client, logID: logID, hasher: hasher}
+}
+
+// Append implements Trail. The index of the event is left for the log to assign.
+func (l *LogTrail) Append(ctx context.Context, event trillian.AuditEvent) error {
+	event.Index = 0
+	data, err := proto.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	leaf := &trillian.LeafProto{LeafHash: l.hasher.HashLeaf(data), LeafData: data}
+	resp, err := l.client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: l.logID, Leaves: []*trillian.LeafProto{leaf}})
+	if err != nil {
+		return err
+	}
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("audit log %d failed to queue event: %v", l.logID, resp.Status)
+	}
+
+	for _, result := range resp.Leaves {
+		if result.Status == trillian.QueuedLeafStatus_REJECTED {
+			return fmt.Errorf("audit log %d rejected event: %s", l.logID, result.Reason)
+		}
+	}
+
+	return nil
+}
+
+// Read implements Trail
+func (l *LogTrail) Read(ctx context.Context, start int64, max int) ([]trillian.AuditEvent, error) {
+	if start < 0 || max < 0 {
+		return nil, fmt.Errorf("invalid audit trail range: %d events from %d", max, start)
+	}
+
+	countResp, err := l.client.GetSequencedLeafCount(ctx, &trillian.GetSequencedLeafCountRequest{LogId: l.logID})
+	if err != nil {
+		return nil, err
+	}
+	if countResp.Status == nil || countResp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("audit log %d failed to get leaf count: %v", l.logID, countResp.Status)
+	}
+
+	var indices []int64
+	for i := start; i < countResp.LeafCount && len(indices) < max; i++ {
+		indices = append(indices, i)
+	}
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	resp, err := l.client.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: l.logID, LeafIndex: indices})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("audit log %d failed to get leaves: %v", l.logID, resp.Status)
+	}
+	if len(resp.Leaves) != len(indices) {
+		return nil, fmt.Errorf("audit log %d returned %d leaves, asked for %d", l.logID, len(resp.Leaves), len(indices))
+	}
+
+	events := make([]trillian.AuditEvent, 0, len(resp.Leaves))
+	for i, leaf := range resp.Leaves {
+		var event trillian.AuditEvent
+		if err := proto.Unmarshal(leaf.LeafData, &event); err != nil {
+			return nil, fmt.Errorf("audit log %d has a bad event at index %d: %v", l.logID, indices[i], err)
+		}
+		event.Index = indices[i]
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var okStatus = &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+
+// fakeLogClient sequences leaves as soon as they're queued. Only the methods used by
+// LogTrail are implemented.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	t      *testing.T
+	hasher merkle.TreeHasher
+	leaves []*trillian.LeafProto
+	// reject makes queued leaves be rejected
+	reject bool
+}
+
+func (f *fakeLogClient) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	if req.LogId != testAuditLogID {
+		f.t.Errorf("Queued leaves to log %d, want %d", req.LogId, testAuditLogID)
+	}
+
+	resp := &trillian.QueueLeavesResponse{Status: okStatus}
+	for _, leaf := range req.Leaves {
+		if !bytes.Equal(leaf.LeafHash, f.hasher.HashLeaf(leaf.LeafData)) {
+			f.t.Errorf("Queued leaf with hash %x, want %x", leaf.LeafHash, f.hasher.HashLeaf(leaf.LeafData))
+		}
+		if f.reject {
+			resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_REJECTED, Reason: "too big"})
+			continue
+		}
+		f.leaves = append(f.leaves, leaf)
+		resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED})
+	}
+
+	return resp, nil
+}
+
+func (f *fakeLogClient) GetSequencedLeafCount(ctx context.Context, req *trillian.GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
+	return &trillian.GetSequencedLeafCountResponse{Status: okStatus, LeafCount: int64(len(f.leaves))}, nil
+}
+
+func (f *fakeLogClient) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	resp := &trillian.GetLeavesByIndexResponse{Status: okStatus}
+	for _, index := range req.LeafIndex {
+		resp.Leaves = append(resp.Leaves, f.leaves[index])
+	}
+
+	return resp, nil
+}
+
+const testAuditLogID = 99
+
+func newTestLogTrail(t *testing.T) (*LogTrail, *fakeLogClient) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	f := &fakeLogClient{t: t, hasher: hasher}
+	return NewLogTrail(f, testAuditLogID, hasher), f
+}
+
+func TestLogTrail(t *testing.T) {
+	trail, _ := newTestLogTrail(t)
+	testTrail(t, trail)
+}
+
+func TestLogTrailRejectedEvent(t *testing.T) {
+	trail, f := newTestLogTrail(t)
+	f.reject = true
+
+	if err := trail.Append(context.Background(), trillian.AuditEvent{TreeId: 1}); err == nil {
+		t.Error("Append() of a rejected event succeeded")
+	}
+}
+
+func TestLogTrailBadEvent(t *testing.T) {
+	trail, f := newTestLogTrail(t)
+	f.leaves = append(f.leaves, &trillian.LeafProto{LeafData: []byte("not an event")})
+
+	if events, err := trail.Read(context.Background(), 0, 1); err == nil {
+		t.Errorf("Read() = %v, want an error", events)
+	}
+}
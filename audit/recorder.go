@@ -0,0 +1,188 @@
+package audit
+
+import (
+	gocrypto "crypto"
+	"io"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/auth"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/publisher"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// adminMethodPrefix starts the full names of the TrillianAdmin RPCs
+const adminMethodPrefix = "/trillian.TrillianAdmin/"
+
+// Recorder adds events to a Trail for the actions it sees, timestamping them when they
+// happen. It provides the hooks to see each kind of action: an interceptor for admin RPCs,
+// a wrapper for key managers and a publisher for signed roots.
+type Recorder struct {
+	trail      Trail
+	timeSource util.TimeSource
+}
+
+// NewRecorder creates a Recorder that appends to trail.
+func NewRecorder(trail Trail, timeSource util.TimeSource) *Recorder {
+	return &Recorder{trail: trail, timeSource: timeSource}
+}
+
+// Trail returns the trail the recorder appends to.
+func (r *Recorder) Trail() Trail {
+	return r.trail
+}
+
+// Record timestamps event and appends it to the trail.
+func (r *Recorder) Record(ctx context.Context, event trillian.AuditEvent) error {
+	event.TimestampNanos = r.timeSource.Now().UnixNano()
+
+	if err := r.trail.Append(ctx, event); err != nil {
+		glog.Warningf("Failed to record %v audit event for tree %d: %v", event.Type, event.TreeId, err)
+		return err
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor records every TrillianAdmin RPC, once it has been handled, with
+// who made it and whether it failed. Other RPCs aren't recorded. A failure to record an RPC
+// is logged but doesn't fail it, as its change has already been made.
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+
+		if strings.HasPrefix(info.FullMethod, adminMethodPrefix) {
+			event := trillian.AuditEvent{
+				Type:   trillian.AuditEventType_ADMIN_RPC,
+				TreeId: treeOf(req, resp),
+				Actor:  actor(ctx),
+				Method: info.FullMethod,
+			}
+			if err != nil {
+				event.Error = err.Error()
+			} else if status := responseStatus(resp); status != nil && status.StatusCode != trillian.TrillianApiStatusCode_OK {
+				event.Error = status.String()
+			}
+
+			r.Record(ctx, event)
+		}
+
+		return resp, err
+	}
+}
+
+// treeOf returns the tree an admin RPC was for, zero if it wasn't for a single tree. Trees
+// that are created are only known from the response.
+func treeOf(req, resp interface{}) int64 {
+	if r, ok := resp.(*trillian.CreateTreeResponse); ok && r.Tree != nil {
+		return r.Tree.TreeId
+	}
+
+	if treeID, _, ok := auth.RequiredPermission(req); ok && treeID != auth.AllTrees {
+		return treeID
+	}
+
+	return 0
+}
+
+// responseStatus returns the status of an admin RPC's response, nil if it doesn't have one
+func responseStatus(resp interface{}) *trillian.TrillianApiStatus {
+	if r, ok := resp.(interface {
+		GetStatus() *trillian.TrillianApiStatus
+	}); ok {
+		return r.GetStatus()
+	}
+
+	return nil
+}
+
+// actor describes who made a request
+func actor(ctx context.Context) string {
+	if identity := auth.Identity(ctx); len(identity) > 0 {
+		return identity
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+
+	return "unknown client"
+}
+
+// KeyManager wraps km, the key manager with key keyID of tree treeID, so that each use of
+// its private key is recorded along with the digest that was signed. A signature is only
+// returned once its use has been recorded, so no signature is made without a record of it.
+func (r *Recorder) KeyManager(treeID int64, keyID []byte, km crypto.KeyManager) crypto.KeyManager {
+	return &recordedKeyManager{KeyManager: km, recorder: r, treeID: treeID, keyID: keyID}
+}
+
+type recordedKeyManager struct {
+	crypto.KeyManager
+	recorder *Recorder
+	treeID   int64
+	keyID    []byte
+}
+
+func (k *recordedKeyManager) Signer() (gocrypto.Signer, error) {
+	signer, err := k.KeyManager.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordedSigner{Signer: signer, km: k}, nil
+}
+
+type recordedSigner struct {
+	gocrypto.Signer
+	km *recordedKeyManager
+}
+
+func (s *recordedSigner) Sign(rand io.Reader, digest []byte, opts gocrypto.SignerOpts) ([]byte, error) {
+	sig, err := s.Signer.Sign(rand, digest, opts)
+
+	event := trillian.AuditEvent{
+		Type:   trillian.AuditEventType_KEY_USED,
+		TreeId: s.km.treeID,
+		KeyId:  s.km.keyID,
+		Digest: digest,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if recordErr := s.km.recorder.Record(context.Background(), event); recordErr != nil {
+		return nil, recordErr
+	}
+
+	return sig, err
+}
+
+// Publisher returns a publisher that records each root signed for log treeID. Map roots
+// aren't recorded.
+func (r *Recorder) Publisher(treeID int64) publisher.Publisher {
+	return rootRecorder{recorder: r, treeID: treeID}
+}
+
+type rootRecorder struct {
+	recorder *Recorder
+	treeID   int64
+}
+
+func (p rootRecorder) PublishLogRoot(root trillian.SignedLogRoot) error {
+	return p.recorder.Record(context.Background(), trillian.AuditEvent{
+		Type:         trillian.AuditEventType_LOG_ROOT_SIGNED,
+		TreeId:       p.treeID,
+		TreeSize:     root.TreeSize,
+		RootHash:     root.RootHash,
+		TreeRevision: root.TreeRevision,
+	})
+}
+
+func (p rootRecorder) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return nil
+}
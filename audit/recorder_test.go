@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+var testTime = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// failingTrail can't append anything
+type failingTrail struct {
+	MemoryTrail
+}
+
+func (f *failingTrail) Append(ctx context.Context, event trillian.AuditEvent) error {
+	return errors.New("disk full")
+}
+
+func allEvents(t *testing.T, trail Trail) []trillian.AuditEvent {
+	events, err := trail.Read(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	return events
+}
+
+func TestRecorderInterceptor(t *testing.T) {
+	trail := NewMemoryTrail()
+	interceptor := NewRecorder(trail, util.FakeTimeSource{FakeTime: testTime}).UnaryServerInterceptor()
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+
+	for _, test := range []struct {
+		method string
+		req    interface{}
+		resp   interface{}
+		err    error
+	}{
+		{"/trillian.TrillianAdmin/GetTree", &trillian.GetTreeRequest{TreeId: 5}, &trillian.GetTreeResponse{Status: okStatus}, nil},
+		{"/trillian.TrillianLog/QueueLeaves", &trillian.QueueLeavesRequest{LogId: 5}, &trillian.QueueLeavesResponse{Status: okStatus}, nil},
+		{"/trillian.TrillianAdmin/DeleteTree", &trillian.DeleteTreeRequest{TreeId: 6}, nil, errors.New("storage unavailable")},
+		{"/trillian.TrillianAdmin/CreateTree", &trillian.CreateTreeRequest{}, &trillian.CreateTreeResponse{Status: okStatus, Tree: &trillian.Tree{TreeId: 7}}, nil},
+		{"/trillian.TrillianAdmin/ListTrees", &trillian.ListTreesRequest{}, &trillian.ListTreesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR, Description: "bad"}}, nil},
+	} {
+		resp, err := interceptor(ctx, test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return test.resp, test.err
+		})
+		if resp != test.resp || err != test.err {
+			t.Errorf("%s: interceptor returned %v, %v, want %v, %v", test.method, resp, err, test.resp, test.err)
+		}
+	}
+
+	events := allEvents(t, trail)
+	for i, want := range []struct {
+		method  string
+		treeID  int64
+		wantErr bool
+	}{
+		{"/trillian.TrillianAdmin/GetTree", 5, false},
+		{"/trillian.TrillianAdmin/DeleteTree", 6, true},
+		{"/trillian.TrillianAdmin/CreateTree", 7, false},
+		{"/trillian.TrillianAdmin/ListTrees", 0, true},
+	} {
+		if i >= len(events) {
+			t.Fatalf("Recorded %d events, want 4", len(events))
+		}
+		got := events[i]
+		if got.Type != trillian.AuditEventType_ADMIN_RPC || got.Method != want.method || got.TreeId != want.treeID || (len(got.Error) > 0) != want.wantErr {
+			t.Errorf("Event %d = %v, want %s for tree %d with error %v", i, got, want.method, want.treeID, want.wantErr)
+		}
+		if got.Actor != "10.0.0.1:1234" || got.TimestampNanos != testTime.UnixNano() {
+			t.Errorf("Event %d was by %q at %d, want 10.0.0.1:1234 at %d", i, got.Actor, got.TimestampNanos, testTime.UnixNano())
+		}
+	}
+	if len(events) != 4 {
+		t.Errorf("Recorded %d events, want 4", len(events))
+	}
+}
+
+func TestRecorderInterceptorIgnoresTrailFailures(t *testing.T) {
+	interceptor := NewRecorder(&failingTrail{}, util.SystemTimeSource{}).UnaryServerInterceptor()
+	req := &trillian.GetTreeRequest{TreeId: 5}
+	want := &trillian.GetTreeResponse{Status: okStatus}
+
+	resp, err := interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/GetTree"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return want, nil
+	})
+	if resp != want || err != nil {
+		t.Errorf("Interceptor returned %v, %v, want %v, nil", resp, err, want)
+	}
+}
+
+func TestRecorderKeyManager(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	digest := sha256.Sum256([]byte("root"))
+
+	trail := NewMemoryTrail()
+	km := NewRecorder(trail, util.FakeTimeSource{FakeTime: testTime}).KeyManager(3, []byte("pem:key"), tcrypto.PEMKeyManager{}.NewPEMKeyManager(key))
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+		t.Fatalf("Sign() = %v", err)
+	}
+
+	events := allEvents(t, trail)
+	if len(events) != 1 {
+		t.Fatalf("Recorded %d events, want 1", len(events))
+	}
+	if got := events[0]; got.Type != trillian.AuditEventType_KEY_USED || got.TreeId != 3 || string(got.KeyId) != "pem:key" || string(got.Digest) != string(digest[:]) || len(got.Error) > 0 {
+		t.Errorf("Recorded %v, want a use of key pem:key for tree 3", got)
+	}
+
+	// Without a record of it no signature is made
+	km = NewRecorder(&failingTrail{}, util.SystemTimeSource{}).KeyManager(3, []byte("pem:key"), tcrypto.PEMKeyManager{}.NewPEMKeyManager(key))
+	if signer, err = km.Signer(); err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	if sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+		t.Errorf("Sign() = %x without recording it", sig)
+	}
+}
+
+func TestRecorderPublisher(t *testing.T) {
+	trail := NewMemoryTrail()
+	p := NewRecorder(trail, util.FakeTimeSource{FakeTime: testTime}).Publisher(4)
+
+	if err := p.PublishLogRoot(trillian.SignedLogRoot{TreeSize: 10, RootHash: []byte("hash"), TreeRevision: 2}); err != nil {
+		t.Fatalf("PublishLogRoot() = %v", err)
+	}
+	if err := p.PublishMapRoot(trillian.SignedMapRoot{}); err != nil {
+		t.Fatalf("PublishMapRoot() = %v", err)
+	}
+
+	events := allEvents(t, trail)
+	if len(events) != 1 {
+		t.Fatalf("Recorded %d events, want 1", len(events))
+	}
+	if got := events[0]; got.Type != trillian.AuditEventType_LOG_ROOT_SIGNED || got.TreeId != 4 || got.TreeSize != 10 || string(got.RootHash) != "hash" || got.TreeRevision != 2 {
+		t.Errorf("Recorded %v, want the root of tree 4 at size 10", got)
+	}
+
+	if err := NewRecorder(&failingTrail{}, util.SystemTimeSource{}).Publisher(4).PublishLogRoot(trillian.SignedLogRoot{}); err == nil {
+		t.Error("PublishLogRoot() succeeded without recording the root")
+	}
+}
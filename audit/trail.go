@@ -0,0 +1,193 @@
+// Package audit records the administrative and signing actions taken by a server to an
+// append-only audit trail, for deployments that must be able to show who changed their
+// trees and when their keys were used.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// Trail is an append-only sequence of audit events. Events are numbered from zero in the
+// order they were appended.
+type Trail interface {
+	// Append adds event to the end of the trail. Its index is assigned by the trail.
+	Append(ctx context.Context, event trillian.AuditEvent) error
+	// Read returns up to max events from index start, with their indexes set. There are
+	// fewer, possibly none, if the trail doesn't have that many yet.
+	Read(ctx context.Context, start int64, max int) ([]trillian.AuditEvent, error)
+}
+
+// MemoryTrail is a Trail that only lasts as long as the process, for tests and servers
+// that don't need to keep their trail.
+type MemoryTrail struct {
+	mu     sync.Mutex
+	events []trillian.AuditEvent
+}
+
+// NewMemoryTrail creates an empty MemoryTrail.
+func NewMemoryTrail() *MemoryTrail {
+	return &MemoryTrail{}
+}
+
+// Append implements Trail
+func (m *MemoryTrail) Append(ctx context.Context, event trillian.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.Index = int64(len(m.events))
+	m.events = append(m.events, event)
+	return nil
+}
+
+// Read implements Trail
+func (m *MemoryTrail) Read(ctx context.Context, start int64, max int) ([]trillian.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return readRange(m.events, start, max)
+}
+
+// readRange returns a copy of up to max of events from index start.
+func readRange(events []trillian.AuditEvent, start int64, max int) ([]trillian.AuditEvent, error) {
+	if start < 0 || max < 0 {
+		return nil, fmt.Errorf("invalid audit trail range: %d events from %d", max, start)
+	}
+	if start >= int64(len(events)) {
+		return nil, nil
+	}
+
+	end := start + int64(max)
+	if end > int64(len(events)) {
+		end = int64(len(events))
+	}
+
+	return append([]trillian.AuditEvent{}, events[start:end]...), nil
+}
+
+// FileTrail is a Trail kept in a file, with one event per line as JSON. The file is only
+// ever appended to, and each event is synced to disk before Append returns.
+type FileTrail struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// size is the number of events in the file
+	size int64
+}
+
+// NewFileTrail opens the trail in the file at path, creating it if it doesn't exist.
+func NewFileTrail(path string) (*FileTrail, error) {
+	var size int64
+	if err := scanFileTrail(path, func([]byte) (bool, error) {
+		size++
+		return true, nil
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileTrail{path: path, file: file, size: size}, nil
+}
+
+// Append implements Trail
+func (f *FileTrail) Append(ctx context.Context, event trillian.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return errors.New("audit trail is closed")
+	}
+
+	event.Index = f.size
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, &event); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	if _, err := f.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+
+	f.size++
+	return nil
+}
+
+// Read implements Trail
+func (f *FileTrail) Read(ctx context.Context, start int64, max int) ([]trillian.AuditEvent, error) {
+	if start < 0 || max < 0 {
+		return nil, fmt.Errorf("invalid audit trail range: %d events from %d", max, start)
+	}
+
+	var events []trillian.AuditEvent
+	var index int64
+	err := scanFileTrail(f.path, func(line []byte) (bool, error) {
+		if len(events) == max {
+			return false, nil
+		}
+		if index++; index <= start {
+			return true, nil
+		}
+
+		var event trillian.AuditEvent
+		if err := jsonpb.Unmarshal(bytes.NewReader(line), &event); err != nil {
+			return false, fmt.Errorf("audit trail %s has a bad event at line %d: %v", f.path, index, err)
+		}
+		events = append(events, event)
+		return true, nil
+	})
+
+	return events, err
+}
+
+// Close closes the file, after which events can no longer be appended.
+func (f *FileTrail) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// scanFileTrail calls visit with each line of the file at path, until it returns false.
+func scanFileTrail(path string, visit func(line []byte) (bool, error)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		more, err := visit(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// testTrail checks that trail, which must be empty, numbers and returns events in order
+func testTrail(t *testing.T, trail Trail) {
+	ctx := context.Background()
+
+	for i := int64(0); i < 5; i++ {
+		// Indexes set by the caller are ignored
+		if err := trail.Append(ctx, trillian.AuditEvent{Index: 100, TreeId: 10 + i, Type: trillian.AuditEventType_ADMIN_RPC}); err != nil {
+			t.Fatalf("Append() = %v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		start     int64
+		max       int
+		wantTrees []int64
+	}{
+		{0, 10, []int64{10, 11, 12, 13, 14}},
+		{1, 2, []int64{11, 12}},
+		{3, 5, []int64{13, 14}},
+		{5, 5, nil},
+		{10, 5, nil},
+		{0, 0, nil},
+	} {
+		events, err := trail.Read(ctx, test.start, test.max)
+		if err != nil {
+			t.Fatalf("Read(%d, %d) = %v", test.start, test.max, err)
+		}
+		if len(events) != len(test.wantTrees) {
+			t.Errorf("Read(%d, %d) returned %d events, want %d", test.start, test.max, len(events), len(test.wantTrees))
+			continue
+		}
+		for i, event := range events {
+			if got, want := event.Index, test.start+int64(i); got != want {
+				t.Errorf("Read(%d, %d) event %d has index %d, want %d", test.start, test.max, i, got, want)
+			}
+			if got, want := event.TreeId, test.wantTrees[i]; got != want {
+				t.Errorf("Read(%d, %d) event %d is for tree %d, want %d", test.start, test.max, i, got, want)
+			}
+		}
+	}
+
+	if _, err := trail.Read(ctx, -1, 5); err == nil {
+		t.Error("Read() accepted a negative start")
+	}
+}
+
+func TestMemoryTrail(t *testing.T) {
+	testTrail(t, NewMemoryTrail())
+}
+
+func TestFileTrail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "trail")
+	trail, err := NewFileTrail(path)
+	if err != nil {
+		t.Fatalf("NewFileTrail() = %v", err)
+	}
+	testTrail(t, trail)
+	if err := trail.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := trail.Append(context.Background(), trillian.AuditEvent{}); err == nil {
+		t.Error("Append() to a closed trail succeeded")
+	}
+
+	// Reopening the trail carries on from where it ended
+	trail, err = NewFileTrail(path)
+	if err != nil {
+		t.Fatalf("NewFileTrail() = %v", err)
+	}
+	defer trail.Close()
+	if err := trail.Append(context.Background(), trillian.AuditEvent{TreeId: 15}); err != nil {
+		t.Fatalf("Append() = %v", err)
+	}
+
+	events, err := trail.Read(context.Background(), 4, 5)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if len(events) != 2 || events[1].Index != 5 || events[1].TreeId != 15 {
+		t.Errorf("Read() after reopening = %v, want events 4 and 5", events)
+	}
+}
+
+func TestFileTrailRejectsBadEvents(t *testing.T) {
+	f, err := ioutil.TempFile("", "audit")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("not an event\n")
+	f.Close()
+
+	trail, err := NewFileTrail(f.Name())
+	if err != nil {
+		t.Fatalf("NewFileTrail() = %v", err)
+	}
+	defer trail.Close()
+
+	if events, err := trail.Read(context.Background(), 0, 1); err == nil {
+		t.Errorf("Read() = %v, want an error", events)
+	}
+}
+
+func TestNewTrailFromURI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	trail, err := NewTrailFromURI("file://" + filepath.Join(dir, "trail"))
+	if err != nil {
+		t.Fatalf("NewTrailFromURI() = %v", err)
+	}
+	if _, ok := trail.(*FileTrail); !ok {
+		t.Errorf("NewTrailFromURI() = %T, want a FileTrail", trail)
+	}
+
+	trail, err = NewTrailFromURI("trillian://localhost:8090/123")
+	if err != nil {
+		t.Fatalf("NewTrailFromURI() = %v", err)
+	}
+	if l, ok := trail.(*LogTrail); !ok || l.logID != 123 {
+		t.Errorf("NewTrailFromURI() = %v, want a LogTrail for log 123", trail)
+	}
+
+	for _, uri := range []string{"", "file://", "trillian://localhost:8090/", "trillian:///123", "http://localhost/", "%"} {
+		if _, err := NewTrailFromURI(uri); err == nil {
+			t.Errorf("NewTrailFromURI(%q) succeeded", uri)
+		}
+	}
+}
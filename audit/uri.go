@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"google.golang.org/grpc"
+)
+
+// NewTrailFromURI creates the Trail described by uri. That's either a file:// URI naming the
+// file the trail is kept in, or trillian://host:port/<log ID> naming a Trillian log, which
+// must hash its leaves as in RFC 6962 with SHA-256. Its server is connected to without
+// TLS. Other trails can be supported by implementing Trail.
+func NewTrailFromURI(uri string) (Trail, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("bad audit trail URI %q: %v", uri, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if len(u.Path) == 0 {
+			return nil, fmt.Errorf("audit trail URI %q has no path", uri)
+		}
+
+		return NewFileTrail(u.Path)
+	case "trillian":
+		logID, err := strconv.ParseInt(strings.TrimPrefix(u.Path, "/"), 10, 64)
+		if err != nil || len(u.Host) == 0 {
+			return nil, fmt.Errorf("audit trail URI %q is not of the form trillian://host:port/<log ID>", uri)
+		}
+
+		conn, err := grpc.Dial(u.Host, grpc.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to audit log server %s: %v", u.Host, err)
+		}
+
+		return NewLogTrail(trillian.NewTrillianLogClient(conn), logID, merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), nil
+	default:
+		return nil, fmt.Errorf("unknown scheme for audit trail URI %q", uri)
+	}
+}
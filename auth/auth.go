@@ -0,0 +1,239 @@
+// Package auth identifies the clients of the gRPC servers by their TLS certificates and
+// checks that they're allowed to use the trees their requests are for. Each request needs
+// a permission on its tree: read for queries, write to add leaves and admin to manage
+// trees.
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Permission is a set of things that can be done with a tree
+type Permission uint
+
+const (
+	// Read lets a client fetch leaves, proofs and roots
+	Read Permission = 1 << iota
+	// Write lets a client add leaves to a tree
+	Write
+	// Admin lets a client create, change and delete trees
+	Admin
+)
+
+var permissionNames = []struct {
+	perm Permission
+	name string
+}{
+	{Read, "read"},
+	{Write, "write"},
+	{Admin, "admin"},
+}
+
+// String lists the permissions in the set, e.g. read+write
+func (p Permission) String() string {
+	var names []string
+
+	for _, n := range permissionNames {
+		if p&n.perm != 0 {
+			names = append(names, n.name)
+		}
+	}
+
+	return strings.Join(names, "+")
+}
+
+// AllTrees can be granted permissions on in place of a tree ID to give them for every
+// tree. It's safe because no tree has ID 0.
+const AllTrees int64 = 0
+
+// AnyClient can be granted permissions in place of an identity to give them to every client,
+// including ones that didn't present a certificate
+const AnyClient = "*"
+
+// Authorizer decides which clients may use which trees
+type Authorizer interface {
+	// Authorize returns true if the client with identity has all of perm on the tree with ID
+	// treeID. The identity of clients that didn't present a certificate is empty. Requests
+	// that aren't for one tree, such as creating or listing trees, ask for permission on
+	// AllTrees.
+	Authorize(identity string, treeID int64, perm Permission) bool
+}
+
+// Grants is an Authorizer that gives clients the permissions listed for their identity and
+// AnyClient, on a tree and on AllTrees. There are no other permissions.
+type Grants map[string]map[int64]Permission
+
+// Authorize checks the client has been granted perm
+func (g Grants) Authorize(identity string, treeID int64, perm Permission) bool {
+	var granted Permission
+
+	for _, id := range []string{identity, AnyClient} {
+		granted |= g[id][treeID] | g[id][AllTrees]
+	}
+
+	return granted&perm == perm
+}
+
+// ParseGrants parses grants from a comma separated list of identity/tree=permissions, where
+// permissions are separated by +. Either of identity or tree can be * for all of them, e.g.
+// "frontend.example.com/123=read+write,ops.example.com/*=admin,*/*=read" lets the frontend
+// add to tree 123, the ops team manage all trees and anyone read from them.
+func ParseGrants(s string) (Grants, error) {
+	grants := make(Grants)
+
+	if len(s) == 0 {
+		return grants, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(entry, "=")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("auth: invalid grant %q, want identity/tree=permissions", entry)
+		}
+
+		// Split at the last slash, identities might contain them but tree IDs won't
+		slash := strings.LastIndex(parts[0], "/")
+
+		if slash <= 0 {
+			return nil, fmt.Errorf("auth: invalid grant %q, want identity/tree=permissions", entry)
+		}
+
+		identity := parts[0][:slash]
+		treeID := AllTrees
+
+		if tree := parts[0][slash+1:]; tree != "*" {
+			var err error
+
+			if treeID, err = strconv.ParseInt(tree, 10, 64); err != nil || treeID <= 0 {
+				return nil, fmt.Errorf("auth: invalid tree in %q, must be an ID or *", entry)
+			}
+		}
+
+		perm, err := parsePermission(parts[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if grants[identity] == nil {
+			grants[identity] = make(map[int64]Permission)
+		}
+
+		grants[identity][treeID] |= perm
+	}
+
+	return grants, nil
+}
+
+// parsePermission parses a set of permissions, e.g. read+write
+func parsePermission(s string) (Permission, error) {
+	var perm Permission
+
+	for _, name := range strings.Split(s, "+") {
+		found := false
+
+		for _, n := range permissionNames {
+			if n.name == name {
+				perm |= n.perm
+				found = true
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("auth: unknown permission %q", name)
+		}
+	}
+
+	return perm, nil
+}
+
+// Identity returns the common name of the verified certificate the client presented, or ""
+// if it didn't present one
+func Identity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+
+	if !ok {
+		return ""
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// healthMethodPrefix starts the full names of the RPCs of the standard gRPC health service
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+// authorize checks the client whose request has context ctx may make req. Requests the
+// server doesn't know the permissions for are refused. Health checks are allowed for every
+// client, so orchestrators probing the server needn't present certificates.
+func authorize(ctx context.Context, a Authorizer, method string, req interface{}) error {
+	if strings.HasPrefix(method, healthMethodPrefix) {
+		return nil
+	}
+
+	treeID, perm, ok := RequiredPermission(req)
+	identity := Identity(ctx)
+
+	if !ok {
+		return grpc.Errorf(codes.PermissionDenied, "%s: no permissions are defined for %T", method, req)
+	}
+
+	if a.Authorize(identity, treeID, perm) {
+		return nil
+	}
+
+	if len(identity) == 0 {
+		return grpc.Errorf(codes.Unauthenticated, "%s: needs %v permission on tree %d, present a client certificate", method, perm, treeID)
+	}
+
+	return grpc.Errorf(codes.PermissionDenied, "%s: %s doesn't have %v permission on tree %d", method, identity, perm, treeID)
+}
+
+// UnaryServerInterceptor refuses unary RPCs that a doesn't authorize. Clients that didn't
+// present a certificate are told they're unauthenticated, others that permission is denied.
+func UnaryServerInterceptor(a Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, a, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// authorizedStream checks each request received on a stream
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer Authorizer
+	method     string
+}
+
+func (s authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return authorize(s.Context(), s.authorizer, s.method, m)
+}
+
+// StreamServerInterceptor refuses the requests received on streaming RPCs that a doesn't
+// authorize, in the same way as UnaryServerInterceptor
+func StreamServerInterceptor(a Authorizer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, authorizedStream{stream, a, info.FullMethod})
+	}
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// clientContext returns the context of a request from a client that presented a verified
+// certificate for identity
+func clientContext(identity string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+// fakeStream is a server stream that receives a single request
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	req *trillian.StreamLeavesRequest
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s fakeStream) RecvMsg(m interface{}) error {
+	*m.(*trillian.StreamLeavesRequest) = *s.req
+	return nil
+}
+
+func TestParseGrants(t *testing.T) {
+	grants, err := ParseGrants("frontend/123=read+write,ops/*=admin,*/*=read,frontend/123=admin")
+
+	if err != nil {
+		t.Fatalf("Failed to parse grants: %v", err)
+	}
+
+	want := Grants{
+		"frontend": {123: Read | Write | Admin},
+		"ops":      {AllTrees: Admin},
+		AnyClient:  {AllTrees: Read},
+	}
+
+	if !reflect.DeepEqual(grants, want) {
+		t.Errorf("Got grants %v, want %v", grants, want)
+	}
+}
+
+func TestParseGrantsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"frontend",
+		"frontend=read",
+		"/123=read",
+		"frontend/abc=read",
+		"frontend/0=read",
+		"frontend/-1=read",
+		"frontend/123=delete",
+		"frontend/123=read+",
+	} {
+		if grants, err := ParseGrants(s); err == nil {
+			t.Errorf("Parsed invalid grants %q as %v", s, grants)
+		}
+	}
+}
+
+func TestPermissionString(t *testing.T) {
+	if got, want := (Read | Admin).String(), "read+admin"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+}
+
+func TestGrantsAuthorize(t *testing.T) {
+	grants := Grants{
+		"frontend": {123: Write},
+		"ops":      {AllTrees: Admin},
+		AnyClient:  {123: Read},
+	}
+
+	for _, test := range []struct {
+		identity string
+		treeID   int64
+		perm     Permission
+		want     bool
+	}{
+		{"frontend", 123, Write, true},
+		// Permissions of a client and everyone are combined
+		{"frontend", 123, Read | Write, true},
+		{"frontend", 456, Write, false},
+		{"ops", 456, Admin, true},
+		{"ops", AllTrees, Admin, true},
+		{"ops", 456, Write, false},
+		{"", 123, Read, true},
+		{"", 123, Write, false},
+		{"", 456, Read, false},
+	} {
+		if got := grants.Authorize(test.identity, test.treeID, test.perm); got != test.want {
+			t.Errorf("Authorize(%q, %d, %v) = %v, want %v", test.identity, test.treeID, test.perm, got, test.want)
+		}
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	if got := Identity(clientContext("frontend")); got != "frontend" {
+		t.Errorf("Got identity %q, want frontend", got)
+	}
+
+	if got := Identity(context.Background()); got != "" {
+		t.Errorf("Got identity %q for a context without a peer, want none", got)
+	}
+
+	// Clients that connected over TLS without a certificate don't have an identity
+	unverified := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+
+	if got := Identity(unverified); got != "" {
+		t.Errorf("Got identity %q for a client without a certificate, want none", got)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(Grants{"frontend": {123: Write}, "monitor": {123: Read}, "ops": {AllTrees: Admin}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+
+	for _, test := range []struct {
+		ctx  context.Context
+		req  interface{}
+		want codes.Code
+	}{
+		{clientContext("frontend"), &trillian.QueueLeavesRequest{LogId: 123}, codes.OK},
+		{clientContext("frontend"), &trillian.QueueLeavesRequest{LogId: 456}, codes.PermissionDenied},
+		{clientContext("frontend"), &trillian.GetLatestSignedLogRootRequest{LogId: 123}, codes.PermissionDenied},
+		{context.Background(), &trillian.QueueLeavesRequest{LogId: 123}, codes.Unauthenticated},
+		{clientContext("ops"), &trillian.CreateTreeRequest{}, codes.OK},
+		{clientContext("frontend"), &trillian.CreateTreeRequest{}, codes.PermissionDenied},
+		{clientContext("ops"), &trillian.GetTreeUsageRequest{TreeId: 123}, codes.OK},
+		{clientContext("frontend"), &trillian.GetTreeUsageRequest{TreeId: 123}, codes.PermissionDenied},
+		{clientContext("ops"), &trillian.ListAuditEventsRequest{}, codes.OK},
+		{clientContext("frontend"), &trillian.ListAuditEventsRequest{TreeId: 123}, codes.PermissionDenied},
+		{clientContext("ops"), &trillian.ReloadConfigRequest{}, codes.OK},
+		{clientContext("frontend"), &trillian.ReloadConfigRequest{}, codes.PermissionDenied},
+		{clientContext("monitor"), &trillian.StreamMutationsRequest{MapId: 123}, codes.OK},
+		{clientContext("monitor"), &trillian.StreamMutationsRequest{MapId: 456}, codes.PermissionDenied},
+		// Requests without known permissions are always refused
+		{clientContext("ops"), "unknown request", codes.PermissionDenied},
+	} {
+		called := false
+
+		_, err := interceptor(test.ctx, test.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("Got %v for %v from %q, want code %v", err, test.req, Identity(test.ctx), test.want)
+		}
+
+		if called != (test.want == codes.OK) {
+			t.Errorf("Handler called: %v for %v from %q, want %v", called, test.req, Identity(test.ctx), !called)
+		}
+	}
+}
+
+func TestUnaryServerInterceptorAllowsHealthChecks(t *testing.T) {
+	interceptor := UnaryServerInterceptor(Grants{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	if _, err := interceptor(context.Background(), "health check", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}); err != nil {
+		t.Errorf("Health check without a certificate = %v, want it allowed", err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(Grants{"frontend": {123: Read}})
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/StreamLeaves"}
+
+	for _, test := range []struct {
+		identity string
+		logID    int64
+		want     codes.Code
+	}{
+		{"frontend", 123, codes.OK},
+		{"frontend", 456, codes.PermissionDenied},
+	} {
+		stream := fakeStream{ctx: clientContext(test.identity), req: &trillian.StreamLeavesRequest{LogId: test.logID}}
+
+		err := interceptor(nil, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+			var req trillian.StreamLeavesRequest
+			return stream.RecvMsg(&req)
+		})
+
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("Got %v streaming log %d as %q, want code %v", err, test.logID, test.identity, test.want)
+		}
+	}
+}
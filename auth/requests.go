@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"github.com/google/trillian"
+)
+
+// RequiredPermission returns the tree a request is for and the permission needed on it. It
+// returns false for requests it doesn't know about. Requests that aren't for a single tree
+// are for AllTrees.
+func RequiredPermission(req interface{}) (int64, Permission, bool) {
+	switch r := req.(type) {
+	// Log requests
+	case *trillian.QueueLeavesRequest:
+		return r.LogId, Write, true
+	case *trillian.AddSequencedLeavesRequest:
+		return r.LogId, Write, true
+	case *trillian.AddCosignatureRequest:
+		return r.LogId, Write, true
+	case *trillian.GetInclusionProofRequest:
+		return r.LogId, Read, true
+	case *trillian.GetInclusionProofByHashRequest:
+		return r.LogId, Read, true
+	case *trillian.GetConsistencyProofRequest:
+		return r.LogId, Read, true
+	case *trillian.GetLatestSignedLogRootRequest:
+		return r.LogId, Read, true
+	case *trillian.GetSequencedLeafCountRequest:
+		return r.LogId, Read, true
+	case *trillian.GetLeavesByIndexRequest:
+		return r.LogId, Read, true
+	case *trillian.GetLeavesByRangeRequest:
+		return r.LogId, Read, true
+	case *trillian.GetLeavesByHashRequest:
+		return r.LogId, Read, true
+	case *trillian.GetLeavesByIdentityHashRequest:
+		return r.LogId, Read, true
+	case *trillian.GetEntryAndProofRequest:
+		return r.LogId, Read, true
+	case *trillian.StreamLeavesRequest:
+		return r.LogId, Read, true
+
+	// Map requests
+	case *trillian.SetMapLeavesRequest:
+		return r.MapId, Write, true
+	case *trillian.GetMapLeavesRequest:
+		return r.MapId, Read, true
+	case *trillian.GetSignedMapRootRequest:
+		return r.MapId, Read, true
+	case *trillian.GetSignedMapRootByRevisionRequest:
+		return r.MapId, Read, true
+	case *trillian.GetLeafHistoryRequest:
+		return r.MapId, Read, true
+	case *trillian.GetMapLeavesByPrefixRequest:
+		return r.MapId, Read, true
+	case *trillian.StreamMutationsRequest:
+		return r.MapId, Read, true
+
+	// Admin requests, creating or listing trees affects all of them
+	case *trillian.CreateTreeRequest:
+		return AllTrees, Admin, true
+	case *trillian.ListTreesRequest:
+		return AllTrees, Admin, true
+	case *trillian.GetTreeRequest:
+		return r.TreeId, Admin, true
+	case *trillian.UpdateTreeRequest:
+		if r.Tree == nil {
+			return AllTrees, Admin, true
+		}
+
+		return r.Tree.TreeId, Admin, true
+	case *trillian.FreezeTreeRequest:
+		return r.TreeId, Admin, true
+	case *trillian.DeleteTreeRequest:
+		return r.TreeId, Admin, true
+	case *trillian.UndeleteTreeRequest:
+		return r.TreeId, Admin, true
+	case *trillian.GetTreeUsageRequest:
+		return r.TreeId, Admin, true
+	case *trillian.ListAuditEventsRequest:
+		if r.TreeId == 0 {
+			return AllTrees, Admin, true
+		}
+
+		return r.TreeId, Admin, true
+	case *trillian.ReloadConfigRequest:
+		return AllTrees, Admin, true
+	}
+
+	return 0, 0, false
+}
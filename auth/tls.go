@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// NewServerTLSConfig returns the TLS configuration for a server with the PEM encoded
+// certificate and key in certFile and keyFile. If clientCAFile is set clients must present
+// a certificate signed by one of the CAs in it, otherwise they aren't asked for one.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to load server certificate: %v", err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if len(clientCAFile) == 0 {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCAFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to read client CAs: %v", err)
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("auth: no certificates found in %s", clientCAFile)
+	}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertAndKey writes a new self signed certificate and its key to files in dir
+func writeCertAndKey(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertAndKey(t, dir)
+
+	config, err := NewServerTLSConfig(certFile, keyFile, "")
+
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+
+	if len(config.Certificates) != 1 || config.ClientAuth != tls.NoClientCert {
+		t.Errorf("Got %d certificates and client auth %v, want 1 and no client certificates", len(config.Certificates), config.ClientAuth)
+	}
+
+	// Use the server's certificate as the client CA too
+	config, err = NewServerTLSConfig(certFile, keyFile, certFile)
+
+	if err != nil {
+		t.Fatalf("Failed to create mutual TLS config: %v", err)
+	}
+
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("Got client auth %v with CAs %v, want verified client certificates", config.ClientAuth, config.ClientCAs)
+	}
+
+	if _, err := NewServerTLSConfig(certFile, keyFile, keyFile); err == nil {
+		t.Errorf("Created TLS config with client CAs that aren't certificates")
+	}
+
+	if _, err := NewServerTLSConfig(filepath.Join(dir, "missing.pem"), keyFile, ""); err == nil {
+		t.Errorf("Created TLS config without a certificate")
+	}
+}
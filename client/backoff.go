@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// BackoffConfig controls how long a client waits between polls of a server
+type BackoffConfig struct {
+	// Initial is the wait before the first retry
+	Initial time.Duration
+	// Max is the longest wait, however many retries there have been
+	Max time.Duration
+	// Multiplier is how much the wait grows after each retry, it must be >= 1
+	Multiplier float64
+	// Jitter is the fraction of each wait, between 0 and 1, that's randomised so clients
+	// started together don't all poll at the same time
+	Jitter float64
+}
+
+// DefaultBackoff suits waiting for a log to integrate a leaf, which usually takes seconds
+var DefaultBackoff = BackoffConfig{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+func (b BackoffConfig) validate() error {
+	switch {
+	case b.Initial <= 0:
+		return fmt.Errorf("initial backoff must be > 0 but was %v", b.Initial)
+	case b.Max < b.Initial:
+		return fmt.Errorf("max backoff (%v) must be >= initial backoff (%v)", b.Max, b.Initial)
+	case b.Multiplier < 1:
+		return fmt.Errorf("backoff multiplier must be >= 1 but was %v", b.Multiplier)
+	case b.Jitter < 0 || b.Jitter > 1:
+		return fmt.Errorf("backoff jitter must be between 0 and 1 but was %v", b.Jitter)
+	}
+
+	return nil
+}
+
+// backoff hands out the waits for a series of retries
+type backoff struct {
+	config BackoffConfig
+	next   time.Duration
+	// random returns a number in [0, 1), it's replaced in tests
+	random func() float64
+}
+
+func newBackoff(config BackoffConfig) *backoff {
+	return &backoff{config: config, next: config.Initial, random: rand.Float64}
+}
+
+// duration returns the wait before the next retry
+func (b *backoff) duration() time.Duration {
+	d := b.next
+
+	grown := time.Duration(float64(b.next) * b.config.Multiplier)
+	if grown > b.config.Max {
+		grown = b.config.Max
+	}
+	b.next = grown
+
+	// Spread the wait evenly over d +/- Jitter*d
+	return d + time.Duration((b.random()*2-1)*b.config.Jitter*float64(d))
+}
+
+// wait sleeps until the next retry, returning early with an error if ctx is done first
+func (b *backoff) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(b.duration()):
+		return nil
+	}
+}
+
+// isRetryable returns true if err is a failure that might go away if the request is sent
+// again. Anything else, including a proof that doesn't verify, isn't retried.
+func isRetryable(err error) bool {
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestBackoffDuration(t *testing.T) {
+	b := newBackoff(BackoffConfig{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2})
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := b.duration(); got != want {
+			t.Errorf("Got wait %v for retry %d, expected %v", got, i, want)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := newBackoff(BackoffConfig{Initial: 10 * time.Second, Max: 10 * time.Second, Multiplier: 1, Jitter: 0.5})
+
+	for _, test := range []struct {
+		random float64
+		want   time.Duration
+	}{
+		{0, 5 * time.Second},
+		{0.5, 10 * time.Second},
+		{0.75, 12500 * time.Millisecond},
+	} {
+		b.random = func() float64 { return test.random }
+
+		if got := b.duration(); got != test.want {
+			t.Errorf("Got wait %v for random %v, expected %v", got, test.random, test.want)
+		}
+	}
+}
+
+func TestBackoffConfigValidate(t *testing.T) {
+	if err := DefaultBackoff.validate(); err != nil {
+		t.Errorf("Default backoff is invalid: %v", err)
+	}
+
+	for _, config := range []BackoffConfig{
+		{Initial: 0, Max: time.Second, Multiplier: 2},
+		{Initial: time.Second, Max: time.Millisecond, Multiplier: 2},
+		{Initial: time.Second, Max: time.Second, Multiplier: 0.5},
+		{Initial: time.Second, Max: time.Second, Multiplier: 2, Jitter: -0.1},
+		{Initial: time.Second, Max: time.Second, Multiplier: 2, Jitter: 1.5},
+	} {
+		if err := config.validate(); err == nil {
+			t.Errorf("Backoff config %+v validated", config)
+		}
+	}
+}
+
+func TestBackoffWaitStopsWhenContextDone(t *testing.T) {
+	b := newBackoff(BackoffConfig{Initial: time.Hour, Max: time.Hour, Multiplier: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := b.wait(ctx); err != context.Canceled {
+		t.Fatalf("Got error %v waiting with a cancelled context, expected %v", err, context.Canceled)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{grpc.Errorf(codes.Unavailable, "down"), true},
+		{grpc.Errorf(codes.ResourceExhausted, "quota"), true},
+		{grpc.Errorf(codes.InvalidArgument, "bad"), false},
+		{merkle.RootHashMismatchError{}, false},
+	} {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("Got retryable %v for %v, expected %v", got, test.err, test.want)
+		}
+	}
+}
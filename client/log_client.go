@@ -0,0 +1,372 @@
+// Package client provides clients for Trillian servers that check what the servers return
+// instead of trusting them.
+package client
+
+import (
+	gocrypto "crypto"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// LogClient talks to a Trillian log and verifies the signed roots and proofs it returns.
+// The latest root that has been verified is kept in a TrustStore, each new root must be
+// signed by the log and consistent with it, and inclusion proofs are checked against it.
+// The first root seen for a log is trusted if its signature is valid, so the store should
+// be seeded or persistent where that matters.
+type LogClient struct {
+	logID    int64
+	client   trillian.TrillianLogClient
+	hasher   merkle.TreeHasher
+	pubKeys  []gocrypto.PublicKey
+	verifier merkle.LogVerifier
+	store    TrustStore
+}
+
+// NewLogClient creates a LogClient for log logID that makes requests with client. Roots
+// must be signed with the private key for pubKey and proofs are checked with hasher.
+func NewLogClient(logID int64, client trillian.TrillianLogClient, hasher merkle.TreeHasher, pubKey gocrypto.PublicKey, store TrustStore) *LogClient {
+	return &LogClient{
+		logID:    logID,
+		client:   client,
+		hasher:   hasher,
+		pubKeys:  []gocrypto.PublicKey{pubKey},
+		verifier: merkle.NewLogVerifier(hasher),
+		store:    store,
+	}
+}
+
+// SetPublicKeys replaces the key given to NewLogClient with pubKeys, and roots signed with
+// any of them are accepted. This is for logs whose key is being rotated, which advertise
+// both the old and the new key until the rotation is complete.
+func (c *LogClient) SetPublicKeys(pubKeys []gocrypto.PublicKey) {
+	c.pubKeys = pubKeys
+}
+
+// Root returns the latest verified root of the log, nil if no root has been verified yet
+func (c *LogClient) Root() (*trillian.SignedLogRoot, error) {
+	return c.store.LatestRoot(c.logID)
+}
+
+// UpdateRoot fetches the latest root from the log, checks its signature and that it's
+// consistent with the latest verified root, and stores it as the latest verified root if
+// it's newer. It returns the latest verified root. An error means the log couldn't be
+// reached or it returned a root that doesn't verify, which for a log that's reachable is
+// evidence of misbehaviour.
+func (c *LogClient) UpdateRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	trusted, err := c.store.LatestRoot(c.logID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: c.logID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("log %d failed to get latest root: %v", c.logID, resp.Status)
+	}
+
+	if resp.SignedLogRoot == nil {
+		return nil, fmt.Errorf("log %d returned no root", c.logID)
+	}
+
+	root := *resp.SignedLogRoot
+
+	if err := c.verifyRoot(ctx, trusted, root); err != nil {
+		return nil, err
+	}
+
+	if trusted != nil && root.TreeSize == trusted.TreeSize && root.TimestampNanos <= trusted.TimestampNanos {
+		// Nothing new, keep the root we already have
+		return trusted, nil
+	}
+
+	if err := c.store.SetLatestRoot(c.logID, root); err != nil {
+		return nil, err
+	}
+
+	return &root, nil
+}
+
+// verifyRoot checks that root is signed by the log and is consistent with trusted, which
+// can be nil if no root has been verified yet
+func (c *LogClient) verifyRoot(ctx context.Context, trusted *trillian.SignedLogRoot, root trillian.SignedLogRoot) error {
+	if err := crypto.VerifySignedLogRootWithKeys(c.pubKeys, root); err != nil {
+		return fmt.Errorf("log %d root at size %d has a bad signature: %v", c.logID, root.TreeSize, err)
+	}
+
+	if trusted == nil {
+		return nil
+	}
+
+	if root.TreeSize < trusted.TreeSize {
+		return fmt.Errorf("log %d root size %d is smaller than verified size %d", c.logID, root.TreeSize, trusted.TreeSize)
+	}
+
+	var proof [][]byte
+
+	// Anything is consistent with the empty tree so there's no proof to fetch for it
+	if trusted.TreeSize > 0 && root.TreeSize > trusted.TreeSize {
+		resp, err := c.client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: c.logID, FirstTreeSize: trusted.TreeSize, SecondTreeSize: root.TreeSize})
+
+		if err != nil {
+			return err
+		}
+
+		if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+			return fmt.Errorf("log %d failed to get consistency proof: %v", c.logID, resp.Status)
+		}
+
+		if resp.Proof == nil {
+			return fmt.Errorf("log %d returned no consistency proof from %d to %d", c.logID, trusted.TreeSize, root.TreeSize)
+		}
+
+		proof = proofHashes(resp.Proof)
+	}
+
+	if err := c.verifier.VerifyConsistencyProof(trusted.TreeSize, root.TreeSize, trusted.RootHash, root.RootHash, proof); err != nil {
+		return fmt.Errorf("log %d root at size %d is not consistent with verified size %d: %v", c.logID, root.TreeSize, trusted.TreeSize, err)
+	}
+
+	return nil
+}
+
+// trustedRootCovering returns the latest verified root, updating it first if there isn't
+// one or it has fewer than treeSize entries
+func (c *LogClient) trustedRootCovering(ctx context.Context, treeSize int64) (*trillian.SignedLogRoot, error) {
+	root, err := c.store.LatestRoot(c.logID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if root == nil || root.TreeSize < treeSize {
+		if root, err = c.UpdateRoot(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if root.TreeSize < treeSize {
+		return nil, fmt.Errorf("log %d has %d entries, need at least %d", c.logID, root.TreeSize, treeSize)
+	}
+
+	return root, nil
+}
+
+// VerifyInclusion checks that the leaf with leafHash is in the log at the latest verified
+// root and returns its index. If the hash is in the log more than once the index of the
+// first proof that verifies is returned.
+func (c *LogClient) VerifyInclusion(ctx context.Context, leafHash []byte) (int64, error) {
+	root, err := c.trustedRootCovering(ctx, 1)
+
+	if err != nil {
+		return 0, err
+	}
+
+	proof, err := c.findInclusion(ctx, root, leafHash)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if proof == nil {
+		return 0, fmt.Errorf("log %d has no leaf with hash %x at size %d", c.logID, leafHash, root.TreeSize)
+	}
+
+	return proof.LeafIndex, nil
+}
+
+// findInclusion returns a verified proof that the leaf with leafHash is in the log at root,
+// nil if the log has no leaf with that hash at root's size
+func (c *LogClient) findInclusion(ctx context.Context, root *trillian.SignedLogRoot, leafHash []byte) (*trillian.ProofProto, error) {
+	if root.TreeSize == 0 {
+		return nil, nil
+	}
+
+	resp, err := c.client.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{LogId: c.logID, LeafHash: leafHash, TreeSize: root.TreeSize, OrderBySequence: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("log %d failed to get inclusion proof: %v", c.logID, resp.Status)
+	}
+
+	if len(resp.Proof) == 0 {
+		return nil, nil
+	}
+
+	for _, proof := range resp.Proof {
+		if err = c.verifier.VerifyInclusionProof(proof.LeafIndex, root.TreeSize, proofHashes(proof), root.RootHash, leafHash); err == nil {
+			return proof, nil
+		}
+	}
+
+	// None of the proofs verified, report the last failure
+	return nil, fmt.Errorf("log %d returned a bad inclusion proof for hash %x at size %d: %v", c.logID, leafHash, root.TreeSize, err)
+}
+
+// VerifyInclusionAtIndex checks that the leaf with leafHash is at leafIndex in the log at the
+// latest verified root. The root is updated first if it doesn't cover leafIndex.
+func (c *LogClient) VerifyInclusionAtIndex(ctx context.Context, leafHash []byte, leafIndex int64) error {
+	if leafIndex < 0 {
+		return fmt.Errorf("leaf index must be >= 0 but was %d", leafIndex)
+	}
+
+	root, err := c.trustedRootCovering(ctx, leafIndex+1)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: c.logID, LeafIndex: leafIndex, TreeSize: root.TreeSize})
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("log %d failed to get inclusion proof: %v", c.logID, resp.Status)
+	}
+
+	if resp.Proof == nil {
+		return fmt.Errorf("log %d returned no inclusion proof for leaf %d at size %d", c.logID, leafIndex, root.TreeSize)
+	}
+
+	if resp.Proof.LeafIndex != leafIndex {
+		return fmt.Errorf("log %d returned inclusion proof for leaf %d, expected %d", c.logID, resp.Proof.LeafIndex, leafIndex)
+	}
+
+	if err := c.verifier.VerifyInclusionProof(leafIndex, root.TreeSize, proofHashes(resp.Proof), root.RootHash, leafHash); err != nil {
+		return fmt.Errorf("log %d returned a bad inclusion proof for leaf %d at size %d: %v", c.logID, leafIndex, root.TreeSize, err)
+	}
+
+	return nil
+}
+
+// proofHashes returns the node hashes of proof in order
+func proofHashes(proof *trillian.ProofProto) [][]byte {
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+
+	for _, node := range proof.ProofNode {
+		hashes = append(hashes, node.NodeHash)
+	}
+
+	return hashes
+}
+
+// RejectedLeafError is returned when a log rejects a leaf. Result holds the details of why,
+// e.g. its RejectionCode.
+type RejectedLeafError struct {
+	LogID  int64
+	Result *trillian.QueuedLeaf
+}
+
+func (e *RejectedLeafError) Error() string {
+	return fmt.Sprintf("log %d rejected leaf: %s", e.LogID, e.Result.Reason)
+}
+
+// QueueLeaf hashes data and queues it to be added to the log. It returns the leaf that was
+// queued, or the copy already in the log if the log doesn't allow duplicates and has it.
+func (c *LogClient) QueueLeaf(ctx context.Context, data, extraData []byte) (*trillian.LeafProto, error) {
+	leaf := &trillian.LeafProto{LeafHash: c.hasher.HashLeaf(data), LeafData: data, ExtraData: extraData}
+	resp, err := c.client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: c.logID, Leaves: []*trillian.LeafProto{leaf}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("log %d failed to queue leaf: %v", c.logID, resp.Status)
+	}
+
+	if len(resp.Leaves) != 1 {
+		return nil, fmt.Errorf("log %d returned %d results for 1 leaf", c.logID, len(resp.Leaves))
+	}
+
+	switch result := resp.Leaves[0]; result.Status {
+	case trillian.QueuedLeafStatus_QUEUED:
+		return leaf, nil
+	case trillian.QueuedLeafStatus_DUPLICATE:
+		if result.ExistingLeaf == nil {
+			return leaf, nil
+		}
+
+		return result.ExistingLeaf, nil
+	case trillian.QueuedLeafStatus_REJECTED:
+		return nil, &RejectedLeafError{LogID: c.logID, Result: result}
+	default:
+		return nil, fmt.Errorf("log %d returned unknown status %v for leaf", c.logID, result.Status)
+	}
+}
+
+// WaitForInclusion polls the log until the leaf with leafHash is in a verified root, then
+// returns the root and the verified proof against it. Polls back off as set by config, and
+// failures that might be temporary are retried. It returns when the leaf is found, ctx is
+// done, or the log returns something that doesn't verify.
+func (c *LogClient) WaitForInclusion(ctx context.Context, leafHash []byte, config BackoffConfig) (*trillian.ProofProto, *trillian.SignedLogRoot, error) {
+	if err := config.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	b := newBackoff(config)
+
+	for {
+		proof, root, err := c.pollInclusion(ctx, leafHash)
+
+		switch {
+		case err == nil && proof != nil:
+			return proof, root, nil
+		case err != nil && !isRetryable(err):
+			return nil, nil, err
+		case err != nil:
+			glog.Warningf("Failed to check log %d for leaf %x, will retry: %v", c.logID, leafHash, err)
+		}
+
+		if err := b.wait(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// pollInclusion updates the verified root and looks for the leaf with leafHash in it
+func (c *LogClient) pollInclusion(ctx context.Context, leafHash []byte) (*trillian.ProofProto, *trillian.SignedLogRoot, error) {
+	root, err := c.UpdateRoot(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := c.findInclusion(ctx, root, leafHash)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return proof, root, nil
+}
+
+// AddLeaf queues data to be added to the log and waits until it's in a verified root, as
+// for QueueLeaf and WaitForInclusion.
+func (c *LogClient) AddLeaf(ctx context.Context, data, extraData []byte, config BackoffConfig) (*trillian.ProofProto, *trillian.SignedLogRoot, error) {
+	if err := config.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	leaf, err := c.QueueLeaf(ctx, data, extraData)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.WaitForInclusion(ctx, leaf.LeafHash, config)
+}
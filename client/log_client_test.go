@@ -0,0 +1,512 @@
+package client
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const testLogID = 42
+
+var okStatus = &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+
+// fakeLogClient serves roots and proofs for the first size leaves of tree. Only the
+// methods used by LogClient are implemented.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	t      *testing.T
+	hasher merkle.TreeHasher
+	key    *ecdsa.PrivateKey
+	tree   *merkle.InMemoryMerkleTree
+	leaves [][]byte
+	size   int64
+	// corruptProofs makes every proof returned have its first node changed
+	corruptProofs bool
+	// growOnPoll makes each request for the latest root integrate one more leaf, if there
+	// are any waiting
+	growOnPoll bool
+	// rootErrs are returned, in order, by the next requests for the latest root
+	rootErrs []error
+	// queueResult is returned for each leaf queued, if set
+	queueResult *trillian.QueuedLeaf
+}
+
+func newFakeLogClient(t *testing.T, numLeaves int) *fakeLogClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	f := &fakeLogClient{t: t, hasher: hasher, key: key, tree: merkle.NewInMemoryMerkleTree(hasher)}
+
+	for i := 0; i < numLeaves; i++ {
+		leaf := []byte(fmt.Sprintf("leaf %d", i))
+		f.tree.AddLeaf(leaf)
+		f.leaves = append(f.leaves, leaf)
+	}
+
+	f.size = int64(numLeaves)
+
+	return f
+}
+
+func (f *fakeLogClient) signedRoot(size int64, key *ecdsa.PrivateKey) *trillian.SignedLogRoot {
+	root := trillian.SignedLogRoot{TreeSize: size, RootHash: f.tree.RootAtSnapshot(int(size)).Hash(), TimestampNanos: 1000 + size}
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, key).SignLogRoot(root)
+
+	if err != nil {
+		f.t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	root.Signature = &sig
+
+	return &root
+}
+
+func (f *fakeLogClient) proof(descriptors []merkle.TreeEntryDescriptor) []*trillian.NodeProto {
+	nodes := make([]*trillian.NodeProto, 0, len(descriptors))
+
+	for _, d := range descriptors {
+		nodes = append(nodes, &trillian.NodeProto{NodeHash: d.Value.Hash()})
+	}
+
+	if f.corruptProofs && len(nodes) > 0 {
+		nodes[0].NodeHash = f.hasher.HashLeaf(nodes[0].NodeHash)
+	}
+
+	return nodes
+}
+
+func (f *fakeLogClient) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	if len(f.rootErrs) > 0 {
+		err := f.rootErrs[0]
+		f.rootErrs = f.rootErrs[1:]
+		return nil, err
+	}
+
+	if f.growOnPoll && f.size < int64(len(f.leaves)) {
+		f.size++
+	}
+
+	return &trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: f.signedRoot(f.size, f.key)}, nil
+}
+
+func (f *fakeLogClient) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	nodes := f.proof(f.tree.SnapshotConsistency(int(req.FirstTreeSize), int(req.SecondTreeSize)))
+	return &trillian.GetConsistencyProofResponse{Status: okStatus, Proof: &trillian.ProofProto{ProofNode: nodes}}, nil
+}
+
+func (f *fakeLogClient) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	// The in memory tree numbers leaves from 1
+	nodes := f.proof(f.tree.PathToRootAtSnapshot(int(req.LeafIndex+1), int(req.TreeSize)))
+	return &trillian.GetInclusionProofResponse{Status: okStatus, Proof: &trillian.ProofProto{LeafIndex: req.LeafIndex, ProofNode: nodes}}, nil
+}
+
+func (f *fakeLogClient) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp := &trillian.GetInclusionProofByHashResponse{Status: okStatus}
+
+	for i := int64(0); i < req.TreeSize; i++ {
+		if string(f.hasher.HashLeaf(f.leaves[i])) == string(req.LeafHash) {
+			nodes := f.proof(f.tree.PathToRootAtSnapshot(int(i+1), int(req.TreeSize)))
+			resp.Proof = append(resp.Proof, &trillian.ProofProto{LeafIndex: i, ProofNode: nodes})
+		}
+	}
+
+	return resp, nil
+}
+
+func (f *fakeLogClient) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	resp := &trillian.QueueLeavesResponse{Status: okStatus}
+
+	for _, leaf := range req.Leaves {
+		if string(leaf.LeafHash) != string(f.hasher.HashLeaf(leaf.LeafData)) {
+			f.t.Fatalf("Leaf queued with hash %x, expected the hash of its data", leaf.LeafHash)
+		}
+
+		if f.queueResult != nil {
+			resp.Leaves = append(resp.Leaves, f.queueResult)
+			continue
+		}
+
+		f.tree.AddLeaf(leaf.LeafData)
+		f.leaves = append(f.leaves, leaf.LeafData)
+		resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED})
+	}
+
+	return resp, nil
+}
+
+// testBackoff keeps tests fast
+var testBackoff = BackoffConfig{Initial: time.Millisecond, Max: 4 * time.Millisecond, Multiplier: 2, Jitter: 0.1}
+
+func newTestLogClient(f *fakeLogClient, store TrustStore) *LogClient {
+	return NewLogClient(testLogID, f, f.hasher, f.key.Public(), store)
+}
+
+func TestUpdateRoot(t *testing.T) {
+	f := newFakeLogClient(t, 10)
+	store := NewMemoryTrustStore()
+	c := newTestLogClient(f, store)
+
+	for _, size := range []int64{0, 1, 3, 3, 8, 10} {
+		f.size = size
+		root, err := c.UpdateRoot(context.Background())
+
+		if err != nil {
+			t.Fatalf("Failed to update root at size %d: %v", size, err)
+		}
+
+		if got, want := root.TreeSize, size; got != want {
+			t.Errorf("Got root for size %d, expected %d", got, want)
+		}
+
+		stored, err := store.LatestRoot(testLogID)
+
+		if err != nil {
+			t.Fatalf("Failed to get stored root: %v", err)
+		}
+
+		if stored == nil || stored.TreeSize != size {
+			t.Errorf("Stored root %v after update to size %d", stored, size)
+		}
+	}
+}
+
+func TestUpdateRootRejectsBadSignature(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	store := NewMemoryTrustStore()
+	c := NewLogClient(testLogID, f, f.hasher, otherKey.Public(), store)
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("Got error %v for root signed with another key, expected a signature error", err)
+	}
+
+	if root, _ := store.LatestRoot(testLogID); root != nil {
+		t.Errorf("Stored root %v that failed to verify", root)
+	}
+}
+
+func TestUpdateRootAcrossKeyRotation(t *testing.T) {
+	f := newFakeLogClient(t, 6)
+	oldKey := f.key
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	c := newTestLogClient(f, NewMemoryTrustStore())
+	c.SetPublicKeys([]gocrypto.PublicKey{oldKey.Public(), newKey.Public()})
+
+	// The log switches to the new key after its first root
+	for _, step := range []struct {
+		size int64
+		key  *ecdsa.PrivateKey
+	}{{2, oldKey}, {4, newKey}, {6, newKey}} {
+		f.size, f.key = step.size, step.key
+
+		if _, err := c.UpdateRoot(context.Background()); err != nil {
+			t.Fatalf("Failed to update root at size %d: %v", step.size, err)
+		}
+	}
+
+	// Once the old key is retired roots signed with it aren't accepted
+	c.SetPublicKeys([]gocrypto.PublicKey{newKey.Public()})
+	f.key = oldKey
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil {
+		t.Fatal("Accepted a root signed with a retired key")
+	}
+}
+
+func TestUpdateRootRejectsSmallerTree(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	store := NewMemoryTrustStore()
+	c := newTestLogClient(f, store)
+
+	if _, err := c.UpdateRoot(context.Background()); err != nil {
+		t.Fatalf("Failed to update root: %v", err)
+	}
+
+	f.size = 3
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil {
+		t.Fatal("Accepted a root for a smaller tree")
+	}
+
+	if root, _ := store.LatestRoot(testLogID); root.TreeSize != 4 {
+		t.Errorf("Stored root at size %d, expected 4", root.TreeSize)
+	}
+}
+
+func TestUpdateRootRejectsInconsistentRoot(t *testing.T) {
+	f := newFakeLogClient(t, 8)
+	store := NewMemoryTrustStore()
+	c := newTestLogClient(f, store)
+
+	// Trust a root for a tree that's been forked from the one the log now serves
+	fork := newFakeLogClient(t, 0)
+
+	for i := 0; i < 5; i++ {
+		fork.tree.AddLeaf([]byte(fmt.Sprintf("forked leaf %d", i)))
+	}
+
+	if err := store.SetLatestRoot(testLogID, *fork.signedRoot(5, f.key)); err != nil {
+		t.Fatalf("Failed to store root: %v", err)
+	}
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil || !strings.Contains(err.Error(), "not consistent") {
+		t.Fatalf("Got error %v for forked tree, expected a consistency error", err)
+	}
+
+	// And a bad proof for the right tree is no better
+	store = NewMemoryTrustStore()
+	c = newTestLogClient(f, store)
+	f.size = 5
+
+	if _, err := c.UpdateRoot(context.Background()); err != nil {
+		t.Fatalf("Failed to update root: %v", err)
+	}
+
+	f.size = 8
+	f.corruptProofs = true
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil {
+		t.Fatal("Accepted root with a bad consistency proof")
+	}
+
+	if root, _ := store.LatestRoot(testLogID); root.TreeSize != 5 {
+		t.Errorf("Stored root at size %d, expected 5", root.TreeSize)
+	}
+}
+
+func TestUpdateRootRejectsChangedRootAtSameSize(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	store := NewMemoryTrustStore()
+	c := newTestLogClient(f, store)
+
+	root := f.signedRoot(4, f.key)
+	root.RootHash = f.hasher.HashLeaf(root.RootHash)
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, f.key).SignLogRoot(*root)
+
+	if err != nil {
+		t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	root.Signature = &sig
+
+	if err := store.SetLatestRoot(testLogID, *root); err != nil {
+		t.Fatalf("Failed to store root: %v", err)
+	}
+
+	if _, err := c.UpdateRoot(context.Background()); err == nil {
+		t.Fatal("Accepted a different root for the same tree size")
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	f := newFakeLogClient(t, 7)
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	for i, leaf := range f.leaves {
+		index, err := c.VerifyInclusion(context.Background(), f.hasher.HashLeaf(leaf))
+
+		if err != nil {
+			t.Errorf("Failed to verify inclusion of leaf %d: %v", i, err)
+			continue
+		}
+
+		if index != int64(i) {
+			t.Errorf("Got index %d for leaf %d", index, i)
+		}
+	}
+
+	if _, err := c.VerifyInclusion(context.Background(), f.hasher.HashLeaf([]byte("not in the log"))); err == nil {
+		t.Error("Verified inclusion of a leaf that isn't in the log")
+	}
+
+	f.corruptProofs = true
+
+	if _, err := c.VerifyInclusion(context.Background(), f.hasher.HashLeaf(f.leaves[3])); err == nil {
+		t.Error("Verified inclusion with a bad proof")
+	}
+}
+
+func TestVerifyInclusionAtIndex(t *testing.T) {
+	f := newFakeLogClient(t, 7)
+	store := NewMemoryTrustStore()
+	c := newTestLogClient(f, store)
+	f.size = 3
+
+	if _, err := c.UpdateRoot(context.Background()); err != nil {
+		t.Fatalf("Failed to update root: %v", err)
+	}
+
+	f.size = 7
+
+	// Leaf 5 isn't covered by the trusted root so it has to be updated first
+	if err := c.VerifyInclusionAtIndex(context.Background(), f.hasher.HashLeaf(f.leaves[5]), 5); err != nil {
+		t.Fatalf("Failed to verify inclusion of leaf 5: %v", err)
+	}
+
+	if root, _ := store.LatestRoot(testLogID); root.TreeSize != 7 {
+		t.Errorf("Stored root at size %d, expected 7", root.TreeSize)
+	}
+
+	if err := c.VerifyInclusionAtIndex(context.Background(), f.hasher.HashLeaf(f.leaves[5]), 4); err == nil {
+		t.Error("Verified inclusion of leaf 5 at index 4")
+	}
+
+	if err := c.VerifyInclusionAtIndex(context.Background(), f.hasher.HashLeaf(f.leaves[0]), 7); err == nil {
+		t.Error("Verified inclusion past the end of the log")
+	}
+
+	f.corruptProofs = true
+
+	if err := c.VerifyInclusionAtIndex(context.Background(), f.hasher.HashLeaf(f.leaves[2]), 2); err == nil {
+		t.Error("Verified inclusion with a bad proof")
+	}
+}
+
+func TestAddLeaf(t *testing.T) {
+	f := newFakeLogClient(t, 3)
+	f.growOnPoll = true
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	proof, root, err := c.AddLeaf(context.Background(), []byte("new leaf"), nil, testBackoff)
+
+	if err != nil {
+		t.Fatalf("Failed to add leaf: %v", err)
+	}
+
+	if proof.LeafIndex != 3 {
+		t.Errorf("Got proof for leaf %d, expected 3", proof.LeafIndex)
+	}
+
+	if root.TreeSize != 4 {
+		t.Errorf("Got root at size %d, expected 4", root.TreeSize)
+	}
+}
+
+func TestWaitForInclusionRetriesTemporaryFailures(t *testing.T) {
+	f := newFakeLogClient(t, 0)
+	f.growOnPoll = true
+	f.rootErrs = []error{grpc.Errorf(codes.Unavailable, "down"), grpc.Errorf(codes.ResourceExhausted, "busy")}
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	leaf, err := c.QueueLeaf(context.Background(), []byte("new leaf"), []byte("extra"))
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaf: %v", err)
+	}
+
+	proof, _, err := c.WaitForInclusion(context.Background(), leaf.LeafHash, testBackoff)
+
+	if err != nil {
+		t.Fatalf("Failed to wait for inclusion: %v", err)
+	}
+
+	if proof.LeafIndex != 0 {
+		t.Errorf("Got proof for leaf %d, expected 0", proof.LeafIndex)
+	}
+}
+
+func TestWaitForInclusionStopsOnBadProof(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	f.corruptProofs = true
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	if _, _, err := c.WaitForInclusion(context.Background(), f.hasher.HashLeaf(f.leaves[1]), testBackoff); err == nil || !strings.Contains(err.Error(), "bad inclusion proof") {
+		t.Fatalf("Got error %v, expected a bad proof error", err)
+	}
+}
+
+func TestWaitForInclusionStopsOnPermanentFailure(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	f.rootErrs = []error{grpc.Errorf(codes.PermissionDenied, "no")}
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	if _, _, err := c.WaitForInclusion(context.Background(), f.hasher.HashLeaf(f.leaves[1]), testBackoff); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Got error %v, expected PermissionDenied", err)
+	}
+}
+
+func TestWaitForInclusionStopsWhenContextDone(t *testing.T) {
+	f := newFakeLogClient(t, 4)
+	c := newTestLogClient(f, NewMemoryTrustStore())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The leaf is never integrated
+	if _, _, err := c.WaitForInclusion(ctx, f.hasher.HashLeaf([]byte("never added")), testBackoff); err != context.DeadlineExceeded {
+		t.Fatalf("Got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueueLeafResults(t *testing.T) {
+	existing := &trillian.LeafProto{LeafHash: []byte("hash"), LeafIndex: 7}
+
+	for _, test := range []struct {
+		result  *trillian.QueuedLeaf
+		want    *trillian.LeafProto
+		wantErr bool
+	}{
+		{result: &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: existing}, want: existing},
+		{result: &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_REJECTED, Reason: "too big"}, wantErr: true},
+		{result: &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_UNKNOWN_QUEUED_LEAF_STATUS}, wantErr: true},
+	} {
+		f := newFakeLogClient(t, 0)
+		f.queueResult = test.result
+		c := newTestLogClient(f, NewMemoryTrustStore())
+
+		leaf, err := c.QueueLeaf(context.Background(), []byte("data"), nil)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Queued leaf with result %v", test.result)
+			}
+			continue
+		}
+
+		if err != nil || leaf != test.want {
+			t.Errorf("Got leaf %v and error %v for result %v, expected %v", leaf, err, test.result, test.want)
+		}
+	}
+}
+
+func TestQueueLeafRejectedError(t *testing.T) {
+	f := newFakeLogClient(t, 0)
+	f.queueResult = &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_REJECTED, Reason: "too big", RejectionCode: trillian.LeafRejectionCode_TOO_LARGE, MaxLeafSize: 1024}
+	c := newTestLogClient(f, NewMemoryTrustStore())
+
+	_, err := c.QueueLeaf(context.Background(), []byte("data"), nil)
+
+	rejected, ok := err.(*RejectedLeafError)
+	if !ok {
+		t.Fatalf("Got error %v, expected a *RejectedLeafError", err)
+	}
+
+	if rejected.Result.RejectionCode != trillian.LeafRejectionCode_TOO_LARGE || rejected.Result.MaxLeafSize != 1024 {
+		t.Errorf("Got rejection %v, expected the log's details", rejected.Result)
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"fmt"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// maxCachedMapRoots is how many verified map roots a MapClient keeps
+const maxCachedMapRoots = 100
+
+// MapClient talks to a Trillian map and verifies the values and proofs it returns against
+// signed map roots. Roots that have been verified are cached by revision, so a map that
+// returns a different root for a revision it has already served is caught.
+type MapClient struct {
+	mapID    int64
+	client   trillian.TrillianMapClient
+	pubKeys  []gocrypto.PublicKey
+	verifier merkle.MapVerifier
+
+	// mu guards roots
+	mu    sync.Mutex
+	roots map[int64]trillian.SignedMapRoot
+}
+
+// NewMapClient creates a MapClient for map mapID that makes requests with client. Roots
+// must be signed with the private key for pubKey and proofs are checked with hasher, which
+// must be the one the map was created with.
+func NewMapClient(mapID int64, client trillian.TrillianMapClient, hasher merkle.MapHasher, pubKey gocrypto.PublicKey) *MapClient {
+	return &MapClient{
+		mapID:    mapID,
+		client:   client,
+		pubKeys:  []gocrypto.PublicKey{pubKey},
+		verifier: merkle.NewMapVerifier(hasher),
+		roots:    make(map[int64]trillian.SignedMapRoot),
+	}
+}
+
+// SetPublicKeys replaces the key given to NewMapClient with pubKeys, and roots signed with
+// any of them are accepted, as they are while the map's key is being rotated.
+func (c *MapClient) SetPublicKeys(pubKeys []gocrypto.PublicKey) {
+	c.pubKeys = pubKeys
+}
+
+// Root returns the verified root at revision, nil if it isn't cached
+func (c *MapClient) Root(revision int64) *trillian.SignedMapRoot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	root, ok := c.roots[revision]
+
+	if !ok {
+		return nil
+	}
+
+	return &root
+}
+
+// GetLeaves fetches the values of keys at revision, or at the latest revision if revision
+// is < 0, and checks each of them against the signed map root they came with. It returns
+// the values in the same order as keys, nil for a key that has no value in the map, along
+// with the verified root.
+func (c *MapClient) GetLeaves(ctx context.Context, keys [][]byte, revision int64) ([][]byte, *trillian.SignedMapRoot, error) {
+	resp, err := c.client.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: c.mapID, Key: keys, Revision: revision})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.MapRoot == nil {
+		return nil, nil, fmt.Errorf("map %d returned no root", c.mapID)
+	}
+
+	if revision >= 0 && resp.MapRoot.MapRevision != revision {
+		return nil, nil, fmt.Errorf("map %d returned root for revision %d, expected %d", c.mapID, resp.MapRoot.MapRevision, revision)
+	}
+
+	if err := c.verifyRoot(*resp.MapRoot); err != nil {
+		return nil, nil, err
+	}
+
+	if len(resp.KeyValue) != len(keys) {
+		return nil, nil, fmt.Errorf("map %d returned %d values for %d keys", c.mapID, len(resp.KeyValue), len(keys))
+	}
+
+	values := make([][]byte, 0, len(keys))
+
+	for i, kvi := range resp.KeyValue {
+		if kvi.KeyValue == nil || !bytes.Equal(kvi.KeyValue.Key, keys[i]) {
+			return nil, nil, fmt.Errorf("map %d returned value %d for the wrong key", c.mapID, i)
+		}
+
+		var value []byte
+
+		if kvi.KeyValue.Value != nil {
+			value = kvi.KeyValue.Value.LeafValue
+		}
+
+		if err := c.verifier.VerifyMapInclusion(resp.MapRoot.RootHash, keys[i], value, kvi.Inclusion); err != nil {
+			return nil, nil, fmt.Errorf("map %d returned a bad proof for key %x at revision %d: %v", c.mapID, keys[i], resp.MapRoot.MapRevision, err)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, resp.MapRoot, nil
+}
+
+// GetLeaf fetches the value of key at the latest revision and checks it against the signed
+// map root. The value is nil if the key has no value in the map.
+func (c *MapClient) GetLeaf(ctx context.Context, key []byte) ([]byte, error) {
+	values, _, err := c.GetLeaves(ctx, [][]byte{key}, -1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return values[0], nil
+}
+
+// verifyRoot checks the signature of root, or that it's the same as the verified root
+// cached for its revision, and caches it
+func (c *MapClient) verifyRoot(root trillian.SignedMapRoot) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.roots[root.MapRevision]; ok {
+		if !bytes.Equal(cached.RootHash, root.RootHash) {
+			return fmt.Errorf("map %d returned a different root for revision %d: %v", c.mapID, root.MapRevision, merkle.RootHashMismatchError{ExpectedHash: cached.RootHash, ActualHash: root.RootHash})
+		}
+
+		return nil
+	}
+
+	if err := crypto.VerifySignedMapRootWithKeys(c.pubKeys, root); err != nil {
+		return fmt.Errorf("map %d root at revision %d has a bad signature: %v", c.mapID, root.MapRevision, err)
+	}
+
+	if len(c.roots) >= maxCachedMapRoots {
+		// Make room by dropping the oldest revision, it's the least likely to be asked for
+		oldest := root.MapRevision
+
+		for rev := range c.roots {
+			if rev < oldest {
+				oldest = rev
+			}
+		}
+
+		if oldest == root.MapRevision {
+			// root is older than everything cached, so it isn't kept
+			return nil
+		}
+
+		delete(c.roots, oldest)
+	}
+
+	c.roots[root.MapRevision] = root
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const testMapID = 43
+
+// nodeStoreTX keeps the nodes of a sparse merkle tree in memory for one revision
+type nodeStoreTX struct {
+	storage.TreeTX
+	mu    sync.Mutex
+	nodes map[string]storage.Node
+}
+
+func (n *nodeStoreTX) GetMerkleNodes(ctx context.Context, rev int64, ids []storage.NodeID) ([]storage.Node, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var nodes []storage.Node
+
+	for _, id := range ids {
+		if node, ok := n.nodes[id.String()]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes, nil
+}
+
+func (n *nodeStoreTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, node := range nodes {
+		n.nodes[node.NodeID.String()] = node
+	}
+
+	return nil
+}
+
+func (n *nodeStoreTX) Commit() error {
+	return nil
+}
+
+// fakeMapClient serves values and proofs from a sparse merkle tree holding values. Only the
+// methods used by MapClient are implemented.
+type fakeMapClient struct {
+	trillian.TrillianMapClient
+	hasher merkle.MapHasher
+	key    *ecdsa.PrivateKey
+	values map[string]string
+	tx     *nodeStoreTX
+	root   trillian.SignedMapRoot
+	// tamper is called on each response before it's returned, if set
+	tamper func(*trillian.GetMapLeavesResponse)
+}
+
+func newFakeMapClient(t *testing.T, revision int64, values map[string]string) *fakeMapClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	hasher := merkle.NewMapHasher(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	f := &fakeMapClient{hasher: hasher, key: key, values: values, tx: &nodeStoreTX{nodes: make(map[string]storage.Node)}}
+	w, err := merkle.NewSparseMerkleTreeWriter(context.Background(), revision, hasher, func() (storage.TreeTX, error) { return f.tx, nil })
+
+	if err != nil {
+		t.Fatalf("Failed to create tree writer: %v", err)
+	}
+
+	var leaves []merkle.HashKeyValue
+
+	for k, v := range values {
+		leaves = append(leaves, merkle.HashKeyValue{HashedKey: hasher.HashKey([]byte(k)), HashedValue: hasher.HashLeaf([]byte(v))})
+	}
+
+	if err := w.SetLeaves(leaves); err != nil {
+		t.Fatalf("Failed to set leaves: %v", err)
+	}
+
+	rootHash, err := w.CalculateRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to calculate root: %v", err)
+	}
+
+	f.root = f.signedRoot(t, trillian.SignedMapRoot{MapRevision: revision, RootHash: rootHash, TimestampNanos: 1000})
+
+	return f
+}
+
+func (f *fakeMapClient) signedRoot(t *testing.T, root trillian.SignedMapRoot) trillian.SignedMapRoot {
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, f.key).SignMapRoot(root)
+
+	if err != nil {
+		t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	root.Signature = &sig
+
+	return root
+}
+
+func (f *fakeMapClient) GetLeaves(ctx context.Context, req *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	keys := make([]trillian.Key, 0, len(req.Key))
+
+	for _, key := range req.Key {
+		keys = append(keys, key)
+	}
+
+	proofs, err := merkle.NewSparseMerkleTreeReader(f.root.MapRevision, f.hasher, f.tx).BatchInclusionProof(ctx, f.root.MapRevision, keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	root := f.root
+	resp := &trillian.GetMapLeavesResponse{MapRoot: &root}
+
+	for i, key := range req.Key {
+		kv := &trillian.KeyValue{Key: key}
+
+		if v, ok := f.values[string(key)]; ok {
+			kv.Value = &trillian.MapLeaf{KeyHash: f.hasher.HashKey(key), LeafValue: []byte(v)}
+		}
+
+		var inclusion [][]byte
+
+		for _, h := range proofs[i] {
+			inclusion = append(inclusion, h)
+		}
+
+		resp.KeyValue = append(resp.KeyValue, &trillian.KeyValueInclusion{KeyValue: kv, Inclusion: inclusion})
+	}
+
+	if f.tamper != nil {
+		f.tamper(resp)
+	}
+
+	return resp, nil
+}
+
+func newTestMapClient(f *fakeMapClient) *MapClient {
+	return NewMapClient(testMapID, f, f.hasher, f.key.Public())
+}
+
+func TestMapClientGetLeaves(t *testing.T) {
+	f := newFakeMapClient(t, 5, map[string]string{"key1": "value1", "key2": "value2", "key3": "value3"})
+	c := newTestMapClient(f)
+
+	keys := [][]byte{[]byte("key2"), []byte("absent"), []byte("key1")}
+	values, root, err := c.GetLeaves(context.Background(), keys, -1)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	for i, want := range []string{"value2", "", "value1"} {
+		if got := string(values[i]); got != want {
+			t.Errorf("Got value %q for key %s, expected %q", got, keys[i], want)
+		}
+	}
+
+	if values[1] != nil {
+		t.Errorf("Got value %v for absent key, expected nil", values[1])
+	}
+
+	if root.MapRevision != 5 {
+		t.Errorf("Got root for revision %d, expected 5", root.MapRevision)
+	}
+
+	if cached := c.Root(5); cached == nil || string(cached.RootHash) != string(f.root.RootHash) {
+		t.Errorf("Got cached root %v, expected %v", cached, f.root)
+	}
+}
+
+func TestMapClientGetLeaf(t *testing.T) {
+	f := newFakeMapClient(t, 5, map[string]string{"key1": "value1", "key2": "value2"})
+	c := newTestMapClient(f)
+
+	value, err := c.GetLeaf(context.Background(), []byte("key2"))
+
+	if err != nil || string(value) != "value2" {
+		t.Errorf("Got value %q and error %v for key2, expected value2", value, err)
+	}
+
+	value, err = c.GetLeaf(context.Background(), []byte("absent"))
+
+	if err != nil || value != nil {
+		t.Errorf("Got value %v and error %v for absent key, expected nil", value, err)
+	}
+}
+
+func TestMapClientRejectsBadResponses(t *testing.T) {
+	tests := []struct {
+		desc   string
+		tamper func(*trillian.GetMapLeavesResponse)
+		errStr string
+	}{
+		{
+			desc:   "changed value",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.KeyValue[0].KeyValue.Value.LeafValue = []byte("value2") },
+			errStr: "bad proof",
+		},
+		{
+			desc:   "value hidden",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.KeyValue[0].KeyValue.Value = nil },
+			errStr: "bad proof",
+		},
+		{
+			desc:   "changed proof node",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.KeyValue[1].Inclusion[255] = []byte("bad node") },
+			errStr: "bad proof",
+		},
+		{
+			desc:   "wrong key",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.KeyValue[0].KeyValue.Key = []byte("key2") },
+			errStr: "wrong key",
+		},
+		{
+			desc:   "missing value",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.KeyValue = r.KeyValue[:1] },
+			errStr: "2 keys",
+		},
+		{
+			desc:   "missing root",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.MapRoot = nil },
+			errStr: "no root",
+		},
+		{
+			desc:   "unsigned root",
+			tamper: func(r *trillian.GetMapLeavesResponse) { r.MapRoot.Signature = nil },
+			errStr: "signature",
+		},
+		{
+			desc: "changed root",
+			tamper: func(r *trillian.GetMapLeavesResponse) {
+				r.MapRoot.RootHash = append([]byte{}, r.MapRoot.RootHash...)
+				r.MapRoot.RootHash[0] ^= 1
+			},
+			errStr: "signature",
+		},
+	}
+
+	for _, test := range tests {
+		f := newFakeMapClient(t, 5, map[string]string{"key1": "value1", "key2": "value2"})
+		f.tamper = test.tamper
+		c := newTestMapClient(f)
+
+		_, _, err := c.GetLeaves(context.Background(), [][]byte{[]byte("key1"), []byte("absent")}, -1)
+
+		if err == nil || !strings.Contains(err.Error(), test.errStr) {
+			t.Errorf("%s: got error %v, expected one containing %q", test.desc, err, test.errStr)
+		}
+	}
+}
+
+func TestMapClientRejectsWrongRevision(t *testing.T) {
+	f := newFakeMapClient(t, 5, map[string]string{"key1": "value1"})
+	c := newTestMapClient(f)
+
+	if _, _, err := c.GetLeaves(context.Background(), [][]byte{[]byte("key1")}, 4); err == nil {
+		t.Fatal("Accepted root for revision 5 when asking for revision 4")
+	}
+}
+
+func TestMapClientRejectsChangedRootAtSameRevision(t *testing.T) {
+	f := newFakeMapClient(t, 5, map[string]string{"key1": "value1"})
+	c := newTestMapClient(f)
+
+	if _, _, err := c.GetLeaves(context.Background(), [][]byte{[]byte("key1")}, 5); err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	// The map now signs a different tree for the same revision
+	other := newFakeMapClient(t, 5, map[string]string{"key1": "other value"})
+	f.values = other.values
+	f.tx = other.tx
+	f.root = f.signedRoot(t, trillian.SignedMapRoot{MapRevision: 5, RootHash: other.root.RootHash, TimestampNanos: 1000})
+
+	if _, _, err := c.GetLeaves(context.Background(), [][]byte{[]byte("key1")}, 5); err == nil || !strings.Contains(err.Error(), "different root") {
+		t.Fatalf("Got error %v for a different root at the same revision, expected a root mismatch", err)
+	}
+}
+
+func TestMapClientCachesNewestRoots(t *testing.T) {
+	f := newFakeMapClient(t, 1, map[string]string{})
+	c := newTestMapClient(f)
+
+	for rev := int64(1); rev <= maxCachedMapRoots+1; rev++ {
+		if err := c.verifyRoot(f.signedRoot(t, trillian.SignedMapRoot{MapRevision: rev, RootHash: f.root.RootHash})); err != nil {
+			t.Fatalf("Failed to verify root at revision %d: %v", rev, err)
+		}
+	}
+
+	if c.Root(1) != nil {
+		t.Error("Oldest root is still cached")
+	}
+
+	if c.Root(2) == nil || c.Root(maxCachedMapRoots+1) == nil {
+		t.Error("Newest roots aren't cached")
+	}
+
+	// A root older than everything cached is verified but not kept
+	if err := c.verifyRoot(f.signedRoot(t, trillian.SignedMapRoot{MapRevision: 1, RootHash: f.root.RootHash})); err != nil {
+		t.Fatalf("Failed to verify old root: %v", err)
+	}
+
+	if c.Root(1) != nil {
+		t.Error("Old root was cached")
+	}
+}
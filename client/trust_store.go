@@ -0,0 +1,53 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// TrustStore keeps the latest verified root of each log that a LogClient talks to. It
+// should be persistent if the client must notice a log rolling back between runs.
+type TrustStore interface {
+	// LatestRoot returns the latest verified root of log logID, nil if there isn't one yet
+	LatestRoot(logID int64) (*trillian.SignedLogRoot, error)
+	// SetLatestRoot records root as the latest verified root of log logID
+	SetLatestRoot(logID int64, root trillian.SignedLogRoot) error
+}
+
+// MemoryTrustStore is a TrustStore that only lasts as long as the process
+type MemoryTrustStore struct {
+	mu    sync.Mutex
+	roots map[int64]trillian.SignedLogRoot
+}
+
+// NewMemoryTrustStore creates an empty MemoryTrustStore
+func NewMemoryTrustStore() *MemoryTrustStore {
+	return &MemoryTrustStore{roots: make(map[int64]trillian.SignedLogRoot)}
+}
+
+// LatestRoot implements TrustStore
+func (m *MemoryTrustStore) LatestRoot(logID int64) (*trillian.SignedLogRoot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	root, ok := m.roots[logID]
+
+	if !ok {
+		return nil, nil
+	}
+
+	// Callers get their own copy so they can't change what's stored
+	return proto.Clone(&root).(*trillian.SignedLogRoot), nil
+}
+
+// SetLatestRoot implements TrustStore
+func (m *MemoryTrustStore) SetLatestRoot(logID int64, root trillian.SignedLogRoot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roots[logID] = *proto.Clone(&root).(*trillian.SignedLogRoot)
+
+	return nil
+}
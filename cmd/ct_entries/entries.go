@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// ctEntry is an entry as returned by the get-entries call of the RFC 6962 API. The byte
+// slices are base64 encoded in JSON, as CT logs encode them.
+type ctEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+func checkStatus(op string, status *trillian.TrillianApiStatus) error {
+	if status == nil || status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("%s failed: %v", op, status)
+	}
+
+	return nil
+}
+
+// Export writes the leaves of a log from start to w as a get-entries response, a JSON object
+// with an "entries" array. count limits the number of leaves written, zero means all those
+// up to the latest signed root. The leaf data becomes the leaf_input of each entry and the
+// extra data its extra_data. It returns the number of entries written.
+func Export(ctx context.Context, client trillian.TrillianLogClient, logID, start, count int64, w io.Writer) (int64, error) {
+	if start < 0 || count < 0 {
+		return 0, fmt.Errorf("invalid start %d or count %d", start, count)
+	}
+
+	out := bufio.NewWriter(w)
+
+	if _, err := out.WriteString(`{"entries":[`); err != nil {
+		return 0, err
+	}
+
+	req := &trillian.GetLeavesByRangeRequest{LogId: logID, StartIndex: start, Count: count}
+	next := start
+
+	for {
+		resp, err := client.GetLeavesByRange(ctx, req)
+
+		if err != nil {
+			return next - start, err
+		}
+
+		if err := checkStatus("GetLeavesByRange", resp.Status); err != nil {
+			return next - start, err
+		}
+
+		for _, leaf := range resp.Leaves {
+			if leaf.LeafIndex != next {
+				return next - start, fmt.Errorf("log %d returned leaf %d, expected %d", logID, leaf.LeafIndex, next)
+			}
+
+			if err := writeEntry(out, next > start, leaf); err != nil {
+				return next - start, err
+			}
+
+			next++
+		}
+
+		if len(resp.NextPageToken) == 0 {
+			break
+		}
+
+		req = &trillian.GetLeavesByRangeRequest{LogId: logID, PageToken: resp.NextPageToken}
+		glog.V(1).Infof("Exported leaves %d to %d", start, next-1)
+	}
+
+	if _, err := out.WriteString("]}\n"); err != nil {
+		return next - start, err
+	}
+
+	return next - start, out.Flush()
+}
+
+// writeEntry writes leaf as an entry of the array, after a comma unless it's the first
+func writeEntry(w *bufio.Writer, comma bool, leaf *trillian.LeafProto) error {
+	// CT clients expect a string, not null, for an entry without extra data
+	entry := ctEntry{LeafInput: leaf.LeafData, ExtraData: leaf.ExtraData}
+	if entry.ExtraData == nil {
+		entry.ExtraData = []byte{}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if comma {
+		if err := w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// Import adds the entries of a get-entries response read from r to a pre-ordered log, the
+// first at index start and the rest after it in the order they appear. Leaf hashes are
+// computed from the leaf_input of the entries as RFC 6962 does. The entries are added
+// batchSize at a time and the dump is read as it's added, so it needn't fit in memory.
+//
+// Entries the log already has with the same leaf hash are skipped, so an import that
+// failed part way can be run again. It returns the number of entries in the dump it got
+// through.
+func Import(ctx context.Context, client trillian.TrillianLogClient, logID, start int64, batchSize int, r io.Reader) (int64, error) {
+	if start < 0 || batchSize <= 0 {
+		return 0, fmt.Errorf("invalid start %d or batch size %d", start, batchSize)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	batch := make([]*trillian.LeafProto, 0, batchSize)
+	next := start
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := addLeaves(ctx, client, logID, batch); err != nil {
+			return err
+		}
+
+		glog.V(1).Infof("Imported leaves %d to %d", batch[0].LeafIndex, next-1)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readEntries(r, func(entry ctEntry) error {
+		batch = append(batch, &trillian.LeafProto{LeafIndex: next, LeafHash: hasher.HashLeaf(entry.LeafInput), LeafData: entry.LeafInput, ExtraData: entry.ExtraData})
+		next++
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		return next - start - int64(len(batch)), err
+	}
+
+	return next - start, nil
+}
+
+// readEntries decodes a get-entries response from r and calls f with each entry in turn.
+// Other fields of the response are skipped.
+func readEntries(r io.Reader, f func(ctEntry) error) error {
+	dec := json.NewDecoder(r)
+	found := false
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if key, _ := token.(string); key != "entries" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		found = true
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+
+		for i := 0; dec.More(); i++ {
+			var entry ctEntry
+			if err := dec.Decode(&entry); err != nil {
+				return fmt.Errorf("invalid entry %d: %v", i, err)
+			}
+
+			if err := f(entry); err != nil {
+				return err
+			}
+		}
+
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("dump has no entries array")
+	}
+
+	return nil
+}
+
+// expectDelim reads the next token from dec and checks that it's delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("invalid get-entries dump: got %v, expected %v", token, delim)
+	}
+
+	return nil
+}
+
+// addLeaves adds leaves to the log at their indices
+func addLeaves(ctx context.Context, client trillian.TrillianLogClient, logID int64, leaves []*trillian.LeafProto) error {
+	resp, err := client.AddSequencedLeaves(ctx, &trillian.AddSequencedLeavesRequest{LogId: logID, Leaves: leaves})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("AddSequencedLeaves", resp.Status); err != nil {
+		return err
+	}
+
+	if len(resp.Leaves) != len(leaves) {
+		return fmt.Errorf("log returned %d results for %d leaves", len(resp.Leaves), len(leaves))
+	}
+
+	for i, result := range resp.Leaves {
+		leaf := leaves[i]
+
+		switch result.Status {
+		case trillian.QueuedLeafStatus_QUEUED:
+		case trillian.QueuedLeafStatus_DUPLICATE:
+			// Added by an earlier import of the same dump
+			if result.ExistingLeaf == nil || !bytes.Equal(result.ExistingLeaf.LeafHash, leaf.LeafHash) {
+				return fmt.Errorf("log already has a different leaf at index %d", leaf.LeafIndex)
+			}
+		default:
+			return fmt.Errorf("log did not accept leaf %d: %v: %s", leaf.LeafIndex, result.Status, result.Reason)
+		}
+	}
+
+	return nil
+}
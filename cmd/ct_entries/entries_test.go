@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeLogClient is a log held in memory. Only GetLeavesByRange and AddSequencedLeaves are
+// implemented, and pages have at most pageSize leaves.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	leaves   map[int64]*trillian.LeafProto
+	size     int64
+	pageSize int64
+	requests int
+}
+
+func newFakeLogClient() *fakeLogClient {
+	return &fakeLogClient{leaves: make(map[int64]*trillian.LeafProto), pageSize: 3}
+}
+
+func okStatus() *trillian.TrillianApiStatus {
+	return &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+}
+
+func (c *fakeLogClient) GetLeavesByRange(ctx context.Context, req *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	c.requests++
+	start, end := req.StartIndex, c.size
+
+	if len(req.PageToken) > 0 {
+		if _, err := fmt.Sscanf(string(req.PageToken), "%d-%d", &start, &end); err != nil {
+			return nil, err
+		}
+	} else if req.Count > 0 && start+req.Count < end {
+		end = start + req.Count
+	}
+
+	resp := &trillian.GetLeavesByRangeResponse{Status: okStatus()}
+
+	for i := start; i < end && i < start+c.pageSize; i++ {
+		resp.Leaves = append(resp.Leaves, c.leaves[i])
+	}
+
+	if next := start + int64(len(resp.Leaves)); next < end {
+		resp.NextPageToken = []byte(fmt.Sprintf("%d-%d", next, end))
+	}
+
+	return resp, nil
+}
+
+func (c *fakeLogClient) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest, opts ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	c.requests++
+	resp := &trillian.AddSequencedLeavesResponse{Status: okStatus()}
+
+	for _, leaf := range req.Leaves {
+		if existing, ok := c.leaves[leaf.LeafIndex]; ok {
+			resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: existing})
+			continue
+		}
+
+		c.leaves[leaf.LeafIndex] = leaf
+		if leaf.LeafIndex >= c.size {
+			c.size = leaf.LeafIndex + 1
+		}
+		resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED})
+	}
+
+	return resp, nil
+}
+
+// addTestLeaves adds n leaves to the end of the log, every other one with extra data
+func (c *fakeLogClient) addTestLeaves(n int) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for i := 0; i < n; i++ {
+		leaf := &trillian.LeafProto{LeafIndex: c.size, LeafData: []byte(fmt.Sprintf("leaf %d", c.size))}
+		leaf.LeafHash = hasher.HashLeaf(leaf.LeafData)
+		if c.size%2 == 0 {
+			leaf.ExtraData = []byte(fmt.Sprintf("extra %d", c.size))
+		}
+
+		c.leaves[c.size] = leaf
+		c.size++
+	}
+}
+
+func TestExport(t *testing.T) {
+	client := newFakeLogClient()
+	client.addTestLeaves(10)
+
+	for _, test := range []struct {
+		start, count int64
+		want         []int64
+	}{
+		{start: 0, count: 0, want: []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{start: 4, count: 0, want: []int64{4, 5, 6, 7, 8, 9}},
+		{start: 2, count: 5, want: []int64{2, 3, 4, 5, 6}},
+		{start: 10, count: 0, want: []int64{}},
+	} {
+		var b bytes.Buffer
+		n, err := Export(context.Background(), client, 1, test.start, test.count, &b)
+
+		if err != nil {
+			t.Fatalf("Export(%d, %d)=%v", test.start, test.count, err)
+		}
+		if got, want := n, int64(len(test.want)); got != want {
+			t.Errorf("Export(%d, %d) wrote %d entries, want %d", test.start, test.count, got, want)
+		}
+
+		var resp struct {
+			Entries []ctEntry `json:"entries"`
+		}
+		if err := json.Unmarshal(b.Bytes(), &resp); err != nil {
+			t.Fatalf("Export(%d, %d) wrote invalid JSON: %v: %s", test.start, test.count, err, b.String())
+		}
+		if got, want := len(resp.Entries), len(test.want); got != want {
+			t.Fatalf("Export(%d, %d) wrote %d entries, want %d", test.start, test.count, got, want)
+		}
+
+		for i, index := range test.want {
+			leaf := client.leaves[index]
+			if got, want := resp.Entries[i].LeafInput, leaf.LeafData; !bytes.Equal(got, want) {
+				t.Errorf("Export(%d, %d) entry %d leaf_input=%q, want %q", test.start, test.count, i, got, want)
+			}
+			if got, want := resp.Entries[i].ExtraData, leaf.ExtraData; !bytes.Equal(got, want) {
+				t.Errorf("Export(%d, %d) entry %d extra_data=%q, want %q", test.start, test.count, i, got, want)
+			}
+		}
+	}
+}
+
+func TestExportWritesEmptyExtraData(t *testing.T) {
+	client := newFakeLogClient()
+	client.addTestLeaves(2)
+
+	var b bytes.Buffer
+	if _, err := Export(context.Background(), client, 1, 1, 1, &b); err != nil {
+		t.Fatalf("Export()=%v", err)
+	}
+
+	if got, want := b.String(), `{"entries":[{"leaf_input":"bGVhZiAx","extra_data":""}]}`+"\n"; got != want {
+		t.Errorf("Export() wrote %s, want %s", got, want)
+	}
+}
+
+func TestExportMissingLeaf(t *testing.T) {
+	client := newFakeLogClient()
+	client.addTestLeaves(5)
+	client.leaves[3] = client.leaves[4]
+
+	if _, err := Export(context.Background(), client, 1, 0, 0, &bytes.Buffer{}); err == nil {
+		t.Error("Export() with a missing leaf succeeded")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := newFakeLogClient()
+	source.addTestLeaves(11)
+
+	var dump bytes.Buffer
+	if _, err := Export(context.Background(), source, 1, 0, 0, &dump); err != nil {
+		t.Fatalf("Export()=%v", err)
+	}
+
+	dest := newFakeLogClient()
+	n, err := Import(context.Background(), dest, 2, 0, 4, &dump)
+
+	if err != nil {
+		t.Fatalf("Import()=%v", err)
+	}
+	if got, want := n, int64(11); got != want {
+		t.Errorf("Import() imported %d entries, want %d", got, want)
+	}
+	// 11 entries in batches of 4
+	if got, want := dest.requests, 3; got != want {
+		t.Errorf("Import() made %d requests, want %d", got, want)
+	}
+
+	for i := int64(0); i < source.size; i++ {
+		want, got := source.leaves[i], dest.leaves[i]
+
+		if got == nil {
+			t.Errorf("leaf %d was not imported", i)
+			continue
+		}
+		if !bytes.Equal(got.LeafHash, want.LeafHash) || !bytes.Equal(got.LeafData, want.LeafData) || !bytes.Equal(got.ExtraData, want.ExtraData) {
+			t.Errorf("leaf %d imported as %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestImportAtStart(t *testing.T) {
+	client := newFakeLogClient()
+	dump := `{"entries":[{"leaf_input":"YQ==","extra_data":"eA=="},{"leaf_input":"Yg==","extra_data":""}]}`
+
+	if _, err := Import(context.Background(), client, 1, 5, 10, strings.NewReader(dump)); err != nil {
+		t.Fatalf("Import()=%v", err)
+	}
+
+	if got, want := len(client.leaves), 2; got != want {
+		t.Fatalf("Import() added %d leaves, want %d", got, want)
+	}
+	if got, want := client.leaves[5].LeafData, []byte("a"); !bytes.Equal(got, want) {
+		t.Errorf("leaf 5 has data %q, want %q", got, want)
+	}
+	if got, want := client.leaves[6].LeafData, []byte("b"); !bytes.Equal(got, want) {
+		t.Errorf("leaf 6 has data %q, want %q", got, want)
+	}
+	if got, want := client.leaves[5].ExtraData, []byte("x"); !bytes.Equal(got, want) {
+		t.Errorf("leaf 5 has extra data %q, want %q", got, want)
+	}
+}
+
+func TestImportSkipsOtherFields(t *testing.T) {
+	client := newFakeLogClient()
+	dump := `{"sth":{"tree_size":1},"entries":[{"leaf_input":"YQ==","extra_data":""}],"comment":"x"}`
+
+	n, err := Import(context.Background(), client, 1, 0, 10, strings.NewReader(dump))
+
+	if err != nil {
+		t.Fatalf("Import()=%v", err)
+	}
+	if got, want := n, int64(1); got != want {
+		t.Errorf("Import() imported %d entries, want %d", got, want)
+	}
+}
+
+func TestImportResumes(t *testing.T) {
+	source := newFakeLogClient()
+	source.addTestLeaves(6)
+
+	var dump bytes.Buffer
+	if _, err := Export(context.Background(), source, 1, 0, 0, &dump); err != nil {
+		t.Fatalf("Export()=%v", err)
+	}
+
+	// The first leaves were added by an earlier import that failed
+	dest := newFakeLogClient()
+	for i := int64(0); i < 3; i++ {
+		dest.leaves[i] = source.leaves[i]
+	}
+
+	if _, err := Import(context.Background(), dest, 2, 0, 4, bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatalf("Import()=%v", err)
+	}
+	if got, want := len(dest.leaves), 6; got != want {
+		t.Errorf("Import() left %d leaves, want %d", got, want)
+	}
+
+	// A dump with different entries conflicts with the leaves already there
+	other := `{"entries":[{"leaf_input":"YQ==","extra_data":""}]}`
+	if _, err := Import(context.Background(), dest, 2, 0, 4, strings.NewReader(other)); err == nil {
+		t.Error("Import() over different leaves succeeded")
+	}
+}
+
+func TestImportInvalidDump(t *testing.T) {
+	for _, dump := range []string{
+		``,
+		`[]`,
+		`{}`,
+		`{"entries":{}}`,
+		`{"entries":[{"leaf_input":"not base64"}]}`,
+		`{"entries":[{"leaf_input":"YQ=="}`,
+	} {
+		if _, err := Import(context.Background(), newFakeLogClient(), 1, 0, 10, strings.NewReader(dump)); err == nil {
+			t.Errorf("Import(%q) succeeded", dump)
+		}
+	}
+}
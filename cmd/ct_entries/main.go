@@ -0,0 +1,84 @@
+// The ct_entries binary converts between Trillian logs and the JSON that CT logs return from
+// get-entries, to move a log between Trillian and another CT implementation. With
+// --mode=export it writes the leaves of a log as a get-entries response, and with
+// --mode=import it adds the entries of such a dump to a pre-ordered log at their indices.
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var modeFlag = flag.String("mode", "export", "Either export, to dump the leaves of a log, or import, to add the entries of a dump to a pre-ordered log")
+var logServerFlag = flag.String("log_server", "localhost:8090", "Address of the Trillian log server")
+var logIDFlag = flag.Int64("log_id", 0, "Tree id of the log to export or import into")
+var fileFlag = flag.String("file", "", "File to write the dump to or read it from, stdout or stdin if empty")
+var startFlag = flag.Int64("start", 0, "Index of the first leaf to export, or the index the first entry is imported at")
+var countFlag = flag.Int64("count", 0, "Max number of leaves to export, zero for all those up to the latest signed root")
+var batchSizeFlag = flag.Int("batch_size", 100, "Max number of entries to import per request")
+
+func main() {
+	flag.Parse()
+
+	if *logIDFlag == 0 {
+		glog.Fatal("The log must be given with --log_id")
+	}
+
+	conn, err := grpc.Dial(*logServerFlag, grpc.WithInsecure())
+
+	if err != nil {
+		glog.Fatalf("Failed to connect to log server %s: %v", *logServerFlag, err)
+	}
+
+	defer conn.Close()
+
+	client := trillian.NewTrillianLogClient(conn)
+	ctx := context.Background()
+
+	switch *modeFlag {
+	case "export":
+		var w io.Writer = os.Stdout
+
+		if len(*fileFlag) > 0 {
+			f, err := os.Create(*fileFlag)
+			if err != nil {
+				glog.Fatalf("Failed to create %s: %v", *fileFlag, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		n, err := Export(ctx, client, *logIDFlag, *startFlag, *countFlag, w)
+		if err != nil {
+			glog.Fatalf("Export failed after %d entries: %v", n, err)
+		}
+
+		glog.Infof("Exported %d entries of log %d", n, *logIDFlag)
+	case "import":
+		var r io.Reader = os.Stdin
+
+		if len(*fileFlag) > 0 {
+			f, err := os.Open(*fileFlag)
+			if err != nil {
+				glog.Fatalf("Failed to open %s: %v", *fileFlag, err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		n, err := Import(ctx, client, *logIDFlag, *startFlag, *batchSizeFlag, r)
+		if err != nil {
+			glog.Fatalf("Import failed after %d entries, run again with the same flags to resume: %v", n, err)
+		}
+
+		glog.Infof("Imported %d entries into log %d", n, *logIDFlag)
+	default:
+		glog.Fatalf("Unknown mode: %s", *modeFlag)
+	}
+}
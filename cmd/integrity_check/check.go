@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// maxTreeDepth is the depth of the node IDs the sequencer writes log nodes with
+const maxTreeDepth = 64
+
+// NodeCorruption is a node whose stored hash isn't the one recomputed from the leaves.
+// Stored is nil if the node is missing.
+type NodeCorruption struct {
+	Depth    int
+	Index    int64
+	Stored   trillian.Hash
+	Computed trillian.Hash
+}
+
+func (c NodeCorruption) String() string {
+	if c.Stored == nil {
+		return fmt.Sprintf("node at depth %d index %d is missing, recomputed %x", c.Depth, c.Index, c.Computed)
+	}
+
+	return fmt.Sprintf("node at depth %d index %d has hash %x, recomputed %x", c.Depth, c.Index, c.Stored, c.Computed)
+}
+
+type byCoords []NodeCorruption
+
+func (c byCoords) Len() int      { return len(c) }
+func (c byCoords) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCoords) Less(i, j int) bool {
+	if c[i].Depth != c[j].Depth {
+		return c[i].Depth < c[j].Depth
+	}
+
+	return c[i].Index < c[j].Index
+}
+
+// Report is the outcome of checking a log at its latest signed root
+type Report struct {
+	TreeSize     int64
+	TreeRevision int64
+	// RootHash is the hash in the signed root, ComputedRootHash the one recomputed from the
+	// leaves
+	RootHash         trillian.Hash
+	ComputedRootHash trillian.Hash
+	// Nodes are the corrupt nodes, ordered by depth then index
+	Nodes []NodeCorruption
+}
+
+// OK returns true if no corruption was found
+func (r Report) OK() bool {
+	return bytes.Equal(r.RootHash, r.ComputedRootHash) && len(r.Nodes) == 0
+}
+
+// node is a node hash recomputed from the leaves
+type node struct {
+	id    storage.NodeID
+	depth int
+	index int64
+	hash  trillian.Hash
+}
+
+// checker recomputes the nodes of a log from its leaf hashes, as the sequencer does, and
+// compares them with the stored nodes. Reads of the stored nodes go through the populate
+// function of the storage, so internal nodes of subtrees that were stored without them are
+// recomputed from the subtree leaves.
+type checker struct {
+	s         storage.LogStorage
+	batchSize int64
+	root      trillian.SignedLogRoot
+	tree      *merkle.CompactMerkleTree
+	// pending holds the nodes on the right edge of the tree so far, which are rewritten as
+	// the tree grows, so they can only be checked once all of its leaves have been added.
+	// Storage doesn't have to keep them, it only has to keep the nodes of perfect subtrees,
+	// so they're only checked if they're there. The recomputed root covers them anyway.
+	pending map[string]node
+	report  Report
+}
+
+// checkLog checks the stored nodes and leaves of s against its latest signed root, reading
+// batchSize leaves at a time. Errors are returned for failures to read storage and for
+// leaves that are missing, corruption of the hashes is in the Report.
+func checkLog(ctx context.Context, s storage.LogStorage, batchSize int64) (*Report, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be > 0 but was %d", batchSize)
+	}
+
+	hasher, err := merkle.NewTreeHasher(s.HashAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	tx.Commit()
+
+	if err != nil {
+		glog.Warningf("Failed to read latest signed root: %v", err)
+		return nil, err
+	}
+
+	c := &checker{
+		s:         s,
+		batchSize: batchSize,
+		root:      root,
+		tree:      merkle.NewCompactMerkleTree(hasher),
+		pending:   make(map[string]node),
+		report:    Report{TreeSize: root.TreeSize, TreeRevision: root.TreeRevision, RootHash: root.RootHash},
+	}
+
+	for start := int64(0); start < root.TreeSize; start += batchSize {
+		if err := c.checkBatch(ctx, start); err != nil {
+			return nil, err
+		}
+	}
+
+	final := make([]node, 0, len(c.pending))
+	for _, n := range c.pending {
+		final = append(final, n)
+	}
+
+	if err := c.compare(ctx, final, false); err != nil {
+		return nil, err
+	}
+
+	c.report.ComputedRootHash = c.tree.CurrentRoot()
+	sort.Sort(byCoords(c.report.Nodes))
+	return &c.report, nil
+}
+
+// checkBatch adds the leaves from start to the tree and checks the nodes they complete
+func (c *checker) checkBatch(ctx context.Context, start int64) error {
+	count := c.batchSize
+	if start+count > c.root.TreeSize {
+		count = c.root.TreeSize - start
+	}
+
+	tx, err := c.s.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	leaves, err := tx.GetLeavesByRange(ctx, start, count)
+	tx.Commit()
+
+	if err != nil {
+		glog.Warningf("Failed to read leaves [%d, %d): %v", start, start+count, err)
+		return err
+	}
+
+	if int64(len(leaves)) != count {
+		return fmt.Errorf("read %d leaves from %d, expected %d, the rest are missing", len(leaves), start, count)
+	}
+
+	batch := make(map[string]node)
+	var nodeErr error
+
+	record := func(depth int, index int64, hash trillian.Hash) {
+		id, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+		if err != nil {
+			nodeErr = err
+			return
+		}
+
+		batch[id.String()] = node{id: id, depth: depth, index: index, hash: hash}
+	}
+
+	for l, leaf := range leaves {
+		if want := start + int64(l); leaf.SequenceNumber != want {
+			return fmt.Errorf("read leaf %d where leaf %d should be", leaf.SequenceNumber, want)
+		}
+
+		seq := c.tree.AddLeafHash(leaf.LeafHash, record)
+		record(0, seq, leaf.LeafHash)
+	}
+
+	if nodeErr != nil {
+		return nodeErr
+	}
+
+	// The nodes of perfect subtrees are final, so they're checked now
+	size := start + count
+	complete := make([]node, 0, len(batch))
+
+	for key, n := range batch {
+		if (n.index+1)<<uint(n.depth) <= size {
+			complete = append(complete, n)
+			delete(c.pending, key)
+		} else {
+			c.pending[key] = n
+		}
+	}
+
+	return c.compare(ctx, complete, true)
+}
+
+// compare reads the stored hashes of nodes at the revision of the root and records those
+// that don't match, and those that are missing if required
+func (c *checker) compare(ctx context.Context, nodes []node, required bool) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	ids := make([]storage.NodeID, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.id)
+	}
+
+	tx, err := c.s.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	stored, err := tx.GetMerkleNodes(ctx, c.root.TreeRevision, ids)
+	tx.Commit()
+
+	if err != nil {
+		glog.Warningf("Failed to read %d nodes at revision %d: %v", len(ids), c.root.TreeRevision, err)
+		return err
+	}
+
+	hashes := make(map[string]trillian.Hash, len(stored))
+	for _, s := range stored {
+		hashes[s.NodeID.String()] = s.Hash
+	}
+
+	for _, n := range nodes {
+		h, ok := hashes[n.id.String()]
+
+		if !ok && !required {
+			continue
+		}
+
+		if !bytes.Equal(h, n.hash) {
+			c.report.Nodes = append(c.report.Nodes, NodeCorruption{Depth: n.depth, Index: n.index, Stored: h, Computed: n.hash})
+		}
+	}
+
+	return nil
+}
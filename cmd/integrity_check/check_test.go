@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// treeSize is enough leaves to fill a subtree and start the next
+const treeSize = 300
+
+var signedTimestamp = trillian.SignedEntryTimestamp{Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+// databases counts the memory databases created, so each test gets a new one
+var databases int
+
+// createLog returns memory storage holding a log of size leaves, sequenced a few at a time
+// so that the nodes are written at several revisions
+func createLog(t *testing.T, size int) storage.LogStorage {
+	ctx := context.Background()
+	databases++
+
+	p, err := storage.NewProvider(memory.ProviderName, fmt.Sprintf("%s-%d", t.Name(), databases))
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("check"), TreeID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, size)
+	for l := 0; l < size; l++ {
+		value := []byte(fmt.Sprintf("Leaf %d", l))
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(value), LeafValue: value}, SignedEntryTimestamp: signedTimestamp})
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, s, km)
+
+	if err := sequencer.SignRoot(ctx); err != nil {
+		t.Fatalf("SignRoot() = %v", err)
+	}
+
+	for integrated := 0; integrated < size; {
+		n, err := sequencer.SequenceBatch(ctx, 37, func(trillian.SignedLogRoot) bool { return false })
+		if err != nil {
+			t.Fatalf("SequenceBatch() = %v", err)
+		}
+
+		integrated += n
+	}
+
+	return s
+}
+
+// rewrite stores nodes, and a root that's root with a new revision and timestamp, so that
+// the nodes replace the stored ones at the revision of the new root
+func rewrite(t *testing.T, s storage.LogStorage, nodes []storage.Node, rootHash trillian.Hash) {
+	ctx := context.Background()
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = %v", err)
+	}
+
+	for i := range nodes {
+		nodes[i].NodeRevision = tx.WriteRevision()
+	}
+
+	if len(nodes) > 0 {
+		if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+			t.Fatalf("SetMerkleNodes() = %v", err)
+		}
+	}
+
+	root.TreeRevision = tx.WriteRevision()
+	root.TimestampNanos++
+
+	if rootHash != nil {
+		root.RootHash = rootHash
+	}
+
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+		t.Fatalf("StoreSignedLogRoot() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+}
+
+func mustNodeID(t *testing.T, depth, index int64) storage.NodeID {
+	id, err := storage.NewNodeIDForTreeCoords(depth, index, maxTreeDepth)
+	if err != nil {
+		t.Fatalf("Failed to create node ID: %v", err)
+	}
+
+	return id
+}
+
+func TestCheckLogFindsNoCorruption(t *testing.T) {
+	for _, size := range []int{0, 1, 255, 256, treeSize} {
+		s := createLog(t, size)
+
+		for _, batchSize := range []int64{1, 7, 1024} {
+			report, err := checkLog(context.Background(), s, batchSize)
+			if err != nil {
+				t.Fatalf("Size %d, batch size %d: checkLog() = %v", size, batchSize, err)
+			}
+
+			if report.TreeSize != int64(size) {
+				t.Errorf("Size %d, batch size %d: checked %d leaves", size, batchSize, report.TreeSize)
+			}
+
+			if !report.OK() {
+				t.Errorf("Size %d, batch size %d: found corruption in log: %+v", size, batchSize, report)
+			}
+		}
+	}
+}
+
+func TestCheckLogReportsCorruptLeafNode(t *testing.T) {
+	s := createLog(t, treeSize)
+	rewrite(t, s, []storage.Node{{NodeID: mustNodeID(t, 0, 5), Hash: []byte("corrupt")}}, nil)
+
+	report, err := checkLog(context.Background(), s, 64)
+	if err != nil {
+		t.Fatalf("checkLog() = %v", err)
+	}
+
+	if report.OK() {
+		t.Fatal("Found no corruption in log with a corrupt leaf node")
+	}
+
+	if first := report.Nodes[0]; first.Depth != 0 || first.Index != 5 || string(first.Stored) != "corrupt" {
+		t.Errorf("First corrupt node is %v, expected the one at depth 0 index 5", first)
+	}
+
+	// The internal nodes above it are recomputed from it when the subtree is read, up to the
+	// top of its subtree
+	for _, n := range report.Nodes {
+		if n.Depth >= 8 || n.Index != 5>>uint(n.Depth) {
+			t.Errorf("Reported %v, which isn't above the corrupt node in its subtree", n)
+		}
+	}
+
+	// The root is recomputed from the leaves, which aren't corrupt
+	if string(report.RootHash) != string(report.ComputedRootHash) {
+		t.Errorf("Root hash %x doesn't match recomputed %x", report.RootHash, report.ComputedRootHash)
+	}
+}
+
+func TestCheckLogReportsCorruptSubtreeRoot(t *testing.T) {
+	s := createLog(t, treeSize)
+	rewrite(t, s, []storage.Node{{NodeID: mustNodeID(t, 8, 0), Hash: []byte("corrupt")}}, nil)
+
+	report, err := checkLog(context.Background(), s, 1024)
+	if err != nil {
+		t.Fatalf("checkLog() = %v", err)
+	}
+
+	if len(report.Nodes) != 1 || report.Nodes[0].Depth != 8 || report.Nodes[0].Index != 0 {
+		t.Errorf("Reported %v, expected only the node at depth 8 index 0", report.Nodes)
+	}
+}
+
+func TestCheckLogReportsWrongRoot(t *testing.T) {
+	s := createLog(t, treeSize)
+	rewrite(t, s, nil, []byte("wrong root"))
+
+	report, err := checkLog(context.Background(), s, 1024)
+	if err != nil {
+		t.Fatalf("checkLog() = %v", err)
+	}
+
+	if report.OK() || len(report.Nodes) != 0 {
+		t.Errorf("Got report %+v, expected only the root to be wrong", report)
+	}
+}
+
+func TestCheckLogRejectsBadBatchSize(t *testing.T) {
+	if _, err := checkLog(context.Background(), createLog(t, 1), 0); err == nil {
+		t.Error("checkLog() accepted a batch size of 0")
+	}
+}
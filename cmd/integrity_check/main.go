@@ -0,0 +1,92 @@
+// The integrity_check binary checks the stored data of a log against its latest signed
+// root. It recomputes every node hash from the leaf hashes, compares them with the nodes
+// read from the stored subtrees and recomputes the root. Corrupt nodes are reported by
+// depth and index, and the binary exits with status 1 if anything is corrupt.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/mysql"
+	"golang.org/x/net/context"
+
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
+	_ "github.com/google/trillian/storage/postgres"
+)
+
+var storageSystemFlag = flag.String("storage_system", mysql.ProviderName, "Name of the registered storage system holding the log")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "uri to use with the selected storage system")
+var treeIDFlag = flag.Int64("tree_id", 0, "Tree id of the log to check")
+var batchSizeFlag = flag.Int64("batch_size", 1024, "Number of leaves read from storage at a time")
+
+func main() {
+	flag.Parse()
+
+	if *treeIDFlag == 0 {
+		glog.Fatal("The log to check must be given with --tree_id")
+	}
+
+	provider, err := storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+	if err != nil {
+		glog.Fatalf("Failed to create storage provider %s: %v", *storageSystemFlag, err)
+	}
+
+	ctx := context.Background()
+
+	tree, err := getTree(ctx, provider, *treeIDFlag)
+	if err != nil {
+		glog.Fatalf("Failed to read tree %d: %v", *treeIDFlag, err)
+	}
+
+	if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		glog.Fatalf("Tree %d is a %v, only logs can be checked", tree.TreeId, tree.TreeType)
+	}
+
+	s, err := provider.LogStorage(trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId})
+	if err != nil {
+		glog.Fatalf("Failed to get storage for log %d: %v", tree.TreeId, err)
+	}
+
+	report, err := checkLog(ctx, s, *batchSizeFlag)
+	if err != nil {
+		glog.Fatalf("Failed to check log %d: %v", tree.TreeId, err)
+	}
+
+	fmt.Printf("log %d: checked %d leaves at revision %d\n", tree.TreeId, report.TreeSize, report.TreeRevision)
+
+	for _, n := range report.Nodes {
+		fmt.Printf("corrupt: %s\n", n)
+	}
+
+	if !bytes.Equal(report.RootHash, report.ComputedRootHash) {
+		fmt.Printf("corrupt: signed root hash is %x, recomputed %x\n", report.RootHash, report.ComputedRootHash)
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+
+	fmt.Println("ok")
+}
+
+func getTree(ctx context.Context, provider storage.Provider, treeID int64) (*trillian.Tree, error) {
+	as, err := provider.AdminStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := as.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	return tx.GetTree(treeID)
+}
@@ -0,0 +1,56 @@
+// The log_integration binary starts a log server, sequencer and admin server in process over
+// a storage system, creates a log and checks that leaves queued to it are integrated with
+// valid proofs. It exits with a non-zero status if the check fails.
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/integration"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"golang.org/x/net/context"
+
+	_ "github.com/google/trillian/storage/mysql"
+	_ "github.com/google/trillian/storage/postgres"
+)
+
+var storageSystemFlag = flag.String("storage_system", memory.ProviderName, "Name of the registered storage system to run the servers over")
+var storageUriFlag = flag.String("storage_uri", "integration", "uri to use with the selected storage system")
+var leafCountFlag = flag.Int("leaves", integration.DefaultLogParams.LeafCount, "Number of leaves to queue to the log")
+var timeoutFlag = flag.Duration("timeout", time.Minute, "Time allowed for the leaves to be integrated and verified")
+
+func main() {
+	flag.Parse()
+
+	provider, err := storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+	if err != nil {
+		glog.Fatalf("Failed to create storage provider %s: %v", *storageSystemFlag, err)
+	}
+
+	env, err := integration.NewLogEnv(provider, integration.DefaultLogEnvConfig)
+	if err != nil {
+		glog.Fatalf("Failed to start servers: %v", err)
+	}
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+
+	logID, err := env.CreateLog(ctx, trillian.TreeType_LOG)
+	if err != nil {
+		glog.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := integration.DefaultLogParams
+	params.LeafCount = *leafCountFlag
+
+	if err := integration.RunLogIntegration(ctx, env.LogClient, logID, env.PublicKey, params); err != nil {
+		glog.Fatalf("Log integration failed: %v", err)
+	}
+
+	glog.Infof("Log integration passed for log %d", logID)
+}
@@ -0,0 +1,93 @@
+// The log_mapper binary keeps a Trillian map of leaf hash to leaf index up to date with the
+// entries of a Trillian log. Each map root records how many log entries it covers.
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/mapper"
+	"github.com/google/trillian/monitoring"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var logServerFlag = flag.String("log_server", "localhost:8090", "Address of the Trillian log server to read entries from")
+var logIDFlag = flag.Int64("log_id", 0, "Tree id of the log to read entries from")
+var mapServerFlag = flag.String("map_server", "localhost:8091", "Address of the Trillian map server to write to")
+var mapIDFlag = flag.Int64("map_id", 0, "Tree id of the map to write to")
+var batchSizeFlag = flag.Int("batch_size", 256, "Max number of log entries to apply in each map revision")
+var pollIntervalFlag = flag.Duration("poll_interval", time.Second*30, "Time to wait for the log to grow once the map has caught up")
+var rpcTimeoutFlag = flag.Duration("rpc_timeout", time.Second*30, "Deadline for each request to the log and the map")
+var metricsEndpointFlag = flag.String("metrics_endpoint", "", "Address to serve Prometheus metrics on at /metrics, metrics aren't served if empty")
+
+var mapperEntries = monitoring.NewCounter("log_mapper_entries", "Log entries applied to the map")
+var mapperFailures = monitoring.NewCounter("log_mapper_run_failures", "Log mapper runs that failed")
+
+func dial(addr string) *grpc.ClientConn {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(monitoring.UnaryClientInterceptor()))
+
+	if err != nil {
+		glog.Fatalf("Failed to connect to %s: %v", addr, err)
+	}
+
+	return conn
+}
+
+func main() {
+	flag.Parse()
+
+	if len(*metricsEndpointFlag) > 0 {
+		monitoring.StartServer(*metricsEndpointFlag)
+	}
+
+	logConn := dial(*logServerFlag)
+	defer logConn.Close()
+
+	mapConn := dial(*mapServerFlag)
+	defer mapConn.Close()
+
+	config := mapper.LogMapperConfig{LogID: *logIDFlag, MapID: *mapIDFlag, BatchSize: *batchSizeFlag, RPCTimeout: *rpcTimeoutFlag}
+	m, err := mapper.NewLogMapper(trillian.NewTrillianLogClient(logConn), trillian.NewTrillianMapClient(mapConn), mapper.LeafIndexMapper{}, config)
+
+	if err != nil {
+		glog.Fatalf("Failed to create log mapper: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Stop cleanly on the standard termination signals, progress is kept in the map roots
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigs
+		glog.Infof("Signal received: %v", sig)
+		cancel()
+	}()
+
+	for {
+		applied, err := m.RunOnce(ctx)
+
+		if err != nil {
+			glog.Warningf("Log mapper run failed after applying %d entries: %v", applied, err)
+			mapperFailures.Inc()
+		} else {
+			glog.Infof("Log mapper run applied %d entries", applied)
+		}
+
+		mapperEntries.Add(float64(applied))
+
+		select {
+		case <-ctx.Done():
+			glog.Info("Log mapper stopped")
+			return
+		case <-time.After(*pollIntervalFlag):
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// The migrate binary upgrades the schema of a storage database in place by applying the
+// migrations it's missing, so existing trees are kept across releases that change the
+// schema. Migrations are applied under a database lock, so it's safe to run while servers
+// started with --auto_migrate are doing the same.
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
+	"github.com/google/trillian/storage/migrate"
+	"github.com/google/trillian/storage/mysql"
+	_ "github.com/google/trillian/storage/postgres"
+	"golang.org/x/net/context"
+)
+
+var storageSystemFlag = flag.String("storage_system", mysql.ProviderName, "Name of the registered storage system to migrate")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "uri to use with the selected storage system")
+var dryRunFlag = flag.Bool("dry_run", false, "If true the migrations the database is missing are listed but not applied")
+
+func main() {
+	flag.Parse()
+
+	provider, err := storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+
+	if err != nil {
+		glog.Fatalf("Could not create storage provider %s (registered: %v): %v", *storageSystemFlag, storage.Providers(), err)
+	}
+
+	db, migrations, err := storage.Migrations(provider)
+
+	if err != nil {
+		glog.Fatalf("Could not get migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if *dryRunFlag {
+		pending, err := migrate.Pending(ctx, db, migrations)
+
+		if err != nil {
+			glog.Fatalf("Could not find pending migrations: %v", err)
+		}
+
+		for _, m := range pending {
+			fmt.Printf("%d: %s\n", m.Version, m.Description)
+		}
+
+		return
+	}
+
+	applied, err := migrate.Run(ctx, db, migrations)
+
+	if err != nil {
+		glog.Fatalf("Migration failed after applying %d migrations: %v", applied, err)
+	}
+
+	fmt.Printf("Applied %d migrations, schema is at version %d\n", applied, len(migrations))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+)
+
+// checkpoint records how far a mirror has got. Size can be ahead of Head while a mirror is
+// catching up, each batch is checked against a later tree head before it's copied but Head is
+// only moved on once all of the leaves it covers have been.
+type checkpoint struct {
+	// Head is the last source tree head the copied leaves were verified against
+	Head TreeHead
+	// Size is the number of leaves copied
+	Size int64
+	// Nodes and Root are the compact merkle tree over the copied leaves
+	Nodes []trillian.Hash
+	Root  trillian.Hash
+}
+
+func newCheckpoint(head TreeHead, tree *merkle.CompactMerkleTree) checkpoint {
+	return checkpoint{Head: head, Size: tree.Size(), Nodes: tree.Hashes(), Root: tree.CurrentRoot()}
+}
+
+// compactTree rebuilds the compact merkle tree saved in the checkpoint
+func (c checkpoint) compactTree(hasher merkle.TreeHasher) (*merkle.CompactMerkleTree, error) {
+	if c.Size == 0 {
+		return merkle.NewCompactMerkleTree(hasher), nil
+	}
+
+	return merkle.NewCompactMerkleTreeWithState(hasher, c.Size, func(depth int, index int64) (trillian.Hash, error) {
+		if depth >= len(c.Nodes) || len(c.Nodes[depth]) == 0 {
+			return nil, fmt.Errorf("no node saved at depth %d", depth)
+		}
+
+		return c.Nodes[depth], nil
+	}, c.Root)
+}
+
+func loadCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return cp, err
+	}
+
+	err = json.Unmarshal(data, &cp)
+
+	return cp, err
+}
+
+// saveCheckpoint writes cp to a temporary file and renames it over path so a crash never
+// leaves a partly written checkpoint
+func saveCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
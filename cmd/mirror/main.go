@@ -0,0 +1,118 @@
+// The mirror binary copies the entries of a CT or Trillian log into a local pre-ordered
+// Trillian log and keeps it in sync.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var sourceTypeFlag = flag.String("source_type", "trillian", "Type of log to mirror, either trillian or ct")
+var sourceFlag = flag.String("source", "", "Address of the Trillian log server or URL of the CT log to mirror")
+var sourceLogIDFlag = flag.Int64("source_log_id", 0, "Tree id of the log to mirror when the source is a Trillian log server")
+var logServerFlag = flag.String("log_server", "localhost:8090", "Address of the Trillian log server holding the mirror")
+var logIDFlag = flag.Int64("log_id", 0, "Tree id of the pre-ordered log to copy entries into")
+var checkpointFileFlag = flag.String("checkpoint_file", "mirror.checkpoint", "File used to save progress so the mirror can resume after a restart")
+var batchSizeFlag = flag.Int("batch_size", 100, "Max number of entries to copy per request")
+var pollIntervalFlag = flag.Duration("poll_interval", time.Second*30, "Time to wait for the source to grow once the mirror has caught up")
+var rpcTimeoutFlag = flag.Duration("rpc_timeout", time.Second*30, "Deadline for each request to the source and the mirror")
+var metricsEndpointFlag = flag.String("metrics_endpoint", "", "Address to serve Prometheus metrics on at /metrics, metrics aren't served if empty")
+var traceSampleRateFlag = flag.Float64("trace_sample_rate", 0, "Fraction of requests to the log servers to trace, between 0 and 1")
+
+var mirrorSize = monitoring.NewGauge("mirror_entries", "Entries copied into the mirror")
+var mirrorFailures = monitoring.NewCounter("mirror_run_failures", "Mirror runs that failed")
+
+func newSource() (Source, func()) {
+	switch *sourceTypeFlag {
+	case "trillian":
+		conn, err := grpc.Dial(*sourceFlag, grpc.WithInsecure(), grpc.WithUnaryInterceptor(monitoring.UnaryClientInterceptor()))
+
+		if err != nil {
+			glog.Fatalf("Failed to connect to source log server %s: %v", *sourceFlag, err)
+		}
+
+		return newTrillianSource(trillian.NewTrillianLogClient(conn), *sourceLogIDFlag), func() { conn.Close() }
+	case "ct":
+		return newCTSource(*sourceFlag, &http.Client{Timeout: *rpcTimeoutFlag}), func() {}
+	default:
+		glog.Fatalf("Unknown source type: %s", *sourceTypeFlag)
+		return nil, nil
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if len(*sourceFlag) == 0 {
+		glog.Fatal("A source log must be given with --source")
+	}
+
+	if len(*metricsEndpointFlag) > 0 {
+		monitoring.StartServer(*metricsEndpointFlag)
+	}
+
+	if *traceSampleRateFlag > 0 {
+		defer monitoring.InitTracing(*traceSampleRateFlag)()
+	}
+
+	source, closeSource := newSource()
+	defer closeSource()
+
+	conn, err := grpc.Dial(*logServerFlag, grpc.WithInsecure(), grpc.WithUnaryInterceptor(monitoring.UnaryClientInterceptor()))
+
+	if err != nil {
+		glog.Fatalf("Failed to connect to mirror log server %s: %v", *logServerFlag, err)
+	}
+
+	defer conn.Close()
+
+	config := MirrorConfig{LogID: *logIDFlag, BatchSize: *batchSizeFlag, CheckpointFile: *checkpointFileFlag, RPCTimeout: *rpcTimeoutFlag}
+	m, err := NewMirror(source, trillian.NewTrillianLogClient(conn), config)
+
+	if err != nil {
+		glog.Fatalf("Failed to create mirror: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Stop cleanly on the standard termination signals, the checkpoint is always up to date
+	// with the last batch copied
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigs
+		glog.Infof("Signal received: %v", sig)
+		cancel()
+	}()
+
+	for {
+		copied, err := m.RunOnce(ctx)
+
+		if err != nil {
+			glog.Warningf("Mirror run failed after copying %d entries: %v", copied, err)
+			mirrorFailures.Inc()
+		} else {
+			glog.Infof("Mirror run copied %d entries, mirror has %d entries", copied, m.Size())
+		}
+
+		mirrorSize.Set(float64(m.Size()))
+
+		select {
+		case <-ctx.Done():
+			glog.Infof("Mirror stopped with %d entries", m.Size())
+			return
+		case <-time.After(*pollIntervalFlag):
+		}
+	}
+}
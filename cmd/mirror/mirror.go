@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// TreeHead is a tree size and root hash published by the source log
+type TreeHead struct {
+	TreeSize       int64
+	RootHash       []byte
+	TimestampNanos int64
+}
+
+// Source is a log that can be mirrored
+type Source interface {
+	// GetTreeHead returns the latest tree head published by the log
+	GetTreeHead(ctx context.Context) (TreeHead, error)
+	// GetConsistencyProof returns the proof that the tree at second is an extension of the
+	// tree at first, with nodes in RFC 6962 order
+	GetConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error)
+	// GetLeaves returns up to count leaves in order from index start. It can return fewer
+	// leaves than asked for but must return at least one. The leaf hash must be set on all
+	// of them.
+	GetLeaves(ctx context.Context, start, count int64) ([]*trillian.LeafProto, error)
+}
+
+// MirrorConfig controls where a Mirror copies leaves to and how it keeps track of progress
+type MirrorConfig struct {
+	// LogID is the tree id of the pre-ordered log that leaves are copied into
+	LogID int64
+	// BatchSize is the most leaves read and added in one request
+	BatchSize int
+	// CheckpointFile is where progress is saved
+	CheckpointFile string
+	// RPCTimeout is the deadline for each request to the source and the mirror, zero means
+	// no deadline
+	RPCTimeout time.Duration
+}
+
+// Mirror copies the leaves of a Source log into a pre-ordered Trillian log, keeping the
+// same leaf indices. Each tree head read from the source must be consistent with the last
+// one that was verified, and each batch of leaves must be shown to be part of the tree it
+// covers before the batch is written. Progress is saved to a checkpoint file after every
+// batch so an interrupted mirror resumes where it stopped.
+type Mirror struct {
+	source   Source
+	client   trillian.TrillianLogClient
+	config   MirrorConfig
+	hasher   merkle.TreeHasher
+	verifier merkle.LogVerifier
+	// tree holds the compact state of the leaves copied so far
+	tree *merkle.CompactMerkleTree
+	// head is the last source tree head that the copied leaves were verified against
+	head TreeHead
+}
+
+// NewMirror creates a Mirror that copies source into a pre-ordered log using client. It
+// resumes from the configured checkpoint file if it exists.
+func NewMirror(source Source, client trillian.TrillianLogClient, config MirrorConfig) (*Mirror, error) {
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be > 0 but was %d", config.BatchSize)
+	}
+
+	if config.RPCTimeout < 0 {
+		return nil, fmt.Errorf("rpc timeout must be >= 0 but was %v", config.RPCTimeout)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	m := &Mirror{
+		source:   source,
+		client:   client,
+		config:   config,
+		hasher:   hasher,
+		verifier: merkle.NewLogVerifier(hasher),
+		tree:     merkle.NewCompactMerkleTree(hasher),
+	}
+
+	cp, err := loadCheckpoint(config.CheckpointFile)
+
+	if os.IsNotExist(err) {
+		glog.Infof("No checkpoint in %s, mirroring from the start of the log", config.CheckpointFile)
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if m.tree, err = cp.compactTree(hasher); err != nil {
+		return nil, fmt.Errorf("corrupt checkpoint in %s: %v", config.CheckpointFile, err)
+	}
+
+	m.head = cp.Head
+	glog.Infof("Resuming mirror at %d leaves, last verified tree head size %d", m.tree.Size(), m.head.TreeSize)
+
+	return m, nil
+}
+
+// rpcContext returns the context for a single request made on behalf of ctx
+func (m *Mirror) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.config.RPCTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.config.RPCTimeout)
+}
+
+// Size returns the number of leaves that have been copied
+func (m *Mirror) Size() int64 {
+	return m.tree.Size()
+}
+
+// RunOnce reads the latest tree head from the source, checks it's consistent with the last
+// one verified and copies leaves until the mirror has all the leaves it covers. It returns
+// the number of leaves copied.
+func (m *Mirror) RunOnce(ctx context.Context) (int64, error) {
+	rpcCtx, cancel := m.rpcContext(ctx)
+	head, err := m.source.GetTreeHead(rpcCtx)
+	cancel()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.verifyTreeHead(ctx, head); err != nil {
+		return 0, err
+	}
+
+	if head.TreeSize < m.tree.Size() {
+		// An earlier run copied leaves covered by a newer head than the one we just read,
+		// wait for the source to catch up
+		glog.Infof("Source tree head size %d is behind the %d leaves already copied", head.TreeSize, m.tree.Size())
+		return 0, nil
+	}
+
+	start := m.tree.Size()
+
+	for m.tree.Size() < head.TreeSize {
+		if err := m.copyBatch(ctx, head); err != nil {
+			return m.tree.Size() - start, err
+		}
+	}
+
+	// Each batch was checked before it was written, but leaves copied by an earlier run
+	// that stopped part way through are only checked here if nothing was left to copy
+	if root := m.tree.CurrentRoot(); !bytes.Equal(root, head.RootHash) {
+		return m.tree.Size() - start, fmt.Errorf("root hash of copied leaves does not match source tree head at size %d: %v", head.TreeSize, merkle.RootHashMismatchError{ExpectedHash: head.RootHash, ActualHash: root})
+	}
+
+	m.head = head
+
+	return m.tree.Size() - start, m.saveCheckpoint()
+}
+
+// verifyTreeHead checks that head is consistent with the last verified tree head
+func (m *Mirror) verifyTreeHead(ctx context.Context, head TreeHead) error {
+	if head.TreeSize < m.head.TreeSize {
+		return fmt.Errorf("source tree head size %d is smaller than verified size %d", head.TreeSize, m.head.TreeSize)
+	}
+
+	if m.head.TreeSize == 0 {
+		return nil
+	}
+
+	var proof [][]byte
+
+	if head.TreeSize > m.head.TreeSize {
+		rpcCtx, cancel := m.rpcContext(ctx)
+		defer cancel()
+
+		var err error
+		if proof, err = m.source.GetConsistencyProof(rpcCtx, m.head.TreeSize, head.TreeSize); err != nil {
+			return err
+		}
+	}
+
+	if err := m.verifier.VerifyConsistencyProof(m.head.TreeSize, head.TreeSize, m.head.RootHash, head.RootHash, proof); err != nil {
+		return fmt.Errorf("source tree head at size %d is not consistent with verified size %d: %v", head.TreeSize, m.head.TreeSize, err)
+	}
+
+	return nil
+}
+
+// copyBatch copies the next batch of leaves covered by head into the mirror and saves a
+// checkpoint. The batch is only written once it's been verified against head, so leaves
+// that don't match the source's own tree never reach the mirror.
+func (m *Mirror) copyBatch(ctx context.Context, head TreeHead) error {
+	start := m.tree.Size()
+	count := head.TreeSize - start
+
+	if count > int64(m.config.BatchSize) {
+		count = int64(m.config.BatchSize)
+	}
+
+	rpcCtx, cancel := m.rpcContext(ctx)
+	leaves, err := m.source.GetLeaves(rpcCtx, start, count)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+
+	if len(leaves) == 0 || int64(len(leaves)) > count {
+		return fmt.Errorf("source returned %d leaves from index %d, expected between 1 and %d", len(leaves), start, count)
+	}
+
+	for i, leaf := range leaves {
+		if len(leaf.LeafHash) == 0 {
+			return fmt.Errorf("source returned leaf %d without a hash", start+int64(i))
+		}
+
+		leaf.LeafIndex = start + int64(i)
+	}
+
+	// The batch is added to a copy of the tree so nothing changes if it doesn't verify
+	tree, err := merkle.NewCompactMerkleTreeFromProto(m.hasher, m.tree.Proto())
+
+	if err != nil {
+		return err
+	}
+
+	for _, leaf := range leaves {
+		tree.AddLeafHash(leaf.LeafHash, func(int, int64, trillian.Hash) {})
+	}
+
+	if err := m.verifyBatch(ctx, tree, head); err != nil {
+		return fmt.Errorf("leaves %d to %d from the source are not in its tree: %v", start, tree.Size()-1, err)
+	}
+
+	if err := m.addLeaves(ctx, leaves); err != nil {
+		return err
+	}
+
+	m.tree = tree
+
+	glog.V(1).Infof("Copied leaves %d to %d", start, m.tree.Size()-1)
+
+	return m.saveCheckpoint()
+}
+
+// verifyBatch checks that tree, the copied leaves with a new batch added, is the start of
+// the source's tree at head. Unless it covers all of head it must be consistent with it.
+func (m *Mirror) verifyBatch(ctx context.Context, tree *merkle.CompactMerkleTree, head TreeHead) error {
+	if tree.Size() == head.TreeSize {
+		if root := tree.CurrentRoot(); !bytes.Equal(root, head.RootHash) {
+			return merkle.RootHashMismatchError{ExpectedHash: head.RootHash, ActualHash: root}
+		}
+
+		return nil
+	}
+
+	rpcCtx, cancel := m.rpcContext(ctx)
+	proof, err := m.source.GetConsistencyProof(rpcCtx, tree.Size(), head.TreeSize)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+
+	return m.verifier.VerifyConsistencyProof(tree.Size(), head.TreeSize, tree.CurrentRoot(), head.RootHash, proof)
+}
+
+// addLeaves adds leaves to the mirror log at their indices
+func (m *Mirror) addLeaves(ctx context.Context, leaves []*trillian.LeafProto) error {
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := m.client.AddSequencedLeaves(rpcCtx, &trillian.AddSequencedLeavesRequest{LogId: m.config.LogID, Leaves: leaves})
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("mirror log failed to add leaves: %v", resp.Status)
+	}
+
+	if len(resp.Leaves) != len(leaves) {
+		return fmt.Errorf("mirror log returned %d results for %d leaves", len(resp.Leaves), len(leaves))
+	}
+
+	for i, result := range resp.Leaves {
+		leaf := leaves[i]
+
+		switch result.Status {
+		case trillian.QueuedLeafStatus_QUEUED:
+		case trillian.QueuedLeafStatus_DUPLICATE:
+			// Added by an earlier run that stopped before saving its checkpoint
+			if result.ExistingLeaf == nil || !bytes.Equal(result.ExistingLeaf.LeafHash, leaf.LeafHash) {
+				return fmt.Errorf("mirror log already has a different leaf at index %d", leaf.LeafIndex)
+			}
+		default:
+			return fmt.Errorf("mirror log did not accept leaf %d: %v: %s", leaf.LeafIndex, result.Status, result.Reason)
+		}
+	}
+
+	return nil
+}
+
+func (m *Mirror) saveCheckpoint() error {
+	return saveCheckpoint(m.config.CheckpointFile, newCheckpoint(m.head, m.tree))
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeSource serves the leaves of an in memory tree up to its published size
+type fakeSource struct {
+	mt     *merkle.InMemoryMerkleTree
+	leaves [][]byte
+	// size is the tree size of the published tree head
+	size int64
+	// maxLeaves limits how many leaves are returned by each GetLeaves call
+	maxLeaves int64
+	// corruptLeaf causes the leaf at this index to be served with the wrong data if >= 0
+	corruptLeaf int64
+	proofs      int
+}
+
+func newFakeSource(prefix string, n int) *fakeSource {
+	s := &fakeSource{mt: merkle.NewInMemoryMerkleTree(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), maxLeaves: 1000, corruptLeaf: -1}
+	s.grow(prefix, n)
+	return s
+}
+
+// grow adds n leaves and publishes a tree head covering them
+func (s *fakeSource) grow(prefix string, n int) {
+	for i := 0; i < n; i++ {
+		data := []byte(fmt.Sprintf("%s %d", prefix, len(s.leaves)))
+		s.leaves = append(s.leaves, data)
+		s.mt.AddLeaf(data)
+	}
+
+	s.size = int64(len(s.leaves))
+}
+
+func (s *fakeSource) GetTreeHead(ctx context.Context) (TreeHead, error) {
+	return TreeHead{TreeSize: s.size, RootHash: s.mt.RootAtSnapshot(int(s.size)).Hash()}, nil
+}
+
+func (s *fakeSource) GetConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error) {
+	s.proofs++
+	proof := [][]byte{}
+
+	for _, node := range s.mt.SnapshotConsistency(int(first), int(second)) {
+		proof = append(proof, node.Value.Hash())
+	}
+
+	return proof, nil
+}
+
+func (s *fakeSource) GetLeaves(ctx context.Context, start, count int64) ([]*trillian.LeafProto, error) {
+	if count > s.maxLeaves {
+		count = s.maxLeaves
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	leaves := []*trillian.LeafProto{}
+
+	for i := start; i < start+count && i < s.size; i++ {
+		data := s.leaves[i]
+
+		if i == s.corruptLeaf {
+			data = []byte("corrupt")
+		}
+
+		leaves = append(leaves, &trillian.LeafProto{LeafIndex: i, LeafHash: hasher.HashLeaf(data), LeafData: data})
+	}
+
+	return leaves, nil
+}
+
+// fakeLogClient is a pre-ordered log that records the leaves added to it. Only
+// AddSequencedLeaves is implemented.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	leaves   map[int64]*trillian.LeafProto
+	requests int
+	err      error
+}
+
+func newFakeLogClient() *fakeLogClient {
+	return &fakeLogClient{leaves: make(map[int64]*trillian.LeafProto)}
+}
+
+func (c *fakeLogClient) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest, opts ...grpc.CallOption) (*trillian.AddSequencedLeavesResponse, error) {
+	c.requests++
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	resp := &trillian.AddSequencedLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}
+
+	for _, leaf := range req.Leaves {
+		if existing, ok := c.leaves[leaf.LeafIndex]; ok {
+			resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: existing})
+			continue
+		}
+
+		c.leaves[leaf.LeafIndex] = leaf
+		resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED})
+	}
+
+	return resp, nil
+}
+
+func (c *fakeLogClient) checkLeaves(s *fakeSource, t *testing.T) {
+	if got, want := len(c.leaves), len(s.leaves); got != want {
+		t.Fatalf("Mirror has %d leaves, want %d", got, want)
+	}
+
+	for i, data := range s.leaves {
+		if leaf := c.leaves[int64(i)]; leaf == nil || !bytes.Equal(leaf.LeafData, data) {
+			t.Errorf("Mirror has leaf %v at index %d, want data %s", leaf, i, data)
+		}
+	}
+}
+
+func newTestMirror(s Source, c trillian.TrillianLogClient, batchSize int, t *testing.T) (*Mirror, string) {
+	dir, err := ioutil.TempDir("", "mirror")
+
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint dir: %v", err)
+	}
+
+	m := reopenTestMirror(s, c, batchSize, dir, t)
+
+	return m, dir
+}
+
+func reopenTestMirror(s Source, c trillian.TrillianLogClient, batchSize int, dir string, t *testing.T) *Mirror {
+	m, err := NewMirror(s, c, MirrorConfig{LogID: 6962, BatchSize: batchSize, CheckpointFile: filepath.Join(dir, "checkpoint")})
+
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+
+	return m
+}
+
+func runMirror(m *Mirror, want int64, t *testing.T) {
+	copied, err := m.RunOnce(context.Background())
+
+	if err != nil {
+		t.Fatalf("Mirror run failed: %v", err)
+	}
+
+	if copied != want {
+		t.Fatalf("Mirror run copied %d leaves, want %d", copied, want)
+	}
+}
+
+func TestNewMirrorRejectsBadConfig(t *testing.T) {
+	for _, config := range []MirrorConfig{
+		{BatchSize: 0, CheckpointFile: "x"},
+		{BatchSize: 10, CheckpointFile: "x", RPCTimeout: -1},
+	} {
+		if _, err := NewMirror(newFakeSource("leaf", 1), newFakeLogClient(), config); err == nil {
+			t.Errorf("Created mirror with bad config: %v", config)
+		}
+	}
+}
+
+func TestMirrorCopiesLog(t *testing.T) {
+	source := newFakeSource("leaf", 10)
+	source.maxLeaves = 2
+	client := newFakeLogClient()
+	m, dir := newTestMirror(source, client, 3, t)
+	defer os.RemoveAll(dir)
+
+	runMirror(m, 10, t)
+
+	client.checkLeaves(source, t)
+
+	if got, want := client.requests, 5; got != want {
+		t.Errorf("Mirror made %d requests, want %d", got, want)
+	}
+
+	// Nothing to do once caught up
+	runMirror(m, 0, t)
+}
+
+func TestMirrorFollowsSource(t *testing.T) {
+	source := newFakeSource("leaf", 5)
+	client := newFakeLogClient()
+	m, dir := newTestMirror(source, client, 100, t)
+	defer os.RemoveAll(dir)
+
+	runMirror(m, 5, t)
+
+	source.grow("leaf", 7)
+	runMirror(m, 7, t)
+
+	client.checkLeaves(source, t)
+
+	if got, want := source.proofs, 1; got != want {
+		t.Errorf("Mirror fetched %d consistency proofs, want %d", got, want)
+	}
+}
+
+func TestMirrorResumesFromCheckpoint(t *testing.T) {
+	source := newFakeSource("leaf", 6)
+	client := newFakeLogClient()
+	m, dir := newTestMirror(source, client, 4, t)
+	defer os.RemoveAll(dir)
+
+	runMirror(m, 6, t)
+
+	// A new mirror carries on from the checkpoint and checks consistency with the tree head
+	// saved there
+	source.grow("leaf", 3)
+	client.requests, source.proofs = 0, 0
+	m = reopenTestMirror(source, client, 4, dir, t)
+
+	if got, want := m.Size(), int64(6); got != want {
+		t.Fatalf("Resumed mirror at size %d, want %d", got, want)
+	}
+
+	runMirror(m, 3, t)
+
+	client.checkLeaves(source, t)
+
+	if got, want := client.requests, 1; got != want {
+		t.Errorf("Resumed mirror made %d requests, want %d", got, want)
+	}
+
+	if got, want := source.proofs, 1; got != want {
+		t.Errorf("Resumed mirror fetched %d consistency proofs, want %d", got, want)
+	}
+}
+
+func TestMirrorResumesPartialCopy(t *testing.T) {
+	source := newFakeSource("leaf", 9)
+	client := newFakeLogClient()
+	m, dir := newTestMirror(source, client, 2, t)
+	defer os.RemoveAll(dir)
+
+	// Fail part way through so the checkpoint is ahead of the last verified tree head
+	source.corruptLeaf = 8
+
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Fatalf("Mirror run with a corrupt leaf succeeded")
+	}
+
+	if got, want := m.Size(), int64(8); got != want {
+		t.Fatalf("Mirror copied %d leaves before failing, want %d", got, want)
+	}
+
+	m = reopenTestMirror(source, client, 2, dir, t)
+
+	if got, want := m.Size(), int64(8); got != want {
+		t.Fatalf("Resumed mirror at size %d, want %d", got, want)
+	}
+
+	source.corruptLeaf = -1
+	source.grow("leaf", 1)
+	runMirror(m, 2, t)
+
+	client.checkLeaves(source, t)
+}
+
+func TestMirrorRejectsTamperedLeaves(t *testing.T) {
+	for _, corrupt := range []int64{0, 2, 5} {
+		source := newFakeSource("leaf", 6)
+		source.corruptLeaf = corrupt
+		client := newFakeLogClient()
+		m, dir := newTestMirror(source, client, 4, t)
+		defer os.RemoveAll(dir)
+
+		if _, err := m.RunOnce(context.Background()); err == nil {
+			t.Fatalf("Mirror run with leaf %d tampered with succeeded", corrupt)
+		}
+
+		// The batch holding the tampered leaf must not have been written
+		for index := range client.leaves {
+			if index/4 == corrupt/4 {
+				t.Errorf("Mirror wrote leaf %d from the batch with leaf %d tampered with", index, corrupt)
+			}
+		}
+
+		if got, want := m.Size(), corrupt/4*4; got != want {
+			t.Errorf("Mirror copied %d leaves with leaf %d tampered with, want %d", got, corrupt, want)
+		}
+	}
+}
+
+func TestMirrorRejectsInconsistentTreeHead(t *testing.T) {
+	source := newFakeSource("leaf", 5)
+	client := newFakeLogClient()
+	m, dir := newTestMirror(source, client, 100, t)
+	defer os.RemoveAll(dir)
+
+	runMirror(m, 5, t)
+
+	// A fork of the log with different history
+	fork := newFakeSource("fork", 8)
+	m.source = fork
+
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Fatalf("Mirror accepted an inconsistent tree head")
+	}
+
+	if got, want := m.Size(), int64(5); got != want {
+		t.Errorf("Mirror copied leaves from an inconsistent log, got size %d want %d", got, want)
+	}
+
+	// A tree head that goes backwards is also rejected
+	source.size = 3
+	m.source = source
+
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Fatalf("Mirror accepted a smaller tree head")
+	}
+}
+
+func TestMirrorAcceptsLeavesAlreadyAdded(t *testing.T) {
+	source := newFakeSource("leaf", 4)
+	client := newFakeLogClient()
+
+	// As if an earlier run added leaves but stopped before saving its checkpoint
+	leaves, _ := source.GetLeaves(context.Background(), 0, 2)
+	for _, leaf := range leaves {
+		client.leaves[leaf.LeafIndex] = leaf
+	}
+
+	m, dir := newTestMirror(source, client, 100, t)
+	defer os.RemoveAll(dir)
+
+	runMirror(m, 4, t)
+
+	client.checkLeaves(source, t)
+}
+
+func TestMirrorRejectsDifferentExistingLeaf(t *testing.T) {
+	source := newFakeSource("leaf", 4)
+	client := newFakeLogClient()
+
+	other := newFakeSource("other", 4)
+	leaves, _ := other.GetLeaves(context.Background(), 2, 1)
+	client.leaves[2] = leaves[0]
+
+	m, dir := newTestMirror(source, client, 100, t)
+	defer os.RemoveAll(dir)
+
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Fatalf("Mirror accepted a different leaf already in the log")
+	}
+}
+
+func TestMirrorLogErrorsAreReturned(t *testing.T) {
+	source := newFakeSource("leaf", 4)
+	client := newFakeLogClient()
+	client.err = errors.New("log is unavailable")
+
+	m, dir := newTestMirror(source, client, 100, t)
+	defer os.RemoveAll(dir)
+
+	if _, err := m.RunOnce(context.Background()); err == nil {
+		t.Fatalf("Mirror run succeeded when the log failed")
+	}
+
+	if got, want := m.Size(), int64(0); got != want {
+		t.Errorf("Mirror counted leaves the log failed to add, got size %d want %d", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// trillianSource reads a log served by a Trillian log server
+type trillianSource struct {
+	client trillian.TrillianLogClient
+	logID  int64
+}
+
+func newTrillianSource(client trillian.TrillianLogClient, logID int64) *trillianSource {
+	return &trillianSource{client: client, logID: logID}
+}
+
+func checkStatus(op string, status *trillian.TrillianApiStatus) error {
+	if status == nil || status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("%s failed: %v", op, status)
+	}
+
+	return nil
+}
+
+func (s *trillianSource) GetTreeHead(ctx context.Context) (TreeHead, error) {
+	resp, err := s.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: s.logID})
+
+	if err != nil {
+		return TreeHead{}, err
+	}
+
+	if err := checkStatus("GetLatestSignedLogRoot", resp.Status); err != nil {
+		return TreeHead{}, err
+	}
+
+	if resp.SignedLogRoot == nil {
+		return TreeHead{}, fmt.Errorf("source log %d has no signed root", s.logID)
+	}
+
+	root := resp.SignedLogRoot
+
+	return TreeHead{TreeSize: root.TreeSize, RootHash: root.RootHash, TimestampNanos: root.TimestampNanos}, nil
+}
+
+func (s *trillianSource) GetConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error) {
+	resp, err := s.client.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: s.logID, FirstTreeSize: first, SecondTreeSize: second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStatus("GetConsistencyProof", resp.Status); err != nil {
+		return nil, err
+	}
+
+	if resp.Proof == nil {
+		return nil, fmt.Errorf("source log %d returned no proof from %d to %d", s.logID, first, second)
+	}
+
+	proof := make([][]byte, 0, len(resp.Proof.ProofNode))
+
+	for _, node := range resp.Proof.ProofNode {
+		proof = append(proof, node.NodeHash)
+	}
+
+	return proof, nil
+}
+
+func (s *trillianSource) GetLeaves(ctx context.Context, start, count int64) ([]*trillian.LeafProto, error) {
+	indices := make([]int64, 0, count)
+
+	for i := start; i < start+count; i++ {
+		indices = append(indices, i)
+	}
+
+	resp, err := s.client.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: s.logID, LeafIndex: indices})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStatus("GetLeavesByIndex", resp.Status); err != nil {
+		return nil, err
+	}
+
+	// Storage doesn't promise to return leaves in the order they were asked for
+	leaves := resp.Leaves
+	sort.Sort(byLeafIndex(leaves))
+
+	for i, leaf := range leaves {
+		if leaf.LeafIndex != start+int64(i) {
+			return nil, fmt.Errorf("source log %d returned leaf %d, expected %d", s.logID, leaf.LeafIndex, start+int64(i))
+		}
+	}
+
+	return leaves, nil
+}
+
+type byLeafIndex []*trillian.LeafProto
+
+func (l byLeafIndex) Len() int {
+	return len(l)
+}
+
+func (l byLeafIndex) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l byLeafIndex) Less(i, j int) bool {
+	return l[i].LeafIndex < l[j].LeafIndex
+}
+
+// ctSource reads a Certificate Transparency log through its RFC 6962 HTTP API. Leaf hashes
+// are computed from the entries rather than trusted from the log.
+type ctSource struct {
+	uri    string
+	client *http.Client
+	hasher merkle.TreeHasher
+}
+
+func newCTSource(uri string, client *http.Client) *ctSource {
+	return &ctSource{uri: strings.TrimRight(uri, "/"), client: client, hasher: merkle.NewRFC6962TreeHasher(trillian.NewSHA256())}
+}
+
+type ctGetSTHResponse struct {
+	TreeSize       int64  `json:"tree_size"`
+	Timestamp      int64  `json:"timestamp"`
+	SHA256RootHash []byte `json:"sha256_root_hash"`
+}
+
+type ctGetSTHConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+type ctGetEntriesResponse struct {
+	Entries []struct {
+		LeafInput []byte `json:"leaf_input"`
+		ExtraData []byte `json:"extra_data"`
+	} `json:"entries"`
+}
+
+// getJSON calls the CT API endpoint at path and decodes the JSON response into out
+func (s *ctSource) getJSON(ctx context.Context, path string, params url.Values, out interface{}) error {
+	uri := fmt.Sprintf("%s/ct/v1/%s?%s", s.uri, path, params.Encode())
+	resp, err := ctxhttp.Get(ctx, s.client, uri)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP status %s", uri, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (s *ctSource) GetTreeHead(ctx context.Context) (TreeHead, error) {
+	var sth ctGetSTHResponse
+
+	if err := s.getJSON(ctx, "get-sth", url.Values{}, &sth); err != nil {
+		return TreeHead{}, err
+	}
+
+	return TreeHead{TreeSize: sth.TreeSize, RootHash: sth.SHA256RootHash, TimestampNanos: sth.Timestamp * int64(time.Millisecond)}, nil
+}
+
+func (s *ctSource) GetConsistencyProof(ctx context.Context, first, second int64) ([][]byte, error) {
+	var resp ctGetSTHConsistencyResponse
+	params := url.Values{"first": {strconv.FormatInt(first, 10)}, "second": {strconv.FormatInt(second, 10)}}
+
+	if err := s.getJSON(ctx, "get-sth-consistency", params, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Consistency, nil
+}
+
+func (s *ctSource) GetLeaves(ctx context.Context, start, count int64) ([]*trillian.LeafProto, error) {
+	var resp ctGetEntriesResponse
+	// The end of the range is inclusive and logs can return fewer entries than asked for
+	params := url.Values{"start": {strconv.FormatInt(start, 10)}, "end": {strconv.FormatInt(start+count-1, 10)}}
+
+	if err := s.getJSON(ctx, "get-entries", params, &resp); err != nil {
+		return nil, err
+	}
+
+	leaves := make([]*trillian.LeafProto, 0, len(resp.Entries))
+
+	for i, entry := range resp.Entries {
+		leaves = append(leaves, &trillian.LeafProto{
+			LeafIndex: start + int64(i),
+			LeafHash:  s.hasher.HashLeaf(entry.LeafInput),
+			LeafData:  entry.LeafInput,
+			ExtraData: entry.ExtraData,
+		})
+	}
+
+	return leaves, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+var statusOK = &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+
+func TestTrillianSourceGetLeavesSortsByIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	client.EXPECT().GetLeavesByIndex(gomock.Any(), &trillian.GetLeavesByIndexRequest{LogId: 3, LeafIndex: []int64{5, 6}}).Return(&trillian.GetLeavesByIndexResponse{
+		Status: statusOK,
+		Leaves: []*trillian.LeafProto{{LeafIndex: 6, LeafHash: []byte("six")}, {LeafIndex: 5, LeafHash: []byte("five")}},
+	}, nil)
+
+	leaves, err := newTrillianSource(client, 3).GetLeaves(context.Background(), 5, 2)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if len(leaves) != 2 || leaves[0].LeafIndex != 5 || leaves[1].LeafIndex != 6 {
+		t.Fatalf("Got leaves %v, want leaves 5 and 6 in order", leaves)
+	}
+}
+
+func TestTrillianSourceGetLeavesRejectsGaps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	client.EXPECT().GetLeavesByIndex(gomock.Any(), gomock.Any()).Return(&trillian.GetLeavesByIndexResponse{
+		Status: statusOK,
+		Leaves: []*trillian.LeafProto{{LeafIndex: 5, LeafHash: []byte("five")}, {LeafIndex: 7, LeafHash: []byte("seven")}},
+	}, nil)
+
+	if _, err := newTrillianSource(client, 3).GetLeaves(context.Background(), 5, 3); err == nil {
+		t.Fatalf("Got leaves with a gap")
+	}
+}
+
+func TestTrillianSourceErrorStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 3}).Return(&trillian.GetLatestSignedLogRootResponse{
+		Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR},
+	}, nil)
+
+	if _, err := newTrillianSource(client, 3).GetTreeHead(context.Background()); err == nil {
+		t.Fatalf("Got tree head when the log returned an error status")
+	}
+}
+
+func newTestCTServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ct/v1/get-sth", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tree_size":7,"timestamp":1469185273000,"sha256_root_hash":"3bib5AOAnjJXUNPSY814kpwpQreUKjS3fhIslZSnTIw=","tree_head_signature":"BAMARjBE"}`))
+	})
+
+	mux.HandleFunc("/ct/v1/get-sth-consistency", func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("first"), "3"; got != want {
+			t.Errorf("Got first=%s, want %s", got, want)
+		}
+
+		if got, want := r.URL.Query().Get("second"), "7"; got != want {
+			t.Errorf("Got second=%s, want %s", got, want)
+		}
+
+		w.Write([]byte(`{"consistency":["AAEC","AwQF"]}`))
+	})
+
+	mux.HandleFunc("/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("start"), "4"; got != want {
+			t.Errorf("Got start=%s, want %s", got, want)
+		}
+
+		// The end of the range is inclusive
+		if got, want := r.URL.Query().Get("end"), "6"; got != want {
+			t.Errorf("Got end=%s, want %s", got, want)
+		}
+
+		// Return fewer than asked for like a real log can
+		w.Write([]byte(`{"entries":[{"leaf_input":"AAEC","extra_data":"BAU="},{"leaf_input":"AwQF","extra_data":""}]}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestCTSourceGetTreeHead(t *testing.T) {
+	server := newTestCTServer(t)
+	defer server.Close()
+
+	head, err := newCTSource(server.URL+"/", http.DefaultClient).GetTreeHead(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to get tree head: %v", err)
+	}
+
+	if got, want := head.TreeSize, int64(7); got != want {
+		t.Errorf("Got tree size %d, want %d", got, want)
+	}
+
+	if got, want := head.TimestampNanos, int64(1469185273000000000); got != want {
+		t.Errorf("Got timestamp %d, want %d", got, want)
+	}
+
+	if got, want := len(head.RootHash), 32; got != want {
+		t.Errorf("Got %d byte root hash, want %d", got, want)
+	}
+}
+
+func TestCTSourceGetConsistencyProof(t *testing.T) {
+	server := newTestCTServer(t)
+	defer server.Close()
+
+	proof, err := newCTSource(server.URL, http.DefaultClient).GetConsistencyProof(context.Background(), 3, 7)
+
+	if err != nil {
+		t.Fatalf("Failed to get consistency proof: %v", err)
+	}
+
+	if len(proof) != 2 || !bytes.Equal(proof[0], []byte{0, 1, 2}) || !bytes.Equal(proof[1], []byte{3, 4, 5}) {
+		t.Errorf("Got proof %v, want [[0 1 2] [3 4 5]]", proof)
+	}
+}
+
+func TestCTSourceGetLeaves(t *testing.T) {
+	server := newTestCTServer(t)
+	defer server.Close()
+
+	leaves, err := newCTSource(server.URL, http.DefaultClient).GetLeaves(context.Background(), 4, 3)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if got, want := len(leaves), 2; got != want {
+		t.Fatalf("Got %d leaves, want %d", got, want)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for i, leaf := range leaves {
+		if got, want := leaf.LeafIndex, int64(4+i); got != want {
+			t.Errorf("Got leaf index %d, want %d", got, want)
+		}
+
+		// The leaf hash is computed rather than trusted from the log
+		if got, want := leaf.LeafHash, hasher.HashLeaf(leaf.LeafData); !bytes.Equal(got, want) {
+			t.Errorf("Got leaf hash %v, want %v", got, want)
+		}
+	}
+
+	if got, want := leaves[0].ExtraData, []byte{4, 5}; !bytes.Equal(got, want) {
+		t.Errorf("Got extra data %v, want %v", got, want)
+	}
+}
+
+func TestCTSourceHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := newCTSource(server.URL, http.DefaultClient).GetTreeHead(context.Background()); err == nil {
+		t.Fatalf("Got tree head from a log returning errors")
+	}
+}
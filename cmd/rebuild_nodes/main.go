@@ -0,0 +1,114 @@
+// The rebuild_nodes binary recovers a log whose stored nodes are damaged or missing but
+// whose leaves are intact. It recomputes every subtree and upper tree node from the leaf
+// hashes, checks the recomputed root against the latest signed root and stores the nodes
+// with a new root of the same size and hash, signed with the log's key. The stored nodes are
+// then read back and checked against the root. Run it while no signer is sequencing the log,
+// and use integrity_check afterwards to check every node.
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
+	_ "github.com/google/trillian/storage/postgres"
+)
+
+var storageSystemFlag = flag.String("storage_system", mysql.ProviderName, "Name of the registered storage system holding the log")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "uri to use with the selected storage system")
+var treeIDFlag = flag.Int64("tree_id", 0, "Tree id of the log to rebuild")
+var batchSizeFlag = flag.Int64("batch_size", 1024, "Number of leaves read from storage at a time")
+var privateKeyFile = flag.String("private_key_file", "", "File containing a PEM encoded private key used if the log doesn't name its own key")
+var privateKeyPassword = flag.String("private_key_password", "", "Password for the private key and any PEM key named by the log")
+
+func main() {
+	flag.Parse()
+
+	if *treeIDFlag == 0 {
+		glog.Fatal("The log to rebuild must be given with --tree_id")
+	}
+
+	provider, err := storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+	if err != nil {
+		glog.Fatalf("Failed to create storage provider %s: %v", *storageSystemFlag, err)
+	}
+
+	ctx := context.Background()
+
+	tree, err := getTree(ctx, provider, *treeIDFlag)
+	if err != nil {
+		glog.Fatalf("Failed to read tree %d: %v", *treeIDFlag, err)
+	}
+
+	if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		glog.Fatalf("Tree %d is a %v, only logs can be rebuilt", tree.TreeId, tree.TreeType)
+	}
+
+	logID := trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId}
+
+	km, err := keyManager(logID)
+	if err != nil {
+		glog.Fatalf("Failed to get the signing key of log %d: %v", tree.TreeId, err)
+	}
+
+	s, err := provider.LogStorage(logID)
+	if err != nil {
+		glog.Fatalf("Failed to get storage for log %d: %v", tree.TreeId, err)
+	}
+
+	result, err := rebuildLog(ctx, s, km, util.SystemTimeSource{}, *batchSizeFlag)
+	if err != nil {
+		glog.Fatalf("Failed to rebuild log %d: %v", tree.TreeId, err)
+	}
+
+	fmt.Printf("log %d: rebuilt the nodes of %d leaves at revision %d, stored at revision %d\n", tree.TreeId, result.TreeSize, result.Revision, result.NewRevision)
+}
+
+// keyManager returns the key manager for the key of the log, as the log server selects it
+func keyManager(logID trillian.LogID) (crypto.KeyManager, error) {
+	if err := crypto.RegisterKeyManager(crypto.PEMKeyScheme, crypto.NewPEMFileKeyManagerFunc(*privateKeyPassword)); err != nil {
+		return nil, err
+	}
+	if err := crypto.RegisterKeyManager(crypto.RotatingKeyScheme, crypto.NewRotatingKeyManagerFunc(util.SystemTimeSource{})); err != nil {
+		return nil, err
+	}
+	if err := crypto.RegisterKeyManager(crypto.DeterministicECDSAScheme, crypto.NewDeterministicKeyManagerFunc()); err != nil {
+		return nil, err
+	}
+
+	var defaultKM crypto.KeyManager
+
+	if len(*privateKeyFile) > 0 {
+		var err error
+		if defaultKM, err = crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	return server.NewKeyManagerProvider(defaultKM)(logID)
+}
+
+func getTree(ctx context.Context, provider storage.Provider, treeID int64) (*trillian.Tree, error) {
+	as, err := provider.AdminStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := as.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	return tx.GetTree(treeID)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// maxTreeDepth is the depth of the node IDs the sequencer writes log nodes with
+const maxTreeDepth = 64
+
+// Result is the outcome of rebuilding the nodes of a log
+type Result struct {
+	TreeSize int64
+	// Revision is the revision of the signed root the nodes were rebuilt for, NewRevision the
+	// revision of the root they were stored with
+	Revision    int64
+	NewRevision int64
+}
+
+// rebuildLog recomputes every node of a log from its leaf hashes, reading batchSize leaves
+// at a time, and stores them in place of the nodes in storage. The leaves must be intact:
+// nothing is written unless the recomputed root matches the latest signed root.
+//
+// Readers see the nodes of the tree at the revision of a root, so the rebuilt nodes are
+// stored with a new root at the next revision, with the same size and hash as the latest
+// one and signed by km. The nodes are then read back through storage, which populates the
+// internal nodes of subtrees from their leaves, and checked against the root.
+func rebuildLog(ctx context.Context, s storage.LogStorage, km crypto.KeyManager, timeSource util.TimeSource, batchSize int64) (*Result, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be > 0 but was %d", batchSize)
+	}
+
+	hasher, err := merkle.NewTreeHasher(s.HashAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := newSigner(km)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		glog.Warningf("Failed to read latest signed root: %v", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	tree := merkle.NewCompactMerkleTree(hasher)
+
+	for start := int64(0); start < root.TreeSize; start += batchSize {
+		if err := rebuildBatch(ctx, tx, tree, root.TreeSize, start, batchSize); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if computed := tree.CurrentRoot(); !bytes.Equal(computed, root.RootHash) {
+		tx.Rollback()
+		return nil, fmt.Errorf("root recomputed from the leaves is %x but the signed root is %x, the leaves aren't intact", computed, root.RootHash)
+	}
+
+	newRoot := trillian.SignedLogRoot{
+		RootHash:       root.RootHash,
+		TimestampNanos: timeSource.Now().UnixNano(),
+		TreeSize:       root.TreeSize,
+		LogId:          root.LogId,
+		TreeRevision:   tx.WriteRevision(),
+		Metadata:       root.Metadata,
+	}
+
+	signature, err := signer.SignLogRoot(newRoot)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	newRoot.Signature = &signature
+
+	if err := tx.StoreSignedLogRoot(ctx, newRoot); err != nil {
+		glog.Warningf("Failed to store root at revision %d: %v", newRoot.TreeRevision, err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The sequencer stores a checkpoint with each root, so it also resumes from this one
+	checkpoint := storage.SequencerCheckpoint{TreeRevision: newRoot.TreeRevision, CompactTree: tree.Proto()}
+
+	if err := tx.StoreSequencerCheckpoint(ctx, checkpoint); err != nil {
+		glog.Warningf("Failed to store checkpoint at revision %d: %v", newRoot.TreeRevision, err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := verifyRoot(ctx, s, hasher, newRoot); err != nil {
+		return nil, fmt.Errorf("rebuilt nodes don't match the signed root: %v", err)
+	}
+
+	return &Result{TreeSize: root.TreeSize, Revision: root.TreeRevision, NewRevision: newRoot.TreeRevision}, nil
+}
+
+// newSigner returns a signer for roots using the key held by km, as the sequencer does. Roots
+// are signed over their SHA-256 digest whatever the tree's hash algorithm, as verifiers expect.
+func newSigner(km crypto.KeyManager) (*crypto.TrillianSigner, error) {
+	signer, err := km.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	sigAlgorithm, err := crypto.SignatureAlgorithmForKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer), nil
+}
+
+// rebuildBatch adds the leaves from start to tree and writes the nodes they set, including
+// the leaf nodes, at the write revision of tx
+func rebuildBatch(ctx context.Context, tx storage.LogTX, tree *merkle.CompactMerkleTree, treeSize, start, batchSize int64) error {
+	count := batchSize
+	if start+count > treeSize {
+		count = treeSize - start
+	}
+
+	leaves, err := tx.GetLeavesByRange(ctx, start, count)
+	if err != nil {
+		glog.Warningf("Failed to read leaves [%d, %d): %v", start, start+count, err)
+		return err
+	}
+
+	if int64(len(leaves)) != count {
+		return fmt.Errorf("read %d leaves from %d, expected %d, the rest are missing", len(leaves), start, count)
+	}
+
+	nodes := make(map[string]storage.Node)
+	var nodeErr error
+
+	record := func(depth int, index int64, hash trillian.Hash) {
+		id, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+		if err != nil {
+			nodeErr = err
+			return
+		}
+
+		nodes[id.String()] = storage.Node{NodeID: id, Hash: hash, NodeRevision: tx.WriteRevision()}
+	}
+
+	for l, leaf := range leaves {
+		if want := start + int64(l); leaf.SequenceNumber != want {
+			return fmt.Errorf("read leaf %d where leaf %d should be", leaf.SequenceNumber, want)
+		}
+
+		seq := tree.AddLeafHash(leaf.LeafHash, record)
+		record(0, seq, leaf.LeafHash)
+	}
+
+	if nodeErr != nil {
+		return nodeErr
+	}
+
+	batch := make([]storage.Node, 0, len(nodes))
+	for _, n := range nodes {
+		batch = append(batch, n)
+	}
+
+	if err := tx.SetMerkleNodes(ctx, batch); err != nil {
+		glog.Warningf("Failed to write nodes for leaves [%d, %d): %v", start, start+count, err)
+		return err
+	}
+
+	return nil
+}
+
+// verifyRoot reads the nodes that the compact tree of root is made from at its revision and
+// checks that they hash to its root hash
+func verifyRoot(ctx context.Context, s storage.LogStorage, hasher merkle.TreeHasher, root trillian.SignedLogRoot) error {
+	if root.TreeSize == 0 {
+		return nil
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Commit()
+
+	getNode := func(depth int, index int64) (trillian.Hash, error) {
+		id, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes, err := tx.GetMerkleNodes(ctx, root.TreeRevision, []storage.NodeID{id})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(nodes) != 1 {
+			return nil, fmt.Errorf("node at depth %d index %d is missing at revision %d", depth, index, root.TreeRevision)
+		}
+
+		return nodes[0].Hash, nil
+	}
+
+	// The compact tree of a perfect tree is just its root, which NewCompactMerkleTreeWithState
+	// takes from the signed root rather than reading, so it's read here
+	if root.TreeSize&(root.TreeSize-1) == 0 {
+		depth := 0
+		for size := root.TreeSize; size > 1; size >>= 1 {
+			depth++
+		}
+
+		hash, err := getNode(depth, 0)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(hash, root.RootHash) {
+			return fmt.Errorf("node at depth %d index 0 has hash %x, expected %x", depth, hash, root.RootHash)
+		}
+
+		return nil
+	}
+
+	_, err = merkle.NewCompactMerkleTreeWithState(hasher, root.TreeSize, getNode, root.RootHash)
+	return err
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// treeSize is enough leaves to fill a subtree and start the next
+const treeSize = 300
+
+var signedTimestamp = trillian.SignedEntryTimestamp{Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+// databases counts the memory databases created, so each test gets a new one
+var databases int
+
+func newKeyManager(t *testing.T) crypto.KeyManager {
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	return km
+}
+
+func newHasher(t *testing.T) merkle.TreeHasher {
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	return hasher
+}
+
+// createLog returns memory storage holding a log of size leaves, sequenced a few at a time
+// so that the nodes are written at several revisions
+func createLog(t *testing.T, size int) storage.LogStorage {
+	ctx := context.Background()
+	databases++
+
+	p, err := storage.NewProvider(memory.ProviderName, fmt.Sprintf("%s-%d", t.Name(), databases))
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("rebuild"), TreeID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	hasher := newHasher(t)
+
+	leaves := make([]trillian.LogLeaf, 0, size)
+	for l := 0; l < size; l++ {
+		value := []byte(fmt.Sprintf("Leaf %d", l))
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(value), LeafValue: value}, SignedEntryTimestamp: signedTimestamp})
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, s, newKeyManager(t))
+
+	if err := sequencer.SignRoot(ctx); err != nil {
+		t.Fatalf("SignRoot() = %v", err)
+	}
+
+	for integrated := 0; integrated < size; {
+		n, err := sequencer.SequenceBatch(ctx, 37, func(trillian.SignedLogRoot) bool { return false })
+		if err != nil {
+			t.Fatalf("SequenceBatch() = %v", err)
+		}
+
+		integrated += n
+	}
+
+	return s
+}
+
+// corrupt stores nodes, and a root that's the latest with a new revision, so that the nodes
+// replace the stored ones at the revision of the new root. If rootHash isn't nil it replaces
+// the hash of the root.
+func corrupt(t *testing.T, s storage.LogStorage, nodes []storage.Node, rootHash trillian.Hash) {
+	ctx := context.Background()
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = %v", err)
+	}
+
+	for i := range nodes {
+		nodes[i].NodeRevision = tx.WriteRevision()
+	}
+
+	if len(nodes) > 0 {
+		if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+			t.Fatalf("SetMerkleNodes() = %v", err)
+		}
+	}
+
+	root.TreeRevision = tx.WriteRevision()
+	root.TimestampNanos++
+
+	if rootHash != nil {
+		root.RootHash = rootHash
+	}
+
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+		t.Fatalf("StoreSignedLogRoot() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+}
+
+func mustNodeID(t *testing.T, depth, index int64) storage.NodeID {
+	id, err := storage.NewNodeIDForTreeCoords(depth, index, maxTreeDepth)
+	if err != nil {
+		t.Fatalf("Failed to create node ID: %v", err)
+	}
+
+	return id
+}
+
+func latestRoot(t *testing.T, s storage.LogStorage) trillian.SignedLogRoot {
+	ctx := context.Background()
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = %v", err)
+	}
+
+	return root
+}
+
+// checkpointRevision returns the revision of the sequencer checkpoint of the log
+func checkpointRevision(t *testing.T, s storage.LogStorage) int64 {
+	ctx := context.Background()
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+	defer tx.Rollback()
+
+	checkpoint, err := tx.GetSequencerCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("GetSequencerCheckpoint() = %v", err)
+	}
+
+	if checkpoint == nil {
+		t.Fatal("Log has no sequencer checkpoint")
+	}
+
+	return checkpoint.TreeRevision
+}
+
+// storedNode returns the hash of the node stored at depth and index at revision
+func storedNode(t *testing.T, s storage.LogStorage, revision, depth, index int64) trillian.Hash {
+	ctx := context.Background()
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	defer tx.Commit()
+
+	nodes, err := tx.GetMerkleNodes(ctx, revision, []storage.NodeID{mustNodeID(t, depth, index)})
+	if err != nil {
+		t.Fatalf("GetMerkleNodes() = %v", err)
+	}
+
+	if len(nodes) != 1 {
+		t.Fatalf("Node at depth %d index %d is missing at revision %d", depth, index, revision)
+	}
+
+	return nodes[0].Hash
+}
+
+func TestRebuildLog(t *testing.T) {
+	for _, size := range []int{0, 1, 255, 256, treeSize} {
+		s := createLog(t, size)
+		before := latestRoot(t, s)
+
+		for _, batchSize := range []int64{7, 1024} {
+			result, err := rebuildLog(context.Background(), s, newKeyManager(t), util.SystemTimeSource{}, batchSize)
+			if err != nil {
+				t.Fatalf("Size %d, batch size %d: rebuildLog() = %v", size, batchSize, err)
+			}
+
+			after := latestRoot(t, s)
+
+			if result.TreeSize != int64(size) || result.NewRevision != after.TreeRevision {
+				t.Errorf("Size %d, batch size %d: got result %+v, latest root is at revision %d", size, batchSize, result, after.TreeRevision)
+			}
+
+			if after.TreeSize != before.TreeSize || !bytes.Equal(after.RootHash, before.RootHash) {
+				t.Errorf("Size %d, batch size %d: new root %v doesn't match the old root %v", size, batchSize, after, before)
+			}
+
+			if after.Signature == nil || len(after.Signature.Signature) == 0 {
+				t.Errorf("Size %d, batch size %d: new root isn't signed", size, batchSize)
+			}
+
+			if got := checkpointRevision(t, s); got != after.TreeRevision {
+				t.Errorf("Size %d, batch size %d: checkpoint is at revision %d, want %d", size, batchSize, got, after.TreeRevision)
+			}
+		}
+	}
+}
+
+func TestRebuildLogRepairsNodes(t *testing.T) {
+	s := createLog(t, treeSize)
+	hasher := newHasher(t)
+
+	// The root of the first subtree is the root of a tree of its leaves
+	first := merkle.NewCompactMerkleTree(hasher)
+	for l := 0; l < 256; l++ {
+		first.AddLeaf([]byte(fmt.Sprintf("Leaf %d", l)), func(int, int64, trillian.Hash) {})
+	}
+
+	corrupt(t, s, []storage.Node{
+		{NodeID: mustNodeID(t, 0, 5), Hash: []byte("corrupt")},
+		{NodeID: mustNodeID(t, 0, 299), Hash: []byte("corrupt")},
+		{NodeID: mustNodeID(t, 8, 0), Hash: []byte("corrupt")},
+	}, nil)
+
+	result, err := rebuildLog(context.Background(), s, newKeyManager(t), util.SystemTimeSource{}, 64)
+	if err != nil {
+		t.Fatalf("rebuildLog() = %v", err)
+	}
+
+	for _, n := range []struct {
+		depth, index int64
+		want         trillian.Hash
+	}{
+		{depth: 0, index: 5, want: hasher.HashLeaf([]byte("Leaf 5"))},
+		{depth: 0, index: 299, want: hasher.HashLeaf([]byte("Leaf 299"))},
+		{depth: 8, index: 0, want: first.CurrentRoot()},
+	} {
+		if got := storedNode(t, s, result.NewRevision, n.depth, n.index); !bytes.Equal(got, n.want) {
+			t.Errorf("Node at depth %d index %d is %x after rebuilding, want %x", n.depth, n.index, got, n.want)
+		}
+	}
+}
+
+func TestRebuildLogRejectsWrongRoot(t *testing.T) {
+	s := createLog(t, treeSize)
+	corrupt(t, s, nil, []byte("wrong root"))
+	before := latestRoot(t, s)
+
+	if _, err := rebuildLog(context.Background(), s, newKeyManager(t), util.SystemTimeSource{}, 1024); err == nil {
+		t.Fatal("rebuildLog() succeeded for leaves that don't match the root")
+	}
+
+	if after := latestRoot(t, s); after.TreeRevision != before.TreeRevision {
+		t.Errorf("rebuildLog() stored a root at revision %d after failing", after.TreeRevision)
+	}
+}
+
+func TestRebuildLogRejectsBadBatchSize(t *testing.T) {
+	if _, err := rebuildLog(context.Background(), createLog(t, 1), newKeyManager(t), util.SystemTimeSource{}, 0); err == nil {
+		t.Error("rebuildLog() accepted a batch size of 0")
+	}
+}
@@ -0,0 +1,117 @@
+// The rotate_leaf_keys binary rotates the data key that a log's leaf values are encrypted with,
+// see the leaf_data_key_files flag of the log server. With --add_key it first adds a new key to
+// the log's data key file, which servers use for new values once they're restarted. It then
+// rewraps the content key of every blob of the log with the newest key, and encrypts any blobs
+// put in the store before encryption was turned on. Once it has finished the older keys can be
+// removed from the file. If the log's data keys are wrapped, see leaf_data_key_wrappers, the
+// same wrapping key must be given with --data_key_wrapper.
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/storage/blob"
+	"golang.org/x/net/context"
+)
+
+var blobStoreFlag = flag.String("blob_store", "", "URI of the blob store holding the log's leaf values, as given to leaf_blob_stores")
+var treeIDFlag = flag.Int64("tree_id", 0, "Tree id of the log whose values are rewrapped")
+var dataKeyFileFlag = flag.String("data_key_file", "", "File holding the log's data keys, as given to leaf_data_key_files")
+var dataKeyWrapperFlag = flag.String("data_key_wrapper", "", "URI of the key that the log's data keys are wrapped by, as given to leaf_data_key_wrappers, empty if they aren't wrapped")
+var addKeyFlag = flag.Bool("add_key", false, "If true a new data key is added to data_key_file before the blobs are rewrapped with it")
+
+func main() {
+	flag.Parse()
+
+	if *treeIDFlag == 0 || len(*blobStoreFlag) == 0 || len(*dataKeyFileFlag) == 0 {
+		glog.Fatal("The log must be given with --tree_id, --blob_store and --data_key_file")
+	}
+
+	var w crypto.KeyWrapper
+	if len(*dataKeyWrapperFlag) > 0 {
+		var err error
+		if w, err = crypto.NewKeyWrapper(*dataKeyWrapperFlag); err != nil {
+			glog.Fatalf("Failed to create data key wrapper (registered: %v): %v", crypto.KeyWrapperSchemes(), err)
+		}
+	}
+
+	keys, err := loadKeys(*dataKeyFileFlag, w)
+	if err != nil {
+		glog.Fatalf("Failed to load data keys: %v", err)
+	}
+
+	if *addKeyFlag {
+		if keys, err = addKey(*dataKeyFileFlag, keys, w); err != nil {
+			glog.Fatalf("Failed to add data key: %v", err)
+		}
+	}
+
+	store, err := blob.NewStore(*blobStoreFlag)
+	if err != nil {
+		glog.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	lister, ok := store.(blob.Lister)
+	if !ok {
+		glog.Fatalf("Blob store %s can't list its blobs", *blobStoreFlag)
+	}
+
+	ctx := context.Background()
+	blobKeys, err := lister.List(ctx, fmt.Sprintf("%d/", *treeIDFlag))
+	if err != nil {
+		glog.Fatalf("Failed to list blobs of log %d: %v", *treeIDFlag, err)
+	}
+
+	e := blob.NewEncryptedStore(store, keys)
+	rewrapped := 0
+
+	for _, key := range blobKeys {
+		rewritten, err := e.Rewrap(ctx, key)
+		if err != nil {
+			glog.Fatalf("Failed to rewrap blob %s: %v", key, err)
+		}
+		if rewritten {
+			rewrapped++
+		}
+	}
+
+	current, err := keys.CurrentDataKey()
+	if err != nil {
+		glog.Fatalf("Failed to get current data key: %v", err)
+	}
+
+	fmt.Printf("log %d: rewrapped %d of %d blobs with data key version %d\n", *treeIDFlag, rewrapped, len(blobKeys), current.Version)
+}
+
+func loadKeys(path string, w crypto.KeyWrapper) (*crypto.StaticDataKeyManager, error) {
+	if w == nil {
+		return crypto.LoadDataKeyFile(path)
+	}
+
+	return crypto.LoadWrappedDataKeyFile(path, w)
+}
+
+// addKey writes a new key, with the next version, to the key file and returns all the keys.
+// The keys are wrapped by w if it isn't nil.
+func addKey(path string, keys *crypto.StaticDataKeyManager, w crypto.KeyWrapper) (*crypto.StaticDataKeyManager, error) {
+	current, err := keys.CurrentDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := crypto.GenerateDataKey(current.Version + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	all := append(keys.Keys(), key)
+	if err := crypto.WriteWrappedDataKeyFile(path, all, w); err != nil {
+		return nil, err
+	}
+
+	glog.Infof("Added data key version %d to %s", key.Version, path)
+	return crypto.NewStaticDataKeyManager(all)
+}
@@ -0,0 +1,57 @@
+// The subtree_convert binary converts a storage.SubtreeProto between the text, binary and
+// canonical formats, e.g. to regenerate the subtree test fixtures in testdata or to inspect
+// a subtree exported from storage.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+)
+
+var inFlag = flag.String("in", "", "File to read the subtree from, stdin if empty")
+var outFlag = flag.String("out", "", "File to write the converted subtree to, stdout if empty")
+var inFormatFlag = flag.String("in_format", "text", "Format of the input, one of text, binary or canonical")
+var outFormatFlag = flag.String("out_format", "canonical", "Format of the output, one of text, binary or canonical")
+
+func main() {
+	flag.Parse()
+
+	var data []byte
+	var err error
+
+	if len(*inFlag) > 0 {
+		data, err = ioutil.ReadFile(*inFlag)
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+
+	if err != nil {
+		glog.Fatalf("Failed to read subtree: %v", err)
+	}
+
+	st, err := storage.UnmarshalSubtree(data, storage.SubtreeFormat(*inFormatFlag))
+
+	if err != nil {
+		glog.Fatalf("Failed to parse subtree: %v", err)
+	}
+
+	out, err := storage.MarshalSubtree(st, storage.SubtreeFormat(*outFormatFlag))
+
+	if err != nil {
+		glog.Fatalf("Failed to convert subtree: %v", err)
+	}
+
+	if len(*outFlag) > 0 {
+		err = ioutil.WriteFile(*outFlag, out, 0644)
+	} else {
+		_, err = os.Stdout.Write(out)
+	}
+
+	if err != nil {
+		glog.Fatalf("Failed to write subtree: %v", err)
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// Client runs the commands of trillian_client against one tree, writing what the servers
+// return to out. Only the gRPC clients used by the commands that are run need to be set.
+type Client struct {
+	TreeID int64
+	Log    trillian.TrillianLogClient
+	Map    trillian.TrillianMapClient
+	Admin  trillian.TrillianAdminClient
+	// Hasher must be the one the tree was created with, proofs are checked with it
+	Hasher merkle.TreeHasher
+	// PubKey checks the signatures of the roots returned by the servers. They're not checked
+	// if it's nil, but proofs are still checked against the roots.
+	PubKey gocrypto.PublicKey
+	Out    io.Writer
+}
+
+func checkStatus(op string, status *trillian.TrillianApiStatus) error {
+	if status == nil || status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("%s failed: %v", op, status)
+	}
+
+	return nil
+}
+
+// proofHashes returns the node hashes of proof in order
+func proofHashes(proof *trillian.ProofProto) [][]byte {
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+
+	for _, node := range proof.ProofNode {
+		hashes = append(hashes, node.NodeHash)
+	}
+
+	return hashes
+}
+
+func (c *Client) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.Out, format, args...)
+}
+
+func (c *Client) printProto(pb proto.Message) {
+	fmt.Fprint(c.Out, proto.MarshalTextString(pb))
+}
+
+// latestLogRoot fetches the latest root of the log and checks its signature
+func (c *Client) latestLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	resp, err := c.Log.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: c.TreeID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStatus("GetLatestSignedLogRoot", resp.Status); err != nil {
+		return nil, err
+	}
+
+	if resp.SignedLogRoot == nil {
+		return nil, fmt.Errorf("log %d returned no root", c.TreeID)
+	}
+
+	if c.PubKey != nil {
+		if err := crypto.VerifySignedLogRoot(c.PubKey, *resp.SignedLogRoot); err != nil {
+			return nil, fmt.Errorf("log %d root at size %d has a bad signature: %v", c.TreeID, resp.SignedLogRoot.TreeSize, err)
+		}
+	}
+
+	return resp.SignedLogRoot, nil
+}
+
+// verifyMapRoot checks the signature of a root returned by the map
+func (c *Client) verifyMapRoot(root *trillian.SignedMapRoot) error {
+	if root == nil {
+		return fmt.Errorf("map %d returned no root", c.TreeID)
+	}
+
+	if c.PubKey == nil {
+		return nil
+	}
+
+	if err := crypto.VerifySignedMapRoot(c.PubKey, *root); err != nil {
+		return fmt.Errorf("map %d root at revision %d has a bad signature: %v", c.TreeID, root.MapRevision, err)
+	}
+
+	return nil
+}
+
+// QueueLeaf queues data, with extraData, to be added to the log and prints its leaf hash.
+func (c *Client) QueueLeaf(ctx context.Context, data, extraData []byte) error {
+	leaf := &trillian.LeafProto{LeafHash: c.Hasher.HashLeaf(data), LeafData: data, ExtraData: extraData}
+	resp, err := c.Log.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: c.TreeID, Leaves: []*trillian.LeafProto{leaf}})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("QueueLeaves", resp.Status); err != nil {
+		return err
+	}
+
+	if len(resp.Leaves) != 1 {
+		return fmt.Errorf("log %d returned %d results for 1 leaf", c.TreeID, len(resp.Leaves))
+	}
+
+	switch result := resp.Leaves[0]; result.Status {
+	case trillian.QueuedLeafStatus_QUEUED:
+		c.printf("Queued leaf with hash %x\n", leaf.LeafHash)
+	case trillian.QueuedLeafStatus_DUPLICATE:
+		c.printf("Leaf with hash %x is already in the log\n", leaf.LeafHash)
+
+		if result.ExistingLeaf != nil && result.ExistingLeaf.LeafIndex >= 0 {
+			c.printf("It has index %d\n", result.ExistingLeaf.LeafIndex)
+		}
+	case trillian.QueuedLeafStatus_REJECTED:
+		return fmt.Errorf("log %d rejected leaf: %v %s", c.TreeID, result.RejectionCode, result.Reason)
+	default:
+		return fmt.Errorf("log %d returned unknown status %v for leaf", c.TreeID, result.Status)
+	}
+
+	return nil
+}
+
+// GetSTH prints the latest signed root of the log.
+func (c *Client) GetSTH(ctx context.Context) error {
+	root, err := c.latestLogRoot(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	c.printProto(root)
+	return nil
+}
+
+// GetProof fetches the inclusion proof of the leaf holding data in the latest root of the log
+// and checks it. Leaves with the same data, in logs that allow duplicates, each have a proof.
+func (c *Client) GetProof(ctx context.Context, data []byte) error {
+	root, err := c.latestLogRoot(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	leafHash := c.Hasher.HashLeaf(data)
+	resp, err := c.Log.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{LogId: c.TreeID, LeafHash: leafHash, TreeSize: root.TreeSize, OrderBySequence: true})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("GetInclusionProofByHash", resp.Status); err != nil {
+		return err
+	}
+
+	if len(resp.Proof) == 0 {
+		return fmt.Errorf("log %d has no leaf with hash %x at size %d", c.TreeID, leafHash, root.TreeSize)
+	}
+
+	for _, proof := range resp.Proof {
+		if err := c.verifyInclusion(root, proof.LeafIndex, leafHash, proof); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetProofAtIndex fetches the leaf at leafIndex and its inclusion proof in the latest root of
+// the log, and checks the proof against the hash of the leaf data.
+func (c *Client) GetProofAtIndex(ctx context.Context, leafIndex int64) error {
+	root, err := c.latestLogRoot(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if leafIndex < 0 || leafIndex >= root.TreeSize {
+		return fmt.Errorf("leaf %d is outside log %d at size %d", leafIndex, c.TreeID, root.TreeSize)
+	}
+
+	leaves, err := c.Log.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: c.TreeID, LeafIndex: []int64{leafIndex}})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("GetLeavesByIndex", leaves.Status); err != nil {
+		return err
+	}
+
+	if len(leaves.Leaves) != 1 || leaves.Leaves[0].LeafIndex != leafIndex {
+		return fmt.Errorf("log %d didn't return leaf %d", c.TreeID, leafIndex)
+	}
+
+	resp, err := c.Log.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: c.TreeID, LeafIndex: leafIndex, TreeSize: root.TreeSize})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("GetInclusionProof", resp.Status); err != nil {
+		return err
+	}
+
+	if resp.Proof == nil {
+		return fmt.Errorf("log %d returned no inclusion proof for leaf %d", c.TreeID, leafIndex)
+	}
+
+	// The leaf hash is computed here rather than taken from the server, so the proof also
+	// covers the data that was returned
+	return c.verifyInclusion(root, leafIndex, c.Hasher.HashLeaf(leaves.Leaves[0].LeafData), resp.Proof)
+}
+
+func (c *Client) verifyInclusion(root *trillian.SignedLogRoot, leafIndex int64, leafHash []byte, proof *trillian.ProofProto) error {
+	if err := merkle.NewLogVerifier(c.Hasher).VerifyInclusionProof(leafIndex, root.TreeSize, proofHashes(proof), root.RootHash, leafHash); err != nil {
+		return fmt.Errorf("log %d returned a bad inclusion proof for leaf %d at size %d: %v", c.TreeID, leafIndex, root.TreeSize, err)
+	}
+
+	c.printf("Verified inclusion of leaf %d with hash %x at tree size %d\n", leafIndex, leafHash, root.TreeSize)
+	c.printProto(proof)
+	return nil
+}
+
+// GetConsistency fetches the consistency proof from firstSize to the latest root of the log.
+// The proof is checked if firstRootHash, the root hash at firstSize, is given, and printed
+// without being checked otherwise.
+func (c *Client) GetConsistency(ctx context.Context, firstSize int64, firstRootHash []byte) error {
+	root, err := c.latestLogRoot(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if firstSize < 1 || firstSize > root.TreeSize {
+		return fmt.Errorf("size %d is outside log %d at size %d", firstSize, c.TreeID, root.TreeSize)
+	}
+
+	resp, err := c.Log.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: c.TreeID, FirstTreeSize: firstSize, SecondTreeSize: root.TreeSize})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("GetConsistencyProof", resp.Status); err != nil {
+		return err
+	}
+
+	if resp.Proof == nil {
+		return fmt.Errorf("log %d returned no consistency proof from %d to %d", c.TreeID, firstSize, root.TreeSize)
+	}
+
+	if len(firstRootHash) > 0 {
+		if err := merkle.NewLogVerifier(c.Hasher).VerifyConsistencyProof(firstSize, root.TreeSize, firstRootHash, root.RootHash, proofHashes(resp.Proof)); err != nil {
+			return fmt.Errorf("log %d returned a bad consistency proof from %d to %d: %v", c.TreeID, firstSize, root.TreeSize, err)
+		}
+
+		c.printf("Verified consistency of tree size %d with %d\n", firstSize, root.TreeSize)
+	} else {
+		c.printf("Consistency proof from tree size %d to %d, not checked without the root hash at %d\n", firstSize, root.TreeSize, firstSize)
+	}
+
+	c.printProto(resp.Proof)
+	return nil
+}
+
+// MapGet fetches the values of keys at revision, or at the latest revision if revision is
+// < 0, checks their proofs against the map root and prints them.
+func (c *Client) MapGet(ctx context.Context, keys [][]byte, revision int64) error {
+	values, root, err := c.mapGet(ctx, keys, revision)
+
+	if err != nil {
+		return err
+	}
+
+	c.printf("Verified %d values at map revision %d\n", len(values), root.MapRevision)
+
+	for i, key := range keys {
+		if values[i] == nil {
+			c.printf("%q has no value\n", key)
+		} else {
+			c.printf("%q = %q\n", key, values[i])
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) mapGet(ctx context.Context, keys [][]byte, revision int64) ([][]byte, *trillian.SignedMapRoot, error) {
+	resp, err := c.Map.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: c.TreeID, Key: keys, Revision: revision})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkStatus("GetLeaves", resp.Status); err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.verifyMapRoot(resp.MapRoot); err != nil {
+		return nil, nil, err
+	}
+
+	if revision >= 0 && resp.MapRoot.MapRevision != revision {
+		return nil, nil, fmt.Errorf("map %d returned root for revision %d, expected %d", c.TreeID, resp.MapRoot.MapRevision, revision)
+	}
+
+	if resp.MapRootStale {
+		c.printf("Map root at revision %d is older than the map's max root age\n", resp.MapRoot.MapRevision)
+	}
+
+	if len(resp.KeyValue) != len(keys) {
+		return nil, nil, fmt.Errorf("map %d returned %d values for %d keys", c.TreeID, len(resp.KeyValue), len(keys))
+	}
+
+	verifier := merkle.NewMapVerifier(merkle.NewMapHasher(c.Hasher))
+	values := make([][]byte, 0, len(keys))
+
+	for i, kvi := range resp.KeyValue {
+		if kvi.KeyValue == nil || !bytes.Equal(kvi.KeyValue.Key, keys[i]) {
+			return nil, nil, fmt.Errorf("map %d returned value %d for the wrong key", c.TreeID, i)
+		}
+
+		var value []byte
+
+		if kvi.KeyValue.Value != nil {
+			value = kvi.KeyValue.Value.LeafValue
+		}
+
+		if err := verifier.VerifyMapInclusion(resp.MapRoot.RootHash, keys[i], value, kvi.Inclusion); err != nil {
+			return nil, nil, fmt.Errorf("map %d returned a bad proof for key %q at revision %d: %v", c.TreeID, keys[i], resp.MapRoot.MapRevision, err)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, resp.MapRoot, nil
+}
+
+// MapSet sets the values of keys in the map and prints the new root. The values are then
+// read back at the new revision and checked against it.
+func (c *Client) MapSet(ctx context.Context, keys, values [][]byte) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("got %d keys and %d values", len(keys), len(values))
+	}
+
+	req := &trillian.SetMapLeavesRequest{MapId: c.TreeID}
+	mapHasher := merkle.NewMapHasher(c.Hasher)
+
+	for i, key := range keys {
+		leaf := &trillian.MapLeaf{KeyHash: mapHasher.HashKey(key), LeafHash: mapHasher.HashLeaf(values[i]), LeafValue: values[i]}
+		req.KeyValue = append(req.KeyValue, &trillian.KeyValue{Key: key, Value: leaf})
+	}
+
+	resp, err := c.Map.SetLeaves(ctx, req)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("SetLeaves", resp.Status); err != nil {
+		return err
+	}
+
+	if err := c.verifyMapRoot(resp.MapRoot); err != nil {
+		return err
+	}
+
+	got, _, err := c.mapGet(ctx, keys, resp.MapRoot.MapRevision)
+
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if !bytes.Equal(got[i], values[i]) {
+			return fmt.Errorf("map %d has value %q for key %q at revision %d, expected %q", c.TreeID, got[i], key, resp.MapRoot.MapRevision, values[i])
+		}
+	}
+
+	c.printf("Set and verified %d values at map revision %d\n", len(keys), resp.MapRoot.MapRevision)
+	c.printProto(resp.MapRoot)
+	return nil
+}
+
+// ListTrees prints the trees known to the admin server, including deleted ones if
+// showDeleted is set.
+func (c *Client) ListTrees(ctx context.Context, showDeleted bool) error {
+	resp, err := c.Admin.ListTrees(ctx, &trillian.ListTreesRequest{ShowDeleted: showDeleted})
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus("ListTrees", resp.Status); err != nil {
+		return err
+	}
+
+	for _, tree := range resp.Tree {
+		c.printProto(tree)
+		c.printf("\n")
+	}
+
+	return nil
+}
+
+// GetTree prints the settings of the tree.
+func (c *Client) GetTree(ctx context.Context) error {
+	resp, err := c.Admin.GetTree(ctx, &trillian.GetTreeRequest{TreeId: c.TreeID})
+
+	if err != nil {
+		return err
+	}
+
+	return c.printTree("GetTree", resp.Status, resp.Tree)
+}
+
+// CreateTree creates a tree with the settings in treeText, a Tree in protobuf text format,
+// and prints it with the id assigned by the server.
+func (c *Client) CreateTree(ctx context.Context, treeText string) error {
+	var tree trillian.Tree
+
+	if err := proto.UnmarshalText(treeText, &tree); err != nil {
+		return fmt.Errorf("failed to parse tree: %v", err)
+	}
+
+	resp, err := c.Admin.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: &tree})
+
+	if err != nil {
+		return err
+	}
+
+	return c.printTree("CreateTree", resp.Status, resp.Tree)
+}
+
+// FreezeTree freezes the tree and prints it.
+func (c *Client) FreezeTree(ctx context.Context) error {
+	resp, err := c.Admin.FreezeTree(ctx, &trillian.FreezeTreeRequest{TreeId: c.TreeID})
+
+	if err != nil {
+		return err
+	}
+
+	return c.printTree("FreezeTree", resp.Status, resp.Tree)
+}
+
+// DeleteTree soft deletes the tree and prints it.
+func (c *Client) DeleteTree(ctx context.Context) error {
+	resp, err := c.Admin.DeleteTree(ctx, &trillian.DeleteTreeRequest{TreeId: c.TreeID})
+
+	if err != nil {
+		return err
+	}
+
+	return c.printTree("DeleteTree", resp.Status, resp.Tree)
+}
+
+// UndeleteTree restores the soft deleted tree and prints it.
+func (c *Client) UndeleteTree(ctx context.Context) error {
+	resp, err := c.Admin.UndeleteTree(ctx, &trillian.UndeleteTreeRequest{TreeId: c.TreeID})
+
+	if err != nil {
+		return err
+	}
+
+	return c.printTree("UndeleteTree", resp.Status, resp.Tree)
+}
+
+func (c *Client) printTree(op string, status *trillian.TrillianApiStatus, tree *trillian.Tree) error {
+	if err := checkStatus(op, status); err != nil {
+		return err
+	}
+
+	if tree == nil {
+		return fmt.Errorf("%s returned no tree", op)
+	}
+
+	c.printProto(tree)
+	return nil
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const testTreeID = 42
+
+func okStatus() *trillian.TrillianApiStatus {
+	return &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+}
+
+func testHasher() merkle.TreeHasher {
+	return merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+func descriptorHashes(descriptors []merkle.TreeEntryDescriptor) []*trillian.NodeProto {
+	var nodes []*trillian.NodeProto
+
+	for _, d := range descriptors {
+		nodes = append(nodes, &trillian.NodeProto{NodeHash: d.Value.Hash()})
+	}
+
+	return nodes
+}
+
+// fakeLogClient serves a log held in an in memory merkle tree. Only the methods used by the
+// commands are implemented.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	tree   *merkle.InMemoryMerkleTree
+	leaves [][]byte
+	root   trillian.SignedLogRoot
+	// tamper is called on each proof before it's returned, if set
+	tamper func(*trillian.ProofProto)
+}
+
+func newFakeLogClient(t *testing.T, key *ecdsa.PrivateKey, leaves ...string) *fakeLogClient {
+	f := &fakeLogClient{tree: merkle.NewInMemoryMerkleTree(testHasher())}
+
+	for _, leaf := range leaves {
+		f.tree.AddLeaf([]byte(leaf))
+		f.leaves = append(f.leaves, []byte(leaf))
+	}
+
+	f.root = trillian.SignedLogRoot{TreeSize: int64(len(leaves)), RootHash: f.tree.CurrentRoot().Hash(), TimestampNanos: 1000}
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, key).SignLogRoot(f.root)
+
+	if err != nil {
+		t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	f.root.Signature = &sig
+
+	return f
+}
+
+func (f *fakeLogClient) proof(p *trillian.ProofProto) *trillian.ProofProto {
+	if f.tamper != nil {
+		f.tamper(p)
+	}
+
+	return p
+}
+
+func (f *fakeLogClient) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root := f.root
+	return &trillian.GetLatestSignedLogRootResponse{Status: okStatus(), SignedLogRoot: &root}, nil
+}
+
+func (f *fakeLogClient) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	resp := &trillian.GetLeavesByIndexResponse{Status: okStatus()}
+
+	for _, index := range req.LeafIndex {
+		resp.Leaves = append(resp.Leaves, &trillian.LeafProto{LeafIndex: index, LeafData: f.leaves[index]})
+	}
+
+	return resp, nil
+}
+
+func (f *fakeLogClient) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	path := f.tree.PathToRootAtSnapshot(int(req.LeafIndex+1), int(req.TreeSize))
+	proof := &trillian.ProofProto{LeafIndex: req.LeafIndex, ProofNode: descriptorHashes(path)}
+
+	return &trillian.GetInclusionProofResponse{Status: okStatus(), Proof: f.proof(proof)}, nil
+}
+
+func (f *fakeLogClient) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp := &trillian.GetInclusionProofByHashResponse{Status: okStatus()}
+
+	for i, leaf := range f.leaves {
+		if bytes.Equal(testHasher().HashLeaf(leaf), req.LeafHash) {
+			path := f.tree.PathToRootAtSnapshot(i+1, int(req.TreeSize))
+			resp.Proof = append(resp.Proof, f.proof(&trillian.ProofProto{LeafIndex: int64(i), ProofNode: descriptorHashes(path)}))
+		}
+	}
+
+	return resp, nil
+}
+
+func (f *fakeLogClient) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	path := f.tree.SnapshotConsistency(int(req.FirstTreeSize), int(req.SecondTreeSize))
+	proof := &trillian.ProofProto{ProofNode: descriptorHashes(path)}
+
+	return &trillian.GetConsistencyProofResponse{Status: okStatus(), Proof: f.proof(proof)}, nil
+}
+
+func (f *fakeLogClient) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	resp := &trillian.QueueLeavesResponse{Status: okStatus()}
+
+	for _, leaf := range req.Leaves {
+		resp.Leaves = append(resp.Leaves, &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED})
+		f.leaves = append(f.leaves, leaf.LeafData)
+	}
+
+	return resp, nil
+}
+
+func newTestClient(key *ecdsa.PrivateKey) (*Client, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Client{TreeID: testTreeID, Hasher: testHasher(), PubKey: key.Public(), Out: out}, out
+}
+
+func tamperFirstNode(p *trillian.ProofProto) {
+	p.ProofNode[0] = &trillian.NodeProto{NodeHash: []byte("not a hash of the tree")}
+}
+
+func TestGetProof(t *testing.T) {
+	key := newKey(t)
+
+	for _, test := range []struct {
+		desc    string
+		leaf    string
+		tamper  func(*trillian.ProofProto)
+		wantOut string
+		wantErr string
+	}{
+		{desc: "good", leaf: "two", wantOut: "Verified inclusion of leaf 2"},
+		{desc: "duplicates", leaf: "one", wantOut: "Verified inclusion of leaf 4"},
+		{desc: "absent", leaf: "six", wantErr: "has no leaf"},
+		{desc: "tampered", leaf: "two", tamper: tamperFirstNode, wantErr: "bad inclusion proof"},
+	} {
+		f := newFakeLogClient(t, key, "zero", "one", "two", "three", "one")
+		f.tamper = test.tamper
+		c, out := newTestClient(key)
+		c.Log = f
+
+		err := c.GetProof(context.Background(), []byte(test.leaf))
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: GetProof() got error %v, expected one containing %q", test.desc, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: GetProof() failed: %v", test.desc, err)
+		} else if !strings.Contains(out.String(), test.wantOut) {
+			t.Errorf("%s: GetProof() printed %q, expected %q", test.desc, out.String(), test.wantOut)
+		}
+	}
+}
+
+func TestGetProofAtIndex(t *testing.T) {
+	key := newKey(t)
+	f := newFakeLogClient(t, key, "zero", "one", "two")
+	c, out := newTestClient(key)
+	c.Log = f
+
+	if err := c.GetProofAtIndex(context.Background(), 1); err != nil {
+		t.Fatalf("GetProofAtIndex() failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Verified inclusion of leaf 1") {
+		t.Errorf("GetProofAtIndex() printed %q", out.String())
+	}
+
+	if err := c.GetProofAtIndex(context.Background(), 3); err == nil {
+		t.Error("GetProofAtIndex() succeeded for a leaf outside the tree")
+	}
+
+	// The proof is only valid for the data that was logged
+	f.leaves[1] = []byte("other")
+
+	if err := c.GetProofAtIndex(context.Background(), 1); err == nil {
+		t.Error("GetProofAtIndex() succeeded for the wrong leaf data")
+	}
+}
+
+func TestGetConsistency(t *testing.T) {
+	key := newKey(t)
+	f := newFakeLogClient(t, key, "zero", "one", "two", "three", "four")
+	firstRoot := f.tree.RootAtSnapshot(3).Hash()
+
+	for _, test := range []struct {
+		desc      string
+		firstSize int64
+		firstRoot []byte
+		tamper    func(*trillian.ProofProto)
+		wantOut   string
+		wantErr   string
+	}{
+		{desc: "good", firstSize: 3, firstRoot: firstRoot, wantOut: "Verified consistency of tree size 3 with 5"},
+		{desc: "unchecked", firstSize: 3, wantOut: "not checked"},
+		{desc: "wrong root", firstSize: 3, firstRoot: f.root.RootHash, wantErr: "bad consistency proof"},
+		{desc: "tampered", firstSize: 3, firstRoot: firstRoot, tamper: tamperFirstNode, wantErr: "bad consistency proof"},
+		{desc: "too big", firstSize: 6, firstRoot: firstRoot, wantErr: "outside log"},
+	} {
+		f.tamper = test.tamper
+		c, out := newTestClient(key)
+		c.Log = f
+
+		err := c.GetConsistency(context.Background(), test.firstSize, test.firstRoot)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: GetConsistency() got error %v, expected one containing %q", test.desc, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: GetConsistency() failed: %v", test.desc, err)
+		} else if !strings.Contains(out.String(), test.wantOut) {
+			t.Errorf("%s: GetConsistency() printed %q, expected %q", test.desc, out.String(), test.wantOut)
+		}
+	}
+}
+
+func TestGetSTHChecksSignature(t *testing.T) {
+	f := newFakeLogClient(t, newKey(t), "zero")
+	c, _ := newTestClient(newKey(t))
+	c.Log = f
+
+	if err := c.GetSTH(context.Background()); err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Errorf("GetSTH() got error %v for a root signed with another key", err)
+	}
+
+	// Without a key the root is printed as it is
+	c.PubKey = nil
+
+	if err := c.GetSTH(context.Background()); err != nil {
+		t.Errorf("GetSTH() failed without a key: %v", err)
+	}
+}
+
+func TestQueueLeaf(t *testing.T) {
+	key := newKey(t)
+	f := newFakeLogClient(t, key)
+	c, out := newTestClient(key)
+	c.Log = f
+
+	if err := c.QueueLeaf(context.Background(), []byte("data"), nil); err != nil {
+		t.Fatalf("QueueLeaf() failed: %v", err)
+	}
+
+	if len(f.leaves) != 1 || string(f.leaves[0]) != "data" {
+		t.Errorf("QueueLeaf() queued %q", f.leaves)
+	}
+
+	if !strings.Contains(out.String(), "Queued leaf") {
+		t.Errorf("QueueLeaf() printed %q", out.String())
+	}
+}
+
+// fakeMapClient serves a map holding at most one key, whose proof only has empty subtrees
+// as siblings. Only the methods used by the commands are implemented.
+type fakeMapClient struct {
+	trillian.TrillianMapClient
+	t      *testing.T
+	key    *ecdsa.PrivateKey
+	hasher merkle.MapHasher
+	mapKey []byte
+	value  []byte
+	root   trillian.SignedMapRoot
+	// tamper is called on each response before it's returned, if set
+	tamper func(*trillian.GetMapLeavesResponse)
+}
+
+func newFakeMapClient(t *testing.T, key *ecdsa.PrivateKey) *fakeMapClient {
+	return &fakeMapClient{t: t, key: key, hasher: merkle.NewMapHasher(testHasher())}
+}
+
+func (f *fakeMapClient) emptySubtrees() [][]byte {
+	hashes := [][]byte{f.hasher.HashLeaf([]byte{})}
+
+	for i := 1; i < f.hasher.Size()*8; i++ {
+		hashes = append(hashes, f.hasher.HashChildren(hashes[i-1], hashes[i-1]))
+	}
+
+	return hashes
+}
+
+// set replaces the map with one holding value for key at the next revision
+func (f *fakeMapClient) set(key, value []byte) {
+	f.mapKey, f.value = key, value
+	path := f.hasher.HashKey(key)
+	root := []byte(f.hasher.HashLeaf(value))
+
+	for i, empty := range f.emptySubtrees() {
+		if path[len(path)-1-i/8]&(1<<uint(i%8)) == 0 {
+			root = f.hasher.HashChildren(root, empty)
+		} else {
+			root = f.hasher.HashChildren(empty, root)
+		}
+	}
+
+	f.root = trillian.SignedMapRoot{MapRevision: f.root.MapRevision + 1, RootHash: root, TimestampNanos: 1000}
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, f.key).SignMapRoot(f.root)
+
+	if err != nil {
+		f.t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	f.root.Signature = &sig
+}
+
+func (f *fakeMapClient) GetLeaves(ctx context.Context, req *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	root := f.root
+	resp := &trillian.GetMapLeavesResponse{Status: okStatus(), MapRoot: &root}
+
+	for _, key := range req.Key {
+		if !bytes.Equal(key, f.mapKey) {
+			f.t.Fatalf("GetLeaves() called for key %q, only %q is supported", key, f.mapKey)
+		}
+
+		kv := &trillian.KeyValue{Key: key, Value: &trillian.MapLeaf{LeafValue: f.value}}
+		resp.KeyValue = append(resp.KeyValue, &trillian.KeyValueInclusion{KeyValue: kv, Inclusion: f.emptySubtrees()})
+	}
+
+	if f.tamper != nil {
+		f.tamper(resp)
+	}
+
+	return resp, nil
+}
+
+func (f *fakeMapClient) SetLeaves(ctx context.Context, req *trillian.SetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.SetMapLeavesResponse, error) {
+	if len(req.KeyValue) != 1 {
+		f.t.Fatalf("SetLeaves() called with %d values, only 1 is supported", len(req.KeyValue))
+	}
+
+	f.set(req.KeyValue[0].Key, req.KeyValue[0].Value.LeafValue)
+	root := f.root
+
+	return &trillian.SetMapLeavesResponse{Status: okStatus(), MapRoot: &root}, nil
+}
+
+func TestMapGet(t *testing.T) {
+	key := newKey(t)
+
+	for _, test := range []struct {
+		desc     string
+		revision int64
+		tamper   func(*trillian.GetMapLeavesResponse)
+		wantErr  string
+	}{
+		{desc: "latest", revision: -1},
+		{desc: "revision", revision: 1},
+		{desc: "wrong revision", revision: 2, wantErr: "expected 2"},
+		{
+			desc:    "tampered value",
+			tamper:  func(resp *trillian.GetMapLeavesResponse) { resp.KeyValue[0].KeyValue.Value.LeafValue = []byte("other") },
+			wantErr: "bad proof",
+		},
+		{
+			desc:    "tampered root",
+			tamper:  func(resp *trillian.GetMapLeavesResponse) { resp.MapRoot.RootHash = []byte("other") },
+			wantErr: "bad signature",
+		},
+	} {
+		f := newFakeMapClient(t, key)
+		f.set([]byte("key"), []byte("value"))
+		f.tamper = test.tamper
+		c, out := newTestClient(key)
+		c.Map = f
+
+		err := c.MapGet(context.Background(), [][]byte{[]byte("key")}, test.revision)
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: MapGet() got error %v, expected one containing %q", test.desc, err, test.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: MapGet() failed: %v", test.desc, err)
+		} else if !strings.Contains(out.String(), `"key" = "value"`) {
+			t.Errorf("%s: MapGet() printed %q", test.desc, out.String())
+		}
+	}
+}
+
+func TestMapSet(t *testing.T) {
+	key := newKey(t)
+	f := newFakeMapClient(t, key)
+	c, out := newTestClient(key)
+	c.Map = f
+
+	if err := c.MapSet(context.Background(), [][]byte{[]byte("key")}, [][]byte{[]byte("value")}); err != nil {
+		t.Fatalf("MapSet() failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Set and verified 1 values at map revision 1") {
+		t.Errorf("MapSet() printed %q", out.String())
+	}
+
+	// A map that doesn't serve the value that was set is caught when it's read back
+	f.tamper = func(resp *trillian.GetMapLeavesResponse) { resp.KeyValue[0].KeyValue.Value.LeafValue = []byte("other") }
+
+	if err := c.MapSet(context.Background(), [][]byte{[]byte("key")}, [][]byte{[]byte("value")}); err == nil {
+		t.Error("MapSet() succeeded when the value read back didn't verify")
+	}
+}
+
+// fakeAdminClient holds trees in memory. Only the methods used by the commands are
+// implemented.
+type fakeAdminClient struct {
+	trillian.TrillianAdminClient
+	trees map[int64]*trillian.Tree
+}
+
+func (f *fakeAdminClient) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest, opts ...grpc.CallOption) (*trillian.CreateTreeResponse, error) {
+	tree := *req.Tree
+	tree.TreeId = int64(len(f.trees) + 1)
+	f.trees[tree.TreeId] = &tree
+
+	return &trillian.CreateTreeResponse{Status: okStatus(), Tree: &tree}, nil
+}
+
+func (f *fakeAdminClient) FreezeTree(ctx context.Context, req *trillian.FreezeTreeRequest, opts ...grpc.CallOption) (*trillian.FreezeTreeResponse, error) {
+	tree, ok := f.trees[req.TreeId]
+
+	if !ok {
+		return &trillian.FreezeTreeResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR, Description: "no such tree"}}, nil
+	}
+
+	tree.TreeState = trillian.TreeState_FROZEN
+
+	return &trillian.FreezeTreeResponse{Status: okStatus(), Tree: tree}, nil
+}
+
+func TestAdminCommands(t *testing.T) {
+	f := &fakeAdminClient{trees: make(map[int64]*trillian.Tree)}
+	c, out := newTestClient(newKey(t))
+	c.Admin = f
+
+	if err := c.CreateTree(context.Background(), `tree_type: LOG display_name: "test"`); err != nil {
+		t.Fatalf("CreateTree() failed: %v", err)
+	}
+
+	if tree := f.trees[1]; tree == nil || tree.TreeType != trillian.TreeType_LOG || tree.DisplayName != "test" {
+		t.Errorf("CreateTree() created %v", tree)
+	}
+
+	if !strings.Contains(out.String(), "tree_id: 1") {
+		t.Errorf("CreateTree() printed %q", out.String())
+	}
+
+	if err := c.CreateTree(context.Background(), "not a tree"); err == nil {
+		t.Error("CreateTree() succeeded for a bad tree")
+	}
+
+	c.TreeID = 1
+
+	if err := c.FreezeTree(context.Background()); err != nil {
+		t.Errorf("FreezeTree() failed: %v", err)
+	}
+
+	if f.trees[1].TreeState != trillian.TreeState_FROZEN {
+		t.Errorf("FreezeTree() left tree in state %v", f.trees[1].TreeState)
+	}
+
+	c.TreeID = 2
+
+	if err := c.FreezeTree(context.Background()); err == nil || !strings.Contains(err.Error(), "no such tree") {
+		t.Errorf("FreezeTree() got error %v for a missing tree", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	c, _ := newTestClient(newKey(t))
+
+	for _, test := range []struct {
+		command string
+		treeID  int64
+		args    []string
+		wantErr string
+	}{
+		{command: "get-stuff", treeID: testTreeID, wantErr: "unknown command"},
+		{command: "get-sth", wantErr: "--tree_id"},
+		{command: "queue-leaf", treeID: testTreeID, wantErr: "expected the leaf data"},
+		{command: "get-consistency", treeID: testTreeID, args: []string{"three"}, wantErr: "invalid tree size"},
+		{command: "map-set", treeID: testTreeID, args: []string{"key"}, wantErr: "pairs of keys and values"},
+		{command: "create-tree", wantErr: "protobuf text format"},
+	} {
+		c.TreeID = test.treeID
+
+		if err := run(context.Background(), c, test.command, test.args); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("run(%s, %v) got error %v, expected one containing %q", test.command, test.args, err, test.wantErr)
+		}
+	}
+}
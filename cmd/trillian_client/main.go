@@ -0,0 +1,259 @@
+// The trillian_client binary makes requests to Trillian log, map and admin servers, for
+// operators and for debugging. Proofs returned by the servers are checked locally against
+// the roots they come with, and with --public_key_file so are the signatures of the roots.
+//
+// Usage: trillian_client [flags] <command> [args]
+//
+//	queue-leaf DATA           Queue DATA to be added to the log
+//	get-sth                   Print the latest signed root of the log
+//	get-proof [DATA]          Check the inclusion of DATA, or the leaf at --leaf_index, in the log
+//	get-consistency SIZE      Check the latest root of the log is consistent with the tree at SIZE,
+//	                          whose root hash is given by --first_root_hash
+//	map-get KEY...            Print and check the values of keys in the map at --revision
+//	map-set KEY VALUE...      Set the values of keys in the map
+//	list-trees                List the trees, deleted ones with --show_deleted
+//	get-tree                  Print the settings of the tree
+//	create-tree TREE          Create the tree given by TREE, a Tree in protobuf text format
+//	freeze-tree               Freeze the tree
+//	delete-tree               Soft delete the tree
+//	undelete-tree             Undelete the tree
+//
+// Log and map commands use the tree given by --tree_id, as do the admin commands other than
+// list-trees and create-tree.
+package main
+
+import (
+	gocrypto "crypto"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var logServerFlag = flag.String("log_server", "localhost:8090", "Address of the Trillian log server")
+var mapServerFlag = flag.String("map_server", "localhost:8091", "Address of the Trillian map server")
+var adminServerFlag = flag.String("admin_server", "", "Address of the Trillian admin server, the log server if empty")
+var treeIDFlag = flag.Int64("tree_id", 0, "Tree id of the log or map")
+var hashAlgorithmFlag = flag.String("hash_algorithm", "SHA256", "Hash algorithm the tree was created with")
+var publicKeyFileFlag = flag.String("public_key_file", "", "PEM file holding the tree's public key, root signatures aren't checked if empty")
+var rpcTimeoutFlag = flag.Duration("rpc_timeout", time.Second*30, "Deadline for the requests made by a command")
+var extraDataFlag = flag.String("extra_data", "", "Extra data to queue with the leaf for queue-leaf")
+var leafIndexFlag = flag.Int64("leaf_index", -1, "Index of the leaf for get-proof when no data is given")
+var firstRootHashFlag = flag.String("first_root_hash", "", "Hex root hash of the tree at the first size for get-consistency")
+var revisionFlag = flag.Int64("revision", -1, "Map revision for map-get, the latest if < 0")
+var showDeletedFlag = flag.Bool("show_deleted", false, "Include deleted trees in list-trees")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command> [args]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Commands: queue-leaf, get-sth, get-proof, get-consistency, map-get, map-set,")
+	fmt.Fprintln(os.Stderr, "list-trees, get-tree, create-tree, freeze-tree, delete-tree and undelete-tree.")
+	fmt.Fprintln(os.Stderr, "See the package documentation for their arguments.")
+	fmt.Fprintln(os.Stderr)
+	flag.PrintDefaults()
+}
+
+func loadPublicKey(file string) (gocrypto.PublicKey, error) {
+	pemData, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	km := crypto.NewPEMKeyManager()
+
+	if err := km.LoadPublicKey(string(pemData)); err != nil {
+		return nil, err
+	}
+
+	return km.GetPublicKey()
+}
+
+func dial(addr string) *grpc.ClientConn {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+
+	if err != nil {
+		glog.Fatalf("Failed to connect to %s: %v", addr, err)
+	}
+
+	return conn
+}
+
+// byteArgs returns args as byte slices
+func byteArgs(args []string) [][]byte {
+	b := make([][]byte, 0, len(args))
+
+	for _, arg := range args {
+		b = append(b, []byte(arg))
+	}
+
+	return b
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	alg, ok := trillian.HashAlgorithm_value[*hashAlgorithmFlag]
+
+	if !ok {
+		glog.Fatalf("Unknown hash algorithm: %s", *hashAlgorithmFlag)
+	}
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm(alg))
+
+	if err != nil {
+		glog.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	client := &Client{TreeID: *treeIDFlag, Hasher: hasher, Out: os.Stdout}
+
+	if len(*publicKeyFileFlag) > 0 {
+		if client.PubKey, err = loadPublicKey(*publicKeyFileFlag); err != nil {
+			glog.Fatalf("Failed to load public key from %s: %v", *publicKeyFileFlag, err)
+		}
+	} else {
+		glog.Warning("Root signatures aren't checked without --public_key_file")
+	}
+
+	// grpc.Dial doesn't block, so only the server a command uses needs to be running
+	logConn := dial(*logServerFlag)
+	defer logConn.Close()
+	client.Log = trillian.NewTrillianLogClient(logConn)
+
+	mapConn := dial(*mapServerFlag)
+	defer mapConn.Close()
+	client.Map = trillian.NewTrillianMapClient(mapConn)
+
+	if len(*adminServerFlag) > 0 {
+		adminConn := dial(*adminServerFlag)
+		defer adminConn.Close()
+		client.Admin = trillian.NewTrillianAdminClient(adminConn)
+	} else {
+		client.Admin = trillian.NewTrillianAdminClient(logConn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *rpcTimeoutFlag)
+	defer cancel()
+
+	if err := run(ctx, client, flag.Arg(0), flag.Args()[1:]); err != nil {
+		glog.Fatalf("%s failed: %v", flag.Arg(0), err)
+	}
+}
+
+// commands maps the name of each command to whether it needs --tree_id
+var commands = map[string]bool{
+	"queue-leaf":      true,
+	"get-sth":         true,
+	"get-proof":       true,
+	"get-consistency": true,
+	"map-get":         true,
+	"map-set":         true,
+	"list-trees":      false,
+	"get-tree":        true,
+	"create-tree":     false,
+	"freeze-tree":     true,
+	"delete-tree":     true,
+	"undelete-tree":   true,
+}
+
+func run(ctx context.Context, c *Client, command string, args []string) error {
+	needsTree, ok := commands[command]
+
+	if !ok {
+		return fmt.Errorf("unknown command %s", command)
+	}
+
+	if needsTree && c.TreeID == 0 {
+		return fmt.Errorf("the tree must be given with --tree_id")
+	}
+
+	switch command {
+	case "queue-leaf":
+		if len(args) != 1 {
+			return fmt.Errorf("expected the leaf data, got %d args", len(args))
+		}
+
+		return c.QueueLeaf(ctx, []byte(args[0]), []byte(*extraDataFlag))
+	case "get-sth":
+		return c.GetSTH(ctx)
+	case "get-proof":
+		switch {
+		case len(args) == 1:
+			return c.GetProof(ctx, []byte(args[0]))
+		case len(args) == 0 && *leafIndexFlag >= 0:
+			return c.GetProofAtIndex(ctx, *leafIndexFlag)
+		default:
+			return fmt.Errorf("expected the leaf data or --leaf_index")
+		}
+	case "get-consistency":
+		if len(args) != 1 {
+			return fmt.Errorf("expected the first tree size, got %d args", len(args))
+		}
+
+		size, err := strconv.ParseInt(args[0], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid tree size %s: %v", args[0], err)
+		}
+
+		rootHash, err := hex.DecodeString(*firstRootHashFlag)
+
+		if err != nil {
+			return fmt.Errorf("invalid --first_root_hash: %v", err)
+		}
+
+		return c.GetConsistency(ctx, size, rootHash)
+	case "map-get":
+		if len(args) == 0 {
+			return fmt.Errorf("expected at least one key")
+		}
+
+		return c.MapGet(ctx, byteArgs(args), *revisionFlag)
+	case "map-set":
+		if len(args) == 0 || len(args)%2 != 0 {
+			return fmt.Errorf("expected pairs of keys and values, got %d args", len(args))
+		}
+
+		var keys, values [][]byte
+
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, []byte(args[i]))
+			values = append(values, []byte(args[i+1]))
+		}
+
+		return c.MapSet(ctx, keys, values)
+	case "list-trees":
+		return c.ListTrees(ctx, *showDeletedFlag)
+	case "get-tree":
+		return c.GetTree(ctx)
+	case "create-tree":
+		if len(args) != 1 {
+			return fmt.Errorf("expected the tree in protobuf text format, got %d args", len(args))
+		}
+
+		return c.CreateTree(ctx, args[0])
+	case "freeze-tree":
+		return c.FreezeTree(ctx)
+	case "delete-tree":
+		return c.DeleteTree(ctx)
+	case "undelete-tree":
+		return c.UndeleteTree(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package cloudkms provides a KeyManager for signing keys held in Google Cloud KMS, so that
+// the private keys of logs hosted on GCP never leave the service. Credentials are found by
+// the client library, see https://cloud.google.com/docs/authentication.
+package cloudkms
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tcrypto "github.com/google/trillian/crypto"
+	gax "github.com/googleapis/gax-go"
+	"golang.org/x/net/context"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// KeyScheme is the scheme for key IDs of the form "cloudkms:<key version>", where the key
+// version is the resource name of an asymmetric signing key version, i.e.
+// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<n>.
+const KeyScheme = "cloudkms"
+
+// Client is the part of the Cloud KMS client, kms.KeyManagementClient, that's used to sign.
+type Client interface {
+	GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest, opts ...gax.CallOption) (*kmspb.PublicKey, error)
+	AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest, opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+}
+
+// KeyManager is a KeyManager for a Cloud KMS key version. Its public key is fetched when it's
+// created, each signature is then made by a call to the service.
+type KeyManager struct {
+	client  Client
+	name    string
+	timeout time.Duration
+
+	publicKey    crypto.PublicKey
+	rawPublicKey []byte
+}
+
+// NewKeyManager creates a KeyManager for the key version called name. Calls to sign with it
+// are abandoned after timeout.
+func NewKeyManager(ctx context.Context, client Client, name string, timeout time.Duration) (*KeyManager, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 10 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" || parts[8] != "cryptoKeyVersions" {
+		return nil, fmt.Errorf("cloudkms: %q isn't of the form projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/V", name)
+	}
+
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("cloudkms: failed to get public key of %s: %v", name, err)
+	}
+
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, fmt.Errorf("cloudkms: could not decode PEM for public key of %s", name)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cloudkms: unable to parse public key of %s: %v", name, err)
+	}
+
+	return &KeyManager{client: client, name: name, timeout: timeout, publicKey: publicKey, rawPublicKey: block.Bytes}, nil
+}
+
+// NewKeyManagerFunc returns a NewKeyManagerFunc, suitable for registering with KeyScheme,
+// which creates KeyManagers using client.
+func NewKeyManagerFunc(client Client, timeout time.Duration) tcrypto.NewKeyManagerFunc {
+	return func(name string) (tcrypto.KeyManager, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		km, err := NewKeyManager(ctx, client, name, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return km, nil
+	}
+}
+
+// Signer returns a signer that signs with the key version in Cloud KMS.
+func (k *KeyManager) Signer() (crypto.Signer, error) {
+	return signer{k}, nil
+}
+
+// GetPublicKey returns the public key of the key version.
+func (k *KeyManager) GetPublicKey() (crypto.PublicKey, error) {
+	return k.publicKey, nil
+}
+
+// GetRawPublicKey returns the DER encoded public key of the key version.
+func (k *KeyManager) GetRawPublicKey() ([]byte, error) {
+	return k.rawPublicKey, nil
+}
+
+// signer makes signatures with AsymmetricSign. These are ASN.1 encoded for ECDSA keys and
+// PKCS #1 for RSA keys, the same as those of the private keys in the crypto package.
+type signer struct {
+	km *KeyManager
+}
+
+// Public returns the public key.
+func (s signer) Public() crypto.PublicKey {
+	return s.km.publicKey
+}
+
+// Sign signs digest, which must have been made with the hash function of opts. That must
+// be the one the algorithm of the key version uses. The random source is ignored.
+func (s signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var d kmspb.Digest
+
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		d.Digest = &kmspb.Digest_Sha256{Sha256: digest}
+	case crypto.SHA384:
+		d.Digest = &kmspb.Digest_Sha384{Sha384: digest}
+	case crypto.SHA512:
+		d.Digest = &kmspb.Digest_Sha512{Sha512: digest}
+	default:
+		return nil, fmt.Errorf("cloudkms: unsupported hash function %v", opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.km.timeout)
+	defer cancel()
+
+	resp, err := s.km.client.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{Name: s.km.name, Digest: &d})
+	if err != nil {
+		return nil, fmt.Errorf("cloudkms: failed to sign with %s: %v", s.km.name, err)
+	}
+
+	return resp.Signature, nil
+}
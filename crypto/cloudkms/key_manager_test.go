@@ -0,0 +1,148 @@
+package cloudkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	gax "github.com/googleapis/gax-go"
+	"golang.org/x/net/context"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+const testKeyName = "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+
+// fakeClient holds a single ECDSA key version in memory
+type fakeClient struct {
+	name string
+	key  *ecdsa.PrivateKey
+	// digests is the number of digests signed of each size
+	digests map[int]int
+}
+
+func newFakeClient(t *testing.T) *fakeClient {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return &fakeClient{name: testKeyName, key: key, digests: make(map[int]int)}
+}
+
+func (f *fakeClient) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest, opts ...gax.CallOption) (*kmspb.PublicKey, error) {
+	if req.Name != f.name {
+		return nil, errors.New("NOTFOUND")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kmspb.PublicKey{Pem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), Algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256}, nil
+}
+
+func (f *fakeClient) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest, opts ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	if req.Name != f.name {
+		return nil, errors.New("NOTFOUND")
+	}
+
+	digest := req.Digest.GetSha256()
+	if digest == nil {
+		return nil, errors.New("key version only signs SHA-256 digests")
+	}
+	f.digests[len(digest)]++
+
+	sig, err := f.key.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kmspb.AsymmetricSignResponse{Signature: sig}, nil
+}
+
+func TestKeyManagerSignsWithKeyVersion(t *testing.T) {
+	client := newFakeClient(t)
+
+	km, err := NewKeyManagerFunc(client, time.Second)(testKeyName)
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	pub, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatalf("GetPublicKey() = %v", err)
+	}
+
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+
+	sigAlgorithm, err := tcrypto.SignatureAlgorithmForKey(pub)
+	if err != nil {
+		t.Fatalf("SignatureAlgorithmForKey() = %v", err)
+	}
+
+	data := []byte("root")
+	sig, err := tcrypto.NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer).Sign(data)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	// The digest is sent to the service rather than the data
+	if got := client.digests[32]; got != 1 {
+		t.Errorf("Signed %d SHA-256 digests, want 1", got)
+	}
+
+	if err := tcrypto.VerifySignature(pub, data, sig); err != nil {
+		t.Errorf("Signature made in Cloud KMS doesn't verify: %v", err)
+	}
+
+	raw, err := km.GetRawPublicKey()
+	if err != nil {
+		t.Fatalf("GetRawPublicKey() = %v", err)
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(raw); err != nil {
+		t.Errorf("Raw public key isn't DER encoded: %v", err)
+	}
+}
+
+func TestKeyManagerRejectsUnsupportedHash(t *testing.T) {
+	km, err := NewKeyManager(context.Background(), newFakeClient(t), testKeyName, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+
+	if _, err := signer.Sign(rand.Reader, make([]byte, 20), crypto.SHA1); err == nil {
+		t.Error("Signed a SHA-1 digest")
+	}
+}
+
+func TestNewKeyManagerErrors(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"projects/p/locations/global/keyRings/r/cryptoKeys/k",
+		"projects/p/locations/global/keyRings/r/cryptoKeys/k/versions/1",
+		// Well formed but the service doesn't have it
+		"projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/2",
+	} {
+		if _, err := NewKeyManager(context.Background(), newFakeClient(t), name, time.Second); err == nil {
+			t.Errorf("NewKeyManager(%q) created a key manager", name)
+		}
+	}
+}
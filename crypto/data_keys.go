@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DataKeySize is the size in bytes of data keys, which are AES-256 keys
+const DataKeySize = 32
+
+// DataKey is a symmetric key that a tree's data is encrypted with at rest. Data keys are
+// versioned so they can be rotated, encrypted data records the version of the key that
+// protects it.
+type DataKey struct {
+	Version uint32
+	Key     []byte
+}
+
+// DataKeyManager holds the data keys of a tree. Unlike the signing keys of a KeyManager they
+// never leave the server, they protect what the tree keeps in storage.
+type DataKeyManager interface {
+	// CurrentDataKey returns the key that new data should be encrypted with, the one with
+	// the highest version
+	CurrentDataKey() (DataKey, error)
+	// DataKey returns the key with version, which may since have been replaced by a newer
+	// one. Old keys are kept until nothing is encrypted with them.
+	DataKey(version uint32) (DataKey, error)
+}
+
+// StaticDataKeyManager is a DataKeyManager for a fixed set of keys
+type StaticDataKeyManager struct {
+	keys    map[uint32]DataKey
+	current uint32
+}
+
+// NewStaticDataKeyManager creates a StaticDataKeyManager for keys, which must have different
+// versions and be DataKeySize bytes long.
+func NewStaticDataKeyManager(keys []DataKey) (*StaticDataKeyManager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no data keys")
+	}
+
+	m := &StaticDataKeyManager{keys: make(map[uint32]DataKey, len(keys))}
+
+	for _, key := range keys {
+		if len(key.Key) != DataKeySize {
+			return nil, fmt.Errorf("data key version %d is %d bytes, want %d", key.Version, len(key.Key), DataKeySize)
+		}
+		if _, ok := m.keys[key.Version]; ok {
+			return nil, fmt.Errorf("more than one data key has version %d", key.Version)
+		}
+
+		m.keys[key.Version] = DataKey{Version: key.Version, Key: append([]byte{}, key.Key...)}
+		if key.Version > m.current {
+			m.current = key.Version
+		}
+	}
+
+	return m, nil
+}
+
+// CurrentDataKey returns the key with the highest version
+func (m *StaticDataKeyManager) CurrentDataKey() (DataKey, error) {
+	return m.keys[m.current], nil
+}
+
+// DataKey returns the key with version
+func (m *StaticDataKeyManager) DataKey(version uint32) (DataKey, error) {
+	key, ok := m.keys[version]
+	if !ok {
+		return DataKey{}, fmt.Errorf("no data key with version %d", version)
+	}
+
+	return key, nil
+}
+
+// Keys returns all the keys, in version order
+func (m *StaticDataKeyManager) Keys() []DataKey {
+	keys := make([]DataKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	sort.Sort(byDataKeyVersion(keys))
+
+	return keys
+}
+
+type byDataKeyVersion []DataKey
+
+func (k byDataKeyVersion) Len() int           { return len(k) }
+func (k byDataKeyVersion) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k byDataKeyVersion) Less(i, j int) bool { return k[i].Version < k[j].Version }
+
+// GenerateDataKey returns a new random key with the given version
+func GenerateDataKey(version uint32) (DataKey, error) {
+	key := DataKey{Version: version, Key: make([]byte, DataKeySize)}
+
+	if _, err := rand.Read(key.Key); err != nil {
+		return DataKey{}, err
+	}
+
+	return key, nil
+}
+
+// LoadDataKeyFile creates a StaticDataKeyManager from a file with a "<version>=<hex key>"
+// line for each key. Blank lines and lines starting with # are ignored. The file should
+// only be readable by the server.
+func LoadDataKeyFile(path string) (*StaticDataKeyManager, error) {
+	return loadDataKeyFile(path, nil)
+}
+
+// LoadWrappedDataKeyFile is like LoadDataKeyFile for a file holding keys wrapped by w, which
+// are unwrapped as they're loaded. The file needn't be kept secret, the keys can't be used
+// without the wrapping key.
+func LoadWrappedDataKeyFile(path string, w KeyWrapper) (*StaticDataKeyManager, error) {
+	return loadDataKeyFile(path, w)
+}
+
+// loadDataKeyFile loads the keys in path, unwrapping them with w if it isn't nil
+func loadDataKeyFile(path string, w KeyWrapper) (*StaticDataKeyManager, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []DataKey
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: data key is not of the form version=key", path, line)
+		}
+
+		version, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid data key version: %v", path, line, err)
+		}
+
+		key, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid data key: %v", path, line, err)
+		}
+
+		if w != nil {
+			if key, err = w.UnwrapKey(key); err != nil {
+				return nil, fmt.Errorf("%s:%d: failed to unwrap data key version %d: %v", path, line, version, err)
+			}
+		}
+
+		keys = append(keys, DataKey{Version: uint32(version), Key: key})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	m, err := NewStaticDataKeyManager(keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return m, nil
+}
+
+// WriteDataKeyFile writes keys to path in the format read by LoadDataKeyFile, replacing the
+// file if it exists
+func WriteDataKeyFile(path string, keys []DataKey) error {
+	return WriteWrappedDataKeyFile(path, keys, nil)
+}
+
+// WriteWrappedDataKeyFile writes keys to path wrapped by w, for LoadWrappedDataKeyFile. If w
+// is nil the keys are written as they are.
+func WriteWrappedDataKeyFile(path string, keys []DataKey, w KeyWrapper) error {
+	var b bytes.Buffer
+	for _, key := range keys {
+		value := key.Key
+
+		if w != nil {
+			var err error
+			if value, err = w.WrapKey(key.Key); err != nil {
+				return fmt.Errorf("failed to wrap data key version %d: %v", key.Version, err)
+			}
+		}
+
+		fmt.Fprintf(&b, "%d=%x\n", key.Version, value)
+	}
+
+	return ioutil.WriteFile(path, b.Bytes(), 0600)
+}
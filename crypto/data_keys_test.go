@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticDataKeyManager(t *testing.T) {
+	key1 := DataKey{Version: 1, Key: bytes.Repeat([]byte{1}, DataKeySize)}
+	key3 := DataKey{Version: 3, Key: bytes.Repeat([]byte{3}, DataKeySize)}
+
+	m, err := NewStaticDataKeyManager([]DataKey{key3, key1})
+	if err != nil {
+		t.Fatalf("NewStaticDataKeyManager() = %v", err)
+	}
+
+	if got, err := m.CurrentDataKey(); err != nil || got.Version != 3 {
+		t.Errorf("CurrentDataKey() = %v %v, want version 3", got, err)
+	}
+	if got, err := m.DataKey(1); err != nil || !bytes.Equal(got.Key, key1.Key) {
+		t.Errorf("DataKey(1) = %v %v, want %v", got, err, key1)
+	}
+	if _, err := m.DataKey(2); err == nil {
+		t.Error("DataKey() of a missing version succeeded")
+	}
+	if got := m.Keys(); len(got) != 2 || got[0].Version != 1 || got[1].Version != 3 {
+		t.Errorf("Keys() = %v, want versions 1 and 3", got)
+	}
+
+	for _, keys := range [][]DataKey{
+		nil,
+		{{Version: 1, Key: []byte("short")}},
+		{key1, {Version: 1, Key: key3.Key}},
+	} {
+		if _, err := NewStaticDataKeyManager(keys); err == nil {
+			t.Errorf("NewStaticDataKeyManager(%v) succeeded", keys)
+		}
+	}
+}
+
+func TestDataKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datakeys")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var keys []DataKey
+	for _, version := range []uint32{1, 2} {
+		key, err := GenerateDataKey(version)
+		if err != nil {
+			t.Fatalf("GenerateDataKey() = %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	path := filepath.Join(dir, "keys")
+	if err := WriteDataKeyFile(path, keys); err != nil {
+		t.Fatalf("WriteDataKeyFile() = %v", err)
+	}
+
+	m, err := LoadDataKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadDataKeyFile() = %v", err)
+	}
+	for _, want := range keys {
+		if got, err := m.DataKey(want.Version); err != nil || !bytes.Equal(got.Key, want.Key) {
+			t.Errorf("DataKey(%d) = %v %v, want the key written", want.Version, got, err)
+		}
+	}
+
+	for _, contents := range []string{
+		"",
+		"1",
+		"x=" + strings.Repeat("00", DataKeySize),
+		"1=nothex",
+		"1=00",
+	} {
+		if err := ioutil.WriteFile(path, []byte("# comment\n\n"+contents+"\n"), 0600); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+		if _, err := LoadDataKeyFile(path); err == nil {
+			t.Errorf("LoadDataKeyFile() of %q succeeded", contents)
+		}
+	}
+}
+
+func TestWrappedDataKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datakeys")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewAESKeyWrapper(bytes.Repeat([]byte{7}, DataKeySize))
+	if err != nil {
+		t.Fatalf("NewAESKeyWrapper() = %v", err)
+	}
+
+	key, err := GenerateDataKey(1)
+	if err != nil {
+		t.Fatalf("GenerateDataKey() = %v", err)
+	}
+
+	path := filepath.Join(dir, "keys")
+	if err := WriteWrappedDataKeyFile(path, []DataKey{key}, w); err != nil {
+		t.Fatalf("WriteWrappedDataKeyFile() = %v", err)
+	}
+
+	m, err := LoadWrappedDataKeyFile(path, w)
+	if err != nil {
+		t.Fatalf("LoadWrappedDataKeyFile() = %v", err)
+	}
+	if got, err := m.CurrentDataKey(); err != nil || !bytes.Equal(got.Key, key.Key) {
+		t.Errorf("CurrentDataKey() = %v %v, want the key written", got, err)
+	}
+
+	// Without the wrapping key the file is of no use
+	if _, err := LoadDataKeyFile(path); err == nil {
+		t.Error("LoadDataKeyFile() of wrapped keys succeeded")
+	}
+	other, err := NewAESKeyWrapper(bytes.Repeat([]byte{8}, DataKeySize))
+	if err != nil {
+		t.Fatalf("NewAESKeyWrapper() = %v", err)
+	}
+	if _, err := LoadWrappedDataKeyFile(path, other); err == nil {
+		t.Error("LoadWrappedDataKeyFile() with another wrapping key succeeded")
+	}
+}
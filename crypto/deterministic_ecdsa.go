@@ -0,0 +1,190 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// DeterministicECDSAScheme is the scheme for key IDs of the form "rfc6979:<key ID>", which
+// sign with the key named by the inner key ID but make ECDSA signatures deterministically.
+const DeterministicECDSAScheme = "rfc6979"
+
+// DeterministicKeyManager wraps a KeyManager so that its ECDSA signatures use nonces
+// derived from the private key and the digest being signed, as in RFC 6979, rather than
+// from a random source. A weak or broken random source in production can then no longer
+// leak the private key through the signatures. Signatures verify in the same way as
+// randomized ones. Non-ECDSA keys are used unchanged, as RSA PKCS #1 v1.5 and Ed25519
+// signatures are already deterministic.
+type DeterministicKeyManager struct {
+	KeyManager
+}
+
+// NewDeterministicKeyManager returns a DeterministicKeyManager that signs with the key held
+// by km.
+func NewDeterministicKeyManager(km KeyManager) *DeterministicKeyManager {
+	return &DeterministicKeyManager{km}
+}
+
+// Signer returns a signer for the wrapped key, which signs deterministically if it's an
+// ECDSA key.
+func (d *DeterministicKeyManager) Signer() (crypto.Signer, error) {
+	signer, err := d.KeyManager.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := signer.(*ecdsa.PrivateKey); ok {
+		return deterministicECDSASigner{key}, nil
+	}
+
+	return signer, nil
+}
+
+// NewDeterministicKeyManagerFunc returns a NewKeyManagerFunc, suitable for registering with
+// DeterministicECDSAScheme, which wraps the key manager created by NewKeyManager for the
+// key ID it's given.
+func NewDeterministicKeyManagerFunc() NewKeyManagerFunc {
+	return func(keyID string) (KeyManager, error) {
+		km, err := NewKeyManager(keyID)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewDeterministicKeyManager(km), nil
+	}
+}
+
+// deterministicECDSASigner makes ASN.1 encoded ECDSA signatures, like ecdsa.PrivateKey, with
+// the nonce generated as in RFC 6979 section 3.2.
+type deterministicECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// Public returns the public key.
+func (s deterministicECDSASigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+// Sign signs digest, which must have been made with the hash function of opts. That
+// function is also used to generate the nonce. The random source is ignored.
+func (s deterministicECDSASigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function %v is not available for deterministic ECDSA", hash)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("digest is %d bytes, want %d for hash function %v", len(digest), hash.Size(), hash)
+	}
+
+	curve := s.key.Curve
+	n := curve.Params().N
+	e := hashToInt(digest, n)
+
+	nonces := newRFC6979Nonces(hash, s.key.D, n, digest)
+	for {
+		k := nonces.next()
+
+		x, _ := curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 * (e + r*d) mod n
+		sig := new(big.Int).Mul(r, s.key.D)
+		sig.Add(sig, e)
+		sig.Mul(sig, new(big.Int).ModInverse(k, n))
+		sig.Mod(sig, n)
+		if sig.Sign() == 0 {
+			continue
+		}
+
+		return asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+	}
+}
+
+// hashToInt converts b to an integer from its leftmost bits, as many as the curve order n
+// has. It's the bits2int function of RFC 6979, which is also how ECDSA truncates digests.
+func hashToInt(b []byte, n *big.Int) *big.Int {
+	qlen := n.BitLen()
+	v := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - qlen; excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+	return v
+}
+
+// rfc6979Nonces generates the candidate nonces for signing one digest with one key, using
+// HMAC_DRBG as described in RFC 6979 section 3.2.
+type rfc6979Nonces struct {
+	hash crypto.Hash
+	n    *big.Int
+	k, v []byte
+}
+
+func newRFC6979Nonces(hash crypto.Hash, x, n *big.Int, digest []byte) *rfc6979Nonces {
+	rlen := (n.BitLen() + 7) / 8
+	key := int2octets(x, rlen)
+	h1 := hashToInt(digest, n)
+	if h1.Cmp(n) >= 0 {
+		h1.Sub(h1, n)
+	}
+	msg := int2octets(h1, rlen)
+
+	g := &rfc6979Nonces{hash: hash, n: n, k: make([]byte, hash.Size()), v: make([]byte, hash.Size())}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	for _, b := range []byte{0x00, 0x01} {
+		g.k = g.mac(g.k, g.v, []byte{b}, key, msg)
+		g.v = g.mac(g.k, g.v)
+	}
+
+	return g
+}
+
+// next returns the next nonce that is in range for the curve. Once a nonce has been returned
+// the following one is only needed if it failed to make a valid signature.
+func (g *rfc6979Nonces) next() *big.Int {
+	rlen := (g.n.BitLen() + 7) / 8
+	for {
+		var t []byte
+		for len(t) < rlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+
+		k := hashToInt(t[:rlen], g.n)
+
+		// Update the state now, so the following call returns a different nonce
+		g.k = g.mac(g.k, g.v, []byte{0x00})
+		g.v = g.mac(g.k, g.v)
+
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *rfc6979Nonces) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.hash.New, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// int2octets returns x as rlen big endian bytes.
+func int2octets(x *big.Int, rlen int) []byte {
+	b := x.Bytes()
+	if len(b) >= rlen {
+		return b[len(b)-rlen:]
+	}
+	return append(make([]byte, rlen-len(b)), b...)
+}
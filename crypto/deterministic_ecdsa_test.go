@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func fromHex(t *testing.T, s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("Bad hex %q", s)
+	}
+	return v
+}
+
+// rfc6979Key is the P-256 key from RFC 6979 appendix A.2.5
+func rfc6979Key(t *testing.T) *ecdsa.PrivateKey {
+	key := &ecdsa.PrivateKey{D: fromHex(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(key.D.Bytes())
+	return key
+}
+
+func TestDeterministicECDSAVectors(t *testing.T) {
+	key := rfc6979Key(t)
+	if got, want := key.X, fromHex(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"); got.Cmp(want) != 0 {
+		t.Fatalf("Test key has public X %x, want %x", got, want)
+	}
+
+	// The SHA-256 signatures from RFC 6979 appendix A.2.5
+	for _, test := range []struct {
+		msg, k, r, s string
+	}{
+		{
+			msg: "sample",
+			k:   "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:   "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:   "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			msg: "test",
+			k:   "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:   "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:   "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	} {
+		digest := sha256.Sum256([]byte(test.msg))
+
+		if got, want := newRFC6979Nonces(crypto.SHA256, key.D, key.Params().N, digest[:]).next(), fromHex(t, test.k); got.Cmp(want) != 0 {
+			t.Errorf("%q: got nonce %x, want %x", test.msg, got, want)
+		}
+
+		der, err := deterministicECDSASigner{key}.Sign(nil, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%q: Sign() = %v", test.msg, err)
+		}
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &sig); err != nil {
+			t.Fatalf("%q: failed to decode signature: %v", test.msg, err)
+		}
+		if sig.R.Cmp(fromHex(t, test.r)) != 0 || sig.S.Cmp(fromHex(t, test.s)) != 0 {
+			t.Errorf("%q: got signature (%x, %x), want (%s, %s)", test.msg, sig.R, sig.S, test.r, test.s)
+		}
+		if !ecdsa.Verify(&key.PublicKey, digest[:], sig.R, sig.S) {
+			t.Errorf("%q: signature doesn't verify", test.msg)
+		}
+	}
+}
+
+func TestDeterministicKeyManagerSignsRoots(t *testing.T) {
+	km := NewDeterministicKeyManager(PEMKeyManager{}.NewPEMKeyManager(rfc6979Key(t)))
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	s := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer)
+
+	root := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5}
+	sig, err := s.SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to sign log root: %v", err)
+	}
+	again, err := s.SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to sign log root: %v", err)
+	}
+	if !bytes.Equal(sig.Signature, again.Signature) {
+		t.Errorf("Signing the same root twice made different signatures: %s and %s", hex.EncodeToString(sig.Signature), hex.EncodeToString(again.Signature))
+	}
+
+	root.Signature = &sig
+	if err := VerifySignedLogRoot(signer.Public(), root); err != nil {
+		t.Errorf("VerifySignedLogRoot() = %v", err)
+	}
+
+	root.TreeSize++
+	other, err := s.SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to sign log root: %v", err)
+	}
+	if bytes.Equal(sig.Signature, other.Signature) {
+		t.Error("Different roots had the same signature")
+	}
+}
+
+func TestDeterministicKeyManagerOtherKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	signer, err := NewDeterministicKeyManager(PEMKeyManager{}.NewPEMKeyManager(rsaKey)).Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	if signer != crypto.Signer(rsaKey) {
+		t.Errorf("Signer() = %T, want the RSA key unchanged", signer)
+	}
+
+	if _, err := NewDeterministicKeyManager(NewPEMKeyManager()).Signer(); err == nil {
+		t.Error("Signer() succeeded without a private key")
+	}
+}
+
+func TestDeterministicECDSARejectsBadDigests(t *testing.T) {
+	signer := deterministicECDSASigner{rfc6979Key(t)}
+	if _, err := signer.Sign(nil, []byte("short"), crypto.SHA256); err == nil {
+		t.Error("Sign() accepted a digest of the wrong length")
+	}
+	if _, err := signer.Sign(nil, make([]byte, 32), crypto.Hash(0)); err == nil {
+		t.Error("Sign() accepted no hash function")
+	}
+}
+
+func TestDeterministicKeyManagerFunc(t *testing.T) {
+	if err := RegisterKeyManager("test-rfc6979", func(spec string) (KeyManager, error) {
+		return PEMKeyManager{}.NewPEMKeyManager(rfc6979Key(t)), nil
+	}); err != nil {
+		t.Fatalf("RegisterKeyManager() = %v", err)
+	}
+
+	f := NewDeterministicKeyManagerFunc()
+	km, err := f("test-rfc6979:key")
+	if err != nil {
+		t.Fatalf("NewDeterministicKeyManagerFunc() = %v", err)
+	}
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	if _, ok := signer.(deterministicECDSASigner); !ok {
+		t.Errorf("Signer() = %T, want a deterministic signer", signer)
+	}
+
+	if _, err := f("unregistered:key"); err != ErrUnknownKeyScheme {
+		t.Errorf("NewDeterministicKeyManagerFunc() of an unknown scheme = %v, want %v", err, ErrUnknownKeyScheme)
+	}
+}
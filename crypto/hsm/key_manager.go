@@ -0,0 +1,360 @@
+// Package hsm provides a KeyManager for signing keys held in a hardware security module,
+// or anything else with a PKCS #11 interface, so that the private keys of logs never leave
+// it.
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/miekg/pkcs11"
+)
+
+// KeyScheme is the scheme for key IDs of the form "pkcs11:<label>", naming a key pair on
+// the token the server has logged in to. The private and public keys must both have the
+// label.
+const KeyScheme = "pkcs11"
+
+// Token is a session with a PKCS #11 token, shared by all the keys on it. A session can't
+// be used by more than one request at a time so they take turns.
+type Token struct {
+	mutex   sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// OpenToken loads the PKCS #11 module in the library at modulePath and logs in to the token
+// labelled tokenLabel with pin.
+func OpenToken(modulePath, tokenLabel, pin string) (*Token, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("hsm: failed to load PKCS #11 module %s", modulePath)
+	}
+
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("hsm: failed to initialize PKCS #11 module %s: %v", modulePath, err)
+	}
+
+	session, err := openSession(ctx, tokenLabel, pin)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+
+	return &Token{ctx: ctx, session: session}, nil
+}
+
+// openSession logs in to the token labelled tokenLabel in a new session.
+func openSession(ctx *pkcs11.Ctx, tokenLabel, pin string) (pkcs11.SessionHandle, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("hsm: failed to list slots: %v", err)
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("hsm: failed to get token info of slot %d: %v", slot, err)
+		}
+		if info.Label != tokenLabel {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			return 0, fmt.Errorf("hsm: failed to open session with token %s: %v", tokenLabel, err)
+		}
+
+		if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+			ctx.CloseSession(session)
+			return 0, fmt.Errorf("hsm: failed to log in to token %s: %v", tokenLabel, err)
+		}
+
+		return session, nil
+	}
+
+	return 0, fmt.Errorf("hsm: no token labelled %s", tokenLabel)
+}
+
+// Close logs out of the token and unloads the module. The token's keys can't be used after
+// it's closed.
+func (t *Token) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.ctx.Logout(t.session)
+	err := t.ctx.CloseSession(t.session)
+	t.ctx.Finalize()
+	t.ctx.Destroy()
+
+	return err
+}
+
+// KeyManager is a KeyManager for a key pair on a token. Its public key is read when it's
+// created, each signature is then made by the token.
+type KeyManager struct {
+	token      *Token
+	privateKey pkcs11.ObjectHandle
+
+	publicKey    crypto.PublicKey
+	rawPublicKey []byte
+}
+
+// NewKeyManager creates a KeyManager for the key pair on the token called label. ECDSA
+// keys on the P-256, P-384 and P-521 curves and RSA keys are supported.
+func (t *Token) NewKeyManager(label string) (*KeyManager, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	privateKey, err := t.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+
+	publicHandle, err := t.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := t.readPublicKey(publicHandle)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: failed to read public key %s: %v", label, err)
+	}
+
+	rawPublicKey, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyManager{token: t, privateKey: privateKey, publicKey: publicKey, rawPublicKey: rawPublicKey}, nil
+}
+
+// NewKeyManagerFunc returns a NewKeyManagerFunc, suitable for registering with KeyScheme,
+// which creates KeyManagers for key pairs on the token.
+func (t *Token) NewKeyManagerFunc() tcrypto.NewKeyManagerFunc {
+	return func(label string) (tcrypto.KeyManager, error) {
+		km, err := t.NewKeyManager(label)
+		if err != nil {
+			return nil, err
+		}
+
+		return km, nil
+	}
+}
+
+// findObject returns the only object of class with label. The caller must hold the mutex.
+func (t *Token) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return 0, err
+	}
+	defer t.ctx.FindObjectsFinal(t.session)
+
+	objects, _, err := t.ctx.FindObjects(t.session, 2)
+	if err != nil {
+		return 0, err
+	}
+
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("hsm: no key labelled %s", label)
+	case 1:
+		return objects[0], nil
+	}
+
+	return 0, fmt.Errorf("hsm: more than one key labelled %s", label)
+}
+
+// readPublicKey reads an EC or RSA public key. The caller must hold the mutex.
+func (t *Token) readPublicKey(handle pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	// Asking for attributes the key doesn't have fails, so the key type is found by trying
+	// each in turn rather than by decoding CKA_KEY_TYPE, whose size depends on the platform
+	ec, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err == nil {
+		return parseECPublicKey(ec[0].Value, ec[1].Value)
+	}
+
+	rsaKey, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err == nil {
+		return parseRSAPublicKey(rsaKey[0].Value, rsaKey[1].Value)
+	}
+
+	return nil, errors.New("not an EC or RSA key")
+}
+
+var namedCurves = []struct {
+	oid   asn1.ObjectIdentifier
+	curve elliptic.Curve
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}, elliptic.P256()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 34}, elliptic.P384()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 35}, elliptic.P521()},
+}
+
+// parseECPublicKey parses the DER encoded CKA_EC_PARAMS and CKA_EC_POINT of an EC key,
+// the OID of its curve and its uncompressed point in an octet string.
+func parseECPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("EC params aren't a named curve: %v", err)
+	}
+
+	var curve elliptic.Curve
+	for _, named := range namedCurves {
+		if oid.Equal(named.oid) {
+			curve = named.curve
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported curve %v", oid)
+	}
+
+	var encoded []byte
+	if _, err := asn1.Unmarshal(point, &encoded); err != nil {
+		return nil, fmt.Errorf("EC point isn't an octet string: %v", err)
+	}
+
+	x, y := elliptic.Unmarshal(curve, encoded)
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// parseRSAPublicKey parses the big-endian CKA_MODULUS and CKA_PUBLIC_EXPONENT of an RSA key.
+func parseRSAPublicKey(modulus, exponent []byte) (*rsa.PublicKey, error) {
+	e := new(big.Int).SetBytes(exponent)
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("RSA public exponent is too large")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e.Int64())}, nil
+}
+
+// Signer returns a signer that signs with the private key on the token.
+func (k *KeyManager) Signer() (crypto.Signer, error) {
+	return signer{k}, nil
+}
+
+// GetPublicKey returns the public key of the key pair.
+func (k *KeyManager) GetPublicKey() (crypto.PublicKey, error) {
+	return k.publicKey, nil
+}
+
+// GetRawPublicKey returns the DER encoded public key of the key pair.
+func (k *KeyManager) GetRawPublicKey() ([]byte, error) {
+	return k.rawPublicKey, nil
+}
+
+// signer makes ASN.1 encoded ECDSA signatures and PKCS #1 v1.5 RSA signatures, the same as
+// those of the private keys in the crypto package.
+type signer struct {
+	km *KeyManager
+}
+
+// Public returns the public key.
+func (s signer) Public() crypto.PublicKey {
+	return s.km.publicKey
+}
+
+// Sign signs digest, which must have been made with the hash function of opts. The random
+// source is ignored, the token has its own.
+func (s signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, fmt.Errorf("hsm: digest is %d bytes, want %d", len(digest), opts.HashFunc().Size())
+	}
+
+	var mechanism uint
+	var data []byte
+
+	switch s.km.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		mechanism, data = pkcs11.CKM_ECDSA, digest
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("hsm: RSA-PSS signatures aren't supported")
+		}
+
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("hsm: unsupported hash function %v", opts.HashFunc())
+		}
+
+		// CKM_RSA_PKCS pads the data it's given, which must already be a DigestInfo
+		mechanism, data = pkcs11.CKM_RSA_PKCS, append(append([]byte{}, prefix...), digest...)
+	default:
+		return nil, fmt.Errorf("hsm: unsupported key type %T", s.km.publicKey)
+	}
+
+	sig, err := s.km.token.sign(s.km.privateKey, mechanism, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if mechanism == pkcs11.CKM_ECDSA {
+		return encodeECDSASignature(sig)
+	}
+
+	return sig, nil
+}
+
+// sign signs data with the private key using mechanism.
+func (t *Token) sign(privateKey pkcs11.ObjectHandle, mechanism uint, data []byte) ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if err := t.ctx.SignInit(t.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, privateKey); err != nil {
+		return nil, fmt.Errorf("hsm: failed to start signing: %v", err)
+	}
+
+	sig, err := t.ctx.Sign(t.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("hsm: failed to sign: %v", err)
+	}
+
+	return sig, nil
+}
+
+// digestInfoPrefixes are the DER encoded DigestInfo structures that PKCS #1 v1.5 signatures
+// are made over, up to the digest itself.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// encodeECDSASignature converts a PKCS #11 ECDSA signature, r and s of equal length one
+// after the other, to the ASN.1 encoding of ecdsa.PrivateKey.Sign.
+func encodeECDSASignature(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, fmt.Errorf("hsm: ECDSA signature of %d bytes isn't r and s", len(sig))
+	}
+
+	half := len(sig) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{new(big.Int).SetBytes(sig[:half]), new(big.Int).SetBytes(sig[half:])})
+}
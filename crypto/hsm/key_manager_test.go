@@ -0,0 +1,154 @@
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+// The signing itself needs a token, e.g. SoftHSM, so these tests cover converting between
+// PKCS #11 and Go encodings
+
+func TestParseECPublicKey(t *testing.T) {
+	for _, named := range namedCurves {
+		key, err := ecdsa.GenerateKey(named.curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+
+		params, err := asn1.Marshal(named.oid)
+		if err != nil {
+			t.Fatalf("Failed to marshal curve: %v", err)
+		}
+		point, err := asn1.Marshal(elliptic.Marshal(named.curve, key.X, key.Y))
+		if err != nil {
+			t.Fatalf("Failed to marshal point: %v", err)
+		}
+
+		got, err := parseECPublicKey(params, point)
+		if err != nil {
+			t.Fatalf("parseECPublicKey() for %v = %v", named.oid, err)
+		}
+
+		if got.Curve != named.curve || got.X.Cmp(key.X) != 0 || got.Y.Cmp(key.Y) != 0 {
+			t.Errorf("parseECPublicKey() for %v = %v, want %v", named.oid, got, key.PublicKey)
+		}
+	}
+}
+
+func TestParseECPublicKeyErrors(t *testing.T) {
+	p256, _ := asn1.Marshal(namedCurves[0].oid)
+	p224, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 33})
+	notOnCurve, _ := asn1.Marshal(append([]byte{4}, make([]byte, 64)...))
+	// The point must be wrapped in an octet string
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bare := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+
+	for _, test := range []struct {
+		desc          string
+		params, point []byte
+	}{
+		{"garbage params", []byte{1, 2, 3}, notOnCurve},
+		{"unsupported curve", p224, notOnCurve},
+		{"point not on curve", p256, notOnCurve},
+		{"bare point", p256, bare},
+	} {
+		if _, err := parseECPublicKey(test.params, test.point); err == nil {
+			t.Errorf("%s: parseECPublicKey() parsed a key", test.desc)
+		}
+	}
+}
+
+func TestParseRSAPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	got, err := parseRSAPublicKey(key.N.Bytes(), big.NewInt(int64(key.E)).Bytes())
+	if err != nil {
+		t.Fatalf("parseRSAPublicKey() = %v", err)
+	}
+
+	if got.N.Cmp(key.N) != 0 || got.E != key.E {
+		t.Errorf("parseRSAPublicKey() = %v, want %v", got, key.PublicKey)
+	}
+
+	if _, err := parseRSAPublicKey(key.N.Bytes(), []byte{0, 0, 0, 0, 1, 0, 1}); err != nil {
+		t.Errorf("parseRSAPublicKey() rejected zero padded exponent: %v", err)
+	}
+	if _, err := parseRSAPublicKey(key.N.Bytes(), []byte{1, 0, 0, 0, 0}); err == nil {
+		t.Error("parseRSAPublicKey() accepted a 33 bit exponent")
+	}
+}
+
+func TestEncodeECDSASignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("root"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	// PKCS #11 pads r and s to the size of the curve
+	raw := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(raw[32-len(rBytes):32], rBytes)
+	copy(raw[64-len(sBytes):], sBytes)
+
+	sig, err := encodeECDSASignature(raw)
+	if err != nil {
+		t.Fatalf("encodeECDSASignature() = %v", err)
+	}
+
+	var decoded struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &decoded); err != nil {
+		t.Fatalf("Signature isn't ASN.1: %v", err)
+	}
+	if !ecdsa.Verify(&key.PublicKey, digest[:], decoded.R, decoded.S) {
+		t.Error("Encoded signature doesn't verify")
+	}
+
+	for _, raw := range [][]byte{nil, make([]byte, 63)} {
+		if _, err := encodeECDSASignature(raw); err == nil {
+			t.Errorf("encodeECDSASignature() encoded %d bytes", len(raw))
+		}
+	}
+}
+
+func TestDigestInfoPrefixes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	digest256 := sha256.Sum256([]byte("root"))
+	digest384 := sha512.Sum384([]byte("root"))
+	digest512 := sha512.Sum512([]byte("root"))
+
+	for hash, digest := range map[crypto.Hash][]byte{crypto.SHA256: digest256[:], crypto.SHA384: digest384[:], crypto.SHA512: digest512[:]} {
+		// Signing without a hash function pads the data as it is, like CKM_RSA_PKCS
+		data := append(append([]byte{}, digestInfoPrefixes[hash]...), digest...)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, 0, data)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, hash, digest, sig); err != nil {
+			t.Errorf("Signature over %v DigestInfo doesn't verify: %v", hash, err)
+		}
+	}
+}
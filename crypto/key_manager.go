@@ -11,9 +11,12 @@ import (
 	"io/ioutil"
 
 	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"golang.org/x/crypto/ed25519"
 )
 
-// KeyManager loads and holds our private and public keys. Should support ECDSA and RSA keys.
+// KeyManager loads and holds our private and public keys. Should support ECDSA, RSA and
+// Ed25519 keys.
 // The crypto.Signer API allows for obtaining a public key from a private key but there are
 // cases where we have the public key only, such as mirroring another log, so we treat them
 // separately. KeyManager is an interface as we expect multiple implementations supporting
@@ -87,6 +90,12 @@ func (k *PEMKeyManager) LoadPublicKey(pemEncodedKey string) error {
 
 	k.rawPublicKey = publicBlock.Bytes
 
+	// x509 can't encode Ed25519 keys so these are stored as the raw key bytes
+	if len(publicBlock.Bytes) == ed25519.PublicKeySize {
+		k.serverPublicKey = ed25519.PublicKey(publicBlock.Bytes)
+		return nil
+	}
+
 	parsedKey, err := x509.ParsePKIXPublicKey(publicBlock.Bytes)
 
 	if err != nil {
@@ -107,7 +116,7 @@ func (k PEMKeyManager) Signer() (crypto.Signer, error) {
 	// Good old interface{}, this wouldn't be necessary in a proper type system. If it's
 	// even the right thing to do but I couldn't find any useful docs so meh
 	switch k.serverPrivateKey.(type) {
-	case *ecdsa.PrivateKey, *rsa.PrivateKey:
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
 		return k.serverPrivateKey.(crypto.Signer), nil
 	}
 
@@ -136,6 +145,12 @@ func (k PEMKeyManager) GetRawPublicKey() ([]byte, error) {
 }
 
 func parsePrivateKey(key []byte) (crypto.PrivateKey, error) {
+	// x509 can't encode Ed25519 keys so these are stored as the raw key bytes, which are
+	// a different length to any of the other encodings.
+	if len(key) == ed25519.PrivateKeySize {
+		return ed25519.PrivateKey(key), nil
+	}
+
 	// Our two ways of reading keys are ParsePKCS1PrivateKey and ParsePKCS8PrivateKey.
 	// And ParseECPrivateKey. Our three ways of parsing keys are ... I'll come in again.
 	if key, err := x509.ParsePKCS1PrivateKey(key); err == nil {
@@ -180,3 +195,18 @@ func LoadPasswordProtectedPrivateKey(keyFile, keyPassword string) (KeyManager, e
 
 	return *km, nil
 }
+
+// SignatureAlgorithmForKey returns the signature algorithm that is used with a public key,
+// so that the algorithm for a tree follows from the type of its key.
+func SignatureAlgorithmForKey(key crypto.PublicKey) (trillian.SignatureAlgorithm, error) {
+	switch key.(type) {
+	case *ecdsa.PublicKey:
+		return trillian.SignatureAlgorithm_ECDSA, nil
+	case *rsa.PublicKey:
+		return trillian.SignatureAlgorithm_RSA, nil
+	case ed25519.PublicKey:
+		return trillian.SignatureAlgorithm_ED25519, nil
+	}
+
+	return trillian.SignatureAlgorithm_ECDSA, fmt.Errorf("unsupported public key type: %T", key)
+}
@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PEMKeyScheme is the scheme for key IDs of the form "pem:<file>", naming an encrypted PEM
+// private key file.
+const PEMKeyScheme = "pem"
+
+// ErrUnknownKeyScheme is returned by NewKeyManager when a key ID doesn't start with the
+// scheme of a registered KeyManager implementation.
+var ErrUnknownKeyScheme = errors.New("crypto: unknown key scheme")
+
+// NewKeyManagerFunc creates a KeyManager for the key identified by spec. The format of spec
+// is specific to the implementation, for example a file name or the name of a key held in
+// an HSM or a cloud key management service.
+type NewKeyManagerFunc func(spec string) (KeyManager, error)
+
+var keyManagersMutex sync.RWMutex
+var keyManagers = make(map[string]NewKeyManagerFunc)
+
+// RegisterKeyManager makes a KeyManager implementation available for key IDs starting with
+// "<scheme>:". It returns an error if an implementation has already been registered with
+// the same scheme.
+func RegisterKeyManager(scheme string, f NewKeyManagerFunc) error {
+	if f == nil {
+		return fmt.Errorf("crypto: nil NewKeyManagerFunc for scheme %s", scheme)
+	}
+
+	keyManagersMutex.Lock()
+	defer keyManagersMutex.Unlock()
+
+	if _, exists := keyManagers[scheme]; exists {
+		return fmt.Errorf("crypto: key manager for scheme %s already registered", scheme)
+	}
+
+	keyManagers[scheme] = f
+	return nil
+}
+
+// NewKeyManager creates a KeyManager for a key ID of the form "<scheme>:<spec>" using the
+// implementation registered for the scheme. It returns ErrUnknownKeyScheme if there isn't
+// one.
+func NewKeyManager(keyID string) (KeyManager, error) {
+	parts := strings.SplitN(keyID, ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrUnknownKeyScheme
+	}
+
+	keyManagersMutex.RLock()
+	f, ok := keyManagers[parts[0]]
+	keyManagersMutex.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownKeyScheme
+	}
+
+	return f(parts[1])
+}
+
+// KeyManagerSchemes returns the sorted schemes of all registered KeyManager implementations.
+func KeyManagerSchemes() []string {
+	keyManagersMutex.RLock()
+	defer keyManagersMutex.RUnlock()
+
+	schemes := make([]string, 0, len(keyManagers))
+	for scheme := range keyManagers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// NewPEMFileKeyManagerFunc returns a NewKeyManagerFunc, suitable for registering with
+// PEMKeyScheme, which loads private keys from PEM files protected by password.
+func NewPEMFileKeyManagerFunc(password string) NewKeyManagerFunc {
+	return func(keyFile string) (KeyManager, error) {
+		return LoadPasswordProtectedPrivateKey(keyFile, password)
+	}
+}
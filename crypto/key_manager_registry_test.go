@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/trillian/testonly"
+)
+
+func TestRegisterKeyManager(t *testing.T) {
+	var gotSpec string
+	km := NewPEMKeyManager()
+	f := func(spec string) (KeyManager, error) {
+		gotSpec = spec
+		return *km, nil
+	}
+
+	if err := RegisterKeyManager("test-register", f); err != nil {
+		t.Fatalf("RegisterKeyManager() = %v", err)
+	}
+	if err := RegisterKeyManager("test-register", f); err == nil {
+		t.Error("RegisterKeyManager() allowed a scheme to be registered twice")
+	}
+	if err := RegisterKeyManager("test-nil", nil); err == nil {
+		t.Error("RegisterKeyManager() accepted a nil NewKeyManagerFunc")
+	}
+
+	if _, err := NewKeyManager("test-register:key/name:1"); err != nil {
+		t.Fatalf("NewKeyManager() = %v", err)
+	}
+	if want := "key/name:1"; gotSpec != want {
+		t.Errorf("NewKeyManagerFunc got spec %q, want %q", gotSpec, want)
+	}
+
+	found := false
+	for _, scheme := range KeyManagerSchemes() {
+		found = found || scheme == "test-register"
+	}
+	if !found {
+		t.Errorf("KeyManagerSchemes() = %v, want it to include test-register", KeyManagerSchemes())
+	}
+}
+
+func TestNewKeyManagerUnknownScheme(t *testing.T) {
+	for _, keyID := range []string{"", "key", "unregistered:key"} {
+		if _, err := NewKeyManager(keyID); err != ErrUnknownKeyScheme {
+			t.Errorf("NewKeyManager(%q) = %v, want %v", keyID, err, ErrUnknownKeyScheme)
+		}
+	}
+}
+
+func TestNewKeyManagerPropagatesError(t *testing.T) {
+	if err := RegisterKeyManager("test-error", func(string) (KeyManager, error) {
+		return nil, errors.New("KEY")
+	}); err != nil {
+		t.Fatalf("RegisterKeyManager() = %v", err)
+	}
+
+	if _, err := NewKeyManager("test-error:key"); err == nil || err == ErrUnknownKeyScheme {
+		t.Errorf("NewKeyManager() = %v, want the error from the implementation", err)
+	}
+}
+
+func TestPEMFileKeyManagerFunc(t *testing.T) {
+	file, err := ioutil.TempFile("", "key")
+	if err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(testonly.DemoPrivateKey); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	file.Close()
+
+	km, err := NewPEMFileKeyManagerFunc(testonly.DemoPrivateKeyPass)(file.Name())
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	if _, err := km.Signer(); err != nil {
+		t.Errorf("Signer() = %v", err)
+	}
+
+	if _, err := NewPEMFileKeyManagerFunc("wrong")(file.Name()); err == nil {
+		t.Error("Loaded key with the wrong password")
+	}
+}
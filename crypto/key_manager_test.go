@@ -1,7 +1,9 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	cryptorand "crypto/rand"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/testonly"
+	"golang.org/x/crypto/ed25519"
 )
 
 type ecdsaSig struct {
@@ -92,3 +95,50 @@ func TestLoadDemoECDSAPublicKey(t *testing.T) {
 		t.Fatalf("Expected to have loaded an ECDSA key but got: %v", key)
 	}
 }
+
+func TestLoadEd25519KeysAndSign(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	privateBlock, err := x509.EncryptPEMBlock(cryptorand.Reader, "ED25519 PRIVATE KEY", private, []byte("towel"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+
+	km := new(PEMKeyManager)
+
+	if err := km.LoadPrivateKey(string(pem.EncodeToMemory(privateBlock)), "towel"); err != nil {
+		t.Fatalf("Failed to load private key: %v", err)
+	}
+
+	if err := km.LoadPublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "ED25519 PUBLIC KEY", Bytes: public}))); err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	// Ed25519 signs the message itself so no hash function is passed
+	signed, err := signer.Sign(cryptorand.Reader, []byte("hello"), crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	key, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatalf("Unexpected error getting public key: %v", err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		t.Fatalf("Expected to have loaded an Ed25519 key but got: %v", key)
+	}
+
+	if !ed25519.Verify(publicKey, []byte("hello"), signed) {
+		t.Fatal("Signature did not verify on round trip test")
+	}
+}
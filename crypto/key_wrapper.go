@@ -0,0 +1,150 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LocalKeyWrapperScheme is the scheme for key wrapper URIs of the form "local:<file>",
+// naming a file that holds a hex encoded AES-256 key encryption key. It's for deployments
+// without an external key management service, and for testing.
+const LocalKeyWrapperScheme = "local"
+
+// KeyWrapper encrypts keys with a key encryption key, usually one held by an external key
+// management service that never reveals it. A tree's data keys are then only stored wrapped,
+// so whoever controls the wrapping key controls access to the tree's data: once it's revoked
+// the data keys can't be unwrapped.
+type KeyWrapper interface {
+	// WrapKey returns key encrypted by the wrapping key
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey returns the key that was wrapped by WrapKey
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// NewKeyWrapperFunc creates a KeyWrapper for the wrapping key identified by spec. As with
+// NewKeyManagerFunc the format of spec is specific to the implementation, e.g. the resource
+// name of a key in a cloud key management service.
+type NewKeyWrapperFunc func(spec string) (KeyWrapper, error)
+
+var keyWrappersMutex sync.RWMutex
+var keyWrappers = map[string]NewKeyWrapperFunc{LocalKeyWrapperScheme: newLocalKeyWrapper}
+
+// RegisterKeyWrapper makes a KeyWrapper implementation available for key URIs starting with
+// "<scheme>:". It returns an error if an implementation has already been registered with
+// the same scheme. Wrappers for key management services can be registered by packages that
+// depend on their client libraries.
+func RegisterKeyWrapper(scheme string, f NewKeyWrapperFunc) error {
+	if f == nil {
+		return fmt.Errorf("crypto: nil NewKeyWrapperFunc for scheme %s", scheme)
+	}
+
+	keyWrappersMutex.Lock()
+	defer keyWrappersMutex.Unlock()
+
+	if _, exists := keyWrappers[scheme]; exists {
+		return fmt.Errorf("crypto: key wrapper for scheme %s already registered", scheme)
+	}
+
+	keyWrappers[scheme] = f
+	return nil
+}
+
+// NewKeyWrapper creates a KeyWrapper for a key URI of the form "<scheme>:<spec>" using the
+// implementation registered for the scheme. It returns ErrUnknownKeyScheme if there isn't
+// one.
+func NewKeyWrapper(keyURI string) (KeyWrapper, error) {
+	parts := strings.SplitN(keyURI, ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrUnknownKeyScheme
+	}
+
+	keyWrappersMutex.RLock()
+	f, ok := keyWrappers[parts[0]]
+	keyWrappersMutex.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownKeyScheme
+	}
+
+	return f(parts[1])
+}
+
+// KeyWrapperSchemes returns the sorted schemes of all registered KeyWrapper implementations.
+func KeyWrapperSchemes() []string {
+	keyWrappersMutex.RLock()
+	defer keyWrappersMutex.RUnlock()
+
+	schemes := make([]string, 0, len(keyWrappers))
+	for scheme := range keyWrappers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// AESKeyWrapper is a KeyWrapper that seals keys with AES-GCM under a key encryption key
+// that it holds itself
+type AESKeyWrapper struct {
+	gcm cipher.AEAD
+}
+
+// NewAESKeyWrapper creates an AESKeyWrapper for kek, which must be DataKeySize bytes long
+func NewAESKeyWrapper(kek []byte) (*AESKeyWrapper, error) {
+	if len(kek) != DataKeySize {
+		return nil, fmt.Errorf("key encryption key is %d bytes, want %d", len(kek), DataKeySize)
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESKeyWrapper{gcm: gcm}, nil
+}
+
+func newLocalKeyWrapper(file string) (KeyWrapper, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid key encryption key: %v", file, err)
+	}
+
+	return NewAESKeyWrapper(kek)
+}
+
+// WrapKey seals key after a random nonce
+func (w *AESKeyWrapper) WrapKey(key []byte) ([]byte, error) {
+	nonce := make([]byte, w.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return w.gcm.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey opens a key sealed by WrapKey
+func (w *AESKeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < w.gcm.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+
+	return w.gcm.Open(nil, wrapped[:w.gcm.NonceSize()], wrapped[w.gcm.NonceSize():], nil)
+}
@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterKeyWrapper(t *testing.T) {
+	f := func(spec string) (KeyWrapper, error) {
+		return NewAESKeyWrapper(bytes.Repeat([]byte{1}, DataKeySize))
+	}
+
+	if err := RegisterKeyWrapper("test-wrapper", f); err != nil {
+		t.Fatalf("RegisterKeyWrapper() = %v", err)
+	}
+	if err := RegisterKeyWrapper("test-wrapper", f); err == nil {
+		t.Error("RegisterKeyWrapper() allowed a scheme to be registered twice")
+	}
+	if err := RegisterKeyWrapper(LocalKeyWrapperScheme, f); err == nil {
+		t.Error("RegisterKeyWrapper() allowed the local scheme to be registered again")
+	}
+	if err := RegisterKeyWrapper("test-nil", nil); err == nil {
+		t.Error("RegisterKeyWrapper() accepted a nil NewKeyWrapperFunc")
+	}
+
+	if _, err := NewKeyWrapper("test-wrapper:projects/p/keys/k"); err != nil {
+		t.Errorf("NewKeyWrapper() = %v", err)
+	}
+	for _, uri := range []string{"nosuch:key", "noscheme"} {
+		if _, err := NewKeyWrapper(uri); err != ErrUnknownKeyScheme {
+			t.Errorf("NewKeyWrapper(%q) = %v, want %v", uri, err, ErrUnknownKeyScheme)
+		}
+	}
+
+	found := false
+	for _, scheme := range KeyWrapperSchemes() {
+		found = found || scheme == "test-wrapper"
+	}
+	if !found {
+		t.Errorf("KeyWrapperSchemes() = %v, want it to include test-wrapper", KeyWrapperSchemes())
+	}
+}
+
+func TestLocalKeyWrapper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kek")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "kek")
+	if err := ioutil.WriteFile(file, []byte(hex.EncodeToString(bytes.Repeat([]byte{7}, DataKeySize))+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key encryption key: %v", err)
+	}
+
+	w, err := NewKeyWrapper(LocalKeyWrapperScheme + ":" + file)
+	if err != nil {
+		t.Fatalf("NewKeyWrapper() = %v", err)
+	}
+
+	key := []byte("a data key")
+	wrapped, err := w.WrapKey(key)
+	if err != nil {
+		t.Fatalf("WrapKey() = %v", err)
+	}
+	if bytes.Contains(wrapped, key) {
+		t.Errorf("WrapKey() = %x, want the key encrypted", wrapped)
+	}
+
+	if got, err := w.UnwrapKey(wrapped); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("UnwrapKey() = %q %v, want %q", got, err, key)
+	}
+
+	// A different wrapping key can't unwrap it
+	other, err := NewAESKeyWrapper(bytes.Repeat([]byte{8}, DataKeySize))
+	if err != nil {
+		t.Fatalf("NewAESKeyWrapper() = %v", err)
+	}
+	if _, err := other.UnwrapKey(wrapped); err == nil {
+		t.Error("UnwrapKey() with another wrapping key succeeded")
+	}
+
+	if _, err := NewAESKeyWrapper([]byte("short")); err == nil {
+		t.Error("NewAESKeyWrapper() accepted a short key")
+	}
+}
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// RotatingKeyScheme is the scheme for key IDs of the form
+// "rotate:<time>=<key ID>;<time>=<key ID>...", a schedule of keys each taking over signing
+// at an RFC 3339 time. The key IDs are created with NewKeyManager, so can use any other
+// registered scheme.
+const RotatingKeyScheme = "rotate"
+
+// ScheduledKey is a key that signs from ActiveFrom until the next key in its schedule
+// becomes active.
+type ScheduledKey struct {
+	KeyManager KeyManager
+	ActiveFrom time.Time
+}
+
+// RotatingKeyManager is a KeyManager that switches between keys on a schedule so that a
+// tree's signing key can be rolled over without downtime. All the keys are advertised by
+// PublicKeys, from before a key starts signing until it's removed from the schedule, so
+// clients can accept roots signed by either the old or the new key while the switch
+// happens. The other methods use the key that is signing now.
+type RotatingKeyManager struct {
+	timeSource util.TimeSource
+	// keys is sorted by activation time
+	keys []ScheduledKey
+}
+
+// NewRotatingKeyManager creates a RotatingKeyManager for keys, which must have different
+// activation times. They don't need to be in order.
+func NewRotatingKeyManager(timeSource util.TimeSource, keys []ScheduledKey) (*RotatingKeyManager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys scheduled")
+	}
+
+	sorted := append([]ScheduledKey{}, keys...)
+	sort.Sort(byActivationTime(sorted))
+
+	for i, key := range sorted {
+		if key.KeyManager == nil {
+			return nil, fmt.Errorf("no key manager for the key active from %v", key.ActiveFrom)
+		}
+		if i > 0 && key.ActiveFrom.Equal(sorted[i-1].ActiveFrom) {
+			return nil, fmt.Errorf("more than one key is active from %v", key.ActiveFrom)
+		}
+	}
+
+	return &RotatingKeyManager{timeSource: timeSource, keys: sorted}, nil
+}
+
+// current returns the key manager of the key that signs now, the latest one to become
+// active.
+func (r *RotatingKeyManager) current() (KeyManager, error) {
+	now := r.timeSource.Now()
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if !r.keys[i].ActiveFrom.After(now) {
+			return r.keys[i].KeyManager, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no key is active until %v", r.keys[0].ActiveFrom)
+}
+
+// Signer returns a signer for the key that is active now.
+func (r *RotatingKeyManager) Signer() (crypto.Signer, error) {
+	km, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return km.Signer()
+}
+
+// GetPublicKey returns the public key of the key that is active now.
+func (r *RotatingKeyManager) GetPublicKey() (crypto.PublicKey, error) {
+	km, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return publicKeyOf(km)
+}
+
+// GetRawPublicKey returns the DER encoded public key of the key that is active now.
+func (r *RotatingKeyManager) GetRawPublicKey() ([]byte, error) {
+	km, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return km.GetRawPublicKey()
+}
+
+// PublicKeys returns the public keys of every key in the schedule, in activation order,
+// including those that have been replaced and those that aren't active yet. Signatures
+// made by the manager verify with one of them.
+func (r *RotatingKeyManager) PublicKeys() ([]crypto.PublicKey, error) {
+	pubs := make([]crypto.PublicKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		pub, err := publicKeyOf(key.KeyManager)
+		if err != nil {
+			return nil, fmt.Errorf("no public key for the key active from %v: %v", key.ActiveFrom, err)
+		}
+		pubs = append(pubs, pub)
+	}
+
+	return pubs, nil
+}
+
+// publicKeyOf returns the public key held by km, or the public half of its private key if
+// it was only loaded with that.
+func publicKeyOf(km KeyManager) (crypto.PublicKey, error) {
+	if pub, err := km.GetPublicKey(); err == nil {
+		return pub, nil
+	}
+
+	signer, err := km.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Public(), nil
+}
+
+type byActivationTime []ScheduledKey
+
+func (k byActivationTime) Len() int           { return len(k) }
+func (k byActivationTime) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k byActivationTime) Less(i, j int) bool { return k[i].ActiveFrom.Before(k[j].ActiveFrom) }
+
+// NewRotatingKeyManagerFunc returns a NewKeyManagerFunc, suitable for registering with
+// RotatingKeyScheme, which creates a RotatingKeyManager from a schedule of
+// "<time>=<key ID>" entries separated by semicolons. The times are RFC 3339 and the key
+// managers for the key IDs are created with NewKeyManager.
+func NewRotatingKeyManagerFunc(timeSource util.TimeSource) NewKeyManagerFunc {
+	return func(spec string) (KeyManager, error) {
+		var keys []ScheduledKey
+		for _, entry := range strings.Split(spec, ";") {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 || len(parts[1]) == 0 {
+				return nil, fmt.Errorf("scheduled key %q is not of the form time=keyID", entry)
+			}
+
+			activeFrom, err := time.Parse(time.RFC3339, parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("scheduled key %q has an invalid time: %v", entry, err)
+			}
+
+			km, err := NewKeyManager(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to create key manager for scheduled key %q: %v", entry, err)
+			}
+
+			keys = append(keys, ScheduledKey{KeyManager: km, ActiveFrom: activeFrom})
+		}
+
+		return NewRotatingKeyManager(timeSource, keys)
+	}
+}
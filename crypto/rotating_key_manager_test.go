@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+var rotationTime = time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func testKeyManagers(t *testing.T, n int) []*PEMKeyManager {
+	kms := make([]*PEMKeyManager, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate ECDSA key: %v", err)
+		}
+		kms = append(kms, PEMKeyManager{}.NewPEMKeyManager(key))
+	}
+	return kms
+}
+
+func publicKey(t *testing.T, km KeyManager) crypto.PublicKey {
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	return signer.Public()
+}
+
+func TestRotatingKeyManagerSignsWithActiveKey(t *testing.T) {
+	kms := testKeyManagers(t, 2)
+	ts := &util.FakeTimeSource{}
+	// The keys are out of order to check they're sorted
+	r, err := NewRotatingKeyManager(ts, []ScheduledKey{
+		{KeyManager: kms[1], ActiveFrom: rotationTime},
+		{KeyManager: kms[0], ActiveFrom: rotationTime.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("NewRotatingKeyManager() = %v", err)
+	}
+
+	for _, test := range []struct {
+		now  time.Time
+		want KeyManager
+	}{
+		{rotationTime.Add(-time.Hour), kms[0]},
+		{rotationTime.Add(-time.Nanosecond), kms[0]},
+		{rotationTime, kms[1]},
+		{rotationTime.Add(24 * time.Hour), kms[1]},
+	} {
+		ts.FakeTime = test.now
+
+		signer, err := r.Signer()
+		if err != nil {
+			t.Fatalf("%v: Signer() = %v", test.now, err)
+		}
+		if got, want := signer.Public(), publicKey(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: Signer() used the wrong key", test.now)
+		}
+
+		pub, err := r.GetPublicKey()
+		if err != nil {
+			t.Fatalf("%v: GetPublicKey() = %v", test.now, err)
+		}
+		if !reflect.DeepEqual(pub, signer.Public()) {
+			t.Errorf("%v: GetPublicKey() isn't the key of the signer", test.now)
+		}
+	}
+
+	ts.FakeTime = rotationTime.Add(-2 * time.Hour)
+	if _, err := r.Signer(); err == nil {
+		t.Error("Signer() returned a key before any was active")
+	}
+}
+
+func TestRotatingKeyManagerAdvertisesAllKeys(t *testing.T) {
+	kms := testKeyManagers(t, 3)
+	r, err := NewRotatingKeyManager(util.FakeTimeSource{FakeTime: rotationTime}, []ScheduledKey{
+		{KeyManager: kms[0], ActiveFrom: rotationTime.Add(-time.Hour)},
+		{KeyManager: kms[1], ActiveFrom: rotationTime},
+		{KeyManager: kms[2], ActiveFrom: rotationTime.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("NewRotatingKeyManager() = %v", err)
+	}
+
+	pubs, err := r.PublicKeys()
+	if err != nil {
+		t.Fatalf("PublicKeys() = %v", err)
+	}
+	want := []crypto.PublicKey{publicKey(t, kms[0]), publicKey(t, kms[1]), publicKey(t, kms[2])}
+	if !reflect.DeepEqual(pubs, want) {
+		t.Errorf("PublicKeys() didn't return every scheduled key in order")
+	}
+
+	// Roots signed by the manager verify with the advertised keys
+	signer, err := r.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	root := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5}
+	sig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer).SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("Failed to sign log root: %v", err)
+	}
+	root.Signature = &sig
+	if err := VerifySignedLogRootWithKeys(pubs, root); err != nil {
+		t.Errorf("VerifySignedLogRootWithKeys() = %v", err)
+	}
+}
+
+func TestNewRotatingKeyManagerErrors(t *testing.T) {
+	kms := testKeyManagers(t, 2)
+	for _, test := range []struct {
+		desc string
+		keys []ScheduledKey
+	}{
+		{"no keys", nil},
+		{"nil key manager", []ScheduledKey{{ActiveFrom: rotationTime}}},
+		{"same activation time", []ScheduledKey{{kms[0], rotationTime}, {kms[1], rotationTime}}},
+	} {
+		if _, err := NewRotatingKeyManager(util.SystemTimeSource{}, test.keys); err == nil {
+			t.Errorf("%s: NewRotatingKeyManager() succeeded", test.desc)
+		}
+	}
+}
+
+func TestRotatingKeyManagerFunc(t *testing.T) {
+	kms := testKeyManagers(t, 2)
+	if err := RegisterKeyManager("test-rotate", func(spec string) (KeyManager, error) {
+		switch spec {
+		case "old":
+			return kms[0], nil
+		case "new":
+			return kms[1], nil
+		}
+		return nil, ErrUnknownKeyScheme
+	}); err != nil {
+		t.Fatalf("RegisterKeyManager() = %v", err)
+	}
+
+	f := NewRotatingKeyManagerFunc(util.FakeTimeSource{FakeTime: rotationTime})
+	km, err := f("2017-05-01T00:00:00Z=test-rotate:old;2017-06-01T00:00:00Z=test-rotate:new")
+	if err != nil {
+		t.Fatalf("NewRotatingKeyManagerFunc() = %v", err)
+	}
+	signer, err := km.Signer()
+	if err != nil {
+		t.Fatalf("Signer() = %v", err)
+	}
+	if !reflect.DeepEqual(signer.Public(), publicKey(t, kms[1])) {
+		t.Error("Signer() didn't use the key active at the rotation time")
+	}
+
+	for _, spec := range []string{
+		"",
+		"2017-05-01T00:00:00Z",
+		"2017-05-01T00:00:00Z=",
+		"May 2017=test-rotate:old",
+		"2017-05-01T00:00:00Z=test-rotate:missing",
+		"2017-05-01T00:00:00Z=test-rotate:old;2017-05-01T00:00:00Z=test-rotate:new",
+	} {
+		if _, err := f(spec); err == nil {
+			t.Errorf("NewRotatingKeyManagerFunc() accepted spec %q", spec)
+		}
+	}
+}
@@ -3,21 +3,12 @@ package crypto
 import (
 	"crypto"
 	"crypto/rand"
-	"encoding/base64"
 	"fmt"
-	"strconv"
 
-	"github.com/benlaurie/objecthash/go/objecthash"
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
-)
-
-// Constants used as map keys when building input for ObjectHash. They must not be changed
-// as this will change the output of hashRoot()
-const (
-	mapKeyRootHash       string = "RootHash"
-	mapKeyTimestampNanos string = "TimestampNanos"
-	mapKeyTreeSize       string = "TreeSize"
+	"github.com/google/trillian/merkle/verify"
 )
 
 // TrillianSigner is responsible for signing log-related data and producing the appropriate
@@ -29,14 +20,28 @@ type TrillianSigner struct {
 }
 
 // NewSigner creates a new LogSigner wrapping up a hasher and a signer. For the moment
-// we only support SHA256 hashing and either ECDSA or RSA signing but this is not enforced
-// here.
+// we only support SHA256 hashing and ECDSA, RSA or Ed25519 signing but this is not enforced
+// here. The signature algorithm must match the type of key held by the signer.
 func NewTrillianSigner(hasher trillian.Hasher, signatureAlgorithm trillian.SignatureAlgorithm, signer crypto.Signer) *TrillianSigner {
 	return &TrillianSigner{hasher, signer, signatureAlgorithm}
 }
 
-// Sign obtains a signature after first hashing the input data.
+// Sign obtains a signature after first hashing the input data. Ed25519 signatures are made
+// over the input data directly as the algorithm does its own hashing.
 func (s TrillianSigner) Sign(data []byte) (trillian.DigitallySigned, error) {
+	if s.sigAlgorithm == trillian.SignatureAlgorithm_ED25519 {
+		sig, err := s.signer.Sign(rand.Reader, data, crypto.Hash(0))
+
+		if err != nil {
+			return trillian.DigitallySigned{}, err
+		}
+
+		return trillian.DigitallySigned{
+			SignatureAlgorithm: s.sigAlgorithm,
+			HashAlgorithm:      s.hasher.HashAlgorithm(),
+			Signature:          sig}, nil
+	}
+
 	digest := s.hasher.Digest(data)
 
 	if len(digest) != s.hasher.Size() {
@@ -56,26 +61,39 @@ func (s TrillianSigner) Sign(data []byte) (trillian.DigitallySigned, error) {
 		Signature:          sig}, nil
 }
 
-func (s TrillianSigner) hashRoot(root trillian.SignedLogRoot) []byte {
-	rootMap := make(map[string]interface{})
-
-	// Pull out the fields we want to hash. Caution: use string format for int64 values as they
-	// can overflow when JSON encoded otherwise (it uses floats). We want to be sure that people
-	// using JSON to verify hashes can build the exact same input to ObjectHash.
-	rootMap[mapKeyRootHash] = base64.StdEncoding.EncodeToString(root.RootHash)
-	rootMap[mapKeyTimestampNanos] = strconv.FormatInt(root.TimestampNanos, 10)
-	rootMap[mapKeyTreeSize] = strconv.FormatInt(root.TreeSize, 10)
-
-	hash := objecthash.ObjectHash(rootMap)
+// serializeLogRoot returns the data that's signed for a log root, its fields serialized as a
+// verify.LogRootV1.
+func serializeLogRoot(root trillian.SignedLogRoot) ([]byte, error) {
+	return verify.LogRootV1{
+		TreeSize:       root.TreeSize,
+		RootHash:       root.RootHash,
+		TimestampNanos: root.TimestampNanos,
+		Revision:       root.TreeRevision,
+		Metadata:       root.Metadata,
+	}.MarshalBinary()
+}
 
-	return hash[:]
+func hashMapRoot(root trillian.SignedMapRoot) ([]byte, error) {
+	var metadata []byte
+	if root.Metadata != nil {
+		var err error
+		if metadata, err = proto.Marshal(root.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return verify.MapRootHash(root.RootHash, root.TimestampNanos, root.MapId, root.MapRevision, metadata), nil
 }
 
 // SignLogRoot updates a log root to include a signature from the crypto signer this object
-// was created with. Signatures use objecthash on a fixed JSON format of the root.
+// was created with. Signatures are over the root serialized as a verify.LogRootV1.
 func (s TrillianSigner) SignLogRoot(root trillian.SignedLogRoot) (trillian.DigitallySigned, error) {
-	objectHash := s.hashRoot(root)
-	signature, err := s.Sign(objectHash[:])
+	data, err := serializeLogRoot(root)
+	if err != nil {
+		glog.Warningf("Signer failed to serialize root: %v", err)
+		return trillian.DigitallySigned{}, err
+	}
+
+	signature, err := s.Sign(data)
 
 	if err != nil {
 		glog.Warningf("Signer failed to sign root: %v", err)
@@ -84,3 +102,23 @@ func (s TrillianSigner) SignLogRoot(root trillian.SignedLogRoot) (trillian.Digit
 
 	return signature, nil
 }
+
+// SignMapRoot returns a signature over a map root from the crypto signer this object was
+// created with. Signatures use objecthash on a fixed JSON format of the root, including its
+// mapper metadata.
+func (s TrillianSigner) SignMapRoot(root trillian.SignedMapRoot) (trillian.DigitallySigned, error) {
+	objectHash, err := hashMapRoot(root)
+	if err != nil {
+		glog.Warningf("Signer failed to marshal map root metadata: %v", err)
+		return trillian.DigitallySigned{}, err
+	}
+
+	signature, err := s.Sign(objectHash)
+
+	if err != nil {
+		glog.Warningf("Signer failed to sign map root: %v", err)
+		return trillian.DigitallySigned{}, err
+	}
+
+	return signature, nil
+}
@@ -89,7 +89,7 @@ func TestSignLogRootSignerFails(t *testing.T) {
 	mockSigner := NewMockSigner(ctrl)
 
 	mockSigner.EXPECT().Sign(gomock.Any(),
-		[]byte{0xe5, 0xb3, 0x18, 0x1a, 0xec, 0xc8, 0x64, 0xc6, 0x39, 0x6d, 0x83, 0x21, 0x7a, 0x18, 0x3, 0x9, 0xf5, 0xa0, 0x25, 0xde, 0xf7, 0x1b, 0xdb, 0x2d, 0xbe, 0x42, 0x8a, 0x4a, 0xab, 0xc1, 0xcd, 0x49},
+		[]byte{0xf6, 0xd7, 0xe1, 0xde, 0x5b, 0x63, 0x15, 0xce, 0x12, 0xa0, 0x8c, 0x63, 0xf6, 0xa4, 0xcd, 0x41, 0x62, 0xf1, 0x81, 0x4c, 0xfa, 0x74, 0xaa, 0xa4, 0x96, 0xaa, 0x64, 0xa8, 0xc3, 0x85, 0x48, 0x6b},
 		usesSHA256Hasher{}).Return([]byte{}, errors.New("signfail"))
 
 	logSigner := createTestSigner(t, mockSigner)
@@ -107,7 +107,7 @@ func TestSignLogRoot(t *testing.T) {
 	mockSigner := NewMockSigner(ctrl)
 
 	mockSigner.EXPECT().Sign(gomock.Any(),
-		[]byte{0xe5, 0xb3, 0x18, 0x1a, 0xec, 0xc8, 0x64, 0xc6, 0x39, 0x6d, 0x83, 0x21, 0x7a, 0x18, 0x3, 0x9, 0xf5, 0xa0, 0x25, 0xde, 0xf7, 0x1b, 0xdb, 0x2d, 0xbe, 0x42, 0x8a, 0x4a, 0xab, 0xc1, 0xcd, 0x49},
+		[]byte{0xf6, 0xd7, 0xe1, 0xde, 0x5b, 0x63, 0x15, 0xce, 0x12, 0xa0, 0x8c, 0x63, 0xf6, 0xa4, 0xcd, 0x41, 0x62, 0xf1, 0x81, 0x4c, 0xfa, 0x74, 0xaa, 0xa4, 0x96, 0xaa, 0x64, 0xa8, 0xc3, 0x85, 0x48, 0x6b},
 		usesSHA256Hasher{}).Return([]byte(result), nil)
 
 	logSigner := createTestSigner(t, mockSigner)
@@ -141,3 +141,24 @@ func createTestSigner(t *testing.T, mock *MockSigner) *TrillianSigner {
 
 	return NewTrillianSigner(hasher, trillian.SignatureAlgorithm_RSA, mock)
 }
+
+func TestSignerEd25519SignsDataDirectly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSigner := NewMockSigner(ctrl)
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte(message), crypto.Hash(0)).Return([]byte(result), nil)
+
+	sig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ED25519, mockSigner).Sign([]byte(message))
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+
+	if got, want := sig.SignatureAlgorithm, trillian.SignatureAlgorithm_ED25519; got != want {
+		t.Fatalf("Sig alg incorrect, got %v expected %v", got, want)
+	}
+	if got, want := []byte(result), sig.Signature; !bytes.Equal(got, want) {
+		t.Fatalf("Mismatched signature got %v expected %v", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/verify"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrVerificationFailed is returned when a signature doesn't match the data and public key.
+var ErrVerificationFailed = verify.ErrVerificationFailed
+
+// VerifySignature checks that sig is a signature over data made with the private key
+// matching pub, in the same way as TrillianSigner.Sign. The signature algorithm must be
+// the one used for keys of the same type as pub.
+func VerifySignature(pub crypto.PublicKey, data []byte, sig trillian.DigitallySigned) error {
+	sigAlgorithm, err := SignatureAlgorithmForKey(pub)
+	if err != nil {
+		return err
+	}
+
+	if sig.SignatureAlgorithm != sigAlgorithm {
+		return fmt.Errorf("signature algorithm %v does not match %v public key", sig.SignatureAlgorithm, sigAlgorithm)
+	}
+
+	// Ed25519 signs the data directly rather than a digest of it, so the hash doesn't matter
+	if _, ok := pub.(ed25519.PublicKey); !ok && sig.HashAlgorithm != trillian.HashAlgorithm_SHA256 {
+		return fmt.Errorf("unsupported hash algorithm: %v", sig.HashAlgorithm)
+	}
+
+	return verify.Signature(pub, data, sig.Signature)
+}
+
+// VerifySignedLogRoot checks the signature of a log root made by TrillianSigner.SignLogRoot.
+func VerifySignedLogRoot(pub crypto.PublicKey, root trillian.SignedLogRoot) error {
+	if root.Signature == nil {
+		return errors.New("log root is not signed")
+	}
+
+	data, err := serializeLogRoot(root)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignature(pub, data, *root.Signature)
+}
+
+// VerifyCosignature checks a witness's cosignature over a log root. Witnesses cosign the same
+// data as the log, so a cosignature can be made with TrillianSigner.SignLogRoot.
+func VerifyCosignature(pub crypto.PublicKey, root trillian.SignedLogRoot, cosignature trillian.Cosignature) error {
+	if cosignature.Signature == nil {
+		return errors.New("cosignature has no signature")
+	}
+
+	data, err := serializeLogRoot(root)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignature(pub, data, *cosignature.Signature)
+}
+
+// VerifySignedMapRoot checks the signature of a map root made by TrillianSigner.SignMapRoot.
+func VerifySignedMapRoot(pub crypto.PublicKey, root trillian.SignedMapRoot) error {
+	if root.Signature == nil {
+		return errors.New("map root is not signed")
+	}
+
+	objectHash, err := hashMapRoot(root)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignature(pub, objectHash, *root.Signature)
+}
+
+// VerifySignedLogRootWithKeys checks the signature of a log root against each of pubs in
+// turn, and succeeds if one of them verifies it. While a log's key is being rotated its
+// roots are signed by either the old or the new key, so both should be passed.
+func VerifySignedLogRootWithKeys(pubs []crypto.PublicKey, root trillian.SignedLogRoot) error {
+	return verifyWithAnyKey(pubs, func(pub crypto.PublicKey) error {
+		return VerifySignedLogRoot(pub, root)
+	})
+}
+
+// VerifySignedMapRootWithKeys checks the signature of a map root against each of pubs in
+// turn, and succeeds if one of them verifies it.
+func VerifySignedMapRootWithKeys(pubs []crypto.PublicKey, root trillian.SignedMapRoot) error {
+	return verifyWithAnyKey(pubs, func(pub crypto.PublicKey) error {
+		return VerifySignedMapRoot(pub, root)
+	})
+}
+
+// verifyWithAnyKey returns nil if verify succeeds for one of pubs, otherwise the first error.
+func verifyWithAnyKey(pubs []crypto.PublicKey, verify func(crypto.PublicKey) error) error {
+	if len(pubs) == 0 {
+		return errors.New("no public keys to verify with")
+	}
+
+	var firstErr error
+	for _, pub := range pubs {
+		err := verify(pub)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
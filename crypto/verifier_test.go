@@ -0,0 +1,258 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/crypto/ed25519"
+)
+
+func testSigners(t *testing.T) map[trillian.SignatureAlgorithm]crypto.Signer {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	return map[trillian.SignatureAlgorithm]crypto.Signer{
+		trillian.SignatureAlgorithm_ECDSA:   ecdsaKey,
+		trillian.SignatureAlgorithm_RSA:     rsaKey,
+		trillian.SignatureAlgorithm_ED25519: ed25519Key,
+	}
+}
+
+func TestSignatureAlgorithmForKey(t *testing.T) {
+	for want, signer := range testSigners(t) {
+		got, err := SignatureAlgorithmForKey(signer.Public())
+		if err != nil || got != want {
+			t.Errorf("SignatureAlgorithmForKey(%T) = %v, %v, want %v", signer.Public(), got, err, want)
+		}
+	}
+
+	if _, err := SignatureAlgorithmForKey("not a key"); err == nil {
+		t.Error("SignatureAlgorithmForKey() accepted an unsupported key")
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for sigAlgorithm, signer := range testSigners(t) {
+		s := NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer)
+
+		sig, err := s.Sign([]byte(message))
+		if err != nil {
+			t.Fatalf("%v: failed to sign: %v", sigAlgorithm, err)
+		}
+
+		if err := VerifySignature(signer.Public(), []byte(message), sig); err != nil {
+			t.Errorf("%v: VerifySignature() = %v", sigAlgorithm, err)
+		}
+
+		if err := VerifySignature(signer.Public(), []byte("other message"), sig); err != ErrVerificationFailed {
+			t.Errorf("%v: VerifySignature() of different data = %v, want %v", sigAlgorithm, err, ErrVerificationFailed)
+		}
+
+		tampered := sig
+		tampered.Signature = append([]byte{}, sig.Signature...)
+		tampered.Signature[len(tampered.Signature)-1] ^= 1
+		if err := VerifySignature(signer.Public(), []byte(message), tampered); err != ErrVerificationFailed {
+			t.Errorf("%v: VerifySignature() of tampered signature = %v, want %v", sigAlgorithm, err, ErrVerificationFailed)
+		}
+	}
+}
+
+func TestVerifyWrongAlgorithm(t *testing.T) {
+	signers := testSigners(t)
+	signer := signers[trillian.SignatureAlgorithm_ECDSA]
+
+	sig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer).Sign([]byte(message))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	sig.SignatureAlgorithm = trillian.SignatureAlgorithm_ED25519
+	if err := VerifySignature(signer.Public(), []byte(message), sig); err == nil {
+		t.Error("VerifySignature() accepted a signature with the wrong algorithm for the key")
+	}
+}
+
+func TestSignAndVerifyLogRoot(t *testing.T) {
+	for sigAlgorithm, signer := range testSigners(t) {
+		root := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5}
+
+		sig, err := NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer).SignLogRoot(root)
+		if err != nil {
+			t.Fatalf("%v: failed to sign log root: %v", sigAlgorithm, err)
+		}
+		root.Signature = &sig
+
+		if err := VerifySignedLogRoot(signer.Public(), root); err != nil {
+			t.Errorf("%v: VerifySignedLogRoot() = %v", sigAlgorithm, err)
+		}
+
+		root.TreeSize++
+		if err := VerifySignedLogRoot(signer.Public(), root); err != ErrVerificationFailed {
+			t.Errorf("%v: VerifySignedLogRoot() of modified root = %v, want %v", sigAlgorithm, err, ErrVerificationFailed)
+		}
+	}
+}
+
+func TestLogRootSignatureCoversMetadata(t *testing.T) {
+	signer := testSigners(t)[trillian.SignatureAlgorithm_ECDSA]
+	root := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5, TreeRevision: 2, Metadata: []byte("shard 1")}
+
+	sig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer).SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("failed to sign log root: %v", err)
+	}
+	root.Signature = &sig
+
+	if err := VerifySignedLogRoot(signer.Public(), root); err != nil {
+		t.Errorf("VerifySignedLogRoot() = %v", err)
+	}
+
+	for _, metadata := range [][]byte{nil, []byte("shard 2")} {
+		modified := root
+		modified.Metadata = metadata
+		if err := VerifySignedLogRoot(signer.Public(), modified); err != ErrVerificationFailed {
+			t.Errorf("VerifySignedLogRoot() of root with metadata %q = %v, want %v", metadata, err, ErrVerificationFailed)
+		}
+	}
+}
+
+func TestSignAndVerifyCosignature(t *testing.T) {
+	signers := testSigners(t)
+	logSigner := signers[trillian.SignatureAlgorithm_ECDSA]
+
+	for sigAlgorithm, witness := range signers {
+		root := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5}
+
+		logSig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, logSigner).SignLogRoot(root)
+		if err != nil {
+			t.Fatalf("failed to sign log root: %v", err)
+		}
+		root.Signature = &logSig
+
+		sig, err := NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, witness).SignLogRoot(root)
+		if err != nil {
+			t.Fatalf("%v: failed to cosign log root: %v", sigAlgorithm, err)
+		}
+		cosignature := trillian.Cosignature{WitnessId: "witness", Signature: &sig}
+
+		if err := VerifyCosignature(witness.Public(), root, cosignature); err != nil {
+			t.Errorf("%v: VerifyCosignature() = %v", sigAlgorithm, err)
+		}
+
+		root.TreeSize++
+		if err := VerifyCosignature(witness.Public(), root, cosignature); err != ErrVerificationFailed {
+			t.Errorf("%v: VerifyCosignature() of modified root = %v, want %v", sigAlgorithm, err, ErrVerificationFailed)
+		}
+	}
+
+	if err := VerifyCosignature(logSigner.Public(), trillian.SignedLogRoot{}, trillian.Cosignature{WitnessId: "witness"}); err == nil {
+		t.Error("VerifyCosignature() accepted a cosignature with no signature")
+	}
+}
+
+func TestSignAndVerifyMapRoot(t *testing.T) {
+	for sigAlgorithm, signer := range testSigners(t) {
+		root := trillian.SignedMapRoot{TimestampNanos: 1000, RootHash: []byte("root"), MapId: []byte("map"), MapRevision: 3}
+
+		sig, err := NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer).SignMapRoot(root)
+		if err != nil {
+			t.Fatalf("%v: failed to sign map root: %v", sigAlgorithm, err)
+		}
+		root.Signature = &sig
+
+		if err := VerifySignedMapRoot(signer.Public(), root); err != nil {
+			t.Errorf("%v: VerifySignedMapRoot() = %v", sigAlgorithm, err)
+		}
+
+		root.MapRevision++
+		if err := VerifySignedMapRoot(signer.Public(), root); err != ErrVerificationFailed {
+			t.Errorf("%v: VerifySignedMapRoot() of modified root = %v, want %v", sigAlgorithm, err, ErrVerificationFailed)
+		}
+	}
+}
+
+func TestMapRootSignatureCoversMetadata(t *testing.T) {
+	signer := testSigners(t)[trillian.SignatureAlgorithm_ECDSA]
+	root := trillian.SignedMapRoot{TimestampNanos: 1000, RootHash: []byte("root"), MapId: []byte("map"), MapRevision: 3,
+		Metadata: &trillian.MapperMetadata{SourceLogId: []byte("log"), HighestFullyCompletedSeq: 10}}
+
+	sig, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer).SignMapRoot(root)
+	if err != nil {
+		t.Fatalf("failed to sign map root: %v", err)
+	}
+	root.Signature = &sig
+
+	if err := VerifySignedMapRoot(signer.Public(), root); err != nil {
+		t.Errorf("VerifySignedMapRoot() = %v", err)
+	}
+
+	root.Metadata = &trillian.MapperMetadata{SourceLogId: []byte("log"), HighestFullyCompletedSeq: 11}
+	if err := VerifySignedMapRoot(signer.Public(), root); err != ErrVerificationFailed {
+		t.Errorf("VerifySignedMapRoot() of root with modified metadata = %v, want %v", err, ErrVerificationFailed)
+	}
+}
+
+func TestVerifyUnsignedRoots(t *testing.T) {
+	signer := testSigners(t)[trillian.SignatureAlgorithm_ED25519]
+
+	if err := VerifySignedLogRoot(signer.Public(), trillian.SignedLogRoot{}); err == nil {
+		t.Error("VerifySignedLogRoot() accepted a root with no signature")
+	}
+	if err := VerifySignedMapRoot(signer.Public(), trillian.SignedMapRoot{}); err == nil {
+		t.Error("VerifySignedMapRoot() accepted a root with no signature")
+	}
+}
+
+func TestVerifyRootsWithKeys(t *testing.T) {
+	signers := testSigners(t)
+	signer := signers[trillian.SignatureAlgorithm_ECDSA]
+	other := signers[trillian.SignatureAlgorithm_ED25519].Public()
+	s := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer)
+
+	logRoot := trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root"), TreeSize: 5}
+	logSig, err := s.SignLogRoot(logRoot)
+	if err != nil {
+		t.Fatalf("failed to sign log root: %v", err)
+	}
+	logRoot.Signature = &logSig
+
+	mapRoot := trillian.SignedMapRoot{TimestampNanos: 1000, RootHash: []byte("root"), MapId: []byte("map"), MapRevision: 7}
+	mapSig, err := s.SignMapRoot(mapRoot)
+	if err != nil {
+		t.Fatalf("failed to sign map root: %v", err)
+	}
+	mapRoot.Signature = &mapSig
+
+	for _, test := range []struct {
+		pubs    []crypto.PublicKey
+		wantErr bool
+	}{
+		{[]crypto.PublicKey{signer.Public()}, false},
+		{[]crypto.PublicKey{other, signer.Public()}, false},
+		{[]crypto.PublicKey{signer.Public(), other}, false},
+		{[]crypto.PublicKey{other}, true},
+		{nil, true},
+	} {
+		if err := VerifySignedLogRootWithKeys(test.pubs, logRoot); (err != nil) != test.wantErr {
+			t.Errorf("VerifySignedLogRootWithKeys() with %d keys = %v, want error %v", len(test.pubs), err, test.wantErr)
+		}
+		if err := VerifySignedMapRootWithKeys(test.pubs, mapRoot); (err != nil) != test.wantErr {
+			t.Errorf("VerifySignedMapRootWithKeys() with %d keys = %v, want error %v", len(test.pubs), err, test.wantErr)
+		}
+	}
+}
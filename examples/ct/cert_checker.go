@@ -13,6 +13,10 @@ import (
 // OID of the non-critical extension used to mark pre-certificates, defined in RFC 6962
 var ctPoisonExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
 
+// OID of the extended key usage that marks a Precertificate Signing Certificate, which a CA
+// can use to issue pre-certificates on its behalf, defined in RFC 6962
+var ctPrecertSigningEKUOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 4}
+
 // Byte representation of ASN.1 NULL.
 var asn1NullBytes = []byte{0x05, 0x00}
 
@@ -33,6 +37,63 @@ func IsPrecertificate(cert *x509.Certificate) (bool, error) {
 	return false, nil
 }
 
+// IsPrecertificateSigningCert tests if a certificate is a Precertificate Signing Certificate
+// as defined in CT.
+func IsPrecertificateSigningCert(cert *x509.Certificate) bool {
+	for _, eku := range cert.UnknownExtKeyUsage {
+		if ctPrecertSigningEKUOID.Equal(eku) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrecertIssuer works out who will issue the final certificate for the pre-certificate at the
+// start of a path returned by ValidateChain. It returns the issuing CA and, if the CA used a
+// Precertificate Signing Certificate to issue the pre-certificate, the signing cert. The path
+// doesn't include the root so the issuer might be one of the trusted roots.
+func PrecertIssuer(validPath []*x509.Certificate, trustedRoots PEMCertPool) (*x509.Certificate, *x509.Certificate, error) {
+	issuer, err := issuerInPath(validPath, 0, trustedRoots)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !IsPrecertificateSigningCert(issuer) {
+		return issuer, nil, nil
+	}
+
+	// The signing cert must itself be issued by the CA so it can't be a root
+	if len(validPath) < 2 {
+		return nil, nil, errors.New("precert signing cert cannot be a trusted root")
+	}
+
+	caCert, err := issuerInPath(validPath, 1, trustedRoots)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return caCert, issuer, nil
+}
+
+// issuerInPath returns the certificate that issued the one at index i of a validated path,
+// which is either the next one in the path or, for the last, one of the trusted roots.
+func issuerInPath(validPath []*x509.Certificate, i int, trustedRoots PEMCertPool) (*x509.Certificate, error) {
+	if i+1 < len(validPath) {
+		return validPath[i+1], nil
+	}
+
+	for _, root := range trustedRoots.RawCertificates() {
+		if validPath[i].CheckSignatureFrom(root) == nil {
+			return root, nil
+		}
+	}
+
+	return nil, errors.New("no trusted root found that issued the end of the chain")
+}
+
 // ValidateChain takes the certificate chain as it was parsed from a JSON request. Ensures all
 // elements in the chain decode as X.509 certificates. Ensures that there is a valid path from the
 // end entity certificate in the chain to a trusted root cert, possibly using the intermediates
@@ -1,6 +1,7 @@
 package ct
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/pem"
 	"testing"
@@ -160,6 +161,98 @@ func TestCertCheckerValidChainAccepted(t *testing.T) {
 	}
 }
 
+func TestIsPrecertificateSigningCert(t *testing.T) {
+	if !IsPrecertificateSigningCert(pemToCert(t, testonly.PrecertSigningCertPEM)) {
+		t.Fatal("Precert signing cert not recognized")
+	}
+	if IsPrecertificateSigningCert(pemToCert(t, testonly.PrecertIssuerCACertPEM)) {
+		t.Fatal("CA cert misclassified as precert signing cert")
+	}
+}
+
+func TestPrecertIssuerIsTrustedRoot(t *testing.T) {
+	// The precert is issued directly by the root, which isn't in the path
+	jsonChain := pemsToJsonChain(t, []string{testonly.PrecertPEMValid})
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.CACertPEM)) {
+		t.Fatal("failed to load root")
+	}
+
+	validPath, err := ValidateChain(jsonChain, *trustedRoots)
+
+	if err != nil {
+		t.Fatalf("unexpected error verifying precert chain %v", err)
+	}
+
+	issuer, signingCert, err := PrecertIssuer(validPath, *trustedRoots)
+
+	if err != nil {
+		t.Fatalf("unexpected error finding precert issuer %v", err)
+	}
+	if got, want := issuer.Raw, trustedRoots.RawCertificates()[0].Raw; !bytes.Equal(got, want) {
+		t.Fatal("precert issuer is not the trusted root")
+	}
+	if signingCert != nil {
+		t.Fatalf("got signing cert %v for precert issued by CA", signingCert.Subject)
+	}
+}
+
+func TestPrecertIssuerSigningCert(t *testing.T) {
+	// The precert is issued by a signing cert on behalf of the root
+	jsonChain := pemsToJsonChain(t, []string{testonly.PrecertSignedBySigningCertPEM, testonly.PrecertSigningCertPEM})
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.PrecertIssuerCACertPEM)) {
+		t.Fatal("failed to load root")
+	}
+
+	validPath, err := ValidateChain(jsonChain, *trustedRoots)
+
+	if err != nil {
+		t.Fatalf("unexpected error verifying precert chain %v", err)
+	}
+
+	issuer, signingCert, err := PrecertIssuer(validPath, *trustedRoots)
+
+	if err != nil {
+		t.Fatalf("unexpected error finding precert issuer %v", err)
+	}
+	if got, want := issuer.Raw, trustedRoots.RawCertificates()[0].Raw; !bytes.Equal(got, want) {
+		t.Fatal("precert issuer is not the CA that owns the signing cert")
+	}
+	if signingCert == nil || !bytes.Equal(signingCert.Raw, validPath[1].Raw) {
+		t.Fatal("precert signing cert not returned")
+	}
+}
+
+func TestPrecertIssuerSigningCertRootRejected(t *testing.T) {
+	// A signing cert can only act for a CA so it can't be trusted on its own
+	validPath := []*x509.Certificate{pemToCert(t, testonly.PrecertSignedBySigningCertPEM)}
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.PrecertSigningCertPEM)) {
+		t.Fatal("failed to load signing cert")
+	}
+
+	if _, _, err := PrecertIssuer(validPath, *trustedRoots); err == nil {
+		t.Fatal("incorrectly accepted precert signing cert as root")
+	}
+}
+
+func TestPrecertIssuerNotFound(t *testing.T) {
+	validPath := []*x509.Certificate{pemToCert(t, testonly.PrecertPEMValid)}
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.FakeCACertPem)) {
+		t.Fatal("failed to load fake root")
+	}
+
+	if _, _, err := PrecertIssuer(validPath, *trustedRoots); err == nil {
+		t.Fatal("found issuer for precert in unrelated roots")
+	}
+}
+
 // Builds a chain of base64 encoded certs as if they'd been submitted to a handler.
 // Note: ordering is important
 func pemsToJsonChain(t *testing.T, pemCerts []string) []string {
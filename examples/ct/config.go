@@ -0,0 +1,238 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/crypto"
+)
+
+// LoadLogConfigFile reads a LogMultiConfig in protobuf text format from a file and checks
+// that it's usable
+func LoadLogConfigFile(path string) (*LogMultiConfig, error) {
+	cfg, _, err := readLogConfigFile(path)
+	return cfg, err
+}
+
+// readLogConfigFile does the work of LoadLogConfigFile. It also returns a hash of the config
+// and all the files it refers to, so that a change to any of them can be spotted.
+func readLogConfigFile(path string) (*LogMultiConfig, [sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, hash, err
+	}
+
+	cfg, err := ParseLogMultiConfig(data)
+
+	if err != nil {
+		return nil, hash, err
+	}
+
+	hasher := sha256.New()
+	hasher.Write(data)
+
+	for _, logCfg := range cfg.Logs {
+		for _, file := range []string{logCfg.RootsPemFile, logCfg.PrivateKeyFile, logCfg.PublicKeyFile} {
+			fileData, err := ioutil.ReadFile(file)
+
+			if err != nil {
+				return nil, hash, err
+			}
+
+			fileHash := sha256.Sum256(fileData)
+			hasher.Write(fileHash[:])
+		}
+	}
+
+	copy(hash[:], hasher.Sum(nil))
+	return cfg, hash, nil
+}
+
+// ParseLogMultiConfig parses a LogMultiConfig in protobuf text format and checks that it's
+// usable
+func ParseLogMultiConfig(data []byte) (*LogMultiConfig, error) {
+	var cfg LogMultiConfig
+
+	if err := proto.UnmarshalText(string(data), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse log config: %v", err)
+	}
+
+	if err := ValidateLogMultiConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// ValidateLogMultiConfig checks that a config describes at least one log, that the logs and
+// shard groups have distinct path prefixes and that each log's settings are valid. The files
+// it refers to are not read.
+func ValidateLogMultiConfig(cfg *LogMultiConfig) error {
+	if len(cfg.Logs) == 0 {
+		return errors.New("log config has no logs")
+	}
+
+	prefixes := make(map[string]bool)
+	groups := make(map[string]bool)
+
+	for _, logCfg := range cfg.Logs {
+		if !validPathName(logCfg.Prefix) {
+			return fmt.Errorf("invalid log prefix: %q", logCfg.Prefix)
+		}
+
+		if prefixes[logCfg.Prefix] {
+			return fmt.Errorf("duplicate log prefix: %s", logCfg.Prefix)
+		}
+
+		prefixes[logCfg.Prefix] = true
+
+		if err := validateLogConfig(logCfg); err != nil {
+			return fmt.Errorf("log %s: %v", logCfg.Prefix, err)
+		}
+
+		if len(logCfg.ShardGroup) > 0 {
+			groups[logCfg.ShardGroup] = true
+		}
+	}
+
+	// The shard groups are served alongside the logs
+	for group := range groups {
+		if !validPathName(group) || prefixes[group] {
+			return fmt.Errorf("invalid shard group: %q", group)
+		}
+	}
+
+	return nil
+}
+
+// validPathName returns true if a name can be used as the first element of a path
+func validPathName(name string) bool {
+	return len(name) > 0 && !strings.Contains(name, "/")
+}
+
+// validateLogConfig checks the settings of one log
+func validateLogConfig(cfg *LogConfig) error {
+	if cfg.TreeId == 0 {
+		return errors.New("tree_id must be set")
+	}
+
+	if len(cfg.RootsPemFile) == 0 || len(cfg.PrivateKeyFile) == 0 || len(cfg.PublicKeyFile) == 0 {
+		return errors.New("roots_pem_file, private_key_file and public_key_file must be set")
+	}
+
+	if cfg.SubmissionsPerSecond < 0 || (cfg.SubmissionsPerSecond > 0 && cfg.SubmissionBurst <= 0) {
+		return errors.New("submissions_per_second must be >= 0, and submission_burst > 0 if it's set")
+	}
+
+	_, err := policyFromConfig(cfg)
+	return err
+}
+
+// policyFromConfig returns the validation policy set by a log's config
+func policyFromConfig(cfg *LogConfig) (*ValidationPolicy, error) {
+	var policy ValidationPolicy
+	var err error
+
+	if policy.ExtKeyUsages, err = ParseExtKeyUsages(strings.Join(cfg.AcceptedExtKeyUsages, ",")); err != nil {
+		return nil, err
+	}
+
+	if policy.RejectedIssuers, err = ParseFingerprints(strings.Join(cfg.RejectedIssuers, ",")); err != nil {
+		return nil, err
+	}
+
+	if policy.NotAfterStart, err = parseOptionalTime(cfg.NotAfterStart); err != nil {
+		return nil, fmt.Errorf("invalid not_after_start: %v", err)
+	}
+
+	if policy.NotAfterLimit, err = parseOptionalTime(cfg.NotAfterLimit); err != nil {
+		return nil, fmt.Errorf("invalid not_after_limit: %v", err)
+	}
+
+	if !policy.NotAfterStart.IsZero() && !policy.NotAfterLimit.IsZero() && !policy.NotAfterStart.Before(policy.NotAfterLimit) {
+		return nil, errors.New("not_after_start must be before not_after_limit")
+	}
+
+	if cfg.MaxChainLength < 0 {
+		return nil, errors.New("max_chain_length must be >= 0")
+	}
+
+	policy.MaxChainLength = int(cfg.MaxChainLength)
+	return &policy, nil
+}
+
+// parseOptionalTime parses a time in RFC 3339 format, an empty string gives the zero time
+func parseOptionalTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// loadTrustedRoots reads a log's trusted roots from a PEM file. Unless lax is set every cert
+// in the file must parse.
+func loadTrustedRoots(path string, lax bool) (*PEMCertPool, error) {
+	// The set of root data should never be particularly large and we have to keep it in memory
+	// anyway to validate submissions
+	rootData, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trustedRoots := NewPEMCertPool()
+
+	if lax {
+		report := trustedRoots.AppendCertsFromPEMLax(rootData)
+
+		for _, skipped := range report.Skipped {
+			glog.Warningf("Skipped trusted root %d in %s: %v", skipped.Index, path, skipped.Err)
+		}
+
+		glog.Infof("Loaded %d trusted roots from %s, skipped %d", report.Added, path, len(report.Skipped))
+	} else if !trustedRoots.AppendCertsFromPEM(rootData) {
+		return nil, fmt.Errorf("failed to load trusted roots from %s", path)
+	}
+
+	if len(trustedRoots.Subjects()) == 0 {
+		return nil, fmt.Errorf("no trusted roots in %s", path)
+	}
+
+	return trustedRoots, nil
+}
+
+// loadLogKeys reads a log's keys from PEM files
+func loadLogKeys(privateKeyFile, privateKeyPassword, publicKeyFile string) (crypto.KeyManager, error) {
+	logKeyManager := crypto.NewPEMKeyManager()
+
+	privateKeyPEM, err := ioutil.ReadFile(privateKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := logKeyManager.LoadPrivateKey(string(privateKeyPEM), privateKeyPassword); err != nil {
+		return nil, fmt.Errorf("failed to load private key: %v", err)
+	}
+
+	publicKeyPEM, err := ioutil.ReadFile(publicKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := logKeyManager.LoadPublicKey(string(publicKeyPEM)); err != nil {
+		return nil, fmt.Errorf("failed to load public key: %v", err)
+	}
+
+	return logKeyManager, nil
+}
@@ -0,0 +1,160 @@
+package ct
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// writeLogFiles writes the roots and keys for test logs into a temporary directory, which
+// the caller must remove
+func writeLogFiles(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ctconfig")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	for name, contents := range map[string]string{"roots.pem": testonly.FakeCACertPem, "private.pem": testonly.CTLogPrivateKeyPEM, "public.pem": testonly.CTLogPublicKeyPEM} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+// logConfigText returns the text format config of a log using the files written by
+// writeLogFiles, with extra settings appended
+func logConfigText(dir, prefix string, treeID int64, extra string) string {
+	return fmt.Sprintf(`logs {
+  tree_id: %d
+  prefix: %q
+  roots_pem_file: %q
+  private_key_file: %q
+  private_key_password: %q
+  public_key_file: %q
+  %s
+}
+`, treeID, prefix, filepath.Join(dir, "roots.pem"), filepath.Join(dir, "private.pem"), testonly.CTLogKeyPassword, filepath.Join(dir, "public.pem"), extra)
+}
+
+func TestParseLogMultiConfig(t *testing.T) {
+	cfg, err := ParseLogMultiConfig([]byte(logConfigText("/tmp", "pilot", 1, `submissions_per_second: 10
+  submission_burst: 100
+  accepted_ext_key_usages: "serverAuth"
+  not_after_start: "2017-01-01T00:00:00Z"`) + logConfigText("/tmp", "aviator", 2, "")))
+
+	if err != nil {
+		t.Fatalf("Failed to parse valid config: %v", err)
+	}
+
+	if got, want := len(cfg.Logs), 2; got != want {
+		t.Fatalf("Got %d logs, expected %d", got, want)
+	}
+
+	pilot := cfg.Logs[0]
+
+	if pilot.Prefix != "pilot" || pilot.TreeId != 1 || pilot.SubmissionsPerSecond != 10 || pilot.SubmissionBurst != 100 {
+		t.Fatalf("Got log config %v, expected the pilot settings", pilot)
+	}
+}
+
+func TestParseLogMultiConfigInvalid(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		reason string
+	}{
+		{"", "no logs"},
+		{"logs { tree_id: ", "bad syntax"},
+		{"logs { unknown_field: 1 }", "unknown field"},
+		{logConfigText("/tmp", "", 1, ""), "empty prefix"},
+		{logConfigText("/tmp", "a/b", 1, ""), "prefix with slash"},
+		{logConfigText("/tmp", "a", 1, "") + logConfigText("/tmp", "a", 2, ""), "duplicate prefix"},
+		{logConfigText("/tmp", "a", 0, ""), "no tree id"},
+		{`logs { tree_id: 1 prefix: "a" }`, "no files"},
+		{logConfigText("/tmp", "a", 1, "submissions_per_second: -1"), "negative rate"},
+		{logConfigText("/tmp", "a", 1, "submissions_per_second: 1"), "rate without burst"},
+		{logConfigText("/tmp", "a", 1, `accepted_ext_key_usages: "serverauth"`), "unknown usage"},
+		{logConfigText("/tmp", "a", 1, `rejected_issuers: "abcd"`), "bad fingerprint"},
+		{logConfigText("/tmp", "a", 1, `not_after_start: "2017"`), "bad time"},
+		{logConfigText("/tmp", "a", 1, `not_after_start: "2018-01-01T00:00:00Z" not_after_limit: "2017-01-01T00:00:00Z"`), "backwards range"},
+		{logConfigText("/tmp", "a", 1, "max_chain_length: -1"), "negative chain length"},
+		{logConfigText("/tmp", "a", 1, `shard_group: "b/c"`), "shard group with slash"},
+		{logConfigText("/tmp", "a", 1, `shard_group: "b"`) + logConfigText("/tmp", "b", 2, ""), "shard group matches prefix"},
+	} {
+		if _, err := ParseLogMultiConfig([]byte(test.config)); err == nil {
+			t.Errorf("Incorrectly parsed config with %s", test.reason)
+		}
+	}
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+	policy, err := policyFromConfig(&LogConfig{
+		AcceptedExtKeyUsages: []string{"serverAuth", "clientAuth"},
+		MaxChainLength:       4,
+		NotAfterStart:        "2017-01-01T00:00:00Z",
+		NotAfterLimit:        "2018-01-01T00:00:00Z",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+
+	if len(policy.ExtKeyUsages) != 2 || policy.ExtKeyUsages[0] != x509.ExtKeyUsageServerAuth || policy.ExtKeyUsages[1] != x509.ExtKeyUsageClientAuth {
+		t.Errorf("Got usages %v, expected serverAuth and clientAuth", policy.ExtKeyUsages)
+	}
+	if got, want := policy.MaxChainLength, 4; got != want {
+		t.Errorf("Got max chain length %d, expected %d", got, want)
+	}
+	if got, want := policy.NotAfterStart, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Got NotAfterStart %v, expected %v", got, want)
+	}
+	if got, want := policy.NotAfterLimit, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Got NotAfterLimit %v, expected %v", got, want)
+	}
+	if len(policy.RejectedIssuers) != 0 {
+		t.Errorf("Got rejected issuers %v, expected none", policy.RejectedIssuers)
+	}
+}
+
+func TestLoadLogConfigFile(t *testing.T) {
+	dir := writeLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+
+	if err := ioutil.WriteFile(path, []byte(logConfigText(dir, "pilot", 1, "")), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, hash, err := readLogConfigFile(path)
+
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if got, want := cfg.Logs[0].Prefix, "pilot"; got != want {
+		t.Fatalf("Got log %s, expected %s", got, want)
+	}
+
+	// Changing a file the config refers to should change the hash
+	if err := ioutil.WriteFile(filepath.Join(dir, "roots.pem"), []byte(testonly.CACertPEM), 0644); err != nil {
+		t.Fatalf("Failed to update roots: %v", err)
+	}
+
+	if _, updatedHash, err := readLogConfigFile(path); err != nil || updatedHash == hash {
+		t.Fatalf("Got hash %x (%v) after roots changed, expected it to differ from %x", updatedHash, err, hash)
+	}
+
+	os.Remove(filepath.Join(dir, "public.pem"))
+
+	if _, err := LoadLogConfigFile(path); err == nil {
+		t.Fatal("Incorrectly loaded config with missing key file")
+	}
+}
@@ -0,0 +1,126 @@
+// Code generated by protoc-gen-go.
+// source: github.com/google/trillian/examples/ct/ct_config.proto
+// DO NOT EDIT!
+
+/*
+Package ct is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/google/trillian/examples/ct/ct_config.proto
+
+It has these top-level messages:
+	LogConfig
+	LogMultiConfig
+*/
+package ct
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// LogConfig describes one of the logs served by a CT frontend.
+type LogConfig struct {
+	// The backend tree that holds the log's entries.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// The path the log is served under, e.g. "pilot" serves it at /pilot/ct/v1/.
+	Prefix string `protobuf:"bytes,2,opt,name=prefix" json:"prefix,omitempty"`
+	// File containing one or more concatenated trusted root certs in PEM format.
+	RootsPemFile string `protobuf:"bytes,3,opt,name=roots_pem_file,json=rootsPemFile" json:"roots_pem_file,omitempty"`
+	// Skip trusted root certs that fail to parse instead of rejecting the config.
+	LaxRootParsing bool `protobuf:"varint,4,opt,name=lax_root_parsing,json=laxRootParsing" json:"lax_root_parsing,omitempty"`
+	// PEM files holding the log's keys, the private key is encrypted with the password.
+	PrivateKeyFile     string `protobuf:"bytes,5,opt,name=private_key_file,json=privateKeyFile" json:"private_key_file,omitempty"`
+	PrivateKeyPassword string `protobuf:"bytes,6,opt,name=private_key_password,json=privateKeyPassword" json:"private_key_password,omitempty"`
+	PublicKeyFile      string `protobuf:"bytes,7,opt,name=public_key_file,json=publicKeyFile" json:"public_key_file,omitempty"`
+	// The rate add-chain and add-pre-chain requests are limited to, with bursts of up to
+	// submission_burst requests. Zero means no limit.
+	SubmissionsPerSecond float64 `protobuf:"fixed64,8,opt,name=submissions_per_second,json=submissionsPerSecond" json:"submissions_per_second,omitempty"`
+	SubmissionBurst      int64   `protobuf:"varint,9,opt,name=submission_burst,json=submissionBurst" json:"submission_burst,omitempty"`
+	// The validation policy chains must meet. Extended key usages are named as in
+	// ParseExtKeyUsages, issuers are hex SHA-256 fingerprints of their certs and times are
+	// in RFC 3339 format. Empty values mean no restriction.
+	AcceptedExtKeyUsages []string `protobuf:"bytes,10,rep,name=accepted_ext_key_usages,json=acceptedExtKeyUsages" json:"accepted_ext_key_usages,omitempty"`
+	MaxChainLength       int32    `protobuf:"varint,11,opt,name=max_chain_length,json=maxChainLength" json:"max_chain_length,omitempty"`
+	RejectedIssuers      []string `protobuf:"bytes,12,rep,name=rejected_issuers,json=rejectedIssuers" json:"rejected_issuers,omitempty"`
+	NotAfterStart        string   `protobuf:"bytes,13,opt,name=not_after_start,json=notAfterStart" json:"not_after_start,omitempty"`
+	NotAfterLimit        string   `protobuf:"bytes,14,opt,name=not_after_limit,json=notAfterLimit" json:"not_after_limit,omitempty"`
+	// Logs with the same shard group are temporal shards of one log. Chains submitted under
+	// /<shard_group>/ct/v1/ are sent to the shard whose NotAfter range covers their leaf.
+	ShardGroup string `protobuf:"bytes,15,opt,name=shard_group,json=shardGroup" json:"shard_group,omitempty"`
+}
+
+func (m *LogConfig) Reset()                    { *m = LogConfig{} }
+func (m *LogConfig) String() string            { return proto.CompactTextString(m) }
+func (*LogConfig) ProtoMessage()               {}
+func (*LogConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// LogMultiConfig lists the logs served by a CT frontend.
+type LogMultiConfig struct {
+	Logs []*LogConfig `protobuf:"bytes,1,rep,name=logs" json:"logs,omitempty"`
+}
+
+func (m *LogMultiConfig) Reset()                    { *m = LogMultiConfig{} }
+func (m *LogMultiConfig) String() string            { return proto.CompactTextString(m) }
+func (*LogMultiConfig) ProtoMessage()               {}
+func (*LogMultiConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *LogMultiConfig) GetLogs() []*LogConfig {
+	if m != nil {
+		return m.Logs
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*LogConfig)(nil), "ct.LogConfig")
+	proto.RegisterType((*LogMultiConfig)(nil), "ct.LogMultiConfig")
+}
+
+func init() {
+	proto.RegisterFile("github.com/google/trillian/examples/ct/ct_config.proto", fileDescriptor0)
+}
+
+var fileDescriptor0 = []byte{
+	// 470 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x92, 0xd1, 0x6e, 0xd3, 0x30,
+	0x14, 0x86, 0x95, 0xb5, 0xeb, 0x56, 0x77, 0x4d, 0xa7, 0xa8, 0xda, 0x7c, 0x47, 0x98, 0x10, 0x0a,
+	0x37, 0x2d, 0x62, 0xc0, 0x3d, 0x4c, 0x80, 0xa6, 0x15, 0xa9, 0xca, 0xc4, 0xb5, 0xe5, 0x3a, 0xa7,
+	0xae, 0xc1, 0x89, 0x2d, 0xfb, 0x04, 0xd2, 0x97, 0xe6, 0x19, 0x90, 0xdd, 0x96, 0x6e, 0x97, 0xfe,
+	0xfe, 0x4f, 0xbf, 0xed, 0xa3, 0x43, 0x3e, 0x4a, 0x85, 0x9b, 0x76, 0x35, 0x13, 0xa6, 0x9e, 0x4b,
+	0x63, 0xa4, 0x86, 0x39, 0x3a, 0xa5, 0xb5, 0xe2, 0xcd, 0x1c, 0x3a, 0x5e, 0x5b, 0x0d, 0x7e, 0x2e,
+	0x70, 0x2e, 0x90, 0x09, 0xd3, 0xac, 0x95, 0x9c, 0x59, 0x67, 0xd0, 0x64, 0x27, 0x02, 0x6f, 0xfe,
+	0xf6, 0xc9, 0x70, 0x61, 0xe4, 0x5d, 0xe4, 0xd9, 0x35, 0x39, 0x43, 0x07, 0xc0, 0x54, 0x45, 0x93,
+	0x3c, 0x29, 0x7a, 0xe5, 0x20, 0x1c, 0xef, 0xab, 0xec, 0x8a, 0x0c, 0xac, 0x83, 0xb5, 0xea, 0xe8,
+	0x49, 0x9e, 0x14, 0xc3, 0x72, 0x7f, 0xca, 0x5e, 0x91, 0xd4, 0x19, 0x83, 0x9e, 0x59, 0xa8, 0xd9,
+	0x5a, 0x69, 0xa0, 0xbd, 0x98, 0x5f, 0x44, 0xba, 0x84, 0xfa, 0xab, 0xd2, 0x90, 0x15, 0xe4, 0x52,
+	0xf3, 0x8e, 0x05, 0xc6, 0x2c, 0x77, 0x5e, 0x35, 0x92, 0xf6, 0xf3, 0xa4, 0x38, 0x2f, 0x53, 0xcd,
+	0xbb, 0xd2, 0x18, 0x5c, 0xee, 0x68, 0x30, 0xad, 0x53, 0xbf, 0x39, 0x02, 0xfb, 0x05, 0xdb, 0x5d,
+	0xe3, 0x69, 0x6c, 0x4c, 0xf7, 0xfc, 0x01, 0xb6, 0xb1, 0xf3, 0x2d, 0x99, 0x3e, 0x35, 0x2d, 0xf7,
+	0xfe, 0x8f, 0x71, 0x15, 0x1d, 0x44, 0x3b, 0x3b, 0xda, 0xcb, 0x7d, 0x92, 0xbd, 0x26, 0x13, 0xdb,
+	0xae, 0xb4, 0x12, 0xc7, 0xea, 0xb3, 0x28, 0x8f, 0x77, 0xf8, 0xd0, 0xfc, 0x9e, 0x5c, 0xf9, 0x76,
+	0x55, 0x2b, 0xef, 0x95, 0x69, 0xc2, 0xcf, 0x1c, 0xf3, 0x20, 0x4c, 0x53, 0xd1, 0xf3, 0x3c, 0x29,
+	0x92, 0x72, 0xfa, 0x24, 0x5d, 0x82, 0x7b, 0x8c, 0x59, 0xf6, 0x86, 0x5c, 0x1e, 0x39, 0x5b, 0xb5,
+	0xce, 0x23, 0x1d, 0xc6, 0x19, 0x4e, 0x8e, 0xfc, 0x73, 0xc0, 0xd9, 0x07, 0x72, 0xcd, 0x85, 0x00,
+	0x8b, 0x50, 0x31, 0xe8, 0x30, 0x3e, 0xa7, 0xf5, 0x5c, 0x82, 0xa7, 0x24, 0xef, 0x15, 0xc3, 0x72,
+	0x7a, 0x88, 0xbf, 0x74, 0xf8, 0x00, 0xdb, 0x1f, 0x31, 0x0b, 0xb3, 0xa9, 0x79, 0xc7, 0xc4, 0x86,
+	0xab, 0x86, 0x69, 0x68, 0x24, 0x6e, 0xe8, 0x28, 0x4f, 0x8a, 0xd3, 0x32, 0xad, 0x79, 0x77, 0x17,
+	0xf0, 0x22, 0xd2, 0xf0, 0x16, 0x07, 0x3f, 0x41, 0x84, 0x0b, 0x94, 0xf7, 0x2d, 0x38, 0x4f, 0x2f,
+	0x62, 0xf3, 0xe4, 0xc0, 0xef, 0x77, 0x38, 0x0c, 0xa5, 0x31, 0xc8, 0xf8, 0x1a, 0xc3, 0x37, 0x91,
+	0x3b, 0xa4, 0xe3, 0xdd, 0x50, 0x1a, 0x83, 0x9f, 0x02, 0x7d, 0x0c, 0xf0, 0xb9, 0xa7, 0x55, 0xad,
+	0x90, 0xa6, 0xcf, 0xbd, 0x45, 0x80, 0xd9, 0x0b, 0x32, 0xf2, 0x1b, 0xee, 0x2a, 0x26, 0x9d, 0x69,
+	0x2d, 0x9d, 0x44, 0x87, 0x44, 0xf4, 0x2d, 0x90, 0x9b, 0x5b, 0x92, 0x2e, 0x8c, 0xfc, 0xde, 0x6a,
+	0x54, 0xfb, 0xa5, 0x7b, 0x49, 0xfa, 0xda, 0x48, 0x4f, 0x93, 0xbc, 0x57, 0x8c, 0xde, 0x8d, 0x67,
+	0x02, 0x67, 0xff, 0x37, 0xb2, 0x8c, 0xd1, 0x6a, 0x10, 0x17, 0xf6, 0xf6, 0xdf, 0x00, 0xbf, 0x20,
+	0xf0, 0xf2, 0xea, 0x02, 0x00, 0x00,
+}
@@ -17,6 +17,9 @@ import (
 	"github.com/google/certificate-transparency/go/x509"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
 )
@@ -56,6 +59,8 @@ const (
 	getEntryAndProofParamLeafIndex = "leaf_index"
 	// The name of the get-entry-and-proof tree size paramter
 	getEntryAndProofParamTreeSize = "tree_size"
+	// The header that clients can send a request ID in, and that it's returned in
+	requestIDHeader = "X-Request-Id"
 )
 
 // appHandler is a type for simplifying and centralizing error handling from http handlers
@@ -63,20 +68,33 @@ type appHandler func(http.ResponseWriter, *http.Request) (int, error)
 
 // ServeHTTP is an adapter from appHandler to the http framework
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Every request gets an ID that's passed on to the backend and returned to the client, so
+	// the log lines about it can be found on both
+	if !logging.ValidRequestID(r.Header.Get(requestIDHeader)) {
+		r.Header.Set(requestIDHeader, logging.NewRequestID())
+	}
+	w.Header().Set(requestIDHeader, r.Header.Get(requestIDHeader))
+
 	status, err := fn(w, r)
 
 	if err != nil {
-		glog.Warningf("handler error: %v", err)
+		logging.Warningf(requestContext(r), "handler error: %v", err)
 		sendHttpError(w, status, err)
 	}
 
 	// Additional check, for consistency the handler must return an error for non 200 status
 	if status != http.StatusOK {
-		glog.Warningf("handler non 200 without error: %d %v", status, err)
+		logging.Warningf(requestContext(r), "handler non 200 without error: %d %v", status, err)
 		sendHttpError(w, http.StatusInternalServerError, fmt.Errorf("http handler misbehaved, status: %d", status))
 	}
 }
 
+// requestContext returns a context tagged with the ID of r, which is sent on to the backend
+// with the RPCs made with it
+func requestContext(r *http.Request) context.Context {
+	return logging.OutgoingContext(logging.NewContext(context.Background(), logging.Fields{RequestID: r.Header.Get(requestIDHeader)}))
+}
+
 // CTRequestHandlers provides HTTP handler functions for CT V1 as defined in RFC 6962
 // and functionality to translate CT client requests into forms that can be served by a
 // log backend RPC service.
@@ -93,18 +111,43 @@ type CTRequestHandlers struct {
 	rpcDeadline time.Duration
 	// timeSource is a util.TimeSource that can be injected for testing
 	timeSource util.TimeSource
+	// policy restricts the chains the log accepts, if it's not nil
+	policy *ValidationPolicy
+	// submissionLimiter limits the rate of add-chain and add-pre-chain requests, if it's not nil
+	submissionLimiter *rateLimiter
 }
 
 // NewCTRequestHandlers creates a new instance of CTRequestHandlers. They must still
 // be registered by calling RegisterCTHandlers()
 func NewCTRequestHandlers(logID int64, trustedRoots *PEMCertPool, rpcClient trillian.TrillianLogClient, km crypto.KeyManager, rpcDeadline time.Duration, timeSource util.TimeSource) *CTRequestHandlers {
-	return &CTRequestHandlers{logID, trustedRoots, rpcClient, km, rpcDeadline, timeSource}
+	return &CTRequestHandlers{logID, trustedRoots, rpcClient, km, rpcDeadline, timeSource, nil, nil}
+}
+
+// SetValidationPolicy sets the policy that submitted chains must meet on top of chaining to
+// one of the trusted roots. It must be called before the handlers are registered.
+func (c *CTRequestHandlers) SetValidationPolicy(policy *ValidationPolicy) {
+	c.policy = policy
+}
+
+// SetSubmissionRateLimit limits add-chain and add-pre-chain requests to tokensPerSecond,
+// with bursts of up to burst requests. Requests over the limit get a 429 response. It must
+// be called before the handlers are registered.
+func (c *CTRequestHandlers) SetSubmissionRateLimit(tokensPerSecond float64, burst int64) {
+	c.submissionLimiter = newRateLimiter(quota.Limit{Capacity: burst, TokensPerSecond: tokensPerSecond}, c.timeSource)
 }
 
 func pathFor(req string) string {
 	return ctV1BasePath + req
 }
 
+// policyRejectionResponse is the body of the response to an add-chain or add-pre-chain
+// request for a chain that's rejected by the log's ValidationPolicy. This is not defined in
+// RFC 6962.
+type policyRejectionResponse struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
 // addChainRequest is a struct for parsing JSON add-chain requests. See RFC 6962 Sections 4.1 and 4.2
 type addChainRequest struct {
 	Chain []string
@@ -217,8 +260,21 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 		return http.StatusBadRequest, err
 	}
 
+	return addParsedChain(w, r, addChainRequest, c, isPrecert)
+}
+
+// addParsedChain does the work of addChainInternal once the request has been parsed, so that
+// requests routed between logs don't have to be parsed again
+func addParsedChain(w http.ResponseWriter, r *http.Request, addChainRequest addChainRequest, c CTRequestHandlers, isPrecert bool) (int, error) {
+	if !c.submissionLimiter.allow() {
+		return http.StatusTooManyRequests, errors.New("too many submissions, try again later")
+	}
+
+	// The roots might be reloaded while we're working so stick to the ones there are now
+	trustedRoots := c.trustedRoots.Snapshot()
+
 	// We already checked that the chain is not empty so can move on to verification
-	validPath, err := verifyAddChain(addChainRequest, w, *c.trustedRoots, isPrecert)
+	validPath, err := verifyAddChain(addChainRequest, w, *trustedRoots, c.policy, isPrecert)
 
 	if err != nil {
 		// Chain rejected by verify.
@@ -231,7 +287,13 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 	var sct ct.SignedCertificateTimestamp
 
 	if isPrecert {
-		merkleTreeLeaf, sct, err = signV1SCTForPrecertificate(c.logKeyManager, validPath[0], c.timeSource.Now())
+		var issuer, signingCert *x509.Certificate
+
+		if issuer, signingCert, err = PrecertIssuer(validPath, *trustedRoots); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("failed to find precert issuer: %v", err)
+		}
+
+		merkleTreeLeaf, sct, err = signV1SCTForPrecertificate(c.logKeyManager, validPath[0], issuer, signingCert, c.timeSource.Now())
 	} else {
 		merkleTreeLeaf, sct, err = signV1SCTForCertificate(c.logKeyManager, validPath[0], c.timeSource.Now())
 	}
@@ -251,7 +313,7 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 
 	request := trillian.QueueLeavesRequest{LogId: c.logID, Leaves: []*trillian.LeafProto{&leafProto}}
 
-	ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+	ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 
 	response, err := c.rpcClient.QueueLeaves(ctx, &request)
 
@@ -262,6 +324,23 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 		return http.StatusInternalServerError, err
 	}
 
+	// We sent one leaf so there should be one result for it
+	if len(response.Leaves) != 1 {
+		return http.StatusInternalServerError, fmt.Errorf("expected 1 leaf result from backend but got %d", len(response.Leaves))
+	}
+
+	switch result := response.Leaves[0]; result.Status {
+	case trillian.QueuedLeafStatus_QUEUED:
+		// The SCT we already built is the one for the new entry
+	case trillian.QueuedLeafStatus_DUPLICATE:
+		// The chain was submitted before so the client gets an SCT for the original entry
+		if sct, err = sctForExistingLeaf(c.logKeyManager, result.ExistingLeaf); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	default:
+		return http.StatusInternalServerError, fmt.Errorf("backend did not queue leaf: %v %s", result.Status, result.Reason)
+	}
+
 	// Success. We can now build and marshal the JSON response and write it out
 	err = marshalAndWriteAddChainResponse(sct, c.logKeyManager, w)
 
@@ -274,9 +353,31 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 	return http.StatusOK, nil
 }
 
+// sctForExistingLeaf builds an SCT for a leaf that's already in the log. The SCT signs the
+// existing Merkle leaf, including its timestamp, so every submission of the same chain gets
+// an SCT for the entry the log will incorporate.
+func sctForExistingLeaf(km crypto.KeyManager, existing *trillian.LeafProto) (ct.SignedCertificateTimestamp, error) {
+	if existing == nil {
+		return ct.SignedCertificateTimestamp{}, errors.New("backend reported a duplicate without the existing leaf")
+	}
+
+	merkleLeaf, err := ct.ReadMerkleTreeLeaf(bytes.NewBuffer(existing.LeafData))
+
+	if err != nil {
+		return ct.SignedCertificateTimestamp{}, fmt.Errorf("failed to deserialize existing merkle leaf: %v", err)
+	}
+
+	t := time.Unix(0, int64(merkleLeaf.TimestampedEntry.Timestamp)*millisPerNano)
+	_, sct, err := serializeAndSignSCT(km, *merkleLeaf, getSCTForSignatureInput(t), t)
+
+	if err != nil {
+		return ct.SignedCertificateTimestamp{}, fmt.Errorf("failed to create SCT for existing leaf: %v", err)
+	}
+
+	return sct, nil
+}
+
 // All the handlers are wrapped so they have access to the RPC client and other context
-// TODO(Martin2112): Doesn't properly handle duplicate submissions yet but the backend
-// needs this to be implemented before we can do it here
 func wrappedAddChainHandler(c CTRequestHandlers) appHandler {
 	return func(w http.ResponseWriter, r *http.Request) (int, error) {
 		return addChainInternal(w, r, c, false)
@@ -296,7 +397,7 @@ func wrappedGetSTHHandler(c CTRequestHandlers) appHandler {
 		}
 
 		request := trillian.GetLatestSignedLogRootRequest{LogId: c.logID}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &request)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
@@ -362,7 +463,7 @@ func wrappedGetSTHConsistencyHandler(c CTRequestHandlers) appHandler {
 		}
 
 		request := trillian.GetConsistencyProofRequest{LogId: c.logID, FirstTreeSize: first, SecondTreeSize: second}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetConsistencyProof(ctx, &request)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
@@ -427,7 +528,7 @@ func wrappedGetProofByHashHandler(c CTRequestHandlers) appHandler {
 			LeafHash:        leafHash,
 			TreeSize:        treeSize,
 			OrderBySequence: true}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetInclusionProofByHash(ctx, &rpcRequest)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
@@ -480,7 +581,7 @@ func wrappedGetEntriesHandler(c CTRequestHandlers) appHandler {
 		requestIndices := buildIndicesForRange(startIndex, endIndex)
 		request := trillian.GetLeavesByIndexRequest{LogId: c.logID, LeafIndex: requestIndices}
 
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 
 		response, err := c.rpcClient.GetLeavesByIndex(ctx, &request)
 
@@ -572,7 +673,7 @@ func wrappedGetEntryAndProofHandler(c CTRequestHandlers) appHandler {
 		}
 
 		getEntryAndProofRequest := trillian.GetEntryAndProofRequest{LogId: c.logID, LeafIndex: leafIndex, TreeSize: treeSize}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetEntryAndProof(ctx, &getEntryAndProofRequest)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
@@ -612,23 +713,74 @@ func wrappedGetEntryAndProofHandler(c CTRequestHandlers) appHandler {
 // RegisterCTHandlers registers a HandleFunc for all of the RFC6962 defined methods.
 // TODO(Martin2112): This registers on default ServeMux, might need more flexibility?
 func (c CTRequestHandlers) RegisterCTHandlers() {
-	http.Handle(pathFor("add-chain"), wrappedAddChainHandler(c))
-	http.Handle(pathFor("add-pre-chain"), wrappedAddPreChainHandler(c))
-	http.Handle(pathFor("get-sth"), wrappedGetSTHHandler(c))
-	http.Handle(pathFor("get-sth-consistency"), wrappedGetSTHConsistencyHandler(c))
-	http.Handle(pathFor("get-proof-by-hash"), wrappedGetProofByHashHandler(c))
-	http.Handle(pathFor("get-entries"), wrappedGetEntriesHandler(c))
-	http.Handle(pathFor("get-roots"), wrappedGetRootsHandler(c.trustedRoots))
-	http.Handle(pathFor("get-entry-and-proof"), wrappedGetEntryAndProofHandler(c))
+	c.RegisterCTHandlersWithPrefix("")
+}
+
+// RegisterCTHandlersWithPrefix is like RegisterCTHandlers but serves the requests under a path
+// prefix, e.g. "/2017" gives "/2017/ct/v1/add-chain". This lets several logs be served by
+// one process.
+func (c CTRequestHandlers) RegisterCTHandlersWithPrefix(prefix string) {
+	c.RegisterCTHandlersOn(http.DefaultServeMux, prefix)
+}
+
+// RegisterCTHandlersOn is like RegisterCTHandlersWithPrefix but registers the handlers on
+// the given ServeMux rather than the default one
+func (c CTRequestHandlers) RegisterCTHandlersOn(mux *http.ServeMux, prefix string) {
+	mux.Handle(prefix+pathFor("add-chain"), wrappedAddChainHandler(c))
+	mux.Handle(prefix+pathFor("add-pre-chain"), wrappedAddPreChainHandler(c))
+	mux.Handle(prefix+pathFor("get-sth"), wrappedGetSTHHandler(c))
+	mux.Handle(prefix+pathFor("get-sth-consistency"), wrappedGetSTHConsistencyHandler(c))
+	mux.Handle(prefix+pathFor("get-proof-by-hash"), wrappedGetProofByHashHandler(c))
+	mux.Handle(prefix+pathFor("get-entries"), wrappedGetEntriesHandler(c))
+	mux.Handle(prefix+pathFor("get-roots"), wrappedGetRootsHandler(c.trustedRoots))
+	mux.Handle(prefix+pathFor("get-entry-and-proof"), wrappedGetEntryAndProofHandler(c))
 }
 
 // Generates a custom error page to give more information on why something didn't work
 // TODO(Martin2112): Not sure if we want to expose any detail or not
 func sendHttpError(w http.ResponseWriter, statusCode int, err error) {
+	// Chains rejected by policy get a JSON body so clients can tell why
+	if policyErr, ok := err.(*PolicyError); ok {
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		w.WriteHeader(statusCode)
+
+		if err := json.NewEncoder(w).Encode(policyRejectionResponse{Reason: string(policyErr.Reason), Detail: policyErr.Detail}); err != nil {
+			glog.Warningf("failed to write policy rejection: %v", err)
+		}
+
+		return
+	}
+
 	http.Error(w, fmt.Sprintf("%s\n%v", http.StatusText(statusCode), err), statusCode)
 }
 
 // getRPCDeadlineTime calculates the future time an RPC should expire based on our config
+// checkHealth returns an error if the log can't sign with its key or get its latest root
+// from the backend, or if maxRootAge is above zero and that root is older than it
+func (c CTRequestHandlers) checkHealth(ctx context.Context, maxRootAge time.Duration) error {
+	if err := health.KeyCheck(c.logKeyManager)(ctx); err != nil {
+		return fmt.Errorf("key: %v", err)
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, getRPCDeadlineTime(c))
+	defer cancel()
+
+	response, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: c.logID})
+
+	if err != nil {
+		return fmt.Errorf("backend: %v", err)
+	}
+	if !rpcStatusOK(response.GetStatus()) {
+		return fmt.Errorf("backend: %v", response.GetStatus())
+	}
+
+	if root := response.GetSignedLogRoot(); maxRootAge > 0 && root != nil {
+		return health.CheckRootAge(*root, c.timeSource.Now(), maxRootAge)
+	}
+
+	return nil
+}
+
 func getRPCDeadlineTime(c CTRequestHandlers) time.Time {
 	return c.timeSource.Now().Add(c.rpcDeadline)
 }
@@ -641,7 +793,12 @@ func rpcStatusOK(status *trillian.TrillianApiStatus) bool {
 // cert is of the correct type and chains to a trusted root.
 // TODO(Martin2112): This may not implement all the RFC requirements. Check what is provided
 // by fixchain (called by this code) plus the ones here to make sure that it is compliant.
-func verifyAddChain(req addChainRequest, w http.ResponseWriter, trustedRoots PEMCertPool, expectingPrecert bool) ([]*x509.Certificate, error) {
+func verifyAddChain(req addChainRequest, w http.ResponseWriter, trustedRoots PEMCertPool, policy *ValidationPolicy, expectingPrecert bool) ([]*x509.Certificate, error) {
+	// Turn away chains that are too long before doing the work of validating them
+	if err := policy.checkChainLength(len(req.Chain)); err != nil {
+		return nil, err
+	}
+
 	// We already checked that the chain is not empty so can move on to verification
 	validPath, err := ValidateChain(req.Chain, trustedRoots)
 
@@ -667,6 +824,11 @@ func verifyAddChain(req addChainRequest, w http.ResponseWriter, trustedRoots PEM
 		return nil, fmt.Errorf("cert / precert mismatch: %v", expectingPrecert)
 	}
 
+	// The policy's error is returned as it is so the client gets the reason
+	if err := policy.Check(validPath, trustedRoots); err != nil {
+		return nil, err
+	}
+
 	return validPath, nil
 }
 
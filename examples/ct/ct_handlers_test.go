@@ -6,6 +6,7 @@ package ct
 import (
 	"bufio"
 	"bytes"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -301,6 +302,38 @@ func TestPostHandlersAcceptNonEmptyCertChain(t *testing.T) {
 	}
 }
 
+func TestRequestIDs(t *testing.T) {
+	handler := appHandler(func(w http.ResponseWriter, r *http.Request) (int, error) {
+		w.WriteHeader(http.StatusOK)
+		return http.StatusOK, nil
+	})
+
+	for _, test := range []struct {
+		sent string
+		same bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"bad id\n", false},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(test.sent) > 0 {
+			req.Header.Set(requestIDHeader, test.sent)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		got := w.Header().Get(requestIDHeader)
+		if len(got) == 0 || (got == test.sent) != test.same {
+			t.Errorf("Sent request ID %q, got back %q", test.sent, got)
+		}
+	}
+}
+
 func TestGetRoots(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -349,7 +382,7 @@ func TestAddChainMissingIntermediate(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem})
 	chain := createJsonChain(t, *pool)
@@ -371,7 +404,7 @@ func TestAddChainPrecert(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	// TODO(Martin2112): I don't think CT should return NonFatalError for something we expect
 	// to happen - seeing a precert extension. If this is fixed upstream remove all references from
@@ -403,7 +436,7 @@ func TestAddChainRPCFails(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
 	chain := createJsonChain(t, *pool)
@@ -437,7 +470,7 @@ func TestAddChain(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
 	chain := createJsonChain(t, *pool)
@@ -451,7 +484,7 @@ func TestAddChain(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_QUEUED}}}, nil)
 
 	recorder := makeAddChainRequest(t, reqHandlers, chain)
 
@@ -479,6 +512,152 @@ func TestAddChain(t *testing.T) {
 	}
 }
 
+// Resubmitting a chain that's already in the log should return an SCT with the original
+// timestamp
+func TestAddChainDuplicate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	// The SCT is signed again for the original timestamp so accept any input
+	km := crypto.NewMockKeyManager(mockCtrl)
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&rsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(mockSigner, nil)
+	km.EXPECT().GetRawPublicKey().AnyTimes().Return([]byte("key"), nil)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := createJsonChain(t, *pool)
+
+	merkleLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	// The existing leaf was submitted an hour earlier
+	existingLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime.Add(-time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing := leafProtosForCert(t, km, pool.RawCertificates(), existingLeaf)[0]
+	existing.LeafIndex = 7
+
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: existing}}}, nil)
+
+	recorder := makeAddChainRequest(t, reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %v for duplicate add-chain, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	var resp addChainResponse
+	if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
+	}
+
+	if got, want := resp.Timestamp, existingLeaf.TimestampedEntry.Timestamp; got != want {
+		t.Fatalf("Got timestamp %d, expected original timestamp %d", got, want)
+	}
+}
+
+// A leaf the backend rejects results in an error
+func TestAddChainRejectedByBackend(t *testing.T) {
+	toSign := []byte{0x7a, 0xc4, 0xd9, 0xca, 0x5f, 0x2e, 0x23, 0x82, 0xfe, 0xef, 0x5e, 0x95, 0x64, 0x7b, 0x31, 0x11, 0xf, 0x2a, 0x9b, 0x78, 0xa8, 0x3, 0x30, 0x8d, 0xfc, 0x8b, 0x78, 0x6, 0x61, 0xe7, 0x58, 0x44}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := setupMockKeyManager(mockCtrl, toSign)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := createJsonChain(t, *pool)
+
+	merkleLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_REJECTED, Reason: "bad leaf"}}}, nil)
+
+	recorder := makeAddChainRequest(t, reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusInternalServerError; got != want {
+		t.Fatalf("expected %v for rejected add-chain, got %v. Body: %v", want, got, recorder.Body)
+	}
+}
+
+// A chain that breaks the log's validation policy should be rejected with the reason, without
+// anything being sent to the backend
+func TestAddChainRejectedByPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	reqHandlers.SetValidationPolicy(&ValidationPolicy{MaxChainLength: 1})
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := createJsonChain(t, *pool)
+
+	recorder := makeAddChainRequest(t, reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("expected %v for add-chain rejected by policy, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	var resp policyRejectionResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
+	}
+
+	if got, want := resp.Reason, string(RejectedChainTooLong); got != want {
+		t.Fatalf("got rejection reason %s, expected %s", got, want)
+	}
+}
+
+// Submissions over the log's rate limit should be turned away before they're checked
+func TestAddChainRateLimited(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	// The fake time doesn't move on so the bucket is never refilled
+	reqHandlers.SetSubmissionRateLimit(1, 1)
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem})
+
+	// The first chain is let through, and rejected as it's incomplete
+	for _, want := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+		recorder := makeAddChainRequest(t, reqHandlers, createJsonChain(t, *pool))
+
+		if got := recorder.Code; got != want {
+			t.Fatalf("expected %v for rate limited add-chain, got %v. Body: %v", want, got, recorder.Body)
+		}
+	}
+}
+
 // Submit a chain with a valid precert but not signed by next cert in chain. Should be rejected.
 func TestAddPrecertChainInvalidPath(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
@@ -488,7 +667,7 @@ func TestAddPrecertChainInvalidPath(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -526,7 +705,7 @@ func TestAddPrecertChainCert(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.TestCertPEM)
 
@@ -548,7 +727,7 @@ func TestAddPrecertChainCert(t *testing.T) {
 // Submit a chain that should be OK but arrange for the backend RPC to fail. Failure should
 // be propagated.
 func TestAddPrecertChainRPCFails(t *testing.T) {
-	toSign := []byte{0xe4, 0x58, 0xf3, 0x6f, 0xbd, 0xed, 0x2e, 0x62, 0x53, 0x30, 0xb3, 0x4, 0x73, 0x10, 0xb4, 0xe2, 0xe1, 0xa7, 0x44, 0x9e, 0x1f, 0x16, 0x6f, 0x78, 0x61, 0x98, 0x32, 0xe5, 0x43, 0x5a, 0x21, 0xff}
+	toSign := []byte{0x37, 0x0, 0xc5, 0x32, 0xff, 0x59, 0xe, 0x67, 0xb8, 0x65, 0x5a, 0x31, 0x86, 0x51, 0x31, 0x6a, 0xac, 0x9, 0x2e, 0x79, 0xe0, 0x12, 0x5e, 0xf9, 0xc9, 0xe, 0xf2, 0x84, 0xb3, 0x9f, 0x51, 0x3b}
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -556,7 +735,7 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -570,7 +749,7 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 	chain := createJsonChain(t, *pool)
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], roots.RawCertificates()[0], nil, fakeTime)
 
 	if err != nil {
 		t.Fatal(err)
@@ -589,7 +768,7 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 
 // Submit a chain with a valid precert signed by a trusted root. Should be accepted.
 func TestAddPrecertChain(t *testing.T) {
-	toSign := []byte{0xe4, 0x58, 0xf3, 0x6f, 0xbd, 0xed, 0x2e, 0x62, 0x53, 0x30, 0xb3, 0x4, 0x73, 0x10, 0xb4, 0xe2, 0xe1, 0xa7, 0x44, 0x9e, 0x1f, 0x16, 0x6f, 0x78, 0x61, 0x98, 0x32, 0xe5, 0x43, 0x5a, 0x21, 0xff}
+	toSign := []byte{0x37, 0x0, 0xc5, 0x32, 0xff, 0x59, 0xe, 0x67, 0xb8, 0x65, 0x5a, 0x31, 0x86, 0x51, 0x31, 0x6a, 0xac, 0x9, 0x2e, 0x79, 0xe0, 0x12, 0x5e, 0xf9, 0xc9, 0xe, 0xf2, 0x84, 0xb3, 0x9f, 0x51, 0x3b}
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -597,7 +776,7 @@ func TestAddPrecertChain(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -611,7 +790,7 @@ func TestAddPrecertChain(t *testing.T) {
 	chain := createJsonChain(t, *pool)
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], roots.RawCertificates()[0], nil, fakeTime)
 
 	if err != nil {
 		t.Fatal(err)
@@ -619,7 +798,7 @@ func TestAddPrecertChain(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_QUEUED}}}, nil)
 
 	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
 
@@ -647,6 +826,74 @@ func TestAddPrecertChain(t *testing.T) {
 	}
 }
 
+// Resubmitting a precert chain that's already in the log should return an SCT that signs the
+// existing entry
+func TestAddPrecertChainDuplicate(t *testing.T) {
+	toSign := []byte{0x37, 0x0, 0xc5, 0x32, 0xff, 0x59, 0xe, 0x67, 0xb8, 0x65, 0x5a, 0x31, 0x86, 0x51, 0x31, 0x6a, 0xac, 0x9, 0x2e, 0x79, 0xe0, 0x12, 0x5e, 0xf9, 0xc9, 0xe, 0xf2, 0x84, 0xb3, 0x9f, 0x51, 0x3b}
+	existingToSign := []byte{0xe0, 0xa2, 0xd6, 0x14, 0xd7, 0x18, 0x7b, 0xe6, 0x25, 0xc2, 0x26, 0xe6, 0x60, 0xdb, 0x42, 0x59, 0xfc, 0x7f, 0x45, 0xf9, 0xdd, 0x8c, 0x51, 0x6d, 0x12, 0x11, 0xc5, 0x16, 0xb0, 0xf2, 0x82, 0x67}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	// Each SCT is signed once here to set up the test and once by the handler
+	km := crypto.NewMockKeyManager(mockCtrl)
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&rsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), toSign, gomock.Any()).Times(2).Return([]byte("signed"), nil)
+	mockSigner.EXPECT().Sign(gomock.Any(), existingToSign, gomock.Any()).Times(2).Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(mockSigner, nil)
+	km.EXPECT().GetRawPublicKey().AnyTimes().Return([]byte("key"), nil)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+
+	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
+	_, ok := err.(x509.NonFatalErrors)
+
+	if err != nil && !ok {
+		t.Fatal(err)
+	}
+
+	pool := NewPEMCertPool()
+	pool.AddCert(cert)
+	chain := createJsonChain(t, *pool)
+
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], roots.RawCertificates()[0], nil, fakeTime)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	// The existing leaf was submitted an hour earlier
+	existingLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], roots.RawCertificates()[0], nil, fakeTime.Add(-time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing := leafProtosForCert(t, km, pool.RawCertificates(), existingLeaf)[0]
+	existing.LeafIndex = 3
+
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: existing}}}, nil)
+
+	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %v for duplicate add-pre-chain, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	var resp addChainResponse
+	if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
+	}
+
+	if got, want := resp.Timestamp, existingLeaf.TimestampedEntry.Timestamp; got != want {
+		t.Fatalf("Got timestamp %d, expected original timestamp %d", got, want)
+	}
+}
+
 func TestGetSTHBackendErrorFails(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -656,7 +903,7 @@ func TestGetSTHBackendErrorFails(t *testing.T) {
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
 	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(nil, errors.New("backendfailure"))
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -686,7 +933,7 @@ func TestGetSTHInvalidBackendTreeSizeFails(t *testing.T) {
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
 	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, -50, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -715,7 +962,7 @@ func TestGetSTHMissingRootHashFails(t *testing.T) {
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
 	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, 25, []byte("thisisnot32byteslong")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -743,12 +990,13 @@ func TestGetSTHSigningFails(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(&rsa.PublicKey{})
 	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).Return([]byte{}, errors.New("signerfails"))
 	km.EXPECT().Signer().Return(signer, nil)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
 	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -777,7 +1025,7 @@ func TestGetSTH(t *testing.T) {
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
 	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -45,6 +45,10 @@ func main() {
 		}
 		for _, kv := range resp.KeyValue {
 			el := ct_mapper.EntryList{}
+			if kv.KeyValue.Value == nil {
+				glog.Infof("Domain %s is not in the map", kv.KeyValue.Key)
+				continue
+			}
 			v := kv.KeyValue.Value.LeafValue
 			if len(v) == 0 {
 				continue
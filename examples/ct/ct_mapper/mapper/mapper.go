@@ -137,6 +137,10 @@ func (m *CTMapper) oneMapperRun() (bool, error) {
 		if len(v.Inclusion) > 0 {
 			proofs++
 		}
+		if v.KeyValue.Value == nil {
+			// Not in the map yet
+			continue
+		}
 		if err := proto.Unmarshal(v.KeyValue.Value.LeafValue, &e); err != nil {
 			return false, err
 		}
@@ -4,105 +4,81 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
-	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/examples/ct"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/util"
 	"google.golang.org/grpc"
-	"time"
 )
 
 // TODO(Martin2112): We still have the treeid / log ID thing to think about + security etc.
-var logIDFlag = flag.Int64("log_id", 1, "The log id (tree id) to send to the backend")
+var logConfigFlag = flag.String("log_config", "", "File containing a LogMultiConfig in protobuf text format describing the logs to serve")
+var logConfigReloadPeriodFlag = flag.Duration("log_config_reload_period", 0, "How often to check the --log_config file and the roots and keys it refers to for changes and reload them, or 0 to only reload them on SIGHUP")
 var rpcBackendFlag = flag.String("log_rpc_backend", "localhost:8090", "Backend Log RPC server to use")
 var rpcDeadlineFlag = flag.Duration("rpc_deadline", time.Second*10, "Deadline for backend RPC requests")
 var serverPortFlag = flag.Int("port", 8091, "Port to serve CT log requests on")
-var trustedRootPEMFlag = flag.String("trusted_roots", "", "File containing one or more concatenated trusted root certs in PEM format")
-var privateKeyPasswordFlag = flag.String("private_key_password", "", "Password for log private key")
-var privateKeyPEMFlag = flag.String("private_key", "", "PEM file containing log private key")
-var publicKeyPEMFlag = flag.String("public_key", "", "PEM file containing log public key")
-
-func loadTrustedRoots() (*ct.PEMCertPool, error) {
-	if len(*trustedRootPEMFlag) == 0 {
-		return nil, errors.New("the --trusted_roots flag must be set to reference a valid PEM file")
-	}
-
-	trustedRoots := ct.NewPEMCertPool()
-
-	// The set of root data should never be particularly large and we have to keep it in memory
-	// anyway to validate submissions
-	rootData, err := ioutil.ReadFile(*trustedRootPEMFlag)
-
-	if err != nil {
-		return nil, err
-	}
+var traceSampleRateFlag = flag.Float64("trace_sample_rate", 0, "Fraction of requests to the log backend to trace, between 0 and 1")
+var healthCheckTimeoutFlag = flag.Duration("health_check_timeout", time.Second*5, "Longest each health check can take before it's counted as failed")
+var maxRootAgeFlag = flag.Duration("max_root_age", 0, "The server isn't ready if the latest root of any log was signed longer ago than this, which must be longer than the backend's signer_sleep_between_runs. 0 disables the check")
 
-	if !trustedRoots.AppendCertsFromPEM(rootData) {
-		return nil, err
+func loadLogConfig() (*ct.LogMultiConfig, error) {
+	if len(*logConfigFlag) == 0 {
+		return nil, errors.New("the --log_config flag must be set to reference a valid config file")
 	}
 
-	if len(trustedRoots.Subjects()) == 0 {
-		return nil, errors.New("trusted root certificate pool is empty?")
-	}
-
-	return trustedRoots, nil
+	return ct.LoadLogConfigFile(*logConfigFlag)
 }
 
-func loadLogKeys() (crypto.KeyManager, error) {
-	logKeyManager := crypto.NewPEMKeyManager()
+// reloadOnSignal loads the log config again each time the server is sent SIGHUP, so that
+// changes to the logs' roots, keys and rate limits take effect without waiting for
+// --log_config_reload_period or restarting
+func reloadOnSignal(logServer *ct.LogServer) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
 
-	privateKeyPEM, err := ioutil.ReadFile(*privateKeyPEMFlag)
+	for range sigs {
+		logConfig, err := loadLogConfig()
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			glog.Warningf("Failed to read log config: %v", err)
+			continue
+		}
 
-	err = logKeyManager.LoadPrivateKey(string(privateKeyPEM), *privateKeyPasswordFlag)
+		if err := logServer.Load(logConfig); err != nil {
+			glog.Warningf("Failed to load log config: %v", err)
+			continue
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to load private key: %v", err)
+		glog.Infof("Loaded %d logs from %s", len(logConfig.Logs), *logConfigFlag)
 	}
-
-	publicKeyPEM, err := ioutil.ReadFile(*publicKeyPEMFlag)
-
-	if err != nil {
-		return nil, err
-	}
-
-	err = logKeyManager.LoadPublicKey(string(publicKeyPEM))
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to load public key: %v", err)
-	}
-
-	return logKeyManager, nil
 }
 
 func main() {
 	flag.Parse()
 
-	// Load the set of trusted root certs before bringing up any servers
-	trustedRoots, err := loadTrustedRoots()
-
-	if err != nil {
-		glog.Fatalf("Failed to read trusted roots: %v", err)
+	if *traceSampleRateFlag > 0 {
+		defer monitoring.InitTracing(*traceSampleRateFlag)()
 	}
 
-	// And load our keys
-	logKeyManager, err := loadLogKeys()
+	// Read the config before bringing up any servers
+	logConfig, err := loadLogConfig()
 
 	if err != nil {
-		glog.Fatalf("Failed to load keys for log: %v", err)
+		glog.Fatalf("Failed to read log config: %v", err)
 	}
 
 	// TODO(Martin2112): Support TLS and other stuff for RPC client and http server, this is just to
 	// get started. Uses a blocking connection so we don't start serving before we're connected
 	// to backend.
-	conn, err := grpc.Dial(*rpcBackendFlag, grpc.WithInsecure(), grpc.WithBlock())
+	conn, err := grpc.Dial(*rpcBackendFlag, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithUnaryInterceptor(monitoring.UnaryClientInterceptor()))
 
 	if err != nil {
 		glog.Fatalf("Could not connect to rpc server: %v", err)
@@ -111,9 +87,31 @@ func main() {
 	defer conn.Close()
 	client := trillian.NewTrillianLogClient(conn)
 
-	// Create and register the handlers using the RPC client we just set up
-	handlers := ct.NewCTRequestHandlers(*logIDFlag, trustedRoots, client, logKeyManager, *rpcDeadlineFlag, new(util.SystemTimeSource))
-	handlers.RegisterCTHandlers()
+	// Create the logs using the RPC client we just set up
+	logServer := ct.NewLogServer(client, *rpcDeadlineFlag, new(util.SystemTimeSource))
+
+	if err := logServer.Load(logConfig); err != nil {
+		glog.Fatalf("Failed to set up logs: %v", err)
+	}
+
+	// Keep the logs up to date with the config so they can be changed without a restart
+	if *logConfigReloadPeriodFlag > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go logServer.WatchConfigFile(*logConfigFlag, *logConfigReloadPeriodFlag, done)
+	}
+
+	go reloadOnSignal(logServer)
+
+	http.Handle("/", logServer)
+	// Metrics are served alongside the CT API
+	http.Handle("/metrics", monitoring.Handler())
+
+	// As are liveness and readiness, which needs every log's key and backend to be usable
+	healthChecker := health.NewChecker(*healthCheckTimeoutFlag)
+	healthChecker.AddReadinessCheck("logs", logServer.HealthCheck(*maxRootAgeFlag))
+	http.Handle(health.LivenessPath, healthChecker.LivenessHandler())
+	http.Handle(health.ReadinessPath, healthChecker.ReadinessHandler())
 
 	glog.Warningf("Server exited: %v", http.ListenAndServe(fmt.Sprintf("localhost:%d", *serverPortFlag), nil))
 }
@@ -0,0 +1,3 @@
+package ct
+
+//go:generate sh -c "cd $GOPATH/src && protoc --go_out=plugins=grpc:. github.com/google/trillian/examples/ct/*proto"
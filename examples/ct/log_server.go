@@ -0,0 +1,186 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// LogServer serves the logs described by a LogMultiConfig, each under its own path prefix.
+// The config can be replaced while the server is in use, requests that have already started
+// carry on with the logs they were given.
+type LogServer struct {
+	// rpcClient is the client used to communicate with the trillian backend for all the logs
+	rpcClient trillian.TrillianLogClient
+	// rpcDeadline is the deadline that will be set on all backend RPC requests
+	rpcDeadline time.Duration
+	// timeSource is a util.TimeSource that can be injected for testing
+	timeSource util.TimeSource
+	// Must hold this lock before accessing mux or logs
+	mutex sync.RWMutex
+	// mux has the handlers for the current logs, it's replaced rather than changed
+	mux *http.ServeMux
+	// logs has the handlers of each of the current logs, including shards, for health checks
+	logs []*CTRequestHandlers
+}
+
+// NewLogServer creates a new instance of LogServer. It doesn't serve any logs until Load is
+// called.
+func NewLogServer(rpcClient trillian.TrillianLogClient, rpcDeadline time.Duration, timeSource util.TimeSource) *LogServer {
+	return &LogServer{rpcClient: rpcClient, rpcDeadline: rpcDeadline, timeSource: timeSource, mux: http.NewServeMux()}
+}
+
+// ServeHTTP passes a request to the handlers of the current logs
+func (s *LogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	mux := s.mux
+	s.mutex.RUnlock()
+
+	mux.ServeHTTP(w, r)
+}
+
+// Load sets up the logs in a config and then serves them in place of the current ones. If
+// any of them can't be set up the current logs are kept. The logs' keys and roots are read
+// again and their submission rate limits start afresh.
+func (s *LogServer) Load(cfg *LogMultiConfig) error {
+	if err := ValidateLogMultiConfig(cfg); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	shardGroups := make(map[string][]LogShard)
+	var logs []*CTRequestHandlers
+
+	for _, logCfg := range cfg.Logs {
+		handlers, err := s.createHandlers(logCfg)
+
+		if err != nil {
+			return fmt.Errorf("log %s: %v", logCfg.Prefix, err)
+		}
+
+		logs = append(logs, handlers)
+
+		if len(logCfg.ShardGroup) == 0 {
+			handlers.RegisterCTHandlersOn(mux, "/"+logCfg.Prefix)
+			continue
+		}
+
+		// The shard's range was set in its policy
+		shard := LogShard{Name: logCfg.Prefix, NotAfterStart: handlers.policy.NotAfterStart, NotAfterLimit: handlers.policy.NotAfterLimit, Handlers: handlers}
+		shardGroups[logCfg.ShardGroup] = append(shardGroups[logCfg.ShardGroup], shard)
+	}
+
+	for group, shards := range shardGroups {
+		temporalShards, err := NewTemporalShards(shards)
+
+		if err != nil {
+			return fmt.Errorf("shard group %s: %v", group, err)
+		}
+
+		temporalShards.RegisterCTHandlersOn(mux, "/"+group)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mux = mux
+	s.logs = logs
+	return nil
+}
+
+// HealthCheck returns a check that fails if any of the current logs can't use its key or
+// get its latest root from the backend, or if maxRootAge is above zero and that root was
+// signed longer ago than maxRootAge
+func (s *LogServer) HealthCheck(maxRootAge time.Duration) health.Check {
+	return func(ctx context.Context) error {
+		s.mutex.RLock()
+		logs := s.logs
+		s.mutex.RUnlock()
+
+		for _, handlers := range logs {
+			if err := handlers.checkHealth(ctx, maxRootAge); err != nil {
+				return fmt.Errorf("log %d: %v", handlers.logID, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// createHandlers sets up the handlers for one of the logs in a config
+func (s *LogServer) createHandlers(cfg *LogConfig) (*CTRequestHandlers, error) {
+	trustedRoots, err := loadTrustedRoots(cfg.RootsPemFile, cfg.LaxRootParsing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logKeyManager, err := loadLogKeys(cfg.PrivateKeyFile, cfg.PrivateKeyPassword, cfg.PublicKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := policyFromConfig(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	handlers := NewCTRequestHandlers(cfg.TreeId, trustedRoots, s.rpcClient, logKeyManager, s.rpcDeadline, s.timeSource)
+	handlers.SetValidationPolicy(policy)
+
+	if cfg.SubmissionsPerSecond > 0 {
+		handlers.SetSubmissionRateLimit(cfg.SubmissionsPerSecond, cfg.SubmissionBurst)
+	}
+
+	return handlers, nil
+}
+
+// WatchConfigFile loads the config in a file whenever it, or any of the roots and key files it
+// refers to, changes, checking every period until done is closed. A config that can't be read
+// or loaded is logged and the current logs are kept. The first check always loads the config,
+// so changes made after it was first loaded aren't missed.
+func (s *LogServer) WatchConfigFile(path string, period time.Duration, done <-chan struct{}) {
+	var lastHash [sha256.Size]byte
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		cfg, hash, err := readLogConfigFile(path)
+
+		if err != nil {
+			glog.Warningf("Failed to read log config from %s: %v", path, err)
+			continue
+		}
+
+		if hash == lastHash {
+			continue
+		}
+
+		// Don't retry a config that failed to load until it's changed again
+		lastHash = hash
+
+		if err := s.Load(cfg); err != nil {
+			glog.Warningf("Failed to load log config from %s: %v", path, err)
+			continue
+		}
+
+		glog.Infof("Loaded %d logs from %s", len(cfg.Logs), path)
+	}
+}
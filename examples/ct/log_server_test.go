@@ -0,0 +1,202 @@
+package ct
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// serveStatus returns the status a LogServer gives for a request
+func serveStatus(s *LogServer, method, path string) int {
+	req, _ := http.NewRequest(method, "http://example.com"+path, nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func TestLogServerLoad(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := writeLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	server := NewLogServer(trillian.NewMockTrillianLogClient(mockCtrl), time.Millisecond*500, fakeTimeSource)
+
+	if got, want := serveStatus(server, "GET", "/pilot/ct/v1/get-roots"), http.StatusNotFound; got != want {
+		t.Fatalf("Got status %d before loading config, expected %d", got, want)
+	}
+
+	cfg, err := ParseLogMultiConfig([]byte(logConfigText(dir, "pilot", 1, "") + logConfigText(dir, "aviator", 2, "")))
+
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if err := server.Load(cfg); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	for _, prefix := range []string{"/pilot", "/aviator"} {
+		if got, want := serveStatus(server, "GET", prefix+"/ct/v1/get-roots"), http.StatusOK; got != want {
+			t.Errorf("Got status %d for get-roots of %s, expected %d", got, prefix, want)
+		}
+	}
+
+	if got, want := serveStatus(server, "GET", "/ct/v1/get-roots"), http.StatusNotFound; got != want {
+		t.Errorf("Got status %d for get-roots without a prefix, expected %d", got, want)
+	}
+
+	// The new config replaces the logs
+	cfg, err = ParseLogMultiConfig([]byte(logConfigText(dir, "rocketeer", 3, "")))
+
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if err := server.Load(cfg); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if got, want := serveStatus(server, "GET", "/pilot/ct/v1/get-roots"), http.StatusNotFound; got != want {
+		t.Errorf("Got status %d for removed log, expected %d", got, want)
+	}
+	if got, want := serveStatus(server, "GET", "/rocketeer/ct/v1/get-roots"), http.StatusOK; got != want {
+		t.Errorf("Got status %d for added log, expected %d", got, want)
+	}
+
+	// A log that can't be set up leaves the current ones in place
+	os.Remove(filepath.Join(dir, "roots.pem"))
+
+	if err := server.Load(cfg); err == nil {
+		t.Fatal("Incorrectly loaded config with missing roots file")
+	}
+	if got, want := serveStatus(server, "GET", "/rocketeer/ct/v1/get-roots"), http.StatusOK; got != want {
+		t.Errorf("Got status %d after failed load, expected %d", got, want)
+	}
+}
+
+func TestLogServerLoadShardGroup(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := writeLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	server := NewLogServer(trillian.NewMockTrillianLogClient(mockCtrl), time.Millisecond*500, fakeTimeSource)
+	cfg, err := ParseLogMultiConfig([]byte(logConfigText(dir, "2018", 1, `shard_group: "all" not_after_start: "2018-01-01T00:00:00Z" not_after_limit: "2019-01-01T00:00:00Z"`) +
+		logConfigText(dir, "2019", 2, `shard_group: "all" not_after_start: "2019-01-01T00:00:00Z"`)))
+
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if err := server.Load(cfg); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	for _, prefix := range []string{"/2018", "/2019"} {
+		if got, want := serveStatus(server, "GET", prefix+"/ct/v1/get-sth-consistency"), http.StatusBadRequest; got != want {
+			t.Errorf("Got status %d for get-sth-consistency of shard %s, expected %d", got, prefix, want)
+		}
+	}
+
+	// The group only accepts submissions, GET is the wrong method but shows it's registered
+	if got, want := serveStatus(server, "GET", "/all/ct/v1/add-chain"), http.StatusMethodNotAllowed; got != want {
+		t.Errorf("Got status %d for add-chain of shard group, expected %d", got, want)
+	}
+	if got, want := serveStatus(server, "GET", "/all/ct/v1/get-roots"), http.StatusNotFound; got != want {
+		t.Errorf("Got status %d for get-roots of shard group, expected %d", got, want)
+	}
+
+	// Overlapping shards are turned away
+	cfg.Logs[1].NotAfterStart = "2018-06-01T00:00:00Z"
+
+	if err := server.Load(cfg); err == nil {
+		t.Fatal("Incorrectly loaded config with overlapping shards")
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := writeLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+
+	if err := ioutil.WriteFile(path, []byte(logConfigText(dir, "pilot", 1, "")), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	server := NewLogServer(trillian.NewMockTrillianLogClient(mockCtrl), time.Millisecond*500, fakeTimeSource)
+	done := make(chan struct{})
+	defer close(done)
+	go server.WatchConfigFile(path, time.Millisecond, done)
+
+	if err := ioutil.WriteFile(path, []byte(logConfigText(dir, "aviator", 2, "")), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); serveStatus(server, "GET", "/aviator/ct/v1/get-roots") != http.StatusOK; {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected changed config to be loaded")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLogServerHealthCheck(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := writeLogFiles(t)
+	defer os.RemoveAll(dir)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	server := NewLogServer(client, time.Millisecond*500, fakeTimeSource)
+	check := server.HealthCheck(time.Minute)
+
+	// Without any logs there's nothing to check
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("Check before loading config = %v", err)
+	}
+
+	cfg, err := ParseLogMultiConfig([]byte(logConfigText(dir, "pilot", 1, "")))
+
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if err := server.Load(cfg); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	hash := []byte("abcdabcdabcdabcdabcdabcdabcdabcd")
+	request := &trillian.GetLatestSignedLogRootRequest{LogId: 1}
+
+	for _, test := range []struct {
+		resp    *trillian.GetLatestSignedLogRootResponse
+		err     error
+		wantErr bool
+	}{
+		{makeGetRootResponseForTest(fakeTime.Add(-time.Second).UnixNano(), 10, hash), nil, false},
+		{makeGetRootResponseForTest(fakeTime.Add(-time.Hour).UnixNano(), 10, hash), nil, true},
+		{&trillian.GetLatestSignedLogRootResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}}, nil, true},
+		{nil, errors.New("backendfailure"), true},
+	} {
+		client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), request).Return(test.resp, test.err)
+
+		if err := check(context.Background()); (err != nil) != test.wantErr {
+			t.Errorf("Check with backend response %v, %v = %v, want error %v", test.resp, test.err, err, test.wantErr)
+		}
+	}
+}
@@ -3,6 +3,11 @@ package ct
 import (
 	"crypto/sha256"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/certificate-transparency/go/x509"
@@ -14,37 +19,147 @@ const pemCertificateBlockType string = "CERTIFICATE"
 // PEMCertPool is a wrapper / extension to x509.CertPool. It allows us to access the
 // raw certs, which we need to serve get-roots request and has stricter handling on loading
 // certs into the pool. CertPool ignores errors if at least one cert loads correctly but
-// PEMCertPool requires all certs to load.
+// PEMCertPool requires all certs to load, unless they're loaded with AppendCertsFromPEMLax.
+//
+// The certificates can be replaced while the pool is in use with Reload or WatchFile. Copies
+// of a pool share its certificates so they see the new ones too.
 type PEMCertPool struct {
-	// maps from sha-1 to certificate, used for dup detection
+	state *poolState
+}
+
+// poolState holds the current certificates of a pool and its copies
+type poolState struct {
+	// Must hold this lock before accessing certs
+	mutex sync.RWMutex
+	// certs is replaced rather than changed so callers can keep using the set they got
+	certs *certSet
+}
+
+// certSet is a set of certificates that isn't changed once it's in use by a pool
+type certSet struct {
+	// maps from sha-256 to certificate, used for dup detection
 	fingerprintToCertMap map[[sha256.Size]byte]x509.Certificate
 	rawCerts             []*x509.Certificate
 	certPool             *x509.CertPool
 }
 
+func newCertSet() *certSet {
+	return &certSet{fingerprintToCertMap: make(map[[sha256.Size]byte]x509.Certificate), certPool: x509.NewCertPool()}
+}
+
+// add adds a certificate to a set that's not yet in use, returning false if it was already there
+func (s *certSet) add(cert *x509.Certificate) bool {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	if _, ok := s.fingerprintToCertMap[fingerprint]; ok {
+		return false
+	}
+
+	s.fingerprintToCertMap[fingerprint] = *cert
+	s.certPool.AddCert(cert)
+	s.rawCerts = append(s.rawCerts, cert)
+	return true
+}
+
 // Creates a new instance of PEMCertPool containing no certificates.
 func NewPEMCertPool() *PEMCertPool {
-	return &PEMCertPool{fingerprintToCertMap: make(map[[sha256.Size]byte]x509.Certificate), certPool: x509.NewCertPool()}
+	return &PEMCertPool{state: &poolState{certs: newCertSet()}}
+}
+
+// current returns the pool's certificates
+func (p *PEMCertPool) current() *certSet {
+	p.state.mutex.RLock()
+	defer p.state.mutex.RUnlock()
+
+	return p.state.certs
+}
+
+// update adds certificates to a copy of the pool's current set and then swaps it in, so
+// that the set in use is never changed. It returns how many of them were already there.
+func (p *PEMCertPool) update(certs []*x509.Certificate) int {
+	p.state.mutex.Lock()
+	defer p.state.mutex.Unlock()
+
+	updated := newCertSet()
+
+	for _, cert := range p.state.certs.rawCerts {
+		updated.add(cert)
+	}
+
+	duplicates := 0
+
+	for _, cert := range certs {
+		if !updated.add(cert) {
+			duplicates++
+		}
+	}
+
+	p.state.certs = updated
+	return duplicates
+}
+
+// Snapshot returns a pool with the certificates this one has now, which doesn't change if
+// this one is reloaded. Use it to see the same roots throughout the handling of a request.
+func (p *PEMCertPool) Snapshot() *PEMCertPool {
+	return &PEMCertPool{state: &poolState{certs: p.current()}}
 }
 
 // AddCert adds a certificate to a pool. Uses fingerprint to weed out duplicates.
 // cert must not be nil.
 func (p *PEMCertPool) AddCert(cert *x509.Certificate) {
-	fingerprint := sha256.Sum256(cert.Raw)
-	_, ok := p.fingerprintToCertMap[fingerprint]
-
-	if !ok {
-		p.fingerprintToCertMap[fingerprint] = *cert
-		p.certPool.AddCert(cert)
-		p.rawCerts = append(p.rawCerts, cert)
-	}
+	p.update([]*x509.Certificate{cert})
 }
 
 // AppendCertsFromPEM adds certs to the pool from a byte slice assumed to contain PEM encoded data.
 // Skips over non certificate blocks in the data. Returns true if all certificates in the
 // data were parsed and added to the pool successfully and at least one certificate was found.
 func (p *PEMCertPool) AppendCertsFromPEM(pemCerts []byte) (ok bool) {
-	for len(pemCerts) > 0 {
+	report := p.appendCertsFromPEM(pemCerts, false)
+	return len(report.Skipped) == 0 && report.Added+report.Duplicates > 0
+}
+
+// AppendCertsFromPEMLax is like AppendCertsFromPEM but carries on past certificates that
+// fail to parse, so that a bundle containing a few malformed legacy roots can still be used.
+// Certificates with only non fatal parse errors are added. The report lists the certificates
+// that were skipped.
+func (p *PEMCertPool) AppendCertsFromPEMLax(pemCerts []byte) LoadReport {
+	return p.appendCertsFromPEM(pemCerts, true)
+}
+
+// SkippedCert is a certificate in PEM data that couldn't be added to a pool
+type SkippedCert struct {
+	// Index is the position of the certificate among the certificate blocks in the data,
+	// starting from 0
+	Index int
+	// Err is why the certificate couldn't be parsed
+	Err error
+}
+
+// LoadReport describes what happened to the certificates in PEM data loaded into a pool
+type LoadReport struct {
+	// Added is the number of certificates added to the pool
+	Added int
+	// Duplicates is the number of certificates that were already in the pool
+	Duplicates int
+	// Skipped are the certificates that were not added because they failed to parse
+	Skipped []SkippedCert
+}
+
+// appendCertsFromPEM does the work of AppendCertsFromPEM and AppendCertsFromPEMLax
+func (p *PEMCertPool) appendCertsFromPEM(pemCerts []byte, lax bool) LoadReport {
+	certs, report := parseCertsFromPEM(pemCerts, lax)
+	report.Duplicates = p.update(certs)
+	report.Added = len(certs) - report.Duplicates
+	return report
+}
+
+// parseCertsFromPEM parses the certificates in PEM data. Unless lax is set it stops at the
+// first certificate that fails to parse. The report lists the certificates that were skipped.
+func parseCertsFromPEM(pemCerts []byte, lax bool) ([]*x509.Certificate, LoadReport) {
+	var certs []*x509.Certificate
+	var report LoadReport
+
+	for index := 0; len(pemCerts) > 0; {
 		var block *pem.Block
 		block, pemCerts = pem.Decode(pemCerts)
 		if block == nil {
@@ -54,30 +169,120 @@ func (p *PEMCertPool) AppendCertsFromPEM(pemCerts []byte) (ok bool) {
 			continue
 		}
 
+		position := index
+		index++
+
 		cert, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
-			glog.Warningf("error parsing PEM certificate: %v", err)
-			return false
+			glog.Warningf("error parsing PEM certificate %d: %v", position, err)
+			_, nonFatal := err.(x509.NonFatalErrors)
+
+			if !lax || !nonFatal || cert == nil {
+				report.Skipped = append(report.Skipped, SkippedCert{Index: position, Err: err})
+
+				if lax {
+					continue
+				}
+
+				return certs, report
+			}
 		}
 
-		p.AddCert(cert)
-		ok = true
+		certs = append(certs, cert)
+	}
+
+	return certs, report
+}
+
+// Reload replaces all the certificates in the pool with those in PEM data, parsed strictly
+// unless lax is set. The pool is left unchanged if the data can't be loaded or has no
+// certificates. Requests that are already using the old certificates carry on with them.
+func (p *PEMCertPool) Reload(pemCerts []byte, lax bool) (LoadReport, error) {
+	certs, report := parseCertsFromPEM(pemCerts, lax)
+
+	if !lax && len(report.Skipped) > 0 {
+		return report, fmt.Errorf("failed to parse certificate %d: %v", report.Skipped[0].Index, report.Skipped[0].Err)
 	}
 
-	return
+	if len(certs) == 0 {
+		return report, errors.New("no certificates to reload the pool with")
+	}
+
+	updated := newCertSet()
+
+	for _, cert := range certs {
+		if updated.add(cert) {
+			report.Added++
+		} else {
+			report.Duplicates++
+		}
+	}
+
+	p.state.mutex.Lock()
+	defer p.state.mutex.Unlock()
+
+	p.state.certs = updated
+	return report, nil
+}
+
+// WatchFile reloads the pool from a PEM file whenever its contents change, checking every
+// period until done is closed. A file that can't be read or loaded is logged and the pool
+// keeps the certificates it had. The first check always reloads the file, so changes made
+// after it was first loaded aren't missed.
+func (p *PEMCertPool) WatchFile(path string, period time.Duration, lax bool, done <-chan struct{}) {
+	var lastHash [sha256.Size]byte
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			glog.Warningf("Failed to read certificates from %s: %v", path, err)
+			continue
+		}
+
+		hash := sha256.Sum256(data)
+
+		if hash == lastHash {
+			continue
+		}
+
+		// Don't retry contents that failed to load until they're changed again
+		lastHash = hash
+		report, err := p.Reload(data, lax)
+
+		for _, skipped := range report.Skipped {
+			glog.Warningf("Skipped certificate %d in %s: %v", skipped.Index, path, skipped.Err)
+		}
+
+		if err != nil {
+			glog.Warningf("Failed to reload certificates from %s: %v", path, err)
+			continue
+		}
+
+		glog.Infof("Reloaded %d certificates from %s", report.Added, path)
+	}
 }
 
 // Subjects returns a list of the DER-encoded subjects of all of the certificates in the pool.
 func (p *PEMCertPool) Subjects() (res [][]byte) {
-	return p.certPool.Subjects()
+	return p.current().certPool.Subjects()
 }
 
 // CertPool returns the underlying CertPool.
 func (p *PEMCertPool) CertPool() *x509.CertPool {
-	return p.certPool
+	return p.current().certPool
 }
 
 // RawCertificates returns a list of the raw bytes of certificates that are in this pool
 func (p *PEMCertPool) RawCertificates() []*x509.Certificate {
-	return p.rawCerts
+	return p.current().rawCerts
 }
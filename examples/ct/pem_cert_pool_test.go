@@ -1,7 +1,11 @@
 package ct
 
 import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/google/trillian/examples/ct/testonly"
 )
@@ -45,3 +49,157 @@ func TestLoadMultipleCertsFromPEM(t *testing.T) {
 		t.Fatalf("Got %d certs in pool, expected %d", got, want)
 	}
 }
+
+// pemWithMalformedCert returns valid certs surrounding a block that decodes but isn't a
+// certificate
+func pemWithMalformedCert() []byte {
+	bad := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not a certificate")})
+	return []byte(testonly.CACertPEM + string(bad) + testonly.TestCertPEM)
+}
+
+func TestMalformedCertRejected(t *testing.T) {
+	pool := NewPEMCertPool()
+
+	if pool.AppendCertsFromPEM(pemWithMalformedCert()) {
+		t.Fatal("Expected malformed cert to be rejected")
+	}
+}
+
+func TestLaxLoadSkipsMalformedCert(t *testing.T) {
+	pool := NewPEMCertPool()
+
+	report := pool.AppendCertsFromPEMLax(pemWithMalformedCert())
+	if got, want := report.Added, 2; got != want {
+		t.Fatalf("Got %d cert(s) added, expected %d", got, want)
+	}
+	if got, want := len(pool.Subjects()), 2; got != want {
+		t.Fatalf("Got %d cert(s) in pool, expected %d", got, want)
+	}
+	if got, want := len(report.Skipped), 1; got != want {
+		t.Fatalf("Got %d cert(s) skipped, expected %d", got, want)
+	}
+	if got, want := report.Skipped[0].Index, 1; got != want {
+		t.Fatalf("Got skipped cert at index %d, expected %d", got, want)
+	}
+	if report.Skipped[0].Err == nil {
+		t.Fatal("Expected an error for the skipped cert")
+	}
+}
+
+func TestLaxLoadCountsDuplicates(t *testing.T) {
+	pool := NewPEMCertPool()
+
+	report := pool.AppendCertsFromPEMLax([]byte(testonly.CACertPEMDuplicated))
+	if report.Added != 1 || report.Duplicates != 1 || len(report.Skipped) != 0 {
+		t.Fatalf("Got report %+v, expected 1 cert added and 1 duplicate", report)
+	}
+}
+
+func TestLaxLoadAcceptsNonFatalErrors(t *testing.T) {
+	// The CT extension in a precert is reported as a non fatal error
+	if NewPEMCertPool().AppendCertsFromPEM([]byte(testonly.PrecertPEMValid)) {
+		t.Fatal("Expected strict load to reject cert with non fatal errors")
+	}
+
+	pool := NewPEMCertPool()
+
+	report := pool.AppendCertsFromPEMLax([]byte(testonly.PrecertPEMValid))
+	if got, want := report.Added, 1; got != want || len(report.Skipped) != 0 {
+		t.Fatalf("Got %d cert(s) added and %v skipped, expected %d added", got, report.Skipped, want)
+	}
+}
+
+func TestReloadReplacesCerts(t *testing.T) {
+	pool := NewPEMCertPool()
+
+	if !pool.AppendCertsFromPEM([]byte(testonly.CACertPEM)) {
+		t.Fatal("Expected to append a certificate ok")
+	}
+
+	snapshot := pool.Snapshot()
+	poolCopy := *pool
+
+	report, err := pool.Reload([]byte(testonly.CACertMultiplePEM), false)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading pool: %v", err)
+	}
+	if got, want := report.Added, 2; got != want {
+		t.Fatalf("Got %d cert(s) reloaded, expected %d", got, want)
+	}
+	if got, want := len(pool.RawCertificates()), 2; got != want {
+		t.Fatalf("Got %d cert(s) in pool, expected %d", got, want)
+	}
+	// Copies of the pool share its certificates
+	if got, want := len(poolCopy.RawCertificates()), 2; got != want {
+		t.Fatalf("Got %d cert(s) in copy of pool, expected %d", got, want)
+	}
+	// But a snapshot keeps the ones it had
+	if got, want := len(snapshot.RawCertificates()), 1; got != want {
+		t.Fatalf("Got %d cert(s) in snapshot, expected %d", got, want)
+	}
+}
+
+func TestReloadFailureKeepsCerts(t *testing.T) {
+	pool := NewPEMCertPool()
+
+	if !pool.AppendCertsFromPEM([]byte(testonly.CACertPEM)) {
+		t.Fatal("Expected to append a certificate ok")
+	}
+
+	if _, err := pool.Reload(pemWithMalformedCert(), false); err == nil {
+		t.Fatal("Expected error reloading malformed cert")
+	}
+	if _, err := pool.Reload([]byte(testonly.UnknownBlockTypePEM), true); err == nil {
+		t.Fatal("Expected error reloading data with no certs")
+	}
+	if got, want := len(pool.RawCertificates()), 1; got != want {
+		t.Fatalf("Got %d cert(s) in pool, expected %d", got, want)
+	}
+
+	// In lax mode the malformed cert is just skipped
+	report, err := pool.Reload(pemWithMalformedCert(), true)
+	if err != nil {
+		t.Fatalf("Unexpected error reloading pool: %v", err)
+	}
+	if got, want := len(report.Skipped), 1; got != want {
+		t.Fatalf("Got %d cert(s) skipped, expected %d", got, want)
+	}
+	if got, want := len(pool.RawCertificates()), 2; got != want {
+		t.Fatalf("Got %d cert(s) in pool, expected %d", got, want)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "roots")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(testonly.CACertPEM); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	f.Close()
+
+	pool := NewPEMCertPool()
+
+	if !pool.AppendCertsFromPEM([]byte(testonly.CACertPEM)) {
+		t.Fatal("Expected to append a certificate ok")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go pool.WatchFile(f.Name(), time.Millisecond, false, done)
+
+	if err := ioutil.WriteFile(f.Name(), []byte(testonly.CACertMultiplePEM), 0644); err != nil {
+		t.Fatalf("Failed to update temp file: %v", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); len(pool.RawCertificates()) != 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %d cert(s) in pool, expected changed file to be reloaded", len(pool.RawCertificates()))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
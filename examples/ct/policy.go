@@ -0,0 +1,187 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// RejectionReason identifies the rule of a ValidationPolicy that a chain broke
+type RejectionReason string
+
+const (
+	// RejectedChainTooLong means more certificates were submitted than the log accepts
+	RejectedChainTooLong RejectionReason = "chain_too_long"
+	// RejectedExtKeyUsage means the certificate can't be used for any of the purposes the
+	// log accepts
+	RejectedExtKeyUsage RejectionReason = "unacceptable_ext_key_usage"
+	// RejectedNotAfter means the certificate expires outside the range the log accepts,
+	// usually because the log is one shard of a set split by expiry time
+	RejectedNotAfter RejectionReason = "not_after_out_of_range"
+	// RejectedIssuer means one of the issuers in the chain is not accepted by the log
+	RejectedIssuer RejectionReason = "rejected_issuer"
+)
+
+// PolicyError is returned for a chain that's rejected by a ValidationPolicy. The reason is
+// returned to the client along with the detail.
+type PolicyError struct {
+	Reason RejectionReason
+	Detail string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("chain rejected by policy (%s): %s", e.Reason, e.Detail)
+}
+
+// ValidationPolicy restricts the chains a log accepts beyond them having to chain to one of
+// its trusted roots. The zero value accepts every chain.
+type ValidationPolicy struct {
+	// ExtKeyUsages are the usages a certificate must allow one of. Certificates without an
+	// extended key usage extension are allowed any usage. Empty means no restriction.
+	ExtKeyUsages []x509.ExtKeyUsage
+	// MaxChainLength is the most certificates a chain can be submitted with, or 0 for no limit
+	MaxChainLength int
+	// NotAfterStart is the earliest expiry time accepted, inclusive, or zero for no limit
+	NotAfterStart time.Time
+	// NotAfterLimit is the expiry time certificates must expire before, or zero for no limit
+	NotAfterLimit time.Time
+	// RejectedIssuers are the SHA-256 fingerprints of issuer certificates that chains can't
+	// include, whether they're intermediates or the root
+	RejectedIssuers map[[sha256.Size]byte]bool
+}
+
+// checkChainLength checks a chain isn't too long before it's validated
+func (p *ValidationPolicy) checkChainLength(length int) error {
+	if p == nil || p.MaxChainLength == 0 || length <= p.MaxChainLength {
+		return nil
+	}
+
+	return &PolicyError{Reason: RejectedChainTooLong, Detail: fmt.Sprintf("chain has %d certificates, at most %d are accepted", length, p.MaxChainLength)}
+}
+
+// Check applies the policy to a path returned by ValidateChain. It returns a *PolicyError if
+// the chain breaks one of the rules. A nil policy accepts every chain.
+func (p *ValidationPolicy) Check(validPath []*x509.Certificate, trustedRoots PEMCertPool) error {
+	if p == nil {
+		return nil
+	}
+
+	if err := p.checkChainLength(len(validPath)); err != nil {
+		return err
+	}
+
+	cert := validPath[0]
+
+	if !p.allowsUsage(cert) {
+		return &PolicyError{Reason: RejectedExtKeyUsage, Detail: fmt.Sprintf("certificate usages %v don't include any of %v", cert.ExtKeyUsage, p.ExtKeyUsages)}
+	}
+
+	if !p.NotAfterStart.IsZero() && cert.NotAfter.Before(p.NotAfterStart) {
+		return &PolicyError{Reason: RejectedNotAfter, Detail: fmt.Sprintf("certificate expires at %v, before %v", cert.NotAfter, p.NotAfterStart)}
+	}
+
+	if !p.NotAfterLimit.IsZero() && !cert.NotAfter.Before(p.NotAfterLimit) {
+		return &PolicyError{Reason: RejectedNotAfter, Detail: fmt.Sprintf("certificate expires at %v, not before %v", cert.NotAfter, p.NotAfterLimit)}
+	}
+
+	if len(p.RejectedIssuers) == 0 {
+		return nil
+	}
+
+	issuers := append([]*x509.Certificate{}, validPath[1:]...)
+
+	// The path doesn't include the root
+	if root, err := issuerInPath(validPath, len(validPath)-1, trustedRoots); err == nil {
+		issuers = append(issuers, root)
+	}
+
+	for _, issuer := range issuers {
+		if fingerprint := sha256.Sum256(issuer.Raw); p.RejectedIssuers[fingerprint] {
+			return &PolicyError{Reason: RejectedIssuer, Detail: fmt.Sprintf("issuer %s (%x) is not accepted", issuer.Subject.CommonName, fingerprint)}
+		}
+	}
+
+	return nil
+}
+
+// allowsUsage returns true if a certificate can be used for one of the policy's usages
+func (p *ValidationPolicy) allowsUsage(cert *x509.Certificate) bool {
+	if len(p.ExtKeyUsages) == 0 || (len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0) {
+		return true
+	}
+
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageAny {
+			return true
+		}
+
+		for _, accepted := range p.ExtKeyUsages {
+			if usage == accepted {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// extKeyUsageNames maps the names accepted by ParseExtKeyUsages to usages
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"OCSPSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// ParseExtKeyUsages parses a comma separated list of extended key usages, e.g.
+// "serverAuth,clientAuth". An empty string gives no usages.
+func ParseExtKeyUsages(s string) ([]x509.ExtKeyUsage, error) {
+	var usages []x509.ExtKeyUsage
+
+	if len(s) == 0 {
+		return usages, nil
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		usage, ok := extKeyUsageNames[name]
+
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage: %q", name)
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
+
+// ParseFingerprints parses a comma separated list of hex encoded SHA-256 certificate
+// fingerprints. An empty string gives no fingerprints.
+func ParseFingerprints(s string) (map[[sha256.Size]byte]bool, error) {
+	fingerprints := make(map[[sha256.Size]byte]bool)
+
+	if len(s) == 0 {
+		return fingerprints, nil
+	}
+
+	for _, hexFingerprint := range strings.Split(s, ",") {
+		b, err := hex.DecodeString(hexFingerprint)
+
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 fingerprint: %q", hexFingerprint)
+		}
+
+		var fingerprint [sha256.Size]byte
+		copy(fingerprint[:], b)
+		fingerprints[fingerprint] = true
+	}
+
+	return fingerprints, nil
+}
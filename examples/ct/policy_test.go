@@ -0,0 +1,170 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// policyTestPath returns a validated path for a leaf issued by an intermediate, and the
+// trusted root it chains to
+func policyTestPath(t *testing.T) ([]*x509.Certificate, PEMCertPool) {
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.FakeCACertPem)) {
+		t.Fatal("failed to load fake root")
+	}
+
+	jsonChain := pemsToJsonChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	validPath, err := ValidateChain(jsonChain, *trustedRoots)
+
+	if err != nil {
+		t.Fatalf("unexpected error verifying valid chain %v", err)
+	}
+
+	return validPath, *trustedRoots
+}
+
+// checkRejection fails the test unless err is a PolicyError for the reason
+func checkRejection(t *testing.T, err error, reason RejectionReason) {
+	policyErr, ok := err.(*PolicyError)
+
+	if !ok {
+		t.Fatalf("got error %v, expected a policy rejection for %s", err, reason)
+	}
+	if got, want := policyErr.Reason, reason; got != want {
+		t.Fatalf("got rejection for %s, expected %s", got, want)
+	}
+}
+
+func TestNilPolicyAcceptsChain(t *testing.T) {
+	validPath, trustedRoots := policyTestPath(t)
+	var policy *ValidationPolicy
+
+	if err := policy.Check(validPath, trustedRoots); err != nil {
+		t.Fatalf("nil policy rejected chain: %v", err)
+	}
+	if err := (&ValidationPolicy{}).Check(validPath, trustedRoots); err != nil {
+		t.Fatalf("empty policy rejected chain: %v", err)
+	}
+}
+
+func TestPolicyMaxChainLength(t *testing.T) {
+	validPath, trustedRoots := policyTestPath(t)
+
+	if err := (&ValidationPolicy{MaxChainLength: 2}).Check(validPath, trustedRoots); err != nil {
+		t.Fatalf("rejected chain at max length: %v", err)
+	}
+
+	checkRejection(t, (&ValidationPolicy{MaxChainLength: 1}).Check(validPath, trustedRoots), RejectedChainTooLong)
+}
+
+func TestPolicyExtKeyUsages(t *testing.T) {
+	validPath, trustedRoots := policyTestPath(t)
+	policy := &ValidationPolicy{ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+
+	for _, test := range []struct {
+		usages []x509.ExtKeyUsage
+		ok     bool
+	}{
+		{nil, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageAny}, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, false},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageEmailProtection}, false},
+	} {
+		validPath[0].ExtKeyUsage = test.usages
+		validPath[0].UnknownExtKeyUsage = nil
+		err := policy.Check(validPath, trustedRoots)
+
+		if test.ok && err != nil {
+			t.Errorf("cert with usages %v rejected: %v", test.usages, err)
+		}
+		if !test.ok {
+			checkRejection(t, err, RejectedExtKeyUsage)
+		}
+	}
+}
+
+func TestPolicyNotAfterRange(t *testing.T) {
+	validPath, trustedRoots := policyTestPath(t)
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := &ValidationPolicy{NotAfterStart: start, NotAfterLimit: limit}
+
+	for _, test := range []struct {
+		notAfter time.Time
+		ok       bool
+	}{
+		{start.Add(-time.Second), false},
+		{start, true},
+		{limit.Add(-time.Second), true},
+		{limit, false},
+	} {
+		validPath[0].NotAfter = test.notAfter
+		err := policy.Check(validPath, trustedRoots)
+
+		if test.ok && err != nil {
+			t.Errorf("cert expiring at %v rejected: %v", test.notAfter, err)
+		}
+		if !test.ok {
+			checkRejection(t, err, RejectedNotAfter)
+		}
+	}
+}
+
+func TestPolicyRejectedIssuers(t *testing.T) {
+	validPath, trustedRoots := policyTestPath(t)
+
+	for _, issuer := range []*x509.Certificate{validPath[1], trustedRoots.RawCertificates()[0]} {
+		policy := &ValidationPolicy{RejectedIssuers: map[[sha256.Size]byte]bool{sha256.Sum256(issuer.Raw): true}}
+		checkRejection(t, policy.Check(validPath, trustedRoots), RejectedIssuer)
+	}
+
+	// The leaf isn't an issuer
+	policy := &ValidationPolicy{RejectedIssuers: map[[sha256.Size]byte]bool{sha256.Sum256(validPath[0].Raw): true}}
+
+	if err := policy.Check(validPath, trustedRoots); err != nil {
+		t.Fatalf("chain rejected for its leaf: %v", err)
+	}
+}
+
+func TestParseExtKeyUsages(t *testing.T) {
+	usages, err := ParseExtKeyUsages("serverAuth,any")
+
+	if err != nil {
+		t.Fatalf("unexpected error parsing usages: %v", err)
+	}
+	if len(usages) != 2 || usages[0] != x509.ExtKeyUsageServerAuth || usages[1] != x509.ExtKeyUsageAny {
+		t.Fatalf("got usages %v, expected serverAuth and any", usages)
+	}
+
+	for _, s := range []string{"serverauth", "serverAuth,", "1.2.3"} {
+		if _, err := ParseExtKeyUsages(s); err == nil {
+			t.Errorf("incorrectly parsed usages %q", s)
+		}
+	}
+}
+
+func TestParseFingerprints(t *testing.T) {
+	fingerprint := sha256.Sum256([]byte("issuer"))
+	fingerprints, err := ParseFingerprints(hex.EncodeToString(fingerprint[:]))
+
+	if err != nil {
+		t.Fatalf("unexpected error parsing fingerprints: %v", err)
+	}
+	if len(fingerprints) != 1 || !fingerprints[fingerprint] {
+		t.Fatalf("got fingerprints %v, expected %x", fingerprints, fingerprint)
+	}
+
+	for _, s := range []string{"abcd", "xyz", hex.EncodeToString(fingerprint[:]) + ","} {
+		if _, err := ParseFingerprints(s); err == nil {
+			t.Errorf("incorrectly parsed fingerprints %q", s)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ct
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// OID of the X.509 Authority Key Identifier extension
+var authorityKeyIdentifierOID = asn1.ObjectIdentifier{2, 5, 29, 35}
+
+// tbsExtension is an X.509 extension as it's encoded in a TBSCertificate. The certificates
+// are parsed by the CT fork of the X.509 code but this is re-encoded with the standard ASN.1
+// package.
+type tbsExtension struct {
+	Id       asn1.ObjectIdentifier
+	Critical bool `asn1:"optional"`
+	Value    []byte
+}
+
+// BuildPrecertTBS returns the TBSCertificate of a pre-certificate as it's included in a CT
+// log entry, defined in RFC 6962 Section 3.2. The poison extension is removed and, if the
+// pre-certificate was issued by a Precertificate Signing Certificate, the issuer and
+// authority key identifier are replaced with the ones the final certificate will have. The
+// signing cert should be nil otherwise.
+func BuildPrecertTBS(rawTBS []byte, signingCert *x509.Certificate) ([]byte, error) {
+	var tbs asn1.RawValue
+
+	if rest, err := asn1.Unmarshal(rawTBS, &tbs); err != nil {
+		return nil, fmt.Errorf("failed to parse TBS certificate: %v", err)
+	} else if len(rest) > 0 || tbs.Class != asn1.ClassUniversal || tbs.Tag != asn1.TagSequence {
+		return nil, errors.New("TBS certificate is not a single sequence")
+	}
+
+	var fields []asn1.RawValue
+
+	for rest := tbs.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, fmt.Errorf("failed to parse TBS certificate field: %v", err)
+		}
+
+		fields = append(fields, field)
+	}
+
+	// The version is optional and comes before the serial number, signature algorithm and
+	// then the issuer
+	issuerIndex := 2
+
+	if len(fields) > 0 && fields[0].Class == asn1.ClassContextSpecific && fields[0].Tag == 0 {
+		issuerIndex++
+	}
+
+	if len(fields) <= issuerIndex {
+		return nil, errors.New("TBS certificate is missing fields")
+	}
+
+	if signingCert != nil {
+		fields[issuerIndex] = asn1.RawValue{FullBytes: signingCert.RawIssuer}
+	}
+
+	var out bytes.Buffer
+	hasExtensions := false
+
+	for _, field := range fields {
+		// Extensions are the only field with explicit tag 3
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			extBytes, err := buildPrecertExtensions(field.Bytes, signingCert)
+
+			if err != nil {
+				return nil, err
+			}
+
+			field = asn1.RawValue{FullBytes: extBytes}
+			hasExtensions = true
+		}
+
+		out.Write(field.FullBytes)
+	}
+
+	if !hasExtensions {
+		return nil, errors.New("pre-certificate does not have any extensions")
+	}
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: out.Bytes()})
+}
+
+// buildPrecertExtensions does the work of BuildPrecertTBS for the extensions, returning the
+// explicitly tagged field to use in the TBSCertificate. This is empty if there aren't any
+// extensions left.
+func buildPrecertExtensions(rawExts []byte, signingCert *x509.Certificate) ([]byte, error) {
+	var exts []tbsExtension
+
+	if rest, err := asn1.Unmarshal(rawExts, &exts); err != nil {
+		return nil, fmt.Errorf("failed to parse TBS certificate extensions: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after TBS certificate extensions")
+	}
+
+	// If the signing cert doesn't have an authority key identifier the final cert won't either
+	var signingAKI *tbsExtension
+
+	if signingCert != nil {
+		for _, ext := range signingCert.Extensions {
+			if authorityKeyIdentifierOID.Equal(asn1.ObjectIdentifier(ext.Id)) {
+				signingAKI = &tbsExtension{Id: authorityKeyIdentifierOID, Critical: ext.Critical, Value: ext.Value}
+			}
+		}
+	}
+
+	kept := make([]tbsExtension, 0, len(exts))
+	poisoned := false
+
+	for _, ext := range exts {
+		switch {
+		case ext.Id.Equal(asn1.ObjectIdentifier(ctPoisonExtensionOID)):
+			poisoned = true
+		case signingCert != nil && ext.Id.Equal(authorityKeyIdentifierOID):
+			if signingAKI != nil {
+				kept = append(kept, *signingAKI)
+			}
+		default:
+			kept = append(kept, ext)
+		}
+	}
+
+	if !poisoned {
+		return nil, errors.New("pre-certificate does not have the CT poison extension")
+	}
+
+	// The extensions must be left out entirely rather than encoded as an empty sequence
+	if len(kept) == 0 {
+		return nil, nil
+	}
+
+	extBytes, err := asn1.Marshal(kept)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize TBS certificate extensions: %v", err)
+	}
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: extBytes})
+}
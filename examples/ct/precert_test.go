@@ -0,0 +1,69 @@
+package ct
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// DER encoding of the OID of the CT poison extension
+var ctPoisonOIDBytes = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0xd6, 0x79, 0x02, 0x04, 0x03}
+
+func TestBuildPrecertTBS(t *testing.T) {
+	cert := pemToCert(t, testonly.PrecertPEMValid)
+
+	tbs, err := BuildPrecertTBS(cert.RawTBSCertificate, nil)
+
+	if err != nil {
+		t.Fatalf("unexpected error building precert TBS: %v", err)
+	}
+	if bytes.Contains(tbs, ctPoisonOIDBytes) {
+		t.Fatal("poison extension was not removed")
+	}
+	// Only the poison extension, 21 bytes with its header, should have gone
+	if got, want := len(tbs), len(cert.RawTBSCertificate)-21; got != want {
+		t.Fatalf("got TBS of %d bytes, expected %d", got, want)
+	}
+	// The issuer is unchanged when the precert is issued by the CA itself
+	if !bytes.Contains(tbs, cert.RawIssuer) {
+		t.Fatal("issuer was changed")
+	}
+}
+
+func TestBuildPrecertTBSSigningCert(t *testing.T) {
+	precert := pemToCert(t, testonly.PrecertSignedBySigningCertPEM)
+	signingCert := pemToCert(t, testonly.PrecertSigningCertPEM)
+	final := pemToCert(t, testonly.CertMatchingPrecertPEM)
+
+	tbs, err := BuildPrecertTBS(precert.RawTBSCertificate, signingCert)
+
+	if err != nil {
+		t.Fatalf("unexpected error building precert TBS: %v", err)
+	}
+	// Issuer and authority key identifier now match the final cert
+	if got, want := tbs, final.RawTBSCertificate; !bytes.Equal(got, want) {
+		t.Fatalf("precert TBS %x does not match final cert TBS %x", got, want)
+	}
+}
+
+func TestBuildPrecertTBSNotPrecert(t *testing.T) {
+	// Has extensions but none of them is the poison
+	cert := pemToCert(t, testonly.TestCertPEM)
+
+	if _, err := BuildPrecertTBS(cert.RawTBSCertificate, nil); err == nil {
+		t.Fatal("incorrectly built precert TBS for cert without poison extension")
+	}
+}
+
+func TestBuildPrecertTBSInvalid(t *testing.T) {
+	cert := pemToCert(t, testonly.PrecertPEMValid)
+	raw := cert.RawTBSCertificate
+	trailing := append(append([]byte{}, raw...), 0x00)
+
+	for _, tbs := range [][]byte{{}, raw[:len(raw)-1], trailing} {
+		if _, err := BuildPrecertTBS(tbs, nil); err == nil {
+			t.Errorf("incorrectly built precert TBS from invalid data %x", tbs)
+		}
+	}
+}
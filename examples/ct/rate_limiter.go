@@ -0,0 +1,37 @@
+package ct
+
+import (
+	"sync"
+
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/util"
+)
+
+// rateLimiter limits the rate of requests to a log with a token bucket, each request takes
+// one token
+type rateLimiter struct {
+	limit      quota.Limit
+	timeSource util.TimeSource
+	// Must hold this lock before accessing bucket
+	mutex  sync.Mutex
+	bucket quota.Bucket
+}
+
+func newRateLimiter(limit quota.Limit, timeSource util.TimeSource) *rateLimiter {
+	return &rateLimiter{limit: limit, timeSource: timeSource, bucket: quota.NewBucket(limit, timeSource.Now())}
+}
+
+// allow returns true if a request can go ahead, taking a token for it. A nil limiter allows
+// every request.
+func (r *rateLimiter) allow() bool {
+	if r == nil {
+		return true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var ok bool
+	r.bucket, ok = r.bucket.Take(r.limit, 1, r.timeSource.Now())
+	return ok
+}
@@ -0,0 +1,37 @@
+package ct
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/util"
+)
+
+func TestRateLimiter(t *testing.T) {
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTime}
+	limiter := newRateLimiter(quota.Limit{Capacity: 2, TokensPerSecond: 1}, timeSource)
+
+	// Starts full
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.allow(); got != want {
+			t.Fatalf("got %v for request %d, expected %v", got, i, want)
+		}
+	}
+
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Second)
+
+	for i, want := range []bool{true, false} {
+		if got := limiter.allow(); got != want {
+			t.Fatalf("got %v for request %d after refill, expected %v", got, i, want)
+		}
+	}
+}
+
+func TestNilRateLimiterAllows(t *testing.T) {
+	var limiter *rateLimiter
+
+	if !limiter.allow() {
+		t.Fatal("nil limiter refused a request")
+	}
+}
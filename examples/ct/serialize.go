@@ -14,10 +14,45 @@ import (
 	"github.com/google/trillian/crypto"
 )
 
+// ctED25519 is the TLS SignatureAlgorithm value assigned to Ed25519, which the CT library
+// doesn't define.
+const ctED25519 ct.SignatureAlgorithm = 7
+
+// ctSignatureAlgorithms maps the signature algorithms of log keys to their CT equivalents.
+var ctSignatureAlgorithms = map[trillian.SignatureAlgorithm]ct.SignatureAlgorithm{
+	trillian.SignatureAlgorithm_ECDSA:   ct.ECDSA,
+	trillian.SignatureAlgorithm_RSA:     ct.RSA,
+	trillian.SignatureAlgorithm_ED25519: ctED25519,
+}
+
+// signerForKeyManager returns a TrillianSigner using the key held by a key manager, along
+// with the CT signature algorithm for the type of key.
+func signerForKeyManager(km crypto.KeyManager) (*crypto.TrillianSigner, ct.SignatureAlgorithm, error) {
+	signer, err := km.Signer()
+
+	if err != nil {
+		return nil, ct.Anonymous, err
+	}
+
+	sigAlgorithm, err := crypto.SignatureAlgorithmForKey(signer.Public())
+
+	if err != nil {
+		return nil, ct.Anonymous, err
+	}
+
+	ctAlgorithm, ok := ctSignatureAlgorithms[sigAlgorithm]
+
+	if !ok {
+		return nil, ct.Anonymous, fmt.Errorf("no CT signature algorithm for %v", sigAlgorithm)
+	}
+
+	return crypto.NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer), ctAlgorithm, nil
+}
+
 // SignV1TreeHead signs a tree head for CT. The input STH should have been built from a
 // backend response and already checked for validity.
 func signV1TreeHead(km crypto.KeyManager, sth *ct.SignedTreeHead) error {
-	signer, err := km.Signer()
+	trillianSigner, ctAlgorithm, err := signerForKeyManager(km)
 
 	if err != nil {
 		return err
@@ -29,9 +64,6 @@ func signV1TreeHead(km crypto.KeyManager, sth *ct.SignedTreeHead) error {
 		return err
 	}
 
-	// TODO(Martin2112): Algorithms shouldn't be hardcoded here, needs more work in key manager
-	trillianSigner := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_RSA, signer)
-
 	signature, err := trillianSigner.Sign(sthBytes)
 
 	if err != nil {
@@ -40,7 +72,7 @@ func signV1TreeHead(km crypto.KeyManager, sth *ct.SignedTreeHead) error {
 
 	sth.TreeHeadSignature = ct.DigitallySigned{
 		HashAlgorithm:      ct.SHA256,
-		SignatureAlgorithm: ct.RSA,
+		SignatureAlgorithm: ctAlgorithm,
 		Signature:          signature.Signature}
 
 	return nil
@@ -60,16 +92,25 @@ func signV1SCTForCertificate(km crypto.KeyManager, cert *x509.Certificate, t tim
 }
 
 // SignV1SCTForPrecertificate builds and signs a V1 CT SCT for a pre-certificate using the key
-// held by a key manager.
-func signV1SCTForPrecertificate(km crypto.KeyManager, cert *x509.Certificate, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
+// held by a key manager. The issuer is the CA that will issue the final certificate and the
+// signing cert is the Precertificate Signing Certificate it used, if any, as returned by
+// PrecertIssuer.
+func signV1SCTForPrecertificate(km crypto.KeyManager, cert, issuer, signingCert *x509.Certificate, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
 	// Temp SCT for input to the serializer
 	sctInput := getSCTForSignatureInput(t)
 
 	// Build up a LogEntry for the precert
 	// For precerts we need to extract the relevant data from the Certificate container.
-	// This is only possible using the CT specific modified version of X.509.
-	keyHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
-	precert := ct.PreCert{IssuerKeyHash: keyHash, TBSCertificate: cert.RawTBSCertificate}
+	// This is only possible using the CT specific modified version of X.509. The entry is
+	// bound to the key of the CA rather than that of the precert itself.
+	keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	tbs, err := BuildPrecertTBS(cert.RawTBSCertificate, signingCert)
+
+	if err != nil {
+		return ct.MerkleTreeLeaf{}, ct.SignedCertificateTimestamp{}, err
+	}
+
+	precert := ct.PreCert{IssuerKeyHash: keyHash, TBSCertificate: tbs}
 
 	timestampedEntry := ct.TimestampedEntry{Timestamp: sctInput.Timestamp, EntryType: ct.PrecertLogEntryType, PrecertEntry: precert}
 	leaf := ct.MerkleTreeLeaf{Version: ct.V1, LeafType: ct.TimestampedEntryLeafType, TimestampedEntry: timestampedEntry}
@@ -92,14 +133,11 @@ func serializeAndSignSCT(km crypto.KeyManager, leaf ct.MerkleTreeLeaf, sctInput
 }
 
 func signSCT(km crypto.KeyManager, t time.Time, sctData []byte) (ct.SignedCertificateTimestamp, error) {
-	signer, err := km.Signer()
+	trillianSigner, ctAlgorithm, err := signerForKeyManager(km)
 	if err != nil {
 		return ct.SignedCertificateTimestamp{}, err
 	}
 
-	// TODO(Martin2112): Algorithms shouldn't be hardcoded here, needs more work in key manager
-	trillianSigner := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_RSA, signer)
-
 	signature, err := trillianSigner.Sign(sctData)
 
 	if err != nil {
@@ -108,7 +146,7 @@ func signSCT(km crypto.KeyManager, t time.Time, sctData []byte) (ct.SignedCertif
 
 	digitallySigned := ct.DigitallySigned{
 		HashAlgorithm:      ct.SHA256,
-		SignatureAlgorithm: ct.RSA,
+		SignatureAlgorithm: ctAlgorithm,
 		Signature:          signature.Signature}
 
 	logID, err := GetCTLogID(km)
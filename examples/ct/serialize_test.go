@@ -84,9 +84,16 @@ func TestSignV1SCTForPrecertificate(t *testing.T) {
 		t.Fatalf("failed to set up test precert: %v", err)
 	}
 
-	km := setupMockKeyManager(mockCtrl, []byte{0x77, 0xf3, 0x5c, 0xc6, 0xad, 0x85, 0xfd, 0xe0, 0x38, 0xfd, 0x36, 0x34, 0x5c, 0x1e, 0x45, 0x58, 0x60, 0x95, 0xb1, 0x7c, 0x28, 0xaa, 0xa5, 0xa5, 0x84, 0x96, 0x37, 0x4b, 0xf8, 0xbb, 0xd9, 0x8})
+	issuer, err := fixchain.CertificateFromPEM(testonly.CACertPEM)
+	_, ok = err.(x509.NonFatalErrors)
 
-	leaf, got, err := signV1SCTForPrecertificate(km, cert, fixedTime)
+	if err != nil && !ok {
+		t.Fatalf("failed to set up test precert issuer: %v", err)
+	}
+
+	km := setupMockKeyManager(mockCtrl, []byte{0x6c, 0x76, 0xbc, 0x72, 0x7e, 0xce, 0x65, 0xc1, 0xb1, 0x77, 0x9d, 0xa0, 0x22, 0x23, 0x31, 0x30, 0xfa, 0x36, 0x1e, 0xbf, 0xe3, 0x59, 0xe3, 0x82, 0x81, 0xbd, 0xef, 0xf0, 0x4f, 0x7b, 0x1f, 0x58})
+
+	leaf, got, err := signV1SCTForPrecertificate(km, cert, issuer, nil, fixedTime)
 
 	if err != nil {
 		t.Fatalf("create sct for precert failed", err)
@@ -113,8 +120,13 @@ func TestSignV1SCTForPrecertificate(t *testing.T) {
 		t.Fatalf("Mismatched SCT (precert), got %v, expected %v", got, expected)
 	}
 
-	// Additional checks that the MerkleTreeLeaf we built is correct
-	keyHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	// The entry is bound to the key of the issuer, not the precert
+	keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	tbs, err := BuildPrecertTBS(cert.RawTBSCertificate, nil)
+
+	if err != nil {
+		t.Fatalf("failed to build precert TBS: %v", err)
+	}
 
 	// Additional checks that the MerkleTreeLeaf we built is correct
 	if got, want := leaf.Version, ct.V1; got != want {
@@ -132,7 +144,7 @@ func TestSignV1SCTForPrecertificate(t *testing.T) {
 	if got, want := keyHash[:], leaf.TimestampedEntry.PrecertEntry.IssuerKeyHash[:]; !bytes.Equal(got, want) {
 		t.Fatalf("Issuer key hash bytes mismatch, got %v, expected %v", got, want)
 	}
-	if got, want := leaf.TimestampedEntry.PrecertEntry.TBSCertificate, cert.RawTBSCertificate; !bytes.Equal(got, want) {
+	if got, want := leaf.TimestampedEntry.PrecertEntry.TBSCertificate, tbs; !bytes.Equal(got, want) {
 		t.Fatalf("TBS cert mismatch, got %v, expected %v", got, want)
 	}
 }
@@ -0,0 +1,194 @@
+package ct
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// LogShard is one of a set of logs that split the certificates they accept between them by
+// expiry time, so that each log can be retired once everything in it has expired
+type LogShard struct {
+	// Name identifies the shard, its requests are served under "/<name>/ct/v1/"
+	Name string
+	// NotAfterStart is the earliest expiry time the shard accepts, inclusive, or zero for no limit
+	NotAfterStart time.Time
+	// NotAfterLimit is the time certificates in the shard must expire before, or zero for no limit
+	NotAfterLimit time.Time
+	// Handlers serves the shard's log, which has its own tree and keys
+	Handlers *CTRequestHandlers
+}
+
+// accepts returns true if a certificate expiring at notAfter belongs in the shard
+func (s LogShard) accepts(notAfter time.Time) bool {
+	return (s.NotAfterStart.IsZero() || !notAfter.Before(s.NotAfterStart)) && (s.NotAfterLimit.IsZero() || notAfter.Before(s.NotAfterLimit))
+}
+
+// TemporalShards serves a set of temporally sharded logs from one process. Each shard has its
+// own endpoints, and chains submitted to the shared add-chain and add-pre-chain endpoints are
+// sent to the shard for the expiry time of their leaf.
+type TemporalShards struct {
+	// shards are ordered by the start of their expiry time range
+	shards []LogShard
+}
+
+// NewTemporalShards creates a new instance of TemporalShards. The shards must have distinct
+// names and expiry time ranges that don't overlap. Each shard's validation policy is set to
+// only accept certificates in its range, so chains submitted to it directly are checked too.
+// They must still be registered by calling RegisterCTHandlers()
+func NewTemporalShards(shards []LogShard) (*TemporalShards, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no log shards")
+	}
+
+	sorted := append([]LogShard{}, shards...)
+	sort.Sort(byNotAfterStart(sorted))
+	names := make(map[string]bool)
+
+	for i, shard := range sorted {
+		if len(shard.Name) == 0 || strings.Contains(shard.Name, "/") {
+			return nil, fmt.Errorf("invalid log shard name: %q", shard.Name)
+		}
+
+		if names[shard.Name] {
+			return nil, fmt.Errorf("duplicate log shard name: %s", shard.Name)
+		}
+
+		names[shard.Name] = true
+
+		if shard.Handlers == nil {
+			return nil, fmt.Errorf("log shard %s has no handlers", shard.Name)
+		}
+
+		if !shard.NotAfterStart.IsZero() && !shard.NotAfterLimit.IsZero() && !shard.NotAfterStart.Before(shard.NotAfterLimit) {
+			return nil, fmt.Errorf("log shard %s has an empty expiry time range", shard.Name)
+		}
+
+		if i > 0 {
+			previous := sorted[i-1]
+
+			if previous.NotAfterLimit.IsZero() || shard.NotAfterStart.IsZero() || shard.NotAfterStart.Before(previous.NotAfterLimit) {
+				return nil, fmt.Errorf("log shards %s and %s have overlapping expiry time ranges", previous.Name, shard.Name)
+			}
+		}
+	}
+
+	for _, shard := range sorted {
+		var policy ValidationPolicy
+
+		if shard.Handlers.policy != nil {
+			policy = *shard.Handlers.policy
+		}
+
+		policy.NotAfterStart = shard.NotAfterStart
+		policy.NotAfterLimit = shard.NotAfterLimit
+		shard.Handlers.SetValidationPolicy(&policy)
+	}
+
+	return &TemporalShards{shards: sorted}, nil
+}
+
+// byNotAfterStart sorts shards by the start of their expiry time range, earliest first
+type byNotAfterStart []LogShard
+
+func (s byNotAfterStart) Len() int {
+	return len(s)
+}
+
+func (s byNotAfterStart) Less(i, j int) bool {
+	return s[i].NotAfterStart.Before(s[j].NotAfterStart)
+}
+
+func (s byNotAfterStart) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// ShardFor returns the shard that accepts certificates expiring at notAfter, or false if
+// there isn't one
+func (t *TemporalShards) ShardFor(notAfter time.Time) (LogShard, bool) {
+	for _, shard := range t.shards {
+		if shard.accepts(notAfter) {
+			return shard, true
+		}
+	}
+
+	return LogShard{}, false
+}
+
+// shardForChain returns the shard that a chain should be submitted to, based on the expiry
+// time of its leaf. The chain is fully validated by the shard.
+func (t *TemporalShards) shardForChain(req addChainRequest) (LogShard, error) {
+	leafBytes, err := base64.StdEncoding.DecodeString(req.Chain[0])
+
+	if err != nil {
+		return LogShard{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(leafBytes)
+
+	if err != nil {
+		// Pre-certificates give non fatal errors
+		if _, ok := err.(x509.NonFatalErrors); !ok || leaf == nil {
+			return LogShard{}, err
+		}
+	}
+
+	shard, ok := t.ShardFor(leaf.NotAfter)
+
+	if !ok {
+		return LogShard{}, &PolicyError{Reason: RejectedNotAfter, Detail: fmt.Sprintf("no log shard accepts certificates expiring at %v", leaf.NotAfter)}
+	}
+
+	return shard, nil
+}
+
+// wrappedShardedAddChainHandler sends add-chain and add-pre-chain requests to the shard for
+// the leaf's expiry time
+func wrappedShardedAddChainHandler(t *TemporalShards, isPrecert bool) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			// HTTP status code was already set
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		addChainRequest, err := parseBodyAsJSONChain(w, r)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		shard, err := t.shardForChain(addChainRequest)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		glog.V(logVerboseLevel).Infof("Routing chain to log shard %s", shard.Name)
+		return addParsedChain(w, r, addChainRequest, *shard.Handlers, isPrecert)
+	}
+}
+
+// RegisterCTHandlers registers the RFC6962 handlers of every shard under its name, and the
+// add-chain and add-pre-chain handlers that route chains between them under the usual path.
+// The other requests only make sense for a single log so are only served by the shards.
+func (t *TemporalShards) RegisterCTHandlers() {
+	t.RegisterCTHandlersOn(http.DefaultServeMux, "")
+}
+
+// RegisterCTHandlersOn is like RegisterCTHandlers but registers the handlers on the given
+// ServeMux, with the routing handlers under a path prefix
+func (t *TemporalShards) RegisterCTHandlersOn(mux *http.ServeMux, prefix string) {
+	for _, shard := range t.shards {
+		shard.Handlers.RegisterCTHandlersOn(mux, "/"+shard.Name)
+	}
+
+	mux.Handle(prefix+pathFor("add-chain"), wrappedShardedAddChainHandler(t, false))
+	mux.Handle(prefix+pathFor("add-pre-chain"), wrappedShardedAddChainHandler(t, true))
+}
@@ -0,0 +1,171 @@
+package ct
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+func yearStart(year int) time.Time {
+	return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// yearShards returns shards for certs expiring in 2018, 2019 and from 2020 onwards. The leaf
+// signed by the fake intermediate expires in 2019.
+func yearShards(handlers2018, handlers2019, handlers2020 *CTRequestHandlers) []LogShard {
+	return []LogShard{
+		{"2020", yearStart(2020), time.Time{}, handlers2020},
+		{"2018", yearStart(2018), yearStart(2019), handlers2018},
+		{"2019", yearStart(2019), yearStart(2020), handlers2019},
+	}
+}
+
+func TestNewTemporalShardsInvalid(t *testing.T) {
+	h := &CTRequestHandlers{}
+
+	for _, test := range []struct {
+		shards []LogShard
+		reason string
+	}{
+		{nil, "no shards"},
+		{[]LogShard{{"", time.Time{}, time.Time{}, h}}, "empty name"},
+		{[]LogShard{{"a/b", time.Time{}, time.Time{}, h}}, "name with slash"},
+		{[]LogShard{{"a", time.Time{}, time.Time{}, nil}}, "no handlers"},
+		{[]LogShard{{"a", yearStart(2019), yearStart(2019), h}}, "empty range"},
+		{[]LogShard{{"a", yearStart(2018), yearStart(2019), h}, {"a", yearStart(2019), yearStart(2020), h}}, "duplicate name"},
+		{[]LogShard{{"a", yearStart(2018), yearStart(2020), h}, {"b", yearStart(2019), yearStart(2021), h}}, "overlapping ranges"},
+		{[]LogShard{{"a", yearStart(2018), time.Time{}, h}, {"b", yearStart(2019), yearStart(2020), h}}, "open ended range overlaps"},
+		{[]LogShard{{"a", time.Time{}, yearStart(2019), h}, {"b", time.Time{}, yearStart(2018), h}}, "two open starts"},
+	} {
+		if _, err := NewTemporalShards(test.shards); err == nil {
+			t.Errorf("incorrectly created shards with %s", test.reason)
+		}
+	}
+}
+
+func TestShardFor(t *testing.T) {
+	shards, err := NewTemporalShards(yearShards(&CTRequestHandlers{}, &CTRequestHandlers{}, &CTRequestHandlers{}))
+
+	if err != nil {
+		t.Fatalf("failed to create shards: %v", err)
+	}
+
+	for _, test := range []struct {
+		notAfter time.Time
+		name     string
+	}{
+		{yearStart(2017), ""},
+		{yearStart(2018), "2018"},
+		{yearStart(2019).Add(-time.Second), "2018"},
+		{yearStart(2019), "2019"},
+		{yearStart(2020), "2020"},
+		{yearStart(2050), "2020"},
+	} {
+		shard, ok := shards.ShardFor(test.notAfter)
+
+		if ok != (len(test.name) > 0) || shard.Name != test.name {
+			t.Errorf("got shard %q (%v) for %v, expected %q", shard.Name, ok, test.notAfter, test.name)
+		}
+	}
+}
+
+func TestNewTemporalShardsSetsPolicy(t *testing.T) {
+	policy := &ValidationPolicy{MaxChainLength: 3}
+	handlers := []*CTRequestHandlers{{policy: policy}, {policy: policy}, {}}
+
+	if _, err := NewTemporalShards(yearShards(handlers[0], handlers[1], handlers[2])); err != nil {
+		t.Fatalf("failed to create shards: %v", err)
+	}
+
+	if got := handlers[1].policy; got.MaxChainLength != 3 || got.NotAfterStart != yearStart(2019) || got.NotAfterLimit != yearStart(2020) {
+		t.Fatalf("got shard policy %v, expected the original with the 2019 range", got)
+	}
+	if got, want := handlers[2].policy.NotAfterStart, yearStart(2020); got != want {
+		t.Fatalf("got NotAfterStart %v for shard without a policy, expected %v", got, want)
+	}
+	// The policy passed in is shared by the shards so shouldn't be changed
+	if !policy.NotAfterStart.IsZero() || !policy.NotAfterLimit.IsZero() {
+		t.Fatalf("original policy was changed: %v", policy)
+	}
+}
+
+// This submits a chain to the shared add-chain endpoint, it should be added to the 2019 shard
+func TestShardedAddChain(t *testing.T) {
+	toSign := []byte{0x7a, 0xc4, 0xd9, 0xca, 0x5f, 0x2e, 0x23, 0x82, 0xfe, 0xef, 0x5e, 0x95, 0x64, 0x7b, 0x31, 0x11, 0xf, 0x2a, 0x9b, 0x78, 0xa8, 0x3, 0x30, 0x8d, 0xfc, 0x8b, 0x78, 0x6, 0x61, 0xe7, 0x58, 0x44}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := setupMockKeyManager(mockCtrl, toSign)
+	otherKm := crypto.NewMockKeyManager(mockCtrl)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	handlers2018 := &CTRequestHandlers{0x42, roots, client, otherKm, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	handlers2019 := &CTRequestHandlers{0x43, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	handlers2020 := &CTRequestHandlers{0x44, roots, client, otherKm, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	shards, err := NewTemporalShards(yearShards(handlers2018, handlers2019, handlers2020))
+
+	if err != nil {
+		t.Fatalf("failed to create shards: %v", err)
+	}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := createJsonChain(t, *pool)
+
+	merkleLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x43, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, Leaves: []*trillian.QueuedLeaf{{Status: trillian.QueuedLeafStatus_QUEUED}}}, nil)
+
+	recorder := makeAddChainRequestInternal(t, wrappedShardedAddChainHandler(shards, false), "add-chain", chain)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %v for sharded add-chain, got %v. Body: %v", want, got, recorder.Body)
+	}
+}
+
+// A chain whose leaf doesn't expire in any shard's range should be rejected with the reason
+func TestShardedAddChainNoShard(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	handlers := &CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource, nil, nil}
+	shards, err := NewTemporalShards([]LogShard{{"2018", yearStart(2018), yearStart(2019), handlers}})
+
+	if err != nil {
+		t.Fatalf("failed to create shards: %v", err)
+	}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := createJsonChain(t, *pool)
+
+	recorder := makeAddChainRequestInternal(t, wrappedShardedAddChainHandler(shards, false), "add-chain", chain)
+
+	if got, want := recorder.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("expected %v for add-chain without a shard, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	var resp policyRejectionResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
+	}
+
+	if got, want := resp.Reason, string(RejectedNotAfter); got != want {
+		t.Fatalf("got rejection reason %s, expected %s", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package ct
 import (
 	"bufio"
 	"bytes"
+	"crypto/rsa"
 	"encoding/base64"
 	"reflect"
 	"testing"
@@ -108,6 +109,7 @@ func setupMockKeyManager(ctrl *gomock.Controller, toSign []byte) *crypto.MockKey
 func setupMockKeyManagerForSth(ctrl *gomock.Controller, toSign []byte) *crypto.MockKeyManager {
 	mockKeyManager := crypto.NewMockKeyManager(ctrl)
 	mockSigner := crypto.NewMockSigner(ctrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&rsa.PublicKey{})
 	mockSigner.EXPECT().Sign(gomock.Any(), toSign, gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
 	mockKeyManager.EXPECT().Signer().AnyTimes().Return(mockSigner, nil)
 
@@ -292,3 +292,66 @@ D0XUxs5PIdZZGInfeqymk5feReWHBuPHpPIUObKxmQt+hcw6YsHE+0B84Xtx9BMe
 4qqUfrqmtWXn9unBwxqSYsCqxHQpQ+70pmuBxlB9s6LStIzE9syaDmUyjxRljKAw
 INV6z0j7hKQ6MPpE
 -----END CERTIFICATE-----`
+
+// PrecertIssuerCACertPEM is a test CA certificate that issues pre-certificates through
+// PrecertSigningCertPEM
+const PrecertIssuerCACertPEM string = `
+-----BEGIN CERTIFICATE-----
+MIIBnjCCAUWgAwIBAgIBATAKBggqhkjOPQQDAjA/MQswCQYDVQQGEwJHQjEWMBQG
+A1UEChMNVHJpbGxpYW4gVGVzdDEYMBYGA1UEAxMPUHJlY2VydCBUZXN0IENBMB4X
+DTE2MDEwMTAwMDAwMFoXDTI2MDEwMTAwMDAwMFowPzELMAkGA1UEBhMCR0IxFjAU
+BgNVBAoTDVRyaWxsaWFuIFRlc3QxGDAWBgNVBAMTD1ByZWNlcnQgVGVzdCBDQTBZ
+MBMGByqGSM49AgEGCCqGSM49AwEHA0IABFnkRcLHAWWUyfwB7+TKBLYxqDExJN/E
+0WIAkcw39EPtM3ejwRfqp0lQ8OQfYLOTs4ilyYIgSjhqj5OpPh10dm+jMjAwMA4G
+A1UdDwEB/wQEAwICBDAPBgNVHRMBAf8EBTADAQH/MA0GA1UdDgQGBAQBAgMEMAoG
+CCqGSM49BAMCA0cAMEQCIDe1j1fMEndzT0e7DscZx46blHmywImuJINXl3h1esSD
+AiBv9Ko9Ps/sofqmCZ2QLnMiC7FyPBHl/wUhw38jo3BLRg==
+-----END CERTIFICATE-----`
+
+// PrecertSigningCertPEM is a Precertificate Signing Certificate issued by
+// PrecertIssuerCACertPEM
+const PrecertSigningCertPEM string = `
+-----BEGIN CERTIFICATE-----
+MIIB0TCCAXegAwIBAgIBAjAKBggqhkjOPQQDAjA/MQswCQYDVQQGEwJHQjEWMBQG
+A1UEChMNVHJpbGxpYW4gVGVzdDEYMBYGA1UEAxMPUHJlY2VydCBUZXN0IENBMB4X
+DTE2MDEwMTAwMDAwMFoXDTI2MDEwMTAwMDAwMFowSTELMAkGA1UEBhMCR0IxFjAU
+BgNVBAoTDVRyaWxsaWFuIFRlc3QxIjAgBgNVBAMTGVByZWNlcnQgVGVzdCBTaWdu
+aW5nIENlcnQwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAASwfnP0cbwTsVQMRqCz
+/c2m2pBOC+ghxSurOXAndzCiT8Vz85XSfplnPcJbX9BTsa9V6ZNJ+Dvew32cK0XK
+yciQo1owWDAOBgNVHQ8BAf8EBAMCAgQwFQYDVR0lBA4wDAYKKwYBBAHWeQIEBDAP
+BgNVHRMBAf8EBTADAQH/MA0GA1UdDgQGBAQFBgcIMA8GA1UdIwQIMAaABAECAwQw
+CgYIKoZIzj0EAwIDSAAwRQIgVD+FGQnHvz1l0OOzWhW1k0Rek3N0C/v99W9Bp4Cn
+0TUCIQDcYrsSesV3RHX+uNkz/QIBzo9PP+ya/FeiROL0SR59Tg==
+-----END CERTIFICATE-----`
+
+// PrecertSignedBySigningCertPEM is a pre-certificate issued by PrecertSigningCertPEM on
+// behalf of PrecertIssuerCACertPEM
+const PrecertSignedBySigningCertPEM string = `
+-----BEGIN CERTIFICATE-----
+MIIB0TCCAXegAwIBAgIBAzAKBggqhkjOPQQDAjBJMQswCQYDVQQGEwJHQjEWMBQG
+A1UEChMNVHJpbGxpYW4gVGVzdDEiMCAGA1UEAxMZUHJlY2VydCBUZXN0IFNpZ25p
+bmcgQ2VydDAeFw0xNjAxMDEwMDAwMDBaFw0yNjAxMDEwMDAwMDBaMEMxCzAJBgNV
+BAYTAkdCMRYwFAYDVQQKEw1UcmlsbGlhbiBUZXN0MRwwGgYDVQQDExNwcmVjZXJ0
+LmV4YW1wbGUuY29tMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEVVLOSEsnceyo
+gnMk0XApMZuD5fNJDi6rK5jN//bHU3hjbJk0ZKhfcSisMhO3TvbvsIdcQJqRDtVp
+SDRdSDpUy6NWMFQwDAYDVR0TAQH/BAIwADAPBgNVHSMECDAGgAQFBgcIMB4GA1Ud
+EQQXMBWCE3ByZWNlcnQuZXhhbXBsZS5jb20wEwYKKwYBBAHWeQIEAwEB/wQCBQAw
+CgYIKoZIzj0EAwIDSAAwRQIgRSZGB3LbFIHcgvRlshbGwpJSqnWfBQ2z/9Jgvr4C
+t9wCIQDZwDnA6SNrjC+3wz8QfBP3vvuGb2krfUxEBROt85FJ6A==
+-----END CERTIFICATE-----`
+
+// CertMatchingPrecertPEM is the final certificate PrecertIssuerCACertPEM issues for
+// PrecertSignedBySigningCertPEM
+const CertMatchingPrecertPEM string = `
+-----BEGIN CERTIFICATE-----
+MIIBsjCCAVigAwIBAgIBAzAKBggqhkjOPQQDAjA/MQswCQYDVQQGEwJHQjEWMBQG
+A1UEChMNVHJpbGxpYW4gVGVzdDEYMBYGA1UEAxMPUHJlY2VydCBUZXN0IENBMB4X
+DTE2MDEwMTAwMDAwMFoXDTI2MDEwMTAwMDAwMFowQzELMAkGA1UEBhMCR0IxFjAU
+BgNVBAoTDVRyaWxsaWFuIFRlc3QxHDAaBgNVBAMTE3ByZWNlcnQuZXhhbXBsZS5j
+b20wWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAARVUs5ISydx7KiCcyTRcCkxm4Pl
+80kOLqsrmM3/9sdTeGNsmTRkqF9xKKwyE7dO9u+wh1xAmpEO1WlINF1IOlTLo0Ew
+PzAMBgNVHRMBAf8EAjAAMA8GA1UdIwQIMAaABAECAwQwHgYDVR0RBBcwFYITcHJl
+Y2VydC5leGFtcGxlLmNvbTAKBggqhkjOPQQDAgNIADBFAiEAt7E/E6QRPuM34E2i
+nSHzUUG0CgZi2pvmOcrT2t9rjYICIEiS8dO94L2OAScqlFTmmYpN5C2nDRLX9DN0
+cw0kZM5U
+-----END CERTIFICATE-----`
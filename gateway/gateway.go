@@ -0,0 +1,98 @@
+// Package gateway serves the Trillian RPC APIs as REST with JSON bodies, for web clients and
+// tools that don't have gRPC stubs. The handlers are generated by grpc-gateway from the
+// google.api.http options in trillian_api.proto and forward each request to an RPC server.
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// RegisterFunc registers the handlers of one service with mux, which forward requests to the
+// RPC server at endpoint. The generated Register*HandlerFromEndpoint functions are RegisterFuncs.
+type RegisterFunc func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+
+// NewHandler returns an http.Handler that serves the REST endpoints of services by calling the
+// RPC server at endpoint. JSON fields have the names they have in the proto files, and bytes
+// are base64 encoded. The connections to the server are closed once ctx is done.
+func NewHandler(ctx context.Context, endpoint string, opts []grpc.DialOption, services ...RegisterFunc) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{OrigName: true}))
+
+	for _, register := range services {
+		if err := register(ctx, mux, endpoint, opts); err != nil {
+			return nil, fmt.Errorf("gateway: failed to register handlers: %v", err)
+		}
+	}
+
+	return mux, nil
+}
+
+// DialOptions returns the options for the gateway to connect to an RPC server served with the
+// PEM encoded certificate in certFile. The connection trusts only that certificate and checks
+// the server by the first name in it. If certFile is empty the connection doesn't use TLS.
+func DialOptions(certFile string) ([]grpc.DialOption, error) {
+	if len(certFile) == 0 {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+
+	data, err := ioutil.ReadFile(certFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("gateway: failed to read server certificate: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, fmt.Errorf("gateway: no certificate found in %s", certFile)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("gateway: failed to parse server certificate: %v", err)
+	}
+
+	name := cert.Subject.CommonName
+	if len(cert.DNSNames) > 0 {
+		name = cert.DNSNames[0]
+	}
+
+	if len(name) == 0 {
+		return nil, errors.New("gateway: server certificate doesn't name the server")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: name}))}, nil
+}
+
+// StartServer serves h on addr in the background, over TLS with the certificate and key in
+// certFile and keyFile if they're set. Failing to serve is logged but doesn't stop the caller.
+func StartServer(addr string, h http.Handler, certFile, keyFile string) {
+	go func() {
+		var err error
+
+		glog.Infof("Serving REST gateway on %s", addr)
+
+		if len(certFile) > 0 {
+			err = http.ListenAndServeTLS(addr, certFile, keyFile, h)
+		} else {
+			err = http.ListenAndServe(addr, h)
+		}
+
+		glog.Warningf("REST gateway exited: %v", err)
+	}()
+}
@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestHandler(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	logServer := trillian.NewMockTrillianLogServer(mockCtrl)
+	mapServer := trillian.NewMockTrillianMapServer(mockCtrl)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	trillian.RegisterTrillianLogServer(grpcServer, logServer)
+	trillian.RegisterTrillianMapServer(grpcServer, mapServer)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts, err := DialOptions("")
+	if err != nil {
+		t.Fatalf("DialOptions() = %v", err)
+	}
+	h, err := NewHandler(ctx, lis.Addr().String(), opts, trillian.RegisterTrillianLogHandlerFromEndpoint, trillian.RegisterTrillianMapHandlerFromEndpoint)
+	if err != nil {
+		t.Fatalf("NewHandler() = %v", err)
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	logServer.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 5}).Return(&trillian.GetLatestSignedLogRootResponse{SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 12}}, nil)
+	logServer.EXPECT().GetInclusionProof(gomock.Any(), &trillian.GetInclusionProofRequest{LogId: 5, LeafIndex: 3, TreeSize: 12}).Return(&trillian.GetInclusionProofResponse{Proof: &trillian.ProofProto{LeafIndex: 3}}, nil)
+	logServer.EXPECT().QueueLeaves(gomock.Any(), &trillian.QueueLeavesRequest{LogId: 5, Leaves: []*trillian.LeafProto{{LeafData: []byte("data")}}}).Return(&trillian.QueueLeavesResponse{}, nil)
+	mapServer.EXPECT().GetLeaves(gomock.Any(), &trillian.GetMapLeavesRequest{MapId: 7, Key: [][]byte{[]byte("key")}, Revision: -1}).Return(nil, grpc.Errorf(codes.NotFound, "no such map"))
+
+	for _, test := range []struct {
+		method, path, body string
+		wantCode           int
+		wantBody           string
+	}{
+		{method: "GET", path: "/v1/logs/5/roots/latest", wantCode: http.StatusOK, wantBody: `"tree_size":"12"`},
+		{method: "GET", path: "/v1/logs/5/proofs/inclusion?leaf_index=3&tree_size=12", wantCode: http.StatusOK, wantBody: `"leaf_index":"3"`},
+		{method: "POST", path: "/v1/logs/5/leaves", body: `{"leaves":[{"leaf_data":"ZGF0YQ=="}]}`, wantCode: http.StatusOK},
+		{method: "POST", path: "/v1/logs/5/leaves", body: `{"leaves":`, wantCode: http.StatusBadRequest},
+		{method: "GET", path: "/v1/logs/x/roots/latest", wantCode: http.StatusBadRequest},
+		{method: "GET", path: "/v1/maps/7/leaves?key=a2V5&revision=-1", wantCode: http.StatusNotFound, wantBody: "no such map"},
+	} {
+		req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("%s %s failed: %v", test.method, test.path, err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s %s: failed to read body: %v", test.method, test.path, err)
+			continue
+		}
+
+		if resp.StatusCode != test.wantCode || !strings.Contains(string(body), test.wantBody) {
+			t.Errorf("%s %s = %d %s, want %d with %s", test.method, test.path, resp.StatusCode, body, test.wantCode, test.wantBody)
+		}
+	}
+}
+
+func TestDialOptionsBadCertificate(t *testing.T) {
+	f, err := ioutil.TempFile("", "cert")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("not a certificate")
+	f.Close()
+
+	for _, certFile := range []string{f.Name(), f.Name() + ".missing"} {
+		if _, err := DialOptions(certFile); err == nil {
+			t.Errorf("DialOptions(%s) succeeded", certFile)
+		}
+	}
+}
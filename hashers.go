@@ -3,7 +3,10 @@ package trillian
 import (
 	"crypto"
 	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"fmt"
+
+	_ "golang.org/x/crypto/blake2b"
 )
 
 // Hasher is the interface which must be implemented by hashers.
@@ -16,6 +19,10 @@ func NewHasher(alg HashAlgorithm) (Hasher, error) {
 	switch alg {
 	case HashAlgorithm_SHA256:
 		return Hasher{crypto.SHA256, alg}, nil
+	case HashAlgorithm_SHA512_256:
+		return Hasher{crypto.SHA512_256, alg}, nil
+	case HashAlgorithm_BLAKE2B_256:
+		return Hasher{crypto.BLAKE2b_256, alg}, nil
 	}
 	return Hasher{}, fmt.Errorf("unsupported hash algorithm %v", alg)
 }
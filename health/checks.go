@@ -0,0 +1,34 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"golang.org/x/net/context"
+)
+
+// KeyCheck fails if the private key held by km can't be used to sign, e.g. because its file
+// can't be read or its HSM can't be reached
+func KeyCheck(km crypto.KeyManager) Check {
+	return func(ctx context.Context) error {
+		_, err := km.Signer()
+		return err
+	}
+}
+
+// CheckRootAge returns an error if root was signed more than maxAge before now, which means
+// the log's queued leaves aren't being sequenced or its root isn't being signed again. Roots
+// without a timestamp, of logs that haven't been signed yet, aren't checked.
+func CheckRootAge(root trillian.SignedLogRoot, now time.Time, maxAge time.Duration) error {
+	if root.TimestampNanos == 0 {
+		return nil
+	}
+
+	if age := now.Sub(time.Unix(0, root.TimestampNanos)); age > maxAge {
+		return fmt.Errorf("latest root was signed %v ago, more than %v", age, maxAge)
+	}
+
+	return nil
+}
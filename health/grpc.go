@@ -0,0 +1,46 @@
+package health
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// RegisterGRPCServer registers the standard gRPC health service on s, reporting the
+// readiness of the server for the "" service name. The checks are run every period until
+// done is closed, rather than for each health RPC, so frequent probes don't add load.
+func RegisterGRPCServer(done <-chan struct{}, s *grpc.Server, c *Checker, period time.Duration) {
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		for {
+			c.updateGRPCStatus(hs)
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// updateGRPCStatus sets the status that hs reports to the result of the readiness checks
+func (c *Checker) updateGRPCStatus(hs *grpchealth.Server) {
+	status := healthpb.HealthCheckResponse_SERVING
+
+	if failures := c.Ready(context.Background()); len(failures) > 0 {
+		glog.Warningf("Server isn't ready: %s", Describe(failures))
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	hs.SetServingStatus("", status)
+}
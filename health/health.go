@@ -0,0 +1,140 @@
+// Package health checks whether a server is live, so it needn't be restarted, and ready, so
+// it can be sent requests. The results are served over HTTP for load balancers and
+// orchestrators such as Kubernetes, and through the standard gRPC health service.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// errShuttingDown is the readiness failure of a server that's shutting down
+var errShuttingDown = errors.New("server is shutting down")
+
+// Check returns an error if the part of the server it checks isn't working
+type Check func(ctx context.Context) error
+
+// Checker holds the checks of a server's liveness and readiness. A server is only ready if
+// it's also live.
+type Checker struct {
+	// timeout limits how long each check can take, if it's zero checks are only limited by
+	// the context they're run with
+	timeout time.Duration
+	// Must hold this lock before accessing the checks
+	mu        sync.Mutex
+	liveness  map[string]Check
+	readiness map[string]Check
+	// shuttingDown is set once the server starts to shut down, it's never ready after that
+	shuttingDown bool
+}
+
+// NewChecker creates a Checker without any checks, so it's live and ready until some are
+// added. Each check fails if it takes longer than timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, liveness: make(map[string]Check), readiness: make(map[string]Check)}
+}
+
+// AddLivenessCheck adds a check that the server must pass to be live, replacing any check
+// with the same name. It should only fail if restarting the server would fix it.
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness[name] = check
+}
+
+// AddReadinessCheck adds a check that the server must pass to be ready, replacing any check
+// with the same name.
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness[name] = check
+}
+
+// SetShuttingDown makes the server not ready from now on, so that no more requests are sent
+// to it while it finishes the ones it has
+func (c *Checker) SetShuttingDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shuttingDown = true
+}
+
+// Live runs the liveness checks and returns the errors of those that failed, by name
+func (c *Checker) Live(ctx context.Context) map[string]error {
+	c.mu.Lock()
+	checks := copyChecks(c.liveness)
+	c.mu.Unlock()
+
+	return c.run(ctx, checks)
+}
+
+// Ready runs the liveness and readiness checks and returns the errors of those that failed,
+// by name
+func (c *Checker) Ready(ctx context.Context) map[string]error {
+	c.mu.Lock()
+	checks := copyChecks(c.liveness)
+	for name, check := range c.readiness {
+		checks[name] = check
+	}
+	shuttingDown := c.shuttingDown
+	c.mu.Unlock()
+
+	if shuttingDown {
+		return map[string]error{"shutdown": errShuttingDown}
+	}
+
+	return c.run(ctx, checks)
+}
+
+func copyChecks(checks map[string]Check) map[string]Check {
+	copied := make(map[string]Check, len(checks))
+	for name, check := range checks {
+		copied[name] = check
+	}
+
+	return copied
+}
+
+// run runs checks one after the other, so a slow database isn't hit by all of them at once
+func (c *Checker) run(ctx context.Context, checks map[string]Check) map[string]error {
+	failures := make(map[string]error)
+
+	for name, check := range checks {
+		checkCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+
+		if err := check(checkCtx); err != nil {
+			failures[name] = err
+		}
+
+		cancel()
+	}
+
+	return failures
+}
+
+// Describe formats the failures returned by Live or Ready, one per line in name order
+func Describe(failures map[string]error) string {
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %v", name, failures[name]))
+	}
+
+	return strings.Join(lines, "\n")
+}
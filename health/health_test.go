@@ -0,0 +1,118 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+func passing(ctx context.Context) error {
+	return nil
+}
+
+func failing(ctx context.Context) error {
+	return errors.New("database down")
+}
+
+func TestChecker(t *testing.T) {
+	c := NewChecker(0)
+
+	if failures := c.Ready(context.Background()); len(failures) != 0 {
+		t.Errorf("Checker without checks isn't ready: %v", failures)
+	}
+
+	c.AddLivenessCheck("goroutines", passing)
+	c.AddReadinessCheck("database", failing)
+
+	if failures := c.Live(context.Background()); len(failures) != 0 {
+		t.Errorf("Live() = %v, readiness checks shouldn't affect liveness", failures)
+	}
+	if failures := c.Ready(context.Background()); len(failures) != 1 || failures["database"] == nil {
+		t.Errorf("Ready() = %v, want the database check to fail", failures)
+	}
+
+	// A server that isn't live isn't ready either
+	c.AddLivenessCheck("goroutines", failing)
+
+	if failures := c.Ready(context.Background()); len(failures) != 2 {
+		t.Errorf("Ready() = %v, want both checks to fail", failures)
+	}
+	if got, want := Describe(c.Ready(context.Background())), "database: database down\ngoroutines: database down"; got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckerShuttingDown(t *testing.T) {
+	c := NewChecker(0)
+	c.AddReadinessCheck("database", passing)
+	c.SetShuttingDown()
+
+	if failures := c.Ready(context.Background()); failures["shutdown"] != errShuttingDown {
+		t.Errorf("Ready() = %v while shutting down, want it to fail", failures)
+	}
+	if failures := c.Live(context.Background()); len(failures) != 0 {
+		t.Errorf("Live() = %v while shutting down, want it to pass", failures)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := NewChecker(time.Millisecond)
+	c.AddReadinessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if failures := c.Ready(context.Background()); failures["slow"] == nil {
+		t.Errorf("Ready() = %v, want the slow check to time out", failures)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	c := NewChecker(0)
+	c.AddReadinessCheck("database", failing)
+
+	for _, test := range []struct {
+		handler  http.Handler
+		wantCode int
+		wantBody string
+	}{
+		{c.LivenessHandler(), http.StatusOK, "ok\n"},
+		{c.ReadinessHandler(), http.StatusServiceUnavailable, "database: database down\n"},
+	} {
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if w.Code != test.wantCode || w.Body.String() != test.wantBody {
+			t.Errorf("Handler responded %d %q, want %d %q", w.Code, w.Body.String(), test.wantCode, test.wantBody)
+		}
+	}
+}
+
+func TestCheckRootAge(t *testing.T) {
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		root    trillian.SignedLogRoot
+		wantErr bool
+	}{
+		{trillian.SignedLogRoot{TimestampNanos: now.Add(-time.Second).UnixNano()}, false},
+		{trillian.SignedLogRoot{TimestampNanos: now.Add(-time.Minute).UnixNano()}, false},
+		{trillian.SignedLogRoot{TimestampNanos: now.Add(-time.Minute - 1).UnixNano()}, true},
+		{trillian.SignedLogRoot{}, false},
+	} {
+		err := CheckRootAge(test.root, now, time.Minute)
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("CheckRootAge(%v) = %v, want error %v", test.root, err, test.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "ago") {
+			t.Errorf("CheckRootAge(%v) = %v, want it to say how long ago the root was signed", test.root, err)
+		}
+	}
+}
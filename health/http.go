@@ -0,0 +1,56 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// LivenessPath is where StartServer serves the liveness of a server
+	LivenessPath = "/healthz"
+	// ReadinessPath is where StartServer serves the readiness of a server
+	ReadinessPath = "/readyz"
+)
+
+// LivenessHandler returns an http.Handler that responds 200 OK if the server is live and 503
+// Service Unavailable listing the checks that failed if it isn't
+func (c *Checker) LivenessHandler() http.Handler {
+	return c.handler(c.Live)
+}
+
+// ReadinessHandler returns an http.Handler that responds 200 OK if the server is ready and
+// 503 Service Unavailable listing the checks that failed if it isn't
+func (c *Checker) ReadinessHandler() http.Handler {
+	return c.handler(c.Ready)
+}
+
+func (c *Checker) handler(checks func(ctx context.Context) map[string]error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if failures := checks(context.Background()); len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, Describe(failures))
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// StartServer serves the liveness of the server at LivenessPath and its readiness at
+// ReadinessPath on addr in the background. Failing to serve is logged but doesn't stop the
+// caller, though probes of the server will then fail.
+func StartServer(addr string, c *Checker) {
+	mux := http.NewServeMux()
+	mux.Handle(LivenessPath, c.LivenessHandler())
+	mux.Handle(ReadinessPath, c.ReadinessHandler())
+
+	go func() {
+		glog.Infof("Serving health checks on %s", addr)
+		glog.Warningf("Health server exited: %v", http.ListenAndServe(addr, mux))
+	}()
+}
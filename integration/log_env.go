@@ -0,0 +1,223 @@
+// Package integration runs Trillian servers together in one process and checks, through
+// their RPC APIs, that they work end to end. LogEnv starts a log server, its sequencer and
+// an admin server over a storage system, and RunLogIntegration drives a log through them
+// with a verifying client. The tests here use the in-memory storage system, so they need
+// nothing else to run.
+package integration
+
+import (
+	gocrypto "crypto"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/interceptor"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// LogEnvConfig holds the settings of a LogEnv
+type LogEnvConfig struct {
+	// BatchSize is the most leaves sequenced per run
+	BatchSize int
+	// SequencerSleep is the time the sequencer pauses between passes through the logs
+	SequencerSleep time.Duration
+	// SignInterval is how old a log's root can get before a new one is signed, even if no
+	// leaves have been added
+	SignInterval time.Duration
+	// MaxRangeLeaves is the most leaves in each GetLeavesByRange page, 0 uses the server's
+	// default
+	MaxRangeLeaves int64
+}
+
+// DefaultLogEnvConfig sequences often enough that leaves are integrated within a second
+// and serves leaves in pages small enough that reading a log takes several
+var DefaultLogEnvConfig = LogEnvConfig{BatchSize: 50, SequencerSleep: time.Millisecond * 100, SignInterval: time.Second, MaxRangeLeaves: 7}
+
+// LogEnv is a log server and sequencer running over a storage system, with an admin server
+// for creating logs, all serving on one local port. Logs created through it are signed with
+// the demo key in the testonly package.
+type LogEnv struct {
+	// Addr is the address the servers are listening on
+	Addr string
+	// ClientConn is connected to the servers
+	ClientConn *grpc.ClientConn
+	// LogClient and AdminClient make requests through ClientConn
+	LogClient   trillian.TrillianLogClient
+	AdminClient trillian.TrillianAdminClient
+	// PublicKey verifies the roots signed for the logs
+	PublicKey gocrypto.PublicKey
+
+	grpcServer *grpc.Server
+	done       chan struct{}
+	sequencer  sync.WaitGroup
+
+	storageMutex sync.Mutex
+	storageMap   map[int64]storage.LogStorage
+	provider     storage.Provider
+}
+
+// NewLogEnv starts the servers and sequencer over the storage of provider, listening on a
+// port picked by the system. Close must be called to stop them.
+func NewLogEnv(provider storage.Provider, config LogEnvConfig) (*LogEnv, error) {
+	km := crypto.NewPEMKeyManager()
+
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		glog.Warningf("Failed to load the demo private key: %v", err)
+		return nil, err
+	}
+
+	if err := km.LoadPublicKey(testonly.DemoPublicKey); err != nil {
+		glog.Warningf("Failed to load the demo public key: %v", err)
+		return nil, err
+	}
+
+	pubKey, err := km.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	adminStorage, err := provider.AdminStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	env := &LogEnv{
+		Addr:       lis.Addr().String(),
+		PublicKey:  pubKey,
+		done:       make(chan struct{}),
+		storageMap: make(map[int64]storage.LogStorage),
+		provider:   provider,
+	}
+
+	chain, err := interceptor.NewChain(interceptor.RecoveryName + "," + interceptor.LoggingName)
+	if err != nil {
+		lis.Close()
+		return nil, err
+	}
+
+	env.grpcServer = interceptor.NewServer(chain)
+	logServer := server.NewTrillianLogServer(env.getStorageForLog)
+	if config.MaxRangeLeaves > 0 {
+		logServer.SetMaxRangeLeaves(config.MaxRangeLeaves)
+	}
+	trillian.RegisterTrillianLogServer(env.grpcServer, logServer)
+	trillian.RegisterTrillianAdminServer(env.grpcServer, server.NewTrillianAdminServer(func() (storage.AdminStorage, error) { return adminStorage, nil }))
+	go env.grpcServer.Serve(lis)
+
+	// Logs are created with key IDs that don't name a key scheme, so they're signed with km
+	sequencer := server.NewSequencerManager(server.NewKeyManagerProvider(km))
+	sequencerManager := server.NewLogOperationManager(env.done, env.getStorageForLog, config.BatchSize, config.SequencerSleep, config.SignInterval, util.SystemTimeSource{}, sequencer)
+
+	env.sequencer.Add(1)
+	go func() {
+		defer env.sequencer.Done()
+		sequencerManager.OperationLoop(context.Background())
+	}()
+
+	env.ClientConn, err = grpc.Dial(env.Addr, grpc.WithInsecure())
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+
+	env.LogClient = trillian.NewTrillianLogClient(env.ClientConn)
+	env.AdminClient = trillian.NewTrillianAdminClient(env.ClientConn)
+
+	return env, nil
+}
+
+// getStorageForLog returns the storage for a log, creating it the first time it's needed as
+// the log server binary does
+func (env *LogEnv) getStorageForLog(treeID int64) (storage.LogStorage, error) {
+	env.storageMutex.Lock()
+	defer env.storageMutex.Unlock()
+
+	s, ok := env.storageMap[treeID]
+
+	if !ok {
+		var err error
+		s, err = env.provider.LogStorage(trillian.LogID{LogID: []byte("TODO"), TreeID: treeID})
+		if err != nil {
+			return nil, err
+		}
+		env.storageMap[treeID] = s
+	}
+
+	return s, nil
+}
+
+// CreateLog creates a log of treeType through the admin server and returns its tree ID once
+// the sequencer has signed its first root, before then there's no root a client can verify
+func (env *LogEnv) CreateLog(ctx context.Context, treeType trillian.TreeType) (int64, error) {
+	resp, err := env.AdminClient.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: &trillian.Tree{
+		TreeType:      treeType,
+		KeyId:         []byte("integration"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+		DisplayName:   "Integration test log",
+	}})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Tree == nil {
+		return 0, fmt.Errorf("CreateTree() returned no tree: %v", resp)
+	}
+
+	logID := resp.Tree.TreeId
+
+	if err := env.waitForRoot(ctx, logID); err != nil {
+		return 0, err
+	}
+
+	return logID, nil
+}
+
+// waitForRoot polls the log server until the log has a signed root or ctx is done
+func (env *LogEnv) waitForRoot(ctx context.Context, logID int64) error {
+	ticker := time.NewTicker(time.Millisecond * 50)
+	defer ticker.Stop()
+
+	for {
+		resp, err := env.LogClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+		if err != nil {
+			return err
+		}
+
+		if root := resp.SignedLogRoot; root != nil && root.Signature != nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("log %d has no signed root: %v", logID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the sequencer, once the batch under way has finished, and the servers
+func (env *LogEnv) Close() {
+	close(env.done)
+	env.sequencer.Wait()
+
+	if env.ClientConn != nil {
+		env.ClientConn.Close()
+	}
+
+	env.grpcServer.Stop()
+}
@@ -0,0 +1,156 @@
+package integration
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// LogParams holds the settings of a run of RunLogIntegration
+type LogParams struct {
+	// LeafCount is the number of leaves queued
+	LeafCount int
+	// Backoff controls how often the log is polled while waiting for leaves to be integrated
+	Backoff client.BackoffConfig
+}
+
+// DefaultLogParams polls quickly enough to suit a sequencer set up with DefaultLogEnvConfig
+var DefaultLogParams = LogParams{LeafCount: 20, Backoff: client.BackoffConfig{Initial: time.Millisecond * 100, Max: time.Second, Multiplier: 1.5}}
+
+// RunLogIntegration queues leaves to the log through logClient and waits for them all to be
+// integrated. It then checks, against a root signed by pubKey, that every leaf has a valid
+// inclusion proof at the index it was given, that the log returns the data it was sent and
+// that the whole tree can be read a page at a time.
+// The log must use SHA256 hashing and not allow duplicates of the leaves already in it.
+func RunLogIntegration(ctx context.Context, logClient trillian.TrillianLogClient, logID int64, pubKey gocrypto.PublicKey, params LogParams) error {
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewLogClient(logID, logClient, hasher, pubKey, client.NewMemoryTrustStore())
+
+	// Each run queues different leaves so a log can be tested more than once
+	prefix := fmt.Sprintf("integration-%d", time.Now().UnixNano())
+	leaves := make([]*trillian.LeafProto, 0, params.LeafCount)
+
+	for l := 0; l < params.LeafCount; l++ {
+		leaf, err := c.QueueLeaf(ctx, []byte(fmt.Sprintf("%s-%d", prefix, l)), nil)
+		if err != nil {
+			return fmt.Errorf("failed to queue leaf %d: %v", l, err)
+		}
+
+		leaves = append(leaves, leaf)
+	}
+
+	glog.Infof("Queued %d leaves to log %d, waiting for them to be integrated", len(leaves), logID)
+
+	for l, leaf := range leaves {
+		if _, _, err := c.WaitForInclusion(ctx, leaf.LeafHash, params.Backoff); err != nil {
+			return fmt.Errorf("leaf %d was not integrated: %v", l, err)
+		}
+	}
+
+	root, err := c.UpdateRoot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get verified root: %v", err)
+	}
+
+	if root.TreeSize < int64(len(leaves)) {
+		return fmt.Errorf("log %d has size %d after integrating %d leaves", logID, root.TreeSize, len(leaves))
+	}
+
+	indices := make([]int64, 0, len(leaves))
+
+	for l, leaf := range leaves {
+		index, err := c.VerifyInclusion(ctx, leaf.LeafHash)
+		if err != nil {
+			return fmt.Errorf("failed to verify inclusion of leaf %d: %v", l, err)
+		}
+
+		if err := c.VerifyInclusionAtIndex(ctx, leaf.LeafHash, index); err != nil {
+			return fmt.Errorf("failed to verify inclusion of leaf %d at index %d: %v", l, index, err)
+		}
+
+		indices = append(indices, index)
+	}
+
+	resp, err := logClient.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: logID, LeafIndex: indices})
+	if err != nil {
+		return fmt.Errorf("failed to get leaves: %v", err)
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return fmt.Errorf("log %d failed to get leaves: %v", logID, resp.Status)
+	}
+
+	if got, want := len(resp.Leaves), len(leaves); got != want {
+		return fmt.Errorf("log %d returned %d leaves, expected %d", logID, got, want)
+	}
+
+	for l, leaf := range resp.Leaves {
+		if leaf.LeafIndex != indices[l] {
+			return fmt.Errorf("log %d returned leaf %d for index %d", logID, leaf.LeafIndex, indices[l])
+		}
+
+		if !bytes.Equal(leaf.LeafData, leaves[l].LeafData) {
+			return fmt.Errorf("log %d returned data %q at index %d, expected %q", logID, leaf.LeafData, leaf.LeafIndex, leaves[l].LeafData)
+		}
+
+		if !bytes.Equal(leaf.LeafHash, leaves[l].LeafHash) {
+			return fmt.Errorf("log %d returned hash %x at index %d, expected %x", logID, leaf.LeafHash, leaf.LeafIndex, leaves[l].LeafHash)
+		}
+	}
+
+	if err := readAllLeaves(ctx, logClient, logID, root.TreeSize); err != nil {
+		return err
+	}
+
+	glog.Infof("Verified %d leaves in log %d at size %d", len(leaves), logID, root.TreeSize)
+	return nil
+}
+
+// readAllLeaves reads the first treeSize leaves of a log with GetLeavesByRange, following
+// the page tokens, and checks they come back in index order without gaps
+func readAllLeaves(ctx context.Context, logClient trillian.TrillianLogClient, logID, treeSize int64) error {
+	req := &trillian.GetLeavesByRangeRequest{LogId: logID, Count: treeSize}
+	next := int64(0)
+
+	for pages := 1; ; pages++ {
+		resp, err := logClient.GetLeavesByRange(ctx, req)
+		if err != nil {
+			return fmt.Errorf("failed to get page %d of leaves: %v", pages, err)
+		}
+
+		if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+			return fmt.Errorf("log %d failed to get page %d of leaves: %v", logID, pages, resp.Status)
+		}
+
+		for _, leaf := range resp.Leaves {
+			if leaf.LeafIndex != next {
+				return fmt.Errorf("log %d returned leaf %d in page %d, expected leaf %d", logID, leaf.LeafIndex, pages, next)
+			}
+
+			next++
+		}
+
+		if len(resp.NextPageToken) == 0 {
+			break
+		}
+
+		req.PageToken = resp.NextPageToken
+	}
+
+	if next != treeSize {
+		return fmt.Errorf("log %d returned %d leaves in pages, expected %d", logID, next, treeSize)
+	}
+
+	return nil
+}
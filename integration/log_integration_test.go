@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"golang.org/x/net/context"
+)
+
+func TestLogIntegration(t *testing.T) {
+	provider, err := storage.NewProvider(memory.ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create storage provider: %v", err)
+	}
+
+	env, err := NewLogEnv(provider, DefaultLogEnvConfig)
+	if err != nil {
+		t.Fatalf("Failed to start log environment: %v", err)
+	}
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	logID, err := env.CreateLog(ctx, trillian.TreeType_LOG)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	if err := RunLogIntegration(ctx, env.LogClient, logID, env.PublicKey, DefaultLogParams); err != nil {
+		t.Fatalf("Log integration failed: %v", err)
+	}
+
+	// A second run checks leaves added to a log that already has some
+	if err := RunLogIntegration(ctx, env.LogClient, logID, env.PublicKey, DefaultLogParams); err != nil {
+		t.Fatalf("Log integration failed on the second run: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package interceptor
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/auth"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/monitoring"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+// The names the interceptors in this package are registered under
+const (
+	MonitoringName = "monitoring"
+	RecoveryName   = "recovery"
+	LoggingName    = "logging"
+)
+
+func init() {
+	for name, f := range map[string]NewFunc{
+		MonitoringName: newMonitoring,
+		RecoveryName:   newRecovery,
+		LoggingName:    newLogging,
+	} {
+		if err := Register(name, f); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// newMonitoring records metrics and traces for RPCs, see monitoring.UnaryServerInterceptor
+func newMonitoring() (Interceptor, error) {
+	return Interceptor{Unary: monitoring.UnaryServerInterceptor(), Stream: monitoring.StreamServerInterceptor()}, nil
+}
+
+func newRecovery() (Interceptor, error) {
+	return Interceptor{Unary: RecoveryUnaryInterceptor(), Stream: RecoveryStreamInterceptor()}, nil
+}
+
+func newLogging() (Interceptor, error) {
+	return Interceptor{Unary: LoggingUnaryInterceptor(), Stream: LoggingStreamInterceptor()}, nil
+}
+
+// recoverRPC turns a panic in the handling of an RPC into an error, so that one bad request
+// doesn't take the whole server down. It must be deferred.
+func recoverRPC(method string, err *error) {
+	if r := recover(); r != nil {
+		glog.Errorf("%s: panic: %v\n%s", method, r, debug.Stack())
+		*err = grpc.Errorf(codes.Internal, "%s: internal error", method)
+	}
+}
+
+// RecoveryUnaryInterceptor fails unary RPCs whose handling panics with codes.Internal, and
+// logs the panic
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverRPC(info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor does the same as RecoveryUnaryInterceptor for streaming RPCs
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverRPC(info.FullMethod, &err)
+		return handler(srv, stream)
+	}
+}
+
+// client describes who made a request, for logging
+func client(ctx context.Context) string {
+	if identity := auth.Identity(ctx); len(identity) > 0 {
+		return identity
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+
+	return "unknown client"
+}
+
+// logRPC logs an RPC that's finished, tagged with its request ID, method and tree. Failures
+// are always logged but successes only with -v=1 or above, as there can be a great many of
+// them.
+func logRPC(ctx context.Context, start time.Time, err error) {
+	if err != nil {
+		logging.Infof(ctx, "RPC from %s failed after %v: %v", client(ctx), time.Since(start), err)
+	} else if glog.V(1) {
+		logging.Infof(ctx, "RPC from %s succeeded in %v", client(ctx), time.Since(start))
+	}
+}
+
+// LoggingUnaryInterceptor tags the context of unary RPCs for logging, see
+// logging.UnaryServerInterceptor, and logs them as they finish
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	tag := logging.UnaryServerInterceptor()
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return tag(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			start := time.Now()
+			resp, err := handler(ctx, req)
+			logRPC(ctx, start, err)
+			return resp, err
+		})
+	}
+}
+
+// LoggingStreamInterceptor does the same as LoggingUnaryInterceptor for streaming RPCs
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	tag := logging.StreamServerInterceptor()
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return tag(srv, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+			start := time.Now()
+			err := handler(srv, stream)
+			logRPC(stream.Context(), start, err)
+			return err
+		})
+	}
+}
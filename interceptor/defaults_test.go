@@ -0,0 +1,54 @@
+package interceptor
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"}
+
+	if _, err := RecoveryUnaryInterceptor()(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("bad request")
+	}); grpc.Code(err) != codes.Internal {
+		t.Errorf("Got %v when the handler panicked, want code %v", err, codes.Internal)
+	}
+
+	resp, err := RecoveryUnaryInterceptor()(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	})
+
+	if resp != "resp" || err != nil {
+		t.Errorf("Got %v, %v from interceptor, want the handler's result", resp, err)
+	}
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	if err := RecoveryStreamInterceptor()(nil, fakeStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		panic("bad request")
+	}); grpc.Code(err) != codes.Internal {
+		t.Errorf("Got %v when the handler panicked, want code %v", err, codes.Internal)
+	}
+}
+
+func TestLoggingInterceptors(t *testing.T) {
+	handlerErr := errors.New("it broke")
+
+	if _, err := LoggingUnaryInterceptor()(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, handlerErr
+	}); err != handlerErr {
+		t.Errorf("Got %v from interceptor, want the handler's error", err)
+	}
+
+	if err := LoggingStreamInterceptor()(nil, fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		return handlerErr
+	}); err != handlerErr {
+		t.Errorf("Got %v from interceptor, want the handler's error", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package interceptor lets the gRPC interceptors the servers run requests through be chosen
+// and ordered by configuration. Interceptors are registered by name, usually from an init()
+// function, so a deployment can add its own by linking in a package that registers them
+// and naming them in the server's flags.
+package interceptor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Interceptor is a pair of interceptors for unary and streaming RPCs. Either can be nil if
+// the interceptor doesn't need to see that kind of RPC.
+type Interceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// NewFunc creates an interceptor. It's called once for each server that uses it.
+type NewFunc func() (Interceptor, error)
+
+var registryMutex sync.RWMutex
+var registry = make(map[string]NewFunc)
+
+// Register makes an interceptor available by the provided name. It returns an error if an
+// interceptor has already been registered with the same name.
+func Register(name string, f NewFunc) error {
+	if f == nil {
+		return fmt.Errorf("interceptor: nil NewFunc for %s", name)
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[name]; exists {
+		return fmt.Errorf("interceptor: %s already registered", name)
+	}
+
+	registry[name] = f
+	return nil
+}
+
+// Names returns the sorted names of all registered interceptors.
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Chain is a list of interceptors that RPCs pass through in order, so the first sees each
+// RPC before any of the others and its result after all of them
+type Chain []Interceptor
+
+// NewChain creates the interceptors named in a comma separated list, in the order they're
+// listed
+func NewChain(names string) (Chain, error) {
+	var chain Chain
+
+	if len(names) == 0 {
+		return chain, nil
+	}
+
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(names, ",") {
+		registryMutex.RLock()
+		f, ok := registry[name]
+		registryMutex.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("interceptor: unknown interceptor %s (registered: %v)", name, Names())
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("interceptor: %s listed more than once", name)
+		}
+
+		seen[name] = true
+
+		i, err := f()
+
+		if err != nil {
+			return nil, fmt.Errorf("interceptor: failed to create %s: %v", name, err)
+		}
+
+		chain = append(chain, i)
+	}
+
+	return chain, nil
+}
+
+// UnaryInterceptor returns an interceptor that runs unary RPCs through each of the chain's
+func (c Chain) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	var interceptors []grpc.UnaryServerInterceptor
+
+	for _, i := range c {
+		if i.Unary != nil {
+			interceptors = append(interceptors, i.Unary)
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return callUnary(interceptors, ctx, req, info, handler)
+	}
+}
+
+// callUnary passes an RPC to the first of interceptors, which hands it on to the rest
+func callUnary(interceptors []grpc.UnaryServerInterceptor, ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if len(interceptors) == 0 {
+		return handler(ctx, req)
+	}
+
+	return interceptors[0](ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return callUnary(interceptors[1:], ctx, req, info, handler)
+	})
+}
+
+// StreamInterceptor returns an interceptor that runs streaming RPCs through each of the
+// chain's
+func (c Chain) StreamInterceptor() grpc.StreamServerInterceptor {
+	var interceptors []grpc.StreamServerInterceptor
+
+	for _, i := range c {
+		if i.Stream != nil {
+			interceptors = append(interceptors, i.Stream)
+		}
+	}
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return callStream(interceptors, srv, stream, info, handler)
+	}
+}
+
+// callStream passes an RPC to the first of interceptors, which hands it on to the rest
+func callStream(interceptors []grpc.StreamServerInterceptor, srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if len(interceptors) == 0 {
+		return handler(srv, stream)
+	}
+
+	return interceptors[0](srv, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+		return callStream(interceptors[1:], srv, stream, info, handler)
+	})
+}
+
+// NewServer creates a gRPC server whose RPCs pass through chain before reaching their
+// handlers
+func NewServer(chain Chain, opts ...grpc.ServerOption) *grpc.Server {
+	return grpc.NewServer(append(opts, grpc.UnaryInterceptor(chain.UnaryInterceptor()), grpc.StreamInterceptor(chain.StreamInterceptor()))...)
+}
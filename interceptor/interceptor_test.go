@@ -0,0 +1,135 @@
+package interceptor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeStream is a server stream that only has a context, and ignores headers
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s fakeStream) SetHeader(md metadata.MD) error {
+	return nil
+}
+
+// recorder returns an interceptor that appends name to calls before and after passing RPCs
+// on
+func recorder(name string, calls *[]string) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			*calls = append(*calls, name)
+			resp, err := handler(ctx, req)
+			*calls = append(*calls, name+" done")
+			return resp, err
+		},
+		Stream: func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			*calls = append(*calls, name)
+			err := handler(srv, stream)
+			*calls = append(*calls, name+" done")
+			return err
+		},
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	chain := Chain{recorder("first", &calls), {}, recorder("second", &calls)}
+	want := []string{"first", "second", "handler", "second done", "first done"}
+
+	resp, err := chain.UnaryInterceptor()(context.Background(), "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return "resp", nil
+	})
+
+	if resp != "resp" || err != nil {
+		t.Errorf("Got %v, %v from the chain, want the handler's result", resp, err)
+	}
+
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got unary calls %v, want %v", calls, want)
+	}
+
+	calls = nil
+	handlerErr := errors.New("stream failed")
+
+	if err := chain.StreamInterceptor()(nil, fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		calls = append(calls, "handler")
+		return handlerErr
+	}); err != handlerErr {
+		t.Errorf("Got %v from the chain, want the handler's error", err)
+	}
+
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got stream calls %v, want %v", calls, want)
+	}
+}
+
+func TestEmptyChain(t *testing.T) {
+	resp, err := Chain{}.UnaryInterceptor()(context.Background(), "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+
+	if resp != "req" || err != nil {
+		t.Errorf("Got %v, %v from an empty chain, want the handler's result", resp, err)
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	var calls []string
+
+	for _, name := range []string{"test-a", "test-b"} {
+		name := name
+
+		if err := Register(name, func() (Interceptor, error) { return recorder(name, &calls), nil }); err != nil {
+			t.Fatalf("Failed to register %s: %v", name, err)
+		}
+	}
+
+	chain, err := NewChain("test-b,test-a")
+
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	chain.UnaryInterceptor()(context.Background(), "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	if want := []string{"test-b", "test-a", "test-a done", "test-b done"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got calls %v, want %v", calls, want)
+	}
+
+	if err := Register("test-a", func() (Interceptor, error) { return Interceptor{}, nil }); err == nil {
+		t.Errorf("Registered test-a twice")
+	}
+}
+
+func TestNewChainErrors(t *testing.T) {
+	if err := Register("test-broken", func() (Interceptor, error) { return Interceptor{}, errors.New("broken") }); err != nil {
+		t.Fatalf("Failed to register test-broken: %v", err)
+	}
+
+	for _, names := range []string{"unknown", "logging,logging", "logging,test-broken", "logging,"} {
+		if chain, err := NewChain(names); err == nil {
+			t.Errorf("Created chain %v from %q, want an error", chain, names)
+		}
+	}
+}
+
+func TestDefaultsRegistered(t *testing.T) {
+	if _, err := NewChain("monitoring,recovery,logging"); err != nil {
+		t.Errorf("Failed to create chain of the default interceptors: %v", err)
+	}
+}
@@ -2,13 +2,22 @@
 package log
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"time"
+
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/publisher"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/context"
 )
 
 // Sequencer instances are responsible for integrating new leaves into a log.
@@ -20,12 +29,34 @@ type Sequencer struct {
 	timeSource util.TimeSource
 	logStorage storage.LogStorage
 	keyManager crypto.KeyManager
+	publisher  publisher.Publisher
+	// rootMetadata gives the metadata signed with each new root, if it's nil roots have none
+	rootMetadata RootMetadataFunc
+	// claimOwner is who the sequencer claims leaves as if it shares the queue with others,
+	// for claimLease at a time. If it's empty leaves are dequeued without claiming them.
+	claimOwner string
+	claimLease time.Duration
+	// integrationDelay is told how long each integrated leaf was queued for, if it's set
+	integrationDelay IntegrationDelayFunc
+	// maxMergeDelay is the longest a leaf can be left queued by a new root, if it's 0 there's
+	// no limit
+	maxMergeDelay time.Duration
+	// mastershipCheck is called before each new root is committed, if it's nil the sequencer
+	// is assumed to be the only one for its log
+	mastershipCheck MastershipCheckFunc
 }
 
+// ErrMergeDelayExceeded is returned when a root isn't signed because it would leave out a
+// leaf that's been queued for longer than the log's max merge delay.
+var ErrMergeDelayExceeded = errors.New("log: leaves have been queued for longer than the max merge delay")
+
+// maxMergeDelayBatches is how many batches' worth of leaves that have been queued for longer
+// than the max merge delay the sequencer dequeues at a time while it catches up with them
+const maxMergeDelayBatches = 10
+
 // maxTreeDepth sets an upper limit on the size of Log trees.
 // TODO(al): We actually can't go beyond 2^63 entries becuase we use int64s,
-//           but we need to calculate tree depths from a multiple of 8 due to
-//           the subtrees.
+// but we need to calculate tree depths from a multiple of 8 due to the subtrees.
 const maxTreeDepth = 64
 
 // CurrentRootExpiredFunc examines a signed log root and decides if it has expired with respect
@@ -33,23 +64,80 @@ const maxTreeDepth = 64
 // TODO(Martin2112): This is all likely to go away when we switch to application STHs
 type CurrentRootExpiredFunc func(trillian.SignedLogRoot) bool
 
+// RootMetadataFunc returns the opaque metadata to sign with a new root. Every other field of
+// root is set, so personalities can use it to bind the root to something outside the log,
+// e.g. a shard ID or a position in another tree.
+type RootMetadataFunc func(ctx context.Context, root trillian.SignedLogRoot) ([]byte, error)
+
+// IntegrationDelayFunc is given how long a leaf was queued for, from when storage queued it to
+// the timestamp of the root that integrated it.
+type IntegrationDelayFunc func(delay time.Duration)
+
+// MastershipCheckFunc returns an error if the sequencer can't be sure it's still master for its
+// log, e.g. because its lease on mastership has lapsed.
+type MastershipCheckFunc func(ctx context.Context) error
+
 func NewSequencer(hasher merkle.TreeHasher, timeSource util.TimeSource, logStorage storage.LogStorage, km crypto.KeyManager) *Sequencer {
-	return &Sequencer{hasher, timeSource, logStorage, km}
+	return &Sequencer{hasher: hasher, timeSource: timeSource, logStorage: logStorage, keyManager: km, publisher: publisher.None{}}
+}
+
+// NewPublishingSequencer creates a Sequencer that passes each new signed root to p once it
+// has been committed.
+func NewPublishingSequencer(hasher merkle.TreeHasher, timeSource util.TimeSource, logStorage storage.LogStorage, km crypto.KeyManager, p publisher.Publisher) *Sequencer {
+	return &Sequencer{hasher: hasher, timeSource: timeSource, logStorage: logStorage, keyManager: km, publisher: p}
+}
+
+// SetRootMetadataFunc arranges for each new root to be signed with the metadata f returns
+// for it. A failure from f fails the signing of the root.
+func (s *Sequencer) SetRootMetadataFunc(f RootMetadataFunc) {
+	s.rootMetadata = f
+}
+
+// SetLeafClaims arranges for the sequencer to claim the leaves it integrates as owner before
+// dequeuing them, see storage.LeafClaimer, so that it can share the log's queue with other
+// sequencers. Each claim lasts for lease, and is renewed with every batch until the leaves
+// are integrated. Every sequencer of the log must claim leaves, with a different owner.
+func (s *Sequencer) SetLeafClaims(owner string, lease time.Duration) {
+	s.claimOwner = owner
+	s.claimLease = lease
+}
+
+// SetIntegrationDelayFunc arranges for f to be told the integration delay of each leaf of a
+// normal log once the root that integrates it has been committed.
+func (s *Sequencer) SetIntegrationDelayFunc(f IntegrationDelayFunc) {
+	s.integrationDelay = f
+}
+
+// SetMaxMergeDelay stops the sequencer signing a root that leaves out a leaf queued for longer
+// than mmd. Such leaves are dequeued ahead of any others, however many there are, so a backlog
+// is caught up with in one run. If one is still left queued when the root is signed, e.g.
+// because it became overdue during the run, the root isn't signed and ErrMergeDelayExceeded is
+// returned. It has no effect if the sequencer claims leaves, see SetLeafClaims, as those it
+// leaves queued may be integrated by another.
+func (s *Sequencer) SetMaxMergeDelay(mmd time.Duration) {
+	s.maxMergeDelay = mmd
+}
+
+// SetMastershipCheck arranges for f to be called just before each new root is committed. If it
+// fails the transaction is rolled back, so a sequencer that lost mastership part way through a
+// run can't store a root that conflicts with those of the new master.
+func (s *Sequencer) SetMastershipCheck(f MastershipCheckFunc) {
+	s.mastershipCheck = f
 }
 
 // TODO: This currently doesn't use the batch api for fetching the required nodes. This
 // would be more efficient but requires refactoring.
-func (s Sequencer) buildMerkleTreeFromStorageAtRoot(root trillian.SignedLogRoot, tx storage.TreeTX) (*merkle.CompactMerkleTree, error) {
+func (s Sequencer) buildMerkleTreeFromStorageAtRoot(ctx context.Context, root trillian.SignedLogRoot, tx storage.TreeTX) (*merkle.CompactMerkleTree, error) {
 	mt, err := merkle.NewCompactMerkleTreeWithState(s.hasher, root.TreeSize, func(depth int, index int64) (trillian.Hash, error) {
 		nodeId, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
 		if err != nil {
-			glog.Warningf("Failed to create nodeID: %v", err)
+			logging.Warningf(ctx, "Failed to create nodeID: %v", err)
 			return nil, err
 		}
-		nodes, err := tx.GetMerkleNodes(root.TreeRevision, []storage.NodeID{nodeId})
+		nodes, err := tx.GetMerkleNodes(ctx, root.TreeRevision, []storage.NodeID{nodeId})
 
 		if err != nil {
-			glog.Warningf("Failed to get merkle nodes: %s", err)
+			logging.Warningf(ctx, "Failed to get merkle nodes: %s", err)
 			return nil, err
 		}
 
@@ -108,16 +196,98 @@ func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []trillia
 	return nodeMap, sequenceNumbers, nil
 }
 
-func (s Sequencer) initMerkleTreeFromStorage(currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, error) {
+func (s Sequencer) initMerkleTreeFromStorage(ctx context.Context, currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, error) {
 	if currentRoot.TreeSize == 0 {
 		return merkle.NewCompactMerkleTree(s.hasher), nil
 	}
 
+	// Carry on from the checkpoint stored with the latest root if there is one, which saves
+	// reading the nodes back
+	mt, err := s.initMerkleTreeFromCheckpoint(ctx, currentRoot, tx)
+	if err != nil || mt != nil {
+		return mt, err
+	}
+
 	// Initialize the compact tree state to match the latest root in the database
-	return s.buildMerkleTreeFromStorageAtRoot(currentRoot, tx)
+	return s.buildMerkleTreeFromStorageAtRoot(ctx, currentRoot, tx)
+}
+
+// initMerkleTreeFromCheckpoint returns the compact tree saved in the log's sequencer
+// checkpoint, or nil if there isn't a checkpoint for currentRoot. A checkpoint that's for
+// another root, e.g. one stored before a crash by a sequencer that didn't store its root,
+// or that doesn't match the root hash is ignored and the tree is built from the nodes.
+func (s Sequencer) initMerkleTreeFromCheckpoint(ctx context.Context, currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, error) {
+	checkpoint, err := tx.GetSequencerCheckpoint(ctx)
+
+	if err != nil {
+		logging.Warningf(ctx, "Sequencer failed to get checkpoint: %s", err)
+		return nil, err
+	}
+
+	if checkpoint == nil || checkpoint.CompactTree == nil {
+		return nil, nil
+	}
+
+	if checkpoint.TreeRevision != currentRoot.TreeRevision || checkpoint.CompactTree.End != currentRoot.TreeSize {
+		logging.Warningf(ctx, "Ignoring sequencer checkpoint for size %d at revision %d, the latest root is for size %d at revision %d",
+			checkpoint.CompactTree.End, checkpoint.TreeRevision, currentRoot.TreeSize, currentRoot.TreeRevision)
+		return nil, nil
+	}
+
+	mt, err := merkle.NewCompactMerkleTreeFromProto(s.hasher, checkpoint.CompactTree)
+
+	if err != nil {
+		logging.Warningf(ctx, "Ignoring sequencer checkpoint at revision %d: %v", checkpoint.TreeRevision, err)
+		return nil, nil
+	}
+
+	if !bytes.Equal(mt.CurrentRoot(), currentRoot.RootHash) {
+		logging.Warningf(ctx, "Ignoring sequencer checkpoint at revision %d, it has root hash %s but the root has %s",
+			checkpoint.TreeRevision, mt.CurrentRoot(), trillian.Hash(currentRoot.RootHash))
+		return nil, nil
+	}
+
+	return mt, nil
 }
 
-func (s Sequencer) signRoot(root trillian.SignedLogRoot) (trillian.DigitallySigned, error) {
+// storeCheckpoint saves the state of mt with the root being stored at revision, so the next
+// run can carry on from it
+func (s Sequencer) storeCheckpoint(ctx context.Context, mt *merkle.CompactMerkleTree, revision int64, tx storage.LogTX) error {
+	checkpoint := storage.SequencerCheckpoint{TreeRevision: revision, CompactTree: mt.Proto()}
+
+	if err := tx.StoreSequencerCheckpoint(ctx, checkpoint); err != nil {
+		logging.Warningf(ctx, "Sequencer failed to store checkpoint at revision %d: %s", revision, err)
+		return err
+	}
+
+	return nil
+}
+
+// addRootMetadata sets the metadata of root ready for it to be signed
+func (s Sequencer) addRootMetadata(ctx context.Context, root *trillian.SignedLogRoot) error {
+	if s.rootMetadata == nil {
+		return nil
+	}
+
+	metadata, err := s.rootMetadata(ctx, *root)
+
+	if err != nil {
+		logging.Warningf(ctx, "failed to get metadata for root at revision %d: %v", root.TreeRevision, err)
+		return err
+	}
+
+	root.Metadata = metadata
+	return nil
+}
+
+func (s Sequencer) signRoot(ctx context.Context, root trillian.SignedLogRoot) (trillian.DigitallySigned, error) {
+	_, span := monitoring.StartSpan(ctx, "Sequencer.signRoot")
+	signature, err := s.signRootInternal(root)
+	monitoring.EndSpan(span, err)
+	return signature, err
+}
+
+func (s Sequencer) signRootInternal(root trillian.SignedLogRoot) (trillian.DigitallySigned, error) {
 	signer, err := s.keyManager.Signer()
 
 	if err != nil {
@@ -125,8 +295,17 @@ func (s Sequencer) signRoot(root trillian.SignedLogRoot) (trillian.DigitallySign
 		return trillian.DigitallySigned{}, err
 	}
 
-	// TODO(Martin2112): Signature algorithm shouldn't be fixed here
-	trillianSigner := crypto.NewTrillianSigner(s.hasher.Hasher, trillian.SignatureAlgorithm_ECDSA, signer)
+	// The signature algorithm depends on the type of key the log is using
+	sigAlgorithm, err := crypto.SignatureAlgorithmForKey(signer.Public())
+
+	if err != nil {
+		glog.Warningf("key manager returned signer with unusable key: %v", err)
+		return trillian.DigitallySigned{}, err
+	}
+
+	// Roots are signed over their SHA-256 digest whatever hash the tree uses, as that's the
+	// only one clients verify signatures with
+	trillianSigner := crypto.NewTrillianSigner(trillian.NewSHA256(), sigAlgorithm, signer)
 
 	signature, err := trillianSigner.SignLogRoot(root)
 
@@ -138,39 +317,200 @@ func (s Sequencer) signRoot(root trillian.SignedLogRoot) (trillian.DigitallySign
 	return signature, nil
 }
 
+// enforcesMergeDelay returns whether roots are kept within the max merge delay
+func (s Sequencer) enforcesMergeDelay() bool {
+	return s.maxMergeDelay > 0 && len(s.claimOwner) == 0
+}
+
+// checkMergeDelay returns ErrMergeDelayExceeded if a leaf still queued outside tx will have
+// been queued for longer than the max merge delay at nowNanos
+func (s Sequencer) checkMergeDelay(ctx context.Context, tx storage.LogTX, nowNanos int64) error {
+	if !s.enforcesMergeDelay() {
+		return nil
+	}
+
+	oldest, err := tx.OldestQueuedLeafNanos(ctx, nowNanos)
+
+	if err != nil {
+		logging.Warningf(ctx, "Sequencer failed to find oldest queued leaf: %s", err)
+		return err
+	}
+
+	if oldest > 0 && time.Duration(nowNanos-oldest) > s.maxMergeDelay {
+		logging.Errorf(ctx, "Refusing to sign root: a leaf queued at %d would be queued for %v, longer than the max merge delay of %v", oldest, time.Duration(nowNanos-oldest), s.maxMergeDelay)
+		return ErrMergeDelayExceeded
+	}
+
+	return nil
+}
+
+// dequeueOverdueLeaves dequeues all the leaves that will have been queued for longer than the
+// max merge delay at nowNanos, the longest queued first, maxMergeDelayBatches batches of limit
+// at a time. Higher priority leaves queued since then would otherwise keep them out.
+func (s Sequencer) dequeueOverdueLeaves(ctx context.Context, tx storage.LogTX, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	var leaves []trillian.LogLeaf
+
+	if !s.enforcesMergeDelay() {
+		return leaves, nil
+	}
+
+	for {
+		oldest, err := tx.OldestQueuedLeafNanos(ctx, nowNanos)
+
+		if err != nil {
+			logging.Warningf(ctx, "Sequencer failed to find oldest queued leaf: %s", err)
+			return nil, err
+		}
+
+		if oldest == 0 || time.Duration(nowNanos-oldest) <= s.maxMergeDelay {
+			return leaves, nil
+		}
+
+		overdue, err := tx.DequeueOverdueLeaves(ctx, limit*maxMergeDelayBatches, nowNanos-int64(s.maxMergeDelay), nowNanos)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Any left can't be dequeued, the root will be refused
+		if len(overdue) == 0 {
+			return leaves, nil
+		}
+
+		leaves = append(leaves, overdue...)
+	}
+}
+
+// reportIntegrationDelays tells the integration delay func how long each of leaves was queued
+// for before root
+func (s Sequencer) reportIntegrationDelays(leaves []trillian.LogLeaf, root trillian.SignedLogRoot) {
+	if s.integrationDelay == nil {
+		return
+	}
+
+	for _, leaf := range leaves {
+		if leaf.QueueTimestampNanos > 0 {
+			s.integrationDelay(time.Duration(root.TimestampNanos - leaf.QueueTimestampNanos))
+		}
+	}
+}
+
+// checkMastership fails if the sequencer has a mastership check and it fails
+func (s Sequencer) checkMastership(ctx context.Context) error {
+	if s.mastershipCheck == nil {
+		return nil
+	}
+
+	if err := s.mastershipCheck(ctx); err != nil {
+		logging.Warningf(ctx, "Sequencer isn't master, not committing root: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// publishRoot hands a committed root to the publisher. The root is already stored so a
+// failure is only logged, it'll be available through the API regardless.
+func (s Sequencer) publishRoot(root trillian.SignedLogRoot) {
+	if err := s.publisher.PublishLogRoot(root); err != nil {
+		glog.Warningf("failed to publish root at revision %d: %v", root.TreeRevision, err)
+	}
+}
+
 // SequenceBatch wraps up all the operations needed to take a batch of queued leaves
-// and integrate them into the tree.
+// and integrate them into the tree. The batch is traced as part of ctx.
+func (s Sequencer) SequenceBatch(ctx context.Context, limit int, expiryFunc CurrentRootExpiredFunc) (int, error) {
+	ctx, span := monitoring.StartSpan(ctx, "Sequencer.SequenceBatch", attribute.Int("limit", limit))
+	leaves, err := s.sequenceBatch(ctx, limit, expiryFunc)
+	span.SetAttributes(attribute.Int("leaves", leaves))
+	monitoring.EndSpan(span, err)
+	return leaves, err
+}
+
+// claimLeaves claims up to limit queued leaves for the sequencer, renewing the claims it
+// already holds. They're claimed in a transaction of their own so that other sequencers see
+// the claims while the leaves are being integrated.
+func (s Sequencer) claimLeaves(ctx context.Context, limit int) error {
+	tx, err := s.logStorage.Begin(ctx)
+
+	if err != nil {
+		logging.Warningf(ctx, "Sequencer failed to start claim tx: %s", err)
+		return err
+	}
+
+	now := s.timeSource.Now()
+	claim := storage.LeafClaim{Owner: s.claimOwner, ExpiryNanos: now.Add(s.claimLease).UnixNano()}
+
+	if _, err := tx.ClaimLeaves(ctx, claim, now.UnixNano(), limit); err != nil {
+		logging.Warningf(ctx, "Sequencer failed to claim leaves: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logging.Warningf(ctx, "Sequencer failed to commit claims: %s", err)
+		return err
+	}
+
+	return nil
+}
+
 // TODO(Martin2112): Can possibly improve by deferring a function that attempts to rollback,
 // which will fail if the tx was committed. Should only do this if we can hide the details of
 // the underlying storage transactions and it doesn't create other problems.
-func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (int, error) {
-	tx, err := s.logStorage.Begin()
+func (s Sequencer) sequenceBatch(ctx context.Context, limit int, expiryFunc CurrentRootExpiredFunc) (int, error) {
+	if len(s.claimOwner) > 0 {
+		if err := s.claimLeaves(ctx, limit); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := s.logStorage.Begin(ctx)
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to start tx: %s", err)
+		logging.Warningf(ctx, "Sequencer failed to start tx: %s", err)
 		return 0, err
 	}
 
-	leaves, err := tx.DequeueLeaves(limit)
+	now := s.timeSource.Now().UnixNano()
+
+	// Leaves mustn't be left queued for longer than the max merge delay, so those that would
+	// be are taken in first, however many there are, and the batch is made up with others
+	overdue, err := s.dequeueOverdueLeaves(ctx, tx, limit, now)
+
+	if err != nil {
+		logging.Warningf(ctx, "Sequencer failed to dequeue overdue leaves: %s", err)
+		tx.Rollback()
+		return 0, err
+	}
+
+	leaves := overdue
+	if len(s.claimOwner) > 0 {
+		leaves, err = tx.DequeueClaimedLeaves(ctx, s.claimOwner, limit)
+	} else if len(overdue) < limit {
+		var queued []trillian.LogLeaf
+		queued, err = tx.DequeueLeaves(ctx, limit-len(overdue), now)
+		leaves = append(leaves, queued...)
+	}
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to dequeue leaves: %s", err)
+		logging.Warningf(ctx, "Sequencer failed to dequeue leaves: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
 
 	// Get the latest known root from storage
-	currentRoot, err := tx.LatestSignedLogRoot()
+	currentRoot, err := tx.LatestSignedLogRoot(ctx)
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to get latest root: %s", err)
+		logging.Warningf(ctx, "Sequencer failed to get latest root: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
 
 	// TODO(al): Have a better detection mechanism for there being no stored root.
 	if currentRoot.RootHash == nil {
-		glog.Warning("Fresh log - no previous TreeHeads exist.")
+		logging.Warningf(ctx, "Fresh log - no previous TreeHeads exist.")
 	}
 
 	// There might be no work to be done. But we possibly still need to create an STH if the
@@ -181,12 +521,12 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 		if expiryFunc(currentRoot) {
 			// Current root is too old, sign one. Will use a new TX, safe as we have no writes
 			// pending in this one.
-			return 0, s.SignRoot()
+			return 0, s.SignRoot(ctx)
 		}
 		return 0, nil
 	}
 
-	merkleTree, err := s.initMerkleTreeFromStorage(currentRoot, tx)
+	merkleTree, err := s.initMerkleTreeFromStorage(ctx, currentRoot, tx)
 
 	if err != nil {
 		tx.Rollback()
@@ -215,15 +555,24 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 			len(leaves)))
 	}
 
+	// The leaves of a pre-ordered log already have sequence numbers, which the tree we built
+	// must agree with
+	preordered := s.logStorage.TreeType() == trillian.TreeType_PREORDERED_LOG
+
 	for index, _ := range sequenceNumbers {
+		if preordered && leaves[index].SequenceNumber != sequenceNumbers[index] {
+			tx.Rollback()
+			return 0, fmt.Errorf("pre-ordered leaf with sequence number %d would be integrated at %d", leaves[index].SequenceNumber, sequenceNumbers[index])
+		}
+
 		leaves[index].SequenceNumber = sequenceNumbers[index]
 	}
 
 	// Write the new sequence numbers to the leaves in the DB
-	err = tx.UpdateSequencedLeaves(leaves)
+	err = tx.UpdateSequencedLeaves(ctx, leaves)
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to update sequenced leaves: %s", err)
+		logging.Warningf(ctx, "Sequencer failed to update sequenced leaves: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
@@ -235,16 +584,16 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 
 	if err != nil {
 		// probably an internal error with map building, unexpected
-		glog.Warningf("Failed to build target nodes in sequencer: %s", err)
+		logging.Warningf(ctx, "Failed to build target nodes in sequencer: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
 
 	// Now insert or update the nodes affected by the above, at the new tree version
-	err = tx.SetMerkleNodes(targetNodes)
+	err = tx.SetMerkleNodes(ctx, targetNodes)
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to set merkle nodes: %s", err)
+		logging.Warningf(ctx, "Sequencer failed to set merkle nodes: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
@@ -258,21 +607,44 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 		TreeRevision:   newVersion,
 	}
 
+	// Overdue leaves were all taken in, but others may have become overdue since
+	if err := s.checkMergeDelay(ctx, tx, newLogRoot.TimestampNanos); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := s.addRootMetadata(ctx, &newLogRoot); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	// Hash and sign the root, update it with the signature
-	signature, err := s.signRoot(newLogRoot)
+	signature, err := s.signRoot(ctx, newLogRoot)
 
 	if err != nil {
-		glog.Warningf("signer failed to sign root: %v", err)
+		logging.Warningf(ctx, "signer failed to sign root: %v", err)
 		tx.Rollback()
 		return 0, err
 	}
 
 	newLogRoot.Signature = &signature
 
-	err = tx.StoreSignedLogRoot(newLogRoot)
+	// The checkpoint is committed with the leaves, nodes and root, so after a crash the
+	// sequencer either carries on from this batch or redoes it entirely
+	if err := s.storeCheckpoint(ctx, merkleTree, newVersion, tx); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.StoreSignedLogRoot(ctx, newLogRoot)
 
 	if err != nil {
-		glog.Warningf("failed to write updated tree root: %s", err)
+		logging.Warningf(ctx, "failed to write updated tree root: %s", err)
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := s.checkMastership(ctx); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -282,30 +654,41 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 		return 0, err
 	}
 
+	s.reportIntegrationDelays(leaves, newLogRoot)
+	s.publishRoot(newLogRoot)
+
 	return len(leaves), nil
 }
 
-// SignRoot wraps up all the operations for creating a new log signed root.
-func (s Sequencer) SignRoot() error {
-	tx, err := s.logStorage.Begin()
+// SignRoot wraps up all the operations for creating a new log signed root. The signing is
+// traced as part of ctx.
+func (s Sequencer) SignRoot(ctx context.Context) error {
+	ctx, span := monitoring.StartSpan(ctx, "Sequencer.SignRoot")
+	err := s.signAndStoreRoot(ctx)
+	monitoring.EndSpan(span, err)
+	return err
+}
+
+func (s Sequencer) signAndStoreRoot(ctx context.Context) error {
+	tx, err := s.logStorage.Begin(ctx)
 
 	if err != nil {
-		glog.Warningf("signer failed to start tx: %s", err)
+		logging.Warningf(ctx, "signer failed to start tx: %s", err)
 		return err
 	}
 
 	// Get the latest known root from storage
-	currentRoot, err := tx.LatestSignedLogRoot()
+	currentRoot, err := tx.LatestSignedLogRoot(ctx)
 
 	if err != nil {
-		glog.Warningf("signer failed to get latest root: %s", err)
+		logging.Warningf(ctx, "signer failed to get latest root: %s", err)
 		tx.Rollback()
 		return err
 	}
 
 	// Initialize a Merkle Tree from the state in storage. This should fail if the tree is
 	// in a corrupt state.
-	merkleTree, err := s.initMerkleTreeFromStorage(currentRoot, tx)
+	merkleTree, err := s.initMerkleTreeFromStorage(ctx, currentRoot, tx)
 
 	if err != nil {
 		tx.Rollback()
@@ -321,23 +704,50 @@ func (s Sequencer) SignRoot() error {
 		TreeRevision:   currentRoot.TreeRevision + 1,
 	}
 
+	// The root integrates no leaves, so it can't be signed if any are overdue
+	if err := s.checkMergeDelay(ctx, tx, newLogRoot.TimestampNanos); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.addRootMetadata(ctx, &newLogRoot); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Hash and sign the root
-	signature, err := s.signRoot(newLogRoot)
+	signature, err := s.signRoot(ctx, newLogRoot)
 
 	if err != nil {
-		glog.Warningf("signer failed to sign root: %v", err)
+		logging.Warningf(ctx, "signer failed to sign root: %v", err)
 		tx.Rollback()
 		return err
 	}
 
 	newLogRoot.Signature = &signature
 
+	if err := s.storeCheckpoint(ctx, merkleTree, newLogRoot.TreeRevision, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Store the new root and we're done
-	if err := tx.StoreSignedLogRoot(newLogRoot); err != nil {
-		glog.Warningf("signer failed to write updated root: %v", err)
+	if err := tx.StoreSignedLogRoot(ctx, newLogRoot); err != nil {
+		logging.Warningf(ctx, "signer failed to write updated root: %v", err)
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.checkMastership(ctx); err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.publishRoot(newLogRoot)
+
+	return nil
 }
@@ -1,18 +1,25 @@
 package log
 
 import (
+	"crypto/ecdsa"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/verify"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // Long duration to prevent root signing kicking in for tests where we're only testing
@@ -100,6 +107,11 @@ type testParameters struct {
 	merkleNodesSet      *[]storage.Node
 	merkleNodesSetError error
 
+	checkpoint           *storage.SequencerCheckpoint
+	checkpointError      error
+	storeCheckpoint      *storage.SequencerCheckpoint
+	storeCheckpointError error
+
 	skipStoreSignedRoot  bool
 	storeSignedRoot      *trillian.SignedLogRoot
 	storeSignedRootError error
@@ -111,6 +123,9 @@ type testParameters struct {
 	signingError    error
 
 	writeRevision int64
+
+	// treeType defaults to a normal log
+	treeType trillian.TreeType
 }
 
 // Tests get their own mock context so they can be run in parallel safely
@@ -147,10 +162,16 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) testConte
 
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(params.writeRevision)
 
+	if params.treeType == trillian.TreeType_UNKNOWN_TREE_TYPE {
+		params.treeType = trillian.TreeType_LOG
+	}
+
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(params.treeType)
+
 	if params.beginFails {
-		mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, errors.New("TX"))
+		mockStorage.EXPECT().Begin(gomock.Any()).AnyTimes().Return(mockTx, errors.New("TX"))
 	} else {
-		mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
+		mockStorage.EXPECT().Begin(gomock.Any()).AnyTimes().Return(mockTx, nil)
 	}
 
 	if params.shouldCommit {
@@ -166,27 +187,35 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) testConte
 	}
 
 	if !params.skipDequeue {
-		mockTx.EXPECT().DequeueLeaves(params.dequeueLimit).AnyTimes().Return(params.dequeuedLeaves, params.dequeuedError)
+		mockTx.EXPECT().DequeueLeaves(gomock.Any(), params.dequeueLimit, fakeTimeForTest.UnixNano()).AnyTimes().Return(params.dequeuedLeaves, params.dequeuedError)
 	}
 
 	if params.latestSignedRoot != nil {
-		mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(*params.latestSignedRoot, params.latestSignedRootError)
+		mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).AnyTimes().Return(*params.latestSignedRoot, params.latestSignedRootError)
 	}
 
 	if params.updatedLeaves != nil {
-		mockTx.EXPECT().UpdateSequencedLeaves(*params.updatedLeaves).AnyTimes().Return(params.updatedLeavesError)
+		mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), *params.updatedLeaves).AnyTimes().Return(params.updatedLeavesError)
 	}
 
 	if params.merkleNodesSet != nil {
-		mockTx.EXPECT().SetMerkleNodes(testonly.NodeSet(*params.merkleNodesSet)).AnyTimes().Return(params.merkleNodesSetError)
+		mockTx.EXPECT().SetMerkleNodes(gomock.Any(), testonly.NodeSet(*params.merkleNodesSet)).AnyTimes().Return(params.merkleNodesSetError)
+	}
+
+	mockTx.EXPECT().GetSequencerCheckpoint(gomock.Any()).AnyTimes().Return(params.checkpoint, params.checkpointError)
+
+	if params.storeCheckpoint != nil {
+		mockTx.EXPECT().StoreSequencerCheckpoint(gomock.Any(), *params.storeCheckpoint).AnyTimes().Return(params.storeCheckpointError)
+	} else {
+		mockTx.EXPECT().StoreSequencerCheckpoint(gomock.Any(), gomock.Any()).AnyTimes().Return(params.storeCheckpointError)
 	}
 
 	if !params.skipStoreSignedRoot {
 		if params.storeSignedRoot != nil {
-			mockTx.EXPECT().StoreSignedLogRoot(*params.storeSignedRoot).AnyTimes().Return(params.storeSignedRootError)
+			mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), *params.storeSignedRoot).AnyTimes().Return(params.storeSignedRootError)
 		} else {
 			// At the moment if we're going to fail the operation we accept any root
-			mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).AnyTimes().Return(params.storeSignedRootError)
+			mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), gomock.Any()).AnyTimes().Return(params.storeSignedRootError)
 		}
 	}
 
@@ -195,6 +224,7 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) testConte
 
 	if params.setupSigner {
 		mockSigner := crypto.NewMockSigner(ctrl)
+		mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
 		mockSigner.EXPECT().Sign(gomock.Any(), params.dataToSign, hasher.Hasher).AnyTimes().Return(params.signingResult, params.signingError)
 		mockKeyManager.EXPECT().Signer().AnyTimes().Return(mockSigner, params.keyManagerError)
 	}
@@ -214,7 +244,7 @@ func TestBeginTXFails(t *testing.T) {
 	params := testParameters{beginFails: true, skipDequeue: true, skipStoreSignedRoot: true}
 	c := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leaves, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leaves != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
 	}
@@ -229,7 +259,7 @@ func TestSequenceWithNothingQueued(t *testing.T) {
 
 	c := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leaves, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leaves != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
 	}
@@ -246,7 +276,7 @@ func TestDequeueError(t *testing.T) {
 	params := testParameters{dequeueLimit: 1, shouldRollback: true, dequeuedError: errors.New("dequeue")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	testonly.EnsureErrorContains(t, err, "dequeue")
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
@@ -262,7 +292,7 @@ func TestLatestRootError(t *testing.T) {
 		latestSignedRoot: &testRoot16, latestSignedRootError: errors.New("root")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -280,7 +310,7 @@ func TestUpdateSequencedLeavesError(t *testing.T) {
 		updatedLeavesError: errors.New("unsequenced")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -298,7 +328,7 @@ func TestSetMerkleNodesError(t *testing.T) {
 		merkleNodesSetError: errors.New("setmerklenodes")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -315,11 +345,11 @@ func TestStoreSignedRootError(t *testing.T) {
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot:      nil,
 		storeSignedRootError: errors.New("storesignedroot"), setupSigner: true,
-		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -339,7 +369,7 @@ func TestStoreSignedRootKeyManagerFails(t *testing.T) {
 		keyManagerError: errors.New("keymanagerfailed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -356,11 +386,11 @@ func TestStoreSignedRootSignerFails(t *testing.T) {
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot: nil,
 		setupSigner:     true,
-		dataToSign:      []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		dataToSign:      []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
 		signingError:    errors.New("signerfailed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -378,17 +408,43 @@ func TestCommitFails(t *testing.T) {
 		commitError: errors.New("commit"), dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot: nil, setupSigner: true,
-		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "commit")
 }
 
+func TestSequenceBatchMastershipLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+
+	// Everything's written, but the transaction mustn't be committed
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetMastershipCheck(func(context.Context) error {
+		return errors.New("mastership")
+	})
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves without mastership", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "mastership")
+}
+
 // TODO: We used a perfect tree size so this isn't testing code that loads the compact merkle
 // tree. This will be done later as it's planned to refactor it anyway.
 func TestSequenceBatch(t *testing.T) {
@@ -401,11 +457,11 @@ func TestSequenceBatch(t *testing.T) {
 		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
 		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
-		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
 	if err != nil {
 		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
 	}
@@ -414,6 +470,94 @@ func TestSequenceBatch(t *testing.T) {
 	}
 }
 
+func TestSequenceBatchPreordered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The leaf already has the next sequence number so it's integrated there
+	leaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed"), treeType: trillian.TreeType_PREORDERED_LOG}
+	c := createTestContext(ctrl, params)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+	if got, want := leafCount, 1; got != want {
+		t.Fatalf("Sequenced %d leaf, expected %d", got, want)
+	}
+}
+
+func TestSequenceBatchClaimsLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, shouldCommit: true, skipDequeue: true,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetLeafClaims("sequencer-1", time.Minute)
+
+	claim := storage.LeafClaim{Owner: "sequencer-1", ExpiryNanos: fakeTimeForTest.Add(time.Minute).UnixNano()}
+	gomock.InOrder(
+		c.mockTx.EXPECT().ClaimLeaves(gomock.Any(), claim, fakeTimeForTest.UnixNano(), 1).Return(leaves, nil),
+		c.mockTx.EXPECT().DequeueClaimedLeaves(gomock.Any(), "sequencer-1", 1).Return(leaves, nil),
+	)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+	if got, want := leafCount, 1; got != want {
+		t.Fatalf("Sequenced %d leaf, expected %d", got, want)
+	}
+}
+
+func TestSequenceBatchClaimLeavesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{shouldRollback: true, skipDequeue: true, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetLeafClaims("sequencer-1", time.Minute)
+
+	c.mockTx.EXPECT().ClaimLeaves(gomock.Any(), gomock.Any(), gomock.Any(), 1).Return(nil, errors.New("claim"))
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "claim")
+}
+
+func TestSequenceBatchPreorderedWrongSequenceNumber(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Storage should only return leaves that follow on from the tree size
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16, treeType: trillian.TreeType_PREORDERED_LOG}
+	c := createTestContext(ctrl, params)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "pre-ordered")
+}
+
 func TestSignBeginTxFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -421,7 +565,7 @@ func TestSignBeginTxFails(t *testing.T) {
 	params := testParameters{beginFails: true}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "TX")
 }
 
@@ -434,7 +578,7 @@ func TestSignLatestRootFails(t *testing.T) {
 		latestSignedRoot: &testRoot16, latestSignedRootError: errors.New("root")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "root")
 }
 
@@ -449,7 +593,7 @@ func TestSignedRootKeyManagerFails(t *testing.T) {
 		setupSigner:      true, keyManagerError: errors.New("keymanagerfailed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "keymanager")
 }
 
@@ -461,11 +605,11 @@ func TestSignRootSignerFails(t *testing.T) {
 		dequeueLimit: 1, shouldRollback: true,
 		latestSignedRoot: &testRoot16,
 		storeSignedRoot:  nil, setupSigner: true,
-		dataToSign:   []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		dataToSign:   []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
 		signingError: errors.New("signerfailed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "signer")
 }
 
@@ -478,11 +622,11 @@ func TestSignRootStoreSignedRootFails(t *testing.T) {
 		latestSignedRoot:     &testRoot16,
 		storeSignedRoot:      nil,
 		storeSignedRootError: errors.New("storesignedroot"), setupSigner: true,
-		dataToSign:    []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		dataToSign:    []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "storesignedroot")
 }
 
@@ -495,11 +639,11 @@ func TestSignRootCommitFails(t *testing.T) {
 		commitError:      errors.New("commit"),
 		latestSignedRoot: &testRoot16,
 		storeSignedRoot:  nil, setupSigner: true,
-		dataToSign:    []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		dataToSign:    []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "commit")
 }
 
@@ -512,15 +656,35 @@ func TestSignRoot(t *testing.T) {
 		latestSignedRoot: &testRoot16,
 		storeSignedRoot:  &expectedSignedRoot16,
 		setupSigner:      true,
-		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		dataToSign:       []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
 		signingResult:    []byte("signed"), shouldCommit: true}
 	c := createTestContext(ctrl, params)
 
-	if err := c.sequencer.SignRoot(); err != nil {
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
 		t.Fatalf("Expected signing to succeed, but got err: %v", err)
 	}
 }
 
+func TestSignRootMastershipLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
+		signingResult:    []byte("signed")}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetMastershipCheck(func(context.Context) error {
+		return errors.New("mastership")
+	})
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "mastership")
+}
+
 func TestSignRootNoExistingRoot(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -530,11 +694,541 @@ func TestSignRootNoExistingRoot(t *testing.T) {
 		latestSignedRoot: &trillian.SignedLogRoot{},
 		storeSignedRoot:  &expectedSignedRoot0,
 		setupSigner:      true,
-		dataToSign:       []byte{0xc2, 0xc, 0x1e, 0x33, 0x8, 0xcd, 0x2d, 0x50, 0xbb, 0xf9, 0xf9, 0x1, 0x29, 0xb2, 0xfb, 0xb9, 0x4d, 0x30, 0x27, 0x84, 0xf2, 0xc0, 0x48, 0x5f, 0x46, 0xd4, 0xbe, 0x8a, 0xb8, 0x27, 0x96, 0x22},
+		dataToSign:       []byte{0x41, 0x1d, 0xf4, 0x49, 0x46, 0xaf, 0x64, 0xec, 0x8, 0x91, 0x93, 0x18, 0xb4, 0xb2, 0x90, 0x24, 0x6b, 0x21, 0x8b, 0xde, 0xaa, 0x33, 0xc6, 0xc6, 0x27, 0xb4, 0xf0, 0xdb, 0xa8, 0x78, 0xcf, 0xa},
 		signingResult:    []byte("signed"), shouldCommit: true}
 	c := createTestContext(ctrl, params)
 
-	if err := c.sequencer.SignRoot(); err != nil {
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
 		t.Fatalf("Expected signing to succeed, but got err: %v", err)
 	}
 }
+
+func TestSignRootOfNonSHA256TreeVerifies(t *testing.T) {
+	ctx := context.Background()
+	p, err := storage.NewProvider(memory.ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("sha512_256"), TreeID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA512_256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	sequencer := NewSequencer(hasher, util.FakeTimeSource{FakeTime: fakeTimeForTest}, s, km)
+	if err := sequencer.SignRoot(ctx); err != nil {
+		t.Fatalf("SignRoot()=%v", err)
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+
+	pub, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatalf("GetPublicKey()=%v", err)
+	}
+
+	// Clients verify the signature the same way whatever hash the tree uses
+	if err := crypto.VerifySignedLogRoot(pub, root); err != nil {
+		t.Errorf("VerifySignedLogRoot()=%v, want nil", err)
+	}
+}
+
+func TestSignRootWithMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadata := []byte("shard 3")
+	wantRoot := expectedSignedRoot16
+	wantRoot.Metadata = metadata
+
+	data, err := verify.LogRootV1{TreeSize: wantRoot.TreeSize, RootHash: wantRoot.RootHash, TimestampNanos: wantRoot.TimestampNanos,
+		Revision: wantRoot.TreeRevision, Metadata: metadata}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize root: %v", err)
+	}
+	digest := sha256.Sum256(data)
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &wantRoot,
+		setupSigner:      true,
+		dataToSign:       digest[:],
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	var gotRoot trillian.SignedLogRoot
+	c.sequencer.SetRootMetadataFunc(func(ctx context.Context, root trillian.SignedLogRoot) ([]byte, error) {
+		gotRoot = root
+		return metadata, nil
+	})
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+
+	// The metadata func is given the root before it's signed
+	if gotRoot.TreeSize != wantRoot.TreeSize || gotRoot.TreeRevision != wantRoot.TreeRevision || gotRoot.Signature != nil {
+		t.Errorf("Metadata func got root %v, expected the unsigned root at revision %d", gotRoot, wantRoot.TreeRevision)
+	}
+}
+
+func TestSignRootMetadataFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:      true,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetRootMetadataFunc(func(ctx context.Context, root trillian.SignedLogRoot) ([]byte, error) {
+		return nil, errors.New("nometadata")
+	})
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "nometadata")
+}
+
+// recordingPublisher keeps the log roots published to it
+type recordingPublisher struct {
+	roots []trillian.SignedLogRoot
+	err   error
+}
+
+func (r *recordingPublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	r.roots = append(r.roots, root)
+	return r.err
+}
+
+func (r *recordingPublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return errors.New("not a map")
+}
+
+func (r *recordingPublisher) checkPublished(want []trillian.SignedLogRoot, t *testing.T) {
+	if got := len(r.roots); got != len(want) {
+		t.Fatalf("Published %d roots, expected %d", got, len(want))
+	}
+
+	for i := range want {
+		if !proto.Equal(&want[i], &r.roots[i]) {
+			t.Errorf("Published root %v, expected %v", r.roots[i], want[i])
+		}
+	}
+}
+
+func TestSequenceBatchPublishesRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	p := &recordingPublisher{}
+	c.sequencer.publisher = p
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+
+	p.checkPublished([]trillian.SignedLogRoot{expectedSignedRoot}, t)
+}
+
+func TestCommitFailsDoesNotPublish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true, commitFails: true,
+		commitError: errors.New("commit"), dequeuedLeaves: leaves,
+		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: nil, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	p := &recordingPublisher{}
+	c.sequencer.publisher = p
+
+	_, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	testonly.EnsureErrorContains(t, err, "commit")
+
+	p.checkPublished([]trillian.SignedLogRoot{}, t)
+}
+
+func TestSignRootPublishesRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0xc8, 0x64, 0x97, 0x79, 0x1b, 0x6d, 0x2, 0x6, 0x47, 0x75, 0x79, 0xa6, 0x87, 0x64, 0xca, 0xbc, 0xe5, 0xfa, 0xe1, 0xac, 0xde, 0xe4, 0x2b, 0x15, 0xad, 0x18, 0xbd, 0xc, 0xd8, 0x55, 0x2a, 0xc6},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+	// The root is already committed so a publishing failure doesn't fail signing
+	p := &recordingPublisher{err: errors.New("publish")}
+	c.sequencer.publisher = p
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+
+	p.checkPublished([]trillian.SignedLogRoot{expectedSignedRoot16}, t)
+}
+
+// checkpointTree returns a compact tree holding the given leaf hashes
+func checkpointTree(leafHashes ...trillian.Hash) *merkle.CompactMerkleTree {
+	mt := merkle.NewCompactMerkleTree(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	for _, h := range leafHashes {
+		mt.AddLeafHash(h, func(int, int64, trillian.Hash) {})
+	}
+	return mt
+}
+
+// signRootParams returns the parameters for signing a new root over mt, when the latest root
+// is at currentRoot's revision
+func signRootParams(t *testing.T, currentRoot trillian.SignedLogRoot, mt *merkle.CompactMerkleTree) testParameters {
+	wantRoot := trillian.SignedLogRoot{
+		RootHash:       mt.CurrentRoot(),
+		TimestampNanos: fakeTimeForTest.UnixNano(),
+		TreeRevision:   currentRoot.TreeRevision + 1,
+		TreeSize:       mt.Size(),
+		Signature:      &trillian.DigitallySigned{Signature: []byte("signed")},
+	}
+
+	data, err := verify.LogRootV1{TreeSize: wantRoot.TreeSize, RootHash: wantRoot.RootHash, TimestampNanos: wantRoot.TimestampNanos,
+		Revision: wantRoot.TreeRevision}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize root: %v", err)
+	}
+	digest := sha256.Sum256(data)
+
+	return testParameters{writeRevision: currentRoot.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &currentRoot,
+		storeCheckpoint:  &storage.SequencerCheckpoint{TreeRevision: wantRoot.TreeRevision, CompactTree: mt.Proto()},
+		storeSignedRoot:  &wantRoot,
+		setupSigner:      true,
+		dataToSign:       digest[:],
+		signingResult:    []byte("signed"), shouldCommit: true}
+}
+
+func TestSignRootResumesFromCheckpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The tree isn't perfect so building it from storage would need GetMerkleNodes, which
+	// the mock doesn't expect
+	mt := checkpointTree(trillian.Hash{1}, trillian.Hash{2}, trillian.Hash{3})
+	currentRoot := trillian.SignedLogRoot{TreeSize: 3, TreeRevision: 5, RootHash: mt.CurrentRoot()}
+
+	params := signRootParams(t, currentRoot, mt)
+	params.checkpoint = &storage.SequencerCheckpoint{TreeRevision: 5, CompactTree: mt.Proto()}
+	c := createTestContext(ctrl, params)
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootIgnoresCheckpoint(t *testing.T) {
+	mt := checkpointTree(trillian.Hash{1}, trillian.Hash{2}, trillian.Hash{3}, trillian.Hash{4})
+	otherTree := checkpointTree(trillian.Hash{5}, trillian.Hash{6}, trillian.Hash{7}, trillian.Hash{8})
+	smallerTree := checkpointTree(trillian.Hash{1}, trillian.Hash{2}, trillian.Hash{3})
+	currentRoot := trillian.SignedLogRoot{TreeSize: 4, TreeRevision: 5, RootHash: mt.CurrentRoot()}
+
+	for _, test := range []struct {
+		desc       string
+		checkpoint storage.SequencerCheckpoint
+	}{
+		// e.g. a sequencer stored its checkpoint but not its root
+		{desc: "later revision", checkpoint: storage.SequencerCheckpoint{TreeRevision: 6, CompactTree: mt.Proto()}},
+		{desc: "earlier revision", checkpoint: storage.SequencerCheckpoint{TreeRevision: 4, CompactTree: mt.Proto()}},
+		{desc: "wrong size", checkpoint: storage.SequencerCheckpoint{TreeRevision: 5, CompactTree: smallerTree.Proto()}},
+		{desc: "wrong hash", checkpoint: storage.SequencerCheckpoint{TreeRevision: 5, CompactTree: otherTree.Proto()}},
+		{desc: "bad range", checkpoint: storage.SequencerCheckpoint{TreeRevision: 5, CompactTree: &storage.CompactRangeProto{Begin: 1, End: 4}}},
+	} {
+		ctrl := gomock.NewController(t)
+
+		// The tree is perfect, so it's rebuilt from the root without reading any nodes
+		params := signRootParams(t, currentRoot, mt)
+		params.checkpoint = &test.checkpoint
+		c := createTestContext(ctrl, params)
+
+		if err := c.sequencer.SignRoot(context.Background()); err != nil {
+			t.Errorf("%s: Expected signing to succeed, but got err: %v", test.desc, err)
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func TestSignRootGetCheckpointFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:      true,
+		latestSignedRoot:    &testRoot16,
+		checkpointError:     errors.New("getcheckpoint"),
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "getcheckpoint")
+}
+
+func TestSequenceBatchStoresCheckpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The perfect tree of 16 leaves is on the left of the new leaf
+	checkpoint := storage.SequencerCheckpoint{TreeRevision: 6,
+		CompactTree: &storage.CompactRangeProto{End: 17, Hashes: [][]byte{testRoot16.RootHash, getLeaf42().LeafHash}}}
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeCheckpoint: &checkpoint, storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSequenceBatchStoreCheckpointFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The root isn't stored and nothing is committed
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeCheckpointError: errors.New("storecheckpoint"), skipStoreSignedRoot: true, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "storecheckpoint")
+}
+
+func TestSequenceBatchReportsIntegrationDelays(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaf := getLeaf42()
+	leaf.QueueTimestampNanos = fakeTimeForTest.Add(-5 * time.Second).UnixNano()
+	updatedLeaf := testLeaf16
+	updatedLeaf.QueueTimestampNanos = leaf.QueueTimestampNanos
+	leaves := []trillian.LogLeaf{leaf}
+	updatedLeaves := []trillian.LogLeaf{updatedLeaf}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	var delays []time.Duration
+	c.sequencer.SetIntegrationDelayFunc(func(delay time.Duration) {
+		delays = append(delays, delay)
+	})
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+
+	if got, want := delays, []time.Duration{5 * time.Second}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Reported integration delays %v, want %v", got, want)
+	}
+}
+
+func TestSequenceBatchTakesInOverdueLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, shouldCommit: true, skipDequeue: true,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x63, 0x1, 0xff, 0x6c, 0xbd, 0x85, 0x9b, 0x1, 0x54, 0x1e, 0xc2, 0xd8, 0xb5, 0x14, 0x13, 0x49, 0xd9, 0x6e, 0x75, 0x7e, 0x6d, 0x2f, 0x85, 0x8f, 0xf3, 0x10, 0xb, 0x87, 0x1b, 0xe6, 0x15, 0xfb},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetMaxMergeDelay(time.Hour)
+
+	// An overdue leaf is dequeued ahead of the batch, which it fills, and none are left when
+	// the root is signed
+	now := fakeTimeForTest.UnixNano()
+	gomock.InOrder(
+		c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), now).Return(fakeTimeForTest.Add(-2*time.Hour).UnixNano(), nil),
+		c.mockTx.EXPECT().DequeueOverdueLeaves(gomock.Any(), maxMergeDelayBatches, fakeTimeForTest.Add(-time.Hour).UnixNano(), now).Return(leaves, nil),
+		c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), now).Return(int64(0), nil),
+		c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), now).Return(int64(0), nil),
+	)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+	if got, want := leafCount, 1; got != want {
+		t.Fatalf("Sequenced %d leaf, expected %d", got, want)
+	}
+}
+
+func TestSequenceBatchRefusesToExceedMaxMergeDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Neither the signer nor a commit are expected, the root mustn't be signed or stored
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, shouldRollback: true, skipDequeue: true,
+		latestSignedRoot: &testRoot16,
+		updatedLeaves:    &updatedLeaves, merkleNodesSet: &updatedNodes,
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetMaxMergeDelay(time.Hour)
+
+	// An overdue leaf is still queued once nothing more can be dequeued
+	now := fakeTimeForTest.UnixNano()
+	gomock.InOrder(
+		c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), now).Return(fakeTimeForTest.Add(-2*time.Hour).UnixNano(), nil),
+		c.mockTx.EXPECT().DequeueOverdueLeaves(gomock.Any(), maxMergeDelayBatches, fakeTimeForTest.Add(-time.Hour).UnixNano(), now).Return(nil, nil),
+		c.mockTx.EXPECT().DequeueLeaves(gomock.Any(), 1, now).Return(leaves, nil),
+		c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), now).Return(fakeTimeForTest.Add(-2*time.Hour).UnixNano(), nil),
+	)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1, rootNeverExpiresFunc)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	if err != ErrMergeDelayExceeded {
+		t.Fatalf("SequenceBatch()=%v, want %v", err, ErrMergeDelayExceeded)
+	}
+}
+
+func TestSequenceBatchCatchesUpWithOverdueBacklog(t *testing.T) {
+	ctx := context.Background()
+	p, err := storage.NewProvider(memory.ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("backlog"), TreeID: 1})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	// Many more leaves are queued than fit in a batch, or in the overdue batches dequeued at
+	// a time
+	const limit, backlog = 2, 100
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	leaves := make([]trillian.LogLeaf, 0, backlog)
+	for l := 0; l < backlog; l++ {
+		value := []byte(fmt.Sprintf("Leaf %d", l))
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(value), LeafValue: value},
+			SignedEntryTimestamp: trillian.SignedEntryTimestamp{Signature: &trillian.DigitallySigned{Signature: []byte("unsigned")}}})
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit()=%v", err)
+	}
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	// The sequencer runs long after the leaves were queued, so they're all overdue and are
+	// integrated by the one run
+	sequencer := NewSequencer(hasher, util.FakeTimeSource{FakeTime: time.Now().Add(2 * time.Hour)}, s, km)
+	sequencer.SetMaxMergeDelay(time.Hour)
+
+	n, err := sequencer.SequenceBatch(ctx, limit, rootNeverExpiresFunc)
+	if err != nil {
+		t.Fatalf("SequenceBatch()=%v", err)
+	}
+	if got, want := n, backlog; got != want {
+		t.Errorf("SequenceBatch() integrated %d leaves, want %d", got, want)
+	}
+
+	tx, err = s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin()=%v", err)
+	}
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot()=%v", err)
+	}
+	if got, want := root.TreeSize, int64(backlog); got != want {
+		t.Errorf("Got tree size %d after catching up, want %d", got, want)
+	}
+}
+
+func TestSignRootRefusesToExceedMaxMergeDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:      true,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetMaxMergeDelay(time.Hour)
+
+	c.mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), fakeTimeForTest.UnixNano()).Return(fakeTimeForTest.Add(-2*time.Hour).UnixNano(), nil)
+
+	if err := c.sequencer.SignRoot(context.Background()); err != ErrMergeDelayExceeded {
+		t.Fatalf("SignRoot()=%v, want %v", err, ErrMergeDelayExceeded)
+	}
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"github.com/google/trillian/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the gRPC metadata key that clients send request IDs in, and that servers
+// return the request IDs of RPCs in as a response header
+const RequestIDKey = "x-request-id"
+
+// maxRequestIDLength is the longest request ID accepted from a client
+const maxRequestIDLength = 64
+
+// ValidRequestID reports whether a request ID from a client can be logged as it is. IDs
+// are limited to letters, digits, '-', '_' and '.' so they can't break up log lines.
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// incomingRequestID returns the request ID the client of an RPC sent, or a new one if it
+// didn't send a valid one
+func incomingRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md[RequestIDKey]; len(ids) > 0 && ValidRequestID(ids[0]) {
+			return ids[0]
+		}
+	}
+
+	return NewRequestID()
+}
+
+// serverContext tags the context of an RPC with its request ID and method, and with the
+// tree it's for if req is for a single tree
+func serverContext(ctx context.Context, method string, req interface{}) context.Context {
+	fields := Fields{RequestID: incomingRequestID(ctx), Method: method}
+
+	if req != nil {
+		if treeID, _, ok := auth.RequiredPermission(req); ok {
+			fields.TreeID = treeID
+		}
+	}
+
+	return NewContext(ctx, fields)
+}
+
+// UnaryServerInterceptor tags the context of each unary RPC with its request ID, method and
+// tree, so that whatever is logged while handling it is tagged too. The request ID is the
+// one the client sent, if any, and is returned to the client in the response header.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = serverContext(ctx, info.FullMethod, req)
+
+		// This only fails if the RPC isn't being handled by a gRPC server, e.g. in tests
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, FromContext(ctx).RequestID))
+
+		return handler(ctx, req)
+	}
+}
+
+// taggedStream gives a stream handler a context tagged with its RPC's fields
+type taggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s taggedStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor does the same as UnaryServerInterceptor for streaming RPCs. Their
+// tree isn't known until the first request has been received, so handlers should tag their
+// context with it themselves.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := serverContext(stream.Context(), info.FullMethod, nil)
+		stream.SetHeader(metadata.Pairs(RequestIDKey, FromContext(ctx).RequestID))
+
+		return handler(srv, taggedStream{stream, ctx})
+	}
+}
+
+// OutgoingContext returns a context that sends the request ID held by ctx, if it has one,
+// to the servers of the RPCs made with it, so that their log lines for the request are
+// tagged with the same ID
+func OutgoingContext(ctx context.Context) context.Context {
+	id := FromContext(ctx).RequestID
+
+	if len(id) == 0 {
+		return ctx
+	}
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	md[RequestIDKey] = []string{id}
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeStream is a server stream that only has a context
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s fakeStream) SetHeader(md metadata.MD) error {
+	return nil
+}
+
+func TestValidRequestID(t *testing.T) {
+	for _, test := range []struct {
+		id   string
+		want bool
+	}{
+		{"5f2a9c1e0b7d3864", true},
+		{"frontend-1.req_42", true},
+		{"", false},
+		{"two words", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	} {
+		if got := ValidRequestID(test.id); got != test.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", test.id, got, test.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+	req := &trillian.QueueLeavesRequest{LogId: 123}
+
+	for _, test := range []struct {
+		ctx    context.Context
+		wantID string
+	}{
+		{metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "frontend-1")), "frontend-1"},
+		{metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "bad id")), ""},
+		{context.Background(), ""},
+	} {
+		var got Fields
+		UnaryServerInterceptor()(test.ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = FromContext(ctx)
+			return nil, nil
+		})
+
+		if got.Method != info.FullMethod || got.TreeID != 123 {
+			t.Errorf("Handler got fields %v, want method %s and tree 123", got, info.FullMethod)
+		}
+		if len(test.wantID) > 0 && got.RequestID != test.wantID {
+			t.Errorf("Handler got request ID %q, want the client's %q", got.RequestID, test.wantID)
+		}
+		if len(test.wantID) == 0 && !ValidRequestID(got.RequestID) {
+			t.Errorf("Handler got request ID %q, want a new one", got.RequestID)
+		}
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/trillian.TrillianLog/StreamLeaves"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "frontend-1"))
+
+	var got Fields
+	StreamServerInterceptor()(nil, fakeStream{ctx: ctx}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		got = FromContext(stream.Context())
+		return nil
+	})
+
+	if want := (Fields{RequestID: "frontend-1", Method: info.FullMethod}); got != want {
+		t.Errorf("Handler got fields %v, want %v", got, want)
+	}
+}
+
+func TestOutgoingContext(t *testing.T) {
+	if ctx := OutgoingContext(context.Background()); ctx != context.Background() {
+		t.Error("OutgoingContext() changed a context without a request ID")
+	}
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("other", "value"))
+	ctx = OutgoingContext(NewContext(ctx, Fields{RequestID: "abc"}))
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	if got := md[RequestIDKey]; len(got) != 1 || got[0] != "abc" {
+		t.Errorf("Outgoing request IDs = %v, want [abc]", got)
+	}
+	if got := md["other"]; len(got) != 1 {
+		t.Errorf("Outgoing metadata %v lost the metadata already in the context", md)
+	}
+}
@@ -0,0 +1,132 @@
+// Package logging tags log lines with what they're about, so that the lines logged while
+// handling one request can be found together and followed between servers. The request ID,
+// RPC method and tree ID of a request are kept in its context and added to every line
+// logged with it, e.g. "request_id=5f2a9c1e0b7d3864 method=/trillian.TrillianLog/QueueLeaves
+// tree_id=123: Failed to queue leaves". Lines are written through glog as before.
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Fields identify what a log line is about, those that aren't set are left out
+type Fields struct {
+	// RequestID is shared by everything done for a request, including by other servers
+	RequestID string
+	// Method is the full name of the RPC being handled
+	Method string
+	// TreeID is the tree the work is for
+	TreeID int64
+}
+
+// String formats the fields that are set as space separated name=value pairs
+func (f Fields) String() string {
+	var parts []string
+
+	if len(f.RequestID) > 0 {
+		parts = append(parts, "request_id="+f.RequestID)
+	}
+	if len(f.Method) > 0 {
+		parts = append(parts, "method="+f.Method)
+	}
+	if f.TreeID != 0 {
+		parts = append(parts, fmt.Sprintf("tree_id=%d", f.TreeID))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+type fieldsKey struct{}
+
+// NewContext returns a context holding the fields of ctx, replaced by those that are set in
+// f
+func NewContext(ctx context.Context, f Fields) context.Context {
+	fields := FromContext(ctx)
+
+	if len(f.RequestID) > 0 {
+		fields.RequestID = f.RequestID
+	}
+	if len(f.Method) > 0 {
+		fields.Method = f.Method
+	}
+	if f.TreeID != 0 {
+		fields.TreeID = f.TreeID
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithTreeID returns a context whose log lines are tagged with treeID
+func WithTreeID(ctx context.Context, treeID int64) context.Context {
+	return NewContext(ctx, Fields{TreeID: treeID})
+}
+
+// FromContext returns the fields held by ctx, none are set if it doesn't have any
+func FromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return Fields{}
+	}
+
+	f, _ := ctx.Value(fieldsKey{}).(Fields)
+	return f
+}
+
+// NewRequestID returns a random ID for a request that didn't come with one
+func NewRequestID() string {
+	id := make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		glog.Warningf("Failed to generate a request ID: %v", err)
+		return "unknown"
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// format prefixes a log line with the fields held by ctx
+func format(ctx context.Context, format string, args []interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+
+	if fields := FromContext(ctx).String(); len(fields) > 0 {
+		return fields + ": " + msg
+	}
+
+	return msg
+}
+
+// Infof logs to the INFO log, tagged with the fields held by ctx
+func Infof(ctx context.Context, f string, args ...interface{}) {
+	glog.InfoDepth(1, format(ctx, f, args))
+}
+
+// Warningf logs to the WARNING and INFO logs, tagged with the fields held by ctx
+func Warningf(ctx context.Context, f string, args ...interface{}) {
+	glog.WarningDepth(1, format(ctx, f, args))
+}
+
+// Errorf logs to the ERROR, WARNING and INFO logs, tagged with the fields held by ctx
+func Errorf(ctx context.Context, f string, args ...interface{}) {
+	glog.ErrorDepth(1, format(ctx, f, args))
+}
+
+// Verbose logs to the INFO log only if the verbosity it was made with is enabled, as
+// glog.Verbose does
+type Verbose bool
+
+// V returns a Verbose that logs if glog's verbosity is at least level
+func V(level glog.Level) Verbose {
+	return Verbose(glog.V(level))
+}
+
+// Infof logs to the INFO log if v is enabled, tagged with the fields held by ctx
+func (v Verbose) Infof(ctx context.Context, f string, args ...interface{}) {
+	if v {
+		glog.InfoDepth(1, format(ctx, f, args))
+	}
+}
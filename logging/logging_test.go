@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestFieldsString(t *testing.T) {
+	for _, test := range []struct {
+		fields Fields
+		want   string
+	}{
+		{Fields{}, ""},
+		{Fields{RequestID: "abc"}, "request_id=abc"},
+		{Fields{TreeID: 5}, "tree_id=5"},
+		{Fields{RequestID: "abc", Method: "/trillian.TrillianLog/QueueLeaves", TreeID: 5}, "request_id=abc method=/trillian.TrillianLog/QueueLeaves tree_id=5"},
+	} {
+		if got := test.fields.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.fields, got, test.want)
+		}
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	ctx := NewContext(context.Background(), Fields{RequestID: "abc", Method: "/trillian.TrillianAdmin/ListTrees"})
+
+	// Fields that aren't set keep the values they had
+	ctx = WithTreeID(ctx, 7)
+
+	if got, want := FromContext(ctx), (Fields{RequestID: "abc", Method: "/trillian.TrillianAdmin/ListTrees", TreeID: 7}); got != want {
+		t.Errorf("FromContext() = %v, want %v", got, want)
+	}
+
+	ctx = WithTreeID(ctx, 8)
+
+	if got := FromContext(ctx).TreeID; got != 8 {
+		t.Errorf("FromContext().TreeID = %d, want 8", got)
+	}
+
+	if got := FromContext(context.Background()); got != (Fields{}) {
+		t.Errorf("FromContext() of a context without fields = %v, want none", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	ctx := NewContext(context.Background(), Fields{RequestID: "abc", TreeID: 5})
+
+	if got, want := format(ctx, "Failed to queue %d leaves", []interface{}{3}), "request_id=abc tree_id=5: Failed to queue 3 leaves"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+
+	if got, want := format(context.Background(), "Failed to queue %d leaves", []interface{}{3}), "Failed to queue 3 leaves"; got != want {
+		t.Errorf("format() without fields = %q, want %q", got, want)
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	id := NewRequestID()
+
+	if !ValidRequestID(id) {
+		t.Errorf("NewRequestID() = %q, which isn't a valid request ID", id)
+	}
+
+	if id == NewRequestID() {
+		t.Errorf("NewRequestID() returned %q twice", id)
+	}
+}
+
+func TestV(t *testing.T) {
+	// glog's verbosity is 0 unless the -v flag is set
+	if !V(0) {
+		t.Error("V(0) = false, want true")
+	}
+
+	if V(10) {
+		t.Error("V(10) = true, want false")
+	}
+}
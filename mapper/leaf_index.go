@@ -0,0 +1,31 @@
+package mapper
+
+import (
+	"encoding/binary"
+
+	"github.com/google/trillian"
+)
+
+// LeafIndexMapper maps the leaf hash of each log entry to its index in the log, encoded as
+// 8 bytes big endian. An entry that's in the log more than once keeps its first index. The
+// map lets a client find an entry's index, and so fetch its inclusion proof, from its hash
+// with a proof that the answer is the one everyone else gets.
+type LeafIndexMapper struct{}
+
+// Keys implements EntryMapper
+func (LeafIndexMapper) Keys(leaf *trillian.LeafProto) ([][]byte, error) {
+	return [][]byte{leaf.LeafHash}, nil
+}
+
+// Apply implements EntryMapper
+func (LeafIndexMapper) Apply(leaf *trillian.LeafProto, values map[string][]byte) error {
+	if values[string(leaf.LeafHash)] != nil {
+		return nil
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(leaf.LeafIndex))
+	values[string(leaf.LeafHash)] = value
+
+	return nil
+}
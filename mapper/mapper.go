@@ -0,0 +1,340 @@
+// Package mapper builds a Trillian map from the entries of a Trillian log. Each map root
+// it publishes records in its metadata how much of the log it covers, so anyone holding
+// the log can check the map by replaying the entries up to that point.
+package mapper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// EntryMapper turns log entries into changes to map values. It must be deterministic, the
+// same entries applied to the same values must always give the same result, or the map
+// can't be checked against the log.
+type EntryMapper interface {
+	// Keys returns the map keys whose values leaf might change
+	Keys(leaf *trillian.LeafProto) ([][]byte, error)
+	// Apply makes the changes for leaf to values, which holds the value of every key
+	// returned by Keys for leaf, nil if the key has no value yet. A key can't be removed
+	// from the map so setting a value to nil leaves it unchanged. An error stops the map
+	// at leaf, entries that can't be used should be skipped rather than failed.
+	Apply(leaf *trillian.LeafProto, values map[string][]byte) error
+}
+
+// LogMapperConfig controls which log a LogMapper reads and which map it writes
+type LogMapperConfig struct {
+	// LogID is the tree id of the log that entries are read from
+	LogID int64
+	// MapID is the tree id of the map that is written to
+	MapID int64
+	// BatchSize is the most log entries applied in one map revision
+	BatchSize int
+	// RPCTimeout is the deadline for each request to the log and the map, zero means no
+	// deadline
+	RPCTimeout time.Duration
+}
+
+// LogMapper applies the entries of a log to a map in order. Each batch of entries is
+// written as one map revision whose root metadata gives the id of the log and the index of
+// the last entry applied. The position is read back from the latest map root, so a LogMapper
+// that is restarted carries on where it stopped and doesn't need any other state.
+type LogMapper struct {
+	logClient trillian.TrillianLogClient
+	mapClient trillian.TrillianMapClient
+	mapper    EntryMapper
+	config    LogMapperConfig
+}
+
+// NewLogMapper creates a LogMapper that applies entries read with logClient to the map
+// using mapClient
+func NewLogMapper(logClient trillian.TrillianLogClient, mapClient trillian.TrillianMapClient, mapper EntryMapper, config LogMapperConfig) (*LogMapper, error) {
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be > 0 but was %d", config.BatchSize)
+	}
+
+	if config.RPCTimeout < 0 {
+		return nil, fmt.Errorf("rpc timeout must be >= 0 but was %v", config.RPCTimeout)
+	}
+
+	return &LogMapper{logClient: logClient, mapClient: mapClient, mapper: mapper, config: config}, nil
+}
+
+// LogIDBytes returns the form of a log id that's stored as the source log id in map root
+// metadata
+func LogIDBytes(logID int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(logID))
+	return b
+}
+
+// MapRootLogSize returns the number of entries of log logID that have been applied to the
+// map at root. It's an error if the map was built from a different log.
+func MapRootLogSize(root *trillian.SignedMapRoot, logID int64) (int64, error) {
+	if root == nil || root.Metadata == nil || len(root.Metadata.SourceLogId) == 0 {
+		// Nothing has been applied yet
+		return 0, nil
+	}
+
+	if !bytes.Equal(root.Metadata.SourceLogId, LogIDBytes(logID)) {
+		return 0, fmt.Errorf("map root at revision %d was built from log %x, not %d", root.MapRevision, root.Metadata.SourceLogId, logID)
+	}
+
+	return root.Metadata.HighestFullyCompletedSeq + 1, nil
+}
+
+// rpcContext returns the context for a single request made on behalf of ctx
+func (m *LogMapper) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.config.RPCTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.config.RPCTimeout)
+}
+
+// RunOnce applies batches of entries to the map until it covers all of the entries in the
+// latest log root. It returns the number of entries applied.
+func (m *LogMapper) RunOnce(ctx context.Context) (int64, error) {
+	next, err := m.mapLogSize(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	treeSize, err := m.logTreeSize(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if treeSize < next {
+		return 0, fmt.Errorf("log %d has %d entries but the map already covers %d", m.config.LogID, treeSize, next)
+	}
+
+	start := next
+
+	for next < treeSize {
+		end := next + int64(m.config.BatchSize)
+
+		if end > treeSize {
+			end = treeSize
+		}
+
+		if err := m.applyBatch(ctx, next, end); err != nil {
+			return next - start, err
+		}
+
+		glog.V(1).Infof("Applied log entries %d to %d to map %d", next, end-1, m.config.MapID)
+		next = end
+	}
+
+	return next - start, nil
+}
+
+// mapLogSize returns the number of log entries covered by the latest map root
+func (m *LogMapper) mapLogSize(ctx context.Context) (int64, error) {
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := m.mapClient.GetSignedMapRoot(rpcCtx, &trillian.GetSignedMapRootRequest{MapId: m.config.MapID})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return MapRootLogSize(resp.MapRoot, m.config.LogID)
+}
+
+// logTreeSize returns the number of entries in the latest log root
+func (m *LogMapper) logTreeSize(ctx context.Context) (int64, error) {
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := m.logClient.GetLatestSignedLogRoot(rpcCtx, &trillian.GetLatestSignedLogRootRequest{LogId: m.config.LogID})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return 0, fmt.Errorf("log failed to get latest root: %v", resp.Status)
+	}
+
+	if resp.SignedLogRoot == nil {
+		return 0, nil
+	}
+
+	return resp.SignedLogRoot.TreeSize, nil
+}
+
+// applyBatch applies the log entries in [start, end) to the map as one revision
+func (m *LogMapper) applyBatch(ctx context.Context, start, end int64) error {
+	leaves, err := m.getLeaves(ctx, start, end)
+
+	if err != nil {
+		return err
+	}
+
+	var keys [][]byte
+	seen := make(map[string]bool)
+
+	for _, leaf := range leaves {
+		leafKeys, err := m.mapper.Keys(leaf)
+
+		if err != nil {
+			return fmt.Errorf("failed to get map keys for log entry %d: %v", leaf.LeafIndex, err)
+		}
+
+		for _, key := range leafKeys {
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	current, err := m.getValues(ctx, keys)
+
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string][]byte)
+
+	for k, v := range current {
+		values[k] = v
+	}
+
+	for _, leaf := range leaves {
+		if err := m.mapper.Apply(leaf, values); err != nil {
+			return fmt.Errorf("failed to apply log entry %d: %v", leaf.LeafIndex, err)
+		}
+	}
+
+	// Only write the values that changed, in key order so the request doesn't depend on map
+	// iteration order
+	setReq := &trillian.SetMapLeavesRequest{
+		MapId:      m.config.MapID,
+		MapperData: &trillian.MapperMetadata{SourceLogId: LogIDBytes(m.config.LogID), HighestFullyCompletedSeq: end - 1, HighestPartiallyCompletedSeq: end - 1},
+	}
+
+	for _, key := range keys {
+		value := values[string(key)]
+
+		if value == nil || bytes.Equal(value, current[string(key)]) {
+			continue
+		}
+
+		setReq.KeyValue = append(setReq.KeyValue, &trillian.KeyValue{Key: key, Value: &trillian.MapLeaf{LeafValue: value}})
+	}
+
+	sort.Sort(byKey(setReq.KeyValue))
+
+	// A revision is written even if nothing changed so the map root shows the entries were
+	// applied
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	_, err = m.mapClient.SetLeaves(rpcCtx, setReq)
+	return err
+}
+
+// getLeaves returns the log entries in [start, end) in order
+func (m *LogMapper) getLeaves(ctx context.Context, start, end int64) ([]*trillian.LeafProto, error) {
+	indices := make([]int64, 0, end-start)
+
+	for i := start; i < end; i++ {
+		indices = append(indices, i)
+	}
+
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := m.logClient.GetLeavesByIndex(rpcCtx, &trillian.GetLeavesByIndexRequest{LogId: m.config.LogID, LeafIndex: indices})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return nil, fmt.Errorf("log failed to get leaves: %v", resp.Status)
+	}
+
+	// Storage doesn't promise to return leaves in the order they were asked for
+	leaves := resp.Leaves
+	sort.Sort(byLeafIndex(leaves))
+
+	if int64(len(leaves)) != end-start {
+		return nil, fmt.Errorf("log %d returned %d leaves from index %d, expected %d", m.config.LogID, len(leaves), start, end-start)
+	}
+
+	for i, leaf := range leaves {
+		if leaf.LeafIndex != start+int64(i) {
+			return nil, fmt.Errorf("log %d returned leaf %d, expected %d", m.config.LogID, leaf.LeafIndex, start+int64(i))
+		}
+	}
+
+	return leaves, nil
+}
+
+// getValues returns the current map values of keys that have one
+func (m *LogMapper) getValues(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	rpcCtx, cancel := m.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := m.mapClient.GetLeaves(rpcCtx, &trillian.GetMapLeavesRequest{MapId: m.config.MapID, Key: keys, Revision: -1})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range resp.KeyValue {
+		if kv.KeyValue == nil || kv.KeyValue.Value == nil {
+			continue
+		}
+
+		values[string(kv.KeyValue.Key)] = kv.KeyValue.Value.LeafValue
+	}
+
+	return values, nil
+}
+
+type byLeafIndex []*trillian.LeafProto
+
+func (l byLeafIndex) Len() int {
+	return len(l)
+}
+
+func (l byLeafIndex) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l byLeafIndex) Less(i, j int) bool {
+	return l[i].LeafIndex < l[j].LeafIndex
+}
+
+type byKey []*trillian.KeyValue
+
+func (k byKey) Len() int {
+	return len(k)
+}
+
+func (k byKey) Swap(i, j int) {
+	k[i], k[j] = k[j], k[i]
+}
+
+func (k byKey) Less(i, j int) bool {
+	return bytes.Compare(k[i].Key, k[j].Key) < 0
+}
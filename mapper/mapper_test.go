@@ -0,0 +1,319 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var okStatus = &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+
+// fakeLogClient serves the entries of an in memory log. Only the methods used by a
+// LogMapper are implemented.
+type fakeLogClient struct {
+	trillian.TrillianLogClient
+	leaves []*trillian.LeafProto
+	// size is the tree size of the latest log root
+	size int64
+}
+
+func (c *fakeLogClient) add(data ...string) {
+	for _, d := range data {
+		c.leaves = append(c.leaves, &trillian.LeafProto{LeafIndex: int64(len(c.leaves)), LeafHash: []byte("hash " + d), LeafData: []byte(d)})
+	}
+
+	c.size = int64(len(c.leaves))
+}
+
+func (c *fakeLogClient) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	return &trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: c.size}}, nil
+}
+
+func (c *fakeLogClient) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	resp := &trillian.GetLeavesByIndexResponse{Status: okStatus}
+
+	// Return them backwards to check they're put in order
+	for i := len(req.LeafIndex) - 1; i >= 0; i-- {
+		if index := req.LeafIndex[i]; index < int64(len(c.leaves)) {
+			resp.Leaves = append(resp.Leaves, c.leaves[index])
+		}
+	}
+
+	return resp, nil
+}
+
+// fakeMapClient keeps the values and roots set in memory
+type fakeMapClient struct {
+	trillian.TrillianMapClient
+	values map[string][]byte
+	roots  []*trillian.SignedMapRoot
+	// sets records the keys written by each SetLeaves call
+	sets [][]string
+	err  error
+}
+
+func newFakeMapClient() *fakeMapClient {
+	return &fakeMapClient{values: make(map[string][]byte)}
+}
+
+func (c *fakeMapClient) GetSignedMapRoot(ctx context.Context, req *trillian.GetSignedMapRootRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
+	if len(c.roots) == 0 {
+		return &trillian.GetSignedMapRootResponse{MapRoot: &trillian.SignedMapRoot{}}, nil
+	}
+
+	return &trillian.GetSignedMapRootResponse{MapRoot: c.roots[len(c.roots)-1]}, nil
+}
+
+func (c *fakeMapClient) GetLeaves(ctx context.Context, req *trillian.GetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	resp := &trillian.GetMapLeavesResponse{}
+
+	for _, key := range req.Key {
+		if value, ok := c.values[string(key)]; ok {
+			resp.KeyValue = append(resp.KeyValue, &trillian.KeyValueInclusion{KeyValue: &trillian.KeyValue{Key: key, Value: &trillian.MapLeaf{LeafValue: value}}})
+		}
+	}
+
+	return resp, nil
+}
+
+func (c *fakeMapClient) SetLeaves(ctx context.Context, req *trillian.SetMapLeavesRequest, opts ...grpc.CallOption) (*trillian.SetMapLeavesResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var keys []string
+
+	for _, kv := range req.KeyValue {
+		c.values[string(kv.Key)] = kv.Value.LeafValue
+		keys = append(keys, string(kv.Key))
+	}
+
+	root := &trillian.SignedMapRoot{MapRevision: int64(len(c.roots) + 1), Metadata: req.MapperData}
+	c.roots = append(c.roots, root)
+	c.sets = append(c.sets, keys)
+
+	return &trillian.SetMapLeavesResponse{MapRoot: root}, nil
+}
+
+// countMapper counts how many times each entry's data has been seen
+type countMapper struct{}
+
+func (countMapper) Keys(leaf *trillian.LeafProto) ([][]byte, error) {
+	if string(leaf.LeafData) == "ignored" {
+		return nil, nil
+	}
+
+	return [][]byte{leaf.LeafData}, nil
+}
+
+func (countMapper) Apply(leaf *trillian.LeafProto, values map[string][]byte) error {
+	if string(leaf.LeafData) == "ignored" {
+		return nil
+	}
+
+	count := 0
+	if v := values[string(leaf.LeafData)]; v != nil {
+		fmt.Sscan(string(v), &count)
+	}
+
+	values[string(leaf.LeafData)] = []byte(fmt.Sprint(count + 1))
+	return nil
+}
+
+func newTestLogMapper(t *testing.T, logClient *fakeLogClient, mapClient *fakeMapClient, batchSize int) *LogMapper {
+	m, err := NewLogMapper(logClient, mapClient, countMapper{}, LogMapperConfig{LogID: 6962, MapID: 1, BatchSize: batchSize})
+
+	if err != nil {
+		t.Fatalf("Failed to create log mapper: %v", err)
+	}
+
+	return m
+}
+
+func checkValues(t *testing.T, mapClient *fakeMapClient, want map[string]string) {
+	if got, want := len(mapClient.values), len(want); got != want {
+		t.Fatalf("Got %d map values, expected %d", got, want)
+	}
+
+	for k, v := range want {
+		if got := string(mapClient.values[k]); got != v {
+			t.Errorf("Got value %q for key %s, expected %q", got, k, v)
+		}
+	}
+}
+
+func TestNewLogMapperInvalidConfig(t *testing.T) {
+	for _, config := range []LogMapperConfig{{BatchSize: 0}, {BatchSize: 1, RPCTimeout: -1}} {
+		if _, err := NewLogMapper(&fakeLogClient{}, newFakeMapClient(), countMapper{}, config); err == nil {
+			t.Errorf("Incorrectly created log mapper with config %+v", config)
+		}
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a", "b", "a", "ignored", "c")
+	mapClient := newFakeMapClient()
+	m := newTestLogMapper(t, logClient, mapClient, 2)
+
+	applied, err := m.RunOnce(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to run log mapper: %v", err)
+	}
+	if got, want := applied, int64(5); got != want {
+		t.Fatalf("Applied %d entries, expected %d", got, want)
+	}
+
+	checkValues(t, mapClient, map[string]string{"a": "2", "b": "1", "c": "1"})
+
+	// One revision per batch, the one with only the ignored entry and a repeated key
+	// changes nothing else
+	if got, want := len(mapClient.roots), 3; got != want {
+		t.Fatalf("Got %d map revisions, expected %d", got, want)
+	}
+
+	if got, want := fmt.Sprint(mapClient.sets), "[[a b] [a] [c]]"; got != want {
+		t.Errorf("Got keys set %s, expected %s", got, want)
+	}
+
+	root := mapClient.roots[len(mapClient.roots)-1]
+
+	if size, err := MapRootLogSize(root, 6962); err != nil || size != 5 {
+		t.Errorf("Got map root log size %d (%v), expected 5", size, err)
+	}
+
+	// Nothing new in the log
+	if applied, err := m.RunOnce(context.Background()); err != nil || applied != 0 {
+		t.Fatalf("Got %d entries applied (%v) with nothing new, expected none", applied, err)
+	}
+
+	// A new mapper carries on from the position in the map root
+	logClient.add("b")
+	m = newTestLogMapper(t, logClient, mapClient, 10)
+
+	if applied, err := m.RunOnce(context.Background()); err != nil || applied != 1 {
+		t.Fatalf("Got %d entries applied (%v) after log grew, expected 1", applied, err)
+	}
+
+	checkValues(t, mapClient, map[string]string{"a": "2", "b": "2", "c": "1"})
+}
+
+func TestRunOnceWrongLog(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a")
+	mapClient := newFakeMapClient()
+	mapClient.roots = append(mapClient.roots, &trillian.SignedMapRoot{Metadata: &trillian.MapperMetadata{SourceLogId: LogIDBytes(1234)}})
+
+	if _, err := newTestLogMapper(t, logClient, mapClient, 10).RunOnce(context.Background()); err == nil {
+		t.Fatal("Incorrectly applied entries to a map built from another log")
+	}
+}
+
+func TestRunOnceLogBehindMap(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a")
+	mapClient := newFakeMapClient()
+	mapClient.roots = append(mapClient.roots, &trillian.SignedMapRoot{Metadata: &trillian.MapperMetadata{SourceLogId: LogIDBytes(6962), HighestFullyCompletedSeq: 4}})
+
+	if _, err := newTestLogMapper(t, logClient, mapClient, 10).RunOnce(context.Background()); err == nil {
+		t.Fatal("Incorrectly ran with the log smaller than the map")
+	}
+}
+
+func TestRunOnceMissingLeaves(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a", "b")
+	// The root covers an entry the log can't serve
+	logClient.size = 3
+	mapClient := newFakeMapClient()
+
+	if _, err := newTestLogMapper(t, logClient, mapClient, 10).RunOnce(context.Background()); err == nil {
+		t.Fatal("Incorrectly applied entries with some missing")
+	}
+
+	if len(mapClient.roots) != 0 {
+		t.Errorf("Got %d map revisions after failure, expected none", len(mapClient.roots))
+	}
+}
+
+func TestRunOnceSetLeavesFails(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a", "b", "c")
+	mapClient := newFakeMapClient()
+	m := newTestLogMapper(t, logClient, mapClient, 1)
+	mapClient.err = errors.New("map unavailable")
+
+	if applied, err := m.RunOnce(context.Background()); err == nil || applied != 0 {
+		t.Fatalf("Got %d entries applied (%v) with the map failing, expected an error", applied, err)
+	}
+
+	// Nothing was recorded so the next run starts again from the beginning
+	mapClient.err = nil
+
+	if applied, err := m.RunOnce(context.Background()); err != nil || applied != 3 {
+		t.Fatalf("Got %d entries applied (%v) after map recovered, expected 3", applied, err)
+	}
+
+	checkValues(t, mapClient, map[string]string{"a": "1", "b": "1", "c": "1"})
+}
+
+func TestMapRootLogSize(t *testing.T) {
+	for _, test := range []struct {
+		root *trillian.SignedMapRoot
+		size int64
+		err  bool
+	}{
+		{nil, 0, false},
+		{&trillian.SignedMapRoot{}, 0, false},
+		{&trillian.SignedMapRoot{Metadata: &trillian.MapperMetadata{SourceLogId: LogIDBytes(6962), HighestFullyCompletedSeq: 9}}, 10, false},
+		{&trillian.SignedMapRoot{Metadata: &trillian.MapperMetadata{SourceLogId: LogIDBytes(1), HighestFullyCompletedSeq: 9}}, 0, true},
+	} {
+		size, err := MapRootLogSize(test.root, 6962)
+
+		if got, want := err != nil, test.err; got != want {
+			t.Errorf("Got error %v for root %v, expected error: %v", err, test.root, want)
+			continue
+		}
+		if got, want := size, test.size; got != want {
+			t.Errorf("Got size %d for root %v, expected %d", got, test.root, want)
+		}
+	}
+}
+
+func TestLeafIndexMapper(t *testing.T) {
+	logClient := &fakeLogClient{}
+	logClient.add("a", "b", "a")
+	mapClient := newFakeMapClient()
+	m, err := NewLogMapper(logClient, mapClient, LeafIndexMapper{}, LogMapperConfig{LogID: 6962, MapID: 1, BatchSize: 2})
+
+	if err != nil {
+		t.Fatalf("Failed to create log mapper: %v", err)
+	}
+
+	if _, err := m.RunOnce(context.Background()); err != nil {
+		t.Fatalf("Failed to run log mapper: %v", err)
+	}
+
+	for key, want := range map[string]uint64{"hash a": 0, "hash b": 1} {
+		value := mapClient.values[key]
+
+		if len(value) != 8 || binary.BigEndian.Uint64(value) != want {
+			t.Errorf("Got value %x for %s, expected index %d", value, key, want)
+		}
+	}
+
+	if got, want := len(mapClient.values), 2; got != want {
+		t.Errorf("Got %d map values, expected %d", got, want)
+	}
+
+	if !bytes.Equal(mapClient.roots[len(mapClient.roots)-1].Metadata.SourceLogId, LogIDBytes(6962)) {
+		t.Error("Map root doesn't record the source log")
+	}
+}
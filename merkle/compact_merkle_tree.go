@@ -8,6 +8,7 @@ import (
 
 	log "github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
 )
 
 type RootHashMismatchError struct {
@@ -91,6 +92,28 @@ func NewCompactMerkleTreeWithState(hasher TreeHasher, size int64, f GetNodeFunc,
 	return &r, nil
 }
 
+// NewCompactMerkleTreeFromProto creates a CompactMerkleTree from the state saved by Proto,
+// so a tree can be resumed without fetching nodes from storage. The range in |p| must start
+// at leaf 0. Callers should check CurrentRoot against a root they trust.
+func NewCompactMerkleTreeFromProto(hasher TreeHasher, p *storage.CompactRangeProto) (*CompactMerkleTree, error) {
+	if p.Begin != 0 {
+		return nil, fmt.Errorf("compact merkle tree state must start at leaf 0, not %d", p.Begin)
+	}
+
+	r, err := NewCompactRangeFromProto(hasher, p)
+	if err != nil {
+		log.Warningf("Failed to load compact merkle tree state: %v", err)
+		return nil, err
+	}
+
+	t := NewCompactMerkleTree(hasher)
+	if err := t.AppendRange(r, func(int, int64, trillian.Hash) {}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
 // NewCompactMerkleTree creates a new CompactMerkleTree with size zero. This always succeeds.
 func NewCompactMerkleTree(hasher TreeHasher) *CompactMerkleTree {
 	emptyHash := hasher.Digest([]byte{})
@@ -162,35 +185,43 @@ func (c *CompactMerkleTree) AddLeaf(data []byte, f setNodeFunc) (int64, trillian
 // AddLeafHash adds the specified |leafHash| to the tree.
 // |f| is a callback which will be called multiple times with the full MerkleTree coordinates of nodes whose hash should be updated.
 func (c *CompactMerkleTree) AddLeafHash(leafHash trillian.Hash, f setNodeFunc) (assignedSeq int64) {
+	assignedSeq = c.size
+	c.addSubtreeHash(0, leafHash, f)
+	return
+}
+
+// addSubtreeHash adds the root |hash| of a perfect subtree of |height| to the tree, the
+// size of the tree must be a multiple of the subtree size. A leaf is a subtree of height 0.
+// |f| is called as for AddLeafHash.
+func (c *CompactMerkleTree) addSubtreeHash(height int, hash trillian.Hash, f setNodeFunc) {
 	defer func() {
-		c.size++
+		c.size += int64(1) << uint(height)
 		// TODO(al): do this lazily
 		c.recalculateRoot(f)
 	}()
 
-	assignedSeq = c.size
-	index := assignedSeq
+	index := c.size >> uint(height)
 
-	f(0, index, leafHash)
+	f(height, index, hash)
 
 	if c.size == 0 {
 		// new tree
-		c.nodes = append(c.nodes, leafHash)
+		c.nodes = make([]trillian.Hash, height+1)
+		c.nodes[height] = hash
 		return
 	}
 
-	// Initialize our running hash value to the leaf hash
-	hash := leafHash
-	bit := 0
+	// Our running hash value starts as the subtree hash
+	bit := height
 	// Iterate over the bits in our tree size
-	for t := c.size; t > 0; t >>= 1 {
+	for t := c.size >> uint(height); t > 0; t >>= 1 {
 		index >>= 1
 		if t&1 == 0 {
 			// Just store the running hash here; we're done.
 			c.nodes[bit] = hash
-			// Don't re-write the leaf hash node (we've done it above already)
-			if bit > 0 {
-				// Store the leaf hash node
+			// Don't re-write the subtree hash node (we've done it above already)
+			if bit > height {
+				// Store the node
 				f(bit, index, hash)
 			}
 			return
@@ -217,7 +248,21 @@ func (c *CompactMerkleTree) AddLeafHash(leafHash trillian.Hash, f setNodeFunc) (
 	// We should never get here, because that'd mean we had a running hash which
 	// we've not stored somewhere.
 	log.Fatal("AddLeaf failed.")
-	return
+}
+
+// AppendRange adds the leaves covered by |r| to the tree, which must start at the current
+// size of the tree. |f| is called as for AddLeafHash, for the nodes at and above the roots
+// of the subtrees that make up |r|.
+func (c *CompactMerkleTree) AppendRange(r *CompactRange, f setNodeFunc) error {
+	if r.Begin() != c.size {
+		return fmt.Errorf("can't append compact range [%d, %d) to tree of size %d", r.Begin(), r.End(), c.size)
+	}
+
+	for _, n := range r.nodes {
+		c.addSubtreeHash(int(n.height), n.hash, f)
+	}
+
+	return nil
 }
 
 // Size returns the current size of the tree, that is, the number of leaves ever added to the tree.
@@ -232,6 +277,21 @@ func (c CompactMerkleTree) Hashes() []trillian.Hash {
 	return n
 }
 
+// Proto returns the state of the tree as a compact range starting at leaf 0. The tree can be
+// recreated from it with NewCompactMerkleTreeFromProto.
+func (c CompactMerkleTree) Proto() *storage.CompactRangeProto {
+	p := &storage.CompactRangeProto{Begin: 0, End: c.size}
+
+	// The largest subtree is on the left
+	for bit := len(c.nodes) - 1; bit >= 0; bit-- {
+		if c.size&(int64(1)<<uint(bit)) != 0 {
+			p.Hashes = append(p.Hashes, append(trillian.Hash{}, c.nodes[bit]...))
+		}
+	}
+
+	return p
+}
+
 // Depth returns the number of levels in the tree.
 func (c CompactMerkleTree) Depth() int {
 	if c.size == 0 {
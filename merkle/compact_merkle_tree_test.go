@@ -187,3 +187,111 @@ func TestCompactVsFullTree(t *testing.T) {
 
 	}
 }
+
+func TestCompactMerkleTreeProtoRoundTrip(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	tree := NewCompactMerkleTree(hasher)
+
+	for i := 0; i < 100; i++ {
+		loaded, err := NewCompactMerkleTreeFromProto(hasher, tree.Proto())
+		if err != nil {
+			t.Fatalf("size %d: failed to load tree state: %v", tree.Size(), err)
+		}
+
+		if got, want := loaded.Size(), tree.Size(); got != want {
+			t.Fatalf("Got size %d after loading tree state, expected %d", got, want)
+		}
+		if got, want := loaded.CurrentRoot(), tree.CurrentRoot(); !bytes.Equal(got, want) {
+			t.Fatalf("size %d: got root %v after loading tree state, expected %v", tree.Size(), got, want)
+		}
+
+		leaf := []byte(fmt.Sprintf("Leaf %d", i))
+		tree.AddLeaf(leaf, func(int, int64, trillian.Hash) {})
+		loaded.AddLeaf(leaf, func(int, int64, trillian.Hash) {})
+
+		if got, want := loaded.CurrentRoot(), tree.CurrentRoot(); !bytes.Equal(got, want) {
+			t.Fatalf("size %d: got root %v after adding to loaded tree, expected %v", tree.Size(), got, want)
+		}
+	}
+}
+
+func TestNewCompactMerkleTreeFromProtoRejectsRangeNotAtStart(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	p := &storage.CompactRangeProto{Begin: 1, End: 2, Hashes: [][]byte{hasher.HashLeaf([]byte("Leaf 1"))}}
+
+	if _, err := NewCompactMerkleTreeFromProto(hasher, p); err == nil {
+		t.Error("Loaded tree state that doesn't start at leaf 0")
+	}
+}
+
+func TestCompactMerkleTreeAppendRange(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for size := int64(0); size <= 20; size++ {
+		for end := size; end <= 40; end++ {
+			want := NewCompactMerkleTree(hasher)
+			wantNodes := make(map[string]trillian.Hash)
+			tree := NewCompactMerkleTree(hasher)
+			r := NewCompactRange(hasher, size)
+
+			for i := int64(0); i < end; i++ {
+				leaf := []byte(fmt.Sprintf("Leaf %d", i))
+				_, leafHash := want.AddLeaf(leaf, func(depth int, index int64, hash trillian.Hash) {
+					k, err := nodeKey(depth, index)
+					if err != nil {
+						t.Fatalf("failed to create nodeID: %v", err)
+					}
+					wantNodes[k] = hash
+				})
+
+				if i < size {
+					tree.AddLeaf(leaf, func(int, int64, trillian.Hash) {})
+				} else {
+					r.AppendLeafHash(leafHash)
+				}
+			}
+
+			// Nodes on the right edge of the tree are written again as it grows, so only the
+			// last hash written for each node is checked
+			gotNodes := make(map[string]trillian.Hash)
+			err := tree.AppendRange(r, func(depth int, index int64, hash trillian.Hash) {
+				k, err := nodeKey(depth, index)
+				if err != nil {
+					t.Fatalf("failed to create nodeID: %v", err)
+				}
+				gotNodes[k] = hash
+			})
+			if err != nil {
+				t.Fatalf("Failed to append [%d, %d) to tree: %v", size, end, err)
+			}
+			for k, got := range gotNodes {
+				if want := wantNodes[k]; !bytes.Equal(got, want) {
+					t.Errorf("appending [%d, %d): got node %v with hash %v, expected %v", size, end, k, got, want)
+				}
+			}
+
+			if got, want := tree.Size(), want.Size(); got != want {
+				t.Errorf("Got size %d after appending [%d, %d), expected %d", got, size, end, want)
+			}
+			if got, want := tree.CurrentRoot(), want.CurrentRoot(); !bytes.Equal(got, want) {
+				t.Errorf("Got root %v after appending [%d, %d), expected %v", got, size, end, want)
+			}
+			if got, want := tree.Proto(), want.Proto(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Got state %v after appending [%d, %d), expected %v", got, size, end, want)
+			}
+		}
+	}
+}
+
+func TestCompactMerkleTreeAppendRangeRejectsGaps(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	tree := NewCompactMerkleTree(hasher)
+	tree.AddLeaf([]byte("Leaf 0"), func(int, int64, trillian.Hash) {})
+
+	r := NewCompactRange(hasher, 2)
+	r.AppendLeafHash(hasher.HashLeaf([]byte("Leaf 2")))
+
+	if err := tree.AppendRange(r, func(int, int64, trillian.Hash) {}); err == nil {
+		t.Error("Appended a range that doesn't start at the end of the tree")
+	}
+}
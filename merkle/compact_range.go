@@ -0,0 +1,156 @@
+package merkle
+
+import (
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// compactRangeNode is the root of one of the perfect subtrees in a CompactRange
+type compactRangeNode struct {
+	// height of the subtree, a subtree of height h holds 2^h leaves
+	height uint
+	hash   trillian.Hash
+}
+
+// CompactRange holds the roots of the perfect subtrees that cover a contiguous range of
+// leaves, using as few subtrees as possible. Ranges that are next to each other can be
+// merged, so the leaves of a tree can be hashed by separate workers and the results joined,
+// and a range that starts at the first leaf can be appended to a CompactMerkleTree.
+type CompactRange struct {
+	hasher TreeHasher
+	begin  int64
+	end    int64
+	// nodes are ordered from left to right
+	nodes []compactRangeNode
+}
+
+// NewCompactRange creates an empty CompactRange that starts at leaf begin.
+func NewCompactRange(hasher TreeHasher, begin int64) *CompactRange {
+	return &CompactRange{hasher: hasher, begin: begin, end: begin}
+}
+
+// NewCompactRangeFromProto creates a CompactRange from its serialized form. It fails if the
+// number or size of the hashes doesn't match the range.
+func NewCompactRangeFromProto(hasher TreeHasher, p *storage.CompactRangeProto) (*CompactRange, error) {
+	if p.Begin < 0 || p.End < p.Begin {
+		return nil, fmt.Errorf("invalid compact range [%d, %d)", p.Begin, p.End)
+	}
+
+	heights := compactRangeHeights(p.Begin, p.End)
+
+	if len(heights) != len(p.Hashes) {
+		return nil, fmt.Errorf("compact range [%d, %d) must have %d hashes but had %d", p.Begin, p.End, len(heights), len(p.Hashes))
+	}
+
+	r := NewCompactRange(hasher, p.Begin)
+	r.end = p.End
+
+	for i, h := range p.Hashes {
+		if len(h) != hasher.Size() {
+			return nil, fmt.Errorf("compact range hash %d has %d bytes, expected %d", i, len(h), hasher.Size())
+		}
+
+		r.nodes = append(r.nodes, compactRangeNode{height: heights[i], hash: append(trillian.Hash{}, h...)})
+	}
+
+	return r, nil
+}
+
+// compactRangeHeights returns the heights of the subtrees that cover [begin, end), from left
+// to right. Each one is the largest subtree that starts where the last one finished and
+// doesn't go past end.
+func compactRangeHeights(begin, end int64) []uint {
+	var heights []uint
+
+	for begin < end {
+		height := uint(0)
+
+		for begin%(int64(2)<<height) == 0 && begin+int64(2)<<height <= end {
+			height++
+		}
+
+		heights = append(heights, height)
+		begin += int64(1) << height
+	}
+
+	return heights
+}
+
+// Begin returns the index of the first leaf in the range.
+func (r CompactRange) Begin() int64 {
+	return r.begin
+}
+
+// End returns the index of the leaf after the last one in the range.
+func (r CompactRange) End() int64 {
+	return r.end
+}
+
+// Hashes returns a copy of the subtree roots that make up the range, from left to right.
+func (r CompactRange) Hashes() []trillian.Hash {
+	hashes := make([]trillian.Hash, 0, len(r.nodes))
+
+	for _, n := range r.nodes {
+		hashes = append(hashes, n.hash)
+	}
+
+	return hashes
+}
+
+// Proto returns the serialized form of the range.
+func (r CompactRange) Proto() *storage.CompactRangeProto {
+	p := &storage.CompactRangeProto{Begin: r.begin, End: r.end}
+
+	for _, n := range r.nodes {
+		p.Hashes = append(p.Hashes, n.hash)
+	}
+
+	return p
+}
+
+// AppendLeafHash adds the leaf with leafHash to the end of the range.
+func (r *CompactRange) AppendLeafHash(leafHash trillian.Hash) {
+	// A single leaf always fits, so this can't fail
+	r.appendSubtree(0, leafHash)
+}
+
+// Merge appends other to the end of this range. other must start where this range ends.
+func (r *CompactRange) Merge(other *CompactRange) error {
+	if other.begin != r.end {
+		return fmt.Errorf("can't merge compact range [%d, %d) onto [%d, %d)", other.begin, other.end, r.begin, r.end)
+	}
+
+	for _, n := range other.nodes {
+		if err := r.appendSubtree(n.height, n.hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendSubtree adds a perfect subtree of height with root hash to the end of the range,
+// joining it with the subtrees to its left for as long as they're its siblings.
+func (r *CompactRange) appendSubtree(height uint, hash trillian.Hash) error {
+	if r.end%(int64(1)<<height) != 0 {
+		return fmt.Errorf("subtree of height %d can't start at leaf %d", height, r.end)
+	}
+
+	r.nodes = append(r.nodes, compactRangeNode{height: height, hash: hash})
+	r.end += int64(1) << height
+
+	for len(r.nodes) >= 2 {
+		left, right := r.nodes[len(r.nodes)-2], r.nodes[len(r.nodes)-1]
+		parentBegin := r.end - int64(2)<<right.height
+
+		if left.height != right.height || parentBegin%(int64(2)<<right.height) != 0 {
+			break
+		}
+
+		r.nodes = append(r.nodes[:len(r.nodes)-2], compactRangeNode{height: left.height + 1, hash: r.hasher.HashChildren(left.hash, right.hash)})
+	}
+
+	return nil
+}
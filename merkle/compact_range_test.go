@@ -0,0 +1,162 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+func compactRangeTestHasher() TreeHasher {
+	return NewRFC6962TreeHasher(trillian.NewSHA256())
+}
+
+func compactRangeTestLeafHash(i int64) trillian.Hash {
+	return compactRangeTestHasher().HashLeaf([]byte(fmt.Sprintf("Leaf %d", i)))
+}
+
+// buildCompactRange creates the range [begin, end) by adding the test leaves one at a time
+func buildCompactRange(begin, end int64) *CompactRange {
+	r := NewCompactRange(compactRangeTestHasher(), begin)
+
+	for i := begin; i < end; i++ {
+		r.AppendLeafHash(compactRangeTestLeafHash(i))
+	}
+
+	return r
+}
+
+func TestCompactRangeHeights(t *testing.T) {
+	tests := []struct {
+		begin, end int64
+		heights    []uint
+	}{
+		{0, 0, nil},
+		{0, 1, []uint{0}},
+		{0, 8, []uint{3}},
+		{0, 11, []uint{3, 1, 0}},
+		{3, 3, nil},
+		{3, 4, []uint{0}},
+		{1, 8, []uint{0, 1, 2}},
+		{5, 13, []uint{0, 1, 2, 0}},
+		{8, 16, []uint{3}},
+	}
+
+	for _, test := range tests {
+		if got := compactRangeHeights(test.begin, test.end); !reflect.DeepEqual(got, test.heights) {
+			t.Errorf("Got heights %v for [%d, %d), expected %v", got, test.begin, test.end, test.heights)
+		}
+	}
+}
+
+func TestCompactRangeMatchesTree(t *testing.T) {
+	hasher := compactRangeTestHasher()
+
+	for end := int64(0); end <= 40; end++ {
+		r := buildCompactRange(0, end)
+		tree := NewCompactMerkleTree(hasher)
+
+		for i := int64(0); i < end; i++ {
+			tree.AddLeafHash(compactRangeTestLeafHash(i), func(int, int64, trillian.Hash) {})
+		}
+
+		if got, want := r.Proto(), tree.Proto(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Got range %v for [0, %d), but tree state %v", got, end, want)
+		}
+	}
+}
+
+func TestCompactRangeMerge(t *testing.T) {
+	for begin := int64(0); begin < 20; begin++ {
+		for end := begin; end <= 40; end++ {
+			want := buildCompactRange(begin, end)
+
+			for mid := begin; mid <= end; mid++ {
+				r := buildCompactRange(begin, mid)
+
+				if err := r.Merge(buildCompactRange(mid, end)); err != nil {
+					t.Fatalf("Failed to merge [%d, %d) onto [%d, %d): %v", mid, end, begin, mid, err)
+				}
+
+				if got := r.Proto(); !reflect.DeepEqual(got, want.Proto()) {
+					t.Errorf("Got %v from merging [%d, %d) onto [%d, %d), expected %v", got, mid, end, begin, mid, want.Proto())
+				}
+			}
+		}
+	}
+}
+
+func TestCompactRangeMergeRejectsGaps(t *testing.T) {
+	r := buildCompactRange(0, 5)
+
+	for _, begin := range []int64{4, 6} {
+		if err := r.Merge(buildCompactRange(begin, 10)); err == nil {
+			t.Errorf("Merged a range starting at %d onto [0, 5)", begin)
+		}
+	}
+}
+
+func TestCompactRangeProtoRoundTrip(t *testing.T) {
+	hasher := compactRangeTestHasher()
+
+	for begin := int64(0); begin < 20; begin++ {
+		for end := begin; end <= 40; end++ {
+			p := buildCompactRange(begin, end).Proto()
+			r, err := NewCompactRangeFromProto(hasher, p)
+
+			if err != nil {
+				t.Fatalf("Failed to load range [%d, %d): %v", begin, end, err)
+			}
+
+			if got := r.Proto(); !reflect.DeepEqual(got, p) {
+				t.Errorf("Got %v after loading %v", got, p)
+			}
+
+			// The loaded range must carry on in the same way as the original
+			r.AppendLeafHash(compactRangeTestLeafHash(end))
+
+			if got, want := r.Proto(), buildCompactRange(begin, end+1).Proto(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Got %v after adding a leaf to the loaded range [%d, %d), expected %v", got, begin, end, want)
+			}
+		}
+	}
+}
+
+func TestNewCompactRangeFromProtoRejectsBadState(t *testing.T) {
+	hash := compactRangeTestLeafHash(0)
+
+	tests := []struct {
+		desc string
+		p    *storage.CompactRangeProto
+	}{
+		{"negative begin", &storage.CompactRangeProto{Begin: -1, End: 0}},
+		{"end before begin", &storage.CompactRangeProto{Begin: 3, End: 2, Hashes: [][]byte{hash}}},
+		{"missing hash", &storage.CompactRangeProto{Begin: 0, End: 3, Hashes: [][]byte{hash}}},
+		{"extra hash", &storage.CompactRangeProto{Begin: 0, End: 4, Hashes: [][]byte{hash, hash}}},
+		{"short hash", &storage.CompactRangeProto{Begin: 0, End: 1, Hashes: [][]byte{hash[1:]}}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewCompactRangeFromProto(compactRangeTestHasher(), test.p); err == nil {
+			t.Errorf("%s: loaded bad range %v", test.desc, test.p)
+		}
+	}
+}
+
+func TestCompactRangeHashesAreCopies(t *testing.T) {
+	r := buildCompactRange(0, 3)
+	hashes := r.Hashes()
+
+	if got, want := len(hashes), 2; got != want {
+		t.Fatalf("Got %d hashes for [0, 3), expected %d", got, want)
+	}
+
+	hashes[0] = nil
+
+	if bytes.Equal(r.Hashes()[0], nil) {
+		t.Error("Changing the returned hashes changed the range")
+	}
+}
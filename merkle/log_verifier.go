@@ -0,0 +1,58 @@
+package merkle
+
+import (
+	"github.com/google/trillian/merkle/verify"
+)
+
+// LogVerifier checks proofs produced by a log against tree roots that the caller trusts.
+// The checks themselves are in the verify package, which clients that only need to verify
+// proofs can use without depending on the rest of Trillian.
+type LogVerifier struct {
+	hasher TreeHasher
+}
+
+// NewLogVerifier creates a LogVerifier that hashes proof nodes with hasher.
+func NewLogVerifier(hasher TreeHasher) LogVerifier {
+	return LogVerifier{hasher: hasher}
+}
+
+// VerifyConsistencyProof checks that proof shows the tree with root2 at snapshot2 is an
+// append only extension of the tree with root1 at snapshot1. The proof nodes must be in
+// the order given by RFC 6962 section 2.1.2.
+func (v LogVerifier) VerifyConsistencyProof(snapshot1, snapshot2 int64, root1, root2 []byte, proof [][]byte) error {
+	return fromVerifyError(verify.ConsistencyProof(verifyHasher{v.hasher}, snapshot1, snapshot2, root1, root2, proof))
+}
+
+// VerifyInclusionProof checks that proof shows the leaf with leafHash is at leafIndex in the
+// tree with root at treeSize. The proof nodes must be in the order given by RFC 6962 section
+// 2.1.1, starting with the sibling of the leaf.
+func (v LogVerifier) VerifyInclusionProof(leafIndex, treeSize int64, proof [][]byte, root []byte, leafHash []byte) error {
+	return fromVerifyError(verify.InclusionProof(verifyHasher{v.hasher}, leafIndex, treeSize, proof, root, leafHash))
+}
+
+// verifyHasher adapts a TreeHasher to the verify.Hasher interface
+type verifyHasher struct {
+	hasher TreeHasher
+}
+
+func (v verifyHasher) HashLeaf(data []byte) []byte {
+	return v.hasher.HashLeaf(data)
+}
+
+func (v verifyHasher) HashChildren(l, r []byte) []byte {
+	return v.hasher.HashChildren(l, r)
+}
+
+func (v verifyHasher) Size() int {
+	return v.hasher.Size()
+}
+
+// fromVerifyError turns root mismatches reported by the verify package into the
+// RootHashMismatchError used by the rest of this package
+func fromVerifyError(err error) error {
+	if mismatch, ok := err.(verify.RootMismatchError); ok {
+		return RootHashMismatchError{ExpectedHash: mismatch.ExpectedRoot, ActualHash: mismatch.ComputedRoot}
+	}
+
+	return err
+}
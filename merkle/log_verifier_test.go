@@ -0,0 +1,182 @@
+package merkle
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func buildVerifierTestTree() (*InMemoryMerkleTree, [][]byte) {
+	mt := makeEmptyTree()
+	inputs := make([][]byte, 0, len(leafInputs))
+
+	for _, leaf := range leafInputs {
+		data := decodeHexStringOrPanic(leaf)
+		inputs = append(inputs, data)
+		mt.AddLeaf(data)
+	}
+
+	return mt, inputs
+}
+
+func TestVerifyConsistencyProof(t *testing.T) {
+	mt, inputs := buildVerifierTestTree()
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	v := NewLogVerifier(hasher)
+
+	for snapshot1 := 1; snapshot1 <= len(inputs); snapshot1++ {
+		for snapshot2 := snapshot1; snapshot2 <= len(inputs); snapshot2++ {
+			proof := referenceSnapshotConsistency(inputs[:snapshot2], snapshot2, snapshot1, hasher, true)
+			root1 := mt.RootAtSnapshot(snapshot1).Hash()
+			root2 := mt.RootAtSnapshot(snapshot2).Hash()
+
+			if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot2), root1, root2, proof); err != nil {
+				t.Errorf("Failed to verify consistency proof from %d to %d: %v", snapshot1, snapshot2, err)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyProofRejectsBadProofs(t *testing.T) {
+	mt, inputs := buildVerifierTestTree()
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	v := NewLogVerifier(hasher)
+
+	for snapshot1 := 1; snapshot1 < len(inputs); snapshot1++ {
+		for snapshot2 := snapshot1 + 1; snapshot2 <= len(inputs); snapshot2++ {
+			proof := referenceSnapshotConsistency(inputs[:snapshot2], snapshot2, snapshot1, hasher, true)
+			root1 := mt.RootAtSnapshot(snapshot1).Hash()
+			root2 := mt.RootAtSnapshot(snapshot2).Hash()
+
+			if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot2), root2, root1, proof); err == nil {
+				t.Errorf("Verified consistency proof from %d to %d with swapped roots", snapshot1, snapshot2)
+			}
+
+			if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot2), root1, root2, proof[:len(proof)-1]); err == nil {
+				t.Errorf("Verified truncated consistency proof from %d to %d", snapshot1, snapshot2)
+			}
+
+			if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot2), root1, root2, append(proof, root1)); err == nil {
+				t.Errorf("Verified extended consistency proof from %d to %d", snapshot1, snapshot2)
+			}
+
+			for i := range proof {
+				bad := append([][]byte{}, proof...)
+				bad[i] = hasher.HashLeaf(bad[i])
+
+				if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot2), root1, root2, bad); err == nil {
+					t.Errorf("Verified consistency proof from %d to %d with node %d modified", snapshot1, snapshot2, i)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyProofEdgeCases(t *testing.T) {
+	mt, _ := buildVerifierTestTree()
+	v := NewLogVerifier(NewRFC6962TreeHasher(trillian.NewSHA256()))
+	root := mt.CurrentRoot().Hash()
+
+	if err := v.VerifyConsistencyProof(0, 8, nil, root, [][]byte{}); err != nil {
+		t.Errorf("Failed to verify consistency from the empty tree: %v", err)
+	}
+
+	if err := v.VerifyConsistencyProof(0, 8, nil, root, [][]byte{root}); err == nil {
+		t.Errorf("Verified non empty proof from the empty tree")
+	}
+
+	if err := v.VerifyConsistencyProof(8, 8, root, mt.RootAtSnapshot(7).Hash(), [][]byte{}); err == nil {
+		t.Errorf("Verified equal tree sizes with different roots")
+	}
+
+	if err := v.VerifyConsistencyProof(8, 7, root, root, [][]byte{root}); err == nil {
+		t.Errorf("Verified proof where the second tree is smaller")
+	}
+
+	if err := v.VerifyConsistencyProof(-1, 7, root, root, [][]byte{root}); err == nil {
+		t.Errorf("Verified proof with a negative tree size")
+	}
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	mt, inputs := buildVerifierTestTree()
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	v := NewLogVerifier(hasher)
+
+	for treeSize := 1; treeSize <= len(inputs); treeSize++ {
+		root := mt.RootAtSnapshot(treeSize).Hash()
+
+		for leaf := 0; leaf < treeSize; leaf++ {
+			// The reference implementation numbers leaves from 1
+			proof := referenceMerklePath(inputs[:treeSize], leaf+1, hasher)
+			leafHash := hasher.HashLeaf(inputs[leaf])
+
+			if err := v.VerifyInclusionProof(int64(leaf), int64(treeSize), proof, root, leafHash); err != nil {
+				t.Errorf("Failed to verify inclusion of leaf %d in tree size %d: %v", leaf, treeSize, err)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionProofRejectsBadProofs(t *testing.T) {
+	mt, inputs := buildVerifierTestTree()
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	v := NewLogVerifier(hasher)
+
+	for treeSize := 2; treeSize <= len(inputs); treeSize++ {
+		root := mt.RootAtSnapshot(treeSize).Hash()
+
+		for leaf := 0; leaf < treeSize; leaf++ {
+			proof := referenceMerklePath(inputs[:treeSize], leaf+1, hasher)
+			leafHash := hasher.HashLeaf(inputs[leaf])
+
+			if err := v.VerifyInclusionProof(int64(leaf), int64(treeSize), proof, root, hasher.HashLeaf(leafHash)); err == nil {
+				t.Errorf("Verified inclusion of the wrong leaf hash at %d in tree size %d", leaf, treeSize)
+			}
+
+			if err := v.VerifyInclusionProof(int64((leaf+1)%treeSize), int64(treeSize), proof, root, leafHash); err == nil {
+				t.Errorf("Verified inclusion of leaf %d at the wrong index in tree size %d", leaf, treeSize)
+			}
+
+			if err := v.VerifyInclusionProof(int64(leaf), int64(treeSize), proof[:len(proof)-1], root, leafHash); err == nil {
+				t.Errorf("Verified truncated inclusion proof for leaf %d in tree size %d", leaf, treeSize)
+			}
+
+			if err := v.VerifyInclusionProof(int64(leaf), int64(treeSize), append(proof, root), root, leafHash); err == nil {
+				t.Errorf("Verified extended inclusion proof for leaf %d in tree size %d", leaf, treeSize)
+			}
+
+			for i := range proof {
+				bad := append([][]byte{}, proof...)
+				bad[i] = hasher.HashLeaf(bad[i])
+
+				if err := v.VerifyInclusionProof(int64(leaf), int64(treeSize), bad, root, leafHash); err == nil {
+					t.Errorf("Verified inclusion proof for leaf %d in tree size %d with node %d modified", leaf, treeSize, i)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionProofEdgeCases(t *testing.T) {
+	mt, inputs := buildVerifierTestTree()
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	v := NewLogVerifier(hasher)
+	leafHash := hasher.HashLeaf(inputs[0])
+
+	if err := v.VerifyInclusionProof(0, 1, [][]byte{}, mt.RootAtSnapshot(1).Hash(), leafHash); err != nil {
+		t.Errorf("Failed to verify inclusion in a tree of size 1: %v", err)
+	}
+
+	if err := v.VerifyInclusionProof(0, 0, [][]byte{}, nil, leafHash); err == nil {
+		t.Errorf("Verified inclusion in the empty tree")
+	}
+
+	if err := v.VerifyInclusionProof(-1, 8, [][]byte{}, mt.CurrentRoot().Hash(), leafHash); err == nil {
+		t.Errorf("Verified inclusion at a negative index")
+	}
+
+	if err := v.VerifyInclusionProof(8, 8, [][]byte{}, mt.CurrentRoot().Hash(), leafHash); err == nil {
+		t.Errorf("Verified inclusion at an index past the end of the tree")
+	}
+}
@@ -0,0 +1,34 @@
+package merkle
+
+import (
+	"github.com/google/trillian/merkle/verify"
+)
+
+// MapVerifier checks proofs produced by a map against map roots that the caller trusts.
+type MapVerifier struct {
+	hasher MapHasher
+}
+
+// NewMapVerifier creates a MapVerifier that hashes keys and proof nodes with hasher.
+func NewMapVerifier(hasher MapHasher) MapVerifier {
+	return MapVerifier{hasher: hasher}
+}
+
+// VerifyMapInclusion checks that proof shows key has value in the map with root. A nil
+// value checks that key has no value in the map, in which case the leaf is empty and the
+// proof holds the null hashes wherever the path has no other keys under it. An empty value
+// can't be told apart from no value. The proof nodes must be in the order returned by the
+// map server, starting with the sibling of the leaf and ending with the child of the root.
+func (v MapVerifier) VerifyMapInclusion(root []byte, key []byte, value []byte, proof [][]byte) error {
+	return fromVerifyError(verify.MapInclusionProof(verifyMapHasher{verifyHasher{v.hasher.TreeHasher}, v.hasher}, root, key, value, proof))
+}
+
+// verifyMapHasher adapts a MapHasher to the verify.MapHasher interface
+type verifyMapHasher struct {
+	verifyHasher
+	hasher MapHasher
+}
+
+func (v verifyMapHasher) HashKey(key []byte) []byte {
+	return v.hasher.HashKey(key)
+}
@@ -0,0 +1,169 @@
+package merkle
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+)
+
+// nodeStoreTX keeps the nodes written by a SparseMerkleTreeWriter so a
+// SparseMerkleTreeReader can build proofs from them. It only holds one revision.
+type nodeStoreTX struct {
+	storage.TreeTX
+	mu    sync.Mutex
+	nodes map[string]storage.Node
+}
+
+func newNodeStoreTX() *nodeStoreTX {
+	return &nodeStoreTX{nodes: make(map[string]storage.Node)}
+}
+
+func (n *nodeStoreTX) GetMerkleNodes(ctx context.Context, rev int64, ids []storage.NodeID) ([]storage.Node, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var nodes []storage.Node
+	for _, id := range ids {
+		if node, ok := n.nodes[id.String()]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func (n *nodeStoreTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, node := range nodes {
+		n.nodes[node.NodeID.String()] = node
+	}
+	return nil
+}
+
+func (n *nodeStoreTX) Commit() error {
+	return nil
+}
+
+func buildMapVerifierTestTree(t *testing.T, hasher MapHasher, kvs map[string]string) (*SparseMerkleTreeReader, trillian.Hash) {
+	const rev = 1
+	tx := newNodeStoreTX()
+	w, err := NewSparseMerkleTreeWriter(context.Background(), rev, hasher, func() (storage.TreeTX, error) { return tx, nil })
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	leaves := make([]HashKeyValue, 0, len(kvs))
+	for k, v := range kvs {
+		leaves = append(leaves, HashKeyValue{hasher.HashKey([]byte(k)), hasher.HashLeaf([]byte(v))})
+	}
+	if err := w.SetLeaves(leaves); err != nil {
+		t.Fatalf("Failed to set leaves: %v", err)
+	}
+	root, err := w.CalculateRoot()
+	if err != nil {
+		t.Fatalf("Failed to calculate root: %v", err)
+	}
+
+	return NewSparseMerkleTreeReader(rev, hasher, tx), root
+}
+
+func mapInclusionProof(t *testing.T, r *SparseMerkleTreeReader, key string) [][]byte {
+	proof, err := r.InclusionProof(context.Background(), r.treeRevision, []byte(key))
+	if err != nil {
+		t.Fatalf("Failed to get inclusion proof for %s: %v", key, err)
+	}
+
+	ret := make([][]byte, 0, len(proof))
+	for _, h := range proof {
+		ret = append(ret, h)
+	}
+	return ret
+}
+
+func TestVerifyMapInclusion(t *testing.T) {
+	hasher := NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256()))
+	v := NewMapVerifier(hasher)
+	kvs := map[string]string{"key1": "value1", "key2": "value2", "key3": "value3"}
+	r, root := buildMapVerifierTestTree(t, hasher, kvs)
+
+	for k, value := range kvs {
+		proof := mapInclusionProof(t, r, k)
+
+		if err := v.VerifyMapInclusion(root, []byte(k), []byte(value), proof); err != nil {
+			t.Errorf("Failed to verify inclusion of %s: %v", k, err)
+		}
+		if err := v.VerifyMapInclusion(root, []byte(k), nil, proof); err == nil {
+			t.Errorf("Verified absence of %s, which has a value", k)
+		}
+		if err := v.VerifyMapInclusion(root, []byte(k), []byte("wrong"), proof); err == nil {
+			t.Errorf("Verified the wrong value for %s", k)
+		}
+	}
+}
+
+func TestVerifyMapInclusionOfAbsentKey(t *testing.T) {
+	hasher := NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256()))
+	v := NewMapVerifier(hasher)
+	r, root := buildMapVerifierTestTree(t, hasher, map[string]string{"key1": "value1", "key2": "value2"})
+
+	for _, k := range []string{"absent1", "absent2", "absent3"} {
+		proof := mapInclusionProof(t, r, k)
+
+		if err := v.VerifyMapInclusion(root, []byte(k), nil, proof); err != nil {
+			t.Errorf("Failed to verify absence of %s: %v", k, err)
+		}
+		if err := v.VerifyMapInclusion(root, []byte(k), []byte("value1"), proof); err == nil {
+			t.Errorf("Verified a value for %s, which is absent", k)
+		}
+	}
+}
+
+func TestVerifyMapInclusionInEmptyMap(t *testing.T) {
+	hasher := NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256()))
+	v := NewMapVerifier(hasher)
+	r := NewSparseMerkleTreeReader(1, hasher, newNodeStoreTX())
+	root := testonly.MustDecodeBase64(sparseEmptyRootHashB64)
+
+	if err := v.VerifyMapInclusion(root, []byte("key"), nil, mapInclusionProof(t, r, "key")); err != nil {
+		t.Fatalf("Failed to verify absence of key in empty map: %v", err)
+	}
+}
+
+func TestVerifyMapInclusionRejectsBadProofs(t *testing.T) {
+	hasher := NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256()))
+	v := NewMapVerifier(hasher)
+	r, root := buildMapVerifierTestTree(t, hasher, map[string]string{"key1": "value1", "key2": "value2"})
+	proof := mapInclusionProof(t, r, "key1")
+
+	if err := v.VerifyMapInclusion(root, []byte("key1"), []byte("value1"), proof[:len(proof)-1]); err == nil {
+		t.Error("Verified truncated proof")
+	}
+	if err := v.VerifyMapInclusion(root, []byte("key1"), []byte("value1"), append(proof, root)); err == nil {
+		t.Error("Verified extended proof")
+	}
+	if err := v.VerifyMapInclusion(root, []byte("key2"), []byte("value1"), proof); err == nil {
+		t.Error("Verified proof for the wrong key")
+	}
+
+	err := v.VerifyMapInclusion(proof[0], []byte("key1"), []byte("value1"), proof)
+	if _, ok := err.(RootHashMismatchError); !ok {
+		t.Errorf("Expected RootHashMismatchError for the wrong root but got %v", err)
+	}
+
+	// Flip each node in turn
+	for i := range proof {
+		bad := make([][]byte, len(proof))
+		copy(bad, proof)
+		bad[i] = append([]byte{}, proof[i]...)
+		bad[i][0] ^= 1
+
+		if err := v.VerifyMapInclusion(root, []byte("key1"), []byte("value1"), bad); err == nil {
+			t.Errorf("Verified proof with node %d changed", i)
+		}
+	}
+}
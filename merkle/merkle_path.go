@@ -148,6 +148,167 @@ func pathFromNodeToRootAtSnapshot(node int64, level int, snapshot int64, maxBitL
 	return proof, nil
 }
 
+// NodeFetch describes how to get one node of a proof for a tree size from storage that holds
+// a larger tree. Nodes on the right edge of the smaller tree may since have been overwritten,
+// as the subtrees under them grew, so they're recomputed from the perfect subtrees they're made
+// of, which never change once written.
+type NodeFetch struct {
+	// NodeID is the ID of the node in the tree of the size the proof is for.
+	NodeID storage.NodeID
+	// Rehash holds the IDs of the perfect subtrees that make up the node, from left to right,
+	// if it has to be recomputed. If it's empty the node is read as NodeID.
+	Rehash []storage.NodeID
+}
+
+// CalcInclusionProofNodeFetches returns the fetches needed to build an inclusion proof for a
+// specified leaf and snapshot tree size, reading nodes from storage at a revision where the
+// tree has treeSize leaves. If snapshot is treeSize no nodes need to be rehashed.
+func CalcInclusionProofNodeFetches(snapshot, index, treeSize int64, maxBitLen int) ([]NodeFetch, error) {
+	if snapshot > treeSize {
+		return []NodeFetch{}, fmt.Errorf("invalid params snapshot: %d treesize: %d", snapshot, treeSize)
+	}
+
+	ids, err := CalcInclusionProofNodeAddresses(snapshot, index, maxBitLen)
+
+	if err != nil {
+		return []NodeFetch{}, err
+	}
+
+	return nodeFetches(ids, snapshot, treeSize, maxBitLen)
+}
+
+// CalcConsistencyProofNodeFetches returns the fetches needed to build a consistency proof
+// between two specified tree sizes, reading nodes from storage at a revision where the tree
+// has treeSize leaves. If treeSize is the second tree size no nodes need to be rehashed.
+func CalcConsistencyProofNodeFetches(previousTreeSize, snapshot, treeSize int64, maxBitLen int) ([]NodeFetch, error) {
+	if snapshot > treeSize {
+		return []NodeFetch{}, fmt.Errorf("invalid params snapshot: %d treesize: %d", snapshot, treeSize)
+	}
+
+	ids, err := CalcConsistencyProofNodeAddresses(previousTreeSize, snapshot, maxBitLen)
+
+	if err != nil {
+		return []NodeFetch{}, err
+	}
+
+	return nodeFetches(ids, snapshot, treeSize, maxBitLen)
+}
+
+// nodeFetches works out how to read the nodes with ids, which are in the tree of size snapshot,
+// from the tree of size treeSize. A node covering leaves past the end of the snapshot holds the
+// hash of the ones up to its end, but only while that is the size of the tree. Once the tree is
+// larger the node has to be recomputed.
+func nodeFetches(ids []storage.NodeID, snapshot, treeSize int64, maxBitLen int) ([]NodeFetch, error) {
+	fetches := make([]NodeFetch, 0, len(ids))
+
+	for _, id := range ids {
+		level, index := nodeCoords(id)
+		start := index << uint(level)
+
+		if snapshot == treeSize || start+(1<<uint(level)) <= snapshot {
+			fetches = append(fetches, NodeFetch{NodeID: id})
+			continue
+		}
+
+		// Split the leaves from start to the end of the snapshot into perfect subtrees, biggest
+		// first, which is how the node's hash was made from them
+		rehash := make([]storage.NodeID, 0, bitLen(snapshot-start))
+
+		for b := bitLen(snapshot-start) - 1; b >= 0; b-- {
+			if (snapshot-start)&(1<<uint(b)) == 0 {
+				continue
+			}
+
+			n, err := storage.NewNodeIDForTreeCoords(int64(b), start>>uint(b), maxBitLen)
+			if err != nil {
+				return nil, err
+			}
+			rehash = append(rehash, n)
+			start += 1 << uint(b)
+		}
+
+		if len(rehash) == 1 {
+			// The node is itself a perfect subtree so it can be read as it is
+			fetches = append(fetches, NodeFetch{NodeID: rehash[0]})
+			continue
+		}
+
+		fetches = append(fetches, NodeFetch{NodeID: id, Rehash: rehash})
+	}
+
+	return fetches, nil
+}
+
+// nodeCoords returns the level and index of a node created by storage.NewNodeIDForTreeCoords.
+func nodeCoords(id storage.NodeID) (int, int64) {
+	var path uint64
+	for _, b := range id.Path {
+		path = path<<8 | uint64(b)
+	}
+
+	level := id.PathLenBits - id.PrefixLenBits
+	return level, int64(path >> uint(level))
+}
+
+// NodeFetchIDs returns the IDs of all the nodes to read from storage for fetches, in order.
+func NodeFetchIDs(fetches []NodeFetch) []storage.NodeID {
+	ids := make([]storage.NodeID, 0, len(fetches))
+
+	for _, f := range fetches {
+		if len(f.Rehash) > 0 {
+			ids = append(ids, f.Rehash...)
+		} else {
+			ids = append(ids, f.NodeID)
+		}
+	}
+
+	return ids
+}
+
+// RehashProofNodes turns the nodes read from storage for the IDs given by NodeFetchIDs into
+// the nodes of the proof, one for each fetch. Nodes that need to be recomputed are hashed
+// together from right to left with hasher, and have the latest revision of their parts.
+func RehashProofNodes(hasher TreeHasher, fetches []NodeFetch, nodes []storage.Node) ([]storage.Node, error) {
+	ids := NodeFetchIDs(fetches)
+
+	if len(nodes) != len(ids) {
+		return nil, fmt.Errorf("expected %d nodes to rehash but got %d", len(ids), len(nodes))
+	}
+
+	for i, node := range nodes {
+		if !node.NodeID.Equivalent(ids[i]) {
+			return nil, fmt.Errorf("expected node %v at pos %d but got %v", ids[i], i, node.NodeID)
+		}
+	}
+
+	proof := make([]storage.Node, 0, len(fetches))
+
+	for _, f := range fetches {
+		if len(f.Rehash) == 0 {
+			proof = append(proof, nodes[0])
+			nodes = nodes[1:]
+			continue
+		}
+
+		parts := nodes[:len(f.Rehash)]
+		nodes = nodes[len(f.Rehash):]
+
+		node := storage.Node{NodeID: f.NodeID, Hash: parts[len(parts)-1].Hash, NodeRevision: parts[len(parts)-1].NodeRevision}
+
+		for i := len(parts) - 2; i >= 0; i-- {
+			node.Hash = hasher.HashChildren(parts[i].Hash, node.Hash)
+
+			if parts[i].NodeRevision > node.NodeRevision {
+				node.NodeRevision = parts[i].NodeRevision
+			}
+		}
+
+		proof = append(proof, node)
+	}
+
+	return proof, nil
+}
+
 // subtreeDepth calculates the depth of a subtree, used at the right of the tree which
 // may not be completely populated
 func subtreeDepth(size int64, bits int) int {
@@ -155,6 +316,11 @@ func subtreeDepth(size int64, bits int) int {
 		size = size &^ (1 << uint(b))
 	}
 
+	// the last node at the level is complete so no levels are skipped
+	if size == 0 {
+		return bits + 1
+	}
+
 	// determine tree height for the remaining bits.
 	p2 := bitLen(size) - 1
 	size = size &^ (1 << uint(p2))
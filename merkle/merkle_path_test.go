@@ -1,8 +1,10 @@
 package merkle
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
 )
@@ -31,11 +33,16 @@ var expectedPathSize7Index3 = []storage.NodeID{testonly.MustCreateNodeIDForTreeC
 var expectedPathSize7Index4 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 5, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64), testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}
 var expectedPathSize7Index6 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}
 
+// In a tree with a power of two leaves the last node at each level is complete
+var expectedPathSize2Index0 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 1, 64)}
+var expectedPathSize8Index6 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 7, 64), testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}
+
 // Expected consistency proofs built from the examples in RFC 6962. Again, in our implementation
 // node layers are filled from the bottom upwards.
 var expectedConsistencyProofFromSize6To7 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64), testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}
 var expectedConsistencyProofFromSize3To7 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 3, 64), testonly.MustCreateNodeIDForTreeCoords(1, 0, 64), testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
 var expectedConsistencyProofFromSize4To7 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
+var expectedConsistencyProofFromSize4To8 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
 
 var bitLenTests = []bitLenTestData{{0, 0}, {1, 1}, {2, 2}, {3, 2}, {12, 4}}
 
@@ -45,7 +52,9 @@ var pathTests = []auditPathTestData{
 	{7, 3, expectedPathSize7Index3},
 	{7, 6, expectedPathSize7Index6},
 	{7, 0, expectedPathSize7Index0},
-	{7, 4, expectedPathSize7Index4}}
+	{7, 4, expectedPathSize7Index4},
+	{2, 0, expectedPathSize2Index0},
+	{8, 6, expectedPathSize8Index6}}
 
 // These should all fail
 var pathTestBad = []auditPathTestData{
@@ -61,7 +70,8 @@ var pathTestBad = []auditPathTestData{
 var consistencyTests = []consistencyProofTestData{
 	{6, 7, expectedConsistencyProofFromSize6To7},
 	{3, 7, expectedConsistencyProofFromSize3To7},
-	{4, 7, expectedConsistencyProofFromSize4To7}}
+	{4, 7, expectedConsistencyProofFromSize4To7},
+	{4, 8, expectedConsistencyProofFromSize4To8}}
 
 // These should all fail to provide proofs
 var consistencyTestsBad = []consistencyProofTestData{
@@ -140,6 +150,167 @@ func TestCalcConsistencyProofNodeAddressesRejectsBadBitLen(t *testing.T) {
 	}
 }
 
+// rehashTestLog holds the leaf hashes of a log and hashes its nodes as they're stored once
+// the tree has grown to a size, where a node on the right edge of the tree holds the hash of
+// the leaves under it that are in the tree
+type rehashTestLog struct {
+	hasher TreeHasher
+	leaves [][]byte
+}
+
+func newRehashTestLog(size int) rehashTestLog {
+	l := rehashTestLog{hasher: NewRFC6962TreeHasher(trillian.NewSHA256())}
+	for i := 0; i < size; i++ {
+		l.leaves = append(l.leaves, l.hasher.HashLeaf([]byte(fmt.Sprintf("leaf %d", i))))
+	}
+	return l
+}
+
+// rootOf is the RFC 6962 hash of leaves
+func (l rehashTestLog) rootOf(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := 1
+	for k<<1 < len(leaves) {
+		k <<= 1
+	}
+	return l.hasher.HashChildren(l.rootOf(leaves[:k]), l.rootOf(leaves[k:]))
+}
+
+// storedNodes returns the nodes with ids as they're stored when the tree has treeSize leaves
+func (l rehashTestLog) storedNodes(ids []storage.NodeID, treeSize int64) []storage.Node {
+	nodes := make([]storage.Node, 0, len(ids))
+	for _, id := range ids {
+		level, index := nodeCoords(id)
+		start, end := index<<uint(level), (index+1)<<uint(level)
+		if end > treeSize {
+			end = treeSize
+		}
+		nodes = append(nodes, storage.Node{NodeID: id, Hash: l.rootOf(l.leaves[start:end])})
+	}
+	return nodes
+}
+
+// proofAt builds the proof for fetches from the nodes stored when the tree has treeSize leaves
+func (l rehashTestLog) proofAt(t *testing.T, fetches []NodeFetch, treeSize int64) [][]byte {
+	nodes, err := RehashProofNodes(l.hasher, fetches, l.storedNodes(NodeFetchIDs(fetches), treeSize))
+	if err != nil {
+		t.Fatalf("failed to rehash proof nodes: %v", err)
+	}
+	proof := make([][]byte, 0, len(nodes))
+	for i, n := range nodes {
+		if !n.NodeID.Equivalent(fetches[i].NodeID) {
+			t.Fatalf("expected node %v at position %d but got %v", fetches[i].NodeID, i, n.NodeID)
+		}
+		proof = append(proof, n.Hash)
+	}
+	return proof
+}
+
+func TestCalcInclusionProofNodeFetchesVerifyAtEverySize(t *testing.T) {
+	const treeSize = 21
+	l := newRehashTestLog(treeSize)
+	v := NewLogVerifier(l.hasher)
+
+	for snapshot := int64(1); snapshot <= treeSize; snapshot++ {
+		root := l.rootOf(l.leaves[:snapshot])
+
+		for index := int64(0); index < snapshot; index++ {
+			fetches, err := CalcInclusionProofNodeFetches(snapshot, index, treeSize, 64)
+			if err != nil {
+				t.Fatalf("failed to calculate fetches for leaf %d at size %d: %v", index, snapshot, err)
+			}
+
+			proof := l.proofAt(t, fetches, treeSize)
+			if err := v.VerifyInclusionProof(index, snapshot, proof, root, l.leaves[index]); err != nil {
+				t.Errorf("inclusion proof for leaf %d at size %d read from size %d didn't verify: %v", index, snapshot, treeSize, err)
+			}
+		}
+	}
+}
+
+func TestCalcConsistencyProofNodeFetchesVerifyAtEverySize(t *testing.T) {
+	const treeSize = 21
+	l := newRehashTestLog(treeSize)
+	v := NewLogVerifier(l.hasher)
+
+	for snapshot := int64(2); snapshot <= treeSize; snapshot++ {
+		root := l.rootOf(l.leaves[:snapshot])
+
+		for prior := int64(1); prior < snapshot; prior++ {
+			fetches, err := CalcConsistencyProofNodeFetches(prior, snapshot, treeSize, 64)
+			if err != nil {
+				t.Fatalf("failed to calculate fetches from size %d to %d: %v", prior, snapshot, err)
+			}
+
+			proof := l.proofAt(t, fetches, treeSize)
+			if err := v.VerifyConsistencyProof(prior, snapshot, l.rootOf(l.leaves[:prior]), root, proof); err != nil {
+				t.Errorf("consistency proof from size %d to %d read from size %d didn't verify: %v", prior, snapshot, treeSize, err)
+			}
+		}
+	}
+}
+
+func TestCalcProofNodeFetchesAtSnapshotNeedNoRehash(t *testing.T) {
+	for _, testCase := range pathTests[1:] {
+		fetches, err := CalcInclusionProofNodeFetches(testCase.treeSize, testCase.leafIndex, testCase.treeSize, 64)
+		if err != nil {
+			t.Fatalf("unexpected error calculating fetches %v: %v", testCase, err)
+		}
+
+		for _, f := range fetches {
+			if len(f.Rehash) > 0 {
+				t.Errorf("fetch of %v for %v at its own size needs a rehash", f.NodeID, testCase)
+			}
+		}
+		comparePaths(t, NodeFetchIDs(fetches), testCase.expectedPath)
+	}
+}
+
+func TestCalcInclusionProofNodeFetchesRehashesRightEdge(t *testing.T) {
+	// At size 7 the sibling of leaf 0 at level 2 covers leaves 4 to 6, which is rewritten once
+	// leaf 7 is added so has to be recomputed from the node over leaves 4 and 5, and leaf 6
+	fetches, err := CalcInclusionProofNodeFetches(7, 0, 8, 64)
+	if err != nil {
+		t.Fatalf("unexpected error calculating fetches: %v", err)
+	}
+
+	comparePaths(t, []storage.NodeID{fetches[0].NodeID, fetches[1].NodeID, fetches[2].NodeID}, expectedPathSize7Index0)
+	if len(fetches[0].Rehash) > 0 || len(fetches[1].Rehash) > 0 {
+		t.Errorf("complete nodes of the proof need a rehash: %v", fetches)
+	}
+	comparePaths(t, fetches[2].Rehash, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)})
+}
+
+func TestCalcProofNodeFetchesRejectsSnapshotAfterTreeSize(t *testing.T) {
+	if _, err := CalcInclusionProofNodeFetches(8, 3, 7, 64); err == nil {
+		t.Error("inclusion proof fetches accepted a snapshot larger than the tree")
+	}
+
+	if _, err := CalcConsistencyProofNodeFetches(3, 8, 7, 64); err == nil {
+		t.Error("consistency proof fetches accepted a snapshot larger than the tree")
+	}
+}
+
+func TestRehashProofNodesChecksNodes(t *testing.T) {
+	l := newRehashTestLog(8)
+	fetches, err := CalcInclusionProofNodeFetches(7, 0, 8, 64)
+	if err != nil {
+		t.Fatalf("unexpected error calculating fetches: %v", err)
+	}
+	nodes := l.storedNodes(NodeFetchIDs(fetches), 8)
+
+	if _, err := RehashProofNodes(l.hasher, fetches, nodes[1:]); err == nil {
+		t.Error("rehashed proof with a node missing")
+	}
+
+	nodes[3].NodeID = testonly.MustCreateNodeIDForTreeCoords(0, 7, 64)
+	if _, err := RehashProofNodes(l.hasher, fetches, nodes); err == nil {
+		t.Error("rehashed proof with the wrong node")
+	}
+}
+
 func comparePaths(t *testing.T, got, expected []storage.NodeID) {
 	if len(expected) != len(got) {
 		t.Fatalf("expected %d nodes in path but got %d: %v", len(expected), len(got), got)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 // For more information about how Sparse Merkle Trees work see the Revocation Transparency
@@ -89,6 +90,9 @@ type subtreeWriter struct {
 	// children is a map of child-subtrees by stringified prefix.
 	children map[string]Subtree
 
+	// ctx is the context of the request the tree is being updated for, which the
+	// subtree's storage operations are done under.
+	ctx          context.Context
 	tx           storage.TreeTX
 	treeRevision int64
 
@@ -194,9 +198,7 @@ func nodeIDFromAddress(size int, prefix []byte, index *big.Int, depth int) stora
 	} else {
 		copy(t[len(prefix):], ib)
 	}
-	n := storage.NewNodeIDFromHash(t)
-	n.PrefixLenBits = len(prefix)*8 + depth
-	return n
+	return storage.NewNodeIDFromHash(t).Prefix(len(prefix)*8 + depth)
 }
 
 // buildSubtree is the worker function which calculates the root hash.
@@ -230,7 +232,7 @@ func (s *subtreeWriter) buildSubtree() {
 	root, err := hs2.HStar2Nodes(s.subtreeDepth, treeDepthOffset, leaves,
 		func(depth int, index *big.Int) (trillian.Hash, error) {
 			nodeID := nodeIDFromAddress(s.treeHasher.Size(), s.prefix, index, depth)
-			nodes, err := s.tx.GetMerkleNodes(s.treeRevision, []storage.NodeID{nodeID})
+			nodes, err := s.tx.GetMerkleNodes(s.ctx, s.treeRevision, []storage.NodeID{nodeID})
 			if err != nil {
 				return nil, err
 			}
@@ -266,7 +268,7 @@ func (s *subtreeWriter) buildSubtree() {
 	}
 
 	// write nodes back to storage
-	if err := s.tx.SetMerkleNodes(nodesToStore); err != nil {
+	if err := s.tx.SetMerkleNodes(s.ctx, nodesToStore); err != nil {
 		s.root <- rootHashOrError{nil, err}
 		return
 	}
@@ -306,7 +308,7 @@ func leafQueueSize(depths []int) int {
 }
 
 // newLocalSubtreeWriter creates a new local go-routine based subtree worker.
-func newLocalSubtreeWriter(rev int64, prefix []byte, depths []int, newTX newTXFunc, h TreeHasher) (Subtree, error) {
+func newLocalSubtreeWriter(ctx context.Context, rev int64, prefix []byte, depths []int, newTX newTXFunc, h TreeHasher) (Subtree, error) {
 	tx, err := newTX()
 	if err != nil {
 		return nil, err
@@ -319,11 +321,12 @@ func newLocalSubtreeWriter(rev int64, prefix []byte, depths []int, newTX newTXFu
 		leafQueue:    make(chan func() (*indexAndHash, error), leafQueueSize(depths)),
 		root:         make(chan rootHashOrError, 1),
 		children:     make(map[string]Subtree),
+		ctx:          ctx,
 		tx:           tx,
 		treeHasher:   h,
 		getSubtree: func(p []byte) (Subtree, error) {
 			myPrefix := bytes.Join([][]byte{prefix, p}, []byte{})
-			return newLocalSubtreeWriter(rev, myPrefix, depths[1:], newTX, h)
+			return newLocalSubtreeWriter(ctx, rev, myPrefix, depths[1:], newTX, h)
 		},
 	}
 	// TODO(al): probably shouldn't be spawning go routines willy-nilly like
@@ -334,11 +337,12 @@ func newLocalSubtreeWriter(rev int64, prefix []byte, depths []int, newTX newTXFu
 
 // NewSparseMerkleTreeWriter returns a new SparseMerkleTreeWriter, which will
 // write data back into the tree at the specified revision, using the passed
-// in MapHasher to calulate/verify tree hashes, storing via tx.
-func NewSparseMerkleTreeWriter(rev int64, h MapHasher, newTX newTXFunc) (*SparseMerkleTreeWriter, error) {
+// in MapHasher to calulate/verify tree hashes, storing via tx. Storage operations are
+// done under ctx, which must remain valid until the root has been calculated.
+func NewSparseMerkleTreeWriter(ctx context.Context, rev int64, h MapHasher, newTX newTXFunc) (*SparseMerkleTreeWriter, error) {
 	// TODO(al): allow the tree layering sizes to be customisable somehow.
 	const topSubtreeSize = 8 // must be a multiple of 8 for now.
-	tree, err := newLocalSubtreeWriter(rev, []byte{}, []int{topSubtreeSize, h.Size()*8 - topSubtreeSize}, newTX, h.TreeHasher)
+	tree, err := newLocalSubtreeWriter(ctx, rev, []byte{}, []int{topSubtreeSize, h.Size()*8 - topSubtreeSize}, newTX, h.TreeHasher)
 	if err != nil {
 		return nil, err
 	}
@@ -351,9 +355,9 @@ func NewSparseMerkleTreeWriter(rev int64, h MapHasher, newTX newTXFunc) (*Sparse
 
 // RootAtRevision returns the sparse merkle tree root hash at the specified
 // revision, or ErrNoSuchRevision if the requested revision doesn't exist.
-func (s SparseMerkleTreeReader) RootAtRevision(rev int64) (trillian.Hash, error) {
+func (s SparseMerkleTreeReader) RootAtRevision(ctx context.Context, rev int64) (trillian.Hash, error) {
 	rootNodeID := storage.NewEmptyNodeID(256)
-	nodes, err := s.tx.GetMerkleNodes(rev, []storage.NodeID{rootNodeID})
+	nodes, err := s.tx.GetMerkleNodes(ctx, rev, []storage.NodeID{rootNodeID})
 	if err != nil {
 		return nil, err
 	}
@@ -377,11 +381,36 @@ func (s SparseMerkleTreeReader) RootAtRevision(rev int64) (trillian.Hash, error)
 // InclusionProof returns an inclusion (or non-inclusion) proof for the
 // specified key at the specified revision.
 // If the revision does not exist it will return ErrNoSuchRevision error.
-func (s SparseMerkleTreeReader) InclusionProof(rev int64, key trillian.Key) ([]trillian.Hash, error) {
-	kh := s.hasher.HashKey(key)
-	nid := storage.NewNodeIDFromHash(kh)
-	sibs := nid.Siblings()
-	nodes, err := s.tx.GetMerkleNodes(rev, sibs)
+func (s SparseMerkleTreeReader) InclusionProof(ctx context.Context, rev int64, key trillian.Key) ([]trillian.Hash, error) {
+	proofs, err := s.BatchInclusionProof(ctx, rev, []trillian.Key{key})
+	if err != nil {
+		return nil, err
+	}
+	return proofs[0], nil
+}
+
+// BatchInclusionProof returns inclusion (or non-inclusion) proofs for each of
+// the specified keys at the specified revision, in the same order as keys.
+// The sibling nodes for all of the proofs are fetched from storage in a single
+// request, so nodes shared between proofs are only read once.
+func (s SparseMerkleTreeReader) BatchInclusionProof(ctx context.Context, rev int64, keys []trillian.Key) ([][]trillian.Hash, error) {
+	sibs := make([][]storage.NodeID, len(keys))
+	// unique set of sibling nodes across all of the proofs, in request order.
+	seen := make(map[string]bool)
+	all := make([]storage.NodeID, 0)
+	for i, key := range keys {
+		kh := s.hasher.HashKey(key)
+		nid := storage.NewNodeIDFromHash(kh)
+		sibs[i] = nid.Siblings()
+		for _, sib := range sibs[i] {
+			if id := sib.String(); !seen[id] {
+				seen[id] = true
+				all = append(all, sib)
+			}
+		}
+	}
+
+	nodes, err := s.tx.GetMerkleNodes(ctx, rev, all)
 	if err != nil {
 		return nil, err
 	}
@@ -392,27 +421,33 @@ func (s SparseMerkleTreeReader) InclusionProof(rev int64, key trillian.Key) ([]t
 		nodeMap[n.NodeID.String()] = &n
 	}
 
-	// We're building a full proof from a combination of whichever nodes we got
+	// We're building full proofs from a combination of whichever nodes we got
 	// back from the storage layer, and the set of "null" hashes.
-	r := make([]trillian.Hash, len(sibs), len(sibs))
-	// For each proof element:
-	for i := 0; i < len(r); i++ {
-		proofID := sibs[i]
-		pNode := nodeMap[proofID.String()]
-		if pNode == nil {
-			// we have no node for this level from storage, so use the null hash:
-			r[i] = s.hasher.nullHashes[i]
-			continue
+	used := make(map[string]bool)
+	proofs := make([][]trillian.Hash, len(keys))
+	for k := range keys {
+		r := make([]trillian.Hash, len(sibs[k]), len(sibs[k]))
+		// For each proof element:
+		for i := 0; i < len(r); i++ {
+			proofID := sibs[k][i].String()
+			pNode := nodeMap[proofID]
+			if pNode == nil {
+				// we have no node for this level from storage, so use the null hash.
+				// The proof starts at the leaf but the null hashes start at the root.
+				r[i] = s.hasher.nullHashes[len(s.hasher.nullHashes)-1-i]
+				continue
+			}
+			r[i] = pNode.Hash
+			used[proofID] = true
 		}
-		r[i] = pNode.Hash
-		delete(nodeMap, proofID.String())
+		proofs[k] = r
 	}
 
 	// Make sure we used up all the returned nodes, otherwise something's gone wrong.
-	if remaining := len(nodeMap); remaining != 0 {
+	if remaining := len(nodeMap) - len(used); remaining != 0 {
 		return nil, fmt.Errorf("failed to consume all returned nodes; got %d nodes, but %d remain(s) unused", len(nodes), remaining)
 	}
-	return r, nil
+	return proofs, nil
 }
 
 // SetLeaves adds a batch of leaves to the in-flight tree update.
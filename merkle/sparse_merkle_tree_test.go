@@ -19,6 +19,7 @@ import (
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -64,7 +65,7 @@ func getSparseMerkleTreeReaderWithMockTX(ctrl *gomock.Controller, rev int64) (*S
 func getSparseMerkleTreeWriterWithMockTX(ctrl *gomock.Controller, rev int64) (*SparseMerkleTreeWriter, *storage.MockMapTX) {
 	tx := storage.NewMockMapTX(ctrl)
 	tx.EXPECT().WriteRevision().AnyTimes().Return(rev)
-	tree, err := NewSparseMerkleTreeWriter(rev, NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256())), newTX(tx))
+	tree, err := NewSparseMerkleTreeWriter(context.Background(), rev, NewMapHasher(NewRFC6962TreeHasher(trillian.NewSHA256())), newTX(tx))
 	if err != nil {
 		panic(err)
 	}
@@ -112,9 +113,8 @@ func getRandomRootNode(t *testing.T, rev int64) storage.Node {
 }
 
 func getRandomNonRootNode(t *testing.T, rev int64) storage.Node {
-	nodeID := storage.NewNodeIDFromHash(randomBytes(t, 32))
 	// Make sure it's not a root node.
-	nodeID.PrefixLenBits = int(1 + randomBytes(t, 1)[0]%254)
+	nodeID := storage.NewNodeIDFromHash(randomBytes(t, 32)).Prefix(int(1 + randomBytes(t, 1)[0]%254))
 	return storage.Node{
 		NodeID:       nodeID,
 		Hash:         randomBytes(t, 32),
@@ -123,14 +123,15 @@ func getRandomNonRootNode(t *testing.T, rev int64) storage.Node {
 }
 
 func TestRootAtRevision(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, 100)
 	node := getRandomRootNode(t, 14)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(23), rootNodeMatcher{}).Return([]storage.Node{node}, nil)
-	root, err := r.RootAtRevision(23)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(23), rootNodeMatcher{}).Return([]storage.Node{node}, nil)
+	root, err := r.RootAtRevision(ctx, 23)
 	if err != nil {
 		t.Fatalf("Failed when calling RootAtRevision(23): %v", err)
 	}
@@ -140,33 +141,36 @@ func TestRootAtRevision(t *testing.T) {
 }
 
 func TestRootAtUnknownRevision(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, 100)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(23), rootNodeMatcher{}).Return([]storage.Node{}, nil)
-	_, err := r.RootAtRevision(23)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(23), rootNodeMatcher{}).Return([]storage.Node{}, nil)
+	_, err := r.RootAtRevision(ctx, 23)
 	if err != ErrNoSuchRevision {
 		t.Fatalf("Attempt to retrieve root an non-existent revision did not result in ErrNoSuchRevision: %v", err)
 	}
 }
 
 func TestRootAtRevisionHasMultipleRoots(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, 100)
 	n1, n2 := getRandomRootNode(t, 14), getRandomRootNode(t, 15)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(23), rootNodeMatcher{}).Return([]storage.Node{n1, n2}, nil)
-	_, err := r.RootAtRevision(23)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(23), rootNodeMatcher{}).Return([]storage.Node{n1, n2}, nil)
+	_, err := r.RootAtRevision(ctx, 23)
 	if err == nil || err == ErrNoSuchRevision {
 		t.Fatalf("Attempt to retrieve root an non-existent revision did not result in error: %v", err)
 	}
 }
 
 func TestRootAtRevisionCatchesFutureRevision(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -176,14 +180,15 @@ func TestRootAtRevisionCatchesFutureRevision(t *testing.T) {
 	// returned by the storage layer.
 	n1 := getRandomRootNode(t, rev+1)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), rootNodeMatcher{}).Return([]storage.Node{n1}, nil)
-	_, err := r.RootAtRevision(rev)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), rootNodeMatcher{}).Return([]storage.Node{n1}, nil)
+	_, err := r.RootAtRevision(ctx, rev)
 	if err == nil || err == ErrNoSuchRevision {
 		t.Fatalf("Attempt to retrieve root with corrupt node did not result in error: %v", err)
 	}
 }
 
 func TestRootAtRevisionCatchesNonRootNode(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -192,23 +197,24 @@ func TestRootAtRevisionCatchesNonRootNode(t *testing.T) {
 	// Sanity checking in RootAtRevision should catch this node being incorrectly
 	// returned by the storage layer.
 	n1 := getRandomNonRootNode(t, rev)
-	tx.EXPECT().GetMerkleNodes(int64(rev), rootNodeMatcher{}).Return([]storage.Node{n1}, nil)
-	_, err := r.RootAtRevision(rev)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), rootNodeMatcher{}).Return([]storage.Node{n1}, nil)
+	_, err := r.RootAtRevision(ctx, rev)
 	if err == nil || err == ErrNoSuchRevision {
 		t.Fatalf("Attempt to retrieve root with corrupt node did not result in error: %v", err)
 	}
 }
 
 func TestInclusionProofForNullEntryInEmptyTree(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	const rev = 100
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).Return([]storage.Node{}, nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).Return([]storage.Node{}, nil)
 	const key = "SomeArbitraryKey"
-	proof, err := r.InclusionProof(rev, []byte(key))
+	proof, err := r.InclusionProof(ctx, rev, []byte(key))
 	if err != nil {
 		t.Fatalf("Got error while retrieving inclusion proof: %v", err)
 	}
@@ -218,29 +224,33 @@ func TestInclusionProofForNullEntryInEmptyTree(t *testing.T) {
 	}
 
 	treeHasher := NewRFC6962TreeHasher(trillian.NewSHA256())
-	// Verify these are null hashes
-	for i := len(proof) - 1; i > 0; i-- {
-		expectedParent := treeHasher.HashChildren(proof[i], proof[i])
-		if got := proof[i-1]; !bytes.Equal(expectedParent, got) {
+	// Verify these are null hashes, starting with the empty leaf
+	if expected, got := treeHasher.HashLeaf([]byte{}), proof[0]; !bytes.Equal(expected, got) {
+		t.Fatalf("Expected proof[0] to be the empty leaf hash %v, but got %v", expected, got)
+	}
+	for i := 1; i < len(proof); i++ {
+		expectedParent := treeHasher.HashChildren(proof[i-1], proof[i-1])
+		if got := proof[i]; !bytes.Equal(expectedParent, got) {
 			t.Fatalf("Expected proof[%d] to be %v, but got %v", i, expectedParent, got)
 		}
 	}
-	// And hashing the zeroth proof element with itself should give us the empty root hash
-	if expected, got := testonly.MustDecodeBase64(sparseEmptyRootHashB64), treeHasher.HashChildren(proof[0], proof[0]); !bytes.Equal(expected, got) {
-		t.Fatalf("Expected to generate sparseEmptyRootHash using proof[0], but got %v", got)
+	// And hashing the last proof element with itself should give us the empty root hash
+	if expected, got := testonly.MustDecodeBase64(sparseEmptyRootHashB64), treeHasher.HashChildren(proof[255], proof[255]); !bytes.Equal(expected, got) {
+		t.Fatalf("Expected to generate sparseEmptyRootHash using proof[255], but got %v", got)
 	}
 }
 
 func TestInclusionProofGetsIncorrectNode(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	const rev = 100
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).Return([]storage.Node{getRandomNonRootNode(t, 34)}, nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).Return([]storage.Node{getRandomNonRootNode(t, 34)}, nil)
 	const key = "SomeArbitraryKey"
-	_, err := r.InclusionProof(rev, []byte(key))
+	_, err := r.InclusionProof(ctx, rev, []byte(key))
 	if err == nil {
 		t.Fatal("InclusionProof() should've returned an error due to incorrect node from storage layer")
 	}
@@ -250,20 +260,22 @@ func TestInclusionProofGetsIncorrectNode(t *testing.T) {
 }
 
 func TestInclusionProofPassesThroughStorageError(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	const rev = 100
 	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
 	e := errors.New("Boo!")
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).Return([]storage.Node{}, e)
-	_, err := r.InclusionProof(rev, []byte("Whatever"))
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).Return([]storage.Node{}, e)
+	_, err := r.InclusionProof(ctx, rev, []byte("Whatever"))
 	if err != e {
 		t.Fatal("InclusionProof() should've returned an error '%v', but got '%v'", e, err)
 	}
 }
 
 func TestInclusionProofGetsTooManyNodes(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -275,16 +287,14 @@ func TestInclusionProofGetsTooManyNodes(t *testing.T) {
 	nodes := make([]storage.Node, 257, 257)
 	// First build a plausible looking set of proof nodes.
 	for i := 1; i < 256; i++ {
-		nodes[255-i].NodeID = storage.NewNodeIDFromHash(keyHash)
-		nodes[255-i].NodeID.PrefixLenBits = i + 1
-		nodes[255-i].NodeID.SetBit(i, nodes[255-i].NodeID.Bit(i)^1)
+		nodes[255-i].NodeID = storage.NewNodeIDFromHash(keyHash).Prefix(i + 1).Sibling()
 	}
 	// and then tack on some rubbish:
 	nodes[256] = getRandomNonRootNode(t, 42)
 
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).AnyTimes().Return(nodes, nil)
-	_, err := r.InclusionProof(rev, []byte(key))
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).AnyTimes().Return(nodes, nil)
+	_, err := r.InclusionProof(ctx, rev, []byte(key))
 	if err == nil {
 		t.Fatal("InclusionProof() should've returned an error due to extra unused node")
 	}
@@ -293,6 +303,120 @@ func TestInclusionProofGetsTooManyNodes(t *testing.T) {
 	}
 }
 
+type nodeCountMatcher struct {
+	count int
+}
+
+func (n nodeCountMatcher) Matches(x interface{}) bool {
+	nodes, ok := x.([]storage.NodeID)
+	return ok && len(nodes) == n.count
+}
+
+func (n nodeCountMatcher) String() string {
+	return fmt.Sprintf("has %d nodes", n.count)
+}
+
+func TestBatchInclusionProofReadsSharedNodesOnce(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const rev = 100
+	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
+
+	// Find two keys whose hashes agree on the first bit, so they share the
+	// sibling at the top of the tree.
+	keys := []trillian.Key{[]byte("key0")}
+	firstID := storage.NewNodeIDFromHash(r.hasher.HashKey(keys[0]))
+	for i := 1; len(keys) < 2; i++ {
+		k := []byte(fmt.Sprintf("key%d", i))
+		if id := storage.NewNodeIDFromHash(r.hasher.HashKey(k)); id.Bit(255) == firstID.Bit(255) {
+			keys = append(keys, k)
+		}
+	}
+	shared := storage.Node{
+		NodeID:       firstID.Siblings()[255],
+		Hash:         randomBytes(t, 32),
+		NodeRevision: rev,
+	}
+
+	// Each distinct sibling should be requested exactly once.
+	distinct := make(map[string]bool)
+	for _, k := range keys {
+		id := storage.NewNodeIDFromHash(r.hasher.HashKey(k))
+		for _, sib := range id.Siblings() {
+			distinct[sib.String()] = true
+		}
+	}
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), nodeCountMatcher{len(distinct)}).Times(1).Return([]storage.Node{shared}, nil)
+	proofs, err := r.BatchInclusionProof(ctx, rev, keys)
+	if err != nil {
+		t.Fatalf("BatchInclusionProof(): %v", err)
+	}
+	if expected, got := len(keys), len(proofs); expected != got {
+		t.Fatalf("Expected %d proofs, but got %d", expected, got)
+	}
+	for i, proof := range proofs {
+		if expected, got := 256, len(proof); expected != got {
+			t.Fatalf("Expected proof %d of len %d, but got len %d", i, expected, got)
+		}
+		if !bytes.Equal(shared.Hash, proof[255]) {
+			t.Errorf("Expected proof %d to end with shared node hash %v, but got %v", i, shared.Hash, proof[255])
+		}
+		for j := 0; j < 255; j++ {
+			if !bytes.Equal(r.hasher.nullHashes[255-j], proof[j]) {
+				t.Errorf("Expected proof %d element %d to be the null hash", i, j)
+			}
+		}
+	}
+}
+
+func TestBatchInclusionProofMatchesInclusionProof(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const rev = 100
+	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).AnyTimes().Return([]storage.Node{}, nil)
+	keys := []trillian.Key{[]byte("one"), []byte("two"), []byte("three")}
+	proofs, err := r.BatchInclusionProof(ctx, rev, keys)
+	if err != nil {
+		t.Fatalf("BatchInclusionProof(): %v", err)
+	}
+	for i, key := range keys {
+		proof, err := r.InclusionProof(ctx, rev, key)
+		if err != nil {
+			t.Fatalf("InclusionProof(%s): %v", key, err)
+		}
+		if expected, got := len(proof), len(proofs[i]); expected != got {
+			t.Fatalf("Expected batch proof for %s of len %d, but got len %d", key, expected, got)
+		}
+		for j := range proof {
+			if !bytes.Equal(proof[j], proofs[i][j]) {
+				t.Fatalf("Batch proof for %s differs at element %d", key, j)
+			}
+		}
+	}
+}
+
+func TestBatchInclusionProofGetsIncorrectNode(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const rev = 100
+	r, tx := getSparseMerkleTreeReaderWithMockTX(mockCtrl, rev)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).Return([]storage.Node{getRandomNonRootNode(t, 34)}, nil)
+	_, err := r.BatchInclusionProof(ctx, rev, []trillian.Key{[]byte("one"), []byte("two")})
+	if err == nil {
+		t.Fatal("BatchInclusionProof() should've returned an error due to incorrect node from storage layer")
+	}
+	if !strings.Contains(err.Error(), "1 remain(s) unused") {
+		t.Fatalf("Saw unexpected error: %v", err)
+	}
+}
+
 type sparseKeyValue struct {
 	k, v string
 }
@@ -310,8 +434,8 @@ func testSparseTreeCalculatedRoot(t *testing.T, vec sparseTestVector) {
 	w, tx := getSparseMerkleTreeWriterWithMockTX(mockCtrl, rev)
 
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).AnyTimes().Return([]storage.Node{}, nil)
-	tx.EXPECT().SetMerkleNodes(gomock.Any()).AnyTimes().Return(nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).AnyTimes().Return([]storage.Node{}, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
 
 	testSparseTreeCalculatedRootWithWriter(t, rev, vec, w)
 }
@@ -413,7 +537,7 @@ func testSparseTreeFetches(t *testing.T, vec sparseTestVector) {
 
 	// Now, set up a mock call for GetMerkleNodes for the nodeIDs in the map
 	// we've just created:
-	tx.EXPECT().GetMerkleNodes(int64(rev), nodeIDFuncMatcher{func(ids []storage.NodeID) bool {
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), nodeIDFuncMatcher{func(ids []storage.NodeID) bool {
 		if len(ids) == 0 {
 			return false
 		}
@@ -429,8 +553,8 @@ func testSparseTreeFetches(t *testing.T, vec sparseTestVector) {
 	// it'll panic() with an unhelpful message on the first unexpected nodeID, so
 	// rather than doing that we'll make a note of all the unexpected IDs here
 	// instead, and we can then print them out later on.
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).AnyTimes().Do(
-		func(rev int64, a []storage.NodeID) {
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).AnyTimes().Do(
+		func(_ context.Context, rev int64, a []storage.NodeID) {
 			if a == nil {
 				return
 			}
@@ -458,8 +582,8 @@ func testSparseTreeFetches(t *testing.T, vec sparseTestVector) {
 		writeMutex.Unlock()
 	}
 
-	tx.EXPECT().SetMerkleNodes(gomock.Any()).AnyTimes().Do(
-		func(a []storage.Node) {
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Do(
+		func(_ context.Context, a []storage.Node) {
 			writeMutex.Lock()
 			defer writeMutex.Unlock()
 			if a == nil {
@@ -541,8 +665,8 @@ func DISABLEDTestSparseMerkleTreeWriterBigBatch(t *testing.T) {
 	w, tx := getSparseMerkleTreeWriterWithMockTX(mockCtrl, rev)
 
 	tx.EXPECT().Commit().AnyTimes().Return(nil)
-	tx.EXPECT().GetMerkleNodes(int64(rev), gomock.Any()).Return([]storage.Node{}, nil)
-	tx.EXPECT().SetMerkleNodes(gomock.Any()).Return(nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(rev), gomock.Any()).Return([]storage.Node{}, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).Return(nil)
 
 	const batchSize = 1024
 	const numBatches = 4
@@ -0,0 +1,71 @@
+package merkle
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/trillian"
+)
+
+// NewTreeHasherFunc creates the TreeHasher used by trees configured with a hash algorithm.
+type NewTreeHasherFunc func() (TreeHasher, error)
+
+var treeHashersMutex sync.RWMutex
+var treeHashers = make(map[trillian.HashAlgorithm]NewTreeHasherFunc)
+
+func init() {
+	for _, alg := range []trillian.HashAlgorithm{
+		trillian.HashAlgorithm_SHA256,
+		trillian.HashAlgorithm_SHA512_256,
+		trillian.HashAlgorithm_BLAKE2B_256,
+	} {
+		if err := RegisterTreeHasher(alg, newRFC6962TreeHasherFunc(alg)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// newRFC6962TreeHasherFunc returns a NewTreeHasherFunc building RFC6962 style tree hashers
+// on top of alg.
+func newRFC6962TreeHasherFunc(alg trillian.HashAlgorithm) NewTreeHasherFunc {
+	return func() (TreeHasher, error) {
+		hasher, err := trillian.NewHasher(alg)
+		if err != nil {
+			return TreeHasher{}, err
+		}
+
+		return NewRFC6962TreeHasher(hasher), nil
+	}
+}
+
+// RegisterTreeHasher makes a TreeHasher implementation available for trees configured with
+// alg. It returns an error if an implementation has already been registered for alg.
+func RegisterTreeHasher(alg trillian.HashAlgorithm, f NewTreeHasherFunc) error {
+	if f == nil {
+		return fmt.Errorf("merkle: nil NewTreeHasherFunc for hash algorithm %v", alg)
+	}
+
+	treeHashersMutex.Lock()
+	defer treeHashersMutex.Unlock()
+
+	if _, exists := treeHashers[alg]; exists {
+		return fmt.Errorf("merkle: tree hasher for hash algorithm %v already registered", alg)
+	}
+
+	treeHashers[alg] = f
+	return nil
+}
+
+// NewTreeHasher creates a TreeHasher for trees configured with alg using the implementation
+// registered for it.
+func NewTreeHasher(alg trillian.HashAlgorithm) (TreeHasher, error) {
+	treeHashersMutex.RLock()
+	f, ok := treeHashers[alg]
+	treeHashersMutex.RUnlock()
+
+	if !ok {
+		return TreeHasher{}, fmt.Errorf("merkle: no tree hasher registered for hash algorithm %v", alg)
+	}
+
+	return f()
+}
@@ -0,0 +1,59 @@
+package merkle
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+)
+
+func TestRegisteredTreeHashers(t *testing.T) {
+	var tests = []struct {
+		alg            trillian.HashAlgorithm
+		emptyHashHex   string
+		leafL123456Hex string
+	}{
+		{trillian.HashAlgorithm_SHA256, rfc6962EmptyHashHex, rfc6962LeafL123456HashHex},
+		// As above using openssl dgst -sha512-256
+		{trillian.HashAlgorithm_SHA512_256, "c672b8d1ef56ed28ab87c3622c5114069bdd3ad7b8f9737498d0c01ecef0967a",
+			"ddc60d56df2a66360865a5cd33971e54bfb0152be673d3d5dbdacc723bd2f707"},
+		// As above using b2sum -l 256
+		{trillian.HashAlgorithm_BLAKE2B_256, "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8",
+			"76ad9a1dbf9de24cf6eb6caa7367663fd059b30b158516221ac5a9dae37d3a93"},
+	}
+
+	for _, test := range tests {
+		hasher, err := NewTreeHasher(test.alg)
+		if err != nil {
+			t.Fatalf("Failed to create tree hasher for %v: %v", test.alg, err)
+		}
+
+		if got, want := hasher.HashAlgorithm(), test.alg; got != want {
+			t.Errorf("Tree hasher for %v uses %v", want, got)
+		}
+		if got, want := hasher.Size(), 32; got != want {
+			t.Errorf("Tree hasher for %v has size %d, want %d", test.alg, got, want)
+		}
+
+		ensureHashMatches(testonly.MustHexDecode(test.emptyHashHex), hasher.HashEmpty(), test.alg.String()+" Empty", t)
+		ensureHashMatches(testonly.MustHexDecode(test.leafL123456Hex), hasher.HashLeaf([]byte("L123456")), test.alg.String()+" Leaf", t)
+	}
+}
+
+func TestNewTreeHasherUnknownAlgorithm(t *testing.T) {
+	if _, err := NewTreeHasher(trillian.HashAlgorithm(50)); err == nil {
+		t.Fatal("Created a tree hasher for an unknown hash algorithm")
+	}
+}
+
+func TestRegisterTreeHasherTwice(t *testing.T) {
+	if err := RegisterTreeHasher(trillian.HashAlgorithm_SHA256, newRFC6962TreeHasherFunc(trillian.HashAlgorithm_SHA256)); err == nil {
+		t.Fatal("Registered a second tree hasher for SHA256")
+	}
+}
+
+func TestRegisterNilTreeHasher(t *testing.T) {
+	if err := RegisterTreeHasher(trillian.HashAlgorithm(51), nil); err == nil {
+		t.Fatal("Registered a nil NewTreeHasherFunc")
+	}
+}
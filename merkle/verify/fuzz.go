@@ -0,0 +1,259 @@
+//go:build gofuzz
+// +build gofuzz
+
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/binary"
+)
+
+// Harnesses for go-fuzz (github.com/dvyukov/go-fuzz). Each one decodes its input into the
+// arguments of a verification function and checks it against a small tree whose proofs are
+// known, panicking if anything other than the correct proof is accepted. Build and run one
+// with:
+//
+//   go-fuzz-build -func FuzzInclusionProof github.com/google/trillian/merkle/verify
+//   go-fuzz -bin verify-fuzz.zip -workdir /tmp/fuzz
+//
+// The return values follow the go-fuzz convention, 1 if the input decoded and was
+// interesting and 0 otherwise.
+
+// fuzzTreeSize is the number of leaves in the tree the harnesses check proofs against
+const fuzzTreeSize = 8
+
+var fuzzHasher = NewRFC6962Hasher(crypto.SHA256)
+
+// fuzzLeaves returns the leaves of the tree the harnesses check proofs against
+func fuzzLeaves() [][]byte {
+	var leaves [][]byte
+
+	for i := 0; i < fuzzTreeSize; i++ {
+		leaves = append(leaves, []byte{byte(i)})
+	}
+
+	return leaves
+}
+
+// fuzzRoot returns the root of leaves, as defined in RFC 6962 section 2.1
+func fuzzRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return fuzzHasher.HashLeaf(leaves[0])
+	}
+
+	k := 1
+	for k<<1 < len(leaves) {
+		k <<= 1
+	}
+
+	return fuzzHasher.HashChildren(fuzzRoot(leaves[:k]), fuzzRoot(leaves[k:]))
+}
+
+// fuzzPath returns the inclusion proof for leaf m in leaves, as defined in RFC 6962
+// section 2.1.1
+func fuzzPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+
+	k := 1
+	for k<<1 < len(leaves) {
+		k <<= 1
+	}
+
+	if m < k {
+		return append(fuzzPath(m, leaves[:k]), fuzzRoot(leaves[k:]))
+	}
+
+	return append(fuzzPath(m-k, leaves[k:]), fuzzRoot(leaves[:k]))
+}
+
+// fuzzSubProof returns the consistency proof between the first m leaves and all of leaves,
+// as defined in RFC 6962 section 2.1.2
+func fuzzSubProof(m int, leaves [][]byte, complete bool) [][]byte {
+	if m == len(leaves) {
+		if complete {
+			return nil
+		}
+
+		return [][]byte{fuzzRoot(leaves)}
+	}
+
+	k := 1
+	for k<<1 < len(leaves) {
+		k <<= 1
+	}
+
+	if m <= k {
+		return append(fuzzSubProof(m, leaves[:k], complete), fuzzRoot(leaves[k:]))
+	}
+
+	return append(fuzzSubProof(m-k, leaves[k:], false), fuzzRoot(leaves[:k]))
+}
+
+// decodeFuzzInts reads n varints from the front of data, returning them and the rest of
+// data. ok is false if data doesn't start with n varints.
+func decodeFuzzInts(data []byte, n int) (ints []int64, rest []byte, ok bool) {
+	for i := 0; i < n; i++ {
+		v, read := binary.Varint(data)
+
+		if read <= 0 {
+			return nil, nil, false
+		}
+
+		ints = append(ints, v)
+		data = data[read:]
+	}
+
+	return ints, data, true
+}
+
+// decodeFuzzProof splits data into proof nodes of the hash size. A short last node is kept
+// so that malformed nodes are tried too.
+func decodeFuzzProof(data []byte) [][]byte {
+	var proof [][]byte
+
+	for len(data) > 0 {
+		n := fuzzHasher.Size()
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		proof = append(proof, data[:n])
+		data = data[n:]
+	}
+
+	return proof
+}
+
+func equalProofs(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FuzzInclusionProof decodes a leaf index, a tree size and proof nodes and checks them
+// against the root of the fuzz tree at that size
+func FuzzInclusionProof(data []byte) int {
+	ints, rest, ok := decodeFuzzInts(data, 2)
+
+	if !ok {
+		return 0
+	}
+
+	leafIndex, treeSize := ints[0], ints[1]
+	proof := decodeFuzzProof(rest)
+	leaves := fuzzLeaves()
+
+	if treeSize < 1 || treeSize > fuzzTreeSize {
+		// There's no root to check against, but the verifier mustn't accept or panic on
+		// proofs for leaves outside the tree
+		if leafIndex < 0 || leafIndex >= treeSize {
+			if InclusionProof(fuzzHasher, leafIndex, treeSize, proof, nil, nil) == nil {
+				panic("accepted proof for a leaf outside the tree")
+			}
+		}
+
+		return 0
+	}
+
+	root := fuzzRoot(leaves[:treeSize])
+	leafHash := fuzzHasher.HashLeaf([]byte{byte(leafIndex)})
+	err := InclusionProof(fuzzHasher, leafIndex, treeSize, proof, root, leafHash)
+
+	if leafIndex < 0 || leafIndex >= treeSize {
+		if err == nil {
+			panic("accepted proof for a leaf outside the tree")
+		}
+
+		return 0
+	}
+
+	if correct := equalProofs(proof, fuzzPath(int(leafIndex), leaves[:treeSize])); correct != (err == nil) {
+		panic("inclusion proof verification disagrees with the reference implementation")
+	}
+
+	return 1
+}
+
+// FuzzConsistencyProof decodes two tree sizes and proof nodes and checks them against the
+// roots of the fuzz tree at those sizes
+func FuzzConsistencyProof(data []byte) int {
+	ints, rest, ok := decodeFuzzInts(data, 2)
+
+	if !ok {
+		return 0
+	}
+
+	size1, size2 := ints[0], ints[1]
+	proof := decodeFuzzProof(rest)
+	leaves := fuzzLeaves()
+
+	if size1 < 1 || size2 < size1 || size2 > fuzzTreeSize {
+		// Sizes outside the fuzz tree have no roots to check against, the verifier just
+		// mustn't panic
+		ConsistencyProof(fuzzHasher, size1, size2, nil, nil, proof)
+
+		return 0
+	}
+
+	root1, root2 := fuzzRoot(leaves[:size1]), fuzzRoot(leaves[:size2])
+	err := ConsistencyProof(fuzzHasher, size1, size2, root1, root2, proof)
+
+	if correct := equalProofs(proof, fuzzSubProof(int(size1), leaves[:size2], true)); correct != (err == nil) {
+		panic("consistency proof verification disagrees with the reference implementation")
+	}
+
+	return 1
+}
+
+// FuzzMapInclusionProof decodes a key, a value and proof nodes and checks them against the
+// root of an empty map, where the only proof that verifies is the absence of the key with
+// every node the hash of an empty subtree
+func FuzzMapInclusionProof(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+
+	// The first two bytes are the lengths of the key and value
+	keyEnd := 2 + int(data[0])
+	valueEnd := keyEnd + int(data[1])
+
+	if valueEnd > len(data) {
+		return 0
+	}
+
+	key := data[2:keyEnd]
+	value := data[keyEnd:valueEnd]
+	proof := decodeFuzzProof(data[valueEnd:])
+
+	if len(value) == 0 {
+		value = nil
+	}
+
+	empty := [][]byte{fuzzHasher.HashLeaf([]byte{})}
+
+	for i := 1; i < fuzzHasher.Size()*8; i++ {
+		empty = append(empty, fuzzHasher.HashChildren(empty[i-1], empty[i-1]))
+	}
+
+	root := fuzzHasher.HashChildren(empty[len(empty)-1], empty[len(empty)-1])
+	err := MapInclusionProof(fuzzHasher, root, key, value, proof)
+
+	if correct := value == nil && equalProofs(proof, empty); correct != (err == nil) {
+		panic("map inclusion proof verification disagrees with the empty map")
+	}
+
+	return 1
+}
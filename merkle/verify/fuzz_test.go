@@ -0,0 +1,65 @@
+//go:build gofuzz
+// +build gofuzz
+
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// These tests check the fuzz harnesses themselves, run them with go test -tags gofuzz
+
+func fuzzInput(ints []int64, proof [][]byte) []byte {
+	var data []byte
+
+	for _, v := range ints {
+		buf := make([]byte, binary.MaxVarintLen64)
+		data = append(data, buf[:binary.PutVarint(buf, v)]...)
+	}
+
+	return append(data, bytes.Join(proof, nil)...)
+}
+
+func TestFuzzHarnessesAcceptCorrectProofs(t *testing.T) {
+	leaves := fuzzLeaves()
+
+	for size := 1; size <= fuzzTreeSize; size++ {
+		for index := 0; index < size; index++ {
+			if FuzzInclusionProof(fuzzInput([]int64{int64(index), int64(size)}, fuzzPath(index, leaves[:size]))) != 1 {
+				t.Errorf("Inclusion harness rejected the input for leaf %d in tree size %d", index, size)
+			}
+		}
+
+		for size1 := 1; size1 <= size; size1++ {
+			if FuzzConsistencyProof(fuzzInput([]int64{int64(size1), int64(size)}, fuzzSubProof(size1, leaves[:size], true))) != 1 {
+				t.Errorf("Consistency harness rejected the input for sizes %d and %d", size1, size)
+			}
+		}
+	}
+
+	empty := [][]byte{fuzzHasher.HashLeaf([]byte{})}
+
+	for i := 1; i < fuzzHasher.Size()*8; i++ {
+		empty = append(empty, fuzzHasher.HashChildren(empty[i-1], empty[i-1]))
+	}
+
+	if FuzzMapInclusionProof(append([]byte{3, 0, 'k', 'e', 'y'}, bytes.Join(empty, nil)...)) != 1 {
+		t.Error("Map harness rejected the absence proof for the empty map")
+	}
+}
+
+func TestFuzzHarnessesDontPanicOnRandomInput(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		data := make([]byte, r.Intn(200))
+		r.Read(data)
+
+		FuzzInclusionProof(data)
+		FuzzConsistencyProof(data)
+		FuzzMapInclusionProof(data)
+	}
+}
@@ -0,0 +1,75 @@
+// Package verify checks the proofs and signed roots returned by Trillian servers. It only
+// depends on the standard library and small crypto packages, not on storage, gRPC or the
+// generated protos, so verifiers that can't carry the rest of Trillian can import it alone.
+// The other Trillian packages use it for their own checks so the results always match.
+package verify
+
+import (
+	"crypto"
+)
+
+// Domain separation prefixes from RFC 6962 section 2.1
+const (
+	rfc6962LeafHashPrefix = 0
+	rfc6962NodeHashPrefix = 1
+)
+
+// Hasher computes the hashes of a Merkle tree
+type Hasher interface {
+	// HashLeaf returns the hash of a leaf holding data
+	HashLeaf(data []byte) []byte
+	// HashChildren returns the hash of the node whose children have hashes l and r
+	HashChildren(l, r []byte) []byte
+	// Size returns the length of the hashes in bytes
+	Size() int
+}
+
+// MapHasher computes the hashes of a sparse Merkle tree
+type MapHasher interface {
+	Hasher
+	// HashKey returns the path to the leaf for key
+	HashKey(key []byte) []byte
+}
+
+// RFC6962Hasher hashes trees as described in RFC 6962, keys of sparse trees are hashed
+// with the same hash function and no prefix.
+type RFC6962Hasher struct {
+	hash crypto.Hash
+}
+
+// NewRFC6962Hasher creates an RFC6962Hasher that uses hash, which must be linked into the
+// binary
+func NewRFC6962Hasher(hash crypto.Hash) RFC6962Hasher {
+	return RFC6962Hasher{hash: hash}
+}
+
+func (r RFC6962Hasher) digest(prefix []byte, data ...[]byte) []byte {
+	h := r.hash.New()
+	h.Write(prefix)
+
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// HashLeaf implements Hasher
+func (r RFC6962Hasher) HashLeaf(data []byte) []byte {
+	return r.digest([]byte{rfc6962LeafHashPrefix}, data)
+}
+
+// HashChildren implements Hasher
+func (r RFC6962Hasher) HashChildren(lhs, rhs []byte) []byte {
+	return r.digest([]byte{rfc6962NodeHashPrefix}, lhs, rhs)
+}
+
+// HashKey implements MapHasher
+func (r RFC6962Hasher) HashKey(key []byte) []byte {
+	return r.digest(nil, key)
+}
+
+// Size implements Hasher
+func (r RFC6962Hasher) Size() int {
+	return r.hash.Size()
+}
@@ -0,0 +1,45 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", s, err)
+	}
+
+	return b
+}
+
+func TestRFC6962Hasher(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+
+	if got, want := h.Size(), 32; got != want {
+		t.Errorf("Got hash size %d, expected %d", got, want)
+	}
+
+	tests := []struct {
+		desc string
+		got  []byte
+		want string
+	}{
+		// Hash of an empty leaf, the same as the root of a tree holding one empty leaf in CT
+		{"empty leaf", h.HashLeaf([]byte{}), "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"},
+		{"leaf", h.HashLeaf([]byte("L123456")), "395aa064aa4c29f7010acfe3f25db9485bbd4b91897b6ad7ad547639252b4d56"},
+		{"children", h.HashChildren([]byte("N123"), []byte("N456")), "aa217fe888e47007fa15edab33c2b492a722cb106c64667fc2b044444de66bbb"},
+		{"key", h.HashKey([]byte("key")), "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683"},
+	}
+
+	for _, test := range tests {
+		if want := mustDecodeHex(t, test.want); !bytes.Equal(test.got, want) {
+			t.Errorf("%s: got hash %x, expected %x", test.desc, test.got, want)
+		}
+	}
+}
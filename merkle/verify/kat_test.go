@@ -0,0 +1,155 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+// Known answer tests using the reference tree from the C++ certificate transparency code,
+// cpp/merkletree/merkletree_test.cc in the main certificate transparency repo. Any verifier
+// that gets these right will interoperate with CT logs and with the other implementations
+// that use the same vectors.
+
+// katLeafInputs are the leaves of the reference tree, which has eight leaves
+var katLeafInputs = []string{"", "00", "10", "2021", "3031", "40414243",
+	"5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+// katRoots are the roots of the reference tree at sizes 1 to 8
+var katRoots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328"}
+
+// katInclusionProofs are from ReferenceMerklePath in the C++ code. Leaves are numbered from
+// 0 here, the C++ code numbers them from 1.
+var katInclusionProofs = []struct {
+	leafIndex int64
+	treeSize  int64
+	proof     []string
+}{
+	{0, 1, nil},
+	{0, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4"}},
+	{5, 8, []string{
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7"}},
+	{2, 3, []string{
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125"}},
+	{1, 5, []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b"}},
+}
+
+// katConsistencyProofs are from ReferenceSnapshotConsistency in the C++ code
+var katConsistencyProofs = []struct {
+	size1, size2 int64
+	proof        []string
+}{
+	{1, 1, nil},
+	{1, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4"}},
+	{6, 8, []string{
+		"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7"}},
+	{2, 5, []string{
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b"}},
+}
+
+func decodeHexProof(t *testing.T, nodes []string) [][]byte {
+	var proof [][]byte
+
+	for _, n := range nodes {
+		proof = append(proof, mustDecodeHex(t, n))
+	}
+
+	return proof
+}
+
+func katLeaves(t *testing.T) [][]byte {
+	var leaves [][]byte
+
+	for _, l := range katLeafInputs {
+		leaves = append(leaves, mustDecodeHex(t, l))
+	}
+
+	return leaves
+}
+
+func TestKATRoots(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	leaves := katLeaves(t)
+
+	for i, want := range katRoots {
+		if got := referenceRoot(h, leaves[:i+1]); !bytes.Equal(got, mustDecodeHex(t, want)) {
+			t.Errorf("Got root %x for tree size %d, expected %s", got, i+1, want)
+		}
+	}
+}
+
+func TestKATInclusionProofs(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	leaves := katLeaves(t)
+
+	for _, test := range katInclusionProofs {
+		root := mustDecodeHex(t, katRoots[test.treeSize-1])
+		leafHash := h.HashLeaf(leaves[test.leafIndex])
+		proof := decodeHexProof(t, test.proof)
+
+		if err := InclusionProof(h, test.leafIndex, test.treeSize, proof, root, leafHash); err != nil {
+			t.Errorf("Failed to verify inclusion of leaf %d in tree size %d: %v", test.leafIndex, test.treeSize, err)
+		}
+
+		// Changing any single node, or the leaf, must make the proof fail
+		for i := range proof {
+			corrupt := append([][]byte{}, proof...)
+			corrupt[i] = append([]byte{}, proof[i]...)
+			corrupt[i][0] ^= 1
+
+			if err := InclusionProof(h, test.leafIndex, test.treeSize, corrupt, root, leafHash); err == nil {
+				t.Errorf("Verified inclusion of leaf %d in tree size %d with node %d corrupted", test.leafIndex, test.treeSize, i)
+			}
+		}
+
+		if err := InclusionProof(h, test.leafIndex, test.treeSize, proof, root, h.HashLeaf([]byte("other"))); err == nil {
+			t.Errorf("Verified inclusion of the wrong leaf at %d in tree size %d", test.leafIndex, test.treeSize)
+		}
+	}
+}
+
+func TestKATConsistencyProofs(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+
+	for _, test := range katConsistencyProofs {
+		root1 := mustDecodeHex(t, katRoots[test.size1-1])
+		root2 := mustDecodeHex(t, katRoots[test.size2-1])
+		proof := decodeHexProof(t, test.proof)
+
+		if err := ConsistencyProof(h, test.size1, test.size2, root1, root2, proof); err != nil {
+			t.Errorf("Failed to verify consistency between sizes %d and %d: %v", test.size1, test.size2, err)
+		}
+
+		for i := range proof {
+			corrupt := append([][]byte{}, proof...)
+			corrupt[i] = append([]byte{}, proof[i]...)
+			corrupt[i][0] ^= 1
+
+			if err := ConsistencyProof(h, test.size1, test.size2, root1, root2, corrupt); err == nil {
+				t.Errorf("Verified consistency between sizes %d and %d with node %d corrupted", test.size1, test.size2, i)
+			}
+		}
+	}
+}
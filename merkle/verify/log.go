@@ -0,0 +1,158 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RootMismatchError is returned when a proof doesn't lead to the expected root
+type RootMismatchError struct {
+	ExpectedRoot []byte
+	ComputedRoot []byte
+}
+
+func (r RootMismatchError) Error() string {
+	return fmt.Sprintf("root hash mismatch got: %v expected: %v", r.ComputedRoot, r.ExpectedRoot)
+}
+
+// InclusionProof checks that proof shows the leaf with leafHash is at leafIndex in the tree
+// with root at treeSize. The proof nodes must be in the order given by RFC 6962 section
+// 2.1.1, starting with the sibling of the leaf.
+func InclusionProof(h Hasher, leafIndex, treeSize int64, proof [][]byte, root []byte, leafHash []byte) error {
+	if leafIndex < 0 || leafIndex >= treeSize {
+		return fmt.Errorf("leaf index %d is outside a tree of size %d", leafIndex, treeSize)
+	}
+
+	node := leafIndex
+	lastNode := treeSize - 1
+	hash := leafHash
+	next := 0
+
+	for lastNode > 0 {
+		if node&1 == 1 {
+			if next == len(proof) {
+				return fmt.Errorf("proof for leaf %d in tree size %d is too short", leafIndex, treeSize)
+			}
+
+			hash = h.HashChildren(proof[next], hash)
+			next++
+		} else if node < lastNode {
+			if next == len(proof) {
+				return fmt.Errorf("proof for leaf %d in tree size %d is too short", leafIndex, treeSize)
+			}
+
+			hash = h.HashChildren(hash, proof[next])
+			next++
+		}
+
+		// Otherwise node is the last node on its level and has no sibling, it moves up as it is
+
+		node >>= 1
+		lastNode >>= 1
+	}
+
+	if next != len(proof) {
+		return fmt.Errorf("proof for leaf %d in tree size %d has %d unused nodes", leafIndex, treeSize, len(proof)-next)
+	}
+
+	if !bytes.Equal(hash, root) {
+		return RootMismatchError{ExpectedRoot: root, ComputedRoot: hash}
+	}
+
+	return nil
+}
+
+// ConsistencyProof checks that proof shows the tree with root2 at snapshot2 is an append
+// only extension of the tree with root1 at snapshot1. The proof nodes must be in the order
+// given by RFC 6962 section 2.1.2.
+func ConsistencyProof(h Hasher, snapshot1, snapshot2 int64, root1, root2 []byte, proof [][]byte) error {
+	switch {
+	case snapshot1 < 0 || snapshot2 < 0:
+		return fmt.Errorf("tree sizes must be >= 0 but were %d and %d", snapshot1, snapshot2)
+	case snapshot2 < snapshot1:
+		return fmt.Errorf("second tree size (%d) must be >= first tree size (%d)", snapshot2, snapshot1)
+	case snapshot1 == snapshot2:
+		if !bytes.Equal(root1, root2) {
+			return RootMismatchError{ExpectedRoot: root1, ComputedRoot: root2}
+		}
+
+		if len(proof) > 0 {
+			return fmt.Errorf("expected empty proof for equal tree sizes but got %d nodes", len(proof))
+		}
+
+		return nil
+	case snapshot1 == 0:
+		// Any tree is consistent with the empty tree
+		if len(proof) > 0 {
+			return fmt.Errorf("expected empty proof from an empty tree but got %d nodes", len(proof))
+		}
+
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("empty proof for tree sizes %d and %d", snapshot1, snapshot2)
+	}
+
+	node := snapshot1 - 1
+	lastNode := snapshot2 - 1
+
+	// Everything to the left of node is the same in both trees, skip until node is a left
+	// child or the root of the first tree
+	for node&1 == 1 {
+		node >>= 1
+		lastNode >>= 1
+	}
+
+	// If node is the root of the first tree it isn't included in the proof
+	hash1, hash2 := root1, root1
+	next := 0
+
+	if node > 0 {
+		hash1, hash2 = proof[0], proof[0]
+		next = 1
+	}
+
+	for node > 0 {
+		if next == len(proof) {
+			return fmt.Errorf("proof for tree sizes %d and %d is too short", snapshot1, snapshot2)
+		}
+
+		if node&1 == 1 {
+			// The proof node is to our left so it's in both trees
+			hash1 = h.HashChildren(proof[next], hash1)
+			hash2 = h.HashChildren(proof[next], hash2)
+			next++
+		} else if node < lastNode {
+			// The proof node is to our right so it's only in the second tree
+			hash2 = h.HashChildren(hash2, proof[next])
+			next++
+		}
+
+		node >>= 1
+		lastNode >>= 1
+	}
+
+	if !bytes.Equal(hash1, root1) {
+		return RootMismatchError{ExpectedRoot: root1, ComputedRoot: hash1}
+	}
+
+	// The rest of the proof is the path from here to the root of the second tree
+	for lastNode > 0 {
+		if next == len(proof) {
+			return fmt.Errorf("proof for tree sizes %d and %d is too short", snapshot1, snapshot2)
+		}
+
+		hash2 = h.HashChildren(hash2, proof[next])
+		next++
+		lastNode >>= 1
+	}
+
+	if !bytes.Equal(hash2, root2) {
+		return RootMismatchError{ExpectedRoot: root2, ComputedRoot: hash2}
+	}
+
+	if next != len(proof) {
+		return fmt.Errorf("proof for tree sizes %d and %d has %d unused nodes", snapshot1, snapshot2, len(proof)-next)
+	}
+
+	return nil
+}
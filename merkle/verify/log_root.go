@@ -0,0 +1,151 @@
+package verify
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// LogRootV1Version is the version that starts a serialized LogRootV1.
+const LogRootV1Version uint16 = 1
+
+// LogRootV1 holds the fields of a log root that are covered by its signature. The data
+// signed is its serialization (see MarshalBinary), so clients can check a signature and
+// then decode exactly what was signed.
+type LogRootV1 struct {
+	// TreeSize is the number of entries in the tree.
+	TreeSize int64
+	// RootHash is the root of the tree at TreeSize, at most 255 bytes.
+	RootHash []byte
+	// TimestampNanos is when the root was made, in nanoseconds since the epoch.
+	TimestampNanos int64
+	// Revision is the revision of the tree the root was stored at.
+	Revision int64
+	// Metadata is opaque to Trillian, at most 65535 bytes.
+	Metadata []byte
+}
+
+// MarshalBinary returns the serialization of the root. All integers are big endian:
+//
+//	uint16 version (LogRootV1Version)
+//	uint64 tree_size
+//	uint8  length of root_hash, then root_hash
+//	uint64 timestamp_nanos
+//	uint64 revision
+//	uint16 length of metadata, then metadata
+func (r LogRootV1) MarshalBinary() ([]byte, error) {
+	if r.TreeSize < 0 || r.TimestampNanos < 0 || r.Revision < 0 {
+		return nil, fmt.Errorf("log root has a negative field: size %d, timestamp %d, revision %d", r.TreeSize, r.TimestampNanos, r.Revision)
+	}
+	if len(r.RootHash) > math.MaxUint8 {
+		return nil, fmt.Errorf("log root hash is %d bytes, the most is %d", len(r.RootHash), math.MaxUint8)
+	}
+	if len(r.Metadata) > math.MaxUint16 {
+		return nil, fmt.Errorf("log root metadata is %d bytes, the most is %d", len(r.Metadata), math.MaxUint16)
+	}
+
+	b := make([]byte, 0, 2+8+1+len(r.RootHash)+8+8+2+len(r.Metadata))
+	b = appendUint16(b, LogRootV1Version)
+	b = appendUint64(b, uint64(r.TreeSize))
+	b = append(b, byte(len(r.RootHash)))
+	b = append(b, r.RootHash...)
+	b = appendUint64(b, uint64(r.TimestampNanos))
+	b = appendUint64(b, uint64(r.Revision))
+	b = appendUint16(b, uint16(len(r.Metadata)))
+	b = append(b, r.Metadata...)
+	return b, nil
+}
+
+// UnmarshalBinary sets the root from a serialization made by MarshalBinary. It fails for
+// other versions, and if there's anything after the root.
+func (r *LogRootV1) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	if version := d.uint16(); d.err == nil && version != LogRootV1Version {
+		return fmt.Errorf("unknown log root version %d", version)
+	}
+	treeSize := d.int64()
+	rootHash := d.bytes(int(d.uint8()))
+	timestamp := d.int64()
+	revision := d.int64()
+	metadata := d.bytes(int(d.uint16()))
+	if d.err != nil {
+		return fmt.Errorf("invalid log root: %v", d.err)
+	}
+	if len(d.data) > 0 {
+		return fmt.Errorf("invalid log root: %d bytes after the end", len(d.data))
+	}
+
+	*r = LogRootV1{
+		TreeSize:       treeSize,
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		Revision:       revision,
+		Metadata:       metadata,
+	}
+	return nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+var errTruncated = errors.New("truncated")
+
+// decoder reads fields from the front of data. After the first failure err is set and
+// everything read is zero.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = errTruncated
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	b := append([]byte{}, d.data[:n]...)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	if b := d.bytes(1); d.err == nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.bytes(2); d.err == nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	b := d.bytes(8)
+	if d.err != nil {
+		return 0
+	}
+	v := binary.BigEndian.Uint64(b)
+	if v > math.MaxInt64 {
+		d.err = fmt.Errorf("value %d is too big", v)
+		return 0
+	}
+	return int64(v)
+}
@@ -0,0 +1,84 @@
+package verify
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogRootV1Encoding(t *testing.T) {
+	for _, test := range []struct {
+		root LogRootV1
+		want string
+	}{
+		{
+			root: LogRootV1{},
+			want: "0001" + "0000000000000000" + "00" + "0000000000000000" + "0000000000000000" + "0000",
+		},
+		{
+			root: LogRootV1{TreeSize: 7, RootHash: []byte{0xaa, 0xbb}, TimestampNanos: 1000, Revision: 3, Metadata: []byte("md")},
+			want: "0001" + "0000000000000007" + "02aabb" + "00000000000003e8" + "0000000000000003" + "0002" + "6d64",
+		},
+	} {
+		b, err := test.root.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%+v): %v", test.root, err)
+		}
+		if got := hex.EncodeToString(b); got != test.want {
+			t.Errorf("MarshalBinary(%+v): got %s, want %s", test.root, got, test.want)
+		}
+
+		var got LogRootV1
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary(%s): %v", test.want, err)
+		}
+		if !reflect.DeepEqual(got, test.root) {
+			t.Errorf("UnmarshalBinary(%s): got %+v, want %+v", test.want, got, test.root)
+		}
+	}
+}
+
+func TestLogRootV1MarshalRejectsBadRoots(t *testing.T) {
+	for _, root := range []LogRootV1{
+		{TreeSize: -1},
+		{TimestampNanos: -1},
+		{Revision: -1},
+		{RootHash: make([]byte, 256)},
+		{Metadata: make([]byte, 65536)},
+	} {
+		if b, err := root.MarshalBinary(); err == nil {
+			t.Errorf("MarshalBinary of a root with a %d byte hash and %d bytes of metadata: got %x, want an error", len(root.RootHash), len(root.Metadata), b)
+		}
+	}
+}
+
+func TestLogRootV1UnmarshalRejectsBadData(t *testing.T) {
+	valid := "0001" + "0000000000000007" + "02aabb" + "00000000000003e8" + "0000000000000003" + "0002" + "6d64"
+
+	for _, test := range []struct {
+		desc, data string
+	}{
+		{"empty", ""},
+		{"other version", "0002" + valid[4:]},
+		{"truncated", valid[:len(valid)-2]},
+		{"no metadata length", valid[:len(valid)-8]},
+		{"trailing data", valid + "00"},
+		{"size too big", "0001" + "8000000000000000" + valid[20:]},
+	} {
+		b, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Fatalf("%s: bad test data: %v", test.desc, err)
+		}
+		var root LogRootV1
+		if err := root.UnmarshalBinary(b); err == nil {
+			t.Errorf("%s: UnmarshalBinary(%s): got %+v, want an error", test.desc, test.data, root)
+		}
+	}
+
+	var root LogRootV1
+	b, _ := hex.DecodeString("0002" + valid[4:])
+	if err := root.UnmarshalBinary(b); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("UnmarshalBinary of version 2: got error %v, want an unknown version error", err)
+	}
+}
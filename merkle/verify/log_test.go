@@ -0,0 +1,185 @@
+package verify
+
+import (
+	"crypto"
+	"fmt"
+	"testing"
+)
+
+// The functions below are direct implementations of the definitions in RFC 6962 section
+// 2.1, used to build the trees and proofs the verifier is checked against.
+
+// largestPowerOfTwoBelow returns the largest power of two smaller than n, which must be > 1
+func largestPowerOfTwoBelow(n int64) int64 {
+	k := int64(1)
+
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+func referenceRoot(h Hasher, leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return h.HashLeaf(leaves[0])
+	}
+
+	k := largestPowerOfTwoBelow(int64(len(leaves)))
+
+	return h.HashChildren(referenceRoot(h, leaves[:k]), referenceRoot(h, leaves[k:]))
+}
+
+func referencePath(h Hasher, m int64, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+
+	k := largestPowerOfTwoBelow(int64(len(leaves)))
+
+	if m < k {
+		return append(referencePath(h, m, leaves[:k]), referenceRoot(h, leaves[k:]))
+	}
+
+	return append(referencePath(h, m-k, leaves[k:]), referenceRoot(h, leaves[:k]))
+}
+
+func referenceSubProof(h Hasher, m int64, leaves [][]byte, complete bool) [][]byte {
+	n := int64(len(leaves))
+
+	if m == n {
+		if complete {
+			return nil
+		}
+
+		return [][]byte{referenceRoot(h, leaves)}
+	}
+
+	k := largestPowerOfTwoBelow(n)
+
+	if m <= k {
+		return append(referenceSubProof(h, m, leaves[:k], complete), referenceRoot(h, leaves[k:]))
+	}
+
+	return append(referenceSubProof(h, m-k, leaves[k:], false), referenceRoot(h, leaves[:k]))
+}
+
+func testLeaves(n int) [][]byte {
+	var leaves [][]byte
+
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, []byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	return leaves
+}
+
+func TestInclusionProof(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	leaves := testLeaves(20)
+
+	for size := int64(1); size <= int64(len(leaves)); size++ {
+		root := referenceRoot(h, leaves[:size])
+
+		for index := int64(0); index < size; index++ {
+			proof := referencePath(h, index, leaves[:size])
+
+			if err := InclusionProof(h, index, size, proof, root, h.HashLeaf(leaves[index])); err != nil {
+				t.Errorf("Failed to verify inclusion of leaf %d in tree size %d: %v", index, size, err)
+			}
+
+			if err := InclusionProof(h, index, size, proof, root, h.HashLeaf([]byte("other"))); err == nil {
+				t.Errorf("Verified inclusion of the wrong leaf at %d in tree size %d", index, size)
+			}
+
+			if len(proof) > 0 {
+				if err := InclusionProof(h, index, size, proof[:len(proof)-1], root, h.HashLeaf(leaves[index])); err == nil {
+					t.Errorf("Verified short proof for leaf %d in tree size %d", index, size)
+				}
+			}
+
+			if err := InclusionProof(h, index, size, append(proof, root), root, h.HashLeaf(leaves[index])); err == nil {
+				t.Errorf("Verified long proof for leaf %d in tree size %d", index, size)
+			}
+		}
+	}
+
+	if err := InclusionProof(h, 1, 1, nil, nil, nil); err == nil {
+		t.Error("Verified inclusion of a leaf outside the tree")
+	}
+}
+
+func TestInclusionProofReportsRootMismatch(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	leaves := testLeaves(5)
+
+	err := InclusionProof(h, 2, 5, referencePath(h, 2, leaves), []byte("root"), h.HashLeaf(leaves[2]))
+
+	if _, ok := err.(RootMismatchError); !ok {
+		t.Errorf("Got error %v for the wrong root, expected RootMismatchError", err)
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	leaves := testLeaves(20)
+
+	for size2 := int64(1); size2 <= int64(len(leaves)); size2++ {
+		root2 := referenceRoot(h, leaves[:size2])
+
+		for size1 := int64(1); size1 <= size2; size1++ {
+			root1 := referenceRoot(h, leaves[:size1])
+			proof := referenceSubProof(h, size1, leaves[:size2], true)
+
+			if err := ConsistencyProof(h, size1, size2, root1, root2, proof); err != nil {
+				t.Errorf("Failed to verify consistency between sizes %d and %d: %v", size1, size2, err)
+			}
+
+			if size1 == size2 {
+				continue
+			}
+
+			if err := ConsistencyProof(h, size1, size2, root2, root2, proof); err == nil {
+				t.Errorf("Verified consistency with the wrong first root between sizes %d and %d", size1, size2)
+			}
+
+			if err := ConsistencyProof(h, size1, size2, root1, root1, proof); err == nil {
+				t.Errorf("Verified consistency with the wrong second root between sizes %d and %d", size1, size2)
+			}
+
+			if err := ConsistencyProof(h, size1, size2, root1, root2, proof[:len(proof)-1]); err == nil {
+				t.Errorf("Verified short proof between sizes %d and %d", size1, size2)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofEdgeCases(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	root := referenceRoot(h, testLeaves(3))
+
+	tests := []struct {
+		desc         string
+		size1, size2 int64
+		root1, root2 []byte
+		proof        [][]byte
+		ok           bool
+	}{
+		{"from empty tree", 0, 3, nil, root, nil, true},
+		{"from empty tree with proof", 0, 3, nil, root, [][]byte{root}, false},
+		{"same size", 3, 3, root, root, nil, true},
+		{"same size different roots", 3, 3, root, []byte("root"), nil, false},
+		{"same size with proof", 3, 3, root, root, [][]byte{root}, false},
+		{"shrinking tree", 3, 2, root, root, nil, false},
+		{"negative size", -1, 2, root, root, nil, false},
+		{"empty proof", 2, 3, root, root, nil, false},
+	}
+
+	for _, test := range tests {
+		err := ConsistencyProof(h, test.size1, test.size2, test.root1, test.root2, test.proof)
+
+		if got := err == nil; got != test.ok {
+			t.Errorf("%s: got error %v, expected success %v", test.desc, err, test.ok)
+		}
+	}
+}
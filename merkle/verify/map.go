@@ -0,0 +1,47 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MapInclusionProof checks that proof shows key has value in the sparse Merkle tree with
+// root. A nil value checks that key has no value, in which case the leaf is empty and the
+// proof holds the hashes of empty subtrees wherever the path has no other keys under it. An
+// empty value can't be told apart from no value. The proof nodes start with the sibling of
+// the leaf and end with the child of the root.
+func MapInclusionProof(h MapHasher, root []byte, key []byte, value []byte, proof [][]byte) error {
+	if expected, got := h.Size()*8, len(proof); expected != got {
+		return fmt.Errorf("map inclusion proof must have %d nodes but had %d", expected, got)
+	}
+
+	var hash []byte
+
+	if value == nil {
+		hash = h.HashLeaf([]byte{})
+	} else {
+		hash = h.HashLeaf(value)
+	}
+
+	path := h.HashKey(key)
+
+	if len(path) != h.Size() {
+		return fmt.Errorf("key hash must be %d bytes but was %d", h.Size(), len(path))
+	}
+
+	for i, sibling := range proof {
+		// Bit i of the path, counting from the last bit, says which side of its parent the
+		// node at height i is on
+		if (path[len(path)-1-i/8]>>uint(i%8))&1 == 0 {
+			hash = h.HashChildren(hash, sibling)
+		} else {
+			hash = h.HashChildren(sibling, hash)
+		}
+	}
+
+	if !bytes.Equal(root, hash) {
+		return RootMismatchError{ExpectedRoot: root, ComputedRoot: hash}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package verify
+
+import (
+	"crypto"
+	"testing"
+)
+
+// emptySubtreeHashes returns the hash of an empty subtree at each height of a sparse tree,
+// starting with an empty leaf
+func emptySubtreeHashes(h MapHasher) [][]byte {
+	hashes := [][]byte{h.HashLeaf([]byte{})}
+
+	for i := 1; i <= h.Size()*8; i++ {
+		hashes = append(hashes, h.HashChildren(hashes[i-1], hashes[i-1]))
+	}
+
+	return hashes
+}
+
+func TestMapInclusionProofInEmptyMap(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	empty := emptySubtreeHashes(h)
+	root := empty[len(empty)-1]
+	proof := empty[:len(empty)-1]
+
+	if err := MapInclusionProof(h, root, []byte("key"), nil, proof); err != nil {
+		t.Errorf("Failed to verify absence in an empty map: %v", err)
+	}
+
+	if err := MapInclusionProof(h, root, []byte("key"), []byte("value"), proof); err == nil {
+		t.Error("Verified a value in an empty map")
+	}
+
+	if err := MapInclusionProof(h, root, []byte("key"), nil, proof[1:]); err == nil {
+		t.Error("Verified a short proof")
+	}
+}
+
+func TestMapInclusionProofWithOneKey(t *testing.T) {
+	h := NewRFC6962Hasher(crypto.SHA256)
+	empty := emptySubtreeHashes(h)
+	key, value := []byte("key"), []byte("value")
+
+	// With only one key in the map every sibling on its path is empty, so the root is built
+	// by walking up from the leaf and checking the bits of the key hash here
+	path := h.HashKey(key)
+	root := h.HashLeaf(value)
+
+	for i := 0; i < h.Size()*8; i++ {
+		if path[len(path)-1-i/8]&(1<<uint(i%8)) == 0 {
+			root = h.HashChildren(root, empty[i])
+		} else {
+			root = h.HashChildren(empty[i], root)
+		}
+	}
+
+	proof := empty[:len(empty)-1]
+
+	if err := MapInclusionProof(h, root, key, value, proof); err != nil {
+		t.Errorf("Failed to verify the only value in the map: %v", err)
+	}
+
+	if err := MapInclusionProof(h, root, key, []byte("other value"), proof); err == nil {
+		t.Error("Verified the wrong value")
+	}
+
+	if err := MapInclusionProof(h, root, key, nil, proof); err == nil {
+		t.Error("Verified absence of a key with a value")
+	}
+
+	err := MapInclusionProof(h, root, []byte("other key"), value, proof)
+
+	if _, ok := err.(RootMismatchError); !ok {
+		t.Errorf("Got error %v for the wrong key, expected RootMismatchError", err)
+	}
+}
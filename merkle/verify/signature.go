@@ -0,0 +1,110 @@
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/benlaurie/objecthash/go/objecthash"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrVerificationFailed is returned when a signature doesn't match the data and public key.
+var ErrVerificationFailed = errors.New("verify: signature verification failed")
+
+// Constants used as map keys when building input for ObjectHash. They must not be changed
+// as this will change the output of MapRootHash()
+const (
+	mapKeyRootHash       string = "RootHash"
+	mapKeyTimestampNanos string = "TimestampNanos"
+	mapKeyMapID          string = "MapId"
+	mapKeyMapRevision    string = "MapRevision"
+	mapKeyMetadata       string = "Metadata"
+)
+
+// ecdsaSignature is the ASN.1 structure of the signatures produced by ecdsa.PrivateKey.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Signature checks that sig is a signature over data made with the private key matching
+// pub. Ed25519 signatures are over data itself, ECDSA and RSA (PKCS #1 v1.5) signatures
+// are over its SHA-256 digest.
+func Signature(pub crypto.PublicKey, data []byte, sig []byte) error {
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return ErrVerificationFailed
+		}
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+
+		var ecdsaSig ecdsaSignature
+		rest, err := asn1.Unmarshal(sig, &ecdsaSig)
+		if err != nil || len(rest) > 0 || ecdsaSig.R == nil || ecdsaSig.S == nil {
+			return ErrVerificationFailed
+		}
+
+		if !ecdsa.Verify(key, digest[:], ecdsaSig.R, ecdsaSig.S) {
+			return ErrVerificationFailed
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrVerificationFailed
+		}
+	default:
+		return fmt.Errorf("unsupported public key type: %T", pub)
+	}
+
+	return nil
+}
+
+// MapRootHash returns the data that's signed for a map root with the given fields. metadata
+// is the serialized mapper metadata, if it's empty the field is left out so that roots
+// without any hash as they did before it was covered.
+func MapRootHash(rootHash []byte, timestampNanos int64, mapID []byte, mapRevision int64, metadata []byte) []byte {
+	rootMap := make(map[string]interface{})
+
+	// Caution: use string format for int64 values as they can overflow when JSON encoded
+	// otherwise (it uses floats). We want to be sure that people using JSON to verify hashes
+	// can build the exact same input to ObjectHash.
+	rootMap[mapKeyRootHash] = base64.StdEncoding.EncodeToString(rootHash)
+	rootMap[mapKeyTimestampNanos] = strconv.FormatInt(timestampNanos, 10)
+	rootMap[mapKeyMapID] = base64.StdEncoding.EncodeToString(mapID)
+	rootMap[mapKeyMapRevision] = strconv.FormatInt(mapRevision, 10)
+	if len(metadata) > 0 {
+		rootMap[mapKeyMetadata] = base64.StdEncoding.EncodeToString(metadata)
+	}
+
+	hash := objecthash.ObjectHash(rootMap)
+
+	return hash[:]
+}
+
+// LogRoot checks that sig is a signature over logRoot, a serialized LogRootV1, and returns
+// the root it holds.
+func LogRoot(pub crypto.PublicKey, logRoot []byte, sig []byte) (*LogRootV1, error) {
+	if err := Signature(pub, logRoot, sig); err != nil {
+		return nil, err
+	}
+
+	var root LogRootV1
+	if err := root.UnmarshalBinary(logRoot); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// MapRoot checks that sig is a signature over the map root with the given fields
+func MapRoot(pub crypto.PublicKey, rootHash []byte, timestampNanos int64, mapID []byte, mapRevision int64, metadata []byte, sig []byte) error {
+	return Signature(pub, MapRootHash(rootHash, timestampNanos, mapID, mapRevision, metadata), sig)
+}
@@ -0,0 +1,127 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignature(t *testing.T) {
+	data := []byte("data")
+	digest := sha256.Sum256(data)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	ed25519Pub, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		signer crypto.Signer
+		pub    crypto.PublicKey
+		input  []byte
+		opts   crypto.SignerOpts
+	}{
+		{"ECDSA", ecdsaKey, ecdsaKey.Public(), digest[:], crypto.SHA256},
+		{"RSA", rsaKey, rsaKey.Public(), digest[:], crypto.SHA256},
+		{"Ed25519", ed25519Key, ed25519Pub, data, crypto.Hash(0)},
+	}
+
+	for _, test := range tests {
+		sig, err := test.signer.Sign(rand.Reader, test.input, test.opts)
+
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", test.desc, err)
+		}
+
+		if err := Signature(test.pub, data, sig); err != nil {
+			t.Errorf("%s: failed to verify signature: %v", test.desc, err)
+		}
+
+		if err := Signature(test.pub, []byte("other data"), sig); err != ErrVerificationFailed {
+			t.Errorf("%s: got error %v for the wrong data, expected ErrVerificationFailed", test.desc, err)
+		}
+
+		if err := Signature(test.pub, data, append([]byte{}, sig[1:]...)); err != ErrVerificationFailed {
+			t.Errorf("%s: got error %v for a corrupt signature, expected ErrVerificationFailed", test.desc, err)
+		}
+	}
+
+	if err := Signature("not a key", data, nil); err == nil || err == ErrVerificationFailed {
+		t.Errorf("Got error %v for an unsupported key, expected a key type error", err)
+	}
+}
+
+func TestRootHashesCoverEveryField(t *testing.T) {
+	mapRoot := MapRootHash([]byte("root"), 1000, []byte("map"), 3, []byte("metadata"))
+
+	for _, other := range [][]byte{
+		MapRootHash([]byte("other"), 1000, []byte("map"), 3, []byte("metadata")),
+		MapRootHash([]byte("root"), 1001, []byte("map"), 3, []byte("metadata")),
+		MapRootHash([]byte("root"), 1000, []byte("other"), 3, []byte("metadata")),
+		MapRootHash([]byte("root"), 1000, []byte("map"), 4, []byte("metadata")),
+		MapRootHash([]byte("root"), 1000, []byte("map"), 3, []byte("other")),
+		MapRootHash([]byte("root"), 1000, []byte("map"), 3, nil),
+	} {
+		if bytes.Equal(mapRoot, other) {
+			t.Errorf("Map root hash %x didn't change with its fields", other)
+		}
+	}
+}
+
+func TestLogRoot(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	root := LogRootV1{TreeSize: 10, RootHash: []byte("root"), TimestampNanos: 1000, Revision: 3}
+	logRoot, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize log root: %v", err)
+	}
+	digest := sha256.Sum256(logRoot)
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	got, err := LogRoot(key.Public(), logRoot, sig)
+	if err != nil {
+		t.Fatalf("Failed to verify log root: %v", err)
+	}
+	if !reflect.DeepEqual(*got, root) {
+		t.Errorf("Got log root %+v, expected %+v", *got, root)
+	}
+
+	root.TreeSize++
+	changed, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize log root: %v", err)
+	}
+	if _, err := LogRoot(key.Public(), changed, sig); err != ErrVerificationFailed {
+		t.Errorf("Got error %v for a changed log root, expected ErrVerificationFailed", err)
+	}
+
+	if err := MapRoot(key.Public(), []byte("root"), 1000, nil, 10, nil, sig); err != ErrVerificationFailed {
+		t.Errorf("Got error %v for a map root with a log root signature, expected ErrVerificationFailed", err)
+	}
+}
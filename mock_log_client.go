@@ -30,6 +30,38 @@ func (_m *MockTrillianLogClient) EXPECT() *_MockTrillianLogClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianLogClient) AddCosignature(_param0 context.Context, _param1 *AddCosignatureRequest, _param2 ...grpc.CallOption) (*AddCosignatureResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "AddCosignature", _s...)
+	ret0, _ := ret[0].(*AddCosignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) AddCosignature(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddCosignature", _s...)
+}
+
+func (_m *MockTrillianLogClient) AddSequencedLeaves(_param0 context.Context, _param1 *AddSequencedLeavesRequest, _param2 ...grpc.CallOption) (*AddSequencedLeavesResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _s...)
+	ret0, _ := ret[0].(*AddSequencedLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) AddSequencedLeaves(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetConsistencyProof(_param0 context.Context, _param1 *GetConsistencyProofRequest, _param2 ...grpc.CallOption) (*GetConsistencyProofResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -126,6 +158,22 @@ func (_mr *_MockTrillianLogClientRecorder) GetLeavesByHash(arg0, arg1 interface{
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetLeavesByIdentityHash(_param0 context.Context, _param1 *GetLeavesByIdentityHashRequest, _param2 ...grpc.CallOption) (*GetLeavesByIdentityHashResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _s...)
+	ret0, _ := ret[0].(*GetLeavesByIdentityHashResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLeavesByIdentityHash(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetLeavesByIndex(_param0 context.Context, _param1 *GetLeavesByIndexRequest, _param2 ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -142,6 +190,22 @@ func (_mr *_MockTrillianLogClientRecorder) GetLeavesByIndex(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetLeavesByRange(_param0 context.Context, _param1 *GetLeavesByRangeRequest, _param2 ...grpc.CallOption) (*GetLeavesByRangeResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _s...)
+	ret0, _ := ret[0].(*GetLeavesByRangeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLeavesByRange(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetSequencedLeafCount(_param0 context.Context, _param1 *GetSequencedLeafCountRequest, _param2 ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -174,6 +238,22 @@ func (_mr *_MockTrillianLogClientRecorder) QueueLeaves(arg0, arg1 interface{}, a
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", _s...)
 }
 
+func (_m *MockTrillianLogClient) StreamLeaves(_param0 context.Context, _param1 *StreamLeavesRequest, _param2 ...grpc.CallOption) (TrillianLog_StreamLeavesClient, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "StreamLeaves", _s...)
+	ret0, _ := ret[0].(TrillianLog_StreamLeavesClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) StreamLeaves(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StreamLeaves", _s...)
+}
+
 // Mock of TrillianLogServer interface
 type MockTrillianLogServer struct {
 	ctrl     *gomock.Controller
@@ -195,6 +275,28 @@ func (_m *MockTrillianLogServer) EXPECT() *_MockTrillianLogServerRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianLogServer) AddCosignature(_param0 context.Context, _param1 *AddCosignatureRequest) (*AddCosignatureResponse, error) {
+	ret := _m.ctrl.Call(_m, "AddCosignature", _param0, _param1)
+	ret0, _ := ret[0].(*AddCosignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) AddCosignature(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddCosignature", arg0, arg1)
+}
+
+func (_m *MockTrillianLogServer) AddSequencedLeaves(_param0 context.Context, _param1 *AddSequencedLeavesRequest) (*AddSequencedLeavesResponse, error) {
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _param0, _param1)
+	ret0, _ := ret[0].(*AddSequencedLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) AddSequencedLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetConsistencyProof(_param0 context.Context, _param1 *GetConsistencyProofRequest) (*GetConsistencyProofResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetConsistencyProof", _param0, _param1)
 	ret0, _ := ret[0].(*GetConsistencyProofResponse)
@@ -261,6 +363,17 @@ func (_mr *_MockTrillianLogServerRecorder) GetLeavesByHash(arg0, arg1 interface{
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1)
 }
 
+func (_m *MockTrillianLogServer) GetLeavesByIdentityHash(_param0 context.Context, _param1 *GetLeavesByIdentityHashRequest) (*GetLeavesByIdentityHashResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0, _param1)
+	ret0, _ := ret[0].(*GetLeavesByIdentityHashResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) GetLeavesByIdentityHash(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetLeavesByIndex(_param0 context.Context, _param1 *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0, _param1)
 	ret0, _ := ret[0].(*GetLeavesByIndexResponse)
@@ -272,6 +385,17 @@ func (_mr *_MockTrillianLogServerRecorder) GetLeavesByIndex(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0, arg1)
 }
 
+func (_m *MockTrillianLogServer) GetLeavesByRange(_param0 context.Context, _param1 *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1)
+	ret0, _ := ret[0].(*GetLeavesByRangeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogServerRecorder) GetLeavesByRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1)
+}
+
 func (_m *MockTrillianLogServer) GetSequencedLeafCount(_param0 context.Context, _param1 *GetSequencedLeafCountRequest) (*GetSequencedLeafCountResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount", _param0, _param1)
 	ret0, _ := ret[0].(*GetSequencedLeafCountResponse)
@@ -294,6 +418,16 @@ func (_mr *_MockTrillianLogServerRecorder) QueueLeaves(arg0, arg1 interface{}) *
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", arg0, arg1)
 }
 
+func (_m *MockTrillianLogServer) StreamLeaves(_param0 *StreamLeavesRequest, _param1 TrillianLog_StreamLeavesServer) error {
+	ret := _m.ctrl.Call(_m, "StreamLeaves", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTrillianLogServerRecorder) StreamLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StreamLeaves", arg0, arg1)
+}
+
 // Mock of TrillianMapClient interface
 type MockTrillianMapClient struct {
 	ctrl     *gomock.Controller
@@ -315,6 +449,22 @@ func (_m *MockTrillianMapClient) EXPECT() *_MockTrillianMapClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianMapClient) GetLeafHistory(_param0 context.Context, _param1 *GetLeafHistoryRequest, _param2 ...grpc.CallOption) (*GetLeafHistoryResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeafHistory", _s...)
+	ret0, _ := ret[0].(*GetLeafHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapClientRecorder) GetLeafHistory(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeafHistory", _s...)
+}
+
 func (_m *MockTrillianMapClient) GetLeaves(_param0 context.Context, _param1 *GetMapLeavesRequest, _param2 ...grpc.CallOption) (*GetMapLeavesResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -331,6 +481,22 @@ func (_mr *_MockTrillianMapClientRecorder) GetLeaves(arg0, arg1 interface{}, arg
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeaves", _s...)
 }
 
+func (_m *MockTrillianMapClient) GetLeavesByPrefix(_param0 context.Context, _param1 *GetMapLeavesByPrefixRequest, _param2 ...grpc.CallOption) (*GetMapLeavesByPrefixResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeavesByPrefix", _s...)
+	ret0, _ := ret[0].(*GetMapLeavesByPrefixResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapClientRecorder) GetLeavesByPrefix(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByPrefix", _s...)
+}
+
 func (_m *MockTrillianMapClient) GetSignedMapRoot(_param0 context.Context, _param1 *GetSignedMapRootRequest, _param2 ...grpc.CallOption) (*GetSignedMapRootResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -347,6 +513,22 @@ func (_mr *_MockTrillianMapClientRecorder) GetSignedMapRoot(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRoot", _s...)
 }
 
+func (_m *MockTrillianMapClient) GetSignedMapRootByRevision(_param0 context.Context, _param1 *GetSignedMapRootByRevisionRequest, _param2 ...grpc.CallOption) (*GetSignedMapRootByRevisionResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetSignedMapRootByRevision", _s...)
+	ret0, _ := ret[0].(*GetSignedMapRootByRevisionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapClientRecorder) GetSignedMapRootByRevision(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRootByRevision", _s...)
+}
+
 func (_m *MockTrillianMapClient) SetLeaves(_param0 context.Context, _param1 *SetMapLeavesRequest, _param2 ...grpc.CallOption) (*SetMapLeavesResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -363,6 +545,22 @@ func (_mr *_MockTrillianMapClientRecorder) SetLeaves(arg0, arg1 interface{}, arg
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetLeaves", _s...)
 }
 
+func (_m *MockTrillianMapClient) StreamMutations(_param0 context.Context, _param1 *StreamMutationsRequest, _param2 ...grpc.CallOption) (TrillianMap_StreamMutationsClient, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "StreamMutations", _s...)
+	ret0, _ := ret[0].(TrillianMap_StreamMutationsClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapClientRecorder) StreamMutations(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StreamMutations", _s...)
+}
+
 // Mock of TrillianMapServer interface
 type MockTrillianMapServer struct {
 	ctrl     *gomock.Controller
@@ -384,6 +582,17 @@ func (_m *MockTrillianMapServer) EXPECT() *_MockTrillianMapServerRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianMapServer) GetLeafHistory(_param0 context.Context, _param1 *GetLeafHistoryRequest) (*GetLeafHistoryResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetLeafHistory", _param0, _param1)
+	ret0, _ := ret[0].(*GetLeafHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapServerRecorder) GetLeafHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeafHistory", arg0, arg1)
+}
+
 func (_m *MockTrillianMapServer) GetLeaves(_param0 context.Context, _param1 *GetMapLeavesRequest) (*GetMapLeavesResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetLeaves", _param0, _param1)
 	ret0, _ := ret[0].(*GetMapLeavesResponse)
@@ -395,6 +604,17 @@ func (_mr *_MockTrillianMapServerRecorder) GetLeaves(arg0, arg1 interface{}) *go
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeaves", arg0, arg1)
 }
 
+func (_m *MockTrillianMapServer) GetLeavesByPrefix(_param0 context.Context, _param1 *GetMapLeavesByPrefixRequest) (*GetMapLeavesByPrefixResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByPrefix", _param0, _param1)
+	ret0, _ := ret[0].(*GetMapLeavesByPrefixResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapServerRecorder) GetLeavesByPrefix(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByPrefix", arg0, arg1)
+}
+
 func (_m *MockTrillianMapServer) GetSignedMapRoot(_param0 context.Context, _param1 *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetSignedMapRoot", _param0, _param1)
 	ret0, _ := ret[0].(*GetSignedMapRootResponse)
@@ -406,6 +626,17 @@ func (_mr *_MockTrillianMapServerRecorder) GetSignedMapRoot(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRoot", arg0, arg1)
 }
 
+func (_m *MockTrillianMapServer) GetSignedMapRootByRevision(_param0 context.Context, _param1 *GetSignedMapRootByRevisionRequest) (*GetSignedMapRootByRevisionResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRootByRevision", _param0, _param1)
+	ret0, _ := ret[0].(*GetSignedMapRootByRevisionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapServerRecorder) GetSignedMapRootByRevision(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRootByRevision", arg0, arg1)
+}
+
 func (_m *MockTrillianMapServer) SetLeaves(_param0 context.Context, _param1 *SetMapLeavesRequest) (*SetMapLeavesResponse, error) {
 	ret := _m.ctrl.Call(_m, "SetLeaves", _param0, _param1)
 	ret0, _ := ret[0].(*SetMapLeavesResponse)
@@ -416,3 +647,13 @@ func (_m *MockTrillianMapServer) SetLeaves(_param0 context.Context, _param1 *Set
 func (_mr *_MockTrillianMapServerRecorder) SetLeaves(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetLeaves", arg0, arg1)
 }
+
+func (_m *MockTrillianMapServer) StreamMutations(_param0 *StreamMutationsRequest, _param1 TrillianMap_StreamMutationsServer) error {
+	ret := _m.ctrl.Call(_m, "StreamMutations", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTrillianMapServerRecorder) StreamMutations(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StreamMutations", arg0, arg1)
+}
@@ -0,0 +1,135 @@
+package monitoring
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// rpcLatency is the time taken to handle each RPC, by method and gRPC status code
+var rpcLatency = NewHistogram("rpc_latency_seconds", "Time taken to handle RPCs", DefaultBuckets, "method", "code")
+
+// metadataCarrier lets trace context be propagated in gRPC metadata
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	if v := metadata.MD(m)[key]; len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m)[key] = []string{value}
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// startServerSpan starts a span for handling an RPC, continuing any trace the client
+// propagated to us
+func startServerSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.String("rpc.method", method)))
+}
+
+// contextStatus gives err the gRPC status code matching ctx's error if an RPC failed after
+// it was cancelled or its deadline passed, so the client is told why rather than seeing
+// whatever storage happened to return when the request was abandoned. Errors which already
+// have a status code are returned unchanged.
+func contextStatus(ctx context.Context, err error) error {
+	if err == nil || grpc.Code(err) != codes.Unknown {
+		return err
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return grpc.Errorf(codes.DeadlineExceeded, "%v", err)
+	case context.Canceled:
+		return grpc.Errorf(codes.Canceled, "%v", err)
+	}
+
+	return err
+}
+
+// UnaryServerInterceptor records the latency of unary RPCs and traces their handling. RPCs
+// that fail because their deadline passed or they were cancelled get the matching status.
+// Install it with grpc.UnaryInterceptor when creating the server.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		err = contextStatus(ctx, err)
+		EndSpan(span, err)
+		rpcLatency.Observe(time.Since(start).Seconds(), info.FullMethod, grpc.Code(err).String())
+		return resp, err
+	}
+}
+
+// tracedStream gives a stream handler a context holding the span for its RPC
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor records the time taken to complete streaming RPCs and traces
+// their handling. Like UnaryServerInterceptor it reports RPCs abandoned by the client with
+// the matching status. Install it with grpc.StreamInterceptor when creating the server.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, span := startServerSpan(stream.Context(), info.FullMethod)
+		err := contextStatus(ctx, handler(srv, tracedStream{stream, ctx}))
+		EndSpan(span, err)
+		rpcLatency.Observe(time.Since(start).Seconds(), info.FullMethod, grpc.Code(err).String())
+		return err
+	}
+}
+
+// UnaryClientInterceptor traces outgoing RPCs and propagates the trace to the server.
+// Install it with grpc.WithUnaryInterceptor when dialing.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := otel.Tracer(tracerName).Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("rpc.method", method)))
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		EndSpan(span, err)
+		return err
+	}
+}
+
+// NewServer creates a gRPC server that records the latency of all its RPCs and traces them
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	return grpc.NewServer(append(opts, grpc.UnaryInterceptor(UnaryServerInterceptor()), grpc.StreamInterceptor(StreamServerInterceptor()))...)
+}
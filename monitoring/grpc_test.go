@@ -0,0 +1,103 @@
+package monitoring
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// fakeStream is a server stream that only has a context
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"}
+	interceptor := UnaryServerInterceptor()
+	ok, failed := rpcLatency.Count(info.FullMethod, "OK"), rpcLatency.Count(info.FullMethod, "NotFound")
+
+	resp, err := interceptor(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	})
+
+	if resp != "resp" || err != nil {
+		t.Errorf("Got %v, %v from interceptor, want the handler's result", resp, err)
+	}
+
+	if _, err := interceptor(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, grpc.Errorf(codes.NotFound, "not found")
+	}); grpc.Code(err) != codes.NotFound {
+		t.Errorf("Got %v from interceptor, want the handler's error", err)
+	}
+
+	if got, want := rpcLatency.Count(info.FullMethod, "OK"), ok+1; got != want {
+		t.Errorf("Got %d successful RPCs, want %d", got, want)
+	}
+
+	if got, want := rpcLatency.Count(info.FullMethod, "NotFound"), failed+1; got != want {
+		t.Errorf("Got %d failed RPCs, want %d", got, want)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	handlerErr := errors.New("stream failed")
+	failed := rpcLatency.Count(info.FullMethod, "Unknown")
+
+	err := StreamServerInterceptor()(nil, fakeStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		return handlerErr
+	})
+
+	if err != handlerErr {
+		t.Errorf("Got %v from interceptor, want the handler's error", err)
+	}
+
+	if got, want := rpcLatency.Count(info.FullMethod, "Unknown"), failed+1; got != want {
+		t.Errorf("Got %d failed RPCs, want %d", got, want)
+	}
+}
+
+func TestServerInterceptorsReportAbandonedRPCs(t *testing.T) {
+	unaryInfo := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, test := range []struct {
+		ctx  context.Context
+		err  error
+		want codes.Code
+	}{
+		{ctx: expired, err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{ctx: expired, err: errors.New("storage read abandoned"), want: codes.DeadlineExceeded},
+		{ctx: cancelled, err: context.Canceled, want: codes.Canceled},
+		// Errors that already have a status keep it
+		{ctx: expired, err: grpc.Errorf(codes.NotFound, "not found"), want: codes.NotFound},
+		// Failures of RPCs that weren't abandoned are passed through
+		{ctx: context.Background(), err: errors.New("it broke"), want: codes.Unknown},
+	} {
+		if _, err := UnaryServerInterceptor()(test.ctx, "req", unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, test.err
+		}); grpc.Code(err) != test.want {
+			t.Errorf("Got %v from unary interceptor for %v, want code %v", err, test.err, test.want)
+		}
+
+		if err := StreamServerInterceptor()(nil, fakeStream{ctx: test.ctx}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+			return test.err
+		}); grpc.Code(err) != test.want {
+			t.Errorf("Got %v from stream interceptor for %v, want code %v", err, test.err, test.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package monitoring provides counters, gauges and histograms that are exported in the
+// Prometheus text format, so servers can be scraped without depending on a client library.
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram bucket upper bounds suited to latencies measured in seconds
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is the part of a metric shared by all types, holding a value for each set of
+// label values
+type metric struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	// series is keyed by the label values joined with labelSeparator
+	series map[string]interface{}
+}
+
+// labelSeparator can't appear in a label value written to a key because it's not valid UTF-8
+const labelSeparator = "\xff"
+
+func newMetric(name, help string, labelNames []string) metric {
+	return metric{name: name, help: help, labelNames: labelNames, series: make(map[string]interface{})}
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s has labels %v but got values %v", m.name, m.labelNames, labelValues))
+	}
+
+	return strings.Join(labelValues, labelSeparator)
+}
+
+// initUnlabelled gives a counter or gauge without labels its zero value, so it's exported
+// before anything has been recorded
+func (m *metric) initUnlabelled() {
+	if len(m.labelNames) == 0 {
+		m.series[""] = float64(0)
+	}
+}
+
+// sortedKeys returns the series keys in order, so output is stable between scrapes. Must be
+// called with m.mu held.
+func (m *metric) sortedKeys() []string {
+	keys := make([]string, 0, len(m.series))
+
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Counter is a metric whose value for each set of labels only goes up
+type Counter struct {
+	metric
+}
+
+// NewCounter creates a Counter with the given label names and registers it with the
+// DefaultRegistry. It panics if the name is already registered.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newMetric(name, help, labelNames)}
+	c.initUnlabelled()
+	DefaultRegistry.mustRegister(c)
+	return c
+}
+
+// Inc adds one to the counter for labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds val, which must not be negative, to the counter for labelValues
+func (c *Counter) Add(val float64, labelValues ...string) {
+	if val < 0 {
+		panic(fmt.Sprintf("counter %s can't be decreased by %v", c.name, val))
+	}
+
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, _ := c.series[key].(float64)
+	c.series[key] = v + val
+}
+
+// Value returns the counter for labelValues
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, _ := c.series[key].(float64)
+	return v
+}
+
+// Gauge is a metric whose value for each set of labels can go up and down
+type Gauge struct {
+	metric
+}
+
+// NewGauge creates a Gauge with the given label names and registers it with the
+// DefaultRegistry. It panics if the name is already registered.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newMetric(name, help, labelNames)}
+	g.initUnlabelled()
+	DefaultRegistry.mustRegister(g)
+	return g
+}
+
+// Set sets the gauge for labelValues to val
+func (g *Gauge) Set(val float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.series[key] = val
+}
+
+// Value returns the gauge for labelValues
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	v, _ := g.series[key].(float64)
+	return v
+}
+
+// Histogram is a metric that counts observations into buckets for each set of labels
+type Histogram struct {
+	metric
+	// buckets are the upper bounds of the buckets in increasing order
+	buckets []float64
+}
+
+// histogramSeries holds the observations for one set of label values. counts[i] is the
+// number of observations that fell in bucket i but not bucket i-1, the last entry is for
+// observations above all bucket bounds.
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a Histogram with the given bucket upper bounds, which must be in
+// increasing order, and label names. It's registered with the DefaultRegistry and panics if
+// the name is already registered.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("histogram %s buckets are not in increasing order: %v", name, buckets))
+	}
+
+	h := &Histogram{newMetric(name, help, labelNames), buckets}
+	DefaultRegistry.mustRegister(h)
+	return h
+}
+
+// Observe records val in the histogram for labelValues
+func (h *Histogram) Observe(val float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key].(*histogramSeries)
+
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+
+	s.counts[sort.SearchFloat64s(h.buckets, val)]++
+	s.count++
+	s.sum += val
+}
+
+// Count returns the number of observations made for labelValues
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.series[key].(*histogramSeries); ok {
+		return s.count
+	}
+
+	return 0
+}
+
+// Sum returns the total of the observations made for labelValues
+func (h *Histogram) Sum(labelValues ...string) float64 {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.series[key].(*histogramSeries); ok {
+		return s.sum
+	}
+
+	return 0
+}
@@ -0,0 +1,144 @@
+package monitoring
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_counter", "A counter", "method")
+
+	c.Inc("get")
+	c.Add(2.5, "get")
+	c.Inc("set")
+
+	if got, want := c.Value("get"), 3.5; got != want {
+		t.Errorf("Got %v for get, want %v", got, want)
+	}
+
+	if got, want := c.Value("set"), float64(1); got != want {
+		t.Errorf("Got %v for set, want %v", got, want)
+	}
+
+	if got := c.Value("unused"); got != 0 {
+		t.Errorf("Got %v for unused labels, want 0", got)
+	}
+}
+
+func TestCounterRejectsDecrease(t *testing.T) {
+	c := NewCounter("test_counter_decrease", "A counter")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Counter was decreased")
+		}
+	}()
+
+	c.Add(-1)
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	g := NewGauge("test_gauge_labels", "A gauge", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Set gauge with the wrong number of labels")
+		}
+	}()
+
+	g.Set(1, "a")
+}
+
+func TestDuplicateNamePanics(t *testing.T) {
+	NewGauge("test_duplicate", "A gauge")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registered two metrics with the same name")
+		}
+	}()
+
+	NewCounter("test_duplicate", "A counter")
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_histogram", "A histogram", []float64{1, 2, 5}, "tree")
+
+	for _, v := range []float64{0.5, 1, 1.5, 3, 10} {
+		h.Observe(v, "1")
+	}
+
+	if got, want := h.Count("1"), uint64(5); got != want {
+		t.Errorf("Got count %d, want %d", got, want)
+	}
+
+	if got, want := h.Sum("1"), 16.0; got != want {
+		t.Errorf("Got sum %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	h.writeText(&buf)
+
+	// Buckets are cumulative and include their upper bound
+	want := `# HELP test_histogram A histogram
+# TYPE test_histogram histogram
+test_histogram_bucket{tree="1",le="1"} 2
+test_histogram_bucket{tree="1",le="2"} 3
+test_histogram_bucket{tree="1",le="5"} 4
+test_histogram_bucket{tree="1",le="+Inf"} 5
+test_histogram_sum{tree="1"} 16
+test_histogram_count{tree="1"} 5
+`
+
+	if got := buf.String(); got != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramRejectsUnsortedBuckets(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Created histogram with unsorted buckets")
+		}
+	}()
+
+	NewHistogram("test_histogram_unsorted", "A histogram", []float64{2, 1})
+}
+
+func TestWriteTextEscapesAndSorts(t *testing.T) {
+	g := NewGauge("test_gauge_text", "A gauge\nwith a \\ in its help", "name")
+
+	g.Set(2, `b"\`)
+	g.Set(-1.5, "a\n")
+
+	var buf bytes.Buffer
+	g.writeText(&buf)
+
+	want := `# HELP test_gauge_text A gauge\nwith a \\ in its help
+# TYPE test_gauge_text gauge
+test_gauge_text{name="a\n"} -1.5
+test_gauge_text{name="b\"\\"} 2
+`
+
+	if got := buf.String(); got != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewCounter("test_handler_counter", "A counter")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got, want := w.Header().Get("Content-Type"), "text/plain; version=0.0.4"; got != want {
+		t.Errorf("Got content type %s, want %s", got, want)
+	}
+
+	// Metrics without labels are exported before anything is recorded
+	if body := w.Body.String(); !strings.Contains(body, "\ntest_handler_counter 0\n") {
+		t.Errorf("Unrecorded counter missing from metrics:\n%s", body)
+	}
+}
@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// exportable is implemented by all the metric types
+type exportable interface {
+	metricName() string
+	// writeText writes the metric in the Prometheus text format
+	writeText(w io.Writer)
+}
+
+// Registry holds a set of metrics with distinct names
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]exportable
+}
+
+// DefaultRegistry holds all the metrics created by this package and is what Handler exports
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]exportable)}
+}
+
+func (r *Registry) mustRegister(m exportable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.metrics[m.metricName()]; exists {
+		panic(fmt.Sprintf("metric %s is already registered", m.metricName()))
+	}
+
+	r.metrics[m.metricName()] = m
+}
+
+// WriteText writes all the metrics in the registry to w in the Prometheus text format,
+// ordered by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]exportable, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.writeText(w)
+	}
+}
+
+// Handler returns an http.Handler that serves the metrics in the DefaultRegistry, for
+// registering at /metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		DefaultRegistry.WriteText(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	})
+}
+
+// StartServer serves the metrics at /metrics on addr in the background. Failing to serve
+// is logged but doesn't stop the caller, metrics won't be available.
+func StartServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	go func() {
+		glog.Infof("Serving metrics on %s", addr)
+		glog.Warningf("Metrics server exited: %v", http.ListenAndServe(addr, mux))
+	}()
+}
+
+func (m *metric) metricName() string {
+	return m.name
+}
+
+func (m *metric) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, strings.Replace(strings.Replace(m.help, `\`, `\\`, -1), "\n", `\n`, -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, metricType)
+}
+
+// labels formats the labels for a series key, with any extra label appended
+func (m *metric) labels(key string, extra ...string) string {
+	pairs := []string{}
+
+	if len(m.labelNames) > 0 {
+		for i, v := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, m.labelNames[i]+"="+quoteLabelValue(v))
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+quoteLabelValue(extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func quoteLabelValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return `"` + v + `"`
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (c *Counter) writeText(w io.Writer) {
+	c.writeFloats(w, "counter")
+}
+
+func (g *Gauge) writeText(w io.Writer) {
+	g.writeFloats(w, "gauge")
+}
+
+func (m *metric) writeFloats(w io.Writer, metricType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writeHeader(w, metricType)
+
+	for _, key := range m.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, m.labels(key), formatValue(m.series[key].(float64)))
+	}
+}
+
+func (h *Histogram) writeText(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w, "histogram")
+
+	for _, key := range h.sortedKeys() {
+		s := h.series[key].(*histogramSeries)
+		cumulative := uint64(0)
+
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", formatValue(bound)), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(key), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(key), s.count)
+	}
+}
@@ -0,0 +1,70 @@
+package monitoring
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// tracerName identifies the spans created by Trillian
+const tracerName = "github.com/google/trillian"
+
+// StartSpan starts a span called name as a child of any span in ctx and returns a context
+// holding it. Until InitTracing is called spans aren't recorded and this is cheap.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends span, marking it as failed if err is not nil
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// InitTracing records spans for sampleRate of the traces started by this process, and for
+// the traces that callers have chosen to sample. Completed spans are logged. Trace context
+// is propagated to and from other processes in gRPC metadata using the W3C format. The
+// returned function flushes any spans that haven't been logged yet and should be called
+// before exiting.
+func InitTracing(sampleRate float64) func() {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate))),
+		sdktrace.WithBatcher(logExporter{}))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			glog.Warningf("Failed to flush spans: %v", err)
+		}
+	}
+}
+
+// logExporter writes completed spans to the log
+type logExporter struct{}
+
+func (logExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, s := range spans {
+		glog.Infof("Span %s trace=%s span=%s parent=%s duration=%v status=%s %v",
+			s.Name(), s.SpanContext().TraceID(), s.SpanContext().SpanID(), s.Parent().SpanID(),
+			s.EndTime().Sub(s.StartTime()).Round(time.Microsecond), s.Status().Code, s.Attributes())
+	}
+
+	return nil
+}
+
+func (logExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package monitoring
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// recordSpans makes spans be recorded until the returned function is called
+func recordSpans() (*tracetest.SpanRecorder, func()) {
+	recorder := tracetest.NewSpanRecorder()
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return recorder, func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	}
+}
+
+func TestEndSpanRecordsError(t *testing.T) {
+	recorder, restore := recordSpans()
+	defer restore()
+
+	_, ok := StartSpan(context.Background(), "ok")
+	EndSpan(ok, nil)
+	_, failed := StartSpan(context.Background(), "failed")
+	EndSpan(failed, errors.New("it broke"))
+
+	spans := recorder.Ended()
+
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("Got %d spans, want %d", got, want)
+	}
+
+	if got := spans[0].Status().Code; got != codes.Unset {
+		t.Errorf("Got status %v for successful span, want unset", got)
+	}
+
+	if got, want := spans[1].Status(), (sdktrace.Status{Code: codes.Error, Description: "it broke"}); got != want {
+		t.Errorf("Got status %v for failed span, want %v", got, want)
+	}
+}
+
+func TestTracePropagatesFromClientToServer(t *testing.T) {
+	recorder, restore := recordSpans()
+	defer restore()
+
+	// Pass the metadata the client sends straight to the server interceptor
+	var sent metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+
+	if err := UnaryClientInterceptor()(ctx, "/test.Service/Traced", "req", nil, nil, invoker); err != nil {
+		t.Fatalf("Client interceptor failed: %v", err)
+	}
+
+	parent.End()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Traced"}
+	handled := false
+
+	if _, err := UnaryServerInterceptor()(metadata.NewIncomingContext(context.Background(), sent), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, child := StartSpan(ctx, "child")
+		child.End()
+		handled = true
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("Server interceptor failed: %v", err)
+	}
+
+	if !handled {
+		t.Fatalf("Handler wasn't called")
+	}
+
+	spans := recorder.Ended()
+
+	if got, want := len(spans), 4; got != want {
+		t.Fatalf("Got %d spans, want %d", got, want)
+	}
+
+	// Spans are recorded in the order they end
+	client, child, server := spans[0], spans[2], spans[3]
+
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+			t.Errorf("Span %s is in trace %s, want %s", s.Name(), s.SpanContext().TraceID(), parent.SpanContext().TraceID())
+		}
+	}
+
+	if got, want := server.Parent().SpanID(), client.SpanContext().SpanID(); got != want {
+		t.Errorf("Server span has parent %s, want the client span %s", got, want)
+	}
+
+	if got, want := child.Parent().SpanID(), server.SpanContext().SpanID(); got != want {
+		t.Errorf("Handler span has parent %s, want the server span %s", got, want)
+	}
+}
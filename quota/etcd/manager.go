@@ -0,0 +1,229 @@
+// Package etcd provides a quota.Manager that keeps its token buckets in etcd, so that a
+// set of servers enforces the same quotas between them.
+package etcd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// maxAttempts is how many times taking or returning tokens is tried when other servers
+// keep updating the same buckets first
+const maxAttempts = 10
+
+// Manager is a quota.Manager whose buckets are shared through etcd. Each bucket is stored
+// under its own key and updated with a transaction that fails if another server changed
+// it in the meantime, so tokens are never handed out twice.
+type Manager struct {
+	client     clientv3.KV
+	prefix     string
+	timeSource util.TimeSource
+
+	// Must hold this lock before accessing the limits
+	mutex  sync.RWMutex
+	limits quota.Limits
+}
+
+// NewManager creates a Manager that enforces limits with buckets stored under keys
+// starting with prefix
+func NewManager(client clientv3.KV, prefix string, limits quota.Limits, timeSource util.TimeSource) *Manager {
+	return &Manager{client: client, prefix: prefix, limits: limits, timeSource: timeSource}
+}
+
+// SetLimits replaces the limits the buckets are enforced with. Other servers sharing the
+// buckets keep their own limits, so they should all be changed together.
+func (m *Manager) SetLimits(limits quota.Limits) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.limits = limits
+}
+
+// currentLimits returns the limits buckets are enforced with
+func (m *Manager) currentLimits() quota.Limits {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.limits
+}
+
+// GetTokens takes tokens from each of the buckets in specs that has a limit
+func (m *Manager) GetTokens(ctx context.Context, numTokens int, specs []quota.Spec) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		ok, err := m.tryGetTokens(ctx, numTokens, specs)
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("etcd: buckets %v kept changing while taking tokens", specs)
+}
+
+// PutTokens returns tokens to each of the buckets in specs that's refilled by sequencing
+func (m *Manager) PutTokens(ctx context.Context, numTokens int, specs []quota.Spec) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		ok, err := m.tryPutTokens(ctx, numTokens, specs)
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("etcd: buckets %v kept changing while returning tokens", specs)
+}
+
+// tryGetTokens takes the tokens if none of the buckets are changed by another server
+// while it's working out how many they have. It returns false if one was.
+func (m *Manager) tryGetTokens(ctx context.Context, numTokens int, specs []quota.Spec) (bool, error) {
+	now := m.timeSource.Now()
+	limits := m.currentLimits()
+	cmps := make([]clientv3.Cmp, 0, len(specs))
+	puts := make([]clientv3.Op, 0, len(specs))
+	seen := make(map[string]bool)
+
+	for _, spec := range specs {
+		limit, ok := limits[spec.Group][spec.Kind]
+		key := fmt.Sprintf("%s/%v", m.prefix, spec)
+
+		if !ok || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		resp, err := m.client.Get(ctx, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		b := quota.NewBucket(limit, now)
+		// A new bucket must still not exist when it's written
+		cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+
+		if len(resp.Kvs) > 0 {
+			if b, err = decodeBucket(resp.Kvs[0].Value); err != nil {
+				return false, fmt.Errorf("etcd: invalid bucket %s: %v", key, err)
+			}
+
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)
+		}
+
+		if b, ok = b.Take(limit, numTokens, now); !ok {
+			return false, quota.ErrExhausted
+		}
+
+		cmps = append(cmps, cmp)
+		puts = append(puts, clientv3.OpPut(key, encodeBucket(b)))
+	}
+
+	if len(puts) == 0 {
+		return true, nil
+	}
+
+	resp, err := m.client.Txn(ctx).If(cmps...).Then(puts...).Commit()
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Succeeded, nil
+}
+
+// tryPutTokens returns the tokens if none of the buckets are changed by another server
+// while it's working out how many they have. It returns false if one was.
+func (m *Manager) tryPutTokens(ctx context.Context, numTokens int, specs []quota.Spec) (bool, error) {
+	now := m.timeSource.Now()
+	limits := m.currentLimits()
+	cmps := make([]clientv3.Cmp, 0, len(specs))
+	puts := make([]clientv3.Op, 0, len(specs))
+	seen := make(map[string]bool)
+
+	for _, spec := range specs {
+		limit, ok := limits[spec.Group][spec.Kind]
+		key := fmt.Sprintf("%s/%v", m.prefix, spec)
+
+		if !ok || !limit.Sequenced || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		resp, err := m.client.Get(ctx, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		// A bucket that hasn't been used yet is already full
+		if len(resp.Kvs) == 0 {
+			continue
+		}
+
+		b, err := decodeBucket(resp.Kvs[0].Value)
+
+		if err != nil {
+			return false, fmt.Errorf("etcd: invalid bucket %s: %v", key, err)
+		}
+
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision))
+		puts = append(puts, clientv3.OpPut(key, encodeBucket(b.Put(limit, numTokens, now))))
+	}
+
+	if len(puts) == 0 {
+		return true, nil
+	}
+
+	resp, err := m.client.Txn(ctx).If(cmps...).Then(puts...).Commit()
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Succeeded, nil
+}
+
+// encodeBucket stores a bucket as its tokens and the time it was updated in nanoseconds
+// since the epoch, separated by a space
+func encodeBucket(b quota.Bucket) string {
+	return fmt.Sprintf("%s %d", strconv.FormatFloat(b.Tokens, 'g', -1, 64), b.Updated.UnixNano())
+}
+
+func decodeBucket(value []byte) (quota.Bucket, error) {
+	fields := strings.Fields(string(value))
+
+	if len(fields) != 2 {
+		return quota.Bucket{}, fmt.Errorf("got %d fields, want 2", len(fields))
+	}
+
+	tokens, err := strconv.ParseFloat(fields[0], 64)
+
+	if err != nil {
+		return quota.Bucket{}, err
+	}
+
+	nanos, err := strconv.ParseInt(fields[1], 10, 64)
+
+	if err != nil {
+		return quota.Bucket{}, err
+	}
+
+	return quota.Bucket{Tokens: tokens, Updated: time.Unix(0, nanos)}, nil
+}
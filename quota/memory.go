@@ -0,0 +1,124 @@
+package quota
+
+import (
+	"sync"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// MemoryManager is a Manager that keeps its buckets in memory, so each server enforces
+// its quotas on its own. Use it when there's only one server or it's acceptable for the
+// limits to apply to each server separately. A bucket is kept for every tree and user
+// that has made a request.
+type MemoryManager struct {
+	timeSource util.TimeSource
+
+	// Must hold this lock before accessing the limits or buckets
+	mutex   sync.Mutex
+	limits  Limits
+	buckets map[Spec]Bucket
+}
+
+// NewMemoryManager creates a MemoryManager that enforces limits. Buckets are created full
+// when they're first used.
+func NewMemoryManager(limits Limits, timeSource util.TimeSource) *MemoryManager {
+	return &MemoryManager{limits: limits, timeSource: timeSource, buckets: make(map[Spec]Bucket)}
+}
+
+// SetLimits replaces the limits the buckets are enforced with. Buckets keep the tokens they
+// have, up to their new capacity, and those that no longer have a limit are let go.
+func (m *MemoryManager) SetLimits(limits Limits) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.limits = limits
+
+	for spec, b := range m.buckets {
+		limit, ok := limits[spec.Group][spec.Kind]
+
+		if !ok {
+			delete(m.buckets, spec)
+		} else if b.Tokens > float64(limit.Capacity) {
+			b.Tokens = float64(limit.Capacity)
+			m.buckets[spec] = b
+		}
+	}
+}
+
+// PruneTrees drops the buckets of trees that aren't in live. Requests are charged before
+// the tree they name is looked up, so this also lets go of the buckets of trees that never
+// existed. A tree created since live was listed just gets a new, full bucket.
+func (m *MemoryManager) PruneTrees(live map[int64]bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for spec := range m.buckets {
+		if spec.Group == Tree && !live[spec.TreeID] {
+			delete(m.buckets, spec)
+		}
+	}
+}
+
+// GetTokens takes tokens from each of the buckets in specs that has a limit
+func (m *MemoryManager) GetTokens(ctx context.Context, numTokens int, specs []Spec) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.timeSource.Now()
+	taken := make(map[Spec]Bucket)
+
+	for _, spec := range specs {
+		limit, ok := m.limits[spec.Group][spec.Kind]
+
+		if !ok {
+			continue
+		}
+
+		b, ok := taken[spec]
+
+		if !ok {
+			b, ok = m.buckets[spec]
+		}
+
+		if !ok {
+			b = NewBucket(limit, now)
+		}
+
+		if b, ok = b.Take(limit, numTokens, now); !ok {
+			return ErrExhausted
+		}
+
+		taken[spec] = b
+	}
+
+	// Only update the buckets once we know all of them had enough tokens
+	for spec, b := range taken {
+		m.buckets[spec] = b
+	}
+
+	return nil
+}
+
+// PutTokens returns tokens to each of the buckets in specs that's refilled by sequencing
+func (m *MemoryManager) PutTokens(ctx context.Context, numTokens int, specs []Spec) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.timeSource.Now()
+
+	for _, spec := range specs {
+		limit, ok := m.limits[spec.Group][spec.Kind]
+
+		if !ok || !limit.Sequenced {
+			continue
+		}
+
+		// A bucket that hasn't been used yet is already full
+		if b, ok := m.buckets[spec]; ok {
+			m.buckets[spec] = b.Put(limit, numTokens, now)
+		}
+	}
+
+	return nil
+}
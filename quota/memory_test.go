@@ -0,0 +1,165 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var globalWrite = Spec{Group: Global, Kind: Write}
+var tree1Write = Spec{Group: Tree, Kind: Write, TreeID: 1}
+var tree2Write = Spec{Group: Tree, Kind: Write, TreeID: 2}
+var userRead = Spec{Group: User, Kind: Read, User: "alice"}
+
+func TestMemoryManagerEnforcesLimits(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{Tree: {Write: {Capacity: 5, TokensPerSecond: 1}}}, ts)
+	ctx := context.Background()
+
+	if err := m.GetTokens(ctx, 5, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to take tokens from a full bucket: %v", err)
+	}
+
+	if err := m.GetTokens(ctx, 1, []Spec{tree1Write}); err != ErrExhausted {
+		t.Errorf("Got %v taking tokens from an empty bucket, want %v", err, ErrExhausted)
+	}
+
+	// Each tree has its own bucket
+	if err := m.GetTokens(ctx, 5, []Spec{tree2Write}); err != nil {
+		t.Errorf("Failed to take tokens for another tree: %v", err)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Second * 2)
+
+	if err := m.GetTokens(ctx, 2, []Spec{tree1Write}); err != nil {
+		t.Errorf("Failed to take tokens after the bucket was refilled: %v", err)
+	}
+}
+
+func TestMemoryManagerTakesFromAllBucketsOrNone(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{
+		Global: {Write: {Capacity: 10}},
+		Tree:   {Write: {Capacity: 4}},
+	}, ts)
+	ctx := context.Background()
+
+	// The tree bucket doesn't have enough so nothing should be taken from the global one
+	if err := m.GetTokens(ctx, 6, []Spec{globalWrite, tree1Write}); err != ErrExhausted {
+		t.Fatalf("Got %v taking more tokens than the tree has, want %v", err, ErrExhausted)
+	}
+
+	if err := m.GetTokens(ctx, 10, []Spec{globalWrite}); err != nil {
+		t.Errorf("Failed to take all the global tokens: %v", err)
+	}
+}
+
+func TestMemoryManagerUnlimitedBuckets(t *testing.T) {
+	m := NewMemoryManager(Limits{Tree: {Write: {Capacity: 1}}}, util.FakeTimeSource{FakeTime: time.Unix(1000, 0)})
+
+	// There are no limits on reads or global writes
+	for i := 0; i < 10; i++ {
+		if err := m.GetTokens(context.Background(), 100, []Spec{globalWrite, userRead}); err != nil {
+			t.Fatalf("Failed to take tokens from unlimited buckets: %v", err)
+		}
+	}
+}
+
+func TestMemoryManagerSequencedBuckets(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{Tree: {Write: {Capacity: 5, Sequenced: true}}}, ts)
+	ctx := context.Background()
+
+	if err := m.GetTokens(ctx, 5, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to take tokens from a full bucket: %v", err)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Hour)
+
+	if err := m.GetTokens(ctx, 1, []Spec{tree1Write}); err != ErrExhausted {
+		t.Errorf("Got %v taking tokens before any were sequenced, want %v", err, ErrExhausted)
+	}
+
+	if err := m.PutTokens(ctx, 3, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to return tokens: %v", err)
+	}
+
+	if err := m.GetTokens(ctx, 3, []Spec{tree1Write}); err != nil {
+		t.Errorf("Failed to take the tokens that were returned: %v", err)
+	}
+}
+
+func TestMemoryManagerPutTokensIgnoresTimedBuckets(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{Tree: {Write: {Capacity: 5, TokensPerSecond: 1}}}, ts)
+	ctx := context.Background()
+
+	if err := m.GetTokens(ctx, 5, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to take tokens from a full bucket: %v", err)
+	}
+
+	if err := m.PutTokens(ctx, 5, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to return tokens: %v", err)
+	}
+
+	if err := m.GetTokens(ctx, 1, []Spec{tree1Write}); err != ErrExhausted {
+		t.Errorf("Got %v after returning tokens to a bucket refilled over time, want %v", err, ErrExhausted)
+	}
+}
+
+func TestMemoryManagerSetLimits(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{Tree: {Write: {Capacity: 10, TokensPerSecond: 1}}}, ts)
+	ctx := context.Background()
+
+	if err := m.GetTokens(ctx, 2, []Spec{tree1Write}); err != nil {
+		t.Fatalf("Failed to take tokens from a full bucket: %v", err)
+	}
+
+	// The bucket keeps 5 of its 8 tokens and global writes become limited
+	m.SetLimits(Limits{Tree: {Write: {Capacity: 5, TokensPerSecond: 1}}, Global: {Write: {Capacity: 1}}})
+
+	if err := m.GetTokens(ctx, 6, []Spec{tree1Write}); err != ErrExhausted {
+		t.Errorf("Got %v taking more tokens than the new capacity, want %v", err, ErrExhausted)
+	}
+	if err := m.GetTokens(ctx, 5, []Spec{tree1Write}); err != nil {
+		t.Errorf("Failed to take the tokens left after lowering the capacity: %v", err)
+	}
+	if err := m.GetTokens(ctx, 2, []Spec{globalWrite}); err != ErrExhausted {
+		t.Errorf("Got %v taking tokens over a new limit, want %v", err, ErrExhausted)
+	}
+
+	// Without limits there's nothing to enforce
+	m.SetLimits(Limits{})
+
+	if err := m.GetTokens(ctx, 100, []Spec{tree1Write, globalWrite}); err != nil {
+		t.Errorf("Failed to take tokens after the limits were removed: %v", err)
+	}
+}
+
+func TestMemoryManagerPruneTrees(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	m := NewMemoryManager(Limits{Global: {Write: {Capacity: 10}}, Tree: {Write: {Capacity: 5}}}, ts)
+	ctx := context.Background()
+
+	for _, spec := range []Spec{tree1Write, tree2Write} {
+		if err := m.GetTokens(ctx, 5, []Spec{globalWrite, spec}); err != nil {
+			t.Fatalf("Failed to take tokens from %v: %v", spec, err)
+		}
+	}
+
+	// Tree 2 has been deleted
+	m.PruneTrees(map[int64]bool{1: true})
+
+	if got, want := len(m.buckets), 2; got != want {
+		t.Errorf("Kept %d buckets after pruning, want %d", got, want)
+	}
+	if err := m.GetTokens(ctx, 1, []Spec{tree1Write}); err != ErrExhausted {
+		t.Errorf("Got %v taking tokens from the empty bucket of a live tree, want %v", err, ErrExhausted)
+	}
+	if err := m.GetTokens(ctx, 1, []Spec{globalWrite}); err != ErrExhausted {
+		t.Errorf("Got %v taking tokens from the empty global bucket, want %v", err, ErrExhausted)
+	}
+}
@@ -0,0 +1,280 @@
+// Package quota limits the rate at which clients can use the servers, so that one abusive
+// client can't overload the storage for everyone else. Usage is metered with token buckets
+// which are refilled at a fixed rate, and a request is refused if any of the buckets it
+// draws from doesn't have enough tokens left.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrExhausted is returned by a Manager when there aren't enough tokens for a request
+var ErrExhausted = errors.New("quota: exhausted")
+
+// Kind is whether tokens are for reading or writing
+type Kind int
+
+const (
+	// Read tokens are used by requests that just read from storage
+	Read Kind = iota
+	// Write tokens are used by requests that add to trees
+	Write
+)
+
+var kindNames = map[Kind]string{Read: "read", Write: "write"}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Group is what a token bucket is shared between
+type Group int
+
+const (
+	// Global buckets are shared by all requests
+	Global Group = iota
+	// Tree buckets are shared by the requests for a tree
+	Tree
+	// User buckets are shared by the requests from a user
+	User
+)
+
+var groupNames = map[Group]string{Global: "global", Tree: "tree", User: "user"}
+
+func (g Group) String() string {
+	if name, ok := groupNames[g]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Group(%d)", int(g))
+}
+
+// Spec identifies a token bucket
+type Spec struct {
+	Group Group
+	Kind  Kind
+	// TreeID is the tree the bucket is for if Group is Tree
+	TreeID int64
+	// User is the user the bucket is for if Group is User
+	User string
+}
+
+// String names the bucket, e.g. tree/123/write
+func (s Spec) String() string {
+	switch s.Group {
+	case Tree:
+		return fmt.Sprintf("%v/%d/%v", s.Group, s.TreeID, s.Kind)
+	case User:
+		return fmt.Sprintf("%v/%s/%v", s.Group, s.User, s.Kind)
+	default:
+		return fmt.Sprintf("%v/%v", s.Group, s.Kind)
+	}
+}
+
+// Manager hands out tokens for requests
+type Manager interface {
+	// GetTokens takes numTokens from each of the buckets identified by specs. Either the
+	// tokens are taken from all of the buckets or, if any of them doesn't have enough, from
+	// none of them and ErrExhausted is returned.
+	GetTokens(ctx context.Context, numTokens int, specs []Spec) error
+	// PutTokens returns numTokens to each of the buckets identified by specs that are
+	// refilled by sequencing, up to their capacity. It's called as leaves are integrated
+	// into trees, or when leaves that tokens were taken for won't be. Buckets refilled over
+	// time aren't changed.
+	PutTokens(ctx context.Context, numTokens int, specs []Spec) error
+}
+
+// LimitSetter is implemented by Managers whose limits can be changed while they're in use
+type LimitSetter interface {
+	SetLimits(limits Limits)
+}
+
+// TreePruner is implemented by Managers that keep a bucket for each tree, so that the buckets
+// of trees that have been deleted can be let go
+type TreePruner interface {
+	// PruneTrees drops the buckets of all the trees that aren't in live
+	PruneTrees(live map[int64]bool)
+}
+
+// Unlimited is a Manager for servers that don't enforce quotas
+type Unlimited struct{}
+
+// GetTokens always succeeds
+func (u Unlimited) GetTokens(ctx context.Context, numTokens int, specs []Spec) error {
+	return nil
+}
+
+// PutTokens does nothing as there are no buckets to refill
+func (u Unlimited) PutTokens(ctx context.Context, numTokens int, specs []Spec) error {
+	return nil
+}
+
+// Limit is the size and refill rate of a token bucket
+type Limit struct {
+	// Capacity is the most tokens the bucket can hold, and how many a new bucket starts with
+	Capacity int64
+	// TokensPerSecond is the rate the bucket is refilled at
+	TokensPerSecond float64
+	// Sequenced means the bucket is refilled as leaves are sequenced, rather than over
+	// time, so that writers are held back when sequencing falls behind instead of the queue
+	// of leaves growing without limit
+	Sequenced bool
+}
+
+// Limits gives the limit for the buckets of each group and kind, e.g. all the per tree
+// write buckets. There's no limit on buckets which don't have one.
+type Limits map[Group]map[Kind]Limit
+
+// ParseLimits parses limits from a comma separated list of group/kind=capacity:rate, e.g.
+// "global/write=1000:100,user/read=50:10" lets all clients write at 100 leaves per second
+// with bursts of up to 1000, and each user make 10 read requests per second. The rate of
+// global and tree write buckets can be "sequenced" to refill them as leaves are sequenced,
+// e.g. "tree/write=5000:sequenced" lets each tree have at most 5000 leaves waiting.
+func ParseLimits(s string) (Limits, error) {
+	limits := make(Limits)
+
+	if len(s) == 0 {
+		return limits, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(entry, "=")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("quota: invalid limit %q, want group/kind=capacity:rate", entry)
+		}
+
+		group, kind, err := parseBucketType(parts[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		values := strings.Split(parts[1], ":")
+
+		if len(values) != 2 {
+			return nil, fmt.Errorf("quota: invalid limit %q, want group/kind=capacity:rate", entry)
+		}
+
+		capacity, err := strconv.ParseInt(values[0], 10, 64)
+
+		if err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("quota: invalid capacity in %q, must be > 0", entry)
+		}
+
+		limit := Limit{Capacity: capacity}
+
+		if values[1] == "sequenced" {
+			// Only writes for a tree or all trees can be attributed to sequencing runs
+			if kind != Write || group == User {
+				return nil, fmt.Errorf("quota: %v/%v buckets can't be refilled by sequencing", group, kind)
+			}
+
+			limit.Sequenced = true
+		} else if limit.TokensPerSecond, err = strconv.ParseFloat(values[1], 64); err != nil || limit.TokensPerSecond < 0 {
+			return nil, fmt.Errorf("quota: invalid rate in %q, must be >= 0 or sequenced", entry)
+		}
+
+		if _, ok := limits[group][kind]; ok {
+			return nil, fmt.Errorf("quota: more than one limit for %v/%v", group, kind)
+		}
+
+		if limits[group] == nil {
+			limits[group] = make(map[Kind]Limit)
+		}
+
+		limits[group][kind] = limit
+	}
+
+	return limits, nil
+}
+
+// parseBucketType parses the group and kind of a bucket, e.g. tree/write
+func parseBucketType(s string) (Group, Kind, error) {
+	parts := strings.Split(s, "/")
+
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("quota: invalid bucket type %q, want group/kind", s)
+	}
+
+	for group, groupName := range groupNames {
+		if groupName != parts[0] {
+			continue
+		}
+
+		for kind, kindName := range kindNames {
+			if kindName == parts[1] {
+				return group, kind, nil
+			}
+		}
+
+		return 0, 0, fmt.Errorf("quota: unknown kind %q", parts[1])
+	}
+
+	return 0, 0, fmt.Errorf("quota: unknown group %q", parts[0])
+}
+
+// Bucket is the state of a token bucket at a point in time
+type Bucket struct {
+	Tokens float64
+	// Updated is when Tokens was last worked out
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Capacity), Updated: now}
+}
+
+// Take refills the bucket for the time since it was updated and takes numTokens from it.
+// It returns the bucket's new state and whether it had enough tokens, if it didn't none
+// are taken.
+func (b Bucket) Take(limit Limit, numTokens int, now time.Time) (Bucket, bool) {
+	b = b.refill(limit, now)
+
+	if b.Tokens < float64(numTokens) {
+		return b, false
+	}
+
+	b.Tokens -= float64(numTokens)
+	return b, true
+}
+
+// Put refills the bucket for the time since it was updated and adds numTokens to it, up
+// to its capacity
+func (b Bucket) Put(limit Limit, numTokens int, now time.Time) Bucket {
+	b = b.refill(limit, now)
+	b.Tokens += float64(numTokens)
+
+	if b.Tokens > float64(limit.Capacity) {
+		b.Tokens = float64(limit.Capacity)
+	}
+
+	return b
+}
+
+// refill adds the tokens the bucket has gained since it was updated
+func (b Bucket) refill(limit Limit, now time.Time) Bucket {
+	// Guard against the clock going backwards, especially between servers sharing buckets
+	if !now.After(b.Updated) {
+		return b
+	}
+
+	tokens := b.Tokens + now.Sub(b.Updated).Seconds()*limit.TokensPerSecond
+
+	if tokens > float64(limit.Capacity) {
+		tokens = float64(limit.Capacity)
+	}
+
+	return Bucket{Tokens: tokens, Updated: now}
+}
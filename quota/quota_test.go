@@ -0,0 +1,142 @@
+package quota
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("global/write=1000:100,tree/write=100:10,user/read=50:0.5")
+
+	if err != nil {
+		t.Fatalf("Failed to parse limits: %v", err)
+	}
+
+	want := Limits{
+		Global: {Write: {Capacity: 1000, TokensPerSecond: 100}},
+		Tree:   {Write: {Capacity: 100, TokensPerSecond: 10}},
+		User:   {Read: {Capacity: 50, TokensPerSecond: 0.5}},
+	}
+
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("Got limits %v, want %v", limits, want)
+	}
+}
+
+func TestParseLimitsSequenced(t *testing.T) {
+	limits, err := ParseLimits("global/write=100000:sequenced,tree/write=5000:sequenced")
+
+	if err != nil {
+		t.Fatalf("Failed to parse limits: %v", err)
+	}
+
+	want := Limits{
+		Global: {Write: {Capacity: 100000, Sequenced: true}},
+		Tree:   {Write: {Capacity: 5000, Sequenced: true}},
+	}
+
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("Got limits %v, want %v", limits, want)
+	}
+}
+
+func TestParseLimitsEmpty(t *testing.T) {
+	limits, err := ParseLimits("")
+
+	if err != nil || len(limits) != 0 {
+		t.Errorf("Got %v, %v for no limits, want none", limits, err)
+	}
+}
+
+func TestParseLimitsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"global/write",
+		"global/write=10",
+		"global=10:1",
+		"everyone/write=10:1",
+		"global/delete=10:1",
+		"global/write=0:1",
+		"global/write=ten:1",
+		"global/write=10:-1",
+		"global/write=10:1,global/write=20:2",
+		"user/write=10:sequenced",
+		"tree/read=10:sequenced",
+	} {
+		if limits, err := ParseLimits(s); err == nil {
+			t.Errorf("Parsed invalid limits %q as %v", s, limits)
+		}
+	}
+}
+
+func TestSpecString(t *testing.T) {
+	for _, test := range []struct {
+		spec Spec
+		want string
+	}{
+		{Spec{Group: Global, Kind: Write}, "global/write"},
+		{Spec{Group: Tree, Kind: Read, TreeID: 123}, "tree/123/read"},
+		{Spec{Group: User, Kind: Write, User: "10.0.0.1"}, "user/10.0.0.1/write"},
+	} {
+		if got := test.spec.String(); got != test.want {
+			t.Errorf("Got %q for %#v, want %q", got, test.spec, test.want)
+		}
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{Capacity: 10, TokensPerSecond: 2}
+	start := time.Unix(1000, 0)
+
+	b, ok := NewBucket(limit, start).Take(limit, 8, start)
+
+	if !ok || b.Tokens != 2 {
+		t.Fatalf("Got %v, %v taking 8 tokens from a full bucket, want 2 tokens left", b, ok)
+	}
+
+	if _, ok := b.Take(limit, 3, start); ok {
+		t.Errorf("Took 3 tokens from a bucket with 2")
+	}
+
+	// After a second another 2 tokens have been added
+	b, ok = b.Take(limit, 4, start.Add(time.Second))
+
+	if !ok || b.Tokens != 0 {
+		t.Fatalf("Got %v, %v taking 4 tokens after a refill, want none left", b, ok)
+	}
+
+	// A bucket never refills past its capacity
+	b, ok = b.Take(limit, 0, start.Add(time.Hour))
+
+	if !ok || b.Tokens != 10 {
+		t.Errorf("Got %v, %v after a long wait, want a full bucket", b, ok)
+	}
+}
+
+func TestBucketTakeClockGoesBackwards(t *testing.T) {
+	limit := Limit{Capacity: 10, TokensPerSecond: 2}
+	start := time.Unix(1000, 0)
+
+	b, _ := NewBucket(limit, start).Take(limit, 10, start)
+	b, ok := b.Take(limit, 0, start.Add(-time.Minute))
+
+	if !ok || b.Tokens != 0 || !b.Updated.Equal(start) {
+		t.Errorf("Got %v, %v when the clock went backwards, want the bucket unchanged", b, ok)
+	}
+}
+
+func TestBucketPut(t *testing.T) {
+	limit := Limit{Capacity: 10, Sequenced: true}
+	start := time.Unix(1000, 0)
+
+	b, _ := NewBucket(limit, start).Take(limit, 8, start)
+
+	// Sequenced buckets don't refill over time
+	if b = b.Put(limit, 3, start.Add(time.Hour)); b.Tokens != 5 {
+		t.Errorf("Got %v tokens after putting 3 back in a bucket with 2, want 5", b.Tokens)
+	}
+
+	if b = b.Put(limit, 20, start.Add(time.Hour)); b.Tokens != 10 {
+		t.Errorf("Got %v tokens after overfilling the bucket, want its capacity of 10", b.Tokens)
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchSizeConfig controls how the sequencing batch size for a tree adapts between runs.
+type BatchSizeConfig struct {
+	// Initial is the batch size used for the first sequencing run of a tree
+	Initial int
+	// Min is the smallest batch size that slow runs can shrink the batch to
+	Min int
+	// Max is the largest batch size that a backlog of queued leaves can grow the batch to
+	Max int
+	// TargetLatency is the longest a sequencing run should take. Runs that take longer
+	// shrink the batch size. Zero disables shrinking on latency.
+	TargetLatency time.Duration
+}
+
+func (c BatchSizeConfig) validate() error {
+	if c.Min <= 0 {
+		return fmt.Errorf("min batch size must be > 0 but was %d", c.Min)
+	}
+
+	if c.Initial < c.Min || c.Initial > c.Max {
+		return fmt.Errorf("initial batch size %d must be between min %d and max %d", c.Initial, c.Min, c.Max)
+	}
+
+	if c.TargetLatency < 0 {
+		return fmt.Errorf("target latency must be >= 0 but was %v", c.TargetLatency)
+	}
+
+	return nil
+}
+
+// AdaptiveBatchSizer tracks the batch size to use for each tree. The size doubles while
+// runs dequeue a full batch, which means more leaves are waiting, and halves when a run
+// exceeds the target latency so transactions stay short. Once a tree's queue drains the
+// size steps back down towards its initial value.
+type AdaptiveBatchSizer struct {
+	defaults  BatchSizeConfig
+	overrides map[int64]BatchSizeConfig
+
+	mutex sync.Mutex
+	sizes map[int64]int
+}
+
+// NewAdaptiveBatchSizer creates an AdaptiveBatchSizer that uses defaults for all trees that
+// don't have an entry in overrides. Override entries with no target latency inherit the
+// default one.
+func NewAdaptiveBatchSizer(defaults BatchSizeConfig, overrides map[int64]BatchSizeConfig) (*AdaptiveBatchSizer, error) {
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+
+	configs := make(map[int64]BatchSizeConfig, len(overrides))
+
+	for treeID, config := range overrides {
+		if config.TargetLatency == 0 {
+			config.TargetLatency = defaults.TargetLatency
+		}
+
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid batch size config for tree %d: %v", treeID, err)
+		}
+
+		configs[treeID] = config
+	}
+
+	return &AdaptiveBatchSizer{defaults: defaults, overrides: configs, sizes: make(map[int64]int)}, nil
+}
+
+func (a *AdaptiveBatchSizer) configForTree(treeID int64) BatchSizeConfig {
+	if config, ok := a.overrides[treeID]; ok {
+		return config
+	}
+
+	return a.defaults
+}
+
+// BatchSize returns the number of leaves the next sequencing run for a tree should dequeue.
+func (a *AdaptiveBatchSizer) BatchSize(treeID int64) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if size, ok := a.sizes[treeID]; ok {
+		return size
+	}
+
+	return a.configForTree(treeID).Initial
+}
+
+// RecordRun adjusts the batch size for a tree after a sequencing run that dequeued leaves
+// out of a batch of limit and took elapsed to complete.
+func (a *AdaptiveBatchSizer) RecordRun(treeID int64, limit, leaves int, elapsed time.Duration) {
+	config := a.configForTree(treeID)
+	size := limit
+
+	switch {
+	case config.TargetLatency > 0 && elapsed > config.TargetLatency:
+		size = limit / 2
+	case leaves >= limit:
+		size = limit * 2
+	case leaves < limit/2 && limit > config.Initial:
+		size = limit / 2
+		if size < config.Initial {
+			size = config.Initial
+		}
+	}
+
+	if size < config.Min {
+		size = config.Min
+	}
+
+	if size > config.Max {
+		size = config.Max
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.sizes[treeID] = size
+}
+
+// RecordFailure halves the batch size for a tree after a sequencing run with a batch of
+// limit failed. A batch too big to be sequenced, e.g. within the deadline or the storage's
+// transaction limits, would otherwise be retried at the same size for good.
+func (a *AdaptiveBatchSizer) RecordFailure(treeID int64, limit int) {
+	config := a.configForTree(treeID)
+	size := limit / 2
+
+	if size < config.Min {
+		size = config.Min
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.sizes[treeID] = size
+}
+
+// ParseBatchSizeOverrides parses per tree batch size settings from a comma separated list
+// of treeID=initial:min:max entries. The target latency of each entry is taken from
+// defaults.
+func ParseBatchSizeOverrides(s string, defaults BatchSizeConfig) (map[int64]BatchSizeConfig, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[int64]BatchSizeConfig, len(settings))
+
+	for treeID, setting := range settings {
+		sizes := strings.Split(setting, ":")
+
+		if len(sizes) != 3 {
+			return nil, fmt.Errorf("batch size override %q for tree %d is not of the form initial:min:max", setting, treeID)
+		}
+
+		var values [3]int
+
+		for i, size := range sizes {
+			if values[i], err = strconv.Atoi(size); err != nil {
+				return nil, fmt.Errorf("invalid batch size in override for tree %d: %v", treeID, err)
+			}
+		}
+
+		overrides[treeID] = BatchSizeConfig{Initial: values[0], Min: values[1], Max: values[2], TargetLatency: defaults.TargetLatency}
+	}
+
+	return overrides, nil
+}
+
+// parseTreeSettings splits a comma separated list of treeID=setting entries, as used by
+// flags that configure individual trees.
+func parseTreeSettings(s string) (map[int64]string, error) {
+	settings := make(map[int64]string)
+
+	if len(s) == 0 {
+		return settings, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(entry, "=")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("tree setting %q is not of the form treeID=value", entry)
+		}
+
+		treeID, err := strconv.ParseInt(parts[0], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree id in tree setting %q: %v", entry, err)
+		}
+
+		if _, exists := settings[treeID]; exists {
+			return nil, fmt.Errorf("duplicate setting for tree %d", treeID)
+		}
+
+		settings[treeID] = parts[1]
+	}
+
+	return settings, nil
+}
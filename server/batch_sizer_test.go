@@ -0,0 +1,152 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+var testBatchSizeConfig = BatchSizeConfig{Initial: 50, Min: 10, Max: 400, TargetLatency: time.Second}
+
+func TestAdaptiveBatchSizerRejectsBadConfig(t *testing.T) {
+	for _, config := range []BatchSizeConfig{
+		{Initial: 50, Min: 0, Max: 100},
+		{Initial: 5, Min: 10, Max: 100},
+		{Initial: 500, Min: 10, Max: 100},
+		{Initial: 50, Min: 10, Max: 100, TargetLatency: -time.Second},
+	} {
+		if _, err := NewAdaptiveBatchSizer(config, nil); err == nil {
+			t.Errorf("Created batch sizer with bad default config: %v", config)
+		}
+
+		if _, err := NewAdaptiveBatchSizer(testBatchSizeConfig, map[int64]BatchSizeConfig{1: config}); err == nil {
+			t.Errorf("Created batch sizer with bad override config: %v", config)
+		}
+	}
+}
+
+func TestAdaptiveBatchSizerAdapts(t *testing.T) {
+	sizer, err := NewAdaptiveBatchSizer(testBatchSizeConfig, nil)
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	var tests = []struct {
+		leaves  int
+		elapsed time.Duration
+		want    int
+	}{
+		// Full batches grow until max
+		{50, time.Millisecond, 100},
+		{100, time.Millisecond, 200},
+		{200, time.Millisecond, 400},
+		{400, time.Millisecond, 400},
+		// A partly filled batch doesn't change the size
+		{300, time.Millisecond, 400},
+		// Slow runs shrink even when the batch was full
+		{400, time.Second * 2, 200},
+		// A drained queue steps back down to the initial size
+		{10, time.Millisecond, 100},
+		{0, time.Millisecond, 50},
+		{0, time.Millisecond, 50},
+		// Slow runs shrink until min
+		{50, time.Second * 2, 25},
+		{25, time.Second * 2, 12},
+		{12, time.Second * 2, 10},
+		{10, time.Second * 2, 10},
+	}
+
+	for _, test := range tests {
+		limit := sizer.BatchSize(1)
+		sizer.RecordRun(1, limit, test.leaves, test.elapsed)
+
+		if got := sizer.BatchSize(1); got != test.want {
+			t.Errorf("After run of %d leaves from %d in %v got batch size %d, want %d", test.leaves, limit, test.elapsed, got, test.want)
+		}
+	}
+
+	// Other trees are unaffected
+	if got, want := sizer.BatchSize(2), testBatchSizeConfig.Initial; got != want {
+		t.Errorf("Got batch size %d for tree with no runs, want %d", got, want)
+	}
+}
+
+func TestAdaptiveBatchSizerOverrides(t *testing.T) {
+	sizer, err := NewAdaptiveBatchSizer(testBatchSizeConfig, map[int64]BatchSizeConfig{7: {Initial: 1000, Min: 500, Max: 1500}})
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	if got, want := sizer.BatchSize(7), 1000; got != want {
+		t.Errorf("Got initial batch size %d for overridden tree, want %d", got, want)
+	}
+
+	sizer.RecordRun(7, 1000, 1000, time.Millisecond)
+	if got, want := sizer.BatchSize(7), 1500; got != want {
+		t.Errorf("Got batch size %d after full batch for overridden tree, want %d", got, want)
+	}
+
+	// The default target latency applies to the override
+	sizer.RecordRun(7, 1500, 1500, time.Second*2)
+	if got, want := sizer.BatchSize(7), 750; got != want {
+		t.Errorf("Got batch size %d after slow run for overridden tree, want %d", got, want)
+	}
+}
+
+func TestAdaptiveBatchSizerShrinksAfterFailures(t *testing.T) {
+	sizer, err := NewAdaptiveBatchSizer(testBatchSizeConfig, nil)
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	// Failed runs shrink the batch until min, however big it had grown
+	sizer.RecordRun(1, 50, 50, time.Millisecond)
+	for _, want := range []int{50, 25, 12, 10, 10} {
+		sizer.RecordFailure(1, sizer.BatchSize(1))
+		if got := sizer.BatchSize(1); got != want {
+			t.Errorf("Got batch size %d after a failed run, want %d", got, want)
+		}
+	}
+}
+
+func TestParseBatchSizeOverrides(t *testing.T) {
+	overrides, err := ParseBatchSizeOverrides("1=100:20:200,7=1000:500:1500", testBatchSizeConfig)
+	if err != nil {
+		t.Fatalf("Failed to parse overrides: %v", err)
+	}
+
+	want := map[int64]BatchSizeConfig{
+		1: {Initial: 100, Min: 20, Max: 200, TargetLatency: time.Second},
+		7: {Initial: 1000, Min: 500, Max: 1500, TargetLatency: time.Second},
+	}
+
+	if len(overrides) != len(want) {
+		t.Fatalf("Got %d overrides, want %d", len(overrides), len(want))
+	}
+
+	for treeID, config := range want {
+		if got := overrides[treeID]; got != config {
+			t.Errorf("Got override %v for tree %d, want %v", got, treeID, config)
+		}
+	}
+
+	if overrides, err := ParseBatchSizeOverrides("", testBatchSizeConfig); err != nil || len(overrides) != 0 {
+		t.Errorf("Got %v, %v parsing empty overrides, want no overrides", overrides, err)
+	}
+}
+
+func TestParseBatchSizeOverridesRejectsBadInput(t *testing.T) {
+	for _, s := range []string{
+		"1",
+		"1=100",
+		"1=100:20",
+		"x=100:20:200",
+		"1=100:x:200",
+		"1=100:20:200=3",
+		"1=100:20:x",
+		"1=100:20:200,1=100:20:200",
+	} {
+		if _, err := ParseBatchSizeOverrides(s, testBatchSizeConfig); err == nil {
+			t.Errorf("Parsed bad batch size overrides: %q", s)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+// Package config reads the configuration of the log and map servers from a file in protobuf
+// text format, as an alternative to setting each of their flags. The settings in a config
+// are given to the servers as the values of the flags they correspond to, so flags and
+// config files configure the same things in the same ways.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/auth"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+)
+
+// LoadLogServerConfig reads a LogServerConfig in protobuf text format from a file and checks
+// that it's valid
+func LoadLogServerConfig(path string) (*LogServerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseLogServerConfig(data)
+}
+
+// ParseLogServerConfig parses a LogServerConfig in protobuf text format and checks that it's
+// valid
+func ParseLogServerConfig(data []byte) (*LogServerConfig, error) {
+	var cfg LogServerConfig
+
+	if err := proto.UnmarshalText(string(data), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse log server config: %v", err)
+	}
+
+	if err := ValidateLogServerConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// LoadMapServerConfig reads a MapServerConfig in protobuf text format from a file and checks
+// that it's valid
+func LoadMapServerConfig(path string) (*MapServerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMapServerConfig(data)
+}
+
+// ParseMapServerConfig parses a MapServerConfig in protobuf text format and checks that it's
+// valid
+func ParseMapServerConfig(data []byte) (*MapServerConfig, error) {
+	var cfg MapServerConfig
+
+	if err := proto.UnmarshalText(string(data), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse map server config: %v", err)
+	}
+
+	if err := ValidateMapServerConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// ValidateLogServerConfig checks the settings of a log server. The files and databases it
+// refers to aren't opened.
+func ValidateLogServerConfig(cfg *LogServerConfig) error {
+	if err := validateCommon(cfg.Port, cfg.Storage, cfg.Tls, cfg.Grants); err != nil {
+		return err
+	}
+
+	if q := cfg.Quota; q != nil {
+		if len(q.System) > 0 && q.System != "memory" && q.System != "etcd" {
+			return fmt.Errorf("unknown quota system: %s", q.System)
+		}
+
+		if q.System == "etcd" && (cfg.Etcd == nil || len(cfg.Etcd.Servers) == 0) {
+			return errors.New("etcd quotas need etcd servers to be set")
+		}
+
+		if _, err := quota.ParseLimits(quotaLimits(q.Limits)); err != nil {
+			return err
+		}
+	}
+
+	if s := cfg.Sequencer; s != nil {
+		if s.BatchSize < 0 || s.Workers < 0 || s.MaxRunsPerPass < 0 {
+			return errors.New("sequencer batch_size, workers and max_runs_per_pass must be >= 0")
+		}
+
+		if err := validateDurations(map[string]string{"sleep_between_runs": s.SleepBetweenRuns, "signer_sleep_between_runs": s.SignerSleepBetweenRuns}); err != nil {
+			return fmt.Errorf("sequencer: %v", err)
+		}
+	}
+
+	trees := make(map[int64]bool)
+
+	for _, tree := range cfg.Trees {
+		if tree.TreeId <= 0 {
+			return fmt.Errorf("invalid tree_id %d, must be > 0", tree.TreeId)
+		}
+
+		if trees[tree.TreeId] {
+			return fmt.Errorf("duplicate config for tree %d", tree.TreeId)
+		}
+
+		trees[tree.TreeId] = true
+
+		if err := validateLogTreeConfig(tree); err != nil {
+			return fmt.Errorf("tree %d: %v", tree.TreeId, err)
+		}
+	}
+
+	return nil
+}
+
+// validateLogTreeConfig checks the settings of one log
+func validateLogTreeConfig(tree *LogTreeConfig) error {
+	if tree.SequencerWeight < 0 || tree.MaxLeafSize < 0 {
+		return errors.New("sequencer_weight and max_leaf_size must be >= 0")
+	}
+
+	if b := tree.BatchSize; b != nil && (b.Min <= 0 || b.Initial < b.Min || b.Max < b.Initial) {
+		return fmt.Errorf("batch size %d:%d:%d isn't 0 < min <= initial <= max", b.Initial, b.Min, b.Max)
+	}
+
+	if len(tree.LeafDataKeyFile) > 0 && len(tree.LeafBlobStore) == 0 {
+		return errors.New("leaf_data_key_file needs a leaf_blob_store to keep the encrypted values in")
+	}
+
+	if len(tree.LeafDataKeyWrapper) > 0 && len(tree.LeafDataKeyFile) == 0 {
+		return errors.New("leaf_data_key_wrapper needs a leaf_data_key_file of keys to unwrap")
+	}
+
+	if tree.MinQueuePriority > 0 || tree.MaxQueuePriority < 0 {
+		return fmt.Errorf("queue priorities %d:%d don't include 0", tree.MinQueuePriority, tree.MaxQueuePriority)
+	}
+
+	if len(tree.MaxMergeDelay) > 0 {
+		if d, err := time.ParseDuration(tree.MaxMergeDelay); err != nil || d <= 0 {
+			return fmt.Errorf("invalid max_merge_delay %q, must be a positive duration", tree.MaxMergeDelay)
+		}
+	}
+
+	return nil
+}
+
+// ValidateMapServerConfig checks the settings of a map server. The files and databases it
+// refers to aren't opened.
+func ValidateMapServerConfig(cfg *MapServerConfig) error {
+	if err := validateCommon(cfg.Port, cfg.Storage, cfg.Tls, cfg.Grants); err != nil {
+		return err
+	}
+
+	trees := make(map[int64]bool)
+
+	for _, tree := range cfg.Trees {
+		if tree.TreeId <= 0 {
+			return fmt.Errorf("invalid tree_id %d, must be > 0", tree.TreeId)
+		}
+
+		if trees[tree.TreeId] {
+			return fmt.Errorf("duplicate config for tree %d", tree.TreeId)
+		}
+
+		trees[tree.TreeId] = true
+
+		if len(tree.MaxRootAge) > 0 {
+			if d, err := time.ParseDuration(tree.MaxRootAge); err != nil || d <= 0 {
+				return fmt.Errorf("tree %d: invalid max_root_age %q, must be a positive duration", tree.TreeId, tree.MaxRootAge)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCommon checks the settings that log and map servers share
+func validateCommon(port int32, s *StorageConfig, tls *TLSConfig, grants []*Grant) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+
+	if s != nil {
+		if s.MaxOpenConns < 0 || s.MaxIdleConns < 0 || s.BreakerFailures < 0 {
+			return errors.New("storage max_open_conns, max_idle_conns and breaker_failures must be >= 0")
+		}
+
+		if err := validateDurations(map[string]string{"conn_max_lifetime": s.ConnMaxLifetime, "query_timeout": s.QueryTimeout, "breaker_open_duration": s.BreakerOpenDuration}); err != nil {
+			return fmt.Errorf("storage: %v", err)
+		}
+
+		if _, err := storage.ParseSubtreeShards(subtreeShards(s.SubtreeShards)); err != nil {
+			return err
+		}
+	}
+
+	if tls != nil && (len(tls.CertFile) == 0) != (len(tls.KeyFile) == 0) {
+		return errors.New("tls cert_file and key_file must be set together")
+	}
+
+	for _, grant := range grants {
+		if len(grant.Identity) == 0 || strings.ContainsAny(grant.Identity, ",=") {
+			return fmt.Errorf("invalid grant identity %q", grant.Identity)
+		}
+	}
+
+	_, err := auth.ParseGrants(grantList(grants))
+	return err
+}
+
+// validateDurations checks that the values of the named durations that are set parse
+func validateDurations(durations map[string]string) error {
+	for name, d := range durations {
+		if len(d) == 0 {
+			continue
+		}
+
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// LogServerFlags returns the values of the log server flags that are set by cfg, by flag name
+func LogServerFlags(cfg *LogServerConfig) map[string]string {
+	values := commonFlags(cfg.Port, cfg.Storage, cfg.Tls, cfg.Grants, cfg.Monitoring, cfg.PrivateKeyFile, cfg.PrivateKeyPassword)
+
+	if q := cfg.Quota; q != nil {
+		setString(values, "quota_system", q.System)
+		setString(values, "quota_limits", quotaLimits(q.Limits))
+	}
+
+	if e := cfg.Etcd; e != nil {
+		setString(values, "etcd_servers", strings.Join(e.Servers, ","))
+		setString(values, "etcd_election_prefix", e.ElectionPrefix)
+		setString(values, "etcd_quota_prefix", e.QuotaPrefix)
+		setInt(values, "election_lease_ttl_secs", e.ElectionLeaseTtlSecs)
+	}
+
+	if s := cfg.Sequencer; s != nil {
+		setInt(values, "batch_size", s.BatchSize)
+		setString(values, "sequencer_sleep_between_runs", s.SleepBetweenRuns)
+		setString(values, "signer_sleep_between_runs", s.SignerSleepBetweenRuns)
+		setInt(values, "sequencer_workers", s.Workers)
+		setInt(values, "sequencer_max_runs_per_pass", s.MaxRunsPerPass)
+		if s.EnforceMaxMergeDelay {
+			values["enforce_max_merge_delay"] = "true"
+		}
+	}
+
+	var weights, batchSizes, leafSizes, queuePriorities, mergeDelays, blobStores, dataKeyFiles, dataKeyWrappers []string
+
+	for _, tree := range cfg.Trees {
+		if tree.SequencerWeight > 0 {
+			weights = append(weights, fmt.Sprintf("%d=%d", tree.TreeId, tree.SequencerWeight))
+		}
+		if b := tree.BatchSize; b != nil {
+			batchSizes = append(batchSizes, fmt.Sprintf("%d=%d:%d:%d", tree.TreeId, b.Initial, b.Min, b.Max))
+		}
+		if tree.MaxLeafSize > 0 {
+			leafSizes = append(leafSizes, fmt.Sprintf("%d=%d", tree.TreeId, tree.MaxLeafSize))
+		}
+		if tree.MinQueuePriority != 0 || tree.MaxQueuePriority != 0 {
+			queuePriorities = append(queuePriorities, fmt.Sprintf("%d=%d:%d", tree.TreeId, tree.MinQueuePriority, tree.MaxQueuePriority))
+		}
+		if len(tree.MaxMergeDelay) > 0 {
+			mergeDelays = append(mergeDelays, fmt.Sprintf("%d=%s", tree.TreeId, tree.MaxMergeDelay))
+		}
+		if len(tree.LeafBlobStore) > 0 {
+			blobStores = append(blobStores, fmt.Sprintf("%d=%s", tree.TreeId, tree.LeafBlobStore))
+		}
+		if len(tree.LeafDataKeyFile) > 0 {
+			dataKeyFiles = append(dataKeyFiles, fmt.Sprintf("%d=%s", tree.TreeId, tree.LeafDataKeyFile))
+		}
+		if len(tree.LeafDataKeyWrapper) > 0 {
+			dataKeyWrappers = append(dataKeyWrappers, fmt.Sprintf("%d=%s", tree.TreeId, tree.LeafDataKeyWrapper))
+		}
+	}
+
+	setString(values, "tree_sequencer_weights", strings.Join(weights, ","))
+	setString(values, "tree_batch_sizes", strings.Join(batchSizes, ","))
+	setString(values, "tree_max_leaf_sizes", strings.Join(leafSizes, ","))
+	setString(values, "tree_queue_priorities", strings.Join(queuePriorities, ","))
+	setString(values, "tree_max_merge_delays", strings.Join(mergeDelays, ","))
+	setString(values, "leaf_blob_stores", strings.Join(blobStores, ","))
+	setString(values, "leaf_data_key_files", strings.Join(dataKeyFiles, ","))
+	setString(values, "leaf_data_key_wrappers", strings.Join(dataKeyWrappers, ","))
+	setString(values, "http_endpoint", cfg.HttpEndpoint)
+
+	// Batch sizes for single logs are only used if batch sizes adapt
+	if len(batchSizes) > 0 {
+		values["adaptive_batch_size"] = "true"
+	}
+
+	return values
+}
+
+// MapServerFlags returns the values of the map server flags that are set by cfg, by flag name
+func MapServerFlags(cfg *MapServerConfig) map[string]string {
+	values := commonFlags(cfg.Port, cfg.Storage, cfg.Tls, cfg.Grants, cfg.Monitoring, cfg.PrivateKeyFile, cfg.PrivateKeyPassword)
+
+	var rootAges []string
+
+	for _, tree := range cfg.Trees {
+		if len(tree.MaxRootAge) > 0 {
+			rootAges = append(rootAges, fmt.Sprintf("%d=%s", tree.TreeId, tree.MaxRootAge))
+		}
+	}
+
+	setString(values, "tree_max_root_ages", strings.Join(rootAges, ","))
+	setString(values, "http_endpoint", cfg.HttpEndpoint)
+
+	if cfg.StrictMaxRootAge {
+		values["strict_max_root_age"] = "true"
+	}
+
+	return values
+}
+
+// commonFlags returns the values of the flags that log and map servers share
+func commonFlags(port int32, s *StorageConfig, tls *TLSConfig, grants []*Grant, m *MonitoringConfig, keyFile, keyPassword string) map[string]string {
+	values := make(map[string]string)
+	setInt(values, "port", port)
+
+	if s != nil {
+		setString(values, "storage_system", s.System)
+		setString(values, "storage_uri", s.Uri)
+		setInt(values, "db_max_open_conns", s.MaxOpenConns)
+		setInt(values, "db_max_idle_conns", s.MaxIdleConns)
+		setString(values, "db_conn_max_lifetime", s.ConnMaxLifetime)
+		setString(values, "db_query_timeout", s.QueryTimeout)
+		setInt(values, "db_breaker_failures", s.BreakerFailures)
+		setString(values, "db_breaker_open_duration", s.BreakerOpenDuration)
+		setString(values, "subtree_shards", subtreeShards(s.SubtreeShards))
+
+		if s.AutoMigrate {
+			values["auto_migrate"] = "true"
+		}
+	}
+
+	if tls != nil {
+		setString(values, "tls_cert_file", tls.CertFile)
+		setString(values, "tls_key_file", tls.KeyFile)
+		setString(values, "tls_client_ca_file", tls.ClientCaFile)
+	}
+
+	setString(values, "grants", grantList(grants))
+
+	if m != nil {
+		setString(values, "metrics_endpoint", m.MetricsEndpoint)
+		setString(values, "health_endpoint", m.HealthEndpoint)
+
+		setInt(values, "v", m.LogVerbosity)
+
+		if m.TraceSampleRate != 0 {
+			values["trace_sample_rate"] = strconv.FormatFloat(m.TraceSampleRate, 'g', -1, 64)
+		}
+	}
+
+	setString(values, "private_key_file", keyFile)
+	setString(values, "private_key_password", keyPassword)
+
+	return values
+}
+
+func setString(values map[string]string, name, value string) {
+	if len(value) > 0 {
+		values[name] = value
+	}
+}
+
+func setInt(values map[string]string, name string, value int32) {
+	if value != 0 {
+		values[name] = strconv.Itoa(int(value))
+	}
+}
+
+// subtreeShards formats shards as for storage.ParseSubtreeShards
+func subtreeShards(shards []*SubtreeShard) string {
+	entries := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		entries = append(entries, shard.Name+"="+shard.Dsn)
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// grantList formats grants as for auth.ParseGrants
+func grantList(grants []*Grant) string {
+	entries := make([]string, 0, len(grants))
+
+	for _, grant := range grants {
+		tree := "*"
+		if grant.TreeId != 0 {
+			tree = strconv.FormatInt(grant.TreeId, 10)
+		}
+
+		entries = append(entries, fmt.Sprintf("%s/%s=%s", grant.Identity, tree, strings.Join(grant.Permissions, "+")))
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// quotaLimits formats limits as for quota.ParseLimits
+func quotaLimits(limits []*QuotaLimit) string {
+	entries := make([]string, 0, len(limits))
+
+	for _, limit := range limits {
+		rate := strconv.FormatFloat(limit.TokensPerSecond, 'g', -1, 64)
+		if limit.Sequenced {
+			rate = "sequenced"
+		}
+
+		entries = append(entries, fmt.Sprintf("%s/%s=%d:%s", limit.Group, limit.Kind, limit.Capacity, rate))
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// SetFlags sets the flags in fs to values, by flag name, except for those that were given on
+// the command line, which override the config
+func SetFlags(fs *flag.FlagSet, values map[string]string) error {
+	given := GivenFlags(fs)
+
+	for name, value := range values {
+		if given[name] {
+			continue
+		}
+
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("config sets unknown flag %s", name)
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config sets invalid value %q for flag %s: %v", value, name, err)
+		}
+	}
+
+	return nil
+}
+
+// GivenFlags returns the names of the flags that have been set in fs, which are those given
+// on the command line if it's called straight after fs is parsed
+func GivenFlags(fs *flag.FlagSet) map[string]bool {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return given
+}
+
+// ReloadFlags sets the named flags in fs to their values from a reloaded config, or back to
+// their defaults if the config no longer sets them, and then calls apply to put them into
+// effect. Flags in given keep the values they were given on the command line. If a value
+// can't be set or apply fails the flags are set back to what they were.
+func ReloadFlags(fs *flag.FlagSet, values map[string]string, given map[string]bool, names []string, apply func() error) error {
+	previous := make(map[string]string)
+
+	restore := func() {
+		for name, value := range previous {
+			fs.Set(name, value)
+		}
+	}
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+
+		if f == nil {
+			return fmt.Errorf("unknown flag %s", name)
+		}
+
+		if given[name] {
+			continue
+		}
+
+		value, ok := values[name]
+
+		if !ok {
+			value = f.DefValue
+		}
+
+		previous[name] = f.Value.String()
+
+		if err := fs.Set(name, value); err != nil {
+			restore()
+			return fmt.Errorf("config sets invalid value %q for flag %s: %v", value, name, err)
+		}
+	}
+
+	if err := apply(); err != nil {
+		restore()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,513 @@
+// Code generated by protoc-gen-go.
+// source: github.com/google/trillian/server/config/config.proto
+// DO NOT EDIT!
+
+/*
+Package config is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/google/trillian/server/config/config.proto
+
+It has these top-level messages:
+	StorageConfig
+	SubtreeShard
+	TLSConfig
+	Grant
+	MonitoringConfig
+	QuotaLimit
+	QuotaConfig
+	EtcdConfig
+	SequencerConfig
+	BatchSize
+	LogTreeConfig
+	LogServerConfig
+	MapServerConfig
+	MapTreeConfig
+*/
+package config
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// StorageConfig selects the database a server keeps its trees in and how it's connected to.
+type StorageConfig struct {
+	// The name of a registered storage system, e.g. "mysql", and the URI of its database.
+	// Flags storage_system and storage_uri.
+	System string `protobuf:"bytes,1,opt,name=system" json:"system,omitempty"`
+	Uri    string `protobuf:"bytes,2,opt,name=uri" json:"uri,omitempty"`
+	// Limits on the connections to the database, flags db_max_open_conns, db_max_idle_conns,
+	// db_conn_max_lifetime and db_query_timeout.
+	MaxOpenConns    int32  `protobuf:"varint,3,opt,name=max_open_conns,json=maxOpenConns" json:"max_open_conns,omitempty"`
+	MaxIdleConns    int32  `protobuf:"varint,4,opt,name=max_idle_conns,json=maxIdleConns" json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string `protobuf:"bytes,5,opt,name=conn_max_lifetime,json=connMaxLifetime" json:"conn_max_lifetime,omitempty"`
+	QueryTimeout    string `protobuf:"bytes,6,opt,name=query_timeout,json=queryTimeout" json:"query_timeout,omitempty"`
+	// Failures in a row after which the circuit breaker refuses requests for
+	// breaker_open_duration, flags db_breaker_failures and db_breaker_open_duration.
+	BreakerFailures     int32  `protobuf:"varint,7,opt,name=breaker_failures,json=breakerFailures" json:"breaker_failures,omitempty"`
+	BreakerOpenDuration string `protobuf:"bytes,8,opt,name=breaker_open_duration,json=breakerOpenDuration" json:"breaker_open_duration,omitempty"`
+	// Whether missing schema migrations are applied at startup, flag auto_migrate.
+	AutoMigrate bool `protobuf:"varint,9,opt,name=auto_migrate,json=autoMigrate" json:"auto_migrate,omitempty"`
+	// The databases that the subtrees of new trees are spread over, flag subtree_shards.
+	SubtreeShards []*SubtreeShard `protobuf:"bytes,10,rep,name=subtree_shards,json=subtreeShards" json:"subtree_shards,omitempty"`
+}
+
+func (m *StorageConfig) Reset()                    { *m = StorageConfig{} }
+func (m *StorageConfig) String() string            { return proto.CompactTextString(m) }
+func (*StorageConfig) ProtoMessage()               {}
+func (*StorageConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *StorageConfig) GetSubtreeShards() []*SubtreeShard {
+	if m != nil {
+		return m.SubtreeShards
+	}
+	return nil
+}
+
+// SubtreeShard names one of the databases subtrees can be kept in.
+type SubtreeShard struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Dsn  string `protobuf:"bytes,2,opt,name=dsn" json:"dsn,omitempty"`
+}
+
+func (m *SubtreeShard) Reset()                    { *m = SubtreeShard{} }
+func (m *SubtreeShard) String() string            { return proto.CompactTextString(m) }
+func (*SubtreeShard) ProtoMessage()               {}
+func (*SubtreeShard) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+// TLSConfig holds the PEM files RPCs are served over TLS with, flags tls_cert_file,
+// tls_key_file and tls_client_ca_file.
+type TLSConfig struct {
+	CertFile string `protobuf:"bytes,1,opt,name=cert_file,json=certFile" json:"cert_file,omitempty"`
+	KeyFile  string `protobuf:"bytes,2,opt,name=key_file,json=keyFile" json:"key_file,omitempty"`
+	// The CAs client certificates must be signed by, clients needn't present one if empty.
+	ClientCaFile string `protobuf:"bytes,3,opt,name=client_ca_file,json=clientCaFile" json:"client_ca_file,omitempty"`
+}
+
+func (m *TLSConfig) Reset()                    { *m = TLSConfig{} }
+func (m *TLSConfig) String() string            { return proto.CompactTextString(m) }
+func (*TLSConfig) ProtoMessage()               {}
+func (*TLSConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+// Grant gives a client permissions on a tree, see flag grants.
+type Grant struct {
+	// A client certificate common name, or "*" for all clients.
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	// The tree the permissions are for, zero for all trees.
+	TreeId int64 `protobuf:"varint,2,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// Any of "read", "write" and "admin".
+	Permissions []string `protobuf:"bytes,3,rep,name=permissions" json:"permissions,omitempty"`
+}
+
+func (m *Grant) Reset()                    { *m = Grant{} }
+func (m *Grant) String() string            { return proto.CompactTextString(m) }
+func (*Grant) ProtoMessage()               {}
+func (*Grant) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+// MonitoringConfig says where a server's metrics and health are served and how much of its
+// work is traced, flags metrics_endpoint, health_endpoint and trace_sample_rate.
+type MonitoringConfig struct {
+	MetricsEndpoint string  `protobuf:"bytes,1,opt,name=metrics_endpoint,json=metricsEndpoint" json:"metrics_endpoint,omitempty"`
+	HealthEndpoint  string  `protobuf:"bytes,2,opt,name=health_endpoint,json=healthEndpoint" json:"health_endpoint,omitempty"`
+	TraceSampleRate float64 `protobuf:"fixed64,3,opt,name=trace_sample_rate,json=traceSampleRate" json:"trace_sample_rate,omitempty"`
+	// The verbosity of glog's V logging, flag v.
+	LogVerbosity int32 `protobuf:"varint,4,opt,name=log_verbosity,json=logVerbosity" json:"log_verbosity,omitempty"`
+}
+
+func (m *MonitoringConfig) Reset()                    { *m = MonitoringConfig{} }
+func (m *MonitoringConfig) String() string            { return proto.CompactTextString(m) }
+func (*MonitoringConfig) ProtoMessage()               {}
+func (*MonitoringConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+// QuotaLimit is the size and refill rate of a group of token buckets, see quota.ParseLimits.
+type QuotaLimit struct {
+	// One of "global", "tree" or "user".
+	Group string `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	// One of "read" or "write".
+	Kind     string `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	Capacity int64  `protobuf:"varint,3,opt,name=capacity" json:"capacity,omitempty"`
+	// Tokens added to the buckets each second.
+	TokensPerSecond float64 `protobuf:"fixed64,4,opt,name=tokens_per_second,json=tokensPerSecond" json:"tokens_per_second,omitempty"`
+	// Refill the buckets as leaves are sequenced rather than over time, tokens_per_second is
+	// ignored.
+	Sequenced bool `protobuf:"varint,5,opt,name=sequenced" json:"sequenced,omitempty"`
+}
+
+func (m *QuotaLimit) Reset()                    { *m = QuotaLimit{} }
+func (m *QuotaLimit) String() string            { return proto.CompactTextString(m) }
+func (*QuotaLimit) ProtoMessage()               {}
+func (*QuotaLimit) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+// QuotaConfig limits the requests a log server accepts, flags quota_system and
+// quota_limits.
+type QuotaConfig struct {
+	// "memory" or "etcd".
+	System string        `protobuf:"bytes,1,opt,name=system" json:"system,omitempty"`
+	Limits []*QuotaLimit `protobuf:"bytes,2,rep,name=limits" json:"limits,omitempty"`
+}
+
+func (m *QuotaConfig) Reset()                    { *m = QuotaConfig{} }
+func (m *QuotaConfig) String() string            { return proto.CompactTextString(m) }
+func (*QuotaConfig) ProtoMessage()               {}
+func (*QuotaConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *QuotaConfig) GetLimits() []*QuotaLimit {
+	if m != nil {
+		return m.Limits
+	}
+	return nil
+}
+
+// EtcdConfig holds the etcd cluster that log servers elect masters and share quotas through,
+// flags etcd_servers, etcd_election_prefix, etcd_quota_prefix and election_lease_ttl_secs.
+type EtcdConfig struct {
+	Servers              []string `protobuf:"bytes,1,rep,name=servers" json:"servers,omitempty"`
+	ElectionPrefix       string   `protobuf:"bytes,2,opt,name=election_prefix,json=electionPrefix" json:"election_prefix,omitempty"`
+	QuotaPrefix          string   `protobuf:"bytes,3,opt,name=quota_prefix,json=quotaPrefix" json:"quota_prefix,omitempty"`
+	ElectionLeaseTtlSecs int32    `protobuf:"varint,4,opt,name=election_lease_ttl_secs,json=electionLeaseTtlSecs" json:"election_lease_ttl_secs,omitempty"`
+}
+
+func (m *EtcdConfig) Reset()                    { *m = EtcdConfig{} }
+func (m *EtcdConfig) String() string            { return proto.CompactTextString(m) }
+func (*EtcdConfig) ProtoMessage()               {}
+func (*EtcdConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+// SequencerConfig controls how a log server sequences and signs its logs, flags batch_size,
+// sequencer_sleep_between_runs, signer_sleep_between_runs, sequencer_workers,
+// sequencer_max_runs_per_pass and enforce_max_merge_delay.
+type SequencerConfig struct {
+	BatchSize              int32  `protobuf:"varint,1,opt,name=batch_size,json=batchSize" json:"batch_size,omitempty"`
+	SleepBetweenRuns       string `protobuf:"bytes,2,opt,name=sleep_between_runs,json=sleepBetweenRuns" json:"sleep_between_runs,omitempty"`
+	SignerSleepBetweenRuns string `protobuf:"bytes,3,opt,name=signer_sleep_between_runs,json=signerSleepBetweenRuns" json:"signer_sleep_between_runs,omitempty"`
+	Workers                int32  `protobuf:"varint,4,opt,name=workers" json:"workers,omitempty"`
+	MaxRunsPerPass         int32  `protobuf:"varint,5,opt,name=max_runs_per_pass,json=maxRunsPerPass" json:"max_runs_per_pass,omitempty"`
+	EnforceMaxMergeDelay   bool   `protobuf:"varint,6,opt,name=enforce_max_merge_delay,json=enforceMaxMergeDelay" json:"enforce_max_merge_delay,omitempty"`
+}
+
+func (m *SequencerConfig) Reset()                    { *m = SequencerConfig{} }
+func (m *SequencerConfig) String() string            { return proto.CompactTextString(m) }
+func (*SequencerConfig) ProtoMessage()               {}
+func (*SequencerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+// BatchSize is the adaptive batch size range of a log, see flag tree_batch_sizes.
+type BatchSize struct {
+	Initial int32 `protobuf:"varint,1,opt,name=initial" json:"initial,omitempty"`
+	Min     int32 `protobuf:"varint,2,opt,name=min" json:"min,omitempty"`
+	Max     int32 `protobuf:"varint,3,opt,name=max" json:"max,omitempty"`
+}
+
+func (m *BatchSize) Reset()                    { *m = BatchSize{} }
+func (m *BatchSize) String() string            { return proto.CompactTextString(m) }
+func (*BatchSize) ProtoMessage()               {}
+func (*BatchSize) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+// LogTreeConfig holds the settings of one of the logs a log server serves that differ from
+// the server's defaults.
+type LogTreeConfig struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// Flag tree_sequencer_weights.
+	SequencerWeight int32 `protobuf:"varint,2,opt,name=sequencer_weight,json=sequencerWeight" json:"sequencer_weight,omitempty"`
+	// Flag tree_batch_sizes.
+	BatchSize *BatchSize `protobuf:"bytes,3,opt,name=batch_size,json=batchSize" json:"batch_size,omitempty"`
+	// Flag tree_max_leaf_sizes.
+	MaxLeafSize int32 `protobuf:"varint,4,opt,name=max_leaf_size,json=maxLeafSize" json:"max_leaf_size,omitempty"`
+	// Flag leaf_blob_stores.
+	LeafBlobStore string `protobuf:"bytes,5,opt,name=leaf_blob_store,json=leafBlobStore" json:"leaf_blob_store,omitempty"`
+	// Flag leaf_data_key_files.
+	LeafDataKeyFile string `protobuf:"bytes,6,opt,name=leaf_data_key_file,json=leafDataKeyFile" json:"leaf_data_key_file,omitempty"`
+	// Flag leaf_data_key_wrappers, the URI of the key in a key management service that the
+	// data keys in leaf_data_key_file are wrapped by.
+	LeafDataKeyWrapper string `protobuf:"bytes,7,opt,name=leaf_data_key_wrapper,json=leafDataKeyWrapper" json:"leaf_data_key_wrapper,omitempty"`
+	// Flag tree_queue_priorities, the lowest and highest priorities leaves can be queued with.
+	MinQueuePriority int32 `protobuf:"varint,8,opt,name=min_queue_priority,json=minQueuePriority" json:"min_queue_priority,omitempty"`
+	MaxQueuePriority int32 `protobuf:"varint,9,opt,name=max_queue_priority,json=maxQueuePriority" json:"max_queue_priority,omitempty"`
+	// Flag tree_max_merge_delays.
+	MaxMergeDelay string `protobuf:"bytes,10,opt,name=max_merge_delay,json=maxMergeDelay" json:"max_merge_delay,omitempty"`
+}
+
+func (m *LogTreeConfig) Reset()                    { *m = LogTreeConfig{} }
+func (m *LogTreeConfig) String() string            { return proto.CompactTextString(m) }
+func (*LogTreeConfig) ProtoMessage()               {}
+func (*LogTreeConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *LogTreeConfig) GetBatchSize() *BatchSize {
+	if m != nil {
+		return m.BatchSize
+	}
+	return nil
+}
+
+// LogServerConfig configures server/log.
+type LogServerConfig struct {
+	// The port RPCs are served on, flag port.
+	Port       int32             `protobuf:"varint,1,opt,name=port" json:"port,omitempty"`
+	Storage    *StorageConfig    `protobuf:"bytes,2,opt,name=storage" json:"storage,omitempty"`
+	Tls        *TLSConfig        `protobuf:"bytes,3,opt,name=tls" json:"tls,omitempty"`
+	Grants     []*Grant          `protobuf:"bytes,4,rep,name=grants" json:"grants,omitempty"`
+	Monitoring *MonitoringConfig `protobuf:"bytes,5,opt,name=monitoring" json:"monitoring,omitempty"`
+	Quota      *QuotaConfig      `protobuf:"bytes,6,opt,name=quota" json:"quota,omitempty"`
+	Etcd       *EtcdConfig       `protobuf:"bytes,7,opt,name=etcd" json:"etcd,omitempty"`
+	Sequencer  *SequencerConfig  `protobuf:"bytes,8,opt,name=sequencer" json:"sequencer,omitempty"`
+	// The key used for logs that don't name their own, flags private_key_file and
+	// private_key_password.
+	PrivateKeyFile     string           `protobuf:"bytes,9,opt,name=private_key_file,json=privateKeyFile" json:"private_key_file,omitempty"`
+	PrivateKeyPassword string           `protobuf:"bytes,10,opt,name=private_key_password,json=privateKeyPassword" json:"private_key_password,omitempty"`
+	Trees              []*LogTreeConfig `protobuf:"bytes,11,rep,name=trees" json:"trees,omitempty"`
+	// The address the REST gateway to the log and admin APIs is served on, flag
+	// http_endpoint.
+	HttpEndpoint string `protobuf:"bytes,12,opt,name=http_endpoint,json=httpEndpoint" json:"http_endpoint,omitempty"`
+}
+
+func (m *LogServerConfig) Reset()                    { *m = LogServerConfig{} }
+func (m *LogServerConfig) String() string            { return proto.CompactTextString(m) }
+func (*LogServerConfig) ProtoMessage()               {}
+func (*LogServerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *LogServerConfig) GetStorage() *StorageConfig {
+	if m != nil {
+		return m.Storage
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetTls() *TLSConfig {
+	if m != nil {
+		return m.Tls
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetGrants() []*Grant {
+	if m != nil {
+		return m.Grants
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetMonitoring() *MonitoringConfig {
+	if m != nil {
+		return m.Monitoring
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetQuota() *QuotaConfig {
+	if m != nil {
+		return m.Quota
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetEtcd() *EtcdConfig {
+	if m != nil {
+		return m.Etcd
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetSequencer() *SequencerConfig {
+	if m != nil {
+		return m.Sequencer
+	}
+	return nil
+}
+
+func (m *LogServerConfig) GetTrees() []*LogTreeConfig {
+	if m != nil {
+		return m.Trees
+	}
+	return nil
+}
+
+// MapServerConfig configures server/vmap/trillian_map_server.
+type MapServerConfig struct {
+	// The port RPCs are served on, flag port.
+	Port       int32             `protobuf:"varint,1,opt,name=port" json:"port,omitempty"`
+	Storage    *StorageConfig    `protobuf:"bytes,2,opt,name=storage" json:"storage,omitempty"`
+	Tls        *TLSConfig        `protobuf:"bytes,3,opt,name=tls" json:"tls,omitempty"`
+	Grants     []*Grant          `protobuf:"bytes,4,rep,name=grants" json:"grants,omitempty"`
+	Monitoring *MonitoringConfig `protobuf:"bytes,5,opt,name=monitoring" json:"monitoring,omitempty"`
+	// The map key, flags private_key_file and private_key_password.
+	PrivateKeyFile     string           `protobuf:"bytes,6,opt,name=private_key_file,json=privateKeyFile" json:"private_key_file,omitempty"`
+	PrivateKeyPassword string           `protobuf:"bytes,7,opt,name=private_key_password,json=privateKeyPassword" json:"private_key_password,omitempty"`
+	Trees              []*MapTreeConfig `protobuf:"bytes,8,rep,name=trees" json:"trees,omitempty"`
+	// Flag strict_max_root_age.
+	StrictMaxRootAge bool `protobuf:"varint,9,opt,name=strict_max_root_age,json=strictMaxRootAge" json:"strict_max_root_age,omitempty"`
+	// The address the REST gateway to the map API is served on, flag http_endpoint.
+	HttpEndpoint string `protobuf:"bytes,10,opt,name=http_endpoint,json=httpEndpoint" json:"http_endpoint,omitempty"`
+}
+
+func (m *MapServerConfig) Reset()                    { *m = MapServerConfig{} }
+func (m *MapServerConfig) String() string            { return proto.CompactTextString(m) }
+func (*MapServerConfig) ProtoMessage()               {}
+func (*MapServerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *MapServerConfig) GetStorage() *StorageConfig {
+	if m != nil {
+		return m.Storage
+	}
+	return nil
+}
+
+func (m *MapServerConfig) GetTls() *TLSConfig {
+	if m != nil {
+		return m.Tls
+	}
+	return nil
+}
+
+func (m *MapServerConfig) GetGrants() []*Grant {
+	if m != nil {
+		return m.Grants
+	}
+	return nil
+}
+
+func (m *MapServerConfig) GetMonitoring() *MonitoringConfig {
+	if m != nil {
+		return m.Monitoring
+	}
+	return nil
+}
+
+func (m *MapServerConfig) GetTrees() []*MapTreeConfig {
+	if m != nil {
+		return m.Trees
+	}
+	return nil
+}
+
+// MapTreeConfig holds the settings of one map.
+type MapTreeConfig struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// Flag tree_max_root_ages.
+	MaxRootAge string `protobuf:"bytes,2,opt,name=max_root_age,json=maxRootAge" json:"max_root_age,omitempty"`
+}
+
+func (m *MapTreeConfig) Reset()                    { *m = MapTreeConfig{} }
+func (m *MapTreeConfig) String() string            { return proto.CompactTextString(m) }
+func (*MapTreeConfig) ProtoMessage()               {}
+func (*MapTreeConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func init() {
+	proto.RegisterType((*StorageConfig)(nil), "config.StorageConfig")
+	proto.RegisterType((*SubtreeShard)(nil), "config.SubtreeShard")
+	proto.RegisterType((*TLSConfig)(nil), "config.TLSConfig")
+	proto.RegisterType((*Grant)(nil), "config.Grant")
+	proto.RegisterType((*MonitoringConfig)(nil), "config.MonitoringConfig")
+	proto.RegisterType((*QuotaLimit)(nil), "config.QuotaLimit")
+	proto.RegisterType((*QuotaConfig)(nil), "config.QuotaConfig")
+	proto.RegisterType((*EtcdConfig)(nil), "config.EtcdConfig")
+	proto.RegisterType((*SequencerConfig)(nil), "config.SequencerConfig")
+	proto.RegisterType((*BatchSize)(nil), "config.BatchSize")
+	proto.RegisterType((*LogTreeConfig)(nil), "config.LogTreeConfig")
+	proto.RegisterType((*LogServerConfig)(nil), "config.LogServerConfig")
+	proto.RegisterType((*MapServerConfig)(nil), "config.MapServerConfig")
+	proto.RegisterType((*MapTreeConfig)(nil), "config.MapTreeConfig")
+}
+
+func init() {
+	proto.RegisterFile("github.com/google/trillian/server/config/config.proto", fileDescriptor0)
+}
+
+var fileDescriptor0 = []byte{
+	// 1378 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdc, 0x57, 0x5b, 0x6f, 0x1b, 0x45,
+	0x18, 0x95, 0xeb, 0xf8, 0xf6, 0x39, 0x8e, 0x9d, 0x69, 0xda, 0x6e, 0xb9, 0x48, 0xc1, 0x2d, 0x25,
+	0x69, 0xa1, 0x29, 0x81, 0x4a, 0x20, 0x9e, 0xe8, 0x0d, 0x15, 0x62, 0x91, 0xae, 0x23, 0xfa, 0xc6,
+	0x6a, 0xbc, 0xfb, 0x79, 0x3d, 0xca, 0xee, 0xcc, 0x66, 0x66, 0xb6, 0x71, 0xfa, 0x3b, 0xe8, 0x1b,
+	0x7f, 0x80, 0x1f, 0x80, 0x10, 0xff, 0x0e, 0xcd, 0x65, 0x7d, 0x49, 0x8b, 0x2a, 0x5e, 0x79, 0xca,
+	0xce, 0x39, 0x67, 0x77, 0x2e, 0xe7, 0x9b, 0xf3, 0x39, 0xf0, 0x30, 0x65, 0x7a, 0x56, 0x4e, 0xee,
+	0xc7, 0x22, 0x3f, 0x48, 0x85, 0x48, 0x33, 0x3c, 0xd0, 0x92, 0x65, 0x19, 0xa3, 0xfc, 0x40, 0xa1,
+	0x7c, 0x85, 0xf2, 0x20, 0x16, 0x7c, 0xca, 0x52, 0xff, 0xe7, 0x7e, 0x21, 0x85, 0x16, 0xa4, 0xe9,
+	0x46, 0xc3, 0x37, 0x75, 0xe8, 0x8d, 0xb5, 0x90, 0x34, 0xc5, 0xc7, 0x16, 0x21, 0xd7, 0xa1, 0xa9,
+	0x2e, 0x94, 0xc6, 0x3c, 0xa8, 0xed, 0xd6, 0xf6, 0x3a, 0xa1, 0x1f, 0x91, 0x01, 0xd4, 0x4b, 0xc9,
+	0x82, 0x2b, 0x16, 0x34, 0x8f, 0xe4, 0x36, 0x6c, 0xe5, 0x74, 0x1e, 0x89, 0x02, 0x79, 0x14, 0x0b,
+	0xce, 0x55, 0x50, 0xdf, 0xad, 0xed, 0x35, 0xc2, 0xcd, 0x9c, 0xce, 0x7f, 0x2e, 0x90, 0x3f, 0x36,
+	0x58, 0xa5, 0x62, 0x49, 0x86, 0x5e, 0xb5, 0xb1, 0x50, 0x3d, 0x4f, 0x32, 0x74, 0xaa, 0xbb, 0xb0,
+	0x6d, 0xc8, 0xc8, 0x48, 0x33, 0x36, 0x45, 0xcd, 0x72, 0x0c, 0x1a, 0x76, 0xae, 0xbe, 0x21, 0x46,
+	0x74, 0x7e, 0xe4, 0x61, 0x72, 0x0b, 0x7a, 0x67, 0x25, 0xca, 0x8b, 0xc8, 0x8c, 0x44, 0xa9, 0x83,
+	0xa6, 0xd5, 0x6d, 0x5a, 0xf0, 0xc4, 0x61, 0x64, 0x1f, 0x06, 0x13, 0x89, 0xf4, 0x14, 0x65, 0x34,
+	0xa5, 0x2c, 0x2b, 0x25, 0xaa, 0xa0, 0x65, 0x27, 0xee, 0x7b, 0xfc, 0x99, 0x87, 0xc9, 0x21, 0x5c,
+	0xab, 0xa4, 0x76, 0x2f, 0x49, 0x29, 0xa9, 0x66, 0x82, 0x07, 0x6d, 0xfb, 0xdd, 0xab, 0x9e, 0x34,
+	0x5b, 0x7a, 0xe2, 0x29, 0xf2, 0x09, 0x6c, 0xd2, 0x52, 0x8b, 0x28, 0x67, 0xa9, 0xa4, 0x1a, 0x83,
+	0xce, 0x6e, 0x6d, 0xaf, 0x1d, 0x76, 0x0d, 0x36, 0x72, 0x10, 0xf9, 0x0e, 0xb6, 0x54, 0x39, 0xd1,
+	0x12, 0x31, 0x52, 0x33, 0x2a, 0x13, 0x15, 0xc0, 0x6e, 0x7d, 0xaf, 0x7b, 0xb8, 0x73, 0xdf, 0x3b,
+	0x31, 0x76, 0xec, 0xd8, 0x90, 0x61, 0x4f, 0xad, 0x8c, 0xd4, 0xf0, 0x6b, 0xd8, 0x5c, 0xa5, 0x09,
+	0x81, 0x0d, 0x4e, 0x73, 0xf4, 0x9e, 0xd8, 0x67, 0xe3, 0x48, 0xa2, 0x78, 0xe5, 0x48, 0xa2, 0xf8,
+	0x90, 0x41, 0xe7, 0xe4, 0x68, 0xec, 0x8d, 0xfc, 0x10, 0x3a, 0x31, 0x4a, 0x1d, 0x4d, 0x59, 0x56,
+	0xbd, 0xd7, 0x36, 0xc0, 0x33, 0x96, 0x21, 0xb9, 0x09, 0xed, 0x53, 0xbc, 0x70, 0x9c, 0xfb, 0x40,
+	0xeb, 0x14, 0x2f, 0x2c, 0x75, 0x1b, 0xb6, 0xe2, 0x8c, 0x21, 0xd7, 0x51, 0x4c, 0x9d, 0xa0, 0xee,
+	0xce, 0xd7, 0xa1, 0x8f, 0xa9, 0x51, 0x0d, 0x7f, 0x85, 0xc6, 0x0f, 0x92, 0x72, 0x4d, 0x3e, 0x80,
+	0x36, 0x4b, 0x90, 0x6b, 0xa6, 0x2f, 0xaa, 0x59, 0xaa, 0x31, 0xb9, 0x01, 0x2d, 0xbb, 0x7f, 0x96,
+	0xd8, 0x49, 0xea, 0x61, 0xd3, 0x0c, 0x9f, 0x27, 0x64, 0x17, 0xba, 0x05, 0xca, 0x9c, 0x29, 0xc5,
+	0x84, 0xad, 0x9b, 0xfa, 0x5e, 0x27, 0x5c, 0x85, 0x86, 0x7f, 0xd5, 0x60, 0x30, 0x12, 0x9c, 0x69,
+	0x21, 0x19, 0x4f, 0xfd, 0x96, 0xf6, 0x61, 0x90, 0xa3, 0x96, 0x2c, 0x56, 0x11, 0xf2, 0xa4, 0x10,
+	0x8c, 0x6b, 0x3f, 0x67, 0xdf, 0xe3, 0x4f, 0x3d, 0x4c, 0x3e, 0x83, 0xfe, 0x0c, 0x69, 0xa6, 0x67,
+	0x4b, 0xa5, 0xdb, 0xe7, 0x96, 0x83, 0x17, 0xc2, 0xbb, 0xb0, 0xad, 0x25, 0x8d, 0x31, 0x52, 0x34,
+	0x2f, 0x32, 0x8c, 0xac, 0x9d, 0x66, 0xc7, 0xb5, 0xb0, 0x6f, 0x89, 0xb1, 0xc5, 0x43, 0x63, 0xe9,
+	0x2d, 0xe8, 0x65, 0x22, 0x8d, 0x5e, 0xa1, 0x9c, 0x08, 0x65, 0x36, 0xec, 0x4b, 0x39, 0x13, 0xe9,
+	0x2f, 0x15, 0x36, 0xfc, 0xbd, 0x06, 0xf0, 0xa2, 0x14, 0x9a, 0x1e, 0xb1, 0x9c, 0x69, 0xb2, 0x03,
+	0x8d, 0x54, 0x8a, 0xb2, 0xf0, 0x0b, 0x75, 0x03, 0xe3, 0xe7, 0x29, 0xe3, 0x89, 0x5f, 0x93, 0x7d,
+	0x36, 0x27, 0x19, 0xd3, 0x82, 0xc6, 0xe6, 0xc3, 0x75, 0x7b, 0x5c, 0x8b, 0xb1, 0x5d, 0xa5, 0x38,
+	0x45, 0xae, 0xa2, 0x02, 0x65, 0xa4, 0x30, 0x16, 0x3c, 0x09, 0x36, 0xfc, 0x2a, 0x2d, 0x71, 0x8c,
+	0x72, 0x6c, 0x61, 0xf2, 0x11, 0x74, 0x14, 0x9e, 0x95, 0xc8, 0x63, 0x4c, 0xec, 0x1d, 0x6a, 0x87,
+	0x4b, 0x60, 0xf8, 0x02, 0xba, 0x76, 0x75, 0xef, 0xb9, 0xee, 0x77, 0xa1, 0x99, 0x99, 0xf5, 0xab,
+	0xe0, 0x8a, 0xad, 0x5a, 0x52, 0x55, 0xed, 0x72, 0x6b, 0xa1, 0x57, 0x0c, 0xff, 0xa8, 0x01, 0x3c,
+	0xd5, 0x71, 0xe2, 0x3f, 0x19, 0x40, 0xcb, 0x25, 0x8f, 0x0a, 0x6a, 0xd6, 0xd8, 0x6a, 0x68, 0x4c,
+	0xc1, 0x0c, 0x63, 0x73, 0x83, 0xa2, 0x42, 0xe2, 0x94, 0xcd, 0x2b, 0x53, 0x2a, 0xf8, 0xd8, 0xa2,
+	0xe6, 0x7a, 0x9d, 0x99, 0x79, 0x2a, 0x95, 0xab, 0xc0, 0xae, 0xc5, 0xbc, 0xe4, 0x21, 0xdc, 0x58,
+	0x7c, 0x2b, 0x43, 0xaa, 0x30, 0xd2, 0x3a, 0x33, 0x27, 0x53, 0x05, 0xcc, 0x4e, 0x45, 0x1f, 0x19,
+	0xf6, 0x44, 0x67, 0x63, 0x8c, 0xd5, 0xf0, 0xcd, 0x15, 0xe8, 0x8f, 0xfd, 0x61, 0x48, 0xbf, 0xe0,
+	0x8f, 0x01, 0x26, 0x54, 0xc7, 0xb3, 0x48, 0xb1, 0xd7, 0xee, 0xaa, 0x34, 0xc2, 0x8e, 0x45, 0xc6,
+	0xec, 0x35, 0x92, 0xcf, 0x81, 0xa8, 0x0c, 0xb1, 0x88, 0x26, 0xa8, 0xcf, 0x11, 0x79, 0x24, 0x4b,
+	0xae, 0xfc, 0xc2, 0x07, 0x96, 0x79, 0xe4, 0x88, 0xb0, 0xe4, 0x8a, 0x7c, 0x0b, 0x37, 0x15, 0x4b,
+	0xb9, 0x71, 0xe9, 0xed, 0x97, 0xdc, 0x3e, 0xae, 0x3b, 0xc1, 0xf8, 0xf2, 0xab, 0x01, 0xb4, 0xce,
+	0x85, 0x3c, 0x45, 0x59, 0x6d, 0xa1, 0x1a, 0x92, 0x7d, 0xd8, 0x36, 0xc9, 0x28, 0x4b, 0x5f, 0x00,
+	0x05, 0x55, 0xca, 0x5a, 0xdb, 0x08, 0x4d, 0xba, 0x9a, 0xb7, 0x8f, 0x51, 0x1e, 0x53, 0xa5, 0xec,
+	0xb9, 0xf0, 0xa9, 0x90, 0x31, 0xda, 0x30, 0xcd, 0x51, 0xa6, 0x18, 0x25, 0x98, 0xd1, 0x0b, 0x9b,
+	0x93, 0xed, 0x70, 0xc7, 0xd3, 0x23, 0x3a, 0x1f, 0x19, 0xf2, 0x89, 0xe1, 0x86, 0xcf, 0xa1, 0xf3,
+	0x68, 0xb1, 0xe3, 0x00, 0x5a, 0x8c, 0x33, 0xcd, 0x68, 0xe6, 0x4f, 0xa3, 0x1a, 0x9a, 0xcc, 0xc9,
+	0x99, 0xcb, 0x9c, 0x46, 0x68, 0x1e, 0x2d, 0x42, 0xe7, 0x3e, 0xfa, 0xcd, 0xe3, 0xf0, 0xcf, 0x3a,
+	0xf4, 0x8e, 0x44, 0x7a, 0x22, 0xb1, 0xea, 0x29, 0x2b, 0x39, 0x50, 0x5b, 0xcb, 0x81, 0x7d, 0x18,
+	0x54, 0x95, 0x29, 0xa3, 0x73, 0x64, 0xe9, 0x4c, 0xfb, 0x6f, 0xf7, 0x17, 0xf8, 0x4b, 0x0b, 0x93,
+	0x07, 0x6b, 0x26, 0x99, 0xe9, 0xba, 0x87, 0xdb, 0x55, 0x51, 0x2e, 0x96, 0xbe, 0xea, 0xdb, 0x10,
+	0x7a, 0xb6, 0x9d, 0x20, 0x9d, 0xba, 0x97, 0xdc, 0xa1, 0x76, 0x73, 0x3a, 0x3f, 0x42, 0x3a, 0xb5,
+	0x9a, 0x3b, 0xd0, 0xb7, 0xfc, 0x24, 0x13, 0x93, 0x48, 0x69, 0x21, 0xab, 0xae, 0xd3, 0x33, 0xf0,
+	0xa3, 0x4c, 0x4c, 0x4c, 0x77, 0x44, 0x72, 0x0f, 0x88, 0xd5, 0x25, 0x54, 0xd3, 0x68, 0x91, 0x9c,
+	0xae, 0xf1, 0xd8, 0x2f, 0x3c, 0xa1, 0x9a, 0xfe, 0xe4, 0x13, 0xf4, 0x4b, 0xb8, 0xb6, 0x2e, 0x3e,
+	0x97, 0xb4, 0x28, 0x50, 0xda, 0x06, 0xd4, 0x09, 0xc9, 0x8a, 0xfe, 0xa5, 0x63, 0x4c, 0x8d, 0xe5,
+	0x8c, 0x47, 0x67, 0x25, 0x96, 0x18, 0x15, 0x92, 0x09, 0x69, 0x52, 0xa0, 0x6d, 0x17, 0x3c, 0xc8,
+	0x19, 0x7f, 0x61, 0x88, 0x63, 0x8f, 0x5b, 0x35, 0x9d, 0x5f, 0x56, 0x77, 0xbc, 0x9a, 0xce, 0xd7,
+	0xd5, 0x77, 0xa0, 0x7f, 0xb9, 0x12, 0xc0, 0xed, 0x31, 0x5f, 0x2b, 0x81, 0xdf, 0x36, 0xa0, 0x7f,
+	0x24, 0xd2, 0xb1, 0xbd, 0xac, 0xde, 0x39, 0x02, 0x1b, 0x85, 0x90, 0xda, 0x97, 0x81, 0x7d, 0x26,
+	0x07, 0xd0, 0x52, 0xee, 0x27, 0x83, 0xf5, 0xaa, 0x7b, 0x78, 0x6d, 0xd1, 0xd1, 0x56, 0x7f, 0x49,
+	0x84, 0x95, 0x8a, 0xdc, 0x82, 0xba, 0xce, 0xd4, 0x65, 0xcf, 0x16, 0x9d, 0x2a, 0x34, 0x2c, 0xf9,
+	0x14, 0x9a, 0xa9, 0x69, 0x28, 0xa6, 0xf6, 0x4d, 0xe0, 0xf4, 0x2a, 0x9d, 0x6d, 0x33, 0xa1, 0x27,
+	0xc9, 0x37, 0x00, 0xf9, 0xa2, 0x2d, 0x58, 0xaf, 0xba, 0x87, 0x41, 0x25, 0xbd, 0xdc, 0x30, 0xc2,
+	0x15, 0x2d, 0xd9, 0x87, 0x86, 0xcd, 0x0f, 0xeb, 0x5a, 0xf7, 0xf0, 0xea, 0x5a, 0xa0, 0x79, 0xbd,
+	0x53, 0x90, 0x3b, 0xb0, 0x81, 0x3a, 0x4e, 0xac, 0x5f, 0x2b, 0xd1, 0xb7, 0xcc, 0xb8, 0xd0, 0xf2,
+	0xe4, 0xe1, 0x32, 0x69, 0xa5, 0x35, 0xab, 0x7b, 0x78, 0x63, 0x71, 0x16, 0xeb, 0x21, 0xb3, 0x8c,
+	0x60, 0x49, 0xf6, 0x60, 0x50, 0x48, 0xf6, 0x8a, 0x6a, 0x5c, 0x96, 0x52, 0xc7, 0xe5, 0xa0, 0xc7,
+	0xab, 0x4a, 0x7a, 0x00, 0x3b, 0xab, 0x4a, 0x73, 0xed, 0xcf, 0x85, 0x4c, 0xbc, 0x7f, 0x64, 0xa9,
+	0x3e, 0xf6, 0x0c, 0xb9, 0x07, 0x0d, 0x73, 0xb7, 0x54, 0xd0, 0xdd, 0xad, 0xaf, 0x5a, 0xb3, 0x76,
+	0x21, 0x43, 0xa7, 0x31, 0xfd, 0x6c, 0xa6, 0x75, 0xb1, 0x6c, 0x91, 0x9b, 0xae, 0xd3, 0x1b, 0xb0,
+	0x6a, 0x90, 0xc3, 0xbf, 0xeb, 0xd0, 0x1f, 0xd1, 0xe2, 0xff, 0x5a, 0x16, 0xef, 0x32, 0xa3, 0xf9,
+	0x9f, 0xcc, 0x68, 0xbd, 0xdf, 0x8c, 0xf6, 0xba, 0x19, 0x23, 0x5a, 0xbc, 0x6d, 0xc6, 0x17, 0x70,
+	0x55, 0x99, 0xdf, 0x30, 0xda, 0xe6, 0xb6, 0x14, 0x42, 0x47, 0x34, 0x75, 0x85, 0xd1, 0x0e, 0x07,
+	0x8e, 0x1a, 0xd1, 0x79, 0x28, 0x84, 0xfe, 0x3e, 0xc5, 0xb7, 0xbd, 0x83, 0x77, 0x78, 0xf7, 0x23,
+	0xf4, 0xd6, 0xe6, 0xfa, 0xf7, 0x24, 0xde, 0x85, 0xcd, 0xb5, 0x69, 0x5d, 0x7b, 0x83, 0x7c, 0x31,
+	0xe1, 0xa4, 0x69, 0xff, 0x73, 0xf8, 0xea, 0x9f, 0x01, 0x00, 0x13, 0xc0, 0x3d, 0x20, 0x72, 0x0c,
+	0x00, 0x00,
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testLogServerConfig = `
+port: 8090
+storage {
+  system: "mysql"
+  uri: "test:zaphod@tcp(127.0.0.1:3306)/test"
+  max_open_conns: 50
+  query_timeout: "5s"
+  auto_migrate: true
+  subtree_shards { name: "a" dsn: "test@tcp(db-a)/test" }
+  subtree_shards { name: "b" dsn: "test@tcp(db-b)/test" }
+}
+tls { cert_file: "server.crt" key_file: "server.key" }
+grants { identity: "frontend" tree_id: 123 permissions: "read" permissions: "write" }
+grants { identity: "ops" permissions: "admin" }
+monitoring { metrics_endpoint: ":8093" trace_sample_rate: 0.5 log_verbosity: 2 }
+http_endpoint: ":8097"
+quota {
+  system: "memory"
+  limits { group: "global" kind: "write" capacity: 1000 tokens_per_second: 100 }
+  limits { group: "tree" kind: "write" capacity: 5000 sequenced: true }
+}
+sequencer { batch_size: 100 sleep_between_runs: "1s" workers: 4 enforce_max_merge_delay: true }
+trees { tree_id: 123 sequencer_weight: 3 batch_size { initial: 50 min: 10 max: 500 } max_merge_delay: "24h" }
+trees { tree_id: 456 max_leaf_size: 4096 leaf_blob_store: "file:///blobs/456" min_queue_priority: -1 max_queue_priority: 1 }
+trees {
+  tree_id: 789
+  leaf_blob_store: "file:///blobs/789"
+  leaf_data_key_file: "/keys/789"
+  leaf_data_key_wrapper: "local:/keys/tenant-kek"
+}
+`
+
+func TestLogServerFlags(t *testing.T) {
+	cfg, err := ParseLogServerConfig([]byte(testLogServerConfig))
+	if err != nil {
+		t.Fatalf("ParseLogServerConfig() = %v", err)
+	}
+
+	want := map[string]string{
+		"port":                         "8090",
+		"storage_system":               "mysql",
+		"storage_uri":                  "test:zaphod@tcp(127.0.0.1:3306)/test",
+		"db_max_open_conns":            "50",
+		"db_query_timeout":             "5s",
+		"auto_migrate":                 "true",
+		"subtree_shards":               "a=test@tcp(db-a)/test,b=test@tcp(db-b)/test",
+		"tls_cert_file":                "server.crt",
+		"tls_key_file":                 "server.key",
+		"grants":                       "frontend/123=read+write,ops/*=admin",
+		"metrics_endpoint":             ":8093",
+		"trace_sample_rate":            "0.5",
+		"v":                            "2",
+		"quota_system":                 "memory",
+		"quota_limits":                 "global/write=1000:100,tree/write=5000:sequenced",
+		"batch_size":                   "100",
+		"sequencer_sleep_between_runs": "1s",
+		"sequencer_workers":            "4",
+		"enforce_max_merge_delay":      "true",
+		"tree_sequencer_weights":       "123=3",
+		"tree_batch_sizes":             "123=50:10:500",
+		"adaptive_batch_size":          "true",
+		"tree_max_leaf_sizes":          "456=4096",
+		"tree_queue_priorities":        "456=-1:1",
+		"tree_max_merge_delays":        "123=24h",
+		"leaf_blob_stores":             "456=file:///blobs/456,789=file:///blobs/789",
+		"leaf_data_key_files":          "789=/keys/789",
+		"leaf_data_key_wrappers":       "789=local:/keys/tenant-kek",
+		"http_endpoint":                ":8097",
+	}
+
+	if got := LogServerFlags(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("LogServerFlags() = %v, want %v", got, want)
+	}
+}
+
+func TestMapServerFlags(t *testing.T) {
+	cfg, err := ParseMapServerConfig([]byte(`port: 8091 storage { system: "mysql" } private_key_file: "map.pem" trees { tree_id: 5 max_root_age: "1h" } trees { tree_id: 6 } strict_max_root_age: true http_endpoint: ":8098"`))
+	if err != nil {
+		t.Fatalf("ParseMapServerConfig() = %v", err)
+	}
+
+	want := map[string]string{"port": "8091", "storage_system": "mysql", "private_key_file": "map.pem", "tree_max_root_ages": "5=1h", "strict_max_root_age": "true", "http_endpoint": ":8098"}
+
+	if got := MapServerFlags(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("MapServerFlags() = %v, want %v", got, want)
+	}
+}
+
+func TestParseInvalidConfigs(t *testing.T) {
+	for _, text := range []string{
+		`not a config`,
+		`port: 70000`,
+		`storage { query_timeout: "5 seconds" }`,
+		`storage { max_open_conns: -1 }`,
+		`storage { subtree_shards { name: "a" } }`,
+		`storage { subtree_shards { name: "a" dsn: "x" } subtree_shards { name: "a" dsn: "y" } }`,
+		`tls { cert_file: "server.crt" }`,
+		`grants { identity: "frontend" permissions: "everything" }`,
+		`grants { identity: "" permissions: "read" }`,
+		`quota { system: "redis" }`,
+		`quota { system: "etcd" }`,
+		`quota { limits { group: "user" kind: "write" capacity: 10 sequenced: true } }`,
+		`quota { limits { group: "tree" kind: "read" capacity: 0 tokens_per_second: 1 } }`,
+		`sequencer { signer_sleep_between_runs: "often" }`,
+		`trees { sequencer_weight: 2 }`,
+		`trees { tree_id: 1 } trees { tree_id: 1 }`,
+		`trees { tree_id: 1 batch_size { initial: 5 min: 10 max: 20 } }`,
+		`trees { tree_id: 1 max_leaf_size: -1 }`,
+		`trees { tree_id: 1 min_queue_priority: 1 max_queue_priority: 2 }`,
+		`trees { tree_id: 1 max_merge_delay: "a day" }`,
+		`trees { tree_id: 1 max_merge_delay: "0s" }`,
+		`trees { tree_id: 1 leaf_data_key_file: "/keys/1" }`,
+		`trees { tree_id: 1 leaf_blob_store: "file:///blobs" leaf_data_key_wrapper: "local:/kek" }`,
+	} {
+		if _, err := ParseLogServerConfig([]byte(text)); err == nil {
+			t.Errorf("ParseLogServerConfig(%q) succeeded", text)
+		}
+	}
+
+	for _, text := range []string{
+		`port: -1`,
+		`trees { max_root_age: "1h" }`,
+		`trees { tree_id: 1 } trees { tree_id: 1 }`,
+		`trees { tree_id: 1 max_root_age: "an hour" }`,
+		`trees { tree_id: 1 max_root_age: "-1h" }`,
+	} {
+		if _, err := ParseMapServerConfig([]byte(text)); err == nil {
+			t.Errorf("ParseMapServerConfig(%q) succeeded", text)
+		}
+	}
+}
+
+func TestLoadLogServerConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testLogServerConfig)
+	f.Close()
+
+	cfg, err := LoadLogServerConfig(f.Name())
+	if err != nil {
+		t.Fatalf("LoadLogServerConfig() = %v", err)
+	}
+	if len(cfg.Trees) != 3 || cfg.Storage.MaxOpenConns != 50 {
+		t.Errorf("LoadLogServerConfig() = %v, want the test config", cfg)
+	}
+
+	if _, err := LoadLogServerConfig(f.Name() + ".missing"); err == nil {
+		t.Error("LoadLogServerConfig() of a missing file succeeded")
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.Int("port", 8090, "")
+	uri := fs.String("storage_uri", "default", "")
+	timeout := fs.Duration("db_query_timeout", 0, "")
+
+	if err := fs.Parse([]string{"--storage_uri=given"}); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+
+	// Flags given on the command line win
+	if err := SetFlags(fs, map[string]string{"port": "9000", "storage_uri": "config", "db_query_timeout": "5s"}); err != nil {
+		t.Fatalf("SetFlags() = %v", err)
+	}
+	if *port != 9000 || *uri != "given" || *timeout != 5*time.Second {
+		t.Errorf("SetFlags() set port %d, storage_uri %q, db_query_timeout %v, want 9000, given, 5s", *port, *uri, *timeout)
+	}
+
+	if err := SetFlags(fs, map[string]string{"unknown": "1"}); err == nil {
+		t.Error("SetFlags() of an unknown flag succeeded")
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("port", 8090, "")
+	if err := SetFlags(fs, map[string]string{"port": "high"}); err == nil {
+		t.Error("SetFlags() of an invalid value succeeded")
+	}
+}
+
+func TestReloadFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	limits := fs.String("quota_limits", "", "")
+	verbosity := fs.Int("v", 0, "")
+	size := fs.Int("max_leaf_size", 0, "")
+
+	if err := fs.Parse([]string{"--max_leaf_size=100"}); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	given := GivenFlags(fs)
+
+	if err := SetFlags(fs, map[string]string{"quota_limits": "tree/write=10:1", "v": "2"}); err != nil {
+		t.Fatalf("SetFlags() = %v", err)
+	}
+
+	names := []string{"quota_limits", "v", "max_leaf_size"}
+	applied := 0
+	apply := func() error {
+		applied++
+		return nil
+	}
+
+	// Settings dropped from the config go back to their defaults, those given on the command
+	// line are kept
+	if err := ReloadFlags(fs, map[string]string{"v": "3", "max_leaf_size": "5"}, given, names, apply); err != nil {
+		t.Fatalf("ReloadFlags() = %v", err)
+	}
+	if *limits != "" || *verbosity != 3 || *size != 100 || applied != 1 {
+		t.Errorf("ReloadFlags() set quota_limits %q, v %d, max_leaf_size %d and applied them %d times, want \"\", 3, 100 and 1", *limits, *verbosity, *size, applied)
+	}
+
+	// Failed reloads leave the flags as they were
+	err := ReloadFlags(fs, map[string]string{"quota_limits": "global/read=1:1", "v": "loud"}, given, names, apply)
+	if err == nil || *limits != "" || *verbosity != 3 || applied != 1 {
+		t.Errorf("ReloadFlags() of an invalid value = %v and set quota_limits %q, v %d, want an error and \"\", 3", err, *limits, *verbosity)
+	}
+
+	err = ReloadFlags(fs, map[string]string{"quota_limits": "global/read=1:1"}, given, names, func() error { return errors.New("can't apply") })
+	if err == nil || *limits != "" || *verbosity != 3 {
+		t.Errorf("ReloadFlags() that failed to apply = %v and set quota_limits %q, v %d, want an error and \"\", 3", err, *limits, *verbosity)
+	}
+
+	if err := ReloadFlags(fs, nil, given, []string{"unknown"}, apply); err == nil {
+		t.Error("ReloadFlags() of an unknown flag succeeded")
+	}
+}
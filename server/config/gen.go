@@ -0,0 +1,3 @@
+package config
+
+//go:generate sh -c "cd $GOPATH/src && protoc --go_out=plugins=grpc:. github.com/google/trillian/server/config/*.proto"
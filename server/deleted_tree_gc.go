@@ -0,0 +1,171 @@
+package server
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// deletedTreeGCTreesDeleted counts the trees permanently removed by garbage collection.
+var deletedTreeGCTreesDeleted = expvar.NewInt("deleted_tree_gc_trees_deleted")
+
+// DeletedTreeGC permanently removes the storage of trees that have been deleted through
+// the admin API for longer than a grace period. Until then a tree can still be undeleted.
+type DeletedTreeGC struct {
+	// done is a channel that provides an exit signal
+	done chan struct{}
+	// storageProvider provides the admin storage the trees are listed from and deleted in
+	storageProvider AdminStorageProviderFunc
+	// gracePeriod is how long a tree must have been deleted before it's removed
+	gracePeriod time.Duration
+	// sleepBetweenRuns is the time to pause after each collection pass
+	sleepBetweenRuns time.Duration
+	// timeSource allows us to mock this in tests
+	timeSource util.TimeSource
+	// quotaManager has the buckets of trees dropped once they're deleted, if it keeps any
+	quotaManager quota.Manager
+}
+
+// NewDeletedTreeGC creates a DeletedTreeGC which removes trees once they've been deleted
+// for at least gracePeriod.
+func NewDeletedTreeGC(done chan struct{}, sp AdminStorageProviderFunc, gracePeriod time.Duration, sleepBetweenRuns time.Duration, timeSource util.TimeSource) *DeletedTreeGC {
+	return &DeletedTreeGC{done: done, storageProvider: sp, gracePeriod: gracePeriod, sleepBetweenRuns: sleepBetweenRuns, timeSource: timeSource}
+}
+
+// SetQuotaManager sets the quota manager whose per tree buckets are dropped for trees that
+// are deleted or don't exist, if it implements quota.TreePruner.
+func (g *DeletedTreeGC) SetQuotaManager(qm quota.Manager) {
+	g.quotaManager = qm
+}
+
+// Run collects deleted trees every sleepBetweenRuns until told to exit.
+func (g DeletedTreeGC) Run(ctx context.Context) {
+	logging.Infof(ctx, "Deleted tree garbage collector starting")
+
+	for {
+		select {
+		case <-g.done:
+			logging.Infof(ctx, "Deleted tree garbage collector shutting down")
+			return
+		case <-time.After(g.sleepBetweenRuns):
+		}
+
+		if count, err := g.RunOnce(ctx); err != nil {
+			logging.Warningf(ctx, "Deleted tree garbage collection failed after removing %d tree(s): %v", count, err)
+		}
+	}
+}
+
+// RunOnce makes a single collection pass, removing every tree whose grace period has
+// expired. It returns the number of trees that were removed.
+func (g DeletedTreeGC) RunOnce(ctx context.Context) (int, error) {
+	trees, live, err := g.listTrees(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if pruner, ok := g.quotaManager.(quota.TreePruner); ok {
+		pruner.PruneTrees(live)
+	}
+
+	cutoffMillis := g.timeSource.Now().Add(-g.gracePeriod).UnixNano() / int64(time.Millisecond)
+	count := 0
+
+	for _, tree := range trees {
+		// See if it's time to quit
+		select {
+		case <-g.done:
+			return count, nil
+		default:
+		}
+
+		if tree.DeleteTimeMillis > cutoffMillis {
+			continue
+		}
+
+		removed, err := g.hardDeleteTree(ctx, tree.TreeId)
+		if err != nil {
+			return count, err
+		}
+
+		if removed {
+			logging.Infof(ctx, "Garbage collected deleted tree: %d", tree.TreeId)
+			deletedTreeGCTreesDeleted.Add(1)
+			count++
+		}
+	}
+
+	logging.V(1).Infof(ctx, "Deleted tree garbage collection removed %d tree(s)", count)
+
+	return count, nil
+}
+
+// listTrees returns the deleted trees, and the IDs of the others
+func (g DeletedTreeGC) listTrees(ctx context.Context) ([]*trillian.Tree, map[int64]bool, error) {
+	s, err := g.storageProvider()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trees, err := tx.ListTrees(true)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	deleted := make([]*trillian.Tree, 0, len(trees))
+	live := make(map[int64]bool)
+	for _, tree := range trees {
+		if tree.Deleted {
+			deleted = append(deleted, tree)
+		} else {
+			live[tree.TreeId] = true
+		}
+	}
+
+	return deleted, live, nil
+}
+
+// hardDeleteTree removes a single tree in its own transaction. It returns false without
+// an error if the tree was undeleted or removed since it was listed.
+func (g DeletedTreeGC) hardDeleteTree(ctx context.Context, treeID int64) (bool, error) {
+	s, err := g.storageProvider()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.HardDeleteTree(treeID)
+	if err == storage.ErrTreeNotDeleted || err == storage.ErrTreeNotFound {
+		logging.V(1).Infof(ctx, "Tree %d was undeleted or removed since listing, not collecting it", treeID)
+		return false, tx.Rollback()
+	} else if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
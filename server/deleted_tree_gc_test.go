@@ -0,0 +1,187 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+const deletedTreeGracePeriod = time.Hour
+
+// gcNow is the fake time the collector runs at, it's well after all the tree timestamps
+var gcNow = time.Unix(1000000, 0)
+
+func deletedTree(treeID int64, deletedFor time.Duration) *trillian.Tree {
+	tree := storedLogTree
+	tree.TreeId = treeID
+	tree.Deleted = true
+	tree.DeleteTimeMillis = gcNow.Add(-deletedFor).UnixNano() / int64(time.Millisecond)
+	return &tree
+}
+
+func newDeletedTreeGCForTest(mockStorage storage.AdminStorage, done chan struct{}) *DeletedTreeGC {
+	return NewDeletedTreeGC(done, mockAdminStorageProviderFunc(mockStorage), deletedTreeGracePeriod, time.Millisecond, util.FakeTimeSource{FakeTime: gcNow})
+}
+
+func TestDeletedTreeGCRemovesExpiredTrees(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	live := storedLogTree
+	expired := deletedTree(1, deletedTreeGracePeriod)
+	recent := deletedTree(2, deletedTreeGracePeriod-time.Minute)
+	older := deletedTree(3, deletedTreeGracePeriod*2)
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return([]*trillian.Tree{&live, expired, recent, older}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+
+	// Only the trees that have been deleted for at least the grace period are removed
+	for _, tree := range []*trillian.Tree{expired, older} {
+		mockTx := storage.NewMockAdminTX(ctrl)
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+		mockTx.EXPECT().HardDeleteTree(tree.TreeId).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+	}
+
+	count, err := newDeletedTreeGCForTest(mockStorage, make(chan struct{})).RunOnce(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to collect deleted trees: %v", err)
+	}
+
+	if got, want := count, 2; got != want {
+		t.Fatalf("Expected %d trees to be removed but got: %d", want, got)
+	}
+}
+
+// fakeTreePruner records the live trees it was told to keep buckets for
+type fakeTreePruner struct {
+	quota.Unlimited
+	live map[int64]bool
+}
+
+func (f *fakeTreePruner) PruneTrees(live map[int64]bool) {
+	f.live = live
+}
+
+func TestDeletedTreeGCPrunesQuotaBuckets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	live := storedLogTree
+	live.TreeId = 5
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return([]*trillian.Tree{&live, deletedTree(2, deletedTreeGracePeriod-time.Minute)}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+
+	pruner := &fakeTreePruner{}
+	gc := newDeletedTreeGCForTest(mockStorage, make(chan struct{}))
+	gc.SetQuotaManager(pruner)
+
+	if _, err := gc.RunOnce(context.Background()); err != nil {
+		t.Fatalf("Failed to collect deleted trees: %v", err)
+	}
+
+	// The buckets of the deleted tree go even though its storage is kept for now
+	if want := map[int64]bool{5: true}; !reflect.DeepEqual(pruner.live, want) {
+		t.Errorf("Pruned quota buckets keeping trees %v, want %v", pruner.live, want)
+	}
+}
+
+func TestDeletedTreeGCSkipsUndeletedTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tree := deletedTree(1, deletedTreeGracePeriod)
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return([]*trillian.Tree{tree}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+
+	// The tree was undeleted after it was listed
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().HardDeleteTree(tree.TreeId).Return(storage.ErrTreeNotDeleted)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	count, err := newDeletedTreeGCForTest(mockStorage, make(chan struct{})).RunOnce(context.Background())
+
+	if err != nil || count != 0 {
+		t.Fatalf("Expected no trees to be removed without error but got: %d, %v", count, err)
+	}
+}
+
+func TestDeletedTreeGCStorageErrorRollsBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tree := deletedTree(1, deletedTreeGracePeriod)
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return([]*trillian.Tree{tree, deletedTree(2, deletedTreeGracePeriod)}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+
+	// The pass stops at the first failure
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().HardDeleteTree(tree.TreeId).Return(errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	if _, err := newDeletedTreeGCForTest(mockStorage, make(chan struct{})).RunOnce(context.Background()); err == nil {
+		t.Fatal("Returned OK when storage failed")
+	}
+}
+
+func TestDeletedTreeGCListFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return(nil, errors.New("STORAGE"))
+	mockSnapshot.EXPECT().Rollback().Return(nil)
+
+	if _, err := newDeletedTreeGCForTest(mockStorage, make(chan struct{})).RunOnce(context.Background()); err == nil {
+		t.Fatal("Returned OK when listing trees failed")
+	}
+}
+
+func TestDeletedTreeGCStopsWhenDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockSnapshot := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+	mockSnapshot.EXPECT().ListTrees(true).Return([]*trillian.Tree{deletedTree(1, deletedTreeGracePeriod)}, nil)
+	mockSnapshot.EXPECT().Commit().Return(nil)
+
+	// No trees should be removed once the exit signal has been given
+	done := make(chan struct{})
+	close(done)
+
+	count, err := newDeletedTreeGCForTest(mockStorage, done).RunOnce(context.Background())
+
+	if err != nil || count != 0 {
+		t.Fatalf("Expected no trees to be removed without error but got: %d, %v", count, err)
+	}
+}
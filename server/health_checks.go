@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// activeLogIDs returns the IDs of the logs in storage
+func activeLogIDs(ctx context.Context, sp LogStorageProviderFunc) ([]trillian.LogID, error) {
+	// TODO(Martin2112) using log ID zero because we don't have an id for metadata ops
+	s, err := sp(0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logIDs, err := tx.GetActiveLogIDs(ctx)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return logIDs, tx.Commit()
+}
+
+// NewLogKeysCheck returns a check that fails if the signing key of any active log can't be
+// used, so a server that would fail to sign roots isn't sent traffic
+func NewLogKeysCheck(sp LogStorageProviderFunc, kmp KeyManagerProviderFunc) health.Check {
+	return func(ctx context.Context) error {
+		logIDs, err := activeLogIDs(ctx, sp)
+
+		if err != nil {
+			return err
+		}
+
+		for _, logID := range logIDs {
+			km, err := kmp(logID)
+
+			if err == nil {
+				err = health.KeyCheck(km)(ctx)
+			}
+			if err != nil {
+				return fmt.Errorf("key of log %d: %v", logID.TreeID, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// NewSequencingLagCheck returns a check that fails if the latest root of any active log was
+// signed more than maxLag ago. Roots are signed again every signing interval even if nothing
+// was sequenced, so maxLag must be longer than that.
+func NewSequencingLagCheck(sp LogStorageProviderFunc, timeSource util.TimeSource, maxLag time.Duration) health.Check {
+	return func(ctx context.Context) error {
+		logIDs, err := activeLogIDs(ctx, sp)
+
+		if err != nil {
+			return err
+		}
+
+		for _, logID := range logIDs {
+			root, err := latestRoot(ctx, sp, logID.TreeID)
+
+			if err == nil {
+				err = health.CheckRootAge(root, timeSource.Now(), maxLag)
+			}
+			if err != nil {
+				return fmt.Errorf("log %d: %v", logID.TreeID, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// latestRoot reads the latest signed root of log treeID
+func latestRoot(ctx context.Context, sp LogStorageProviderFunc, treeID int64) (trillian.SignedLogRoot, error) {
+	s, err := sp(treeID)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	tx, err := s.Snapshot(ctx)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+
+	if err != nil {
+		tx.Commit()
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, tx.Commit()
+}
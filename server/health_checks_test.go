@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// expectActiveLogs sets up mockStorage to list logIDs as the active logs
+func expectActiveLogs(mockCtrl *gomock.Controller, mockStorage *storage.MockLogStorage, logIDs []trillian.LogID) {
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetActiveLogIDs(gomock.Any()).Return(logIDs, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+}
+
+func TestLogKeysCheck(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	check := NewLogKeysCheck(mockStorageProviderForSequencer(mockStorage), mockKeyManagerProvider(mockKeyManager))
+
+	expectActiveLogs(mockCtrl, mockStorage, []trillian.LogID{logID1})
+	mockKeyManager.EXPECT().Signer().Return(crypto.NewMockSigner(mockCtrl), nil)
+
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Check with a usable key = %v", err)
+	}
+
+	expectActiveLogs(mockCtrl, mockStorage, []trillian.LogID{logID1})
+	mockKeyManager.EXPECT().Signer().Return(nil, errors.New("HSM unreachable"))
+
+	if err := check(context.Background()); err == nil {
+		t.Error("Check with an unusable key succeeded")
+	}
+}
+
+func TestLogKeysCheckStorageFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(nil, errors.New("database down"))
+	check := NewLogKeysCheck(mockStorageProviderForSequencer(mockStorage), mockKeyManagerProvider(crypto.NewMockKeyManager(mockCtrl)))
+
+	if err := check(context.Background()); err == nil {
+		t.Error("Check without storage succeeded")
+	}
+}
+
+func TestSequencingLagCheck(t *testing.T) {
+	for _, test := range []struct {
+		rootTime time.Time
+		wantErr  bool
+	}{
+		{fakeTime.Add(-time.Minute), false},
+		{fakeTime.Add(-time.Hour), true},
+		// Logs that haven't been signed yet aren't lagging
+		{time.Unix(0, 0), false},
+	} {
+		mockCtrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockLogStorage(mockCtrl)
+		mockSnapshot := storage.NewMockReadOnlyLogTX(mockCtrl)
+		expectActiveLogs(mockCtrl, mockStorage, []trillian.LogID{logID1})
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockSnapshot, nil)
+		mockSnapshot.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{TimestampNanos: test.rootTime.UnixNano()}, nil)
+		mockSnapshot.EXPECT().Commit().Return(nil)
+
+		check := NewSequencingLagCheck(mockStorageProviderForSequencer(mockStorage), fakeTimeSource, time.Minute*10)
+
+		if err := check(context.Background()); (err != nil) != test.wantErr {
+			t.Errorf("Check of root signed at %v = %v, want error %v", test.rootTime, err, test.wantErr)
+		}
+
+		mockCtrl.Finish()
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+)
+
+// KeyManagerProviderFunc returns the KeyManager holding the signing key of a log. This
+// decouples the server from the ways keys can be stored.
+type KeyManagerProviderFunc func(trillian.LogID) (crypto.KeyManager, error)
+
+// keyManagerCacheKey identifies a key manager, the key ID is included so a log that
+// changes key doesn't keep using the old one.
+type keyManagerCacheKey struct {
+	treeID int64
+	keyID  string
+}
+
+// NewKeyManagerProvider returns a KeyManagerProviderFunc that selects the key manager for
+// each log from the key ID stored with its tree, using the implementations registered with
+// crypto.RegisterKeyManager. Logs with a key ID that doesn't name a registered scheme use
+// defaultKM, or fail if it's nil. Key managers are created once and then reused.
+func NewKeyManagerProvider(defaultKM crypto.KeyManager) KeyManagerProviderFunc {
+	var mutex sync.Mutex
+	keyManagers := make(map[keyManagerCacheKey]crypto.KeyManager)
+
+	return func(logID trillian.LogID) (crypto.KeyManager, error) {
+		cacheKey := keyManagerCacheKey{treeID: logID.TreeID, keyID: string(logID.LogID)}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if km, ok := keyManagers[cacheKey]; ok {
+			return km, nil
+		}
+
+		km, err := crypto.NewKeyManager(cacheKey.keyID)
+		switch {
+		case err == crypto.ErrUnknownKeyScheme && defaultKM != nil:
+			km = defaultKM
+		case err == crypto.ErrUnknownKeyScheme:
+			return nil, fmt.Errorf("no key manager for key %q of log %d (registered: %v)", cacheKey.keyID, logID.TreeID, crypto.KeyManagerSchemes())
+		case err != nil:
+			glog.Warningf("Failed to create key manager for log %d: %v", logID.TreeID, err)
+			return nil, err
+		}
+
+		keyManagers[cacheKey] = km
+		return km, nil
+	}
+}
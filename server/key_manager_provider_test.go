@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+)
+
+// keyManagersCreated records the specs passed to the test key scheme
+var keyManagersCreated []string
+
+func init() {
+	if err := crypto.RegisterKeyManager("test-server", func(spec string) (crypto.KeyManager, error) {
+		keyManagersCreated = append(keyManagersCreated, spec)
+		return crypto.NewPEMKeyManager(), nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := crypto.RegisterKeyManager("test-server-error", func(string) (crypto.KeyManager, error) {
+		return nil, errors.New("KEY")
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestKeyManagerProviderUsesRegisteredScheme(t *testing.T) {
+	keyManagersCreated = nil
+	provider := NewKeyManagerProvider(nil)
+
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("test-server:key1")}
+	for i := 0; i < 2; i++ {
+		if _, err := provider(logID); err != nil {
+			t.Fatalf("Failed to get key manager: %v", err)
+		}
+	}
+
+	// Changing the key of the log should create a new key manager
+	logID.LogID = []byte("test-server:key2")
+	if _, err := provider(logID); err != nil {
+		t.Fatalf("Failed to get key manager: %v", err)
+	}
+
+	if len(keyManagersCreated) != 2 || keyManagersCreated[0] != "key1" || keyManagersCreated[1] != "key2" {
+		t.Fatalf("Expected key managers to be created once per key but got: %v", keyManagersCreated)
+	}
+}
+
+func TestKeyManagerProviderFallsBackToDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	defaultKM := crypto.NewMockKeyManager(ctrl)
+	provider := NewKeyManagerProvider(defaultKM)
+
+	km, err := provider(trillian.LogID{TreeID: 1, LogID: []byte("Test")})
+
+	if err != nil {
+		t.Fatalf("Failed to get key manager: %v", err)
+	}
+
+	if km != defaultKM {
+		t.Fatalf("Expected the default key manager but got: %v", km)
+	}
+}
+
+func TestKeyManagerProviderNoDefault(t *testing.T) {
+	provider := NewKeyManagerProvider(nil)
+
+	if _, err := provider(trillian.LogID{TreeID: 1, LogID: []byte("Test")}); err == nil {
+		t.Fatal("Returned a key manager for a log with an unknown key scheme")
+	}
+}
+
+func TestKeyManagerProviderPropagatesError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The default shouldn't be used when the named key can't be loaded
+	provider := NewKeyManagerProvider(crypto.NewMockKeyManager(ctrl))
+
+	if _, err := provider(trillian.LogID{TreeID: 1, LogID: []byte("test-server-error:key")}); err == nil {
+		t.Fatal("Returned a key manager for a key that failed to load")
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/storage/blob"
+)
+
+// ParseLeafBlobStores parses a comma separated list of treeID=uri entries, each naming the
+// blob store that keeps the large leaf values of a log. The URIs are as used by
+// blob.NewStore, e.g. 1=file:///var/trillian/blobs.
+func ParseLeafBlobStores(s string) (map[int64]blob.Store, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stores := make(map[int64]blob.Store, len(settings))
+
+	for treeID, uri := range settings {
+		if stores[treeID], err = blob.NewStore(uri); err != nil {
+			return nil, fmt.Errorf("invalid blob store for tree %d: %v", treeID, err)
+		}
+	}
+
+	return stores, nil
+}
+
+// ParseLeafDataKeys parses a comma separated list of treeID=file entries, each naming the
+// file holding the data keys that a log's leaf values are encrypted with, see
+// crypto.LoadDataKeyFile. wrappers is a list of treeID=uri entries in the same form, naming
+// the keys created by crypto.NewKeyWrapper that the data keys of some of the logs are
+// wrapped by, e.g. a key that the tenant owning the log holds in their own key management
+// service.
+func ParseLeafDataKeys(files, wrappers string) (map[int64]crypto.DataKeyManager, error) {
+	settings, err := parseTreeSettings(files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	wrapperSettings, err := parseTreeSettings(wrappers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for treeID := range wrapperSettings {
+		if _, ok := settings[treeID]; !ok {
+			return nil, fmt.Errorf("tree %d has a data key wrapper but no data key file", treeID)
+		}
+	}
+
+	keys := make(map[int64]crypto.DataKeyManager, len(settings))
+
+	for treeID, file := range settings {
+		uri, wrapped := wrapperSettings[treeID]
+
+		if !wrapped {
+			if keys[treeID], err = crypto.LoadDataKeyFile(file); err != nil {
+				return nil, fmt.Errorf("invalid data keys for tree %d: %v", treeID, err)
+			}
+			continue
+		}
+
+		w, err := crypto.NewKeyWrapper(uri)
+
+		if err == crypto.ErrUnknownKeyScheme {
+			return nil, fmt.Errorf("no key wrapper for key %q of tree %d (registered: %v)", uri, treeID, crypto.KeyWrapperSchemes())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid data key wrapper for tree %d: %v", treeID, err)
+		}
+
+		if keys[treeID], err = crypto.LoadWrappedDataKeyFile(file, w); err != nil {
+			return nil, fmt.Errorf("invalid data keys for tree %d: %v", treeID, err)
+		}
+	}
+
+	return keys, nil
+}
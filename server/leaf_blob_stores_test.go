@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/trillian/crypto"
+)
+
+func TestParseLeafBlobStores(t *testing.T) {
+	stores, err := ParseLeafBlobStores("1=file:///tmp/blobs1,7=file:///tmp/blobs7")
+	if err != nil {
+		t.Fatalf("Failed to parse blob stores: %v", err)
+	}
+
+	if len(stores) != 2 || stores[1] == nil || stores[7] == nil {
+		t.Errorf("Got blob stores %v, want stores for trees 1 and 7", stores)
+	}
+
+	for _, s := range []string{"1", "x=file:///tmp", "1=nosuch://bucket", "1=file://", "1=file:///a,1=file:///b"} {
+		if _, err := ParseLeafBlobStores(s); err == nil {
+			t.Errorf("Parsed bad blob stores: %q", s)
+		}
+	}
+}
+
+func TestParseLeafDataKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datakeys")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := crypto.GenerateDataKey(1)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	file := filepath.Join(dir, "keys")
+	if err := crypto.WriteDataKeyFile(file, []crypto.DataKey{key}); err != nil {
+		t.Fatalf("Failed to write data keys: %v", err)
+	}
+
+	keys, err := ParseLeafDataKeys("3="+file, "")
+	if err != nil {
+		t.Fatalf("Failed to parse data keys: %v", err)
+	}
+
+	if got, err := keys[3].CurrentDataKey(); err != nil || got.Version != 1 {
+		t.Errorf("Got current data key %v %v for tree 3, want version 1", got, err)
+	}
+
+	for _, s := range []string{"3", "3=" + filepath.Join(dir, "missing"), "3=" + file + ",3=" + file} {
+		if _, err := ParseLeafDataKeys(s, ""); err == nil {
+			t.Errorf("Parsed bad data keys: %q", s)
+		}
+	}
+}
+
+func TestParseWrappedLeafDataKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datakeys")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kek := bytes.Repeat([]byte{7}, crypto.DataKeySize)
+	kekFile := filepath.Join(dir, "kek")
+	if err := ioutil.WriteFile(kekFile, []byte(hex.EncodeToString(kek)), 0600); err != nil {
+		t.Fatalf("Failed to write key encryption key: %v", err)
+	}
+	w, err := crypto.NewAESKeyWrapper(kek)
+	if err != nil {
+		t.Fatalf("Failed to create key wrapper: %v", err)
+	}
+
+	key, err := crypto.GenerateDataKey(1)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	file := filepath.Join(dir, "keys")
+	if err := crypto.WriteWrappedDataKeyFile(file, []crypto.DataKey{key}, w); err != nil {
+		t.Fatalf("Failed to write data keys: %v", err)
+	}
+
+	wrapper := "3=" + crypto.LocalKeyWrapperScheme + ":" + kekFile
+	keys, err := ParseLeafDataKeys("3="+file, wrapper)
+	if err != nil {
+		t.Fatalf("Failed to parse wrapped data keys: %v", err)
+	}
+
+	if got, err := keys[3].CurrentDataKey(); err != nil || !bytes.Equal(got.Key, key.Key) {
+		t.Errorf("Got current data key %v %v for tree 3, want the unwrapped key", got, err)
+	}
+
+	for _, test := range []struct{ files, wrappers string }{
+		// The keys are wrapped
+		{"3=" + file, ""},
+		{"3=" + file, "3=nosuch:key"},
+		{"3=" + file, "3=" + crypto.LocalKeyWrapperScheme + ":" + filepath.Join(dir, "missing")},
+		{"", wrapper},
+	} {
+		if _, err := ParseLeafDataKeys(test.files, test.wrappers); err == nil {
+			t.Errorf("Parsed bad data keys: %q %q", test.files, test.wrappers)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// LeafRejection is why a leaf submitted to a log was rejected. It's an error so that a
+// LeafValidatorFunc can return it.
+type LeafRejection struct {
+	// Code is the kind of problem with the leaf. FAILED_VALIDATION is used if a validator
+	// leaves it unset.
+	Code trillian.LeafRejectionCode
+	// Field names the field of the leaf at fault, if there's one in particular
+	Field string
+	// Reason describes the problem for people
+	Reason string
+	// MaxLeafSize is the limit a TOO_LARGE leaf went over
+	MaxLeafSize int64
+}
+
+func (r *LeafRejection) Error() string {
+	return r.Reason
+}
+
+func (r *LeafRejection) queuedLeaf() *trillian.QueuedLeaf {
+	return &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_REJECTED, Reason: r.Reason, RejectionCode: r.Code, RejectedField: r.Field, MaxLeafSize: r.MaxLeafSize}
+}
+
+// LeafValidatorFunc checks a leaf submitted to a log before it's queued or added, so that
+// applications can reject malformed entries before they take up space in the queue. It
+// returns nil if the leaf is acceptable or a *LeafRejection if it isn't, which rejects just
+// that leaf. Any other error fails the whole request, e.g. if the leaf couldn't be checked.
+type LeafValidatorFunc func(ctx context.Context, logID int64, leaf *trillian.LeafProto) error
+
+// ParseMaxLeafSizes parses a comma separated list of treeID=bytes entries, each giving the
+// most bytes of leaf value and extra data a log accepts in a leaf.
+func ParseMaxLeafSizes(s string) (map[int64]int, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[int64]int, len(settings))
+
+	for treeID, setting := range settings {
+		size, err := strconv.Atoi(setting)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid max leaf size for tree %d: %v", treeID, err)
+		}
+
+		if size <= 0 {
+			return nil, fmt.Errorf("max leaf size for tree %d must be > 0 but was %d", treeID, size)
+		}
+
+		sizes[treeID] = size
+	}
+
+	return sizes, nil
+}
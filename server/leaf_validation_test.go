@@ -0,0 +1,20 @@
+package server
+
+import "testing"
+
+func TestParseMaxLeafSizes(t *testing.T) {
+	sizes, err := ParseMaxLeafSizes("1=1024,7=65536")
+	if err != nil {
+		t.Fatalf("Failed to parse max leaf sizes: %v", err)
+	}
+
+	if got, want := len(sizes), 2; got != want || sizes[1] != 1024 || sizes[7] != 65536 {
+		t.Errorf("Got max leaf sizes %v, want 1=1024,7=65536", sizes)
+	}
+
+	for _, s := range []string{"1", "x=3", "1=x", "1=0", "1=-5", "1=3,1=4"} {
+		if _, err := ParseMaxLeafSizes(s); err == nil {
+			t.Errorf("Parsed bad max leaf sizes: %q", s)
+		}
+	}
+}
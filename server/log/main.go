@@ -1,46 +1,384 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	kms "cloud.google.com/go/kms/apiv1"
+	"github.com/coreos/etcd/clientv3"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/accounting"
+	"github.com/google/trillian/audit"
+	"github.com/google/trillian/auth"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/cloudkms"
+	"github.com/google/trillian/crypto/hsm"
+	"github.com/google/trillian/gateway"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/interceptor"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/quota"
+	quotaetcd "github.com/google/trillian/quota/etcd"
 	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/config"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/blob"
+	"github.com/google/trillian/storage/cache"
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
+	"github.com/google/trillian/storage/migrate"
 	"github.com/google/trillian/storage/mysql"
+	_ "github.com/google/trillian/storage/postgres"
 	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/election/etcd"
+	"github.com/google/trillian/util/publisher"
+	"github.com/google/trillian/util/rootcache"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
-	"sync"
+	"google.golang.org/grpc/credentials"
 )
 
-var mysqlUriFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
-	"uri to use with mysql storage")
+var configFileFlag = flag.String("config_file", "", "File containing a config.LogServerConfig in protobuf text format that sets the flags it covers, flags given on the command line override it. Its quota limits, leaf size limits and log verbosity are applied again on SIGHUP or a ReloadConfig RPC")
+var validateConfigFlag = flag.Bool("validate_config", false, "If true the settings given by flags and config_file are checked, along with the key and TLS files they name, and the server exits without starting")
+var storageSystemFlag = flag.String("storage_system", mysql.ProviderName, "Name of the registered storage system to use")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
+	"uri to use with the selected storage system")
 var serverPortFlag = flag.Int("port", 8090, "Port to serve log requests on")
-var sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second * 10, "Time to pause after each sequencing pass through all logs")
-var signerSleepBetweenRunsFlag = flag.Duration("signer_sleep_between_runs", time.Second * 120, "Time to pause after each signing pass through all logs")
+var sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second*10, "Time to pause after each sequencing pass through all logs")
+var signerSleepBetweenRunsFlag = flag.Duration("signer_sleep_between_runs", time.Second*120, "Time to pause after each signing pass through all logs")
 var batchSizeFlag = flag.Int("batch_size", 50, "Max number of leaves to process per batch")
+var adaptiveBatchSizeFlag = flag.Bool("adaptive_batch_size", false, "If true the sequencing batch size for each log starts at batch_size and adapts to queue depth and latency")
+var minBatchSizeFlag = flag.Int("min_batch_size", 10, "Smallest batch size adaptive sequencing will shrink to")
+var maxBatchSizeFlag = flag.Int("max_batch_size", 1000, "Largest batch size adaptive sequencing will grow to")
+var batchTargetLatencyFlag = flag.Duration("batch_target_latency", time.Second*2, "Sequencing runs slower than this shrink the adaptive batch size, 0 disables shrinking on latency")
+var sequencerWorkersFlag = flag.Int("sequencer_workers", 1, "Number of logs to sequence concurrently")
+var sequencerMaxRunsPerPassFlag = flag.Int("sequencer_max_runs_per_pass", 1, "Most sequencing runs a log with a backlog of queued leaves gets in each pass")
+var treeSequencerWeightsFlag = flag.String("tree_sequencer_weights", "", "Per log overrides of sequencer_max_runs_per_pass as a comma separated list of treeID=weight")
+var leafClaimOwnerFlag = flag.String("leaf_claim_owner", "", "If set the sequencer claims the leaves it integrates under this name, which must be unique to the instance, so that several instances can share the queue of a log. Empty disables claims")
+var leafClaimLeaseFlag = flag.Duration("leaf_claim_lease", time.Minute, "How long the leaves claimed by leaf_claim_owner stay claimed if they aren't integrated")
+var treeMaxMergeDelaysFlag = flag.String("tree_max_merge_delays", "", "Max merge delay of each log, the longest a leaf should be queued before it's integrated, as a comma separated list of treeID=duration. The time each leaf was queued for is exported for all logs, and leaves integrated after their log's delay are counted")
+var enforceMaxMergeDelayFlag = flag.Bool("enforce_max_merge_delay", false, "If true the sequencer takes in the leaves of a log in tree_max_merge_delays that have been queued for longer than its delay ahead of others, and refuses to sign a root that would still leave one out, logging an error instead. Can't be used with leaf_claim_owner")
+var treeBatchSizesFlag = flag.String("tree_batch_sizes", "", "Per log adaptive batch sizes as a comma separated list of treeID=initial:min:max")
+var subtreeGCRetainRevisionsFlag = flag.Int64("subtree_gc_retain_revisions", 0, "Number of recent tree revisions to keep fully readable when garbage collecting subtrees, 0 disables collection")
+var subtreeGCSleepBetweenRunsFlag = flag.Duration("subtree_gc_sleep_between_runs", time.Hour, "Time to pause after each subtree garbage collection pass through all logs")
+var selfAuditSleepBetweenRunsFlag = flag.Duration("self_audit_sleep_between_runs", 0, "Time to pause after each pass auditing the signed roots and inclusion proofs of all logs, 0 disables auditing")
+var selfAuditInclusionChecksFlag = flag.Int("self_audit_inclusion_checks", 10, "Number of random leaves of each log whose inclusion proofs are checked in each self audit pass")
+var deletedTreeGracePeriodFlag = flag.Duration("deleted_tree_grace_period", time.Hour*24*7, "Time a deleted tree can still be undeleted before its storage is removed")
+var deletedTreeGCSleepBetweenRunsFlag = flag.Duration("deleted_tree_gc_sleep_between_runs", time.Hour, "Time to pause after each pass removing deleted trees")
+var etcdServersFlag = flag.String("etcd_servers", "", "Comma separated etcd endpoints used to elect a master for each log and to share quotas, if empty this instance sequences all logs")
+var etcdElectionPrefixFlag = flag.String("etcd_election_prefix", "/trillian/master", "etcd key prefix for log master elections")
+var etcdQuotaPrefixFlag = flag.String("etcd_quota_prefix", "/trillian/quota", "etcd key prefix for quota token buckets")
+var quotaLimitsFlag = flag.String("quota_limits", "", "Token bucket limits on requests as a comma separated list of group/kind=capacity:rate, where group is global, tree or user and kind is read or write, requests aren't limited if empty. Global and tree write buckets with a rate of sequenced are refilled as leaves are sequenced")
+var quotaSystemFlag = flag.String("quota_system", "memory", "Where to keep quota token buckets, memory to enforce quotas on each instance separately or etcd to share them between instances through etcd_servers")
+var electionLeaseTTLFlag = flag.Int("election_lease_ttl_secs", 10, "Seconds a master can fail to refresh its lease before another instance takes over")
+var rootPublishersFlag = flag.String("root_publishers", "", "Comma separated file:// or http(s):// destinations each new signed log root is published to")
+var publishAttemptsFlag = flag.Int("publish_attempts", 3, "Number of times to try publishing each root before giving up")
+var publishBackoffFlag = flag.Duration("publish_backoff", time.Second, "Time to wait before retrying a failed publish, doubled after each further failure")
+var publishTimeoutFlag = flag.Duration("publish_timeout", time.Second*10, "Deadline for each attempt to publish a root over HTTP")
+var publishQueueSizeFlag = flag.Int("publish_queue_size", 100, "Number of roots that can be waiting to be published in the background, roots signed while it's full aren't published")
+var instanceIDFlag = flag.String("instance_id", "", "Name of this instance in master elections, defaults to hostname:port")
+var metricsEndpointFlag = flag.String("metrics_endpoint", "", "Address to serve Prometheus metrics on at /metrics, e.g. :8093, metrics aren't served if empty")
+var healthEndpointFlag = flag.String("health_endpoint", "", "Address to serve liveness on at /healthz and readiness on at /readyz, e.g. :8095, they aren't served over HTTP if empty. Readiness is always served by the gRPC health service")
+var healthCheckTimeoutFlag = flag.Duration("health_check_timeout", time.Second*5, "Longest each health check can take before it's counted as failed")
+var healthCheckPeriodFlag = flag.Duration("health_check_period", time.Second*10, "How often readiness is checked for the gRPC health service")
+var maxSequencingLagFlag = flag.Duration("max_sequencing_lag", 0, "The server isn't ready if the latest root of any log was signed longer ago than this, which must be longer than signer_sleep_between_runs. 0 disables the check")
+var shutdownTimeoutFlag = flag.Duration("shutdown_timeout", time.Second*30, "Longest to wait on shutdown for RPCs in flight to finish, and then for sequencing batches and other background work under way, before they're abandoned and their transactions rolled back")
+var traceSampleRateFlag = flag.Float64("trace_sample_rate", 0, "Fraction of requests and sequencing runs to trace, between 0 and 1. Requests traced by the client are traced regardless, if this is above 0")
+var httpEndpointFlag = flag.String("http_endpoint", "", "Address to serve the log and admin APIs on as REST with JSON bodies, e.g. :8097, forwarding requests to the RPC server. It's served over TLS if tls_cert_file is set. Requests through it are made without a client certificate, so they only get the permissions granted to *. The gateway isn't served if empty")
+var tlsCertFileFlag = flag.String("tls_cert_file", "", "File containing the PEM encoded certificate to serve log and admin requests over TLS with, TLS isn't used if empty")
+var tlsKeyFileFlag = flag.String("tls_key_file", "", "File containing the PEM encoded private key for tls_cert_file")
+var tlsClientCAFileFlag = flag.String("tls_client_ca_file", "", "File containing the PEM encoded CAs that must have signed client certificates, clients needn't present certificates if empty")
+var grantsFlag = flag.String("grants", "", "Permissions of clients as a comma separated list of identity/tree=permissions, where identity is a client certificate common name or * for all clients, tree is a tree ID or * for all trees and permissions are read, write or admin separated by +. Clients aren't checked if empty or auth isn't one of the interceptors")
+var interceptorsFlag = flag.String("interceptors", "monitoring,recovery,logging,audit,auth,accounting", "Comma separated interceptors that requests pass through in order before they're handled, from those registered with the interceptor package. audit records admin RPCs in the audit trail, auth checks the permissions given by grants and accounting counts the usage of each tree")
+var auditTrailFlag = flag.String("audit_trail", "", "Where admin RPCs, uses of signing keys and signed roots are recorded, either file:///path or trillian://host:port/<log ID> for a log hashed with RFC 6962 SHA-256. Nothing is recorded if empty")
+var sharedCacheMaxBytesFlag = flag.Int64("shared_subtree_cache_max_bytes", 0, "Approximate max bytes of subtree hashes cached between requests, shared by all trees, so that the subtrees near the top of each tree aren't read from storage by every request. 0 disables the shared cache")
+var subtreeKeyVersionFlag = flag.Int("subtree_key_version", 0, "Version of the suffix key encoding that subtrees are written with, see storage/suffix_key.go. Subtrees of every version are read whatever the setting, so only raise it once all the servers sharing the storage can read the new version")
+var storeSubtreeInternalNodesFlag = flag.Bool("store_subtree_internal_nodes", false, "If true subtrees are stored with their internal nodes, using about twice the space but saving the CPU spent recalculating them each time a subtree is read")
+var accountingPeriodFlag = flag.Duration("accounting_period", time.Minute, "How often the usage of each tree counted by the accounting interceptor is added to storage")
+var rootCacheTTLFlag = flag.Duration("root_cache_ttl", time.Second, "How long the latest signed root of each log is served from memory before it's read from storage again, roots signed by this instance replace it straight away. 0 disables the cache")
+var maxLeafSizeFlag = flag.Int("max_leaf_size", 0, "Most bytes of leaf value and extra data a leaf can have, larger leaves are rejected. 0 means there's no limit")
+var treeMaxLeafSizesFlag = flag.String("tree_max_leaf_sizes", "", "Per log overrides of max_leaf_size as a comma separated list of treeID=bytes")
+var treeQueuePrioritiesFlag = flag.String("tree_queue_priorities", "", "Range of priorities leaves can be queued with in each log, as a comma separated list of treeID=min:max that must include 0. The sequencer integrates leaves with higher priorities first, so e.g. a backfill queued at -1 doesn't delay other submissions. Priorities outside a log's range are limited to it, and logs that aren't listed queue every leaf at 0")
+var maxRangeLeavesFlag = flag.Int64("max_range_leaves", 1000, "Most leaves returned in each page of GetLeavesByRange, clients asking for more get them over several pages")
+var leafBlobStoresFlag = flag.String("leaf_blob_stores", "", "Blob stores that keep the large leaf values of logs, with only references to them in the database, as a comma separated list of treeID=uri. Only file:///<dir> stores are built in, others can be registered with the storage/blob package. A log's store must be kept for as long as it has values in it")
+var leafBlobMinSizeFlag = flag.Int("leaf_blob_min_size", 4096, "Smallest leaf value in bytes that's kept in the blob store of logs that have one")
+var leafDataKeyFilesFlag = flag.String("leaf_data_key_files", "", "Files holding the data keys that leaf values are encrypted with at rest, as a comma separated list of treeID=file, see crypto.LoadDataKeyFile. Every leaf value of these logs is kept in their blob store, encrypted with the key of the highest version. Keys are rotated and values rewrapped with cmd/rotate_leaf_keys")
+var leafDataKeyWrappersFlag = flag.String("leaf_data_key_wrappers", "", "Keys that the data keys of logs are wrapped by, as a comma separated list of treeID=uri, so that the owner of each key controls access to the log's values. Only local:<file> keys are built in, key management services can be registered with crypto.RegisterKeyWrapper. The data keys of other logs aren't wrapped")
+var dbMaxOpenConnsFlag = flag.Int("db_max_open_conns", 0, "Most connections open to the database at once, shared by all trees. Transactions wait up to db_query_timeout for one to be free. 0 means there's no limit")
+var dbMaxIdleConnsFlag = flag.Int("db_max_idle_conns", 0, "Most unused connections kept open to the database, 0 uses the driver's default")
+var dbConnMaxLifetimeFlag = flag.Duration("db_conn_max_lifetime", 0, "How long a database connection is reused for before it's closed, 0 means connections are kept open")
+var dbQueryTimeoutFlag = flag.Duration("db_query_timeout", 0, "Longest a transaction waits for a database connection and that each query can run, 0 means they're only limited by the deadlines of requests")
+var dbBreakerFailuresFlag = flag.Int("db_breaker_failures", 0, "Database failures in a row after which requests are refused with RESOURCE_EXHAUSTED until it recovers, rather than queued up for it. 0 disables the circuit breaker")
+var dbBreakerOpenDurationFlag = flag.Duration("db_breaker_open_duration", time.Second*5, "How long requests are refused for once the circuit breaker opens, before one is let through to test the database again")
+var autoMigrateFlag = flag.Bool("auto_migrate", false, "If true any schema migrations the database is missing are applied at startup, otherwise the server refuses to start until they've been applied with cmd/migrate")
+var subtreeShardsFlag = flag.String("subtree_shards", "", "Databases that the subtrees of trees created by this server are spread over, as a comma separated list of name=dsn. Trees keep the names of their shards, so a name must stay for as long as trees use it, though its DSN can change. Only supported by mysql")
+
+// Each log is signed with the key named by its key ID, e.g. "pem:<file>", and the server key is
+// used for logs with key IDs that don't name a registered key scheme.
+var privateKeyFile = flag.String("private_key_file", "", "File containing a PEM encoded private key used for logs that don't name their own key")
+var privateKeyPassword = flag.String("private_key_password", "", "Password for server private key and any PEM keys named by logs")
+var cloudKMSKeysFlag = flag.Bool("cloud_kms_keys", false, "If true logs can be signed with keys held in Google Cloud KMS, named by key IDs of the form cloudkms:projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/V. The application default credentials are used")
+var cloudKMSTimeoutFlag = flag.Duration("cloud_kms_timeout", time.Second*10, "Deadline for each call to Cloud KMS to get a public key or sign a root")
+var pkcs11ModuleFlag = flag.String("pkcs11_module", "", "PKCS #11 library of an HSM that logs can be signed with keys held in, named by key IDs of the form pkcs11:<label>. HSM keys can't be used if empty")
+var pkcs11TokenLabelFlag = flag.String("pkcs11_token_label", "", "Label of the token that pkcs11_module logs in to")
+var pkcs11PINFlag = flag.String("pkcs11_pin", "", "User PIN of the token that pkcs11_module logs in to")
+var witnessPublicKeysFlag = flag.String("witness_public_keys", "", "Witnesses allowed to cosign log roots as a comma separated list of witnessID=file, where each file holds a PEM encoded public key")
+
+// reloadableFlags are the flags set from config_file again when it's reloaded, the others
+// keep the values they had at startup
+var reloadableFlags = []string{"quota_limits", "max_leaf_size", "tree_max_leaf_sizes", "tree_queue_priorities", "v"}
 
-// TODO(Martin2112): Single private key doesn't really work for multi tenant and we can't use
-// an HSM interface in this way. Deferring these issues for later.
-var privateKeyFile = flag.String("private_key_file", "", "File containing a PEM encoded private key")
-var privateKeyPassword = flag.String("private_key_password", "", "Password for server private key")
+// The flags given on the command line, which reloading config_file doesn't change
+var commandLineFlags map[string]bool
 
 // Must hold this lock before accessing the storage map
 var storageMapGuard sync.Mutex
+
 // Map from tree ID to storage impl for that log
 var storageMap = make(map[int64]storage.LogStorage)
 
-// TODO(Martin2112): Needs to be able to swap out for different storage type
-func simpleMySqlStorageProvider(treeID int64) (storage.LogStorage, error) {
-	return mysql.NewLogStorage(trillian.LogID{[]byte("TODO"), treeID}, *mysqlUriFlag)
+// The provider for the storage system selected by flags, set up in main
+var storageProvider storage.Provider
+
+// The blob stores for large leaf values of the logs that have them, set up in main
+var leafBlobStores map[int64]blob.Store
+
+// The data keys of the logs whose leaf values are encrypted, set up in main
+var leafDataKeys map[int64]crypto.DataKeyManager
+
+// The subtree cache shared by the storage for all the logs, or nil if it's disabled
+var sharedSubtreeCache *cache.SharedSubtreeCache
+
+// Tracks the sequencing, garbage collection and accounting loops, which finish the work
+// they have under way once done is closed
+var background sync.WaitGroup
+
+func simpleStorageProvider(treeID int64) (storage.LogStorage, error) {
+	s, err := storageProvider.LogStorage(trillian.LogID{[]byte("TODO"), treeID})
+	if err != nil {
+		return nil, err
+	}
+
+	if ss, ok := s.(cache.InternalNodeStorageSetter); ok {
+		ss.SetStoreInternalNodes(*storeSubtreeInternalNodesFlag)
+	} else if *storeSubtreeInternalNodesFlag {
+		glog.Warningf("Storage for log %d can't store subtree internal nodes", treeID)
+	}
+
+	if ks, ok := s.(cache.SubtreeKeyVersionSetter); ok {
+		ks.SetSubtreeKeyVersion(int32(*subtreeKeyVersionFlag))
+	} else if *subtreeKeyVersionFlag != 0 {
+		glog.Warningf("Storage for log %d can't write subtree key version %d", treeID, *subtreeKeyVersionFlag)
+	}
+
+	if sharedSubtreeCache != nil {
+		if cs, ok := s.(cache.SharedCacheSetter); ok {
+			cs.SetSharedSubtreeCache(sharedSubtreeCache)
+		} else {
+			glog.Warningf("Storage for log %d can't use the shared subtree cache", treeID)
+		}
+	}
+
+	if store, ok := leafBlobStores[treeID]; ok {
+		minSize := *leafBlobMinSizeFlag
+
+		// Every value of a log with data keys goes to its blob store, so none are kept
+		// unencrypted in the database
+		if keys, ok := leafDataKeys[treeID]; ok {
+			store, minSize = blob.NewEncryptedStore(store, keys), 0
+		}
+
+		s = blob.NewLogStorage(s, treeID, store, minSize)
+	}
+
+	return s, nil
+}
+
+// parseLeafBlobStoresAndKeys returns the leaf blob stores and data keys set by flags. Encrypted
+// values are kept in the blob store, so every log with data keys must have one.
+func parseLeafBlobStoresAndKeys() (map[int64]blob.Store, map[int64]crypto.DataKeyManager, error) {
+	stores, err := server.ParseLeafBlobStores(*leafBlobStoresFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := server.ParseLeafDataKeys(*leafDataKeyFilesFlag, *leafDataKeyWrappersFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for treeID := range keys {
+		if _, ok := stores[treeID]; !ok {
+			return nil, nil, fmt.Errorf("log %d has data keys but no leaf blob store to keep its encrypted values in", treeID)
+		}
+	}
+
+	return stores, keys, nil
+}
+
+// createEtcdClient returns a client for the etcd servers configured by flags, or nil if
+// there aren't any
+func createEtcdClient() (*clientv3.Client, error) {
+	if len(*etcdServersFlag) == 0 {
+		return nil, nil
+	}
+
+	return clientv3.New(clientv3.Config{Endpoints: strings.Split(*etcdServersFlag, ","), DialTimeout: time.Second * 5})
+}
+
+// createElection returns the election used to decide which logs this instance sequences,
+// which is always master unless etcd servers have been configured by flags
+func createElection(client *clientv3.Client) (election.Election, error) {
+	if client == nil {
+		return election.SingleNode{}, nil
+	}
+
+	instanceID := *instanceIDFlag
+
+	if len(instanceID) == 0 {
+		hostname, err := os.Hostname()
+
+		if err != nil {
+			return nil, err
+		}
+
+		instanceID = fmt.Sprintf("%s:%d", hostname, *serverPortFlag)
+	}
+
+	return etcd.NewElection(client, instanceID, *etcdElectionPrefixFlag, *electionLeaseTTLFlag)
+}
+
+// createQuotaManager returns the quota manager configured by flags, which doesn't limit
+// requests unless some limits have been set. Quotas kept in memory or etcd can have their
+// limits changed when the config is reloaded.
+func createQuotaManager(client *clientv3.Client) (quota.Manager, error) {
+	limits, err := quota.ParseLimits(*quotaLimitsFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch *quotaSystemFlag {
+	case "memory":
+		return quota.NewMemoryManager(limits, util.SystemTimeSource{}), nil
+	case "etcd":
+		if client == nil {
+			if len(limits) == 0 {
+				return quota.Unlimited{}, nil
+			}
+
+			return nil, errors.New("etcd quotas need etcd_servers to be set")
+		}
+
+		return quotaetcd.NewManager(client, *etcdQuotaPrefixFlag, limits, util.SystemTimeSource{}), nil
+	default:
+		return nil, fmt.Errorf("unknown quota system: %s", *quotaSystemFlag)
+	}
+}
+
+// createPublisher returns the publisher that new roots are sent to, or nil if no
+// destinations have been configured by flags
+func createPublisher() (publisher.Publisher, error) {
+	if len(*rootPublishersFlag) == 0 {
+		return nil, nil
+	}
+
+	p, err := publisher.NewFromURIs(*rootPublishersFlag, *publishTimeoutFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := publisher.NewRetrying(p, *publishAttemptsFlag, *publishBackoffFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Roots are published in the background so a slow destination doesn't hold up signing
+	return publisher.NewAsync(r, *publishQueueSizeFlag)
+}
+
+// createSequencerManager returns a sequencer using a fixed batch size unless adaptive batch
+// sizes have been enabled by flags
+func createSequencerManager(kmp server.KeyManagerProviderFunc, e election.Election) (*server.SequencerManager, error) {
+	if !*adaptiveBatchSizeFlag {
+		return server.NewElectedSequencerManager(kmp, nil, e), nil
+	}
+
+	defaults := server.BatchSizeConfig{Initial: *batchSizeFlag, Min: *minBatchSizeFlag, Max: *maxBatchSizeFlag, TargetLatency: *batchTargetLatencyFlag}
+	overrides, err := server.ParseBatchSizeOverrides(*treeBatchSizesFlag, defaults)
+
+	if err != nil {
+		return nil, err
+	}
+
+	batchSizer, err := server.NewAdaptiveBatchSizer(defaults, overrides)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return server.NewElectedSequencerManager(kmp, batchSizer, e), nil
+}
+
+// createSequencerScheduler returns the operation that shares sequencing between active logs
+// as configured by flags
+func createSequencerScheduler(kmp server.KeyManagerProviderFunc, e election.Election, quotaManager quota.Manager, rootCache *rootcache.Cache, recorder *audit.Recorder) (*server.SequencerScheduler, error) {
+	sequencer, err := createSequencerManager(kmp, e)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sequencer.SetQuotaManager(quotaManager)
+	sequencer.SetRootCache(rootCache)
+
+	if len(*leafClaimOwnerFlag) > 0 {
+		sequencer.SetLeafClaims(*leafClaimOwnerFlag, *leafClaimLeaseFlag)
+	}
+
+	maxMergeDelays, err := server.ParseMaxMergeDelays(*treeMaxMergeDelaysFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sequencer.SetMaxMergeDelays(maxMergeDelays, *enforceMaxMergeDelayFlag)
+
+	if recorder != nil {
+		sequencer.SetAuditRecorder(recorder)
+	}
+
+	p, err := createPublisher()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p != nil {
+		sequencer.SetPublisher(p)
+	}
+
+	weights, err := server.ParseSequencerWeights(*treeSequencerWeightsFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return server.NewSequencerScheduler(sequencer, server.SequencerScheduleConfig{Workers: *sequencerWorkersFlag, DefaultWeight: *sequencerMaxRunsPerPassFlag, Weights: weights})
 }
 
 // TODO(Martin2112): Could pull this out as a wrapper so it can be used elsewhere
@@ -54,7 +392,7 @@ func getStorageForLog(logId int64) (storage.LogStorage, error) {
 		glog.Infof("Creating new storage for log: %d", logId)
 
 		var err error
-		s, err = simpleMySqlStorageProvider(logId)
+		s, err = simpleStorageProvider(logId)
 
 		if err != nil {
 			return s, err
@@ -66,16 +404,53 @@ func getStorageForLog(logId int64) (storage.LogStorage, error) {
 	return s, nil
 }
 
-func checkDatabaseAccessible(dbUri string) error {
+// connectionConfig returns the database connection limits set by flags
+func connectionConfig() storage.ConnectionConfig {
+	config := storage.ConnectionConfig{
+		MaxOpenConns:    *dbMaxOpenConnsFlag,
+		MaxIdleConns:    *dbMaxIdleConnsFlag,
+		ConnMaxLifetime: *dbConnMaxLifetimeFlag,
+		QueryTimeout:    *dbQueryTimeoutFlag,
+	}
+
+	if *dbBreakerFailuresFlag != 0 {
+		config.Breaker = storage.CircuitBreakerConfig{Failures: *dbBreakerFailuresFlag, OpenDuration: *dbBreakerOpenDurationFlag}
+	}
+
+	return config
+}
+
+// checkSchema applies the schema migrations the database is missing if auto_migrate is set,
+// otherwise it returns an error if there are any
+func checkSchema(provider storage.Provider) error {
+	db, migrations, err := storage.Migrations(provider)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if !*autoMigrateFlag {
+		return migrate.Check(ctx, db, migrations)
+	}
+
+	applied, err := migrate.Run(ctx, db, migrations)
+	glog.Infof("Applied %d schema migrations", applied)
+
+	return err
+}
+
+func checkDatabaseAccessible(ctx context.Context, provider storage.Provider) error {
 	// TODO(Martin2112): Have to pass a tree ID when we just want metadata. API mismatch
-	storage, err := mysql.NewLogStorage(trillian.LogID{[]byte("TODO"), int64(0)}, dbUri)
+	storage, err := provider.LogStorage(trillian.LogID{[]byte("TODO"), int64(0)})
 
 	if err != nil {
 		// This is probably something fundamentally wrong
 		return err
 	}
 
-	tx, err := storage.Begin()
+	tx, err := storage.Begin(ctx)
 
 	if err != nil {
 		// Out of resources maybe?
@@ -85,20 +460,360 @@ func checkDatabaseAccessible(dbUri string) error {
 	defer tx.Commit()
 
 	// Pull the log ids, we don't care about the result, we just want to know that it works
-	_, err = tx.GetActiveLogIDs()
+	_, err = tx.GetActiveLogIDs(ctx)
 
 	return err
 }
 
-func startRpcServer(listener net.Listener, port int, provider server.LogStorageProviderFunc) *grpc.Server {
-	grpcServer := grpc.NewServer()
-	logServer := server.NewTrillianLogServer(provider)
+// createHealthChecker returns the checks of whether the server is ready to serve requests:
+// that it can reach the database and use the keys of the logs, and if max_sequencing_lag is
+// set that their roots are being signed
+func createHealthChecker(kmp server.KeyManagerProviderFunc) *health.Checker {
+	checker := health.NewChecker(*healthCheckTimeoutFlag)
+	checker.AddReadinessCheck("database", func(ctx context.Context) error {
+		return checkDatabaseAccessible(ctx, storageProvider)
+	})
+	checker.AddReadinessCheck("keys", server.NewLogKeysCheck(getStorageForLog, kmp))
+
+	if *maxSequencingLagFlag > 0 {
+		checker.AddReadinessCheck("sequencing", server.NewSequencingLagCheck(getStorageForLog, util.SystemTimeSource{}, *maxSequencingLagFlag))
+	}
+
+	return checker
+}
+
+func init() {
+	if err := interceptor.Register("auth", newAuthInterceptor); err != nil {
+		panic(err)
+	}
+}
+
+// createServer returns an RPC server that uses TLS if configured by flags, and passes
+// requests through the interceptors they list
+func createServer() (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+
+	if len(*tlsCertFileFlag) > 0 {
+		config, err := auth.NewServerTLSConfig(*tlsCertFileFlag, *tlsKeyFileFlag, *tlsClientCAFileFlag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	} else if len(*tlsClientCAFileFlag) > 0 {
+		return nil, errors.New("client certificates can't be checked without tls_cert_file")
+	}
+
+	chain, err := interceptor.NewChain(*interceptorsFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return interceptor.NewServer(chain, opts...), nil
+}
+
+// newAuthInterceptor checks clients have the permissions given to them by flags, it lets
+// all requests through if there aren't any
+func newAuthInterceptor() (interceptor.Interceptor, error) {
+	if len(*grantsFlag) == 0 {
+		return interceptor.Interceptor{}, nil
+	}
+
+	grants, err := auth.ParseGrants(*grantsFlag)
+
+	if err != nil {
+		return interceptor.Interceptor{}, err
+	}
+
+	return interceptor.Interceptor{Unary: auth.UnaryServerInterceptor(grants), Stream: auth.StreamServerInterceptor(grants)}, nil
+}
+
+// startAccounting counts the usage of trees by requests through the accounting interceptor
+// and adds it to storage as often as flags say
+func startAccounting(done chan struct{}, sp accounting.StorageProviderFunc) (*accounting.Accountant, error) {
+	accountant := accounting.NewAccountant(done, sp, *accountingPeriodFlag, util.SystemTimeSource{})
+
+	// The interceptor can only be registered once there's an accountant for it to count with
+	err := interceptor.Register("accounting", func() (interceptor.Interceptor, error) {
+		return interceptor.Interceptor{Unary: accountant.UnaryServerInterceptor(), Stream: accountant.StreamServerInterceptor()}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	runInBackground(accountant.Run)
+	return accountant, nil
+}
+
+// createAuditRecorder returns the recorder for the audit trail configured by flags, or nil if
+// there isn't one
+func createAuditRecorder() (*audit.Recorder, error) {
+	var recorder *audit.Recorder
+
+	if len(*auditTrailFlag) > 0 {
+		trail, err := audit.NewTrailFromURI(*auditTrailFlag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		recorder = audit.NewRecorder(trail, util.SystemTimeSource{})
+	}
+
+	// The interceptor is registered either way so it can be in the default list, without a
+	// trail it records nothing
+	err := interceptor.Register("audit", func() (interceptor.Interceptor, error) {
+		if recorder == nil {
+			return interceptor.Interceptor{}, nil
+		}
+
+		return interceptor.Interceptor{Unary: recorder.UnaryServerInterceptor()}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+func startRpcServer(listener net.Listener, port int, provider server.LogStorageProviderFunc, adminProvider server.AdminStorageProviderFunc, witnessKeys server.WitnessKeyProviderFunc, quotaManager quota.Manager, accountant *accounting.Accountant, rootCache *rootcache.Cache, recorder *audit.Recorder, reloader *settingsReloader) (*grpc.Server, error) {
+	grpcServer, err := createServer()
+
+	if err != nil {
+		return nil, err
+	}
+
+	maxLeafSizes, err := server.ParseMaxLeafSizes(*treeMaxLeafSizesFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	queuePriorities, err := server.ParseQueuePriorities(*treeQueuePrioritiesFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMaxRangeLeaves(); err != nil {
+		return nil, err
+	}
+
+	logServer := server.NewTrillianLogServerWithWitnesses(provider, witnessKeys)
+	logServer.SetQuotaManager(quotaManager)
+	logServer.SetRootCache(rootCache)
+	logServer.SetMaxLeafSizes(*maxLeafSizeFlag, maxLeafSizes)
+	logServer.SetQueuePriorities(queuePriorities)
+	logServer.SetMaxRangeLeaves(*maxRangeLeavesFlag)
 	trillian.RegisterTrillianLogServer(grpcServer, logServer)
+	reloader.logServer = logServer
+	adminServer := server.NewTrillianAdminServer(adminProvider)
+	adminServer.SetAccountant(accountant)
+	adminServer.SetConfigReloader(reloader.reload)
+
+	if recorder != nil {
+		adminServer.SetAuditTrail(recorder.Trail())
+	}
+
+	trillian.RegisterTrillianAdminServer(grpcServer, adminServer)
+
+	return grpcServer, nil
+}
+
+// settingsReloader applies the settings in config_file that can change while the server is
+// running, when it's sent SIGHUP or a ReloadConfig RPC
+type settingsReloader struct {
+	// Must hold this lock while reloading, so that reloads don't interleave
+	mutex        sync.Mutex
+	quotaManager quota.Manager
+	logServer    *server.TrillianLogServer
+}
+
+// reload reads config_file again and applies the reloadable flags it sets. If any of them
+// is invalid the server keeps the settings it had.
+func (r *settingsReloader) reload(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(*configFileFlag) == 0 {
+		return errors.New("there's no config_file to reload")
+	}
+
+	cfg, err := config.LoadLogServerConfig(*configFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	if err := config.ReloadFlags(flag.CommandLine, config.LogServerFlags(cfg), commandLineFlags, reloadableFlags, r.apply); err != nil {
+		return err
+	}
+
+	glog.Infof("Reloaded settings from %s", *configFileFlag)
+	return nil
+}
+
+// apply puts the reloadable flags into effect, changing nothing unless all of them can be
+// applied. The log verbosity has already taken effect, glog applies it when it's set.
+func (r *settingsReloader) apply() error {
+	limits, err := quota.ParseLimits(*quotaLimitsFlag)
+
+	if err != nil {
+		return err
+	}
+
+	limitSetter, ok := r.quotaManager.(quota.LimitSetter)
+
+	if !ok && len(limits) > 0 {
+		return errors.New("etcd quotas need etcd_servers to be set")
+	}
+
+	maxLeafSizes, err := server.ParseMaxLeafSizes(*treeMaxLeafSizesFlag)
+
+	if err != nil {
+		return err
+	}
+
+	queuePriorities, err := server.ParseQueuePriorities(*treeQueuePrioritiesFlag)
+
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		limitSetter.SetLimits(limits)
+	}
+
+	r.logServer.SetMaxLeafSizes(*maxLeafSizeFlag, maxLeafSizes)
+	r.logServer.SetQueuePriorities(queuePriorities)
+	return nil
+}
+
+// registerExternalKeyManagers makes the keys held in Cloud KMS and on an HSM available to
+// logs, if the flags ask for them. The token stays logged in until the server exits.
+func registerExternalKeyManagers() error {
+	if *cloudKMSKeysFlag {
+		client, err := kms.NewKeyManagementClient(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to create Cloud KMS client: %v", err)
+		}
+
+		if err := crypto.RegisterKeyManager(cloudkms.KeyScheme, cloudkms.NewKeyManagerFunc(client, *cloudKMSTimeoutFlag)); err != nil {
+			return err
+		}
+	}
+
+	if len(*pkcs11ModuleFlag) > 0 {
+		token, err := hsm.OpenToken(*pkcs11ModuleFlag, *pkcs11TokenLabelFlag, *pkcs11PINFlag)
+		if err != nil {
+			return err
+		}
+
+		if err := crypto.RegisterKeyManager(hsm.KeyScheme, token.NewKeyManagerFunc()); err != nil {
+			token.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadOnSignal reloads the settings each time the server is sent SIGHUP, until done is
+// closed
+func reloadOnSignal(done <-chan struct{}, reloader *settingsReloader) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-sigs:
+		}
+
+		if err := reloader.reload(context.Background()); err != nil {
+			glog.Warningf("Failed to reload config: %v", err)
+		}
+	}
+}
+
+// startGateway serves the REST gateway to the log and admin APIs on http_endpoint. It forwards requests
+// to the RPC server on port until done is closed.
+func startGateway(done <-chan struct{}, port int) error {
+	opts, err := gateway.DialOptions(*tlsCertFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h, err := gateway.NewHandler(ctx, fmt.Sprintf("localhost:%d", port), opts, trillian.RegisterTrillianLogHandlerFromEndpoint, trillian.RegisterTrillianAdminHandlerFromEndpoint)
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	gateway.StartServer(*httpEndpointFlag, h, *tlsCertFileFlag, *tlsKeyFileFlag)
+	return nil
+}
+
+// runInBackground runs f in a goroutine that waitForBackground waits for
+func runInBackground(f func(context.Context)) {
+	background.Add(1)
+
+	go func() {
+		defer background.Done()
+		f(context.Background())
+	}()
+}
+
+// waitForBackground waits up to timeout for the goroutines started by runInBackground to
+// return, and reports whether they did. Work still under way after that is abandoned when
+// the process exits, the database rolls back its uncommitted transactions.
+func waitForBackground(timeout time.Duration) bool {
+	finished := make(chan struct{})
 
-	return grpcServer
+	go func() {
+		background.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// closeAuditTrail closes the audit trail, if it's one that has to be closed, so that
+// everything recorded is kept
+func closeAuditTrail(recorder *audit.Recorder) {
+	if recorder == nil {
+		return
+	}
+
+	if c, ok := recorder.Trail().(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			glog.Warningf("Failed to close the audit trail: %v", err)
+		}
+	}
 }
 
-func awaitSignal(rpcServer *grpc.Server) {
+func awaitSignal(rpcServer *grpc.Server, healthChecker *health.Checker, drained chan<- struct{}) {
+	defer close(drained)
+
 	// Arrange notification for the standard set of signals used to terminate a server
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -107,30 +822,285 @@ func awaitSignal(rpcServer *grpc.Server) {
 	sig := <-sigs
 	glog.Infof("Signal received: %v", sig)
 
-	// Bring down the RPC server, which will unblock main
-	rpcServer.Stop()
+	// Stop being ready, so no more requests are sent our way, and bring down the RPC server,
+	// which will unblock main. The RPCs in flight are let finish for a while, after that
+	// they're cancelled, which rolls back their transactions.
+	healthChecker.SetShuttingDown()
+	stopped := make(chan struct{})
+
+	go func() {
+		rpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(*shutdownTimeoutFlag):
+		glog.Warningf("RPCs still in flight after %v, cancelling them", *shutdownTimeoutFlag)
+		rpcServer.Stop()
+	}
+}
+
+// applyConfigFile sets the flags that aren't given on the command line from the config file
+// named by config_file, if there is one
+func applyConfigFile() error {
+	if len(*configFileFlag) == 0 {
+		return nil
+	}
+
+	cfg, err := config.LoadLogServerConfig(*configFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	return config.SetFlags(flag.CommandLine, config.LogServerFlags(cfg))
+}
+
+func checkMaxRangeLeaves() error {
+	if *maxRangeLeavesFlag <= 0 {
+		return fmt.Errorf("max_range_leaves must be > 0 but was %d", *maxRangeLeavesFlag)
+	}
+
+	return nil
+}
+
+// validateSettings checks the settings given by flags, parsing them as the server would
+// when it uses them and reading the files they name, but without connecting to anything
+func validateSettings() error {
+	if *subtreeKeyVersionFlag < 0 || *subtreeKeyVersionFlag > int(storage.MaxSuffixKeyVersion) {
+		return fmt.Errorf("unknown subtree key version %d, must be between 0 and %d", *subtreeKeyVersionFlag, storage.MaxSuffixKeyVersion)
+	}
+
+	found := false
+	for _, name := range storage.Providers() {
+		found = found || name == *storageSystemFlag
+	}
+	if !found {
+		return fmt.Errorf("unknown storage system %s (registered: %v)", *storageSystemFlag, storage.Providers())
+	}
+
+	if _, err := storage.ParseSubtreeShards(*subtreeShardsFlag); err != nil {
+		return err
+	}
+
+	if err := checkMaxRangeLeaves(); err != nil {
+		return err
+	}
+
+	if _, err := quota.ParseLimits(*quotaLimitsFlag); err != nil {
+		return err
+	}
+
+	if *quotaSystemFlag != "memory" && *quotaSystemFlag != "etcd" {
+		return fmt.Errorf("unknown quota system: %s", *quotaSystemFlag)
+	}
+
+	if *quotaSystemFlag == "etcd" && len(*quotaLimitsFlag) > 0 && len(*etcdServersFlag) == 0 {
+		return errors.New("etcd quotas need etcd_servers to be set")
+	}
+
+	if _, err := auth.ParseGrants(*grantsFlag); err != nil {
+		return err
+	}
+
+	if _, err := server.ParseSequencerWeights(*treeSequencerWeightsFlag); err != nil {
+		return err
+	}
+
+	if len(*leafClaimOwnerFlag) > 0 && *leafClaimLeaseFlag <= 0 {
+		return fmt.Errorf("leaf_claim_lease must be > 0 but was %v", *leafClaimLeaseFlag)
+	}
+
+	if _, err := server.ParseMaxMergeDelays(*treeMaxMergeDelaysFlag); err != nil {
+		return err
+	}
+
+	if *enforceMaxMergeDelayFlag && len(*leafClaimOwnerFlag) > 0 {
+		return errors.New("enforce_max_merge_delay can't be used with leaf_claim_owner")
+	}
+
+	if *adaptiveBatchSizeFlag {
+		defaults := server.BatchSizeConfig{Initial: *batchSizeFlag, Min: *minBatchSizeFlag, Max: *maxBatchSizeFlag, TargetLatency: *batchTargetLatencyFlag}
+		overrides, err := server.ParseBatchSizeOverrides(*treeBatchSizesFlag, defaults)
+
+		if err == nil {
+			_, err = server.NewAdaptiveBatchSizer(defaults, overrides)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := server.ParseMaxLeafSizes(*treeMaxLeafSizesFlag); err != nil {
+		return err
+	}
+
+	if _, err := server.ParseQueuePriorities(*treeQueuePrioritiesFlag); err != nil {
+		return err
+	}
+
+	if _, _, err := parseLeafBlobStoresAndKeys(); err != nil {
+		return err
+	}
+
+	if len(*tlsCertFileFlag) > 0 {
+		if _, err := auth.NewServerTLSConfig(*tlsCertFileFlag, *tlsKeyFileFlag, *tlsClientCAFileFlag); err != nil {
+			return err
+		}
+	}
+
+	if len(*httpEndpointFlag) > 0 {
+		if len(*tlsClientCAFileFlag) > 0 {
+			return errors.New("the REST gateway can't present a client certificate, so http_endpoint can't be used with tls_client_ca_file")
+		}
+
+		if _, err := gateway.DialOptions(*tlsCertFileFlag); err != nil {
+			return err
+		}
+	}
+
+	if len(*privateKeyFile) > 0 {
+		if _, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword); err != nil {
+			return err
+		}
+	}
+
+	if len(*witnessPublicKeysFlag) > 0 {
+		if _, err := server.LoadWitnessKeys(*witnessPublicKeysFlag); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func main() {
 	flag.Parse()
+	commandLineFlags = config.GivenFlags(flag.CommandLine)
+
+	if err := applyConfigFile(); err != nil {
+		glog.Errorf("Could not apply config file %s: %v", *configFileFlag, err)
+		os.Exit(1)
+	}
+
+	if *validateConfigFlag {
+		if err := validateSettings(); err != nil {
+			glog.Errorf("Invalid settings: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Settings are valid")
+		return
+	}
 
 	done := make(chan struct{})
 
 	glog.Info("**** Log Server Starting ****")
 
+	if len(*metricsEndpointFlag) > 0 {
+		monitoring.StartServer(*metricsEndpointFlag)
+	}
+
+	if *traceSampleRateFlag > 0 {
+		defer monitoring.InitTracing(*traceSampleRateFlag)()
+	}
+
+	if *subtreeKeyVersionFlag < 0 || *subtreeKeyVersionFlag > int(storage.MaxSuffixKeyVersion) {
+		glog.Errorf("Unknown subtree key version %d, must be between 0 and %d", *subtreeKeyVersionFlag, storage.MaxSuffixKeyVersion)
+		os.Exit(1)
+	}
+
+	if *sharedCacheMaxBytesFlag > 0 {
+		sharedSubtreeCache = cache.NewSharedSubtreeCache(cache.CacheLimits{MaxBytes: *sharedCacheMaxBytesFlag})
+	}
+
+	// Set up the selected storage system, quit if it's not available
+	var err error
+	storageProvider, err = storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+
+	if err != nil {
+		glog.Errorf("Could not create storage provider %s (registered: %v): %v", *storageSystemFlag, storage.Providers(), err)
+		os.Exit(1)
+	}
+
+	if err := storage.SetConnectionConfig(storageProvider, connectionConfig()); err != nil {
+		glog.Errorf("Could not limit database connections: %v", err)
+		os.Exit(1)
+	}
+
+	shards, err := storage.ParseSubtreeShards(*subtreeShardsFlag)
+	if err == nil {
+		err = storage.SetSubtreeShards(storageProvider, shards)
+	}
+	if err != nil {
+		glog.Errorf("Could not set subtree shards: %v", err)
+		os.Exit(1)
+	}
+
+	if err := checkSchema(storageProvider); err != nil {
+		glog.Errorf("Could not check database schema, it may need migrating with cmd/migrate or --auto_migrate: %v", err)
+		os.Exit(1)
+	}
+
 	// First make sure we can access the database, quit if not
-	if err := checkDatabaseAccessible(*mysqlUriFlag); err != nil {
+	if err := checkDatabaseAccessible(context.Background(), storageProvider); err != nil {
 		glog.Errorf("Could not access storage, check db configuration and flags")
 		os.Exit(1)
 	}
 
-	// Load up our private key, exit if this fails to work
-	// TODO(Martin2112): This will need to be changed for multi tenant as we'll need at
-	// least one key per tenant, possibly more.
-	keyManager, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+	leafBlobStores, leafDataKeys, err = parseLeafBlobStoresAndKeys()
 
 	if err != nil {
-		glog.Fatalf("Failed to load server key: %v", err)
+		glog.Errorf("Could not set up leaf blob stores: %v", err)
+		os.Exit(1)
+	}
+
+	// The admin API shares a single storage instance for all the tree metadata
+	adminStorage, err := storageProvider.AdminStorage()
+
+	if err != nil {
+		glog.Errorf("Could not create admin storage: %v", err)
+		os.Exit(1)
+	}
+
+	// Make PEM key files, rotation schedules of other keys and deterministic ECDSA signing
+	// available to logs, along with keys in Cloud KMS or on an HSM if they're configured
+	if err := crypto.RegisterKeyManager(crypto.PEMKeyScheme, crypto.NewPEMFileKeyManagerFunc(*privateKeyPassword)); err != nil {
+		glog.Fatalf("Failed to register PEM key manager: %v", err)
+	}
+	if err := crypto.RegisterKeyManager(crypto.RotatingKeyScheme, crypto.NewRotatingKeyManagerFunc(util.SystemTimeSource{})); err != nil {
+		glog.Fatalf("Failed to register rotating key manager: %v", err)
+	}
+	if err := crypto.RegisterKeyManager(crypto.DeterministicECDSAScheme, crypto.NewDeterministicKeyManagerFunc()); err != nil {
+		glog.Fatalf("Failed to register deterministic ECDSA key manager: %v", err)
+	}
+	if err := registerExternalKeyManagers(); err != nil {
+		glog.Fatalf("Failed to register key manager: %v", err)
+	}
+
+	// Load up our private key if there is one, exit if this fails to work
+	var keyManager crypto.KeyManager
+
+	if len(*privateKeyFile) > 0 {
+		keyManager, err = crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+
+		if err != nil {
+			glog.Fatalf("Failed to load server key: %v", err)
+		}
+	}
+
+	// Cosignatures are only accepted if some witnesses are configured
+	var witnessKeys server.WitnessKeyProviderFunc
+
+	if len(*witnessPublicKeysFlag) > 0 {
+		keys, err := server.LoadWitnessKeys(*witnessPublicKeysFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to load witness keys: %v", err)
+		}
+
+		witnessKeys = server.NewWitnessKeyProvider(keys)
 	}
 
 	// Set up the listener for the server
@@ -146,12 +1116,108 @@ func main() {
 	// Start the sequencing loop, which will run until we terminate the process. This controls
 	// both sequencing and signing.
 	// TODO(Martin2112): Should respect read only mode and the flags in tree control etc
-	sequencerManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *sequencerSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, server.NewSequencerManager(keyManager))
-	go sequencerManager.OperationLoop()
+	etcdClient, err := createEtcdClient()
+
+	if err != nil {
+		glog.Errorf("Failed to connect to etcd: %v", err)
+		os.Exit(1)
+	}
+
+	masterElection, err := createElection(etcdClient)
+
+	if err != nil {
+		glog.Errorf("Failed to set up master election: %v", err)
+		os.Exit(1)
+	}
+
+	quotaManager, err := createQuotaManager(etcdClient)
+
+	if err != nil {
+		glog.Errorf("Failed to set up quotas: %v", err)
+		os.Exit(1)
+	}
+
+	// The RPC server answers requests for the latest root from memory and the sequencer
+	// replaces the roots of the logs it signs
+	var rootCache *rootcache.Cache
+
+	if *rootCacheTTLFlag > 0 {
+		rootCache = rootcache.NewCache(*rootCacheTTLFlag, util.SystemTimeSource{})
+	}
+
+	recorder, err := createAuditRecorder()
+
+	if err != nil {
+		glog.Errorf("Failed to set up the audit trail: %v", err)
+		os.Exit(1)
+	}
+
+	keyManagerProvider := server.NewKeyManagerProvider(keyManager)
+	sequencer, err := createSequencerScheduler(keyManagerProvider, masterElection, quotaManager, rootCache, recorder)
+
+	if err != nil {
+		glog.Errorf("Failed to set up sequencing: %v", err)
+		os.Exit(1)
+	}
+
+	sequencerManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *sequencerSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, sequencer)
+	runInBackground(sequencerManager.OperationLoop)
+
+	// Optionally start deleting subtree revisions that are older than we need to keep.
+	if *subtreeGCRetainRevisionsFlag > 0 {
+		gcManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *subtreeGCSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, server.NewSubtreeGCManager(*subtreeGCRetainRevisionsFlag))
+		runInBackground(gcManager.OperationLoop)
+	}
+
+	// Optionally check the roots and proofs the logs serve, as a client would.
+	if *selfAuditSleepBetweenRunsFlag > 0 {
+		auditManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *selfAuditSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, server.NewSelfAuditManager(*selfAuditInclusionChecksFlag))
+		runInBackground(auditManager.OperationLoop)
+	}
+
+	adminProvider := func() (storage.AdminStorage, error) { return adminStorage, nil }
+
+	// Remove the storage of deleted trees once they can no longer be undeleted, and their
+	// quota buckets on each pass.
+	deletedTreeGC := server.NewDeletedTreeGC(done, adminProvider, *deletedTreeGracePeriodFlag, *deletedTreeGCSleepBetweenRunsFlag, util.SystemTimeSource{})
+	deletedTreeGC.SetQuotaManager(quotaManager)
+	runInBackground(deletedTreeGC.Run)
+
+	accountant, err := startAccounting(done, adminProvider)
+
+	if err != nil {
+		glog.Errorf("Failed to start tree accounting: %v", err)
+		os.Exit(1)
+	}
 
 	// Bring up the RPC server and then block until we get a signal to stop
-	rpcServer := startRpcServer(lis, *serverPortFlag, getStorageForLog)
-	go awaitSignal(rpcServer)
+	reloader := &settingsReloader{quotaManager: quotaManager}
+	rpcServer, err := startRpcServer(lis, *serverPortFlag, getStorageForLog, adminProvider, witnessKeys, quotaManager, accountant, rootCache, recorder, reloader)
+
+	if err != nil {
+		glog.Errorf("Failed to create RPC server: %v", err)
+		os.Exit(1)
+	}
+
+	// Let load balancers and orchestrators hold back requests until the server can handle them
+	healthChecker := createHealthChecker(keyManagerProvider)
+	health.RegisterGRPCServer(done, rpcServer, healthChecker, *healthCheckPeriodFlag)
+
+	if len(*healthEndpointFlag) > 0 {
+		health.StartServer(*healthEndpointFlag, healthChecker)
+	}
+
+	if len(*httpEndpointFlag) > 0 {
+		if err := startGateway(done, *serverPortFlag); err != nil {
+			glog.Errorf("Failed to start REST gateway: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	go reloadOnSignal(done, reloader)
+
+	drained := make(chan struct{})
+	go awaitSignal(rpcServer, healthChecker, drained)
 	err = rpcServer.Serve(lis)
 
 	if err != nil {
@@ -159,10 +1225,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Serve returns once no more RPCs are accepted, the ones in flight may still be running
+	<-drained
+
 	// Shut down everything we previously started, rpc server is already down
 	close(done)
 
-	// Give things a few seconds to tidy up
+	// Let the sequencing batches under way be committed and the tree usage so far be stored
+	// before anything they need is closed
+	if !waitForBackground(*shutdownTimeoutFlag) {
+		glog.Warningf("Background work still under way after %v, abandoning it", *shutdownTimeoutFlag)
+	}
+
+	closeAuditTrail(recorder)
+
+	// Let another instance take over the logs we were master for
+	if err := masterElection.Close(); err != nil {
+		glog.Warningf("Failed to resign from master elections: %v", err)
+	}
+
 	glog.Infof("Stopping server, about to exit")
-	time.Sleep(time.Second * 5)
 }
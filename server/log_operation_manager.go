@@ -3,16 +3,17 @@ package server
 import (
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // LogOperation defines a task that operates on logs. Examples are scheduling, signing,
 // consistency checking or cleanup.
 type LogOperation interface {
 	Name() string
-	ExecutePass([]trillian.LogID, LogOperationManagerContext) bool
+	ExecutePass(context.Context, []trillian.LogID, LogOperationManagerContext) bool
 }
 
 // LogOperationManagerContext bundles up the values so testing can be made easier
@@ -52,60 +53,66 @@ func NewLogOperationManagerForTest(done chan struct{}, sp LogStorageProviderFunc
 	return &LogOperationManager{context: LogOperationManagerContext{done: done, storageProvider: sp, batchSize: batchSize, sleepBetweenRuns: sleepBetweenRuns, signInterval: signInterval, timeSource: timeSource, oneShot: true}, logOperation: logOperation}
 }
 
-func (l LogOperationManager) getLogsAndExecutePass() bool {
+func (l LogOperationManager) getLogsAndExecutePass(ctx context.Context) bool {
 	// TODO(Martin2112) using log ID zero because we don't have an id for metadata ops
 	// this API could improved
 	provider, err := l.context.storageProvider(0)
 
 	// If we get an error, we can't do anything but wait until the next run through
 	if err != nil {
-		glog.Warningf("Failed to get storage provider for run: %v", err)
+		logging.Warningf(ctx, "Failed to get storage provider for run: %v", err)
 		return false
 	}
 
-	tx, err := provider.Begin()
+	tx, err := provider.Begin(ctx)
 
 	if err != nil {
-		glog.Warningf("Failed to get tx for run: %v", err)
+		logging.Warningf(ctx, "Failed to get tx for run: %v", err)
 		return false
 	}
 
 	// Inner loop is across all active logs, currently one at a time
-	logIDs, err := tx.GetActiveLogIDs()
+	logIDs, err := tx.GetActiveLogIDs(ctx)
 
 	if err != nil {
-		glog.Warningf("Failed to get log list for run: %v", err)
+		logging.Warningf(ctx, "Failed to get log list for run: %v", err)
 		tx.Rollback()
 		return false
 	}
 
 	if err := tx.Commit(); err != nil {
-		glog.Warningf("Failed to commit getting logs: %v", err)
+		logging.Warningf(ctx, "Failed to commit getting logs: %v", err)
 		return false
 	}
 
 	// Process each active log once, exit if we've seen a quit signal
-	quit := l.logOperation.ExecutePass(logIDs, l.context)
+	quit := l.logOperation.ExecutePass(ctx, logIDs, l.context)
 	if quit {
-		glog.Infof("Log operation manager shutting down")
+		logging.Infof(ctx, "Log operation manager shutting down")
 	}
 
 	return quit
 }
 
-// OperationLoop starts the manager working. It continues until told to exit.
+// OperationLoop starts the manager working. It continues until told to exit, returning once
+// the pass under way has finished so that its transactions aren't abandoned half done.
 // TODO(Martin2112): No mechanism for error reporting etc., this is OK for v1 but needs work
-func (l LogOperationManager) OperationLoop() {
-	glog.Infof("Log operation manager starting")
+func (l LogOperationManager) OperationLoop(ctx context.Context) {
+	logging.Infof(ctx, "Log operation manager starting")
 
 	// Outer loop, runs until terminated
 	for {
 		// Wait for the configured time before going for another pass
-		time.Sleep(l.context.sleepBetweenRuns)
+		select {
+		case <-l.context.done:
+			logging.Infof(ctx, "Log operation manager shutting down")
+			return
+		case <-time.After(l.context.sleepBetweenRuns):
+		}
 
-		quit := l.getLogsAndExecutePass()
+		quit := l.getLogsAndExecutePass(ctx)
 
-		glog.Infof("Log operation manager pass complete")
+		logging.Infof(ctx, "Log operation manager pass complete")
 
 		// We might want to bail out early when testing
 		if quit || l.context.oneShot {
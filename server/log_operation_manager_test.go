@@ -9,6 +9,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 func TestLogOperationManagerBeginFails(t *testing.T) {
@@ -17,14 +18,14 @@ func TestLogOperationManagerBeginFails(t *testing.T) {
 
 	mockTx := storage.NewMockLogTX(ctrl)
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, errors.New("TX"))
 
 	mockLogOp := NewMockLogOperation(ctrl)
 
 	done := make(chan struct{})
 	lom := NewLogOperationManagerForTest(done, mockStorageProviderForSequencer(mockStorage), 50, time.Second, time.Second, fakeTimeSource, mockLogOp)
 
-	lom.OperationLoop()
+	lom.OperationLoop(context.Background())
 }
 
 func TestLogOperationManagerGetLogsFails(t *testing.T) {
@@ -32,17 +33,17 @@ func TestLogOperationManagerGetLogsFails(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockTx.EXPECT().GetActiveLogIDs().Return([]trillian.LogID{}, errors.New("getactivelogs"))
+	mockTx.EXPECT().GetActiveLogIDs(gomock.Any()).Return([]trillian.LogID{}, errors.New("getactivelogs"))
 	mockTx.EXPECT().Rollback().Return(nil)
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 
 	mockLogOp := NewMockLogOperation(ctrl)
 
 	done := make(chan struct{})
 	lom := NewLogOperationManagerForTest(done, mockStorageProviderForSequencer(mockStorage), 50, time.Second, time.Second, fakeTimeSource, mockLogOp)
 
-	lom.OperationLoop()
+	lom.OperationLoop(context.Background())
 }
 
 func TestLogOperationManagerCommitFails(t *testing.T) {
@@ -50,17 +51,17 @@ func TestLogOperationManagerCommitFails(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockTx.EXPECT().GetActiveLogIDs().Return([]trillian.LogID{}, nil)
+	mockTx.EXPECT().GetActiveLogIDs(gomock.Any()).Return([]trillian.LogID{}, nil)
 	mockTx.EXPECT().Commit().Return(errors.New("commit"))
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 
 	mockLogOp := NewMockLogOperation(ctrl)
 
 	done := make(chan struct{})
 	lom := NewLogOperationManagerForTest(done, mockStorageProviderForSequencer(mockStorage), 50, time.Second, time.Second, fakeTimeSource, mockLogOp)
 
-	lom.OperationLoop()
+	lom.OperationLoop(context.Background())
 }
 
 type logOpMgrContextMatcher struct {
@@ -87,16 +88,41 @@ func TestLogOperationManagerPassesIDs(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockTx.EXPECT().GetActiveLogIDs().Return([]trillian.LogID{logID1, logID2}, nil)
+	mockTx.EXPECT().GetActiveLogIDs(gomock.Any()).Return([]trillian.LogID{logID1, logID2}, nil)
 	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 
 	mockLogOp := NewMockLogOperation(ctrl)
-	mockLogOp.EXPECT().ExecutePass([]trillian.LogID{logID1, logID2}, logOpMgrContextMatcher{50}).Return(false)
+	mockLogOp.EXPECT().ExecutePass(gomock.Any(), []trillian.LogID{logID1, logID2}, logOpMgrContextMatcher{50}).Return(false)
 
 	done := make(chan struct{})
 	lom := NewLogOperationManagerForTest(done, mockStorageProviderForSequencer(mockStorage), 50, time.Second, time.Second, fakeTimeSource, mockLogOp)
 
-	lom.OperationLoop()
+	lom.OperationLoop(context.Background())
+}
+
+func TestLogOperationManagerExitsWhileSleeping(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No pass is started once it's been told to exit
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockLogOp := NewMockLogOperation(ctrl)
+
+	done := make(chan struct{})
+	lom := NewLogOperationManager(done, mockStorageProviderForSequencer(mockStorage), 50, time.Hour, time.Hour, fakeTimeSource, mockLogOp)
+	close(done)
+
+	exited := make(chan struct{})
+	go func() {
+		lom.OperationLoop(context.Background())
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Operation loop didn't exit while waiting for its next pass")
+	}
 }
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseMaxMergeDelays parses a comma separated list of treeID=duration entries, each giving
+// the longest a leaf can be queued in a log before it's integrated.
+func ParseMaxMergeDelays(s string) (map[int64]time.Duration, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delays := make(map[int64]time.Duration, len(settings))
+
+	for treeID, setting := range settings {
+		delay, err := time.ParseDuration(setting)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid max merge delay for tree %d: %v", treeID, err)
+		}
+
+		if delay <= 0 {
+			return nil, fmt.Errorf("max merge delay for tree %d must be positive but was %v", treeID, delay)
+		}
+
+		delays[treeID] = delay
+	}
+
+	return delays, nil
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMaxMergeDelays(t *testing.T) {
+	delays, err := ParseMaxMergeDelays("1=24h,7=90s")
+	if err != nil {
+		t.Fatalf("Failed to parse max merge delays: %v", err)
+	}
+
+	if got, want := len(delays), 2; got != want || delays[1] != 24*time.Hour || delays[7] != 90*time.Second {
+		t.Errorf("Got max merge delays %v, want 1=24h,7=90s", delays)
+	}
+
+	for _, s := range []string{"1", "x=1h", "1=x", "1=0s", "1=-1h", "1=1h,1=2h"} {
+		if _, err := ParseMaxMergeDelays(s); err == nil {
+			t.Errorf("Parsed bad max merge delays: %q", s)
+		}
+	}
+}
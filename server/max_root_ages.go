@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseMaxRootAges parses a comma separated list of treeID=duration entries, each giving
+// the oldest the latest root of a map can be before reads against it are stale.
+func ParseMaxRootAges(s string) (map[int64]time.Duration, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ages := make(map[int64]time.Duration, len(settings))
+
+	for treeID, setting := range settings {
+		age, err := time.ParseDuration(setting)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid max root age for tree %d: %v", treeID, err)
+		}
+
+		if age <= 0 {
+			return nil, fmt.Errorf("max root age for tree %d must be positive but was %v", treeID, age)
+		}
+
+		ages[treeID] = age
+	}
+
+	return ages, nil
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMaxRootAges(t *testing.T) {
+	ages, err := ParseMaxRootAges("3=1h,8=30s")
+	if err != nil {
+		t.Fatalf("Failed to parse max root ages: %v", err)
+	}
+
+	if got, want := len(ages), 2; got != want || ages[3] != time.Hour || ages[8] != 30*time.Second {
+		t.Errorf("Got max root ages %v, want 3=1h,8=30s", ages)
+	}
+
+	if ages, err := ParseMaxRootAges(""); err != nil || len(ages) != 0 {
+		t.Errorf("Got max root ages %v, %v for an empty list, want none", ages, err)
+	}
+
+	for _, s := range []string{"3", "x=1h", "3=x", "3=0s", "3=-1m", "3=1h,3=2h"} {
+		if _, err := ParseMaxRootAges(s); err == nil {
+			t.Errorf("Parsed bad max root ages: %q", s)
+		}
+	}
+}
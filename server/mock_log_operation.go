@@ -6,6 +6,7 @@ package server
 import (
 	gomock "github.com/golang/mock/gomock"
 	trillian "github.com/google/trillian"
+	context "golang.org/x/net/context"
 )
 
 // Mock of LogOperation interface
@@ -29,14 +30,14 @@ func (_m *MockLogOperation) EXPECT() *_MockLogOperationRecorder {
 	return _m.recorder
 }
 
-func (_m *MockLogOperation) ExecutePass(_param0 []trillian.LogID, _param1 LogOperationManagerContext) bool {
-	ret := _m.ctrl.Call(_m, "ExecutePass", _param0, _param1)
+func (_m *MockLogOperation) ExecutePass(_param0 context.Context, _param1 []trillian.LogID, _param2 LogOperationManagerContext) bool {
+	ret := _m.ctrl.Call(_m, "ExecutePass", _param0, _param1, _param2)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
-func (_mr *_MockLogOperationRecorder) ExecutePass(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "ExecutePass", arg0, arg1)
+func (_mr *_MockLogOperationRecorder) ExecutePass(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ExecutePass", arg0, arg1, arg2)
 }
 
 func (_m *MockLogOperation) Name() string {
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// pageTokenVersion is the first byte of every page token, so that the encoding can change
+// without misreading tokens handed out by older servers
+const pageTokenVersion = 1
+
+// pageTokenSize is the length of an encoded token, the version then three int64s
+const pageTokenSize = 1 + 3*8
+
+var errInvalidPageToken = errors.New("invalid page token")
+
+// leafPageToken records where a GetLeavesByRange request got to. Tokens are handed to
+// clients, so everything in them is checked again when they come back.
+type leafPageToken struct {
+	// logID is the log the range is of, a token can't be used with another
+	logID int64
+	// next is the index of the first leaf of the next page
+	next int64
+	// end is the index after the last leaf of the range, fixed by the first request so that
+	// later pages don't grow it when the tree grows
+	end int64
+}
+
+func (p leafPageToken) encode() []byte {
+	b := make([]byte, pageTokenSize)
+	b[0] = pageTokenVersion
+	binary.BigEndian.PutUint64(b[1:], uint64(p.logID))
+	binary.BigEndian.PutUint64(b[9:], uint64(p.next))
+	binary.BigEndian.PutUint64(b[17:], uint64(p.end))
+
+	return b
+}
+
+// decodeLeafPageToken reads a token made by encode, checking it's for logID and holds a
+// non empty range
+func decodeLeafPageToken(b []byte, logID int64) (leafPageToken, error) {
+	if len(b) != pageTokenSize || b[0] != pageTokenVersion {
+		return leafPageToken{}, errInvalidPageToken
+	}
+
+	p := leafPageToken{
+		logID: int64(binary.BigEndian.Uint64(b[1:])),
+		next:  int64(binary.BigEndian.Uint64(b[9:])),
+		end:   int64(binary.BigEndian.Uint64(b[17:])),
+	}
+
+	if p.logID != logID || p.next < 0 || p.end <= p.next {
+		return leafPageToken{}, errInvalidPageToken
+	}
+
+	return p, nil
+}
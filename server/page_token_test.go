@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestLeafPageTokenRoundTrip(t *testing.T) {
+	for _, p := range []leafPageToken{{logID: 1, next: 0, end: 1}, {logID: -5, next: 1000, end: 1 << 40}} {
+		got, err := decodeLeafPageToken(p.encode(), p.logID)
+		if err != nil {
+			t.Fatalf("decodeLeafPageToken(%v) = %v", p, err)
+		}
+
+		if got != p {
+			t.Errorf("decodeLeafPageToken() = %v, want %v", got, p)
+		}
+	}
+}
+
+func TestLeafPageTokenRejectsInvalid(t *testing.T) {
+	good := leafPageToken{logID: 1, next: 2, end: 3}.encode()
+	wrongVersion := append([]byte{}, good...)
+	wrongVersion[0]++
+
+	for _, test := range []struct {
+		desc  string
+		token []byte
+	}{
+		{"empty", nil},
+		{"truncated", good[:len(good)-1]},
+		{"too long", append(good, 0)},
+		{"wrong version", wrongVersion},
+		{"empty range", leafPageToken{logID: 1, next: 3, end: 3}.encode()},
+		{"negative index", leafPageToken{logID: 1, next: -1, end: 3}.encode()},
+	} {
+		if _, err := decodeLeafPageToken(test.token, 1); err == nil {
+			t.Errorf("%s: decodeLeafPageToken() accepted %x", test.desc, test.token)
+		}
+	}
+
+	if _, err := decodeLeafPageToken(good, 2); err == nil {
+		t.Error("decodeLeafPageToken() accepted the token of another log")
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QueuePriorityRange is the range of priorities a log's leaves can be queued with. Leaves
+// with a higher priority are integrated first, so e.g. a backfill queued at -1 doesn't delay
+// leaves queued at the default of 0.
+type QueuePriorityRange struct {
+	Min int32
+	Max int32
+}
+
+// clamp returns the priority in the range closest to priority
+func (r QueuePriorityRange) clamp(priority int32) int32 {
+	if priority < r.Min {
+		return r.Min
+	}
+
+	if priority > r.Max {
+		return r.Max
+	}
+
+	return priority
+}
+
+// ParseQueuePriorities parses a comma separated list of treeID=min:max entries, each giving
+// the lowest and highest priority leaves can be queued with in a log.
+func ParseQueuePriorities(s string) (map[int64]QueuePriorityRange, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make(map[int64]QueuePriorityRange, len(settings))
+
+	for treeID, setting := range settings {
+		parts := strings.Split(setting, ":")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("queue priorities for tree %d are not of the form min:max: %q", treeID, setting)
+		}
+
+		min, err := strconv.ParseInt(parts[0], 10, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid min queue priority for tree %d: %v", treeID, err)
+		}
+
+		max, err := strconv.ParseInt(parts[1], 10, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid max queue priority for tree %d: %v", treeID, err)
+		}
+
+		if min > 0 || max < 0 {
+			return nil, fmt.Errorf("queue priorities for tree %d must include the default of 0 but were %d:%d", treeID, min, max)
+		}
+
+		ranges[treeID] = QueuePriorityRange{Min: int32(min), Max: int32(max)}
+	}
+
+	return ranges, nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import "testing"
+
+func TestParseQueuePriorities(t *testing.T) {
+	ranges, err := ParseQueuePriorities("1=-1:1,7=0:5")
+	if err != nil {
+		t.Fatalf("Failed to parse queue priorities: %v", err)
+	}
+
+	if got, want := len(ranges), 2; got != want || ranges[1] != (QueuePriorityRange{Min: -1, Max: 1}) || ranges[7] != (QueuePriorityRange{Min: 0, Max: 5}) {
+		t.Errorf("Got queue priorities %v, want 1=-1:1,7=0:5", ranges)
+	}
+
+	for _, s := range []string{"1", "1=2", "x=0:1", "1=x:1", "1=0:x", "1=1:2", "1=-2:-1", "1=0:1:2", "1=0:3000000000", "1=0:1,1=0:2"} {
+		if _, err := ParseQueuePriorities(s); err == nil {
+			t.Errorf("Parsed bad queue priorities: %q", s)
+		}
+	}
+}
+
+func TestQueuePriorityRangeClamp(t *testing.T) {
+	r := QueuePriorityRange{Min: -1, Max: 2}
+
+	for _, test := range []struct {
+		priority, want int32
+	}{
+		{priority: -5, want: -1},
+		{priority: -1, want: -1},
+		{priority: 0, want: 0},
+		{priority: 2, want: 2},
+		{priority: 9, want: 2},
+	} {
+		if got := r.clamp(test.priority); got != test.want {
+			t.Errorf("clamp(%d)=%d, want %d", test.priority, got, test.want)
+		}
+	}
+
+	if got := (QueuePriorityRange{}).clamp(3); got != 0 {
+		t.Errorf("Empty range clamp(3)=%d, want 0", got)
+	}
+}
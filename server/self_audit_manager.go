@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+const (
+	// consistencyCheck and inclusionCheck label the self audit metrics with the kind of check
+	consistencyCheck = "consistency"
+	inclusionCheck   = "inclusion"
+)
+
+var (
+	selfAuditChecks   = monitoring.NewCounter("self_audit_checks", "Checks made of the log by the self auditor", "treeid", "check")
+	selfAuditFailures = monitoring.NewCounter("self_audit_failures", "Checks made by the self auditor that the log failed, any failure means the log served a bad root or proof", "treeid", "check")
+)
+
+// SelfAuditManager is a LogOperation that checks the logs it signs as a client would. Each
+// pass proves the latest signed root of a log consistent with the one seen by the previous
+// pass, and proves a few randomly chosen leaves are included in it. Failures are logged as
+// errors and counted in the self_audit_failures metric, which should be alerted on.
+type SelfAuditManager struct {
+	// inclusionChecks is the number of leaves of each log checked in a pass
+	inclusionChecks int
+	rand            *rand.Rand
+	// roots holds the last root of each log that passed its consistency check, by tree ID.
+	// Passes run one at a time so it isn't locked.
+	roots map[int64]trillian.SignedLogRoot
+}
+
+// NewSelfAuditManager creates a SelfAuditManager which checks the inclusion of
+// inclusionChecks random leaves of each log in every pass.
+func NewSelfAuditManager(inclusionChecks int) *SelfAuditManager {
+	return &SelfAuditManager{
+		inclusionChecks: inclusionChecks,
+		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		roots:           make(map[int64]trillian.SignedLogRoot),
+	}
+}
+
+func (s *SelfAuditManager) Name() string {
+	return "SelfAudit"
+}
+
+func (s *SelfAuditManager) ExecutePass(ctx context.Context, logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+	logging.V(1).Infof(ctx, "Beginning self audit of %d active log(s)", len(logIDs))
+
+	failed := 0
+
+	for _, logID := range logIDs {
+		// See if it's time to quit
+		select {
+		case <-context.done:
+			return true
+		default:
+		}
+
+		if err := s.auditLog(logID, context); err != nil {
+			logging.Warningf(ctx, "Error auditing log: %v: %v", logID, err)
+			failed++
+		}
+	}
+
+	logging.V(1).Infof(ctx, "Self audit completed %d succeeded %d could not be audited", len(logIDs)-failed, failed)
+
+	return false
+}
+
+// auditLog runs the checks of a single log against its latest signed root. Errors are
+// returned when the log couldn't be audited, failed checks are only recorded.
+func (s *SelfAuditManager) auditLog(logID trillian.LogID, opContext LogOperationManagerContext) error {
+	ls, err := opContext.storageProvider(logID.TreeID)
+	if err != nil {
+		return err
+	}
+
+	hasher, err := merkle.NewTreeHasher(ls.HashAlgorithm())
+	if err != nil {
+		return err
+	}
+
+	ctx := logging.NewContext(context.Background(), logging.Fields{RequestID: logging.NewRequestID(), TreeID: logID.TreeID})
+	tx, err := ls.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return err
+	}
+
+	treeID := strconv.FormatInt(logID.TreeID, 10)
+
+	// The root that failed isn't kept, so that it keeps failing against the last good one
+	if prev, ok := s.roots[logID.TreeID]; ok {
+		if s.record(ctx, treeID, consistencyCheck, s.checkConsistency(ctx, tx, hasher, prev, root)) {
+			s.roots[logID.TreeID] = root
+		}
+	} else {
+		s.roots[logID.TreeID] = root
+	}
+
+	for c := 0; c < s.inclusionChecks && root.TreeSize > 0; c++ {
+		index := s.rand.Int63n(root.TreeSize)
+		s.record(ctx, treeID, inclusionCheck, s.checkInclusion(ctx, tx, hasher, root, index))
+	}
+
+	return nil
+}
+
+// record counts the outcome of a check and returns true if it passed
+func (s *SelfAuditManager) record(ctx context.Context, treeID, check string, err error) bool {
+	selfAuditChecks.Inc(treeID, check)
+
+	if err != nil {
+		selfAuditFailures.Inc(treeID, check)
+		logging.Errorf(ctx, "Self audit %s check failed: %v", check, err)
+		return false
+	}
+
+	return true
+}
+
+// checkConsistency proves root is an append only extension of prev
+func (s *SelfAuditManager) checkConsistency(ctx context.Context, tx storage.ReadOnlyLogTX, hasher merkle.TreeHasher, prev, root trillian.SignedLogRoot) error {
+	switch {
+	case root.TreeSize < prev.TreeSize:
+		return fmt.Errorf("tree shrank from size %d to %d", prev.TreeSize, root.TreeSize)
+	case root.TreeSize == prev.TreeSize:
+		if !bytes.Equal(root.RootHash, prev.RootHash) {
+			return fmt.Errorf("root hash of size %d changed from %x to %x", root.TreeSize, prev.RootHash, root.RootHash)
+		}
+		return nil
+	case prev.TreeSize == 0:
+		// Every tree is an extension of the empty one
+		return nil
+	}
+
+	fetches, err := merkle.CalcConsistencyProofNodeFetches(prev.TreeSize, root.TreeSize, root.TreeSize, proofMaxBitLen)
+	if err != nil {
+		return err
+	}
+
+	proof, err := proofHashes(ctx, tx, hasher, root, fetches)
+	if err != nil {
+		return err
+	}
+
+	if err := merkle.NewLogVerifier(hasher).VerifyConsistencyProof(prev.TreeSize, root.TreeSize, prev.RootHash, root.RootHash, proof); err != nil {
+		return fmt.Errorf("size %d is not consistent with size %d: %v", root.TreeSize, prev.TreeSize, err)
+	}
+
+	return nil
+}
+
+// checkInclusion proves the leaf at index is included in root
+func (s *SelfAuditManager) checkInclusion(ctx context.Context, tx storage.ReadOnlyLogTX, hasher merkle.TreeHasher, root trillian.SignedLogRoot, index int64) error {
+	leaves, err := tx.GetLeavesByIndex(ctx, []int64{index})
+	if err != nil {
+		return err
+	}
+
+	if len(leaves) != 1 {
+		return fmt.Errorf("leaf %d of %d is missing", index, root.TreeSize)
+	}
+
+	fetches, err := merkle.CalcInclusionProofNodeFetches(root.TreeSize, index, root.TreeSize, proofMaxBitLen)
+	if err != nil {
+		return err
+	}
+
+	proof, err := proofHashes(ctx, tx, hasher, root, fetches)
+	if err != nil {
+		return err
+	}
+
+	if err := merkle.NewLogVerifier(hasher).VerifyInclusionProof(index, root.TreeSize, proof, root.RootHash, leaves[0].LeafHash); err != nil {
+		return fmt.Errorf("leaf %d is not included at size %d: %v", index, root.TreeSize, err)
+	}
+
+	return nil
+}
+
+// proofHashes reads the nodes of a proof at the revision of root and recomputes any that
+// need it, as the server does when serving the proof, and returns their hashes
+func proofHashes(ctx context.Context, tx storage.NodeReader, hasher merkle.TreeHasher, root trillian.SignedLogRoot, fetches []merkle.NodeFetch) ([][]byte, error) {
+	nodes, err := tx.GetMerkleNodes(ctx, root.TreeRevision, merkle.NodeFetchIDs(fetches))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]storage.NodeID, 0, len(fetches))
+	rehash := false
+
+	for _, f := range fetches {
+		ids = append(ids, f.NodeID)
+		rehash = rehash || len(f.Rehash) > 0
+	}
+
+	if rehash {
+		if nodes, err = merkle.RehashProofNodes(hasher, fetches, nodes); err != nil {
+			return nil, err
+		}
+	}
+
+	proof, err := buildProof(0, ids, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, 0, len(proof.ProofNode))
+	for _, n := range proof.ProofNode {
+		hashes = append(hashes, n.NodeHash)
+	}
+
+	return hashes, nil
+}
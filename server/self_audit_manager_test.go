@@ -0,0 +1,237 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var auditLogID = trillian.LogID{LogID: []byte("audit"), TreeID: 1}
+
+// auditDatabases counts the memory databases created, so each test gets a new one even
+// when the tests are run more than once
+var auditDatabases int
+
+func newAuditedLog(t *testing.T) storage.LogStorage {
+	auditDatabases++
+	p, err := storage.NewProvider(memory.ProviderName, fmt.Sprintf("%s-%d", t.Name(), auditDatabases))
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+
+	s, err := p.LogStorage(auditLogID)
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	return s
+}
+
+// addLeaves queues leaves from first up to last and sequences them all
+func addLeaves(t *testing.T, s storage.LogStorage, first, last int) {
+	ctx := context.Background()
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, last-first)
+	for l := first; l < last; l++ {
+		value := []byte(fmt.Sprintf("Leaf %d", l))
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(value), LeafValue: value}, SignedEntryTimestamp: unsignedTimestamp})
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, s, km)
+
+	if first == 0 {
+		if err := sequencer.SignRoot(ctx); err != nil {
+			t.Fatalf("SignRoot() = %v", err)
+		}
+	}
+
+	for integrated := first; integrated < last; {
+		n, err := sequencer.SequenceBatch(ctx, 13, func(trillian.SignedLogRoot) bool { return false })
+		if err != nil {
+			t.Fatalf("SequenceBatch() = %v", err)
+		}
+
+		integrated += n
+	}
+}
+
+// storeRoot stores a root that's the latest with a new revision and timestamp, after
+// nodes, which are written at that revision
+func storeRoot(t *testing.T, s storage.LogStorage, nodes []storage.Node, update func(*trillian.SignedLogRoot)) {
+	ctx := context.Background()
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = %v", err)
+	}
+
+	for i := range nodes {
+		nodes[i].NodeRevision = tx.WriteRevision()
+	}
+
+	if len(nodes) > 0 {
+		if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+			t.Fatalf("SetMerkleNodes() = %v", err)
+		}
+	}
+
+	root.TreeRevision = tx.WriteRevision()
+	root.TimestampNanos++
+	update(&root)
+
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+		t.Fatalf("StoreSignedLogRoot() = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+}
+
+// auditCounts returns the checks of a kind made and failed so far
+func auditCounts(check string) (float64, float64) {
+	return selfAuditChecks.Value("1", check), selfAuditFailures.Value("1", check)
+}
+
+func newSeededSelfAuditManager(inclusionChecks int) *SelfAuditManager {
+	s := NewSelfAuditManager(inclusionChecks)
+	s.rand = rand.New(rand.NewSource(1))
+	return s
+}
+
+func TestSelfAuditPassesGrowingLog(t *testing.T) {
+	s := newAuditedLog(t)
+	auditor := newSeededSelfAuditManager(10)
+	ctx := createTestContext(func(int64) (storage.LogStorage, error) { return s, nil })
+	checks, failures := auditCounts(consistencyCheck)
+	inclusionChecks, inclusionFailures := auditCounts(inclusionCheck)
+
+	// The first pass sees an empty log, the others check it against the last
+	for _, size := range []int{0, 1, 50, 50, 300} {
+		addLeaves(t, s, int(auditor.roots[auditLogID.TreeID].TreeSize), size)
+
+		if auditor.ExecutePass(context.Background(), []trillian.LogID{auditLogID}, ctx) {
+			t.Fatal("ExecutePass() asked to quit")
+		}
+
+		if got := auditor.roots[auditLogID.TreeID].TreeSize; got != int64(size) {
+			t.Fatalf("Audited root of size %d, expected %d", got, size)
+		}
+	}
+
+	if c, f := auditCounts(consistencyCheck); c-checks != 4 || f != failures {
+		t.Errorf("Made %v consistency checks with %v failures, expected 4 with none", c-checks, f-failures)
+	}
+
+	if c, f := auditCounts(inclusionCheck); c-inclusionChecks != 40 || f != inclusionFailures {
+		t.Errorf("Made %v inclusion checks with %v failures, expected 40 with none", c-inclusionChecks, f-inclusionFailures)
+	}
+}
+
+func TestSelfAuditReportsInconsistentRoot(t *testing.T) {
+	s := newAuditedLog(t)
+	addLeaves(t, s, 0, 20)
+
+	auditor := newSeededSelfAuditManager(0)
+	ctx := createTestContext(func(int64) (storage.LogStorage, error) { return s, nil })
+	auditor.ExecutePass(context.Background(), []trillian.LogID{auditLogID}, ctx)
+	good := auditor.roots[auditLogID.TreeID]
+
+	for _, test := range []struct {
+		desc   string
+		update func(*trillian.SignedLogRoot)
+	}{
+		{"changed hash", func(r *trillian.SignedLogRoot) { r.RootHash = []byte("changed") }},
+		{"shrunk", func(r *trillian.SignedLogRoot) { r.TreeSize-- }},
+		{"grown", func(r *trillian.SignedLogRoot) { r.TreeSize++ }},
+	} {
+		storeRoot(t, s, nil, test.update)
+		_, failures := auditCounts(consistencyCheck)
+
+		// Bad roots aren't kept, so every pass checks against the good one and fails
+		for pass := 0; pass < 2; pass++ {
+			auditor.ExecutePass(context.Background(), []trillian.LogID{auditLogID}, ctx)
+		}
+
+		if _, f := auditCounts(consistencyCheck); f-failures != 2 {
+			t.Errorf("%s: counted %v consistency failures, expected 2", test.desc, f-failures)
+		}
+
+		if got := auditor.roots[auditLogID.TreeID]; got.TreeSize != good.TreeSize || string(got.RootHash) != string(good.RootHash) {
+			t.Errorf("%s: kept root %v, expected %v", test.desc, got, good)
+		}
+
+		// Put the good root back for the next test
+		storeRoot(t, s, nil, func(r *trillian.SignedLogRoot) { r.TreeSize, r.RootHash = good.TreeSize, good.RootHash })
+	}
+}
+
+func TestSelfAuditReportsBadInclusionProof(t *testing.T) {
+	s := newAuditedLog(t)
+	addLeaves(t, s, 0, 8)
+
+	id, err := storage.NewNodeIDForTreeCoords(0, 5, proofMaxBitLen)
+	if err != nil {
+		t.Fatalf("Failed to create node ID: %v", err)
+	}
+
+	storeRoot(t, s, []storage.Node{{NodeID: id, Hash: []byte("corrupt")}}, func(*trillian.SignedLogRoot) {})
+
+	// The proof of leaf 4 has the corrupt node in it, and the proofs of the others have nodes
+	// recomputed from it
+	auditor := newSeededSelfAuditManager(50)
+	ctx := createTestContext(func(int64) (storage.LogStorage, error) { return s, nil })
+	checks, failures := auditCounts(inclusionCheck)
+	auditor.ExecutePass(context.Background(), []trillian.LogID{auditLogID}, ctx)
+
+	if c, f := auditCounts(inclusionCheck); c-checks != 50 || f == failures {
+		t.Errorf("Made %v inclusion checks with %v failures, expected 50 with some failures", c-checks, f-failures)
+	}
+}
+
+func TestSelfAuditStopsWhenDone(t *testing.T) {
+	ctx := createTestContext(func(int64) (storage.LogStorage, error) { return nil, fmt.Errorf("shouldn't be called") })
+	close(ctx.done)
+
+	if !newSeededSelfAuditManager(1).ExecutePass(context.Background(), []trillian.LogID{auditLogID}, ctx) {
+		t.Error("ExecutePass() didn't quit when done")
+	}
+}
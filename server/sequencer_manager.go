@@ -1,19 +1,73 @@
 package server
 
 import (
-	"github.com/golang/glog"
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/google/trillian"
-	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/audit"
 	"github.com/google/trillian/log"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/util"
-	"time"
+	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/publisher"
+	"github.com/google/trillian/util/rootcache"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/context"
 )
 
+// Sequencing metrics, by tree ID
+var (
+	sequencingLatency  = monitoring.NewHistogram("sequencing_latency_seconds", "Time taken to sequence a batch of leaves", monitoring.DefaultBuckets, "treeid")
+	leavesSequenced    = monitoring.NewCounter("sequencing_leaves_integrated", "Leaves integrated into the tree", "treeid")
+	sequencingFailures = monitoring.NewCounter("sequencing_failures", "Sequencing runs that failed", "treeid")
+	integrationDelay   = monitoring.NewHistogram("sequencing_integration_delay_seconds", "Time from a leaf being queued to the root that integrated it", integrationDelayBuckets, "treeid")
+	mergeDelayExceeded = monitoring.NewCounter("sequencing_merge_delay_exceeded", "Leaves integrated after the max merge delay", "treeid")
+	mergeDelayRefusals = monitoring.NewCounter("sequencing_merge_delay_refusals", "Roots not signed because they would exceed the max merge delay", "treeid")
+)
+
+// integrationDelayBuckets go up to a day, the longest max merge delay in common use
+var integrationDelayBuckets = []float64{1, 10, 60, 300, 900, 1800, 3600, 4 * 3600, 12 * 3600, 24 * 3600}
+
 type SequencerManager struct {
-	keyManager crypto.KeyManager
+	keyManagerProvider KeyManagerProviderFunc
+	// batchSizer adapts the batch size for each log between runs. If it is nil the fixed
+	// batch size from the operation manager context is used.
+	batchSizer *AdaptiveBatchSizer
+	// election decides which logs this instance is master for and so can sequence
+	election election.Election
+	// publisher is given every root the sequencer signs, if it's nil roots aren't published
+	publisher publisher.Publisher
+	// quotaManager gets back the write tokens for the leaves that are integrated, if it's
+	// nil they aren't returned
+	quotaManager quota.Manager
+	// rootCache is given every root the sequencer signs for the log it's for, if it's nil
+	// there's no cache to keep up to date
+	rootCache *rootcache.Cache
+	// rootMetadata gives the metadata signed with each new root, if it's nil roots have none
+	rootMetadata RootMetadataFunc
+	// auditRecorder records each use of a log's key and each root signed, if it's nil
+	// they aren't recorded
+	auditRecorder *audit.Recorder
+	// leafClaimOwner is who sequencers claim leaves as, for leafClaimLease at a time. If
+	// it's empty leaves aren't claimed.
+	leafClaimOwner string
+	leafClaimLease time.Duration
+	// maxMergeDelays are the max merge delays of logs, by tree ID. Integration delays are
+	// reported against them, and enforced if enforceMaxMergeDelay is set.
+	maxMergeDelays       map[int64]time.Duration
+	enforceMaxMergeDelay bool
 }
 
+// RootMetadataFunc returns the opaque metadata to sign with a new root of the log treeID.
+// It's how a personality running the sequencer attaches its own data to the roots of its
+// logs, see log.RootMetadataFunc.
+type RootMetadataFunc func(ctx context.Context, treeID int64, root trillian.SignedLogRoot) ([]byte, error)
+
 func isRootTooOld(ts util.TimeSource, maxAge time.Duration) log.CurrentRootExpiredFunc {
 	return func(root trillian.SignedLogRoot) bool {
 		rootTime := time.Unix(0, root.TimestampNanos)
@@ -23,18 +77,79 @@ func isRootTooOld(ts util.TimeSource, maxAge time.Duration) log.CurrentRootExpir
 	}
 }
 
-func NewSequencerManager(km crypto.KeyManager) *SequencerManager {
-	return &SequencerManager{keyManager: km}
+func NewSequencerManager(kmp KeyManagerProviderFunc) *SequencerManager {
+	return &SequencerManager{keyManagerProvider: kmp, election: election.SingleNode{}}
+}
+
+// NewAdaptiveSequencerManager creates a SequencerManager that takes the batch size for each
+// log from batchSizer and feeds the outcome of every run back into it.
+func NewAdaptiveSequencerManager(kmp KeyManagerProviderFunc, batchSizer *AdaptiveBatchSizer) *SequencerManager {
+	return &SequencerManager{keyManagerProvider: kmp, batchSizer: batchSizer, election: election.SingleNode{}}
+}
+
+// NewElectedSequencerManager creates a SequencerManager that only sequences and signs the
+// logs that e says this instance is master for. If batchSizer is nil the fixed batch size
+// from the operation manager context is used.
+func NewElectedSequencerManager(kmp KeyManagerProviderFunc, batchSizer *AdaptiveBatchSizer, e election.Election) *SequencerManager {
+	return &SequencerManager{keyManagerProvider: kmp, batchSizer: batchSizer, election: e}
+}
+
+// SetPublisher arranges for every new root signed by the sequencer to be passed to p. It's
+// called by the sequencer between runs, so p shouldn't block, see publisher.Async.
+func (s *SequencerManager) SetPublisher(p publisher.Publisher) {
+	s.publisher = p
+}
+
+// SetQuotaManager arranges for write tokens to be returned to m as leaves are integrated,
+// so that writers are held back by quotas refilled by sequencing if it falls behind
+func (s *SequencerManager) SetQuotaManager(m quota.Manager) {
+	s.quotaManager = m
+}
+
+// SetRootCache arranges for every new root signed by the sequencer to replace the one c
+// holds for its log, so servers sharing c don't serve the old root until it expires
+func (s *SequencerManager) SetRootCache(c *rootcache.Cache) {
+	s.rootCache = c
+}
+
+// SetRootMetadata arranges for every new root signed by the sequencer to carry the metadata
+// f returns for it
+func (s *SequencerManager) SetRootMetadata(f RootMetadataFunc) {
+	s.rootMetadata = f
+}
+
+// SetAuditRecorder arranges for every use of a log's signing key and every new root signed
+// by the sequencer to be recorded by r
+func (s *SequencerManager) SetAuditRecorder(r *audit.Recorder) {
+	s.auditRecorder = r
+}
+
+// SetLeafClaims arranges for the sequencer to claim the leaves it integrates as owner, for
+// lease at a time, so that instances with different owners can share the queues of the same
+// logs instead of being elected to sequence them, see log.Sequencer.SetLeafClaims
+func (s *SequencerManager) SetLeafClaims(owner string, lease time.Duration) {
+	s.leafClaimOwner = owner
+	s.leafClaimLease = lease
+}
+
+// SetMaxMergeDelays sets the max merge delays of logs, by tree ID. Leaves integrated after
+// their log's delay are counted, and if enforce is set the sequencer takes in overdue leaves
+// first and refuses to sign a root that would still leave one out, see
+// log.Sequencer.SetMaxMergeDelay
+func (s *SequencerManager) SetMaxMergeDelays(perTree map[int64]time.Duration, enforce bool) {
+	s.maxMergeDelays = perTree
+	s.enforceMaxMergeDelay = enforce
 }
 
 func (s SequencerManager) Name() string {
 	return "Sequencer"
 }
 
-func (s SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+func (s SequencerManager) ExecutePass(ctx context.Context, logIDs []trillian.LogID, context LogOperationManagerContext) bool {
 	// TODO(Martin2112): Demote logging to verbose level
-	glog.Infof("Beginning sequencing run for %d active log(s)", len(logIDs))
+	logging.Infof(ctx, "Beginning sequencing run for %d active log(s)", len(logIDs))
 
+	logIDs = s.masterLogs(ctx, logIDs)
 	successCount := 0
 	leavesAdded := 0
 
@@ -46,32 +161,195 @@ func (s SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperat
 		default:
 		}
 
-		// TODO(Martin2112): Probably want to make the sequencer objects longer lived to
-		// avoid the cost of initializing their state each time but this works for now
-		storage, err := context.storageProvider(logID.TreeID)
+		leaves, _, err := s.sequenceLog(logID, context)
 
-		// TODO(Martin2112): Honour the sequencing enabled in log parameters, needs an API change
-		// so deferring it
 		if err != nil {
-			glog.Warningf("Storage provider failed for id: %v because: %v", logID, err)
+			logging.Warningf(ctx, "Error trying to sequence batch for: %v: %v", logID, err)
 			continue
 		}
 
-		// TODO(Martin2112): Allow for different tree hashers to be used by different logs
-		sequencer := log.NewSequencer(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), context.timeSource, storage, s.keyManager)
+		successCount++
+		leavesAdded += leaves
+	}
+
+	logging.Infof(ctx, "Sequencing run completed %d succeeded %d failed %d leaves integrated", successCount, len(logIDs)-successCount, leavesAdded)
+
+	return false
+}
+
+// masterLogs returns the logs in logIDs that this instance is currently master for. Logs
+// whose mastership can't be determined are left out, another replica may hold it.
+func (s SequencerManager) masterLogs(ctx context.Context, logIDs []trillian.LogID) []trillian.LogID {
+	masterIDs := make([]trillian.LogID, 0, len(logIDs))
 
-		leaves, err := sequencer.SequenceBatch(context.batchSize, isRootTooOld(context.timeSource, context.signInterval))
+	for _, logID := range logIDs {
+		isMaster, err := s.election.IsMaster(logID.TreeID)
 
 		if err != nil {
-			glog.Warningf("Error trying to sequence batch for: %v: %v", logID, err)
+			logging.Warningf(ctx, "Failed to check mastership for id: %v because: %v", logID, err)
 			continue
 		}
 
-		successCount++
-		leavesAdded += leaves
+		if !isMaster {
+			logging.V(1).Infof(ctx, "Not master for id: %v, skipping", logID)
+			continue
+		}
+
+		masterIDs = append(masterIDs, logID)
 	}
 
-	glog.Infof("Sequencing run completed %d succeeded %d failed %d leaves integrated", successCount, len(logIDs)-successCount, leavesAdded)
+	return masterIDs
+}
 
-	return false
+// sequenceLog runs a single sequencing batch for a log. It returns the number of leaves
+// integrated and the batch size that was used.
+func (s SequencerManager) sequenceLog(logID trillian.LogID, opContext LogOperationManagerContext) (int, int, error) {
+	// TODO(Martin2112): Probably want to make the sequencer objects longer lived to
+	// avoid the cost of initializing their state each time but this works for now
+	storage, err := opContext.storageProvider(logID.TreeID)
+
+	// TODO(Martin2112): Honour the sequencing enabled in log parameters, needs an API change
+	// so deferring it
+	if err != nil {
+		return 0, 0, fmt.Errorf("storage provider failed: %v", err)
+	}
+
+	keyManager, err := s.keyManagerProvider(logID)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("key manager provider failed: %v", err)
+	}
+
+	if s.auditRecorder != nil {
+		keyManager = s.auditRecorder.KeyManager(logID.TreeID, logID.LogID, keyManager)
+	}
+
+	hasher, err := merkle.NewTreeHasher(storage.HashAlgorithm())
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create tree hasher: %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, opContext.timeSource, storage, keyManager)
+
+	if p := s.rootPublisher(logID.TreeID); p != nil {
+		sequencer = log.NewPublishingSequencer(hasher, opContext.timeSource, storage, keyManager, p)
+	}
+
+	if s.rootMetadata != nil {
+		treeID := logID.TreeID
+		sequencer.SetRootMetadataFunc(func(ctx context.Context, root trillian.SignedLogRoot) ([]byte, error) {
+			return s.rootMetadata(ctx, treeID, root)
+		})
+	}
+
+	if len(s.leafClaimOwner) > 0 {
+		sequencer.SetLeafClaims(s.leafClaimOwner, s.leafClaimLease)
+	}
+
+	treeID := strconv.FormatInt(logID.TreeID, 10)
+	mmd := s.maxMergeDelays[logID.TreeID]
+	late := 0
+
+	sequencer.SetIntegrationDelayFunc(func(delay time.Duration) {
+		integrationDelay.Observe(delay.Seconds(), treeID)
+		if mmd > 0 && delay > mmd {
+			mergeDelayExceeded.Inc(treeID)
+			late++
+		}
+	})
+
+	if s.enforceMaxMergeDelay && mmd > 0 {
+		sequencer.SetMaxMergeDelay(mmd)
+	}
+
+	// Mastership was checked at the start of the pass, but could have been lost since
+	sequencer.SetMastershipCheck(func(ctx context.Context) error {
+		return s.election.VerifyMaster(ctx, logID.TreeID)
+	})
+
+	batchSize := opContext.batchSize
+
+	if s.batchSizer != nil {
+		batchSize = s.batchSizer.BatchSize(logID.TreeID)
+	}
+
+	// Each run is the root of its own trace, and is logged under its own request ID
+	ctx := logging.NewContext(context.Background(), logging.Fields{RequestID: logging.NewRequestID(), TreeID: logID.TreeID})
+	ctx, span := monitoring.StartSpan(ctx, "SequencerManager.sequenceLog", attribute.Int64("treeid", logID.TreeID))
+	start := opContext.timeSource.Now()
+	leaves, err := sequencer.SequenceBatch(ctx, batchSize, isRootTooOld(opContext.timeSource, opContext.signInterval))
+	monitoring.EndSpan(span, err)
+
+	// Losing mastership says nothing about the batch size, another replica has the log now
+	if err == election.ErrNotMaster {
+		return 0, batchSize, fmt.Errorf("lost mastership during run: %v", err)
+	}
+
+	if err != nil {
+		sequencingFailures.Inc(treeID)
+		if err == log.ErrMergeDelayExceeded {
+			mergeDelayRefusals.Inc(treeID)
+		}
+		if s.batchSizer != nil {
+			s.batchSizer.RecordFailure(logID.TreeID, batchSize)
+		}
+		return 0, batchSize, err
+	}
+
+	if late > 0 {
+		logging.Warningf(ctx, "%d leaves were integrated after the max merge delay of %v", late, mmd)
+	}
+
+	elapsed := opContext.timeSource.Now().Sub(start)
+	sequencingLatency.Observe(elapsed.Seconds(), treeID)
+	leavesSequenced.Add(float64(leaves), treeID)
+	s.putTokens(ctx, logID.TreeID, leaves)
+
+	if s.batchSizer != nil {
+		s.batchSizer.RecordRun(logID.TreeID, batchSize, leaves, elapsed)
+	}
+
+	return leaves, batchSize, nil
+}
+
+// rootPublisher returns where the roots signed for a log go, or nil if there's nowhere
+func (s SequencerManager) rootPublisher(treeID int64) publisher.Publisher {
+	var publishers publisher.Multi
+
+	if s.rootCache != nil {
+		publishers = append(publishers, s.rootCache.Publisher(treeID))
+	}
+	if s.auditRecorder != nil {
+		publishers = append(publishers, s.auditRecorder.Publisher(treeID))
+	}
+	if s.publisher != nil {
+		publishers = append(publishers, s.publisher)
+	}
+
+	switch len(publishers) {
+	case 0:
+		return nil
+	case 1:
+		return publishers[0]
+	default:
+		return publishers
+	}
+}
+
+// putTokens returns the write tokens taken for leaves that have been integrated. A failure
+// doesn't fail the run, the leaves have been sequenced regardless.
+func (s SequencerManager) putTokens(ctx context.Context, treeID int64, leaves int) {
+	if s.quotaManager == nil || leaves == 0 {
+		return
+	}
+
+	specs := []quota.Spec{
+		{Group: quota.Global, Kind: quota.Write},
+		{Group: quota.Tree, Kind: quota.Write, TreeID: treeID},
+	}
+
+	if err := s.quotaManager.PutTokens(ctx, leaves, specs); err != nil {
+		logging.Warningf(ctx, "Failed to return %d write tokens for tree %d: %v", leaves, treeID, err)
+	}
 }
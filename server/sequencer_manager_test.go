@@ -1,15 +1,22 @@
 package server
 
 import (
+	"crypto/ecdsa"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/rootcache"
+	"golang.org/x/net/context"
 )
 
 // Arbitrary time for use in tests
@@ -36,9 +43,9 @@ func TestSequencerManagerNothingToDo(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(mockCtrl)
 	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
 
-	sm := NewSequencerManager(mockKeyManager)
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
 
-	sm.ExecutePass([]trillian.LogID{}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+	sm.ExecutePass(context.Background(), []trillian.LogID{}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
 }
 
 func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
@@ -49,16 +56,18 @@ func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
 	mockTx := storage.NewMockLogTX(mockCtrl)
 	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
-	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
-	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
 	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
 
-	sm := NewSequencerManager(mockKeyManager)
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
 
-	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
 }
 
 func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
@@ -76,20 +85,123 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().Rollback().AnyTimes().Do(func() { panic(nil) })
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
-	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{testLeaf0}, nil)
-	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
-	mockTx.EXPECT().UpdateSequencedLeaves([]trillian.LogLeaf{testLeaf0}).Return(nil)
-	mockTx.EXPECT().SetMerkleNodes(updatedNodes0).Return(nil)
-	mockTx.EXPECT().StoreSignedLogRoot(updatedRoot).Return(nil)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), []trillian.LogLeaf{testLeaf0}).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRoot).Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 
 	mockSigner := crypto.NewMockSigner(mockCtrl)
-	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0x13, 0xa6, 0xf3, 0xcb, 0xa2, 0x82, 0x52, 0xfc, 0x5a, 0x98, 0xfe, 0x81, 0x7c, 0xb7, 0xaf, 0x68, 0x1f, 0x83, 0x30, 0xcf, 0x80, 0x71, 0x1e, 0x9e, 0x16, 0xf6, 0x1e, 0x55, 0xcf, 0x78, 0xa, 0xb9}, hasher).Return([]byte("signed"), nil)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xc6, 0x3b, 0xf2, 0x7e, 0x6a, 0x74, 0x49, 0x1b, 0x5, 0xfa, 0x66, 0xf2, 0x47, 0xf0, 0x9c, 0x66, 0xcf, 0xc0, 0xa2, 0x3d, 0xd4, 0x33, 0x72, 0xd6, 0x17, 0xe2, 0xe9, 0xa9, 0xe3, 0x81, 0xc6, 0x25}, hasher).Return([]byte("signed"), nil)
 	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
 
-	sm := NewSequencerManager(mockKeyManager)
+	quotaManager := &fakeQuotaManager{}
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	sm.SetQuotaManager(quotaManager)
+	leaves, runs := leavesSequenced.Value("1"), sequencingLatency.Count("1")
 
-	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	if got, want := leavesSequenced.Value("1"), leaves+1; got != want {
+		t.Errorf("Got %v leaves integrated, want %v", got, want)
+	}
+
+	if got, want := sequencingLatency.Count("1"), runs+1; got != want {
+		t.Errorf("Got %d sequencing runs timed, want %d", got, want)
+	}
+
+	// The write token for the leaf goes back now it's been integrated
+	wantSpecs := []quota.Spec{{Group: quota.Global, Kind: quota.Write}, {Group: quota.Tree, Kind: quota.Write, TreeID: 1}}
+
+	if quotaManager.returned != 1 || !reflect.DeepEqual(quotaManager.returnedSpecs, wantSpecs) {
+		t.Errorf("Returned %d tokens to %v, want 1 to %v", quotaManager.returned, quotaManager.returnedSpecs, wantSpecs)
+	}
+}
+
+func TestSequencerManagerRecordsIntegrationDelay(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+	hasher := trillian.NewSHA256()
+
+	// The leaf was queued for longer than the max merge delay, which isn't enforced
+	leaf := testLeaf0
+	leaf.QueueTimestampNanos = fakeTime.Add(-2 * time.Hour).UnixNano()
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Rollback().AnyTimes().Do(func() { panic(nil) })
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{leaf}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), []trillian.LogLeaf{leaf}).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRoot).Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xc6, 0x3b, 0xf2, 0x7e, 0x6a, 0x74, 0x49, 0x1b, 0x5, 0xfa, 0x66, 0xf2, 0x47, 0xf0, 0x9c, 0x66, 0xcf, 0xc0, 0xa2, 0x3d, 0xd4, 0x33, 0x72, 0xd6, 0x17, 0xe2, 0xe9, 0xa9, 0xe3, 0x81, 0xc6, 0x25}, hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	sm.SetMaxMergeDelays(map[int64]time.Duration{1: time.Hour}, false)
+	delays, late := integrationDelay.Count("1"), mergeDelayExceeded.Value("1")
+
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	if got, want := integrationDelay.Count("1"), delays+1; got != want {
+		t.Errorf("Got %d integration delays, want %d", got, want)
+	}
+
+	if got, want := mergeDelayExceeded.Value("1"), late+1; got != want {
+		t.Errorf("Got %v leaves integrated late, want %v", got, want)
+	}
+}
+
+func TestSequencerManagerCountsMergeDelayRefusals(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	// A leaf that's overdue can't be dequeued, so the root that's due isn't signed
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Times(2).Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueOverdueLeaves(gomock.Any(), 500, fakeTime.Add(-time.Hour).UnixNano(), fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().OldestQueuedLeafNanos(gomock.Any(), fakeTime.UnixNano()).Times(2).Return(fakeTime.Add(-2*time.Hour).UnixNano(), nil)
+
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	sm.SetMaxMergeDelays(map[int64]time.Duration{1: time.Hour}, true)
+	refusals, failures := mergeDelayRefusals.Value("1"), sequencingFailures.Value("1")
+
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	tc.signInterval = time.Second * 5
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, tc)
+
+	if got, want := mergeDelayRefusals.Value("1"), refusals+1; got != want {
+		t.Errorf("Got %v refusals, want %v", got, want)
+	}
+
+	if got, want := sequencingFailures.Value("1"), failures+1; got != want {
+		t.Errorf("Got %v sequencing failures, want %v", got, want)
+	}
 }
 
 // Tests that a new root is signed if it's due even when there is no work to sequence.
@@ -105,23 +217,315 @@ func TestSignsIfNoWorkAndRootExpired(t *testing.T) {
 	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
 	hasher := trillian.NewSHA256()
 
-	mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).AnyTimes().Return(mockTx, nil)
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
 	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
-	mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(testRoot0, nil)
-	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{}, nil)
-	mockTx.EXPECT().StoreSignedLogRoot(updatedRootSignOnly).AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).AnyTimes().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRootSignOnly).AnyTimes().Return(nil)
 
 	mockSigner := crypto.NewMockSigner(mockCtrl)
-	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xeb, 0x7d, 0xa1, 0x4f, 0x1e, 0x60, 0x91, 0x24, 0xa, 0xf7, 0x1c, 0xcd, 0xdb, 0xd4, 0xca, 0x38, 0x4b, 0x12, 0xe4, 0xa3, 0xcf, 0x80, 0x5, 0x55, 0x17, 0x71, 0x35, 0xaf, 0x80, 0x11, 0xa, 0x87}, hasher).Return([]byte("signed"), nil)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xda, 0x97, 0xa2, 0x6c, 0x42, 0xf8, 0x10, 0x5f, 0x53, 0x1a, 0x66, 0xc0, 0x2f, 0xb0, 0xcb, 0x5d, 0x58, 0xa1, 0x74, 0xd0, 0xb, 0x95, 0x69, 0x96, 0x55, 0xbc, 0x55, 0x9f, 0x93, 0xc1, 0x4e, 0xe8}, hasher).Return([]byte("signed"), nil)
 	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
 
-	sm := NewSequencerManager(mockKeyManager)
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	rootCache := rootcache.NewCache(time.Minute, fakeTimeSource)
+	sm.SetRootCache(rootCache)
 
 	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
 	// Lower the expiry so we can trigger a signing for a root older than 5 seconds
 	tc.signInterval = time.Second * 5
-	sm.ExecutePass([]trillian.LogID{logID}, tc)
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, tc)
+
+	// Servers sharing the cache see the new root straight away
+	if root, _, ok := rootCache.LogRoot(logID.TreeID); !ok || !reflect.DeepEqual(root, updatedRootSignOnly) {
+		t.Errorf("Got cached root %v (%v), want %v", root, ok, updatedRootSignOnly)
+	}
+}
+
+func TestSequencerManagerSignsRootMetadata(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+	hasher := trillian.NewSHA256()
+
+	wantRoot := updatedRootSignOnly
+	wantRoot.Metadata = []byte("tree 1")
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).AnyTimes().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), wantRoot).Return(nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	sm.SetRootMetadata(func(ctx context.Context, treeID int64, root trillian.SignedLogRoot) ([]byte, error) {
+		return []byte(fmt.Sprintf("tree %d", treeID)), nil
+	})
+
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	tc.signInterval = time.Second * 5
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, tc)
+}
+
+func TestSequencerManagerRecordsAuditEvents(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+	hasher := trillian.NewSHA256()
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).AnyTimes().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRootSignOnly).Return(nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	trail := audit.NewMemoryTrail()
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+	sm.SetAuditRecorder(audit.NewRecorder(trail, util.FakeTimeSource{FakeTime: fakeTime}))
+
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	tc.signInterval = time.Second * 5
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, tc)
+
+	events, err := trail.Read(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("Failed to read audit trail: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Recorded %d audit events, want 2: %v", len(events), events)
+	}
+	if got := events[0]; got.Type != trillian.AuditEventType_KEY_USED || got.TreeId != 1 || string(got.KeyId) != "Test" {
+		t.Errorf("Recorded %v, want a use of the key of tree 1", got)
+	}
+	if got := events[1]; got.Type != trillian.AuditEventType_LOG_ROOT_SIGNED || got.TreeId != 1 || got.TreeSize != updatedRootSignOnly.TreeSize {
+		t.Errorf("Recorded %v, want the new root of tree 1", got)
+	}
+}
+
+func TestSequencerManagerSkipsLogWithUnknownHashAlgorithm(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The log should not be sequenced so there's no transaction
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm(50))
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	sm := NewSequencerManager(mockKeyManagerProvider(mockKeyManager))
+
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
+
+func TestSequencerManagerUsesAdaptiveBatchSize(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	// The override for this log should be used rather than the context batch size
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 200, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	batchSizer, err := NewAdaptiveBatchSizer(BatchSizeConfig{Initial: 50, Min: 10, Max: 100}, map[int64]BatchSizeConfig{1: {Initial: 200, Min: 100, Max: 400}})
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	sm := NewAdaptiveSequencerManager(mockKeyManagerProvider(mockKeyManager), batchSizer)
+
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	// Nothing was queued so the size should stay at the initial value
+	if got, want := batchSizer.BatchSize(1), 200; got != want {
+		t.Errorf("Got batch size %d after empty run, want %d", got, want)
+	}
+}
+
+func TestSequencerManagerShrinksBatchAfterFailedRun(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	// The batch is too big to be dequeued in time
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 200, fakeTime.UnixNano()).Return(nil, context.DeadlineExceeded)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	batchSizer, err := NewAdaptiveBatchSizer(BatchSizeConfig{Initial: 200, Min: 10, Max: 400}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	sm := NewAdaptiveSequencerManager(mockKeyManagerProvider(mockKeyManager), batchSizer)
+
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	// The next run tries a smaller batch rather than failing the same way again
+	if got, want := batchSizer.BatchSize(1), 100; got != want {
+		t.Errorf("Got batch size %d after failed run, want %d", got, want)
+	}
+}
+
+// fakeElection is master for the trees in its map set to true
+type fakeElection map[int64]bool
+
+func (f fakeElection) IsMaster(treeID int64) (bool, error) {
+	isMaster, ok := f[treeID]
+	if !ok {
+		return false, fmt.Errorf("no election for tree %d", treeID)
+	}
+	return isMaster, nil
+}
+
+func (f fakeElection) VerifyMaster(ctx context.Context, treeID int64) error {
+	if !f[treeID] {
+		return election.ErrNotMaster
+	}
+	return nil
+}
+
+func (f fakeElection) Close() error {
+	return nil
+}
+
+func TestSequencerManagerSkipsLogsWhenNotMaster(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Only the log we're master for should be sequenced
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 50, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	sp := func(id int64) (storage.LogStorage, error) {
+		if id != 1 {
+			return nil, fmt.Errorf("sequenced log %d without mastership", id)
+		}
+		return mockStorage, nil
+	}
+
+	sm := NewElectedSequencerManager(mockKeyManagerProvider(mockKeyManager), nil, fakeElection{1: true, 2: false})
+
+	logIDs := []trillian.LogID{{TreeID: 1, LogID: []byte("Master")}, {TreeID: 2, LogID: []byte("Other")}, {TreeID: 3, LogID: []byte("Unknown")}}
+	sm.ExecutePass(context.Background(), logIDs, createTestContext(sp))
+}
+
+// lostElection was master when asked at the start of a pass, but has lost mastership since
+type lostElection struct{}
+
+func (l lostElection) IsMaster(treeID int64) (bool, error) {
+	return true, nil
+}
+
+func (l lostElection) VerifyMaster(ctx context.Context, treeID int64) error {
+	return election.ErrNotMaster
+}
+
+func (l lostElection) Close() error {
+	return nil
+}
+
+func TestSequencerManagerDoesNotCommitAfterLosingMastership(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+	hasher := trillian.NewSHA256()
+
+	// The batch is built and signed, but rolled back rather than committed
+	mockTx.EXPECT().Rollback().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 200, fakeTime.UnixNano()).Return([]trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), []trillian.LogLeaf{testLeaf0}).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRoot).Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	batchSizer, err := NewAdaptiveBatchSizer(BatchSizeConfig{Initial: 200, Min: 10, Max: 400}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create batch sizer: %v", err)
+	}
+
+	quotaManager := &fakeQuotaManager{}
+	sm := NewElectedSequencerManager(mockKeyManagerProvider(mockKeyManager), batchSizer, lostElection{})
+	sm.SetQuotaManager(quotaManager)
+	leaves := leavesSequenced.Value("1")
+
+	sm.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	if got := leavesSequenced.Value("1"); got != leaves {
+		t.Errorf("Got %v leaves integrated, want %v", got, leaves)
+	}
+
+	if quotaManager.returned != 0 {
+		t.Errorf("Returned %d tokens for leaves that weren't integrated", quotaManager.returned)
+	}
+
+	// Losing mastership isn't the batch's fault
+	if got, want := batchSizer.BatchSize(1), 200; got != want {
+		t.Errorf("Got batch size %d after losing mastership, want %d", got, want)
+	}
 }
 
 func mockStorageProviderForSequencer(mockStorage storage.LogStorage) LogStorageProviderFunc {
@@ -134,6 +538,12 @@ func mockStorageProviderForSequencer(mockStorage storage.LogStorage) LogStorageP
 	}
 }
 
+func mockKeyManagerProvider(km crypto.KeyManager) KeyManagerProviderFunc {
+	return func(trillian.LogID) (crypto.KeyManager, error) {
+		return km, nil
+	}
+}
+
 func createTestContext(sp LogStorageProviderFunc) LogOperationManagerContext {
 	done := make(chan struct{})
 
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"golang.org/x/net/context"
+)
+
+// SequencerScheduleConfig controls how a SequencerScheduler shares sequencing work between
+// logs in each pass.
+type SequencerScheduleConfig struct {
+	// Workers is the number of logs that can be sequenced at the same time
+	Workers int
+	// DefaultWeight is the most sequencing runs a log can be given in one pass
+	DefaultWeight int
+	// Weights overrides DefaultWeight for individual trees
+	Weights map[int64]int
+}
+
+// SequencerScheduler is a LogOperation that sequences all active logs using a pool of
+// workers. Each pass is made up of rounds. Every log gets one run in the first round so
+// quiet logs are never starved. A log that dequeued a full batch still has leaves waiting,
+// so it goes into the next round until it has had as many runs as its weight allows. A log
+// is never sequenced by two workers at once, as concurrent runs would contend for the same
+// queued leaves and tree revision.
+type SequencerScheduler struct {
+	sequencer *SequencerManager
+	config    SequencerScheduleConfig
+}
+
+// sequencingResult holds the outcome of one sequencing run in a round
+type sequencingResult struct {
+	ran       bool
+	leaves    int
+	batchSize int
+	err       error
+}
+
+// NewSequencerScheduler creates a SequencerScheduler that uses sequencer to run each batch.
+func NewSequencerScheduler(sequencer *SequencerManager, config SequencerScheduleConfig) (*SequencerScheduler, error) {
+	if config.Workers <= 0 {
+		return nil, fmt.Errorf("number of workers must be > 0 but was %d", config.Workers)
+	}
+
+	if config.DefaultWeight <= 0 {
+		return nil, fmt.Errorf("default weight must be > 0 but was %d", config.DefaultWeight)
+	}
+
+	for treeID, weight := range config.Weights {
+		if weight <= 0 {
+			return nil, fmt.Errorf("weight for tree %d must be > 0 but was %d", treeID, weight)
+		}
+	}
+
+	return &SequencerScheduler{sequencer: sequencer, config: config}, nil
+}
+
+func (s SequencerScheduler) Name() string {
+	return "SequencerScheduler"
+}
+
+func (s SequencerScheduler) weight(treeID int64) int {
+	if weight, ok := s.config.Weights[treeID]; ok {
+		return weight
+	}
+
+	return s.config.DefaultWeight
+}
+
+func (s SequencerScheduler) ExecutePass(ctx context.Context, logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+	logging.Infof(ctx, "Beginning scheduled sequencing run for %d active log(s)", len(logIDs))
+
+	logIDs = s.sequencer.masterLogs(ctx, logIDs)
+
+	runs := make(map[int64]int)
+	runCount := 0
+	failCount := 0
+	leavesAdded := 0
+	pending := logIDs
+
+	for len(pending) > 0 {
+		results, quit := s.runRound(pending, context)
+		next := []trillian.LogID{}
+
+		for i, result := range results {
+			if !result.ran {
+				continue
+			}
+
+			logID := pending[i]
+			runs[logID.TreeID]++
+			runCount++
+
+			if result.err != nil {
+				logging.Warningf(ctx, "Error trying to sequence batch for: %v: %v", logID, result.err)
+				failCount++
+				continue
+			}
+
+			leavesAdded += result.leaves
+
+			if result.leaves >= result.batchSize && runs[logID.TreeID] < s.weight(logID.TreeID) {
+				next = append(next, logID)
+			}
+		}
+
+		if quit {
+			return true
+		}
+
+		pending = next
+	}
+
+	logging.Infof(ctx, "Scheduled sequencing run completed %d runs over %d log(s) %d failed %d leaves integrated", runCount, len(logIDs), failCount, leavesAdded)
+
+	return false
+}
+
+// runRound sequences each of logIDs once, spread over the configured number of workers. It
+// returns the result for each log and whether the exit signal was seen.
+func (s SequencerScheduler) runRound(logIDs []trillian.LogID, context LogOperationManagerContext) ([]sequencingResult, bool) {
+	results := make([]sequencingResult, len(logIDs))
+	indices := make(chan int, len(logIDs))
+
+	for i := range logIDs {
+		indices <- i
+	}
+	close(indices)
+
+	workers := s.config.Workers
+	if workers > len(logIDs) {
+		workers = len(logIDs)
+	}
+
+	var quitMutex sync.Mutex
+	quit := false
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				// See if it's time to quit, the remaining logs are left unsequenced
+				select {
+				case <-context.done:
+					quitMutex.Lock()
+					quit = true
+					quitMutex.Unlock()
+					return
+				default:
+				}
+
+				leaves, batchSize, err := s.sequencer.sequenceLog(logIDs[i], context)
+				results[i] = sequencingResult{ran: true, leaves: leaves, batchSize: batchSize, err: err}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results, quit
+}
+
+// ParseSequencerWeights parses per tree weights from a comma separated list of
+// treeID=weight entries.
+func ParseSequencerWeights(s string) (map[int64]int, error) {
+	settings, err := parseTreeSettings(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[int64]int, len(settings))
+
+	for treeID, setting := range settings {
+		if weights[treeID], err = strconv.Atoi(setting); err != nil {
+			return nil, fmt.Errorf("invalid weight for tree %d: %v", treeID, err)
+		}
+	}
+
+	return weights, nil
+}
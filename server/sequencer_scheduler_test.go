@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+func TestNewSequencerSchedulerRejectsBadConfig(t *testing.T) {
+	sm := NewSequencerManager(mockKeyManagerProvider(nil))
+
+	for _, config := range []SequencerScheduleConfig{
+		{Workers: 0, DefaultWeight: 1},
+		{Workers: 1, DefaultWeight: 0},
+		{Workers: 1, DefaultWeight: 1, Weights: map[int64]int{1: 0}},
+	} {
+		if _, err := NewSequencerScheduler(sm, config); err == nil {
+			t.Errorf("Created scheduler with bad config: %v", config)
+		}
+	}
+}
+
+// expectOneLeafRuns sets up storage for a log that always has a single leaf queued and
+// expects it to be sequenced the given number of times.
+func expectOneLeafRuns(mockCtrl *gomock.Controller, runs int) (*storage.MockLogStorage, *crypto.MockKeyManager) {
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	mockTx.EXPECT().Commit().Times(runs).Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 1, fakeTime.UnixNano()).Times(runs).Return([]trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Times(runs).Return(testRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), []trillian.LogLeaf{testLeaf0}).Times(runs).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), updatedNodes0).Times(runs).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedRoot).Times(runs).Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Times(runs).Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	mockStorage.EXPECT().Begin(gomock.Any()).Times(runs).Return(mockTx, nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(&ecdsa.PublicKey{})
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xc6, 0x3b, 0xf2, 0x7e, 0x6a, 0x74, 0x49, 0x1b, 0x5, 0xfa, 0x66, 0xf2, 0x47, 0xf0, 0x9c, 0x66, 0xcf, 0xc0, 0xa2, 0x3d, 0xd4, 0x33, 0x72, 0xd6, 0x17, 0xe2, 0xe9, 0xa9, 0xe3, 0x81, 0xc6, 0x25}, trillian.NewSHA256()).Times(runs).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Times(runs).Return(mockSigner, nil)
+
+	return mockStorage, mockKeyManager
+}
+
+func TestSequencerSchedulerRunsBusyLogsUpToWeight(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	busyLogID := trillian.LogID{TreeID: 1, LogID: []byte("Busy")}
+	quietLogID := trillian.LogID{TreeID: 2, LogID: []byte("Quiet")}
+
+	// The busy log always fills its batch so it should be run as often as its weight allows
+	busyStorage, busyKeyManager := expectOneLeafRuns(mockCtrl, 3)
+
+	// The quiet log has nothing queued so it gets a single run
+	quietStorage := storage.NewMockLogStorage(mockCtrl)
+	quietTx := storage.NewMockLogTX(mockCtrl)
+	quietStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	quietStorage.EXPECT().TreeType().AnyTimes().Return(trillian.TreeType_LOG)
+	quietStorage.EXPECT().Begin(gomock.Any()).Return(quietTx, nil)
+	quietTx.EXPECT().Commit().Return(nil)
+	quietTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	quietTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testRoot0, nil)
+	quietTx.EXPECT().DequeueLeaves(gomock.Any(), 1, fakeTime.UnixNano()).Return([]trillian.LogLeaf{}, nil)
+	quietKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	sp := func(id int64) (storage.LogStorage, error) {
+		switch id {
+		case busyLogID.TreeID:
+			return busyStorage, nil
+		case quietLogID.TreeID:
+			return quietStorage, nil
+		default:
+			return nil, fmt.Errorf("BADLOGID: %d", id)
+		}
+	}
+
+	kmp := func(logID trillian.LogID) (crypto.KeyManager, error) {
+		if logID.TreeID == busyLogID.TreeID {
+			return busyKeyManager, nil
+		}
+		return quietKeyManager, nil
+	}
+
+	scheduler, err := NewSequencerScheduler(NewSequencerManager(kmp), SequencerScheduleConfig{Workers: 2, DefaultWeight: 5, Weights: map[int64]int{busyLogID.TreeID: 3}})
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+
+	tc := createTestContext(sp)
+	tc.batchSize = 1
+
+	if scheduler.ExecutePass(context.Background(), []trillian.LogID{busyLogID, quietLogID}, tc) {
+		t.Error("Scheduler pass returned quit without an exit signal")
+	}
+}
+
+func TestSequencerSchedulerStopsOnExit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed after the exit signal
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	scheduler, err := NewSequencerScheduler(NewSequencerManager(mockKeyManagerProvider(mockKeyManager)), SequencerScheduleConfig{Workers: 2, DefaultWeight: 1})
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	close(tc.done)
+
+	if !scheduler.ExecutePass(context.Background(), []trillian.LogID{logID1, {TreeID: 0, LogID: []byte("other")}}, tc) {
+		t.Error("Scheduler pass did not return quit after exit signal")
+	}
+}
+
+func TestParseSequencerWeights(t *testing.T) {
+	weights, err := ParseSequencerWeights("1=3,7=10")
+	if err != nil {
+		t.Fatalf("Failed to parse weights: %v", err)
+	}
+
+	if len(weights) != 2 || weights[1] != 3 || weights[7] != 10 {
+		t.Errorf("Got weights %v, want map[1:3 7:10]", weights)
+	}
+
+	for _, s := range []string{"1", "x=3", "1=x", "1=3,1=4"} {
+		if _, err := ParseSequencerWeights(s); err == nil {
+			t.Errorf("Parsed bad sequencer weights: %q", s)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"expvar"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+var (
+	// subtreeGCRowsReclaimed counts the subtree revisions deleted by garbage collection.
+	subtreeGCRowsReclaimed = expvar.NewInt("subtree_gc_rows_reclaimed")
+	// subtreeGCBytesReclaimed counts the bytes of subtree data deleted by garbage collection.
+	subtreeGCBytesReclaimed = expvar.NewInt("subtree_gc_bytes_reclaimed")
+)
+
+// SubtreeGCManager is a LogOperation that deletes subtree revisions which can't be read
+// at any of the most recent tree revisions of each log, so that node storage doesn't
+// grow without bound.
+type SubtreeGCManager struct {
+	// retainRevisions is the number of tree revisions, counting back from the latest
+	// signed root, which must remain fully readable.
+	retainRevisions int64
+}
+
+// NewSubtreeGCManager creates a SubtreeGCManager which keeps the subtree revisions needed
+// to read the last retainRevisions revisions of each log.
+func NewSubtreeGCManager(retainRevisions int64) *SubtreeGCManager {
+	return &SubtreeGCManager{retainRevisions: retainRevisions}
+}
+
+func (s SubtreeGCManager) Name() string {
+	return "SubtreeGC"
+}
+
+func (s SubtreeGCManager) ExecutePass(ctx context.Context, logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+	logging.V(1).Infof(ctx, "Beginning subtree garbage collection for %d active log(s)", len(logIDs))
+
+	var total storage.PruneStats
+	successCount := 0
+
+	for _, logID := range logIDs {
+		// See if it's time to quit
+		select {
+		case <-context.done:
+			return true
+		default:
+		}
+
+		stats, err := s.pruneLog(ctx, logID, context)
+		if err != nil {
+			logging.Warningf(ctx, "Error collecting subtrees for: %v: %v", logID, err)
+			continue
+		}
+
+		successCount++
+		total.Rows += stats.Rows
+		total.Bytes += stats.Bytes
+	}
+
+	subtreeGCRowsReclaimed.Add(total.Rows)
+	subtreeGCBytesReclaimed.Add(total.Bytes)
+	logging.Infof(ctx, "Subtree garbage collection completed %d succeeded %d failed, reclaimed %d rows %d bytes", successCount, len(logIDs)-successCount, total.Rows, total.Bytes)
+
+	return false
+}
+
+// pruneLog deletes the superseded subtree revisions of a single log which are older than
+// the retention horizon.
+func (s SubtreeGCManager) pruneLog(ctx context.Context, logID trillian.LogID, opContext LogOperationManagerContext) (storage.PruneStats, error) {
+	ls, err := opContext.storageProvider(logID.TreeID)
+	if err != nil {
+		return storage.PruneStats{}, err
+	}
+
+	tx, err := ls.Begin(ctx)
+	if err != nil {
+		return storage.PruneStats{}, err
+	}
+
+	pruner, ok := tx.(storage.SubtreePruner)
+	if !ok {
+		// Not an error, the storage just doesn't support it.
+		logging.V(1).Infof(ctx, "Storage for %v does not support subtree garbage collection", logID)
+		return storage.PruneStats{}, tx.Commit()
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return storage.PruneStats{}, err
+	}
+
+	horizon := root.TreeRevision - s.retainRevisions
+	if horizon <= 0 {
+		return storage.PruneStats{}, tx.Commit()
+	}
+
+	stats, err := pruner.PruneSubtrees(ctx, horizon)
+	if err != nil {
+		tx.Rollback()
+		return storage.PruneStats{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storage.PruneStats{}, err
+	}
+
+	logging.V(1).Infof(ctx, "Reclaimed %d subtree rows %d bytes for %v, horizon %d", stats.Rows, stats.Bytes, logID, horizon)
+	return stats, nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// prunableLogTX adds SubtreePruner support to a mock LogTX.
+type prunableLogTX struct {
+	*storage.MockLogTX
+	horizons []int64
+	stats    storage.PruneStats
+	err      error
+}
+
+func (p *prunableLogTX) PruneSubtrees(_ context.Context, horizon int64) (storage.PruneStats, error) {
+	p.horizons = append(p.horizons, horizon)
+	return p.stats, p.err
+}
+
+func TestSubtreeGCManagerPrunesBeforeHorizon(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := &prunableLogTX{MockLogTX: storage.NewMockLogTX(mockCtrl), stats: storage.PruneStats{Rows: 3, Bytes: 300}}
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.MockLogTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{TreeRevision: 25}, nil)
+	mockTx.MockLogTX.EXPECT().Commit().Return(nil)
+
+	rows, bytes := subtreeGCRowsReclaimed.Value(), subtreeGCBytesReclaimed.Value()
+
+	gc := NewSubtreeGCManager(10)
+	if gc.ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage))) {
+		t.Error("ExecutePass()=true, want false")
+	}
+
+	if got, want := mockTx.horizons, []int64{15}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("pruned at horizons %v, want %v", got, want)
+	}
+	if got, want := subtreeGCRowsReclaimed.Value()-rows, int64(3); got != want {
+		t.Errorf("reclaimed %d rows, want %d", got, want)
+	}
+	if got, want := subtreeGCBytesReclaimed.Value()-bytes, int64(300); got != want {
+		t.Errorf("reclaimed %d bytes, want %d", got, want)
+	}
+}
+
+func TestSubtreeGCManagerYoungTreeNotPruned(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := &prunableLogTX{MockLogTX: storage.NewMockLogTX(mockCtrl)}
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.MockLogTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{TreeRevision: 5}, nil)
+	mockTx.MockLogTX.EXPECT().Commit().Return(nil)
+
+	NewSubtreeGCManager(10).ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	if len(mockTx.horizons) != 0 {
+		t.Errorf("pruned at horizons %v, want none", mockTx.horizons)
+	}
+}
+
+func TestSubtreeGCManagerPruneFailsRollsBack(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := &prunableLogTX{MockLogTX: storage.NewMockLogTX(mockCtrl), err: errors.New("prune")}
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.MockLogTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{TreeRevision: 25}, nil)
+	mockTx.MockLogTX.EXPECT().Rollback().Return(nil)
+
+	NewSubtreeGCManager(10).ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
+
+func TestSubtreeGCManagerStorageWithoutPruner(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	NewSubtreeGCManager(10).ExecutePass(context.Background(), []trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/accounting"
+	"github.com/google/trillian/audit"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// Access control is left to the auth interceptors, which need clients to have admin
+// permission on a tree to change or delete it. Without them any client can manage any tree.
+
+// defaultMaxAuditEvents limits the audit events returned by ListAuditEvents if the request
+// doesn't, or asks for more
+const defaultMaxAuditEvents = 1000
+
+// AdminStorageProviderFunc decouples the server from storage implementations
+type AdminStorageProviderFunc func() (storage.AdminStorage, error)
+
+// TrillianAdminServer implements the TrillianAdmin RPC API defined in the proto
+type TrillianAdminServer struct {
+	storageProvider AdminStorageProviderFunc
+	// accountant has the usage this server hasn't stored yet, if it's nil only stored usage
+	// is reported
+	accountant *accounting.Accountant
+	// auditTrail is served by ListAuditEvents, nil if the server doesn't keep one
+	auditTrail audit.Trail
+	// configReloader is called by ReloadConfig, nil if the server can't reload its config
+	configReloader func(context.Context) error
+}
+
+// NewTrillianAdminServer creates a new RPC server backed by an AdminStorageProvider.
+func NewTrillianAdminServer(p AdminStorageProviderFunc) *TrillianAdminServer {
+	return &TrillianAdminServer{storageProvider: p}
+}
+
+// SetAccountant includes the usage a counts that hasn't been stored yet in the usage
+// returned by GetTreeUsage, along with the recent request rate.
+func (t *TrillianAdminServer) SetAccountant(a *accounting.Accountant) {
+	t.accountant = a
+}
+
+// SetAuditTrail makes the events in trail available from ListAuditEvents.
+func (t *TrillianAdminServer) SetAuditTrail(trail audit.Trail) {
+	t.auditTrail = trail
+}
+
+// SetConfigReloader makes ReloadConfig call f, which should apply the settings in the
+// server's config file that can change while it's running.
+func (t *TrillianAdminServer) SetConfigReloader(f func(context.Context) error) {
+	t.configReloader = f
+}
+
+// CreateTree provisions a new log or map with the requested settings.
+func (t *TrillianAdminServer) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest) (*trillian.CreateTreeResponse, error) {
+	if err := storage.ValidateTreeForCreation(req.Tree); err != nil {
+		return &trillian.CreateTreeResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())}, nil
+	}
+
+	var tree *trillian.Tree
+	err := t.update(ctx, "CreateTree", func(tx storage.AdminTX) error {
+		var err error
+		tree, err = tx.CreateTree(req.Tree)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Infof(ctx, "Created %v tree: %d", tree.TreeType, tree.TreeId)
+
+	return &trillian.CreateTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// ListTrees returns all of the trees known to the server. Deleted trees are only included
+// if the request asks for them.
+func (t *TrillianAdminServer) ListTrees(ctx context.Context, req *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
+	var trees []*trillian.Tree
+	err := t.read(ctx, "ListTrees", func(tx storage.ReadOnlyAdminTX) error {
+		var err error
+		trees, err = tx.ListTrees(req.ShowDeleted)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.ListTreesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: trees}, nil
+}
+
+// GetTree returns the settings of a single tree.
+func (t *TrillianAdminServer) GetTree(ctx context.Context, req *trillian.GetTreeRequest) (*trillian.GetTreeResponse, error) {
+	var tree *trillian.Tree
+	err := t.read(ctx, "GetTree", func(tx storage.ReadOnlyAdminTX) error {
+		var err error
+		tree, err = tx.GetTree(req.TreeId)
+		return err
+	})
+
+	if isTreeError(err) {
+		return &trillian.GetTreeResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// UpdateTree changes the display name and description of a tree. These are the only
+// settings that can be changed once a tree has been created.
+func (t *TrillianAdminServer) UpdateTree(ctx context.Context, req *trillian.UpdateTreeRequest) (*trillian.UpdateTreeResponse, error) {
+	if req.Tree == nil {
+		return &trillian.UpdateTreeResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must specify the tree to update")}, nil
+	}
+
+	tree, err := t.updateTree(ctx, "UpdateTree", req.Tree.TreeId, func(tree *trillian.Tree) {
+		tree.DisplayName = req.Tree.DisplayName
+		tree.Description = req.Tree.Description
+	})
+
+	if isTreeError(err) {
+		return &trillian.UpdateTreeResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &trillian.UpdateTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// FreezeTree stops a tree from accepting any further writes. Freezing a tree that is already
+// frozen has no effect.
+func (t *TrillianAdminServer) FreezeTree(ctx context.Context, req *trillian.FreezeTreeRequest) (*trillian.FreezeTreeResponse, error) {
+	tree, err := t.updateTree(ctx, "FreezeTree", req.TreeId, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+	})
+
+	if isTreeError(err) {
+		return &trillian.FreezeTreeResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logging.Infof(ctx, "Froze tree: %d", tree.TreeId)
+
+	return &trillian.FreezeTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// DeleteTree marks a tree as deleted. The tree can no longer be used but its data is kept
+// until the garbage collector removes it, and until then it can be restored by UndeleteTree.
+func (t *TrillianAdminServer) DeleteTree(ctx context.Context, req *trillian.DeleteTreeRequest) (*trillian.DeleteTreeResponse, error) {
+	var tree *trillian.Tree
+	err := t.update(ctx, "DeleteTree", func(tx storage.AdminTX) error {
+		var err error
+		tree, err = tx.SoftDeleteTree(req.TreeId)
+		return err
+	})
+
+	if isTreeError(err) {
+		return &trillian.DeleteTreeResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logging.Infof(ctx, "Deleted tree: %d", tree.TreeId)
+
+	return &trillian.DeleteTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// UndeleteTree restores a deleted tree that has not yet been garbage collected.
+func (t *TrillianAdminServer) UndeleteTree(ctx context.Context, req *trillian.UndeleteTreeRequest) (*trillian.UndeleteTreeResponse, error) {
+	var tree *trillian.Tree
+	err := t.update(ctx, "UndeleteTree", func(tx storage.AdminTX) error {
+		var err error
+		tree, err = tx.UndeleteTree(req.TreeId)
+		return err
+	})
+
+	if isTreeError(err) {
+		return &trillian.UndeleteTreeResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logging.Infof(ctx, "Undeleted tree: %d", tree.TreeId)
+
+	return &trillian.UndeleteTreeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tree: tree}, nil
+}
+
+// GetTreeUsage returns the usage of a tree that has been stored, plus the usage this server
+// hasn't stored yet.
+func (t *TrillianAdminServer) GetTreeUsage(ctx context.Context, req *trillian.GetTreeUsageRequest) (*trillian.GetTreeUsageResponse, error) {
+	var usage *trillian.TreeUsage
+	err := t.read(ctx, "GetTreeUsage", func(tx storage.ReadOnlyAdminTX) error {
+		// Make sure the tree exists, trees that haven't been used have no stored usage
+		if _, err := tx.GetTree(req.TreeId); err != nil {
+			return err
+		}
+
+		var err error
+		usage, err = tx.GetTreeUsage(req.TreeId)
+		return err
+	})
+
+	if isTreeError(err) {
+		return &trillian.GetTreeUsageResponse{Status: treeErrorStatus(err)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if t.accountant != nil {
+		pending := t.accountant.Pending(req.TreeId)
+		usage.Requests += pending.Requests
+		usage.BytesIn += pending.BytesIn
+		usage.BytesOut += pending.BytesOut
+		usage.LeavesQueued += pending.LeavesQueued
+		usage.RecentQps = pending.RecentQps
+	}
+
+	return &trillian.GetTreeUsageResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Usage: usage}, nil
+}
+
+// ListAuditEvents returns the events in the audit trail from the requested index, only those
+// for one tree if the request names it.
+func (t *TrillianAdminServer) ListAuditEvents(ctx context.Context, req *trillian.ListAuditEventsRequest) (*trillian.ListAuditEventsResponse, error) {
+	if t.auditTrail == nil {
+		return &trillian.ListAuditEventsResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "This server doesn't keep an audit trail")}, nil
+	}
+	if req.StartIndex < 0 {
+		return &trillian.ListAuditEventsResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Start index must not be negative")}, nil
+	}
+
+	max := int(req.MaxEvents)
+	if max <= 0 || max > defaultMaxAuditEvents {
+		max = defaultMaxAuditEvents
+	}
+
+	// Keep reading until there are enough events for the tree or the trail runs out
+	events := []*trillian.AuditEvent{}
+	next := req.StartIndex
+	for len(events) < max {
+		batch, err := t.auditTrail.Read(ctx, next, max)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for i := range batch {
+			next = batch[i].Index + 1
+			if req.TreeId == 0 || batch[i].TreeId == req.TreeId {
+				events = append(events, &batch[i])
+				if len(events) == max {
+					break
+				}
+			}
+		}
+	}
+
+	return &trillian.ListAuditEventsResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Events: events, NextIndex: next}, nil
+}
+
+// ReloadConfig applies the server's config file again. Settings that fail to load are
+// reported in the status and the server keeps the ones it had.
+func (t *TrillianAdminServer) ReloadConfig(ctx context.Context, req *trillian.ReloadConfigRequest) (*trillian.ReloadConfigResponse, error) {
+	if t.configReloader == nil {
+		return &trillian.ReloadConfigResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "This server can't reload its config")}, nil
+	}
+
+	if err := t.configReloader(ctx); err != nil {
+		logging.Warningf(ctx, "Failed to reload config: %v", err)
+		return &trillian.ReloadConfigResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())}, nil
+	}
+
+	return &trillian.ReloadConfigResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
+}
+
+func (t *TrillianAdminServer) updateTree(ctx context.Context, op string, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	var tree *trillian.Tree
+	err := t.update(ctx, op, func(tx storage.AdminTX) error {
+		var err error
+		tree, err = tx.UpdateTree(treeID, updateFunc)
+		return err
+	})
+
+	return tree, err
+}
+
+// read runs f in a read-only admin transaction, which is committed if f succeeds.
+func (t *TrillianAdminServer) read(ctx context.Context, op string, f func(storage.ReadOnlyAdminTX) error) error {
+	s, err := t.storageProvider()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return storageStatus(err)
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return t.commitAndLog(ctx, tx, op)
+}
+
+// update runs f in an admin transaction, which is committed if f succeeds and rolled back
+// otherwise.
+func (t *TrillianAdminServer) update(ctx context.Context, op string, f func(storage.AdminTX) error) error {
+	s, err := t.storageProvider()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return storageStatus(err)
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return t.commitAndLog(ctx, tx, op)
+}
+
+func (t *TrillianAdminServer) commitAndLog(ctx context.Context, tx storage.ReadOnlyAdminTX, op string) error {
+	err := tx.Commit()
+
+	if err != nil {
+		logging.Warningf(ctx, "Commit failed for %s: %v", op, err)
+	}
+
+	return err
+}
+
+// isTreeError returns true if err is caused by the state of the requested tree, rather
+// than a failure of the server, and so should be reported to the client in the status.
+func isTreeError(err error) bool {
+	return err == storage.ErrTreeNotFound || err == storage.ErrTreeDeleted || err == storage.ErrTreeNotDeleted
+}
+
+func treeErrorStatus(err error) *trillian.TrillianApiStatus {
+	return buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())
+}
@@ -0,0 +1,514 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/accounting"
+	"github.com/google/trillian/audit"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var newLogTree = trillian.Tree{
+	TreeType:      trillian.TreeType_LOG,
+	KeyId:         []byte("key"),
+	HashAlgorithm: trillian.HashAlgorithm_SHA256,
+	DisplayName:   "Log",
+}
+
+var storedLogTree = trillian.Tree{
+	TreeId:           12345,
+	TreeType:         trillian.TreeType_LOG,
+	TreeState:        trillian.TreeState_ACTIVE,
+	KeyId:            []byte("key"),
+	HashAlgorithm:    trillian.HashAlgorithm_SHA256,
+	DisplayName:      "Log",
+	CreateTimeMillis: 1000,
+	UpdateTimeMillis: 1000,
+}
+
+func mockAdminStorageProviderFunc(mockStorage storage.AdminStorage) AdminStorageProviderFunc {
+	return func() (storage.AdminStorage, error) {
+		return mockStorage, nil
+	}
+}
+
+func TestCreateTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().CreateTree(&newLogTree).Return(&storedLogTree, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.CreateTree(context.Background(), &trillian.CreateTreeRequest{Tree: &newLogTree})
+
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", got)
+	}
+
+	if !proto.Equal(&storedLogTree, resp.Tree) {
+		t.Fatalf("Expected tree %v but got: %v", storedLogTree, resp.Tree)
+	}
+}
+
+func TestCreateTreeInvalidTreeRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No storage calls are expected
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(storage.NewMockAdminStorage(ctrl)))
+
+	invalid := newLogTree
+	invalid.TreeId = 23
+
+	for _, req := range []*trillian.CreateTreeRequest{{}, {Tree: &invalid}} {
+		resp, err := server.CreateTree(context.Background(), req)
+
+		if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+			t.Fatalf("Allowed invalid tree to be created: %v, %v", req, err)
+		}
+	}
+}
+
+func TestCreateTreeStorageErrorRollsBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().CreateTree(&newLogTree).Return(nil, errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+
+	if _, err := server.CreateTree(context.Background(), &trillian.CreateTreeRequest{Tree: &newLogTree}); err == nil {
+		t.Fatal("Returned OK when storage failed")
+	}
+}
+
+func TestCreateTreeCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().CreateTree(&newLogTree).Return(&storedLogTree, nil)
+	mockTx.EXPECT().Commit().Return(errors.New("COMMIT"))
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+
+	if _, err := server.CreateTree(context.Background(), &trillian.CreateTreeRequest{Tree: &newLogTree}); err == nil {
+		t.Fatal("Returned OK when commit failed")
+	}
+}
+
+func TestListTrees(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mapTree := storedLogTree
+	mapTree.TreeId = 67890
+	mapTree.TreeType = trillian.TreeType_MAP
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().ListTrees(false).Return([]*trillian.Tree{&storedLogTree, &mapTree}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.ListTrees(context.Background(), &trillian.ListTreesRequest{})
+
+	if err != nil {
+		t.Fatalf("Failed to list trees: %v", err)
+	}
+
+	if len(resp.Tree) != 2 || !proto.Equal(&storedLogTree, resp.Tree[0]) || !proto.Equal(&mapTree, resp.Tree[1]) {
+		t.Fatalf("Got unexpected trees: %v", resp.Tree)
+	}
+}
+
+func TestGetTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetTree(storedLogTree.TreeId).Return(&storedLogTree, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.GetTree(context.Background(), &trillian.GetTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+
+	if !proto.Equal(&storedLogTree, resp.Tree) {
+		t.Fatalf("Expected tree %v but got: %v", storedLogTree, resp.Tree)
+	}
+}
+
+func TestGetTreeNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetTree(int64(99)).Return(nil, storage.ErrTreeNotFound)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.GetTree(context.Background(), &trillian.GetTreeRequest{TreeId: 99})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error for missing tree but got: %v, %v", resp, err)
+	}
+}
+
+func TestUpdateTreeOnlyChangesMutableFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	update := trillian.Tree{
+		TreeId:      storedLogTree.TreeId,
+		TreeType:    trillian.TreeType_MAP,
+		TreeState:   trillian.TreeState_FROZEN,
+		DisplayName: "New name",
+		Description: "New description",
+	}
+	updated := storedLogTree
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().UpdateTree(storedLogTree.TreeId, gomock.Any()).Do(func(treeID int64, f func(*trillian.Tree)) {
+		f(&updated)
+	}).Return(&updated, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.UpdateTree(context.Background(), &trillian.UpdateTreeRequest{Tree: &update})
+
+	if err != nil {
+		t.Fatalf("Failed to update tree: %v", err)
+	}
+
+	want := storedLogTree
+	want.DisplayName = "New name"
+	want.Description = "New description"
+
+	if !proto.Equal(&want, resp.Tree) {
+		t.Fatalf("Expected tree %v but got: %v", want, resp.Tree)
+	}
+}
+
+func TestFreezeTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	frozen := storedLogTree
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().UpdateTree(storedLogTree.TreeId, gomock.Any()).Do(func(treeID int64, f func(*trillian.Tree)) {
+		f(&frozen)
+	}).Return(&frozen, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.FreezeTree(context.Background(), &trillian.FreezeTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil {
+		t.Fatalf("Failed to freeze tree: %v", err)
+	}
+
+	if expected, got := trillian.TreeState_FROZEN, resp.Tree.TreeState; expected != got {
+		t.Fatalf("Expected tree state %v but got: %v", expected, got)
+	}
+}
+
+func TestListTreesShowDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deleted := storedLogTree
+	deleted.Deleted = true
+	deleted.DeleteTimeMillis = 2000
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().ListTrees(true).Return([]*trillian.Tree{&deleted}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.ListTrees(context.Background(), &trillian.ListTreesRequest{ShowDeleted: true})
+
+	if err != nil {
+		t.Fatalf("Failed to list trees: %v", err)
+	}
+
+	if len(resp.Tree) != 1 || !proto.Equal(&deleted, resp.Tree[0]) {
+		t.Fatalf("Got unexpected trees: %v", resp.Tree)
+	}
+}
+
+func TestDeleteTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deleted := storedLogTree
+	deleted.Deleted = true
+	deleted.DeleteTimeMillis = 2000
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().SoftDeleteTree(storedLogTree.TreeId).Return(&deleted, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.DeleteTree(context.Background(), &trillian.DeleteTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil {
+		t.Fatalf("Failed to delete tree: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", got)
+	}
+
+	if !proto.Equal(&deleted, resp.Tree) {
+		t.Fatalf("Expected tree %v but got: %v", deleted, resp.Tree)
+	}
+}
+
+func TestDeleteTreeNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().SoftDeleteTree(int64(99)).Return(nil, storage.ErrTreeNotFound)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.DeleteTree(context.Background(), &trillian.DeleteTreeRequest{TreeId: 99})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error for missing tree but got: %v, %v", resp, err)
+	}
+}
+
+func TestDeleteTreeAlreadyDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().SoftDeleteTree(storedLogTree.TreeId).Return(nil, storage.ErrTreeDeleted)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.DeleteTree(context.Background(), &trillian.DeleteTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error for deleted tree but got: %v, %v", resp, err)
+	}
+}
+
+func TestUndeleteTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().UndeleteTree(storedLogTree.TreeId).Return(&storedLogTree, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.UndeleteTree(context.Background(), &trillian.UndeleteTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil {
+		t.Fatalf("Failed to undelete tree: %v", err)
+	}
+
+	if !proto.Equal(&storedLogTree, resp.Tree) {
+		t.Fatalf("Expected tree %v but got: %v", storedLogTree, resp.Tree)
+	}
+}
+
+func TestUndeleteTreeNotDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockAdminTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().UndeleteTree(storedLogTree.TreeId).Return(nil, storage.ErrTreeNotDeleted)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.UndeleteTree(context.Background(), &trillian.UndeleteTreeRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error for tree that isn't deleted but got: %v, %v", resp, err)
+	}
+}
+
+func TestGetTreeUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stored := &trillian.TreeUsage{TreeId: storedLogTree.TreeId, Requests: 10, BytesIn: 100, BytesOut: 1000, LeavesQueued: 5, UpdateTimeMillis: 2000}
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetTree(storedLogTree.TreeId).Return(&storedLogTree, nil)
+	mockTx.EXPECT().GetTreeUsage(storedLogTree.TreeId).Return(stored, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	// The server adds the usage it hasn't stored yet
+	accountant := accounting.NewAccountant(make(chan struct{}), nil, time.Minute, util.FakeTimeSource{FakeTime: fakeTime})
+	accountant.Record(storedLogTree.TreeId, 10, 100, 1)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	server.SetAccountant(accountant)
+	resp, err := server.GetTreeUsage(context.Background(), &trillian.GetTreeUsageRequest{TreeId: storedLogTree.TreeId})
+
+	if err != nil {
+		t.Fatalf("Failed to get tree usage: %v", err)
+	}
+
+	want := &trillian.TreeUsage{TreeId: storedLogTree.TreeId, Requests: 11, BytesIn: 110, BytesOut: 1100, LeavesQueued: 6, UpdateTimeMillis: 2000}
+
+	if !proto.Equal(want, resp.Usage) {
+		t.Fatalf("Expected usage %v but got: %v", want, resp.Usage)
+	}
+}
+
+func TestGetTreeUsageNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockAdminStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyAdminTX(ctrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetTree(int64(99)).Return(nil, storage.ErrTreeNotFound)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianAdminServer(mockAdminStorageProviderFunc(mockStorage))
+	resp, err := server.GetTreeUsage(context.Background(), &trillian.GetTreeUsageRequest{TreeId: 99})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error for missing tree but got: %v, %v", resp, err)
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	trail := audit.NewMemoryTrail()
+	for _, treeID := range []int64{1, 2, 1, 0, 1} {
+		if err := trail.Append(ctx, trillian.AuditEvent{Type: trillian.AuditEventType_ADMIN_RPC, TreeId: treeID}); err != nil {
+			t.Fatalf("Failed to append audit event: %v", err)
+		}
+	}
+
+	server := NewTrillianAdminServer(nil)
+	server.SetAuditTrail(trail)
+
+	for _, test := range []struct {
+		req         trillian.ListAuditEventsRequest
+		wantIndexes []int64
+		wantNext    int64
+	}{
+		{trillian.ListAuditEventsRequest{}, []int64{0, 1, 2, 3, 4}, 5},
+		{trillian.ListAuditEventsRequest{StartIndex: 1, MaxEvents: 2}, []int64{1, 2}, 3},
+		{trillian.ListAuditEventsRequest{TreeId: 1}, []int64{0, 2, 4}, 5},
+		{trillian.ListAuditEventsRequest{TreeId: 1, MaxEvents: 2}, []int64{0, 2}, 3},
+		{trillian.ListAuditEventsRequest{TreeId: 2, StartIndex: 2}, nil, 5},
+		{trillian.ListAuditEventsRequest{StartIndex: 5}, nil, 5},
+	} {
+		req := test.req
+		resp, err := server.ListAuditEvents(ctx, &req)
+		if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+			t.Fatalf("ListAuditEvents(%v) = %v, %v", req, resp, err)
+		}
+
+		var indexes []int64
+		for _, event := range resp.Events {
+			indexes = append(indexes, event.Index)
+		}
+		if !reflect.DeepEqual(indexes, test.wantIndexes) || resp.NextIndex != test.wantNext {
+			t.Errorf("ListAuditEvents(%v) returned events %v and next index %d, want %v and %d", req, indexes, resp.NextIndex, test.wantIndexes, test.wantNext)
+		}
+	}
+
+	if resp, err := server.ListAuditEvents(ctx, &trillian.ListAuditEventsRequest{StartIndex: -1}); err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Errorf("Expected app level error for a negative start index but got: %v, %v", resp, err)
+	}
+}
+
+func TestListAuditEventsWithoutTrail(t *testing.T) {
+	server := NewTrillianAdminServer(nil)
+	resp, err := server.ListAuditEvents(context.Background(), &trillian.ListAuditEventsRequest{})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Expected app level error without an audit trail but got: %v, %v", resp, err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	server := NewTrillianAdminServer(nil)
+	ctx := context.Background()
+
+	if resp, err := server.ReloadConfig(ctx, &trillian.ReloadConfigRequest{}); err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Errorf("Expected app level error without a config reloader but got: %v, %v", resp, err)
+	}
+
+	var reloadErr error
+	reloads := 0
+	server.SetConfigReloader(func(ctx context.Context) error {
+		reloads++
+		return reloadErr
+	})
+
+	if resp, err := server.ReloadConfig(ctx, &trillian.ReloadConfigRequest{}); err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Errorf("ReloadConfig() = %v, %v, want OK", resp, err)
+	}
+
+	reloadErr = errors.New("bad quota limits")
+
+	if resp, err := server.ReloadConfig(ctx, &trillian.ReloadConfigRequest{}); err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR || resp.Status.Description != "bad quota limits" {
+		t.Errorf("Expected app level error when the reload fails but got: %v, %v", resp, err)
+	}
+
+	if reloads != 2 {
+		t.Errorf("Config was reloaded %d times, want 2", reloads)
+	}
+}
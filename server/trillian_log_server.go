@@ -2,59 +2,252 @@ package server
 
 import (
 	"fmt"
+	"net"
+	"sync"
+
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
-	"github.com/google/trillian/storage"
+	"github.com/google/trillian/auth"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/merkle"
-	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util/rootcache"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 )
 
-// TODO: There is no access control in the server yet and clients could easily modify
-// any tree.
+// Access control is left to the auth interceptors. Without them any client can read or
+// add to any log.
 
 // Pass this as a fixed value to proof calculations. It's used as the max depth of the tree
 const proofMaxBitLen = 64
 
+// The largest number of leaves sent in each StreamLeaves response, also used if the
+// client doesn't ask for a particular size
+const maxStreamLeavesChunkSize = 1000
+
+// defaultMaxRangeLeaves is the most leaves a GetLeavesByRange page has unless the server is
+// set up with another limit
+const defaultMaxRangeLeaves = 1000
+
 // LogStorageProviderFunc decouples the server from storage implementations
 type LogStorageProviderFunc func(int64) (storage.LogStorage, error)
 
 // TrillianLogServer implements the RPC API defined in the proto
 type TrillianLogServer struct {
 	storageProvider LogStorageProviderFunc
+	// witnessKeys is nil if the server doesn't accept cosignatures
+	witnessKeys WitnessKeyProviderFunc
+	// quotaManager hands out the tokens each request needs before it's allowed to use storage
+	quotaManager quota.Manager
+	// rootCache holds the latest signed root of each log, if it's nil roots are always read
+	// from storage
+	rootCache *rootcache.Cache
+	// maxLeafSize is the most bytes of value and extra data a leaf can have for logs without
+	// their own limit in maxLeafSizes, leaves can be any size if it's 0. Must hold
+	// leafSizesMutex before accessing them, they can change while requests are served.
+	leafSizesMutex sync.RWMutex
+	maxLeafSize    int
+	maxLeafSizes   map[int64]int
+	// queuePriorities is the range of priorities each log's leaves can be queued with, logs
+	// that aren't in it queue every leaf at 0. Must hold queuePrioritiesMutex before
+	// accessing it.
+	queuePrioritiesMutex sync.RWMutex
+	queuePriorities      map[int64]QueuePriorityRange
+	// leafValidator checks leaves before they're stored, if it's nil only the checks the
+	// log needs are made
+	leafValidator LeafValidatorFunc
+	// maxRangeLeaves is the most leaves returned in a GetLeavesByRange page
+	maxRangeLeaves int64
 }
 
 // NewTrillianLogServer creates a new RPC server backed by a LogStorageProvider.
 func NewTrillianLogServer(p LogStorageProviderFunc) *TrillianLogServer {
-	return &TrillianLogServer{storageProvider: p}
+	return &TrillianLogServer{storageProvider: p, quotaManager: quota.Unlimited{}, maxRangeLeaves: defaultMaxRangeLeaves}
+}
+
+// NewTrillianLogServerWithWitnesses creates a new RPC server that also accepts cosignatures
+// over signed log roots from the witnesses known to witnessKeys.
+func NewTrillianLogServerWithWitnesses(p LogStorageProviderFunc, witnessKeys WitnessKeyProviderFunc) *TrillianLogServer {
+	return &TrillianLogServer{storageProvider: p, witnessKeys: witnessKeys, quotaManager: quota.Unlimited{}, maxRangeLeaves: defaultMaxRangeLeaves}
+}
+
+// SetQuotaManager makes requests take tokens from m before they can use storage. Writes
+// take a write token for each leaf, and reads a read token for each leaf they ask for or
+// one if they're for a proof or root. Write tokens are returned for leaves that aren't
+// stored or are duplicates, and the sequencer returns the rest as leaves are integrated.
+// Without a quota manager there are no limits.
+func (t *TrillianLogServer) SetQuotaManager(m quota.Manager) {
+	t.quotaManager = m
+}
+
+// SetRootCache makes GetLatestSignedLogRoot answer from c when it holds a fresh root, without
+// using storage or taking a read token. Roots read from storage are added to c.
+func (t *TrillianLogServer) SetRootCache(c *rootcache.Cache) {
+	t.rootCache = c
+}
+
+// SetMaxLeafSizes limits the bytes of value and extra data in the leaves each log accepts,
+// leaves over the limit are rejected. Logs in perTree have their own limit and the rest use
+// defaultMax, where 0 means there's no limit. The limits can be changed while the server is
+// serving requests.
+func (t *TrillianLogServer) SetMaxLeafSizes(defaultMax int, perTree map[int64]int) {
+	t.leafSizesMutex.Lock()
+	defer t.leafSizesMutex.Unlock()
+
+	t.maxLeafSize = defaultMax
+	t.maxLeafSizes = perTree
+}
+
+// SetQueuePriorities sets the range of priorities leaves can be queued with in each log.
+// Priorities outside a log's range are limited to it, and logs that aren't in perTree ignore
+// the priority of requests. They can be changed while the server is serving requests.
+func (t *TrillianLogServer) SetQueuePriorities(perTree map[int64]QueuePriorityRange) {
+	t.queuePrioritiesMutex.Lock()
+	defer t.queuePrioritiesMutex.Unlock()
+
+	t.queuePriorities = perTree
+}
+
+// SetLeafValidator makes every leaf that's queued or added pass v before it's stored. It's
+// only given leaves that are within the size limit and have the fields the log needs.
+func (t *TrillianLogServer) SetLeafValidator(v LeafValidatorFunc) {
+	t.leafValidator = v
+}
+
+// SetMaxRangeLeaves limits the leaves in each GetLeavesByRange page to max, which must be
+// > 0. Clients asking for more get them over several pages.
+func (t *TrillianLogServer) SetMaxRangeLeaves(max int64) {
+	t.maxRangeLeaves = max
 }
 
 // QueueLeaves submits a batch of leaves to the log for later integration into the underlying tree.
+// The response holds the outcome for each leaf. Invalid leaves are rejected individually and
+// don't prevent the rest of the batch from being queued.
 func (t *TrillianLogServer) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest) (*trillian.QueueLeavesResponse, error) {
-	leaves := protosToLeaves(req.Leaves)
+	if len(req.Leaves) == 0 {
+		return &trillian.QueueLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must queue at least one leaf")}, nil
+	}
+
+	priority := t.queuePriorityFor(req.LogId, req.Priority)
+	queue := func(tx storage.LogTX, ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+		for i := range leaves {
+			leaves[i].Priority = priority
+		}
+
+		return tx.QueueLeaves(ctx, leaves)
+	}
+
+	results, err := t.storeLeaves(ctx, req.LogId, req.Leaves, validateLeafProto, queue, "QueueLeaves")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.QueueLeavesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: results}, nil
+}
+
+// AddSequencedLeaves adds a batch of leaves to a pre-ordered log at the positions given by their
+// leaf indices. As with QueueLeaves the response holds the outcome for each leaf.
+func (t *TrillianLogServer) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
+	if len(req.Leaves) == 0 {
+		return &trillian.AddSequencedLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must add at least one leaf")}, nil
+	}
+
+	results, err := t.storeLeaves(ctx, req.LogId, req.Leaves, validateSequencedLeafProto, storage.LogTX.AddSequencedLeaves, "AddSequencedLeaves")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.AddSequencedLeavesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: results}, nil
+}
+
+// storeLeaves passes the leaves that pass validate to store in a single transaction and
+// returns the outcome for each of the leaves, in the same order.
+func (t *TrillianLogServer) storeLeaves(ctx context.Context, logID int64, leafProtos []*trillian.LeafProto, validate func(*trillian.LeafProto, int) *LeafRejection,
+	store func(storage.LogTX, context.Context, []trillian.LogLeaf) ([]*trillian.LogLeaf, error), op string) ([]*trillian.QueuedLeaf, error) {
+	// Leaf hashes are integrated into the tree as they are, so they must be hashes of its size
+	hasher, err := t.treeHasher(logID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*trillian.QueuedLeaf, len(leafProtos))
+	leaves := make([]trillian.LogLeaf, 0, len(leafProtos))
+	// The position in the request of each leaf passed to storage
+	indices := make([]int, 0, len(leafProtos))
+
+	for i, leafProto := range leafProtos {
+		rejection := validate(leafProto, hasher.Size())
+
+		if rejection == nil {
+			if rejection, err = t.checkLeaf(ctx, logID, leafProto); err != nil {
+				return nil, err
+			}
+		}
+
+		if rejection != nil {
+			results[i] = rejection.queuedLeaf()
+			continue
+		}
+
+		leaves = append(leaves, protoToLeaf(leafProto))
+		indices = append(indices, i)
+	}
 
 	if len(leaves) == 0 {
-		return &trillian.QueueLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must queue at least one leaf")}, nil
+		return results, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, logID, quota.Write, len(leaves))
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.QueueLeaves(leaves)
+	existingLeaves, err := store(tx, ctx, leaves)
 
 	if err != nil {
 		tx.Rollback()
+		t.putTokens(logID, len(leaves))
+		return nil, err
+	}
+
+	// Don't commit leaves the client has given up on, it will be told they weren't stored
+	if err := ctx.Err(); err != nil {
+		tx.Rollback()
+		t.putTokens(logID, len(leaves))
 		return nil, err
 	}
 
-	if err := t.commitAndLog(tx, "QueueLeaves"); err != nil {
+	if err := t.commitAndLog(tx, op); err != nil {
+		t.putTokens(logID, len(leaves))
 		return nil, err
 	}
 
-	return &trillian.QueueLeavesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
+	duplicates := 0
+
+	for j, existing := range existingLeaves {
+		results[indices[j]] = queuedLeafResult(existing)
+
+		if existing != nil {
+			duplicates++
+		}
+	}
+
+	// Duplicates won't be sequenced so the tokens taken for them won't come back otherwise
+	if duplicates > 0 {
+		t.putTokens(logID, duplicates)
+	}
+
+	return results, nil
 }
 
 // GetInclusionProof obtains the proof of inclusion in the tree for a leaf that has been sequenced.
@@ -65,7 +258,7 @@ func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian
 		return nil, fmt.Errorf("invalid tree size for proof by hash: %d", req.TreeSize)
 	}
 
-	if req.LeafIndex <= 0 {
+	if req.LeafIndex < 0 {
 		return nil, fmt.Errorf("invalid leaf index: %d", req.LeafIndex)
 	}
 
@@ -73,25 +266,18 @@ func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian
 		return nil, fmt.Errorf("leaf index %d does not exist in tree of size %d", req.LeafIndex, req.TreeSize)
 	}
 
-	// Next we need to make sure the requested tree size corresponds to an STH, so that we
-	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
+	// The snapshot is pinned to the revision of the requested tree size, or of a larger tree if
+	// it doesn't have an STH
+	tx, err := t.prepareSnapshot(ctx, req.LogId, req.TreeSize)
 
 	if err != nil {
 		return nil, err
 	}
 
-	treeRevision, err := tx.GetTreeRevisionAtSize(req.TreeSize)
+	proof, err := t.getInclusionProofForLeafIndexAtRevision(ctx, req.LogId, tx, tx.ReadRevision(), req.TreeSize, req.LeafIndex)
 
 	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
-	proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, req.LeafIndex)
-
-	if err != nil {
-		tx.Rollback()
+		tx.Commit()
 		return nil, err
 	}
 
@@ -102,7 +288,7 @@ func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian
 		return nil, err
 	}
 
-	response := trillian.GetInclusionProofResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof:&proof}
+	response := trillian.GetInclusionProofResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof: &proof}
 
 	return &response, nil
 }
@@ -119,27 +305,22 @@ func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *tr
 		return nil, fmt.Errorf("invalid leaf hash: %v", req.LeafHash)
 	}
 
-	// Next we need to make sure the requested tree size corresponds to an STH, so that we
-	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
+	// The snapshot is pinned to the revision of the requested tree size, or of a larger tree if
+	// it doesn't have an STH
+	tx, err := t.prepareSnapshot(ctx, req.LogId, req.TreeSize)
 
 	if err != nil {
 		return nil, err
 	}
 
-	treeRevision, err := tx.GetTreeRevisionAtSize(req.TreeSize)
-
-	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	treeRevision := tx.ReadRevision()
 
 	// Find the leaf index of the supplied hash
 	leafHashes := []trillian.Hash{req.LeafHash}
-	leaves, err := tx.GetLeavesByHash(leafHashes, req.OrderBySequence)
+	leaves, err := tx.GetLeavesByHash(ctx, leafHashes, req.OrderBySequence)
 
 	if err != nil {
-		tx.Rollback()
+		tx.Commit()
 		return nil, err
 	}
 
@@ -147,10 +328,21 @@ func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *tr
 	proofs := make([]*trillian.ProofProto, 0, len(leaves))
 
 	for _, leaf := range leaves {
-		proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, leaf.SequenceNumber)
+		// Stop building proofs if the client has gone away
+		if err := ctx.Err(); err != nil {
+			tx.Commit()
+			return nil, err
+		}
+
+		// A snapshot of a larger tree also finds leaves that aren't in the tree the proof is for
+		if leaf.SequenceNumber >= req.TreeSize {
+			continue
+		}
+
+		proof, err := t.getInclusionProofForLeafIndexAtRevision(ctx, req.LogId, tx, treeRevision, req.TreeSize, leaf.SequenceNumber)
 
 		if err != nil {
-			tx.Rollback()
+			tx.Commit()
 			return nil, err
 		}
 
@@ -164,7 +356,7 @@ func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *tr
 		return nil, err
 	}
 
-	response := trillian.GetInclusionProofByHashResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof:proofs}
+	response := trillian.GetInclusionProofByHashResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof: proofs}
 
 	return &response, nil
 }
@@ -186,86 +378,163 @@ func (t *TrillianLogServer) GetConsistencyProof(ctx context.Context, req *trilli
 		return nil, fmt.Errorf("second tree size (%d) must be > first tree size (%d)", req.SecondTreeSize, req.FirstTreeSize)
 	}
 
-	nodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(req.FirstTreeSize, req.SecondTreeSize, proofMaxBitLen)
+	// The snapshot is pinned to the revision of the second tree size, or of a larger tree if
+	// it doesn't have an STH, and all the node fetches are done at it. Neither size needs an STH
+	tx, err := t.prepareSnapshot(ctx, req.LogId, req.SecondTreeSize)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	fetches, err := merkle.CalcConsistencyProofNodeFetches(req.FirstTreeSize, req.SecondTreeSize, tx.TreeSize(), proofMaxBitLen)
 
 	if err != nil {
+		tx.Commit()
 		return nil, err
 	}
 
-	// We need to make sure that both the given sizes are actually STHs, though we don't use the
-	// first tree revision in fetches
-	_, err = tx.GetTreeRevisionAtSize(req.FirstTreeSize)
+	proof, err := t.fetchNodesAndBuildProof(ctx, req.LogId, tx, tx.ReadRevision(), 0, fetches)
 
 	if err != nil {
-		tx.Rollback()
+		tx.Commit()
 		return nil, err
 	}
 
-	secondTreeRevision, err := tx.GetTreeRevisionAtSize(req.SecondTreeSize)
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// We have everything we need. Return the proof
+	return &trillian.GetConsistencyProofResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof: &proof}, nil
+}
+
+// GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
+// underlies the log.
+func (t *TrillianLogServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
+	if t.rootCache != nil {
+		if signedRoot, cosignatures, ok := t.rootCache.LogRoot(req.LogId); ok {
+			return &trillian.GetLatestSignedLogRootResponse{
+				Status:        buildStatus(trillian.TrillianApiStatusCode_OK),
+				SignedLogRoot: &signedRoot,
+				Cosignatures:  cosignaturesToProtos(cosignatures),
+			}, nil
+		}
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, 1)
 
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	// Do all the node fetches at the second tree revision, which is what the node ids were calculated
-	// against.
-	proof, err := fetchNodesAndBuildProof(tx, secondTreeRevision, 0, nodeIDs)
+	signedRoot, err := tx.LatestSignedLogRoot(ctx)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	err = tx.Commit()
+	// A log that hasn't signed a root yet has no cosignatures either
+	cosignatures := []trillian.Cosignature{}
 
-	if err != nil {
+	if signedRoot.Signature != nil {
+		if cosignatures, err = tx.GetCosignatures(ctx, signedRoot.TimestampNanos); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := t.commitAndLog(tx, "GetLatestSignedLogRoot"); err != nil {
 		return nil, err
 	}
 
-	// We have everything we need. Return the proof
-	return &trillian.GetConsistencyProofResponse{Status:buildStatus(trillian.TrillianApiStatusCode_OK), Proof:&proof}, nil
+	if t.rootCache != nil {
+		t.rootCache.SetLogRoot(req.LogId, signedRoot, cosignatures)
+	}
+
+	return &trillian.GetLatestSignedLogRootResponse{
+		Status:        buildStatus(trillian.TrillianApiStatusCode_OK),
+		SignedLogRoot: &signedRoot,
+		Cosignatures:  cosignaturesToProtos(cosignatures),
+	}, nil
 }
 
-// GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
-// underlies the log.
-func (t *TrillianLogServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
-	tx, err := t.prepareStorageTx(req.LogId)
+// AddCosignature stores a witness's cosignature over one of the log's signed roots. It's
+// only kept if it verifies with the witness's public key against the root the log signed
+// at the requested timestamp.
+func (t *TrillianLogServer) AddCosignature(ctx context.Context, req *trillian.AddCosignatureRequest) (*trillian.AddCosignatureResponse, error) {
+	cosignature := req.Cosignature
 
-	if err != nil {
+	if cosignature == nil || len(cosignature.WitnessId) == 0 || cosignature.Signature == nil {
+		return &trillian.AddCosignatureResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Cosignature must have a witness id and signature")}, nil
+	}
+
+	if t.witnessKeys == nil {
+		return &trillian.AddCosignatureResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Cosignatures are not accepted by this server")}, nil
+	}
+
+	key, err := t.witnessKeys(req.LogId, cosignature.WitnessId)
+
+	if err == ErrUnknownWitness {
+		return &trillian.AddCosignatureResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, fmt.Sprintf("Unknown witness: %s", cosignature.WitnessId))}, nil
+	} else if err != nil {
 		return nil, err
 	}
 
-	signedRoot, err := tx.LatestSignedLogRoot()
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Write, 1)
 
 	if err != nil {
+		return nil, err
+	}
+
+	// A cosignature doesn't add a leaf, so the sequencer will never return its write token
+	// to buckets that are refilled by sequencing
+	defer t.putTokens(req.LogId, 1)
+
+	root, err := tx.GetSignedLogRoot(ctx, req.RootTimestampNanos)
+
+	if err == storage.ErrLogRootNotFound {
+		tx.Rollback()
+		return &trillian.AddCosignatureResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, fmt.Sprintf("No signed log root at timestamp %d", req.RootTimestampNanos))}, nil
+	} else if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	if err := t.commitAndLog(tx, "GetLatestSignedLogRoot"); err != nil {
+	if err := crypto.VerifyCosignature(key, root, *cosignature); err != nil {
+		tx.Rollback()
+		return &trillian.AddCosignatureResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, fmt.Sprintf("Cosignature from %s did not verify: %v", cosignature.WitnessId, err))}, nil
+	}
+
+	if err := tx.AddCosignature(ctx, req.RootTimestampNanos, *cosignature); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := t.commitAndLog(tx, "AddCosignature"); err != nil {
 		return nil, err
 	}
 
-	return &trillian.GetLatestSignedLogRootResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), SignedLogRoot: &signedRoot}, nil
+	// The cached root, if it's the one cosigned, no longer has all its cosignatures
+	if t.rootCache != nil {
+		t.rootCache.InvalidateLog(req.LogId)
+	}
+
+	return &trillian.AddCosignatureResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
 }
 
 // GetSequencedLeafCount returns the number of leaves that have been integrated into the Merkle
 // Tree. This can be zero for a log containing no entries.
 func (t *TrillianLogServer) GetSequencedLeafCount(ctx context.Context, req *trillian.GetSequencedLeafCountRequest) (*trillian.GetSequencedLeafCountResponse, error) {
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, 1)
 
 	if err != nil {
 		return nil, err
 	}
 
-	leafCount, err := tx.GetSequencedLeafCount()
+	leafCount, err := tx.GetSequencedLeafCount(ctx)
 
 	if err != nil {
 		tx.Rollback()
@@ -276,7 +545,7 @@ func (t *TrillianLogServer) GetSequencedLeafCount(ctx context.Context, req *tril
 		return nil, err
 	}
 
-	return &trillian.GetSequencedLeafCountResponse{Status:buildStatus(trillian.TrillianApiStatusCode_OK), LeafCount:leafCount}, nil
+	return &trillian.GetSequencedLeafCountResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), LeafCount: leafCount}, nil
 }
 
 // GetLeavesByIndex obtains one or more leaves based on their sequence number within the
@@ -288,13 +557,13 @@ func (t *TrillianLogServer) GetLeavesByIndex(ctx context.Context, req *trillian.
 		return &trillian.GetLeavesByIndexResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid -ve leaf index in request")}, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, len(req.LeafIndex))
 
 	if err != nil {
 		return nil, err
 	}
 
-	leaves, err := tx.GetLeavesByIndex(req.LeafIndex)
+	leaves, err := tx.GetLeavesByIndex(ctx, req.LeafIndex)
 
 	if err != nil {
 		tx.Rollback()
@@ -310,6 +579,176 @@ func (t *TrillianLogServer) GetLeavesByIndex(ctx context.Context, req *trillian.
 	return &trillian.GetLeavesByIndexResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
 }
 
+// GetLeavesByRange returns a page of the leaves in a range, in index order. The root and the
+// leaves of a page are read in one transaction, so a page only has leaves the root covers.
+// The range is fixed by the first request, at no more than the tree size then, and the
+// rest of its pages are asked for with the token returned with the page before.
+func (t *TrillianLogServer) GetLeavesByRange(ctx context.Context, req *trillian.GetLeavesByRangeRequest) (*trillian.GetLeavesByRangeResponse, error) {
+	page := leafPageToken{logID: req.LogId, next: req.StartIndex}
+	continued := len(req.PageToken) > 0
+
+	if continued {
+		var err error
+		if page, err = decodeLeafPageToken(req.PageToken, req.LogId); err != nil {
+			return &trillian.GetLeavesByRangeResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid page token in request")}, nil
+		}
+	} else if req.StartIndex < 0 || req.Count < 0 {
+		return &trillian.GetLeavesByRangeResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid -ve leaf index or count in request")}, nil
+	}
+
+	// Tokens are taken for the most leaves the page can have, before the root is read
+	want := t.maxRangeLeaves
+	if continued && page.end-page.next < want {
+		want = page.end - page.next
+	} else if !continued && req.Count > 0 && req.Count < want {
+		want = req.Count
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, int(want))
+
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if !continued {
+		page.end = root.TreeSize
+		if req.Count > 0 && req.Count < page.end-page.next {
+			page.end = page.next + req.Count
+		}
+	} else if page.end > root.TreeSize {
+		// Trees don't shrink so this token wasn't made by the server
+		tx.Rollback()
+		return &trillian.GetLeavesByRangeResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid page token in request")}, nil
+	}
+
+	count := page.end - page.next
+	if count > want {
+		count = want
+	}
+
+	var leaves []trillian.LogLeaf
+
+	if count > 0 {
+		if leaves, err = tx.GetLeavesByRange(ctx, page.next, count); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if got := int64(len(leaves)); got != count {
+			tx.Rollback()
+			return nil, fmt.Errorf("expected %d leaves from index %d but got %d", count, page.next, got)
+		}
+	}
+
+	if err := t.commitAndLog(tx, "GetLeavesByRange"); err != nil {
+		return nil, err
+	}
+
+	resp := &trillian.GetLeavesByRangeResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leavesToProtos(leaves)}
+
+	if page.next += count; count > 0 && page.next < page.end {
+		resp.NextPageToken = page.encode()
+	}
+
+	return resp, nil
+}
+
+// StreamLeaves sends a contiguous range of leaves to the client in chunks. Only leaves covered
+// by the latest signed log root are sent. Each chunk is read in a separate transaction so a
+// long running stream doesn't keep one open, and sending blocks if the client isn't keeping
+// up so we don't read ahead of it.
+func (t *TrillianLogServer) StreamLeaves(req *trillian.StreamLeavesRequest, stream trillian.TrillianLog_StreamLeavesServer) error {
+	if req.StartIndex < 0 || req.Count < 0 {
+		return stream.Send(&trillian.StreamLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid -ve leaf index or count in request")})
+	}
+
+	ctx := logging.WithTreeID(stream.Context(), req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, 1)
+
+	if err != nil {
+		return err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := t.commitAndLog(tx, "StreamLeaves"); err != nil {
+		return err
+	}
+
+	end := root.TreeSize
+	if req.Count > 0 && req.StartIndex+req.Count < end {
+		end = req.StartIndex + req.Count
+	}
+
+	chunkSize := int64(req.ChunkSize)
+	if chunkSize <= 0 || chunkSize > maxStreamLeavesChunkSize {
+		chunkSize = maxStreamLeavesChunkSize
+	}
+
+	// Each chunk is read with a read token per leaf, so a long stream is charged as it's sent
+	// and cut off once the client's quota runs out rather than for the price of one request
+	for start := req.StartIndex; start < end; start += chunkSize {
+		// Stop if the client has gone away
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if start+chunkSize > end {
+			chunkSize = end - start
+		}
+
+		leaves, err := t.getLeavesByRange(ctx, req.LogId, start, chunkSize)
+
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(&trillian.StreamLeavesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leavesToProtos(leaves)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLeavesByRange reads a range of leaves that must all have been sequenced in its own transaction
+func (t *TrillianLogServer) getLeavesByRange(ctx context.Context, logID, start, count int64) ([]trillian.LogLeaf, error) {
+	tx, err := t.prepareStorageTx(ctx, logID, quota.Read, int(count))
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := tx.GetLeavesByRange(ctx, start, count)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := t.commitAndLog(tx, "StreamLeaves"); err != nil {
+		return nil, err
+	}
+
+	if got, want := int64(len(leaves)), count; got != want {
+		return nil, fmt.Errorf("expected %d leaves from index %d but got %d", want, start, got)
+	}
+
+	return leaves, nil
+}
+
 // GetLeavesByIndex obtains one or more leaves based on their tree hash. It is not possible
 // to fetch leaves that have been queued but not yet integrated. Logs may accept duplicate
 // entries so this may return more results than the number of hashes in the request.
@@ -318,13 +757,13 @@ func (t *TrillianLogServer) GetLeavesByHash(ctx context.Context, req *trillian.G
 		return &trillian.GetLeavesByHashResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must supply at least one hash and none must be empty")}, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, len(req.LeafHash))
 
 	if err != nil {
 		return nil, err
 	}
 
-	leaves, err := tx.GetLeavesByHash(bytesToHash(req.LeafHash), req.OrderBySequence)
+	leaves, err := tx.GetLeavesByHash(ctx, bytesToHash(req.LeafHash), req.OrderBySequence)
 
 	if err != nil {
 		tx.Rollback()
@@ -340,6 +779,36 @@ func (t *TrillianLogServer) GetLeavesByHash(ctx context.Context, req *trillian.G
 	return &trillian.GetLeavesByHashResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
 }
 
+// GetLeavesByIdentityHash obtains the integrated leaves an application queued with any of
+// the identity hashes in the request. Several leaves can share an identity hash, e.g. when
+// they're versions of the same entry, so this may return more results than hashes.
+func (t *TrillianLogServer) GetLeavesByIdentityHash(ctx context.Context, req *trillian.GetLeavesByIdentityHashRequest) (*trillian.GetLeavesByIdentityHashResponse, error) {
+	if len(req.LeafIdentityHash) == 0 || !validateLeafHashes(req.LeafIdentityHash) {
+		return &trillian.GetLeavesByIdentityHashResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must supply at least one identity hash and none must be empty")}, nil
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId, quota.Read, len(req.LeafIdentityHash))
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := tx.GetLeavesByIdentityHash(ctx, bytesToHash(req.LeafIdentityHash), req.OrderBySequence)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	leafProtos := leavesToProtos(leaves)
+
+	if err := t.commitAndLog(tx, "GetLeavesByIdentityHash"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetLeavesByIdentityHashResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
+}
+
 // GetEntryAndProof returns both a Merkle Leaf entry and an inclusion proof for a given index
 // and tree size.
 func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
@@ -356,38 +825,31 @@ func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.
 		return nil, fmt.Errorf("invalid params for GetEntryAndProof index: %d exceeds tree size: %d", req.LeafIndex, req.TreeSize)
 	}
 
-	// Next we need to make sure the requested tree size corresponds to an STH, so that we
-	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
-
-	if err != nil {
-		return nil, err
-	}
-
-	treeRevision, err := tx.GetTreeRevisionAtSize(req.TreeSize)
+	// The snapshot is pinned to the revision of the requested tree size, or of a larger tree if
+	// it doesn't have an STH
+	tx, err := t.prepareSnapshot(ctx, req.LogId, req.TreeSize)
 
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, req.LeafIndex)
+	proof, err := t.getInclusionProofForLeafIndexAtRevision(ctx, req.LogId, tx, tx.ReadRevision(), req.TreeSize, req.LeafIndex)
 
 	if err != nil {
-		tx.Rollback()
+		tx.Commit()
 		return nil, err
 	}
 
 	// We also need the leaf entry
-	leaves, err := tx.GetLeavesByIndex([]int64{req.LeafIndex})
+	leaves, err := tx.GetLeavesByIndex(ctx, []int64{req.LeafIndex})
 
 	if err != nil {
-		tx.Rollback()
+		tx.Commit()
 		return nil, err
 	}
 
 	if len(leaves) != 1 {
-		tx.Rollback()
+		tx.Commit()
 		return nil, fmt.Errorf("expected one leaf from storage but got: %d", len(leaves))
 	}
 
@@ -402,26 +864,144 @@ func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.
 	// Work is complete, we have everything we need for the response
 	return &trillian.GetEntryAndProofResponse{
 		Status: buildStatus(trillian.TrillianApiStatusCode_OK),
-		Proof: &proof,
-		Leaf: leafProtos[0]}, nil
+		Proof:  &proof,
+		Leaf:   leafProtos[0]}, nil
 }
 
-func (t *TrillianLogServer) prepareStorageTx(treeID int64) (storage.LogTX, error) {
+// prepareStorageTx begins a transaction on the storage for a tree, unless the request it's
+// for has already been cancelled or passed its deadline, or it can't have numTokens of
+// quota of the given kind. Write tokens are returned if the transaction can't be started.
+func (t *TrillianLogServer) prepareStorageTx(ctx context.Context, treeID int64, kind quota.Kind, numTokens int) (storage.LogTX, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := t.getTokens(ctx, treeID, kind, numTokens); err != nil {
+		return nil, err
+	}
+
 	s, err := t.storageProvider(treeID)
 
 	if err != nil {
+		t.putUnusedTokens(treeID, kind, numTokens)
 		return nil, err
 	}
 
-	tx, err := s.Begin()
+	tx, err := s.Begin(ctx)
 
 	if err != nil {
-		return nil, err
+		t.putUnusedTokens(treeID, kind, numTokens)
+		return nil, storageStatus(err)
 	}
 
 	return tx, err
 }
 
+// prepareSnapshot starts a read-only transaction on the storage for a tree, pinned to the
+// revision of the first tree of at least treeSize leaves with an STH, with the same checks
+// as prepareStorageTx for a read. Proofs are served from snapshots so they see a coherent
+// tree and don't hold up the sequencer. Snapshots can only be committed, which is also how
+// they're released if the request fails.
+func (t *TrillianLogServer) prepareSnapshot(ctx context.Context, treeID, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := t.getTokens(ctx, treeID, quota.Read, 1); err != nil {
+		return nil, err
+	}
+
+	s, err := t.storageProvider(treeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.SnapshotForTree(ctx, treeSize)
+
+	if err != nil {
+		return nil, storageStatus(err)
+	}
+
+	return tx, nil
+}
+
+// storageStatus tells clients to back off with a RESOURCE_EXHAUSTED status if storage is
+// shedding load because its database is unhealthy
+func storageStatus(err error) error {
+	if err == storage.ErrUnhealthy {
+		return grpc.Errorf(codes.ResourceExhausted, "%v", err)
+	}
+
+	return err
+}
+
+// getTokens takes tokens for a request from the global, tree and user buckets. Clients
+// that have run out of quota are told to back off with a RESOURCE_EXHAUSTED status.
+func (t *TrillianLogServer) getTokens(ctx context.Context, treeID int64, kind quota.Kind, numTokens int) error {
+	specs := []quota.Spec{
+		{Group: quota.Global, Kind: kind},
+		{Group: quota.Tree, Kind: kind, TreeID: treeID},
+		{Group: quota.User, Kind: kind, User: quotaUser(ctx)},
+	}
+
+	err := t.quotaManager.GetTokens(ctx, numTokens, specs)
+
+	switch {
+	case err == quota.ErrExhausted:
+		return grpc.Errorf(codes.ResourceExhausted, "%v quota exhausted for tree %d", kind, treeID)
+	case err != nil:
+		logging.Warningf(ctx, "Failed to get %d %v tokens for tree %d: %v", numTokens, kind, treeID, err)
+	}
+
+	return err
+}
+
+// putTokens returns write tokens for leaves that were never stored, or won't be sequenced
+// because they're duplicates
+func (t *TrillianLogServer) putTokens(treeID int64, numTokens int) {
+	specs := []quota.Spec{
+		{Group: quota.Global, Kind: quota.Write},
+		{Group: quota.Tree, Kind: quota.Write, TreeID: treeID},
+	}
+
+	// The request may have been abandoned but its tokens should still be returned
+	if err := t.quotaManager.PutTokens(context.Background(), numTokens, specs); err != nil {
+		glog.Warningf("Failed to return %d write tokens for tree %d: %v", numTokens, treeID, err)
+	}
+}
+
+// putUnusedTokens returns the tokens taken for a request that never got to use storage.
+// Only write buckets can be refilled by sequencing, other tokens are left to be refilled
+// over time.
+func (t *TrillianLogServer) putUnusedTokens(treeID int64, kind quota.Kind, numTokens int) {
+	if kind == quota.Write {
+		t.putTokens(treeID, numTokens)
+	}
+}
+
+// quotaUser returns the user a request's quota is charged to. Clients that presented a
+// certificate are identified by it, others by the host they connect from.
+func quotaUser(ctx context.Context) string {
+	if identity := auth.Identity(ctx); len(identity) > 0 {
+		return identity
+	}
+
+	p, ok := peer.FromContext(ctx)
+
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
 func buildStatus(code trillian.TrillianApiStatusCode) *trillian.TrillianApiStatus {
 	return &trillian.TrillianApiStatus{StatusCode: code}
 }
@@ -443,23 +1023,125 @@ func (t *TrillianLogServer) commitAndLog(tx storage.LogTX, op string) error {
 	return err
 }
 
-func protoToLeaf(proto *trillian.LeafProto) trillian.LogLeaf {
-	return trillian.LogLeaf{SequenceNumber: proto.LeafIndex, Leaf: trillian.Leaf{LeafHash: proto.LeafHash, LeafValue: proto.LeafData, ExtraData: proto.ExtraData}}
+// validateLeafProto returns why a leaf submitted for queueing to a tree with hashes of
+// hashSize bytes is invalid, or nil if it can be queued.
+func validateLeafProto(proto *trillian.LeafProto, hashSize int) *LeafRejection {
+	if proto == nil {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_MISSING_FIELD, Reason: "leaf is missing"}
+	}
+
+	if len(proto.LeafHash) == 0 {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_MISSING_FIELD, Field: "leaf_hash", Reason: "leaf hash is required"}
+	}
+
+	if len(proto.LeafHash) != hashSize {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_INVALID_FIELD, Field: "leaf_hash", Reason: fmt.Sprintf("leaf hash must be %d bytes, got %d", hashSize, len(proto.LeafHash))}
+	}
+
+	if proto.NotBeforeNanos < 0 {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_INVALID_FIELD, Field: "not_before_nanos", Reason: fmt.Sprintf("not before time must be >= 0, got %d", proto.NotBeforeNanos)}
+	}
+
+	return nil
 }
 
-func protosToLeaves(protos []*trillian.LeafProto) []trillian.LogLeaf {
-	leaves := make([]trillian.LogLeaf, 0, len(protos))
+// validateSequencedLeafProto is like validateLeafProto but also checks the leaf index the
+// application assigned.
+func validateSequencedLeafProto(proto *trillian.LeafProto, hashSize int) *LeafRejection {
+	if rejection := validateLeafProto(proto, hashSize); rejection != nil {
+		return rejection
+	}
 
-	for _, proto := range protos {
-		leaves = append(leaves, protoToLeaf(proto))
+	if proto.LeafIndex < 0 {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_INVALID_FIELD, Field: "leaf_index", Reason: fmt.Sprintf("leaf index must be >= 0, got %d", proto.LeafIndex)}
 	}
 
-	return leaves
+	// Pre-ordered leaves are integrated in order, so none can be held back
+	if proto.NotBeforeNanos != 0 {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_INVALID_FIELD, Field: "not_before_nanos", Reason: "not before time can't be set for sequenced leaves"}
+	}
+
+	return nil
+}
+
+// maxLeafSizeFor returns the most bytes of value and extra data a leaf of a log can have,
+// 0 if there's no limit
+func (t *TrillianLogServer) maxLeafSizeFor(logID int64) int {
+	t.leafSizesMutex.RLock()
+	defer t.leafSizesMutex.RUnlock()
+
+	if maxSize, ok := t.maxLeafSizes[logID]; ok {
+		return maxSize
+	}
+
+	return t.maxLeafSize
+}
+
+// queuePriorityFor returns the priority leaves a client asked to queue with priority are
+// queued with in a log
+func (t *TrillianLogServer) queuePriorityFor(logID int64, priority int32) int32 {
+	t.queuePrioritiesMutex.RLock()
+	defer t.queuePrioritiesMutex.RUnlock()
+
+	return t.queuePriorities[logID].clamp(priority)
+}
+
+// checkLeaf applies the log's size limit and validator to a leaf that has the fields the
+// log needs. It returns why the leaf was rejected, or nil if it can be stored, and an error
+// if the validator failed.
+func (t *TrillianLogServer) checkLeaf(ctx context.Context, logID int64, proto *trillian.LeafProto) (*LeafRejection, error) {
+	maxSize := t.maxLeafSizeFor(logID)
+
+	if size := len(proto.LeafData) + len(proto.ExtraData); maxSize > 0 && size > maxSize {
+		return &LeafRejection{Code: trillian.LeafRejectionCode_TOO_LARGE, Reason: fmt.Sprintf("leaf is %d bytes, the log accepts at most %d", size, maxSize), MaxLeafSize: int64(maxSize)}, nil
+	}
+
+	if t.leafValidator == nil {
+		return nil, nil
+	}
+
+	err := t.leafValidator(ctx, logID, proto)
+
+	if rejection, ok := err.(*LeafRejection); ok {
+		r := *rejection
+
+		if r.Code == trillian.LeafRejectionCode_UNKNOWN_LEAF_REJECTION_CODE {
+			r.Code = trillian.LeafRejectionCode_FAILED_VALIDATION
+		}
+
+		return &r, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to validate leaf for log %d: %v", logID, err)
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// queuedLeafResult builds the result for a leaf passed to storage. existing is the leaf
+// already in the log if the submitted leaf was a duplicate, otherwise nil.
+func queuedLeafResult(existing *trillian.LogLeaf) *trillian.QueuedLeaf {
+	if existing == nil {
+		return &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_QUEUED}
+	}
+
+	timestamp := existing.SignedEntryTimestamp
+
+	return &trillian.QueuedLeaf{Status: trillian.QueuedLeafStatus_DUPLICATE, ExistingLeaf: leafToProto(*existing), ExistingTimestamp: &timestamp}
+}
+
+// unsignedTimestamp is given to the leaves passed to storage, which rejects leaves without a
+// timestamp signature.
+// TODO: Sign the timestamps with the log's key and set the time
+var unsignedTimestamp = trillian.SignedEntryTimestamp{Signature: &trillian.DigitallySigned{Signature: []byte("unsigned")}}
+
+func protoToLeaf(proto *trillian.LeafProto) trillian.LogLeaf {
+	return trillian.LogLeaf{SequenceNumber: proto.LeafIndex, Leaf: trillian.Leaf{LeafHash: proto.LeafHash, LeafValue: proto.LeafData, ExtraData: proto.ExtraData, LeafIdentityHash: proto.LeafIdentityHash}, SignedEntryTimestamp: unsignedTimestamp, NotBeforeNanos: proto.NotBeforeNanos}
 }
 
 // TODO: Fill in the log leaf specific fields when we've implemented signed timestamps
 func leafToProto(leaf trillian.LogLeaf) *trillian.LeafProto {
-	return &trillian.LeafProto{LeafIndex: leaf.SequenceNumber, LeafHash: leaf.LeafHash, LeafData: leaf.LeafValue, ExtraData: leaf.ExtraData}
+	return &trillian.LeafProto{LeafIndex: leaf.SequenceNumber, LeafHash: leaf.LeafHash, LeafData: leaf.LeafValue, ExtraData: leaf.ExtraData, LeafIdentityHash: leaf.LeafIdentityHash}
 }
 
 func leavesToProtos(leaves []trillian.LogLeaf) []*trillian.LeafProto {
@@ -472,6 +1154,16 @@ func leavesToProtos(leaves []trillian.LogLeaf) []*trillian.LeafProto {
 	return protos
 }
 
+func cosignaturesToProtos(cosignatures []trillian.Cosignature) []*trillian.Cosignature {
+	protos := make([]*trillian.Cosignature, 0, len(cosignatures))
+
+	for i := range cosignatures {
+		protos = append(protos, &cosignatures[i])
+	}
+
+	return protos
+}
+
 // Don't think we can do this with type assertions, maybe we can
 func bytesToHash(inputs [][]byte) []trillian.Hash {
 	hashes := make([]trillian.Hash, len(inputs), len(inputs))
@@ -507,7 +1199,18 @@ func validateLeafHashes(leafHashes [][]byte) bool {
 // getInclusionProofForLeafIndexAtRevision is used by multiple handlers. It does the storage fetching
 // and makes additional checks on the returned proof. Returns a ProofProto suitable for inclusion in
 // an RPC response
-func getInclusionProofForLeafIndexAtRevision(tx storage.LogTX, treeRevision, treeSize, leafIndex int64) (trillian.ProofProto, error) {
+func (t *TrillianLogServer) getInclusionProofForLeafIndexAtRevision(ctx context.Context, treeID int64, tx storage.ReadOnlyLogTreeTX, treeRevision, treeSize, leafIndex int64) (trillian.ProofProto, error) {
+	if tx.TreeSize() != treeSize {
+		// Some of the nodes of the smaller tree may have to be recomputed from the ones stored
+		fetches, err := merkle.CalcInclusionProofNodeFetches(treeSize, leafIndex, tx.TreeSize(), proofMaxBitLen)
+
+		if err != nil {
+			return trillian.ProofProto{}, err
+		}
+
+		return t.fetchNodesAndBuildProof(ctx, treeID, tx, treeRevision, leafIndex, fetches)
+	}
+
 	// We have the tree size and leaf index so we know the nodes that we need to serve the proof
 	// TODO(Martin2112): Not sure about hardcoding maxBitLen here
 	proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, proofMaxBitLen)
@@ -516,18 +1219,71 @@ func getInclusionProofForLeafIndexAtRevision(tx storage.LogTX, treeRevision, tre
 		return trillian.ProofProto{}, err
 	}
 
-	return fetchNodesAndBuildProof(tx, treeRevision, leafIndex, proofNodeIDs)
+	leafID, err := storage.NewNodeIDForTreeCoords(0, leafIndex, proofMaxBitLen)
+
+	if err != nil {
+		return trillian.ProofProto{}, err
+	}
+
+	// The whole path is read in one go rather than node by node
+	pathNodes, err := tx.GetProofPath(ctx, treeRevision, leafID, treeSize)
+
+	if err != nil {
+		return trillian.ProofProto{}, err
+	}
+
+	return buildProof(leafIndex, proofNodeIDs, pathNodes)
 }
 
-// fetchNodesAndBuildProof is used by both inclusion and consistency proofs. It fetches the nodes
-// from storage and converts them into the proof proto that will be returned to the client.
-func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, proofNodeIDs []storage.NodeID) (trillian.ProofProto, error) {
-	proofNodes, err := tx.GetMerkleNodes(treeRevision, proofNodeIDs)
+// fetchNodesAndBuildProof is used by consistency proofs, and inclusion proofs for a smaller tree
+// than the snapshot's. It fetches the nodes from storage, recomputes any that need to be rehashed
+// and converts them into the proof proto that will be returned to the client.
+func (t *TrillianLogServer) fetchNodesAndBuildProof(ctx context.Context, treeID int64, tx storage.NodeReader, treeRevision, leafIndex int64, fetches []merkle.NodeFetch) (trillian.ProofProto, error) {
+	proofNodes, err := tx.GetMerkleNodes(ctx, treeRevision, merkle.NodeFetchIDs(fetches))
 
 	if err != nil {
 		return trillian.ProofProto{}, err
 	}
 
+	proofNodeIDs := make([]storage.NodeID, 0, len(fetches))
+	rehash := false
+
+	for _, f := range fetches {
+		proofNodeIDs = append(proofNodeIDs, f.NodeID)
+		rehash = rehash || len(f.Rehash) > 0
+	}
+
+	if rehash {
+		hasher, err := t.treeHasher(treeID)
+
+		if err != nil {
+			return trillian.ProofProto{}, err
+		}
+
+		proofNodes, err = merkle.RehashProofNodes(hasher, fetches, proofNodes)
+
+		if err != nil {
+			return trillian.ProofProto{}, err
+		}
+	}
+
+	return buildProof(leafIndex, proofNodeIDs, proofNodes)
+}
+
+// treeHasher returns the hasher of a tree, which recomputes proof nodes for past sizes of it
+func (t *TrillianLogServer) treeHasher(treeID int64) (merkle.TreeHasher, error) {
+	s, err := t.storageProvider(treeID)
+
+	if err != nil {
+		return merkle.TreeHasher{}, err
+	}
+
+	return merkle.NewTreeHasher(s.HashAlgorithm())
+}
+
+// buildProof checks that the nodes read from storage are the ones needed for a proof and converts
+// them into the proof proto that will be returned to the client.
+func buildProof(leafIndex int64, proofNodeIDs []storage.NodeID, proofNodes []storage.Node) (trillian.ProofProto, error) {
 	if len(proofNodes) != len(proofNodeIDs) {
 		return trillian.ProofProto{}, fmt.Errorf("expected %d nodes in proof but got %d", len(proofNodeIDs), len(proofNodes))
 	}
@@ -540,14 +1296,19 @@ func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, pr
 			return trillian.ProofProto{}, fmt.Errorf("expected node %v at proof pos %d but got %v", proofNodeIDs[i], i, node.NodeID)
 		}
 
+		// nodes that weren't in storage come back without a hash
+		if node.Hash == nil {
+			return trillian.ProofProto{}, fmt.Errorf("missing node %v at proof pos %d", node.NodeID, i)
+		}
+
 		idBytes, err := proto.Marshal(node.NodeID.AsProto())
 
 		if err != nil {
 			return trillian.ProofProto{}, err
 		}
 
-		proof = append(proof, &trillian.NodeProto{NodeId:idBytes, NodeHash:node.Hash, NodeRevision:node.NodeRevision})
+		proof = append(proof, &trillian.NodeProto{NodeId: idBytes, NodeHash: node.Hash, NodeRevision: node.NodeRevision})
 	}
 
-	return trillian.ProofProto{LeafIndex:leafIndex, ProofNode:proof}, nil
-}
\ No newline at end of file
+	return trillian.ProofProto{LeafIndex: leafIndex, ProofNode: proof}, nil
+}
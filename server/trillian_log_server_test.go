@@ -1,17 +1,36 @@
 package server
 
 import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"net"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/rootcache"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 var logId1 = int64(1)
@@ -21,10 +40,14 @@ var leaf0Minus2Request = trillian.GetLeavesByIndexRequest{LogId: logId1, LeafInd
 var leaf03Request = trillian.GetLeavesByIndexRequest{LogId: logId1, LeafIndex: []int64{0, 3}}
 var leaf0Log2Request = trillian.GetLeavesByIndexRequest{LogId: logId2, LeafIndex: []int64{0}}
 
-var leaf1 = trillian.LogLeaf{SequenceNumber: 1, Leaf: trillian.Leaf{LeafHash: []byte("hash"), LeafValue: []byte("value"), ExtraData: []byte("extra")}}
-var leaf3 = trillian.LogLeaf{SequenceNumber: 3, Leaf: trillian.Leaf{LeafHash: []byte("hash3"), LeafValue: []byte("value3"), ExtraData: []byte("extra3")}}
-var expectedLeaf1 = trillian.LeafProto{LeafIndex: 1, LeafHash: []byte("hash"), LeafData: []byte("value"), ExtraData: []byte("extra")}
-var expectedLeaf3 = trillian.LeafProto{LeafIndex: 3, LeafHash: []byte("hash3"), LeafData: []byte("value3"), ExtraData: []byte("extra3")}
+// Leaf hashes must be the size of the tree's hashes, the trees in these tests use SHA-256
+var leafHash1 = trillian.NewSHA256().Digest([]byte("hash"))
+var leafHash3 = trillian.NewSHA256().Digest([]byte("hash3"))
+
+var leaf1 = trillian.LogLeaf{SequenceNumber: 1, Leaf: trillian.Leaf{LeafHash: leafHash1, LeafValue: []byte("value"), ExtraData: []byte("extra")}, SignedEntryTimestamp: unsignedTimestamp}
+var leaf3 = trillian.LogLeaf{SequenceNumber: 3, Leaf: trillian.Leaf{LeafHash: leafHash3, LeafValue: []byte("value3"), ExtraData: []byte("extra3")}, SignedEntryTimestamp: unsignedTimestamp}
+var expectedLeaf1 = trillian.LeafProto{LeafIndex: 1, LeafHash: leafHash1, LeafData: []byte("value"), ExtraData: []byte("extra")}
+var expectedLeaf3 = trillian.LeafProto{LeafIndex: 3, LeafHash: leafHash3, LeafData: []byte("value3"), ExtraData: []byte("extra3")}
 
 var queueRequest0 = trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}}
 var queueRequest0Log2 = trillian.QueueLeavesRequest{LogId: logId2, Leaves: []*trillian.LeafProto{&expectedLeaf1}}
@@ -37,6 +60,8 @@ var signedRoot1 = trillian.SignedLogRoot{TimestampNanos: 987654321, RootHash: []
 var getByHashRequest1 = trillian.GetLeavesByHashRequest{LogId: logId1, LeafHash: [][]byte{[]byte("test"), []byte("data")}}
 var getByHashRequestBadHash = trillian.GetLeavesByHashRequest{LogId: logId1, LeafHash: [][]byte{[]byte(""), []byte("data")}}
 var getByHashRequest2 = trillian.GetLeavesByHashRequest{LogId: logId2, LeafHash: [][]byte{[]byte("test"), []byte("data")}}
+var getByIdentityHashRequest1 = trillian.GetLeavesByIdentityHashRequest{LogId: logId1, LeafIdentityHash: [][]byte{[]byte("identity")}, OrderBySequence: true}
+var getByIdentityHashRequestBadHash = trillian.GetLeavesByIdentityHashRequest{LogId: logId1, LeafIdentityHash: [][]byte{[]byte("identity"), []byte("")}}
 
 var getInclusionProofByHashRequestBadTreeSize = trillian.GetInclusionProofByHashRequest{LogId: logId1, TreeSize: -50, LeafHash: []byte("data")}
 var getInclusionProofByHashRequestBadHash = trillian.GetInclusionProofByHashRequest{LogId: logId1, TreeSize: 50, LeafHash: []byte{}}
@@ -66,8 +91,16 @@ var nodeIdsInclusionSize7Index2 = []storage.NodeID{
 	testonly.MustCreateNodeIDForTreeCoords(1, 0, 64),
 	testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
 
+var leafIdSize7Index2 = testonly.MustCreateNodeIDForTreeCoords(0, 2, 64)
+
 var nodeIdsConsistencySize4ToSize7 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
 
+// Once the tree has 8 leaves the last node of size 7 at level 2 has been overwritten, so it's
+// recomputed from the nodes over leaves 4 and 5, and leaf 6
+var nodeIdsRehashSize7InSize8 = []storage.NodeID{
+	testonly.MustCreateNodeIDForTreeCoords(1, 2, 64),
+	testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)}
+
 func mockStorageProviderfunc(mockStorage storage.LogStorage) LogStorageProviderFunc {
 	return func(id int64) (storage.LogStorage, error) {
 		if id == 1 {
@@ -100,7 +133,7 @@ func TestGetLeavesByIndexBeginFailsCausesError(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, errors.New("TX"))
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
@@ -117,7 +150,7 @@ func TestGetLeavesByIndexStorageError(t *testing.T) {
 
 	test := newParameterizedTest(ctrl, "GetLeavesByIndex",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetLeavesByIndex([]int64{0}).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
+			t.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{0}).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
 		},
 		func(s *TrillianLogServer) error {
 			_, err := s.GetLeavesByIndex(context.Background(), &leaf0Request)
@@ -147,7 +180,7 @@ func TestGetLeavesByIndexCommitFails(t *testing.T) {
 
 	test := newParameterizedTest(ctrl, "GetLeavesByIndex",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetLeavesByIndex([]int64{0}).Return([]trillian.LogLeaf{leaf1}, nil)
+			t.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{0}).Return([]trillian.LogLeaf{leaf1}, nil)
 		},
 		func(s *TrillianLogServer) error {
 			_, err := s.GetLeavesByIndex(context.Background(), &leaf0Request)
@@ -164,8 +197,8 @@ func TestGetLeavesByIndex(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-	mockTx.EXPECT().GetLeavesByIndex([]int64{0}).Return([]trillian.LogLeaf{leaf1}, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{0}).Return([]trillian.LogLeaf{leaf1}, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
@@ -193,8 +226,8 @@ func TestGetLeavesByIndexMultiple(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-	mockTx.EXPECT().GetLeavesByIndex([]int64{0, 3}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{0, 3}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
@@ -223,1092 +256,2294 @@ func TestGetLeavesByIndexMultiple(t *testing.T) {
 	}
 }
 
-func TestQueueLeavesStorageError(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+// fakeStreamLeavesServer collects the responses sent on a StreamLeaves stream.
+type fakeStreamLeavesServer struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*trillian.StreamLeavesResponse
+}
 
-	test := newParameterizedTest(ctrl, "QueueLeaves",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
-			return err
-		})
+func (f *fakeStreamLeavesServer) Context() context.Context {
+	return f.ctx
+}
 
-	test.executeStorageFailureTest(t)
+func (f *fakeStreamLeavesServer) Send(resp *trillian.StreamLeavesResponse) error {
+	f.responses = append(f.responses, resp)
+	return nil
 }
 
-func TestQueueLeavesInvalidLogId(t *testing.T) {
+func leavesInRange(start, count int64) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0, count)
+	for i := start; i < start+count; i++ {
+		leaves = append(leaves, trillian.LogLeaf{SequenceNumber: i, Leaf: trillian.Leaf{LeafHash: []byte(fmt.Sprintf("hash%d", i))}})
+	}
+	return leaves
+}
+
+func TestStreamLeavesInvalidRangeRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "QueueLeaves",
-		func(t *storage.MockLogTX) {},
-		func(s *TrillianLogServer) error {
-			_, err := s.QueueLeaves(context.Background(), &queueRequest0Log2)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	test.executeInvalidLogIDTest(t)
+	for _, req := range []trillian.StreamLeavesRequest{{LogId: logId1, StartIndex: -1}, {LogId: logId1, Count: -1}} {
+		stream := &fakeStreamLeavesServer{ctx: context.Background()}
+
+		if err := server.StreamLeaves(&req, stream); err != nil {
+			t.Fatalf("StreamLeaves(%v)=%v, want app level error", req, err)
+		}
+
+		if len(stream.responses) != 1 || stream.responses[0].Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+			t.Fatalf("StreamLeaves(%v) sent %v, want one error status", req, stream.responses)
+		}
+	}
 }
 
-func TestQueueLeavesCommitFails(t *testing.T) {
+func TestStreamLeavesInvalidLogId(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "QueueLeaves",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	test.executeCommitFailsTest(t)
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: logId2}, &fakeStreamLeavesServer{ctx: context.Background()}); err == nil || !strings.Contains(err.Error(), "BADLOGID") {
+		t.Fatalf("Returned wrong error response for nonexistent log: %v", err)
+	}
+}
+
+func TestStreamLeavesInChunks(t *testing.T) {
+	for _, test := range []struct {
+		req    trillian.StreamLeavesRequest
+		chunks [][2]int64
+	}{
+		// Everything from index 1 up to the tree size, in chunks of 2
+		{trillian.StreamLeavesRequest{LogId: logId1, StartIndex: 1, ChunkSize: 2}, [][2]int64{{1, 2}, {3, 2}, {5, 2}}},
+		// A count that doesn't fill the last chunk
+		{trillian.StreamLeavesRequest{LogId: logId1, StartIndex: 0, Count: 3, ChunkSize: 2}, [][2]int64{{0, 2}, {2, 1}}},
+		// A count that goes beyond the tree size
+		{trillian.StreamLeavesRequest{LogId: logId1, StartIndex: 5, Count: 10, ChunkSize: 4}, [][2]int64{{5, 2}}},
+		// No chunk size means use the default
+		{trillian.StreamLeavesRequest{LogId: logId1, StartIndex: 0}, [][2]int64{{0, 7}}},
+		// Starting at or beyond the tree size sends nothing
+		{trillian.StreamLeavesRequest{LogId: logId1, StartIndex: 7}, nil},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTX(ctrl)
+
+		calls := []*gomock.Call{
+			mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+			mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil),
+			mockTx.EXPECT().Commit().Return(nil),
+		}
+		for _, chunk := range test.chunks {
+			calls = append(calls,
+				mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+				mockTx.EXPECT().GetLeavesByRange(gomock.Any(), chunk[0], chunk[1]).Return(leavesInRange(chunk[0], chunk[1]), nil),
+				mockTx.EXPECT().Commit().Return(nil))
+		}
+		gomock.InOrder(calls...)
+
+		server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+		stream := &fakeStreamLeavesServer{ctx: context.Background()}
+
+		if err := server.StreamLeaves(&test.req, stream); err != nil {
+			t.Fatalf("StreamLeaves(%v)=%v, want no error", test.req, err)
+		}
+
+		if got, want := len(stream.responses), len(test.chunks); got != want {
+			t.Fatalf("StreamLeaves(%v) sent %d responses, want %d", test.req, got, want)
+		}
+
+		for i, resp := range stream.responses {
+			if resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+				t.Errorf("StreamLeaves(%v) response %d has status %v", test.req, i, resp.Status)
+			}
+
+			if got, want := int64(len(resp.Leaves)), test.chunks[i][1]; got != want {
+				t.Errorf("StreamLeaves(%v) response %d has %d leaves, want %d", test.req, i, got, want)
+			}
+
+			for j, leaf := range resp.Leaves {
+				if got, want := leaf.LeafIndex, test.chunks[i][0]+int64(j); got != want {
+					t.Errorf("StreamLeaves(%v) response %d leaf %d has index %d, want %d", test.req, i, j, got, want)
+				}
+			}
+		}
+
+		ctrl.Finish()
+	}
 }
 
-func TestQueueLeaves(t *testing.T) {
+func TestStreamLeavesTakesReadTokensPerLeaf(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
-	mockTx.EXPECT().Commit().Return(nil)
-	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+	// The tree's 5 read tokens cover the root and the first two chunks of 2 leaves
+	gomock.InOrder(
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil),
+		mockTx.EXPECT().Commit().Return(nil),
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(2)).Return(leavesInRange(0, 2), nil),
+		mockTx.EXPECT().Commit().Return(nil),
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().GetLeavesByRange(gomock.Any(), int64(2), int64(2)).Return(leavesInRange(2, 2), nil),
+		mockTx.EXPECT().Commit().Return(nil))
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quota.NewMemoryManager(quota.Limits{quota.Tree: {quota.Read: {Capacity: 5}}}, util.FakeTimeSource{FakeTime: time.Now()}))
+	stream := &fakeStreamLeavesServer{ctx: context.Background()}
 
-	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
-
-	if err != nil {
-		t.Fatalf("Failed to get leaf by index: %v", err)
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: logId1, ChunkSize: 2}, stream); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Got %v from streaming more leaves than the quota allows, want code %v", err, codes.ResourceExhausted)
 	}
 
-	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
-		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	if got, want := len(stream.responses), 2; got != want {
+		t.Errorf("Sent %d responses before the quota ran out, want %d", got, want)
 	}
 }
 
-func TestQueueLeavesNoLeavesRejected(t *testing.T) {
+func TestGetLeavesByRangeInvalidRequestRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	resp, err := server.QueueLeaves(context.Background(), &queueRequestEmpty)
+	for _, req := range []trillian.GetLeavesByRangeRequest{
+		{LogId: logId1, StartIndex: -1},
+		{LogId: logId1, Count: -1},
+		{LogId: logId1, PageToken: []byte("not a token")},
+		// A token is only good for the log it came from
+		{LogId: logId1, PageToken: leafPageToken{logID: logId2, next: 1, end: 5}.encode()},
+		{LogId: logId1, PageToken: leafPageToken{logID: logId1, next: 5, end: 5}.encode()},
+	} {
+		resp, err := server.GetLeavesByRange(context.Background(), &req)
+
+		if err != nil {
+			t.Fatalf("GetLeavesByRange(%v)=%v, want app level error", req, err)
+		}
 
-	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
-		t.Fatalf("Allowed zero leaves to be queued")
+		if resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+			t.Fatalf("GetLeavesByRange(%v) returned status %v, want error", req, resp.Status)
+		}
 	}
 }
 
-func TestQueueLeavesBeginFailsCausesError(t *testing.T) {
+func TestGetLeavesByRangeTokenBeyondTreeRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "QueueLeaves",
-		func(t *storage.MockLogTX) {},
-		func(s *TrillianLogServer) error {
-			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
 
-	test.executeBeginFailsTest(t)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	req := trillian.GetLeavesByRangeRequest{LogId: logId1, PageToken: leafPageToken{logID: logId1, next: 5, end: 50}.encode()}
+
+	if resp, err := server.GetLeavesByRange(context.Background(), &req); err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("GetLeavesByRange() with a token beyond the tree = %v %v, want app level error", resp, err)
+	}
 }
 
-func TestGetLatestSignedLogRootBeginFails(t *testing.T) {
+func TestGetLeavesByRangeInvalidLogId(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-
-	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
-
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
-
-	if err == nil || !strings.Contains(err.Error(), "TX") {
-		t.Fatalf("Returned wrong error response when begin failed: %v", err)
+	if _, err := server.GetLeavesByRange(context.Background(), &trillian.GetLeavesByRangeRequest{LogId: logId2}); err == nil || !strings.Contains(err.Error(), "BADLOGID") {
+		t.Fatalf("Returned wrong error response for nonexistent log: %v", err)
 	}
 }
 
-func TestGetLatestSignedLogRootStorageFails(t *testing.T) {
+func TestGetLeavesByRangeStorageFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
+	test := newParameterizedTest(ctrl, "GetLeavesByRange",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{}, errors.New("STORAGE"))
+			t.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+			t.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(7)).Return(nil, errors.New("STORAGE"))
 		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+			_, err := s.GetLeavesByRange(context.Background(), &trillian.GetLeavesByRangeRequest{LogId: logId1})
 			return err
 		})
 
 	test.executeStorageFailureTest(t)
 }
 
-func TestGetLatestSignedLogRootCommitFails(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+func TestGetLeavesByRangeInPages(t *testing.T) {
+	// The tree has grown by the time the later pages are read, which doesn't change the range
+	grownRoot := signedRoot1
+	grownRoot.TreeSize = 20
+
+	for _, test := range []struct {
+		max   int64
+		req   trillian.GetLeavesByRangeRequest
+		pages [][2]int64
+	}{
+		// Everything from index 1 up to the tree size, in pages of 3
+		{3, trillian.GetLeavesByRangeRequest{LogId: logId1, StartIndex: 1}, [][2]int64{{1, 3}, {4, 3}}},
+		// A count that doesn't fill the last page
+		{3, trillian.GetLeavesByRangeRequest{LogId: logId1, Count: 4}, [][2]int64{{0, 3}, {3, 1}}},
+		// A count that fills the last page exactly
+		{2, trillian.GetLeavesByRangeRequest{LogId: logId1, Count: 6}, [][2]int64{{0, 2}, {2, 2}, {4, 2}}},
+		// A count that goes beyond the tree size, in a page of the default size
+		{defaultMaxRangeLeaves, trillian.GetLeavesByRangeRequest{LogId: logId1, StartIndex: 5, Count: 10}, [][2]int64{{5, 2}}},
+		// Starting at or beyond the tree size returns a page without leaves
+		{defaultMaxRangeLeaves, trillian.GetLeavesByRangeRequest{LogId: logId1, StartIndex: 7}, [][2]int64{{7, 0}}},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTX(ctrl)
+
+		var calls []*gomock.Call
+		for i, page := range test.pages {
+			root := signedRoot1
+			if i > 0 {
+				root = grownRoot
+			}
+
+			calls = append(calls,
+				mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil),
+				mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(root, nil))
+			if page[1] > 0 {
+				calls = append(calls, mockTx.EXPECT().GetLeavesByRange(gomock.Any(), page[0], page[1]).Return(leavesInRange(page[0], page[1]), nil))
+			}
+			calls = append(calls, mockTx.EXPECT().Commit().Return(nil))
+		}
+		gomock.InOrder(calls...)
 
-	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
-		func(t *storage.MockLogTX) { t.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{}, nil) },
-		func(s *TrillianLogServer) error {
-			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
-			return err
-		})
+		server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+		server.SetMaxRangeLeaves(test.max)
+		req := test.req
 
-	test.executeCommitFailsTest(t)
-}
+		for i, page := range test.pages {
+			resp, err := server.GetLeavesByRange(context.Background(), &req)
 
-func TestGetLatestSignedLogRootInvalidLogId(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+			if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+				t.Fatalf("GetLeavesByRange(%v) page %d = %v %v, want ok", test.req, i, resp, err)
+			}
 
-	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
-		func(t *storage.MockLogTX) {},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest2)
-			return err
-		})
+			if got, want := int64(len(resp.Leaves)), page[1]; got != want {
+				t.Errorf("GetLeavesByRange(%v) page %d has %d leaves, want %d", test.req, i, got, want)
+			}
 
-	test.executeInvalidLogIDTest(t)
+			for j, leaf := range resp.Leaves {
+				if got, want := leaf.LeafIndex, page[0]+int64(j); got != want {
+					t.Errorf("GetLeavesByRange(%v) page %d leaf %d has index %d, want %d", test.req, i, j, got, want)
+				}
+			}
+
+			if last := i == len(test.pages)-1; last != (len(resp.NextPageToken) == 0) {
+				t.Fatalf("GetLeavesByRange(%v) page %d returned token %x, want one for all but the last page", test.req, i, resp.NextPageToken)
+			}
+
+			req.PageToken = resp.NextPageToken
+		}
+
+		ctrl.Finish()
+	}
 }
 
-func TestGetLatestSignedLogRoot(t *testing.T) {
+func TestStreamLeavesStorageFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
 	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(7)).Return(nil, errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	stream := &fakeStreamLeavesServer{ctx: context.Background()}
 
-	resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
-
-	if err != nil {
-		t.Fatalf("Failed to get log root: %v", err)
-	}
-
-	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
-		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: logId1}, stream); err == nil || !strings.Contains(err.Error(), "STORAGE") {
+		t.Fatalf("Returned wrong error response when storage failed: %v", err)
 	}
 
-	if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
-		t.Fatalf("Log root proto mismatch:\n%v\n%v", signedRoot1, resp.SignedLogRoot)
+	if len(stream.responses) != 0 {
+		t.Fatalf("Unexpectedly sent responses when storage failed: %v", stream.responses)
 	}
 }
 
-func TestGetLeavesByHashInvalidHash(t *testing.T) {
+func TestStreamLeavesMissingLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
-
-	// This request includes an empty hash, which isn't allowed
-	resp, err := server.GetLeavesByHash(context.Background(), &getByHashRequestBadHash)
+	mockStorage.EXPECT().Begin(gomock.Any()).Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+	mockTx.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(7)).Return(leavesInRange(0, 5), nil)
 
-	// Should have succeeded at RPC level
-	if err != nil {
-		t.Fatalf("Request failed with unexpected error: %v", err)
-	}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	// And failed at app level
-	if expected, got := trillian.TrillianApiStatusCode_ERROR, resp.Status.StatusCode; expected != got {
-		t.Fatalf("Expected app level error status but got: %v", resp.Status.StatusCode)
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: logId1}, &fakeStreamLeavesServer{ctx: context.Background()}); err == nil {
+		t.Fatalf("Returned no error when storage returned too few leaves")
 	}
 }
 
-func TestGetLeavesByHashBeginFails(t *testing.T) {
+func TestStreamLeavesClientGone(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	// The client goes away after the root is read, before any leaves are sent
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Do(func(context.Context) { cancel() }).Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
-	_, err := server.GetLeavesByHash(context.Background(), &getByHashRequest1)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	if err == nil || !strings.Contains(err.Error(), "TX") {
-		t.Fatalf("Returned wrong error response when begin failed: %v", err)
+	if err := server.StreamLeaves(&trillian.StreamLeavesRequest{LogId: logId1}, &fakeStreamLeavesServer{ctx: ctx}); err != context.Canceled {
+		t.Fatalf("StreamLeaves()=%v, want %v", err, context.Canceled)
 	}
 }
 
-func TestGetLeavesByHashStorageFails(t *testing.T) {
+func TestQueueLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetLeavesByHash",
+	test := newParameterizedTest(ctrl, "QueueLeaves",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
+			t.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return(nil, errors.New("STORAGE"))
 		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest1)
+			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
 			return err
 		})
 
 	test.executeStorageFailureTest(t)
 }
 
-func TestLeavesByHashCommitFails(t *testing.T) {
+func TestQueueLeavesInvalidLogId(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetLeavesByHash",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{}, nil)
-		},
+	test := newParameterizedTest(ctrl, "QueueLeaves",
+		func(t *storage.MockLogTX) {},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest1)
+			_, err := s.QueueLeaves(context.Background(), &queueRequest0Log2)
 			return err
 		})
 
-	test.executeCommitFailsTest(t)
+	test.executeInvalidLogIDTest(t)
 }
 
-func TestGetLeavesByHashInvalidLogId(t *testing.T) {
+func TestQueueLeavesCommitFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetLeavesByHash",
-		func(t *storage.MockLogTX) {},
+	test := newParameterizedTest(ctrl, "QueueLeaves",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
+		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest2)
+			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
 			return err
 		})
 
-	test.executeInvalidLogIDTest(t)
+	test.executeCommitFailsTest(t)
 }
 
-func TestGetLeavesByHash(t *testing.T) {
+func TestQueueLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
 	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	resp, err := server.GetLeavesByHash(context.Background(), &getByHashRequest1)
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
 
 	if err != nil {
-		t.Fatalf("Got error trying to get leaves by hash: %v", err)
+		t.Fatalf("Failed to get leaf by index: %v", err)
 	}
 
 	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
 		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
 	}
 
-	if len(resp.Leaves) != 2 || !proto.Equal(resp.Leaves[0], &expectedLeaf1) || !proto.Equal(resp.Leaves[1], &expectedLeaf3) {
-		t.Fatalf("Expected leaves %v and %v but got: %v", expectedLeaf1, expectedLeaf3, resp.Leaves)
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Fatalf("Expected leaf to be queued but got: %v", resp.Leaves)
 	}
 }
 
-func TestGetProofByHashBadTreeSize(t *testing.T) {
+func TestQueueLeavesReportsDuplicates(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	existing := leaf1
+	existing.SequenceNumber = 7
+	existing.SignedEntryTimestamp = trillian.SignedEntryTimestamp{TimestampNanos: 12345}
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{&existing}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadTreeSize)
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
 
-	if err == nil {
-		t.Fatalf("get inclusion proof by hash accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_DUPLICATE {
+		t.Fatalf("Expected leaf to be a duplicate but got: %v", resp.Leaves)
+	}
+
+	if got, want := resp.Leaves[0].ExistingLeaf.LeafIndex, int64(7); got != want {
+		t.Errorf("Got existing leaf index %d, want %d", got, want)
+	}
+
+	if got, want := resp.Leaves[0].ExistingTimestamp.TimestampNanos, int64(12345); got != want {
+		t.Errorf("Got existing leaf timestamp %d, want %d", got, want)
 	}
 }
 
-func TestGetProofByHashBadHash(t *testing.T) {
+func TestQueueLeavesRejectsInvalidLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// Only the valid leaf is passed to storage
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadHash)
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafData: []byte("nohash")}, &expectedLeaf1}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
 
-	if err == nil {
-		t.Fatalf("get inclusion proof by hash accepted invalid leaf hash: %v", getInclusionProofByHashRequestBadHash)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
 	}
-}
 
-func TestGetProofByHashBeginTXFails(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
 
-	test := newParameterizedTest(ctrl, "GetInclusionProofByHash",
-		func(t *storage.MockLogTX) {},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest25)
-			return err
-		})
+	if len(resp.Leaves) != 2 {
+		t.Fatalf("Expected a result for each leaf but got: %v", resp.Leaves)
+	}
 
-	test.executeBeginFailsTest(t)
+	if got := resp.Leaves[0]; got.Status != trillian.QueuedLeafStatus_REJECTED || len(got.Reason) == 0 {
+		t.Errorf("Expected leaf without a hash to be rejected with a reason but got: %v", got)
+	}
+
+	if got := resp.Leaves[0]; got.RejectionCode != trillian.LeafRejectionCode_MISSING_FIELD || got.RejectedField != "leaf_hash" {
+		t.Errorf("Expected leaf without a hash to be rejected for missing leaf_hash but got: %v", got)
+	}
+
+	if got := resp.Leaves[1]; got.Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Errorf("Expected valid leaf to be queued but got: %v", got)
+	}
 }
 
-func TestGetProofByHashNoRevisionForTreeSize(t *testing.T) {
+func TestQueueLeavesRejectsHashesOfWrongSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProofByHash",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest25.TreeSize).Return(int64(0), errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest25)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
 
-	test.executeStorageFailureTest(t)
-}
+	// The short hash can't be a SHA-256 hash, only the other leaf is passed to storage
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
-func TestGetProofByHashNoLeafForHash(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	test := newParameterizedTest(ctrl, "GetInclusionProofByHash",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest25.TreeSize).Return(int64(17), nil)
-			t.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest25)
-			return err
-		})
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafHash: []byte("hash"), LeafData: []byte("value")}, &expectedLeaf1}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
 
-	test.executeStorageFailureTest(t)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if len(resp.Leaves) != 2 {
+		t.Fatalf("Expected a result for each leaf but got: %v", resp.Leaves)
+	}
+
+	if got := resp.Leaves[0]; got.Status != trillian.QueuedLeafStatus_REJECTED || got.RejectionCode != trillian.LeafRejectionCode_INVALID_FIELD || got.RejectedField != "leaf_hash" {
+		t.Errorf("Expected leaf with a short hash to be rejected for leaf_hash but got: %v", got)
+	}
+
+	if got := resp.Leaves[1]; got.Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Errorf("Expected valid leaf to be queued but got: %v", got)
+	}
 }
 
-func TestGetProofByHashGetNodesFails(t *testing.T) {
+func TestQueueLeavesRejectsLargeLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProofByHash",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
-			t.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
-			t.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{}, errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
 
-	test.executeStorageFailureTest(t)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	// expectedLeaf1 has 10 bytes of value and extra data, log 1 has a lower limit of its own
+	server.SetMaxLeafSizes(100, map[int64]int{logId1: 9})
+
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if len(resp.Leaves) != 1 {
+		t.Fatalf("Expected a result for each leaf but got: %v", resp.Leaves)
+	}
+
+	got := resp.Leaves[0]
+
+	if got.Status != trillian.QueuedLeafStatus_REJECTED || got.RejectionCode != trillian.LeafRejectionCode_TOO_LARGE {
+		t.Fatalf("Expected leaf to be rejected as too large but got: %v", got)
+	}
+
+	if got, want := got.MaxLeafSize, int64(9); got != want {
+		t.Errorf("Got max leaf size %d, want %d", got, want)
+	}
 }
 
-func TestGetProofByHashWrongNodeCountFetched(t *testing.T) {
+func TestQueueLeavesAllowsLeavesWithinDefaultSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
-	// The server expects three nodes from storage but we return only two
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetMaxLeafSizes(10, map[int64]int{logId2: 1})
 
-	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
 
-	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
-		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Fatalf("Expected leaf to be queued but got: %v", resp.Leaves)
 	}
 }
 
-func TestGetProofByHashWrongNodeReturned(t *testing.T) {
+func TestQueueLeavesLimitsPriority(t *testing.T) {
+	for _, test := range []struct {
+		priorities map[int64]QueuePriorityRange
+		priority   int32
+		want       int32
+	}{
+		{priorities: nil, priority: 5, want: 0},
+		{priorities: map[int64]QueuePriorityRange{logId2: {Min: -1, Max: 1}}, priority: -1, want: 0},
+		{priorities: map[int64]QueuePriorityRange{logId1: {Min: -1, Max: 1}}, priority: -1, want: -1},
+		{priorities: map[int64]QueuePriorityRange{logId1: {Min: -1, Max: 1}}, priority: 5, want: 1},
+		{priorities: map[int64]QueuePriorityRange{logId1: {Min: -1, Max: 1}}, priority: -5, want: -1},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTX(ctrl)
+
+		leaf := leaf1
+		leaf.Priority = test.want
+
+		mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+		mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf}).Return([]*trillian.LogLeaf{nil}, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+		server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+		server.SetQueuePriorities(test.priorities)
+
+		req := queueRequest0
+		req.Priority = test.priority
+
+		if _, err := server.QueueLeaves(context.Background(), &req); err != nil {
+			t.Errorf("Priorities %v, priority %d: failed to queue leaves: %v", test.priorities, test.priority, err)
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func TestQueueLeavesNotBefore(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
-	// We set this up so one of the returned nodes has the wrong ID
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3}, {NodeID: testonly.MustCreateNodeIDForTreeCoords(4, 5, 64), NodeRevision: 2}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	// The leaf with a negative time is rejected, the other is queued with its time
+	leaf := leaf1
+	leaf.NotBeforeNanos = 1234
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf}).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+	notBefore := expectedLeaf1
+	notBefore.NotBeforeNanos = 1234
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafHash: leafHash3, NotBeforeNanos: -1}, &notBefore}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
 
-	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
-		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
 	}
-}
 
-func TestGetProofByHashCommitFails(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+	if len(resp.Leaves) != 2 {
+		t.Fatalf("Expected a result for each leaf but got: %v", resp.Leaves)
+	}
 
-	test := newParameterizedTest(ctrl, "GetInclusionProofByHash",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-			t.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
-			t.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3}, {NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3}}, nil)
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
-			return err
-		})
+	if got := resp.Leaves[0]; got.RejectionCode != trillian.LeafRejectionCode_INVALID_FIELD || got.RejectedField != "not_before_nanos" {
+		t.Errorf("Expected leaf to be rejected for an invalid not_before_nanos but got: %v", got)
+	}
 
-	test.executeCommitFailsTest(t)
+	if got := resp.Leaves[1]; got.Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Errorf("Expected valid leaf to be queued but got: %v", got)
+	}
 }
 
-func TestGetProofByHash(t *testing.T) {
+func TestQueueLeavesValidatorRejectsLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	// Only the leaf the validator accepts is passed to storage
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
 	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetLeafValidator(func(ctx context.Context, logID int64, leaf *trillian.LeafProto) error {
+		if logID != logId1 {
+			t.Errorf("Validator got log %d, want %d", logID, logId1)
+		}
 
-	proofResponse, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+		if string(leaf.LeafData) == "bad" {
+			return &LeafRejection{Field: "leaf_data", Reason: "not a certificate"}
+		}
+
+		return nil
+	})
+
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafHash: leafHash3, LeafData: []byte("bad")}, &expectedLeaf1}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
 
 	if err != nil {
-		t.Fatalf("get inclusion proof by hash should have succeeded but we got: %v", err)
+		t.Fatalf("Failed to queue leaves: %v", err)
 	}
 
-	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
-		t.Fatalf("server response was not successful: %v", proofResponse)
+	if len(resp.Leaves) != 2 {
+		t.Fatalf("Expected a result for each leaf but got: %v", resp.Leaves)
 	}
 
-	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
-	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
-	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+	got := resp.Leaves[0]
 
-	if err1 != nil || err2 != nil || err3 != nil {
-		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	if got.Status != trillian.QueuedLeafStatus_REJECTED || got.RejectionCode != trillian.LeafRejectionCode_FAILED_VALIDATION {
+		t.Errorf("Expected leaf to fail validation but got: %v", got)
 	}
 
-	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
-		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
-		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
-		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
+	if got.RejectedField != "leaf_data" || got.Reason != "not a certificate" {
+		t.Errorf("Expected the validator's details for the rejected leaf but got: %v", got)
+	}
 
-	if !proto.Equal(proofResponse.Proof[0], &expectedProof) {
-		t.Fatalf("expected proof: %v but got: %v", expectedProof, proofResponse.Proof[0])
+	if got := resp.Leaves[1]; got.Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Errorf("Expected valid leaf to be queued but got: %v", got)
 	}
 }
 
-func TestGetProofByIndexBadTreeSize(t *testing.T) {
+func TestQueueLeavesValidatorErrorFailsRequest(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetLeafValidator(func(ctx context.Context, logID int64, leaf *trillian.LeafProto) error {
+		return errors.New("validator unavailable")
+	})
 
-	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadTreeSize)
-
-	if err == nil {
-		t.Fatalf("get inclusion proof by index accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); err == nil {
+		t.Fatalf("Queued leaves when the validator failed")
 	}
 }
 
-func TestGetProofByIndexBadIndex(t *testing.T) {
+func TestQueueLeavesAllRejectedSkipsStorage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndex)
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafData: []byte("nohash")}}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
 
-	if err == nil {
-		t.Fatalf("get inclusion proof by index accepted invalid leaf index: %v", getInclusionProofByIndexRequestBadLeafIndex)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_REJECTED {
+		t.Fatalf("Expected leaf to be rejected but got: %v", resp.Leaves)
 	}
 }
 
-func TestGetProofByIndexBadIndexRange(t *testing.T) {
+func TestQueueLeavesNoLeavesRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndexRange)
+	resp, err := server.QueueLeaves(context.Background(), &queueRequestEmpty)
 
-	if err == nil {
-		t.Fatalf("get inclusion proof by index accepted invalid leaf index (outside tree size): %v", getInclusionProofByIndexRequestBadLeafIndexRange)
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Allowed zero leaves to be queued")
 	}
 }
 
-func TestGetProofByIndexBeginTXFails(t *testing.T) {
+func TestQueueLeavesBeginFailsCausesError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProof",
+	test := newParameterizedTest(ctrl, "QueueLeaves",
 		func(t *storage.MockLogTX) {},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest25)
+			_, err := s.QueueLeaves(context.Background(), &queueRequest0)
 			return err
 		})
 
 	test.executeBeginFailsTest(t)
 }
 
-func TestGetProofByIndexNoRevisionForTreeSize(t *testing.T) {
+func TestQueueLeavesStorageUnhealthy(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProof",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest25.TreeSize).Return(int64(0), errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest25)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(nil, storage.ErrUnhealthy)
 
-	test.executeStorageFailureTest(t)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Got %v from queue leaves with unhealthy storage, want code %v", err, codes.ResourceExhausted)
+	}
 }
 
-func TestGetProofByIndexGetNodesFails(t *testing.T) {
+func TestGetProofByIndexStorageUnhealthy(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProof",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-			t.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{}, errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(7)).Return(nil, storage.ErrUnhealthy)
 
-	test.executeStorageFailureTest(t)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if _, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Got %v from get proof with unhealthy storage, want code %v", err, codes.ResourceExhausted)
+	}
 }
 
-func TestGetProofByIndexWrongNodeCountFetched(t *testing.T) {
+func TestAddSequencedLeaves(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-	// The server expects three nodes from storage but we return only two
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().AddSequencedLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+	request := trillian.AddSequencedLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}}
+	resp, err := server.AddSequencedLeaves(context.Background(), &request)
 
-	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
-		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_QUEUED {
+		t.Fatalf("Expected leaf to be queued but got: %v", resp.Leaves)
 	}
 }
 
-func TestGetProofByIndexWrongNodeReturned(t *testing.T) {
+func TestAddSequencedLeavesRejectsNegativeIndex(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-	// We set this up so one of the returned nodes has the wrong ID
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3}, {NodeID: testonly.MustCreateNodeIDForTreeCoords(4, 5, 64), NodeRevision: 2}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+	request := trillian.AddSequencedLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafIndex: -1, LeafHash: leafHash1, LeafData: []byte("data")}}}
+	resp, err := server.AddSequencedLeaves(context.Background(), &request)
 
-	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
-		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
 	}
-}
-
-func TestGetProofByIndexCommitFails(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetInclusionProof",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-			t.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3}, {NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3}}, nil)
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
-			return err
-		})
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_REJECTED {
+		t.Fatalf("Expected leaf to be rejected but got: %v", resp.Leaves)
+	}
 
-	test.executeCommitFailsTest(t)
+	if got := resp.Leaves[0]; got.RejectionCode != trillian.LeafRejectionCode_INVALID_FIELD || got.RejectedField != "leaf_index" {
+		t.Errorf("Expected leaf to be rejected for an invalid leaf_index but got: %v", got)
+	}
 }
 
-func TestGetProofByIndex(t *testing.T) {
+func TestAddSequencedLeavesRejectsNotBefore(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
-	mockTx.EXPECT().Commit().Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	proofResponse, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+	request := trillian.AddSequencedLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafIndex: 1, LeafHash: leafHash1, LeafData: []byte("data"), NotBeforeNanos: 1234}}}
+	resp, err := server.AddSequencedLeaves(context.Background(), &request)
 
 	if err != nil {
-		t.Fatalf("get inclusion proof by index should have succeeded but we got: %v", err)
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
 	}
 
-	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
-		t.Fatalf("server response was not successful: %v", proofResponse)
-	}
-
-	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
-	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
-	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
-
-	if err1 != nil || err2 != nil || err3 != nil {
-		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	if len(resp.Leaves) != 1 || resp.Leaves[0].Status != trillian.QueuedLeafStatus_REJECTED {
+		t.Fatalf("Expected leaf to be rejected but got: %v", resp.Leaves)
 	}
 
-	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
-		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
-		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
-		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
-
-	if !proto.Equal(proofResponse.Proof, &expectedProof) {
-		t.Fatalf("expected proof: %v but got: %v", expectedProof, proofResponse.Proof)
+	if got := resp.Leaves[0]; got.RejectionCode != trillian.LeafRejectionCode_INVALID_FIELD || got.RejectedField != "not_before_nanos" {
+		t.Errorf("Expected leaf to be rejected for an invalid not_before_nanos but got: %v", got)
 	}
 }
 
-func TestGetEntryAndProofBadTreeSize(t *testing.T) {
+func TestAddSequencedLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
-	mockStorage := storage.NewMockLogStorage(ctrl)
-
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	request := trillian.AddSequencedLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}}
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadTreeSize)
+	test := newParameterizedTest(ctrl, "AddSequencedLeaves",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().AddSequencedLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return(nil, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.AddSequencedLeaves(context.Background(), &request)
+			return err
+		})
 
-	if err == nil {
-		t.Fatalf("get entry and proof accepted invalid tree size")
-	}
+	test.executeStorageFailureTest(t)
 }
 
-func TestGetEntryAndProofBadLeafIndex(t *testing.T) {
+func TestAddSequencedLeavesNoLeavesRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndex)
+	resp, err := server.AddSequencedLeaves(context.Background(), &trillian.AddSequencedLeavesRequest{LogId: logId1})
 
-	if err == nil {
-		t.Fatalf("get entry and proof accepted invalid leaf index")
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Allowed zero leaves to be added")
 	}
 }
 
-func TestGetEntryAndProofBadLeafIndexRange(t *testing.T) {
+func TestGetLatestSignedLogRootBeginFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	// Request should fail validation before any storage operations
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, errors.New("TX"))
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndexRange)
+	_, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
 
-	if err == nil {
-		t.Fatalf("get entry and proof accepted invalid leaf index (out of range)")
+	if err == nil || !strings.Contains(err.Error(), "TX") {
+		t.Fatalf("Returned wrong error response when begin failed: %v", err)
 	}
 }
 
-func TestGetEntryAndProofBeginTXFails(t *testing.T) {
+func TestGetLatestSignedLogRootStorageFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockStorage.EXPECT().Begin().Return(nil, errors.New("BeginTX"))
+	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+			return err
+		})
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	test.executeStorageFailureTest(t)
+}
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest17)
+func TestGetLatestSignedLogRootCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
 
-	if err == nil || !strings.Contains(err.Error(), "BeginTX") {
-		t.Fatalf("get entry and proof returned no or wrong error when begin tx failed: %v", err)
-	}
+	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(trillian.SignedLogRoot{}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetLatestSignedLogRootInvalidLogId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "LatestSignedLogRoot",
+		func(t *storage.MockLogTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest2)
+			return err
+		})
+
+	test.executeInvalidLogIDTest(t)
 }
 
-func TestGetEntryAndProofGetTreeSizeAtRevisionFails(t *testing.T) {
+func TestGetLatestSignedLogRoot(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest17.TreeSize).Return(int64(0), errors.New("NOREVISION"))
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest17)
+	resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
 
-	if err == nil || !strings.Contains(err.Error(), "NOREVISION") {
-		t.Fatalf("get entry and proof returned no or wrong error when no revision: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to get log root: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
+		t.Fatalf("Log root proto mismatch:\n%v\n%v", signedRoot1, resp.SignedLogRoot)
 	}
 }
 
-func TestGetEntryAndProofGetMerkleNodesFails(t *testing.T) {
+func TestGetLatestSignedLogRootIncludesCosignatures(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{}, errors.New("GetNodes"))
-	mockTx.EXPECT().Rollback().Return(nil)
+	root := signedRoot1
+	root.Signature = &trillian.DigitallySigned{Signature: []byte("log")}
+	cosignatures := []trillian.Cosignature{
+		{WitnessId: "witness1", Signature: &trillian.DigitallySigned{Signature: []byte("sig1")}},
+		{WitnessId: "witness2", Signature: &trillian.DigitallySigned{Signature: []byte("sig2")}},
+	}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(root, nil)
+	mockTx.EXPECT().GetCosignatures(gomock.Any(), root.TimestampNanos).Return(cosignatures, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+	resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
 
-	if err == nil || !strings.Contains(err.Error(), "GetNodes") {
-		t.Fatalf("get entry and proof returned no or wrong error when get nodes failed: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to get log root: %v", err)
+	}
+
+	if got, want := len(resp.Cosignatures), len(cosignatures); got != want {
+		t.Fatalf("Got %d cosignatures, want %d", got, want)
+	}
+
+	for i := range cosignatures {
+		if !proto.Equal(&cosignatures[i], resp.Cosignatures[i]) {
+			t.Errorf("Cosignature %d mismatch:\n%v\n%v", i, cosignatures[i], resp.Cosignatures[i])
+		}
 	}
 }
 
-func TestGetEntryAndProofGetLeavesFails(t *testing.T) {
+func TestGetLatestSignedLogRootCached(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
-	mockTx.EXPECT().GetLeavesByIndex([]int64{2}).Return(nil, errors.New("GetLeaves"))
-	mockTx.EXPECT().Rollback().Return(nil)
+	// Only the first request reads the root from storage
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetRootCache(rootcache.NewCache(time.Minute, util.FakeTimeSource{FakeTime: time.Unix(0, 0)}))
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+	for i := 0; i < 2; i++ {
+		resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
 
-	if err == nil || !strings.Contains(err.Error(), "GetLeaves") {
-		t.Fatalf("get entry and proof returned no or wrong error when get leaves failed: %v", err)
+		if err != nil {
+			t.Fatalf("Failed to get log root: %v", err)
+		}
+
+		if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
+			t.Fatalf("Log root proto mismatch:\n%v\n%v", signedRoot1, resp.SignedLogRoot)
+		}
 	}
 }
 
-func TestGetEntryAndProofGetLeavesReturnsMultiple(t *testing.T) {
+func TestAddCosignatureInvalidatesCachedRoot(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
-	// Code passed one leaf index so expects one result, but we return more
-	mockTx.EXPECT().GetLeavesByIndex([]int64{2}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	keys, cosignature := newTestWitness(signedRoot1, t)
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedLogRoot(gomock.Any(), signedRoot1.TimestampNanos).Return(signedRoot1, nil)
+	mockTx.EXPECT().AddCosignature(gomock.Any(), signedRoot1.TimestampNanos, cosignature).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+	rootCache := rootcache.NewCache(time.Minute, util.FakeTimeSource{FakeTime: time.Unix(0, 0)})
+	rootCache.SetLogRoot(logId1, signedRoot1, nil)
+	server := NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys)
+	server.SetRootCache(rootCache)
 
-	if err == nil || !strings.Contains(err.Error(), "expected one leaf") {
-		t.Fatalf("get entry and proof returned no or wrong error when storage returns multiple leaves: %v", err)
+	if _, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature}); err != nil {
+		t.Fatalf("Failed to add cosignature: %v", err)
+	}
+
+	if _, _, ok := rootCache.LogRoot(logId1); ok {
+		t.Error("Root is still cached without its new cosignature")
 	}
 }
 
-func TestGetEntryAndProofCommitFails(t *testing.T) {
+func TestGetLatestSignedLogRootCosignaturesStorageFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	root := signedRoot1
+	root.Signature = &trillian.DigitallySigned{Signature: []byte("log")}
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
-	mockTx.EXPECT().GetLeavesByIndex([]int64{2}).Return([]trillian.LogLeaf{leaf1}, nil)
-	mockTx.EXPECT().Commit().Return(errors.New("COMMIT"))
+	test := newParameterizedTest(ctrl, "GetCosignatures",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(root, nil)
+			t.EXPECT().GetCosignatures(gomock.Any(), root.TimestampNanos).Return(nil, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+			return err
+		})
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	test.executeStorageFailureTest(t)
+}
 
-	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+// newTestWitness returns a key provider knowing a single witness and a cosignature it made
+// over root
+func newTestWitness(root trillian.SignedLogRoot, t *testing.T) (WitnessKeyProviderFunc, trillian.Cosignature) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 
-	if err == nil || !strings.Contains(err.Error(), "COMMIT") {
-		t.Fatalf("get entry and proof returned no or wrong error when commit failed: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to generate witness key: %v", err)
+	}
+
+	sig, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, key).SignLogRoot(root)
+
+	if err != nil {
+		t.Fatalf("Failed to cosign root: %v", err)
 	}
+
+	keys := NewWitnessKeyProvider(map[string]gocrypto.PublicKey{"witness": key.Public()})
+
+	return keys, trillian.Cosignature{WitnessId: "witness", Signature: &sig}
 }
 
-func TestGetEntryAndProof(t *testing.T) {
+func TestAddCosignature(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
-	mockTx.EXPECT().GetTreeRevisionAtSize(getEntryAndProofRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
-		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
-		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
-		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
-	mockTx.EXPECT().GetLeavesByIndex([]int64{2}).Return([]trillian.LogLeaf{leaf1}, nil)
+	keys, cosignature := newTestWitness(signedRoot1, t)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedLogRoot(gomock.Any(), signedRoot1.TimestampNanos).Return(signedRoot1, nil)
+	mockTx.EXPECT().AddCosignature(gomock.Any(), signedRoot1.TimestampNanos, cosignature).Return(nil)
 	mockTx.EXPECT().Commit().Return(nil)
 
-	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server := NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys)
 
-	response, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+	resp, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature})
 
-	if err != nil || response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
-		t.Fatalf("get entry and proof should have succeeded but we got: %v", err)
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("Failed to add cosignature: %v %v", resp, err)
 	}
+}
 
-	// Check the proof is the one we expected
-	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
-	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
-	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+func TestAddCosignatureRejectsBadSignature(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
 
-	if err1 != nil || err2 != nil || err3 != nil {
-		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
-	}
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
 
-	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
-		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
-		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
-		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
+	// The cosignature is over a different root to the one the log signed
+	otherRoot := signedRoot1
+	otherRoot.TreeSize++
+	keys, cosignature := newTestWitness(otherRoot, t)
 
-	if !proto.Equal(response.Proof, &expectedProof) {
-		t.Fatalf("expected proof: %v but got: %v", expectedProof, response.Proof)
-	}
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedLogRoot(gomock.Any(), signedRoot1.TimestampNanos).Return(signedRoot1, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
 
-	// Check we got the correct leaf data
-	if !proto.Equal(response.Leaf, &expectedLeaf1) {
-		t.Fatalf("Expected leaf %v but got: %v", expectedLeaf1, response.Leaf)
+	server := NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys)
+
+	resp, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Accepted cosignature over a different root: %v %v", resp, err)
 	}
 }
 
-func TestGetSequencedLeafCountBeginTXFails(t *testing.T) {
+func TestAddCosignatureRootNotFound(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
-		func(t *storage.MockLogTX) {},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
-			return err
-		})
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
 
-	test.executeBeginFailsTest(t)
+	keys, cosignature := newTestWitness(signedRoot1, t)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedLogRoot(gomock.Any(), int64(123)).Return(trillian.SignedLogRoot{}, storage.ErrLogRootNotFound)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys)
+
+	resp, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: 123, Cosignature: &cosignature})
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Accepted cosignature for a root that doesn't exist: %v %v", resp, err)
+	}
 }
 
-func TestGetSequencedLeafCountStorageFails(t *testing.T) {
+func TestAddCosignatureRejectsBadRequests(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetSequencedLeafCount().Return(int64(0), errors.New("STORAGE"))
-		},
-		func(s *TrillianLogServer) error {
-			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
-			return err
-		})
-
-	test.executeStorageFailureTest(t)
+	// None of these should touch storage
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	keys, cosignature := newTestWitness(signedRoot1, t)
+	unknown := cosignature
+	unknown.WitnessId = "unknown"
+
+	for _, test := range []struct {
+		server *TrillianLogServer
+		req    trillian.AddCosignatureRequest
+	}{
+		{NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys), trillian.AddCosignatureRequest{LogId: logId1}},
+		{NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys), trillian.AddCosignatureRequest{LogId: logId1, Cosignature: &trillian.Cosignature{Signature: cosignature.Signature}}},
+		{NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys), trillian.AddCosignatureRequest{LogId: logId1, Cosignature: &trillian.Cosignature{WitnessId: "witness"}}},
+		{NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys), trillian.AddCosignatureRequest{LogId: logId1, Cosignature: &unknown}},
+		{NewTrillianLogServer(mockStorageProviderfunc(mockStorage)), trillian.AddCosignatureRequest{LogId: logId1, Cosignature: &cosignature}},
+	} {
+		resp, err := test.server.AddCosignature(context.Background(), &test.req)
+
+		if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+			t.Errorf("Accepted bad cosignature request %v: %v %v", test.req, resp, err)
+		}
+	}
 }
 
-func TestGetSequencedLeafCountCommitFails(t *testing.T) {
+func TestAddCosignatureStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
+	keys, cosignature := newTestWitness(signedRoot1, t)
+
+	test := newParameterizedTest(ctrl, "AddCosignature",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetSequencedLeafCount().Return(int64(27), nil)
+			t.EXPECT().GetSignedLogRoot(gomock.Any(), signedRoot1.TimestampNanos).Return(signedRoot1, nil)
+			t.EXPECT().AddCosignature(gomock.Any(), signedRoot1.TimestampNanos, cosignature).Return(errors.New("STORAGE"))
 		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
+			s.witnessKeys = keys
+			_, err := s.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature})
 			return err
 		})
 
-	test.executeCommitFailsTest(t)
+	test.executeStorageFailureTest(t)
 }
 
-func TestGetSequencedLeafCount(t *testing.T) {
+func TestGetLeavesByHashInvalidHash(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetSequencedLeafCount().Return(int64(268), nil)
-	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	response, err := server.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
+	// This request includes an empty hash, which isn't allowed
+	resp, err := server.GetLeavesByHash(context.Background(), &getByHashRequestBadHash)
 
+	// Should have succeeded at RPC level
 	if err != nil {
-		t.Fatalf("expected no error getting leaf count but got: %v", err)
-	}
-
-	if response.Status == nil || response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
-		t.Fatalf("server response was not successful: %v", response)
+		t.Fatalf("Request failed with unexpected error: %v", err)
 	}
 
-	if got, want := response.LeafCount, int64(268); got != want {
-		t.Fatalf("expected leaf count: %d but got: %d", want, got)
+	// And failed at app level
+	if expected, got := trillian.TrillianApiStatusCode_ERROR, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level error status but got: %v", resp.Status.StatusCode)
 	}
 }
 
-func TestGetConsistencyProofRejectsBadRequests(t *testing.T) {
+func TestGetLeavesByHashBeginFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, errors.New("TX"))
+
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
-	for _, request := range []trillian.GetConsistencyProofRequest{getConsistencyProofRequestBadFirstTreeSize, getConsistencyProofRequestBadSecondTreeSize, getConsistencyProofRequestBadRange} {
-		_, err := server.GetConsistencyProof(context.Background(), &request)
+	_, err := server.GetLeavesByHash(context.Background(), &getByHashRequest1)
 
-		if err == nil {
-			t.Fatalf("get consistency proof accepted invalid request: %v", request)
-		}
+	if err == nil || !strings.Contains(err.Error(), "TX") {
+		t.Fatalf("Returned wrong error response when begin failed: %v", err)
 	}
 }
 
-func TestGetConsistencyProofBeginTXFails(t *testing.T) {
+func TestGetLeavesByHashStorageFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetConsistencyProof",
-		func(t *storage.MockLogTX) {},
+	test := newParameterizedTest(ctrl, "GetLeavesByHash",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
+		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest25)
+			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest1)
 			return err
 		})
 
-	test.executeBeginFailsTest(t)
+	test.executeStorageFailureTest(t)
 }
 
-func TestGetConsistencyProofGetTreeRevision1Fails(t *testing.T) {
+func TestLeavesByHashCommitFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetConsistencyProof",
+	test := newParameterizedTest(ctrl, "GetLeavesByHash",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest25.FirstTreeSize).Return(int64(0), errors.New("STORAGE"))
+			t.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{}, nil)
 		},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest25)
+			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest1)
 			return err
 		})
 
-	test.executeStorageFailureTest(t)
+	test.executeCommitFailsTest(t)
 }
 
-func TestGetConsistencyProofGetTreeRevision2Fails(t *testing.T) {
+func TestGetLeavesByHashInvalidLogId(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetConsistencyProof",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest25.FirstTreeSize).Return(int64(11), nil)
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest25.SecondTreeSize).Return(int64(0), errors.New("STORAGE"))
-		},
+	test := newParameterizedTest(ctrl, "GetLeavesByHash",
+		func(t *storage.MockLogTX) {},
 		func(s *TrillianLogServer) error {
-			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest25)
+			_, err := s.GetLeavesByHash(context.Background(), &getByHashRequest2)
+			return err
+		})
+
+	test.executeInvalidLogIDTest(t)
+}
+
+func TestGetLeavesByHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("test"), []byte("data")}, false).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetLeavesByHash(context.Background(), &getByHashRequest1)
+
+	if err != nil {
+		t.Fatalf("Got error trying to get leaves by hash: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if len(resp.Leaves) != 2 || !proto.Equal(resp.Leaves[0], &expectedLeaf1) || !proto.Equal(resp.Leaves[1], &expectedLeaf3) {
+		t.Fatalf("Expected leaves %v and %v but got: %v", expectedLeaf1, expectedLeaf3, resp.Leaves)
+	}
+}
+
+func TestGetLeavesByIdentityHashInvalidHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, req := range []trillian.GetLeavesByIdentityHashRequest{getByIdentityHashRequestBadHash, {LogId: logId1}} {
+		resp, err := server.GetLeavesByIdentityHash(context.Background(), &req)
+
+		if err != nil {
+			t.Fatalf("Request failed with unexpected error: %v", err)
+		}
+
+		if expected, got := trillian.TrillianApiStatusCode_ERROR, resp.Status.StatusCode; expected != got {
+			t.Fatalf("Expected app level error status for %v but got: %v", req, resp.Status.StatusCode)
+		}
+	}
+}
+
+func TestGetLeavesByIdentityHashStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetLeavesByIdentityHash",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetLeavesByIdentityHash(gomock.Any(), []trillian.Hash{[]byte("identity")}, true).Return(nil, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLeavesByIdentityHash(context.Background(), &getByIdentityHashRequest1)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetLeavesByIdentityHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// Two versions of an entry, with different leaf hashes
+	versions := []trillian.LogLeaf{leaf1, leaf3}
+	for i := range versions {
+		versions[i].LeafIdentityHash = []byte("identity")
+	}
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIdentityHash(gomock.Any(), []trillian.Hash{[]byte("identity")}, true).Return(versions, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetLeavesByIdentityHash(context.Background(), &getByIdentityHashRequest1)
+
+	if err != nil {
+		t.Fatalf("Got error trying to get leaves by identity hash: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if len(resp.Leaves) != 2 {
+		t.Fatalf("Expected 2 leaves but got: %v", resp.Leaves)
+	}
+
+	for i, want := range []trillian.LeafProto{expectedLeaf1, expectedLeaf3} {
+		want.LeafIdentityHash = []byte("identity")
+		if !proto.Equal(resp.Leaves[i], &want) {
+			t.Errorf("Expected leaf %v but got: %v", want, resp.Leaves[i])
+		}
+	}
+}
+
+func TestGetProofByHashBadTreeSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadTreeSize)
+
+	if err == nil {
+		t.Fatalf("get inclusion proof by hash accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
+	}
+}
+
+func TestGetProofByHashBadHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadHash)
+
+	if err == nil {
+		t.Fatalf("get inclusion proof by hash accepted invalid leaf hash: %v", getInclusionProofByHashRequestBadHash)
+	}
+}
+
+func TestGetProofByHashSnapshotFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProofByHash", getInclusionProofByHashRequest25.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest25)
+			return err
+		})
+
+	test.executeSnapshotFailsTest(t)
+}
+
+func TestGetProofByHashNoLeafForHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProofByHash", getInclusionProofByHashRequest25.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(17))
+			t.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest25)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetProofByHashGetNodesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProofByHash", getInclusionProofByHashRequest7.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+			t.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetProofByHashWrongNodeCountFetched(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	// The server expects three nodes from storage but we return only two
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
+		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
+	}
+}
+
+func TestGetProofByHashWrongNodeReturned(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	// We set this up so one of the returned nodes has the wrong ID
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: testonly.MustCreateNodeIDForTreeCoords(4, 5, 64), NodeRevision: 2, Hash: []byte("nodehash1")}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
+		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
+	}
+}
+
+func TestGetProofByHashMissingNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	// The second node of the path isn't in storage so has no hash
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: nodeIdsInclusionSize7Index2[1]}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "missing node") || !strings.Contains(err.Error(), "at proof pos 1") {
+		t.Fatalf("get inclusion proof by hash returned no or wrong error when a node is missing: %v", err)
+	}
+}
+
+func TestGetProofByHashCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProofByHash", getInclusionProofByIndexRequest7.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+			t.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetProofByHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by hash should have succeeded but we got: %v", err)
+	}
+
+	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("server response was not successful: %v", proofResponse)
+	}
+
+	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
+	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
+	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	}
+
+	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
+		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
+		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
+		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
+
+	if !proto.Equal(proofResponse.Proof[0], &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, proofResponse.Proof[0])
+	}
+}
+
+func TestGetProofByIndexBadTreeSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadTreeSize)
+
+	if err == nil {
+		t.Fatalf("get inclusion proof by index accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
+	}
+}
+
+func TestGetProofByIndexBadIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndex)
+
+	if err == nil {
+		t.Fatalf("get inclusion proof by index accepted invalid leaf index: %v", getInclusionProofByIndexRequestBadLeafIndex)
+	}
+}
+
+func TestGetProofByIndexBadIndexRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndexRange)
+
+	if err == nil {
+		t.Fatalf("get inclusion proof by index accepted invalid leaf index (outside tree size): %v", getInclusionProofByIndexRequestBadLeafIndexRange)
+	}
+}
+
+func TestGetProofByIndexSnapshotFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProof", getInclusionProofByIndexRequest25.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest25)
+			return err
+		})
+
+	test.executeSnapshotFailsTest(t)
+}
+
+func TestGetProofByIndexGetNodesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProof", getInclusionProofByIndexRequest7.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetProofByIndexWrongNodeCountFetched(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByIndexRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	// The server expects three nodes from storage but we return only two
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
+		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
+	}
+}
+
+func TestGetProofByIndexWrongNodeReturned(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByIndexRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	// We set this up so one of the returned nodes has the wrong ID
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: testonly.MustCreateNodeIDForTreeCoords(4, 5, 64), NodeRevision: 2, Hash: []byte("nodehash1")}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
+		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
+	}
+}
+
+func TestGetProofByIndexCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetInclusionProof", getInclusionProofByIndexRequest7.TreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetProofByIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByIndexRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by index should have succeeded but we got: %v", err)
+	}
+
+	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("server response was not successful: %v", proofResponse)
+	}
+
+	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
+	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
+	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	}
+
+	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
+		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
+		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
+		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
+
+	if !proto.Equal(proofResponse.Proof, &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, proofResponse.Proof)
+	}
+}
+
+func TestGetProofByIndexForSizeWithoutSTH(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByIndexRequest7.TreeSize).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+
+	// The first STH with at least 7 leaves has 8
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(4))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(8))
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(4), append(nodeIdsInclusionSize7Index2[:2:2], nodeIdsRehashSize7InSize8...)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsRehashSize7InSize8[0], NodeRevision: 3, Hash: []byte("nodehash2")},
+		{NodeID: nodeIdsRehashSize7InSize8[1], NodeRevision: 3, Hash: []byte("nodehash3")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by index should have succeeded but we got: %v", err)
+	}
+
+	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
+	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
+	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
+		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
+		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
+		{NodeId: nodeIDBytes3, NodeHash: hasher.HashChildren([]byte("nodehash2"), []byte("nodehash3")), NodeRevision: 3}}}
+
+	if !proto.Equal(proofResponse.Proof, &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, proofResponse.Proof)
+	}
+}
+
+func TestGetEntryAndProofBadTreeSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadTreeSize)
+
+	if err == nil {
+		t.Fatalf("get entry and proof accepted invalid tree size")
+	}
+}
+
+func TestGetEntryAndProofBadLeafIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndex)
+
+	if err == nil {
+		t.Fatalf("get entry and proof accepted invalid leaf index")
+	}
+}
+
+func TestGetEntryAndProofBadLeafIndexRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndexRange)
+
+	if err == nil {
+		t.Fatalf("get entry and proof accepted invalid leaf index (out of range)")
+	}
+}
+
+func TestGetEntryAndProofSnapshotFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// This is also how storage reports a tree size without a revision
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest17.TreeSize).Return(nil, errors.New("NOREVISION"))
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest17)
+
+	if err == nil || !strings.Contains(err.Error(), "NOREVISION") {
+		t.Fatalf("get entry and proof returned no or wrong error when snapshot failed: %v", err)
+	}
+}
+
+func TestGetEntryAndProofGetMerkleNodesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{}, errors.New("GetNodes"))
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "GetNodes") {
+		t.Fatalf("get entry and proof returned no or wrong error when get nodes failed: %v", err)
+	}
+}
+
+func TestGetEntryAndProofGetLeavesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{2}).Return(nil, errors.New("GetLeaves"))
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "GetLeaves") {
+		t.Fatalf("get entry and proof returned no or wrong error when get leaves failed: %v", err)
+	}
+}
+
+func TestGetEntryAndProofGetLeavesReturnsMultiple(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	// Code passed one leaf index so expects one result, but we return more
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{2}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "expected one leaf") {
+		t.Fatalf("get entry and proof returned no or wrong error when storage returns multiple leaves: %v", err)
+	}
+}
+
+func TestGetEntryAndProofCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{2}).Return([]trillian.LogLeaf{leaf1}, nil)
+	mockTx.EXPECT().Commit().Return(errors.New("COMMIT"))
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+
+	if err == nil || !strings.Contains(err.Error(), "COMMIT") {
+		t.Fatalf("get entry and proof returned no or wrong error when commit failed: %v", err)
+	}
+}
+
+func TestGetEntryAndProof(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getEntryAndProofRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{2}).Return([]trillian.LogLeaf{leaf1}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
+
+	if err != nil || response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("get entry and proof should have succeeded but we got: %v", err)
+	}
+
+	// Check the proof is the one we expected
+	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
+	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
+	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
+	}
+
+	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{
+		{NodeId: nodeIDBytes1, NodeHash: []byte("nodehash0"), NodeRevision: 3},
+		{NodeId: nodeIDBytes2, NodeHash: []byte("nodehash1"), NodeRevision: 2},
+		{NodeId: nodeIDBytes3, NodeHash: []byte("nodehash2"), NodeRevision: 3}}}
+
+	if !proto.Equal(response.Proof, &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, response.Proof)
+	}
+
+	// Check we got the correct leaf data
+	if !proto.Equal(response.Leaf, &expectedLeaf1) {
+		t.Fatalf("Expected leaf %v but got: %v", expectedLeaf1, response.Leaf)
+	}
+}
+
+func TestGetSequencedLeafCountBeginTXFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
+		func(t *storage.MockLogTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
+			return err
+		})
+
+	test.executeBeginFailsTest(t)
+}
+
+func TestGetSequencedLeafCountStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetSequencedLeafCount(gomock.Any()).Return(int64(0), errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
 			return err
 		})
 
 	test.executeStorageFailureTest(t)
 }
 
-func TestGetConsistencyProofGetNodesFails(t *testing.T) {
+func TestGetSequencedLeafCountCommitFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetConsistencyProof",
+	test := newParameterizedTest(ctrl, "GetSequencedLeafCount",
 		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
-			t.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{}, errors.New("STORAGE"))
+			t.EXPECT().GetSequencedLeafCount(gomock.Any()).Return(int64(27), nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetSequencedLeafCount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+
+	mockTx.EXPECT().GetSequencedLeafCount(gomock.Any()).Return(int64(268), nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetSequencedLeafCount(context.Background(), &trillian.GetSequencedLeafCountRequest{LogId: logId1})
+
+	if err != nil {
+		t.Fatalf("expected no error getting leaf count but got: %v", err)
+	}
+
+	if response.Status == nil || response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("server response was not successful: %v", response)
+	}
+
+	if got, want := response.LeafCount, int64(268); got != want {
+		t.Fatalf("expected leaf count: %d but got: %d", want, got)
+	}
+}
+
+func TestGetConsistencyProofRejectsBadRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, request := range []trillian.GetConsistencyProofRequest{getConsistencyProofRequestBadFirstTreeSize, getConsistencyProofRequestBadSecondTreeSize, getConsistencyProofRequestBadRange} {
+		_, err := server.GetConsistencyProof(context.Background(), &request)
+
+		if err == nil {
+			t.Fatalf("get consistency proof accepted invalid request: %v", request)
+		}
+	}
+}
+
+func TestGetConsistencyProofSnapshotFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetConsistencyProof", getConsistencyProofRequest25.SecondTreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest25)
+			return err
+		})
+
+	test.executeSnapshotFailsTest(t)
+}
+
+func TestGetConsistencyProofGetNodesFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newSnapshotTest(ctrl, "GetConsistencyProof", getConsistencyProofRequest7.SecondTreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(5))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{}, errors.New("STORAGE"))
 		},
 		func(s *TrillianLogServer) error {
 			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
@@ -1323,14 +2558,13 @@ func TestGetConsistencyProofGetNodesReturnsWrongCount(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getConsistencyProofRequest7.SecondTreeSize).Return(mockTx, nil)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(5))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
 	// The server expects one node from storage but we return two
-	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeRevision: 3}, {NodeRevision: 2}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
@@ -1346,14 +2580,13 @@ func TestGetConsistencyProofGetNodesReturnsWrongNode(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getConsistencyProofRequest7.SecondTreeSize).Return(mockTx, nil)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(5))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
 	// Return an unexpected node that wasn't requested
-	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), NodeRevision: 3}}, nil)
-	mockTx.EXPECT().Rollback().Return(nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), NodeRevision: 3}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
@@ -1368,11 +2601,11 @@ func TestGetConsistencyProofCommitFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	test := newParameterizedTest(ctrl, "GetConsistencyProof",
-		func(t *storage.MockLogTX) {
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
-			t.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
-			t.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3}}, nil)
+	test := newSnapshotTest(ctrl, "GetConsistencyProof", getConsistencyProofRequest7.SecondTreeSize,
+		func(t *storage.MockReadOnlyLogTreeTX) {
+			t.EXPECT().ReadRevision().AnyTimes().Return(int64(5))
+			t.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+			t.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
 		},
 		func(s *TrillianLogServer) error {
 			_, err := s.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
@@ -1387,12 +2620,11 @@ func TestGetConsistencyProof(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(ctrl)
-	mockTx := storage.NewMockLogTX(ctrl)
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
-
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
-	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getConsistencyProofRequest7.SecondTreeSize).Return(mockTx, nil)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(5))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: testonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
@@ -1422,6 +2654,44 @@ func TestGetConsistencyProof(t *testing.T) {
 	}
 }
 
+func TestGetConsistencyProofToSizeWithoutSTH(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getConsistencyProofRequest7.SecondTreeSize).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(6))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(8))
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(6), nodeIdsRehashSize7InSize8).Return([]storage.Node{
+		{NodeID: nodeIdsRehashSize7InSize8[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsRehashSize7InSize8[1], NodeRevision: 5, Hash: []byte("nodehash1")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
+
+	if err != nil {
+		t.Fatalf("failed to get consistency proof: %v", err)
+	}
+
+	nodeIDBytes, err := proto.Marshal(nodeIdsConsistencySize4ToSize7[0].AsProto())
+
+	if err != nil {
+		t.Fatalf("failed to marshall test proto - should not happen: %v ", err)
+	}
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	expectedProof := trillian.ProofProto{LeafIndex: 0, ProofNode: []*trillian.NodeProto{
+		{NodeId: nodeIDBytes, NodeHash: hasher.HashChildren([]byte("nodehash0"), []byte("nodehash1")), NodeRevision: 5}}}
+
+	if !proto.Equal(response.Proof, &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, response.Proof)
+	}
+}
+
 type prepareMockTXFunc func(*storage.MockLogTX)
 type makeRpcFunc func(*TrillianLogServer) error
 
@@ -1440,7 +2710,8 @@ func (p *parameterizedTest) executeCommitFailsTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 	p.prepareTx(mockTx)
 	mockTx.EXPECT().Commit().Return(errors.New("Bang!"))
 	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
@@ -1471,7 +2742,8 @@ func (p *parameterizedTest) executeStorageFailureTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
 	p.prepareTx(mockTx)
 	mockTx.EXPECT().Rollback().Return(nil)
 
@@ -1484,11 +2756,72 @@ func (p *parameterizedTest) executeStorageFailureTest(t *testing.T) {
 	}
 }
 
+// snapshotTest runs the tests shared by the RPCs that serve proofs from a snapshot pinned
+// to treeSize
+type snapshotTest struct {
+	ctrl      *gomock.Controller
+	operation string
+	treeSize  int64
+	prepareTx func(*storage.MockReadOnlyLogTreeTX)
+	makeRpc   makeRpcFunc
+}
+
+func newSnapshotTest(ctrl *gomock.Controller, operation string, treeSize int64, prepareTx func(*storage.MockReadOnlyLogTreeTX), makeRpc makeRpcFunc) *snapshotTest {
+	return &snapshotTest{ctrl, operation, treeSize, prepareTx, makeRpc}
+}
+
+func (p *snapshotTest) executeCommitFailsTest(t *testing.T) {
+	mockStorage := storage.NewMockLogStorage(p.ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(p.ctrl)
+
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), p.treeSize).Return(mockTx, nil)
+	p.prepareTx(mockTx)
+	mockTx.EXPECT().Commit().Return(errors.New("Bang!"))
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if err := p.makeRpc(server); err == nil {
+		t.Fatalf("Returned OK when commit failed: %s: %v", p.operation, err)
+	}
+}
+
+// executeStorageFailureTest checks a failed read is returned, and that the snapshot is
+// still released by committing it
+func (p *snapshotTest) executeStorageFailureTest(t *testing.T) {
+	mockStorage := storage.NewMockLogStorage(p.ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(p.ctrl)
+
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), p.treeSize).Return(mockTx, nil)
+	p.prepareTx(mockTx)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if err := p.makeRpc(server); err == nil || !strings.Contains(err.Error(), "STORAGE") {
+		t.Fatalf("Returned wrong error response when storage failed: %s: %v", p.operation, err)
+	}
+}
+
+// executeSnapshotFailsTest checks the error is returned when a snapshot can't be started,
+// e.g. because there's no revision for the tree size
+func (p *snapshotTest) executeSnapshotFailsTest(t *testing.T) {
+	mockStorage := storage.NewMockLogStorage(p.ctrl)
+
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), p.treeSize).Return(nil, errors.New("SNAPSHOT"))
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if err := p.makeRpc(server); err == nil || !strings.Contains(err.Error(), "SNAPSHOT") {
+		t.Fatalf("Returned wrong error response when snapshot failed: %s: %v", p.operation, err)
+	}
+}
+
 func (p *parameterizedTest) executeBeginFailsTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
-	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, errors.New("TX"))
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
 
@@ -1498,3 +2831,345 @@ func (p *parameterizedTest) executeBeginFailsTest(t *testing.T) {
 		t.Fatalf("Returned wrong error response when begin failed")
 	}
 }
+
+func TestQueueLeavesDeadlinePassedSkipsStorage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No transaction should be started for a request that's already been abandoned
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, err := server.QueueLeaves(ctx, &queueRequest0); err != context.DeadlineExceeded {
+		t.Fatalf("Got %v from queue leaves after the deadline, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueueLeavesCancelledRollsBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	// The client goes away while the leaves are being stored, so they mustn't be committed
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Do(func(context.Context, []trillian.LogLeaf) { cancel() }).Return([]*trillian.LogLeaf{nil}, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if _, err := server.QueueLeaves(ctx, &queueRequest0); err != context.Canceled {
+		t.Fatalf("Got %v from queue leaves for a cancelled request, want %v", err, context.Canceled)
+	}
+}
+
+func TestGetProofByHashSkipsLeavesAfterTreeSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	// The snapshot's tree has a duplicate of the leaf that isn't in the first 7
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}, {SequenceNumber: 7}}, nil)
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by hash should have succeeded but we got: %v", err)
+	}
+
+	if got, want := len(proofResponse.Proof), 1; got != want {
+		t.Fatalf("got %d proofs, expected %d: %v", got, want, proofResponse.Proof)
+	}
+}
+
+func TestGetProofByHashStopsWhenContextCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), getInclusionProofByHashRequest7.TreeSize).Return(mockTx, nil)
+
+	mockTx.EXPECT().ReadRevision().AnyTimes().Return(int64(3))
+	mockTx.EXPECT().TreeSize().AnyTimes().Return(int64(7))
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}, {SequenceNumber: 2}}, nil)
+	// The client goes away while the first proof is being built, so there's no second one
+	mockTx.EXPECT().GetProofPath(gomock.Any(), int64(3), leafIdSize7Index2, int64(7)).Do(func(context.Context, int64, storage.NodeID, int64) { cancel() }).Return([]storage.Node{{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")}, {NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")}, {NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if _, err := server.GetInclusionProofByHash(ctx, &getInclusionProofByHashRequest7); err != context.Canceled {
+		t.Fatalf("Got %v from get inclusion proof by hash for a cancelled request, want %v", err, context.Canceled)
+	}
+}
+
+// fakeQuotaManager records the tokens requested from and returned to it. Requests for
+// tokens fail with err.
+type fakeQuotaManager struct {
+	numTokens int
+	specs     []quota.Spec
+	err       error
+	// returned is the total number of tokens put back, to the buckets in returnedSpecs
+	returned      int
+	returnedSpecs []quota.Spec
+}
+
+func (f *fakeQuotaManager) GetTokens(ctx context.Context, numTokens int, specs []quota.Spec) error {
+	f.numTokens, f.specs = numTokens, specs
+	return f.err
+}
+
+func (f *fakeQuotaManager) PutTokens(ctx context.Context, numTokens int, specs []quota.Spec) error {
+	f.returned += numTokens
+	f.returnedSpecs = specs
+	return nil
+}
+
+func TestQueueLeavesQuotaExhausted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Storage shouldn't be touched for a client without quota
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(&fakeQuotaManager{err: quota.ErrExhausted})
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Got %v from queue leaves without quota, want code %v", err, codes.ResourceExhausted)
+	}
+}
+
+func TestQueueLeavesQuotaFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	quotaErr := errors.New("QUOTA")
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(&fakeQuotaManager{err: quotaErr})
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); err != quotaErr {
+		t.Fatalf("Got %v from queue leaves when quota failed, want %v", err, quotaErr)
+	}
+}
+
+func TestQueueLeavesTakesWriteTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1, leaf3}).Return([]*trillian.LogLeaf{nil, nil}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quotaManager)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4321}})
+	req := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1, &expectedLeaf3}}
+
+	if _, err := server.QueueLeaves(ctx, &req); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	wantSpecs := []quota.Spec{
+		{Group: quota.Global, Kind: quota.Write},
+		{Group: quota.Tree, Kind: quota.Write, TreeID: logId1},
+		{Group: quota.User, Kind: quota.Write, User: "10.0.0.1"},
+	}
+
+	if quotaManager.numTokens != 2 || !reflect.DeepEqual(quotaManager.specs, wantSpecs) {
+		t.Errorf("Took %d tokens from %v, want 2 from %v", quotaManager.numTokens, quotaManager.specs, wantSpecs)
+	}
+}
+
+func TestQuotaUserIsCertificateIdentity(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "frontend"}}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4321}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{State: state}})
+
+	if got := quotaUser(ctx); got != "frontend" {
+		t.Errorf("Got quota user %q for a client with a certificate, want frontend", got)
+	}
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{}})
+
+	if got := quotaUser(ctx); got != "10.0.0.1" {
+		t.Errorf("Got quota user %q for a client without a certificate, want its host", got)
+	}
+}
+
+func TestGetLeavesByIndexTakesReadTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{0, 3}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quotaManager)
+
+	if _, err := server.GetLeavesByIndex(context.Background(), &leaf03Request); err != nil {
+		t.Fatalf("Failed to get leaves by index: %v", err)
+	}
+
+	if quotaManager.numTokens != 2 || len(quotaManager.specs) != 3 || quotaManager.specs[1] != (quota.Spec{Group: quota.Tree, Kind: quota.Read, TreeID: logId1}) {
+		t.Errorf("Took %d tokens from %v, want 2 read tokens for tree %d", quotaManager.numTokens, quotaManager.specs, logId1)
+	}
+}
+
+func TestQueueLeavesReturnsTokensForDuplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	// The second leaf is already in the log so it won't be sequenced
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1, leaf3}).Return([]*trillian.LogLeaf{nil, &leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quotaManager)
+
+	req := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1, &expectedLeaf3}}
+
+	if _, err := server.QueueLeaves(context.Background(), &req); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	wantSpecs := []quota.Spec{{Group: quota.Global, Kind: quota.Write}, {Group: quota.Tree, Kind: quota.Write, TreeID: logId1}}
+
+	if quotaManager.returned != 1 || !reflect.DeepEqual(quotaManager.returnedSpecs, wantSpecs) {
+		t.Errorf("Returned %d tokens to %v, want 1 to %v", quotaManager.returned, quotaManager.returnedSpecs, wantSpecs)
+	}
+}
+
+func TestQueueLeavesStorageErrorReturnsTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leaf1}).Return(nil, errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quotaManager)
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); err == nil {
+		t.Fatalf("Queued leaves when storage failed")
+	}
+
+	if quotaManager.numTokens != 1 || quotaManager.returned != 1 {
+		t.Errorf("Took %d tokens and returned %d when storage failed, want 1 of each", quotaManager.numTokens, quotaManager.returned)
+	}
+}
+
+func TestQueueLeavesBeginErrorReturnsTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(nil, storage.ErrUnhealthy)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetQuotaManager(quotaManager)
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Got %v from queue leaves with unhealthy storage, want code %v", err, codes.ResourceExhausted)
+	}
+
+	if quotaManager.numTokens != 1 || quotaManager.returned != 1 {
+		t.Errorf("Took %d tokens and returned %d when storage was unhealthy, want 1 of each", quotaManager.numTokens, quotaManager.returned)
+	}
+}
+
+func TestAddCosignatureReturnsWriteToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	keys, cosignature := newTestWitness(signedRoot1, t)
+
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedLogRoot(gomock.Any(), signedRoot1.TimestampNanos).Return(signedRoot1, nil)
+	mockTx.EXPECT().AddCosignature(gomock.Any(), signedRoot1.TimestampNanos, cosignature).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServerWithWitnesses(mockStorageProviderfunc(mockStorage), keys)
+	server.SetQuotaManager(quotaManager)
+
+	if _, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature}); err != nil {
+		t.Fatalf("Failed to add cosignature: %v", err)
+	}
+
+	// The cosignature will never be sequenced, so its token mustn't drain sequenced buckets
+	if quotaManager.numTokens != 1 || quotaManager.returned != 1 {
+		t.Errorf("Took %d tokens and returned %d for a cosignature, want 1 of each", quotaManager.numTokens, quotaManager.returned)
+	}
+}
+
+func TestAddCosignatureStorageProviderErrorReturnsToken(t *testing.T) {
+	keys, cosignature := newTestWitness(signedRoot1, t)
+
+	quotaManager := &fakeQuotaManager{}
+	server := NewTrillianLogServerWithWitnesses(func(int64) (storage.LogStorage, error) { return nil, errors.New("PROVIDER") }, keys)
+	server.SetQuotaManager(quotaManager)
+
+	if _, err := server.AddCosignature(context.Background(), &trillian.AddCosignatureRequest{LogId: logId1, RootTimestampNanos: signedRoot1.TimestampNanos, Cosignature: &cosignature}); err == nil {
+		t.Fatalf("Added cosignature without storage")
+	}
+
+	if quotaManager.numTokens != 1 || quotaManager.returned != 1 {
+		t.Errorf("Took %d tokens and returned %d without storage, want 1 of each", quotaManager.numTokens, quotaManager.returned)
+	}
+}
@@ -0,0 +1,73 @@
+package vmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/trillian"
+)
+
+// pageTokenVersion is the first byte of every page token, so that the encoding can change
+// without misreading tokens handed out by older servers
+const pageTokenVersion = 1
+
+// pageTokenHeaderSize is the length of a token before its key, the version then two int64s
+const pageTokenHeaderSize = 1 + 2*8
+
+var errInvalidPageToken = errors.New("invalid page token")
+
+// keyPageToken records where a GetLeavesByPrefix request got to. Tokens are handed to
+// clients, so everything in them is checked again when they come back.
+type keyPageToken struct {
+	// mapID is the map the keys are of, a token can't be used with another
+	mapID int64
+	// revision is the revision the first page was read at, later pages are read at it too
+	revision int64
+	// next is the first key of the next page
+	next trillian.Key
+}
+
+func (p keyPageToken) encode() []byte {
+	b := make([]byte, pageTokenHeaderSize, pageTokenHeaderSize+len(p.next))
+	b[0] = pageTokenVersion
+	binary.BigEndian.PutUint64(b[1:], uint64(p.mapID))
+	binary.BigEndian.PutUint64(b[9:], uint64(p.revision))
+
+	return append(b, p.next...)
+}
+
+// decodeKeyPageToken reads a token made by encode, checking it's for mapID and its next key
+// starts with prefix
+func decodeKeyPageToken(b []byte, mapID int64, prefix []byte) (keyPageToken, error) {
+	if len(b) < pageTokenHeaderSize || b[0] != pageTokenVersion {
+		return keyPageToken{}, errInvalidPageToken
+	}
+
+	p := keyPageToken{
+		mapID:    int64(binary.BigEndian.Uint64(b[1:])),
+		revision: int64(binary.BigEndian.Uint64(b[9:])),
+		next:     append(trillian.Key{}, b[pageTokenHeaderSize:]...),
+	}
+
+	if p.mapID != mapID || p.revision < 0 || !bytes.HasPrefix(p.next, prefix) {
+		return keyPageToken{}, errInvalidPageToken
+	}
+
+	return p, nil
+}
+
+// prefixEnd returns the first key after all the keys starting with prefix, or nil if there
+// isn't one because prefix is empty or all 0xff bytes
+func prefixEnd(prefix []byte) trillian.Key {
+	end := append(trillian.Key{}, prefix...)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] != 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package vmap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func TestKeyPageTokenRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		p      keyPageToken
+		prefix []byte
+	}{
+		{keyPageToken{mapID: 1, revision: 0, next: trillian.Key{}}, nil},
+		{keyPageToken{mapID: -5, revision: 1 << 40, next: trillian.Key("a/b")}, []byte("a/")},
+	} {
+		got, err := decodeKeyPageToken(test.p.encode(), test.p.mapID, test.prefix)
+		if err != nil {
+			t.Fatalf("decodeKeyPageToken(%v) = %v", test.p, err)
+		}
+
+		if got.mapID != test.p.mapID || got.revision != test.p.revision || !bytes.Equal(got.next, test.p.next) {
+			t.Errorf("decodeKeyPageToken() = %v, want %v", got, test.p)
+		}
+	}
+}
+
+func TestKeyPageTokenRejectsInvalid(t *testing.T) {
+	good := keyPageToken{mapID: 1, revision: 2, next: trillian.Key("a/b")}.encode()
+	wrongVersion := append([]byte{}, good...)
+	wrongVersion[0]++
+
+	for _, test := range []struct {
+		desc  string
+		token []byte
+	}{
+		{"empty", nil},
+		{"truncated", good[:pageTokenHeaderSize-1]},
+		{"wrong version", wrongVersion},
+		{"negative revision", keyPageToken{mapID: 1, revision: -1, next: trillian.Key("a/b")}.encode()},
+		{"key outside prefix", keyPageToken{mapID: 1, revision: 2, next: trillian.Key("b")}.encode()},
+	} {
+		if _, err := decodeKeyPageToken(test.token, 1, []byte("a/")); err == nil {
+			t.Errorf("%s: decodeKeyPageToken() accepted %x", test.desc, test.token)
+		}
+	}
+
+	if _, err := decodeKeyPageToken(good, 2, []byte("a/")); err == nil {
+		t.Error("decodeKeyPageToken() accepted the token of another map")
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for _, test := range []struct {
+		prefix []byte
+		want   trillian.Key
+	}{
+		{nil, nil},
+		{[]byte{0xff, 0xff}, nil},
+		{[]byte("a/"), trillian.Key("a0")},
+		{[]byte{1, 0xff}, trillian.Key{2}},
+		{[]byte{1, 0xfe, 0xff}, trillian.Key{1, 0xff}},
+	} {
+		if got := prefixEnd(test.prefix); !bytes.Equal(got, test.want) || (got == nil) != (test.want == nil) {
+			t.Errorf("prefixEnd(%x) = %x, want %x", test.prefix, got, test.want)
+		}
+	}
+}
@@ -2,14 +2,23 @@ package vmap
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/publisher"
+	"github.com/google/trillian/util/rootcache"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -18,8 +27,23 @@ var (
 	ErrNotImplemented = errors.New("Not yet implemented")
 )
 
-// TODO: There is no access control in the server yet and clients could easily modify
-// any tree.
+// The largest number of mutations sent in each StreamMutations response, also used if the
+// request doesn't give a chunk size
+const maxStreamMutationsChunkSize = 100
+
+// The largest number of keys looked at for each page of a GetLeavesByPrefix response, also
+// used if the request doesn't give a page size
+const maxLeavesByPrefixPageSize = 100
+
+// Metrics of how old the roots that reads are served from are, by map ID
+var (
+	mapRootAge          = monitoring.NewGauge("map_root_age_seconds", "Age of the latest map root when it was last read", "treeid")
+	staleMapRootReads   = monitoring.NewCounter("map_stale_root_reads", "Reads served from a root older than the map's max root age", "treeid")
+	refusedMapRootReads = monitoring.NewCounter("map_stale_root_refusals", "Reads refused in strict mode because the root was older than the map's max root age", "treeid")
+)
+
+// Access control is left to the auth interceptors. Without them any client can read or
+// change any map.
 
 // MapStorageProviderFunc decouples the server from storage implementations
 type MapStorageProviderFunc func(int64) (storage.MapStorage, error)
@@ -31,11 +55,72 @@ type TrillianMapServer struct {
 	storageMapGuard sync.Mutex
 	// Map from tree ID to storage impl for that map
 	storageMap map[int64]storage.MapStorage
+	// writeLocks has a lock for each map that SetLeaves requests hold while writing to it,
+	// also guarded by storageMapGuard
+	writeLocks map[int64]*sync.Mutex
+	// publisher is given every new map root once it has been committed
+	publisher publisher.Publisher
+	// rootCache holds the latest signed root of each map, if it's nil roots are always read
+	// from storage
+	rootCache *rootcache.Cache
+	// maxRootAges holds the oldest the latest root of each map can be before reads against
+	// it are stale, maps without one are never stale
+	maxRootAges map[int64]time.Duration
+	// strictRootAge makes reads against a stale root fail rather than be flagged
+	strictRootAge bool
+	timeSource    util.TimeSource
 }
 
 // NewTrillianMaperver creates a new RPC server backed by a MapStorageProvider.
 func NewTrillianMapServer(p MapStorageProviderFunc) *TrillianMapServer {
-	return &TrillianMapServer{storageProvider: p, storageMap: make(map[int64]storage.MapStorage)}
+	return &TrillianMapServer{storageProvider: p, storageMap: make(map[int64]storage.MapStorage), writeLocks: make(map[int64]*sync.Mutex), publisher: publisher.None{}, timeSource: util.SystemTimeSource{}}
+}
+
+// SetPublisher arranges for every new map root to be passed to p. It's called while the map
+// is locked for writing, so p shouldn't block, see publisher.Async.
+func (t *TrillianMapServer) SetPublisher(p publisher.Publisher) {
+	t.publisher = p
+}
+
+// SetRootCache makes GetSignedMapRoot answer from c when it holds a fresh root, without
+// using storage. Roots read from storage or written by SetLeaves are added to c.
+func (t *TrillianMapServer) SetRootCache(c *rootcache.Cache) {
+	t.rootCache = c
+}
+
+// SetMaxRootAges sets the oldest the latest root of each map in perTree can be. Reads of the
+// latest root served from an older one are flagged as stale in their responses, or refused
+// with FAILED_PRECONDITION if strict is set. Reads at a given revision aren't checked.
+func (t *TrillianMapServer) SetMaxRootAges(perTree map[int64]time.Duration, strict bool) {
+	t.maxRootAges = perTree
+	t.strictRootAge = strict
+}
+
+// checkRootAge records the age of root, the latest root of map mapID, and reports whether
+// it's older than the map's max root age. In strict mode a stale root returns an error.
+func (t *TrillianMapServer) checkRootAge(ctx context.Context, mapID int64, root trillian.SignedMapRoot) (bool, error) {
+	// A map that hasn't been written to yet has no root to be stale
+	if root.TimestampNanos == 0 {
+		return false, nil
+	}
+
+	id := strconv.FormatInt(mapID, 10)
+	age := t.timeSource.Now().Sub(time.Unix(0, root.TimestampNanos))
+	mapRootAge.Set(age.Seconds(), id)
+
+	maxAge, ok := t.maxRootAges[mapID]
+	if !ok || age <= maxAge {
+		return false, nil
+	}
+
+	if t.strictRootAge {
+		refusedMapRootReads.Inc(id)
+		return false, grpc.Errorf(codes.FailedPrecondition, "root of map %d is %v old, older than its max root age of %v", mapID, age, maxAge)
+	}
+
+	staleMapRootReads.Inc(id)
+	logging.Warningf(ctx, "Serving read from root of map %d that is %v old, older than its max root age of %v", mapID, age, maxAge)
+	return true, nil
 }
 
 func (t *TrillianMapServer) getStorageForMap(mapId int64) (storage.MapStorage, error) {
@@ -56,9 +141,14 @@ func (t *TrillianMapServer) getStorageForMap(mapId int64) (storage.MapStorage, e
 	return s, err
 }
 
-func (t *TrillianMapServer) getHasherForMap(mapId int64) (merkle.MapHasher, error) {
-	// TODO(al): actually return tailored hashers.
-	return merkle.NewMapHasher(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), nil
+// getHasherForMap returns a MapHasher using the hash algorithm the map was created with.
+func (t *TrillianMapServer) getHasherForMap(s storage.ReadOnlyMapStorage) (merkle.MapHasher, error) {
+	th, err := merkle.NewTreeHasher(s.HashAlgorithm())
+	if err != nil {
+		return merkle.MapHasher{}, err
+	}
+
+	return merkle.NewMapHasher(th), nil
 }
 
 // GetLeaves implements the GetLeaves RPC method.
@@ -68,9 +158,11 @@ func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapL
 		return nil, err
 	}
 
-	tx, err := s.Snapshot()
+	// The snapshot is pinned to the requested revision, or the newest published one, so all
+	// the values and proofs are read from the same version of the map
+	tx, err := s.SnapshotForTree(ctx, req.Revision)
 	if err != nil {
-		return nil, err
+		return nil, storageStatus(err)
 	}
 	defer func() {
 		e := tx.Commit()
@@ -79,88 +171,324 @@ func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapL
 		}
 	}()
 
-	kh, err := t.getHasherForMap(req.MapId)
+	kh, err := t.getHasherForMap(s)
 	if err != nil {
 		return nil, err
 	}
 
 	var root *trillian.SignedMapRoot
+	stale := false
 
 	if req.Revision < 0 {
-		// need to know the newest published revision
-		r, err := tx.LatestSignedMapRoot()
+		r, err := tx.LatestSignedMapRoot(ctx)
 		if err != nil {
 			return nil, err
 		}
+		if stale, err = t.checkRootAge(ctx, req.MapId, r); err != nil {
+			return nil, err
+		}
 		root = &r
 		req.Revision = root.MapRevision
+	} else {
+		// return the root for the requested revision so the proofs can be checked against it
+		r, err := tx.GetSignedMapRoot(ctx, req.Revision)
+		if err != nil {
+			return nil, err
+		}
+		root = &r
 	}
 
 	smtReader := merkle.NewSparseMerkleTreeReader(req.Revision, kh, tx)
 
 	resp = &trillian.GetMapLeavesResponse{
-		KeyValue: make([]*trillian.KeyValueInclusion, 0, len(req.Key)),
+		KeyValue:     make([]*trillian.KeyValueInclusion, 0, len(req.Key)),
+		MapRootStale: stale,
 	}
 
 	keyHashes := make([]trillian.Hash, 0, len(req.Key))
-	hashToKey := make(map[string][]byte)
+	requested := make(map[string]bool)
 	for _, key := range req.Key {
 		kHash := kh.HashKey(key)
 		keyHashes = append(keyHashes, kHash)
-		hashToKey[string(kHash)] = key
+		requested[string(kHash)] = true
 	}
 
-	leaves, err := tx.Get(req.Revision, keyHashes)
+	leaves, err := tx.Get(ctx, req.Revision, keyHashes)
 	if err != nil {
 		return nil, err
 	}
 
-	glog.Infof("wanted %d leaves, found %d", len(req.Key), len(leaves))
+	logging.Infof(ctx, "wanted %d leaves, found %d", len(req.Key), len(leaves))
 
+	hashToLeaf := make(map[string]*trillian.MapLeaf)
 	for _, leaf := range leaves {
 		leaf := leaf
-		key, ok := hashToKey[string(leaf.KeyHash)]
-		if !ok {
-			glog.Warningf("Retrieved unrequested leaf with keyhash: %v, skipping", leaf.KeyHash)
+		if !requested[string(leaf.KeyHash)] {
+			logging.Warningf(ctx, "Retrieved unrequested leaf with keyhash: %v, skipping", leaf.KeyHash)
 			continue
 		}
-		proof, err := smtReader.InclusionProof(req.Revision, key)
+		hashToLeaf[string(leaf.KeyHash)] = &leaf
+	}
+
+	// Every key gets an entry, in request order. A key with no value has a nil Value and
+	// its proof shows the leaf is empty, so the client can tell it apart from an error.
+	kvs := make([]*trillian.KeyValueInclusion, 0, len(req.Key))
+	for i, key := range req.Key {
+		kvs = append(kvs, &trillian.KeyValueInclusion{
+			KeyValue: &trillian.KeyValue{
+				Key:   key,
+				Value: hashToLeaf[string(keyHashes[i])],
+			},
+		})
+	}
+
+	// Compute all of the proofs against the same revision in one go, so the
+	// sibling nodes shared between keys are only read from storage once.
+	keys := make([]trillian.Key, 0, len(kvs))
+	for _, kvi := range kvs {
+		keys = append(keys, kvi.KeyValue.Key)
+	}
+	proofs, err := smtReader.BatchInclusionProof(ctx, req.Revision, keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, kvi := range kvs {
+		kvi.Inclusion = inclusionProofBytes(proofs[i])
+	}
+	resp.KeyValue = kvs
+	resp.MapRoot = root
+
+	return resp, nil
+}
+
+// GetLeafHistory implements the GetLeafHistory RPC method.
+func (t *TrillianMapServer) GetLeafHistory(ctx context.Context, req *trillian.GetLeafHistoryRequest) (resp *trillian.GetLeafHistoryResponse, err error) {
+	if req.StartRevision < 0 || (req.EndRevision >= 0 && req.EndRevision < req.StartRevision) {
+		return nil, fmt.Errorf("invalid map revision range: %d to %d", req.StartRevision, req.EndRevision)
+	}
+
+	s, err := t.getStorageForMap(req.MapId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, storageStatus(err)
+	}
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			resp, err = nil, e
+		}
+	}()
+
+	kh, err := t.getHasherForMap(s)
+	if err != nil {
+		return nil, err
+	}
+
+	endRevision := req.EndRevision
+	if endRevision < 0 {
+		root, err := tx.LatestSignedMapRoot(ctx)
 		if err != nil {
 			return nil, err
 		}
-		kvi := trillian.KeyValueInclusion{
-			KeyValue: &trillian.KeyValue{
-				Key:   key,
-				Value: &leaf,
+		endRevision = root.MapRevision
+	}
+
+	history, err := tx.GetHistory(ctx, kh.HashKey(req.Key), req.StartRevision, endRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &trillian.GetLeafHistoryResponse{
+		History: make([]*trillian.MapLeafRevision, 0, len(history)),
+	}
+
+	for _, h := range history {
+		h := h
+		// Stop building proofs if the client has gone away
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		root, err := tx.GetSignedMapRoot(ctx, h.Revision)
+		if err != nil {
+			logging.Warningf(ctx, "Failed to get map root for revision %d: %v", h.Revision, err)
+			return nil, err
+		}
+
+		proof, err := merkle.NewSparseMerkleTreeReader(h.Revision, kh, tx).InclusionProof(ctx, h.Revision, req.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.History = append(resp.History, &trillian.MapLeafRevision{
+			Revision: h.Revision,
+			KeyValue: &trillian.KeyValueInclusion{
+				KeyValue: &trillian.KeyValue{
+					Key:   req.Key,
+					Value: &h.Leaf,
+				},
+				Inclusion: inclusionProofBytes(proof),
 			},
-			Inclusion: make([][]byte, 0, len(proof)),
+			MapRoot: &root,
+		})
+	}
+
+	return resp, nil
+}
+
+// GetLeavesByPrefix implements the GetLeavesByPrefix RPC method. The keys starting with the
+// prefix are read from the map's key index a page at a time, and the keys of the page without
+// a value at the revision are left out of the response.
+func (t *TrillianMapServer) GetLeavesByPrefix(ctx context.Context, req *trillian.GetMapLeavesByPrefixRequest) (resp *trillian.GetMapLeavesByPrefixResponse, err error) {
+	revision, start := req.Revision, append(trillian.Key{}, req.KeyPrefix...)
+	if len(req.PageToken) > 0 {
+		p, err := decodeKeyPageToken(req.PageToken, req.MapId, req.KeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		revision, start = p.revision, p.next
+	}
+
+	pageSize := maxLeavesByPrefixPageSize
+	if req.PageSize > 0 && int(req.PageSize) < pageSize {
+		pageSize = int(req.PageSize)
+	}
+
+	s, err := t.getStorageForMap(req.MapId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.SnapshotForTree(ctx, revision)
+	if err != nil {
+		return nil, storageStatus(err)
+	}
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			resp, err = nil, e
 		}
-		for j := 0; j < len(proof); j++ {
-			kvi.Inclusion = append(kvi.Inclusion, []byte(proof[j]))
+	}()
+
+	kh, err := t.getHasherForMap(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var root trillian.SignedMapRoot
+	if revision < 0 {
+		root, err = tx.LatestSignedMapRoot(ctx)
+	} else {
+		root, err = tx.GetSignedMapRoot(ctx, revision)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Later pages are read at the revision of the first, so only its root is checked
+	stale := false
+	if revision < 0 {
+		if stale, err = t.checkRootAge(ctx, req.MapId, root); err != nil {
+			return nil, err
+		}
+	}
+
+	resp = &trillian.GetMapLeavesByPrefixResponse{MapRoot: &root, MapRootStale: stale}
+
+	// One more key than the page holds is read to find out if there's another page
+	keys, err := tx.GetKeys(ctx, start, prefixEnd(req.KeyPrefix), pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > pageSize {
+		resp.NextPageToken = keyPageToken{mapID: req.MapId, revision: root.MapRevision, next: keys[pageSize]}.encode()
+		keys = keys[:pageSize]
+	}
+
+	keyHashes := make([]trillian.Hash, 0, len(keys))
+	for _, key := range keys {
+		keyHashes = append(keyHashes, kh.HashKey(key))
+	}
+
+	leaves, err := tx.Get(ctx, root.MapRevision, keyHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	hashToLeaf := make(map[string]*trillian.MapLeaf)
+	for _, leaf := range leaves {
+		leaf := leaf
+		hashToLeaf[string(leaf.KeyHash)] = &leaf
+	}
+
+	found := make([]trillian.Key, 0, len(keys))
+	for i, key := range keys {
+		leaf, ok := hashToLeaf[string(keyHashes[i])]
+		if !ok {
+			continue
 		}
+		found = append(found, key)
+		resp.KeyValue = append(resp.KeyValue, &trillian.KeyValueInclusion{
+			KeyValue: &trillian.KeyValue{Key: key, Value: leaf},
+		})
+	}
 
-		resp.KeyValue = append(resp.KeyValue, &kvi)
+	if req.IncludeProofs && len(found) > 0 {
+		proofs, err := merkle.NewSparseMerkleTreeReader(root.MapRevision, kh, tx).BatchInclusionProof(ctx, root.MapRevision, found)
+		if err != nil {
+			return nil, err
+		}
+		for i, kvi := range resp.KeyValue {
+			kvi.Inclusion = inclusionProofBytes(proofs[i])
+		}
 	}
 
 	return resp, nil
 }
 
-// SetLeaves implements the SetLeaves RPC method.
+// inclusionProofBytes converts an inclusion proof to the form used in responses.
+func inclusionProofBytes(proof []trillian.Hash) [][]byte {
+	ret := make([][]byte, 0, len(proof))
+	for _, h := range proof {
+		ret = append(ret, []byte(h))
+	}
+	return ret
+}
+
+// SetLeaves implements the SetLeaves RPC method. All of the leaves are written in one
+// transaction along with the new map root, so a request creates exactly one revision and
+// either all of its leaves are set or none are. Requests for the same map are handled one at
+// a time by this server, and a request that loses a race with another server writing the
+// same revision fails with codes.Aborted so it can be retried.
 func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapLeavesRequest) (resp *trillian.SetMapLeavesResponse, err error) {
+	if err := validateKeyValues(req.KeyValue); err != nil {
+		return nil, err
+	}
+
 	s, err := t.getStorageForMap(req.MapId)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.Begin()
+	unlock := t.lockMapForWrite(req.MapId)
+	defer unlock()
+
+	tx, err := s.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return nil, storageStatus(err)
 	}
 	defer func() {
 		if err != nil {
 			// Something went wrong, we should rollback and not return any partial/wrong data
 			resp = nil
 			tx.Rollback()
+			if err == storage.ErrMapRevisionConflict {
+				err = grpc.Errorf(codes.Aborted, "revision %d of map %d was written by another request, retry the request", tx.WriteRevision(), req.MapId)
+			}
 			return
 		}
 		// try to commit the tx
@@ -169,18 +497,28 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 			// don't return partial/uncommited/wrong data:
 			resp = nil
 			err = e
+			return
+		}
+		if t.rootCache != nil {
+			t.rootCache.SetMapRoot(req.MapId, *resp.MapRoot)
+		}
+		// The root is stored so failing to publish it doesn't fail the request
+		if e := t.publisher.PublishMapRoot(*resp.MapRoot); e != nil {
+			logging.Warningf(ctx, "Failed to publish map root at revision %d: %v", resp.MapRoot.MapRevision, e)
 		}
 	}()
 
-	hasher, err := t.getHasherForMap(req.MapId)
+	hasher, err := t.getHasherForMap(s)
 	if err != nil {
 		return nil, err
 	}
 
-	glog.Infof("Writing at revision %d", tx.WriteRevision())
+	logging.Infof(ctx, "Writing at revision %d", tx.WriteRevision())
 
-	smtWriter, err := merkle.NewSparseMerkleTreeWriter(tx.WriteRevision(), hasher, func() (storage.TreeTX, error) {
-		return s.Begin()
+	// The subtree writers share the request's transaction so the nodes they write are
+	// committed, or rolled back, with everything else
+	smtWriter, err := merkle.NewSparseMerkleTreeWriter(ctx, tx.WriteRevision(), hasher, func() (storage.TreeTX, error) {
+		return sharedTX{tx}, nil
 	})
 	if err != nil {
 		return nil, err
@@ -192,7 +530,10 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 		kHash := hasher.HashKey(kv.Key)
 		vHash := hasher.HashLeaf(kv.Value.LeafValue)
 		leaves = append(leaves, merkle.HashKeyValue{kHash, vHash})
-		if err = tx.Set(kHash, *kv.Value); err != nil {
+		if err = tx.Set(ctx, kHash, *kv.Value); err != nil {
+			return nil, err
+		}
+		if err = tx.StoreKey(ctx, kv.Key); err != nil {
 			return nil, err
 		}
 	}
@@ -200,6 +541,9 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 		return nil, err
 	}
 	rootHash, err := smtWriter.CalculateRoot()
+	if err != nil {
+		return nil, err
+	}
 
 	newRoot := trillian.SignedMapRoot{
 		TimestampNanos: time.Now().UnixNano(),
@@ -211,8 +555,16 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 		Signature: &trillian.DigitallySigned{},
 	}
 
-	// TODO(al): need an smtWriter.Rollback() or similar I think.
-	if err = tx.StoreSignedMapRoot(newRoot); err != nil {
+	// Don't store a new root the client has given up on, it will be told the leaves weren't set
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = tx.StoreSignedMapRoot(ctx, newRoot); err != nil {
+		return nil, err
+	}
+	// Record what was set so the map can be rebuilt from its mutations
+	if err = tx.StoreMutation(ctx, trillian.MapMutation{MapRevision: tx.WriteRevision(), KeyValue: req.KeyValue}); err != nil {
 		return nil, err
 	}
 	resp = &trillian.SetMapLeavesResponse{
@@ -221,16 +573,73 @@ func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapL
 	return resp, nil
 }
 
+// validateKeyValues checks the leaves of a SetLeaves request. A key can only be set once in
+// each revision.
+func validateKeyValues(kvs []*trillian.KeyValue) error {
+	keys := make(map[string]bool)
+
+	for _, kv := range kvs {
+		if kv.Value == nil {
+			return fmt.Errorf("no value given for key %x", kv.Key)
+		}
+		if keys[string(kv.Key)] {
+			return fmt.Errorf("key %x is set more than once", kv.Key)
+		}
+		keys[string(kv.Key)] = true
+	}
+
+	return nil
+}
+
+// lockMapForWrite waits until no other request to this server is writing to a map and then
+// stops any others starting until the returned function is called
+func (t *TrillianMapServer) lockMapForWrite(mapID int64) func() {
+	t.storageMapGuard.Lock()
+	l, ok := t.writeLocks[mapID]
+	if !ok {
+		l = &sync.Mutex{}
+		t.writeLocks[mapID] = l
+	}
+	t.storageMapGuard.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// sharedTX lets the subtree writers of a SetLeaves request use its transaction, which
+// storage allows them to do concurrently. Commit and Rollback are left to SetLeaves.
+type sharedTX struct {
+	storage.TreeTX
+}
+
+func (sharedTX) Commit() error {
+	return nil
+}
+
+func (sharedTX) Rollback() error {
+	return nil
+}
+
 // GetSignedMapRoot implements the GetSignedMapRoot RPC method.
 func (t *TrillianMapServer) GetSignedMapRoot(ctx context.Context, req *trillian.GetSignedMapRootRequest) (resp *trillian.GetSignedMapRootResponse, err error) {
+	if t.rootCache != nil {
+		if r, ok := t.rootCache.MapRoot(req.MapId); ok {
+			stale, err := t.checkRootAge(ctx, req.MapId, r)
+			if err != nil {
+				return nil, err
+			}
+			return &trillian.GetSignedMapRootResponse{MapRoot: &r, MapRootStale: stale}, nil
+		}
+	}
+
 	s, err := t.getStorageForMap(req.MapId)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.Snapshot()
+	tx, err := s.Snapshot(ctx)
 	if err != nil {
-		return nil, err
+		return nil, storageStatus(err)
 	}
 	defer func() {
 		// try to commit the tx
@@ -240,17 +649,158 @@ func (t *TrillianMapServer) GetSignedMapRoot(ctx context.Context, req *trillian.
 		}
 	}()
 
-	r, err := tx.LatestSignedMapRoot()
+	r, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.rootCache != nil {
+		t.rootCache.SetMapRoot(req.MapId, r)
+	}
+
+	stale, err := t.checkRootAge(ctx, req.MapId, r)
 	if err != nil {
 		return nil, err
 	}
 
 	resp = &trillian.GetSignedMapRootResponse{
+		MapRoot:      &r,
+		MapRootStale: stale,
+	}
+	return resp, err
+}
+
+// GetSignedMapRootByRevision implements the GetSignedMapRootByRevision RPC method.
+func (t *TrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req *trillian.GetSignedMapRootByRevisionRequest) (resp *trillian.GetSignedMapRootByRevisionResponse, err error) {
+	if req.Revision < 0 {
+		return nil, fmt.Errorf("invalid map revision: %d", req.Revision)
+	}
+
+	s, err := t.getStorageForMap(req.MapId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, storageStatus(err)
+	}
+	defer func() {
+		// try to commit the tx
+		e := tx.Commit()
+		if e != nil && err == nil {
+			resp, err = nil, e
+		}
+	}()
+
+	r, err := tx.GetSignedMapRoot(ctx, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &trillian.GetSignedMapRootByRevisionResponse{
 		MapRoot: &r,
 	}
 	return resp, err
 }
 
+// StreamMutations sends the mutations of a map from a revision onwards to the client in
+// chunks. Only mutations up to the latest signed map root when the request started are sent.
+// Each chunk is read in a separate transaction so a long running stream doesn't keep one
+// open.
+func (t *TrillianMapServer) StreamMutations(req *trillian.StreamMutationsRequest, stream trillian.TrillianMap_StreamMutationsServer) error {
+	if req.StartRevision < 0 {
+		return fmt.Errorf("invalid map revision: %d", req.StartRevision)
+	}
+
+	ctx := stream.Context()
+	s, err := t.getStorageForMap(req.MapId)
+	if err != nil {
+		return err
+	}
+
+	endRevision, err := t.latestRevision(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := int(req.ChunkSize)
+	if chunkSize <= 0 || chunkSize > maxStreamMutationsChunkSize {
+		chunkSize = maxStreamMutationsChunkSize
+	}
+
+	for start := req.StartRevision; start <= endRevision; {
+		// Stop if the client has gone away
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		mutations, err := t.getMutations(ctx, s, start, chunkSize)
+		if err != nil {
+			return err
+		}
+
+		// Drop any written since the request started
+		for len(mutations) > 0 && mutations[len(mutations)-1].MapRevision > endRevision {
+			mutations = mutations[:len(mutations)-1]
+		}
+
+		if len(mutations) == 0 {
+			return nil
+		}
+
+		resp := &trillian.StreamMutationsResponse{Mutations: make([]*trillian.MapMutation, 0, len(mutations))}
+		for i := range mutations {
+			resp.Mutations = append(resp.Mutations, &mutations[i])
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+
+		start = mutations[len(mutations)-1].MapRevision + 1
+	}
+
+	return nil
+}
+
+// latestRevision returns the revision of the latest signed root of a map
+func (t *TrillianMapServer) latestRevision(ctx context.Context, s storage.MapStorage) (revision int64, err error) {
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return 0, storageStatus(err)
+	}
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			revision, err = 0, e
+		}
+	}()
+
+	root, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return root.MapRevision, nil
+}
+
+// getMutations reads up to count mutations from startRevision onwards in its own transaction
+func (t *TrillianMapServer) getMutations(ctx context.Context, s storage.MapStorage, startRevision int64, count int) (mutations []trillian.MapMutation, err error) {
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		return nil, storageStatus(err)
+	}
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			mutations, err = nil, e
+		}
+	}()
+
+	return tx.GetMutations(ctx, startRevision, count)
+}
+
 func buildStatus(code trillian.TrillianApiStatusCode) *trillian.TrillianApiStatus {
 	return &trillian.TrillianApiStatus{StatusCode: code}
 }
@@ -271,3 +821,13 @@ func (t *TrillianMapServer) commitAndLog(tx storage.MapTX, op string) error {
 
 	return err
 }
+
+// storageStatus tells clients to back off with a RESOURCE_EXHAUSTED status if storage is
+// shedding load because its database is unhealthy
+func storageStatus(err error) error {
+	if err == storage.ErrUnhealthy {
+		return grpc.Errorf(codes.ResourceExhausted, "%v", err)
+	}
+
+	return err
+}
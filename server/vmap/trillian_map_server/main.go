@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net"
@@ -13,53 +14,176 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/accounting"
+	"github.com/google/trillian/auth"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/gateway"
+	"github.com/google/trillian/health"
+	"github.com/google/trillian/interceptor"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/config"
 	"github.com/google/trillian/server/vmap"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
+	"github.com/google/trillian/storage/migrate"
 	"github.com/google/trillian/storage/mysql"
+	_ "github.com/google/trillian/storage/postgres"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/publisher"
+	"github.com/google/trillian/util/rootcache"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-var mysqlUriFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
-	"uri to use with mysql storage")
+var configFileFlag = flag.String("config_file", "", "File containing a config.MapServerConfig in protobuf text format that sets the flags it covers, flags given on the command line override it. Its log verbosity is applied again on SIGHUP")
+var validateConfigFlag = flag.Bool("validate_config", false, "If true the settings given by flags and config_file are checked, along with the key and TLS files they name, and the server exits without starting")
+var storageSystemFlag = flag.String("storage_system", mysql.ProviderName, "Name of the registered storage system to use")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
+	"uri to use with the selected storage system")
 var serverPortFlag = flag.Int("port", 8091, "Port to serve map requests on")
+var sharedCacheMaxBytesFlag = flag.Int64("shared_subtree_cache_max_bytes", 0, "Approximate max bytes of subtree hashes cached between requests, shared by all trees, so that the subtrees near the top of each tree aren't read from storage by every request. 0 disables the shared cache")
+var subtreeKeyVersionFlag = flag.Int("subtree_key_version", 0, "Version of the suffix key encoding that subtrees are written with, see storage/suffix_key.go. Subtrees of every version are read whatever the setting, so only raise it once all the servers sharing the storage can read the new version")
+var cacheMaxSubtreesFlag = flag.Int("subtree_cache_max_subtrees", 0, "Max number of subtrees cached per transaction, 0 for no limit")
+var cacheMaxBytesFlag = flag.Int64("subtree_cache_max_bytes", 0, "Approximate max bytes of subtree hashes cached per transaction, 0 for no limit")
+var rootPublishersFlag = flag.String("root_publishers", "", "Comma separated file:// or http(s):// destinations each new signed map root is published to")
+var publishAttemptsFlag = flag.Int("publish_attempts", 3, "Number of times to try publishing each root before giving up")
+var publishBackoffFlag = flag.Duration("publish_backoff", time.Second, "Time to wait before retrying a failed publish, doubled after each further failure")
+var publishTimeoutFlag = flag.Duration("publish_timeout", time.Second*10, "Deadline for each attempt to publish a root over HTTP")
+var publishQueueSizeFlag = flag.Int("publish_queue_size", 100, "Number of roots that can be waiting to be published in the background, roots signed while it's full aren't published")
+var metricsEndpointFlag = flag.String("metrics_endpoint", "", "Address to serve Prometheus metrics on at /metrics, e.g. :8094, metrics aren't served if empty")
+var healthEndpointFlag = flag.String("health_endpoint", "", "Address to serve liveness on at /healthz and readiness on at /readyz, e.g. :8096, they aren't served over HTTP if empty. Readiness is always served by the gRPC health service")
+var healthCheckTimeoutFlag = flag.Duration("health_check_timeout", time.Second*5, "Longest each health check can take before it's counted as failed")
+var healthCheckPeriodFlag = flag.Duration("health_check_period", time.Second*10, "How often readiness is checked for the gRPC health service")
+var shutdownTimeoutFlag = flag.Duration("shutdown_timeout", time.Second*30, "Longest to wait on shutdown for RPCs in flight to finish, and then for the tree usage to be stored, before they're abandoned and their transactions rolled back")
+var traceSampleRateFlag = flag.Float64("trace_sample_rate", 0, "Fraction of requests to trace, between 0 and 1. Requests traced by the client are traced regardless, if this is above 0")
+var httpEndpointFlag = flag.String("http_endpoint", "", "Address to serve the map APIs on as REST with JSON bodies, e.g. :8098, forwarding requests to the RPC server. It's served over TLS if tls_cert_file is set. Requests through it are made without a client certificate, so they only get the permissions granted to *. The gateway isn't served if empty")
+var tlsCertFileFlag = flag.String("tls_cert_file", "", "File containing the PEM encoded certificate to serve map requests over TLS with, TLS isn't used if empty")
+var tlsKeyFileFlag = flag.String("tls_key_file", "", "File containing the PEM encoded private key for tls_cert_file")
+var tlsClientCAFileFlag = flag.String("tls_client_ca_file", "", "File containing the PEM encoded CAs that must have signed client certificates, clients needn't present certificates if empty")
+var grantsFlag = flag.String("grants", "", "Permissions of clients as a comma separated list of identity/tree=permissions, where identity is a client certificate common name or * for all clients, tree is a tree ID or * for all trees and permissions are read, write or admin separated by +. Clients aren't checked if empty or auth isn't one of the interceptors")
+var interceptorsFlag = flag.String("interceptors", "monitoring,recovery,logging,auth,accounting", "Comma separated interceptors that requests pass through in order before they're handled, from those registered with the interceptor package. auth checks the permissions given by grants and accounting counts the usage of each tree")
+var accountingPeriodFlag = flag.Duration("accounting_period", time.Minute, "How often the usage of each tree counted by the accounting interceptor is added to storage")
+var rootCacheTTLFlag = flag.Duration("root_cache_ttl", time.Second, "How long the latest signed root of each map is served from memory before it's read from storage again, roots written through this instance replace it straight away. 0 disables the cache")
+var treeMaxRootAgesFlag = flag.String("tree_max_root_ages", "", "Comma separated treeID=duration list of the oldest the latest root of each map can be, e.g. 1=1h. Reads of the latest root served from an older one are flagged as stale and counted. Maps that aren't listed are never stale")
+var strictMaxRootAgeFlag = flag.Bool("strict_max_root_age", false, "If true reads of the latest root of a map are refused with FAILED_PRECONDITION, rather than flagged, when the root is older than the map's max root age")
+var dbMaxOpenConnsFlag = flag.Int("db_max_open_conns", 0, "Most connections open to the database at once, shared by all trees. Transactions wait up to db_query_timeout for one to be free. 0 means there's no limit")
+var dbMaxIdleConnsFlag = flag.Int("db_max_idle_conns", 0, "Most unused connections kept open to the database, 0 uses the driver's default")
+var dbConnMaxLifetimeFlag = flag.Duration("db_conn_max_lifetime", 0, "How long a database connection is reused for before it's closed, 0 means connections are kept open")
+var dbQueryTimeoutFlag = flag.Duration("db_query_timeout", 0, "Longest a transaction waits for a database connection and that each query can run, 0 means they're only limited by the deadlines of requests")
+var dbBreakerFailuresFlag = flag.Int("db_breaker_failures", 0, "Database failures in a row after which requests are refused with RESOURCE_EXHAUSTED until it recovers, rather than queued up for it. 0 disables the circuit breaker")
+var dbBreakerOpenDurationFlag = flag.Duration("db_breaker_open_duration", time.Second*5, "How long requests are refused for once the circuit breaker opens, before one is let through to test the database again")
+var autoMigrateFlag = flag.Bool("auto_migrate", false, "If true any schema migrations the database is missing are applied at startup, otherwise the server refuses to start until they've been applied with cmd/migrate")
+var subtreeShardsFlag = flag.String("subtree_shards", "", "Databases that the subtrees of trees created by this server are spread over, as a comma separated list of name=dsn. Trees keep the names of their shards, so a name must stay for as long as trees use it, though its DSN can change. Only supported by mysql")
 
 // TODO(Martin2112): Single private key doesn't really work for multi tenant and we can't use
 // an HSM interface in this way. Deferring these issues for later.
 var privateKeyFile = flag.String("private_key_file", "", "File containing a PEM encoded private key")
 var privateKeyPassword = flag.String("private_key_password", "", "Password for server private key")
 
+// reloadableFlags are the flags set from config_file again when it's reloaded, the others
+// keep the values they had at startup
+var reloadableFlags = []string{"v"}
+
+// The flags given on the command line, which reloading config_file doesn't change
+var commandLineFlags map[string]bool
+
 var mapMutex sync.Mutex
 var mapStorage = make(map[int64]storage.MapStorage)
 
+// The provider for the storage system selected by flags, set up in main
+var storageProvider storage.Provider
+
+// The subtree cache shared by the storage for all the maps, or nil if it's disabled
+var sharedSubtreeCache *cache.SharedSubtreeCache
+
+// Tracks the accounting loop, which stores the tree usage so far once done is closed
+var background sync.WaitGroup
+
 // TODO(Martin2112): Needs a more realistic provider of map storage with some caching
-// and ability to swap out for different storage type
-func simpleMySqlStorageProvider(treeID int64) (storage.MapStorage, error) {
+func simpleStorageProvider(treeID int64) (storage.MapStorage, error) {
 	mapMutex.Lock()
 	defer mapMutex.Unlock()
 
 	s := mapStorage[treeID]
 	if s == nil {
 		var err error
-		s, err = mysql.NewMapStorage(trillian.MapID{[]byte("TODO"), treeID}, *mysqlUriFlag)
+		s, err = storageProvider.MapStorage(trillian.MapID{[]byte("TODO"), treeID})
 		if err != nil {
 			return nil, err
 		}
+		if ls, ok := s.(cache.CacheLimitSetter); ok {
+			ls.SetSubtreeCacheLimits(cache.CacheLimits{MaxSubtrees: *cacheMaxSubtreesFlag, MaxBytes: *cacheMaxBytesFlag})
+		} else if *cacheMaxSubtreesFlag > 0 || *cacheMaxBytesFlag > 0 {
+			glog.Warningf("Storage for map %d does not support subtree cache limits", treeID)
+		}
+		if ks, ok := s.(cache.SubtreeKeyVersionSetter); ok {
+			ks.SetSubtreeKeyVersion(int32(*subtreeKeyVersionFlag))
+		} else if *subtreeKeyVersionFlag != 0 {
+			glog.Warningf("Storage for map %d can't write subtree key version %d", treeID, *subtreeKeyVersionFlag)
+		}
+		if sharedSubtreeCache != nil {
+			if cs, ok := s.(cache.SharedCacheSetter); ok {
+				cs.SetSharedSubtreeCache(sharedSubtreeCache)
+			} else {
+				glog.Warningf("Storage for map %d can't use the shared subtree cache", treeID)
+			}
+		}
 		mapStorage[treeID] = s
 	}
 	return s, nil
 }
 
-func checkDatabaseAccessible(dbUri string) error {
+// connectionConfig returns the database connection limits set by flags
+func connectionConfig() storage.ConnectionConfig {
+	config := storage.ConnectionConfig{
+		MaxOpenConns:    *dbMaxOpenConnsFlag,
+		MaxIdleConns:    *dbMaxIdleConnsFlag,
+		ConnMaxLifetime: *dbConnMaxLifetimeFlag,
+		QueryTimeout:    *dbQueryTimeoutFlag,
+	}
+
+	if *dbBreakerFailuresFlag != 0 {
+		config.Breaker = storage.CircuitBreakerConfig{Failures: *dbBreakerFailuresFlag, OpenDuration: *dbBreakerOpenDurationFlag}
+	}
+
+	return config
+}
+
+// checkSchema applies the schema migrations the database is missing if auto_migrate is set,
+// otherwise it returns an error if there are any
+func checkSchema(provider storage.Provider) error {
+	db, migrations, err := storage.Migrations(provider)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if !*autoMigrateFlag {
+		return migrate.Check(ctx, db, migrations)
+	}
+
+	applied, err := migrate.Run(ctx, db, migrations)
+	glog.Infof("Applied %d schema migrations", applied)
+
+	return err
+}
+
+func checkDatabaseAccessible(ctx context.Context, provider storage.Provider) error {
 	// TODO(Martin2112): Have to pass a tree ID when we just want metadata. API mismatch
-	storage, err := mysql.NewMapStorage(trillian.MapID{[]byte("TODO"), int64(0)}, dbUri)
+	storage, err := provider.MapStorage(trillian.MapID{[]byte("TODO"), int64(0)})
 
 	if err != nil {
 		// This is probably something fundamentally wrong
 		return err
 	}
 
-	tx, err := storage.Begin()
+	tx, err := storage.Begin(ctx)
 
 	if err != nil {
 		// Out of resources maybe?
@@ -71,15 +195,190 @@ func checkDatabaseAccessible(dbUri string) error {
 	return nil
 }
 
-func startRpcServer(listener net.Listener, port int, provider vmap.MapStorageProviderFunc) *grpc.Server {
-	grpcServer := grpc.NewServer()
+// createHealthChecker returns the checks of whether the server is ready to serve requests:
+// that it can reach the database and use the map key
+func createHealthChecker(keyManager crypto.KeyManager) *health.Checker {
+	checker := health.NewChecker(*healthCheckTimeoutFlag)
+	checker.AddReadinessCheck("database", func(ctx context.Context) error {
+		return checkDatabaseAccessible(ctx, storageProvider)
+	})
+	checker.AddReadinessCheck("key", health.KeyCheck(keyManager))
+
+	return checker
+}
+
+// createPublisher returns the publisher that new roots are sent to, which publishes nowhere
+// unless destinations have been configured by flags
+func createPublisher() (publisher.Publisher, error) {
+	if len(*rootPublishersFlag) == 0 {
+		return publisher.None{}, nil
+	}
+
+	p, err := publisher.NewFromURIs(*rootPublishersFlag, *publishTimeoutFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := publisher.NewRetrying(p, *publishAttemptsFlag, *publishBackoffFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Roots are published in the background so a slow destination doesn't hold up signing
+	return publisher.NewAsync(r, *publishQueueSizeFlag)
+}
+
+func init() {
+	if err := interceptor.Register("auth", newAuthInterceptor); err != nil {
+		panic(err)
+	}
+}
+
+// createServer returns an RPC server that uses TLS if configured by flags, and passes
+// requests through the interceptors they list
+func createServer() (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+
+	if len(*tlsCertFileFlag) > 0 {
+		config, err := auth.NewServerTLSConfig(*tlsCertFileFlag, *tlsKeyFileFlag, *tlsClientCAFileFlag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	} else if len(*tlsClientCAFileFlag) > 0 {
+		return nil, errors.New("client certificates can't be checked without tls_cert_file")
+	}
+
+	chain, err := interceptor.NewChain(*interceptorsFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return interceptor.NewServer(chain, opts...), nil
+}
+
+// newAuthInterceptor checks clients have the permissions given to them by flags, it lets
+// all requests through if there aren't any
+func newAuthInterceptor() (interceptor.Interceptor, error) {
+	if len(*grantsFlag) == 0 {
+		return interceptor.Interceptor{}, nil
+	}
+
+	grants, err := auth.ParseGrants(*grantsFlag)
+
+	if err != nil {
+		return interceptor.Interceptor{}, err
+	}
+
+	return interceptor.Interceptor{Unary: auth.UnaryServerInterceptor(grants), Stream: auth.StreamServerInterceptor(grants)}, nil
+}
+
+// startAccounting counts the usage of trees by requests through the accounting interceptor
+// and adds it to storage as often as flags say
+func startAccounting(done chan struct{}, sp accounting.StorageProviderFunc) (*accounting.Accountant, error) {
+	accountant := accounting.NewAccountant(done, sp, *accountingPeriodFlag, util.SystemTimeSource{})
+
+	// The interceptor can only be registered once there's an accountant for it to count with
+	err := interceptor.Register("accounting", func() (interceptor.Interceptor, error) {
+		return interceptor.Interceptor{Unary: accountant.UnaryServerInterceptor(), Stream: accountant.StreamServerInterceptor()}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	runInBackground(accountant.Run)
+	return accountant, nil
+}
+
+func startRpcServer(listener net.Listener, port int, provider vmap.MapStorageProviderFunc, p publisher.Publisher) (*grpc.Server, error) {
+	grpcServer, err := createServer()
+
+	if err != nil {
+		return nil, err
+	}
+
 	mapServer := vmap.NewTrillianMapServer(provider)
+	mapServer.SetPublisher(p)
+
+	if *rootCacheTTLFlag > 0 {
+		mapServer.SetRootCache(rootcache.NewCache(*rootCacheTTLFlag, util.SystemTimeSource{}))
+	}
+
+	maxRootAges, err := server.ParseMaxRootAges(*treeMaxRootAgesFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mapServer.SetMaxRootAges(maxRootAges, *strictMaxRootAgeFlag)
 	trillian.RegisterTrillianMapServer(grpcServer, mapServer)
 
-	return grpcServer
+	return grpcServer, nil
 }
 
-func awaitSignal(rpcServer *grpc.Server) {
+// startGateway serves the REST gateway to the map APIs on http_endpoint. It forwards requests
+// to the RPC server on port until done is closed.
+func startGateway(done <-chan struct{}, port int) error {
+	opts, err := gateway.DialOptions(*tlsCertFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h, err := gateway.NewHandler(ctx, fmt.Sprintf("localhost:%d", port), opts, trillian.RegisterTrillianMapHandlerFromEndpoint)
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	gateway.StartServer(*httpEndpointFlag, h, *tlsCertFileFlag, *tlsKeyFileFlag)
+	return nil
+}
+
+// runInBackground runs f in a goroutine that waitForBackground waits for
+func runInBackground(f func(context.Context)) {
+	background.Add(1)
+
+	go func() {
+		defer background.Done()
+		f(context.Background())
+	}()
+}
+
+// waitForBackground waits up to timeout for the goroutines started by runInBackground to
+// return, and reports whether they did
+func waitForBackground(timeout time.Duration) bool {
+	finished := make(chan struct{})
+
+	go func() {
+		background.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func awaitSignal(rpcServer *grpc.Server, healthChecker *health.Checker, drained chan<- struct{}) {
+	defer close(drained)
+
 	// Arrange notification for the standard set of signals used to terminate a server
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -88,19 +387,197 @@ func awaitSignal(rpcServer *grpc.Server) {
 	sig := <-sigs
 	glog.Infof("Signal received: %v", sig)
 
-	// Bring down the RPC server, which will unblock main
-	rpcServer.Stop()
+	// Stop being ready and bring down the RPC server, which will unblock main. Leaves being
+	// set are let finish for a while, after that their RPCs are cancelled, which rolls back
+	// their transactions rather than leaving subtrees partly written.
+	healthChecker.SetShuttingDown()
+	stopped := make(chan struct{})
+
+	go func() {
+		rpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(*shutdownTimeoutFlag):
+		glog.Warningf("RPCs still in flight after %v, cancelling them", *shutdownTimeoutFlag)
+		rpcServer.Stop()
+	}
+}
+
+// applyConfigFile sets the flags that aren't given on the command line from the config file
+// named by config_file, if there is one
+func applyConfigFile() error {
+	if len(*configFileFlag) == 0 {
+		return nil
+	}
+
+	cfg, err := config.LoadMapServerConfig(*configFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	return config.SetFlags(flag.CommandLine, config.MapServerFlags(cfg))
+}
+
+// reloadSettings reads config_file again and applies the reloadable flags it sets, which
+// glog puts into effect as they're set
+func reloadSettings() error {
+	if len(*configFileFlag) == 0 {
+		return errors.New("there's no config_file to reload")
+	}
+
+	cfg, err := config.LoadMapServerConfig(*configFileFlag)
+
+	if err != nil {
+		return err
+	}
+
+	return config.ReloadFlags(flag.CommandLine, config.MapServerFlags(cfg), commandLineFlags, reloadableFlags, func() error { return nil })
+}
+
+// reloadOnSignal reloads the settings each time the server is sent SIGHUP, until done is
+// closed
+func reloadOnSignal(done <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-sigs:
+		}
+
+		if err := reloadSettings(); err != nil {
+			glog.Warningf("Failed to reload config: %v", err)
+			continue
+		}
+
+		glog.Infof("Reloaded settings from %s", *configFileFlag)
+	}
+}
+
+// validateSettings checks the settings given by flags, parsing them as the server would
+// when it uses them and reading the files they name, but without connecting to anything
+func validateSettings() error {
+	if *subtreeKeyVersionFlag < 0 || *subtreeKeyVersionFlag > int(storage.MaxSuffixKeyVersion) {
+		return fmt.Errorf("unknown subtree key version %d, must be between 0 and %d", *subtreeKeyVersionFlag, storage.MaxSuffixKeyVersion)
+	}
+
+	found := false
+	for _, name := range storage.Providers() {
+		found = found || name == *storageSystemFlag
+	}
+	if !found {
+		return fmt.Errorf("unknown storage system %s (registered: %v)", *storageSystemFlag, storage.Providers())
+	}
+
+	if _, err := storage.ParseSubtreeShards(*subtreeShardsFlag); err != nil {
+		return err
+	}
+
+	if _, err := auth.ParseGrants(*grantsFlag); err != nil {
+		return err
+	}
+
+	if _, err := server.ParseMaxRootAges(*treeMaxRootAgesFlag); err != nil {
+		return err
+	}
+
+	if len(*tlsCertFileFlag) > 0 {
+		if _, err := auth.NewServerTLSConfig(*tlsCertFileFlag, *tlsKeyFileFlag, *tlsClientCAFileFlag); err != nil {
+			return err
+		}
+	}
+
+	if len(*httpEndpointFlag) > 0 {
+		if len(*tlsClientCAFileFlag) > 0 {
+			return errors.New("the REST gateway can't present a client certificate, so http_endpoint can't be used with tls_client_ca_file")
+		}
+
+		if _, err := gateway.DialOptions(*tlsCertFileFlag); err != nil {
+			return err
+		}
+	}
+
+	_, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+	return err
 }
 
 func main() {
 	flag.Parse()
+	commandLineFlags = config.GivenFlags(flag.CommandLine)
+
+	if err := applyConfigFile(); err != nil {
+		glog.Errorf("Could not apply config file %s: %v", *configFileFlag, err)
+		os.Exit(1)
+	}
+
+	if *validateConfigFlag {
+		if err := validateSettings(); err != nil {
+			glog.Errorf("Invalid settings: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Settings are valid")
+		return
+	}
 
 	done := make(chan struct{})
 
 	glog.Info("**** Map Server Starting ****")
 
+	if len(*metricsEndpointFlag) > 0 {
+		monitoring.StartServer(*metricsEndpointFlag)
+	}
+
+	if *traceSampleRateFlag > 0 {
+		defer monitoring.InitTracing(*traceSampleRateFlag)()
+	}
+
+	if *subtreeKeyVersionFlag < 0 || *subtreeKeyVersionFlag > int(storage.MaxSuffixKeyVersion) {
+		glog.Errorf("Unknown subtree key version %d, must be between 0 and %d", *subtreeKeyVersionFlag, storage.MaxSuffixKeyVersion)
+		os.Exit(1)
+	}
+
+	if *sharedCacheMaxBytesFlag > 0 {
+		sharedSubtreeCache = cache.NewSharedSubtreeCache(cache.CacheLimits{MaxBytes: *sharedCacheMaxBytesFlag})
+	}
+
+	// Set up the selected storage system, quit if it's not available
+	var err error
+	storageProvider, err = storage.NewProvider(*storageSystemFlag, *storageUriFlag)
+
+	if err != nil {
+		glog.Errorf("Could not create storage provider %s (registered: %v): %v", *storageSystemFlag, storage.Providers(), err)
+		os.Exit(1)
+	}
+
+	if err := storage.SetConnectionConfig(storageProvider, connectionConfig()); err != nil {
+		glog.Errorf("Could not limit database connections: %v", err)
+		os.Exit(1)
+	}
+
+	shards, err := storage.ParseSubtreeShards(*subtreeShardsFlag)
+	if err == nil {
+		err = storage.SetSubtreeShards(storageProvider, shards)
+	}
+	if err != nil {
+		glog.Errorf("Could not set subtree shards: %v", err)
+		os.Exit(1)
+	}
+
+	if err := checkSchema(storageProvider); err != nil {
+		glog.Errorf("Could not check database schema, it may need migrating with cmd/migrate or --auto_migrate: %v", err)
+		os.Exit(1)
+	}
+
 	// First make sure we can access the database, quit if not
-	if err := checkDatabaseAccessible(*mysqlUriFlag); err != nil {
+	if err := checkDatabaseAccessible(context.Background(), storageProvider); err != nil {
 		glog.Errorf("Could not access storage, check db configuration and flags")
 		os.Exit(1)
 	}
@@ -108,12 +585,18 @@ func main() {
 	// Load up our private key, exit if this fails to work
 	// TODO(Martin2112): This will need to be changed for multi tenant as we'll need at
 	// least one key per tenant, possibly more.
-	_, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+	keyManager, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
 
 	if err != nil {
 		glog.Fatalf("Failed to load map server key: %v", err)
 	}
 
+	rootPublisher, err := createPublisher()
+
+	if err != nil {
+		glog.Fatalf("Failed to create root publisher: %v", err)
+	}
+
 	// Set up the listener for the server
 	glog.Infof("Creating RPC server starting on port: %d", *serverPortFlag)
 	// TODO(Martin2112): More flexible listen address configuration
@@ -124,9 +607,46 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Tree usage is stored with the tree metadata
+	adminStorage, err := storageProvider.AdminStorage()
+
+	if err != nil {
+		glog.Errorf("Could not create admin storage: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := startAccounting(done, func() (storage.AdminStorage, error) { return adminStorage, nil }); err != nil {
+		glog.Errorf("Failed to start tree accounting: %v", err)
+		os.Exit(1)
+	}
+
 	// Bring up the RPC server and then block until we get a signal to stop
-	rpcServer := startRpcServer(lis, *serverPortFlag, simpleMySqlStorageProvider)
-	go awaitSignal(rpcServer)
+	rpcServer, err := startRpcServer(lis, *serverPortFlag, simpleStorageProvider, rootPublisher)
+
+	if err != nil {
+		glog.Errorf("Failed to create RPC server: %v", err)
+		os.Exit(1)
+	}
+
+	// Let load balancers and orchestrators hold back requests until the server can handle them
+	healthChecker := createHealthChecker(keyManager)
+	health.RegisterGRPCServer(done, rpcServer, healthChecker, *healthCheckPeriodFlag)
+
+	if len(*healthEndpointFlag) > 0 {
+		health.StartServer(*healthEndpointFlag, healthChecker)
+	}
+
+	if len(*httpEndpointFlag) > 0 {
+		if err := startGateway(done, *serverPortFlag); err != nil {
+			glog.Errorf("Failed to start REST gateway: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	go reloadOnSignal(done)
+
+	drained := make(chan struct{})
+	go awaitSignal(rpcServer, healthChecker, drained)
 	err = rpcServer.Serve(lis)
 
 	if err != nil {
@@ -134,10 +654,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Serve returns once no more RPCs are accepted, the ones in flight may still be running
+	<-drained
+
 	// Shut down everything we previously started, rpc server is already down
 	close(done)
 
-	// Give things a few seconds to tidy up
+	if !waitForBackground(*shutdownTimeoutFlag) {
+		glog.Warningf("Tree usage still being stored after %v, abandoning it", *shutdownTimeoutFlag)
+	}
+
 	glog.Infof("Stopping map server, about to exit")
-	time.Sleep(time.Second * 5)
 }
@@ -0,0 +1,688 @@
+package vmap
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/rootcache"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var mapRoot5 = trillian.SignedMapRoot{MapId: []byte("map"), TimestampNanos: 98765, MapRevision: 5, RootHash: []byte("root")}
+
+func mockStorageProviderForMap(mockStorage storage.MapStorage) MapStorageProviderFunc {
+	return func(id int64) (storage.MapStorage, error) {
+		return mockStorage, nil
+	}
+}
+
+func TestGetSignedMapRootCached(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Only the first request reads the root from storage
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(mapRoot5, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	server.SetRootCache(rootcache.NewCache(time.Minute, util.FakeTimeSource{FakeTime: time.Unix(0, 0)}))
+
+	for i := 0; i < 2; i++ {
+		resp, err := server.GetSignedMapRoot(context.Background(), &trillian.GetSignedMapRootRequest{MapId: 1})
+
+		if err != nil {
+			t.Fatalf("Failed to get map root: %v", err)
+		}
+
+		if !proto.Equal(resp.MapRoot, &mapRoot5) {
+			t.Fatalf("Got map root %v but expected %v", resp.MapRoot, mapRoot5)
+		}
+	}
+}
+
+func TestGetSignedMapRootStale(t *testing.T) {
+	// mapRoot5 is a minute old
+	now := time.Unix(0, mapRoot5.TimestampNanos).Add(time.Minute)
+
+	for _, test := range []struct {
+		desc      string
+		maxAges   map[int64]time.Duration
+		strict    bool
+		wantStale bool
+		wantErr   bool
+	}{
+		{desc: "no max age"},
+		{desc: "other map", maxAges: map[int64]time.Duration{2: time.Second}},
+		{desc: "fresh", maxAges: map[int64]time.Duration{1: time.Hour}, strict: true},
+		{desc: "stale", maxAges: map[int64]time.Duration{1: time.Second}, wantStale: true},
+		{desc: "stale strict", maxAges: map[int64]time.Duration{1: time.Second}, strict: true, wantErr: true},
+	} {
+		mockCtrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockMapStorage(mockCtrl)
+		mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+		mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(mapRoot5, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+
+		server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+		server.timeSource = util.FakeTimeSource{FakeTime: now}
+		server.SetMaxRootAges(test.maxAges, test.strict)
+
+		staleBefore, refusedBefore := staleMapRootReads.Value("1"), refusedMapRootReads.Value("1")
+		resp, err := server.GetSignedMapRoot(context.Background(), &trillian.GetSignedMapRootRequest{MapId: 1})
+
+		if test.wantErr {
+			if grpc.Code(err) != codes.FailedPrecondition {
+				t.Errorf("%s: got error %v, want FAILED_PRECONDITION", test.desc, err)
+			}
+			if got := refusedMapRootReads.Value("1") - refusedBefore; got != 1 {
+				t.Errorf("%s: counted %v refused reads, want 1", test.desc, got)
+			}
+		} else if err != nil {
+			t.Errorf("%s: failed to get map root: %v", test.desc, err)
+		} else if resp.MapRootStale != test.wantStale {
+			t.Errorf("%s: got stale %v, want %v", test.desc, resp.MapRootStale, test.wantStale)
+		}
+
+		wantStaleReads := 0.0
+		if test.wantStale {
+			wantStaleReads = 1
+		}
+		if got := staleMapRootReads.Value("1") - staleBefore; got != wantStaleReads {
+			t.Errorf("%s: counted %v stale reads, want %v", test.desc, got, wantStaleReads)
+		}
+		if got := mapRootAge.Value("1"); got != 60 {
+			t.Errorf("%s: got root age %v, want 60s", test.desc, got)
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestGetLeavesRefusesStaleRoot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// No leaves are read once the root is found to be stale
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTreeTX(mockCtrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(-1)).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(mapRoot5, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	server.timeSource = util.FakeTimeSource{FakeTime: time.Unix(0, mapRoot5.TimestampNanos).Add(time.Hour)}
+	server.SetMaxRootAges(map[int64]time.Duration{1: time.Minute}, true)
+
+	_, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: 1, Key: [][]byte{[]byte("one")}, Revision: -1})
+	if grpc.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Got error %v reading from a stale root, want FAILED_PRECONDITION", err)
+	}
+}
+
+func TestGetSignedMapRootByRevision(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(5)).Return(mapRoot5, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	resp, err := server.GetSignedMapRootByRevision(context.Background(), &trillian.GetSignedMapRootByRevisionRequest{MapId: 1, Revision: 5})
+
+	if err != nil {
+		t.Fatalf("Failed to get map root: %v", err)
+	}
+
+	if !proto.Equal(resp.MapRoot, &mapRoot5) {
+		t.Fatalf("Got map root %v but expected %v", resp.MapRoot, mapRoot5)
+	}
+}
+
+func TestGetSignedMapRootByRevisionNotFound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(6)).Return(trillian.SignedMapRoot{}, storage.ErrMapRootNotFound)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	if _, err := server.GetSignedMapRootByRevision(context.Background(), &trillian.GetSignedMapRootByRevisionRequest{MapId: 1, Revision: 6}); err != storage.ErrMapRootNotFound {
+		t.Fatalf("Expected %v for a revision without a root, got: %v", storage.ErrMapRootNotFound, err)
+	}
+}
+
+func TestGetSignedMapRootByRevisionInvalidRevision(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed for an invalid request
+	server := NewTrillianMapServer(mockStorageProviderForMap(storage.NewMockMapStorage(mockCtrl)))
+
+	if _, err := server.GetSignedMapRootByRevision(context.Background(), &trillian.GetSignedMapRootByRevisionRequest{MapId: 1, Revision: -1}); err == nil {
+		t.Fatal("Returned a map root for a negative revision")
+	}
+}
+
+func TestGetLeavesReturnsEveryKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	th, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	hasher := merkle.NewMapHasher(th)
+	present, absent := []byte("present"), []byte("absent")
+	leaf := trillian.MapLeaf{KeyHash: hasher.HashKey(present), LeafValue: []byte("value")}
+
+	// present is the only key in the map so none of its siblings are stored
+	hs2 := merkle.NewHStar2(th)
+	rootHash, err := hs2.HStar2Root(th.Size()*8, []merkle.HStar2LeafHash{{Index: new(big.Int).SetBytes(leaf.KeyHash), LeafHash: th.HashLeaf(leaf.LeafValue)}})
+	if err != nil {
+		t.Fatalf("Failed to calculate root: %v", err)
+	}
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTreeTX(mockCtrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(-1)).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(trillian.SignedMapRoot{MapRevision: 5, RootHash: rootHash}, nil)
+	mockTx.EXPECT().Get(gomock.Any(), int64(5), gomock.Any()).Return([]trillian.MapLeaf{leaf}, nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	resp, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: 1, Key: [][]byte{absent, present}, Revision: -1})
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if got, want := len(resp.KeyValue), 2; got != want {
+		t.Fatalf("Got %d leaves, expected %d", got, want)
+	}
+
+	for i, key := range [][]byte{absent, present} {
+		if got := resp.KeyValue[i].KeyValue.Key; !bytes.Equal(got, key) {
+			t.Errorf("Got key %s for entry %d, expected %s", got, i, key)
+		}
+		if got, want := len(resp.KeyValue[i].Inclusion), th.Size()*8; got != want {
+			t.Errorf("Got inclusion proof of length %d for entry %d, expected %d", got, i, want)
+		}
+	}
+
+	if got := resp.KeyValue[0].KeyValue.Value; got != nil {
+		t.Errorf("Got value %v for absent key, expected nil", got)
+	}
+
+	if got, want := resp.KeyValue[1].KeyValue.Value, &leaf; !proto.Equal(got, want) {
+		t.Errorf("Got value %v for present key, expected %v", got, want)
+	}
+
+	v := merkle.NewMapVerifier(hasher)
+	if err := v.VerifyMapInclusion(rootHash, present, leaf.LeafValue, resp.KeyValue[1].Inclusion); err != nil {
+		t.Errorf("Failed to verify inclusion of present key: %v", err)
+	}
+}
+
+func TestGetLeavesProvesAbsenceInEmptyMap(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	th, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	hs2 := merkle.NewHStar2(th)
+	emptyRoot, err := hs2.HStar2Root(th.Size()*8, nil)
+	if err != nil {
+		t.Fatalf("Failed to calculate empty root: %v", err)
+	}
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTreeTX(mockCtrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(3)).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(3)).Return(trillian.SignedMapRoot{MapRevision: 3, RootHash: emptyRoot}, nil)
+	mockTx.EXPECT().Get(gomock.Any(), int64(3), gomock.Any()).Return([]trillian.MapLeaf{}, nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(3), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	keys := [][]byte{[]byte("one"), []byte("two")}
+	resp, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: 1, Key: keys, Revision: 3})
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if got, want := len(resp.KeyValue), len(keys); got != want {
+		t.Fatalf("Got %d leaves, expected %d", got, want)
+	}
+
+	v := merkle.NewMapVerifier(merkle.NewMapHasher(th))
+	for i, kvi := range resp.KeyValue {
+		if kvi.KeyValue.Value != nil {
+			t.Errorf("Got value %v for key %s, expected nil", kvi.KeyValue.Value, keys[i])
+		}
+		if err := v.VerifyMapInclusion(resp.MapRoot.RootHash, keys[i], nil, kvi.Inclusion); err != nil {
+			t.Errorf("Failed to verify absence of key %s: %v", keys[i], err)
+		}
+	}
+}
+
+func TestGetLeavesByPrefixInPages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	th, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	hasher := merkle.NewMapHasher(th)
+	first, cleared, last := trillian.Key("a/1"), trillian.Key("a/2"), trillian.Key("a/3")
+	leaves := map[string]trillian.MapLeaf{
+		string(first): {KeyHash: hasher.HashKey(first), LeafValue: []byte("value 1")},
+		string(last):  {KeyHash: hasher.HashKey(last), LeafValue: []byte("value 3")},
+	}
+
+	// The proof of first is checked against a root of it alone, so none of its siblings are stored
+	hs2 := merkle.NewHStar2(th)
+	rootHash, err := hs2.HStar2Root(th.Size()*8, []merkle.HStar2LeafHash{{Index: new(big.Int).SetBytes(leaves[string(first)].KeyHash), LeafHash: th.HashLeaf(leaves[string(first)].LeafValue)}})
+	if err != nil {
+		t.Fatalf("Failed to calculate root: %v", err)
+	}
+	root := trillian.SignedMapRoot{MapRevision: 5, RootHash: rootHash}
+
+	// The second page is read at the revision of the first, even though the map has grown
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockStorage.EXPECT().HashAlgorithm().Times(2).Return(trillian.HashAlgorithm_SHA256)
+	mockTx := storage.NewMockReadOnlyMapTreeTX(mockCtrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(-1)).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(root, nil)
+	mockTx.EXPECT().GetKeys(gomock.Any(), trillian.Key("a/"), trillian.Key("a0"), 3).Return([]trillian.Key{first, cleared, last}, nil)
+	mockTx.EXPECT().Get(gomock.Any(), int64(5), []trillian.Hash{hasher.HashKey(first), hasher.HashKey(cleared)}).Return([]trillian.MapLeaf{leaves[string(first)]}, nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	mockTx2 := storage.NewMockReadOnlyMapTreeTX(mockCtrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(5)).Return(mockTx2, nil)
+	mockTx2.EXPECT().GetSignedMapRoot(gomock.Any(), int64(5)).Return(root, nil)
+	mockTx2.EXPECT().GetKeys(gomock.Any(), last, trillian.Key("a0"), 3).Return([]trillian.Key{last}, nil)
+	mockTx2.EXPECT().Get(gomock.Any(), int64(5), []trillian.Hash{hasher.HashKey(last)}).Return([]trillian.MapLeaf{leaves[string(last)]}, nil)
+	mockTx2.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	req := &trillian.GetMapLeavesByPrefixRequest{MapId: 1, KeyPrefix: []byte("a/"), Revision: -1, IncludeProofs: true, PageSize: 2}
+
+	resp, err := server.GetLeavesByPrefix(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to get first page: %v", err)
+	}
+	if got, want := len(resp.KeyValue), 1; got != want {
+		t.Fatalf("Got %d leaves in first page, expected %d", got, want)
+	}
+	kvi := resp.KeyValue[0]
+	if got, want := kvi.KeyValue.Value, leaves[string(first)]; !bytes.Equal(kvi.KeyValue.Key, first) || !proto.Equal(got, &want) {
+		t.Errorf("Got %s = %v in first page, expected %s = %v", kvi.KeyValue.Key, got, first, want)
+	}
+	if err := merkle.NewMapVerifier(hasher).VerifyMapInclusion(rootHash, first, leaves[string(first)].LeafValue, kvi.Inclusion); err != nil {
+		t.Errorf("Failed to verify inclusion of %s: %v", first, err)
+	}
+	if len(resp.NextPageToken) == 0 {
+		t.Fatal("No token for the second page")
+	}
+
+	req.PageToken, req.Revision, req.IncludeProofs = resp.NextPageToken, -1, false
+	resp, err = server.GetLeavesByPrefix(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to get second page: %v", err)
+	}
+	if got, want := len(resp.KeyValue), 1; got != want {
+		t.Fatalf("Got %d leaves in second page, expected %d", got, want)
+	}
+	if kvi := resp.KeyValue[0]; !bytes.Equal(kvi.KeyValue.Key, last) || len(kvi.Inclusion) != 0 {
+		t.Errorf("Got key %s with %d proof nodes in second page, expected %s without a proof", kvi.KeyValue.Key, len(kvi.Inclusion), last)
+	}
+	if len(resp.NextPageToken) != 0 {
+		t.Errorf("Got token %x after the last page", resp.NextPageToken)
+	}
+}
+
+func TestGetLeavesByPrefixInvalidToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed for an invalid request
+	server := NewTrillianMapServer(mockStorageProviderForMap(storage.NewMockMapStorage(mockCtrl)))
+	token := keyPageToken{mapID: 1, revision: 5, next: trillian.Key("a/3")}.encode()
+
+	for _, req := range []trillian.GetMapLeavesByPrefixRequest{
+		{MapId: 2, KeyPrefix: []byte("a/"), PageToken: token},
+		{MapId: 1, KeyPrefix: []byte("b/"), PageToken: token},
+		{MapId: 1, KeyPrefix: []byte("a/"), PageToken: token[:len(token)-4]},
+	} {
+		if _, err := server.GetLeavesByPrefix(context.Background(), &req); err == nil {
+			t.Errorf("Accepted invalid request %v", req)
+		}
+	}
+}
+
+func TestGetLeafHistory(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	key := []byte("key")
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	keyHash := merkle.NewMapHasher(hasher).HashKey(key)
+
+	history := []storage.MapLeafRevision{
+		{Revision: 2, Leaf: trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value2")}},
+		{Revision: 5, Leaf: trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value5")}},
+	}
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(trillian.SignedMapRoot{MapRevision: 6}, nil)
+	mockTx.EXPECT().GetHistory(gomock.Any(), keyHash, int64(1), int64(6)).Return(history, nil)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(trillian.SignedMapRoot{MapRevision: 2}, nil)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(5)).Return(mapRoot5, nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(2), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	resp, err := server.GetLeafHistory(context.Background(), &trillian.GetLeafHistoryRequest{MapId: 1, Key: key, StartRevision: 1, EndRevision: -1})
+
+	if err != nil {
+		t.Fatalf("Failed to get leaf history: %v", err)
+	}
+
+	if got, want := len(resp.History), len(history); got != want {
+		t.Fatalf("Got %d history entries, expected %d", got, want)
+	}
+
+	for i, h := range resp.History {
+		if got, want := h.Revision, history[i].Revision; got != want {
+			t.Errorf("Got revision %d for entry %d, expected %d", got, i, want)
+		}
+		if got, want := h.MapRoot.MapRevision, history[i].Revision; got != want {
+			t.Errorf("Got root for revision %d for entry %d, expected %d", got, i, want)
+		}
+		if got, want := h.KeyValue.KeyValue.Value, &history[i].Leaf; !proto.Equal(got, want) {
+			t.Errorf("Got value %v for entry %d, expected %v", got, i, want)
+		}
+		if got, want := len(h.KeyValue.Inclusion), hasher.Size()*8; got != want {
+			t.Errorf("Got inclusion proof of length %d for entry %d, expected %d", got, i, want)
+		}
+	}
+}
+
+func TestGetLeafHistoryInvalidRange(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed for an invalid request
+	server := NewTrillianMapServer(mockStorageProviderForMap(storage.NewMockMapStorage(mockCtrl)))
+
+	for _, req := range []trillian.GetLeafHistoryRequest{
+		{MapId: 1, Key: []byte("key"), StartRevision: -1, EndRevision: 5},
+		{MapId: 1, Key: []byte("key"), StartRevision: 5, EndRevision: 4},
+	} {
+		req := req
+		if _, err := server.GetLeafHistory(context.Background(), &req); err == nil {
+			t.Errorf("Returned leaf history for invalid revision range %d to %d", req.StartRevision, req.EndRevision)
+		}
+	}
+}
+
+func TestGetLeafHistoryStopsWhenContextCancelled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	key := []byte("key")
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+	keyHash := merkle.NewMapHasher(hasher).HashKey(key)
+
+	history := []storage.MapLeafRevision{
+		{Revision: 2, Leaf: trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value2")}},
+		{Revision: 5, Leaf: trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value5")}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockTx.EXPECT().GetHistory(gomock.Any(), keyHash, int64(1), int64(6)).Return(history, nil)
+	mockTx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(trillian.SignedMapRoot{MapRevision: 2}, nil)
+	// The client goes away while the first proof is being built, so there's no second one
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(2), gomock.Any()).Do(func(context.Context, int64, []storage.NodeID) { cancel() }).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	if _, err := server.GetLeafHistory(ctx, &trillian.GetLeafHistoryRequest{MapId: 1, Key: key, StartRevision: 1, EndRevision: 6}); err != context.Canceled {
+		t.Fatalf("Got %v from leaf history for a cancelled request, expected %v", err, context.Canceled)
+	}
+}
+
+// fakeStreamMutationsServer collects the responses sent on a StreamMutations stream.
+type fakeStreamMutationsServer struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*trillian.StreamMutationsResponse
+}
+
+func (f *fakeStreamMutationsServer) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeStreamMutationsServer) Send(resp *trillian.StreamMutationsResponse) error {
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func mutationsAt(revisions ...int64) []trillian.MapMutation {
+	mutations := make([]trillian.MapMutation, 0, len(revisions))
+	for _, rev := range revisions {
+		mutations = append(mutations, trillian.MapMutation{MapRevision: rev, KeyValue: []*trillian.KeyValue{{Key: []byte(fmt.Sprintf("key%d", rev)), Value: &trillian.MapLeaf{LeafValue: []byte("value")}}}})
+	}
+	return mutations
+}
+
+func TestStreamMutationsInvalidRevision(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed for an invalid request
+	server := NewTrillianMapServer(mockStorageProviderForMap(storage.NewMockMapStorage(mockCtrl)))
+
+	if err := server.StreamMutations(&trillian.StreamMutationsRequest{MapId: 1, StartRevision: -1}, &fakeStreamMutationsServer{ctx: context.Background()}); err == nil {
+		t.Fatal("Streamed mutations from an invalid revision")
+	}
+}
+
+func TestStreamMutationsInChunks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+
+	// Revisions 3 and 5 have no mutation, and 6 was written after the stream started so isn't
+	// sent
+	gomock.InOrder(
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(mapRoot5, nil),
+		mockTx.EXPECT().Commit().Return(nil),
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().GetMutations(gomock.Any(), int64(1), 2).Return(mutationsAt(1, 2), nil),
+		mockTx.EXPECT().Commit().Return(nil),
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().GetMutations(gomock.Any(), int64(3), 2).Return(mutationsAt(4, 6), nil),
+		mockTx.EXPECT().Commit().Return(nil),
+		mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil),
+		mockTx.EXPECT().GetMutations(gomock.Any(), int64(5), 2).Return(mutationsAt(6), nil),
+		mockTx.EXPECT().Commit().Return(nil))
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	stream := &fakeStreamMutationsServer{ctx: context.Background()}
+
+	if err := server.StreamMutations(&trillian.StreamMutationsRequest{MapId: 1, StartRevision: 1, ChunkSize: 2}, stream); err != nil {
+		t.Fatalf("Failed to stream mutations: %v", err)
+	}
+
+	var got []int64
+	for _, resp := range stream.responses {
+		for _, mutation := range resp.Mutations {
+			got = append(got, mutation.MapRevision)
+		}
+	}
+
+	if want := []int64{1, 2, 4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Streamed mutations for revisions %v, expected %v", got, want)
+	}
+	if got, want := len(stream.responses), 2; got != want {
+		t.Fatalf("Sent %d responses, expected %d", got, want)
+	}
+}
+
+func TestStreamMutationsStartAfterLatestRevision(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockReadOnlyMapTX(mockCtrl)
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(mapRoot5, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	stream := &fakeStreamMutationsServer{ctx: context.Background()}
+
+	if err := server.StreamMutations(&trillian.StreamMutationsRequest{MapId: 1, StartRevision: 6}, stream); err != nil {
+		t.Fatalf("Failed to stream mutations: %v", err)
+	}
+	if len(stream.responses) != 0 {
+		t.Fatalf("Sent %v after the latest revision, expected nothing", stream.responses)
+	}
+}
+
+func TestSetLeavesInvalidRequest(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Storage should not be accessed for an invalid request
+	server := NewTrillianMapServer(mockStorageProviderForMap(storage.NewMockMapStorage(mockCtrl)))
+	value := &trillian.MapLeaf{LeafValue: []byte("value")}
+
+	for _, kvs := range [][]*trillian.KeyValue{
+		{{Key: []byte("key")}},
+		{{Key: []byte("key"), Value: value}, {Key: []byte("other"), Value: value}, {Key: []byte("key"), Value: value}},
+	} {
+		if _, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{MapId: 1, KeyValue: kvs}); err == nil {
+			t.Errorf("Set invalid leaves %v", kvs)
+		}
+	}
+}
+
+// expectSetLeaves sets up the storage calls made by a SetLeaves request for keys at revision
+// 6 up to storing the root. Everything is done in one transaction.
+func expectSetLeaves(mockStorage *storage.MockMapStorage, mockTx *storage.MockMapTX, keys int) *gomock.Call {
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().MapID().AnyTimes().Return(trillian.MapID{MapID: []byte("map"), TreeID: 1})
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(int64(6))
+	mockTx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(keys).Return(nil)
+	mockTx.EXPECT().StoreKey(gomock.Any(), gomock.Any()).Times(keys).Return(nil)
+	mockTx.EXPECT().GetMerkleNodes(gomock.Any(), int64(6), gomock.Any()).AnyTimes().Return([]storage.Node{}, nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	return mockTx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any())
+}
+
+func keyValues(n int) []*trillian.KeyValue {
+	kvs := make([]*trillian.KeyValue, 0, n)
+	for i := 0; i < n; i++ {
+		kvs = append(kvs, &trillian.KeyValue{Key: []byte(fmt.Sprintf("key%d", i)), Value: &trillian.MapLeaf{LeafValue: []byte(fmt.Sprintf("value%d", i))}})
+	}
+	return kvs
+}
+
+func TestSetLeavesInOneTransaction(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Enough keys to need many subtree writers, which mustn't commit the transaction
+	kvs := keyValues(500)
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockMapTX(mockCtrl)
+	expectSetLeaves(mockStorage, mockTx, len(kvs)).Return(nil)
+	mockTx.EXPECT().StoreMutation(gomock.Any(), trillian.MapMutation{MapRevision: 6, KeyValue: kvs}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	resp, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{MapId: 1, KeyValue: kvs})
+
+	if err != nil {
+		t.Fatalf("Failed to set leaves: %v", err)
+	}
+	if got, want := resp.MapRoot.MapRevision, int64(6); got != want {
+		t.Fatalf("Got map root for revision %d, expected %d", got, want)
+	}
+}
+
+func TestSetLeavesRevisionConflict(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	kvs := keyValues(3)
+	mockStorage := storage.NewMockMapStorage(mockCtrl)
+	mockTx := storage.NewMockMapTX(mockCtrl)
+	expectSetLeaves(mockStorage, mockTx, len(kvs)).Return(storage.ErrMapRevisionConflict)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+
+	if _, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{MapId: 1, KeyValue: kvs}); grpc.Code(err) != codes.Aborted {
+		t.Fatalf("Got error %v for conflicting write, expected code %v", err, codes.Aborted)
+	}
+}
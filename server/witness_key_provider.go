@@ -0,0 +1,71 @@
+package server
+
+import (
+	gocrypto "crypto"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/trillian/crypto"
+)
+
+// ErrUnknownWitness is returned when a cosignature is submitted by a witness that the log
+// doesn't know the public key of
+var ErrUnknownWitness = errors.New("unknown witness")
+
+// WitnessKeyProviderFunc returns the public key of a witness trusted to cosign the roots of
+// a log, or ErrUnknownWitness if there's no such witness for the log.
+type WitnessKeyProviderFunc func(treeID int64, witnessID string) (gocrypto.PublicKey, error)
+
+// NewWitnessKeyProvider returns a WitnessKeyProviderFunc that trusts the same witnesses,
+// given by keys from witness ID to public key, for every log.
+func NewWitnessKeyProvider(keys map[string]gocrypto.PublicKey) WitnessKeyProviderFunc {
+	return func(treeID int64, witnessID string) (gocrypto.PublicKey, error) {
+		if key, ok := keys[witnessID]; ok {
+			return key, nil
+		}
+
+		return nil, ErrUnknownWitness
+	}
+}
+
+// LoadWitnessKeys loads witness public keys from a comma separated list of witnessID=file
+// entries, where each file holds a PEM encoded public key.
+func LoadWitnessKeys(s string) (map[string]gocrypto.PublicKey, error) {
+	keys := make(map[string]gocrypto.PublicKey)
+
+	if len(s) == 0 {
+		return keys, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("witness key %q is not of the form witnessID=file", entry)
+		}
+
+		if _, exists := keys[parts[0]]; exists {
+			return nil, fmt.Errorf("duplicate key for witness %s", parts[0])
+		}
+
+		pemData, err := ioutil.ReadFile(parts[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key for witness %s: %v", parts[0], err)
+		}
+
+		km := crypto.NewPEMKeyManager()
+
+		if err := km.LoadPublicKey(string(pemData)); err != nil {
+			return nil, fmt.Errorf("failed to load key for witness %s: %v", parts[0], err)
+		}
+
+		if keys[parts[0]], err = km.GetPublicKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	gocrypto "crypto"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/trillian/testonly"
+)
+
+func TestWitnessKeyProvider(t *testing.T) {
+	key := gocrypto.PublicKey("key")
+	provider := NewWitnessKeyProvider(map[string]gocrypto.PublicKey{"witness": key})
+
+	if got, err := provider(1, "witness"); err != nil || got != key {
+		t.Errorf("Got key %v, %v for known witness, want %v", got, err, key)
+	}
+
+	if _, err := provider(1, "unknown"); err != ErrUnknownWitness {
+		t.Errorf("Got %v for unknown witness, want %v", err, ErrUnknownWitness)
+	}
+}
+
+func TestLoadWitnessKeys(t *testing.T) {
+	f, err := ioutil.TempFile("", "witness")
+
+	if err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(testonly.DemoPublicKey); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	f.Close()
+
+	keys, err := LoadWitnessKeys("w1=" + f.Name() + ",w2=" + f.Name())
+
+	if err != nil {
+		t.Fatalf("Failed to load witness keys: %v", err)
+	}
+
+	if got, want := len(keys), 2; got != want {
+		t.Fatalf("Loaded %d witness keys, want %d", got, want)
+	}
+
+	if keys["w1"] == nil || keys["w2"] == nil {
+		t.Errorf("Missing witness keys: %v", keys)
+	}
+
+	if keys, err := LoadWitnessKeys(""); err != nil || len(keys) != 0 {
+		t.Errorf("Got %v, %v for no witnesses, want no keys", keys, err)
+	}
+
+	for _, bad := range []string{"w1", "=" + f.Name(), "w1=", "w1=" + f.Name() + ",w1=" + f.Name(), "w1=/does/not/exist"} {
+		if _, err := LoadWitnessKeys(bad); err == nil {
+			t.Errorf("Loaded witness keys from bad spec: %s", bad)
+		}
+	}
+}
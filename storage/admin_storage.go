@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// ErrTreeNotFound is returned when an operation refers to a tree that doesn't exist.
+var ErrTreeNotFound = errors.New("storage: tree not found")
+
+// ErrTreeDeleted is returned when an operation needs a tree that has been soft deleted.
+var ErrTreeDeleted = errors.New("storage: tree has been deleted")
+
+// ErrTreeNotDeleted is returned when an operation needs a tree to have been soft deleted
+// but it hasn't been.
+var ErrTreeNotDeleted = errors.New("storage: tree has not been deleted")
+
+// ReadOnlyAdminTX is a transaction over the tree metadata that only permits reads.
+type ReadOnlyAdminTX interface {
+	AdminReader
+
+	// Commit ends the transaction, values read through it should only be used if
+	// Commit returns without error.
+	Commit() error
+
+	// Rollback aborts the transaction.
+	Rollback() error
+}
+
+// AdminTX is a transaction for creating, reading and modifying tree metadata.
+// The transaction must end with a call to Commit or Rollback.
+type AdminTX interface {
+	ReadOnlyAdminTX
+	AdminWriter
+}
+
+// AdminStorage should be implemented by concrete storage mechanisms which store the
+// metadata for logs and maps.
+type AdminStorage interface {
+	// Snapshot starts a read-only transaction. Problems met by the transaction are
+	// logged against ctx.
+	Snapshot(ctx context.Context) (ReadOnlyAdminTX, error)
+
+	// Begin starts a new transaction which can modify tree metadata. Problems met by
+	// the transaction are logged against ctx.
+	Begin(ctx context.Context) (AdminTX, error)
+}
+
+// AdminReader provides a read only interface to tree metadata.
+type AdminReader interface {
+	// GetTree returns the tree with the specified ID, or ErrTreeNotFound if it doesn't exist.
+	// Soft deleted trees are returned until they have been hard deleted.
+	GetTree(treeID int64) (*trillian.Tree, error)
+
+	// ListTrees returns the trees in the storage, ordered by tree ID. Soft deleted trees
+	// are only included if includeDeleted is true.
+	ListTrees(includeDeleted bool) ([]*trillian.Tree, error)
+
+	// GetTreeUsage returns the usage that has been added for the tree with the specified ID.
+	// Trees without any usage get a TreeUsage with only TreeId set.
+	GetTreeUsage(treeID int64) (*trillian.TreeUsage, error)
+}
+
+// AdminWriter provides a write interface for tree metadata.
+type AdminWriter interface {
+	// CreateTree stores a new tree, assigning it an ID, an ACTIVE state and its
+	// timestamps. The stored tree is returned.
+	CreateTree(tree *trillian.Tree) (*trillian.Tree, error)
+
+	// UpdateTree applies updateFunc to the stored tree with the specified ID and stores
+	// the result, if the changes are permitted by ValidateTreeForUpdate. The stored tree
+	// is returned.
+	UpdateTree(treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error)
+
+	// SoftDeleteTree marks the tree with the specified ID as deleted, after which it is no
+	// longer served. It returns ErrTreeDeleted if the tree was already deleted.
+	SoftDeleteTree(treeID int64) (*trillian.Tree, error)
+
+	// UndeleteTree reverses SoftDeleteTree. It returns ErrTreeNotDeleted if the tree
+	// hasn't been deleted.
+	UndeleteTree(treeID int64) (*trillian.Tree, error)
+
+	// AddTreeUsage adds the counts in usage to the totals for the tree it's for and sets
+	// their update time. RecentQps isn't stored.
+	AddTreeUsage(usage *trillian.TreeUsage) error
+
+	// HardDeleteTree removes the tree with the specified ID and all of its data. Only soft
+	// deleted trees can be removed, ErrTreeNotDeleted is returned for any other tree.
+	HardDeleteTree(treeID int64) error
+}
+
+// NewTreeID returns a random positive ID for a new tree.
+func NewTreeID() (int64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+
+	// Clear the top bit so the ID is positive, and avoid zero, which means "unset".
+	id := int64(binary.BigEndian.Uint64(b[:]) &^ (1 << 63))
+	if id == 0 {
+		id = 1
+	}
+
+	return id, nil
+}
+
+// ValidateTreeForCreation checks that a tree passed to CreateTree has the settings it needs
+// and doesn't set any of the fields which are assigned by storage.
+func ValidateTreeForCreation(tree *trillian.Tree) error {
+	switch {
+	case tree == nil:
+		return errors.New("storage: tree is required")
+	case tree.TreeId != 0:
+		return fmt.Errorf("storage: tree ID is assigned on creation and must not be set, got %d", tree.TreeId)
+	case tree.TreeState != trillian.TreeState_UNKNOWN_TREE_STATE:
+		return fmt.Errorf("storage: tree state is assigned on creation and must not be set, got %v", tree.TreeState)
+	case tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_MAP && tree.TreeType != trillian.TreeType_PREORDERED_LOG:
+		return fmt.Errorf("storage: invalid tree type: %v", tree.TreeType)
+	case len(tree.KeyId) == 0:
+		return errors.New("storage: tree key ID is required")
+	case tree.CreateTimeMillis != 0 || tree.UpdateTimeMillis != 0:
+		return errors.New("storage: tree timestamps are assigned on creation and must not be set")
+	case tree.Deleted || tree.DeleteTimeMillis != 0:
+		return errors.New("storage: new trees can't be deleted")
+	}
+
+	if _, ok := trillian.HashAlgorithm_name[int32(tree.HashAlgorithm)]; !ok {
+		return fmt.Errorf("storage: invalid hash algorithm: %v", tree.HashAlgorithm)
+	}
+
+	return nil
+}
+
+// ValidateTreeForUpdate checks that the changes from orig to updated are permitted. Only
+// the display name, description and update time can be changed freely. The state can
+// move from ACTIVE to FROZEN but frozen trees can't be made active again. Deleted trees
+// can't be updated, and deletion is handled by SoftDeleteTree and UndeleteTree rather than
+// updates.
+func ValidateTreeForUpdate(orig, updated *trillian.Tree) error {
+	switch {
+	case orig.Deleted:
+		return ErrTreeDeleted
+	case updated.Deleted || updated.DeleteTimeMillis != orig.DeleteTimeMillis:
+		return errors.New("storage: trees can't be deleted by updating them")
+	case updated.TreeId != orig.TreeId:
+		return errors.New("storage: tree ID cannot be changed")
+	case updated.TreeType != orig.TreeType:
+		return errors.New("storage: tree type cannot be changed")
+	case !bytes.Equal(updated.KeyId, orig.KeyId):
+		return errors.New("storage: tree key ID cannot be changed")
+	case updated.HashAlgorithm != orig.HashAlgorithm:
+		return errors.New("storage: tree hash algorithm cannot be changed")
+	case updated.AllowDuplicateLeaves != orig.AllowDuplicateLeaves:
+		return errors.New("storage: tree duplicate leaves setting cannot be changed")
+	case updated.CreateTimeMillis != orig.CreateTimeMillis:
+		return errors.New("storage: tree creation time cannot be changed")
+	}
+
+	return validateTreeStateTransition(orig.TreeState, updated.TreeState)
+}
+
+func validateTreeStateTransition(from, to trillian.TreeState) error {
+	if from == to {
+		return nil
+	}
+
+	if from == trillian.TreeState_ACTIVE && to == trillian.TreeState_FROZEN {
+		return nil
+	}
+
+	return fmt.Errorf("storage: invalid tree state transition from %v to %v", from, to)
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func validTreeForCreation() *trillian.Tree {
+	return &trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("key"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+		DisplayName:   "Log",
+		Description:   "A log",
+	}
+}
+
+func TestValidateTreeForCreation(t *testing.T) {
+	tests := []struct {
+		desc    string
+		modify  func(*trillian.Tree)
+		wantErr bool
+	}{
+		{"valid log", func(*trillian.Tree) {}, false},
+		{"valid map", func(tree *trillian.Tree) { tree.TreeType = trillian.TreeType_MAP }, false},
+		{"valid pre-ordered log", func(tree *trillian.Tree) { tree.TreeType = trillian.TreeType_PREORDERED_LOG }, false},
+		{"tree ID set", func(tree *trillian.Tree) { tree.TreeId = 1 }, true},
+		{"state set", func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_ACTIVE }, true},
+		{"unknown type", func(tree *trillian.Tree) { tree.TreeType = trillian.TreeType_UNKNOWN_TREE_TYPE }, true},
+		{"no key ID", func(tree *trillian.Tree) { tree.KeyId = nil }, true},
+		{"bad hash algorithm", func(tree *trillian.Tree) { tree.HashAlgorithm = trillian.HashAlgorithm(50) }, true},
+		{"create time set", func(tree *trillian.Tree) { tree.CreateTimeMillis = 1 }, true},
+		{"update time set", func(tree *trillian.Tree) { tree.UpdateTimeMillis = 1 }, true},
+		{"deleted", func(tree *trillian.Tree) { tree.Deleted = true }, true},
+		{"delete time set", func(tree *trillian.Tree) { tree.DeleteTimeMillis = 1 }, true},
+	}
+
+	for _, test := range tests {
+		tree := validTreeForCreation()
+		test.modify(tree)
+		if err := ValidateTreeForCreation(tree); (err != nil) != test.wantErr {
+			t.Errorf("%s: ValidateTreeForCreation() = %v, want error: %v", test.desc, err, test.wantErr)
+		}
+	}
+
+	if err := ValidateTreeForCreation(nil); err == nil {
+		t.Error("ValidateTreeForCreation() accepted a nil tree")
+	}
+}
+
+func TestValidateTreeForUpdate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		modify  func(*trillian.Tree)
+		wantErr bool
+	}{
+		{"no change", func(*trillian.Tree) {}, false},
+		{"display name", func(tree *trillian.Tree) { tree.DisplayName = "Renamed" }, false},
+		{"description", func(tree *trillian.Tree) { tree.Description = "Changed" }, false},
+		{"update time", func(tree *trillian.Tree) { tree.UpdateTimeMillis = 2000 }, false},
+		{"freeze", func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_FROZEN }, false},
+		{"unknown state", func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_UNKNOWN_TREE_STATE }, true},
+		{"tree ID", func(tree *trillian.Tree) { tree.TreeId = 2 }, true},
+		{"tree type", func(tree *trillian.Tree) { tree.TreeType = trillian.TreeType_MAP }, true},
+		{"key ID", func(tree *trillian.Tree) { tree.KeyId = []byte("other") }, true},
+		{"hash algorithm", func(tree *trillian.Tree) { tree.HashAlgorithm = trillian.HashAlgorithm(50) }, true},
+		{"duplicate leaves", func(tree *trillian.Tree) { tree.AllowDuplicateLeaves = true }, true},
+		{"create time", func(tree *trillian.Tree) { tree.CreateTimeMillis = 2000 }, true},
+		{"deleted", func(tree *trillian.Tree) { tree.Deleted = true }, true},
+		{"delete time", func(tree *trillian.Tree) { tree.DeleteTimeMillis = 2000 }, true},
+	}
+
+	orig := validTreeForCreation()
+	orig.TreeId = 1
+	orig.TreeState = trillian.TreeState_ACTIVE
+	orig.CreateTimeMillis = 1000
+	orig.UpdateTimeMillis = 1000
+
+	for _, test := range tests {
+		tree := *orig
+		test.modify(&tree)
+		if err := ValidateTreeForUpdate(orig, &tree); (err != nil) != test.wantErr {
+			t.Errorf("%s: ValidateTreeForUpdate() = %v, want error: %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateTreeForUpdateFrozenTreeStaysFrozen(t *testing.T) {
+	orig := validTreeForCreation()
+	orig.TreeState = trillian.TreeState_FROZEN
+
+	tree := *orig
+	if err := ValidateTreeForUpdate(orig, &tree); err != nil {
+		t.Errorf("ValidateTreeForUpdate() rejected a frozen tree staying frozen: %v", err)
+	}
+
+	tree.TreeState = trillian.TreeState_ACTIVE
+	if err := ValidateTreeForUpdate(orig, &tree); err == nil {
+		t.Error("ValidateTreeForUpdate() allowed a frozen tree to be made active")
+	}
+}
+
+func TestValidateTreeForUpdateDeletedTree(t *testing.T) {
+	orig := validTreeForCreation()
+	orig.Deleted = true
+	orig.DeleteTimeMillis = 1000
+
+	tree := *orig
+	tree.DisplayName = "Renamed"
+	if err := ValidateTreeForUpdate(orig, &tree); err != ErrTreeDeleted {
+		t.Errorf("ValidateTreeForUpdate() on a deleted tree = %v, want %v", err, ErrTreeDeleted)
+	}
+}
+
+func TestNewTreeID(t *testing.T) {
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		id, err := NewTreeID()
+		if err != nil {
+			t.Fatalf("NewTreeID() = %v", err)
+		}
+		if id <= 0 {
+			t.Fatalf("NewTreeID() = %d, want a positive ID", id)
+		}
+		if seen[id] {
+			t.Fatalf("NewTreeID() returned %d twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,100 @@
+// Package blob keeps large leaf values outside of the relational database, in a pluggable
+// Store, so that logs with multi-KB entries don't make the database grow with them. Only
+// a reference to each value is kept in SQL.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNotFound is returned by Store.Get when nothing is stored under a key
+var ErrNotFound = errors.New("blob: not found")
+
+// Store is implemented by anything that can hold blobs by key, e.g. a directory or an object
+// storage bucket such as S3 or GCS. Keys are made of lower case hex digits and slashes.
+type Store interface {
+	// Put stores value under key. Values are never changed once stored, so putting the same
+	// value under a key again must succeed.
+	Put(ctx context.Context, key string, value []byte) error
+	// Get returns the value stored under key, or ErrNotFound if there isn't one
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Lister is implemented by stores that can list the keys they hold, which tools that work
+// on every blob of a log need
+type Lister interface {
+	// List returns the sorted keys of the blobs whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// NewStoreFunc creates a Store for the location given by u. The rest of the URI after its
+// scheme is specific to the kind of store.
+type NewStoreFunc func(u *url.URL) (Store, error)
+
+var storesMutex sync.RWMutex
+var stores = make(map[string]NewStoreFunc)
+
+func init() {
+	if err := RegisterStore("file", newFileStoreFromURI); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterStore makes a kind of Store available for URIs with the given scheme. It returns
+// an error if the scheme has already been registered. Stores for object storage services
+// can be registered by packages that depend on their client libraries.
+func RegisterStore(scheme string, f NewStoreFunc) error {
+	if f == nil {
+		return fmt.Errorf("blob: nil NewStoreFunc for scheme %s", scheme)
+	}
+
+	storesMutex.Lock()
+	defer storesMutex.Unlock()
+
+	if _, exists := stores[scheme]; exists {
+		return fmt.Errorf("blob: scheme %s already registered", scheme)
+	}
+
+	stores[scheme] = f
+	return nil
+}
+
+// NewStore creates a Store for uri using the kind of store registered for its scheme, e.g.
+// file:///var/trillian/blobs keeps blobs in files under that directory.
+func NewStore(uri string) (Store, error) {
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("blob: bad store URI %q: %v", uri, err)
+	}
+
+	storesMutex.RLock()
+	f, ok := stores[u.Scheme]
+	storesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("blob: unknown scheme for store URI %q (registered: %v)", uri, Schemes())
+	}
+
+	return f(u)
+}
+
+// Schemes returns the sorted URI schemes of all registered kinds of Store
+func Schemes() []string {
+	storesMutex.RLock()
+	defer storesMutex.RUnlock()
+
+	schemes := make([]string, 0, len(stores))
+	for scheme := range stores {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
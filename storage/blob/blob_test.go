@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestRegisterStore(t *testing.T) {
+	if err := RegisterStore("file", newFileStoreFromURI); err == nil {
+		t.Error("Registered the file scheme twice")
+	}
+
+	if err := RegisterStore("test", nil); err == nil {
+		t.Error("Registered a nil NewStoreFunc")
+	}
+
+	if err := RegisterStore("test", func(u *url.URL) (Store, error) { return newMemoryStore(), nil }); err != nil {
+		t.Fatalf("Failed to register test scheme: %v", err)
+	}
+
+	if s, err := NewStore("test://bucket/prefix"); err != nil || s == nil {
+		t.Errorf("Got store %v and error %v for registered scheme", s, err)
+	}
+}
+
+func TestNewStoreRejectsBadURIs(t *testing.T) {
+	for _, uri := range []string{"nosuch://bucket", "file://", "%"} {
+		if _, err := NewStore(uri); err == nil {
+			t.Errorf("Created a store for bad URI %q", uri)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewStore("file://" + dir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "1/ab/abcd"); err != ErrNotFound {
+		t.Errorf("Got error %v for missing blob, want %v", err, ErrNotFound)
+	}
+
+	// Putting the same value twice succeeds
+	for i := 0; i < 2; i++ {
+		if err := s.Put(ctx, "1/ab/abcd", []byte("value")); err != nil {
+			t.Fatalf("Failed to put blob: %v", err)
+		}
+	}
+
+	if value, err := s.Get(ctx, "1/ab/abcd"); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Got blob %q and error %v, want %q", value, err, "value")
+	}
+}
+
+func TestFileStoreList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	s := NewFileStore(dir)
+
+	for _, key := range []string{"5/cd/cdef", "5/ab/abcd", "6/ab/abcd"} {
+		if err := s.Put(ctx, key, []byte("value")); err != nil {
+			t.Fatalf("Failed to put blob: %v", err)
+		}
+	}
+
+	if keys, err := s.List(ctx, "5/"); err != nil || fmt.Sprint(keys) != "[5/ab/abcd 5/cd/cdef]" {
+		t.Errorf("List(5/) = %v %v, want the blobs of tree 5", keys, err)
+	}
+
+	if keys, err := NewFileStore(dir+"/missing").List(ctx, ""); err != nil || len(keys) != 0 {
+		t.Errorf("List() of a missing directory = %v %v, want no keys", keys, err)
+	}
+}
@@ -0,0 +1,203 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto"
+	"golang.org/x/net/context"
+)
+
+// encPrefix starts every blob encrypted by an EncryptedStore. It's followed by the version
+// of the data key, the content key wrapped by the data key and the value encrypted with the
+// content key, each sealed with AES-GCM after a random nonce.
+var encPrefix = []byte("\x00trillian-enc:")
+
+const (
+	nonceSize      = 12
+	wrappedKeySize = crypto.DataKeySize + 16
+	encHeaderSize  = 4 + nonceSize + wrappedKeySize
+)
+
+// EncryptedStore wraps a Store so that blobs are encrypted before they're put in it, with
+// envelope encryption: each blob has its own random content key, which is kept with it
+// wrapped by the current data key of the tree. Rotating the data key only means rewrapping
+// the content keys, see Rewrap, the values themselves aren't encrypted again.
+//
+// Blobs put in the store before it was encrypted are returned as they are, so encryption
+// can be turned on for a log that already has blobs and they can be encrypted later with
+// Rewrap.
+type EncryptedStore struct {
+	store Store
+	keys  crypto.DataKeyManager
+}
+
+// NewEncryptedStore creates an EncryptedStore that keeps blobs in store, encrypted with the
+// data keys held by keys. Every key that blobs have been encrypted with must be kept until
+// they've all been rewrapped with a newer one.
+func NewEncryptedStore(store Store, keys crypto.DataKeyManager) *EncryptedStore {
+	return &EncryptedStore{store: store, keys: keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal appends a random nonce and plaintext sealed with key after it to dst
+func seal(dst, key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(append(dst, nonce...), nonce, plaintext, additionalData), nil
+}
+
+// open opens the nonce and sealed data at the start of sealed with key
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("blob: sealed data is too short")
+	}
+
+	return gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData)
+}
+
+// isEncrypted returns whether value is a blob encrypted by an EncryptedStore
+func isEncrypted(value []byte) bool {
+	return len(value) >= len(encPrefix)+encHeaderSize && bytes.HasPrefix(value, encPrefix)
+}
+
+// wrapHeader returns the header holding contentKey wrapped by the current data key
+func (e *EncryptedStore) wrapHeader(contentKey []byte) ([]byte, error) {
+	dataKey, err := e.keys.CurrentDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4, encHeaderSize)
+	binary.BigEndian.PutUint32(header, dataKey.Version)
+
+	// The version is bound to the wrapped key so it can't be changed to another key's
+	return seal(header, dataKey.Key, contentKey, header[:4])
+}
+
+// unwrapHeader returns the content key and data key version of an encrypted blob
+func (e *EncryptedStore) unwrapHeader(value []byte) ([]byte, uint32, error) {
+	header := value[len(encPrefix) : len(encPrefix)+encHeaderSize]
+	version := binary.BigEndian.Uint32(header)
+
+	dataKey, err := e.keys.DataKey(version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	contentKey, err := open(dataKey.Key, header[4:], header[:4])
+	if err != nil {
+		return nil, 0, fmt.Errorf("blob: failed to unwrap content key with data key version %d: %v", version, err)
+	}
+
+	return contentKey, version, nil
+}
+
+// Put encrypts value with a new content key and puts it in the wrapped store
+func (e *EncryptedStore) Put(ctx context.Context, key string, value []byte) error {
+	contentKey := make([]byte, crypto.DataKeySize)
+	if _, err := rand.Read(contentKey); err != nil {
+		return err
+	}
+
+	header, err := e.wrapHeader(contentKey)
+	if err != nil {
+		glog.Warningf("Failed to wrap content key for blob %s: %v", key, err)
+		return err
+	}
+
+	// The blob's key is bound to the value so it can't be moved to another key
+	encrypted, err := seal(append(append([]byte{}, encPrefix...), header...), contentKey, value, []byte(key))
+	if err != nil {
+		return err
+	}
+
+	return e.store.Put(ctx, key, encrypted)
+}
+
+// Get gets the blob under key from the wrapped store and decrypts it
+func (e *EncryptedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := e.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isEncrypted(value) {
+		return value, nil
+	}
+
+	contentKey, _, err := e.unwrapHeader(value)
+	if err != nil {
+		glog.Warningf("Failed to decrypt blob %s: %v", key, err)
+		return nil, err
+	}
+
+	plaintext, err := open(contentKey, value[len(encPrefix)+encHeaderSize:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("blob: failed to decrypt blob %s: %v", key, err)
+	}
+
+	return plaintext, nil
+}
+
+// Rewrap makes sure the blob under key is protected by the current data key. The content
+// key of a blob wrapped by an older data key is wrapped again and a blob that isn't
+// encrypted is encrypted. It returns whether the blob was rewritten.
+func (e *EncryptedStore) Rewrap(ctx context.Context, key string) (bool, error) {
+	value, err := e.store.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	if !isEncrypted(value) {
+		return true, e.Put(ctx, key, value)
+	}
+
+	current, err := e.keys.CurrentDataKey()
+	if err != nil {
+		return false, err
+	}
+
+	contentKey, version, err := e.unwrapHeader(value)
+	if err != nil {
+		return false, err
+	}
+
+	if version == current.Version {
+		return false, nil
+	}
+
+	header, err := e.wrapHeader(contentKey)
+	if err != nil {
+		return false, err
+	}
+
+	rewrapped := append(append(append([]byte{}, encPrefix...), header...), value[len(encPrefix)+encHeaderSize:]...)
+	return true, e.store.Put(ctx, key, rewrapped)
+}
@@ -0,0 +1,118 @@
+package blob
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian/crypto"
+	"golang.org/x/net/context"
+)
+
+func mustDataKeys(t *testing.T, versions ...uint32) *crypto.StaticDataKeyManager {
+	var keys []crypto.DataKey
+	for _, version := range versions {
+		key, err := crypto.GenerateDataKey(version)
+		if err != nil {
+			t.Fatalf("Failed to generate data key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return mustDataKeysFrom(t, keys...)
+}
+
+func mustDataKeysFrom(t *testing.T, keys ...crypto.DataKey) *crypto.StaticDataKeyManager {
+	m, err := crypto.NewStaticDataKeyManager(keys)
+	if err != nil {
+		t.Fatalf("Failed to create data key manager: %v", err)
+	}
+
+	return m
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	e := NewEncryptedStore(store, mustDataKeys(t, 1))
+
+	if err := e.Put(ctx, "5/ab/abcd", largeValue); err != nil {
+		t.Fatalf("Failed to put blob: %v", err)
+	}
+
+	// The wrapped store only sees the encrypted value
+	if raw := store.blobs["5/ab/abcd"]; !isEncrypted(raw) || bytes.Contains(raw, largeValue[:10]) {
+		t.Errorf("Wrapped store has blob %q, want it encrypted", raw)
+	}
+
+	if got, err := e.Get(ctx, "5/ab/abcd"); err != nil || !bytes.Equal(got, largeValue) {
+		t.Errorf("Get() = %q %v, want %q", got, err, largeValue)
+	}
+
+	if _, err := e.Get(ctx, "5/ab/missing"); err != ErrNotFound {
+		t.Errorf("Get() of a missing blob = %v, want %v", err, ErrNotFound)
+	}
+
+	// A blob moved to another key doesn't decrypt
+	store.blobs["5/cd/cdef"] = store.blobs["5/ab/abcd"]
+	if _, err := e.Get(ctx, "5/cd/cdef"); err == nil {
+		t.Error("Get() of a blob moved to another key succeeded")
+	}
+
+	// Nor does one encrypted with a key the manager doesn't have
+	other := NewEncryptedStore(store, mustDataKeys(t, 2))
+	if _, err := other.Get(ctx, "5/ab/abcd"); err == nil {
+		t.Error("Get() without the data key succeeded")
+	}
+}
+
+func TestEncryptedStoreReadsPlaintextBlobs(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	store.blobs["5/ab/abcd"] = largeValue
+
+	if got, err := NewEncryptedStore(store, mustDataKeys(t, 1)).Get(ctx, "5/ab/abcd"); err != nil || !bytes.Equal(got, largeValue) {
+		t.Errorf("Get() of a plaintext blob = %q %v, want %q", got, err, largeValue)
+	}
+}
+
+func TestEncryptedStoreRewrap(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	oldKeys := mustDataKeys(t, 1)
+
+	if err := NewEncryptedStore(store, oldKeys).Put(ctx, "5/ab/abcd", largeValue); err != nil {
+		t.Fatalf("Failed to put blob: %v", err)
+	}
+	store.blobs["5/cd/cdef"] = smallValue
+
+	// Rotate to a new key, keeping the old one
+	newKey, err := crypto.GenerateDataKey(2)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	newKeys, err := crypto.NewStaticDataKeyManager(append(oldKeys.Keys(), newKey))
+	if err != nil {
+		t.Fatalf("Failed to create data key manager: %v", err)
+	}
+	e := NewEncryptedStore(store, newKeys)
+
+	for _, key := range []string{"5/ab/abcd", "5/cd/cdef"} {
+		if rewritten, err := e.Rewrap(ctx, key); err != nil || !rewritten {
+			t.Errorf("Rewrap(%s) = %v %v, want the blob rewritten", key, rewritten, err)
+		}
+		if rewritten, err := e.Rewrap(ctx, key); err != nil || rewritten {
+			t.Errorf("Rewrap(%s) again = %v %v, want nothing to do", key, rewritten, err)
+		}
+	}
+
+	// Only the new key is needed now
+	e = NewEncryptedStore(store, mustDataKeysFrom(t, newKey))
+	for key, want := range map[string][]byte{"5/ab/abcd": largeValue, "5/cd/cdef": smallValue} {
+		if got, err := e.Get(ctx, key); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Get(%s) after Rewrap() = %q %v, want %q", key, got, err, want)
+		}
+		if !isEncrypted(store.blobs[key]) {
+			t.Errorf("Blob %s isn't encrypted after Rewrap()", key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package blob
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// FileStore keeps each blob in a file under a directory, named by its key
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a FileStore for dir, which is created when the first blob is put if
+// it doesn't already exist
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func newFileStoreFromURI(u *url.URL) (Store, error) {
+	if len(u.Path) == 0 {
+		return nil, fmt.Errorf("blob: file store URI %q has no path", u)
+	}
+
+	return NewFileStore(u.Path), nil
+}
+
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.dir, filepath.FromSlash(key))
+}
+
+// Put writes value to the file for key. It's written to a temporary file first and then
+// renamed, so a blob is never seen half written.
+func (f *FileStore) Put(ctx context.Context, key string, value []byte) error {
+	path := f.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Get reads the file for key
+func (f *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := ioutil.ReadFile(f.path(key))
+
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return value, err
+}
+
+// List walks the directory for the files of blobs with keys starting with prefix. Temporary
+// files of blobs being put are skipped.
+func (f *FileStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	err := filepath.Walk(f.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == f.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+
+		return ctx.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
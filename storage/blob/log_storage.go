@@ -0,0 +1,326 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// refPrefix starts every leaf value that's a reference to a blob, it's followed by the
+// SHA-256 digest of the value. Values that happen to start with it are always moved to the
+// blob store, so anything else stored with the prefix is a reference.
+var refPrefix = []byte("\x00trillian-blob:")
+
+// maxParallelBlobs is how many blobs are put or got at once for a batch of leaves
+const maxParallelBlobs = 16
+
+// leafBlobs moves the values of one log's leaves in and out of a Store
+type leafBlobs struct {
+	treeID  int64
+	store   Store
+	minSize int
+}
+
+// key returns where the value with digest is kept. The first byte of the digest names a
+// directory so that no single one gets too big.
+func (b *leafBlobs) key(digest []byte) string {
+	return fmt.Sprintf("%d/%x/%x", b.treeID, digest[:1], digest)
+}
+
+func (b *leafBlobs) isRef(value []byte) bool {
+	return len(value) == len(refPrefix)+sha256.Size && bytes.HasPrefix(value, refPrefix)
+}
+
+// forEach runs f for each of leaves, several at once, and returns the first error
+func forEach(leaves []trillian.LogLeaf, f func(leaf *trillian.LogLeaf) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, maxParallelBlobs)
+
+	for i := range leaves {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(leaf *trillian.LogLeaf) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := f(leaf); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(&leaves[i])
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// externalize returns a copy of leaves where values of at least minSize bytes have been put
+// in the store and replaced by references to them. The blobs of a transaction that's rolled
+// back are left behind, but will be reused if the same values are stored again.
+func (b *leafBlobs) externalize(ctx context.Context, leaves []trillian.LogLeaf) ([]trillian.LogLeaf, error) {
+	out := append([]trillian.LogLeaf{}, leaves...)
+
+	err := forEach(out, func(leaf *trillian.LogLeaf) error {
+		if len(leaf.LeafValue) < b.minSize && !bytes.HasPrefix(leaf.LeafValue, refPrefix) {
+			return nil
+		}
+
+		digest := sha256.Sum256(leaf.LeafValue)
+
+		if err := b.store.Put(ctx, b.key(digest[:]), leaf.LeafValue); err != nil {
+			glog.Warningf("Failed to put leaf value blob for tree %d: %v", b.treeID, err)
+			return err
+		}
+
+		leaf.LeafValue = append(append([]byte{}, refPrefix...), digest[:]...)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// resolve replaces the references in leaves with the values they refer to. The values are
+// checked against their digests so a blob that's been changed isn't returned.
+func (b *leafBlobs) resolve(ctx context.Context, leaves []trillian.LogLeaf) error {
+	return forEach(leaves, func(leaf *trillian.LogLeaf) error {
+		if !b.isRef(leaf.LeafValue) {
+			return nil
+		}
+
+		digest := leaf.LeafValue[len(refPrefix):]
+		key := b.key(digest)
+		value, err := b.store.Get(ctx, key)
+
+		if err != nil {
+			glog.Warningf("Failed to get leaf value blob %s: %v", key, err)
+			return err
+		}
+
+		if got := sha256.Sum256(value); !bytes.Equal(got[:], digest) {
+			return fmt.Errorf("blob: leaf value blob %s doesn't match its digest", key)
+		}
+
+		leaf.LeafValue = value
+		return nil
+	})
+}
+
+// resolveExisting resolves the references in the existing leaves returned when leaves are
+// queued or added, where entries for new leaves are nil
+func (b *leafBlobs) resolveExisting(ctx context.Context, existing []*trillian.LogLeaf) error {
+	for _, leaf := range existing {
+		if leaf == nil {
+			continue
+		}
+
+		resolved := []trillian.LogLeaf{*leaf}
+
+		if err := b.resolve(ctx, resolved); err != nil {
+			return err
+		}
+
+		*leaf = resolved[0]
+	}
+
+	return nil
+}
+
+func (b *leafBlobs) resolved(ctx context.Context, leaves []trillian.LogLeaf, err error) ([]trillian.LogLeaf, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.resolve(ctx, leaves); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+type logStorage struct {
+	storage.LogStorage
+	blobs *leafBlobs
+}
+
+// NewLogStorage wraps the storage of a log so that leaf values of at least minSize bytes are
+// kept in store, with only a reference to them in s. Values read through the transactions
+// it starts have their references resolved, except for the leaves returned by DequeueLeaves
+// and the LeafClaimer methods, which are only sequenced by their hashes. The same store must
+// be used for as long as the log has values in it, but it can be added to a log that already
+// has leaves.
+func NewLogStorage(s storage.LogStorage, treeID int64, store Store, minSize int) storage.LogStorage {
+	return &logStorage{LogStorage: s, blobs: &leafBlobs{treeID: treeID, store: store, minSize: minSize}}
+}
+
+func (s *logStorage) Begin(ctx context.Context) (storage.LogTX, error) {
+	tx, err := s.LogStorage.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := &logTX{LogTX: tx, blobs: s.blobs}
+
+	// Subtree garbage collection looks for this on the transaction
+	if pruner, ok := tx.(storage.SubtreePruner); ok {
+		return prunableLogTX{logTX: wrapped, SubtreePruner: pruner}, nil
+	}
+
+	return wrapped, nil
+}
+
+func (s *logStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := s.LogStorage.Snapshot(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &readOnlyLogTX{ReadOnlyLogTX: tx, blobs: s.blobs}, nil
+}
+
+func (s *logStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := s.LogStorage.SnapshotForTree(ctx, treeSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &readOnlyLogTreeTX{ReadOnlyLogTreeTX: tx, blobs: s.blobs}, nil
+}
+
+type logTX struct {
+	storage.LogTX
+	blobs *leafBlobs
+}
+
+type prunableLogTX struct {
+	*logTX
+	storage.SubtreePruner
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.blobs.externalize(ctx, leaves)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := t.LogTX.QueueLeaves(ctx, leaves)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.blobs.resolveExisting(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	leaves, err := t.blobs.externalize(ctx, leaves)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := t.LogTX.AddSequencedLeaves(ctx, leaves)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.blobs.resolveExisting(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	r, err := t.LogTX.GetLeavesByIndex(ctx, leaves)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	r, err := t.LogTX.GetLeavesByRange(ctx, start, count)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.LogTX.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *logTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.LogTX.GetLeavesByIdentityHash(ctx, identityHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+type readOnlyLogTX struct {
+	storage.ReadOnlyLogTX
+	blobs *leafBlobs
+}
+
+func (t *readOnlyLogTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTX.GetLeavesByIndex(ctx, leaves)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTX.GetLeavesByRange(ctx, start, count)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTX.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTX.GetLeavesByIdentityHash(ctx, identityHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+type readOnlyLogTreeTX struct {
+	storage.ReadOnlyLogTreeTX
+	blobs *leafBlobs
+}
+
+func (t *readOnlyLogTreeTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTreeTX.GetLeavesByIndex(ctx, leaves)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTreeTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTreeTX.GetLeavesByRange(ctx, start, count)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTreeTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTreeTX.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
+
+func (t *readOnlyLogTreeTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	r, err := t.ReadOnlyLogTreeTX.GetLeavesByIdentityHash(ctx, identityHashes, orderBySequence)
+	return t.blobs.resolved(ctx, r, err)
+}
@@ -0,0 +1,211 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+const testTreeID = 5
+
+// memoryStore is a Store that keeps blobs in a map
+type memoryStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{blobs: make(map[string][]byte)}
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blobs[key] = value
+	return nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return value, nil
+}
+
+var smallValue = []byte("small")
+var largeValue = bytes.Repeat([]byte("large"), 20)
+
+// prefixedValue is small, but would be mistaken for a reference if it was kept in SQL
+var prefixedValue = append(append([]byte{}, refPrefix...), []byte("not a reference")...)
+
+func leafWithValue(value []byte) trillian.LogLeaf {
+	return trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: []byte("hash"), LeafValue: value}}
+}
+
+func refTo(value []byte) []byte {
+	digest := sha256.Sum256(value)
+	return append(append([]byte{}, refPrefix...), digest[:]...)
+}
+
+func TestQueueLeavesKeepsLargeValuesInStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	store := newMemoryStore()
+
+	existing := leafWithValue(refTo(largeValue))
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves(gomock.Any(), []trillian.LogLeaf{leafWithValue(smallValue), leafWithValue(refTo(largeValue)), leafWithValue(refTo(prefixedValue))}).Return([]*trillian.LogLeaf{nil, &existing, nil}, nil)
+
+	s := NewLogStorage(mockStorage, testTreeID, store, 10)
+	tx, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	leaves := []trillian.LogLeaf{leafWithValue(smallValue), leafWithValue(largeValue), leafWithValue(prefixedValue)}
+	dups, err := tx.QueueLeaves(context.Background(), leaves)
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	// The caller's leaves aren't changed and the duplicate comes back with its value
+	if !bytes.Equal(leaves[1].LeafValue, largeValue) {
+		t.Errorf("Queued leaf value was changed to %q", leaves[1].LeafValue)
+	}
+
+	if !bytes.Equal(dups[1].LeafValue, largeValue) {
+		t.Errorf("Got duplicate leaf value %q, want %q", dups[1].LeafValue, largeValue)
+	}
+
+	if got, want := len(store.blobs), 2; got != want {
+		t.Errorf("Got %d blobs, want %d", got, want)
+	}
+}
+
+func TestGetLeavesResolvesReferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+	store := newMemoryStore()
+	digest := sha256.Sum256(largeValue)
+	store.Put(context.Background(), (&leafBlobs{treeID: testTreeID}).key(digest[:]), largeValue)
+
+	mockStorage.EXPECT().Snapshot(gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(2)).Return([]trillian.LogLeaf{leafWithValue(smallValue), leafWithValue(refTo(largeValue))}, nil)
+
+	s := NewLogStorage(mockStorage, testTreeID, store, 10)
+	tx, err := s.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	leaves, err := tx.GetLeavesByRange(context.Background(), 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if !bytes.Equal(leaves[0].LeafValue, smallValue) || !bytes.Equal(leaves[1].LeafValue, largeValue) {
+		t.Errorf("Got leaf values %q and %q, want %q and %q", leaves[0].LeafValue, leaves[1].LeafValue, smallValue, largeValue)
+	}
+}
+
+func TestGetLeavesFailsForMissingOrChangedBlob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	digest := sha256.Sum256(largeValue)
+	key := (&leafBlobs{treeID: testTreeID}).key(digest[:])
+
+	for _, test := range []struct {
+		blobs  map[string][]byte
+		errStr string
+	}{
+		{blobs: map[string][]byte{}, errStr: ErrNotFound.Error()},
+		{blobs: map[string][]byte{key: []byte("changed")}, errStr: "digest"},
+	} {
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTX(ctrl)
+		mockStorage.EXPECT().Begin(gomock.Any()).Return(mockTx, nil)
+		mockTx.EXPECT().GetLeavesByIndex(gomock.Any(), []int64{3}).Return([]trillian.LogLeaf{leafWithValue(refTo(largeValue))}, nil)
+
+		s := NewLogStorage(mockStorage, testTreeID, &memoryStore{blobs: test.blobs}, 10)
+		tx, err := s.Begin(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to begin tx: %v", err)
+		}
+
+		if _, err := tx.GetLeavesByIndex(context.Background(), []int64{3}); err == nil || !strings.Contains(err.Error(), test.errStr) {
+			t.Errorf("Got error %v, want one containing %q", err, test.errStr)
+		}
+	}
+}
+
+func TestGetLeavesPassesOnStorageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), int64(8)).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash(gomock.Any(), []trillian.Hash{[]byte("hash")}, false).Return(nil, errors.New("STORAGE"))
+
+	s := NewLogStorage(mockStorage, testTreeID, newMemoryStore(), 10)
+	tx, err := s.SnapshotForTree(context.Background(), 8)
+	if err != nil {
+		t.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	if _, err := tx.GetLeavesByHash(context.Background(), []trillian.Hash{[]byte("hash")}, false); err == nil || err.Error() != "STORAGE" {
+		t.Errorf("Got error %v, want STORAGE", err)
+	}
+}
+
+// prunableTX is a transaction on storage that supports subtree garbage collection
+type prunableTX struct {
+	*storage.MockLogTX
+}
+
+func (p prunableTX) PruneSubtrees(ctx context.Context, horizon int64) (storage.PruneStats, error) {
+	return storage.PruneStats{}, nil
+}
+
+func TestBeginKeepsSubtreePruner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(storage.NewMockLogTX(ctrl), nil)
+	mockStorage.EXPECT().Begin(gomock.Any()).Return(prunableTX{storage.NewMockLogTX(ctrl)}, nil)
+
+	s := NewLogStorage(mockStorage, testTreeID, newMemoryStore(), 10)
+
+	for _, want := range []bool{false, true} {
+		tx, err := s.Begin(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to begin tx: %v", err)
+		}
+
+		if _, got := tx.(storage.SubtreePruner); got != want {
+			t.Errorf("Got transaction that's a SubtreePruner %v, want %v", got, want)
+		}
+	}
+}
@@ -4,9 +4,11 @@ package cache
 
 import (
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 type NodeStorage interface {
-	GetSubtree(n storage.NodeID) (*storage.SubtreeProto, error)
-	SetSubtrees(s []*storage.SubtreeProto) error
+	GetSubtree(ctx context.Context, n storage.NodeID) (*storage.SubtreeProto, error)
+	GetSubtrees(ctx context.Context, n []storage.NodeID) ([]*storage.SubtreeProto, error)
+	SetSubtrees(ctx context.Context, s []*storage.SubtreeProto) error
 }
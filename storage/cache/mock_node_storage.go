@@ -6,6 +6,7 @@ package cache
 import (
 	gomock "github.com/golang/mock/gomock"
 	storage "github.com/google/trillian/storage"
+	context "golang.org/x/net/context"
 )
 
 // Mock of NodeStorage interface
@@ -29,23 +30,34 @@ func (_m *MockNodeStorage) EXPECT() *_MockNodeStorageRecorder {
 	return _m.recorder
 }
 
-func (_m *MockNodeStorage) GetSubtree(_param0 storage.NodeID) (*storage.SubtreeProto, error) {
-	ret := _m.ctrl.Call(_m, "GetSubtree", _param0)
+func (_m *MockNodeStorage) GetSubtree(_param0 context.Context, _param1 storage.NodeID) (*storage.SubtreeProto, error) {
+	ret := _m.ctrl.Call(_m, "GetSubtree", _param0, _param1)
 	ret0, _ := ret[0].(*storage.SubtreeProto)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockNodeStorageRecorder) GetSubtree(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSubtree", arg0)
+func (_mr *_MockNodeStorageRecorder) GetSubtree(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSubtree", arg0, arg1)
 }
 
-func (_m *MockNodeStorage) SetSubtrees(_param0 []*storage.SubtreeProto) error {
-	ret := _m.ctrl.Call(_m, "SetSubtrees", _param0)
+func (_m *MockNodeStorage) GetSubtrees(_param0 context.Context, _param1 []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	ret := _m.ctrl.Call(_m, "GetSubtrees", _param0, _param1)
+	ret0, _ := ret[0].([]*storage.SubtreeProto)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockNodeStorageRecorder) GetSubtrees(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSubtrees", arg0, arg1)
+}
+
+func (_m *MockNodeStorage) SetSubtrees(_param0 context.Context, _param1 []*storage.SubtreeProto) error {
+	ret := _m.ctrl.Call(_m, "SetSubtrees", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockNodeStorageRecorder) SetSubtrees(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetSubtrees", arg0)
+func (_mr *_MockNodeStorageRecorder) SetSubtrees(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetSubtrees", arg0, arg1)
 }
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// SharedCacheSetter is implemented by storage whose transactions can read subtrees through
+// a SharedSubtreeCache, which is shared with the storage for other trees.
+type SharedCacheSetter interface {
+	SetSharedSubtreeCache(c *SharedSubtreeCache)
+}
+
+// SharedSubtreeCache holds populated subtrees read from storage between transactions, so
+// that the hot subtrees near the root of a tree aren't read and populated again by every
+// request. It sits beneath the SubtreeCache of each transaction and is safe for concurrent
+// use by all of them.
+//
+// Each subtree is cached along with the revisions of the tree it's known to be current at,
+// and is only returned for reads at those. Reads must only be made at revisions that have
+// been committed, as what's stored for them can't change. When a transaction commits a new
+// revision the subtrees it wrote are removed, and the others that were current at the
+// revision before become current at the new one too. Revisions committed by other
+// processes aren't seen, so the subtrees cached for them are read again.
+//
+// A nil *SharedSubtreeCache caches nothing.
+type SharedSubtreeCache struct {
+	mutex  sync.Mutex
+	limits CacheLimits
+	// order holds the cached subtrees, most recently used first
+	order *list.List
+	// trees indexes the elements of order by tree ID then prefix
+	trees      map[int64]map[string]*list.Element
+	totalBytes int64
+}
+
+// sharedSubtree is a subtree held by a SharedSubtreeCache, which is current in the
+// revisions from to to inclusive.
+type sharedSubtree struct {
+	treeID    int64
+	prefixKey string
+	subtree   *storage.SubtreeProto
+	from, to  int64
+	size      int64
+}
+
+// Hits are subtrees found in the shared cache, misses are subtrees that had to be read from
+// storage. Reads that don't go through the shared cache aren't counted.
+var (
+	sharedSubtreeCacheHits   = monitoring.NewCounter("shared_subtree_cache_hits", "Subtrees found in the shared cache")
+	sharedSubtreeCacheMisses = monitoring.NewCounter("shared_subtree_cache_misses", "Subtrees that weren't in the shared cache")
+)
+
+// NewSharedSubtreeCache returns an empty cache which keeps within limits by evicting the
+// least recently used subtrees.
+func NewSharedSubtreeCache(limits CacheLimits) *SharedSubtreeCache {
+	return &SharedSubtreeCache{
+		limits: limits,
+		order:  list.New(),
+		trees:  make(map[int64]map[string]*list.Element),
+	}
+}
+
+// Wrap returns a GetSubtreesFunc that reads the subtrees of the tree at revision through
+// the cache. The subtrees that aren't cached are read with getSubtrees in a single call
+// and populated with populateSubtree before they're added, so they needn't be populated
+// again as they're read from the cache. Each call returns its own copies of the subtrees,
+// which can be changed freely.
+func (c *SharedSubtreeCache) Wrap(treeID, revision int64, populateSubtree storage.PopulateSubtreeFunc, getSubtrees GetSubtreesFunc) GetSubtreesFunc {
+	if c == nil {
+		return getSubtrees
+	}
+
+	return func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		ret := make([]*storage.SubtreeProto, 0, len(ids))
+		misses := make([]storage.NodeID, 0, len(ids))
+
+		c.mutex.Lock()
+		for _, id := range ids {
+			if st := c.get(treeID, revision, string(id.Path[:id.PrefixLenBits/8])); st != nil {
+				ret = append(ret, copySubtree(st))
+			} else {
+				misses = append(misses, id)
+			}
+		}
+		c.mutex.Unlock()
+		sharedSubtreeCacheHits.Add(float64(len(ret)))
+		sharedSubtreeCacheMisses.Add(float64(len(misses)))
+
+		if len(misses) == 0 {
+			return ret, nil
+		}
+
+		subtrees, err := getSubtrees(ctx, misses)
+		if err != nil {
+			return nil, err
+		}
+
+		// Subtrees that were stored with their internal nodes don't need populating.
+		unpopulated := make([]*storage.SubtreeProto, 0, len(subtrees))
+		for _, st := range subtrees {
+			if err := storage.ConvertSubtreeKeys(st, memoryKeyVersion); err != nil {
+				return nil, err
+			}
+			if len(st.InternalNodes) == 0 {
+				unpopulated = append(unpopulated, st)
+			}
+		}
+		if err := PopulateSubtrees(populateSubtree, unpopulated, runtime.GOMAXPROCS(0)); err != nil {
+			return nil, err
+		}
+
+		c.mutex.Lock()
+		for _, st := range subtrees {
+			prefixKey := string(st.Prefix)
+			if len(misses) == 1 {
+				// A single read is for the subtree we asked for, as in SubtreeCache.
+				prefixKey = string(misses[0].Path[:misses[0].PrefixLenBits/8])
+			}
+			c.put(treeID, revision, prefixKey, copySubtree(st))
+		}
+		c.evict()
+		c.mutex.Unlock()
+
+		return append(ret, subtrees...), nil
+	}
+}
+
+// Committed records that revision of the tree has been committed, changing only the
+// subtrees with prefixes. It must be called after every commit that stores a new root of
+// the tree, including those that don't change any subtrees.
+func (c *SharedSubtreeCache) Committed(treeID, revision int64, prefixes [][]byte) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, prefix := range prefixes {
+		if e, ok := c.trees[treeID][string(prefix)]; ok {
+			c.remove(e)
+		}
+	}
+
+	for _, e := range c.trees[treeID] {
+		if s := e.Value.(*sharedSubtree); s.to == revision-1 {
+			s.to = revision
+		}
+	}
+}
+
+// get returns the cached subtree with prefixKey if it's current at revision, or nil. Must
+// be called with c.mutex locked.
+func (c *SharedSubtreeCache) get(treeID, revision int64, prefixKey string) *storage.SubtreeProto {
+	e, ok := c.trees[treeID][prefixKey]
+	if !ok {
+		return nil
+	}
+
+	s := e.Value.(*sharedSubtree)
+	if revision < s.from || revision > s.to {
+		return nil
+	}
+
+	c.order.MoveToFront(e)
+	return s.subtree
+}
+
+// put caches a subtree read at revision, unless the one already cached is for a later
+// revision. Must be called with c.mutex locked.
+func (c *SharedSubtreeCache) put(treeID, revision int64, prefixKey string, st *storage.SubtreeProto) {
+	if e, ok := c.trees[treeID][prefixKey]; ok {
+		if s := e.Value.(*sharedSubtree); revision <= s.to {
+			c.order.MoveToFront(e)
+			return
+		}
+		c.remove(e)
+	}
+
+	s := &sharedSubtree{
+		treeID:    treeID,
+		prefixKey: prefixKey,
+		subtree:   st,
+		from:      revision,
+		to:        revision,
+		size:      subtreeSize(st),
+	}
+
+	prefixes := c.trees[treeID]
+	if prefixes == nil {
+		prefixes = make(map[string]*list.Element)
+		c.trees[treeID] = prefixes
+	}
+	prefixes[prefixKey] = c.order.PushFront(s)
+	c.totalBytes += s.size
+}
+
+// remove stops caching a subtree. Must be called with c.mutex locked.
+func (c *SharedSubtreeCache) remove(e *list.Element) {
+	s := c.order.Remove(e).(*sharedSubtree)
+	c.totalBytes -= s.size
+
+	delete(c.trees[s.treeID], s.prefixKey)
+	if len(c.trees[s.treeID]) == 0 {
+		delete(c.trees, s.treeID)
+	}
+}
+
+// evict removes the least recently used subtrees until the cache is within its limits.
+// Must be called with c.mutex locked.
+func (c *SharedSubtreeCache) evict() {
+	for c.order.Len() > 0 {
+		if (c.limits.MaxSubtrees <= 0 || c.order.Len() <= c.limits.MaxSubtrees) && (c.limits.MaxBytes <= 0 || c.totalBytes <= c.limits.MaxBytes) {
+			return
+		}
+		c.remove(c.order.Back())
+	}
+}
+
+// copySubtree returns a copy of st whose maps can be changed without affecting it. The
+// hashes themselves are shared, as they're replaced rather than changed in place.
+func copySubtree(st *storage.SubtreeProto) *storage.SubtreeProto {
+	r := &storage.SubtreeProto{
+		Prefix:     append([]byte{}, st.Prefix...),
+		Depth:      st.Depth,
+		RootHash:   st.RootHash,
+		Leaves:     make(map[string][]byte, len(st.Leaves)),
+		KeyVersion: st.KeyVersion,
+	}
+	for k, v := range st.Leaves {
+		r.Leaves[k] = v
+	}
+	if st.InternalNodes != nil {
+		r.InternalNodes = make(map[string][]byte, len(st.InternalNodes))
+		for k, v := range st.InternalNodes {
+			r.InternalNodes[k] = v
+		}
+	}
+	return r
+}
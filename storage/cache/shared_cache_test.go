@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// fakeSubtreeStorage returns copies of its subtrees without their internal nodes, as they'd
+// be read from storage, and counts the subtrees read.
+type fakeSubtreeStorage struct {
+	subtrees map[string]*storage.SubtreeProto
+	reads    int
+}
+
+func newFakeSubtreeStorage(subtrees []*storage.SubtreeProto) *fakeSubtreeStorage {
+	f := &fakeSubtreeStorage{subtrees: make(map[string]*storage.SubtreeProto)}
+	for _, st := range subtrees {
+		f.subtrees[string(st.Prefix)] = st
+	}
+	return f
+}
+
+func (f *fakeSubtreeStorage) getSubtrees(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	ret := make([]*storage.SubtreeProto, 0, len(ids))
+	for _, id := range ids {
+		f.reads++
+		if st, ok := f.subtrees[string(id.Path[:id.PrefixLenBits/8])]; ok {
+			c := copySubtree(st)
+			c.InternalNodes = nil
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+// subtreeIDs returns the IDs used to read the subtrees
+func subtreeIDs(subtrees []*storage.SubtreeProto) []storage.NodeID {
+	ids := make([]storage.NodeID, 0, len(subtrees))
+	for _, st := range subtrees {
+		ids = append(ids, storage.NewNodeIDFromHash(st.Prefix))
+	}
+	return ids
+}
+
+func TestSharedCacheReadsSubtreesOnce(t *testing.T) {
+	ctx := context.Background()
+	populate := PopulateLogSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	// The first subtree only has one leaf, so has no internal nodes.
+	subtrees := makeLogSubtrees(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), 4)[1:]
+	f := newFakeSubtreeStorage(subtrees)
+	c := NewSharedSubtreeCache(CacheLimits{})
+
+	for i := 0; i < 3; i++ {
+		got, err := c.Wrap(1, 5, populate, f.getSubtrees)(ctx, subtreeIDs(subtrees))
+		if err != nil {
+			t.Fatalf("read %d: got error %v", i, err)
+		}
+		if len(got) != len(subtrees) {
+			t.Fatalf("read %d: got %d subtrees, want %d", i, len(got), len(subtrees))
+		}
+		for _, st := range got {
+			if len(st.InternalNodes) == 0 {
+				t.Errorf("read %d: subtree %x wasn't populated", i, st.Prefix)
+			}
+		}
+	}
+
+	if got, want := f.reads, len(subtrees); got != want {
+		t.Errorf("read %d subtrees from storage, want %d", got, want)
+	}
+}
+
+func TestSharedCacheReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	populate := PopulateLogSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	subtrees := makeLogSubtrees(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), 2)[1:]
+	f := newFakeSubtreeStorage(subtrees)
+	c := NewSharedSubtreeCache(CacheLimits{})
+	ids := subtreeIDs(subtrees)
+
+	for i := 0; i < 2; i++ {
+		got, err := c.Wrap(1, 5, populate, f.getSubtrees)(ctx, ids)
+		if err != nil {
+			t.Fatalf("read %d: got error %v", i, err)
+		}
+		if got, want := len(got[0].Leaves), len(subtrees[0].Leaves); got != want {
+			t.Fatalf("read %d: got %d leaves, want %d", i, got, want)
+		}
+		// Nothing done to the subtree should reach the cache.
+		got[0].Leaves = nil
+		for k := range got[0].InternalNodes {
+			delete(got[0].InternalNodes, k)
+		}
+	}
+
+	got, err := c.Wrap(1, 5, populate, f.getSubtrees)(ctx, ids)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if len(got[0].InternalNodes) == 0 {
+		t.Errorf("cached subtree was changed by a reader")
+	}
+}
+
+func TestSharedCacheRevisions(t *testing.T) {
+	ctx := context.Background()
+	populate := PopulateLogSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	subtrees := makeLogSubtrees(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), 2)
+	ids := subtreeIDs(subtrees)
+
+	type read struct {
+		treeID, revision int64
+		// commit, if set, commits the revision instead, rewriting the first subtree
+		commit bool
+		// wantReads is the number of subtrees read from storage
+		wantReads int
+	}
+
+	for _, test := range []struct {
+		desc  string
+		reads []read
+	}{
+		{
+			desc:  "same revision",
+			reads: []read{{1, 5, false, 2}, {1, 5, false, 0}},
+		},
+		{
+			desc:  "later revision isn't known to be the same",
+			reads: []read{{1, 5, false, 2}, {1, 6, false, 2}, {1, 6, false, 0}},
+		},
+		{
+			desc:  "earlier revision isn't known to be the same",
+			reads: []read{{1, 6, false, 2}, {1, 5, false, 2}, {1, 6, false, 0}},
+		},
+		{
+			desc:  "other trees are cached separately",
+			reads: []read{{1, 5, false, 2}, {2, 5, false, 2}, {1, 5, false, 0}, {2, 5, false, 0}},
+		},
+		{
+			desc:  "commit keeps subtrees it didn't write",
+			reads: []read{{1, 5, false, 2}, {1, 6, true, 0}, {1, 6, false, 1}, {1, 5, false, 1}},
+		},
+		{
+			desc:  "commit of a later revision keeps nothing",
+			reads: []read{{1, 5, false, 2}, {1, 7, true, 0}, {1, 7, false, 2}},
+		},
+		{
+			desc:  "commit of one tree doesn't affect others",
+			reads: []read{{1, 5, false, 2}, {2, 5, false, 2}, {2, 6, true, 0}, {1, 6, false, 2}, {2, 6, false, 1}},
+		},
+	} {
+		f := newFakeSubtreeStorage(subtrees)
+		c := NewSharedSubtreeCache(CacheLimits{})
+
+		for i, r := range test.reads {
+			f.reads = 0
+			if r.commit {
+				c.Committed(r.treeID, r.revision, [][]byte{subtrees[0].Prefix})
+				continue
+			}
+
+			if _, err := c.Wrap(r.treeID, r.revision, populate, f.getSubtrees)(ctx, ids); err != nil {
+				t.Fatalf("%s: read %d: got error %v", test.desc, i, err)
+			}
+			if got, want := f.reads, r.wantReads; got != want {
+				t.Errorf("%s: read %d of tree %d at revision %d read %d subtrees from storage, want %d", test.desc, i, r.treeID, r.revision, got, want)
+			}
+		}
+	}
+}
+
+func TestSharedCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	populate := PopulateLogSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	subtrees := makeLogSubtrees(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), 3)
+	ids := subtreeIDs(subtrees)
+	f := newFakeSubtreeStorage(subtrees)
+	c := NewSharedSubtreeCache(CacheLimits{MaxSubtrees: 2})
+
+	get := c.Wrap(1, 5, populate, f.getSubtrees)
+	for _, id := range []storage.NodeID{ids[0], ids[1], ids[0], ids[2]} {
+		if _, err := get(ctx, []storage.NodeID{id}); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+	}
+
+	// Subtree 1 was the least recently used when subtree 2 was added.
+	for _, test := range []struct {
+		id        storage.NodeID
+		wantReads int
+	}{{ids[0], 0}, {ids[2], 0}, {ids[1], 1}} {
+		f.reads = 0
+		if _, err := get(ctx, []storage.NodeID{test.id}); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if got, want := f.reads, test.wantReads; got != want {
+			t.Errorf("read of subtree %x read %d subtrees from storage, want %d", test.id.Path, got, want)
+		}
+	}
+}
+
+func TestNilSharedCacheReadsStorage(t *testing.T) {
+	ctx := context.Background()
+	subtrees := makeLogSubtrees(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), 2)
+	f := newFakeSubtreeStorage(subtrees)
+	var c *SharedSubtreeCache
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Wrap(1, 5, nil, f.getSubtrees)(ctx, subtreeIDs(subtrees)); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		c.Committed(1, 6, nil)
+	}
+
+	if got, want := f.reads, 2*len(subtrees); got != want {
+		t.Errorf("read %d subtrees from storage, want %d", got, want)
+	}
+}
@@ -2,24 +2,69 @@ package cache
 
 import (
 	"bytes"
-	"encoding/base64"
+	"container/list"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sync"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 // GetSubtreeFunc describes a function which can return a Subtree from storage.
-type GetSubtreeFunc func(id storage.NodeID) (*storage.SubtreeProto, error)
+type GetSubtreeFunc func(ctx context.Context, id storage.NodeID) (*storage.SubtreeProto, error)
+
+// GetSubtreesFunc describes a function which can return a number of Subtrees from
+// storage in a single call. Subtrees not present in storage are omitted from the result.
+type GetSubtreesFunc func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error)
 
 // SetSubtreeFunc describes a function which can store a Subtree into storage.
-type SetSubtreesFunc func(s []*storage.SubtreeProto) error
+type SetSubtreesFunc func(ctx context.Context, s []*storage.SubtreeProto) error
+
+// CacheLimits bounds the amount of data a SubtreeCache will hold. A zero value for
+// either field means that dimension is not limited.
+type CacheLimits struct {
+	// MaxSubtrees is the maximum number of subtrees held in the cache.
+	MaxSubtrees int
+	// MaxBytes is the approximate maximum size of the hashes held in the cache.
+	MaxBytes int64
+}
+
+// CacheLimitSetter is implemented by storage that allows the limits of the subtree
+// caches used by its transactions to be configured.
+type CacheLimitSetter interface {
+	SetSubtreeCacheLimits(limits CacheLimits)
+}
+
+// InternalNodeStorageSetter is implemented by storage that can be told whether to store
+// the internal nodes of subtrees. By default only the leaves of each subtree are stored
+// and the internal nodes are recalculated whenever the subtree is read, which roughly
+// halves the size of a log subtree. Storing them as well trades that space for the CPU
+// spent recalculating them. Subtrees stored either way can be read whatever the setting.
+type InternalNodeStorageSetter interface {
+	SetStoreInternalNodes(store bool)
+}
+
+// SubtreeKeyVersionSetter is implemented by storage that can be told which version of the
+// suffix key encoding to write subtrees with, see storage/suffix_key.go. By default they're
+// written with storage.SuffixKeyV0, which every server can read, so a newer version should
+// only be set once all the servers sharing the storage have been updated to read it.
+// Subtrees written with any version can be read whatever the setting.
+type SubtreeKeyVersionSetter interface {
+	SetSubtreeKeyVersion(version int32)
+}
 
-// SubtreeCache provides a caching access to Subtree storage.
+// SubtreeCache provides a caching access to Subtree storage. It is safe for concurrent
+// use. Readers of cached subtrees don't block each other, and subtrees are read from
+// storage and populated without holding the cache lock, so concurrent misses for
+// different subtrees proceed in parallel. Concurrent misses for the same subtree
+// result in a single storage read. The functions passed in to fetch or store subtrees
+// may be called concurrently and must be safe for that.
 type SubtreeCache struct {
 	// subtrees contains the Subtree data read from storage, and is updated by
 	// calls to SetNodeHash.
@@ -27,10 +72,42 @@ type SubtreeCache struct {
 	// dirtyPrefixes keeps track of all Subtrees which need to be written back
 	// to storage.
 	dirtyPrefixes map[string]bool
-	// mutex guards access to the maps above.
+	// pending holds the storage reads currently in progress, by prefix.
+	pending map[string]*pendingSubtree
+	// lru tracks usage order and sizes of the subtrees so the cache can be kept
+	// within its limits.
+	lru *subtreeLRU
+	// mutex guards access to the fields above. The lru has its own lock so it can
+	// be updated by readers holding only the read lock.
 	mutex *sync.RWMutex
 
+	limits          CacheLimits
 	populateSubtree storage.PopulateSubtreeFunc
+	// populateWorkers is the number of subtrees read in one batch that are repopulated
+	// at the same time.
+	populateWorkers int
+	// storeInternalNodes is true if subtrees are written back with their internal nodes.
+	storeInternalNodes bool
+	// keyVersion is the version of the suffix key encoding subtrees are written back with.
+	keyVersion int32
+}
+
+// pendingSubtree is a storage read of a subtree which is in progress. done is closed when
+// the read completes, after which subtree and err are set.
+type pendingSubtree struct {
+	done    chan struct{}
+	subtree *storage.SubtreeProto
+	err     error
+}
+
+// subtreeLRU holds the subtree prefix keys in order of use, most recent first,
+// along with the approximate size of each subtree.
+type subtreeLRU struct {
+	mutex      sync.Mutex
+	order      *list.List
+	elements   map[string]*list.Element
+	sizes      map[string]int64
+	totalBytes int64
 }
 
 // Suffix represents the tail of a NodeID, indexing into the Subtree which
@@ -42,16 +119,22 @@ type Suffix struct {
 	path byte
 }
 
+// serialize returns the key of the suffix in the Leaves and InternalNodes maps of subtrees
+// held in memory.
 func (s Suffix) serialize() string {
-	r := make([]byte, 2)
-	r[0] = s.bits
-	r[1] = s.path
-	return base64.StdEncoding.EncodeToString(r)
+	k, err := storage.EncodeSuffixKey(memoryKeyVersion, s.bits, s.path)
+	if err != nil {
+		panic(err)
+	}
+	return k
 }
 
 const (
 	// strataDepth is the depth of Subtree.
 	strataDepth = 8
+	// memoryKeyVersion is the version of the suffix key encoding used by the subtrees held
+	// in memory. Subtrees read from storage are converted to it before they're used.
+	memoryKeyVersion = storage.SuffixKeyV0
 )
 
 // NewSubtreeCache returns a newly intialised cache ready for use.
@@ -60,12 +143,252 @@ const (
 // from storage.
 // TODO(al): consider supporting different sized subtrees - for now everything's subtrees of 8 levels.
 func NewSubtreeCache(populateSubtree storage.PopulateSubtreeFunc) SubtreeCache {
+	return NewSubtreeCacheWithLimits(populateSubtree, CacheLimits{})
+}
+
+// NewSubtreeCacheWithLimits returns a newly initialised cache which will try to stay
+// within the specified limits. Clean subtrees are evicted in least recently used order
+// when the cache is over its limits. If that isn't enough then the least recently used
+// dirty subtrees are written back to storage and evicted by SetNodeHash.
+func NewSubtreeCacheWithLimits(populateSubtree storage.PopulateSubtreeFunc, limits CacheLimits) SubtreeCache {
 	return SubtreeCache{
-		subtrees:        make(map[string]*storage.SubtreeProto),
-		dirtyPrefixes:   make(map[string]bool),
+		subtrees:      make(map[string]*storage.SubtreeProto),
+		dirtyPrefixes: make(map[string]bool),
+		pending:       make(map[string]*pendingSubtree),
+		lru: &subtreeLRU{
+			order:    list.New(),
+			elements: make(map[string]*list.Element),
+			sizes:    make(map[string]int64),
+		},
 		mutex:           new(sync.RWMutex),
+		limits:          limits,
 		populateSubtree: populateSubtree,
+		populateWorkers: runtime.GOMAXPROCS(0),
+	}
+}
+
+// SetStoreInternalNodes sets whether subtrees are written back with their internal nodes,
+// rather than just their leaves. It must be called before the cache is used. Subtrees
+// read from storage with their internal nodes are never repopulated, whatever the setting.
+func (s *SubtreeCache) SetStoreInternalNodes(store bool) {
+	s.storeInternalNodes = store
+}
+
+// SetSubtreeKeyVersion sets the version of the suffix key encoding that subtrees are
+// written back with. It must be called before the cache is used. The version must be
+// between storage.SuffixKeyV0 and storage.MaxSuffixKeyVersion.
+func (s *SubtreeCache) SetSubtreeKeyVersion(version int32) {
+	s.keyVersion = version
+}
+
+// touch marks a subtree as the most recently used.
+func (l *subtreeLRU) touch(prefixKey string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if e, ok := l.elements[prefixKey]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.elements[prefixKey] = l.order.PushFront(prefixKey)
+}
+
+// resize records a new approximate size for a subtree.
+func (l *subtreeLRU) resize(prefixKey string, size int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.totalBytes += size - l.sizes[prefixKey]
+	l.sizes[prefixKey] = size
+}
+
+// size returns the recorded size of a subtree.
+func (l *subtreeLRU) size(prefixKey string) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.sizes[prefixKey]
+}
+
+// total returns the recorded size of all the subtrees.
+func (l *subtreeLRU) total() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.totalBytes
+}
+
+// remove stops tracking a subtree.
+func (l *subtreeLRU) remove(prefixKey string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if e, ok := l.elements[prefixKey]; ok {
+		l.order.Remove(e)
+		delete(l.elements, prefixKey)
+	}
+	l.totalBytes -= l.sizes[prefixKey]
+	delete(l.sizes, prefixKey)
+}
+
+// eviction returns the prefix keys of all subtrees in least recently used order, except
+// for the most recently used one, which should never be evicted.
+func (l *subtreeLRU) eviction() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	keys := make([]string, 0, l.order.Len())
+	for e := l.order.Back(); e != nil && e != l.order.Front(); e = e.Prev() {
+		keys = append(keys, e.Value.(string))
+	}
+	return keys
+}
+
+// subtreeSize returns the approximate number of bytes used by the hashes in a subtree.
+func subtreeSize(st *storage.SubtreeProto) int64 {
+	size := int64(len(st.Prefix) + len(st.RootHash))
+	for k, v := range st.Leaves {
+		size += int64(len(k) + len(v))
+	}
+	for k, v := range st.InternalNodes {
+		size += int64(len(k) + len(v))
+	}
+	return size
+}
+
+// limited returns true if the cache has any limits set.
+func (s *SubtreeCache) limited() bool {
+	return s.limits.MaxSubtrees > 0 || s.limits.MaxBytes > 0
+}
+
+// withinLimits returns true if the specified number and size of subtrees is allowed.
+func (s *SubtreeCache) withinLimits(count int, size int64) bool {
+	return (s.limits.MaxSubtrees <= 0 || count <= s.limits.MaxSubtrees) && (s.limits.MaxBytes <= 0 || size <= s.limits.MaxBytes)
+}
+
+// overLimits returns true if the cache currently holds more than its limits allow.
+// Must be called with s.mutex locked.
+func (s *SubtreeCache) overLimits() bool {
+	return !s.withinLimits(len(s.subtrees), s.lru.total())
+}
+
+// cacheSubtree adds a subtree to the cache, or marks it as the most recently used if
+// it is already present. Must be called with s.mutex locked.
+func (s *SubtreeCache) cacheSubtree(prefixKey string, st *storage.SubtreeProto) {
+	s.subtrees[prefixKey] = st
+	s.lru.resize(prefixKey, subtreeSize(st))
+	s.lru.touch(prefixKey)
+}
+
+// evict removes a subtree from the cache. Must be called with s.mutex locked.
+func (s *SubtreeCache) evict(prefixKey string) {
+	s.lru.remove(prefixKey)
+	delete(s.subtrees, prefixKey)
+	delete(s.dirtyPrefixes, prefixKey)
+}
+
+// evictClean removes the least recently used clean subtrees until the cache is within
+// its limits or only dirty subtrees remain. The most recently used subtree is never
+// evicted. Must be called with s.mutex locked.
+func (s *SubtreeCache) evictClean() {
+	if !s.overLimits() {
+		return
+	}
+	for _, prefixKey := range s.lru.eviction() {
+		if !s.overLimits() {
+			return
+		}
+		if !s.dirtyPrefixes[prefixKey] {
+			s.evict(prefixKey)
+		}
+	}
+}
+
+// evictCleanLocked takes the lock and calls evictClean, if the cache has limits.
+func (s *SubtreeCache) evictCleanLocked() {
+	if !s.limited() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.evictClean()
+}
+
+// flushLeastRecentlyUsed writes back the least recently used dirty subtrees and evicts
+// them until the cache is within its limits. The most recently used subtree is never
+// written or evicted. Must be called with s.mutex locked.
+func (s *SubtreeCache) flushLeastRecentlyUsed(ctx context.Context, setSubtrees SetSubtreesFunc) error {
+	s.evictClean()
+	if !s.overLimits() || setSubtrees == nil {
+		return nil
+	}
+
+	// Work out which subtrees have to go, oldest first, without changing the cache
+	// until they've been successfully written.
+	count, size := len(s.subtrees), s.lru.total()
+	victims := make([]string, 0)
+	for _, prefixKey := range s.lru.eviction() {
+		if s.withinLimits(count, size) {
+			break
+		}
+		victims = append(victims, prefixKey)
+		count--
+		size -= s.lru.size(prefixKey)
+	}
+
+	treesToWrite := make([]*storage.SubtreeProto, 0, len(victims))
+	for _, prefixKey := range victims {
+		st, err := s.prepareForWrite(s.subtrees[prefixKey])
+		if err != nil {
+			return err
+		}
+		if st != nil {
+			treesToWrite = append(treesToWrite, st)
+		}
+	}
+	if len(treesToWrite) > 0 {
+		glog.V(1).Infof("subtree cache over limits, writing back %d subtrees", len(treesToWrite))
+		if err := setSubtrees(ctx, treesToWrite); err != nil {
+			return err
+		}
+	}
+	for _, prefixKey := range victims {
+		s.evict(prefixKey)
+	}
+	return nil
+}
+
+// prepareForWrite strips the fields of a dirty subtree that aren't stored. It returns
+// nil if the subtree doesn't need to be written at all. If the subtree is written with a
+// different version of the suffix keys a converted copy is returned, leaving the cached
+// one as it was.
+func (s *SubtreeCache) prepareForWrite(st *storage.SubtreeProto) (*storage.SubtreeProto, error) {
+	// TODO(al): Do actually write this one once we're storing the updated
+	// subtree root value here during tree update calculations.
+	st.RootHash = nil
+
+	if len(st.Leaves) == 0 {
+		return nil, nil
+	}
+	if !s.storeInternalNodes {
+		// clear the internal node cache; they're recalculated when the subtree is read.
+		st.InternalNodes = nil
+	}
+	if st.KeyVersion == s.keyVersion {
+		return st, nil
+	}
+
+	w := &storage.SubtreeProto{
+		Prefix:        st.Prefix,
+		Depth:         st.Depth,
+		Leaves:        st.Leaves,
+		InternalNodes: st.InternalNodes,
+		KeyVersion:    st.KeyVersion,
+	}
+	if err := storage.ConvertSubtreeKeys(w, s.keyVersion); err != nil {
+		return nil, err
 	}
+	return w, nil
 }
 
 // splitNodeID breaks a NodeID out into its prefix and suffix parts.
@@ -87,143 +410,384 @@ func splitNodeID(id storage.NodeID) ([]byte, Suffix) {
 	return r, s
 }
 
-func (s *SubtreeCache) Preload(ids []storage.NodeID, getSubtrees func(id []storage.NodeID) ([]*storage.SubtreeProto, error)) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// newEmptySubtree creates a subtree with no nodes for the specified prefix.
+func newEmptySubtree(px []byte) *storage.SubtreeProto {
+	return &storage.SubtreeProto{
+		Prefix:        px,
+		Depth:         strataDepth,
+		Leaves:        make(map[string][]byte),
+		InternalNodes: make(map[string][]byte),
+		KeyVersion:    memoryKeyVersion,
+	}
+}
+
+// singleSubtreeFetcher adapts a GetSubtreeFunc for use where a GetSubtreesFunc is needed.
+func singleSubtreeFetcher(getSubtree GetSubtreeFunc) GetSubtreesFunc {
+	return func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		ret := make([]*storage.SubtreeProto, 0, len(ids))
+		for _, id := range ids {
+			st, err := getSubtree(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if st != nil {
+				ret = append(ret, st)
+			}
+		}
+		return ret, nil
+	}
+}
 
-	// Figure out the set of subtrees we need:
-	want := make(map[string]*storage.NodeID)
+// Hits are subtrees found in the cache, misses are subtrees that had to be read from
+// storage, by this or another goroutine. These count lookups of subtrees rather than nodes.
+var (
+	subtreeCacheHits   = monitoring.NewCounter("subtree_cache_hits", "Subtrees found in the cache")
+	subtreeCacheMisses = monitoring.NewCounter("subtree_cache_misses", "Subtrees that had to be read from storage")
+)
+
+// fetchSubtrees returns the subtrees needed to read the specified nodes, keyed by
+// prefix. Subtrees which aren't cached are read from storage with a single call to
+// getSubtrees and populated without holding the cache lock. If another goroutine is
+// already reading one of the subtrees its result is waited for and shared, rather than
+// reading it again, unless ctx is done first. Subtrees which don't exist in storage are
+// returned empty.
+func (s *SubtreeCache) fetchSubtrees(ctx context.Context, ids []storage.NodeID, getSubtrees GetSubtreesFunc) (map[string]*storage.SubtreeProto, error) {
+	ret := make(map[string]*storage.SubtreeProto)
+	want := make(map[string]storage.NodeID)
+
+	s.mutex.RLock()
 	for _, id := range ids {
-		id := id
 		px, _ := splitNodeID(id)
 		pxKey := string(px)
-		_, ok := s.subtrees[pxKey]
-		id.PrefixLenBits = len(px) * 8
-		if !ok {
-			want[pxKey] = &id
+		if _, ok := ret[pxKey]; ok {
+			continue
+		}
+		if c := s.subtrees[pxKey]; c != nil {
+			ret[pxKey] = c
+			continue
 		}
+		want[pxKey] = id.Prefix(len(px) * 8)
+	}
+	s.mutex.RUnlock()
+	subtreeCacheHits.Add(float64(len(ret)))
+
+	if len(want) == 0 {
+		return ret, nil
 	}
 
+	// Claim the reads nobody else is doing, and note the ones we need to wait for.
+	s.mutex.Lock()
+	mine := make(map[string]*pendingSubtree)
+	theirs := make(map[string]*pendingSubtree)
 	list := make([]storage.NodeID, 0, len(want))
-	for _, v := range want {
-		list = append(list, *v)
+	for pxKey, id := range want {
+		if c := s.subtrees[pxKey]; c != nil {
+			ret[pxKey] = c
+			subtreeCacheHits.Inc()
+		} else if p := s.pending[pxKey]; p != nil {
+			theirs[pxKey] = p
+		} else {
+			p := &pendingSubtree{done: make(chan struct{})}
+			s.pending[pxKey] = p
+			mine[pxKey] = p
+			list = append(list, id)
+		}
+	}
+	s.mutex.Unlock()
+	subtreeCacheMisses.Add(float64(len(mine) + len(theirs)))
+
+	if len(mine) > 0 {
+		err := s.readSubtrees(ctx, list, getSubtrees, mine)
+
+		s.mutex.Lock()
+		for pxKey, p := range mine {
+			p.err = err
+			if err == nil {
+				// A writer may have created it while we were reading, theirs wins.
+				if c := s.subtrees[pxKey]; c != nil {
+					p.subtree = c
+				} else {
+					s.cacheSubtree(pxKey, p.subtree)
+				}
+				ret[pxKey] = p.subtree
+			}
+			delete(s.pending, pxKey)
+			close(p.done)
+		}
+		s.mutex.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for pxKey, p := range theirs {
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		ret[pxKey] = p.subtree
 	}
-	subtrees, err := getSubtrees(list)
+
+	return ret, nil
+}
+
+// readSubtrees reads and populates subtrees from storage, storing each result in the
+// corresponding pending entry. It must not be called with s.mutex held.
+func (s *SubtreeCache) readSubtrees(ctx context.Context, ids []storage.NodeID, getSubtrees GetSubtreesFunc, pending map[string]*pendingSubtree) error {
+	subtrees, err := getSubtrees(ctx, ids)
 	if err != nil {
 		return err
 	}
+
+	requested := make([]*storage.SubtreeProto, 0, len(subtrees))
+	requestedPending := make([]*pendingSubtree, 0, len(subtrees))
 	for _, t := range subtrees {
-		s.populateSubtree(t)
-		s.subtrees[string(t.Prefix)] = t
+		pxKey := string(t.Prefix)
+		if len(ids) == 1 {
+			// A single read is for the subtree we asked for, whatever it claims.
+			pxKey = string(ids[0].Path[:ids[0].PrefixLenBits/8])
+		}
+		p, ok := pending[pxKey]
+		if !ok {
+			glog.Warningf("storage returned unrequested subtree %x, ignoring", t.Prefix)
+			continue
+		}
+		if err := storage.ConvertSubtreeKeys(t, memoryKeyVersion); err != nil {
+			return err
+		}
+		requested = append(requested, t)
+		requestedPending = append(requestedPending, p)
+	}
+
+	// Subtrees that were stored with their internal nodes don't need repopulating.
+	unpopulated := make([]*storage.SubtreeProto, 0, len(requested))
+	for _, t := range requested {
+		if len(t.InternalNodes) == 0 {
+			unpopulated = append(unpopulated, t)
+		}
+	}
+	if err := PopulateSubtrees(s.populateSubtree, unpopulated, s.populateWorkers); err != nil {
+		return err
+	}
+	for i, t := range requested {
+		if t.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on subtree %v read from storage", t))
+		}
+		requestedPending[i].subtree = t
+	}
+
+	// Anything storage didn't return doesn't exist yet, so use empty protos for these in
+	// case we try to update them later on (we won't flush them back to storage unless
+	// they've been written to.)
+	for pxKey, p := range pending {
+		if p.subtree == nil {
+			p.subtree = newEmptySubtree([]byte(pxKey))
+		}
 	}
 	return nil
 }
 
-// GetNodeHash retrieves the previously written hash and corresponding tree
-// revision for the given node ID.
-func (s *SubtreeCache) GetNodeHash(id storage.NodeID, getSubtree GetSubtreeFunc) (trillian.Hash, error) {
+// Preload fetches all the subtrees needed to read the specified nodes which aren't
+// already cached using a single call to getSubtrees.
+func (s *SubtreeCache) Preload(ctx context.Context, ids []storage.NodeID, getSubtrees GetSubtreesFunc) error {
+	if _, err := s.fetchSubtrees(ctx, ids, getSubtrees); err != nil {
+		return err
+	}
+	s.evictCleanLocked()
+	return nil
+}
+
+// PreloadSiblings fetches, in a single call to getSubtrees, all the subtrees needed to
+// read the siblings of the specified node, i.e. those needed to build a proof for it.
+func (s *SubtreeCache) PreloadSiblings(ctx context.Context, id storage.NodeID, getSubtrees GetSubtreesFunc) error {
+	return s.Preload(ctx, append(id.Siblings(), id), getSubtrees)
+}
+
+// GetNodeHashes retrieves the previously written hashes for the given node IDs. Any
+// subtrees which need to be read from storage are fetched with a single call to
+// getSubtrees. Hashes are returned in the same order as ids and are nil for nodes
+// which have not been written.
+func (s *SubtreeCache) GetNodeHashes(ctx context.Context, ids []storage.NodeID, getSubtrees GetSubtreesFunc) ([]trillian.Hash, error) {
+	subtrees, err := s.fetchSubtrees(ctx, ids, getSubtrees)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]trillian.Hash, 0, len(ids))
+
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	return s.getNodeHashUnderLock(id, getSubtree)
+	for _, id := range ids {
+		px, sx := splitNodeID(id)
+		hashes = append(hashes, s.readNodeHash(string(px), subtrees[string(px)], sx))
+	}
+	s.mutex.RUnlock()
+
+	s.evictCleanLocked()
+	return hashes, nil
 }
 
-// getNodeHashUnderLock must be called with s.mutex locked.
-func (s *SubtreeCache) getNodeHashUnderLock(id storage.NodeID, getSubtree GetSubtreeFunc) (trillian.Hash, error) {
+// GetProofPath retrieves the nodes of the inclusion proof for the leaf with leafID, from
+// the leaf up, see storage.NodeReader. Any subtrees which need to be read from storage are
+// fetched with a single call to getSubtrees. Nodes which have not been written have a nil
+// Hash.
+func (s *SubtreeCache) GetProofPath(ctx context.Context, leafID storage.NodeID, treeSize int64, getSubtrees GetSubtreesFunc) ([]storage.Node, error) {
+	ids, err := proofPathIDs(leafID, treeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := s.GetNodeHashes(ctx, ids, getSubtrees)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]storage.Node, 0, len(ids))
+	for i, id := range ids {
+		nodes = append(nodes, storage.Node{NodeID: id, Hash: hashes[i]})
+	}
+	return nodes, nil
+}
+
+// proofPathIDs returns the IDs of the nodes in the inclusion proof for leafID. For a log
+// tree of treeSize leaves these are the nodes given by merkle.CalcInclusionProofNodeAddresses
+// for the leaf's index, and for a map tree, where treeSize is 0, they're all of its siblings.
+func proofPathIDs(leafID storage.NodeID, treeSize int64) ([]storage.NodeID, error) {
+	if treeSize == 0 {
+		return leafID.Siblings(), nil
+	}
+
+	// Log leaf IDs hold the index of the leaf, see storage.NewNodeIDForTreeCoords.
+	if leafID.PrefixLenBits != leafID.PathLenBits || len(leafID.Path) > 8 {
+		return nil, fmt.Errorf("%v isn't the ID of a log leaf", leafID)
+	}
+	var index int64
+	for _, b := range leafID.Path {
+		index = index<<8 | int64(b)
+	}
+	return merkle.CalcInclusionProofNodeAddresses(treeSize, index, leafID.PathLenBits)
+}
+
+// GetNodeHash retrieves the previously written hash and corresponding tree
+// revision for the given node ID.
+func (s *SubtreeCache) GetNodeHash(ctx context.Context, id storage.NodeID, getSubtree GetSubtreeFunc) (trillian.Hash, error) {
 	px, sx := splitNodeID(id)
 	prefixKey := string(px)
+
+	s.mutex.RLock()
 	c := s.subtrees[prefixKey]
-	if c == nil {
-		// Cache miss, so we'll try to fetch from storage.
-		subID := id
-		subID.PrefixLenBits = len(px) * 8
-		var err error
-		c, err = getSubtree(subID)
-		if err != nil {
-			return nil, err
-		}
-		if c == nil {
-			// storage didn't have one for us, so we'll store an empty proto here
-			// incase we try to update it later on (we won't flush it back to
-			// storage unless it's been written to.)
-			c = &storage.SubtreeProto{
-				Prefix:        px,
-				Depth:         strataDepth,
-				Leaves:        make(map[string][]byte),
-				InternalNodes: make(map[string][]byte),
-			}
-		} else {
-			if err := s.populateSubtree(c); err != nil {
-				return nil, err
-			}
-		}
-		if c.Prefix == nil {
-			panic(fmt.Errorf("GetNodeHash nil prefix on %v for id %v with px %#v", c, id.String(), px))
-		}
+	if c != nil {
+		defer s.mutex.RUnlock()
+		subtreeCacheHits.Inc()
+		return s.readNodeHash(prefixKey, c, sx), nil
+	}
+	s.mutex.RUnlock()
 
-		s.subtrees[prefixKey] = c
+	// Cache miss, so we'll try to fetch from storage.
+	subtrees, err := s.fetchSubtrees(ctx, []storage.NodeID{id}, singleSubtreeFetcher(getSubtree))
+	if err != nil {
+		return nil, err
 	}
 
-	// finally look for the particular node within the subtree so we can return
-	// the hash & revision.
-	var nh trillian.Hash
+	s.mutex.RLock()
+	h := s.readNodeHash(prefixKey, subtrees[prefixKey], sx)
+	s.mutex.RUnlock()
+
+	s.evictCleanLocked()
+	return h, nil
+}
+
+// readNodeHash looks up a node within a subtree, returning nil if it isn't set. Must be
+// called with s.mutex held, at least for reading.
+func (s *SubtreeCache) readNodeHash(prefixKey string, c *storage.SubtreeProto, sx Suffix) trillian.Hash {
+	if s.limited() {
+		s.lru.touch(prefixKey)
+	}
 
 	// Look up the hash in the appropriate map.
 	// The leaf hashes are stored in a separate map to the internal nodes so that
 	// we can easily dump (and later reconstruct) the internal nodes.
 	// Since the subtrees are fixed to a depth of 8, any suffix with 8
 	// significant bits must be a leaf hash.
+	var nh trillian.Hash
 	if sx.bits == 8 {
 		nh = c.Leaves[sx.serialize()]
 	} else {
 		nh = c.InternalNodes[sx.serialize()]
 	}
 	if nh == nil {
-		return nil, nil
+		return nil
 	}
-	return nh, nil
+	return nh
 }
 
-// SetNodeHash sets a node hash in the cache.
-func (s *SubtreeCache) SetNodeHash(id storage.NodeID, h trillian.Hash, getSubtree GetSubtreeFunc) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// SetNodeHash sets a node hash in the cache. If the cache is over its limits afterwards
+// then setSubtrees is used to write back the least recently used dirty subtrees so they
+// can be evicted. setSubtrees may be nil if the cache has no limits.
+func (s *SubtreeCache) SetNodeHash(ctx context.Context, id storage.NodeID, h trillian.Hash, getSubtree GetSubtreeFunc, setSubtrees SetSubtreesFunc) error {
 	px, sx := splitNodeID(id)
 	prefixKey := string(px)
+
+	s.mutex.RLock()
 	c := s.subtrees[prefixKey]
+	s.mutex.RUnlock()
+
 	if c == nil {
 		// TODO(al): This is ok, IFF *all* leaves in the subtree are being set,
 		// verify that this is the case when it happens.
 		// For now, just read from storage if we don't already have it.
 		glog.V(1).Infof("attempting to write to unread subtree for %v, reading now", id.String())
-		// We hold the lock so can call this directly:
-		_, err := s.getNodeHashUnderLock(id, getSubtree)
+		subtrees, err := s.fetchSubtrees(ctx, []storage.NodeID{id}, singleSubtreeFetcher(getSubtree))
 		if err != nil {
 			return err
 		}
-		// There must be a subtree present in the cache now, even if storage didn't have anything for us.
-		c = s.subtrees[prefixKey]
+		// There must be a subtree now, even if storage didn't have anything for us.
+		c = subtrees[prefixKey]
 		if c == nil {
 			return fmt.Errorf("internal error, subtree cache for %v is nil after a read attempt", id.String())
 		}
 	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The subtree may have been evicted while we weren't holding the lock, if so put
+	// it back, we're about to make it dirty.
+	if cached := s.subtrees[prefixKey]; cached != nil {
+		c = cached
+	} else {
+		s.cacheSubtree(prefixKey, c)
+	}
 	if c.Prefix == nil {
 		panic(fmt.Errorf("nil prefix for %v (key %v)", id.String(), prefixKey))
 	}
 	s.dirtyPrefixes[prefixKey] = true
+	s.lru.touch(prefixKey)
 	// Determine whether we're being asked to store a leaf node, or an internal
 	// node, and store it accordingly.
+	sfxKey := sx.serialize()
+	nodes := c.InternalNodes
 	if sx.bits == 8 {
-		c.Leaves[sx.serialize()] = h
-	} else {
-		c.InternalNodes[sx.serialize()] = h
+		nodes = c.Leaves
 	}
-	return nil
+	size := s.lru.size(prefixKey) + int64(len(h)-len(nodes[sfxKey]))
+	if _, ok := nodes[sfxKey]; !ok {
+		size += int64(len(sfxKey))
+	}
+	nodes[sfxKey] = h
+	s.lru.resize(prefixKey, size)
+
+	return s.flushLeastRecentlyUsed(ctx, setSubtrees)
 }
 
 // Flush causes the cache to write all dirty Subtrees back to storage.
-func (s *SubtreeCache) Flush(setSubtrees SetSubtreesFunc) error {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *SubtreeCache) Flush(ctx context.Context, setSubtrees SetSubtreesFunc) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
 	treesToWrite := make([]*storage.SubtreeProto, 0, len(s.dirtyPrefixes))
 	for k, v := range s.subtrees {
@@ -232,18 +796,16 @@ func (s *SubtreeCache) Flush(setSubtrees SetSubtreesFunc) error {
 			if !bytes.Equal(bk, v.Prefix) {
 				return fmt.Errorf("inconsistent cache: prefix key is %v, but cached object claims %v", bk, v.Prefix)
 			}
-			// TODO(al): Do actually write this one once we're storing the updated
-			// subtree root value here during tree update calculations.
-			v.RootHash = nil
-
-			if len(v.Leaves) > 0 {
-				// clear the internal node cache; we don't want to write that.
-				v.InternalNodes = nil
-				treesToWrite = append(treesToWrite, v)
+			st, err := s.prepareForWrite(v)
+			if err != nil {
+				return err
+			}
+			if st != nil {
+				treesToWrite = append(treesToWrite, st)
 			}
 		}
 	}
-	if err := setSubtrees(treesToWrite); err != nil {
+	if err := setSubtrees(ctx, treesToWrite); err != nil {
 		return err
 	}
 	return nil
@@ -263,27 +825,89 @@ func makeSuffixKey(depth int, index int64) (string, error) {
 	return sfx.serialize(), nil
 }
 
+// PopulateSubtrees calls populate for each of subtrees, using up to workers goroutines so
+// that large batches of subtrees are repopulated in parallel. populate must be safe to call
+// concurrently for different subtrees, which the functions returned by
+// PopulateMapSubtreeNodes and PopulateLogSubtreeNodes are. If any call fails then one of
+// the errors is returned and no more subtrees are handed out, so some may not have been
+// populated.
+func PopulateSubtrees(populate storage.PopulateSubtreeFunc, subtrees []*storage.SubtreeProto, workers int) error {
+	if workers > len(subtrees) {
+		workers = len(subtrees)
+	}
+	if workers <= 1 {
+		for _, st := range subtrees {
+			if err := populate(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	work := make(chan *storage.SubtreeProto)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for st := range work {
+				if err := populate(st); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	// Stop handing out work as soon as a worker fails. A failed worker has stopped
+	// reading, but there's room in errs for every worker so the others can still finish.
+	var err error
+	for _, st := range subtrees {
+		select {
+		case work <- st:
+			continue
+		case err = <-errs:
+		}
+		break
+	}
+	close(work)
+	wg.Wait()
+
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	return err
+}
+
 // PopulateMapSubtreeNodes re-creates Map subtree's InternalNodes from the
-// subtree Leaves map.
+// subtree Leaves map. The keys of the subtree are converted to the version used in
+// memory first.
 //
 // This uses HStar2 to repopulate internal nodes.
 func PopulateMapSubtreeNodes(treeHasher merkle.TreeHasher) storage.PopulateSubtreeFunc {
 	return func(st *storage.SubtreeProto) error {
+		if err := storage.ConvertSubtreeKeys(st, memoryKeyVersion); err != nil {
+			return err
+		}
 		st.InternalNodes = make(map[string][]byte)
 		rootID := storage.NewNodeIDFromHash(st.Prefix)
 		fullTreeDepth := treeHasher.Size() * 8
 		leaves := make([]merkle.HStar2LeafHash, 0, len(st.Leaves))
-		for k64, v := range st.Leaves {
-			k, err := base64.StdEncoding.DecodeString(k64)
+		for k, v := range st.Leaves {
+			bits, path, err := storage.DecodeSuffixKey(st.KeyVersion, k)
 			if err != nil {
 				return err
 			}
-			if k[0] != 8 {
-				return fmt.Errorf("unexpected non-leaf suffix found: %x", k)
+			if bits != 8 {
+				return fmt.Errorf("unexpected non-leaf suffix found: %s", k)
 			}
 			leaves = append(leaves, merkle.HStar2LeafHash{
 				LeafHash: v,
-				Index:    big.NewInt(int64(k[1])),
+				Index:    big.NewInt(int64(path)),
 			})
 		}
 		hs2 := merkle.NewHStar2(treeHasher)
@@ -310,12 +934,16 @@ func PopulateMapSubtreeNodes(treeHasher merkle.TreeHasher) storage.PopulateSubtr
 }
 
 // PopulateLogSubtreeNodes re-creates a Log subtree's InternalNodes from the
-// subtree Leaves map.
+// subtree Leaves map. The keys of the subtree are converted to the version used in
+// memory first.
 //
 // This uses the CompactMerkleTree to repopulate internal nodes, and so will
 // handle imperfect (but left-hand dense) subtrees.
 func PopulateLogSubtreeNodes(treeHasher merkle.TreeHasher) storage.PopulateSubtreeFunc {
 	return func(st *storage.SubtreeProto) error {
+		if err := storage.ConvertSubtreeKeys(st, memoryKeyVersion); err != nil {
+			return err
+		}
 		st.InternalNodes = make(map[string][]byte)
 		cmt := merkle.NewCompactMerkleTree(treeHasher)
 		for leafIndex := int64(0); leafIndex < int64(len(st.Leaves)); leafIndex++ {
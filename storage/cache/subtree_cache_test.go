@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -15,6 +17,7 @@ import (
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
 )
 
 var splitTestVector = []struct {
@@ -42,8 +45,7 @@ var splitTestVector = []struct {
 
 func TestSplitNodeID(t *testing.T) {
 	for i, v := range splitTestVector {
-		n := storage.NewNodeIDFromHash(v.inPath)
-		n.PrefixLenBits = v.inPathLenBits
+		n := storage.NewNodeIDFromHash(v.inPath).Prefix(v.inPathLenBits)
 
 		p, s := splitNodeID(n)
 		if expected, got := v.outPrefix, p; !bytes.Equal(expected, got) {
@@ -61,6 +63,7 @@ func TestSplitNodeID(t *testing.T) {
 }
 
 func TestCacheFillOnlyReadsSubtrees(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -71,27 +74,321 @@ func TestCacheFillOnlyReadsSubtrees(t *testing.T) {
 	// When we loop around asking for all 0..32 bit prefix lengths of the above
 	// NodeID, we should see just one "Get" request for each subtree.
 	for b := 0; b < nodeID.PrefixLenBits; b += strataDepth {
-		e := nodeID
-		e.PrefixLenBits = b
-		m.EXPECT().GetSubtree(testonly.NodeIDEq(e)).Return(&storage.SubtreeProto{
+		e := nodeID.Prefix(b)
+		m.EXPECT().GetSubtree(gomock.Any(), testonly.NodeIDEq(e)).Return(&storage.SubtreeProto{
 			Prefix: e.Path,
 		}, nil)
 	}
 
-	for nodeID.PrefixLenBits > 0 {
-		_, err := c.GetNodeHash(nodeID, m.GetSubtree)
+	for b := nodeID.PrefixLenBits; b > 0; b-- {
+		_, err := c.GetNodeHash(ctx, nodeID.Prefix(b), m.GetSubtree)
 		if err != nil {
 			t.Fatalf("failed to get node hash: %v", err)
 		}
-		nodeID.PrefixLenBits--
 	}
 }
 
-func noFetch(id storage.NodeID) (*storage.SubtreeProto, error) {
+func TestGetNodeHashesBatchesSubtreeReads(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+	nodeID := storage.NewNodeIDFromHash([]byte("1234"))
+	ids := make([]storage.NodeID, 0, nodeID.PrefixLenBits)
+	for b := nodeID.PrefixLenBits; b > 0; b-- {
+		ids = append(ids, nodeID.Prefix(b))
+	}
+
+	// All four subtrees should be requested in one call. Storage only has one of them.
+	m.EXPECT().GetSubtrees(gomock.Any(), gomock.Any()).Do(func(_ context.Context, n []storage.NodeID) {
+		if got, want := len(n), 4; got != want {
+			t.Errorf("requested %d subtrees, expected %d", got, want)
+		}
+	}).Return([]*storage.SubtreeProto{{Prefix: nodeID.Path[:1]}}, nil)
+
+	hashes, err := c.GetNodeHashes(ctx, ids, m.GetSubtrees)
+	if err != nil {
+		t.Fatalf("failed to get node hashes: %v", err)
+	}
+	if got, want := len(hashes), len(ids); got != want {
+		t.Fatalf("got %d hashes, expected %d", got, want)
+	}
+
+	// Subtrees which storage didn't have must be cached too so we don't ask again.
+	for _, id := range ids {
+		if _, err := c.GetNodeHash(ctx, id, noFetch); err != nil {
+			t.Fatalf("failed to get cached node hash for %v: %v", id, err)
+		}
+	}
+}
+
+func TestGetProofPath(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mapLeafID := storage.NewNodeIDFromHash([]byte("1234"))
+	logLeafID, err := storage.NewNodeIDForTreeCoords(0, 5, 64)
+	if err != nil {
+		t.Fatalf("failed to create log leaf ID: %v", err)
+	}
+	logPathIDs, err := merkle.CalcInclusionProofNodeAddresses(11, 5, 64)
+	if err != nil {
+		t.Fatalf("failed to calculate log proof: %v", err)
+	}
+
+	for _, test := range []struct {
+		desc     string
+		leafID   storage.NodeID
+		treeSize int64
+		wantIDs  []storage.NodeID
+		// wantReads is the number of subtrees requested from storage
+		wantReads int
+	}{
+		{desc: "map", leafID: mapLeafID, treeSize: 0, wantIDs: mapLeafID.Siblings(), wantReads: 4},
+		{desc: "log", leafID: logLeafID, treeSize: 11, wantIDs: logPathIDs, wantReads: 1},
+	} {
+		m := NewMockNodeStorage(mockCtrl)
+		c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+		// The whole path should be requested in one call, storage has none of it.
+		m.EXPECT().GetSubtrees(gomock.Any(), gomock.Any()).Do(func(_ context.Context, n []storage.NodeID) {
+			if got, want := len(n), test.wantReads; got != want {
+				t.Errorf("%s: requested %d subtrees, expected %d", test.desc, got, want)
+			}
+		}).Return(nil, nil)
+
+		nodes, err := c.GetProofPath(ctx, test.leafID, test.treeSize, m.GetSubtrees)
+		if err != nil {
+			t.Fatalf("%s: failed to get proof path: %v", test.desc, err)
+		}
+		if got, want := len(nodes), len(test.wantIDs); got != want {
+			t.Fatalf("%s: got %d nodes, expected %d", test.desc, got, want)
+		}
+		for i, n := range nodes {
+			if !n.NodeID.Equivalent(test.wantIDs[i]) {
+				t.Errorf("%s: got node %v at pos %d, expected %v", test.desc, n.NodeID, i, test.wantIDs[i])
+			}
+			if n.Hash != nil {
+				t.Errorf("%s: got hash %x for unwritten node %v", test.desc, n.Hash, n.NodeID)
+			}
+		}
+
+		// Once written, the path is read back from the cache.
+		for _, id := range test.wantIDs {
+			if err := c.SetNodeHash(ctx, id, []byte(id.String()), noFetch, nil); err != nil {
+				t.Fatalf("%s: failed to set node hash: %v", test.desc, err)
+			}
+		}
+		nodes, err = c.GetProofPath(ctx, test.leafID, test.treeSize, func(context.Context, []storage.NodeID) ([]*storage.SubtreeProto, error) {
+			return nil, errors.New("not supposed to read anything")
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to get cached proof path: %v", test.desc, err)
+		}
+		for i, n := range nodes {
+			if got, want := string(n.Hash), test.wantIDs[i].String(); got != want {
+				t.Errorf("%s: got hash %q at pos %d, expected %q", test.desc, got, i, want)
+			}
+		}
+	}
+}
+
+func TestGetProofPathRejectsBadLogLeaf(t *testing.T) {
+	c := NewSubtreeCache(PopulateLogSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+	internalID, err := storage.NewNodeIDForTreeCoords(1, 2, 64)
+	if err != nil {
+		t.Fatalf("failed to create node ID: %v", err)
+	}
+	if _, err := c.GetProofPath(context.Background(), internalID, 11, nil); err == nil {
+		t.Errorf("got a proof path for internal node %v", internalID)
+	}
+}
+
+func TestPreloadSiblings(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+	nodeID := storage.NewNodeIDFromHash([]byte("1234"))
+	m.EXPECT().GetSubtrees(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	if err := c.PreloadSiblings(ctx, nodeID, m.GetSubtrees); err != nil {
+		t.Fatalf("failed to preload siblings: %v", err)
+	}
+
+	for _, sib := range nodeID.Siblings() {
+		if _, err := c.GetNodeHash(ctx, sib, noFetch); err != nil {
+			t.Fatalf("failed to get cached sibling hash for %v: %v", sib, err)
+		}
+	}
+
+	// Already cached so there should be no further storage reads.
+	if err := c.PreloadSiblings(ctx, nodeID, m.GetSubtrees); err != nil {
+		t.Fatalf("failed to preload siblings again: %v", err)
+	}
+}
+
+func TestCacheCountsHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+	nodeID := storage.NewNodeIDFromHash([]byte("1234"))
+	m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Return(nil, nil)
+	hits, misses := subtreeCacheHits.Value(), subtreeCacheMisses.Value()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetNodeHash(ctx, nodeID, m.GetSubtree); err != nil {
+			t.Fatalf("failed to get node hash: %v", err)
+		}
+	}
+
+	if got, want := subtreeCacheMisses.Value()-misses, float64(1); got != want {
+		t.Errorf("got %v cache misses, want %v", got, want)
+	}
+
+	if got, want := subtreeCacheHits.Value()-hits, float64(2); got != want {
+		t.Errorf("got %v cache hits, want %v", got, want)
+	}
+}
+
+func noFetch(_ context.Context, id storage.NodeID) (*storage.SubtreeProto, error) {
 	return nil, errors.New("not supposed to read anything")
 }
 
+func TestCacheConcurrentReadsFetchEachSubtreeOnce(t *testing.T) {
+	ctx := context.Background()
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	getSubtrees := func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, id := range ids {
+			fetches[string(id.Path[:id.PrefixLenBits/8])]++
+		}
+		return nil, nil
+	}
+	getSubtree := func(ctx context.Context, id storage.NodeID) (*storage.SubtreeProto, error) {
+		_, err := getSubtrees(ctx, []storage.NodeID{id})
+		return nil, err
+	}
+
+	nodeID := storage.NewNodeIDFromHash([]byte("1234"))
+	ids := make([]storage.NodeID, 0, nodeID.PrefixLenBits)
+	for b := nodeID.PrefixLenBits; b > 0; b-- {
+		ids = append(ids, nodeID.Prefix(b))
+	}
+
+	const numReaders = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, numReaders*2)
+	for i := 0; i < numReaders; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetNodeHashes(ctx, ids, getSubtrees); err != nil {
+				errs <- err
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if _, err := c.GetNodeHash(ctx, ids[i%len(ids)], getSubtree); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("failed to get node hash: %v", err)
+	}
+
+	if got, want := len(fetches), 4; got != want {
+		t.Errorf("fetched %d subtrees, expected %d", got, want)
+	}
+	for px, n := range fetches {
+		if n != 1 {
+			t.Errorf("subtree %x fetched %d times, expected once", px, n)
+		}
+	}
+}
+
+func TestCacheStopsWaitingForReadWhenContextDone(t *testing.T) {
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+	nodeID := storage.NewNodeIDFromHash([]byte("1234"))
+
+	// The first read blocks until the test is over.
+	reading, release := make(chan struct{}), make(chan struct{})
+	slowFetch := func(_ context.Context, id storage.NodeID) (*storage.SubtreeProto, error) {
+		close(reading)
+		<-release
+		return nil, nil
+	}
+	done := make(chan error)
+	go func() {
+		_, err := c.GetNodeHash(context.Background(), nodeID, slowFetch)
+		done <- err
+	}()
+	<-reading
+
+	// A reader of the same subtree waits for the first read, until its context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetNodeHash(ctx, nodeID, noFetch); err != context.Canceled {
+		t.Errorf("got %v from cancelled read, expected %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("failed to get node hash: %v", err)
+	}
+}
+
+func TestCacheConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+
+	emptyFetch := func(_ context.Context, id storage.NodeID) (*storage.SubtreeProto, error) {
+		return nil, nil
+	}
+
+	const numWriters = 16
+	var wg sync.WaitGroup
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nodeID := storage.NewNodeIDFromHash([]byte{byte(i), 0x34})
+			h := trillian.Hash([]byte{byte(i)})
+			if err := c.SetNodeHash(ctx, nodeID, h, emptyFetch, nil); err != nil {
+				t.Errorf("failed to set node hash %d: %v", i, err)
+			}
+			got, err := c.GetNodeHash(ctx, nodeID, noFetch)
+			if err != nil {
+				t.Errorf("failed to get node hash %d: %v", i, err)
+			}
+			if !bytes.Equal(got, h) {
+				t.Errorf("got hash %v for node %d, expected %v", got, i, h)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 func TestCacheFlush(t *testing.T) {
+	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
@@ -103,15 +400,13 @@ func TestCacheFlush(t *testing.T) {
 	// When we loop around asking for all 0..32 bit prefix lengths of the above
 	// NodeID, we should see just one "Get" request for each subtree.
 	for b := 0; b < nodeID.PrefixLenBits; b += strataDepth {
-		e := storage.NewNodeIDFromHash([]byte("1234"))
-		//e := nodeID
-		e.PrefixLenBits = b
+		e := nodeID.Prefix(b)
 		expectedSetIDs[e.String()] = "expected"
-		m.EXPECT().GetSubtree(testonly.NodeIDEq(e)).Do(func(n storage.NodeID) {
+		m.EXPECT().GetSubtree(gomock.Any(), testonly.NodeIDEq(e)).Do(func(_ context.Context, n storage.NodeID) {
 			t.Logf("read %v", n)
 		}).Return((*storage.SubtreeProto)(nil), nil)
 	}
-	m.EXPECT().SetSubtrees(gomock.Any()).Do(func(trees []*storage.SubtreeProto) {
+	m.EXPECT().SetSubtrees(gomock.Any(), gomock.Any()).Do(func(_ context.Context, trees []*storage.SubtreeProto) {
 		for _, s := range trees {
 			subID := storage.NewNodeIDFromHash(s.Prefix)
 			state, ok := expectedSetIDs[subID.String()]
@@ -133,7 +428,7 @@ func TestCacheFlush(t *testing.T) {
 	// Read nodes which touch the subtrees we'll write to:
 	sibs := nodeID.Siblings()
 	for s := range sibs {
-		_, err := c.GetNodeHash(sibs[s], m.GetSubtree)
+		_, err := c.GetNodeHash(ctx, sibs[s], m.GetSubtree)
 		if err != nil {
 			t.Fatalf("failed to get node hash: %v", err)
 		}
@@ -142,16 +437,16 @@ func TestCacheFlush(t *testing.T) {
 	t.Logf("after sibs: %v", nodeID)
 
 	// Write nodes
-	for nodeID.PrefixLenBits > 0 {
-		h := []byte(nodeID.String())
-		err := c.SetNodeHash(nodeID, append([]byte("hash-"), h...), noFetch)
+	for b := nodeID.PrefixLenBits; b > 0; b-- {
+		id := nodeID.Prefix(b)
+		h := []byte(id.String())
+		err := c.SetNodeHash(ctx, id, append([]byte("hash-"), h...), noFetch, nil)
 		if err != nil {
 			t.Fatalf("failed to set node hash: %v", err)
 		}
-		nodeID.PrefixLenBits--
 	}
 
-	if err := c.Flush(m.SetSubtrees); err != nil {
+	if err := c.Flush(ctx, m.SetSubtrees); err != nil {
 		t.Fatalf("failed to flush cache: %v", err)
 	}
 
@@ -167,6 +462,120 @@ func TestCacheFlush(t *testing.T) {
 	}
 }
 
+func TestCacheEvictsCleanSubtreesOverLimit(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCacheWithLimits(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), CacheLimits{MaxSubtrees: 2})
+
+	// Three different subtrees, the first should be evicted when the third is read and
+	// so it must be fetched again afterwards.
+	ids := []storage.NodeID{
+		storage.NewNodeIDFromHash([]byte("1234")).Prefix(9),
+		storage.NewNodeIDFromHash([]byte("5678")).Prefix(9),
+		storage.NewNodeIDFromHash([]byte("9abc")).Prefix(9),
+	}
+
+	gomock.InOrder(
+		m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Return(nil, nil),
+		m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Return(nil, nil),
+		m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Return(nil, nil),
+		m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Return(nil, nil))
+
+	for _, id := range append(ids, ids[0]) {
+		if _, err := c.GetNodeHash(ctx, id, m.GetSubtree); err != nil {
+			t.Fatalf("failed to get node hash: %v", err)
+		}
+	}
+
+	if got, want := len(c.subtrees), 2; got != want {
+		t.Fatalf("cache holds %d subtrees, expected %d", got, want)
+	}
+
+	// The most recently used subtrees should still be present so this must not fetch
+	if _, err := c.GetNodeHash(ctx, ids[2], noFetch); err != nil {
+		t.Fatalf("failed to get cached node hash: %v", err)
+	}
+}
+
+func TestCacheWritesBackDirtySubtreesOverLimit(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCacheWithLimits(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), CacheLimits{MaxSubtrees: 1})
+
+	first := storage.NewNodeIDFromHash([]byte("1234")).Prefix(16)
+	second := storage.NewNodeIDFromHash([]byte("5678")).Prefix(16)
+
+	m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Times(2).Return(nil, nil)
+
+	var written [][]byte
+	m.EXPECT().SetSubtrees(gomock.Any(), gomock.Any()).Times(2).Do(func(_ context.Context, trees []*storage.SubtreeProto) {
+		for _, s := range trees {
+			written = append(written, s.Prefix)
+		}
+	}).Return(nil)
+
+	if err := c.SetNodeHash(ctx, first, []byte("hash1"), m.GetSubtree, m.SetSubtrees); err != nil {
+		t.Fatalf("failed to set node hash: %v", err)
+	}
+
+	// Setting a node in a second subtree pushes the cache over its limit and the
+	// first dirty subtree has to be written back.
+	if err := c.SetNodeHash(ctx, second, []byte("hash2"), m.GetSubtree, m.SetSubtrees); err != nil {
+		t.Fatalf("failed to set node hash: %v", err)
+	}
+
+	if got, want := len(written), 1; got != want {
+		t.Fatalf("wrote %d subtrees when over limit, expected %d", got, want)
+	}
+	if got, want := written[0], first.Path[:1]; !bytes.Equal(got, want) {
+		t.Fatalf("wrote back subtree %x, expected %x", got, want)
+	}
+
+	// Only the remaining dirty subtree should be written on flush.
+	if err := c.Flush(ctx, m.SetSubtrees); err != nil {
+		t.Fatalf("failed to flush cache: %v", err)
+	}
+	if got, want := len(written), 2; got != want {
+		t.Fatalf("wrote %d subtrees in total, expected %d", got, want)
+	}
+	if got, want := written[1], second.Path[:1]; !bytes.Equal(got, want) {
+		t.Fatalf("flushed subtree %x, expected %x", got, want)
+	}
+}
+
+func TestCacheWriteBackFailsPreservesDirtySubtrees(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMockNodeStorage(mockCtrl)
+	c := NewSubtreeCacheWithLimits(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())), CacheLimits{MaxBytes: 1})
+
+	first := storage.NewNodeIDFromHash([]byte("1234")).Prefix(16)
+	second := storage.NewNodeIDFromHash([]byte("5678")).Prefix(16)
+
+	m.EXPECT().GetSubtree(gomock.Any(), gomock.Any()).Times(2).Return(nil, nil)
+	m.EXPECT().SetSubtrees(gomock.Any(), gomock.Any()).Return(errors.New("write failed"))
+
+	if err := c.SetNodeHash(ctx, first, []byte("hash1"), m.GetSubtree, m.SetSubtrees); err != nil {
+		t.Fatalf("failed to set node hash: %v", err)
+	}
+
+	if err := c.SetNodeHash(ctx, second, []byte("hash2"), m.GetSubtree, m.SetSubtrees); err == nil {
+		t.Fatal("expected error from failed write back")
+	}
+
+	if got, want := len(c.dirtyPrefixes), 2; got != want {
+		t.Fatalf("cache has %d dirty subtrees after failed write back, expected %d", got, want)
+	}
+}
+
 func TestSuffixSerializeFormat(t *testing.T) {
 	s := Suffix{5, 0xae}
 	if got, want := s.serialize(), "Ba4="; got != want {
@@ -174,26 +583,108 @@ func TestSuffixSerializeFormat(t *testing.T) {
 	}
 }
 
+func TestCacheReadsSubtreesOfEveryKeyVersion(t *testing.T) {
+	ctx := context.Background()
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	want := makeLogSubtrees(hasher, 2)[1]
+	if err := PopulateLogSubtreeNodes(hasher)(want); err != nil {
+		t.Fatalf("failed to populate subtree: %v", err)
+	}
+	leafID := storage.NewNodeIDFromHash([]byte{0x00, 0x01, 0x00})
+	internalID := leafID.Prefix(20)
+	_, internalSfx := splitNodeID(internalID)
+
+	for version := storage.SuffixKeyV0; version <= storage.MaxSuffixKeyVersion; version++ {
+		stored := makeLogSubtrees(hasher, 2)[1]
+		if err := storage.ConvertSubtreeKeys(stored, version); err != nil {
+			t.Fatalf("failed to convert subtree to version %d: %v", version, err)
+		}
+		getSubtree := func(context.Context, storage.NodeID) (*storage.SubtreeProto, error) {
+			return stored, nil
+		}
+		c := NewSubtreeCache(PopulateLogSubtreeNodes(hasher))
+
+		for _, test := range []struct {
+			id   storage.NodeID
+			want []byte
+		}{
+			{leafID, want.Leaves[Suffix{8, 0}.serialize()]},
+			{internalID, want.InternalNodes[internalSfx.serialize()]},
+		} {
+			h, err := c.GetNodeHash(ctx, test.id, getSubtree)
+			if err != nil {
+				t.Fatalf("version %d: failed to get node hash: %v", version, err)
+			}
+			if len(test.want) == 0 || !bytes.Equal(h, test.want) {
+				t.Errorf("version %d: got hash %x for %v, expected %x", version, h, test.id, test.want)
+			}
+		}
+	}
+}
+
+func TestCacheWritesSubtreeKeyVersion(t *testing.T) {
+	ctx := context.Background()
+	id := storage.NewNodeIDFromHash([]byte{0x12, 0x34})
+	getSubtree := func(context.Context, storage.NodeID) (*storage.SubtreeProto, error) {
+		return nil, nil
+	}
+
+	for version := storage.SuffixKeyV0; version <= storage.MaxSuffixKeyVersion; version++ {
+		c := NewSubtreeCache(PopulateMapSubtreeNodes(merkle.NewRFC6962TreeHasher(trillian.NewSHA256())))
+		c.SetSubtreeKeyVersion(version)
+		if err := c.SetNodeHash(ctx, id, []byte("hash"), getSubtree, nil); err != nil {
+			t.Fatalf("version %d: failed to set node hash: %v", version, err)
+		}
+
+		var written []*storage.SubtreeProto
+		if err := c.Flush(ctx, func(_ context.Context, trees []*storage.SubtreeProto) error {
+			written = append(written, trees...)
+			return nil
+		}); err != nil {
+			t.Fatalf("version %d: failed to flush cache: %v", version, err)
+		}
+
+		if got, want := len(written), 1; got != want {
+			t.Fatalf("version %d: wrote %d subtrees, expected %d", version, got, want)
+		}
+		if got, want := written[0].KeyVersion, version; got != want {
+			t.Errorf("version %d: wrote subtree with key version %d", version, got)
+		}
+		key, err := storage.EncodeSuffixKey(version, 8, 0x34)
+		if err != nil {
+			t.Fatalf("failed to encode suffix key: %v", err)
+		}
+		if got, want := written[0].Leaves, map[string][]byte{key: []byte("hash")}; !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: wrote leaves %v, expected %v", version, got, want)
+		}
+
+		// The cached subtree keeps the keys used in memory.
+		if h, err := c.GetNodeHash(ctx, id, noFetch); err != nil || !bytes.Equal(h, []byte("hash")) {
+			t.Errorf("version %d: got cached hash %x, %v after flush", version, h, err)
+		}
+	}
+}
+
 func TestRepopulateMapSubtreeKAT(t *testing.T) {
 	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
 	populateTheThing := PopulateMapSubtreeNodes(hasher)
-	pb, err := ioutil.ReadFile("../../testdata/map_good_subtree.pb")
+	b, err := ioutil.ReadFile("../../testdata/map_good_subtree.json")
 	if err != nil {
 		t.Fatalf("failed to read test data: %v", err)
 	}
-	goodSubtree := storage.SubtreeProto{}
-	if err := proto.UnmarshalText(string(pb), &goodSubtree); err != nil {
+	goodSubtree, err := storage.UnmarshalSubtree(b, storage.SubtreeFormatCanonical)
+	if err != nil {
 		t.Fatalf("failed to unmarshal SubtreeProto: %v", err)
 	}
 
-	leavesOnly := storage.SubtreeProto{}
-	if err := proto.UnmarshalText(string(pb), &leavesOnly); err != nil {
+	leavesOnly, err := storage.UnmarshalSubtree(b, storage.SubtreeFormatCanonical)
+	if err != nil {
 		t.Fatalf("failed to unmarshal SubtreeProto: %v", err)
 	}
 	// erase the internal nodes
 	leavesOnly.InternalNodes = make(map[string][]byte)
 
-	if err := populateTheThing(&leavesOnly); err != nil {
+	if err := populateTheThing(leavesOnly); err != nil {
 		t.Fatalf("failed to repopulate subtree: %v", err)
 	}
 	if got, want := trillian.Hash(leavesOnly.RootHash), trillian.Hash(goodSubtree.RootHash); !bytes.Equal(got, want) {
@@ -284,8 +775,8 @@ type logKATData struct {
 
 func TestRepopulateLogSubtreeKAT(t *testing.T) {
 	testVector := []logKATData{
-		{"log_good_subtree_5.pb", 5},
-		{"log_good_subtree_55.pb", 55},
+		{"log_good_subtree_5.json", 5},
+		{"log_good_subtree_55.json", 55},
 	}
 
 	for _, k := range testVector {
@@ -296,24 +787,24 @@ func TestRepopulateLogSubtreeKAT(t *testing.T) {
 func runLogSubtreeKAT(t *testing.T, data logKATData) {
 	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
 	populateTheThing := PopulateLogSubtreeNodes(hasher)
-	pb, err := ioutil.ReadFile("../../testdata/" + data.File)
+	b, err := ioutil.ReadFile("../../testdata/" + data.File)
 	if err != nil {
 		t.Fatalf("failed to read test data: %v", err)
 	}
-	goodSubtree := storage.SubtreeProto{}
-	if err := proto.UnmarshalText(string(pb), &goodSubtree); err != nil {
+	goodSubtree, err := storage.UnmarshalSubtree(b, storage.SubtreeFormatCanonical)
+	if err != nil {
 		t.Fatalf("failed to unmarshal SubtreeProto: %v", err)
 	}
 	t.Logf("good root %v", trillian.Hash(goodSubtree.RootHash))
 
-	leavesOnly := storage.SubtreeProto{}
-	if err := proto.UnmarshalText(string(pb), &leavesOnly); err != nil {
+	leavesOnly, err := storage.UnmarshalSubtree(b, storage.SubtreeFormatCanonical)
+	if err != nil {
 		t.Fatalf("failed to unmarshal SubtreeProto: %v", err)
 	}
 	// erase the internal nodes
 	leavesOnly.InternalNodes = make(map[string][]byte)
 
-	if err := populateTheThing(&leavesOnly); err != nil {
+	if err := populateTheThing(leavesOnly); err != nil {
 		t.Fatalf("failed to repopulate subtree: %v", err)
 	}
 	if got, want := trillian.Hash(leavesOnly.RootHash), trillian.Hash(goodSubtree.RootHash); !bytes.Equal(got, want) {
@@ -346,3 +837,183 @@ func runLogSubtreeKAT(t *testing.T, data logKATData) {
 		}
 	}
 }
+
+// makeLogSubtrees creates count log subtrees holding between 1 and 256 leaves.
+func makeLogSubtrees(hasher merkle.TreeHasher, count int) []*storage.SubtreeProto {
+	subtrees := make([]*storage.SubtreeProto, 0, count)
+	for i := 0; i < count; i++ {
+		st := newEmptySubtree([]byte{byte(i >> 8), byte(i)})
+		for leaf := int64(0); leaf <= int64((i*37)%256); leaf++ {
+			sfx, err := makeSuffixKey(8, leaf)
+			if err != nil {
+				panic(err)
+			}
+			st.Leaves[sfx] = hasher.HashLeaf([]byte(fmt.Sprintf("subtree %d leaf %d", i, leaf)))
+		}
+		subtrees = append(subtrees, st)
+	}
+	return subtrees
+}
+
+// makeMapSubtrees creates count map subtrees at the bottom of the tree, each with 16 leaves.
+func makeMapSubtrees(hasher merkle.TreeHasher, count int) []*storage.SubtreeProto {
+	subtrees := make([]*storage.SubtreeProto, 0, count)
+	for i := 0; i < count; i++ {
+		prefix := make([]byte, hasher.Size()-1)
+		prefix[0], prefix[1] = byte(i>>8), byte(i)
+		st := newEmptySubtree(prefix)
+		for leaf := int64(0); leaf < 256; leaf += 16 {
+			sfx, err := makeSuffixKey(8, leaf)
+			if err != nil {
+				panic(err)
+			}
+			st.Leaves[sfx] = hasher.HashLeaf([]byte(fmt.Sprintf("subtree %d leaf %d", i, leaf)))
+		}
+		subtrees = append(subtrees, st)
+	}
+	return subtrees
+}
+
+func TestPopulateSubtreesMatchesSerial(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for _, test := range []struct {
+		desc     string
+		populate storage.PopulateSubtreeFunc
+		make     func(merkle.TreeHasher, int) []*storage.SubtreeProto
+	}{
+		{"log", PopulateLogSubtreeNodes(hasher), makeLogSubtrees},
+		{"map", PopulateMapSubtreeNodes(hasher), makeMapSubtrees},
+	} {
+		want := test.make(hasher, 50)
+		for _, st := range want {
+			if err := test.populate(st); err != nil {
+				t.Fatalf("%s: failed to populate subtree: %v", test.desc, err)
+			}
+		}
+
+		for _, workers := range []int{0, 1, 4, 100} {
+			got := test.make(hasher, 50)
+			if err := PopulateSubtrees(test.populate, got, workers); err != nil {
+				t.Fatalf("%s: PopulateSubtrees(%d workers): %v", test.desc, workers, err)
+			}
+			for i := range got {
+				if !proto.Equal(got[i], want[i]) {
+					t.Errorf("%s: PopulateSubtrees(%d workers) subtree %d is\n%v, expected\n%v", test.desc, workers, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestPopulateSubtreesReturnsError(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	populateErr := errors.New("populate failed")
+	populate := func(st *storage.SubtreeProto) error {
+		if st.Prefix[1] == 10 {
+			return populateErr
+		}
+		return nil
+	}
+
+	for _, workers := range []int{1, 4} {
+		if err := PopulateSubtrees(populate, makeLogSubtrees(hasher, 50), workers); err != populateErr {
+			t.Errorf("PopulateSubtrees(%d workers) got error %v, expected %v", workers, err, populateErr)
+		}
+	}
+}
+
+func benchmarkPopulateSubtrees(b *testing.B, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc, makeSubtrees func(merkle.TreeHasher, int) []*storage.SubtreeProto, workers int) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	populate := populateFactory(hasher)
+	subtrees := makeSubtrees(hasher, 256)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := PopulateSubtrees(populate, subtrees, workers); err != nil {
+			b.Fatalf("failed to populate subtrees: %v", err)
+		}
+	}
+}
+
+func BenchmarkPopulateLogSubtreesSerial(b *testing.B) {
+	benchmarkPopulateSubtrees(b, PopulateLogSubtreeNodes, makeLogSubtrees, 1)
+}
+
+func BenchmarkPopulateLogSubtreesParallel(b *testing.B) {
+	benchmarkPopulateSubtrees(b, PopulateLogSubtreeNodes, makeLogSubtrees, runtime.GOMAXPROCS(0))
+}
+
+func BenchmarkPopulateMapSubtreesSerial(b *testing.B) {
+	benchmarkPopulateSubtrees(b, PopulateMapSubtreeNodes, makeMapSubtrees, 1)
+}
+
+func BenchmarkPopulateMapSubtreesParallel(b *testing.B) {
+	benchmarkPopulateSubtrees(b, PopulateMapSubtreeNodes, makeMapSubtrees, runtime.GOMAXPROCS(0))
+}
+
+func TestCacheStoresInternalNodesWhenConfigured(t *testing.T) {
+	ctx := context.Background()
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	// Both nodes are in the subtree with prefix 0x0001
+	leafID, err := storage.NewNodeIDForTreeCoords(0, 256, 24)
+	if err != nil {
+		t.Fatalf("failed to create nodeID: %v", err)
+	}
+	internalID, err := storage.NewNodeIDForTreeCoords(1, 128, 24)
+	if err != nil {
+		t.Fatalf("failed to create nodeID: %v", err)
+	}
+
+	for _, store := range []bool{false, true} {
+		// The subtree holding leafID, as stored with only its leaves
+		stored := makeLogSubtrees(hasher, 2)[1]
+		populateCalls := 0
+		populate := func(st *storage.SubtreeProto) error {
+			populateCalls++
+			return PopulateLogSubtreeNodes(hasher)(st)
+		}
+		getSubtree := func(context.Context, storage.NodeID) (*storage.SubtreeProto, error) {
+			return proto.Clone(stored).(*storage.SubtreeProto), nil
+		}
+
+		c := NewSubtreeCache(populate)
+		c.SetStoreInternalNodes(store)
+		if err := c.SetNodeHash(ctx, leafID, hasher.HashLeaf([]byte("new leaf")), getSubtree, nil); err != nil {
+			t.Fatalf("failed to set node hash: %v", err)
+		}
+		var written []*storage.SubtreeProto
+		if err := c.Flush(ctx, func(_ context.Context, trees []*storage.SubtreeProto) error {
+			written = trees
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to flush cache: %v", err)
+		}
+		if got, want := len(written), 1; got != want {
+			t.Fatalf("store=%v: wrote %d subtrees, expected %d", store, got, want)
+		}
+		if got := len(written[0].InternalNodes) > 0; got != store {
+			t.Errorf("store=%v: wrote subtree with %d internal nodes", store, len(written[0].InternalNodes))
+		}
+
+		// Read back what was written, the internal nodes should only be recalculated if
+		// they weren't stored
+		stored = written[0]
+		populateCalls = 0
+		c = NewSubtreeCache(populate)
+		h, err := c.GetNodeHash(ctx, internalID, getSubtree)
+		if err != nil {
+			t.Fatalf("failed to get node hash: %v", err)
+		}
+		if len(h) == 0 {
+			t.Errorf("store=%v: got no hash for internal node", store)
+		}
+		if got, want := populateCalls, 1; store {
+			if got != 0 {
+				t.Errorf("store=%v: populated %d subtrees stored with internal nodes, expected none", store, got)
+			}
+		} else if got != want {
+			t.Errorf("store=%v: populated %d subtrees, expected %d", store, got, want)
+		}
+	}
+}
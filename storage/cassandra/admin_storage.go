@@ -0,0 +1,384 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+const selectTreeColumnsCql string = `SELECT TreeId, KeyId, TreeType, TreeState, LeafHasherType, AllowsDuplicateLeaves,
+		 DisplayName, Description, CreateTimeMillis, UpdateTimeMillis, Deleted, DeleteTimeMillis
+		 FROM Trees`
+const selectTreeByIDCql string = selectTreeColumnsCql + " WHERE TreeId=?"
+const insertTreeCql string = `INSERT INTO Trees(TreeId, KeyId, TreeType, TreeState, LeafHasherType, TreeHasherType,
+		 AllowsDuplicateLeaves, DisplayName, Description, CreateTimeMillis, UpdateTimeMillis, Deleted, DeleteTimeMillis,
+		 ReadOnlyRequests)
+		 VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false, 0, false) IF NOT EXISTS`
+
+// Trees are updated if they haven't been changed since they were read, there's no row
+// locking to stop concurrent updates
+const updateTreeCql string = `UPDATE Trees SET TreeState=?, DisplayName=?, Description=?, UpdateTimeMillis=?,
+		 Deleted=?, DeleteTimeMillis=?
+		 WHERE TreeId=? IF UpdateTimeMillis=?`
+const selectTreeUsageCql string = "SELECT Requests, BytesIn, BytesOut, LeavesQueued FROM TreeUsage WHERE TreeId=?"
+const selectTreeUsageTimeCql string = "SELECT UpdateTimeMillis FROM TreeUsageTime WHERE TreeId=?"
+const addTreeUsageCql string = `UPDATE TreeUsage SET Requests=Requests+?, BytesIn=BytesIn+?, BytesOut=BytesOut+?,
+		 LeavesQueued=LeavesQueued+? WHERE TreeId=?`
+const insertTreeUsageTimeCql string = "INSERT INTO TreeUsageTime(TreeId, UpdateTimeMillis) VALUES(?, ?)"
+
+// The tables whose partitions are keyed by the tree alone, which are removed whole when the
+// tree is deleted
+var deleteTreePartitionCqls = []string{
+	"DELETE FROM TreeUsage WHERE TreeId=?",
+	"DELETE FROM TreeUsageTime WHERE TreeId=?",
+	"DELETE FROM TreeRevisionClaim WHERE TreeId=?",
+	"DELETE FROM TreeHead WHERE TreeId=?",
+	"DELETE FROM TreeHeadTimestamp WHERE TreeId=?",
+	"DELETE FROM TreeHeadSize WHERE TreeId=?",
+	"DELETE FROM MapHead WHERE TreeId=?",
+	"DELETE FROM MapMutation WHERE TreeId=?",
+}
+
+// The tables whose partitions are keyed by the tree and another column, by that column and
+// a function returning somewhere to scan it into. A tree's partitions in them can only be
+// found by reading the keys of all the partitions.
+var treePartitionedTables = []struct {
+	table, column string
+	newKey        func() interface{}
+}{
+	{"Subtree", "SubtreeId", newBlobKey},
+	{"Cosignature", "TreeHeadTimestamp", newBigintKey},
+	{"LeafData", "LeafHash", newBlobKey},
+	{"SequencedLeafData", "Bucket", newBigintKey},
+	{"SequencedLeafHash", "LeafHash", newBlobKey},
+	{"SequencedMessage", "MessageId", newBlobKey},
+	{"Unsequenced", "Bucket", newIntKey},
+	{"UnsequencedLeafHash", "LeafHash", newBlobKey},
+	{"PreorderedLeaf", "Bucket", newBigintKey},
+	{"MapLeaf", "KeyHash", newBlobKey},
+}
+
+func newBlobKey() interface{}   { return new([]byte) }
+func newBigintKey() interface{} { return new(int64) }
+func newIntKey() interface{}    { return new(int) }
+
+const deleteTreeCql string = "DELETE FROM Trees WHERE TreeId=?"
+
+// errTreeChanged is returned by Commit if a tree updated through the transaction was changed
+// by another one since it was read
+var errTreeChanged = errors.New("cassandra: tree was updated concurrently")
+
+type cassandraAdminStorage struct {
+	session *gocql.Session
+}
+
+func (c *cassandraAdminStorage) beginInternal(ctx context.Context) *adminTX {
+	return &adminTX{session: c.session, ctx: ctx}
+}
+
+func (c *cassandraAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return c.beginInternal(ctx), nil
+}
+
+func (c *cassandraAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return c.beginInternal(ctx), nil
+}
+
+// adminTX keeps its writes until it's committed, like the tree transactions. Hard deletes
+// aren't undone if committing fails part way through, but they can be retried.
+type adminTX struct {
+	session *gocql.Session
+	// ctx is what the writes are applied with and problems are logged against
+	ctx context.Context
+
+	conditions []conditionalWrite
+	writes     []statement
+	// deletes are applied after the other writes, with the Trees rows last
+	deletes    []statement
+	treeDelete []statement
+}
+
+func (t *adminTX) Commit() error {
+	err := runConcurrently(len(t.conditions), func(i int) error {
+		return applyCondition(t.ctx, t.session, t.conditions[i])
+	})
+
+	if err == nil {
+		err = runStatements(t.ctx, t.session, t.writes)
+	}
+	if err == nil {
+		err = runStatements(t.ctx, t.session, t.deletes)
+	}
+	if err == nil {
+		err = runStatements(t.ctx, t.session, t.treeDelete)
+	}
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Admin TX commit error: %s", err)
+	}
+
+	return err
+}
+
+// Rollback discards the transaction's writes, none of which have been applied
+func (t *adminTX) Rollback() error {
+	t.conditions, t.writes, t.deletes, t.treeDelete = nil, nil, nil, nil
+	return nil
+}
+
+func (t *adminTX) readTree(scan func(dest ...interface{}) bool) (*trillian.Tree, bool, error) {
+	var tree trillian.Tree
+	var treeType, treeState, hashAlgorithm string
+
+	if !scan(&tree.TreeId, &tree.KeyId, &treeType, &treeState, &hashAlgorithm, &tree.AllowDuplicateLeaves,
+		&tree.DisplayName, &tree.Description, &tree.CreateTimeMillis, &tree.UpdateTimeMillis, &tree.Deleted,
+		&tree.DeleteTimeMillis) {
+		return nil, false, nil
+	}
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		tree.TreeType = trillian.TreeType(v)
+	} else {
+		return nil, false, fmt.Errorf("unknown tree type %s for tree %d", treeType, tree.TreeId)
+	}
+
+	if v, ok := trillian.TreeState_value[treeState]; ok {
+		tree.TreeState = trillian.TreeState(v)
+	} else {
+		return nil, false, fmt.Errorf("unknown tree state %s for tree %d", treeState, tree.TreeId)
+	}
+
+	if v, ok := trillian.HashAlgorithm_value[hashAlgorithm]; ok {
+		tree.HashAlgorithm = trillian.HashAlgorithm(v)
+	} else {
+		return nil, false, fmt.Errorf("unknown hash algorithm %s for tree %d", hashAlgorithm, tree.TreeId)
+	}
+
+	return &tree, true, nil
+}
+
+func (t *adminTX) GetTree(treeID int64) (*trillian.Tree, error) {
+	iter := t.session.Query(selectTreeByIDCql, treeID).Iter()
+	tree, found, err := t.readTree(iter.Scan)
+
+	if closeErr := iter.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to read tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	if !found {
+		return nil, storage.ErrTreeNotFound
+	}
+
+	return tree, nil
+}
+
+type byTreeID []*trillian.Tree
+
+func (b byTreeID) Len() int           { return len(b) }
+func (b byTreeID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byTreeID) Less(i, j int) bool { return b[i].TreeId < b[j].TreeId }
+
+func (t *adminTX) ListTrees(includeDeleted bool) ([]*trillian.Tree, error) {
+	iter := t.session.Query(selectTreeColumnsCql).Iter()
+
+	trees := make([]*trillian.Tree, 0)
+
+	for {
+		tree, found, err := t.readTree(iter.Scan)
+		if err != nil {
+			logging.Warningf(t.ctx, "Failed to read tree: %s", err)
+			iter.Close()
+			return nil, err
+		}
+
+		if !found {
+			break
+		}
+
+		if includeDeleted || !tree.Deleted {
+			trees = append(trees, tree)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(t.ctx, "Failed to list trees: %s", err)
+		return nil, err
+	}
+
+	// Rows are in token order, the other storage lists trees by ID
+	sort.Sort(byTreeID(trees))
+
+	return trees, nil
+}
+
+func (t *adminTX) GetTreeUsage(treeID int64) (*trillian.TreeUsage, error) {
+	usage := &trillian.TreeUsage{TreeId: treeID}
+	err := t.session.Query(selectTreeUsageCql, treeID).Scan(&usage.Requests, &usage.BytesIn, &usage.BytesOut,
+		&usage.LeavesQueued)
+
+	if err == gocql.ErrNotFound {
+		return usage, nil
+	} else if err != nil {
+		logging.Warningf(t.ctx, "Failed to read usage of tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	err = t.session.Query(selectTreeUsageTimeCql, treeID).Scan(&usage.UpdateTimeMillis)
+
+	if err != nil && err != gocql.ErrNotFound {
+		logging.Warningf(t.ctx, "Failed to read usage time of tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (t *adminTX) CreateTree(tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.TreeState = trillian.TreeState_ACTIVE
+	newTree.CreateTimeMillis = nowMillis()
+	newTree.UpdateTimeMillis = newTree.CreateTimeMillis
+
+	// Both hashers are currently derived from the same algorithm
+	hashAlgorithm := newTree.HashAlgorithm.String()
+
+	t.conditions = append(t.conditions, conditionalWrite{
+		statement{insertTreeCql, []interface{}{newTree.TreeId, newTree.KeyId, newTree.TreeType.String(), newTree.TreeState.String(),
+			hashAlgorithm, hashAlgorithm, newTree.AllowDuplicateLeaves, newTree.DisplayName, newTree.Description,
+			newTree.CreateTimeMillis, newTree.UpdateTimeMillis}},
+		fmt.Errorf("cassandra: tree %d already exists", newTree.TreeId)})
+
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		tree := *orig
+		updateFunc(&tree)
+		if err := storage.ValidateTreeForUpdate(orig, &tree); err != nil {
+			return nil, err
+		}
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) SoftDeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if orig.Deleted {
+			return nil, storage.ErrTreeDeleted
+		}
+		tree := *orig
+		tree.Deleted = true
+		tree.DeleteTimeMillis = nowMillis()
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) UndeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if !orig.Deleted {
+			return nil, storage.ErrTreeNotDeleted
+		}
+		tree := *orig
+		tree.Deleted = false
+		tree.DeleteTimeMillis = 0
+		return &tree, nil
+	})
+}
+
+// updateTree stores the mutable settings of the tree returned by f when the transaction is
+// committed, as long as the tree hasn't been updated since it was read.
+func (t *adminTX) updateTree(treeID int64, f func(orig *trillian.Tree) (*trillian.Tree, error)) (*trillian.Tree, error) {
+	orig, err := t.GetTree(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := f(orig)
+	if err != nil {
+		return nil, err
+	}
+	tree.UpdateTimeMillis = nowMillis()
+
+	t.conditions = append(t.conditions, conditionalWrite{
+		statement{updateTreeCql, []interface{}{tree.TreeState.String(), tree.DisplayName, tree.Description,
+			tree.UpdateTimeMillis, tree.Deleted, tree.DeleteTimeMillis, tree.TreeId, orig.UpdateTimeMillis}},
+		errTreeChanged})
+
+	return tree, nil
+}
+
+func (t *adminTX) AddTreeUsage(usage *trillian.TreeUsage) error {
+	t.writes = append(t.writes,
+		statement{addTreeUsageCql, []interface{}{usage.Requests, usage.BytesIn, usage.BytesOut, usage.LeavesQueued, usage.TreeId}},
+		statement{insertTreeUsageTimeCql, []interface{}{usage.TreeId, nowMillis()}})
+
+	return nil
+}
+
+// HardDeleteTree removes all of the tree's rows when the transaction is committed. Finding
+// the partitions that belong to the tree reads the keys of every partition in the keyspace
+// so it's slow, but it's only done to clean up trees that have already been deleted.
+func (t *adminTX) HardDeleteTree(treeID int64) error {
+	tree, err := t.GetTree(treeID)
+	if err != nil {
+		return err
+	}
+
+	if !tree.Deleted {
+		return storage.ErrTreeNotDeleted
+	}
+
+	for _, cql := range deleteTreePartitionCqls {
+		t.deletes = append(t.deletes, statement{cql, []interface{}{treeID}})
+	}
+
+	for _, p := range treePartitionedTables {
+		iter := t.session.Query(fmt.Sprintf("SELECT DISTINCT TreeId, %s FROM %s", p.column, p.table)).Iter()
+
+		var id int64
+		key := p.newKey()
+		for iter.Scan(&id, key) {
+			if id == treeID {
+				// The driver binds the value key points to
+				t.deletes = append(t.deletes, statement{fmt.Sprintf("DELETE FROM %s WHERE TreeId=? AND %s=?", p.table, p.column),
+					[]interface{}{treeID, key}})
+				key = p.newKey()
+			}
+		}
+
+		if err := iter.Close(); err != nil {
+			logging.Warningf(t.ctx, "Failed to find %s data for tree %d: %s", p.table, treeID, err)
+			return err
+		}
+	}
+
+	t.treeDelete = append(t.treeDelete, statement{deleteTreeCql, []interface{}{treeID}})
+
+	return nil
+}
@@ -0,0 +1,1595 @@
+package cassandra
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+const selectTreePropertiesCql string = "SELECT AllowsDuplicateLeaves, TreeType, ReadOnlyRequests FROM Trees WHERE TreeId=?"
+const selectTreesCql string = "SELECT TreeId, KeyId, TreeType, TreeState, Deleted FROM Trees"
+
+const insertLeafDataCql string = "INSERT INTO LeafData(TreeId, LeafHash, TheData, LeafIdentityHash) VALUES(?, ?, ?, ?)"
+const selectLeafDataCql string = "SELECT TheData, LeafIdentityHash FROM LeafData WHERE TreeId=? AND LeafHash=?"
+const insertLeafIdentityHashCql string = "INSERT INTO LeafIdentityHash(TreeId, LeafIdentityHash, LeafHash) VALUES(?, ?, ?)"
+const selectLeafIdentityHashCql string = "SELECT LeafHash FROM LeafIdentityHash WHERE TreeId=? AND LeafIdentityHash=?"
+
+const insertUnsequencedCql string = `INSERT INTO Unsequenced(TreeId, Bucket, QueueTimestamp, LeafHash, MessageId, SignedEntryTimestamp)
+		 VALUES(?, ?, ?, ?, ?, ?)`
+const insertUnsequencedLeafHashCql string = `INSERT INTO UnsequencedLeafHash(TreeId, LeafHash, MessageId, SignedEntryTimestamp)
+		 VALUES(?, ?, ?, ?)`
+const selectUnsequencedCql string = `SELECT QueueTimestamp, LeafHash, MessageId, SignedEntryTimestamp FROM Unsequenced
+		 WHERE TreeId=? AND Bucket=? AND QueueTimestamp<=? LIMIT ?`
+const selectUnsequencedPresentCql string = "SELECT LeafHash FROM Unsequenced WHERE TreeId=? AND Bucket=? LIMIT 1"
+const selectUnsequencedCountCql string = "SELECT COUNT(*) FROM Unsequenced WHERE TreeId=? AND Bucket=?"
+const selectUnsequencedLeafHashCql string = "SELECT SignedEntryTimestamp FROM UnsequencedLeafHash WHERE TreeId=? AND LeafHash=? LIMIT 1"
+const deleteUnsequencedCql string = `DELETE FROM Unsequenced
+		 WHERE TreeId=? AND Bucket=? AND QueueTimestamp=? AND LeafHash=? AND MessageId=?`
+const deleteUnsequencedLeafHashCql string = "DELETE FROM UnsequencedLeafHash WHERE TreeId=? AND LeafHash=? AND MessageId=?"
+
+// Lanes are only added, a lane that's been emptied is still read
+const selectQueueLanesCql string = "SELECT Priority FROM QueueLane WHERE TreeId=?"
+const insertQueueLaneCql string = "INSERT INTO QueueLane(TreeId, Priority) VALUES(?, ?)"
+
+const insertPreorderedLeafCql string = `INSERT INTO PreorderedLeaf(TreeId, Bucket, SequenceNumber, LeafHash, SignedEntryTimestamp)
+		 VALUES(?, ?, ?, ?, ?) IF NOT EXISTS`
+const selectPreorderedLeafCql string = `SELECT LeafHash, SignedEntryTimestamp FROM PreorderedLeaf
+		 WHERE TreeId=? AND Bucket=? AND SequenceNumber=?`
+const selectPreorderedLeavesCql string = `SELECT SequenceNumber, LeafHash, SignedEntryTimestamp FROM PreorderedLeaf
+		 WHERE TreeId=? AND Bucket=? AND SequenceNumber>=? LIMIT ?`
+
+const insertSequencedLeafDataCql string = `INSERT INTO SequencedLeafData(TreeId, Bucket, SequenceNumber, LeafHash, SignedEntryTimestamp)
+		 VALUES(?, ?, ?, ?, ?)`
+const insertSequencedLeafHashCql string = "INSERT INTO SequencedLeafHash(TreeId, LeafHash, SequenceNumber) VALUES(?, ?, ?)"
+const insertSequencedMessageCql string = "INSERT INTO SequencedMessage(TreeId, MessageId, SequenceNumber) VALUES(?, ?, ?)"
+const selectSequencedLeafCql string = `SELECT LeafHash, SignedEntryTimestamp FROM SequencedLeafData
+		 WHERE TreeId=? AND Bucket=? AND SequenceNumber=?`
+const selectSequencedLeafRangeCql string = `SELECT SequenceNumber, LeafHash, SignedEntryTimestamp FROM SequencedLeafData
+		 WHERE TreeId=? AND Bucket=? AND SequenceNumber>=? AND SequenceNumber<?`
+const selectSequencedLeafHashCql string = "SELECT SequenceNumber FROM SequencedLeafHash WHERE TreeId=? AND LeafHash=?"
+const selectSequencedMessageCql string = "SELECT SequenceNumber FROM SequencedMessage WHERE TreeId=? AND MessageId=?"
+
+const insertTreeHeadCql string = `INSERT INTO TreeHead(TreeId, TreeRevision, TreeHeadTimestamp, TreeSize, RootHash, RootSignature, Metadata)
+		 VALUES(?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS`
+const insertTreeHeadTimestampCql string = "INSERT INTO TreeHeadTimestamp(TreeId, TreeHeadTimestamp, TreeRevision) VALUES(?, ?, ?)"
+const insertTreeHeadSizeCql string = "INSERT INTO TreeHeadSize(TreeId, TreeSize, TreeRevision) VALUES(?, ?, ?)"
+const selectLatestSignedLogRootCql string = `SELECT TreeHeadTimestamp, TreeSize, RootHash, TreeRevision, RootSignature, Metadata
+		 FROM TreeHead WHERE TreeId=? LIMIT 1`
+const selectSignedLogRootCql string = `SELECT TreeHeadTimestamp, TreeSize, RootHash, TreeRevision, RootSignature, Metadata
+		 FROM TreeHead WHERE TreeId=? AND TreeRevision=?`
+const selectTreeHeadRevisionCql string = "SELECT TreeRevision FROM TreeHead WHERE TreeId=? AND TreeRevision=?"
+const selectTreeHeadTimestampCql string = "SELECT TreeRevision FROM TreeHeadTimestamp WHERE TreeId=? AND TreeHeadTimestamp=?"
+
+// There's one checkpoint for each log, a new one replaces the old
+const selectSequencerCheckpointCql string = "SELECT TreeRevision, CompactTree FROM SequencerCheckpoint WHERE TreeId=?"
+const insertSequencerCheckpointCql string = "INSERT INTO SequencerCheckpoint(TreeId, TreeRevision, CompactTree) VALUES(?, ?, ?)"
+
+// Cosignatures are clustered by witness ID so they're read in that order. A witness only has
+// one cosignature for each root, a new one replaces the old.
+const selectCosignaturesCql string = "SELECT WitnessId, Signature FROM Cosignature WHERE TreeId=? AND TreeHeadTimestamp=?"
+const insertCosignatureCql string = "INSERT INTO Cosignature(TreeId, TreeHeadTimestamp, WitnessId, Signature) VALUES(?, ?, ?, ?)"
+
+// Claims are taken with lightweight transactions, a claim that's held is only replaced if it
+// hasn't changed since it was read
+const selectQueueClaimsCql string = "SELECT ClaimKey, Owner, ExpiryNanos FROM QueueClaim WHERE TreeId=?"
+const insertQueueClaimCql string = "INSERT INTO QueueClaim(TreeId, ClaimKey, Owner, ExpiryNanos) VALUES(?, ?, ?, ?) IF NOT EXISTS"
+const updateQueueClaimCql string = `UPDATE QueueClaim SET Owner=?, ExpiryNanos=? WHERE TreeId=? AND ClaimKey=?
+		 IF Owner=? AND ExpiryNanos=?`
+const deleteQueueClaimCql string = "DELETE FROM QueueClaim WHERE TreeId=? AND ClaimKey=?"
+
+// leavesPerBucket is how many consecutive sequence numbers share a partition of
+// SequencedLeafData and PreorderedLeaf. Changing it makes the stored leaves unreadable.
+const leavesPerBucket = 4096
+
+// queueBuckets is how many partitions each log's queue is spread over. Changing it makes
+// queued leaves in the buckets that are no longer used unreadable.
+const queueBuckets = 16
+
+// errLogRevisionConflict is returned when committing a transaction that writes a log revision
+// which another transaction has already written, or is writing.
+var errLogRevisionConflict = errors.New("cassandra: log revision has already been written")
+
+// errClaimConflict is returned when committing a transaction that claims leaves which another
+// transaction has claimed since they were read
+var errClaimConflict = errors.New("cassandra: leaves have been claimed by another owner")
+
+// sequenceBucket returns the partition of SequencedLeafData and PreorderedLeaf that holds seq
+func sequenceBucket(seq int64) int64 {
+	return seq / leavesPerBucket
+}
+
+// laneBucket returns partition i of the queueBuckets partitions of Unsequenced that leaves
+// queued with priority are in. The partitions of priority 0 are the ones leaves were queued
+// in before there were priorities.
+func laneBucket(priority int32, i int) int {
+	return int(priority)*queueBuckets + i
+}
+
+// queueBucket returns the partition of Unsequenced that a leaf queued with priority is in
+func queueBucket(priority int32, leafHash trillian.Hash) int {
+	return laneBucket(priority, int(leafHash[0])%queueBuckets)
+}
+
+// sequenceClaimKey returns the key in QueueClaim of the claim on the leaf of a pre-ordered log
+// at seq
+func sequenceClaimKey(seq int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seq))
+	return key
+}
+
+type cassandraLogStorage struct {
+	*cassandraTreeStorage
+
+	logID           trillian.LogID
+	allowDuplicates bool
+	treeType        trillian.TreeType
+	readOnly        bool
+}
+
+func newLogStorage(id trillian.LogID, session *gocql.Session) (storage.LogStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, session, cache.PopulateLogSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+	ts.selectRootAtRevisionCql = selectTreeHeadRevisionCql
+	ts.revisionConflict = errLogRevisionConflict
+
+	s := cassandraLogStorage{
+		cassandraTreeStorage: ts,
+		logID:                id,
+		treeType:             trillian.TreeType_LOG,
+	}
+
+	// TODO: This should not default but it would currently complicate testing and can be
+	// implemented later when the create tree API has been defined.
+	var treeType string
+
+	err = session.Query(selectTreePropertiesCql, id.TreeID).Scan(&s.allowDuplicates, &treeType, &s.readOnly)
+
+	if err == gocql.ErrNotFound {
+		glog.Warningf("*** Opening storage for log: %v but it has no params configured ***", id)
+	} else if err != nil {
+		glog.Warningf("Failed to get trees row for id %v: %s", id, err)
+		return nil, err
+	}
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		s.treeType = trillian.TreeType(v)
+	}
+
+	return &s, nil
+}
+
+// TreeType returns the type the log was created with.
+func (c *cassandraLogStorage) TreeType() trillian.TreeType {
+	return c.treeType
+}
+
+func (c *cassandraLogStorage) beginInternal(ctx context.Context) (*logTX, error) {
+	tx := &logTX{
+		treeTX:   c.beginTreeTx(ctx),
+		ls:       c,
+		dequeued: make(map[string][]queuedEntry),
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.treeTX.writeRevision = root.TreeRevision + 1
+	tx.treeSize = root.TreeSize
+
+	return tx, nil
+}
+
+func (c *cassandraLogStorage) Begin(ctx context.Context) (storage.LogTX, error) {
+	// Reject attempts to start a writable transaction in read only mode. Anything that
+	// doesn't write is a part of Snapshot so is still available via that API.
+	if c.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+
+	tx, err := c.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (c *cassandraLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := c.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// SnapshotForTree starts a read-only transaction pinned to the revision of the first tree
+// with a root that's at least treeSize. Leaves are only read below the size of that tree so
+// later revisions aren't seen.
+func (c *cassandraLogStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	tx := &logTX{
+		treeTX: c.beginTreeTx(ctx),
+		ls:     c,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rev, size, err := tx.getTreeRevisionCoveringSize(ctx, treeSize)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to find revision for tree size %d: %s", treeSize, err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = rev
+	tx.treeSize = size
+
+	return tx, nil
+}
+
+// queuedEntry is where a dequeued leaf is kept in the queue, so that it can be removed
+// once the leaf has been sequenced
+type queuedEntry struct {
+	bucket         int
+	queueTimestamp int64
+	messageID      []byte
+}
+
+type logTX struct {
+	treeTX
+	ls *cassandraLogStorage
+
+	// treeSize is the size of the tree when the transaction started. Leaves at and above it
+	// may have been written by a transaction that hasn't stored its root, so can't be read.
+	treeSize int64
+	// dequeued are the queue entries of the leaves returned by DequeueLeaves, by leaf hash
+	dequeued map[string][]queuedEntry
+}
+
+func (t *logTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *logTX) TreeSize() int64 {
+	return t.treeSize
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	leaves, _, err := t.dequeueLeaves(ctx, limit, limit, nowNanos, func([]byte) bool { return true })
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		t.recordQueuedLeaves(ctx)
+	}
+
+	return leaves, nil
+}
+
+// DequeueOverdueLeaves reads the oldest entries of every lane, as leaves of any priority can
+// be overdue, and takes the oldest of them.
+func (t *logTX) DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error) {
+	// The leaves of a pre-ordered log are integrated in order, so none are overdue
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, nil
+	}
+
+	// Entries are queued at their not-before times, so those read can also be integrated
+	readBefore := nowNanos
+	if queuedBeforeNanos <= nowNanos {
+		readBefore = queuedBeforeNanos - 1
+	}
+
+	lanes, err := t.queueLanes(ctx, t.ls.logID.TreeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read queue lanes: %s", err)
+		return nil, err
+	}
+
+	var queued []dequeuedLeaf
+
+	for _, priority := range lanes {
+		lane, err := t.readQueueBuckets(ctx, priority, limit+t.dequeuedCount(), readBefore)
+		if err != nil {
+			return nil, err
+		}
+
+		queued = append(queued, lane...)
+	}
+
+	sort.Stable(byQueueTimestamp(queued))
+
+	leaves, _, err := t.takeQueuedLeaves(ctx, queued, limit, func([]byte) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	t.recordQueuedLeaves(ctx)
+
+	return leaves, nil
+}
+
+// ClaimLeaves claims queued leaves for claim.Owner, see storage.LeafClaimer. The claims are
+// taken with a lightweight transaction each when the transaction is committed, and the commit
+// fails with errClaimConflict if another owner got any of the leaves first. Those already
+// taken stay claimed until they expire.
+func (t *logTX) ClaimLeaves(ctx context.Context, claim storage.LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error) {
+	claims, err := t.readQueueClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each bucket is read far enough to get past the leaves held by others
+	held := 0
+	for _, c := range claims {
+		if c.Owner != claim.Owner && c.ExpiryNanos >= nowNanos {
+			held++
+		}
+	}
+
+	leaves, keys, err := t.dequeueLeaves(ctx, limit, limit+held, nowNanos, func(key []byte) bool {
+		c, ok := claims[string(key)]
+		return !ok || c.Owner == claim.Owner || c.ExpiryNanos < nowNanos
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if c, ok := claims[string(key)]; ok {
+			t.addCondition(statement{updateQueueClaimCql, []interface{}{claim.Owner, claim.ExpiryNanos, t.ls.logID.TreeID, key,
+				c.Owner, c.ExpiryNanos}}, errClaimConflict)
+		} else {
+			t.addCondition(statement{insertQueueClaimCql, []interface{}{t.ls.logID.TreeID, key, claim.Owner, claim.ExpiryNanos}}, errClaimConflict)
+		}
+	}
+
+	return leaves, nil
+}
+
+// DequeueClaimedLeaves dequeues leaves claimed by owner, see storage.LeafClaimer. Leaves are
+// claimed oldest first, so only those held by others are read past in each bucket. Leaves
+// taken by another owner in the meantime are still only sequenced once, as the transactions
+// write the same revision.
+func (t *logTX) DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error) {
+	claims, err := t.readQueueClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	others := 0
+	for _, c := range claims {
+		if c.Owner != owner {
+			others++
+		}
+	}
+
+	// The leaves could be integrated when they were claimed, so their not-before times have passed
+	leaves, _, err := t.dequeueLeaves(ctx, limit, limit+others, math.MaxInt64, func(key []byte) bool {
+		c, ok := claims[string(key)]
+		return ok && c.Owner == owner
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		t.recordQueuedLeaves(ctx)
+	}
+
+	return leaves, nil
+}
+
+// readQueueClaims returns the claims on the log's queued leaves, by claim key
+func (t *logTX) readQueueClaims(ctx context.Context) (map[string]storage.LeafClaim, error) {
+	claims := make(map[string]storage.LeafClaim)
+	iter := t.ts.session.Query(selectQueueClaimsCql, t.ls.logID.TreeID).WithContext(ctx).Iter()
+
+	var key []byte
+	var claim storage.LeafClaim
+	for iter.Scan(&key, &claim.Owner, &claim.ExpiryNanos) {
+		claims[string(key)] = claim
+		key, claim = nil, storage.LeafClaim{}
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(ctx, "Failed to read queue claims: %s", err)
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// OldestQueuedLeafNanos reads the oldest entries of each lane, enough to get past those the
+// transaction has dequeued, and returns the oldest of the rest
+func (t *logTX) OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return 0, nil
+	}
+
+	lanes, err := t.queueLanes(ctx, t.ls.logID.TreeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read queue lanes: %s", err)
+		return 0, err
+	}
+
+	var oldest int64
+
+	for _, priority := range lanes {
+		queued, err := t.readQueueBuckets(ctx, priority, t.dequeuedCount()+1, nowNanos)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, d := range queued {
+			if t.isDequeued(d.leafHash, d.queuedEntry) {
+				continue
+			}
+			if oldest == 0 || d.queueTimestamp < oldest {
+				oldest = d.queueTimestamp
+			}
+			break
+		}
+	}
+
+	return oldest, nil
+}
+
+// dequeuedCount returns how many queue entries the transaction has dequeued
+func (t *logTX) dequeuedCount() int {
+	n := 0
+	for _, entries := range t.dequeued {
+		n += len(entries)
+	}
+	return n
+}
+
+// isDequeued returns whether the transaction has dequeued the entry of leafHash at e's
+// timestamp with e's message ID
+func (t *logTX) isDequeued(leafHash []byte, e queuedEntry) bool {
+	for _, d := range t.dequeued[string(leafHash)] {
+		if d.queueTimestamp == e.queueTimestamp && bytes.Equal(d.messageID, e.messageID) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordQueuedLeaves updates the queue depth metric with the number of leaves queued,
+// including those just dequeued as they're only removed once they've been sequenced.
+// Failing to count them isn't an error for the caller.
+func (t *logTX) recordQueuedLeaves(ctx context.Context) {
+	lanes, err := t.queueLanes(ctx, t.ls.logID.TreeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read queue lanes: %s", err)
+		return
+	}
+
+	var queued int64
+
+	for _, priority := range lanes {
+		for bucket := 0; bucket < queueBuckets; bucket++ {
+			var count int64
+			if err := t.ts.session.Query(selectUnsequencedCountCql, t.ls.logID.TreeID, laneBucket(priority, bucket)).WithContext(ctx).Scan(&count); err != nil {
+				logging.Warningf(ctx, "Failed to count queued leaves: %s", err)
+				return
+			}
+			queued += count
+		}
+	}
+
+	storage.QueuedLeaves.Set(float64(queued), "cassandra", strconv.FormatInt(t.ls.logID.TreeID, 10))
+}
+
+// dequeuedLeaf is an entry read from the queue
+type dequeuedLeaf struct {
+	queuedEntry
+	leafHash                  []byte
+	signedEntryTimestampBytes []byte
+}
+
+type byQueueTimestamp []dequeuedLeaf
+
+func (b byQueueTimestamp) Len() int           { return len(b) }
+func (b byQueueTimestamp) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byQueueTimestamp) Less(i, j int) bool { return b[i].queueTimestamp < b[j].queueTimestamp }
+
+type bySequenceNumber []trillian.LogLeaf
+
+func (b bySequenceNumber) Len() int           { return len(b) }
+func (b bySequenceNumber) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bySequenceNumber) Less(i, j int) bool { return b[i].SequenceNumber < b[j].SequenceNumber }
+
+// queueLanes returns the priorities leaves have been queued with in a log, highest first.
+// Priority 0 is always included.
+func (t *logTX) queueLanes(ctx context.Context, treeID int64) ([]int32, error) {
+	var lanes []int32
+	hasZero := false
+
+	iter := t.ts.session.Query(selectQueueLanesCql, treeID).WithContext(ctx).Iter()
+
+	var priority int32
+	for iter.Scan(&priority) {
+		if priority <= 0 && !hasZero {
+			lanes = append(lanes, 0)
+			hasZero = true
+		}
+		if priority != 0 {
+			lanes = append(lanes, priority)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	if !hasZero {
+		lanes = append(lanes, 0)
+	}
+
+	return lanes, nil
+}
+
+// readQueue reads up to limit of the oldest entries that can be integrated at nowNanos from
+// each bucket of the queue, a lane at a time from the highest priority, until it's read at
+// least limit entries
+func (t *logTX) readQueue(ctx context.Context, limit int, nowNanos int64) ([]dequeuedLeaf, error) {
+	lanes, err := t.queueLanes(ctx, t.ls.logID.TreeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read queue lanes: %s", err)
+		return nil, err
+	}
+
+	var queued []dequeuedLeaf
+
+	for _, priority := range lanes {
+		if len(queued) >= limit {
+			break
+		}
+
+		lane, err := t.readQueueBuckets(ctx, priority, limit, nowNanos)
+		if err != nil {
+			return nil, err
+		}
+
+		queued = append(queued, lane...)
+	}
+
+	return queued, nil
+}
+
+// readQueueBuckets reads up to limit of the oldest entries queued by nowNanos from each bucket
+// of the queue for priority, oldest first
+func (t *logTX) readQueueBuckets(ctx context.Context, priority int32, limit int, nowNanos int64) ([]dequeuedLeaf, error) {
+	buckets := make([][]dequeuedLeaf, queueBuckets)
+
+	err := runConcurrently(queueBuckets, func(i int) error {
+		bucket := laneBucket(priority, i)
+		iter := t.ts.session.Query(selectUnsequencedCql, t.ls.logID.TreeID, bucket, nowNanos, limit).WithContext(ctx).Iter()
+
+		var d dequeuedLeaf
+		for iter.Scan(&d.queueTimestamp, &d.leafHash, &d.messageID, &d.signedEntryTimestampBytes) {
+			d.bucket = bucket
+			buckets[i] = append(buckets[i], d)
+			d = dequeuedLeaf{}
+		}
+
+		return iter.Close()
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to select rows for work: %s", err)
+		return nil, err
+	}
+
+	var queued []dequeuedLeaf
+	for _, b := range buckets {
+		queued = append(queued, b...)
+	}
+
+	sort.Stable(byQueueTimestamp(queued))
+
+	return queued, nil
+}
+
+// dequeueLeaves returns up to limit of the queued leaves that take accepts and can be
+// integrated at nowNanos, along with the keys of their claims, reading up to readLimit entries
+// from each bucket as well as those the transaction has already dequeued, which are skipped.
+// The leaves of a pre-ordered log have to be integrated in order so they're returned up to the first that
+// take doesn't accept.
+func (t *logTX) dequeueLeaves(ctx context.Context, limit, readLimit int, nowNanos int64, take func(key []byte) bool) ([]trillian.LogLeaf, [][]byte, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return t.dequeueSequencedLeaves(ctx, limit, take)
+	}
+
+	queued, err := t.readQueue(ctx, readLimit+t.dequeuedCount(), nowNanos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t.takeQueuedLeaves(ctx, queued, limit, take)
+}
+
+// takeQueuedLeaves returns up to limit of the leaves of queued that take accepts, in order,
+// along with the keys of their claims. Entries the transaction has already dequeued are
+// skipped, and those left behind by a failed transaction are removed.
+func (t *logTX) takeQueuedLeaves(ctx context.Context, queued []dequeuedLeaf, limit int, take func(key []byte) bool) ([]trillian.LogLeaf, [][]byte, error) {
+	// An entry is left in the queue if the transaction that sequenced it stored its root but
+	// failed before removing it. It's recognised by its message ID being sequenced within
+	// the tree, and removed now.
+	sequenced := make([]bool, len(queued))
+	err := runConcurrently(len(queued), func(i int) error {
+		var seq int64
+		err := t.ts.session.Query(selectSequencedMessageCql, t.ls.logID.TreeID, queued[i].messageID).WithContext(ctx).Scan(&seq)
+
+		switch {
+		case err == gocql.ErrNotFound:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		sequenced[i] = seq < t.treeSize
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to check for sequenced work: %s", err)
+		return nil, nil, err
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	var keys [][]byte
+	// A log that doesn't allow duplicates can still have two queued copies of a leaf if it
+	// was queued concurrently, only the first is sequenced
+	seen := make(map[string]bool)
+	for leafHash := range t.dequeued {
+		seen[leafHash] = true
+	}
+
+	for i, d := range queued {
+		if t.isDequeued(d.leafHash, d.queuedEntry) {
+			continue
+		}
+
+		if sequenced[i] || (!t.ls.allowDuplicates && seen[string(d.leafHash)]) {
+			t.addWrites(t.removeQueueEntry(d.leafHash, d.queuedEntry)...)
+			continue
+		}
+
+		if len(leaves) == limit || !take(d.messageID) {
+			continue
+		}
+
+		if len(d.leafHash) != t.ts.hashSizeBytes {
+			return nil, nil, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(ctx, d.signedEntryTimestampBytes)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The sequencer only needs the hash, the leaf value stays in LeafData
+		leaf := trillian.LogLeaf{
+			Leaf: trillian.Leaf{
+				LeafHash: d.leafHash,
+			},
+			SignedEntryTimestamp: signedEntryTimestamp,
+			SequenceNumber:       0,
+			QueueTimestampNanos:  d.queueTimestamp,
+		}
+		leaves = append(leaves, leaf)
+		keys = append(keys, d.messageID)
+		seen[string(d.leafHash)] = true
+		t.dequeued[string(d.leafHash)] = append(t.dequeued[string(d.leafHash)], d.queuedEntry)
+	}
+
+	return leaves, keys, nil
+}
+
+// removeQueueEntry returns the deletes that remove a leaf, and any claim on it, from the queue
+func (t *logTX) removeQueueEntry(leafHash []byte, e queuedEntry) []statement {
+	return []statement{
+		{deleteUnsequencedCql, []interface{}{t.ls.logID.TreeID, e.bucket, e.queueTimestamp, leafHash, e.messageID}},
+		{deleteUnsequencedLeafHashCql, []interface{}{t.ls.logID.TreeID, leafHash, e.messageID}},
+		{deleteQueueClaimCql, []interface{}{t.ls.logID.TreeID, e.messageID}},
+	}
+}
+
+// dequeueSequencedLeaves returns the leaves added to a pre-ordered log that follow on from the
+// current tree size, stopping at the first missing sequence number or the first that take
+// doesn't accept.
+func (t *logTX) dequeueSequencedLeaves(ctx context.Context, limit int, take func(key []byte) bool) ([]trillian.LogLeaf, [][]byte, error) {
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	var keys [][]byte
+
+	for len(leaves) < limit {
+		next := t.treeSize + int64(len(leaves))
+		iter := t.ts.session.Query(selectPreorderedLeavesCql, t.ls.logID.TreeID, sequenceBucket(next), next, limit-len(leaves)).WithContext(ctx).Iter()
+
+		var leafHash, signedEntryTimestampBytes []byte
+		var sequenceNumber int64
+		found := 0
+		gap := false
+
+		for iter.Scan(&sequenceNumber, &leafHash, &signedEntryTimestampBytes) {
+			found++
+
+			// The tree can only grow up to a gap, the leaves after it wait until it's filled
+			if sequenceNumber != t.treeSize+int64(len(leaves)) {
+				gap = true
+				break
+			}
+
+			// Nor past a leaf that can't be taken
+			key := sequenceClaimKey(sequenceNumber)
+			if !take(key) {
+				gap = true
+				break
+			}
+
+			if len(leafHash) != t.ts.hashSizeBytes {
+				iter.Close()
+				return nil, nil, errors.New("Dequeued a leaf with incorrect hash size")
+			}
+
+			signedEntryTimestamp, err := decodeSignedTimestamp(ctx, signedEntryTimestampBytes)
+
+			if err != nil {
+				iter.Close()
+				return nil, nil, err
+			}
+
+			leaves = append(leaves, trillian.LogLeaf{
+				Leaf: trillian.Leaf{
+					LeafHash: leafHash,
+				},
+				SignedEntryTimestamp: signedEntryTimestamp,
+				SequenceNumber:       sequenceNumber,
+			})
+			keys = append(keys, key)
+			leafHash, signedEntryTimestampBytes = nil, nil
+		}
+
+		if err := iter.Close(); err != nil {
+			logging.Warningf(ctx, "Failed to select rows for work: %s", err)
+			return nil, nil, err
+		}
+
+		// Carry on into the next bucket only if this one was used up without a gap
+		if gap || found == 0 || sequenceBucket(t.treeSize+int64(len(leaves))) == sequenceBucket(next) {
+			break
+		}
+	}
+
+	return leaves, keys, nil
+}
+
+// messageID returns the ID of a queued copy of a leaf. Message ids only need to guard against
+// duplicates for the time that entries are in the unsequenced queue, which should be short,
+// but we'll still use a strong hash.
+func (t *logTX) messageID(ctx context.Context, leafHash trillian.Hash) ([]byte, error) {
+	hasher := sha256.New()
+
+	// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
+	// Copies of the same leaf queued concurrently by transactions that couldn't see each
+	// other have the same ID, and the later ones are dropped when they're dequeued.
+	messageIdBytes := make([]byte, 8)
+
+	if t.ls.allowDuplicates {
+		if _, err := rand.Read(messageIdBytes); err != nil {
+			logging.Warningf(ctx, "Failed to get a random message id: %s", err)
+			return nil, err
+		}
+	}
+
+	hasher.Write(messageIdBytes)
+	hasher.Write(t.ls.logID.LogID)
+	hasher.Write(leafHash)
+	return hasher.Sum(nil), nil
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Queued leaf cannot have an empty signature")
+		}
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	// The leaves of this batch that will be queued, by hash. Nothing is written until the
+	// transaction is committed so repeats within it have to be found here.
+	batchLeaves := make(map[string]*trillian.LogLeaf)
+	// The lanes of the priorities the batch is queued with
+	lanes := make(map[int32]bool)
+	queueTimestamp := time.Now().UnixNano()
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		// If the log doesn't allow duplicates then resubmitting a leaf returns the copy that's
+		// already there.
+		if !t.ls.allowDuplicates {
+			if first, ok := batchLeaves[string(leaf.LeafHash)]; ok {
+				existingLeaves[i] = &trillian.LogLeaf{
+					Leaf:                 trillian.Leaf{LeafHash: first.LeafHash, LeafValue: first.LeafValue, LeafIdentityHash: first.LeafIdentityHash},
+					SignedEntryTimestamp: first.SignedEntryTimestamp,
+					SequenceNumber:       -1,
+				}
+				continue
+			}
+
+			existing, err := t.getExistingLeaf(ctx, leaf.LeafHash)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				existingLeaves[i] = existing
+				continue
+			}
+
+			batchLeaves[string(leaf.LeafHash)] = leaf
+		}
+
+		messageID, err := t.messageID(ctx, leaf.LeafHash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// A lane is recorded before the leaves in it, so the sequencer reads its partitions
+		if leaf.Priority != 0 && !lanes[leaf.Priority] {
+			t.addWrites(statement{insertQueueLaneCql, []interface{}{t.ls.logID.TreeID, leaf.Priority}})
+			lanes[leaf.Priority] = true
+		}
+
+		// Leaves queued in the same batch are kept in order by their timestamps. A leaf that
+		// can't be integrated yet is queued at its not-before time, so it's only read from then.
+		timestamp := queueTimestamp
+		if leaf.NotBeforeNanos > timestamp {
+			timestamp = leaf.NotBeforeNanos
+		}
+		t.addWrites(
+			statement{insertLeafDataCql, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, []byte(leaf.LeafIdentityHash)}},
+			statement{insertUnsequencedCql, []interface{}{t.ls.logID.TreeID, queueBucket(leaf.Priority, leaf.LeafHash), timestamp + int64(i),
+				[]byte(leaf.LeafHash), messageID, signedTimestampBytes}},
+			statement{insertUnsequencedLeafHashCql, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), messageID, signedTimestampBytes}})
+		t.addIdentityHash(leaf.Leaf)
+	}
+
+	return existingLeaves, nil
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrNotPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Sequenced leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SequenceNumber < 0 {
+			return nil, fmt.Errorf("Sequenced leaf must have a sequence number >= 0, got %d", leaf.SequenceNumber)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Sequenced leaf cannot have an empty signature")
+		}
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	batchLeaves := make(map[int64]*trillian.LogLeaf)
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		if first, ok := batchLeaves[leaf.SequenceNumber]; ok {
+			existingLeaves[i] = first
+			continue
+		}
+
+		// A position can only be filled once, whether or not it has been integrated yet
+		existing, err := t.getLeafAtSequenceNumber(ctx, leaf.SequenceNumber)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			existingLeaves[i] = existing
+			continue
+		}
+
+		batchLeaves[leaf.SequenceNumber] = leaf
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// The position is filled with IF NOT EXISTS in case it's being added concurrently.
+		// Positions filled before a conflict is found are kept, just as if they'd been added
+		// by an earlier call.
+		t.addWrites(statement{insertLeafDataCql, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, []byte(leaf.LeafIdentityHash)}})
+		t.addIdentityHash(leaf.Leaf)
+		t.addCondition(statement{insertPreorderedLeafCql, []interface{}{t.ls.logID.TreeID, sequenceBucket(leaf.SequenceNumber),
+			leaf.SequenceNumber, []byte(leaf.LeafHash), signedTimestampBytes}},
+			fmt.Errorf("cassandra: a leaf was added concurrently at sequence number %d", leaf.SequenceNumber))
+	}
+
+	return existingLeaves, nil
+}
+
+// addIdentityHash indexes the leaf hash of leaf by its identity hash, if it has one
+func (t *logTX) addIdentityHash(leaf trillian.Leaf) {
+	if len(leaf.LeafIdentityHash) > 0 {
+		t.addWrites(statement{insertLeafIdentityHashCql, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafIdentityHash), []byte(leaf.LeafHash)}})
+	}
+}
+
+// getLeafAtSequenceNumber returns the leaf that has been added to a pre-ordered log at seq,
+// or nil if there isn't one.
+func (t *logTX) getLeafAtSequenceNumber(ctx context.Context, seq int64) (*trillian.LogLeaf, error) {
+	var leafHash []byte
+	var signedTimestampBytes []byte
+
+	err := t.ts.session.Query(selectPreorderedLeafCql, t.ls.logID.TreeID, sequenceBucket(seq), seq).WithContext(ctx).Scan(&leafHash, &signedTimestampBytes)
+
+	if err == gocql.ErrNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to look up queued leaf: %s", err)
+		return nil, err
+	}
+
+	return t.readLeaf(ctx, leafHash, seq, signedTimestampBytes)
+}
+
+// readLeaf builds a leaf, reading its value from LeafData
+func (t *logTX) readLeaf(ctx context.Context, leafHash []byte, seq int64, signedTimestampBytes []byte) (*trillian.LogLeaf, error) {
+	if got, want := len(leafHash), t.ts.hashSizeBytes; got != want {
+		return nil, fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
+	}
+
+	signedEntryTimestamp, err := decodeSignedTimestamp(ctx, signedTimestampBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var leafValue, identityHash []byte
+	if err := t.ts.session.Query(selectLeafDataCql, t.ls.logID.TreeID, leafHash).WithContext(ctx).Scan(&leafValue, &identityHash); err != nil {
+		logging.Warningf(ctx, "Failed to read data of leaf %d: %s", seq, err)
+		return nil, err
+	}
+
+	return &trillian.LogLeaf{
+		Leaf: trillian.Leaf{
+			LeafHash:         leafHash,
+			LeafValue:        leafValue,
+			LeafIdentityHash: identityHash,
+		},
+		SignedEntryTimestamp: signedEntryTimestamp,
+		SequenceNumber:       seq,
+	}, nil
+}
+
+// getExistingLeaf returns the leaf in the log with leafHash, or nil if there isn't one. If the
+// leaf has been sequenced more than once the earliest copy is returned.
+func (t *logTX) getExistingLeaf(ctx context.Context, leafHash trillian.Hash) (*trillian.LogLeaf, error) {
+	sequenced, err := t.GetLeavesByHash(ctx, []trillian.Hash{leafHash}, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sequenced) > 0 {
+		return &sequenced[0], nil
+	}
+
+	var signedTimestampBytes []byte
+
+	err = t.ts.session.Query(selectUnsequencedLeafHashCql, t.ls.logID.TreeID, []byte(leafHash)).WithContext(ctx).Scan(&signedTimestampBytes)
+
+	if err == gocql.ErrNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to look up queued leaf: %s", err)
+		return nil, err
+	}
+
+	return t.readLeaf(ctx, leafHash, -1, signedTimestampBytes)
+}
+
+// GetSequencedLeafCount returns the size of the tree, as leaves are sequenced without gaps
+func (t *logTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	return t.treeSize, nil
+}
+
+// getSequencedLeaf reads the leaf at seq, or returns nil if there isn't one
+func (t *logTX) getSequencedLeaf(ctx context.Context, seq int64) (*trillian.LogLeaf, error) {
+	if seq < 0 || seq >= t.treeSize {
+		return nil, nil
+	}
+
+	var leafHash, signedTimestampBytes []byte
+	err := t.ts.session.Query(selectSequencedLeafCql, t.ls.logID.TreeID, sequenceBucket(seq), seq).WithContext(ctx).Scan(&leafHash, &signedTimestampBytes)
+
+	if err == gocql.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to get leaf %d: %s", seq, err)
+		return nil, err
+	}
+
+	return t.readLeaf(ctx, leafHash, seq, signedTimestampBytes)
+}
+
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	ret := make([]*trillian.LogLeaf, len(leaves))
+
+	err := runConcurrently(len(leaves), func(i int) error {
+		leaf, err := t.getSequencedLeaf(ctx, leaves[i])
+		ret[i] = leaf
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]trillian.LogLeaf, 0, len(leaves))
+	for _, leaf := range ret {
+		if leaf != nil {
+			found = append(found, *leaf)
+		}
+	}
+
+	if len(found) != len(leaves) {
+		return nil, fmt.Errorf("expected %d leaves, but saw %d", len(leaves), len(found))
+	}
+
+	return found, nil
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	// The tree could include duplicates so we don't know how many results will be returned
+	found := make([][]trillian.LogLeaf, len(leafHashes))
+
+	err := runConcurrently(len(leafHashes), func(i int) error {
+		iter := t.ts.session.Query(selectSequencedLeafHashCql, t.ls.logID.TreeID, []byte(leafHashes[i])).WithContext(ctx).Iter()
+
+		var seqs []int64
+		var seq int64
+		for iter.Scan(&seq) {
+			seqs = append(seqs, seq)
+		}
+
+		if err := iter.Close(); err != nil {
+			logging.Warningf(ctx, "Failed to get leaves by hash: %s", err)
+			return err
+		}
+
+		for _, seq := range seqs {
+			leaf, err := t.getSequencedLeaf(ctx, seq)
+			if err != nil {
+				return err
+			}
+
+			// The hash can have been written at seq by a transaction that failed before
+			// storing its root, and the position then filled by another leaf
+			if leaf != nil && string(leaf.LeafHash) == string(leafHashes[i]) {
+				found[i] = append(found[i], *leaf)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]trillian.LogLeaf, 0)
+	for _, leaves := range found {
+		ret = append(ret, leaves...)
+	}
+
+	if orderBySequence {
+		sort.Stable(bySequenceNumber(ret))
+	}
+
+	return ret, nil
+}
+
+// GetLeavesByIdentityHash reads the leaf hashes indexed under each identity hash, then the
+// sequenced leaves with those hashes. The index isn't removed when LeafData is rewritten by
+// a repeat of a leaf, so leaves are only returned if LeafData still has the identity hash.
+func (t *logTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	found := make([][]trillian.Hash, len(identityHashes))
+
+	err := runConcurrently(len(identityHashes), func(i int) error {
+		iter := t.ts.session.Query(selectLeafIdentityHashCql, t.ls.logID.TreeID, []byte(identityHashes[i])).WithContext(ctx).Iter()
+
+		var leafHash []byte
+		for iter.Scan(&leafHash) {
+			found[i] = append(found[i], leafHash)
+			leafHash = nil
+		}
+
+		if err := iter.Close(); err != nil {
+			logging.Warningf(ctx, "Failed to get leaf hashes by identity hash: %s", err)
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A leaf hash is only looked up once, even if several of the identity hashes have it
+	identities := make(map[string]bool, len(identityHashes))
+	seen := make(map[string]bool)
+	leafHashes := make([]trillian.Hash, 0)
+
+	for i, hashes := range found {
+		identities[string(identityHashes[i])] = true
+
+		for _, hash := range hashes {
+			if !seen[string(hash)] {
+				seen[string(hash)] = true
+				leafHashes = append(leafHashes, hash)
+			}
+		}
+	}
+
+	leaves, err := t.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]trillian.LogLeaf, 0, len(leaves))
+	for _, leaf := range leaves {
+		if identities[string(leaf.LeafIdentityHash)] {
+			ret = append(ret, leaf)
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	if start < 0 || count <= 0 {
+		return nil, fmt.Errorf("invalid leaf range start=%d count=%d", start, count)
+	}
+
+	end := start + count
+	if end > t.treeSize {
+		end = t.treeSize
+	}
+
+	type rangeLeaf struct {
+		seq                  int64
+		leafHash             []byte
+		signedTimestampBytes []byte
+	}
+	var found []rangeLeaf
+
+	for bucket := sequenceBucket(start); start < end && bucket <= sequenceBucket(end-1); bucket++ {
+		iter := t.ts.session.Query(selectSequencedLeafRangeCql, t.ls.logID.TreeID, bucket, start, end).WithContext(ctx).Iter()
+
+		var l rangeLeaf
+		for iter.Scan(&l.seq, &l.leafHash, &l.signedTimestampBytes) {
+			// Sequence numbers are allocated without gaps so anything else means we've lost data
+			if got, want := l.seq, start+int64(len(found)); got != want {
+				iter.Close()
+				return nil, fmt.Errorf("expected leaf with sequence number %d, but got %d", want, got)
+			}
+
+			found = append(found, l)
+			l = rangeLeaf{}
+		}
+
+		if err := iter.Close(); err != nil {
+			logging.Warningf(ctx, "Failed to get leaves by range: %s", err)
+			return nil, err
+		}
+	}
+
+	ret := make([]trillian.LogLeaf, len(found))
+	err := runConcurrently(len(found), func(i int) error {
+		leaf, err := t.readLeaf(ctx, found[i].leafHash, found[i].seq, found[i].signedTimestampBytes)
+		if err != nil {
+			return err
+		}
+
+		ret[i] = *leaf
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	root, err := t.readSignedLogRoot(ctx, selectLatestSignedLogRootCql, t.ls.logID.TreeID)
+
+	// It's possible there are no roots for this tree yet
+	if err == gocql.ErrNotFound {
+		return trillian.SignedLogRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed root: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *logTX) GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error) {
+	var treeRevision int64
+	err := t.ts.session.Query(selectTreeHeadTimestampCql, t.ls.logID.TreeID, timestampNanos).WithContext(ctx).Scan(&treeRevision)
+
+	if err == nil {
+		var root trillian.SignedLogRoot
+		root, err = t.readSignedLogRoot(ctx, selectSignedLogRootCql, t.ls.logID.TreeID, treeRevision)
+		if err == nil {
+			return root, nil
+		}
+	}
+
+	if err == gocql.ErrNotFound {
+		return trillian.SignedLogRoot{}, storage.ErrLogRootNotFound
+	}
+
+	logging.Warningf(ctx, "Failed to read signed root %d: %v", timestampNanos, err)
+	return trillian.SignedLogRoot{}, err
+}
+
+// readSignedLogRoot reads the single signed root selected by query
+func (t *logTX) readSignedLogRoot(ctx context.Context, query string, args ...interface{}) (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, metadata []byte
+	var rootSignature trillian.DigitallySigned
+
+	err := t.ts.session.Query(query, args...).WithContext(ctx).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &metadata)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to unmarshall root signature: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return trillian.SignedLogRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		TreeRevision:   treeRevision,
+		Signature:      &rootSignature,
+		LogId:          t.ls.logID.LogID,
+		TreeSize:       treeSize,
+		Metadata:       metadata,
+	}, nil
+}
+
+func (t *logTX) GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error) {
+	iter := t.ts.session.Query(selectCosignaturesCql, t.ls.logID.TreeID, rootTimestampNanos).WithContext(ctx).Iter()
+
+	cosignatures := []trillian.Cosignature{}
+
+	var witnessID string
+	var signatureBytes []byte
+
+	for iter.Scan(&witnessID, &signatureBytes) {
+		var signature trillian.DigitallySigned
+
+		if err := proto.Unmarshal(signatureBytes, &signature); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal cosignature from %s: %v", witnessID, err)
+			iter.Close()
+			return nil, err
+		}
+
+		cosignatures = append(cosignatures, trillian.Cosignature{WitnessId: witnessID, Signature: &signature})
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(ctx, "Failed to read cosignatures: %v", err)
+		return nil, err
+	}
+
+	return cosignatures, nil
+}
+
+func (t *logTX) AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error {
+	signatureBytes, err := proto.Marshal(cosignature.Signature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal cosignature: %v %v", cosignature.Signature, err)
+		return err
+	}
+
+	t.addWrites(statement{insertCosignatureCql, []interface{}{t.ls.logID.TreeID, rootTimestampNanos, cosignature.WitnessId, signatureBytes}})
+
+	return nil
+}
+
+// StoreSignedLogRoot stores root when the transaction is committed, after everything else
+// written at its revision. Committing fails if there's already a root at the revision.
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	t.setRoot(statement{insertTreeHeadCql, []interface{}{t.ls.logID.TreeID, root.TreeRevision, root.TimestampNanos,
+		root.TreeSize, root.RootHash, signatureBytes, root.Metadata}})
+	t.addAfterRoot(
+		statement{insertTreeHeadTimestampCql, []interface{}{t.ls.logID.TreeID, root.TimestampNanos, root.TreeRevision}},
+		statement{insertTreeHeadSizeCql, []interface{}{t.ls.logID.TreeID, root.TreeSize, root.TreeRevision}})
+
+	return nil
+}
+
+func (t *logTX) GetSequencerCheckpoint(ctx context.Context) (*storage.SequencerCheckpoint, error) {
+	var revision int64
+	var compactTree []byte
+
+	err := t.ts.session.Query(selectSequencerCheckpointCql, t.ls.logID.TreeID).WithContext(ctx).Scan(&revision, &compactTree)
+
+	if err == gocql.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read sequencer checkpoint: %v", err)
+		return nil, err
+	}
+
+	var p storage.CompactRangeProto
+	if err := proto.Unmarshal(compactTree, &p); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal sequencer checkpoint: %v", err)
+		return nil, err
+	}
+
+	return &storage.SequencerCheckpoint{TreeRevision: revision, CompactTree: &p}, nil
+}
+
+// StoreSequencerCheckpoint stores checkpoint once the root has been stored when the
+// transaction is committed, so a checkpoint is never ahead of the latest root. It's not
+// stored at all if the transaction doesn't store a root.
+func (t *logTX) StoreSequencerCheckpoint(ctx context.Context, checkpoint storage.SequencerCheckpoint) error {
+	if checkpoint.CompactTree == nil {
+		return errors.New("sequencer checkpoint has no compact tree state")
+	}
+
+	compactTree, err := proto.Marshal(checkpoint.CompactTree)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal sequencer checkpoint: %v", err)
+		return err
+	}
+
+	t.addAfterRoot(statement{insertSequencerCheckpointCql, []interface{}{t.ls.logID.TreeID, checkpoint.TreeRevision, compactTree}})
+
+	return nil
+}
+
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return err
+		}
+
+		t.addRevisionWrites(
+			statement{insertSequencedLeafDataCql, []interface{}{t.ls.logID.TreeID, sequenceBucket(leaf.SequenceNumber),
+				leaf.SequenceNumber, []byte(leaf.LeafHash), signedTimestampBytes}},
+			statement{insertSequencedLeafHashCql, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.SequenceNumber}})
+
+		// Leaves of a pre-ordered log stay where they were added, only a claim on them is
+		// removed
+		if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+			t.addAfterRoot(statement{deleteQueueClaimCql, []interface{}{t.ls.logID.TreeID, sequenceClaimKey(leaf.SequenceNumber)}})
+			continue
+		}
+
+		// The convention is that if leaf processing succeeds (by committing this tx)
+		// then the unsequenced entries for them are removed
+		entries := t.dequeued[string(leaf.LeafHash)]
+		if len(entries) == 0 {
+			return fmt.Errorf("sequenced leaf %d wasn't dequeued by this transaction", leaf.SequenceNumber)
+		}
+		e := entries[0]
+		t.dequeued[string(leaf.LeafHash)] = entries[1:]
+
+		t.addRevisionWrites(statement{insertSequencedMessageCql, []interface{}{t.ls.logID.TreeID, e.messageID, leaf.SequenceNumber}})
+		t.addAfterRoot(t.removeQueueEntry(leaf.LeafHash, e)...)
+	}
+
+	return nil
+}
+
+// getActiveLogs returns the IDs of the logs that are active, and whether each is pre-ordered.
+// The Trees table is small enough for all of it to be read.
+func (t *logTX) getActiveLogs(ctx context.Context) ([]trillian.LogID, []bool, error) {
+	iter := t.ts.session.Query(selectTreesCql).WithContext(ctx).Iter()
+
+	logIDs := make([]trillian.LogID, 0, 0)
+	var preordered []bool
+
+	var treeID int64
+	var logID []byte
+	var treeType, treeState string
+	var deleted bool
+
+	for iter.Scan(&treeID, &logID, &treeType, &treeState, &deleted) {
+		isLog := treeType == trillian.TreeType_LOG.String() || treeType == trillian.TreeType_PREORDERED_LOG.String()
+
+		if isLog && treeState == trillian.TreeState_ACTIVE.String() && !deleted {
+			logIDs = append(logIDs, trillian.LogID{LogID: logID, TreeID: treeID})
+			preordered = append(preordered, treeType == trillian.TreeType_PREORDERED_LOG.String())
+		}
+		logID = nil
+	}
+
+	if err := iter.Close(); err != nil {
+		return []trillian.LogID{}, nil, err
+	}
+
+	return logIDs, preordered, nil
+}
+
+// GetActiveLogIDs returns a list of the IDs of all configured logs
+func (t *logTX) GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error) {
+	logIDs, _, err := t.getActiveLogs(ctx)
+	return logIDs, err
+}
+
+// GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
+// that have queued unsequenced leaves that need to be integrated
+func (t *logTX) GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error) {
+	logIDs, preordered, err := t.getActiveLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]bool, len(logIDs))
+	err = runConcurrently(len(logIDs), func(i int) error {
+		var err error
+		pending[i], err = t.hasPendingWork(ctx, logIDs[i].TreeID, preordered[i])
+		return err
+	})
+
+	if err != nil {
+		return []trillian.LogID{}, err
+	}
+
+	ret := make([]trillian.LogID, 0, 0)
+	for i, logID := range logIDs {
+		if pending[i] {
+			ret = append(ret, logID)
+		}
+	}
+
+	return ret, nil
+}
+
+// hasPendingWork returns whether a log has leaves that can be integrated. For a pre-ordered
+// log that's a leaf at the position following on from the tree.
+func (t *logTX) hasPendingWork(ctx context.Context, treeID int64, preordered bool) (bool, error) {
+	var leafHash []byte
+
+	if preordered {
+		var treeSize, ignored int64
+		var ignoredBytes []byte
+		err := t.ts.session.Query(selectLatestSignedLogRootCql, treeID).WithContext(ctx).Scan(&ignored, &treeSize, &ignoredBytes, &ignored, &ignoredBytes, &ignoredBytes)
+		if err != nil && err != gocql.ErrNotFound {
+			return false, err
+		}
+
+		err = t.ts.session.Query(selectPreorderedLeafCql, treeID, sequenceBucket(treeSize), treeSize).WithContext(ctx).Scan(&leafHash, &ignoredBytes)
+		if err == gocql.ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	lanes, err := t.queueLanes(ctx, treeID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, priority := range lanes {
+		for bucket := 0; bucket < queueBuckets; bucket++ {
+			err := t.ts.session.Query(selectUnsequencedPresentCql, treeID, laneBucket(priority, bucket)).WithContext(ctx).Scan(&leafHash)
+			if err == nil {
+				return true, nil
+			} else if err != gocql.ErrNotFound {
+				return false, err
+			}
+		}
+	}
+
+	return false, nil
+}
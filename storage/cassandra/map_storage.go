@@ -0,0 +1,408 @@
+package cassandra
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+const insertMapHeadCql string = `INSERT INTO MapHead(TreeId, MapRevision, MapHeadTimestamp, RootHash, RootSignature, MapperData)
+	VALUES(?, ?, ?, ?, ?, ?) IF NOT EXISTS`
+
+const selectLatestSignedMapRootCql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=? LIMIT 1`
+
+const selectSignedMapRootByRevisionCql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=? AND MapRevision=?`
+
+const selectMapHeadRevisionCql string = "SELECT MapRevision FROM MapHead WHERE TreeId=? AND MapRevision=?"
+
+const insertMapLeafCql string = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, TheData) VALUES(?, ?, ?, ?)`
+
+// Revisions are clustered newest first, so the first row is the value at revision
+const selectMapLeafCql string = `SELECT TheData FROM MapLeaf
+	 WHERE TreeId=? AND KeyHash=? AND MapRevision<=? LIMIT 1`
+
+const selectMapLeafHistoryCql string = `SELECT MapRevision, TheData FROM MapLeaf
+	 WHERE TreeId=? AND KeyHash=? AND MapRevision>=? AND MapRevision<=?
+	 ORDER BY MapRevision ASC`
+
+const insertMapKeyCql string = `INSERT INTO MapKey(TreeId, TheKey) VALUES(?, ?)`
+
+const selectMapKeysCql string = `SELECT TheKey FROM MapKey WHERE TreeId=? AND TheKey>=? LIMIT ?`
+
+const selectMapKeysBeforeCql string = `SELECT TheKey FROM MapKey WHERE TreeId=? AND TheKey>=? AND TheKey<? LIMIT ?`
+
+const insertMapMutationCql string = `INSERT INTO MapMutation(TreeId, MapRevision, TheData) VALUES(?, ?, ?)`
+
+const selectMapMutationsCql string = `SELECT MapRevision, TheData FROM MapMutation
+	 WHERE TreeId=? AND MapRevision>=? AND MapRevision<=?
+	 LIMIT ?`
+
+type cassandraMapStorage struct {
+	*cassandraTreeStorage
+
+	mapID trillian.MapID
+}
+
+func (c *cassandraMapStorage) MapID() trillian.MapID {
+	return c.mapID
+}
+
+func newMapStorage(id trillian.MapID, session *gocql.Session) (storage.MapStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, session, cache.PopulateMapSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+	ts.selectRootAtRevisionCql = selectMapHeadRevisionCql
+	ts.revisionConflict = storage.ErrMapRevisionConflict
+
+	s := cassandraMapStorage{
+		cassandraTreeStorage: ts,
+		mapID:                id,
+	}
+
+	return &s, nil
+}
+
+func (c *cassandraMapStorage) beginInternal(ctx context.Context) (*mapTX, error) {
+	tx := &mapTX{
+		treeTX: c.beginTreeTx(ctx),
+		ms:     c,
+	}
+
+	root, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.treeTX.writeRevision = root.MapRevision + 1
+	tx.publishedRevision = root.MapRevision
+
+	return tx, nil
+}
+
+func (c *cassandraMapStorage) Begin(ctx context.Context) (storage.MapTX, error) {
+	tx, err := c.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (c *cassandraMapStorage) Snapshot(ctx context.Context) (storage.ReadOnlyMapTX, error) {
+	tx, err := c.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, err
+}
+
+// SnapshotForTree starts a read-only transaction pinned to a revision of the map, or to the
+// latest one if revision is < 0.
+func (c *cassandraMapStorage) SnapshotForTree(ctx context.Context, revision int64) (storage.ReadOnlyMapTreeTX, error) {
+	tx := &mapTX{
+		treeTX: c.beginTreeTx(ctx),
+		ms:     c,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var root trillian.SignedMapRoot
+	var err error
+	if revision < 0 {
+		root, err = tx.LatestSignedMapRoot(ctx)
+	} else {
+		root, err = tx.GetSignedMapRoot(ctx, revision)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = root.MapRevision
+	tx.publishedRevision = root.MapRevision
+
+	return tx, nil
+}
+
+type mapTX struct {
+	treeTX
+	ms *cassandraMapStorage
+
+	// publishedRevision is the latest revision with a root when the transaction started.
+	// Later revisions may have been partly written by a transaction that hasn't stored its
+	// root, so can't be read.
+	publishedRevision int64
+}
+
+func (t *mapTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+// Set stores value when the transaction is committed. Committing fails with
+// ErrMapRevisionConflict if another transaction has written the same revision.
+func (t *mapTX) Set(ctx context.Context, keyHash trillian.Hash, value trillian.MapLeaf) error {
+	flatValue, err := proto.Marshal(&value)
+	if err != nil {
+		return err
+	}
+
+	t.addRevisionWrites(statement{insertMapLeafCql, []interface{}{t.ms.mapID.TreeID, []byte(keyHash), t.writeRevision, flatValue}})
+
+	return nil
+}
+
+func (t *mapTX) Get(ctx context.Context, revision int64, keyHashes []trillian.Hash) ([]trillian.MapLeaf, error) {
+	if len(keyHashes) == 0 {
+		return nil, nil
+	}
+
+	if revision < 0 || revision > t.publishedRevision {
+		revision = t.publishedRevision
+	}
+
+	// Each key is its own partition, so they're read concurrently
+	leaves := make([]*trillian.MapLeaf, len(keyHashes))
+	err := runConcurrently(len(keyHashes), func(i int) error {
+		var flatData []byte
+		err := t.ts.session.Query(selectMapLeafCql, t.ms.mapID.TreeID, []byte(keyHashes[i]), revision).WithContext(ctx).Scan(&flatData)
+
+		if err == gocql.ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(flatData) == 0 {
+			return nil
+		}
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return err
+		}
+		mapLeaf.KeyHash = keyHashes[i]
+		leaves[i] = &mapLeaf
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map leaves: %v", err)
+		return nil, err
+	}
+
+	ret := make([]trillian.MapLeaf, 0, len(keyHashes))
+	for _, leaf := range leaves {
+		if leaf != nil {
+			ret = append(ret, *leaf)
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetHistory(ctx context.Context, keyHash trillian.Hash, startRevision, endRevision int64) ([]storage.MapLeafRevision, error) {
+	if endRevision > t.publishedRevision {
+		endRevision = t.publishedRevision
+	}
+
+	iter := t.ts.session.Query(selectMapLeafHistoryCql, t.ms.mapID.TreeID, []byte(keyHash), startRevision, endRevision).WithContext(ctx).Iter()
+
+	ret := make([]storage.MapLeafRevision, 0)
+	var mapRevision int64
+	var flatData []byte
+	for iter.Scan(&mapRevision, &flatData) {
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			iter.Close()
+			return nil, err
+		}
+		mapLeaf.KeyHash = keyHash
+		ret = append(ret, storage.MapLeafRevision{Revision: mapRevision, Leaf: mapLeaf})
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(ctx, "Failed to read history of map key: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// GetKeys reads the keys in order from the map's partition of the key index. Keys written by
+// transactions that haven't stored their root yet can be returned, but have no value to read.
+func (t *mapTX) GetKeys(ctx context.Context, start, end trillian.Key, count int) ([]trillian.Key, error) {
+	query := t.ts.session.Query(selectMapKeysCql, t.ms.mapID.TreeID, []byte(start), count)
+	if end != nil {
+		query = t.ts.session.Query(selectMapKeysBeforeCql, t.ms.mapID.TreeID, []byte(start), []byte(end), count)
+	}
+	iter := query.WithContext(ctx).Iter()
+
+	ret := make([]trillian.Key, 0)
+	var key []byte
+	for iter.Scan(&key) {
+		ret = append(ret, key)
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(ctx, "Failed to read map keys: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetMutations(ctx context.Context, startRevision int64, count int) ([]trillian.MapMutation, error) {
+	iter := t.ts.session.Query(selectMapMutationsCql, t.ms.mapID.TreeID, startRevision, t.publishedRevision, count).WithContext(ctx).Iter()
+
+	ret := make([]trillian.MapMutation, 0)
+	var mapRevision int64
+	var flatData []byte
+	for iter.Scan(&mapRevision, &flatData) {
+		var mutation trillian.MapMutation
+		if err := proto.Unmarshal(flatData, &mutation); err != nil {
+			iter.Close()
+			return nil, err
+		}
+		mutation.MapRevision = mapRevision
+		ret = append(ret, mutation)
+	}
+
+	if err := iter.Close(); err != nil {
+		logging.Warningf(ctx, "Failed to read map mutations: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) LatestSignedMapRoot(ctx context.Context) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, t.ts.session.Query(selectLatestSignedMapRootCql, t.ms.mapID.TreeID).WithContext(ctx))
+
+	// It's possible there are no roots for this tree yet
+	if err == gocql.ErrNotFound {
+		return trillian.SignedMapRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed map root: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, t.ts.session.Query(selectSignedMapRootByRevisionCql, t.ms.mapID.TreeID, revision).WithContext(ctx))
+
+	if err == gocql.ErrNotFound {
+		return trillian.SignedMapRoot{}, storage.ErrMapRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed map root at revision %d: %v", revision, err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedMapRoot reads a MapHead row. It returns gocql.ErrNotFound if there is no row.
+func (t *mapTX) readSignedMapRoot(ctx context.Context, query *gocql.Query) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	if err := query.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes); err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal root signature: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if len(mapperMetaBytes) != 0 {
+		mapperMeta = &trillian.MapperMetadata{}
+		if err := proto.Unmarshal(mapperMetaBytes, mapperMeta); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal Metadata; %v", err)
+			return trillian.SignedMapRoot{}, err
+		}
+	}
+
+	ret := trillian.SignedMapRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		MapRevision:    mapRevision,
+		Signature:      &rootSignature,
+		MapId:          t.ms.mapID.MapID,
+		Metadata:       mapperMeta,
+	}
+
+	return ret, nil
+}
+
+// StoreSignedMapRoot stores root when the transaction is committed, after everything else
+// written at its revision. Committing fails with ErrMapRevisionConflict if another
+// transaction has stored a root for the same revision.
+func (t *mapTX) StoreSignedMapRoot(ctx context.Context, root trillian.SignedMapRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	var mapperMetaBytes []byte
+
+	if root.Metadata != nil {
+		mapperMetaBytes, err = proto.Marshal(root.Metadata)
+		if err != nil {
+			logging.Warningf(ctx, "Failed to marshal MetaData: %v %v", root.Metadata, err)
+			return err
+		}
+	}
+
+	t.setRoot(statement{insertMapHeadCql, []interface{}{t.ms.mapID.TreeID, root.MapRevision, root.TimestampNanos, root.RootHash,
+		signatureBytes, mapperMetaBytes}})
+
+	return nil
+}
+
+// StoreKey adds key to the key index when the transaction is committed. Writing a key that's
+// already there leaves it as it was.
+func (t *mapTX) StoreKey(ctx context.Context, key trillian.Key) error {
+	t.addRevisionWrites(statement{insertMapKeyCql, []interface{}{t.ms.mapID.TreeID, []byte(key)}})
+
+	return nil
+}
+
+func (t *mapTX) StoreMutation(ctx context.Context, mutation trillian.MapMutation) error {
+	flatData, err := proto.Marshal(&mutation)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal map mutation: %v", err)
+		return err
+	}
+
+	t.addRevisionWrites(statement{insertMapMutationCql, []interface{}{t.ms.mapID.TreeID, mutation.MapRevision, flatData}})
+
+	return nil
+}
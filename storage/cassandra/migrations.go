@@ -0,0 +1,335 @@
+package cassandra
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/trillian/storage/migrate"
+	"golang.org/x/net/context"
+)
+
+// migrations upgrade the Cassandra schema from one version to the next. schema.cql creates
+// the schema at the latest version, so a migration added here must also change it and the
+// version it records. Migrations are never changed once released, as keyspaces may already
+// have them applied.
+var migrations = []migrate.Migration{
+	{
+		Version:     1,
+		Description: "Initial schema",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT,
+  KeyId                 BLOB,
+  TreeType              TEXT,
+  LeafHasherType        TEXT,
+  TreeHasherType        TEXT,
+  AllowsDuplicateLeaves BOOLEAN,
+  TreeState             TEXT,
+  DisplayName           TEXT,
+  Description           TEXT,
+  CreateTimeMillis      BIGINT,
+  UpdateTimeMillis      BIGINT,
+  Deleted               BOOLEAN,
+  DeleteTimeMillis      BIGINT,
+  ReadOnlyRequests      BOOLEAN,
+  PRIMARY KEY(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId                  BIGINT,
+  Requests                COUNTER,
+  BytesIn                 COUNTER,
+  BytesOut                COUNTER,
+  LeavesQueued            COUNTER,
+  PRIMARY KEY(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeUsageTime(
+  TreeId                  BIGINT,
+  UpdateTimeMillis        BIGINT,
+  PRIMARY KEY(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT,
+  SubtreeId            BLOB,
+  SubtreeRevision      BIGINT,
+  Nodes                BLOB,
+  PRIMARY KEY((TreeId, SubtreeId), SubtreeRevision)
+) WITH CLUSTERING ORDER BY (SubtreeRevision DESC)`,
+			`CREATE TABLE IF NOT EXISTS TreeRevisionClaim(
+  TreeId               BIGINT,
+  TreeRevision         BIGINT,
+  TxId                 UUID,
+  PRIMARY KEY(TreeId, TreeRevision)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT,
+  TreeRevision         BIGINT,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BLOB,
+  RootSignature        BLOB,
+  PRIMARY KEY(TreeId, TreeRevision)
+) WITH CLUSTERING ORDER BY (TreeRevision DESC)`,
+			`CREATE TABLE IF NOT EXISTS TreeHeadTimestamp(
+  TreeId               BIGINT,
+  TreeHeadTimestamp    BIGINT,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeHeadSize(
+  TreeId               BIGINT,
+  TreeSize             BIGINT,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeSize, TreeRevision)
+) WITH CLUSTERING ORDER BY (TreeSize ASC, TreeRevision DESC)`,
+			`CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               BIGINT,
+  TreeHeadTimestamp    BIGINT,
+  WitnessId            TEXT,
+  Signature            BLOB,
+  PRIMARY KEY((TreeId, TreeHeadTimestamp), WitnessId)
+)`,
+			`CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT,
+  LeafHash             BLOB,
+  TheData              BLOB,
+  PRIMARY KEY((TreeId, LeafHash))
+)`,
+			`CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT,
+  Bucket               BIGINT,
+  SequenceNumber       BIGINT,
+  LeafHash             BLOB,
+  SignedEntryTimestamp BLOB,
+  PRIMARY KEY((TreeId, Bucket), SequenceNumber)
+)`,
+			`CREATE TABLE IF NOT EXISTS SequencedLeafHash(
+  TreeId               BIGINT,
+  LeafHash             BLOB,
+  SequenceNumber       BIGINT,
+  PRIMARY KEY((TreeId, LeafHash), SequenceNumber)
+)`,
+			`CREATE TABLE IF NOT EXISTS SequencedMessage(
+  TreeId               BIGINT,
+  MessageId            BLOB,
+  SequenceNumber       BIGINT,
+  PRIMARY KEY((TreeId, MessageId))
+)`,
+			`CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT,
+  Bucket               INT,
+  QueueTimestamp       BIGINT,
+  LeafHash             BLOB,
+  MessageId            BLOB,
+  SignedEntryTimestamp BLOB,
+  PRIMARY KEY((TreeId, Bucket), QueueTimestamp, LeafHash, MessageId)
+)`,
+			`CREATE TABLE IF NOT EXISTS UnsequencedLeafHash(
+  TreeId               BIGINT,
+  LeafHash             BLOB,
+  MessageId            BLOB,
+  SignedEntryTimestamp BLOB,
+  PRIMARY KEY((TreeId, LeafHash), MessageId)
+)`,
+			`CREATE TABLE IF NOT EXISTS PreorderedLeaf(
+  TreeId               BIGINT,
+  Bucket               BIGINT,
+  SequenceNumber       BIGINT,
+  LeafHash             BLOB,
+  SignedEntryTimestamp BLOB,
+  PRIMARY KEY((TreeId, Bucket), SequenceNumber)
+)`,
+			`CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                BIGINT,
+  KeyHash               BLOB,
+  MapRevision           BIGINT,
+  TheData               BLOB,
+  PRIMARY KEY((TreeId, KeyHash), MapRevision)
+) WITH CLUSTERING ORDER BY (MapRevision DESC)`,
+			`CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               BIGINT,
+  MapRevision          BIGINT,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BLOB,
+  RootSignature        BLOB,
+  MapperData           BLOB,
+  PRIMARY KEY(TreeId, MapRevision)
+) WITH CLUSTERING ORDER BY (MapRevision DESC)`,
+			`CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               BIGINT,
+  MapRevision          BIGINT,
+  TheData              BLOB,
+  PRIMARY KEY(TreeId, MapRevision)
+)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add tree head metadata",
+		Statements: []string{
+			"ALTER TABLE TreeHead ADD Metadata BLOB",
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add leaf identity hashes",
+		Statements: []string{
+			"ALTER TABLE LeafData ADD LeafIdentityHash BLOB",
+			`CREATE TABLE IF NOT EXISTS LeafIdentityHash(
+  TreeId               BIGINT,
+  LeafIdentityHash     BLOB,
+  LeafHash             BLOB,
+  PRIMARY KEY((TreeId, LeafIdentityHash), LeafHash)
+)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add map key index",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               BIGINT,
+  TheKey               BLOB,
+  PRIMARY KEY(TreeId, TheKey)
+)`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add sequencer checkpoints",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               BIGINT,
+  TreeRevision         BIGINT,
+  CompactTree          BLOB,
+  PRIMARY KEY(TreeId)
+)`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add queue claims",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS QueueClaim(
+  TreeId               BIGINT,
+  ClaimKey             BLOB,
+  Owner                TEXT,
+  ExpiryNanos          BIGINT,
+  PRIMARY KEY(TreeId, ClaimKey)
+)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add queue priorities",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS QueueLane(
+  TreeId               BIGINT,
+  Priority             INT,
+  PRIMARY KEY(TreeId, Priority)
+) WITH CLUSTERING ORDER BY (Priority DESC)`,
+		},
+	},
+}
+
+const createSchemaVersionCql string = `CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INT,
+  Description          TEXT,
+  AppliedTimeMillis    BIGINT,
+  PRIMARY KEY(Version)
+)`
+const selectSchemaVersionsCql string = "SELECT Version FROM SchemaVersion"
+const insertSchemaVersionCql string = "INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(?, ?, ?)"
+
+// The migration lock is a row that only one process can insert. It expires in case the
+// process holding it dies without removing it.
+const createSchemaLockCql string = `CREATE TABLE IF NOT EXISTS SchemaLock(
+  Name                 TEXT,
+  Owner                UUID,
+  PRIMARY KEY(Name)
+)`
+const insertSchemaLockCql string = "INSERT INTO SchemaLock(Name, Owner) VALUES('migrate', ?) IF NOT EXISTS USING TTL ?"
+const deleteSchemaLockCql string = "DELETE FROM SchemaLock WHERE Name='migrate' IF Owner=?"
+
+// schemaLockTTL is how long, in seconds, the migration lock lasts. All the migrations must
+// be applied within it.
+const schemaLockTTL = 600
+
+// schemaLockRetry is how often a process waiting for the migration lock tries to take it
+const schemaLockRetry = time.Second
+
+// cqlDatabase migrates a keyspace, recording the version of its schema in a SchemaVersion
+// table. Schema changes can't be rolled back, so a migration that fails part way must be
+// written so that applying it again finishes it, e.g. with IF NOT EXISTS.
+type cqlDatabase struct {
+	session *gocql.Session
+}
+
+func newCQLDatabase(session *gocql.Session) *cqlDatabase {
+	return &cqlDatabase{session: session}
+}
+
+// Lock takes the migration lock, see migrate.Database
+func (c *cqlDatabase) Lock(ctx context.Context) (func(), error) {
+	if err := c.session.Query(createSchemaLockCql).WithContext(ctx).Exec(); err != nil {
+		return nil, err
+	}
+
+	owner := gocql.TimeUUID()
+
+	for {
+		applied, err := c.session.Query(insertSchemaLockCql, owner, schemaLockTTL).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+
+		if applied {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("migrate: timed out waiting for the migration lock")
+		case <-time.After(schemaLockRetry):
+		}
+	}
+
+	return func() {
+		c.session.Query(deleteSchemaLockCql, owner).MapScanCAS(make(map[string]interface{}))
+	}, nil
+}
+
+// Version returns the latest version recorded in the SchemaVersion table, creating the
+// table if it doesn't exist
+func (c *cqlDatabase) Version(ctx context.Context) (int, error) {
+	if err := c.session.Query(createSchemaVersionCql).WithContext(ctx).Exec(); err != nil {
+		return 0, err
+	}
+
+	iter := c.session.Query(selectSchemaVersionsCql).WithContext(ctx).Iter()
+
+	latest := 0
+	var version int
+	for iter.Scan(&version) {
+		if version > latest {
+			latest = version
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return 0, err
+	}
+
+	return latest, nil
+}
+
+// Apply runs the statements of m and records its version, see migrate.Database
+func (c *cqlDatabase) Apply(ctx context.Context, m migrate.Migration) error {
+	for _, statement := range m.Statements {
+		if err := c.session.Query(statement).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return c.session.Query(insertSchemaVersionCql, m.Version, m.Description, time.Now().UnixNano()/int64(time.Millisecond)).WithContext(ctx).Exec()
+}
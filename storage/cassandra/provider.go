@@ -0,0 +1,180 @@
+// Package cassandra stores trees in a Cassandra cluster, or another store speaking its
+// protocol such as ScyllaDB, for deployments that need to scale writes beyond a single SQL
+// primary. Subtrees and map keys are partitions of their own, with their revisions as
+// clustering columns, so a tree's nodes are spread over the whole cluster.
+//
+// The cluster has no multi-row transactions. Writes made through a transaction are kept
+// until it's committed and then applied, after claiming the revision they're written at so
+// that concurrent writers can't both write it. The root is stored last, making the revision
+// visible to readers, which only read at revisions that have a root.
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+)
+
+// ProviderName is the name the Cassandra storage system is registered under.
+const ProviderName = "cassandra"
+
+func init() {
+	if err := storage.RegisterProvider(ProviderName, newCassandraProvider); err != nil {
+		panic(err)
+	}
+}
+
+// parseDSN parses a description of the cluster and keyspace to use, of the form
+// [user:password@]host[:port][,host[:port]...]/keyspace[?option=value&...]. The options are
+// consistency, the consistency level of reads and writes such as LOCAL_QUORUM (the default
+// is QUORUM), and timeout, the longest each query can take such as 5s.
+func parseDSN(dsn string) (*gocql.ClusterConfig, error) {
+	var options string
+	if i := strings.Index(dsn, "?"); i >= 0 {
+		dsn, options = dsn[:i], dsn[i+1:]
+	}
+
+	var user, password string
+	if i := strings.LastIndex(dsn, "@"); i >= 0 {
+		credentials := dsn[:i]
+		dsn = dsn[i+1:]
+
+		j := strings.Index(credentials, ":")
+		if j < 0 {
+			return nil, fmt.Errorf("cassandra: credentials must be user:password")
+		}
+		user, password = credentials[:j], credentials[j+1:]
+	}
+
+	slash := strings.Index(dsn, "/")
+	if slash < 0 || slash == len(dsn)-1 {
+		return nil, fmt.Errorf("cassandra: no keyspace in %q", dsn)
+	}
+
+	hosts := strings.Split(dsn[:slash], ",")
+	for _, host := range hosts {
+		if len(host) == 0 {
+			return nil, fmt.Errorf("cassandra: empty host in %q", dsn)
+		}
+	}
+
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Keyspace = dsn[slash+1:]
+	cluster.Consistency = gocql.Quorum
+
+	if len(user) > 0 {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: user, Password: password}
+	}
+
+	if len(options) == 0 {
+		return cluster, nil
+	}
+
+	for _, option := range strings.Split(options, "&") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("cassandra: option %q must be name=value", option)
+		}
+
+		switch kv[0] {
+		case "consistency":
+			consistency, err := gocql.ParseConsistencyWrapper(strings.ToUpper(kv[1]))
+			if err != nil {
+				return nil, fmt.Errorf("cassandra: bad consistency %q: %v", kv[1], err)
+			}
+			cluster.Consistency = consistency
+		case "timeout":
+			timeout, err := time.ParseDuration(kv[1])
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("cassandra: bad timeout %q", kv[1])
+			}
+			cluster.Timeout = timeout
+		default:
+			return nil, fmt.Errorf("cassandra: unknown option %q", kv[0])
+		}
+	}
+
+	return cluster, nil
+}
+
+// cassandraProvider creates Cassandra backed storage for trees in a single keyspace. The
+// storage it creates shares one session, which pools connections to the cluster.
+type cassandraProvider struct {
+	cluster *gocql.ClusterConfig
+
+	mu      sync.Mutex
+	session *gocql.Session
+}
+
+func newCassandraProvider(dsn string) (storage.Provider, error) {
+	cluster, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cassandraProvider{cluster: cluster}, nil
+}
+
+// getSession returns the session shared by all the storage, connecting to the cluster the
+// first time
+func (c *cassandraProvider) getSession() (*gocql.Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == nil {
+		session, err := c.cluster.CreateSession()
+		if err != nil {
+			// Don't log the DSN as it could contain credentials
+			glog.Warningf("Could not connect to Cassandra cluster, check config: %s", err)
+			return nil, err
+		}
+
+		c.session = session
+	}
+
+	return c.session, nil
+}
+
+func (c *cassandraProvider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogStorage(id, session)
+}
+
+func (c *cassandraProvider) MapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMapStorage(id, session)
+}
+
+func (c *cassandraProvider) AdminStorage() (storage.AdminStorage, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cassandraAdminStorage{session: session}, nil
+}
+
+// Migrations returns the keyspace shared by the storage and the migrations for its schema
+func (c *cassandraProvider) Migrations() (migrate.Database, []migrate.Migration, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newCQLDatabase(session), migrations, nil
+}
@@ -0,0 +1,1001 @@
+package cassandra
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// These tests need a Cassandra keyspace loaded with schema.cql. They're skipped if the
+// cluster cannot be reached.
+var testDSNFlag = flag.String("cassandra_test_dsn", "127.0.0.1/test", "cluster and keyspace to use for tests")
+
+const leavesToInsert = 5
+
+var signedTimestamp = trillian.SignedEntryTimestamp{
+	TimestampNanos: 1234567890, LogId: []byte("sign"), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+var keyHash = []byte("A Key Hash")
+
+// Tests share the keyspace with earlier runs, whose trees aren't removed, so each test uses
+// a new tree id
+var idMutex sync.Mutex
+var testTreeID = time.Now().UnixNano()
+
+func nextTreeID() int64 {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+	testTreeID++
+
+	return testTreeID
+}
+
+func TestParseDSN(t *testing.T) {
+	for _, test := range []struct {
+		dsn         string
+		hosts       []string
+		keyspace    string
+		consistency gocql.Consistency
+		timeout     time.Duration
+		user        string
+		wantErr     bool
+	}{
+		{dsn: "127.0.0.1/trillian", hosts: []string{"127.0.0.1"}, keyspace: "trillian", consistency: gocql.Quorum},
+		{dsn: "a:9042,b:9042/trillian", hosts: []string{"a:9042", "b:9042"}, keyspace: "trillian", consistency: gocql.Quorum},
+		{dsn: "user:p@ss@a/trillian", hosts: []string{"a"}, keyspace: "trillian", consistency: gocql.Quorum, user: "user"},
+		{dsn: "a/trillian?consistency=local_quorum&timeout=5s", hosts: []string{"a"}, keyspace: "trillian",
+			consistency: gocql.LocalQuorum, timeout: 5 * time.Second},
+		{dsn: "a", wantErr: true},
+		{dsn: "a/", wantErr: true},
+		{dsn: "a,,b/trillian", wantErr: true},
+		{dsn: "user@a/trillian", wantErr: true},
+		{dsn: "a/trillian?consistency=most", wantErr: true},
+		{dsn: "a/trillian?timeout=-1s", wantErr: true},
+		{dsn: "a/trillian?retries=3", wantErr: true},
+		{dsn: "a/trillian?timeout", wantErr: true},
+	} {
+		cluster, err := parseDSN(test.dsn)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseDSN(%q)=%v, want error", test.dsn, cluster)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseDSN(%q)=%v, want no error", test.dsn, err)
+			continue
+		}
+
+		if got, want := fmt.Sprint(cluster.Hosts), fmt.Sprint(test.hosts); got != want {
+			t.Errorf("parseDSN(%q) hosts=%s, want %s", test.dsn, got, want)
+		}
+		if got, want := cluster.Keyspace, test.keyspace; got != want {
+			t.Errorf("parseDSN(%q) keyspace=%s, want %s", test.dsn, got, want)
+		}
+		if got, want := cluster.Consistency, test.consistency; got != want {
+			t.Errorf("parseDSN(%q) consistency=%v, want %v", test.dsn, got, want)
+		}
+		if test.timeout != 0 && cluster.Timeout != test.timeout {
+			t.Errorf("parseDSN(%q) timeout=%v, want %v", test.dsn, cluster.Timeout, test.timeout)
+		}
+
+		auth, _ := cluster.Authenticator.(gocql.PasswordAuthenticator)
+		if got, want := auth.Username, test.user; got != want {
+			t.Errorf("parseDSN(%q) user=%q, want %q", test.dsn, got, want)
+		}
+	}
+}
+
+func TestProviderRegistered(t *testing.T) {
+	if _, err := storage.NewProvider(ProviderName, *testDSNFlag); err != nil {
+		t.Fatalf("Failed to create provider %s: %v", ProviderName, err)
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	nodesToStore := createSomeNodes()
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		tx.(*logTX).treeTX.writeRevision = 100
+
+		// Need to read nodes before attempting to write
+		if _, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
+			t.Fatalf("Failed to store nodes: %s", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		readNodes, err := tx.GetMerkleNodes(ctx, 100, nodeIDsToRead)
+		if err != nil {
+			t.Fatalf("Failed to retrieve nodes: %s", err)
+		}
+
+		if got, want := len(readNodes), len(nodesToStore); got != want {
+			t.Fatalf("Read back %d nodes but expected %d", got, want)
+		}
+
+		for i := range readNodes {
+			if !readNodes[i].NodeID.Equivalent(nodesToStore[i].NodeID) || !bytes.Equal(readNodes[i].Hash, nodesToStore[i].Hash) {
+				t.Fatalf("Read back node %v but expected %v", readNodes[i], nodesToStore[i])
+			}
+		}
+	}
+}
+
+func TestQueueAndDequeueLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 20)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if ids, err := tx.GetActiveLogIDsWithPendingWork(ctx); err != nil || !containsTreeID(ids, logID.TreeID) {
+			t.Fatalf("Expected log %d to have pending work, got: %v %v", logID.TreeID, ids, err)
+		}
+
+		leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if got, want := len(leaves), leavesToInsert; got != want {
+			t.Fatalf("Dequeued %d leaves but expected %d", got, want)
+		}
+
+		for i := range leaves {
+			leaves[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		// The leaves can only be read once there's a root that includes them
+		storeTestRoot(tx, leavesToInsert, t)
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected nothing to dequeue, got: %v %v", leaves, err)
+		}
+
+		count, err := tx.GetSequencedLeafCount(ctx)
+
+		if err != nil || count != leavesToInsert {
+			t.Fatalf("Expected %d sequenced leaves, got: %d %v", leavesToInsert, count, err)
+		}
+
+		leaves, err := tx.GetLeavesByIndex(ctx, []int64{0, 3})
+
+		if err != nil || len(leaves) != 2 {
+			t.Fatalf("Failed to get leaves by index: %v %v", leaves, err)
+		}
+
+		byHash, err := tx.GetLeavesByHash(ctx, []trillian.Hash{leaves[1].LeafHash}, true)
+
+		if err != nil || len(byHash) != 1 || byHash[0].SequenceNumber != leaves[1].SequenceNumber {
+			t.Fatalf("Failed to get leaf by hash: %v %v", byHash, err)
+		}
+
+		byRange, err := tx.GetLeavesByRange(ctx, 1, leavesToInsert)
+
+		if err != nil || int64(len(byRange)) != leavesToInsert-1 {
+			t.Fatalf("Failed to get leaves by range: %v %v", byRange, err)
+		}
+
+		for i, leaf := range byRange {
+			if got, want := leaf.SequenceNumber, int64(i+1); got != want {
+				t.Fatalf("Got leaf with sequence number %d in range but expected %d", got, want)
+			}
+		}
+	}
+}
+
+func TestGetLeavesByIdentityHash(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+	leaves := createTestLeaves(4, 0)
+
+	// Two versions of the same entry, one of another and one without an identity
+	leaves[0].LeafIdentityHash = []byte("identity A")
+	leaves[1].LeafIdentityHash = []byte("identity A")
+	leaves[2].LeafIdentityHash = []byte("identity B")
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+		if err != nil || len(dequeued) != len(leaves) {
+			t.Fatalf("Failed to dequeue leaves: %v %v", dequeued, err)
+		}
+
+		for i := range dequeued {
+			dequeued[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		storeTestRoot(tx, int64(len(leaves)), t)
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	for _, test := range []struct {
+		hashes []trillian.Hash
+		want   int
+	}{
+		{[]trillian.Hash{[]byte("identity A")}, 2},
+		{[]trillian.Hash{[]byte("identity B"), []byte("identity A")}, 3},
+		{[]trillian.Hash{[]byte("identity C")}, 0},
+	} {
+		got, err := tx.GetLeavesByIdentityHash(ctx, test.hashes, true)
+		if err != nil || len(got) != test.want {
+			t.Fatalf("Expected %d leaves with identity hashes %s, got: %v %v", test.want, test.hashes, got, err)
+		}
+
+		for i := 1; i < len(got); i++ {
+			if got[i-1].SequenceNumber >= got[i].SequenceNumber {
+				t.Fatalf("Leaves with identity hashes %s are not in sequence number order: %v", test.hashes, got)
+			}
+		}
+	}
+}
+
+func TestUnpublishedLeavesNotRead(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 0)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		// Sequencing without storing a root doesn't publish the leaves, or remove them from
+		// the queue
+		tx := beginLogTx(s, t)
+
+		leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil || len(leaves) != leavesToInsert {
+			t.Fatalf("Failed to dequeue leaves: %v %v", leaves, err)
+		}
+
+		for i := range leaves {
+			leaves[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.GetLeavesByRange(ctx, 0, leavesToInsert); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected no leaves before a root was stored, got: %v %v", leaves, err)
+		}
+
+		if leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(leaves) != leavesToInsert {
+			t.Fatalf("Expected the leaves to still be queued, got: %v %v", leaves, err)
+		}
+	}
+}
+
+func TestDequeueLeavesByPriority(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(4, 0)
+	for i, priority := range []int32{-1, 0, -1, 1} {
+		leaves[i].Priority = priority
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		// Leaves queued only with a low priority are still pending work
+		if _, err := tx.QueueLeaves(ctx, leaves[:1]); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if ids, err := tx.GetActiveLogIDsWithPendingWork(ctx); err != nil || !containsTreeID(ids, logID.TreeID) {
+			t.Fatalf("Expected log %d to have pending work, got: %v %v", logID.TreeID, ids, err)
+		}
+
+		if _, err := tx.QueueLeaves(ctx, leaves[1:]); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	dequeued, err := tx.DequeueLeaves(ctx, 3, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	// Higher priorities come first, then the oldest leaves of each priority
+	if len(dequeued) != 3 {
+		t.Fatalf("Dequeued %d leaves but expected 3", len(dequeued))
+	}
+	for i, want := range []int{3, 1, 0} {
+		if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+			t.Errorf("Dequeued leaf %d was %v but expected %v", i, dequeued[i], leaves[want])
+		}
+	}
+}
+
+func TestDequeueLeavesNotBefore(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	// Entries are read by the time they're queued at, so the not-before time has to be later
+	notBefore := time.Now().Add(time.Hour).UnixNano()
+	leaves := createTestLeaves(3, 0)
+	leaves[0].NotBeforeNanos = notBefore
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	// The first leaf is held back until its time, and then comes after the others
+	for _, test := range []struct {
+		nowNanos int64
+		want     []int
+	}{
+		{nowNanos: time.Now().UnixNano(), want: []int{1, 2}},
+		{nowNanos: notBefore, want: []int{1, 2, 0}},
+	} {
+		tx := beginLogTx(s, t)
+
+		dequeued, err := tx.DequeueLeaves(ctx, 10, test.nowNanos)
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if len(dequeued) != len(test.want) {
+			t.Fatalf("DequeueLeaves(%d) returned %d leaves but expected %d", test.nowNanos, len(dequeued), len(test.want))
+		}
+		for i, want := range test.want {
+			if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+				t.Errorf("DequeueLeaves(%d) leaf %d was %v but expected %v", test.nowNanos, i, dequeued[i], leaves[want])
+			}
+		}
+
+		tx.Rollback()
+	}
+}
+
+func TestOldestQueuedLeaf(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(3, 0)
+	// The last leaf can't be integrated yet so it isn't counted
+	leaves[2].NotBeforeNanos = time.Now().Add(time.Hour).UnixNano()
+
+	before := time.Now().UnixNano()
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	oldest, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || oldest < before || oldest > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v, want a time from %d to %d", oldest, err, before, now)
+	}
+
+	dequeued, err := tx.DequeueLeaves(ctx, 1, now)
+	if err != nil || len(dequeued) != 1 || dequeued[0].QueueTimestampNanos != oldest {
+		t.Fatalf("DequeueLeaves()=%v %v, want the leaf queued at %d", dequeued, err, oldest)
+	}
+
+	// Leaves the transaction dequeued aren't counted, though they're still queued
+	next, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || next <= oldest || next > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v after dequeuing, want a time after %d up to %d", next, err, oldest, now)
+	}
+
+	// Nor are they dequeued again
+	dequeued, err = tx.DequeueLeaves(ctx, 1, now)
+	if err != nil || len(dequeued) != 1 || dequeued[0].QueueTimestampNanos != next {
+		t.Fatalf("DequeueLeaves()=%v %v, want the leaf queued at %d", dequeued, err, next)
+	}
+
+	if last, err := tx.OldestQueuedLeafNanos(ctx, now); err != nil || last != 0 {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v with only a held back leaf queued, want 0", last, err)
+	}
+}
+
+func TestClaimLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 0)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	claim := func(owner string, expiry, now int64, limit int) int {
+		tx := beginLogTx(s, t)
+		leaves, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: owner, ExpiryNanos: expiry}, now, limit)
+		if err != nil {
+			t.Fatalf("Failed to claim leaves for %s: %v", owner, err)
+		}
+		commit(tx, t)
+		return len(leaves)
+	}
+
+	if got, want := claim("a", 200, 100, 2), 2; got != want {
+		t.Fatalf("a claimed %d leaves but expected %d", got, want)
+	}
+
+	// b can't take a's leaves until they expire
+	if got, want := claim("b", 200, 100, 99), leavesToInsert-2; got != want {
+		t.Fatalf("b claimed %d leaves but expected %d", got, want)
+	}
+
+	if got, want := claim("c", 300, 150, 99), 0; got != want {
+		t.Fatalf("c claimed %d leaves before the claims expired but expected %d", got, want)
+	}
+
+	{
+		// Only the first of two owners taking the same expired leaves gets them
+		tx1, tx2 := beginLogTx(s, t), beginLogTx(s, t)
+
+		for _, c := range []struct {
+			tx    storage.LogTX
+			owner string
+		}{{tx1, "c"}, {tx2, "d"}} {
+			if leaves, err := c.tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: c.owner, ExpiryNanos: 300}, 250, 2); err != nil || len(leaves) != 2 {
+				t.Fatalf("Expected %s to claim 2 leaves, got: %v %v", c.owner, leaves, err)
+			}
+		}
+
+		commit(tx1, t)
+
+		if err := tx2.Commit(); err != errClaimConflict {
+			t.Fatalf("Expected the second claim to fail with %v, got: %v", errClaimConflict, err)
+		}
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.DequeueClaimedLeaves(ctx, "c", 99); err != nil || len(leaves) != 2 {
+			t.Fatalf("Expected to dequeue c's 2 leaves, got: %v %v", leaves, err)
+		}
+
+		if leaves, err := tx.DequeueClaimedLeaves(ctx, "d", 99); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected d to have nothing to dequeue, got: %v %v", leaves, err)
+		}
+	}
+}
+
+func TestQueueDuplicateLeafReturnsExisting(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	tx := beginLogTx(s, t)
+
+	leaves := createTestLeaves(1, 0)
+	existing, err := tx.QueueLeaves(ctx, append(leaves, leaves[0]))
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if existing[0] != nil || existing[1] == nil || !bytes.Equal(existing[1].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("Expected only the repeated leaf to be returned as existing, got: %v", existing)
+	}
+
+	commit(tx, t)
+
+	tx = beginLogTx(s, t)
+	defer tx.Commit()
+
+	existing, err = tx.QueueLeaves(ctx, leaves)
+
+	if err != nil || existing[0] == nil || existing[0].SequenceNumber != -1 {
+		t.Fatalf("Expected the queued leaf to be returned as existing, got: %v %v", existing, err)
+	}
+}
+
+func TestLogRevisionConflict(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	tx1 := beginLogTx(s, t)
+	tx2 := beginLogTx(s, t)
+
+	storeTestRoot(tx1, 0, t)
+	storeTestRoot(tx2, 0, t)
+
+	commit(tx1, t)
+
+	if err := tx2.Commit(); err != errLogRevisionConflict {
+		t.Fatalf("Committing a second root at the same revision returned %v, want %v", err, errLogRevisionConflict)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	// The root was stored at revision 1, the first revision of a new log
+	if got, want := tx.WriteRevision(), int64(2); got != want {
+		t.Fatalf("Expected write revision %d but got %d", want, got)
+	}
+
+	if _, err := tx.GetSignedLogRoot(ctx, 98765+1); err != nil {
+		t.Fatalf("Failed to read root by timestamp: %v", err)
+	}
+}
+
+func TestMapSetGetMultipleRevisions(t *testing.T) {
+	ctx := context.Background()
+	mapID := prepareTestMap(t)
+	s := prepareTestMapStorage(mapID, t)
+
+	numRevs := 3
+	values := make([]trillian.MapLeaf, numRevs)
+	for i := 0; i < numRevs; i++ {
+		values[i] = trillian.MapLeaf{
+			KeyHash:   keyHash,
+			LeafHash:  []byte(fmt.Sprintf("A Hash %d", i)),
+			LeafValue: []byte(fmt.Sprintf("A Value %d", i)),
+			ExtraData: []byte(fmt.Sprintf("Some Extra Data %d", i)),
+		}
+	}
+
+	for i := 0; i < numRevs; i++ {
+		tx, err := s.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin: %v", err)
+		}
+		if err := tx.Set(ctx, keyHash, values[i]); err != nil {
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[i], err)
+		}
+		root := trillian.SignedMapRoot{MapId: mapID.MapID, TimestampNanos: int64(i), MapRevision: tx.WriteRevision(),
+			RootHash: dummyHash(), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store root: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	for i := 0; i < numRevs; i++ {
+		tx, err := s.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin snapshot: %v", err)
+		}
+
+		readValues, err := tx.Get(ctx, int64(i+1), []trillian.Hash{keyHash})
+		if err != nil {
+			t.Fatalf("At rev %d failed to get %v:  %v", i+1, keyHash, err)
+		}
+		if got, want := len(readValues), 1; got != want {
+			t.Fatalf("At rev %d got %d values, expected %d", i+1, got, want)
+		}
+		if got, want := &readValues[0], &values[i]; !proto.Equal(got, want) {
+			t.Fatalf("At rev %d read back %v, but expected %v", i+1, got, want)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("At rev %d failed to commit: %v", i+1, err)
+		}
+	}
+}
+
+func TestMapRevisionConflict(t *testing.T) {
+	ctx := context.Background()
+	mapID := prepareTestMap(t)
+	s := prepareTestMapStorage(mapID, t)
+
+	tx1, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	tx2, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+
+	for _, tx := range []storage.MapTX{tx1, tx2} {
+		if err := tx.Set(ctx, keyHash, trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value")}); err != nil {
+			t.Fatalf("Failed to set %v: %v", keyHash, err)
+		}
+	}
+
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if err := tx2.Commit(); err != storage.ErrMapRevisionConflict {
+		t.Fatalf("Committing a second write at the same revision returned %v, want %v", err, storage.ErrMapRevisionConflict)
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	ctx := context.Background()
+	mapID := prepareTestMap(t)
+	s := prepareTestMapStorage(mapID, t)
+
+	// Storing a key that's already there does nothing
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	for _, key := range []string{"b/2", "a", "b/1", "c", "b/1", "b"} {
+		if err := tx.StoreKey(ctx, trillian.Key(key)); err != nil {
+			t.Fatalf("Failed to store key %q: %v", key, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	for _, test := range []struct {
+		start, end trillian.Key
+		count      int
+		want       []string
+	}{
+		{trillian.Key{}, nil, 10, []string{"a", "b", "b/1", "b/2", "c"}},
+		{trillian.Key("b/"), trillian.Key("b0"), 10, []string{"b/1", "b/2"}},
+		{trillian.Key("b"), nil, 2, []string{"b", "b/1"}},
+		{trillian.Key("b/2"), trillian.Key("c"), 10, []string{"b/2"}},
+		{trillian.Key("d"), nil, 10, []string{}},
+	} {
+		tx, err := s.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin snapshot: %v", err)
+		}
+
+		got, err := tx.GetKeys(ctx, test.start, test.end, test.count)
+		if err != nil {
+			t.Fatalf("Failed to get %d keys from %q to %q: %v", test.count, test.start, test.end, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("Got %d keys from %q to %q, expected %d", len(got), test.start, test.end, len(test.want))
+		}
+		for i, key := range got {
+			if string(key) != test.want[i] {
+				t.Errorf("Got key %q at %d from %q to %q, expected %q", key, i, test.start, test.end, test.want[i])
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+}
+
+func TestAdminTreeLifecycle(t *testing.T) {
+	as, err := openTestProviderOrSkip(t).AdminStorage()
+	if err != nil {
+		t.Fatalf("Failed to open admin storage: %s", err)
+	}
+
+	atx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	tree, err := atx.CreateTree(&trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("TestAdminTreeLifecycle"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+		DisplayName:   "Lifecycle",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+
+	rtx, err := as.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	got, err := rtx.GetTree(tree.TreeId)
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+	if !proto.Equal(tree, got) {
+		t.Errorf("GetTree()=%v, want %v", got, tree)
+	}
+	if err := rtx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin snapshot: %v", err)
+	}
+
+	// Two updates of the same tree can't both be committed
+	atx1, _ := as.Begin(context.Background())
+	atx2, _ := as.Begin(context.Background())
+	for _, tx := range []storage.AdminTX{atx1, atx2} {
+		if _, err := tx.UpdateTree(tree.TreeId, func(t *trillian.Tree) { t.DisplayName = "Updated" }); err != nil {
+			t.Fatalf("Failed to update tree: %v", err)
+		}
+	}
+	if err := atx1.Commit(); err != nil {
+		t.Fatalf("Failed to commit update: %v", err)
+	}
+	if err := atx2.Commit(); err != errTreeChanged {
+		t.Fatalf("Committing a concurrent update returned %v, want %v", err, errTreeChanged)
+	}
+
+	atx, _ = as.Begin(context.Background())
+	if _, err := atx.SoftDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to soft delete tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit soft delete: %v", err)
+	}
+
+	atx, _ = as.Begin(context.Background())
+	if err := atx.HardDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to hard delete tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit hard delete: %v", err)
+	}
+
+	rtx, _ = as.Snapshot(context.Background())
+	defer rtx.Commit()
+	if _, err := rtx.GetTree(tree.TreeId); err != storage.ErrTreeNotFound {
+		t.Fatalf("GetTree() after hard delete returned %v, want %v", err, storage.ErrTreeNotFound)
+	}
+}
+
+func dummyHash() []byte {
+	h := sha256.Sum256([]byte("dummy"))
+	return h[:]
+}
+
+func createSomeNodes() []storage.Node {
+	r := make([]storage.Node, 4)
+	for i := range r {
+		r[i].NodeID = storage.NewNodeIDWithPrefix(uint64(i), 8, 8, 8)
+		h := sha256.Sum256([]byte{byte(i)})
+		r[i].Hash = h[:]
+	}
+	return r
+}
+
+// Creates some test leaves with predictable data
+func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0)
+	hasher := trillian.NewSHA256()
+
+	for l := int64(0); l < n; l++ {
+		lv := fmt.Sprintf("Leaf %d", l)
+		leaf := trillian.LogLeaf{
+			Leaf:                 trillian.Leaf{LeafHash: hasher.Digest([]byte(lv)), LeafValue: []byte(lv), ExtraData: []byte(fmt.Sprintf("Extra %d", l))},
+			SignedEntryTimestamp: signedTimestamp,
+			SequenceNumber:       startSeq + l,
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves
+}
+
+func containsTreeID(ids []trillian.LogID, treeID int64) bool {
+	for _, id := range ids {
+		if id.TreeID == treeID {
+			return true
+		}
+	}
+	return false
+}
+
+var testProvider struct {
+	sync.Mutex
+	p   *cassandraProvider
+	err error
+}
+
+// openTestProviderOrSkip returns a provider for the test keyspace, skipping the test if the
+// cluster can't be reached
+func openTestProviderOrSkip(t *testing.T) *cassandraProvider {
+	testProvider.Lock()
+	defer testProvider.Unlock()
+
+	if testProvider.p == nil && testProvider.err == nil {
+		p, err := newCassandraProvider(*testDSNFlag)
+		if err == nil {
+			_, err = p.(*cassandraProvider).getSession()
+		}
+		if err != nil {
+			testProvider.err = err
+		} else {
+			testProvider.p = p.(*cassandraProvider)
+		}
+	}
+
+	if testProvider.err != nil {
+		t.Skipf("Cassandra test cluster not available: %v", testProvider.err)
+	}
+
+	return testProvider.p
+}
+
+func prepareTestTree(treeID int64, keyID []byte, treeType string, t *testing.T) {
+	session, _ := openTestProviderOrSkip(t).getSession()
+
+	err := session.Query(`INSERT INTO Trees(TreeId, KeyId, TreeType, LeafHasherType, TreeHasherType, TreeState, Deleted)
+					 VALUES(?, ?, ?, 'SHA256', 'SHA256', 'ACTIVE', false)`, treeID, keyID, treeType).Exec()
+
+	if err != nil {
+		t.Fatalf("Failed to create tree entry for test: %v", err)
+	}
+}
+
+func prepareTestLog(t *testing.T) trillian.LogID {
+	logID := trillian.LogID{LogID: []byte(t.Name()), TreeID: nextTreeID()}
+	prepareTestTree(logID.TreeID, logID.LogID, "LOG", t)
+
+	return logID
+}
+
+func prepareTestMap(t *testing.T) trillian.MapID {
+	mapID := trillian.MapID{MapID: []byte(t.Name()), TreeID: nextTreeID()}
+	prepareTestTree(mapID.TreeID, mapID.MapID, "MAP", t)
+
+	return mapID
+}
+
+func prepareTestLogStorage(logID trillian.LogID, t *testing.T) storage.LogStorage {
+	s, err := openTestProviderOrSkip(t).LogStorage(logID)
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %s", err)
+	}
+
+	return s
+}
+
+func prepareTestMapStorage(mapID trillian.MapID, t *testing.T) storage.MapStorage {
+	s, err := openTestProviderOrSkip(t).MapStorage(mapID)
+	if err != nil {
+		t.Fatalf("Failed to open map storage: %s", err)
+	}
+
+	return s
+}
+
+// storeTestRoot stores a root of treeSize leaves at the transaction's write revision
+func storeTestRoot(tx storage.LogTX, treeSize int64, t *testing.T) {
+	root := trillian.SignedLogRoot{TimestampNanos: 98765 + tx.WriteRevision(), TreeSize: treeSize, TreeRevision: tx.WriteRevision(),
+		RootHash: dummyHash(), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	if err := tx.StoreSignedLogRoot(context.Background(), root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+}
+
+func commit(tx storage.LogTX, t *testing.T) {
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit tx: %v", err)
+	}
+}
+
+func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
+	tx, err := s.Begin(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+
+	return tx
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,618 @@
+package cassandra
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+const selectTreeStateCql string = "SELECT TreeState, Deleted FROM Trees WHERE TreeId=?"
+const selectTreeHasherTypeCql string = "SELECT TreeHasherType FROM Trees WHERE TreeId=?"
+const selectSubtreeCql string = `SELECT Nodes FROM Subtree
+		 WHERE TreeId=? AND SubtreeId=? AND SubtreeRevision<=? LIMIT 1`
+const insertSubtreeCql string = "INSERT INTO Subtree(TreeId, SubtreeId, SubtreeRevision, Nodes) VALUES(?, ?, ?, ?)"
+const insertRevisionClaimCql string = `INSERT INTO TreeRevisionClaim(TreeId, TreeRevision, TxId) VALUES(?, ?, ?)
+		 IF NOT EXISTS USING TTL ?`
+const selectTreeRevisionAtSizeCql string = "SELECT TreeRevision FROM TreeHeadSize WHERE TreeId=? AND TreeSize=? LIMIT 1"
+const selectTreeRevisionCoveringSizeCql string = "SELECT TreeRevision, TreeSize FROM TreeHeadSize WHERE TreeId=? AND TreeSize>=? LIMIT 1"
+
+// revisionClaimTTL is how long, in seconds, a transaction's claim on the revision it writes
+// lasts. A transaction must finish committing within this time, and a revision claimed by
+// a transaction that failed part way through committing can't be written until it passes.
+const revisionClaimTTL = 600
+
+// maxConcurrentQueries is the most queries a transaction runs at once, when it reads
+// several subtrees or applies its writes
+const maxConcurrentQueries = 32
+
+// statement is a CQL statement and its arguments, to be run later
+type statement struct {
+	cql  string
+	args []interface{}
+}
+
+// runConcurrently calls f for each of 0..n-1, running up to maxConcurrentQueries at once, and
+// returns one of the errors if any fail
+func runConcurrently(n int, f func(i int) error) error {
+	sem := make(chan struct{}, maxConcurrentQueries)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := f(i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// This is nil if nothing failed
+	return <-errs
+}
+
+// runStatements runs statements concurrently, so they mustn't depend on each other
+func runStatements(ctx context.Context, session *gocql.Session, statements []statement) error {
+	return runConcurrently(len(statements), func(i int) error {
+		return session.Query(statements[i].cql, statements[i].args...).WithContext(ctx).Exec()
+	})
+}
+
+// conditionalWrite is a lightweight transaction, and the error returned if it isn't applied
+type conditionalWrite struct {
+	statement
+	conflict error
+}
+
+// applyCondition runs a conditional write, returning its conflict error if it isn't applied
+func applyCondition(ctx context.Context, session *gocql.Session, c conditionalWrite) error {
+	applied, err := session.Query(c.cql, c.args...).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return c.conflict
+	}
+
+	return nil
+}
+
+// cassandraTreeStorage is shared between the cassandraLog- and cassandraMap- Storage
+// implementations, and contains functionality which is common to both.
+type cassandraTreeStorage struct {
+	treeID          int64
+	session         *gocql.Session
+	hashAlgorithm   trillian.HashAlgorithm
+	hashSizeBytes   int
+	populateSubtree storage.PopulateSubtreeFunc
+	cacheLimits     cache.CacheLimits
+	// storeInternalNodes is true if subtrees are stored with their internal nodes
+	storeInternalNodes bool
+	// subtreeKeyVersion is the version of the suffix keys subtrees are written with
+	subtreeKeyVersion int32
+	// selectRootAtRevisionCql reads the root of the tree at a revision, to check that a
+	// revision that's been claimed hasn't already been written
+	selectRootAtRevisionCql string
+	// revisionConflict is returned by Commit if another transaction is writing, or has
+	// written, the same revision
+	revisionConflict error
+}
+
+// newTreeStorage creates the tree hasher configured for the tree, which is passed to
+// populateFactory to build the function used to rebuild subtrees.
+func newTreeStorage(treeID int64, session *gocql.Session, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc) (*cassandraTreeStorage, error) {
+	hashAlgorithm, err := getTreeHashAlgorithm(session, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	th, err := merkle.NewTreeHasher(hashAlgorithm)
+	if err != nil {
+		glog.Warningf("Failed to create tree hasher for tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	s := &cassandraTreeStorage{
+		treeID:          treeID,
+		session:         session,
+		hashAlgorithm:   hashAlgorithm,
+		hashSizeBytes:   th.Size(),
+		populateSubtree: populateFactory(th),
+	}
+
+	return s, nil
+}
+
+// getTreeHashAlgorithm reads the hash algorithm the tree was created with.
+// TODO: Trees without a row default to SHA256 like the other tree properties until
+// everything creates trees through the admin API.
+func getTreeHashAlgorithm(session *gocql.Session, treeID int64) (trillian.HashAlgorithm, error) {
+	var treeHasherType string
+	if err := session.Query(selectTreeHasherTypeCql, treeID).Scan(&treeHasherType); err == gocql.ErrNotFound {
+		return trillian.HashAlgorithm_SHA256, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read hasher type for tree %d: %s", treeID, err)
+		return 0, err
+	}
+
+	v, ok := trillian.HashAlgorithm_value[treeHasherType]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash algorithm %s for tree %d", treeHasherType, treeID)
+	}
+
+	return trillian.HashAlgorithm(v), nil
+}
+
+func decodeSignedTimestamp(ctx context.Context, signedEntryTimestampBytes []byte) (trillian.SignedEntryTimestamp, error) {
+	var signedEntryTimestamp trillian.SignedEntryTimestamp
+
+	if err := proto.Unmarshal(signedEntryTimestampBytes, &signedEntryTimestamp); err != nil {
+		logging.Warningf(ctx, "Failed to decode SignedTimestamp: %s", err)
+		return trillian.SignedEntryTimestamp{}, err
+	}
+
+	return signedEntryTimestamp, nil
+}
+
+func encodeSignedTimestamp(ctx context.Context, signedEntryTimestamp trillian.SignedEntryTimestamp) ([]byte, error) {
+	marshalled, err := proto.Marshal(&signedEntryTimestamp)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to encode SignedTimestamp: %s", err)
+		return nil, err
+	}
+
+	return marshalled, err
+}
+
+// HashAlgorithm returns the hash algorithm the tree was created with.
+func (c *cassandraTreeStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return c.hashAlgorithm
+}
+
+// SetSubtreeCacheLimits sets the limits for the subtree caches of transactions started
+// after this call. By default the caches are unlimited.
+func (c *cassandraTreeStorage) SetSubtreeCacheLimits(limits cache.CacheLimits) {
+	c.cacheLimits = limits
+}
+
+// SetStoreInternalNodes sets whether subtrees written by transactions started after this
+// call are stored with their internal nodes. By default only their leaves are stored.
+func (c *cassandraTreeStorage) SetStoreInternalNodes(store bool) {
+	c.storeInternalNodes = store
+}
+
+// SetSubtreeKeyVersion sets the version of the suffix key encoding that subtrees written by
+// transactions started after this call use. By default they use storage.SuffixKeyV0.
+func (c *cassandraTreeStorage) SetSubtreeKeyVersion(version int32) {
+	c.subtreeKeyVersion = version
+}
+
+// beginTreeTx starts a transaction traced as a span of ctx, which lasts until the
+// transaction is committed or rolled back.
+func (c *cassandraTreeStorage) beginTreeTx(ctx context.Context) treeTX {
+	ctx, span := monitoring.StartSpan(ctx, "cassandra.TX", attribute.Int64("treeid", c.treeID))
+	subtreeCache := cache.NewSubtreeCacheWithLimits(c.populateSubtree, c.cacheLimits)
+	subtreeCache.SetStoreInternalNodes(c.storeInternalNodes)
+	subtreeCache.SetSubtreeKeyVersion(c.subtreeKeyVersion)
+	return treeTX{
+		ctx:           ctx,
+		span:          span,
+		ts:            c,
+		subtreeCache:  subtreeCache,
+		writeRevision: -1,
+		readRevision:  -1,
+		txID:          gocql.TimeUUID(),
+		writesMutex:   new(sync.Mutex),
+		subtrees:      make(map[string]statement),
+		started:       time.Now(),
+	}
+}
+
+type treeTX struct {
+	closed bool
+	// ctx is the context the transaction was started with, which its writes are applied
+	// under when it's committed
+	ctx           context.Context
+	span          trace.Span
+	ts            *cassandraTreeStorage
+	subtreeCache  cache.SubtreeCache
+	writeRevision int64
+	// readRevision is the revision a transaction started by SnapshotForTree is pinned to,
+	// or -1 for other transactions
+	readRevision int64
+	// txID identifies the transaction's claim on writeRevision
+	txID gocql.UUID
+
+	// writesMutex guards the writes below, which may be added to concurrently by the
+	// subtree cache
+	writesMutex *sync.Mutex
+	// subtrees are the subtrees to store at writeRevision, by prefix, so that a subtree
+	// written more than once is only stored once
+	subtrees map[string]statement
+	// revisionWrites are the other writes at writeRevision, which are applied along with
+	// the subtrees once the revision has been claimed
+	revisionWrites []statement
+	// writes don't belong to a revision, and are applied whether or not there is one
+	writes []statement
+	// conditions are IF NOT EXISTS writes, applied before the others, which fail the
+	// commit if they aren't applied
+	conditions []conditionalWrite
+	// root stores the root for writeRevision, if it's set, once everything else at the
+	// revision has been written. It's written with IF NOT EXISTS.
+	root *statement
+	// afterRoot are applied once the root has been stored, and not at all if it isn't set
+	afterRoot []statement
+
+	// started is when the transaction began, for recording how long it was open
+	started time.Time
+}
+
+// addWrites adds writes that don't belong to a revision
+func (t *treeTX) addWrites(writes ...statement) {
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	t.writes = append(t.writes, writes...)
+}
+
+// addRevisionWrites adds writes that are stored at writeRevision
+func (t *treeTX) addRevisionWrites(writes ...statement) {
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	t.revisionWrites = append(t.revisionWrites, writes...)
+}
+
+// addCondition adds a conditional write, which fails the commit with conflict if it isn't
+// applied
+func (t *treeTX) addCondition(s statement, conflict error) {
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	t.conditions = append(t.conditions, conditionalWrite{s, conflict})
+}
+
+// setRoot sets the write that stores the root at writeRevision
+func (t *treeTX) setRoot(root statement) {
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	t.root = &root
+}
+
+// addAfterRoot adds writes to apply once the root has been stored
+func (t *treeTX) addAfterRoot(writes ...statement) {
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	t.afterRoot = append(t.afterRoot, writes...)
+}
+
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+// getSubtrees reads the newest revision at or before treeRevision of each subtree. Each
+// subtree is its own partition, so they're read concurrently.
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+
+	ctx, span := monitoring.StartSpan(trace.ContextWithSpan(ctx, t.span), "cassandra.getSubtrees", attribute.Int("subtrees", len(nodeIDs)))
+	defer span.End()
+
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+	}
+
+	subtrees := make([]*storage.SubtreeProto, len(nodeIDs))
+	err := runConcurrently(len(nodeIDs), func(i int) error {
+		nodeID := nodeIDs[i]
+		var nodesRaw []byte
+		err := t.ts.session.Query(selectSubtreeCql, t.ts.treeID, nodeID.Path[:nodeID.PrefixLenBits/8], treeRevision).WithContext(ctx).Scan(&nodesRaw)
+
+		if err == gocql.ErrNotFound {
+			return nil
+		} else if err != nil {
+			logging.Warningf(ctx, "Failed to get merkle subtree: %s", err)
+			return err
+		}
+
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal SubtreeProto: %s", err)
+			return err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		subtrees[i] = &subtree
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+	for _, s := range subtrees {
+		if s != nil {
+			ret = append(ret, s)
+		}
+	}
+
+	// The InternalNodes cache is nil here unless the subtrees were stored with their
+	// internal nodes, otherwise the SubtreeCache (which called this method) will
+	// re-populate it.
+	return ret, nil
+}
+
+// storeSubtrees keeps the subtrees to be written when the transaction is committed
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storage.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		logging.Warningf(ctx, "attempted to store 0 subtrees...")
+		return nil
+	}
+
+	t.writesMutex.Lock()
+	defer t.writesMutex.Unlock()
+
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		// The SubtreeCache has already removed the internal nodes unless it was told to
+		// store them, otherwise they're recalculated when this subtree is read back.
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		t.subtrees[string(s.Prefix)] = statement{insertSubtreeCql, []interface{}{t.ts.treeID, s.Prefix, t.writeRevision, subtreeBytes}}
+	}
+
+	return nil
+}
+
+// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
+// It is an error to request tree sizes larger than the currently published tree size.
+// This only works for sizes where there is a stored tree head, the same as the MySQL
+// implementation.
+func (t *treeTX) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
+	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
+	if treeSize <= 0 {
+		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision int64
+	err := t.ts.session.Query(selectTreeRevisionAtSizeCql, t.ts.treeID, treeSize).WithContext(ctx).Scan(&treeRevision)
+
+	return treeRevision, err
+}
+
+// getTreeRevisionCoveringSize returns the revision and size of the tree that a snapshot for
+// treeSize is pinned to, the same as the MySQL implementation.
+func (t *treeTX) getTreeRevisionCoveringSize(ctx context.Context, treeSize int64) (int64, int64, error) {
+	if treeSize <= 0 {
+		return 0, 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision, size int64
+	err := t.ts.session.Query(selectTreeRevisionCoveringSizeCql, t.ts.treeID, treeSize).WithContext(ctx).Scan(&treeRevision, &size)
+
+	return treeRevision, size, err
+}
+
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	hashes, err := t.subtreeCache.GetNodeHashes(ctx, nodeIDs, func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	for i, h := range hashes {
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeIDs[i],
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	return t.subtreeCache.GetProofPath(ctx, leafID, treeSize, func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	})
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(ctx, n.NodeID, n.Hash,
+			func(ctx context.Context, nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			},
+			t.storeSubtrees)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimRevision stops any other transaction writing writeRevision until the claim expires,
+// returning revisionConflict if another transaction has claimed it or it's already been
+// written
+func (t *treeTX) claimRevision(ctx context.Context) error {
+	existing := make(map[string]interface{})
+	applied, err := t.ts.session.Query(insertRevisionClaimCql, t.ts.treeID, t.writeRevision, t.txID, revisionClaimTTL).WithContext(ctx).MapScanCAS(existing)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to claim revision %d: %s", t.writeRevision, err)
+		return err
+	}
+
+	// A retried claim finds the one it made itself
+	if !applied && existing["txid"] != t.txID {
+		return t.ts.revisionConflict
+	}
+
+	// The revision could have been written by a transaction whose claim has expired since
+	var revision int64
+	err = t.ts.session.Query(t.ts.selectRootAtRevisionCql, t.ts.treeID, t.writeRevision).WithContext(ctx).Scan(&revision)
+
+	switch {
+	case err == gocql.ErrNotFound:
+		return nil
+	case err != nil:
+		logging.Warningf(ctx, "Failed to check for a root at revision %d: %s", t.writeRevision, err)
+		return err
+	default:
+		return t.ts.revisionConflict
+	}
+}
+
+// commit applies the transaction's writes. The revision is claimed and everything written at
+// it before the root, so readers don't see the revision until it's complete.
+func (t *treeTX) commit() error {
+	if t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(t.ctx, t.storeSubtrees); err != nil {
+			logging.Warningf(t.ctx, "TX commit flush error: %s", err)
+			return err
+		}
+	}
+
+	revisionWrites := t.revisionWrites
+	for _, s := range t.subtrees {
+		revisionWrites = append(revisionWrites, s)
+	}
+
+	if len(revisionWrites) > 0 || t.root != nil {
+		if err := t.claimRevision(t.ctx); err != nil {
+			return err
+		}
+	}
+
+	// If one of several conditions fails those already applied stay applied, so callers
+	// only add more than one when that's harmless.
+	err := runConcurrently(len(t.conditions), func(i int) error {
+		return applyCondition(t.ctx, t.ts.session, t.conditions[i])
+	})
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to apply conditional writes: %s", err)
+		return err
+	}
+
+	if err := runStatements(t.ctx, t.ts.session, append(revisionWrites, t.writes...)); err != nil {
+		logging.Warningf(t.ctx, "Failed to apply writes: %s", err)
+		return err
+	}
+
+	if t.root == nil {
+		return nil
+	}
+
+	if err := applyCondition(t.ctx, t.ts.session, conditionalWrite{*t.root, t.ts.revisionConflict}); err != nil {
+		logging.Warningf(t.ctx, "Failed to store root: %s", err)
+		return err
+	}
+
+	return runStatements(t.ctx, t.ts.session, t.afterRoot)
+}
+
+func (t *treeTX) Commit() error {
+	err := t.commit()
+	t.closed = true
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "cassandra", "commit")
+	monitoring.EndSpan(t.span, err)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "TX commit error: %s", err)
+	}
+
+	return err
+}
+
+// Rollback discards the transaction's writes, none of which have been applied
+func (t *treeTX) Rollback() error {
+	t.closed = true
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "cassandra", "rollback")
+	t.span.SetAttributes(attribute.Bool("rollback", true))
+	monitoring.EndSpan(t.span, nil)
+
+	return nil
+}
+
+// checkTreeState returns storage.ErrTreeDeleted if the tree has been deleted and, for
+// writable transactions, storage.ErrReadOnly if it has been frozen. Trees can be frozen
+// or deleted through the admin API at any time so this is checked whenever a transaction
+// is started.
+func (t *treeTX) checkTreeState(ctx context.Context, write bool) error {
+	var state string
+	var deleted bool
+	err := t.ts.session.Query(selectTreeStateCql, t.ts.treeID).WithContext(ctx).Scan(&state, &deleted)
+
+	switch {
+	case err == gocql.ErrNotFound:
+		// Storage can currently be opened for trees that don't have a Trees row.
+		return nil
+	case err != nil:
+		logging.Warningf(ctx, "Failed to read state of tree %d: %s", t.ts.treeID, err)
+		return err
+	case deleted:
+		return storage.ErrTreeDeleted
+	case write && state == trillian.TreeState_FROZEN.String():
+		return storage.ErrReadOnly
+	}
+
+	return nil
+}
+
+// ReadRevision returns the tree revision a snapshot transaction is pinned to.
+func (t *treeTX) ReadRevision() int64 {
+	return t.readRevision
+}
+
+func (t *treeTX) IsOpen() bool {
+	return !t.closed
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util"
+)
+
+// ErrUnhealthy is returned when storage is shedding load because its database is failing,
+// rather than letting requests queue up for it. Clients should back off and retry.
+var ErrUnhealthy = errors.New("storage: database is unhealthy, shedding load")
+
+// breakerRejections counts the transactions refused by open circuit breakers, by storage
+var breakerRejections = monitoring.NewCounter("storage_breaker_rejections", "Transactions refused because the database was unhealthy", "storage")
+
+// CircuitBreakerConfig says when a CircuitBreaker opens
+type CircuitBreakerConfig struct {
+	// Failures is how many failures in a row open the breaker, 0 means it never opens
+	Failures int
+	// OpenDuration is how long the breaker stays open before letting a transaction through
+	// to see if the database has recovered
+	OpenDuration time.Duration
+}
+
+// CircuitBreaker keeps work away from a database that keeps failing. Once it's seen enough
+// failures in a row it opens and refuses new transactions with ErrUnhealthy, letting one
+// through every OpenDuration to test the database. The first success closes it again.
+type CircuitBreaker struct {
+	config     CircuitBreakerConfig
+	storage    string
+	timeSource util.TimeSource
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker for the named storage system
+func NewCircuitBreaker(config CircuitBreakerConfig, storage string, timeSource util.TimeSource) *CircuitBreaker {
+	return &CircuitBreaker{config: config, storage: storage, timeSource: timeSource}
+}
+
+func (b *CircuitBreaker) open() bool {
+	return b.config.Failures > 0 && b.failures >= b.config.Failures
+}
+
+// Allow returns ErrUnhealthy if a new transaction shouldn't be started, or nil if it can be.
+// An open breaker allows one transaction each time its OpenDuration passes.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open() {
+		return nil
+	}
+
+	now := b.timeSource.Now()
+
+	if now.Before(b.openUntil) {
+		breakerRejections.Inc(b.storage)
+		return ErrUnhealthy
+	}
+
+	b.openUntil = now.Add(b.config.OpenDuration)
+	return nil
+}
+
+// Success records that the database did some work, which closes the breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+}
+
+// Failure records that the database failed, which opens the breaker, or keeps it open, if
+// there have now been enough failures in a row
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	if b.open() {
+		b.openUntil = b.timeSource.Now().Add(b.config.OpenDuration)
+	}
+}
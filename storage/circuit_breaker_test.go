@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+func TestCircuitBreakerOpensAfterFailures(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	b := NewCircuitBreaker(CircuitBreakerConfig{Failures: 3, OpenDuration: time.Second}, "test", ts)
+
+	// A success in between means the failures aren't in a row
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Breaker refused work after %d failures in a row: %v", 2, err)
+	}
+
+	b.Failure()
+
+	if err := b.Allow(); err != ErrUnhealthy {
+		t.Fatalf("Got %v from open breaker, want %v", err, ErrUnhealthy)
+	}
+
+	// One transaction is let through to test the database once the open duration passes
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Open breaker didn't let a trial through: %v", err)
+	}
+
+	if err := b.Allow(); err != ErrUnhealthy {
+		t.Fatalf("Got %v from open breaker during trial, want %v", err, ErrUnhealthy)
+	}
+
+	// The trial failing keeps the breaker open for another period
+	b.Failure()
+	ts.FakeTime = ts.FakeTime.Add(time.Millisecond * 500)
+
+	if err := b.Allow(); err != ErrUnhealthy {
+		t.Fatalf("Got %v from breaker after failed trial, want %v", err, ErrUnhealthy)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Open breaker didn't let a trial through: %v", err)
+	}
+
+	// The trial succeeding closes the breaker
+	b.Success()
+
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Closed breaker refused work: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(CircuitBreakerConfig{}, "test", util.SystemTimeSource{})
+
+	for i := 0; i < 100; i++ {
+		b.Failure()
+	}
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Disabled breaker refused work: %v", err)
+	}
+}
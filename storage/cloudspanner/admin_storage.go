@@ -0,0 +1,332 @@
+package cloudspanner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+const selectTreeColumnsSQL string = `SELECT TreeId, KeyId, TreeType, TreeState, LeafHasherType, AllowsDuplicateLeaves,
+		 DisplayName, Description, CreateTimeMillis, UpdateTimeMillis, Deleted, DeleteTimeMillis
+		 FROM Trees`
+const selectTreeByIDSQL string = selectTreeColumnsSQL + " WHERE TreeId=@tree_id"
+const selectTreesSQL string = selectTreeColumnsSQL + " ORDER BY TreeId"
+const selectTreeUsageSQL string = `SELECT Requests, BytesIn, BytesOut, LeavesQueued, UpdateTimeMillis
+		 FROM TreeUsage WHERE TreeId=@tree_id`
+
+var treeColumns = []string{"TreeId", "KeyId", "TreeType", "TreeState", "LeafHasherType", "TreeHasherType",
+	"AllowsDuplicateLeaves", "DisplayName", "Description", "CreateTimeMillis", "UpdateTimeMillis", "Deleted",
+	"DeleteTimeMillis", "ReadOnlyRequests"}
+var updateTreeColumns = []string{"TreeId", "TreeState", "DisplayName", "Description", "UpdateTimeMillis", "Deleted",
+	"DeleteTimeMillis"}
+var treeUsageColumns = []string{"TreeId", "Requests", "BytesIn", "BytesOut", "LeavesQueued", "UpdateTimeMillis"}
+
+// errTreeChanged is returned by Commit if a tree updated through the transaction was changed
+// by another one since it was read
+var errTreeChanged = errors.New("cloudspanner: tree was updated concurrently")
+
+type spannerAdminStorage struct {
+	client *spanner.Client
+}
+
+func (s *spannerAdminStorage) beginInternal(ctx context.Context) *adminTX {
+	return &adminTX{client: s.client, stx: s.client.ReadOnlyTransaction(), ctx: ctx}
+}
+
+func (s *spannerAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return s.beginInternal(ctx), nil
+}
+
+func (s *spannerAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.beginInternal(ctx), nil
+}
+
+// adminTX reads from a snapshot and keeps its writes until it's committed, like the tree
+// transactions. The writes are applied in a single read-write transaction.
+type adminTX struct {
+	client *spanner.Client
+	stx    *spanner.ReadOnlyTransaction
+	// ctx is what the reads and writes are made with and problems are logged against
+	ctx context.Context
+
+	conditionalWrites []conditionalWrite
+	mutations         []*spanner.Mutation
+}
+
+func (t *adminTX) Commit() error {
+	defer t.stx.Close()
+
+	if len(t.conditionalWrites) == 0 && len(t.mutations) == 0 {
+		return nil
+	}
+
+	_, err := t.client.ReadWriteTransaction(t.ctx, func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+		for _, w := range t.conditionalWrites {
+			if err := w(ctx, rtx); err != nil {
+				return err
+			}
+		}
+
+		return rtx.BufferWrite(t.mutations)
+	})
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Admin TX commit error: %s", err)
+	}
+
+	return err
+}
+
+// Rollback discards the transaction's writes, none of which have been applied
+func (t *adminTX) Rollback() error {
+	t.stx.Close()
+	t.conditionalWrites, t.mutations = nil, nil
+	return nil
+}
+
+func (t *adminTX) readTree(row *spanner.Row) (*trillian.Tree, error) {
+	var tree trillian.Tree
+	var treeType, treeState, hashAlgorithm string
+
+	if err := row.Columns(&tree.TreeId, &tree.KeyId, &treeType, &treeState, &hashAlgorithm, &tree.AllowDuplicateLeaves,
+		&tree.DisplayName, &tree.Description, &tree.CreateTimeMillis, &tree.UpdateTimeMillis, &tree.Deleted,
+		&tree.DeleteTimeMillis); err != nil {
+		return nil, err
+	}
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		tree.TreeType = trillian.TreeType(v)
+	} else {
+		return nil, fmt.Errorf("unknown tree type %s for tree %d", treeType, tree.TreeId)
+	}
+
+	if v, ok := trillian.TreeState_value[treeState]; ok {
+		tree.TreeState = trillian.TreeState(v)
+	} else {
+		return nil, fmt.Errorf("unknown tree state %s for tree %d", treeState, tree.TreeId)
+	}
+
+	if v, ok := trillian.HashAlgorithm_value[hashAlgorithm]; ok {
+		tree.HashAlgorithm = trillian.HashAlgorithm(v)
+	} else {
+		return nil, fmt.Errorf("unknown hash algorithm %s for tree %d", hashAlgorithm, tree.TreeId)
+	}
+
+	return &tree, nil
+}
+
+func (t *adminTX) GetTree(treeID int64) (*trillian.Tree, error) {
+	var tree *trillian.Tree
+
+	err := t.stx.Query(t.ctx, spanner.Statement{
+		SQL:    selectTreeByIDSQL,
+		Params: map[string]interface{}{"tree_id": treeID},
+	}).Do(func(row *spanner.Row) error {
+		var err error
+		tree, err = t.readTree(row)
+		return err
+	})
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to read tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	if tree == nil {
+		return nil, storage.ErrTreeNotFound
+	}
+
+	return tree, nil
+}
+
+func (t *adminTX) ListTrees(includeDeleted bool) ([]*trillian.Tree, error) {
+	trees := make([]*trillian.Tree, 0)
+
+	err := t.stx.Query(t.ctx, spanner.NewStatement(selectTreesSQL)).Do(func(row *spanner.Row) error {
+		tree, err := t.readTree(row)
+		if err != nil {
+			return err
+		}
+
+		if includeDeleted || !tree.Deleted {
+			trees = append(trees, tree)
+		}
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to list trees: %s", err)
+		return nil, err
+	}
+
+	return trees, nil
+}
+
+func (t *adminTX) GetTreeUsage(treeID int64) (*trillian.TreeUsage, error) {
+	usage, err := readTreeUsage(t.ctx, t.stx, treeID)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to read usage of tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// readTreeUsage returns the usage stored for the tree, or a TreeUsage with only TreeId set if
+// there isn't any
+func readTreeUsage(ctx context.Context, r reader, treeID int64) (*trillian.TreeUsage, error) {
+	usage := &trillian.TreeUsage{TreeId: treeID}
+	err := queryRow(ctx, r, spanner.Statement{
+		SQL:    selectTreeUsageSQL,
+		Params: map[string]interface{}{"tree_id": treeID},
+	}, &usage.Requests, &usage.BytesIn, &usage.BytesOut, &usage.LeavesQueued, &usage.UpdateTimeMillis)
+
+	if err != nil && err != errNoRows {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (t *adminTX) CreateTree(tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.TreeState = trillian.TreeState_ACTIVE
+	newTree.CreateTimeMillis = nowMillis()
+	newTree.UpdateTimeMillis = newTree.CreateTimeMillis
+
+	// Both hashers are currently derived from the same algorithm
+	hashAlgorithm := newTree.HashAlgorithm.String()
+
+	// Committing fails if a tree already has the ID
+	t.mutations = append(t.mutations, spanner.Insert("Trees", treeColumns, []interface{}{newTree.TreeId, newTree.KeyId,
+		newTree.TreeType.String(), newTree.TreeState.String(), hashAlgorithm, hashAlgorithm, newTree.AllowDuplicateLeaves,
+		newTree.DisplayName, newTree.Description, newTree.CreateTimeMillis, newTree.UpdateTimeMillis, false, int64(0), false}))
+
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		tree := *orig
+		updateFunc(&tree)
+		if err := storage.ValidateTreeForUpdate(orig, &tree); err != nil {
+			return nil, err
+		}
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) SoftDeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if orig.Deleted {
+			return nil, storage.ErrTreeDeleted
+		}
+		tree := *orig
+		tree.Deleted = true
+		tree.DeleteTimeMillis = nowMillis()
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) UndeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if !orig.Deleted {
+			return nil, storage.ErrTreeNotDeleted
+		}
+		tree := *orig
+		tree.Deleted = false
+		tree.DeleteTimeMillis = 0
+		return &tree, nil
+	})
+}
+
+// updateTree stores the mutable settings of the tree returned by f when the transaction is
+// committed, as long as the tree hasn't been updated since it was read.
+func (t *adminTX) updateTree(treeID int64, f func(orig *trillian.Tree) (*trillian.Tree, error)) (*trillian.Tree, error) {
+	orig, err := t.GetTree(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := f(orig)
+	if err != nil {
+		return nil, err
+	}
+	tree.UpdateTimeMillis = nowMillis()
+
+	t.conditionalWrites = append(t.conditionalWrites, func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+		row, err := rtx.ReadRow(ctx, "Trees", spanner.Key{treeID}, []string{"UpdateTimeMillis"})
+		if err != nil {
+			return err
+		}
+
+		var updated int64
+		if err := row.Columns(&updated); err != nil {
+			return err
+		}
+
+		if updated != orig.UpdateTimeMillis {
+			return errTreeChanged
+		}
+
+		return rtx.BufferWrite([]*spanner.Mutation{spanner.Update("Trees", updateTreeColumns, []interface{}{tree.TreeId,
+			tree.TreeState.String(), tree.DisplayName, tree.Description, tree.UpdateTimeMillis, tree.Deleted,
+			tree.DeleteTimeMillis})})
+	})
+
+	return tree, nil
+}
+
+// AddTreeUsage adds to the totals as they are when the transaction is committed
+func (t *adminTX) AddTreeUsage(usage *trillian.TreeUsage) error {
+	t.conditionalWrites = append(t.conditionalWrites, func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+		total, err := readTreeUsage(ctx, rtx, usage.TreeId)
+		if err != nil {
+			return err
+		}
+
+		return rtx.BufferWrite([]*spanner.Mutation{spanner.InsertOrUpdate("TreeUsage", treeUsageColumns, []interface{}{
+			usage.TreeId, total.Requests + usage.Requests, total.BytesIn + usage.BytesIn, total.BytesOut + usage.BytesOut,
+			total.LeavesQueued + usage.LeavesQueued, nowMillis()})})
+	})
+
+	return nil
+}
+
+// HardDeleteTree removes the tree's row when the transaction is committed. All of its data is
+// interleaved in the row so is removed with it.
+func (t *adminTX) HardDeleteTree(treeID int64) error {
+	tree, err := t.GetTree(treeID)
+	if err != nil {
+		return err
+	}
+
+	if !tree.Deleted {
+		return storage.ErrTreeNotDeleted
+	}
+
+	t.mutations = append(t.mutations, spanner.Delete("Trees", spanner.Key{treeID}))
+
+	return nil
+}
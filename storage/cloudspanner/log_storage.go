@@ -0,0 +1,1271 @@
+package cloudspanner
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+const selectTreePropertiesSQL string = "SELECT AllowsDuplicateLeaves, TreeType, ReadOnlyRequests FROM Trees WHERE TreeId=@tree_id"
+const selectActiveLogsSQL string = `SELECT TreeId, KeyId, TreeType FROM Trees
+		 WHERE TreeType IN ('LOG', 'PREORDERED_LOG') AND TreeState='ACTIVE' AND Deleted=false`
+
+// Sequenced leaves are read along with their values. Only those below @tree_size are in the
+// tree, the others belong to a pre-ordered log and haven't been integrated yet.
+const selectLeavesSQL string = `SELECT s.SequenceNumber, s.LeafHash, s.SignedEntryTimestamp, l.TheData, l.LeafIdentityHash
+		 FROM SequencedLeafData s JOIN LeafData l ON l.TreeId=s.TreeId AND l.LeafHash=s.LeafHash
+		 WHERE s.TreeId=@tree_id`
+const selectLeavesByIndexSQL string = selectLeavesSQL + " AND s.SequenceNumber<@tree_size AND s.SequenceNumber IN UNNEST(@sequence_numbers)"
+const selectLeavesByRangeSQL string = selectLeavesSQL + ` AND s.SequenceNumber<@tree_size AND s.SequenceNumber>=@start
+		 ORDER BY s.SequenceNumber LIMIT @count`
+const selectLeavesByHashSQL string = selectLeavesSQL + " AND s.SequenceNumber<@tree_size AND s.LeafHash IN UNNEST(@leaf_hashes)"
+const selectLeavesByIdentityHashSQL string = selectLeavesSQL + " AND s.SequenceNumber<@tree_size AND l.LeafIdentityHash IN UNNEST(@identity_hashes)"
+const orderBySequenceNumberSQL string = " ORDER BY s.SequenceNumber"
+const selectLeafAtSequenceNumberSQL string = selectLeavesSQL + " AND s.SequenceNumber=@sequence_number"
+const selectPreorderedLeavesSQL string = selectLeavesSQL + ` AND s.SequenceNumber>=@tree_size
+		 ORDER BY s.SequenceNumber LIMIT @limit`
+
+const selectQueuedLeavesSQL string = `SELECT u.Bucket, u.QueueTimestamp, u.LeafHash, u.MessageId, u.SignedEntryTimestamp, l.TheData
+		 FROM Unsequenced u JOIN LeafData l ON l.TreeId=u.TreeId AND l.LeafHash=u.LeafHash
+		 WHERE u.TreeId=@tree_id AND IFNULL(u.NotBefore, 0)<=@now AND u.QueueTimestamp<@queued_before`
+
+// Entries queued before priorities were added have a NULL Priority, which is the default of 0
+const orderByQueuePrioritySQL string = " ORDER BY IFNULL(u.Priority, 0) DESC, u.QueueTimestamp LIMIT @limit"
+
+// Overdue entries are dequeued the longest queued first
+const orderByQueueTimestampSQL string = " ORDER BY u.QueueTimestamp LIMIT @limit"
+
+// Queued leaves are claimed by the MessageId of their entry, @claim_keys are those held by
+// another owner or by the one dequeuing
+const claimableLeavesSQL string = " AND u.MessageId NOT IN UNNEST(@claim_keys)"
+const claimedLeavesSQL string = " AND u.MessageId IN UNNEST(@claim_keys)"
+const selectQueuedLeafSQL string = `SELECT u.SignedEntryTimestamp, l.TheData
+		 FROM Unsequenced u JOIN LeafData l ON l.TreeId=u.TreeId AND l.LeafHash=u.LeafHash
+		 WHERE u.TreeId=@tree_id AND u.LeafHash=@leaf_hash LIMIT 1`
+const selectQueuedLeafCountSQL string = "SELECT COUNT(*) FROM Unsequenced WHERE TreeId=@tree_id"
+const selectAnyQueuedLeafSQL string = "SELECT 1 FROM Unsequenced WHERE TreeId=@tree_id LIMIT 1"
+const selectOldestQueuedLeavesSQL string = `SELECT u.QueueTimestamp, u.LeafHash, u.MessageId FROM Unsequenced u
+		 WHERE u.TreeId=@tree_id AND IFNULL(u.NotBefore, 0)<=@now ORDER BY u.QueueTimestamp LIMIT @limit`
+
+// Finds a leaf that's queued or sequenced, to stop it being queued again in a log that
+// doesn't allow duplicates
+const selectLeafInLogSQL string = `SELECT LeafHash FROM Unsequenced WHERE TreeId=@tree_id AND LeafHash=@leaf_hash
+		 UNION ALL
+		 SELECT LeafHash FROM SequencedLeafData WHERE TreeId=@tree_id AND LeafHash=@leaf_hash
+		 LIMIT 1`
+
+// Finds the leaf following on from the tree in a pre-ordered log
+const selectNextPreorderedLeafSQL string = `SELECT 1 FROM SequencedLeafData s
+		 WHERE s.TreeId=@tree_id AND s.SequenceNumber=IFNULL(
+		   (SELECT h.TreeSize FROM TreeHead h WHERE h.TreeId=@tree_id ORDER BY h.TreeRevision DESC LIMIT 1), 0)`
+
+const selectSignedLogRootSQL string = `SELECT TreeHeadTimestamp, TreeSize, RootHash, TreeRevision, RootSignature, Metadata
+		 FROM TreeHead WHERE TreeId=@tree_id`
+const selectLatestSignedLogRootSQL string = selectSignedLogRootSQL + " ORDER BY TreeRevision DESC LIMIT 1"
+const selectSignedLogRootByTimestampSQL string = selectSignedLogRootSQL + ` AND TreeHeadTimestamp=@timestamp
+		 ORDER BY TreeRevision DESC LIMIT 1`
+
+const selectCosignaturesSQL string = `SELECT WitnessId, Signature FROM Cosignature
+		 WHERE TreeId=@tree_id AND TreeHeadTimestamp=@timestamp ORDER BY WitnessId`
+
+const selectSequencerCheckpointSQL string = "SELECT TreeRevision, CompactTree FROM SequencerCheckpoint WHERE TreeId=@tree_id"
+
+const selectQueueClaimsSQL string = "SELECT ClaimKey, Owner, ExpiryNanos FROM QueueClaim WHERE TreeId=@tree_id"
+
+var leafDataColumns = []string{"TreeId", "LeafHash", "TheData", "LeafIdentityHash"}
+var sequencedLeafDataColumns = []string{"TreeId", "SequenceNumber", "LeafHash", "SignedEntryTimestamp"}
+var unsequencedColumns = []string{"TreeId", "Bucket", "QueueTimestamp", "LeafHash", "MessageId", "SignedEntryTimestamp", "Priority", "NotBefore"}
+var treeHeadColumns = []string{"TreeId", "TreeRevision", "TreeHeadTimestamp", "TreeSize", "RootHash", "RootSignature", "Metadata"}
+var cosignatureColumns = []string{"TreeId", "TreeHeadTimestamp", "WitnessId", "Signature"}
+var sequencerCheckpointColumns = []string{"TreeId", "TreeRevision", "CompactTree"}
+var queueClaimColumns = []string{"TreeId", "ClaimKey", "Owner", "ExpiryNanos"}
+
+// queueBuckets is how many buckets each log's queue is spread over. It can be changed as
+// leaves are dequeued from all buckets.
+const queueBuckets = 16
+
+// errLogRevisionConflict is returned when committing a transaction that writes a log revision
+// which another transaction has already written.
+var errLogRevisionConflict = errors.New("cloudspanner: log revision has already been written")
+
+// queueBucket returns the bucket of Unsequenced that a leaf is queued in
+func queueBucket(leafHash trillian.Hash) int64 {
+	return int64(leafHash[0]) % queueBuckets
+}
+
+// sequenceClaimKey returns the key in QueueClaim of the claim on the leaf of a pre-ordered log
+// at seq
+func sequenceClaimKey(seq int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seq))
+	return key
+}
+
+type spannerLogStorage struct {
+	*spannerTreeStorage
+
+	logID           trillian.LogID
+	allowDuplicates bool
+	treeType        trillian.TreeType
+	readOnly        bool
+}
+
+func newLogStorage(id trillian.LogID, client *spanner.Client) (storage.LogStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, client, cache.PopulateLogSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+	ts.revisionConflict = errLogRevisionConflict
+
+	s := spannerLogStorage{
+		spannerTreeStorage: ts,
+		logID:              id,
+		treeType:           trillian.TreeType_LOG,
+	}
+
+	// TODO: This should not default but it would currently complicate testing and can be
+	// implemented later when the create tree API has been defined.
+	var treeType string
+
+	err = queryRow(context.Background(), client.Single(), spanner.Statement{
+		SQL:    selectTreePropertiesSQL,
+		Params: map[string]interface{}{"tree_id": id.TreeID},
+	}, &s.allowDuplicates, &treeType, &s.readOnly)
+
+	if err == errNoRows {
+		glog.Warningf("*** Opening storage for log: %v but it has no params configured ***", id)
+	} else if err != nil {
+		glog.Warningf("Failed to get trees row for id %v: %s", id, err)
+		return nil, err
+	}
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		s.treeType = trillian.TreeType(v)
+	}
+
+	return &s, nil
+}
+
+// TreeType returns the type the log was created with.
+func (s *spannerLogStorage) TreeType() trillian.TreeType {
+	return s.treeType
+}
+
+func (s *spannerLogStorage) beginInternal(ctx context.Context) (*logTX, error) {
+	tx := &logTX{
+		treeTX:   s.beginTreeTx(ctx),
+		ls:       s,
+		dequeued: make(map[string][]queuedEntry),
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.treeTX.writeRevision = root.TreeRevision + 1
+	tx.treeSize = root.TreeSize
+
+	return tx, nil
+}
+
+func (s *spannerLogStorage) Begin(ctx context.Context) (storage.LogTX, error) {
+	// Reject attempts to start a writable transaction in read only mode. Anything that
+	// doesn't write is a part of Snapshot so is still available via that API.
+	if s.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+
+	tx, err := s.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (s *spannerLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := s.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// SnapshotForTree starts a read-only transaction pinned to the revision of the first tree
+// with a root that's at least treeSize. Leaves are only read below the size of that tree so
+// later revisions aren't seen.
+func (s *spannerLogStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	tx := &logTX{
+		treeTX: s.beginTreeTx(ctx),
+		ls:     s,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rev, size, err := tx.getTreeRevisionCoveringSize(ctx, treeSize)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to find revision for tree size %d: %s", treeSize, err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = rev
+	tx.treeSize = size
+
+	return tx, nil
+}
+
+// queuedEntry is the key of a dequeued leaf's row in Unsequenced, so that it can be removed
+// once the leaf has been sequenced
+type queuedEntry struct {
+	bucket         int64
+	queueTimestamp int64
+	messageID      []byte
+}
+
+type logTX struct {
+	treeTX
+	ls *spannerLogStorage
+
+	// treeSize is the size of the tree when the transaction started. Leaves of a pre-ordered
+	// log at and above it haven't been integrated so aren't read.
+	treeSize int64
+	// dequeued are the queue entries of the leaves returned by DequeueLeaves, by leaf hash
+	dequeued map[string][]queuedEntry
+	// sequenced are the writes of UpdateSequencedLeaves, which are only committed if a root
+	// was stored through the transaction
+	sequenced  []*spanner.Mutation
+	rootStored bool
+}
+
+// Commit applies the transaction's writes. Leaves are only sequenced along with a root that
+// includes them, otherwise they stay queued.
+func (t *logTX) Commit() error {
+	if t.rootStored {
+		t.addRevisionMutations(t.sequenced...)
+	}
+
+	return t.treeTX.Commit()
+}
+
+func (t *logTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *logTX) TreeSize() int64 {
+	return t.treeSize
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	leaves, _, err := t.dequeueLeaves(ctx, limit, nowNanos, math.MaxInt64, claimableLeavesSQL+orderByQueuePrioritySQL, nil, func([]byte) bool { return true })
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		t.recordQueuedLeaves(ctx)
+	}
+
+	return leaves, nil
+}
+
+func (t *logTX) DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error) {
+	// The leaves of a pre-ordered log are integrated in order, so none are overdue
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, nil
+	}
+
+	leaves, _, err := t.dequeueLeaves(ctx, limit, nowNanos, queuedBeforeNanos, claimableLeavesSQL+orderByQueueTimestampSQL, nil, func([]byte) bool { return true })
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.recordQueuedLeaves(ctx)
+
+	return leaves, nil
+}
+
+// ClaimLeaves claims queued leaves for claim.Owner, see storage.LeafClaimer. The transaction
+// reads the claims and the queue, so it conflicts with any other claiming the same leaves.
+func (t *logTX) ClaimLeaves(ctx context.Context, claim storage.LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error) {
+	claims, err := t.readQueueClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leaves already held by the owner are returned again, so only those held by others are
+	// left out of the query
+	var held [][]byte
+	for key, c := range claims {
+		if c.Owner != claim.Owner && c.ExpiryNanos >= nowNanos {
+			held = append(held, []byte(key))
+		}
+	}
+
+	claimable := func(key []byte) bool {
+		c, ok := claims[string(key)]
+		return !ok || c.Owner == claim.Owner || c.ExpiryNanos < nowNanos
+	}
+
+	leaves, keys, err := t.dequeueLeaves(ctx, limit, nowNanos, math.MaxInt64, claimableLeavesSQL+orderByQueuePrioritySQL, held, claimable)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		t.addMutations(spanner.InsertOrUpdate("QueueClaim", queueClaimColumns,
+			[]interface{}{t.ls.logID.TreeID, key, claim.Owner, claim.ExpiryNanos}))
+	}
+
+	return leaves, nil
+}
+
+// DequeueClaimedLeaves dequeues leaves claimed by owner, see storage.LeafClaimer. Claims are
+// removed along with the queue entries of the leaves once they're sequenced.
+func (t *logTX) DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error) {
+	claims, err := t.readQueueClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var owned [][]byte
+	for key, c := range claims {
+		if c.Owner == owner {
+			owned = append(owned, []byte(key))
+		}
+	}
+
+	// Whether the claims have expired doesn't matter, they're the owner's until taken. The
+	// leaves could be integrated when they were claimed, so their not-before times have passed.
+	leaves, _, err := t.dequeueLeaves(ctx, limit, math.MaxInt64, math.MaxInt64, claimedLeavesSQL+orderByQueuePrioritySQL, owned, func(key []byte) bool {
+		c, ok := claims[string(key)]
+		return ok && c.Owner == owner
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		t.recordQueuedLeaves(ctx)
+	}
+
+	return leaves, nil
+}
+
+// readQueueClaims returns the claims on the log's queued leaves, by claim key
+func (t *logTX) readQueueClaims(ctx context.Context) (map[string]storage.LeafClaim, error) {
+	claims := make(map[string]storage.LeafClaim)
+
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectQueueClaimsSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID},
+	}).Do(func(row *spanner.Row) error {
+		var key []byte
+		var claim storage.LeafClaim
+
+		if err := row.Columns(&key, &claim.Owner, &claim.ExpiryNanos); err != nil {
+			return err
+		}
+
+		claims[string(key)] = claim
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read queue claims: %s", err)
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (t *logTX) OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return 0, nil
+	}
+
+	// Entries dequeued by the transaction are only removed when it's committed, so enough are
+	// read to skip past them
+	var oldest int64
+
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectOldestQueuedLeavesSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "now": nowNanos, "limit": int64(t.dequeuedCount() + 1)},
+	}).Do(func(row *spanner.Row) error {
+		var e queuedEntry
+		var leafHash []byte
+
+		if oldest != 0 {
+			return nil
+		}
+
+		if err := row.Columns(&e.queueTimestamp, &leafHash, &e.messageID); err != nil {
+			return err
+		}
+
+		if !t.isDequeued(leafHash, e) {
+			oldest = e.queueTimestamp
+		}
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to find oldest queued leaf: %s", err)
+		return 0, err
+	}
+
+	return oldest, nil
+}
+
+// dequeuedCount returns how many queue entries the transaction has dequeued
+func (t *logTX) dequeuedCount() int {
+	n := 0
+	for _, entries := range t.dequeued {
+		n += len(entries)
+	}
+	return n
+}
+
+// isDequeued returns whether the transaction has dequeued the entry of leafHash at e's
+// timestamp with e's message ID
+func (t *logTX) isDequeued(leafHash []byte, e queuedEntry) bool {
+	for _, d := range t.dequeued[string(leafHash)] {
+		if d.queueTimestamp == e.queueTimestamp && bytes.Equal(d.messageID, e.messageID) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordQueuedLeaves updates the queue depth metric with the number of leaves queued,
+// including those just dequeued as they're only removed when the transaction is committed.
+// Failing to count them isn't an error for the caller.
+func (t *logTX) recordQueuedLeaves(ctx context.Context) {
+	var queued int64
+
+	err := queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectQueuedLeafCountSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID},
+	}, &queued)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to count queued leaves: %s", err)
+		return
+	}
+
+	storage.QueuedLeaves.Set(float64(queued), "cloudspanner", strconv.FormatInt(t.ls.logID.TreeID, 10))
+}
+
+// dequeueLeaves returns up to limit queued leaves that can be integrated at nowNanos and were
+// queued before queuedBeforeNanos, along with the keys of their claims. Leaves are selected by
+// filter, one of the claim SQL fragments followed by one of the orderings, with claimKeys as
+// @claim_keys. Entries the transaction has already dequeued are skipped, since they stay in
+// the table until it's committed. The leaves of a pre-ordered log have to be integrated in
+// order so they're returned up to the first that take doesn't accept.
+func (t *logTX) dequeueLeaves(ctx context.Context, limit int, nowNanos, queuedBeforeNanos int64, filter string, claimKeys [][]byte, take func(key []byte) bool) ([]trillian.LogLeaf, [][]byte, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return t.dequeueSequencedLeaves(ctx, limit, take)
+	}
+
+	if claimKeys == nil {
+		claimKeys = [][]byte{}
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	var keys [][]byte
+
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectQueuedLeavesSQL + filter,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "claim_keys": claimKeys, "now": nowNanos, "queued_before": queuedBeforeNanos, "limit": int64(limit + t.dequeuedCount())},
+	}).Do(func(row *spanner.Row) error {
+		var e queuedEntry
+		var leafHash, signedEntryTimestampBytes, payload []byte
+
+		if err := row.Columns(&e.bucket, &e.queueTimestamp, &leafHash, &e.messageID, &signedEntryTimestampBytes, &payload); err != nil {
+			logging.Warningf(ctx, "Error scanning work rows: %s", err)
+			return err
+		}
+
+		if len(leafHash) != t.ts.hashSizeBytes {
+			return errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		if len(leaves) == limit || t.isDequeued(leafHash, e) || !take(e.messageID) {
+			return nil
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(ctx, signedEntryTimestampBytes)
+
+		if err != nil {
+			return err
+		}
+
+		leaf := trillian.LogLeaf{
+			Leaf: trillian.Leaf{
+				LeafHash:  leafHash,
+				LeafValue: payload,
+			},
+			SignedEntryTimestamp: signedEntryTimestamp,
+			SequenceNumber:       0,
+			QueueTimestampNanos:  e.queueTimestamp,
+		}
+		leaves = append(leaves, leaf)
+		keys = append(keys, e.messageID)
+		t.dequeued[string(leafHash)] = append(t.dequeued[string(leafHash)], e)
+
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to select rows for work: %s", err)
+		return nil, nil, err
+	}
+
+	return leaves, keys, nil
+}
+
+// dequeueSequencedLeaves returns the leaves added to a pre-ordered log that follow on from the
+// current tree size, stopping at the first missing sequence number or the first that take
+// doesn't accept.
+func (t *logTX) dequeueSequencedLeaves(ctx context.Context, limit int, take func(key []byte) bool) ([]trillian.LogLeaf, [][]byte, error) {
+	found, err := t.readLeaves(ctx, spanner.Statement{
+		SQL:    selectPreorderedLeavesSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "tree_size": t.treeSize, "limit": int64(limit)},
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to select rows for work: %s", err)
+		return nil, nil, err
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	var keys [][]byte
+
+	for _, leaf := range found {
+		// The tree can only grow up to a gap, the leaves after it wait until it's filled
+		if leaf.SequenceNumber != t.treeSize+int64(len(leaves)) {
+			break
+		}
+
+		key := sequenceClaimKey(leaf.SequenceNumber)
+		if !take(key) {
+			break
+		}
+
+		// The sequencer only needs the hash, the leaf value stays in LeafData
+		leaf.LeafValue = nil
+		leaves = append(leaves, leaf)
+		keys = append(keys, key)
+	}
+
+	return leaves, keys, nil
+}
+
+// messageID returns the ID of a queued copy of a leaf. Message ids only need to guard against
+// duplicates for the time that entries are in the unsequenced queue, which should be short,
+// but we'll still use a strong hash.
+func (t *logTX) messageID(ctx context.Context, leafHash trillian.Hash) ([]byte, error) {
+	hasher := sha256.New()
+
+	// We use a fixed zero message id if the log disallows duplicates otherwise a random one
+	messageIdBytes := make([]byte, 8)
+
+	if t.ls.allowDuplicates {
+		if _, err := rand.Read(messageIdBytes); err != nil {
+			logging.Warningf(ctx, "Failed to get a random message id: %s", err)
+			return nil, err
+		}
+	}
+
+	hasher.Write(messageIdBytes)
+	hasher.Write(t.ls.logID.LogID)
+	hasher.Write(leafHash)
+	return hasher.Sum(nil), nil
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Queued leaf cannot have an empty signature")
+		}
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	// The leaves of this batch that will be queued, by hash. Nothing is written until the
+	// transaction is committed so repeats within it have to be found here.
+	batchLeaves := make(map[string]*trillian.LogLeaf)
+	queueTimestamp := time.Now().UnixNano()
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		// If the log doesn't allow duplicates then resubmitting a leaf returns the copy that's
+		// already there.
+		if !t.ls.allowDuplicates {
+			if first, ok := batchLeaves[string(leaf.LeafHash)]; ok {
+				existingLeaves[i] = &trillian.LogLeaf{
+					Leaf:                 trillian.Leaf{LeafHash: first.LeafHash, LeafValue: first.LeafValue, LeafIdentityHash: first.LeafIdentityHash},
+					SignedEntryTimestamp: first.SignedEntryTimestamp,
+					SequenceNumber:       -1,
+				}
+				continue
+			}
+
+			existing, err := t.getExistingLeaf(ctx, leaf.LeafHash)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				existingLeaves[i] = existing
+				continue
+			}
+
+			batchLeaves[string(leaf.LeafHash)] = leaf
+		}
+
+		messageID, err := t.messageID(ctx, leaf.LeafHash)
+
+		if err != nil {
+			return nil, err
+		}
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Leaves queued in the same batch are kept in order by their timestamps
+		ms := []*spanner.Mutation{
+			spanner.InsertOrUpdate("LeafData", leafDataColumns, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, []byte(leaf.LeafIdentityHash)}),
+			spanner.Insert("Unsequenced", unsequencedColumns, []interface{}{t.ls.logID.TreeID, queueBucket(leaf.LeafHash),
+				queueTimestamp + int64(i), []byte(leaf.LeafHash), messageID, signedTimestampBytes, int64(leaf.Priority), leaf.NotBeforeNanos}),
+		}
+
+		if t.ls.allowDuplicates {
+			t.addMutations(ms...)
+			continue
+		}
+
+		// The leaf could have been queued by another transaction since this one's snapshot
+		// was read, in which case that copy is kept and this one dropped
+		leafHash := []byte(leaf.LeafHash)
+		t.addConditionalWrite(func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+			var found []byte
+			err := queryRow(ctx, rtx, spanner.Statement{
+				SQL:    selectLeafInLogSQL,
+				Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "leaf_hash": leafHash},
+			}, &found)
+
+			if err == errNoRows {
+				return rtx.BufferWrite(ms)
+			}
+
+			return err
+		})
+	}
+
+	return existingLeaves, nil
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrNotPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Sequenced leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SequenceNumber < 0 {
+			return nil, fmt.Errorf("Sequenced leaf must have a sequence number >= 0, got %d", leaf.SequenceNumber)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Sequenced leaf cannot have an empty signature")
+		}
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	batchLeaves := make(map[int64]*trillian.LogLeaf)
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		if first, ok := batchLeaves[leaf.SequenceNumber]; ok {
+			existingLeaves[i] = first
+			continue
+		}
+
+		// A position can only be filled once, whether or not it has been integrated yet
+		existing, err := t.getLeafAtSequenceNumber(ctx, leaf.SequenceNumber)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			existingLeaves[i] = existing
+			continue
+		}
+
+		batchLeaves[leaf.SequenceNumber] = leaf
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// If the position is filled concurrently then none of the batch is added
+		seq := leaf.SequenceNumber
+		ms := []*spanner.Mutation{
+			spanner.InsertOrUpdate("LeafData", leafDataColumns, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, []byte(leaf.LeafIdentityHash)}),
+			spanner.Insert("SequencedLeafData", sequencedLeafDataColumns, []interface{}{t.ls.logID.TreeID, seq,
+				[]byte(leaf.LeafHash), signedTimestampBytes}),
+		}
+		t.addConditionalWrite(func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+			exists, err := rowExists(ctx, rtx, "SequencedLeafData", spanner.Key{t.ls.logID.TreeID, seq}, "LeafHash")
+
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return fmt.Errorf("cloudspanner: a leaf was added concurrently at sequence number %d", seq)
+			}
+
+			return rtx.BufferWrite(ms)
+		})
+	}
+
+	return existingLeaves, nil
+}
+
+// readLeaves reads the leaves selected by statement, which is one of the selectLeaves queries
+func (t *logTX) readLeaves(ctx context.Context, statement spanner.Statement) ([]trillian.LogLeaf, error) {
+	leaves := make([]trillian.LogLeaf, 0)
+
+	err := t.stx.Query(ctx, statement).Do(func(row *spanner.Row) error {
+		var seq int64
+		var leafHash, signedTimestampBytes, leafValue, identityHash []byte
+
+		if err := row.Columns(&seq, &leafHash, &signedTimestampBytes, &leafValue, &identityHash); err != nil {
+			return err
+		}
+
+		if got, want := len(leafHash), t.ts.hashSizeBytes; got != want {
+			return fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(ctx, signedTimestampBytes)
+
+		if err != nil {
+			return err
+		}
+
+		leaves = append(leaves, trillian.LogLeaf{
+			Leaf: trillian.Leaf{
+				LeafHash:         leafHash,
+				LeafValue:        leafValue,
+				LeafIdentityHash: identityHash,
+			},
+			SignedEntryTimestamp: signedEntryTimestamp,
+			SequenceNumber:       seq,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read leaves: %s", err)
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+// getLeafAtSequenceNumber returns the leaf that has been added to a pre-ordered log at seq,
+// or nil if there isn't one.
+func (t *logTX) getLeafAtSequenceNumber(ctx context.Context, seq int64) (*trillian.LogLeaf, error) {
+	leaves, err := t.readLeaves(ctx, spanner.Statement{
+		SQL:    selectLeafAtSequenceNumberSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "sequence_number": seq},
+	})
+
+	if err != nil || len(leaves) == 0 {
+		return nil, err
+	}
+
+	return &leaves[0], nil
+}
+
+// getExistingLeaf returns the leaf in the log with leafHash, or nil if there isn't one. If the
+// leaf has been sequenced more than once the earliest copy is returned.
+func (t *logTX) getExistingLeaf(ctx context.Context, leafHash trillian.Hash) (*trillian.LogLeaf, error) {
+	sequenced, err := t.GetLeavesByHash(ctx, []trillian.Hash{leafHash}, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sequenced) > 0 {
+		return &sequenced[0], nil
+	}
+
+	var signedTimestampBytes, leafValue []byte
+
+	err = queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectQueuedLeafSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "leaf_hash": []byte(leafHash)},
+	}, &signedTimestampBytes, &leafValue)
+
+	if err == errNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to look up queued leaf: %s", err)
+		return nil, err
+	}
+
+	signedEntryTimestamp, err := decodeSignedTimestamp(ctx, signedTimestampBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.LogLeaf{
+		Leaf:                 trillian.Leaf{LeafHash: leafHash, LeafValue: leafValue},
+		SignedEntryTimestamp: signedEntryTimestamp,
+		SequenceNumber:       -1,
+	}, nil
+}
+
+// GetSequencedLeafCount returns the size of the tree, as leaves are sequenced without gaps
+func (t *logTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	return t.treeSize, nil
+}
+
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	found, err := t.readLeaves(ctx, spanner.Statement{
+		SQL:    selectLeavesByIndexSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "tree_size": t.treeSize, "sequence_numbers": leaves},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(found) != len(leaves) {
+		return nil, fmt.Errorf("expected %d leaves, but saw %d", len(leaves), len(found))
+	}
+
+	// The leaves are returned in the order they were asked for
+	bySeq := make(map[int64]trillian.LogLeaf)
+	for _, leaf := range found {
+		bySeq[leaf.SequenceNumber] = leaf
+	}
+
+	ret := make([]trillian.LogLeaf, 0, len(leaves))
+	for _, seq := range leaves {
+		ret = append(ret, bySeq[seq])
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	hashes := make([][]byte, 0, len(leafHashes))
+	for _, h := range leafHashes {
+		hashes = append(hashes, []byte(h))
+	}
+
+	sql := selectLeavesByHashSQL
+	if orderBySequence {
+		sql += orderBySequenceNumberSQL
+	}
+
+	return t.readLeaves(ctx, spanner.Statement{
+		SQL:    sql,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "tree_size": t.treeSize, "leaf_hashes": hashes},
+	})
+}
+
+func (t *logTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	hashes := make([][]byte, 0, len(identityHashes))
+	for _, h := range identityHashes {
+		hashes = append(hashes, []byte(h))
+	}
+
+	sql := selectLeavesByIdentityHashSQL
+	if orderBySequence {
+		sql += orderBySequenceNumberSQL
+	}
+
+	return t.readLeaves(ctx, spanner.Statement{
+		SQL:    sql,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "tree_size": t.treeSize, "identity_hashes": hashes},
+	})
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	if start < 0 || count <= 0 {
+		return nil, fmt.Errorf("invalid leaf range start=%d count=%d", start, count)
+	}
+
+	leaves, err := t.readLeaves(ctx, spanner.Statement{
+		SQL:    selectLeavesByRangeSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "tree_size": t.treeSize, "start": start, "count": count},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Sequence numbers are allocated without gaps so anything else means we've lost data
+	for i, leaf := range leaves {
+		if got, want := leaf.SequenceNumber, start+int64(i); got != want {
+			return nil, fmt.Errorf("expected leaf with sequence number %d, but got %d", want, got)
+		}
+	}
+
+	return leaves, nil
+}
+
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	root, err := t.readSignedLogRoot(ctx, spanner.Statement{
+		SQL:    selectLatestSignedLogRootSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID},
+	})
+
+	// It's possible there are no roots for this tree yet
+	if err == errNoRows {
+		return trillian.SignedLogRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed root: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *logTX) GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error) {
+	root, err := t.readSignedLogRoot(ctx, spanner.Statement{
+		SQL:    selectSignedLogRootByTimestampSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "timestamp": timestampNanos},
+	})
+
+	if err == errNoRows {
+		return trillian.SignedLogRoot{}, storage.ErrLogRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed root %d: %v", timestampNanos, err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedLogRoot reads the single signed root selected by statement
+func (t *logTX) readSignedLogRoot(ctx context.Context, statement spanner.Statement) (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, metadata []byte
+	var rootSignature trillian.DigitallySigned
+
+	err := queryRow(ctx, t.stx, statement, &timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &metadata)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to unmarshall root signature: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return trillian.SignedLogRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		TreeRevision:   treeRevision,
+		Signature:      &rootSignature,
+		LogId:          t.ls.logID.LogID,
+		TreeSize:       treeSize,
+		Metadata:       metadata,
+	}, nil
+}
+
+func (t *logTX) GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error) {
+	cosignatures := []trillian.Cosignature{}
+
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectCosignaturesSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID, "timestamp": rootTimestampNanos},
+	}).Do(func(row *spanner.Row) error {
+		var witnessID string
+		var signatureBytes []byte
+
+		if err := row.Columns(&witnessID, &signatureBytes); err != nil {
+			return err
+		}
+
+		var signature trillian.DigitallySigned
+
+		if err := proto.Unmarshal(signatureBytes, &signature); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal cosignature from %s: %v", witnessID, err)
+			return err
+		}
+
+		cosignatures = append(cosignatures, trillian.Cosignature{WitnessId: witnessID, Signature: &signature})
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read cosignatures: %v", err)
+		return nil, err
+	}
+
+	return cosignatures, nil
+}
+
+func (t *logTX) AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error {
+	signatureBytes, err := proto.Marshal(cosignature.Signature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal cosignature: %v %v", cosignature.Signature, err)
+		return err
+	}
+
+	// A later cosignature from the same witness replaces the earlier one
+	t.addMutations(spanner.InsertOrUpdate("Cosignature", cosignatureColumns,
+		[]interface{}{t.ls.logID.TreeID, rootTimestampNanos, cosignature.WitnessId, signatureBytes}))
+
+	return nil
+}
+
+// StoreSignedLogRoot stores root when the transaction is committed, along with everything
+// else written at its revision.
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	t.addRevisionMutations(spanner.Insert("TreeHead", treeHeadColumns, []interface{}{t.ls.logID.TreeID, root.TreeRevision,
+		root.TimestampNanos, root.TreeSize, root.RootHash, signatureBytes, root.Metadata}))
+	t.rootStored = true
+
+	return nil
+}
+
+func (t *logTX) GetSequencerCheckpoint(ctx context.Context) (*storage.SequencerCheckpoint, error) {
+	var revision int64
+	var compactTree []byte
+
+	err := queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectSequencerCheckpointSQL,
+		Params: map[string]interface{}{"tree_id": t.ls.logID.TreeID},
+	}, &revision, &compactTree)
+
+	if err == errNoRows {
+		return nil, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read sequencer checkpoint: %v", err)
+		return nil, err
+	}
+
+	var p storage.CompactRangeProto
+	if err := proto.Unmarshal(compactTree, &p); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal sequencer checkpoint: %v", err)
+		return nil, err
+	}
+
+	return &storage.SequencerCheckpoint{TreeRevision: revision, CompactTree: &p}, nil
+}
+
+// StoreSequencerCheckpoint stores checkpoint when the transaction is committed, along with
+// everything else written at its revision.
+func (t *logTX) StoreSequencerCheckpoint(ctx context.Context, checkpoint storage.SequencerCheckpoint) error {
+	if checkpoint.CompactTree == nil {
+		return errors.New("sequencer checkpoint has no compact tree state")
+	}
+
+	compactTree, err := proto.Marshal(checkpoint.CompactTree)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal sequencer checkpoint: %v", err)
+		return err
+	}
+
+	t.addRevisionMutations(spanner.InsertOrUpdate("SequencerCheckpoint", sequencerCheckpointColumns,
+		[]interface{}{t.ls.logID.TreeID, checkpoint.TreeRevision, compactTree}))
+
+	return nil
+}
+
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+
+		// Leaves of a pre-ordered log were stored where they were added, only a claim on
+		// them has to be removed
+		if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+			t.sequenced = append(t.sequenced,
+				spanner.Delete("QueueClaim", spanner.Key{t.ls.logID.TreeID, sequenceClaimKey(leaf.SequenceNumber)}))
+			continue
+		}
+
+		signedTimestampBytes, err := encodeSignedTimestamp(ctx, leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return err
+		}
+
+		// The convention is that if leaf processing succeeds (by committing this tx)
+		// then the unsequenced entries for them are removed
+		entries := t.dequeued[string(leaf.LeafHash)]
+		if len(entries) == 0 {
+			return fmt.Errorf("sequenced leaf %d wasn't dequeued by this transaction", leaf.SequenceNumber)
+		}
+		e := entries[0]
+		t.dequeued[string(leaf.LeafHash)] = entries[1:]
+
+		t.sequenced = append(t.sequenced,
+			spanner.Insert("SequencedLeafData", sequencedLeafDataColumns, []interface{}{t.ls.logID.TreeID, leaf.SequenceNumber,
+				[]byte(leaf.LeafHash), signedTimestampBytes}),
+			spanner.Delete("Unsequenced", spanner.Key{t.ls.logID.TreeID, e.bucket, e.queueTimestamp, []byte(leaf.LeafHash), e.messageID}),
+			spanner.Delete("QueueClaim", spanner.Key{t.ls.logID.TreeID, e.messageID}))
+	}
+
+	return nil
+}
+
+// GetActiveLogIDs returns a list of the IDs of all configured logs
+func (t *logTX) GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error) {
+	logIDs, _, err := t.getActiveLogs(ctx)
+	return logIDs, err
+}
+
+// getActiveLogs returns the IDs of the logs that are active, and whether each is pre-ordered
+func (t *logTX) getActiveLogs(ctx context.Context) ([]trillian.LogID, []bool, error) {
+	logIDs := make([]trillian.LogID, 0, 0)
+	var preordered []bool
+
+	err := t.stx.Query(ctx, spanner.NewStatement(selectActiveLogsSQL)).Do(func(row *spanner.Row) error {
+		var treeID int64
+		var logID []byte
+		var treeType string
+
+		if err := row.Columns(&treeID, &logID, &treeType); err != nil {
+			return err
+		}
+
+		logIDs = append(logIDs, trillian.LogID{LogID: logID, TreeID: treeID})
+		preordered = append(preordered, treeType == trillian.TreeType_PREORDERED_LOG.String())
+		return nil
+	})
+
+	if err != nil {
+		return []trillian.LogID{}, nil, err
+	}
+
+	return logIDs, preordered, nil
+}
+
+// GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
+// that have queued unsequenced leaves that need to be integrated
+func (t *logTX) GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error) {
+	logIDs, preordered, err := t.getActiveLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]trillian.LogID, 0, 0)
+	for i, logID := range logIDs {
+		pending, err := t.hasPendingWork(ctx, logID.TreeID, preordered[i])
+		if err != nil {
+			return []trillian.LogID{}, err
+		}
+
+		if pending {
+			ret = append(ret, logID)
+		}
+	}
+
+	return ret, nil
+}
+
+// hasPendingWork returns whether a log has leaves that can be integrated. For a pre-ordered
+// log that's a leaf at the position following on from the tree.
+func (t *logTX) hasPendingWork(ctx context.Context, treeID int64, preordered bool) (bool, error) {
+	statement := spanner.Statement{
+		SQL:    selectAnyQueuedLeafSQL,
+		Params: map[string]interface{}{"tree_id": treeID},
+	}
+
+	if preordered {
+		statement.SQL = selectNextPreorderedLeafSQL
+	}
+
+	var found int64
+	err := queryRow(ctx, t.stx, statement, &found)
+
+	if err == errNoRows {
+		return false, nil
+	}
+
+	return err == nil, err
+}
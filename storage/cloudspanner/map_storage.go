@@ -0,0 +1,428 @@
+package cloudspanner
+
+import (
+	"cloud.google.com/go/spanner"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+const selectSignedMapRootSQL string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=@tree_id`
+const selectLatestSignedMapRootSQL string = selectSignedMapRootSQL + " ORDER BY MapRevision DESC LIMIT 1"
+const selectSignedMapRootByRevisionSQL string = selectSignedMapRootSQL + " AND MapRevision=@revision"
+
+// Selects the newest value at or before @revision of each key in one query
+const selectMapLeavesSQL string = `SELECT l.KeyHash, l.TheData FROM MapLeaf l
+		 WHERE l.TreeId=@tree_id AND l.KeyHash IN UNNEST(@key_hashes) AND l.MapRevision=(
+		   SELECT MAX(MapRevision) FROM MapLeaf
+		   WHERE TreeId=@tree_id AND KeyHash=l.KeyHash AND MapRevision<=@revision)`
+const selectMapLeafHistorySQL string = `SELECT MapRevision, TheData FROM MapLeaf
+		 WHERE TreeId=@tree_id AND KeyHash=@key_hash AND MapRevision>=@start AND MapRevision<=@end
+		 ORDER BY MapRevision`
+const selectMapKeysSQL string = `SELECT TheKey FROM MapKey
+		 WHERE TreeId=@tree_id AND TheKey>=@start ORDER BY TheKey LIMIT @count`
+const selectMapKeysBeforeSQL string = `SELECT TheKey FROM MapKey
+		 WHERE TreeId=@tree_id AND TheKey>=@start AND TheKey<@end ORDER BY TheKey LIMIT @count`
+const selectMapMutationsSQL string = `SELECT MapRevision, TheData FROM MapMutation
+		 WHERE TreeId=@tree_id AND MapRevision>=@start ORDER BY MapRevision LIMIT @count`
+
+var mapLeafColumns = []string{"TreeId", "KeyHash", "MapRevision", "TheData"}
+var mapHeadColumns = []string{"TreeId", "MapRevision", "MapHeadTimestamp", "RootHash", "RootSignature", "MapperData"}
+var mapKeyColumns = []string{"TreeId", "TheKey"}
+var mapMutationColumns = []string{"TreeId", "MapRevision", "TheData"}
+
+type spannerMapStorage struct {
+	*spannerTreeStorage
+
+	mapID trillian.MapID
+}
+
+func (s *spannerMapStorage) MapID() trillian.MapID {
+	return s.mapID
+}
+
+func newMapStorage(id trillian.MapID, client *spanner.Client) (storage.MapStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, client, cache.PopulateMapSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+	ts.revisionConflict = storage.ErrMapRevisionConflict
+
+	s := spannerMapStorage{
+		spannerTreeStorage: ts,
+		mapID:              id,
+	}
+
+	return &s, nil
+}
+
+func (s *spannerMapStorage) beginInternal(ctx context.Context) (*mapTX, error) {
+	tx := &mapTX{
+		treeTX: s.beginTreeTx(ctx),
+		ms:     s,
+	}
+
+	root, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.treeTX.writeRevision = root.MapRevision + 1
+	tx.latestRevision = root.MapRevision
+
+	return tx, nil
+}
+
+func (s *spannerMapStorage) Begin(ctx context.Context) (storage.MapTX, error) {
+	tx, err := s.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (s *spannerMapStorage) Snapshot(ctx context.Context) (storage.ReadOnlyMapTX, error) {
+	tx, err := s.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, err
+}
+
+// SnapshotForTree starts a read-only transaction pinned to a revision of the map, or to the
+// latest one if revision is < 0.
+func (s *spannerMapStorage) SnapshotForTree(ctx context.Context, revision int64) (storage.ReadOnlyMapTreeTX, error) {
+	tx := &mapTX{
+		treeTX: s.beginTreeTx(ctx),
+		ms:     s,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var root trillian.SignedMapRoot
+	var err error
+	if revision < 0 {
+		root, err = tx.LatestSignedMapRoot(ctx)
+	} else {
+		root, err = tx.GetSignedMapRoot(ctx, revision)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = root.MapRevision
+	tx.latestRevision = root.MapRevision
+
+	return tx, nil
+}
+
+type mapTX struct {
+	treeTX
+	ms *spannerMapStorage
+
+	// latestRevision is the revision of the latest root the transaction can read. A revision
+	// is committed along with its root so nothing after it has been written.
+	latestRevision int64
+}
+
+func (t *mapTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+// Set stores value when the transaction is committed. Committing fails with
+// ErrMapRevisionConflict if another transaction has written the same revision.
+func (t *mapTX) Set(ctx context.Context, keyHash trillian.Hash, value trillian.MapLeaf) error {
+	flatValue, err := proto.Marshal(&value)
+	if err != nil {
+		return err
+	}
+
+	t.addRevisionMutations(spanner.InsertOrUpdate("MapLeaf", mapLeafColumns,
+		[]interface{}{t.ms.mapID.TreeID, []byte(keyHash), t.writeRevision, flatValue}))
+
+	return nil
+}
+
+func (t *mapTX) Get(ctx context.Context, revision int64, keyHashes []trillian.Hash) ([]trillian.MapLeaf, error) {
+	if len(keyHashes) == 0 {
+		return nil, nil
+	}
+
+	if revision < 0 || revision > t.latestRevision {
+		revision = t.latestRevision
+	}
+
+	hashes := make([][]byte, 0, len(keyHashes))
+	for _, h := range keyHashes {
+		hashes = append(hashes, []byte(h))
+	}
+
+	ret := make([]trillian.MapLeaf, 0, len(keyHashes))
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectMapLeavesSQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID, "key_hashes": hashes, "revision": revision},
+	}).Do(func(row *spanner.Row) error {
+		var keyHash, flatData []byte
+
+		if err := row.Columns(&keyHash, &flatData); err != nil {
+			return err
+		}
+
+		if len(flatData) == 0 {
+			return nil
+		}
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return err
+		}
+		mapLeaf.KeyHash = keyHash
+		ret = append(ret, mapLeaf)
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map leaves: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetHistory(ctx context.Context, keyHash trillian.Hash, startRevision, endRevision int64) ([]storage.MapLeafRevision, error) {
+	if endRevision > t.latestRevision {
+		endRevision = t.latestRevision
+	}
+
+	ret := make([]storage.MapLeafRevision, 0)
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL: selectMapLeafHistorySQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID, "key_hash": []byte(keyHash),
+			"start": startRevision, "end": endRevision},
+	}).Do(func(row *spanner.Row) error {
+		var mapRevision int64
+		var flatData []byte
+
+		if err := row.Columns(&mapRevision, &flatData); err != nil {
+			return err
+		}
+
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return err
+		}
+		mapLeaf.KeyHash = keyHash
+		ret = append(ret, storage.MapLeafRevision{Revision: mapRevision, Leaf: mapLeaf})
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read history of map key: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetKeys(ctx context.Context, start, end trillian.Key, count int) ([]trillian.Key, error) {
+	// A nil start would be bound as NULL, which matches nothing
+	if start == nil {
+		start = trillian.Key{}
+	}
+
+	stmt := spanner.Statement{
+		SQL:    selectMapKeysSQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID, "start": []byte(start), "count": int64(count)},
+	}
+	if end != nil {
+		stmt.SQL = selectMapKeysBeforeSQL
+		stmt.Params["end"] = []byte(end)
+	}
+
+	ret := make([]trillian.Key, 0)
+	err := t.stx.Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var key []byte
+
+		if err := row.Columns(&key); err != nil {
+			return err
+		}
+
+		ret = append(ret, key)
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map keys: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetMutations(ctx context.Context, startRevision int64, count int) ([]trillian.MapMutation, error) {
+	ret := make([]trillian.MapMutation, 0)
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL:    selectMapMutationsSQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID, "start": startRevision, "count": int64(count)},
+	}).Do(func(row *spanner.Row) error {
+		var mapRevision int64
+		var flatData []byte
+
+		if err := row.Columns(&mapRevision, &flatData); err != nil {
+			return err
+		}
+
+		var mutation trillian.MapMutation
+		if err := proto.Unmarshal(flatData, &mutation); err != nil {
+			return err
+		}
+		mutation.MapRevision = mapRevision
+		ret = append(ret, mutation)
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map mutations: %v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) LatestSignedMapRoot(ctx context.Context) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, spanner.Statement{
+		SQL:    selectLatestSignedMapRootSQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID},
+	})
+
+	// It's possible there are no roots for this tree yet
+	if err == errNoRows {
+		return trillian.SignedMapRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed map root: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, spanner.Statement{
+		SQL:    selectSignedMapRootByRevisionSQL,
+		Params: map[string]interface{}{"tree_id": t.ms.mapID.TreeID, "revision": revision},
+	})
+
+	if err == errNoRows {
+		return trillian.SignedMapRoot{}, storage.ErrMapRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed map root at revision %d: %v", revision, err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedMapRoot reads the MapHead row selected by statement. It returns errNoRows if
+// there is no row.
+func (t *mapTX) readSignedMapRoot(ctx context.Context, statement spanner.Statement) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	if err := queryRow(ctx, t.stx, statement, &timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes); err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal root signature: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if len(mapperMetaBytes) != 0 {
+		mapperMeta = &trillian.MapperMetadata{}
+		if err := proto.Unmarshal(mapperMetaBytes, mapperMeta); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal Metadata; %v", err)
+			return trillian.SignedMapRoot{}, err
+		}
+	}
+
+	ret := trillian.SignedMapRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		MapRevision:    mapRevision,
+		Signature:      &rootSignature,
+		MapId:          t.ms.mapID.MapID,
+		Metadata:       mapperMeta,
+	}
+
+	return ret, nil
+}
+
+// StoreSignedMapRoot stores root when the transaction is committed, along with everything
+// else written at its revision. Committing fails with ErrMapRevisionConflict if another
+// transaction has written the same revision.
+func (t *mapTX) StoreSignedMapRoot(ctx context.Context, root trillian.SignedMapRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	var mapperMetaBytes []byte
+
+	if root.Metadata != nil {
+		mapperMetaBytes, err = proto.Marshal(root.Metadata)
+		if err != nil {
+			logging.Warningf(ctx, "Failed to marshal MetaData: %v %v", root.Metadata, err)
+			return err
+		}
+	}
+
+	t.addRevisionMutations(spanner.Insert("MapHead", mapHeadColumns, []interface{}{t.ms.mapID.TreeID, root.MapRevision,
+		root.TimestampNanos, root.RootHash, signatureBytes, mapperMetaBytes}))
+
+	return nil
+}
+
+// StoreKey adds key to the key index when the transaction is committed. Writing a key that's
+// already there leaves it as it was.
+func (t *mapTX) StoreKey(ctx context.Context, key trillian.Key) error {
+	t.addRevisionMutations(spanner.InsertOrUpdate("MapKey", mapKeyColumns, []interface{}{t.ms.mapID.TreeID, []byte(key)}))
+
+	return nil
+}
+
+func (t *mapTX) StoreMutation(ctx context.Context, mutation trillian.MapMutation) error {
+	flatData, err := proto.Marshal(&mutation)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal map mutation: %v", err)
+		return err
+	}
+
+	t.addRevisionMutations(spanner.Insert("MapMutation", mapMutationColumns, []interface{}{t.ms.mapID.TreeID, mutation.MapRevision, flatData}))
+
+	return nil
+}
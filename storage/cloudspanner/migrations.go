@@ -0,0 +1,370 @@
+package cloudspanner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/google/trillian/storage/migrate"
+	"golang.org/x/net/context"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// migrations upgrade the Cloud Spanner schema from one version to the next. spanner.sdl is
+// the schema at the latest version, so a migration added here must also change it.
+// Migrations are never changed once released, as databases may already have them applied.
+var migrations = []migrate.Migration{
+	{
+		Version:     1,
+		Description: "Initial schema",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                INT64 NOT NULL,
+  KeyId                 BYTES(MAX) NOT NULL,
+  TreeType              STRING(MAX) NOT NULL,
+  LeafHasherType        STRING(MAX) NOT NULL,
+  TreeHasherType        STRING(MAX) NOT NULL,
+  AllowsDuplicateLeaves BOOL NOT NULL,
+  TreeState             STRING(MAX) NOT NULL,
+  DisplayName           STRING(MAX) NOT NULL,
+  Description           STRING(MAX) NOT NULL,
+  CreateTimeMillis      INT64 NOT NULL,
+  UpdateTimeMillis      INT64 NOT NULL,
+  Deleted               BOOL NOT NULL,
+  DeleteTimeMillis      INT64 NOT NULL,
+  ReadOnlyRequests      BOOL NOT NULL,
+) PRIMARY KEY(TreeId)`,
+			`CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId               INT64 NOT NULL,
+  Requests             INT64 NOT NULL,
+  BytesIn              INT64 NOT NULL,
+  BytesOut             INT64 NOT NULL,
+  LeavesQueued         INT64 NOT NULL,
+  UpdateTimeMillis     INT64 NOT NULL,
+) PRIMARY KEY(TreeId),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               INT64 NOT NULL,
+  SubtreeId            BYTES(MAX) NOT NULL,
+  SubtreeRevision      INT64 NOT NULL,
+  Nodes                BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision DESC),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS TreeRevisionClaim(
+  TreeId               INT64 NOT NULL,
+  TreeRevision         INT64 NOT NULL,
+) PRIMARY KEY(TreeId, TreeRevision DESC),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               INT64 NOT NULL,
+  TreeRevision         INT64 NOT NULL,
+  TreeHeadTimestamp    INT64 NOT NULL,
+  TreeSize             INT64 NOT NULL,
+  RootHash             BYTES(MAX) NOT NULL,
+  RootSignature        BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, TreeRevision DESC),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE INDEX IF NOT EXISTS TreeHeadByTimestamp ON TreeHead(TreeId, TreeHeadTimestamp),
+  INTERLEAVE IN Trees`,
+			`CREATE INDEX IF NOT EXISTS TreeHeadBySize ON TreeHead(TreeId, TreeSize, TreeRevision DESC),
+  INTERLEAVE IN Trees`,
+			`CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               INT64 NOT NULL,
+  TreeHeadTimestamp    INT64 NOT NULL,
+  WitnessId            STRING(MAX) NOT NULL,
+  Signature            BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, TreeHeadTimestamp, WitnessId),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               INT64 NOT NULL,
+  LeafHash             BYTES(MAX) NOT NULL,
+  TheData              BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, LeafHash),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               INT64 NOT NULL,
+  SequenceNumber       INT64 NOT NULL,
+  LeafHash             BYTES(MAX) NOT NULL,
+  SignedEntryTimestamp BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, SequenceNumber),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE INDEX IF NOT EXISTS SequencedLeafDataByLeafHash ON SequencedLeafData(TreeId, LeafHash),
+  INTERLEAVE IN Trees`,
+			`CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               INT64 NOT NULL,
+  Bucket               INT64 NOT NULL,
+  QueueTimestamp       INT64 NOT NULL,
+  LeafHash             BYTES(MAX) NOT NULL,
+  MessageId            BYTES(MAX) NOT NULL,
+  SignedEntryTimestamp BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, Bucket, QueueTimestamp, LeafHash, MessageId),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE INDEX IF NOT EXISTS UnsequencedByLeafHash ON Unsequenced(TreeId, LeafHash),
+  INTERLEAVE IN Trees`,
+			`CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                INT64 NOT NULL,
+  KeyHash               BYTES(MAX) NOT NULL,
+  MapRevision           INT64 NOT NULL,
+  TheData               BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, KeyHash, MapRevision DESC),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               INT64 NOT NULL,
+  MapRevision          INT64 NOT NULL,
+  MapHeadTimestamp     INT64 NOT NULL,
+  RootHash             BYTES(MAX) NOT NULL,
+  RootSignature        BYTES(MAX) NOT NULL,
+  MapperData           BYTES(MAX),
+) PRIMARY KEY(TreeId, MapRevision DESC),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+			`CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               INT64 NOT NULL,
+  MapRevision          INT64 NOT NULL,
+  TheData              BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, MapRevision),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add tree head metadata",
+		Statements: []string{
+			"ALTER TABLE TreeHead ADD COLUMN Metadata BYTES(MAX)",
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add leaf identity hashes",
+		Statements: []string{
+			"ALTER TABLE LeafData ADD COLUMN LeafIdentityHash BYTES(MAX)",
+			`CREATE INDEX IF NOT EXISTS LeafDataByIdentityHash ON LeafData(TreeId, LeafIdentityHash),
+  INTERLEAVE IN Trees`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add map key index",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               INT64 NOT NULL,
+  TheKey               BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId, TheKey),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add sequencer checkpoints",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               INT64 NOT NULL,
+  TreeRevision         INT64 NOT NULL,
+  CompactTree          BYTES(MAX) NOT NULL,
+) PRIMARY KEY(TreeId),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add queue claims",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS QueueClaim(
+  TreeId               INT64 NOT NULL,
+  ClaimKey             BYTES(MAX) NOT NULL,
+  Owner                STRING(MAX) NOT NULL,
+  ExpiryNanos          INT64 NOT NULL,
+) PRIMARY KEY(TreeId, ClaimKey),
+  INTERLEAVE IN PARENT Trees ON DELETE CASCADE`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add queue priorities",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN Priority INT64",
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add queue not-before times",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN NotBefore INT64",
+		},
+	},
+}
+
+// The tables the migrations are recorded in are created with the admin API when they're
+// first needed
+const createSchemaVersionDDL string = `CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INT64 NOT NULL,
+  Description          STRING(MAX) NOT NULL,
+  AppliedTimeMillis    INT64 NOT NULL,
+) PRIMARY KEY(Version)`
+const selectSchemaVersionSQL string = "SELECT IFNULL(MAX(Version), 0) FROM SchemaVersion"
+
+// The migration lock is a row that only one process can hold at a time. It expires in case
+// the process holding it dies without removing it.
+const createSchemaLockDDL string = `CREATE TABLE IF NOT EXISTS SchemaLock(
+  Name                 STRING(MAX) NOT NULL,
+  Owner                STRING(MAX) NOT NULL,
+  ExpireTimeMillis     INT64 NOT NULL,
+) PRIMARY KEY(Name)`
+
+var schemaVersionColumns = []string{"Version", "Description", "AppliedTimeMillis"}
+var schemaLockColumns = []string{"Name", "Owner", "ExpireTimeMillis"}
+
+// schemaLockTTL is how long the migration lock lasts. All the migrations must be applied
+// within it.
+const schemaLockTTL = 10 * time.Minute
+
+// schemaLockRetry is how often a process waiting for the migration lock tries to take it
+const schemaLockRetry = time.Second
+
+// errSchemaLocked is returned by tryLock if another process holds the migration lock
+var errSchemaLocked = errors.New("cloudspanner: migration lock is held by another process")
+
+// ddlDatabase migrates a Cloud Spanner database, applying schema changes through the database
+// admin API and recording the version of its schema in a SchemaVersion table. Schema changes
+// can't be rolled back, so a migration that fails part way must be written so that applying
+// it again finishes it, e.g. with IF NOT EXISTS.
+type ddlDatabase struct {
+	database string
+	client   *spanner.Client
+}
+
+func newDDLDatabase(database string, client *spanner.Client) *ddlDatabase {
+	return &ddlDatabase{database: database, client: client}
+}
+
+// updateDDL applies statements to the schema, waiting until the change is complete
+func (d *ddlDatabase) updateDDL(ctx context.Context, statements ...string) error {
+	admin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	op, err := admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   d.database,
+		Statements: statements,
+	})
+	if err != nil {
+		return err
+	}
+
+	return op.Wait(ctx)
+}
+
+// Lock takes the migration lock, see migrate.Database
+func (d *ddlDatabase) Lock(ctx context.Context) (func(), error) {
+	if err := d.updateDDL(ctx, createSchemaLockDDL); err != nil {
+		return nil, err
+	}
+
+	ownerBytes := make([]byte, 16)
+	if _, err := rand.Read(ownerBytes); err != nil {
+		return nil, err
+	}
+	owner := hex.EncodeToString(ownerBytes)
+
+	for {
+		err := d.tryLock(ctx, owner)
+		if err == nil {
+			break
+		}
+
+		if err != errSchemaLocked {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("migrate: timed out waiting for the migration lock")
+		case <-time.After(schemaLockRetry):
+		}
+	}
+
+	return func() {
+		d.client.ReadWriteTransaction(context.Background(), func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+			holder, _, err := readSchemaLock(ctx, rtx)
+			if err != nil || holder != owner {
+				return err
+			}
+
+			return rtx.BufferWrite([]*spanner.Mutation{spanner.Delete("SchemaLock", spanner.Key{"migrate"})})
+		})
+	}, nil
+}
+
+// tryLock takes the migration lock for owner if it isn't held, or the process holding it has
+// let it expire
+func (d *ddlDatabase) tryLock(ctx context.Context, owner string) error {
+	_, err := d.client.ReadWriteTransaction(ctx, func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+		holder, expires, err := readSchemaLock(ctx, rtx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if holder != "" && holder != owner && now.UnixNano()/int64(time.Millisecond) < expires {
+			return errSchemaLocked
+		}
+
+		return rtx.BufferWrite([]*spanner.Mutation{spanner.InsertOrUpdate("SchemaLock", schemaLockColumns,
+			[]interface{}{"migrate", owner, now.Add(schemaLockTTL).UnixNano() / int64(time.Millisecond)})})
+	})
+
+	return err
+}
+
+// readSchemaLock returns the owner of the migration lock and when it expires, or an empty
+// owner if there's no lock
+func readSchemaLock(ctx context.Context, rtx *spanner.ReadWriteTransaction) (string, int64, error) {
+	row, err := rtx.ReadRow(ctx, "SchemaLock", spanner.Key{"migrate"}, []string{"Owner", "ExpireTimeMillis"})
+	switch {
+	case spanner.ErrCode(err) == codes.NotFound:
+		return "", 0, nil
+	case err != nil:
+		return "", 0, err
+	}
+
+	var owner string
+	var expires int64
+	if err := row.Columns(&owner, &expires); err != nil {
+		return "", 0, err
+	}
+
+	return owner, expires, nil
+}
+
+// Version returns the latest version recorded in the SchemaVersion table, creating the
+// table if it doesn't exist
+func (d *ddlDatabase) Version(ctx context.Context) (int, error) {
+	if err := d.updateDDL(ctx, createSchemaVersionDDL); err != nil {
+		return 0, err
+	}
+
+	var version int64
+	if err := queryRow(ctx, d.client.Single(), spanner.NewStatement(selectSchemaVersionSQL), &version); err != nil {
+		return 0, err
+	}
+
+	return int(version), nil
+}
+
+// Apply runs the statements of m and records its version, see migrate.Database. All the
+// statements are sent in one schema update, which Spanner applies in order.
+func (d *ddlDatabase) Apply(ctx context.Context, m migrate.Migration) error {
+	if err := d.updateDDL(ctx, m.Statements...); err != nil {
+		return err
+	}
+
+	_, err := d.client.Apply(ctx, []*spanner.Mutation{spanner.Insert("SchemaVersion", schemaVersionColumns,
+		[]interface{}{int64(m.Version), m.Description, time.Now().UnixNano() / int64(time.Millisecond)})})
+
+	return err
+}
@@ -0,0 +1,122 @@
+// Package cloudspanner stores trees in a Cloud Spanner database, for logs and maps hosted on
+// GCP that need more capacity, or availability, than a single SQL primary gives them.
+//
+// Reads made through a transaction come from a Spanner read-only transaction, so they all see
+// the database as it was when the first was made. Writes are kept until the transaction is
+// committed and then applied in a single read-write transaction, which also claims the tree
+// revision they're written at so that concurrent writers can't both write it.
+package cloudspanner
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	"golang.org/x/net/context"
+)
+
+// ProviderName is the name the Cloud Spanner storage system is registered under.
+const ProviderName = "cloudspanner"
+
+func init() {
+	if err := storage.RegisterProvider(ProviderName, newSpannerProvider); err != nil {
+		panic(err)
+	}
+}
+
+// parseDSN checks that dsn is the name of a database, of the form
+// projects/<project>/instances/<instance>/databases/<database>. Credentials are found by the
+// client library, see https://cloud.google.com/docs/authentication.
+func parseDSN(dsn string) (string, error) {
+	parts := strings.Split(dsn, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "instances" || parts[4] != "databases" {
+		return "", fmt.Errorf("cloudspanner: %q isn't of the form projects/P/instances/I/databases/D", dsn)
+	}
+
+	for _, part := range parts {
+		if len(part) == 0 {
+			return "", fmt.Errorf("cloudspanner: empty name in %q", dsn)
+		}
+	}
+
+	return dsn, nil
+}
+
+// spannerProvider creates Cloud Spanner backed storage for trees in a single database. The
+// storage it creates shares one client, which pools sessions with the database.
+type spannerProvider struct {
+	database string
+
+	mu     sync.Mutex
+	client *spanner.Client
+}
+
+func newSpannerProvider(dsn string) (storage.Provider, error) {
+	database, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spannerProvider{database: database}, nil
+}
+
+// getClient returns the client shared by all the storage, connecting to the database the
+// first time
+func (s *spannerProvider) getClient() (*spanner.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		client, err := spanner.NewClient(context.Background(), s.database)
+		if err != nil {
+			glog.Warningf("Could not connect to Cloud Spanner database %s, check config: %s", s.database, err)
+			return nil, err
+		}
+
+		s.client = client
+	}
+
+	return s.client, nil
+}
+
+func (s *spannerProvider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogStorage(id, client)
+}
+
+func (s *spannerProvider) MapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMapStorage(id, client)
+}
+
+func (s *spannerProvider) AdminStorage() (storage.AdminStorage, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &spannerAdminStorage{client: client}, nil
+}
+
+// Migrations returns the database shared by the storage and the migrations for its schema
+func (s *spannerProvider) Migrations() (migrate.Database, []migrate.Migration, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newDDLDatabase(s.database, client), migrations, nil
+}
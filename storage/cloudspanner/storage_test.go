@@ -0,0 +1,864 @@
+package cloudspanner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	"golang.org/x/net/context"
+)
+
+// These tests need a Cloud Spanner database loaded with spanner.sdl, e.g. in the emulator.
+// They're skipped if no database is given or it cannot be reached.
+var testDSNFlag = flag.String("cloudspanner_test_dsn", "", "database to use for tests, of the form projects/P/instances/I/databases/D")
+
+const leavesToInsert = 5
+
+var signedTimestamp = trillian.SignedEntryTimestamp{
+	TimestampNanos: 1234567890, LogId: []byte("sign"), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+var keyHash = []byte("A Key Hash")
+
+// Tests share the database with earlier runs, whose trees aren't removed, so each test uses
+// a new tree id
+var idMutex sync.Mutex
+var testTreeID = time.Now().UnixNano()
+
+func nextTreeID() int64 {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+	testTreeID++
+
+	return testTreeID
+}
+
+func TestParseDSN(t *testing.T) {
+	for _, test := range []struct {
+		dsn     string
+		wantErr bool
+	}{
+		{dsn: "projects/p/instances/i/databases/d"},
+		{dsn: "projects/my-project/instances/trillian/databases/trees"},
+		{dsn: "", wantErr: true},
+		{dsn: "projects/p/instances/i", wantErr: true},
+		{dsn: "projects/p/instances/i/databases/", wantErr: true},
+		{dsn: "projects//instances/i/databases/d", wantErr: true},
+		{dsn: "project/p/instance/i/database/d", wantErr: true},
+		{dsn: "projects/p/instances/i/databases/d/tables/t", wantErr: true},
+	} {
+		database, err := parseDSN(test.dsn)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseDSN(%q)=%v, want error", test.dsn, database)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseDSN(%q)=%v, want no error", test.dsn, err)
+			continue
+		}
+
+		if got, want := database, test.dsn; got != want {
+			t.Errorf("parseDSN(%q)=%s, want %s", test.dsn, got, want)
+		}
+	}
+}
+
+func TestMigrationsMatchSchema(t *testing.T) {
+	if err := migrate.Validate(migrations); err != nil {
+		t.Fatalf("Invalid migrations: %v", err)
+	}
+
+	schema, err := ioutil.ReadFile("spanner.sdl")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+
+	// Comments aren't sent to the database
+	var statements []string
+	for _, line := range strings.Split(string(schema), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			statements = append(statements, line)
+		}
+	}
+	stripped := strings.Join(statements, "\n")
+
+	// Every statement of the migrations is in the schema
+	for _, m := range migrations {
+		for _, statement := range m.Statements {
+			if !strings.Contains(stripped, statement) {
+				t.Errorf("Migration %d statement isn't in spanner.sdl: %s", m.Version, statement)
+			}
+		}
+	}
+}
+
+func TestProviderRegistered(t *testing.T) {
+	if _, err := storage.NewProvider(ProviderName, "projects/p/instances/i/databases/d"); err != nil {
+		t.Fatalf("Failed to create provider %s: %v", ProviderName, err)
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	nodesToStore := createSomeNodes()
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		tx.(*logTX).treeTX.writeRevision = 100
+
+		// Need to read nodes before attempting to write
+		if _, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
+			t.Fatalf("Failed to store nodes: %s", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		readNodes, err := tx.GetMerkleNodes(ctx, 100, nodeIDsToRead)
+		if err != nil {
+			t.Fatalf("Failed to retrieve nodes: %s", err)
+		}
+
+		if got, want := len(readNodes), len(nodesToStore); got != want {
+			t.Fatalf("Read back %d nodes but expected %d", got, want)
+		}
+
+		for i := range readNodes {
+			if !readNodes[i].NodeID.Equivalent(nodesToStore[i].NodeID) || !bytes.Equal(readNodes[i].Hash, nodesToStore[i].Hash) {
+				t.Fatalf("Read back node %v but expected %v", readNodes[i], nodesToStore[i])
+			}
+		}
+	}
+}
+
+func TestQueueAndDequeueLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 20)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if ids, err := tx.GetActiveLogIDsWithPendingWork(ctx); err != nil || !containsTreeID(ids, logID.TreeID) {
+			t.Fatalf("Expected log %d to have pending work, got: %v %v", logID.TreeID, ids, err)
+		}
+
+		leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if got, want := len(leaves), leavesToInsert; got != want {
+			t.Fatalf("Dequeued %d leaves but expected %d", got, want)
+		}
+
+		for i := range leaves {
+			leaves[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		// The leaves can only be read once there's a root that includes them
+		storeTestRoot(tx, leavesToInsert, t)
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected nothing to dequeue, got: %v %v", leaves, err)
+		}
+
+		count, err := tx.GetSequencedLeafCount(ctx)
+
+		if err != nil || count != leavesToInsert {
+			t.Fatalf("Expected %d sequenced leaves, got: %d %v", leavesToInsert, count, err)
+		}
+
+		leaves, err := tx.GetLeavesByIndex(ctx, []int64{0, 3})
+
+		if err != nil || len(leaves) != 2 {
+			t.Fatalf("Failed to get leaves by index: %v %v", leaves, err)
+		}
+
+		byHash, err := tx.GetLeavesByHash(ctx, []trillian.Hash{leaves[1].LeafHash}, true)
+
+		if err != nil || len(byHash) != 1 || byHash[0].SequenceNumber != leaves[1].SequenceNumber {
+			t.Fatalf("Failed to get leaf by hash: %v %v", byHash, err)
+		}
+
+		byRange, err := tx.GetLeavesByRange(ctx, 1, leavesToInsert)
+
+		if err != nil || int64(len(byRange)) != leavesToInsert-1 {
+			t.Fatalf("Failed to get leaves by range: %v %v", byRange, err)
+		}
+
+		for i, leaf := range byRange {
+			if got, want := leaf.SequenceNumber, int64(i+1); got != want {
+				t.Fatalf("Got leaf with sequence number %d in range but expected %d", got, want)
+			}
+		}
+	}
+}
+
+func TestUnpublishedLeavesNotRead(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 0)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		// Sequencing without storing a root doesn't publish the leaves, or remove them from
+		// the queue
+		tx := beginLogTx(s, t)
+
+		leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil || len(leaves) != leavesToInsert {
+			t.Fatalf("Failed to dequeue leaves: %v %v", leaves, err)
+		}
+
+		for i := range leaves {
+			leaves[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.GetLeavesByRange(ctx, 0, leavesToInsert); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected no leaves before a root was stored, got: %v %v", leaves, err)
+		}
+
+		if leaves, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(leaves) != leavesToInsert {
+			t.Fatalf("Expected the leaves to still be queued, got: %v %v", leaves, err)
+		}
+	}
+}
+
+func TestDequeueLeavesByPriority(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(4, 0)
+	for i, priority := range []int32{-1, 0, -1, 1} {
+		leaves[i].Priority = priority
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	dequeued, err := tx.DequeueLeaves(ctx, 3, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	// Higher priorities come first, then the oldest leaves of each priority
+	if len(dequeued) != 3 {
+		t.Fatalf("Dequeued %d leaves but expected 3", len(dequeued))
+	}
+	for i, want := range []int{3, 1, 0} {
+		if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+			t.Errorf("Dequeued leaf %d was %v but expected %v", i, dequeued[i], leaves[want])
+		}
+	}
+}
+
+func TestDequeueLeavesNotBefore(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(3, 0)
+	leaves[0].NotBeforeNanos = 2000
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	// The first leaf is held back until its time. Entries are only removed from the queue
+	// when a transaction commits, so each dequeue sees all of them.
+	for _, test := range []struct {
+		nowNanos int64
+		want     []int
+	}{
+		{nowNanos: 1000, want: []int{1, 2}},
+		{nowNanos: 2000, want: []int{0, 1, 2}},
+	} {
+		tx := beginLogTx(s, t)
+
+		dequeued, err := tx.DequeueLeaves(ctx, 10, test.nowNanos)
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if len(dequeued) != len(test.want) {
+			t.Fatalf("DequeueLeaves(%d) returned %d leaves but expected %d", test.nowNanos, len(dequeued), len(test.want))
+		}
+		for i, want := range test.want {
+			if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+				t.Errorf("DequeueLeaves(%d) leaf %d was %v but expected %v", test.nowNanos, i, dequeued[i], leaves[want])
+			}
+		}
+
+		tx.Rollback()
+	}
+}
+
+func TestOldestQueuedLeaf(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(3, 0)
+	// The last leaf can't be integrated yet so it isn't counted
+	leaves[2].NotBeforeNanos = math.MaxInt64
+
+	before := time.Now().UnixNano()
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	oldest, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || oldest < before || oldest > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v, want a time from %d to %d", oldest, err, before, now)
+	}
+
+	dequeued, err := tx.DequeueLeaves(ctx, 1, now)
+	if err != nil || len(dequeued) != 1 || dequeued[0].QueueTimestampNanos != oldest {
+		t.Fatalf("DequeueLeaves()=%v %v, want the leaf queued at %d", dequeued, err, oldest)
+	}
+
+	// Leaves the transaction dequeued aren't counted, though they're still in the table
+	next, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || next <= oldest || next > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v after dequeuing, want a time after %d up to %d", next, err, oldest, now)
+	}
+
+	// Nor are they dequeued again
+	dequeued, err = tx.DequeueLeaves(ctx, 1, now)
+	if err != nil || len(dequeued) != 1 || dequeued[0].QueueTimestampNanos != next {
+		t.Fatalf("DequeueLeaves()=%v %v, want the leaf queued at %d", dequeued, err, next)
+	}
+
+	if last, err := tx.OldestQueuedLeafNanos(ctx, now); err != nil || last != 0 {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v with only a held back leaf queued, want 0", last, err)
+	}
+}
+
+func TestClaimLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 0)); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	claim := func(owner string, expiry, now int64, limit int) int {
+		tx := beginLogTx(s, t)
+		leaves, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: owner, ExpiryNanos: expiry}, now, limit)
+		if err != nil {
+			t.Fatalf("Failed to claim leaves for %s: %v", owner, err)
+		}
+		commit(tx, t)
+		return len(leaves)
+	}
+
+	if got, want := claim("a", 200, 100, 2), 2; got != want {
+		t.Fatalf("a claimed %d leaves but expected %d", got, want)
+	}
+
+	// b can't take a's leaves until they expire
+	if got, want := claim("b", 200, 100, 99), leavesToInsert-2; got != want {
+		t.Fatalf("b claimed %d leaves but expected %d", got, want)
+	}
+
+	if got, want := claim("c", 300, 150, 99), 0; got != want {
+		t.Fatalf("c claimed %d leaves before the claims expired but expected %d", got, want)
+	}
+
+	if got, want := claim("c", 300, 250, 2), 2; got != want {
+		t.Fatalf("c claimed %d expired leaves but expected %d", got, want)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if leaves, err := tx.DequeueClaimedLeaves(ctx, "c", 99); err != nil || len(leaves) != 2 {
+			t.Fatalf("Expected to dequeue c's 2 leaves, got: %v %v", leaves, err)
+		}
+
+		if leaves, err := tx.DequeueClaimedLeaves(ctx, "d", 99); err != nil || len(leaves) != 0 {
+			t.Fatalf("Expected d to have nothing to dequeue, got: %v %v", leaves, err)
+		}
+	}
+}
+
+func TestQueueDuplicateLeafReturnsExisting(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	tx := beginLogTx(s, t)
+
+	leaves := createTestLeaves(1, 0)
+	existing, err := tx.QueueLeaves(ctx, append(leaves, leaves[0]))
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if existing[0] != nil || existing[1] == nil || !bytes.Equal(existing[1].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("Expected only the repeated leaf to be returned as existing, got: %v", existing)
+	}
+
+	commit(tx, t)
+
+	tx = beginLogTx(s, t)
+	defer tx.Commit()
+
+	existing, err = tx.QueueLeaves(ctx, leaves)
+
+	if err != nil || existing[0] == nil || existing[0].SequenceNumber != -1 {
+		t.Fatalf("Expected the queued leaf to be returned as existing, got: %v %v", existing, err)
+	}
+}
+
+func TestLogRevisionConflict(t *testing.T) {
+	ctx := context.Background()
+	logID := prepareTestLog(t)
+	s := prepareTestLogStorage(logID, t)
+
+	tx1 := beginLogTx(s, t)
+	tx2 := beginLogTx(s, t)
+
+	storeTestRoot(tx1, 0, t)
+	storeTestRoot(tx2, 0, t)
+
+	commit(tx1, t)
+
+	if err := tx2.Commit(); err != errLogRevisionConflict {
+		t.Fatalf("Committing a second root at the same revision returned %v, want %v", err, errLogRevisionConflict)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	// The root was stored at revision 1, the first revision of a new log
+	if got, want := tx.WriteRevision(), int64(2); got != want {
+		t.Fatalf("Expected write revision %d but got %d", want, got)
+	}
+
+	if _, err := tx.GetSignedLogRoot(ctx, 98765+1); err != nil {
+		t.Fatalf("Failed to read root by timestamp: %v", err)
+	}
+}
+
+func TestMapSetGetMultipleRevisions(t *testing.T) {
+	ctx := context.Background()
+	mapID := prepareTestMap(t)
+	s := prepareTestMapStorage(mapID, t)
+
+	numRevs := 3
+	values := make([]trillian.MapLeaf, numRevs)
+	for i := 0; i < numRevs; i++ {
+		values[i] = trillian.MapLeaf{
+			KeyHash:   keyHash,
+			LeafHash:  []byte(fmt.Sprintf("A Hash %d", i)),
+			LeafValue: []byte(fmt.Sprintf("A Value %d", i)),
+			ExtraData: []byte(fmt.Sprintf("Some Extra Data %d", i)),
+		}
+	}
+
+	for i := 0; i < numRevs; i++ {
+		tx, err := s.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin: %v", err)
+		}
+		if err := tx.Set(ctx, keyHash, values[i]); err != nil {
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[i], err)
+		}
+		root := trillian.SignedMapRoot{MapId: mapID.MapID, TimestampNanos: int64(i), MapRevision: tx.WriteRevision(),
+			RootHash: dummyHash(), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store root: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	for i := 0; i < numRevs; i++ {
+		tx, err := s.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin snapshot: %v", err)
+		}
+
+		readValues, err := tx.Get(ctx, int64(i+1), []trillian.Hash{keyHash})
+		if err != nil {
+			t.Fatalf("At rev %d failed to get %v:  %v", i+1, keyHash, err)
+		}
+		if got, want := len(readValues), 1; got != want {
+			t.Fatalf("At rev %d got %d values, expected %d", i+1, got, want)
+		}
+		if got, want := &readValues[0], &values[i]; !proto.Equal(got, want) {
+			t.Fatalf("At rev %d read back %v, but expected %v", i+1, got, want)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("At rev %d failed to commit: %v", i+1, err)
+		}
+	}
+}
+
+func TestMapRevisionConflict(t *testing.T) {
+	ctx := context.Background()
+	mapID := prepareTestMap(t)
+	s := prepareTestMapStorage(mapID, t)
+
+	tx1, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	tx2, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+
+	for _, tx := range []storage.MapTX{tx1, tx2} {
+		if err := tx.Set(ctx, keyHash, trillian.MapLeaf{KeyHash: keyHash, LeafValue: []byte("value")}); err != nil {
+			t.Fatalf("Failed to set %v: %v", keyHash, err)
+		}
+	}
+
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if err := tx2.Commit(); err != storage.ErrMapRevisionConflict {
+		t.Fatalf("Committing a second write at the same revision returned %v, want %v", err, storage.ErrMapRevisionConflict)
+	}
+}
+
+func TestAdminTreeLifecycle(t *testing.T) {
+	as, err := openTestProviderOrSkip(t).AdminStorage()
+	if err != nil {
+		t.Fatalf("Failed to open admin storage: %s", err)
+	}
+
+	atx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	tree, err := atx.CreateTree(&trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("TestAdminTreeLifecycle"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+		DisplayName:   "Lifecycle",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+
+	rtx, err := as.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	got, err := rtx.GetTree(tree.TreeId)
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+	if !proto.Equal(tree, got) {
+		t.Errorf("GetTree()=%v, want %v", got, tree)
+	}
+	if err := rtx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin snapshot: %v", err)
+	}
+
+	// Two updates of the same tree can't both be committed
+	atx1, _ := as.Begin(context.Background())
+	atx2, _ := as.Begin(context.Background())
+	for _, tx := range []storage.AdminTX{atx1, atx2} {
+		if _, err := tx.UpdateTree(tree.TreeId, func(t *trillian.Tree) { t.DisplayName = "Updated" }); err != nil {
+			t.Fatalf("Failed to update tree: %v", err)
+		}
+	}
+	if err := atx1.Commit(); err != nil {
+		t.Fatalf("Failed to commit update: %v", err)
+	}
+	if err := atx2.Commit(); err != errTreeChanged {
+		t.Fatalf("Committing a concurrent update returned %v, want %v", err, errTreeChanged)
+	}
+
+	atx, _ = as.Begin(context.Background())
+	if _, err := atx.SoftDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to soft delete tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit soft delete: %v", err)
+	}
+
+	atx, _ = as.Begin(context.Background())
+	if err := atx.HardDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to hard delete tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit hard delete: %v", err)
+	}
+
+	rtx, _ = as.Snapshot(context.Background())
+	defer rtx.Commit()
+	if _, err := rtx.GetTree(tree.TreeId); err != storage.ErrTreeNotFound {
+		t.Fatalf("GetTree() after hard delete returned %v, want %v", err, storage.ErrTreeNotFound)
+	}
+}
+
+func dummyHash() []byte {
+	h := sha256.Sum256([]byte("dummy"))
+	return h[:]
+}
+
+func createSomeNodes() []storage.Node {
+	r := make([]storage.Node, 4)
+	for i := range r {
+		r[i].NodeID = storage.NewNodeIDWithPrefix(uint64(i), 8, 8, 8)
+		h := sha256.Sum256([]byte{byte(i)})
+		r[i].Hash = h[:]
+	}
+	return r
+}
+
+// Creates some test leaves with predictable data
+func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0)
+	hasher := trillian.NewSHA256()
+
+	for l := int64(0); l < n; l++ {
+		lv := fmt.Sprintf("Leaf %d", l)
+		leaf := trillian.LogLeaf{
+			Leaf:                 trillian.Leaf{LeafHash: hasher.Digest([]byte(lv)), LeafValue: []byte(lv), ExtraData: []byte(fmt.Sprintf("Extra %d", l))},
+			SignedEntryTimestamp: signedTimestamp,
+			SequenceNumber:       startSeq + l,
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves
+}
+
+func containsTreeID(ids []trillian.LogID, treeID int64) bool {
+	for _, id := range ids {
+		if id.TreeID == treeID {
+			return true
+		}
+	}
+	return false
+}
+
+var testProvider struct {
+	sync.Mutex
+	p   *spannerProvider
+	err error
+}
+
+// openTestProviderOrSkip returns a provider for the test database, skipping the test if
+// there isn't one
+func openTestProviderOrSkip(t *testing.T) *spannerProvider {
+	if len(*testDSNFlag) == 0 {
+		t.Skip("Cloud Spanner test database not given, see --cloudspanner_test_dsn")
+	}
+
+	testProvider.Lock()
+	defer testProvider.Unlock()
+
+	if testProvider.p == nil && testProvider.err == nil {
+		p, err := newSpannerProvider(*testDSNFlag)
+		if err == nil {
+			_, err = p.(*spannerProvider).getClient()
+		}
+		if err != nil {
+			testProvider.err = err
+		} else {
+			testProvider.p = p.(*spannerProvider)
+		}
+	}
+
+	if testProvider.err != nil {
+		t.Skipf("Cloud Spanner test database not available: %v", testProvider.err)
+	}
+
+	return testProvider.p
+}
+
+func prepareTestTree(treeID int64, keyID []byte, treeType string, t *testing.T) {
+	client, _ := openTestProviderOrSkip(t).getClient()
+
+	_, err := client.Apply(context.Background(), []*spanner.Mutation{spanner.Insert("Trees", treeColumns, []interface{}{
+		treeID, keyID, treeType, "ACTIVE", "SHA256", "SHA256", false, "", "", int64(0), int64(0), false, int64(0), false})})
+
+	if err != nil {
+		t.Fatalf("Failed to create tree entry for test: %v", err)
+	}
+}
+
+func prepareTestLog(t *testing.T) trillian.LogID {
+	logID := trillian.LogID{LogID: []byte(t.Name()), TreeID: nextTreeID()}
+	prepareTestTree(logID.TreeID, logID.LogID, "LOG", t)
+
+	return logID
+}
+
+func prepareTestMap(t *testing.T) trillian.MapID {
+	mapID := trillian.MapID{MapID: []byte(t.Name()), TreeID: nextTreeID()}
+	prepareTestTree(mapID.TreeID, mapID.MapID, "MAP", t)
+
+	return mapID
+}
+
+func prepareTestLogStorage(logID trillian.LogID, t *testing.T) storage.LogStorage {
+	s, err := openTestProviderOrSkip(t).LogStorage(logID)
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %s", err)
+	}
+
+	return s
+}
+
+func prepareTestMapStorage(mapID trillian.MapID, t *testing.T) storage.MapStorage {
+	s, err := openTestProviderOrSkip(t).MapStorage(mapID)
+	if err != nil {
+		t.Fatalf("Failed to open map storage: %s", err)
+	}
+
+	return s
+}
+
+// storeTestRoot stores a root of treeSize leaves at the transaction's write revision
+func storeTestRoot(tx storage.LogTX, treeSize int64, t *testing.T) {
+	root := trillian.SignedLogRoot{TimestampNanos: 98765 + tx.WriteRevision(), TreeSize: treeSize, TreeRevision: tx.WriteRevision(),
+		RootHash: dummyHash(), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	if err := tx.StoreSignedLogRoot(context.Background(), root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+}
+
+func commit(tx storage.LogTX, t *testing.T) {
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit tx: %v", err)
+	}
+}
+
+func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
+	tx, err := s.Begin(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+
+	return tx
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,577 @@
+package cloudspanner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const selectTreeStateSQL string = "SELECT TreeState, Deleted FROM Trees WHERE TreeId=@tree_id"
+const selectTreeHasherTypeSQL string = "SELECT TreeHasherType FROM Trees WHERE TreeId=@tree_id"
+
+// Selects the newest revision at or before @revision of each subtree in one query
+const selectSubtreesSQL string = `SELECT s.Nodes FROM Subtree s
+		 WHERE s.TreeId=@tree_id AND s.SubtreeId IN UNNEST(@subtree_ids) AND s.SubtreeRevision=(
+		   SELECT MAX(SubtreeRevision) FROM Subtree
+		   WHERE TreeId=@tree_id AND SubtreeId=s.SubtreeId AND SubtreeRevision<=@revision)`
+const selectTreeRevisionAtSizeSQL string = `SELECT TreeRevision FROM TreeHead
+		 WHERE TreeId=@tree_id AND TreeSize=@tree_size ORDER BY TreeRevision DESC LIMIT 1`
+const selectTreeRevisionCoveringSizeSQL string = `SELECT TreeRevision, TreeSize FROM TreeHead
+		 WHERE TreeId=@tree_id AND TreeSize>=@tree_size ORDER BY TreeSize, TreeRevision DESC LIMIT 1`
+
+var subtreeColumns = []string{"TreeId", "SubtreeId", "SubtreeRevision", "Nodes"}
+var revisionClaimColumns = []string{"TreeId", "TreeRevision"}
+
+// errNoRows is returned by queryRow if the query doesn't select anything
+var errNoRows = errors.New("cloudspanner: no rows in result set")
+
+// reader is implemented by both read-only and read-write Spanner transactions
+type reader interface {
+	Query(ctx context.Context, statement spanner.Statement) *spanner.RowIterator
+	ReadRow(ctx context.Context, table string, key spanner.Key, columns []string) (*spanner.Row, error)
+}
+
+// queryRow scans the first row selected by statement into dest, or returns errNoRows if it
+// doesn't select any
+func queryRow(ctx context.Context, r reader, statement spanner.Statement, dest ...interface{}) error {
+	found := false
+	err := r.Query(ctx, statement).Do(func(row *spanner.Row) error {
+		if found {
+			return nil
+		}
+		found = true
+		return row.Columns(dest...)
+	})
+
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errNoRows
+	}
+
+	return nil
+}
+
+// rowExists returns whether there's a row in table with key
+func rowExists(ctx context.Context, r reader, table string, key spanner.Key, column string) (bool, error) {
+	_, err := r.ReadRow(ctx, table, key, []string{column})
+
+	switch {
+	case spanner.ErrCode(err) == codes.NotFound:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// conditionalWrite is run in the read-write transaction that commits a transaction's writes.
+// It reads what's stored at that point and either buffers the writes that depend on it, or
+// returns an error which fails the commit.
+type conditionalWrite func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error
+
+// spannerTreeStorage is shared between the spannerLog- and spannerMap- Storage
+// implementations, and contains functionality which is common to both.
+type spannerTreeStorage struct {
+	treeID          int64
+	client          *spanner.Client
+	hashAlgorithm   trillian.HashAlgorithm
+	hashSizeBytes   int
+	populateSubtree storage.PopulateSubtreeFunc
+	cacheLimits     cache.CacheLimits
+	// storeInternalNodes is true if subtrees are stored with their internal nodes
+	storeInternalNodes bool
+	// subtreeKeyVersion is the version of the suffix keys subtrees are written with
+	subtreeKeyVersion int32
+	// revisionConflict is returned by Commit if another transaction has written the same
+	// revision
+	revisionConflict error
+}
+
+// newTreeStorage creates the tree hasher configured for the tree, which is passed to
+// populateFactory to build the function used to rebuild subtrees.
+func newTreeStorage(treeID int64, client *spanner.Client, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc) (*spannerTreeStorage, error) {
+	hashAlgorithm, err := getTreeHashAlgorithm(client, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	th, err := merkle.NewTreeHasher(hashAlgorithm)
+	if err != nil {
+		glog.Warningf("Failed to create tree hasher for tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	s := &spannerTreeStorage{
+		treeID:          treeID,
+		client:          client,
+		hashAlgorithm:   hashAlgorithm,
+		hashSizeBytes:   th.Size(),
+		populateSubtree: populateFactory(th),
+	}
+
+	return s, nil
+}
+
+// getTreeHashAlgorithm reads the hash algorithm the tree was created with.
+// TODO: Trees without a row default to SHA256 like the other tree properties until
+// everything creates trees through the admin API.
+func getTreeHashAlgorithm(client *spanner.Client, treeID int64) (trillian.HashAlgorithm, error) {
+	var treeHasherType string
+	err := queryRow(context.Background(), client.Single(), spanner.Statement{
+		SQL:    selectTreeHasherTypeSQL,
+		Params: map[string]interface{}{"tree_id": treeID},
+	}, &treeHasherType)
+
+	if err == errNoRows {
+		return trillian.HashAlgorithm_SHA256, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read hasher type for tree %d: %s", treeID, err)
+		return 0, err
+	}
+
+	v, ok := trillian.HashAlgorithm_value[treeHasherType]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash algorithm %s for tree %d", treeHasherType, treeID)
+	}
+
+	return trillian.HashAlgorithm(v), nil
+}
+
+func decodeSignedTimestamp(ctx context.Context, signedEntryTimestampBytes []byte) (trillian.SignedEntryTimestamp, error) {
+	var signedEntryTimestamp trillian.SignedEntryTimestamp
+
+	if err := proto.Unmarshal(signedEntryTimestampBytes, &signedEntryTimestamp); err != nil {
+		logging.Warningf(ctx, "Failed to decode SignedTimestamp: %s", err)
+		return trillian.SignedEntryTimestamp{}, err
+	}
+
+	return signedEntryTimestamp, nil
+}
+
+func encodeSignedTimestamp(ctx context.Context, signedEntryTimestamp trillian.SignedEntryTimestamp) ([]byte, error) {
+	marshalled, err := proto.Marshal(&signedEntryTimestamp)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to encode SignedTimestamp: %s", err)
+		return nil, err
+	}
+
+	return marshalled, err
+}
+
+// HashAlgorithm returns the hash algorithm the tree was created with.
+func (s *spannerTreeStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return s.hashAlgorithm
+}
+
+// SetSubtreeCacheLimits sets the limits for the subtree caches of transactions started
+// after this call. By default the caches are unlimited.
+func (s *spannerTreeStorage) SetSubtreeCacheLimits(limits cache.CacheLimits) {
+	s.cacheLimits = limits
+}
+
+// SetStoreInternalNodes sets whether subtrees written by transactions started after this
+// call are stored with their internal nodes. By default only their leaves are stored.
+func (s *spannerTreeStorage) SetStoreInternalNodes(store bool) {
+	s.storeInternalNodes = store
+}
+
+// SetSubtreeKeyVersion sets the version of the suffix key encoding that subtrees written by
+// transactions started after this call use. By default they use storage.SuffixKeyV0.
+func (s *spannerTreeStorage) SetSubtreeKeyVersion(version int32) {
+	s.subtreeKeyVersion = version
+}
+
+// beginTreeTx starts a transaction traced as a span of ctx, which lasts until the
+// transaction is committed or rolled back.
+func (s *spannerTreeStorage) beginTreeTx(ctx context.Context) treeTX {
+	ctx, span := monitoring.StartSpan(ctx, "cloudspanner.TX", attribute.Int64("treeid", s.treeID))
+	subtreeCache := cache.NewSubtreeCacheWithLimits(s.populateSubtree, s.cacheLimits)
+	subtreeCache.SetStoreInternalNodes(s.storeInternalNodes)
+	subtreeCache.SetSubtreeKeyVersion(s.subtreeKeyVersion)
+	return treeTX{
+		ctx:            ctx,
+		span:           span,
+		ts:             s,
+		stx:            s.client.ReadOnlyTransaction(),
+		subtreeCache:   subtreeCache,
+		writeRevision:  -1,
+		readRevision:   -1,
+		mutationsMutex: new(sync.Mutex),
+		subtrees:       make(map[string]*spanner.Mutation),
+		started:        time.Now(),
+	}
+}
+
+type treeTX struct {
+	closed bool
+	// ctx is the context the transaction was started with, which its writes are committed
+	// under
+	ctx  context.Context
+	span trace.Span
+	ts   *spannerTreeStorage
+	// stx is the read-only transaction all the reads are made through, so that they see the
+	// same snapshot of the database
+	stx           *spanner.ReadOnlyTransaction
+	subtreeCache  cache.SubtreeCache
+	writeRevision int64
+	// readRevision is the revision a transaction started by SnapshotForTree is pinned to,
+	// or -1 for other transactions
+	readRevision int64
+
+	// mutationsMutex guards the writes below, which may be added to concurrently by the
+	// subtree cache
+	mutationsMutex *sync.Mutex
+	// subtrees are the subtrees to store at writeRevision, by prefix, so that a subtree
+	// written more than once is only stored once
+	subtrees map[string]*spanner.Mutation
+	// revisionMutations are the other writes at writeRevision, which are committed along
+	// with a claim on the revision
+	revisionMutations []*spanner.Mutation
+	// mutations don't belong to a revision
+	mutations []*spanner.Mutation
+	// conditionalWrites are run before the other writes are buffered
+	conditionalWrites []conditionalWrite
+
+	// started is when the transaction began, for recording how long it was open
+	started time.Time
+}
+
+// addMutations adds writes that don't belong to a revision
+func (t *treeTX) addMutations(ms ...*spanner.Mutation) {
+	t.mutationsMutex.Lock()
+	defer t.mutationsMutex.Unlock()
+
+	t.mutations = append(t.mutations, ms...)
+}
+
+// addRevisionMutations adds writes that are stored at writeRevision
+func (t *treeTX) addRevisionMutations(ms ...*spanner.Mutation) {
+	t.mutationsMutex.Lock()
+	defer t.mutationsMutex.Unlock()
+
+	t.revisionMutations = append(t.revisionMutations, ms...)
+}
+
+// addConditionalWrite adds a write that depends on what's stored when the transaction is
+// committed
+func (t *treeTX) addConditionalWrite(w conditionalWrite) {
+	t.mutationsMutex.Lock()
+	defer t.mutationsMutex.Unlock()
+
+	t.conditionalWrites = append(t.conditionalWrites, w)
+}
+
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+
+	ctx, span := monitoring.StartSpan(trace.ContextWithSpan(ctx, t.span), "cloudspanner.getSubtrees", attribute.Int("subtrees", len(nodeIDs)))
+	defer span.End()
+
+	subtreeIDs := make([][]byte, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+
+		subtreeIDs = append(subtreeIDs, nodeID.Path[:nodeID.PrefixLenBits/8])
+	}
+
+	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+	err := t.stx.Query(ctx, spanner.Statement{
+		SQL: selectSubtreesSQL,
+		Params: map[string]interface{}{
+			"tree_id":     t.ts.treeID,
+			"subtree_ids": subtreeIDs,
+			"revision":    treeRevision,
+		},
+	}).Do(func(row *spanner.Row) error {
+		var nodesRaw []byte
+		if err := row.Columns(&nodesRaw); err != nil {
+			return err
+		}
+
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal SubtreeProto: %s", err)
+			return err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+		return nil
+	})
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+
+	// The InternalNodes cache is nil here unless the subtrees were stored with their
+	// internal nodes, otherwise the SubtreeCache (which called this method) will
+	// re-populate it.
+	return ret, nil
+}
+
+// storeSubtrees keeps the subtrees to be written when the transaction is committed
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storage.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		logging.Warningf(ctx, "attempted to store 0 subtrees...")
+		return nil
+	}
+
+	t.mutationsMutex.Lock()
+	defer t.mutationsMutex.Unlock()
+
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		// The SubtreeCache has already removed the internal nodes unless it was told to
+		// store them, otherwise they're recalculated when this subtree is read back.
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		t.subtrees[string(s.Prefix)] = spanner.Insert("Subtree", subtreeColumns,
+			[]interface{}{t.ts.treeID, s.Prefix, t.writeRevision, subtreeBytes})
+	}
+
+	return nil
+}
+
+// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
+// It is an error to request tree sizes larger than the currently published tree size.
+// This only works for sizes where there is a stored tree head, the same as the MySQL
+// implementation.
+func (t *treeTX) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
+	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
+	if treeSize <= 0 {
+		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision int64
+	err := queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectTreeRevisionAtSizeSQL,
+		Params: map[string]interface{}{"tree_id": t.ts.treeID, "tree_size": treeSize},
+	}, &treeRevision)
+
+	return treeRevision, err
+}
+
+// getTreeRevisionCoveringSize returns the revision and size of the tree that a snapshot for
+// treeSize is pinned to, the same as the MySQL implementation.
+func (t *treeTX) getTreeRevisionCoveringSize(ctx context.Context, treeSize int64) (int64, int64, error) {
+	if treeSize <= 0 {
+		return 0, 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision, size int64
+	err := queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectTreeRevisionCoveringSizeSQL,
+		Params: map[string]interface{}{"tree_id": t.ts.treeID, "tree_size": treeSize},
+	}, &treeRevision, &size)
+
+	return treeRevision, size, err
+}
+
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	hashes, err := t.subtreeCache.GetNodeHashes(ctx, nodeIDs, func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	for i, h := range hashes {
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeIDs[i],
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	return t.subtreeCache.GetProofPath(ctx, leafID, treeSize, func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	})
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(ctx, n.NodeID, n.Hash,
+			func(ctx context.Context, nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			},
+			t.storeSubtrees)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimRevision buffers the claim on writeRevision, returning revisionConflict if another
+// transaction has already written it
+func (t *treeTX) claimRevision(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+	claimed, err := rowExists(ctx, rtx, "TreeRevisionClaim", spanner.Key{t.ts.treeID, t.writeRevision}, "TreeRevision")
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to check claim on revision %d: %s", t.writeRevision, err)
+		return err
+	}
+
+	if claimed {
+		return t.ts.revisionConflict
+	}
+
+	return rtx.BufferWrite([]*spanner.Mutation{
+		spanner.Insert("TreeRevisionClaim", revisionClaimColumns, []interface{}{t.ts.treeID, t.writeRevision}),
+	})
+}
+
+// commit applies the transaction's writes in a single read-write transaction. Spanner
+// retries it if it conflicts with another, in which case the claim on the revision fails
+// if the other wrote it.
+func (t *treeTX) commit() error {
+	if t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(t.ctx, t.storeSubtrees); err != nil {
+			logging.Warningf(t.ctx, "TX commit flush error: %s", err)
+			return err
+		}
+	}
+
+	revisionMutations := t.revisionMutations
+	for _, m := range t.subtrees {
+		revisionMutations = append(revisionMutations, m)
+	}
+
+	if len(revisionMutations) == 0 && len(t.mutations) == 0 && len(t.conditionalWrites) == 0 {
+		return nil
+	}
+
+	ms := append(revisionMutations, t.mutations...)
+
+	_, err := t.ts.client.ReadWriteTransaction(t.ctx, func(ctx context.Context, rtx *spanner.ReadWriteTransaction) error {
+		if len(revisionMutations) > 0 {
+			if err := t.claimRevision(ctx, rtx); err != nil {
+				return err
+			}
+		}
+
+		for _, w := range t.conditionalWrites {
+			if err := w(ctx, rtx); err != nil {
+				return err
+			}
+		}
+
+		return rtx.BufferWrite(ms)
+	})
+
+	return err
+}
+
+func (t *treeTX) Commit() error {
+	err := t.commit()
+	t.stx.Close()
+	t.closed = true
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "cloudspanner", "commit")
+	monitoring.EndSpan(t.span, err)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "TX commit error: %s", err)
+	}
+
+	return err
+}
+
+// Rollback discards the transaction's writes, none of which have been applied
+func (t *treeTX) Rollback() error {
+	t.stx.Close()
+	t.closed = true
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "cloudspanner", "rollback")
+	t.span.SetAttributes(attribute.Bool("rollback", true))
+	monitoring.EndSpan(t.span, nil)
+
+	return nil
+}
+
+// checkTreeState returns storage.ErrTreeDeleted if the tree has been deleted and, for
+// writable transactions, storage.ErrReadOnly if it has been frozen. Trees can be frozen
+// or deleted through the admin API at any time so this is checked whenever a transaction
+// is started.
+func (t *treeTX) checkTreeState(ctx context.Context, write bool) error {
+	var state string
+	var deleted bool
+	err := queryRow(ctx, t.stx, spanner.Statement{
+		SQL:    selectTreeStateSQL,
+		Params: map[string]interface{}{"tree_id": t.ts.treeID},
+	}, &state, &deleted)
+
+	switch {
+	case err == errNoRows:
+		// Storage can currently be opened for trees that don't have a Trees row, though
+		// nothing can be written to them.
+		return nil
+	case err != nil:
+		logging.Warningf(ctx, "Failed to read state of tree %d: %s", t.ts.treeID, err)
+		return err
+	case deleted:
+		return storage.ErrTreeDeleted
+	case write && state == trillian.TreeState_FROZEN.String():
+		return storage.ErrReadOnly
+	}
+
+	return nil
+}
+
+// ReadRevision returns the tree revision a snapshot transaction is pinned to.
+func (t *treeTX) ReadRevision() int64 {
+	return t.readRevision
+}
+
+func (t *treeTX) IsOpen() bool {
+	return !t.closed
+}
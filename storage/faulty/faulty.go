@@ -0,0 +1,130 @@
+// Package faulty wraps storage so that it fails the way a database under strain does, with
+// slow calls, transient errors and transaction conflicts. Faults are picked by a seeded
+// random number generator, so a test that makes the same calls in the same order sees the
+// same faults each run.
+package faulty
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ErrInjected is the transient error returned by storage calls picked to fail. The work of
+// the call isn't done, and retrying it, or the transaction it's part of, might succeed.
+var ErrInjected = errors.New("faulty: injected storage error")
+
+// ErrConflict is returned by the Commit of a writable transaction picked to conflict with
+// another one. The transaction has been rolled back.
+var ErrConflict = errors.New("faulty: injected transaction conflict")
+
+// Config says how often faults are injected. Rates are probabilities between 0 and 1, so
+// the zero Config injects no faults.
+type Config struct {
+	// Seed starts the random number generator that picks the faults
+	Seed int64
+	// ErrorRate is how often a call, including the calls that start and commit a
+	// transaction, fails with ErrInjected
+	ErrorRate float64
+	// ConflictRate is how often the Commit of a writable transaction fails with ErrConflict
+	ConflictRate float64
+	// LatencyRate is how often a call is delayed before it's made
+	LatencyRate float64
+	// MaxLatency is the longest delay, delays are picked evenly up to it
+	MaxLatency time.Duration
+}
+
+// Stats counts the calls an Injector has seen and the faults it's injected
+type Stats struct {
+	Calls     int
+	Errors    int
+	Conflicts int
+	Delays    int
+}
+
+// Injector picks the faults for storage wrapped with it. Storage sharing an Injector shares
+// its random number generator, so the faults depend on the order of the calls to all of it.
+type Injector struct {
+	config Config
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats Stats
+}
+
+// NewInjector creates an Injector that injects faults as set by config
+func NewInjector(config Config) *Injector {
+	return &Injector{config: config, rand: rand.New(rand.NewSource(config.Seed))}
+}
+
+// Stats returns the counts of calls and faults so far
+func (i *Injector) Stats() Stats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.stats
+}
+
+// pick decides the delay and outcome of a call. It always draws the same values from the
+// generator, whatever the config, so changing one rate doesn't move the faults of another.
+func (i *Injector) pick(canConflict bool) (time.Duration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delayed := i.rand.Float64() < i.config.LatencyRate
+	delay := time.Duration(i.rand.Int63n(int64(i.config.MaxLatency) + 1))
+	failed := i.rand.Float64() < i.config.ErrorRate
+	conflicted := i.rand.Float64() < i.config.ConflictRate
+
+	i.stats.Calls++
+
+	if !delayed {
+		delay = 0
+	} else if delay > 0 {
+		i.stats.Delays++
+	}
+
+	switch {
+	case canConflict && conflicted:
+		i.stats.Conflicts++
+		return delay, ErrConflict
+	case failed:
+		i.stats.Errors++
+		return delay, ErrInjected
+	}
+
+	return delay, nil
+}
+
+// fault delays the call op, if it's been picked to be, and returns the error it should fail
+// with, or nil if it should be made
+func (i *Injector) fault(ctx context.Context, op string) error {
+	return i.inject(ctx, op, false)
+}
+
+// commitFault is fault for the Commit of a transaction, which can also conflict if write
+func (i *Injector) commitFault(ctx context.Context, write bool) error {
+	return i.inject(ctx, "Commit", write)
+}
+
+func (i *Injector) inject(ctx context.Context, op string, canConflict bool) error {
+	delay, err := i.pick(canConflict)
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	if err != nil {
+		glog.V(1).Infof("Injecting fault into %s: %v", op, err)
+	}
+
+	return err
+}
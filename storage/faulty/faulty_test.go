@@ -0,0 +1,115 @@
+package faulty
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type outcome struct {
+	delay time.Duration
+	err   error
+}
+
+func picks(i *Injector, n int) []outcome {
+	outcomes := make([]outcome, 0, n)
+
+	for c := 0; c < n; c++ {
+		delay, err := i.pick(c%3 == 0)
+		outcomes = append(outcomes, outcome{delay, err})
+	}
+
+	return outcomes
+}
+
+var noisy = Config{ErrorRate: 0.2, ConflictRate: 0.2, LatencyRate: 0.5, MaxLatency: time.Millisecond * 10}
+
+func TestSameSeedPicksSameFaults(t *testing.T) {
+	config := noisy
+	config.Seed = 42
+
+	first := picks(NewInjector(config), 200)
+	second := picks(NewInjector(config), 200)
+
+	for c := range first {
+		if first[c] != second[c] {
+			t.Fatalf("Call %d: got %v the first time and %v the second", c, first[c], second[c])
+		}
+	}
+
+	config.Seed = 43
+	other := picks(NewInjector(config), 200)
+	same := true
+
+	for c := range first {
+		if first[c] != other[c] {
+			same = false
+			break
+		}
+	}
+
+	if same {
+		t.Error("Different seeds picked the same faults for every call")
+	}
+}
+
+func TestChangingOneRateKeepsOtherFaults(t *testing.T) {
+	config := noisy
+	config.Seed = 7
+	first := picks(NewInjector(config), 200)
+
+	config.ConflictRate = 0
+	second := picks(NewInjector(config), 200)
+
+	for c := range first {
+		if first[c].delay != second[c].delay {
+			t.Fatalf("Call %d: delay changed from %v to %v", c, first[c].delay, second[c].delay)
+		}
+
+		if first[c].err == ErrInjected && second[c].err != ErrInjected {
+			t.Fatalf("Call %d: injected error became %v", c, second[c].err)
+		}
+	}
+}
+
+func TestZeroConfigInjectsNoFaults(t *testing.T) {
+	i := NewInjector(Config{})
+
+	for c := 0; c < 100; c++ {
+		if err := i.commitFault(context.Background(), true); err != nil {
+			t.Fatalf("Call %d: got error %v, expected none", c, err)
+		}
+	}
+
+	if got, want := i.Stats(), (Stats{Calls: 100}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestConflictsOnlyOnCommitOfWrites(t *testing.T) {
+	i := NewInjector(Config{ConflictRate: 1})
+	ctx := context.Background()
+
+	if err := i.fault(ctx, "GetLeavesByIndex"); err != nil {
+		t.Errorf("fault() = %v, a call that isn't a commit can't conflict", err)
+	}
+
+	if err := i.commitFault(ctx, false); err != nil {
+		t.Errorf("commitFault(false) = %v, a read-only transaction can't conflict", err)
+	}
+
+	if err := i.commitFault(ctx, true); err != ErrConflict {
+		t.Errorf("commitFault(true) = %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestDelayStopsWhenContextDone(t *testing.T) {
+	i := NewInjector(Config{LatencyRate: 1, MaxLatency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if err := i.fault(ctx, "GetLeavesByIndex"); err != context.DeadlineExceeded {
+		t.Errorf("fault() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
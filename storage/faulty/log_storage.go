@@ -0,0 +1,382 @@
+package faulty
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+type provider struct {
+	storage.Provider
+	faults *Injector
+}
+
+// NewProvider wraps p so that the log storage it returns injects the faults picked by
+// faults. Map and admin storage are returned as they are.
+func NewProvider(p storage.Provider, faults *Injector) storage.Provider {
+	return &provider{Provider: p, faults: faults}
+}
+
+func (p *provider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	s, err := p.Provider.LogStorage(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogStorage(s, p.faults), nil
+}
+
+type logStorage struct {
+	storage.LogStorage
+	faults *Injector
+}
+
+// NewLogStorage wraps the storage of a log so that starting transactions, and the calls
+// made on them, fail or are delayed when picked to be by faults. A transaction is still
+// open after one of its calls fails, as it would be after a database error, so the caller
+// has to roll it back. Transactions that fail to commit have been rolled back.
+func NewLogStorage(s storage.LogStorage, faults *Injector) storage.LogStorage {
+	return &logStorage{LogStorage: s, faults: faults}
+}
+
+func (s *logStorage) Begin(ctx context.Context) (storage.LogTX, error) {
+	if err := s.faults.fault(ctx, "Begin"); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.LogStorage.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := &logTX{reader: reader{ctx: ctx, tx: tx, faults: s.faults}, tx: tx}
+
+	// Subtree garbage collection looks for this on the transaction
+	if pruner, ok := tx.(storage.SubtreePruner); ok {
+		return prunableLogTX{logTX: wrapped, pruner: pruner}, nil
+	}
+
+	return wrapped, nil
+}
+
+func (s *logStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	if err := s.faults.fault(ctx, "Snapshot"); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.LogStorage.Snapshot(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &readOnlyLogTX{reader: reader{ctx: ctx, tx: tx, faults: s.faults}, tx: tx}, nil
+}
+
+func (s *logStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	if err := s.faults.fault(ctx, "SnapshotForTree"); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.LogStorage.SnapshotForTree(ctx, treeSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &readOnlyLogTreeTX{readOnlyLogTX: readOnlyLogTX{reader: reader{ctx: ctx, tx: tx, faults: s.faults}, tx: tx}, snapshot: tx}, nil
+}
+
+// readTX holds the reads that every kind of log transaction has
+type readTX interface {
+	storage.NodeReader
+	storage.LeafReader
+	storage.LogRootReader
+	storage.CosignatureReader
+}
+
+// reader injects faults into the reads of a transaction. ctx is the context the
+// transaction was started with, used for delaying calls that aren't passed one.
+type reader struct {
+	ctx    context.Context
+	tx     readTX
+	faults *Injector
+}
+
+func (r reader) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
+	if err := r.faults.fault(ctx, "GetTreeRevisionAtSize"); err != nil {
+		return 0, err
+	}
+
+	return r.tx.GetTreeRevisionAtSize(ctx, treeSize)
+}
+
+func (r reader) GetMerkleNodes(ctx context.Context, treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	if err := r.faults.fault(ctx, "GetMerkleNodes"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetMerkleNodes(ctx, treeRevision, ids)
+}
+
+func (r reader) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	if err := r.faults.fault(ctx, "GetProofPath"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetProofPath(ctx, treeRevision, leafID, treeSize)
+}
+
+func (r reader) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	if err := r.faults.fault(ctx, "GetSequencedLeafCount"); err != nil {
+		return 0, err
+	}
+
+	return r.tx.GetSequencedLeafCount(ctx)
+}
+
+func (r reader) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	if err := r.faults.fault(ctx, "GetLeavesByIndex"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetLeavesByIndex(ctx, leaves)
+}
+
+func (r reader) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	if err := r.faults.fault(ctx, "GetLeavesByRange"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetLeavesByRange(ctx, start, count)
+}
+
+func (r reader) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	if err := r.faults.fault(ctx, "GetLeavesByHash"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetLeavesByHash(ctx, leafHashes, orderBySequence)
+}
+
+func (r reader) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	if err := r.faults.fault(ctx, "GetLeavesByIdentityHash"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetLeavesByIdentityHash(ctx, identityHashes, orderBySequence)
+}
+
+func (r reader) LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	if err := r.faults.fault(ctx, "LatestSignedLogRoot"); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return r.tx.LatestSignedLogRoot(ctx)
+}
+
+func (r reader) GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error) {
+	if err := r.faults.fault(ctx, "GetSignedLogRoot"); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return r.tx.GetSignedLogRoot(ctx, timestampNanos)
+}
+
+func (r reader) GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error) {
+	if err := r.faults.fault(ctx, "GetCosignatures"); err != nil {
+		return nil, err
+	}
+
+	return r.tx.GetCosignatures(ctx, rootTimestampNanos)
+}
+
+type readOnlyLogTX struct {
+	reader
+	tx storage.ReadOnlyLogTX
+}
+
+// Commit of a read-only transaction can fail but not conflict. The snapshot is closed
+// either way.
+func (t *readOnlyLogTX) Commit() error {
+	if err := t.faults.commitFault(t.ctx, false); err != nil {
+		t.tx.Commit()
+		return err
+	}
+
+	return t.tx.Commit()
+}
+
+type readOnlyLogTreeTX struct {
+	readOnlyLogTX
+	snapshot storage.ReadOnlyLogTreeTX
+}
+
+func (t *readOnlyLogTreeTX) ReadRevision() int64 {
+	return t.snapshot.ReadRevision()
+}
+
+func (t *readOnlyLogTreeTX) TreeSize() int64 {
+	return t.snapshot.TreeSize()
+}
+
+type logTX struct {
+	reader
+	tx storage.LogTX
+}
+
+type prunableLogTX struct {
+	*logTX
+	pruner storage.SubtreePruner
+}
+
+func (t prunableLogTX) PruneSubtrees(ctx context.Context, horizon int64) (storage.PruneStats, error) {
+	if err := t.faults.fault(ctx, "PruneSubtrees"); err != nil {
+		return storage.PruneStats{}, err
+	}
+
+	return t.pruner.PruneSubtrees(ctx, horizon)
+}
+
+func (t *logTX) Commit() error {
+	if err := t.faults.commitFault(t.ctx, true); err != nil {
+		t.tx.Rollback()
+		return err
+	}
+
+	return t.tx.Commit()
+}
+
+func (t *logTX) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *logTX) IsOpen() bool {
+	return t.tx.IsOpen()
+}
+
+func (t *logTX) WriteRevision() int64 {
+	return t.tx.WriteRevision()
+}
+
+func (t *logTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	if err := t.faults.fault(ctx, "SetMerkleNodes"); err != nil {
+		return err
+	}
+
+	return t.tx.SetMerkleNodes(ctx, nodes)
+}
+
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error {
+	if err := t.faults.fault(ctx, "StoreSignedLogRoot"); err != nil {
+		return err
+	}
+
+	return t.tx.StoreSignedLogRoot(ctx, root)
+}
+
+func (t *logTX) GetSequencerCheckpoint(ctx context.Context) (*storage.SequencerCheckpoint, error) {
+	if err := t.faults.fault(ctx, "GetSequencerCheckpoint"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.GetSequencerCheckpoint(ctx)
+}
+
+func (t *logTX) StoreSequencerCheckpoint(ctx context.Context, checkpoint storage.SequencerCheckpoint) error {
+	if err := t.faults.fault(ctx, "StoreSequencerCheckpoint"); err != nil {
+		return err
+	}
+
+	return t.tx.StoreSequencerCheckpoint(ctx, checkpoint)
+}
+
+func (t *logTX) AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error {
+	if err := t.faults.fault(ctx, "AddCosignature"); err != nil {
+		return err
+	}
+
+	return t.tx.AddCosignature(ctx, rootTimestampNanos, cosignature)
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "QueueLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.QueueLeaves(ctx, leaves)
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "AddSequencedLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.AddSequencedLeaves(ctx, leaves)
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "DequeueLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.DequeueLeaves(ctx, limit, nowNanos)
+}
+
+func (t *logTX) DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "DequeueOverdueLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.DequeueOverdueLeaves(ctx, limit, queuedBeforeNanos, nowNanos)
+}
+
+func (t *logTX) ClaimLeaves(ctx context.Context, claim storage.LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "ClaimLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.ClaimLeaves(ctx, claim, nowNanos, limit)
+}
+
+func (t *logTX) DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error) {
+	if err := t.faults.fault(ctx, "DequeueClaimedLeaves"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.DequeueClaimedLeaves(ctx, owner, limit)
+}
+
+func (t *logTX) OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error) {
+	if err := t.faults.fault(ctx, "OldestQueuedLeafNanos"); err != nil {
+		return 0, err
+	}
+
+	return t.tx.OldestQueuedLeafNanos(ctx, nowNanos)
+}
+
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error {
+	if err := t.faults.fault(ctx, "UpdateSequencedLeaves"); err != nil {
+		return err
+	}
+
+	return t.tx.UpdateSequencedLeaves(ctx, leaves)
+}
+
+func (t *logTX) GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error) {
+	if err := t.faults.fault(ctx, "GetActiveLogIDs"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.GetActiveLogIDs(ctx)
+}
+
+func (t *logTX) GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error) {
+	if err := t.faults.fault(ctx, "GetActiveLogIDsWithPendingWork"); err != nil {
+		return nil, err
+	}
+
+	return t.tx.GetActiveLogIDsWithPendingWork(ctx)
+}
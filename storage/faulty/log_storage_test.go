@@ -0,0 +1,220 @@
+package faulty
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+const treeID = int64(1)
+
+var signedTimestamp = trillian.SignedEntryTimestamp{Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+// databases counts the memory databases created, so each test storage gets a new one even
+// when the tests are run more than once
+var databases int
+
+func newMemoryLogStorage(t *testing.T) storage.LogStorage {
+	databases++
+	p, err := storage.NewProvider(memory.ProviderName, fmt.Sprintf("%s-%d", t.Name(), databases))
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("faulty"), TreeID: treeID})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	return s
+}
+
+func createLeaves(t *testing.T, n int) []trillian.LogLeaf {
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, n)
+
+	for l := 0; l < n; l++ {
+		value := []byte(fmt.Sprintf("Leaf %d", l))
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(value), LeafValue: value}, SignedEntryTimestamp: signedTimestamp})
+	}
+
+	return leaves
+}
+
+func TestConflictRollsBackTransaction(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryLogStorage(t)
+	faulty := NewLogStorage(s, NewInjector(Config{ConflictRate: 1}))
+
+	tx, err := faulty.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	if _, err := tx.QueueLeaves(ctx, createLeaves(t, 3)); err != nil {
+		t.Fatalf("QueueLeaves() = %v", err)
+	}
+
+	if err := tx.Commit(); err != ErrConflict {
+		t.Fatalf("Commit() = %v, want %v", err, ErrConflict)
+	}
+
+	tx, err = s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+	defer tx.Commit()
+
+	leaves, err := tx.DequeueLeaves(ctx, 10, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("DequeueLeaves() = %v", err)
+	}
+
+	if len(leaves) != 0 {
+		t.Errorf("Dequeued %d leaves, the transaction that queued them should have been rolled back", len(leaves))
+	}
+}
+
+func TestFailedCallLeavesTransactionOpen(t *testing.T) {
+	ctx := context.Background()
+	faulty := NewLogStorage(newMemoryLogStorage(t), NewInjector(Config{}))
+
+	tx, err := faulty.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+
+	faulty.(*logStorage).faults.config.ErrorRate = 1
+
+	if _, err := tx.GetSequencedLeafCount(ctx); err != ErrInjected {
+		t.Errorf("GetSequencedLeafCount() = %v, want %v", err, ErrInjected)
+	}
+
+	if !tx.IsOpen() {
+		t.Error("Transaction closed by failed call")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback() = %v, should never fail", err)
+	}
+}
+
+func TestKeepsSubtreePruner(t *testing.T) {
+	faulty := NewLogStorage(newMemoryLogStorage(t), NewInjector(Config{}))
+
+	tx, err := faulty.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin() = %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, ok := tx.(storage.SubtreePruner); !ok {
+		t.Error("Wrapped transaction doesn't prune subtrees, the memory storage it wraps does")
+	}
+}
+
+// sequenceAll queues leaves and sequences them, retrying whatever fails, and returns the
+// root once they're all integrated
+func sequenceAll(t *testing.T, s storage.LogStorage, leaves []trillian.LogLeaf) trillian.SignedLogRoot {
+	ctx := context.Background()
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	// Leaves are queued one at a time, retrying each until it's in, so the queue has them in
+	// the same order however many faults there are
+	for l := range leaves {
+		for attempt := 0; ; attempt++ {
+			if attempt == 100 {
+				t.Fatalf("Failed to queue leaf %d after %d attempts", l, attempt)
+			}
+
+			tx, err := s.Begin(ctx)
+			if err != nil {
+				continue
+			}
+
+			if _, err := tx.QueueLeaves(ctx, leaves[l:l+1]); err != nil {
+				tx.Rollback()
+				continue
+			}
+
+			if err := tx.Commit(); err == nil {
+				break
+			}
+		}
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, s, km)
+	neverExpires := func(trillian.SignedLogRoot) bool { return false }
+	integrated := 0
+
+	for attempt := 0; integrated < len(leaves); attempt++ {
+		if attempt == 1000 {
+			t.Fatalf("Only %d of %d leaves integrated after %d attempts", integrated, len(leaves), attempt)
+		}
+
+		if n, err := sequencer.SequenceBatch(ctx, 7, neverExpires); err == nil {
+			integrated += n
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt == 100 {
+			t.Fatalf("Failed to read root after %d attempts", attempt)
+		}
+
+		tx, err := s.Snapshot(ctx)
+		if err != nil {
+			continue
+		}
+
+		root, err := tx.LatestSignedLogRoot(ctx)
+		tx.Commit()
+
+		if err == nil {
+			return root
+		}
+	}
+}
+
+func TestSequencerUnderFaults(t *testing.T) {
+	leaves := createLeaves(t, 50)
+	want := sequenceAll(t, newMemoryLogStorage(t), leaves)
+
+	for _, seed := range []int64{1, 2, 3} {
+		config := Config{Seed: seed, ErrorRate: 0.05, ConflictRate: 0.2}
+		faults := NewInjector(config)
+		got := sequenceAll(t, NewLogStorage(newMemoryLogStorage(t), faults), leaves)
+
+		if got.TreeSize != want.TreeSize || !bytes.Equal(got.RootHash, want.RootHash) {
+			t.Errorf("Seed %d: got root of size %d with hash %x, want size %d with hash %x", seed, got.TreeSize, got.RootHash, want.TreeSize, want.RootHash)
+		}
+
+		if stats := faults.Stats(); stats.Errors == 0 || stats.Conflicts == 0 {
+			t.Errorf("Seed %d: injected %d errors and %d conflicts, the test needs both", seed, stats.Errors, stats.Conflicts)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"github.com/google/trillian"
+	"golang.org/x/net/context"
 )
 
 // ReadOnlyLogTX provides a read-only view into the Log data.
@@ -9,6 +10,20 @@ type ReadOnlyLogTX interface {
 	ReadOnlyTreeTX
 	LeafReader
 	LogRootReader
+	CosignatureReader
+}
+
+// ReadOnlyLogTreeTX is a read-only view into the Log data pinned to one of its tree
+// sizes, see ReadOnlyLogStorage.SnapshotForTree.
+type ReadOnlyLogTreeTX interface {
+	ReadOnlyLogTX
+	TreeSnapshot
+
+	// TreeSize returns the size of the tree at ReadRevision, which is the size of the stored
+	// SignedLogRoot the transaction is pinned to. It can be larger than the size the snapshot
+	// was started for, in which case nodes on the right edge of the smaller tree have to be
+	// recomputed to serve proofs for it, see merkle.NodeFetch.
+	TreeSize() int64
 }
 
 // LogTX is the transactional interface for reading/updating a Log.
@@ -19,9 +34,14 @@ type LogTX interface {
 	TreeTX
 	LogRootReader
 	LogRootWriter
+	CosignatureReader
+	CosignatureWriter
 	LeafReader
 	LeafQueuer
+	SequencedLeafAdder
 	LeafDequeuer
+	LeafClaimer
+	SequencerCheckpointer
 	LogMetadata
 }
 
@@ -30,8 +50,24 @@ type ReadOnlyLogStorage interface {
 	// Snapshot starts a read-only transaction.
 	// Commit must be called when the caller is finished with the returned object,
 	// and values read through it should only be propagated if Commit returns
-	// without error.
-	Snapshot() (ReadOnlyLogTX, error)
+	// without error. Work done through the transaction is traced as part of ctx, and it's
+	// abandoned if ctx is cancelled before it's committed.
+	Snapshot(ctx context.Context) (ReadOnlyLogTX, error)
+
+	// SnapshotForTree starts a read-only transaction pinned to the revision of the first
+	// stored SignedLogRoot with at least treeSize leaves, so that proofs can be served for
+	// any size up to the latest root's, not just the sizes that have roots. All reads
+	// through it see the tree as it was when the transaction started, however long it's
+	// open for, and they don't take locks that would block writers. Commit must be called
+	// when the caller is finished with the returned object.
+	SnapshotForTree(ctx context.Context, treeSize int64) (ReadOnlyLogTreeTX, error)
+
+	// HashAlgorithm returns the hash algorithm the log was created with.
+	HashAlgorithm() trillian.HashAlgorithm
+
+	// TreeType returns whether this is a normal log, where the sequencer assigns sequence
+	// numbers, or a pre-ordered one.
+	TreeType() trillian.TreeType
 }
 
 // LogStorage should be implemented by concrete storage mechanisms which want to support Logs.
@@ -41,55 +77,165 @@ type LogStorage interface {
 	// Begin starts a new Log transaction.
 	// Either Commit or Rollback must be called when the caller is finished with
 	// the returned object, and values read through it should only be propagated
-	// if Commit returns without error.
-	Begin() (LogTX, error)
+	// if Commit returns without error. Work done through the transaction is traced as
+	// part of ctx, and it's rolled back if ctx is cancelled before it's committed.
+	Begin(ctx context.Context) (LogTX, error)
 }
 
 // LeafQueuer provides a write-only interface for the queueing (but not necesarily integration) of leaves.
 type LeafQueuer interface {
-	// QueueLeaves enqueues leaves for later integration into the tree.
-	QueueLeaves(leaves []trillian.LogLeaf) error
+	// QueueLeaves enqueues leaves for later integration into the tree. If the log doesn't
+	// allow duplicate leaves then a leaf with the same leaf hash as one already in the log is
+	// not queued again. The result has an entry for each of leaves, which is nil if the leaf
+	// was queued or the existing leaf if it was a duplicate. Existing leaves that are still
+	// waiting to be sequenced have a SequenceNumber of -1.
+	QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error)
+}
+
+// SequencedLeafAdder provides a write-only interface for adding leaves to a pre-ordered log,
+// where the caller has already assigned each leaf's sequence number.
+type SequencedLeafAdder interface {
+	// AddSequencedLeaves stores leaves at the positions given by their sequence numbers, ready
+	// for later integration into the tree. Integration happens in sequence number order and
+	// stops at the first missing leaf, so leaves can be added out of order. The result has an
+	// entry for each of leaves, which is nil if the leaf was added or the leaf that already
+	// has that sequence number. Only pre-ordered logs accept sequenced leaves.
+	AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error)
 }
 
 // LeafDequeuer provides an interface for reading previously queued leaves for integration into the tree.
 type LeafDequeuer interface {
-	// DequeueLeaves will return between [0, limit] leaves from the queue.
+	// DequeueLeaves will return between [0, limit] leaves from the queue. Leaves that can't be
+	// integrated until after nowNanos, see LogLeaf.NotBeforeNanos, are left in it.
 	// Leaves which have been dequeued within a Rolled-back Tx will become available for dequeing again.
-	DequeueLeaves(limit int) ([]trillian.LogLeaf, error)
-	UpdateSequencedLeaves([]trillian.LogLeaf) error
+	// Calling it again in the same Tx returns leaves it hasn't already returned.
+	// Claims are ignored, so only a log's single sequencer should use it, see LeafClaimer.
+	// For a pre-ordered log the leaves have their sequence numbers set and follow on without
+	// gaps from the current tree size.
+	DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error)
+	// DequeueOverdueLeaves is like DequeueLeaves, but only returns leaves queued before
+	// queuedBeforeNanos, and the longest queued first whatever their priority. It's used to
+	// catch up on leaves that would otherwise be left out past the max merge delay, and
+	// never returns any for a pre-ordered log.
+	DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error)
+	UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error
+	// OldestQueuedLeafNanos returns when the longest queued of the leaves that can be
+	// integrated at nowNanos was queued, in nanoseconds since the epoch, or 0 if there are
+	// none. Leaves dequeued by the transaction aren't counted, but those claimed by other
+	// sequencers are. It's always 0 for a pre-ordered log.
+	OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error)
+}
+
+// LeafClaim is a sequencer's lease on queued leaves. Until it expires the other sequencers
+// sharing the queue leave the claimed leaves alone.
+type LeafClaim struct {
+	// Owner identifies the sequencer, each one sharing a queue must have its own.
+	Owner string
+	// ExpiryNanos is when the claim lapses, in nanoseconds since the epoch.
+	ExpiryNanos int64
+}
+
+// LeafClaimer provides an interface for several sequencers to share a log's queue. Each
+// claims leaves in a transaction of its own, which makes the claims visible to the others
+// once it's committed, then dequeues the leaves it still holds in the transaction that
+// integrates them, and they're removed from the queue when that's committed. A sequencer that
+// fails in between leaves its leaves queued, to be claimed by another once its claims expire.
+type LeafClaimer interface {
+	// ClaimLeaves claims up to limit queued leaves for claim.Owner and returns them. Leaves
+	// that no one holds, that the owner already holds or whose claims expired before nowNanos
+	// can be claimed, as long as they can be integrated by then. For a pre-ordered log the
+	// leaves follow on without gaps from the current tree size, so claiming stops at a leaf
+	// held by another owner.
+	ClaimLeaves(ctx context.Context, claim LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error)
+	// DequeueClaimedLeaves is DequeueLeaves for the leaves held by owner, it returns between
+	// [0, limit] of them. Leaves whose claims expired and were taken by another owner aren't
+	// returned, and can't be taken from owner before the transaction ends.
+	DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error)
 }
 
 // LeafReader provides a read only interface to stored tree leaves
 type LeafReader interface {
 	// GetSequencedLeafCount returns the total number of leaves that have been integrated into the
 	// tree via sequencing.
-	GetSequencedLeafCount() (int64, error)
+	GetSequencedLeafCount(ctx context.Context) (int64, error)
 	// GetLeavesByIndex returns leaf metadata and data for a set of specified sequenced leaf indexes.
-	GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error)
+	GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error)
+	// GetLeavesByRange returns leaf metadata and data for up to count sequenced leaves starting
+	// at index start, in sequence number order. Fewer leaves are returned if the range extends
+	// beyond the leaves which have been sequenced.
+	GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error)
 	// GetLeavesByHash looks up sequenced leaf metadata and data by their hash. If the tree permits
 	// duplicate leaves callers must be prepared to handle multiple results with the same hash
 	// but different sequence numbers. If orderBySequence is true then the returned data
 	// will be in sequence number order.
-	GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error)
+	GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error)
+	// GetLeavesByIdentityHash looks up sequenced leaf metadata and data by the identity hashes
+	// they were queued with. Leaves with different leaf hashes can share an identity hash, so
+	// there may be several results for each. Storage keeps one identity hash for each leaf
+	// hash, which may be that of the first or of the latest copy of the leaf stored. If
+	// orderBySequence is true then the returned data will be in sequence number order.
+	GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error)
 }
 
 // LogRootReader provides an interface for reading SignedLogRoots.
 type LogRootReader interface {
 	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any.
-	LatestSignedLogRoot() (trillian.SignedLogRoot, error)
+	LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error)
+	// GetSignedLogRoot returns the SignedLogRoot created at timestampNanos, or
+	// ErrLogRootNotFound if there isn't one.
+	GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error)
 }
 
 // LogRootWriter provides an interface for storing new SignedLogRoots.
 type LogRootWriter interface {
 	// StoreSignedLogRoot stores a freshly created SignedLogRoot.
-	StoreSignedLogRoot(root trillian.SignedLogRoot) error
+	StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error
+}
+
+// CosignatureReader provides an interface for reading the cosignatures that witnesses have
+// made over SignedLogRoots.
+type CosignatureReader interface {
+	// GetCosignatures returns the cosignatures stored for the SignedLogRoot created at
+	// rootTimestampNanos, ordered by witness ID.
+	GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error)
+}
+
+// CosignatureWriter provides an interface for storing witness cosignatures.
+type CosignatureWriter interface {
+	// AddCosignature stores a cosignature over the SignedLogRoot created at rootTimestampNanos.
+	// The caller must have verified it. A witness has at most one cosignature for each root so
+	// a later one from the same witness replaces the earlier one.
+	AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error
+}
+
+// SequencerCheckpoint is the progress the sequencer saves with each root it stores: the
+// revision of the root and the state of the compact merkle tree for it. A sequencer that
+// starts with a checkpoint for the latest root carries on from there without reading nodes
+// back from the tree.
+type SequencerCheckpoint struct {
+	// TreeRevision is the revision of the root the checkpoint was stored with.
+	TreeRevision int64
+	// CompactTree is the state of the compact merkle tree at the root's tree size.
+	CompactTree *CompactRangeProto
+}
+
+// SequencerCheckpointer provides an interface for reading and storing the sequencer's
+// checkpoint. There's only one checkpoint for each log, a new one replaces the last.
+type SequencerCheckpointer interface {
+	// GetSequencerCheckpoint returns the last checkpoint stored for the log, or nil if
+	// there isn't one.
+	GetSequencerCheckpoint(ctx context.Context) (*SequencerCheckpoint, error)
+	// StoreSequencerCheckpoint replaces the log's checkpoint. It must be stored in the same
+	// transaction as the root it was made for, so that it's never ahead of or behind the
+	// stored nodes.
+	StoreSequencerCheckpoint(ctx context.Context, checkpoint SequencerCheckpoint) error
 }
 
 // LogMetadata provides access to information about the logs in storage
 type LogMetadata interface {
 	// GetActiveLogs returns a list of the IDs of all the logs that are configured in storage
-	GetActiveLogIDs() ([]trillian.LogID, error)
+	GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error)
 	// GetActiveLogIDsWithPendingWork returns a list of IDs of logs that have
 	// pending queued leaves that need to be integrated into the log.
-	GetActiveLogIDsWithPendingWork() ([]trillian.LogID, error)
+	GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error)
 }
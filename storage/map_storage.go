@@ -1,14 +1,34 @@
 package storage
 
 import (
+	"errors"
+
 	"github.com/google/trillian"
+	"golang.org/x/net/context"
 )
 
+// ErrMapRootNotFound is returned when there is no SignedMapRoot for a requested revision.
+var ErrMapRootNotFound = errors.New("storage: map root not found")
+
+// ErrMapRevisionConflict is returned when a transaction writes to a map revision that
+// another transaction has already written. The transaction must be rolled back, and the
+// write can be retried at the next revision.
+var ErrMapRevisionConflict = errors.New("storage: map revision has already been written")
+
 // ReadOnlyMapTX provides a read-only view into the Map data.
 type ReadOnlyMapTX interface {
 	ReadOnlyTreeTX
 	MapRootReader
 	Getter
+	MapKeyReader
+	MapMutationReader
+}
+
+// ReadOnlyMapTreeTX is a read-only view into the Map data pinned to one of its revisions,
+// see ReadOnlyMapStorage.SnapshotForTree.
+type ReadOnlyMapTreeTX interface {
+	ReadOnlyMapTX
+	TreeSnapshot
 }
 
 // MapTX is the transactional interface for reading/modifying a Map.
@@ -21,6 +41,10 @@ type MapTX interface {
 	MapRootWriter
 	Getter
 	Setter
+	MapKeyReader
+	MapKeyWriter
+	MapMutationReader
+	MapMutationWriter
 }
 
 // ReadOnlyMapStorage provides a narrow read-only view into a MapStorage.
@@ -28,11 +52,23 @@ type ReadOnlyMapStorage interface {
 	// Snapshot starts a new read-only transaction.
 	// Commit must be called when the caller is finished with the returned object,
 	// and values read through it should only be propagated if Commit returns
-	// without error.
-	Snapshot() (ReadOnlyMapTX, error)
+	// without error. Work done through the transaction is traced as part of ctx, and it's
+	// abandoned if ctx is cancelled before it's committed.
+	Snapshot(ctx context.Context) (ReadOnlyMapTX, error)
+
+	// SnapshotForTree starts a read-only transaction pinned to a revision of the map, or
+	// to the latest published revision if revision is < 0. ErrMapRootNotFound is returned
+	// if there's no SignedMapRoot for the revision. All reads through it see the map as it
+	// was when the transaction started, however long it's open for, and they don't take
+	// locks that would block writers. Commit must be called when the caller is finished
+	// with the returned object.
+	SnapshotForTree(ctx context.Context, revision int64) (ReadOnlyMapTreeTX, error)
 
 	// Returns the MapID this storage relates to.
 	MapID() trillian.MapID
+
+	// HashAlgorithm returns the hash algorithm the map was created with.
+	HashAlgorithm() trillian.HashAlgorithm
 }
 
 // MapStorage should be implemented by concrete storage mechanisms which want to support Maps
@@ -41,14 +77,16 @@ type MapStorage interface {
 	// Begin starts a new Map transaction.
 	// Either Commit or Rollback must be called when the caller is finished with
 	// the returned object, and values read through it should only be propagated
-	// if Commit returns without error.
-	Begin() (MapTX, error)
+	// if Commit returns without error. Work done through the transaction is traced as
+	// part of ctx, and it's rolled back if ctx is cancelled before it's committed.
+	Begin(ctx context.Context) (MapTX, error)
 }
 
 // Setter allows the setting of key->value pairs on the map.
 type Setter interface {
-	// Set sets key to leaf
-	Set(keyHash trillian.Hash, value trillian.MapLeaf) error
+	// Set sets key to leaf. It returns ErrMapRevisionConflict if another transaction has
+	// set key at the same revision.
+	Set(ctx context.Context, keyHash trillian.Hash, value trillian.MapLeaf) error
 }
 
 // Getter allows access to the values stored in the map.
@@ -59,17 +97,63 @@ type Getter interface {
 	// The returned array of MapLeaves will only contain entries for which values
 	// exist.  i.e. requesting a set of unknown keys would result in a
 	// zero-length array being returned.
-	Get(revision int64, keyHash []trillian.Hash) ([]trillian.MapLeaf, error)
+	Get(ctx context.Context, revision int64, keyHash []trillian.Hash) ([]trillian.MapLeaf, error)
+
+	// GetHistory returns the values written to keyHash at revisions in the range
+	// [startRevision, endRevision], ordered by revision. A value which was cleared
+	// is returned as a MapLeaf with only the KeyHash set.
+	GetHistory(ctx context.Context, keyHash trillian.Hash, startRevision, endRevision int64) ([]MapLeafRevision, error)
+}
+
+// MapKeyReader provides access to the keys which have been set in the map, in key order.
+type MapKeyReader interface {
+	// GetKeys returns up to count of the keys from start up to, but not including, end in
+	// order. A nil end means there's no upper limit. Keys are returned whether or not they
+	// have a value at the revision being read, and keys set before the key index was added
+	// to the schema are only returned once they've been set again.
+	GetKeys(ctx context.Context, start, end trillian.Key, count int) ([]trillian.Key, error)
+}
+
+// MapKeyWriter allows the keys set in the map to be recorded, so they can be listed in order.
+type MapKeyWriter interface {
+	// StoreKey records that key has been set. Storing a key that's already recorded
+	// does nothing.
+	StoreKey(ctx context.Context, key trillian.Key) error
+}
+
+// MapLeafRevision is a value of a map key and the revision it was written at.
+type MapLeafRevision struct {
+	Revision int64
+	Leaf     trillian.MapLeaf
 }
 
 // MapRootReader provides access to the map roots.
 type MapRootReader interface {
 	// LatestSignedMapRoot returns the most recently created SignedMapRoot.
-	LatestSignedMapRoot() (trillian.SignedMapRoot, error)
+	LatestSignedMapRoot(ctx context.Context) (trillian.SignedMapRoot, error)
+
+	// GetSignedMapRoot returns the SignedMapRoot created for revision, or
+	// ErrMapRootNotFound if there isn't one.
+	GetSignedMapRoot(ctx context.Context, revision int64) (trillian.SignedMapRoot, error)
 }
 
 // MapRootWriter allows the storage of new SignedMapRoots
 type MapRootWriter interface {
-	// StoreSignedMapRoot stores root.
-	StoreSignedMapRoot(root trillian.SignedMapRoot) error
+	// StoreSignedMapRoot stores root. It returns ErrMapRevisionConflict if another
+	// transaction has stored a root for the same revision.
+	StoreSignedMapRoot(ctx context.Context, root trillian.SignedMapRoot) error
+}
+
+// MapMutationReader provides access to the values written at each revision of the map.
+type MapMutationReader interface {
+	// GetMutations returns up to count of the mutations stored for revisions from
+	// startRevision onwards, ordered by revision. Revisions written before mutations were
+	// recorded don't have one.
+	GetMutations(ctx context.Context, startRevision int64, count int) ([]trillian.MapMutation, error)
+}
+
+// MapMutationWriter allows the values written at a revision of the map to be recorded.
+type MapMutationWriter interface {
+	// StoreMutation stores mutation. There can only be one for each revision.
+	StoreMutation(ctx context.Context, mutation trillian.MapMutation) error
 }
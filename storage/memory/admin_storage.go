@@ -0,0 +1,269 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+type memoryAdminStorage struct {
+	db *database
+}
+
+// Begin starts a transaction that works on a copy of the tree metadata, which replaces the
+// stored metadata when it's committed. Only one can be open at a time for a database.
+func (m *memoryAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	m.db.adminMutex.Lock()
+	return m.beginInternal(true), nil
+}
+
+func (m *memoryAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return m.beginInternal(false), nil
+}
+
+func (m *memoryAdminStorage) beginInternal(write bool) *adminTX {
+	m.db.mutex.RLock()
+	defer m.db.mutex.RUnlock()
+
+	t := &adminTX{
+		db:    m.db,
+		write: write,
+		trees: make(map[int64]*trillian.Tree, len(m.db.trees)),
+		usage: make(map[int64]*trillian.TreeUsage, len(m.db.usage)),
+	}
+
+	// The stored values are never changed, updates replace them, so only the maps are copied
+	for id, tree := range m.db.trees {
+		t.trees[id] = tree
+	}
+	for id, usage := range m.db.usage {
+		t.usage[id] = usage
+	}
+
+	return t
+}
+
+type adminTX struct {
+	db     *database
+	write  bool
+	closed bool
+	trees  map[int64]*trillian.Tree
+	usage  map[int64]*trillian.TreeUsage
+	// deleted holds the IDs of the trees hard deleted by the transaction
+	deleted []int64
+}
+
+func (t *adminTX) Commit() error {
+	if t.closed {
+		return ErrTxClosed
+	}
+
+	t.closed = true
+
+	if !t.write {
+		return nil
+	}
+
+	t.db.mutex.Lock()
+	t.db.trees = t.trees
+	t.db.usage = t.usage
+	for _, id := range t.deleted {
+		delete(t.db.data, id)
+	}
+	t.db.mutex.Unlock()
+
+	t.db.adminMutex.Unlock()
+	return nil
+}
+
+func (t *adminTX) Rollback() error {
+	if t.closed {
+		return ErrTxClosed
+	}
+
+	t.closed = true
+
+	if t.write {
+		t.db.adminMutex.Unlock()
+	}
+
+	return nil
+}
+
+func (t *adminTX) GetTree(treeID int64) (*trillian.Tree, error) {
+	tree, ok := t.trees[treeID]
+
+	if !ok {
+		return nil, storage.ErrTreeNotFound
+	}
+
+	copied := *tree
+	return &copied, nil
+}
+
+func (t *adminTX) ListTrees(includeDeleted bool) ([]*trillian.Tree, error) {
+	trees := make([]*trillian.Tree, 0)
+
+	for _, id := range sortedTreeIDs(t.trees) {
+		if tree := t.trees[id]; includeDeleted || !tree.Deleted {
+			copied := *tree
+			trees = append(trees, &copied)
+		}
+	}
+
+	return trees, nil
+}
+
+func (t *adminTX) GetTreeUsage(treeID int64) (*trillian.TreeUsage, error) {
+	usage, ok := t.usage[treeID]
+
+	if !ok {
+		return &trillian.TreeUsage{TreeId: treeID}, nil
+	}
+
+	copied := *usage
+	return &copied, nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (t *adminTX) CreateTree(tree *trillian.Tree) (*trillian.Tree, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.TreeState = trillian.TreeState_ACTIVE
+	newTree.CreateTimeMillis = nowMillis()
+	newTree.UpdateTimeMillis = newTree.CreateTimeMillis
+
+	stored := newTree
+	t.trees[id] = &stored
+
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		tree := *orig
+		updateFunc(&tree)
+
+		if err := storage.ValidateTreeForUpdate(orig, &tree); err != nil {
+			return nil, err
+		}
+
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) SoftDeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if orig.Deleted {
+			return nil, storage.ErrTreeDeleted
+		}
+
+		tree := *orig
+		tree.Deleted = true
+		tree.DeleteTimeMillis = nowMillis()
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) UndeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if !orig.Deleted {
+			return nil, storage.ErrTreeNotDeleted
+		}
+
+		tree := *orig
+		tree.Deleted = false
+		tree.DeleteTimeMillis = 0
+		return &tree, nil
+	})
+}
+
+// updateTree stores the tree returned by f for the tree with the specified ID. As with the
+// database storage systems, only the mutable settings are taken from it.
+func (t *adminTX) updateTree(treeID int64, f func(orig *trillian.Tree) (*trillian.Tree, error)) (*trillian.Tree, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	orig, err := t.GetTree(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := f(orig)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *orig
+	stored.TreeState = tree.TreeState
+	stored.DisplayName = tree.DisplayName
+	stored.Description = tree.Description
+	stored.UpdateTimeMillis = nowMillis()
+	stored.Deleted = tree.Deleted
+	stored.DeleteTimeMillis = tree.DeleteTimeMillis
+	t.trees[treeID] = &stored
+
+	updated := stored
+	return &updated, nil
+}
+
+func (t *adminTX) AddTreeUsage(usage *trillian.TreeUsage) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	total := trillian.TreeUsage{TreeId: usage.TreeId}
+
+	if stored, ok := t.usage[usage.TreeId]; ok {
+		total = *stored
+	}
+
+	total.Requests += usage.Requests
+	total.BytesIn += usage.BytesIn
+	total.BytesOut += usage.BytesOut
+	total.LeavesQueued += usage.LeavesQueued
+	total.UpdateTimeMillis = nowMillis()
+	t.usage[usage.TreeId] = &total
+
+	return nil
+}
+
+func (t *adminTX) HardDeleteTree(treeID int64) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	tree, err := t.GetTree(treeID)
+	if err != nil {
+		return err
+	}
+
+	if !tree.Deleted {
+		return storage.ErrTreeNotDeleted
+	}
+
+	delete(t.trees, treeID)
+	delete(t.usage, treeID)
+	t.deleted = append(t.deleted, treeID)
+
+	return nil
+}
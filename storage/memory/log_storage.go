@@ -0,0 +1,969 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+type memoryLogStorage struct {
+	*memoryTreeStorage
+
+	logID           trillian.LogID
+	allowDuplicates bool
+	treeType        trillian.TreeType
+}
+
+// newLogStorage creates the storage for a log. Logs that weren't created through the admin
+// storage are normal logs that don't allow duplicate leaves, as in the database storage
+// systems.
+func newLogStorage(db *database, id trillian.LogID) (storage.LogStorage, error) {
+	ts, err := newTreeStorage(db, id.TreeID, cache.PopulateLogSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+
+	s := memoryLogStorage{
+		memoryTreeStorage: ts,
+		logID:             id,
+		treeType:          trillian.TreeType_LOG,
+	}
+
+	if tree := db.tree(id.TreeID); tree != nil {
+		s.allowDuplicates = tree.AllowDuplicateLeaves
+		s.treeType = tree.TreeType
+	}
+
+	return &s, nil
+}
+
+// TreeType returns the type the log was created with.
+func (m *memoryLogStorage) TreeType() trillian.TreeType {
+	return m.treeType
+}
+
+func (m *memoryLogStorage) beginInternal(ctx context.Context, write bool) (*logTX, error) {
+	tx := &logTX{
+		treeTX:       m.beginTreeTx(ctx, write),
+		ls:           m,
+		dequeued:     make(map[int64]bool),
+		claims:       make(map[int64]storage.LeafClaim),
+		sequenced:    make(map[int64]trillian.LogLeaf),
+		cosignatures: make(map[int64]map[string]trillian.Cosignature),
+	}
+
+	if err := tx.checkTreeState(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (m *memoryLogStorage) Begin(ctx context.Context) (storage.LogTX, error) {
+	tx, err := m.beginInternal(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.treeTX.writeRevision = root.TreeRevision + 1
+
+	return tx, nil
+}
+
+func (m *memoryLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := m.beginInternal(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Nothing is written through a snapshot, this only makes it read the latest subtrees
+	tx.treeTX.writeRevision = root.TreeRevision + 1
+
+	return tx, nil
+}
+
+func (m *memoryLogStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := m.beginInternal(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, size, err := tx.getTreeRevisionCoveringSize(treeSize)
+	if err != nil {
+		glog.Warningf("Failed to find revision for tree size %d: %s", treeSize, err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = rev
+	tx.treeSize = size
+
+	return tx, nil
+}
+
+// logTX keeps the changes made through it until it's committed, and reads see them on top of
+// what has been committed, as they would in a database transaction.
+type logTX struct {
+	treeTX
+	ls *memoryLogStorage
+
+	// treeSize is the size of the tree a snapshot is pinned to
+	treeSize int64
+
+	// queued holds the leaves queued or added by the transaction
+	queued []queuedLeaf
+	// dequeued holds the IDs of the committed queued leaves dequeued by the transaction
+	dequeued map[int64]bool
+	// claims holds the claims made by the transaction, by queue ID
+	claims map[int64]storage.LeafClaim
+	// sequenced holds the leaves integrated by the transaction, by sequence number
+	sequenced map[int64]trillian.LogLeaf
+	// roots holds the roots stored by the transaction
+	roots []trillian.SignedLogRoot
+	// cosignatures holds the cosignatures stored by the transaction, by root timestamp
+	// and witness
+	cosignatures map[int64]map[string]trillian.Cosignature
+	// checkpoint is the sequencer checkpoint stored by the transaction, if any
+	checkpoint *storage.SequencerCheckpoint
+}
+
+func (t *logTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *logTX) TreeSize() int64 {
+	return t.treeSize
+}
+
+func (t *logTX) Commit() error {
+	if err := t.flush(); err != nil {
+		t.Rollback()
+		return err
+	}
+
+	if t.write {
+		t.ts.db.mutex.Lock()
+		t.apply()
+		t.ts.db.mutex.Unlock()
+	}
+
+	return t.close("commit")
+}
+
+func (t *logTX) Rollback() error {
+	return t.close("rollback")
+}
+
+// apply stores the changes made through the transaction. The database mutex must be held.
+func (t *logTX) apply() {
+	data := t.ts.data
+
+	t.commitSubtrees()
+
+	queue := make([]queuedLeaf, 0, len(data.queue)+len(t.queued))
+	for _, q := range data.queue {
+		if !t.dequeued[q.id] {
+			queue = append(queue, q)
+		}
+	}
+	for _, q := range t.queued {
+		if !t.dequeued[q.id] {
+			queue = append(queue, q)
+		}
+	}
+	data.queue = queue
+
+	for id, claim := range t.claims {
+		data.claims[id] = claim
+	}
+	for id := range t.dequeued {
+		delete(data.claims, id)
+	}
+
+	for seq, leaf := range t.sequenced {
+		data.sequenced[seq] = leaf
+		hash := string(leaf.LeafHash)
+		data.leafIndices[hash] = append(data.leafIndices[hash], seq)
+
+		if len(leaf.LeafIdentityHash) > 0 {
+			identity := string(leaf.LeafIdentityHash)
+			data.identityIndices[identity] = append(data.identityIndices[identity], seq)
+		}
+	}
+
+	data.roots = append(data.roots, t.roots...)
+
+	if t.checkpoint != nil {
+		data.checkpoint = t.checkpoint
+	}
+
+	for timestamp, witnesses := range t.cosignatures {
+		if data.cosignatures[timestamp] == nil {
+			data.cosignatures[timestamp] = make(map[string]trillian.Cosignature)
+		}
+		for witnessID, cosignature := range witnesses {
+			data.cosignatures[timestamp][witnessID] = cosignature
+		}
+	}
+}
+
+// visibleQueue returns the leaves waiting to be sequenced as the transaction sees them,
+// oldest first
+func (t *logTX) visibleQueue() []queuedLeaf {
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	queue := make([]queuedLeaf, 0, len(t.ts.data.queue)+len(t.queued))
+	for _, q := range t.ts.data.queue {
+		if !t.dequeued[q.id] {
+			queue = append(queue, q)
+		}
+	}
+	for _, q := range t.queued {
+		if !t.dequeued[q.id] {
+			queue = append(queue, q)
+		}
+	}
+
+	return queue
+}
+
+// queue adds a leaf to the leaves queued by the transaction
+func (t *logTX) queue(leaf trillian.LogLeaf) {
+	t.ts.db.mutex.Lock()
+	id := t.ts.data.nextQueueID
+	t.ts.data.nextQueueID++
+	t.ts.db.mutex.Unlock()
+
+	t.queued = append(t.queued, queuedLeaf{id: id, leaf: leaf, timestampNanos: time.Now().UnixNano()})
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	leaves, ids, err := t.queuedLeaves(ctx, limit, nowNanos, func(int64) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	t.dequeue(ids)
+
+	return leaves, nil
+}
+
+func (t *logTX) DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	// The leaves of a pre-ordered log are integrated in order, so none are overdue
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, nil
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	ids := make([]int64, 0, limit)
+
+	// The queue is in the order the leaves were queued, so the longest queued come first
+	for _, q := range t.visibleQueue() {
+		if len(leaves) >= limit {
+			break
+		}
+
+		if q.timestampNanos >= queuedBeforeNanos || q.leaf.NotBeforeNanos > nowNanos {
+			continue
+		}
+
+		if len(q.leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		leaf := q.leaf
+		leaf.ExtraData = nil
+		leaf.SequenceNumber = 0
+		leaf.QueueTimestampNanos = q.timestampNanos
+		leaves = append(leaves, leaf)
+		ids = append(ids, q.id)
+	}
+
+	t.dequeue(ids)
+
+	return leaves, nil
+}
+
+func (t *logTX) OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return 0, nil
+	}
+
+	var oldest int64
+	for _, q := range t.visibleQueue() {
+		if q.leaf.NotBeforeNanos <= nowNanos && (oldest == 0 || q.timestampNanos < oldest) {
+			oldest = q.timestampNanos
+		}
+	}
+
+	return oldest, nil
+}
+
+// dequeue removes the queued leaves with ids from the queue once the transaction is committed
+func (t *logTX) dequeue(ids []int64) {
+	// As in the database storage systems, the dequeued leaves are only removed from the
+	// queue if the transaction is committed
+	for _, id := range ids {
+		t.dequeued[id] = true
+	}
+
+	storage.QueuedLeaves.Set(float64(len(t.visibleQueue())), "memory", strconv.FormatInt(t.ls.logID.TreeID, 10))
+}
+
+func (t *logTX) ClaimLeaves(ctx context.Context, claim storage.LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	leaves, ids, err := t.queuedLeaves(ctx, limit, nowNanos, func(id int64) bool {
+		held, ok := t.claimOf(id)
+		return !ok || held.Owner == claim.Owner || held.ExpiryNanos < nowNanos
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		t.claims[id] = claim
+	}
+
+	return leaves, nil
+}
+
+func (t *logTX) DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	// The transaction holds the tree's writer lock, so no one can take the claims until it ends.
+	// Leaves could be integrated when they were claimed, so their not-before times have passed.
+	leaves, ids, err := t.queuedLeaves(ctx, limit, math.MaxInt64, func(id int64) bool {
+		held, ok := t.claimOf(id)
+		return ok && held.Owner == owner
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.dequeue(ids)
+
+	return leaves, nil
+}
+
+// claimOf returns the claim on the queued leaf with id as the transaction sees it, if it has one
+func (t *logTX) claimOf(id int64) (storage.LeafClaim, bool) {
+	if claim, ok := t.claims[id]; ok {
+		return claim, true
+	}
+
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	claim, ok := t.ts.data.claims[id]
+	return claim, ok
+}
+
+// queuedLeaves returns up to limit of the queued leaves that take accepts and can be integrated
+// at nowNanos, highest priority then oldest first, and their queue IDs
+func (t *logTX) queuedLeaves(ctx context.Context, limit int, nowNanos int64, take func(id int64) bool) ([]trillian.LogLeaf, []int64, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return t.queuedSequencedLeaves(ctx, limit, take)
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	ids := make([]int64, 0, limit)
+
+	queue := t.visibleQueue()
+	sort.Stable(byPriority(queue))
+
+	for _, q := range queue {
+		if len(leaves) >= limit {
+			break
+		}
+
+		if q.leaf.NotBeforeNanos > nowNanos || !take(q.id) {
+			continue
+		}
+
+		if len(q.leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, nil, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		leaf := q.leaf
+		leaf.ExtraData = nil
+		leaf.SequenceNumber = 0
+		leaf.QueueTimestampNanos = q.timestampNanos
+		leaves = append(leaves, leaf)
+		ids = append(ids, q.id)
+	}
+
+	return leaves, ids, nil
+}
+
+// byPriority sorts queued leaves with the highest priority first
+type byPriority []queuedLeaf
+
+func (b byPriority) Len() int           { return len(b) }
+func (b byPriority) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPriority) Less(i, j int) bool { return b[i].leaf.Priority > b[j].leaf.Priority }
+
+// queuedSequencedLeaves returns the leaves added to a pre-ordered log that follow on from the
+// current tree size, stopping at the first missing sequence number or leaf take doesn't accept.
+func (t *logTX) queuedSequencedLeaves(ctx context.Context, limit int, take func(id int64) bool) ([]trillian.LogLeaf, []int64, error) {
+	root, err := t.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queued := make(map[int64]queuedLeaf)
+	for _, q := range t.visibleQueue() {
+		queued[q.leaf.SequenceNumber] = q
+	}
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+	ids := make([]int64, 0, limit)
+
+	// The tree can only grow up to a gap, the leaves after it wait until it's filled
+	for seq := root.TreeSize; len(leaves) < limit; seq++ {
+		q, ok := queued[seq]
+		if !ok || !take(q.id) {
+			break
+		}
+
+		if len(q.leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, nil, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		leaf := q.leaf
+		leaf.ExtraData = nil
+		leaves = append(leaves, leaf)
+		ids = append(ids, q.id)
+	}
+
+	return leaves, ids, nil
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Queued leaf cannot have an empty signature")
+		}
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+
+	for i, leaf := range leaves {
+		// If the log doesn't allow duplicates then resubmitting a leaf returns the copy that's
+		// already there. This also catches repeats within the batch, as the earlier copies
+		// have already been queued in this transaction.
+		if !t.ls.allowDuplicates {
+			existing, err := t.getExistingLeaf(ctx, leaf.LeafHash)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				existingLeaves[i] = existing
+				continue
+			}
+		}
+
+		leaf.SequenceNumber = -1
+		t.queue(leaf)
+	}
+
+	return existingLeaves, nil
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if !t.write {
+		return nil, storage.ErrReadOnly
+	}
+
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrNotPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Sequenced leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SequenceNumber < 0 {
+			return nil, fmt.Errorf("Sequenced leaf must have a sequence number >= 0, got %d", leaf.SequenceNumber)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Sequenced leaf cannot have an empty signature")
+		}
+	}
+
+	root, err := t.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+
+	for i, leaf := range leaves {
+		// A position can only be filled once, whether or not it has been integrated yet
+		existing, err := t.getLeafAtSequenceNumber(ctx, leaf.SequenceNumber, root.TreeSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			existingLeaves[i] = existing
+			continue
+		}
+
+		t.queue(leaf)
+	}
+
+	return existingLeaves, nil
+}
+
+// getLeafAtSequenceNumber returns the leaf that has been added to a pre-ordered log at seq, or
+// nil if there isn't one. Leaves below treeSize have already been integrated.
+func (t *logTX) getLeafAtSequenceNumber(ctx context.Context, seq, treeSize int64) (*trillian.LogLeaf, error) {
+	if seq < treeSize {
+		sequenced, err := t.GetLeavesByIndex(ctx, []int64{seq})
+		if err != nil {
+			return nil, err
+		}
+
+		return &sequenced[0], nil
+	}
+
+	for _, q := range t.visibleQueue() {
+		if q.leaf.SequenceNumber == seq {
+			leaf := q.leaf
+			leaf.ExtraData = nil
+			return &leaf, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// getExistingLeaf returns the leaf in the log with leafHash, or nil if there isn't one. If the
+// leaf has been sequenced more than once the earliest copy is returned.
+func (t *logTX) getExistingLeaf(ctx context.Context, leafHash trillian.Hash) (*trillian.LogLeaf, error) {
+	sequenced, err := t.GetLeavesByHash(ctx, []trillian.Hash{leafHash}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sequenced) > 0 {
+		return &sequenced[0], nil
+	}
+
+	for _, q := range t.visibleQueue() {
+		if bytes.Equal(q.leaf.LeafHash, leafHash) {
+			leaf := q.leaf
+			leaf.ExtraData = nil
+			leaf.SequenceNumber = -1
+			return &leaf, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// sequencedLeaf returns the integrated leaf at seq as the transaction sees it
+func (t *logTX) sequencedLeaf(seq int64) (trillian.LogLeaf, bool) {
+	if leaf, ok := t.sequenced[seq]; ok {
+		return leaf, true
+	}
+
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	leaf, ok := t.ts.data.sequenced[seq]
+	return leaf, ok
+}
+
+func (t *logTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	return int64(len(t.ts.data.sequenced) + len(t.sequenced)), nil
+}
+
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	ret := make([]trillian.LogLeaf, 0, len(leaves))
+
+	for _, seq := range leaves {
+		leaf, ok := t.sequencedLeaf(seq)
+		if !ok {
+			return nil, fmt.Errorf("expected %d leaves, but saw %d", len(leaves), len(ret))
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	return t.getLeavesByHashes(leafHashes, t.ts.data.leafIndices, func(leaf trillian.LogLeaf) trillian.Hash { return leaf.LeafHash })
+}
+
+func (t *logTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	return t.getLeavesByHashes(identityHashes, t.ts.data.identityIndices, func(leaf trillian.LogLeaf) trillian.Hash { return leaf.LeafIdentityHash })
+}
+
+// getLeavesByHashes returns the sequenced leaves with any of hashes, looking up the
+// integrated leaves in index and the ones sequenced by the transaction with hashOf. The
+// index is guarded by the database mutex.
+func (t *logTX) getLeavesByHashes(hashes []trillian.Hash, index map[string][]int64, hashOf func(trillian.LogLeaf) trillian.Hash) ([]trillian.LogLeaf, error) {
+	var seqs []int64
+
+	t.ts.db.mutex.RLock()
+	for _, hash := range hashes {
+		seqs = append(seqs, index[string(hash)]...)
+	}
+	t.ts.db.mutex.RUnlock()
+
+	for seq, leaf := range t.sequenced {
+		for _, hash := range hashes {
+			if h := hashOf(leaf); len(h) > 0 && bytes.Equal(h, hash) {
+				seqs = append(seqs, seq)
+				break
+			}
+		}
+	}
+
+	// Leaves are always returned in sequence number order, which is also what's needed
+	// when orderBySequence is true
+	sort.Sort(int64s(seqs))
+
+	// The tree could include duplicates so we don't know how many results will be returned
+	ret := make([]trillian.LogLeaf, 0, len(seqs))
+
+	for i, seq := range seqs {
+		// The same sequence number is found more than once if the same hash was asked for twice
+		if i > 0 && seqs[i-1] == seq {
+			continue
+		}
+
+		leaf, ok := t.sequencedLeaf(seq)
+		if !ok {
+			return nil, fmt.Errorf("leaf hash index refers to missing leaf %d", seq)
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	if start < 0 || count <= 0 {
+		return nil, fmt.Errorf("invalid leaf range start=%d count=%d", start, count)
+	}
+
+	ret := make([]trillian.LogLeaf, 0, count)
+
+	for seq := start; seq < start+count; seq++ {
+		leaf, ok := t.sequencedLeaf(seq)
+		if !ok {
+			break
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, nil
+}
+
+// visibleRoots returns the stored roots as the transaction sees them
+func (t *logTX) visibleRoots() []trillian.SignedLogRoot {
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	roots := make([]trillian.SignedLogRoot, 0, len(t.ts.data.roots)+len(t.roots))
+	roots = append(roots, t.ts.data.roots...)
+	return append(roots, t.roots...)
+}
+
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	// It's possible there are no roots for this tree yet
+	var latest trillian.SignedLogRoot
+
+	for i, root := range t.visibleRoots() {
+		if i == 0 || root.TimestampNanos > latest.TimestampNanos {
+			latest = root
+		}
+	}
+
+	return latest, nil
+}
+
+func (t *logTX) GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error) {
+	for _, root := range t.visibleRoots() {
+		if root.TimestampNanos == timestampNanos {
+			return root, nil
+		}
+	}
+
+	return trillian.SignedLogRoot{}, storage.ErrLogRootNotFound
+}
+
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	// Roots are keyed by timestamp, as they are in the database storage systems
+	if _, err := t.GetSignedLogRoot(ctx, root.TimestampNanos); err == nil {
+		err := fmt.Errorf("a signed root with timestamp %d has already been stored", root.TimestampNanos)
+		glog.Warningf("Failed to store signed root: %s", err)
+		return err
+	}
+
+	root.LogId = t.ls.logID.LogID
+	t.roots = append(t.roots, root)
+	return nil
+}
+
+// copyCheckpoint returns a copy of c that shares no memory with it, as a checkpoint read back
+// from a database wouldn't
+func copyCheckpoint(c *storage.SequencerCheckpoint) *storage.SequencerCheckpoint {
+	return &storage.SequencerCheckpoint{
+		TreeRevision: c.TreeRevision,
+		CompactTree:  proto.Clone(c.CompactTree).(*storage.CompactRangeProto),
+	}
+}
+
+func (t *logTX) GetSequencerCheckpoint(ctx context.Context) (*storage.SequencerCheckpoint, error) {
+	if t.checkpoint != nil {
+		return copyCheckpoint(t.checkpoint), nil
+	}
+
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	if t.ts.data.checkpoint == nil {
+		return nil, nil
+	}
+
+	return copyCheckpoint(t.ts.data.checkpoint), nil
+}
+
+func (t *logTX) StoreSequencerCheckpoint(ctx context.Context, checkpoint storage.SequencerCheckpoint) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	if checkpoint.CompactTree == nil {
+		return errors.New("sequencer checkpoint has no compact tree state")
+	}
+
+	t.checkpoint = copyCheckpoint(&checkpoint)
+	return nil
+}
+
+// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
+// It is an error to request tree sizes larger than the currently published tree size.
+// This only works for sizes where there is a stored tree head, the same as the database
+// storage systems.
+func (t *logTX) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
+	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
+	if treeSize <= 0 {
+		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	found := false
+	var treeRevision int64
+
+	for _, root := range t.visibleRoots() {
+		if root.TreeSize == treeSize && (!found || root.TreeRevision > treeRevision) {
+			treeRevision = root.TreeRevision
+			found = true
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("no signed root for tree size %d", treeSize)
+	}
+
+	return treeRevision, nil
+}
+
+// getTreeRevisionCoveringSize returns the revision and size of the tree that a snapshot for
+// treeSize is pinned to, the latest revision of the smallest tree at least treeSize.
+func (t *logTX) getTreeRevisionCoveringSize(treeSize int64) (int64, int64, error) {
+	if treeSize <= 0 {
+		return 0, 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	found := false
+	var treeRevision, size int64
+
+	for _, root := range t.visibleRoots() {
+		if root.TreeSize < treeSize {
+			continue
+		}
+
+		if !found || root.TreeSize < size || (root.TreeSize == size && root.TreeRevision > treeRevision) {
+			treeRevision = root.TreeRevision
+			size = root.TreeSize
+			found = true
+		}
+	}
+
+	if !found {
+		return 0, 0, fmt.Errorf("no signed root for tree size %d or larger", treeSize)
+	}
+
+	return treeRevision, size, nil
+}
+
+func (t *logTX) GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error) {
+	byWitness := make(map[string]trillian.Cosignature)
+
+	t.ts.db.mutex.RLock()
+	for witnessID, cosignature := range t.ts.data.cosignatures[rootTimestampNanos] {
+		byWitness[witnessID] = cosignature
+	}
+	t.ts.db.mutex.RUnlock()
+
+	for witnessID, cosignature := range t.cosignatures[rootTimestampNanos] {
+		byWitness[witnessID] = cosignature
+	}
+
+	witnessIDs := make([]string, 0, len(byWitness))
+	for witnessID := range byWitness {
+		witnessIDs = append(witnessIDs, witnessID)
+	}
+	sort.Strings(witnessIDs)
+
+	cosignatures := []trillian.Cosignature{}
+
+	for _, witnessID := range witnessIDs {
+		cosignatures = append(cosignatures, byWitness[witnessID])
+	}
+
+	return cosignatures, nil
+}
+
+func (t *logTX) AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	if t.cosignatures[rootTimestampNanos] == nil {
+		t.cosignatures[rootTimestampNanos] = make(map[string]trillian.Cosignature)
+	}
+
+	t.cosignatures[rootTimestampNanos][cosignature.WitnessId] = cosignature
+	return nil
+}
+
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+
+		if _, ok := t.sequencedLeaf(leaf.SequenceNumber); ok {
+			err := fmt.Errorf("a leaf has already been sequenced at %d", leaf.SequenceNumber)
+			glog.Warningf("Failed to update sequenced leaves: %s", err)
+			return err
+		}
+
+		t.sequenced[leaf.SequenceNumber] = leaf
+	}
+
+	return nil
+}
+
+// getActiveLogIDs returns the IDs of the logs that are active and, if pendingWork is true,
+// have leaves waiting to be sequenced
+func (t *logTX) getActiveLogIDs(pendingWork bool) []trillian.LogID {
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	logIDs := make([]trillian.LogID, 0, 0)
+
+	for _, id := range sortedTreeIDs(t.ts.db.trees) {
+		tree := t.ts.db.trees[id]
+
+		if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+			continue
+		}
+		if tree.TreeState != trillian.TreeState_ACTIVE || tree.Deleted {
+			continue
+		}
+		if data, ok := t.ts.db.data[id]; pendingWork && (!ok || len(data.queue) == 0) {
+			continue
+		}
+
+		logIDs = append(logIDs, trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId})
+	}
+
+	return logIDs
+}
+
+// GetActiveLogIDs returns a list of the IDs of all configured logs
+func (t *logTX) GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error) {
+	return t.getActiveLogIDs(false), nil
+}
+
+// GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
+// that have queued unsequenced leaves that need to be integrated
+func (t *logTX) GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error) {
+	return t.getActiveLogIDs(true), nil
+}
@@ -0,0 +1,62 @@
+// Package memory is a storage system that keeps trees in memory, for tests and for trying
+// out servers without a database. Nothing is persisted, so everything is lost when the
+// process exits. Only logs are supported.
+package memory
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// ProviderName is the name the in-memory storage system is registered under.
+const ProviderName = "memory"
+
+// ErrMapsUnsupported is returned when storage is requested for a map
+var ErrMapsUnsupported = errors.New("memory: maps are not supported")
+
+func init() {
+	if err := storage.RegisterProvider(ProviderName, newMemoryProvider); err != nil {
+		panic(err)
+	}
+}
+
+var databasesMutex sync.Mutex
+
+// databases holds the trees of each DSN that a provider has been created for
+var databases = make(map[string]*database)
+
+// memoryProvider creates storage for the trees in an in-memory database. Providers created
+// for the same DSN share the database, so servers and tools in the same process see the
+// same trees, and different DSNs keep tests apart.
+type memoryProvider struct {
+	db *database
+}
+
+func newMemoryProvider(dsn string) (storage.Provider, error) {
+	databasesMutex.Lock()
+	defer databasesMutex.Unlock()
+
+	db, ok := databases[dsn]
+
+	if !ok {
+		db = newDatabase()
+		databases[dsn] = db
+	}
+
+	return &memoryProvider{db: db}, nil
+}
+
+func (m *memoryProvider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	return newLogStorage(m.db, id)
+}
+
+func (m *memoryProvider) MapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	return nil, ErrMapsUnsupported
+}
+
+func (m *memoryProvider) AdminStorage() (storage.AdminStorage, error) {
+	return &memoryAdminStorage{db: m.db}, nil
+}
@@ -0,0 +1,854 @@
+package memory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+var signedTimestamp = trillian.SignedEntryTimestamp{
+	TimestampNanos: 1234567890, LogId: []byte("sign"), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+func TestProviderRegistered(t *testing.T) {
+	p1, err := storage.NewProvider(ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create provider %s: %v", ProviderName, err)
+	}
+	p2, err := storage.NewProvider(ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create provider %s: %v", ProviderName, err)
+	}
+
+	// Providers for the same DSN see the same trees
+	tree := createTree(p1, trillian.TreeType_LOG, t)
+
+	atx, err := mustAdminStorage(p2, t).Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	defer atx.Commit()
+
+	if _, err := atx.GetTree(tree.TreeId); err != nil {
+		t.Errorf("GetTree() through a second provider = %v, want the tree", err)
+	}
+
+	if _, err := p1.MapStorage(trillian.MapID{TreeID: 1}); err != ErrMapsUnsupported {
+		t.Errorf("MapStorage() = %v, want %v", err, ErrMapsUnsupported)
+	}
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+
+	nodesToStore := createSomeNodes()
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		tx.(*logTX).treeTX.writeRevision = 100
+
+		// Need to read nodes before attempting to write
+		if _, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
+			t.Fatalf("Failed to store nodes: %s", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		readNodes, err := tx.GetMerkleNodes(ctx, 100, nodeIDsToRead)
+		if err != nil {
+			t.Fatalf("Failed to retrieve nodes: %s", err)
+		}
+
+		if got, want := len(readNodes), len(nodesToStore); got != want {
+			t.Fatalf("Read back %d nodes but expected %d", got, want)
+		}
+
+		for i := range readNodes {
+			if !readNodes[i].NodeID.Equivalent(nodesToStore[i].NodeID) || !bytes.Equal(readNodes[i].Hash, nodesToStore[i].Hash) {
+				t.Fatalf("Read back node %v but expected %v", readNodes[i], nodesToStore[i])
+			}
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		// Nothing had been written before revision 100
+		if readNodes, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil || len(readNodes) != 0 {
+			t.Fatalf("Expected no nodes at revision 99, got: %v %v", readNodes, err)
+		}
+	}
+}
+
+func TestQueueAndDequeueLeaves(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(5, 0)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		// Nothing is visible to others until the transaction is committed
+		snapshot, err := s.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Failed to start snapshot: %v", err)
+		}
+		if ids, err := snapshot.(*logTX).GetActiveLogIDsWithPendingWork(ctx); err != nil || len(ids) != 0 {
+			t.Fatalf("Expected no pending work before commit, got: %v %v", ids, err)
+		}
+		snapshot.Commit()
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		// Leaves dequeued by a transaction that rolls back can be dequeued again
+		if dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(dequeued) != len(leaves) {
+			t.Fatalf("Failed to dequeue leaves: %v %v", dequeued, err)
+		}
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to roll back: %v", err)
+		}
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if ids, err := tx.GetActiveLogIDsWithPendingWork(ctx); err != nil || len(ids) != 1 {
+			t.Fatalf("Expected the log to have pending work, got: %v %v", ids, err)
+		}
+
+		dequeued, err := tx.DequeueLeaves(ctx, 3, time.Now().UnixNano())
+		if err != nil || len(dequeued) != 3 {
+			t.Fatalf("Failed to dequeue leaves: %v %v", dequeued, err)
+		}
+
+		// Leaves are dequeued oldest first
+		for i := range dequeued {
+			if !bytes.Equal(dequeued[i].LeafHash, leaves[i].LeafHash) {
+				t.Fatalf("Dequeued leaf %d was %v but expected %v", i, dequeued[i], leaves[i])
+			}
+			dequeued[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		if count, err := tx.GetSequencedLeafCount(ctx); err != nil || count != 3 {
+			t.Fatalf("Expected the transaction to see 3 sequenced leaves, got: %d %v", count, err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano()); err != nil || len(dequeued) != 2 {
+			t.Fatalf("Expected 2 leaves left to dequeue, got: %v %v", dequeued, err)
+		}
+
+		byIndex, err := tx.GetLeavesByIndex(ctx, []int64{0, 2})
+		if err != nil || len(byIndex) != 2 || !bytes.Equal(byIndex[1].LeafValue, leaves[2].LeafValue) {
+			t.Fatalf("Failed to get leaves by index: %v %v", byIndex, err)
+		}
+
+		if _, err := tx.GetLeavesByIndex(ctx, []int64{3}); err == nil {
+			t.Fatal("GetLeavesByIndex() of an unsequenced leaf succeeded")
+		}
+
+		byHash, err := tx.GetLeavesByHash(ctx, []trillian.Hash{leaves[1].LeafHash}, true)
+		if err != nil || len(byHash) != 1 || byHash[0].SequenceNumber != 1 {
+			t.Fatalf("Failed to get leaf by hash: %v %v", byHash, err)
+		}
+
+		byRange, err := tx.GetLeavesByRange(ctx, 1, 5)
+		if err != nil || len(byRange) != 2 || byRange[0].SequenceNumber != 1 {
+			t.Fatalf("Failed to get leaves by range: %v %v", byRange, err)
+		}
+	}
+}
+
+func TestDequeueLeavesByPriority(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(5, 0)
+
+	// A backfill is queued first, then leaves at the default priority and one above it
+	for i, priority := range []int32{-1, -1, 0, 0, 1} {
+		leaves[i].Priority = priority
+	}
+
+	for _, batch := range [][]trillian.LogLeaf{leaves[:2], leaves[2:4], leaves[4:]} {
+		tx := beginLogTx(s, t)
+		if _, err := tx.QueueLeaves(ctx, batch); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	dequeued, err := tx.DequeueLeaves(ctx, 4, time.Now().UnixNano())
+	if err != nil || len(dequeued) != 4 {
+		t.Fatalf("Failed to dequeue leaves: %v %v", dequeued, err)
+	}
+
+	// Higher priorities come first, then the oldest leaves of each priority
+	for i, want := range []int{4, 2, 3, 0} {
+		if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+			t.Errorf("Dequeued leaf %d was %v but expected %v", i, dequeued[i], leaves[want])
+		}
+	}
+}
+
+func TestDequeueLeavesNotBefore(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(3, 0)
+	leaves[0].NotBeforeNanos = 2000
+
+	tx := beginLogTx(s, t)
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+	commit(tx, t)
+
+	tx = beginLogTx(s, t)
+	defer tx.Commit()
+
+	// The first leaf is held back until its time
+	for _, test := range []struct {
+		nowNanos int64
+		want     []int
+	}{
+		{nowNanos: 1000, want: []int{1, 2}},
+		{nowNanos: 1999, want: []int{}},
+		{nowNanos: 2000, want: []int{0}},
+	} {
+		dequeued, err := tx.DequeueLeaves(ctx, 10, test.nowNanos)
+		if err != nil || len(dequeued) != len(test.want) {
+			t.Fatalf("DequeueLeaves(%d)=%v %v, want %d leaves", test.nowNanos, dequeued, err, len(test.want))
+		}
+
+		for i, want := range test.want {
+			if !bytes.Equal(dequeued[i].LeafHash, leaves[want].LeafHash) {
+				t.Errorf("DequeueLeaves(%d) leaf %d was %v but expected %v", test.nowNanos, i, dequeued[i], leaves[want])
+			}
+		}
+	}
+}
+
+func TestOldestQueuedLeaf(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(3, 0)
+	// The last leaf can't be integrated yet so it isn't counted
+	leaves[2].NotBeforeNanos = math.MaxInt64
+
+	before := time.Now().UnixNano()
+	for _, leaf := range leaves {
+		tx := beginLogTx(s, t)
+		if _, err := tx.QueueLeaves(ctx, []trillian.LogLeaf{leaf}); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	now := time.Now().UnixNano()
+	oldest, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || oldest < before || oldest > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v, want a time from %d to %d", oldest, err, before, now)
+	}
+
+	dequeued, err := tx.DequeueLeaves(ctx, 1, now)
+	if err != nil || len(dequeued) != 1 || dequeued[0].QueueTimestampNanos != oldest {
+		t.Fatalf("DequeueLeaves()=%v %v, want the leaf queued at %d", dequeued, err, oldest)
+	}
+
+	// Leaves the transaction dequeued aren't counted
+	next, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || next < oldest || next > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v after dequeuing, want a time from %d to %d", next, err, oldest, now)
+	}
+
+	if _, err := tx.DequeueLeaves(ctx, 1, now); err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	if last, err := tx.OldestQueuedLeafNanos(ctx, now); err != nil || last != 0 {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v with only a held back leaf queued, want 0", last, err)
+	}
+}
+
+func TestDequeueOverdueLeaves(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+
+	// Higher priority leaves queued after the overdue ones come first for DequeueLeaves
+	const limit = 2
+	leaves := createTestLeaves(3+11*limit+1, 0)
+	for i := range leaves[3:] {
+		leaves[3+i].Priority = 1
+	}
+
+	var queuedBefore int64
+	for i, leaf := range leaves {
+		if i == 3 {
+			queuedBefore = time.Now().UnixNano()
+		}
+
+		tx := beginLogTx(s, t)
+		if _, err := tx.QueueLeaves(ctx, []trillian.LogLeaf{leaf}); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	now := time.Now().UnixNano()
+	dequeued, err := tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now)
+	if err != nil || len(dequeued) != 2 || !bytes.Equal(dequeued[0].LeafHash, leaves[0].LeafHash) || !bytes.Equal(dequeued[1].LeafHash, leaves[1].LeafHash) {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v, want leaves 0 and 1", dequeued, err)
+	}
+
+	// Leaves the transaction dequeued aren't returned again
+	dequeued, err = tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now)
+	if err != nil || len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafHash, leaves[2].LeafHash) {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v, want leaf 2", dequeued, err)
+	}
+
+	if dequeued, err := tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now); err != nil || len(dequeued) != 0 {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v with only recent leaves queued, want none", dequeued, err)
+	}
+}
+
+func TestQueueDuplicateLeafReturnsExisting(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(1, 0)
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	// A repeat within the batch is caught too
+	existing, err := tx.QueueLeaves(ctx, append(leaves, leaves...))
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if existing[0] != nil || existing[1] == nil || existing[1].SequenceNumber != -1 {
+		t.Fatalf("QueueLeaves() returned %v, want nil and the queued leaf", existing)
+	}
+}
+
+func TestAddSequencedLeaves(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_PREORDERED_LOG, t)
+	leaves := createTestLeaves(4, 0)
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != storage.ErrPreordered {
+		t.Fatalf("QueueLeaves() = %v, want %v", err, storage.ErrPreordered)
+	}
+
+	// Leave a gap at 2
+	existing, err := tx.AddSequencedLeaves(ctx, []trillian.LogLeaf{leaves[3], leaves[0], leaves[1], leaves[1]})
+	if err != nil {
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
+	}
+	if existing[2] != nil || existing[3] == nil || existing[3].SequenceNumber != 1 {
+		t.Fatalf("AddSequencedLeaves() returned %v, want the leaf at 1 for the repeat", existing)
+	}
+
+	dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+	if err != nil || len(dequeued) != 2 || dequeued[1].SequenceNumber != 1 {
+		t.Fatalf("Expected leaves 0 and 1 to be dequeued, got: %v %v", dequeued, err)
+	}
+}
+
+func TestClaimLeaves(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(5, 0)
+
+	{
+		tx := beginLogTx(s, t)
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	claim := func(owner string, expiry, now int64, limit int) []trillian.LogLeaf {
+		tx := beginLogTx(s, t)
+		claimed, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: owner, ExpiryNanos: expiry}, now, limit)
+		if err != nil {
+			t.Fatalf("ClaimLeaves(%s) = %v", owner, err)
+		}
+		commit(tx, t)
+		return claimed
+	}
+
+	if claimed := claim("a", 200, 0, 3); len(claimed) != 3 || !bytes.Equal(claimed[0].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("Owner a claimed %v, want the 3 oldest leaves", claimed)
+	}
+
+	// Another owner only gets the leaves a doesn't hold
+	if claimed := claim("b", 100, 0, 99); len(claimed) != 2 || !bytes.Equal(claimed[0].LeafHash, leaves[3].LeafHash) {
+		t.Fatalf("Owner b claimed %v, want the 2 leaves left", claimed)
+	}
+
+	{
+		// Claims made by a transaction that rolls back are dropped
+		tx := beginLogTx(s, t)
+		if claimed, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: "d", ExpiryNanos: 400}, 250, 99); err != nil || len(claimed) != 5 {
+			t.Fatalf("Owner d claimed %v %v after the claims expired, want all 5 leaves", claimed, err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to roll back: %v", err)
+		}
+	}
+
+	{
+		tx := beginLogTx(s, t)
+
+		dequeued, err := tx.DequeueClaimedLeaves(ctx, "a", 2)
+		if err != nil || len(dequeued) != 2 || !bytes.Equal(dequeued[0].LeafHash, leaves[0].LeafHash) {
+			t.Fatalf("DequeueClaimedLeaves(a) = %v %v, want 2 of a's leaves", dequeued, err)
+		}
+
+		for i := range dequeued {
+			dequeued[i].SequenceNumber = int64(i)
+		}
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	// b's claims expire and c takes them, the leaf a still holds is left alone and the ones
+	// a integrated are gone
+	if claimed := claim("c", 300, 150, 99); len(claimed) != 2 || !bytes.Equal(claimed[0].LeafHash, leaves[3].LeafHash) {
+		t.Fatalf("Owner c claimed %v, want b's 2 leaves", claimed)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if dequeued, err := tx.DequeueClaimedLeaves(ctx, "b", 99); err != nil || len(dequeued) != 0 {
+			t.Fatalf("DequeueClaimedLeaves(b) = %v %v, want nothing after c took the leaves", dequeued, err)
+		}
+
+		if dequeued, err := tx.DequeueClaimedLeaves(ctx, "a", 99); err != nil || len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafHash, leaves[2].LeafHash) {
+			t.Fatalf("DequeueClaimedLeaves(a) = %v %v, want a's last leaf", dequeued, err)
+		}
+	}
+}
+
+func TestClaimSequencedLeaves(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_PREORDERED_LOG, t)
+	leaves := createTestLeaves(4, 0)
+
+	{
+		tx := beginLogTx(s, t)
+		if _, err := tx.AddSequencedLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to add sequenced leaves: %v", err)
+		}
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	if claimed, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: "a", ExpiryNanos: 100}, 0, 2); err != nil || len(claimed) != 2 {
+		t.Fatalf("Owner a claimed %v %v, want leaves 0 and 1", claimed, err)
+	}
+
+	// Leaves can't be integrated past the ones a holds
+	if claimed, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: "b", ExpiryNanos: 100}, 0, 99); err != nil || len(claimed) != 0 {
+		t.Fatalf("Owner b claimed %v %v, want nothing", claimed, err)
+	}
+
+	dequeued, err := tx.DequeueClaimedLeaves(ctx, "a", 99)
+	if err != nil || len(dequeued) != 2 || dequeued[1].SequenceNumber != 1 {
+		t.Fatalf("DequeueClaimedLeaves(a) = %v %v, want leaves 0 and 1", dequeued, err)
+	}
+}
+
+func TestGetLeavesByIdentityHash(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+	leaves := createTestLeaves(4, 0)
+
+	// Two versions of the same entry, one of another and one without an identity
+	leaves[0].LeafIdentityHash = []byte("identity A")
+	leaves[1].LeafIdentityHash = []byte("identity A")
+	leaves[2].LeafIdentityHash = []byte("identity B")
+
+	{
+		tx := beginLogTx(s, t)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+		if err != nil || len(dequeued) != len(leaves) {
+			t.Fatalf("Failed to dequeue leaves: %v %v", dequeued, err)
+		}
+		for i := range dequeued {
+			dequeued[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		// The transaction sees the leaves it sequenced
+		if got, err := tx.GetLeavesByIdentityHash(ctx, []trillian.Hash{[]byte("identity A")}, true); err != nil || len(got) != 2 {
+			t.Fatalf("Expected the transaction to see 2 leaves with identity A, got: %v %v", got, err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	for _, test := range []struct {
+		hashes []trillian.Hash
+		want   []int64
+	}{
+		{[]trillian.Hash{[]byte("identity A")}, []int64{0, 1}},
+		{[]trillian.Hash{[]byte("identity B"), []byte("identity A")}, []int64{0, 1, 2}},
+		{[]trillian.Hash{[]byte("identity C")}, []int64{}},
+	} {
+		got, err := tx.GetLeavesByIdentityHash(ctx, test.hashes, true)
+		if err != nil {
+			t.Fatalf("GetLeavesByIdentityHash(%s) = %v", test.hashes, err)
+		}
+
+		seqs := make([]int64, 0, len(got))
+		for _, leaf := range got {
+			seqs = append(seqs, leaf.SequenceNumber)
+		}
+
+		if fmt.Sprint(seqs) != fmt.Sprint(test.want) {
+			t.Errorf("GetLeavesByIdentityHash(%s) returned leaves %v, expected %v", test.hashes, seqs, test.want)
+		}
+	}
+}
+
+func TestSnapshotForTree(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+
+	{
+		tx := beginLogTx(s, t)
+		for i, size := range []int64{2, 5, 5} {
+			root := trillian.SignedLogRoot{TimestampNanos: int64(i + 1), TreeSize: size, TreeRevision: int64(i + 1)}
+			if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+				t.Fatalf("Failed to store root: %v", err)
+			}
+		}
+
+		if err := tx.StoreSignedLogRoot(ctx, trillian.SignedLogRoot{TimestampNanos: 1}); err == nil {
+			t.Fatal("StoreSignedLogRoot() with a repeated timestamp succeeded")
+		}
+
+		commit(tx, t)
+	}
+
+	for _, test := range []struct {
+		treeSize, wantSize, wantRevision int64
+	}{
+		{1, 2, 1},
+		{2, 2, 1},
+		{3, 5, 3},
+	} {
+		tx, err := s.SnapshotForTree(ctx, test.treeSize)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(%d) = %v", test.treeSize, err)
+		}
+
+		if got, want := tx.TreeSize(), test.wantSize; got != want {
+			t.Errorf("SnapshotForTree(%d).TreeSize() = %d, want %d", test.treeSize, got, want)
+		}
+		if got, want := tx.ReadRevision(), test.wantRevision; got != want {
+			t.Errorf("SnapshotForTree(%d).ReadRevision() = %d, want %d", test.treeSize, got, want)
+		}
+
+		tx.Commit()
+	}
+
+	if _, err := s.SnapshotForTree(ctx, 6); err == nil {
+		t.Error("SnapshotForTree() larger than the tree succeeded")
+	}
+}
+
+func TestSequencerCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	s := prepareTestLogStorage(trillian.TreeType_LOG, t)
+
+	first := storage.SequencerCheckpoint{TreeRevision: 1, CompactTree: &storage.CompactRangeProto{End: 1, Hashes: [][]byte{[]byte("leaf")}}}
+	second := storage.SequencerCheckpoint{TreeRevision: 2, CompactTree: &storage.CompactRangeProto{End: 2, Hashes: [][]byte{[]byte("node")}}}
+
+	{
+		tx := beginLogTx(s, t)
+
+		if got, err := tx.GetSequencerCheckpoint(ctx); err != nil || got != nil {
+			t.Fatalf("GetSequencerCheckpoint() on a new log = %v %v, want nil", got, err)
+		}
+
+		if err := tx.StoreSequencerCheckpoint(ctx, first); err != nil {
+			t.Fatalf("Failed to store checkpoint: %v", err)
+		}
+
+		// The transaction sees the checkpoint it stored
+		if got, err := tx.GetSequencerCheckpoint(ctx); err != nil || got == nil || got.TreeRevision != 1 {
+			t.Fatalf("GetSequencerCheckpoint() in the storing tx = %v %v, want revision 1", got, err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		// A rolled back checkpoint doesn't replace the committed one
+		tx := beginLogTx(s, t)
+		if err := tx.StoreSequencerCheckpoint(ctx, second); err != nil {
+			t.Fatalf("Failed to store checkpoint: %v", err)
+		}
+		tx.Rollback()
+	}
+
+	for _, want := range []storage.SequencerCheckpoint{first, second} {
+		tx := beginLogTx(s, t)
+
+		got, err := tx.GetSequencerCheckpoint(ctx)
+		if err != nil || got == nil {
+			t.Fatalf("GetSequencerCheckpoint() = %v %v, want revision %d", got, err, want.TreeRevision)
+		}
+		if got.TreeRevision != want.TreeRevision || !proto.Equal(got.CompactTree, want.CompactTree) {
+			t.Errorf("GetSequencerCheckpoint() = %v, want %v", got, want)
+		}
+
+		// Each checkpoint replaces the last
+		if err := tx.StoreSequencerCheckpoint(ctx, second); err != nil {
+			t.Fatalf("Failed to store checkpoint: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Commit()
+
+		if err := tx.StoreSequencerCheckpoint(ctx, storage.SequencerCheckpoint{TreeRevision: 3}); err == nil {
+			t.Error("StoreSequencerCheckpoint() without a compact tree succeeded")
+		}
+	}
+}
+
+func TestAdminTreeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p, err := storage.NewProvider(ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	as := mustAdminStorage(p, t)
+
+	tree := createTree(p, trillian.TreeType_LOG, t)
+	createTree(p, trillian.TreeType_MAP, t)
+
+	s, err := p.LogStorage(trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId})
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		ids, err := tx.GetActiveLogIDs(ctx)
+		if err != nil || len(ids) != 1 || ids[0].TreeID != tree.TreeId || !bytes.Equal(ids[0].LogID, tree.KeyId) {
+			t.Fatalf("GetActiveLogIDs() = %v %v, want only the log", ids, err)
+		}
+		commit(tx, t)
+	}
+
+	updateAdmin(as, t, func(tx storage.AdminTX) error {
+		_, err := tx.UpdateTree(tree.TreeId, func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_FROZEN })
+		return err
+	})
+
+	if _, err := s.Begin(ctx); err != storage.ErrReadOnly {
+		t.Errorf("Begin() on a frozen tree = %v, want %v", err, storage.ErrReadOnly)
+	}
+
+	updateAdmin(as, t, func(tx storage.AdminTX) error {
+		_, err := tx.SoftDeleteTree(tree.TreeId)
+		return err
+	})
+
+	if _, err := s.Snapshot(ctx); err != storage.ErrTreeDeleted {
+		t.Errorf("Snapshot() of a deleted tree = %v, want %v", err, storage.ErrTreeDeleted)
+	}
+
+	updateAdmin(as, t, func(tx storage.AdminTX) error {
+		return tx.HardDeleteTree(tree.TreeId)
+	})
+
+	atx, err := as.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	defer atx.Commit()
+
+	if _, err := atx.GetTree(tree.TreeId); err != storage.ErrTreeNotFound {
+		t.Errorf("GetTree() of a hard deleted tree = %v, want %v", err, storage.ErrTreeNotFound)
+	}
+	if trees, err := atx.ListTrees(true); err != nil || len(trees) != 1 {
+		t.Errorf("ListTrees() = %v %v, want the map", trees, err)
+	}
+}
+
+func createSomeNodes() []storage.Node {
+	r := make([]storage.Node, 4)
+	for i := range r {
+		r[i].NodeID = storage.NewNodeIDWithPrefix(uint64(i), 8, 8, 8)
+		h := sha256.Sum256([]byte{byte(i)})
+		r[i].Hash = h[:]
+	}
+	return r
+}
+
+// Creates some test leaves with predictable data
+func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0)
+	hasher := trillian.NewSHA256()
+
+	for l := int64(0); l < n; l++ {
+		lv := fmt.Sprintf("Leaf %d", l)
+		leaf := trillian.LogLeaf{
+			Leaf:                 trillian.Leaf{LeafHash: hasher.Digest([]byte(lv)), LeafValue: []byte(lv), ExtraData: []byte(fmt.Sprintf("Extra %d", l))},
+			SignedEntryTimestamp: signedTimestamp,
+			SequenceNumber:       startSeq + l,
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves
+}
+
+func mustAdminStorage(p storage.Provider, t *testing.T) storage.AdminStorage {
+	as, err := p.AdminStorage()
+	if err != nil {
+		t.Fatalf("Failed to get admin storage: %v", err)
+	}
+
+	return as
+}
+
+func updateAdmin(as storage.AdminStorage, t *testing.T, f func(storage.AdminTX) error) {
+	tx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("Failed to update trees: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+}
+
+func createTree(p storage.Provider, treeType trillian.TreeType, t *testing.T) *trillian.Tree {
+	var tree *trillian.Tree
+
+	updateAdmin(mustAdminStorage(p, t), t, func(tx storage.AdminTX) error {
+		var err error
+		tree, err = tx.CreateTree(&trillian.Tree{TreeType: treeType, KeyId: []byte(t.Name()), HashAlgorithm: trillian.HashAlgorithm_SHA256})
+		return err
+	})
+
+	return tree
+}
+
+// prepareTestLogStorage creates a log of treeType in a database of its own
+func prepareTestLogStorage(treeType trillian.TreeType, t *testing.T) storage.LogStorage {
+	p, err := storage.NewProvider(ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	tree := createTree(p, treeType, t)
+
+	s, err := p.LogStorage(trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId})
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %s", err)
+	}
+
+	return s
+}
+
+func commit(tx storage.LogTX, t *testing.T) {
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit tx: %v", err)
+	}
+}
+
+func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
+	tx, err := s.Begin(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+
+	return tx
+}
@@ -0,0 +1,470 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
+)
+
+// ErrTxClosed is returned when a transaction is used after it has been committed or
+// rolled back
+var ErrTxClosed = errors.New("memory: transaction has already been committed or rolled back")
+
+// database holds everything stored through the providers for one DSN
+type database struct {
+	// mutex guards the fields below, and the data of each tree. It's only held while they're
+	// read or changed: writable transactions on a tree are kept apart by the tree's writer
+	// lock, and admin transactions by adminMutex.
+	mutex sync.RWMutex
+	trees map[int64]*trillian.Tree
+	usage map[int64]*trillian.TreeUsage
+	data  map[int64]*treeData
+
+	adminMutex sync.Mutex
+}
+
+func newDatabase() *database {
+	return &database{
+		trees: make(map[int64]*trillian.Tree),
+		usage: make(map[int64]*trillian.TreeUsage),
+		data:  make(map[int64]*treeData),
+	}
+}
+
+// treeData returns what's stored for a tree, creating it if nothing has been yet
+func (d *database) treeData(treeID int64) *treeData {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	data, ok := d.data[treeID]
+
+	if !ok {
+		data = &treeData{
+			subtrees:        make(map[string][]storedSubtree),
+			sequenced:       make(map[int64]trillian.LogLeaf),
+			leafIndices:     make(map[string][]int64),
+			identityIndices: make(map[string][]int64),
+			cosignatures:    make(map[int64]map[string]trillian.Cosignature),
+			claims:          make(map[int64]storage.LeafClaim),
+		}
+		d.data[treeID] = data
+	}
+
+	return data
+}
+
+// storedSubtree is a subtree as it was written at a revision, marshalled like it would be
+// for a database so that nothing read back shares memory with what was written
+type storedSubtree struct {
+	revision int64
+	nodes    []byte
+}
+
+// queuedLeaf is a leaf waiting to be sequenced. The ID tells apart copies of the same leaf.
+type queuedLeaf struct {
+	id   int64
+	leaf trillian.LogLeaf
+	// timestampNanos is when the leaf was queued
+	timestampNanos int64
+}
+
+// treeData is what's stored for a tree. The fields are guarded by the mutex of the
+// database.
+type treeData struct {
+	// writer is held by the writable transaction on the tree for as long as it's open
+	writer sync.Mutex
+
+	// subtrees holds the revisions of each subtree by prefix, in the order they were written
+	subtrees map[string][]storedSubtree
+	// roots holds the signed roots of a log in the order they were stored
+	roots []trillian.SignedLogRoot
+	// queue holds the leaves waiting to be sequenced, oldest first
+	queue []queuedLeaf
+	// nextQueueID is the ID of the next leaf to be queued
+	nextQueueID int64
+	// claims holds the claims on queued leaves by queue ID, they're removed with the leaves
+	claims map[int64]storage.LeafClaim
+	// sequenced holds the leaves that have been integrated, by sequence number
+	sequenced map[int64]trillian.LogLeaf
+	// leafIndices holds the sequence numbers of the integrated leaves by leaf hash
+	leafIndices map[string][]int64
+	// identityIndices holds the sequence numbers of the integrated leaves by leaf identity hash
+	identityIndices map[string][]int64
+	// cosignatures holds the cosignatures of each root by root timestamp and witness
+	cosignatures map[int64]map[string]trillian.Cosignature
+	// checkpoint is the sequencer checkpoint of a log, nil if none has been stored
+	checkpoint *storage.SequencerCheckpoint
+}
+
+// memoryTreeStorage is shared by the log storage for a tree and its transactions
+type memoryTreeStorage struct {
+	db              *database
+	treeID          int64
+	data            *treeData
+	hashAlgorithm   trillian.HashAlgorithm
+	hashSizeBytes   int
+	populateSubtree storage.PopulateSubtreeFunc
+}
+
+// newTreeStorage returns the storage for a tree, whose subtrees are rebuilt with the
+// function that populateFactory returns for the tree's hasher. Trees that weren't created
+// through the admin storage get the same defaults as in the database storage systems.
+func newTreeStorage(db *database, treeID int64, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc) (*memoryTreeStorage, error) {
+	hashAlgorithm := trillian.HashAlgorithm_SHA256
+
+	if tree := db.tree(treeID); tree != nil {
+		hashAlgorithm = tree.HashAlgorithm
+	}
+
+	th, err := merkle.NewTreeHasher(hashAlgorithm)
+	if err != nil {
+		glog.Warningf("Failed to create tree hasher for tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return &memoryTreeStorage{
+		db:              db,
+		treeID:          treeID,
+		data:            db.treeData(treeID),
+		hashAlgorithm:   hashAlgorithm,
+		hashSizeBytes:   th.Size(),
+		populateSubtree: populateFactory(th),
+	}, nil
+}
+
+// tree returns a copy of the metadata of a tree, nil if it wasn't created through the admin
+// storage
+func (d *database) tree(treeID int64) *trillian.Tree {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	tree, ok := d.trees[treeID]
+
+	if !ok {
+		return nil
+	}
+
+	copied := *tree
+	return &copied
+}
+
+// HashAlgorithm returns the hash algorithm the tree was created with.
+func (m *memoryTreeStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return m.hashAlgorithm
+}
+
+// beginTreeTx starts a transaction on the tree. Writable transactions hold the tree's
+// writer lock until they're committed or rolled back, and keep their changes to themselves
+// until they're committed.
+func (m *memoryTreeStorage) beginTreeTx(ctx context.Context, write bool) treeTX {
+	t := treeTX{
+		ctx:           ctx,
+		started:       time.Now(),
+		ts:            m,
+		subtreeCache:  cache.NewSubtreeCache(m.populateSubtree),
+		writeRevision: -1,
+		readRevision:  -1,
+		write:         write,
+		subtreeMutex:  new(sync.Mutex),
+	}
+
+	if write {
+		m.data.writer.Lock()
+		t.subtrees = make(map[string][]byte)
+	}
+
+	return t
+}
+
+type treeTX struct {
+	closed bool
+	// ctx is the context the transaction was started with, which cached subtrees are
+	// written back under when it's committed
+	ctx           context.Context
+	started       time.Time
+	ts            *memoryTreeStorage
+	subtreeCache  cache.SubtreeCache
+	writeRevision int64
+	// readRevision is the revision a transaction started by SnapshotForTree is pinned to,
+	// or -1 for other transactions
+	readRevision int64
+	// write is true if the transaction holds the writer lock of the tree
+	write bool
+	// subtrees holds the subtrees written by the transaction, at writeRevision, by prefix
+	subtrees map[string][]byte
+	// subtreeMutex guards subtrees, as the subtree cache may read and write them
+	// concurrently
+	subtreeMutex *sync.Mutex
+}
+
+// checkTreeState returns storage.ErrTreeDeleted if the tree has been deleted and, for
+// writable transactions, storage.ErrReadOnly if it has been frozen.
+func (t *treeTX) checkTreeState() error {
+	tree := t.ts.db.tree(t.ts.treeID)
+
+	switch {
+	case tree == nil:
+		// As in the database storage systems, trees don't have to have been created first
+		return nil
+	case tree.Deleted:
+		return storage.ErrTreeDeleted
+	case t.write && tree.TreeState == trillian.TreeState_FROZEN:
+		return storage.ErrReadOnly
+	}
+
+	return nil
+}
+
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+// getSubtrees returns the latest revision at or before treeRevision of the subtrees with the
+// given IDs that have been written. Subtrees written by the transaction itself are seen at
+// its write revision.
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	t.ts.db.mutex.RLock()
+	defer t.ts.db.mutex.RUnlock()
+
+	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+
+		prefix := string(nodeID.Path[:nodeID.PrefixLenBits/8])
+		nodes, ok := t.subtrees[prefix]
+
+		if !ok || treeRevision < t.writeRevision {
+			nodes = latestSubtree(t.ts.data.subtrees[prefix], treeRevision)
+		}
+
+		if nodes == nil {
+			continue
+		}
+
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodes, &subtree); err != nil {
+			glog.Warningf("Failed to unmarshal SubtreeProto: %s", err)
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+	}
+
+	return ret, nil
+}
+
+// latestSubtree returns the latest of the revisions of a subtree that's at or before
+// treeRevision, nil if there isn't one
+func latestSubtree(revisions []storedSubtree, treeRevision int64) []byte {
+	var nodes []byte
+	latest := int64(-1)
+
+	for _, s := range revisions {
+		if s.revision <= treeRevision && s.revision >= latest {
+			nodes = s.nodes
+			latest = s.revision
+		}
+	}
+
+	return nodes
+}
+
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storage.SubtreeProto) error {
+	if !t.write {
+		return storage.ErrReadOnly
+	}
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		nodes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		t.subtrees[string(s.Prefix)] = nodes
+	}
+
+	return nil
+}
+
+// commitSubtrees stores the subtrees written by the transaction. The database mutex must be
+// held.
+func (t *treeTX) commitSubtrees() {
+	for prefix, nodes := range t.subtrees {
+		t.ts.data.subtrees[prefix] = append(t.ts.data.subtrees[prefix], storedSubtree{revision: t.writeRevision, nodes: nodes})
+	}
+}
+
+// PruneSubtrees removes the revisions of subtrees that are older than the newest revision of
+// the same subtree at or before horizon, and so can't be read at the horizon or later.
+func (t *treeTX) PruneSubtrees(ctx context.Context, horizon int64) (storage.PruneStats, error) {
+	var stats storage.PruneStats
+
+	if !t.write {
+		return stats, storage.ErrReadOnly
+	}
+
+	t.ts.db.mutex.Lock()
+	defer t.ts.db.mutex.Unlock()
+
+	for prefix, revisions := range t.ts.data.subtrees {
+		keep := int64(-1)
+		for _, s := range revisions {
+			if s.revision <= horizon && s.revision > keep {
+				keep = s.revision
+			}
+		}
+
+		kept := revisions[:0]
+		for _, s := range revisions {
+			if s.revision < keep {
+				stats.Rows++
+				stats.Bytes += int64(len(s.nodes))
+				continue
+			}
+			kept = append(kept, s)
+		}
+		t.ts.data.subtrees[prefix] = kept
+	}
+
+	return stats, nil
+}
+
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	hashes, err := t.subtreeCache.GetNodeHashes(ctx, nodeIDs, t.subtreesAt(treeRevision))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	for i, h := range hashes {
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeIDs[i],
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	return t.subtreeCache.GetProofPath(ctx, leafID, treeSize, t.subtreesAt(treeRevision))
+}
+
+// subtreesAt returns a function reading subtrees at treeRevision
+func (t *treeTX) subtreesAt(treeRevision int64) cache.GetSubtreesFunc {
+	return func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	}
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(ctx, n.NodeID, n.Hash,
+			func(ctx context.Context, nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			},
+			t.storeSubtrees)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes back the subtrees changed in the cache, before the transaction is committed
+func (t *treeTX) flush() error {
+	if t.closed {
+		return ErrTxClosed
+	}
+
+	if t.write && t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(t.ctx, t.storeSubtrees); err != nil {
+			glog.Warningf("TX commit flush error: %s", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close ends the transaction with outcome, "commit" or "rollback", releasing the tree's
+// writer lock if it holds it
+func (t *treeTX) close(outcome string) error {
+	if t.closed {
+		return ErrTxClosed
+	}
+
+	t.closed = true
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "memory", outcome)
+
+	if t.write {
+		t.ts.data.writer.Unlock()
+	}
+
+	return nil
+}
+
+// ReadRevision returns the tree revision a snapshot transaction is pinned to.
+func (t *treeTX) ReadRevision() int64 {
+	return t.readRevision
+}
+
+func (t *treeTX) IsOpen() bool {
+	return !t.closed
+}
+
+// sortedTreeIDs returns the IDs of trees in ascending order
+func sortedTreeIDs(trees map[int64]*trillian.Tree) []int64 {
+	ids := make([]int64, 0, len(trees))
+
+	for id := range trees {
+		ids = append(ids, id)
+	}
+
+	sort.Sort(int64s(ids))
+	return ids
+}
+
+// int64s sorts IDs and sequence numbers in ascending order
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
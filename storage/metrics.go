@@ -0,0 +1,14 @@
+package storage
+
+import "github.com/google/trillian/monitoring"
+
+// These metrics are shared by the storage implementations, which are distinguished by the
+// storage label.
+var (
+	// TXDuration is how long tree transactions were open for, by storage and whether they were
+	// committed or rolled back
+	TXDuration = monitoring.NewHistogram("storage_tx_duration_seconds", "Time tree transactions were open for", monitoring.DefaultBuckets, "storage", "outcome")
+	// QueuedLeaves is the number of leaves waiting to be sequenced, by storage and tree. It's
+	// updated whenever leaves are dequeued.
+	QueuedLeaves = monitoring.NewGauge("storage_queued_leaves", "Leaves waiting to be sequenced", "storage", "treeid")
+)
@@ -0,0 +1,114 @@
+// Package migrate upgrades the schema of storage databases in place. Each storage system
+// has a list of numbered migrations, each of which takes its schema from one version to the
+// next, and records the version its database is at so only the migrations it's missing are
+// applied.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ErrOutOfDate is returned by Check if a database has migrations still to be applied
+var ErrOutOfDate = errors.New("migrate: database schema is out of date")
+
+// Migration changes a schema from the version before it to Version
+type Migration struct {
+	// Version is the schema version after the migration is applied, starting at 1
+	Version int
+	// Description says what the migration changes
+	Description string
+	// Statements are run in order to apply the migration
+	Statements []string
+}
+
+// Database is implemented by each storage system to apply migrations to its database
+type Database interface {
+	// Lock stops any other process migrating the database until unlock is called, so that
+	// servers starting together don't apply the same migration twice
+	Lock(ctx context.Context) (unlock func(), err error)
+	// Version returns the version of the schema, or 0 for a database with no schema
+	Version(ctx context.Context) (int, error)
+	// Apply runs the statements of m and records that the schema is at m.Version
+	Apply(ctx context.Context, m Migration) error
+}
+
+// Validate checks that migrations are numbered in order from 1 without any gaps
+func Validate(migrations []Migration) error {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("migrate: migration %d has version %d, want %d", i, m.Version, i+1)
+		}
+
+		if len(m.Statements) == 0 {
+			return fmt.Errorf("migrate: migration %d has no statements", m.Version)
+		}
+	}
+
+	return nil
+}
+
+// Pending returns the migrations that haven't been applied to db. It returns an error if db
+// has a newer schema than the latest of migrations, e.g. if it was migrated by a newer
+// release.
+func Pending(ctx context.Context, db Database, migrations []Migration) ([]Migration, error) {
+	if err := Validate(migrations); err != nil {
+		return nil, err
+	}
+
+	version, err := db.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > len(migrations) {
+		return nil, fmt.Errorf("migrate: database schema version %d is newer than the latest known version %d", version, len(migrations))
+	}
+
+	return migrations[version:], nil
+}
+
+// Check returns ErrOutOfDate if migrations need to be applied to db, or nil if its schema is
+// up to date
+func Check(ctx context.Context, db Database, migrations []Migration) error {
+	pending, err := Pending(ctx, db, migrations)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return ErrOutOfDate
+	}
+
+	return nil
+}
+
+// Run applies the migrations that db is missing, in order, and returns how many were applied.
+// If one fails the database is left at the version of the last one that succeeded.
+func Run(ctx context.Context, db Database, migrations []Migration) (int, error) {
+	unlock, err := db.Lock(ctx)
+	if err != nil {
+		glog.Warningf("Failed to lock database for migration: %s", err)
+		return 0, err
+	}
+	defer unlock()
+
+	pending, err := Pending(ctx, db, migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, m := range pending {
+		glog.Infof("Migrating database schema to version %d: %s", m.Version, m.Description)
+
+		if err := db.Apply(ctx, m); err != nil {
+			glog.Warningf("Failed to migrate database schema to version %d: %s", m.Version, err)
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Description: "one", Statements: []string{"CREATE one"}},
+	{Version: 2, Description: "two", Statements: []string{"ALTER two", "UPDATE two"}},
+	{Version: 3, Description: "three", Statements: []string{"ALTER three"}},
+}
+
+// fakeDatabase records the statements run on it, failing the migration to failVersion
+type fakeDatabase struct {
+	version     int
+	failVersion int
+	locked      bool
+	statements  []string
+}
+
+func (f *fakeDatabase) Lock(ctx context.Context) (func(), error) {
+	if f.locked {
+		return nil, errors.New("already locked")
+	}
+
+	f.locked = true
+	return func() { f.locked = false }, nil
+}
+
+func (f *fakeDatabase) Version(ctx context.Context) (int, error) {
+	return f.version, nil
+}
+
+func (f *fakeDatabase) Apply(ctx context.Context, m Migration) error {
+	if m.Version == f.failVersion {
+		return errors.New("migration failed")
+	}
+
+	f.statements = append(f.statements, m.Statements...)
+	f.version = m.Version
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testMigrations); err != nil {
+		t.Errorf("Valid migrations failed validation: %v", err)
+	}
+
+	for _, migrations := range [][]Migration{
+		{{Version: 2, Statements: []string{"x"}}},
+		{{Version: 1, Statements: []string{"x"}}, {Version: 3, Statements: []string{"x"}}},
+		{{Version: 1}},
+	} {
+		if err := Validate(migrations); err == nil {
+			t.Errorf("Invalid migrations %v passed validation", migrations)
+		}
+	}
+}
+
+func TestRunAppliesPendingMigrations(t *testing.T) {
+	db := &fakeDatabase{version: 1}
+
+	if err := Check(context.Background(), db, testMigrations); err != ErrOutOfDate {
+		t.Fatalf("Got %v checking database at version 1, want %v", err, ErrOutOfDate)
+	}
+
+	applied, err := Run(context.Background(), db, testMigrations)
+	if err != nil || applied != 2 {
+		t.Fatalf("Got %d applied and error %v, want 2 applied", applied, err)
+	}
+
+	if want := []string{"ALTER two", "UPDATE two", "ALTER three"}; !reflect.DeepEqual(db.statements, want) {
+		t.Errorf("Ran %v, want %v", db.statements, want)
+	}
+
+	if db.locked {
+		t.Error("Database still locked after migration")
+	}
+
+	if err := Check(context.Background(), db, testMigrations); err != nil {
+		t.Errorf("Got %v checking migrated database", err)
+	}
+
+	// Running again does nothing
+	if applied, err := Run(context.Background(), db, testMigrations); err != nil || applied != 0 {
+		t.Errorf("Got %d applied and error %v migrating up to date database, want none applied", applied, err)
+	}
+}
+
+func TestRunStopsAtFailedMigration(t *testing.T) {
+	db := &fakeDatabase{failVersion: 2}
+
+	applied, err := Run(context.Background(), db, testMigrations)
+	if err == nil || applied != 1 {
+		t.Fatalf("Got %d applied and error %v, want 1 applied and an error", applied, err)
+	}
+
+	if db.version != 1 {
+		t.Errorf("Database at version %d after failed migration, want 1", db.version)
+	}
+}
+
+func TestRunRejectsNewerDatabase(t *testing.T) {
+	db := &fakeDatabase{version: 4}
+
+	if _, err := Run(context.Background(), db, testMigrations); err == nil {
+		t.Error("Migrated database with a newer schema than the migrations")
+	}
+
+	if len(db.statements) > 0 {
+		t.Errorf("Ran %v on database with a newer schema", db.statements)
+	}
+}
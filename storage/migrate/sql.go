@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// SQLDialect holds the statements a SQLDatabase uses to lock a database and record its
+// schema version, which differ between database systems
+type SQLDialect struct {
+	// CreateVersionTable creates the SchemaVersion table if it doesn't exist, with columns
+	// Version, Description and AppliedTimeMillis
+	CreateVersionTable string
+	// InsertVersion records a version, taking the same arguments as the columns
+	InsertVersion string
+	// Lock waits for the migration lock, returning a single row holding 1 once it's taken
+	Lock string
+	// Unlock releases the migration lock
+	Unlock string
+	// Transactional is true if schema changes can be rolled back, in which case each
+	// migration is applied in a transaction that includes recording its version
+	Transactional bool
+}
+
+// queryer is the part of sql.DB and sql.Conn used to migrate a database
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// SQLDatabase migrates a database accessed through database/sql, recording the version of
+// its schema in a SchemaVersion table
+type SQLDatabase struct {
+	db      *sql.DB
+	dialect SQLDialect
+	// conn holds the migration lock while it's locked, statements are run on it because the
+	// lock belongs to the connection
+	conn *sql.Conn
+}
+
+// NewSQLDatabase creates a Database for migrating db, which uses dialect
+func NewSQLDatabase(db *sql.DB, dialect SQLDialect) *SQLDatabase {
+	return &SQLDatabase{db: db, dialect: dialect}
+}
+
+func (s *SQLDatabase) queryer() queryer {
+	if s.conn != nil {
+		return s.conn
+	}
+
+	return s.db
+}
+
+// Lock takes the migration lock, see Database
+func (s *SQLDatabase) Lock(ctx context.Context) (func(), error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked int
+	if err := conn.QueryRowContext(ctx, s.dialect.Lock).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if locked != 1 {
+		conn.Close()
+		return nil, errors.New("migrate: timed out waiting for the migration lock")
+	}
+
+	s.conn = conn
+
+	return func() {
+		conn.ExecContext(context.Background(), s.dialect.Unlock)
+		conn.Close()
+		s.conn = nil
+	}, nil
+}
+
+// Version returns the version recorded in the SchemaVersion table, creating the table if
+// it doesn't exist
+func (s *SQLDatabase) Version(ctx context.Context) (int, error) {
+	q := s.queryer()
+
+	if _, err := q.ExecContext(ctx, s.dialect.CreateVersionTable); err != nil {
+		return 0, err
+	}
+
+	var version int
+	if err := q.QueryRowContext(ctx, "SELECT COALESCE(MAX(Version), 0) FROM SchemaVersion").Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Apply runs the statements of m and records its version, see Database
+func (s *SQLDatabase) Apply(ctx context.Context, m Migration) error {
+	q := s.queryer()
+
+	if !s.dialect.Transactional {
+		return applyStatements(ctx, q, s.dialect, m)
+	}
+
+	tx, err := q.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := applyStatements(ctx, tx, s.dialect, m); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execer is the part of sql.DB, sql.Conn and sql.Tx used to apply a migration
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func applyStatements(ctx context.Context, e execer, dialect SQLDialect, m Migration) error {
+	for _, statement := range m.Statements {
+		if _, err := e.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	_, err := e.ExecContext(ctx, dialect.InsertVersion, m.Version, m.Description, time.Now().UnixNano()/int64(time.Millisecond))
+	return err
+}
@@ -1,11 +1,12 @@
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/google/trillian/storage (interfaces: LogTX,MapTX,ReadOnlyLogTX,ReadOnlyMapTX,MapStorage,LogStorage)
+// Source: github.com/google/trillian/storage (interfaces: LogTX,MapTX,ReadOnlyLogTX,ReadOnlyMapTX,MapStorage,LogStorage,AdminStorage,AdminTX,ReadOnlyAdminTX)
 
 package storage
 
 import (
 	gomock "github.com/golang/mock/gomock"
 	trillian "github.com/google/trillian"
+	context "golang.org/x/net/context"
 )
 
 // Mock of LogTX interface
@@ -29,6 +30,38 @@ func (_m *MockLogTX) EXPECT() *_MockLogTXRecorder {
 	return _m.recorder
 }
 
+func (_m *MockLogTX) AddCosignature(_param0 context.Context, _param1 int64, _param2 trillian.Cosignature) error {
+	ret := _m.ctrl.Call(_m, "AddCosignature", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) AddCosignature(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddCosignature", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTX) AddSequencedLeaves(_param0 context.Context, _param1 []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "AddSequencedLeaves", _param0, _param1)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) AddSequencedLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddSequencedLeaves", arg0, arg1)
+}
+
+func (_m *MockLogTX) ClaimLeaves(_param0 context.Context, _param1 LeafClaim, _param2 int64, _param3 int) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "ClaimLeaves", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) ClaimLeaves(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ClaimLeaves", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockLogTX) Commit() error {
 	ret := _m.ctrl.Call(_m, "Commit")
 	ret0, _ := ret[0].(error)
@@ -39,92 +72,180 @@ func (_mr *_MockLogTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
-func (_m *MockLogTX) DequeueLeaves(_param0 int) ([]trillian.LogLeaf, error) {
-	ret := _m.ctrl.Call(_m, "DequeueLeaves", _param0)
+func (_m *MockLogTX) DequeueClaimedLeaves(_param0 context.Context, _param1 string, _param2 int) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "DequeueClaimedLeaves", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) DequeueClaimedLeaves(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueClaimedLeaves", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTX) DequeueLeaves(_param0 context.Context, _param1 int, _param2 int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "DequeueLeaves", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) DequeueLeaves(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeaves", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTX) DequeueOverdueLeaves(_param0 context.Context, _param1 int, _param2 int64, _param3 int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "DequeueOverdueLeaves", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) DequeueLeaves(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueLeaves", arg0)
+func (_mr *_MockLogTXRecorder) DequeueOverdueLeaves(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DequeueOverdueLeaves", arg0, arg1, arg2, arg3)
 }
 
-func (_m *MockLogTX) GetActiveLogIDs() ([]trillian.LogID, error) {
-	ret := _m.ctrl.Call(_m, "GetActiveLogIDs")
+func (_m *MockLogTX) GetActiveLogIDs(_param0 context.Context) ([]trillian.LogID, error) {
+	ret := _m.ctrl.Call(_m, "GetActiveLogIDs", _param0)
 	ret0, _ := ret[0].([]trillian.LogID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetActiveLogIDs() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetActiveLogIDs")
+func (_mr *_MockLogTXRecorder) GetActiveLogIDs(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetActiveLogIDs", arg0)
 }
 
-func (_m *MockLogTX) GetActiveLogIDsWithPendingWork() ([]trillian.LogID, error) {
-	ret := _m.ctrl.Call(_m, "GetActiveLogIDsWithPendingWork")
+func (_m *MockLogTX) GetActiveLogIDsWithPendingWork(_param0 context.Context) ([]trillian.LogID, error) {
+	ret := _m.ctrl.Call(_m, "GetActiveLogIDsWithPendingWork", _param0)
 	ret0, _ := ret[0].([]trillian.LogID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetActiveLogIDsWithPendingWork() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetActiveLogIDsWithPendingWork")
+func (_mr *_MockLogTXRecorder) GetActiveLogIDsWithPendingWork(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetActiveLogIDsWithPendingWork", arg0)
+}
+
+func (_m *MockLogTX) GetCosignatures(_param0 context.Context, _param1 int64) ([]trillian.Cosignature, error) {
+	ret := _m.ctrl.Call(_m, "GetCosignatures", _param0, _param1)
+	ret0, _ := ret[0].([]trillian.Cosignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetCosignatures(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetCosignatures", arg0, arg1)
+}
+
+func (_m *MockLogTX) GetLeavesByHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetLeavesByHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTX) GetLeavesByIdentityHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetLeavesByIdentityHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0, arg1, arg2)
 }
 
-func (_m *MockLogTX) GetLeavesByHash(_param0 []trillian.Hash, _param1 bool) ([]trillian.LogLeaf, error) {
-	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1)
+func (_m *MockLogTX) GetLeavesByIndex(_param0 context.Context, _param1 []int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0, _param1)
 	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetLeavesByHash(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1)
+func (_mr *_MockLogTXRecorder) GetLeavesByIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0, arg1)
 }
 
-func (_m *MockLogTX) GetLeavesByIndex(_param0 []int64) ([]trillian.LogLeaf, error) {
-	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0)
+func (_m *MockLogTX) GetLeavesByRange(_param0 context.Context, _param1 int64, _param2 int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1, _param2)
 	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetLeavesByIndex(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
+func (_mr *_MockLogTXRecorder) GetLeavesByRange(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1, arg2)
+}
+
+func (_m *MockLogTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
 }
 
-func (_m *MockLogTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
-	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
+func (_m *MockLogTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
+func (_mr *_MockLogTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
 }
 
-func (_m *MockLogTX) GetSequencedLeafCount() (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount")
+func (_m *MockLogTX) GetSequencedLeafCount(_param0 context.Context) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount", _param0)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetSequencedLeafCount() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
+func (_mr *_MockLogTXRecorder) GetSequencedLeafCount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", arg0)
+}
+
+func (_m *MockLogTX) GetSequencerCheckpoint(_param0 context.Context) (*SequencerCheckpoint, error) {
+	ret := _m.ctrl.Call(_m, "GetSequencerCheckpoint", _param0)
+	ret0, _ := ret[0].(*SequencerCheckpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetSequencerCheckpoint(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencerCheckpoint", arg0)
+}
+
+func (_m *MockLogTX) GetSignedLogRoot(_param0 context.Context, _param1 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedLogRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedLogRoot", arg0, arg1)
 }
 
-func (_m *MockLogTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
+func (_m *MockLogTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) GetTreeRevisionAtSize(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0)
+func (_mr *_MockLogTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
 }
 
 func (_m *MockLogTX) IsOpen() bool {
@@ -137,25 +258,37 @@ func (_mr *_MockLogTXRecorder) IsOpen() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "IsOpen")
 }
 
-func (_m *MockLogTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
-	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot")
+func (_m *MockLogTX) LatestSignedLogRoot(_param0 context.Context) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot", _param0)
 	ret0, _ := ret[0].(trillian.SignedLogRoot)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogTXRecorder) LatestSignedLogRoot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot")
+func (_mr *_MockLogTXRecorder) LatestSignedLogRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot", arg0)
 }
 
-func (_m *MockLogTX) QueueLeaves(_param0 []trillian.LogLeaf) error {
-	ret := _m.ctrl.Call(_m, "QueueLeaves", _param0)
-	ret0, _ := ret[0].(error)
-	return ret0
+func (_m *MockLogTX) OldestQueuedLeafNanos(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "OldestQueuedLeafNanos", _param0, _param1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) OldestQueuedLeafNanos(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "OldestQueuedLeafNanos", arg0, arg1)
 }
 
-func (_mr *_MockLogTXRecorder) QueueLeaves(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", arg0)
+func (_m *MockLogTX) QueueLeaves(_param0 context.Context, _param1 []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "QueueLeaves", _param0, _param1)
+	ret0, _ := ret[0].([]*trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) QueueLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", arg0, arg1)
 }
 
 func (_m *MockLogTX) Rollback() error {
@@ -168,34 +301,44 @@ func (_mr *_MockLogTXRecorder) Rollback() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rollback")
 }
 
-func (_m *MockLogTX) SetMerkleNodes(_param0 []Node) error {
-	ret := _m.ctrl.Call(_m, "SetMerkleNodes", _param0)
+func (_m *MockLogTX) SetMerkleNodes(_param0 context.Context, _param1 []Node) error {
+	ret := _m.ctrl.Call(_m, "SetMerkleNodes", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) SetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0, arg1)
+}
+
+func (_m *MockLogTX) StoreSequencerCheckpoint(_param0 context.Context, _param1 SequencerCheckpoint) error {
+	ret := _m.ctrl.Call(_m, "StoreSequencerCheckpoint", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockLogTXRecorder) SetMerkleNodes(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0)
+func (_mr *_MockLogTXRecorder) StoreSequencerCheckpoint(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSequencerCheckpoint", arg0, arg1)
 }
 
-func (_m *MockLogTX) StoreSignedLogRoot(_param0 trillian.SignedLogRoot) error {
-	ret := _m.ctrl.Call(_m, "StoreSignedLogRoot", _param0)
+func (_m *MockLogTX) StoreSignedLogRoot(_param0 context.Context, _param1 trillian.SignedLogRoot) error {
+	ret := _m.ctrl.Call(_m, "StoreSignedLogRoot", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockLogTXRecorder) StoreSignedLogRoot(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedLogRoot", arg0)
+func (_mr *_MockLogTXRecorder) StoreSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedLogRoot", arg0, arg1)
 }
 
-func (_m *MockLogTX) UpdateSequencedLeaves(_param0 []trillian.LogLeaf) error {
-	ret := _m.ctrl.Call(_m, "UpdateSequencedLeaves", _param0)
+func (_m *MockLogTX) UpdateSequencedLeaves(_param0 context.Context, _param1 []trillian.LogLeaf) error {
+	ret := _m.ctrl.Call(_m, "UpdateSequencedLeaves", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockLogTXRecorder) UpdateSequencedLeaves(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateSequencedLeaves", arg0)
+func (_mr *_MockLogTXRecorder) UpdateSequencedLeaves(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateSequencedLeaves", arg0, arg1)
 }
 
 func (_m *MockLogTX) WriteRevision() int64 {
@@ -239,37 +382,92 @@ func (_mr *_MockMapTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
-func (_m *MockMapTX) Get(_param0 int64, _param1 []trillian.Hash) ([]trillian.MapLeaf, error) {
-	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
+func (_m *MockMapTX) Get(_param0 context.Context, _param1 int64, _param2 []trillian.Hash) ([]trillian.MapLeaf, error) {
+	ret := _m.ctrl.Call(_m, "Get", _param0, _param1, _param2)
 	ret0, _ := ret[0].([]trillian.MapLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapTXRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
+func (_mr *_MockMapTXRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1, arg2)
+}
+
+func (_m *MockMapTX) GetHistory(_param0 context.Context, _param1 trillian.Hash, _param2 int64, _param3 int64) ([]MapLeafRevision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]MapLeafRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetHistory(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0, arg1, arg2, arg3)
 }
 
-func (_m *MockMapTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
-	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
+func (_m *MockMapTX) GetKeys(_param0 context.Context, _param1 trillian.Key, _param2 trillian.Key, _param3 int) ([]trillian.Key, error) {
+	ret := _m.ctrl.Call(_m, "GetKeys", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.Key)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetKeys(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetKeys", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockMapTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
+}
+
+func (_m *MockMapTX) GetMutations(_param0 context.Context, _param1 int64, _param2 int) ([]trillian.MapMutation, error) {
+	ret := _m.ctrl.Call(_m, "GetMutations", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.MapMutation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetMutations(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMutations", arg0, arg1, arg2)
+}
+
+func (_m *MockMapTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
+func (_mr *_MockMapTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockMapTX) GetSignedMapRoot(_param0 context.Context, _param1 int64) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetSignedMapRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRoot", arg0, arg1)
 }
 
-func (_m *MockMapTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
+func (_m *MockMapTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapTXRecorder) GetTreeRevisionAtSize(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0)
+func (_mr *_MockMapTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
 }
 
 func (_m *MockMapTX) IsOpen() bool {
@@ -282,15 +480,15 @@ func (_mr *_MockMapTXRecorder) IsOpen() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "IsOpen")
 }
 
-func (_m *MockMapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
-	ret := _m.ctrl.Call(_m, "LatestSignedMapRoot")
+func (_m *MockMapTX) LatestSignedMapRoot(_param0 context.Context) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedMapRoot", _param0)
 	ret0, _ := ret[0].(trillian.SignedMapRoot)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapTXRecorder) LatestSignedMapRoot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedMapRoot")
+func (_mr *_MockMapTXRecorder) LatestSignedMapRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedMapRoot", arg0)
 }
 
 func (_m *MockMapTX) Rollback() error {
@@ -303,34 +501,54 @@ func (_mr *_MockMapTXRecorder) Rollback() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rollback")
 }
 
-func (_m *MockMapTX) Set(_param0 trillian.Hash, _param1 trillian.MapLeaf) error {
-	ret := _m.ctrl.Call(_m, "Set", _param0, _param1)
+func (_m *MockMapTX) Set(_param0 context.Context, _param1 trillian.Hash, _param2 trillian.MapLeaf) error {
+	ret := _m.ctrl.Call(_m, "Set", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockMapTXRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Set", arg0, arg1, arg2)
+}
+
+func (_m *MockMapTX) SetMerkleNodes(_param0 context.Context, _param1 []Node) error {
+	ret := _m.ctrl.Call(_m, "SetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockMapTXRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Set", arg0, arg1)
+func (_mr *_MockMapTXRecorder) SetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0, arg1)
 }
 
-func (_m *MockMapTX) SetMerkleNodes(_param0 []Node) error {
-	ret := _m.ctrl.Call(_m, "SetMerkleNodes", _param0)
+func (_m *MockMapTX) StoreKey(_param0 context.Context, _param1 trillian.Key) error {
+	ret := _m.ctrl.Call(_m, "StoreKey", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockMapTXRecorder) SetMerkleNodes(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0)
+func (_mr *_MockMapTXRecorder) StoreKey(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreKey", arg0, arg1)
 }
 
-func (_m *MockMapTX) StoreSignedMapRoot(_param0 trillian.SignedMapRoot) error {
-	ret := _m.ctrl.Call(_m, "StoreSignedMapRoot", _param0)
+func (_m *MockMapTX) StoreMutation(_param0 context.Context, _param1 trillian.MapMutation) error {
+	ret := _m.ctrl.Call(_m, "StoreMutation", _param0, _param1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockMapTXRecorder) StoreSignedMapRoot(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedMapRoot", arg0)
+func (_mr *_MockMapTXRecorder) StoreMutation(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreMutation", arg0, arg1)
+}
+
+func (_m *MockMapTX) StoreSignedMapRoot(_param0 context.Context, _param1 trillian.SignedMapRoot) error {
+	ret := _m.ctrl.Call(_m, "StoreSignedMapRoot", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockMapTXRecorder) StoreSignedMapRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedMapRoot", arg0, arg1)
 }
 
 func (_m *MockMapTX) WriteRevision() int64 {
@@ -374,70 +592,125 @@ func (_mr *_MockReadOnlyLogTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
-func (_m *MockReadOnlyLogTX) GetLeavesByHash(_param0 []trillian.Hash, _param1 bool) ([]trillian.LogLeaf, error) {
-	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1)
+func (_m *MockReadOnlyLogTX) GetCosignatures(_param0 context.Context, _param1 int64) ([]trillian.Cosignature, error) {
+	ret := _m.ctrl.Call(_m, "GetCosignatures", _param0, _param1)
+	ret0, _ := ret[0].([]trillian.Cosignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetCosignatures(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetCosignatures", arg0, arg1)
+}
+
+func (_m *MockReadOnlyLogTX) GetLeavesByHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyLogTX) GetLeavesByIdentityHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByIdentityHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyLogTX) GetLeavesByIndex(_param0 context.Context, _param1 []int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0, _param1)
 	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByHash(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1)
+func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0, arg1)
 }
 
-func (_m *MockReadOnlyLogTX) GetLeavesByIndex(_param0 []int64) ([]trillian.LogLeaf, error) {
-	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0)
+func (_m *MockReadOnlyLogTX) GetLeavesByRange(_param0 context.Context, _param1 int64, _param2 int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1, _param2)
 	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByIndex(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
+func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByRange(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyLogTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
 }
 
-func (_m *MockReadOnlyLogTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
-	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
+func (_m *MockReadOnlyLogTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
+func (_mr *_MockReadOnlyLogTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
 }
 
-func (_m *MockReadOnlyLogTX) GetSequencedLeafCount() (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount")
+func (_m *MockReadOnlyLogTX) GetSequencedLeafCount(_param0 context.Context) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount", _param0)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) GetSequencedLeafCount() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
+func (_mr *_MockReadOnlyLogTXRecorder) GetSequencedLeafCount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", arg0)
+}
+
+func (_m *MockReadOnlyLogTX) GetSignedLogRoot(_param0 context.Context, _param1 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedLogRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedLogRoot", arg0, arg1)
 }
 
-func (_m *MockReadOnlyLogTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
+func (_m *MockReadOnlyLogTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) GetTreeRevisionAtSize(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0)
+func (_mr *_MockReadOnlyLogTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
 }
 
-func (_m *MockReadOnlyLogTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
-	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot")
+func (_m *MockReadOnlyLogTX) LatestSignedLogRoot(_param0 context.Context) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot", _param0)
 	ret0, _ := ret[0].(trillian.SignedLogRoot)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyLogTXRecorder) LatestSignedLogRoot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot")
+func (_mr *_MockReadOnlyLogTXRecorder) LatestSignedLogRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot", arg0)
 }
 
 // Mock of ReadOnlyMapTX interface
@@ -471,142 +744,816 @@ func (_mr *_MockReadOnlyMapTXRecorder) Commit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
 }
 
-func (_m *MockReadOnlyMapTX) Get(_param0 int64, _param1 []trillian.Hash) ([]trillian.MapLeaf, error) {
-	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
+func (_m *MockReadOnlyMapTX) Get(_param0 context.Context, _param1 int64, _param2 []trillian.Hash) ([]trillian.MapLeaf, error) {
+	ret := _m.ctrl.Call(_m, "Get", _param0, _param1, _param2)
 	ret0, _ := ret[0].([]trillian.MapLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyMapTXRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
+func (_mr *_MockReadOnlyMapTXRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyMapTX) GetHistory(_param0 context.Context, _param1 trillian.Hash, _param2 int64, _param3 int64) ([]MapLeafRevision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]MapLeafRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetHistory(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTX) GetKeys(_param0 context.Context, _param1 trillian.Key, _param2 trillian.Key, _param3 int) ([]trillian.Key, error) {
+	ret := _m.ctrl.Call(_m, "GetKeys", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.Key)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetKeys(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetKeys", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyMapTX) GetMutations(_param0 context.Context, _param1 int64, _param2 int) ([]trillian.MapMutation, error) {
+	ret := _m.ctrl.Call(_m, "GetMutations", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.MapMutation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetMutations(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMutations", arg0, arg1, arg2)
 }
 
-func (_m *MockReadOnlyMapTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
-	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
+func (_m *MockReadOnlyMapTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
 	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyMapTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
+func (_mr *_MockReadOnlyMapTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTX) GetSignedMapRoot(_param0 context.Context, _param1 int64) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetSignedMapRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRoot", arg0, arg1)
 }
 
-func (_m *MockReadOnlyMapTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
-	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
+func (_m *MockReadOnlyMapTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyMapTXRecorder) GetTreeRevisionAtSize(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0)
+func (_mr *_MockReadOnlyMapTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
 }
 
-func (_m *MockReadOnlyMapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
-	ret := _m.ctrl.Call(_m, "LatestSignedMapRoot")
+func (_m *MockReadOnlyMapTX) LatestSignedMapRoot(_param0 context.Context) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedMapRoot", _param0)
 	ret0, _ := ret[0].(trillian.SignedMapRoot)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockReadOnlyMapTXRecorder) LatestSignedMapRoot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedMapRoot")
+func (_mr *_MockReadOnlyMapTXRecorder) LatestSignedMapRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedMapRoot", arg0)
 }
 
-// Mock of MapStorage interface
-type MockMapStorage struct {
+// Mock of ReadOnlyLogTreeTX interface
+type MockReadOnlyLogTreeTX struct {
 	ctrl     *gomock.Controller
-	recorder *_MockMapStorageRecorder
+	recorder *_MockReadOnlyLogTreeTXRecorder
 }
 
-// Recorder for MockMapStorage (not exported)
-type _MockMapStorageRecorder struct {
-	mock *MockMapStorage
+// Recorder for MockReadOnlyLogTreeTX (not exported)
+type _MockReadOnlyLogTreeTXRecorder struct {
+	mock *MockReadOnlyLogTreeTX
 }
 
-func NewMockMapStorage(ctrl *gomock.Controller) *MockMapStorage {
-	mock := &MockMapStorage{ctrl: ctrl}
-	mock.recorder = &_MockMapStorageRecorder{mock}
+func NewMockReadOnlyLogTreeTX(ctrl *gomock.Controller) *MockReadOnlyLogTreeTX {
+	mock := &MockReadOnlyLogTreeTX{ctrl: ctrl}
+	mock.recorder = &_MockReadOnlyLogTreeTXRecorder{mock}
 	return mock
 }
 
-func (_m *MockMapStorage) EXPECT() *_MockMapStorageRecorder {
+func (_m *MockReadOnlyLogTreeTX) EXPECT() *_MockReadOnlyLogTreeTXRecorder {
 	return _m.recorder
 }
 
-func (_m *MockMapStorage) Begin() (MapTX, error) {
-	ret := _m.ctrl.Call(_m, "Begin")
-	ret0, _ := ret[0].(MapTX)
+func (_m *MockReadOnlyLogTreeTX) Commit() error {
+	ret := _m.ctrl.Call(_m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) Commit() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
+}
+
+func (_m *MockReadOnlyLogTreeTX) GetCosignatures(_param0 context.Context, _param1 int64) ([]trillian.Cosignature, error) {
+	ret := _m.ctrl.Call(_m, "GetCosignatures", _param0, _param1)
+	ret0, _ := ret[0].([]trillian.Cosignature)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapStorageRecorder) Begin() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin")
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetCosignatures(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetCosignatures", arg0, arg1)
 }
 
-func (_m *MockMapStorage) MapID() trillian.MapID {
-	ret := _m.ctrl.Call(_m, "MapID")
-	ret0, _ := ret[0].(trillian.MapID)
-	return ret0
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-func (_mr *_MockMapStorageRecorder) MapID() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "MapID")
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByHash", arg0, arg1, arg2)
 }
 
-func (_m *MockMapStorage) Snapshot() (ReadOnlyMapTX, error) {
-	ret := _m.ctrl.Call(_m, "Snapshot")
-	ret0, _ := ret[0].(ReadOnlyMapTX)
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByIdentityHash(_param0 context.Context, _param1 []trillian.Hash, _param2 bool) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIdentityHash", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockMapStorageRecorder) Snapshot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Snapshot")
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByIdentityHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIdentityHash", arg0, arg1, arg2)
 }
 
-// Mock of LogStorage interface
-type MockLogStorage struct {
-	ctrl     *gomock.Controller
-	recorder *_MockLogStorageRecorder
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByIndex(_param0 context.Context, _param1 []int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByIndex", _param0, _param1)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Recorder for MockLogStorage (not exported)
-type _MockLogStorageRecorder struct {
-	mock *MockLogStorage
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0, arg1)
 }
 
-func NewMockLogStorage(ctrl *gomock.Controller) *MockLogStorage {
-	mock := &MockLogStorage{ctrl: ctrl}
-	mock.recorder = &_MockLogStorageRecorder{mock}
-	return mock
+func (_m *MockReadOnlyLogTreeTX) GetLeavesByRange(_param0 context.Context, _param1 int64, _param2 int64) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByRange", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-func (_m *MockLogStorage) EXPECT() *_MockLogStorageRecorder {
-	return _m.recorder
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetLeavesByRange(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByRange", arg0, arg1, arg2)
 }
 
-func (_m *MockLogStorage) Begin() (LogTX, error) {
-	ret := _m.ctrl.Call(_m, "Begin")
-	ret0, _ := ret[0].(LogTX)
+func (_m *MockReadOnlyLogTreeTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogStorageRecorder) Begin() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin")
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
 }
 
-func (_m *MockLogStorage) Snapshot() (ReadOnlyLogTX, error) {
-	ret := _m.ctrl.Call(_m, "Snapshot")
-	ret0, _ := ret[0].(ReadOnlyLogTX)
+func (_m *MockReadOnlyLogTreeTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockLogStorageRecorder) Snapshot() *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Snapshot")
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyLogTreeTX) GetSequencedLeafCount(_param0 context.Context) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount", _param0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetSequencedLeafCount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", arg0)
+}
+
+func (_m *MockReadOnlyLogTreeTX) GetSignedLogRoot(_param0 context.Context, _param1 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedLogRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedLogRoot", arg0, arg1)
+}
+
+func (_m *MockReadOnlyLogTreeTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
+}
+
+func (_m *MockReadOnlyLogTreeTX) LatestSignedLogRoot(_param0 context.Context) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot", _param0)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) LatestSignedLogRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedLogRoot", arg0)
+}
+
+func (_m *MockReadOnlyLogTreeTX) ReadRevision() int64 {
+	ret := _m.ctrl.Call(_m, "ReadRevision")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) ReadRevision() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReadRevision")
+}
+
+func (_m *MockReadOnlyLogTreeTX) TreeSize() int64 {
+	ret := _m.ctrl.Call(_m, "TreeSize")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyLogTreeTXRecorder) TreeSize() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TreeSize")
+}
+
+// Mock of ReadOnlyMapTreeTX interface
+type MockReadOnlyMapTreeTX struct {
+	ctrl     *gomock.Controller
+	recorder *_MockReadOnlyMapTreeTXRecorder
+}
+
+// Recorder for MockReadOnlyMapTreeTX (not exported)
+type _MockReadOnlyMapTreeTXRecorder struct {
+	mock *MockReadOnlyMapTreeTX
+}
+
+func NewMockReadOnlyMapTreeTX(ctrl *gomock.Controller) *MockReadOnlyMapTreeTX {
+	mock := &MockReadOnlyMapTreeTX{ctrl: ctrl}
+	mock.recorder = &_MockReadOnlyMapTreeTXRecorder{mock}
+	return mock
+}
+
+func (_m *MockReadOnlyMapTreeTX) EXPECT() *_MockReadOnlyMapTreeTXRecorder {
+	return _m.recorder
+}
+
+func (_m *MockReadOnlyMapTreeTX) Commit() error {
+	ret := _m.ctrl.Call(_m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) Commit() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
+}
+
+func (_m *MockReadOnlyMapTreeTX) Get(_param0 context.Context, _param1 int64, _param2 []trillian.Hash) ([]trillian.MapLeaf, error) {
+	ret := _m.ctrl.Call(_m, "Get", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.MapLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetHistory(_param0 context.Context, _param1 trillian.Hash, _param2 int64, _param3 int64) ([]MapLeafRevision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]MapLeafRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetHistory(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetKeys(_param0 context.Context, _param1 trillian.Key, _param2 trillian.Key, _param3 int) ([]trillian.Key, error) {
+	ret := _m.ctrl.Call(_m, "GetKeys", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.Key)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetKeys(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetKeys", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetMerkleNodes(_param0 context.Context, _param1 int64, _param2 []NodeID) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetMutations(_param0 context.Context, _param1 int64, _param2 int) ([]trillian.MapMutation, error) {
+	ret := _m.ctrl.Call(_m, "GetMutations", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]trillian.MapMutation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetMutations(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMutations", arg0, arg1, arg2)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetProofPath(_param0 context.Context, _param1 int64, _param2 NodeID, _param3 int64) ([]Node, error) {
+	ret := _m.ctrl.Call(_m, "GetProofPath", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetProofPath(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetProofPath", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetSignedMapRoot(_param0 context.Context, _param1 int64) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRoot", _param0, _param1)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetSignedMapRoot(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRoot", arg0, arg1)
+}
+
+func (_m *MockReadOnlyMapTreeTX) GetTreeRevisionAtSize(_param0 context.Context, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0, _param1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) GetTreeRevisionAtSize(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0, arg1)
+}
+
+func (_m *MockReadOnlyMapTreeTX) LatestSignedMapRoot(_param0 context.Context) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "LatestSignedMapRoot", _param0)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) LatestSignedMapRoot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestSignedMapRoot", arg0)
+}
+
+func (_m *MockReadOnlyMapTreeTX) ReadRevision() int64 {
+	ret := _m.ctrl.Call(_m, "ReadRevision")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyMapTreeTXRecorder) ReadRevision() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReadRevision")
+}
+
+// Mock of MapStorage interface
+type MockMapStorage struct {
+	ctrl     *gomock.Controller
+	recorder *_MockMapStorageRecorder
+}
+
+// Recorder for MockMapStorage (not exported)
+type _MockMapStorageRecorder struct {
+	mock *MockMapStorage
+}
+
+func NewMockMapStorage(ctrl *gomock.Controller) *MockMapStorage {
+	mock := &MockMapStorage{ctrl: ctrl}
+	mock.recorder = &_MockMapStorageRecorder{mock}
+	return mock
+}
+
+func (_m *MockMapStorage) EXPECT() *_MockMapStorageRecorder {
+	return _m.recorder
+}
+
+func (_m *MockMapStorage) Begin(_param0 context.Context) (MapTX, error) {
+	ret := _m.ctrl.Call(_m, "Begin", _param0)
+	ret0, _ := ret[0].(MapTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapStorageRecorder) Begin(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin", arg0)
+}
+
+func (_m *MockMapStorage) HashAlgorithm() trillian.HashAlgorithm {
+	ret := _m.ctrl.Call(_m, "HashAlgorithm")
+	ret0, _ := ret[0].(trillian.HashAlgorithm)
+	return ret0
+}
+
+func (_mr *_MockMapStorageRecorder) HashAlgorithm() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "HashAlgorithm")
+}
+
+func (_m *MockMapStorage) MapID() trillian.MapID {
+	ret := _m.ctrl.Call(_m, "MapID")
+	ret0, _ := ret[0].(trillian.MapID)
+	return ret0
+}
+
+func (_mr *_MockMapStorageRecorder) MapID() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MapID")
+}
+
+func (_m *MockMapStorage) Snapshot(_param0 context.Context) (ReadOnlyMapTX, error) {
+	ret := _m.ctrl.Call(_m, "Snapshot", _param0)
+	ret0, _ := ret[0].(ReadOnlyMapTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapStorageRecorder) Snapshot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Snapshot", arg0)
+}
+
+func (_m *MockMapStorage) SnapshotForTree(_param0 context.Context, _param1 int64) (ReadOnlyMapTreeTX, error) {
+	ret := _m.ctrl.Call(_m, "SnapshotForTree", _param0, _param1)
+	ret0, _ := ret[0].(ReadOnlyMapTreeTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapStorageRecorder) SnapshotForTree(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SnapshotForTree", arg0, arg1)
+}
+
+// Mock of LogStorage interface
+type MockLogStorage struct {
+	ctrl     *gomock.Controller
+	recorder *_MockLogStorageRecorder
+}
+
+// Recorder for MockLogStorage (not exported)
+type _MockLogStorageRecorder struct {
+	mock *MockLogStorage
+}
+
+func NewMockLogStorage(ctrl *gomock.Controller) *MockLogStorage {
+	mock := &MockLogStorage{ctrl: ctrl}
+	mock.recorder = &_MockLogStorageRecorder{mock}
+	return mock
+}
+
+func (_m *MockLogStorage) EXPECT() *_MockLogStorageRecorder {
+	return _m.recorder
+}
+
+func (_m *MockLogStorage) Begin(_param0 context.Context) (LogTX, error) {
+	ret := _m.ctrl.Call(_m, "Begin", _param0)
+	ret0, _ := ret[0].(LogTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogStorageRecorder) Begin(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin", arg0)
+}
+
+func (_m *MockLogStorage) HashAlgorithm() trillian.HashAlgorithm {
+	ret := _m.ctrl.Call(_m, "HashAlgorithm")
+	ret0, _ := ret[0].(trillian.HashAlgorithm)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) HashAlgorithm() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "HashAlgorithm")
+}
+
+func (_m *MockLogStorage) Snapshot(_param0 context.Context) (ReadOnlyLogTX, error) {
+	ret := _m.ctrl.Call(_m, "Snapshot", _param0)
+	ret0, _ := ret[0].(ReadOnlyLogTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogStorageRecorder) Snapshot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Snapshot", arg0)
+}
+
+func (_m *MockLogStorage) SnapshotForTree(_param0 context.Context, _param1 int64) (ReadOnlyLogTreeTX, error) {
+	ret := _m.ctrl.Call(_m, "SnapshotForTree", _param0, _param1)
+	ret0, _ := ret[0].(ReadOnlyLogTreeTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogStorageRecorder) SnapshotForTree(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SnapshotForTree", arg0, arg1)
+}
+
+func (_m *MockLogStorage) TreeType() trillian.TreeType {
+	ret := _m.ctrl.Call(_m, "TreeType")
+	ret0, _ := ret[0].(trillian.TreeType)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) TreeType() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TreeType")
+}
+
+// Mock of AdminStorage interface
+type MockAdminStorage struct {
+	ctrl     *gomock.Controller
+	recorder *_MockAdminStorageRecorder
+}
+
+// Recorder for MockAdminStorage (not exported)
+type _MockAdminStorageRecorder struct {
+	mock *MockAdminStorage
+}
+
+func NewMockAdminStorage(ctrl *gomock.Controller) *MockAdminStorage {
+	mock := &MockAdminStorage{ctrl: ctrl}
+	mock.recorder = &_MockAdminStorageRecorder{mock}
+	return mock
+}
+
+func (_m *MockAdminStorage) EXPECT() *_MockAdminStorageRecorder {
+	return _m.recorder
+}
+
+func (_m *MockAdminStorage) Begin(_param0 context.Context) (AdminTX, error) {
+	ret := _m.ctrl.Call(_m, "Begin", _param0)
+	ret0, _ := ret[0].(AdminTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminStorageRecorder) Begin(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin", arg0)
+}
+
+func (_m *MockAdminStorage) Snapshot(_param0 context.Context) (ReadOnlyAdminTX, error) {
+	ret := _m.ctrl.Call(_m, "Snapshot", _param0)
+	ret0, _ := ret[0].(ReadOnlyAdminTX)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminStorageRecorder) Snapshot(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Snapshot", arg0)
+}
+
+// Mock of AdminTX interface
+type MockAdminTX struct {
+	ctrl     *gomock.Controller
+	recorder *_MockAdminTXRecorder
+}
+
+// Recorder for MockAdminTX (not exported)
+type _MockAdminTXRecorder struct {
+	mock *MockAdminTX
+}
+
+func NewMockAdminTX(ctrl *gomock.Controller) *MockAdminTX {
+	mock := &MockAdminTX{ctrl: ctrl}
+	mock.recorder = &_MockAdminTXRecorder{mock}
+	return mock
+}
+
+func (_m *MockAdminTX) EXPECT() *_MockAdminTXRecorder {
+	return _m.recorder
+}
+
+func (_m *MockAdminTX) AddTreeUsage(_param0 *trillian.TreeUsage) error {
+	ret := _m.ctrl.Call(_m, "AddTreeUsage", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockAdminTXRecorder) AddTreeUsage(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddTreeUsage", arg0)
+}
+
+func (_m *MockAdminTX) Commit() error {
+	ret := _m.ctrl.Call(_m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockAdminTXRecorder) Commit() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
+}
+
+func (_m *MockAdminTX) CreateTree(_param0 *trillian.Tree) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "CreateTree", _param0)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) CreateTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateTree", arg0)
+}
+
+func (_m *MockAdminTX) GetTree(_param0 int64) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "GetTree", _param0)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) GetTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTree", arg0)
+}
+
+func (_m *MockAdminTX) HardDeleteTree(_param0 int64) error {
+	ret := _m.ctrl.Call(_m, "HardDeleteTree", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockAdminTXRecorder) HardDeleteTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "HardDeleteTree", arg0)
+}
+
+func (_m *MockAdminTX) GetTreeUsage(_param0 int64) (*trillian.TreeUsage, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeUsage", _param0)
+	ret0, _ := ret[0].(*trillian.TreeUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) GetTreeUsage(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeUsage", arg0)
+}
+
+func (_m *MockAdminTX) ListTrees(_param0 bool) ([]*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "ListTrees", _param0)
+	ret0, _ := ret[0].([]*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) ListTrees(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListTrees", arg0)
+}
+
+func (_m *MockAdminTX) Rollback() error {
+	ret := _m.ctrl.Call(_m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockAdminTXRecorder) Rollback() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rollback")
+}
+
+func (_m *MockAdminTX) SoftDeleteTree(_param0 int64) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "SoftDeleteTree", _param0)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) SoftDeleteTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SoftDeleteTree", arg0)
+}
+
+func (_m *MockAdminTX) UndeleteTree(_param0 int64) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "UndeleteTree", _param0)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) UndeleteTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UndeleteTree", arg0)
+}
+
+func (_m *MockAdminTX) UpdateTree(_param0 int64, _param1 func(*trillian.Tree)) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "UpdateTree", _param0, _param1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockAdminTXRecorder) UpdateTree(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateTree", arg0, arg1)
+}
+
+// Mock of ReadOnlyAdminTX interface
+type MockReadOnlyAdminTX struct {
+	ctrl     *gomock.Controller
+	recorder *_MockReadOnlyAdminTXRecorder
+}
+
+// Recorder for MockReadOnlyAdminTX (not exported)
+type _MockReadOnlyAdminTXRecorder struct {
+	mock *MockReadOnlyAdminTX
+}
+
+func NewMockReadOnlyAdminTX(ctrl *gomock.Controller) *MockReadOnlyAdminTX {
+	mock := &MockReadOnlyAdminTX{ctrl: ctrl}
+	mock.recorder = &_MockReadOnlyAdminTXRecorder{mock}
+	return mock
+}
+
+func (_m *MockReadOnlyAdminTX) EXPECT() *_MockReadOnlyAdminTXRecorder {
+	return _m.recorder
+}
+
+func (_m *MockReadOnlyAdminTX) Commit() error {
+	ret := _m.ctrl.Call(_m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyAdminTXRecorder) Commit() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Commit")
+}
+
+func (_m *MockReadOnlyAdminTX) GetTree(_param0 int64) (*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "GetTree", _param0)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyAdminTXRecorder) GetTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTree", arg0)
+}
+
+func (_m *MockReadOnlyAdminTX) GetTreeUsage(_param0 int64) (*trillian.TreeUsage, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeUsage", _param0)
+	ret0, _ := ret[0].(*trillian.TreeUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyAdminTXRecorder) GetTreeUsage(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeUsage", arg0)
+}
+
+func (_m *MockReadOnlyAdminTX) ListTrees(_param0 bool) ([]*trillian.Tree, error) {
+	ret := _m.ctrl.Call(_m, "ListTrees", _param0)
+	ret0, _ := ret[0].([]*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyAdminTXRecorder) ListTrees(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListTrees", arg0)
+}
+
+func (_m *MockReadOnlyAdminTX) Rollback() error {
+	ret := _m.ctrl.Call(_m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockReadOnlyAdminTXRecorder) Rollback() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rollback")
 }
@@ -0,0 +1,378 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+const selectTreesSql string = `SELECT TreeId,KeyId,TreeType,TreeState,LeafHasherType,AllowsDuplicateLeaves,
+		 DisplayName,Description,CreateTimeMillis,UpdateTimeMillis,Deleted,DeleteTimeMillis
+		 FROM Trees`
+const selectTreeByIDSql string = selectTreesSql + " WHERE TreeId=?"
+const selectTreeByIDForUpdateSql string = selectTreeByIDSql + " FOR UPDATE"
+const selectAllTreesSql string = selectTreesSql + " ORDER BY TreeId"
+const selectLiveTreesSql string = selectTreesSql + " WHERE Deleted=FALSE ORDER BY TreeId"
+const insertTreeSql string = `INSERT INTO Trees(TreeId,KeyId,TreeType,TreeState,LeafHasherType,TreeHasherType,
+		 AllowsDuplicateLeaves,DisplayName,Description,CreateTimeMillis,UpdateTimeMillis)
+		 VALUES(?,?,?,?,?,?,?,?,?,?,?)`
+const insertTreeControlSql string = `INSERT INTO TreeControl(TreeId,ReadOnlyRequests,SigningEnabled,SequencingEnabled)
+		 VALUES(?,FALSE,TRUE,TRUE)`
+const updateTreeSql string = `UPDATE Trees SET TreeState=?,DisplayName=?,Description=?,UpdateTimeMillis=?,
+		 Deleted=?,DeleteTimeMillis=?
+		 WHERE TreeId=?`
+const selectTreeUsageSql string = `SELECT Requests,BytesIn,BytesOut,LeavesQueued,UpdateTimeMillis
+		 FROM TreeUsage WHERE TreeId=?`
+const addTreeUsageSql string = `INSERT INTO TreeUsage(TreeId,Requests,BytesIn,BytesOut,LeavesQueued,UpdateTimeMillis)
+		 VALUES(?,?,?,?,?,?) ON DUPLICATE KEY UPDATE Requests=Requests+VALUES(Requests),BytesIn=BytesIn+VALUES(BytesIn),
+		 BytesOut=BytesOut+VALUES(BytesOut),LeavesQueued=LeavesQueued+VALUES(LeavesQueued),
+		 UpdateTimeMillis=VALUES(UpdateTimeMillis)`
+
+// Not all of the tables are removed when the tree row is deleted so these have to be
+// cleared first, in this order.
+var deleteTreeSqls = []string{
+	"DELETE FROM Unsequenced WHERE TreeId=?",
+	"DELETE FROM SequencedLeafData WHERE TreeId=?",
+	"DELETE FROM LeafData WHERE TreeId=?",
+	"DELETE FROM TreeControl WHERE TreeId=?",
+}
+
+const deleteTreeSql string = "DELETE FROM Trees WHERE TreeId=?"
+
+type mySQLAdminStorage struct {
+	db     *sql.DB
+	limits dbLimits
+	shards *subtreeShards
+}
+
+// NewAdminStorage creates an AdminStorage for the tree metadata in the MySQL database
+// identified by dbURL.
+func NewAdminStorage(dbURL string) (storage.AdminStorage, error) {
+	db, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mySQLAdminStorage{db: db}, nil
+}
+
+func (m *mySQLAdminStorage) beginInternal(ctx context.Context) (*adminTX, error) {
+	if err := m.limits.allow(); err != nil {
+		return nil, err
+	}
+
+	tx, err := m.db.Begin()
+	m.limits.done(ctx, err)
+	if err != nil {
+		logging.Warningf(ctx, "Could not start admin TX: %s", err)
+		return nil, err
+	}
+
+	return &adminTX{tx: tx, shards: m.shards, ctx: ctx}, nil
+}
+
+func (m *mySQLAdminStorage) Begin(ctx context.Context) (storage.AdminTX, error) {
+	return m.beginInternal(ctx)
+}
+
+func (m *mySQLAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return m.beginInternal(ctx)
+}
+
+type adminTX struct {
+	tx     *sql.Tx
+	shards *subtreeShards
+	// ctx is what problems met by the transaction are logged against
+	ctx context.Context
+}
+
+func (t *adminTX) Commit() error {
+	err := t.tx.Commit()
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Admin TX commit error: %s", err)
+	}
+
+	return err
+}
+
+func (t *adminTX) Rollback() error {
+	err := t.tx.Rollback()
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Admin TX rollback error: %s", err)
+	}
+
+	return err
+}
+
+// rowScanner is satisfied by both sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func readTree(row rowScanner) (*trillian.Tree, error) {
+	var tree trillian.Tree
+	var treeType, treeState, hashAlgorithm string
+
+	if err := row.Scan(&tree.TreeId, &tree.KeyId, &treeType, &treeState, &hashAlgorithm, &tree.AllowDuplicateLeaves,
+		&tree.DisplayName, &tree.Description, &tree.CreateTimeMillis, &tree.UpdateTimeMillis, &tree.Deleted,
+		&tree.DeleteTimeMillis); err != nil {
+		return nil, err
+	}
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		tree.TreeType = trillian.TreeType(v)
+	} else {
+		return nil, fmt.Errorf("unknown tree type %s for tree %d", treeType, tree.TreeId)
+	}
+
+	if v, ok := trillian.TreeState_value[treeState]; ok {
+		tree.TreeState = trillian.TreeState(v)
+	} else {
+		return nil, fmt.Errorf("unknown tree state %s for tree %d", treeState, tree.TreeId)
+	}
+
+	if v, ok := trillian.HashAlgorithm_value[hashAlgorithm]; ok {
+		tree.HashAlgorithm = trillian.HashAlgorithm(v)
+	} else {
+		return nil, fmt.Errorf("unknown hash algorithm %s for tree %d", hashAlgorithm, tree.TreeId)
+	}
+
+	return &tree, nil
+}
+
+func (t *adminTX) getTree(query string, treeID int64) (*trillian.Tree, error) {
+	tree, err := readTree(t.tx.QueryRow(query, treeID))
+
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrTreeNotFound
+	} else if err != nil {
+		logging.Warningf(t.ctx, "Failed to read tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func (t *adminTX) GetTree(treeID int64) (*trillian.Tree, error) {
+	return t.getTree(selectTreeByIDSql, treeID)
+}
+
+func (t *adminTX) ListTrees(includeDeleted bool) ([]*trillian.Tree, error) {
+	query := selectLiveTreesSql
+	if includeDeleted {
+		query = selectAllTreesSql
+	}
+
+	rows, err := t.tx.Query(query)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to list trees: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	trees := make([]*trillian.Tree, 0)
+
+	for rows.Next() {
+		tree, err := readTree(rows)
+		if err != nil {
+			logging.Warningf(t.ctx, "Failed to read tree: %s", err)
+			return nil, err
+		}
+
+		trees = append(trees, tree)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return trees, nil
+}
+
+func (t *adminTX) GetTreeUsage(treeID int64) (*trillian.TreeUsage, error) {
+	usage := &trillian.TreeUsage{TreeId: treeID}
+	err := t.tx.QueryRow(selectTreeUsageSql, treeID).Scan(&usage.Requests, &usage.BytesIn, &usage.BytesOut,
+		&usage.LeavesQueued, &usage.UpdateTimeMillis)
+
+	if err == sql.ErrNoRows {
+		return usage, nil
+	} else if err != nil {
+		logging.Warningf(t.ctx, "Failed to read usage of tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (t *adminTX) CreateTree(tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.NewTreeID()
+	if err != nil {
+		return nil, err
+	}
+
+	newTree := *tree
+	newTree.TreeId = id
+	newTree.TreeState = trillian.TreeState_ACTIVE
+	newTree.CreateTimeMillis = nowMillis()
+	newTree.UpdateTimeMillis = newTree.CreateTimeMillis
+
+	// Both hashers are currently derived from the same algorithm
+	hashAlgorithm := newTree.HashAlgorithm.String()
+
+	_, err = t.tx.Exec(insertTreeSql, newTree.TreeId, newTree.KeyId, newTree.TreeType.String(), newTree.TreeState.String(),
+		hashAlgorithm, hashAlgorithm, newTree.AllowDuplicateLeaves, newTree.DisplayName, newTree.Description,
+		newTree.CreateTimeMillis, newTree.UpdateTimeMillis)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to insert tree: %s", err)
+		return nil, err
+	}
+
+	if _, err := t.tx.Exec(insertTreeControlSql, newTree.TreeId); err != nil {
+		logging.Warningf(t.ctx, "Failed to insert tree control for tree %d: %s", newTree.TreeId, err)
+		return nil, err
+	}
+
+	if t.shards != nil {
+		for i, name := range t.shards.names() {
+			if _, err := t.tx.Exec(insertSubtreeShardSql, newTree.TreeId, i, name); err != nil {
+				logging.Warningf(t.ctx, "Failed to insert shard map for tree %d: %s", newTree.TreeId, err)
+				return nil, err
+			}
+		}
+	}
+
+	return &newTree, nil
+}
+
+func (t *adminTX) UpdateTree(treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		tree := *orig
+		updateFunc(&tree)
+		if err := storage.ValidateTreeForUpdate(orig, &tree); err != nil {
+			return nil, err
+		}
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) SoftDeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if orig.Deleted {
+			return nil, storage.ErrTreeDeleted
+		}
+		tree := *orig
+		tree.Deleted = true
+		tree.DeleteTimeMillis = nowMillis()
+		return &tree, nil
+	})
+}
+
+func (t *adminTX) UndeleteTree(treeID int64) (*trillian.Tree, error) {
+	return t.updateTree(treeID, func(orig *trillian.Tree) (*trillian.Tree, error) {
+		if !orig.Deleted {
+			return nil, storage.ErrTreeNotDeleted
+		}
+		tree := *orig
+		tree.Deleted = false
+		tree.DeleteTimeMillis = 0
+		return &tree, nil
+	})
+}
+
+// updateTree locks the row for the tree with the specified ID and stores the mutable
+// settings of the tree returned by f.
+func (t *adminTX) updateTree(treeID int64, f func(orig *trillian.Tree) (*trillian.Tree, error)) (*trillian.Tree, error) {
+	orig, err := t.getTree(selectTreeByIDForUpdateSql, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := f(orig)
+	if err != nil {
+		return nil, err
+	}
+	tree.UpdateTimeMillis = nowMillis()
+
+	// The row is locked by the read above so it can't have gone away. MySQL only counts
+	// rows that actually changed as affected so there's no point checking the count.
+	_, err = t.tx.Exec(updateTreeSql, tree.TreeState.String(), tree.DisplayName, tree.Description,
+		tree.UpdateTimeMillis, tree.Deleted, tree.DeleteTimeMillis, tree.TreeId)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to update tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func (t *adminTX) AddTreeUsage(usage *trillian.TreeUsage) error {
+	_, err := t.tx.Exec(addTreeUsageSql, usage.TreeId, usage.Requests, usage.BytesIn, usage.BytesOut,
+		usage.LeavesQueued, nowMillis())
+
+	if err != nil {
+		logging.Warningf(t.ctx, "Failed to add usage of tree %d: %s", usage.TreeId, err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *adminTX) HardDeleteTree(treeID int64) error {
+	tree, err := t.getTree(selectTreeByIDForUpdateSql, treeID)
+	if err != nil {
+		return err
+	}
+
+	if !tree.Deleted {
+		return storage.ErrTreeNotDeleted
+	}
+
+	for _, stmt := range deleteTreeSqls {
+		if _, err := t.tx.Exec(stmt, treeID); err != nil {
+			logging.Warningf(t.ctx, "Failed to delete data for tree %d: %s", treeID, err)
+			return err
+		}
+	}
+
+	// The subtrees on the tree's shards are deleted straight away rather than when the
+	// transaction commits. The tree is already deleted, so nothing reads them.
+	shards, err := getTreeShards(t.tx, t.shards, treeID)
+	if err != nil {
+		return err
+	}
+
+	for i, db := range shards {
+		if _, err := db.Exec(deleteTreeSubtreesSql, treeID); err != nil {
+			logging.Warningf(t.ctx, "Failed to delete subtrees of tree %d from shard %d: %s", treeID, i, err)
+			return err
+		}
+	}
+
+	result, err := t.tx.Exec(deleteTreeSql, treeID)
+
+	if err := checkResultOkAndRowCountIs(result, err, 1); err != nil {
+		logging.Warningf(t.ctx, "Failed to delete tree %d: %s", treeID, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/sqltrace"
+	"golang.org/x/net/context"
+)
+
+// Errors reported by the database that mean it can't take any more work, rather than
+// that something was wrong with a statement
+var overloadedErrors = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR, too many connections
+	1203: true, // ER_TOO_MANY_USER_CONNECTIONS
+}
+
+// dbLimits are how storage limits its use of a database shared with other trees, see
+// storage.ConnectionConfig. The zero value doesn't limit anything.
+type dbLimits struct {
+	queryTimeout time.Duration
+	// breaker is told the outcome of the work done on the database, if it's not nil
+	breaker *storage.CircuitBreaker
+}
+
+// allow returns storage.ErrUnhealthy if no transactions should be started on the database
+func (l dbLimits) allow() error {
+	if l.breaker == nil {
+		return nil
+	}
+
+	return l.breaker.Allow()
+}
+
+// done records the outcome of some work done on the database for a request with context
+// ctx. The database failing counts against its health but errors it reported itself, like
+// constraint violations, say that it's working, and requests that gave up say nothing.
+func (l dbLimits) done(ctx context.Context, err error) {
+	if l.breaker == nil {
+		return
+	}
+
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		if overloadedErrors[mysqlErr.Number] {
+			l.breaker.Failure()
+		} else {
+			l.breaker.Success()
+		}
+		return
+	}
+
+	switch {
+	case err == nil, err == sql.ErrNoRows:
+		l.breaker.Success()
+	case ctx.Err() == nil:
+		l.breaker.Failure()
+	}
+}
+
+// txOptions returns the options for the statements of a transaction
+func (l dbLimits) txOptions() sqltrace.Options {
+	return sqltrace.Options{QueryTimeout: l.queryTimeout, Done: l.done}
+}
+
+// conn takes a connection from db's pool for a transaction for a request with context ctx,
+// waiting at most queryTimeout for one to be free. The connection must be closed once the
+// transaction is over.
+func (l dbLimits) conn(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	connCtx := ctx
+
+	if l.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		connCtx, cancel = context.WithTimeout(ctx, l.queryTimeout)
+		defer cancel()
+	}
+
+	conn, err := db.Conn(connCtx)
+	l.done(ctx, err)
+
+	return conn, err
+}
+
+// applyConnectionConfig sets the limits of db's connection pool
+func applyConnectionConfig(db *sql.DB, config storage.ConnectionConfig) {
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+}
@@ -4,61 +4,148 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
-	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
+	"golang.org/x/net/context"
 )
 
-const getTreePropertiesSql string = "SELECT AllowsDuplicateLeaves FROM Trees WHERE TreeId=?"
+const getTreePropertiesSql string = "SELECT AllowsDuplicateLeaves,TreeType FROM Trees WHERE TreeId=?"
 const getTreeParametersSql string = "SELECT ReadOnlyRequests From TreeControl WHERE TreeID=?"
-const selectQueuedLeavesSql string = `SELECT LeafHash,Payload,SignedEntryTimestamp
+
+// The FOR UPDATE locks the rows being sequenced so that concurrent sequencers for the same
+// tree serialize rather than integrating the same leaves twice.
+// Entries are only dequeued or claimed once their not-before time has passed. Those of
+// pre-ordered logs don't have one, but take the time too so the queries share arguments.
+const selectQueuedLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,UNIX_TIMESTAMP(QueueTimestamp)
+		 FROM Unsequenced
+		 WHERE TreeID=? AND NotBefore<=?
+		 ORDER BY Priority DESC, QueueTimestamp DESC LIMIT ? FOR UPDATE`
+const selectQueuedSequencedLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,SequenceNumber
+		 FROM Unsequenced
+		 WHERE TreeID=? AND SequenceNumber >= ? AND NotBefore<=?
+		 ORDER BY SequenceNumber LIMIT ? FOR UPDATE`
+
+// Overdue entries are those queued before a time, in seconds, and the longest queued come first
+const selectOverdueLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,UNIX_TIMESTAMP(QueueTimestamp)
+		 FROM Unsequenced
+		 WHERE TreeID=? AND NotBefore<=? AND UNIX_TIMESTAMP(QueueTimestamp)<?
+		 ORDER BY QueueTimestamp LIMIT ? FOR UPDATE`
+
+// Queued entries can be claimed if no one holds them, the owner holds them already or the
+// claim on them has expired. They're locked as they're selected, so a concurrent claim
+// waits for this one to finish and then skips them.
+const selectClaimableLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,UNIX_TIMESTAMP(QueueTimestamp)
+		 FROM Unsequenced
+		 WHERE TreeID=? AND (ClaimOwner IS NULL OR ClaimOwner=? OR ClaimExpiry<?) AND NotBefore<=?
+		 ORDER BY Priority DESC, QueueTimestamp DESC LIMIT ? FOR UPDATE`
+const selectClaimableSequencedLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,SequenceNumber
 		 FROM Unsequenced
-		 WHERE TreeID=?
-		 ORDER BY QueueTimestamp DESC LIMIT ?`
-const insertUnsequencedLeafSql string = `INSERT INTO LeafData(TreeId,LeafHash,TheData)
-		 VALUES(?,?,?) ON DUPLICATE KEY UPDATE LeafHash=LeafHash`
-const insertUnsequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload)
-     VALUES(?,?,?,?,?)`
-const insertSequencedLeafSql string = `INSERT INTO SequencedLeafData(TreeId,LeafHash,SequenceNumber,SignedEntryTimestamp)
-		 VALUES(?,?,?,?)`
+		 WHERE TreeID=? AND SequenceNumber >= ? AND (ClaimOwner IS NULL OR ClaimOwner=? OR ClaimExpiry<?) AND NotBefore<=?
+		 ORDER BY SequenceNumber LIMIT ? FOR UPDATE`
+const selectClaimedLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,UNIX_TIMESTAMP(QueueTimestamp)
+		 FROM Unsequenced
+		 WHERE TreeID=? AND ClaimOwner=?
+		 ORDER BY Priority DESC, QueueTimestamp DESC LIMIT ? FOR UPDATE`
+const selectClaimedSequencedLeavesSql string = `SELECT LeafHash,MessageId,Payload,SignedEntryTimestamp,SequenceNumber
+		 FROM Unsequenced
+		 WHERE TreeID=? AND SequenceNumber >= ? AND ClaimOwner=?
+		 ORDER BY SequenceNumber LIMIT ? FOR UPDATE`
+const selectQueuedLeafCountSql string = "SELECT COUNT(*) FROM Unsequenced WHERE TreeId=?"
+const selectOldestQueuedLeafSql string = "SELECT UNIX_TIMESTAMP(MIN(QueueTimestamp)) FROM Unsequenced WHERE TreeId=? AND NotBefore<=?"
+const selectQueuedLeafBySequenceSql string = `SELECT l.LeafHash,l.TheData,u.SignedEntryTimestamp
+		 FROM LeafData l,Unsequenced u
+		 WHERE l.TreeId = u.TreeId AND l.LeafHash = u.LeafHash
+		 AND u.TreeId=? AND u.SequenceNumber=?`
+const selectQueuedLeafByHashSql string = `SELECT l.TheData,u.SignedEntryTimestamp
+		 FROM LeafData l,Unsequenced u
+		 WHERE l.TreeId = u.TreeId AND l.LeafHash = u.LeafHash
+		 AND u.TreeId=? AND u.LeafHash=? LIMIT 1`
 const selectSequencedLeafCountSql string = "SELECT COUNT(*) FROM SequencedLeafData"
-const selectLatestSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
+const selectLatestSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,Metadata
 		 FROM TreeHead WHERE TreeId=?
 		 ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+const selectSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,Metadata
+		 FROM TreeHead WHERE TreeId=? AND TreeHeadTimestamp=?`
+const selectCosignaturesSql string = `SELECT WitnessId,Signature FROM Cosignature
+		 WHERE TreeId=? AND TreeHeadTimestamp=? ORDER BY WitnessId`
+const selectSequencerCheckpointSql string = "SELECT TreeRevision,CompactTree FROM SequencerCheckpoint WHERE TreeId=?"
+
+// There's one checkpoint for each log, a new one replaces the old
+const insertSequencerCheckpointSql string = `INSERT INTO SequencerCheckpoint(TreeId,TreeRevision,CompactTree)
+		 VALUES(?,?,?) ON DUPLICATE KEY UPDATE TreeRevision=VALUES(TreeRevision),CompactTree=VALUES(CompactTree)`
+
+// A witness only has one cosignature for each root, a new one replaces the old
+const insertCosignatureSql string = `INSERT INTO Cosignature(TreeId,TreeHeadTimestamp,WitnessId,Signature)
+		 VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE Signature=VALUES(Signature)`
 
 // These statements need to be expanded to provide the correct number of parameter placeholders
 // for a particular case
-const deleteUnsequencedSql string = "DELETE FROM Unsequenced WHERE LeafHash IN (<placeholder>) AND TreeId = ?"
-const selectLeavesByIndexSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumber,s.SignedEntryTimestamp
+// Queued entries are deleted by message id as well as leaf hash so that only the dequeued
+// copies of a duplicate leaf are removed
+const deleteUnsequencedSql string = "DELETE FROM Unsequenced WHERE (LeafHash, MessageId) IN (<placeholder>) AND TreeId = ?"
+const claimUnsequencedSql string = `UPDATE Unsequenced SET ClaimOwner=?,ClaimExpiry=?
+		 WHERE (LeafHash, MessageId) IN (<placeholder>) AND TreeId = ?`
+
+// Leaves are written with multi-row INSERTs, see insertRows
+const insertUnsequencedLeafSql string = `INSERT INTO LeafData(TreeId,LeafHash,TheData,LeafIdentityHash) ` + placeholderSql +
+	` ON DUPLICATE KEY UPDATE LeafHash=LeafHash`
+const insertUnsequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload,Priority,NotBefore) ` + placeholderSql
+const insertSequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload,SequenceNumber) ` + placeholderSql
+const insertSequencedLeafSql string = `INSERT INTO SequencedLeafData(TreeId,LeafHash,SequenceNumber,SignedEntryTimestamp) ` + placeholderSql
+const selectLeavesByIndexSql string = `SELECT l.LeafHash,l.TheData,l.LeafIdentityHash,s.SequenceNumber,s.SignedEntryTimestamp
 		     FROM LeafData l,SequencedLeafData s
 		     WHERE l.LeafHash = s.LeafHash
 		     AND s.SequenceNumber IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
-const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumber,s.SignedEntryTimestamp
+const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,l.LeafIdentityHash,s.SequenceNumber,s.SignedEntryTimestamp
 		     FROM LeafData l,SequencedLeafData s
 		     WHERE l.LeafHash = s.LeafHash
 		     AND l.LeafHash IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+const selectLeavesByIdentityHashSql string = `SELECT l.LeafHash,l.TheData,l.LeafIdentityHash,s.SequenceNumber,s.SignedEntryTimestamp
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND l.LeafIdentityHash IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+const selectLeavesByRangeSql string = `SELECT l.LeafHash,l.TheData,l.LeafIdentityHash,s.SequenceNumber,s.SignedEntryTimestamp
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.SequenceNumber >= ? AND s.SequenceNumber < ? AND l.TreeId = ? AND s.TreeId = l.TreeId
+		     ORDER BY s.SequenceNumber`
 
 // Same as above except with leaves ordered by sequence so we only incur this cost when necessary
 const selectLeavesByHashOrderedBySequenceSQL string = selectLeavesByHashSql + " ORDER BY s.SequenceNumber"
+const selectLeavesByIdentityHashOrderedBySequenceSQL string = selectLeavesByIdentityHashSql + " ORDER BY s.SequenceNumber"
 
 type mySQLLogStorage struct {
 	mySQLTreeStorage
 
 	logID           trillian.LogID
 	allowDuplicates bool
+	treeType        trillian.TreeType
 	readOnly        bool
 }
 
+// NewLogStorage creates a LogStorage for the log in the MySQL database identified by dbURL,
+// which it has its own connections to.
 func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error) {
-	// TODO(al): pass this through/configure from DB
-	th := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
-	ts, err := newTreeStorage(id.TreeID, dbURL, th.Size(), cache.PopulateLogSubtreeNodes(th))
+	db, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogStorage(id, db, dbLimits{}, nil)
+}
+
+func newLogStorage(id trillian.LogID, db *sql.DB, limits dbLimits, shards *subtreeShards) (storage.LogStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, db, limits, shards, cache.PopulateLogSubtreeNodes)
 	if err != nil {
 		glog.Warningf("Couldn't create a new treeStorage: %s", err)
 		return nil, err
@@ -71,13 +158,21 @@ func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error)
 
 	// TODO: This should not default but it would currently complicate testing and can be
 	// implemented later when the create tree API has been defined.
-	if err := s.db.QueryRow(getTreePropertiesSql, id.TreeID).Scan(&s.allowDuplicates); err == sql.ErrNoRows {
+	var treeType string
+
+	if err := s.db.QueryRow(getTreePropertiesSql, id.TreeID).Scan(&s.allowDuplicates, &treeType); err == sql.ErrNoRows {
 		s.allowDuplicates = false
 	} else if err != nil {
 		glog.Warningf("Failed to get trees row for id %v: %s", id, err)
 		return nil, err
 	}
 
+	s.treeType = trillian.TreeType_LOG
+
+	if v, ok := trillian.TreeType_value[treeType]; ok {
+		s.treeType = trillian.TreeType(v)
+	}
+
 	err = s.db.QueryRow(getTreeParametersSql, id.TreeID).Scan(&s.readOnly)
 
 	// TODO(Martin2112): It's probably not ok for the log to have no parameters set. Enforce this when
@@ -89,6 +184,11 @@ func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error)
 	return &s, nil
 }
 
+// TreeType returns the type the log was created with.
+func (m *mySQLLogStorage) TreeType() trillian.TreeType {
+	return m.treeType
+}
+
 func (m *mySQLLogStorage) getLeavesByIndexStmt(num int) (*sql.Stmt, error) {
 	return m.getStmt(selectLeavesByIndexSql, num, "?", "?")
 }
@@ -101,54 +201,66 @@ func (m *mySQLLogStorage) getLeavesByHashStmt(num int, orderBySequence bool) (*s
 	return m.getStmt(selectLeavesByHashSql, num, "?", "?")
 }
 
+func (m *mySQLLogStorage) getLeavesByIdentityHashStmt(num int, orderBySequence bool) (*sql.Stmt, error) {
+	if orderBySequence {
+		return m.getStmt(selectLeavesByIdentityHashOrderedBySequenceSQL, num, "?", "?")
+	}
+
+	return m.getStmt(selectLeavesByIdentityHashSql, num, "?", "?")
+}
+
 func (m *mySQLLogStorage) getDeleteUnsequencedStmt(num int) (*sql.Stmt, error) {
-	return m.getStmt(deleteUnsequencedSql, num, "?", "?")
+	return m.getStmt(deleteUnsequencedSql, num, "(?,?)", "(?,?)")
 }
 
-func (m *mySQLLogStorage) LatestSVignedLogRoot() (trillian.SignedLogRoot, error) {
-	t, err := m.Begin()
+func (m *mySQLLogStorage) getClaimUnsequencedStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(claimUnsequencedSql, num, "(?,?)", "(?,?)")
+}
+
+func (m *mySQLLogStorage) LatestSVignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	t, err := m.Begin(ctx)
 
 	if err != nil {
 		return trillian.SignedLogRoot{}, err
 	}
 
 	defer t.Commit()
-	return t.LatestSignedLogRoot()
+	return t.LatestSignedLogRoot(ctx)
 }
 
-func (m *mySQLLogStorage) GetSequencedLeafCount() (int64, error) {
-	t, err := m.Begin()
+func (m *mySQLLogStorage) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	t, err := m.Begin(ctx)
 
 	if err != nil {
 		return 0, err
 	}
 
 	defer t.Commit()
-	return t.GetSequencedLeafCount()
+	return t.GetSequencedLeafCount(ctx)
 }
 
-func (m *mySQLLogStorage) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
-	t, err := m.Begin()
+func (m *mySQLLogStorage) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
+	t, err := m.Begin(ctx)
 
 	if err != nil {
 		return []trillian.LogLeaf{}, err
 	}
 	defer t.Commit()
-	return t.GetLeavesByIndex(leaves)
+	return t.GetLeavesByIndex(ctx, leaves)
 }
 
-func (m *mySQLLogStorage) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
-	t, err := m.Begin()
+func (m *mySQLLogStorage) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	t, err := m.Begin(ctx)
 
 	if err != nil {
 		return []trillian.LogLeaf{}, err
 	}
 	defer t.Commit()
-	return t.GetLeavesByHash(leafHashes, orderBySequence)
+	return t.GetLeavesByHash(ctx, leafHashes, orderBySequence)
 }
 
-func (m *mySQLLogStorage) beginInternal() (storage.LogTX, error) {
-	ttx, err := m.beginTreeTx()
+func (m *mySQLLogStorage) beginInternal(ctx context.Context) (storage.LogTX, error) {
+	ttx, err := m.beginTreeTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -157,7 +269,7 @@ func (m *mySQLLogStorage) beginInternal() (storage.LogTX, error) {
 		ls:     m,
 	}
 
-	root, err := ret.LatestSignedLogRoot()
+	root, err := ret.LatestSignedLogRoot(ctx)
 	if err != nil {
 		ttx.Rollback()
 		return nil, err
@@ -168,126 +280,381 @@ func (m *mySQLLogStorage) beginInternal() (storage.LogTX, error) {
 	return ret, nil
 }
 
-func (m *mySQLLogStorage) Begin() (storage.LogTX, error) {
+func (m *mySQLLogStorage) Begin(ctx context.Context) (storage.LogTX, error) {
 	// Reject attempts to start a writable transaction in read only mode. Anything that
 	// doesn't write is a part of Snapshot so is still available via that API.
 	if m.readOnly {
 		return nil, storage.ErrReadOnly
 	}
 
-	return m.beginInternal()
+	tx, err := m.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.(*logTX).checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
 }
 
-func (m *mySQLLogStorage) Snapshot() (storage.ReadOnlyLogTX, error) {
-	tx, err := m.beginInternal()
+func (m *mySQLLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	tx, err := m.beginInternal(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	if err := tx.(*logTX).checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	return tx.(storage.ReadOnlyLogTX), err
 }
 
+// SnapshotForTree starts a read-only transaction pinned to the revision of the first tree
+// with a root that's at least treeSize. It doesn't need the latest root, as nothing is
+// written through it.
+func (m *mySQLLogStorage) SnapshotForTree(ctx context.Context, treeSize int64) (storage.ReadOnlyLogTreeTX, error) {
+	ttx, err := m.beginTreeTx(ctx, snapshotTxOptions)
+	if err != nil {
+		return nil, err
+	}
+	tx := &logTX{
+		treeTX: ttx,
+		ls:     m,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rev, size, err := tx.getTreeRevisionCoveringSize(ctx, treeSize)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to find revision for tree size %d: %s", treeSize, err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = rev
+	tx.treeSize = size
+
+	return tx, nil
+}
+
 type logTX struct {
 	treeTX
 	ls *mySQLLogStorage
+
+	// treeSize is the size of the tree a snapshot is pinned to
+	treeSize int64
 }
 
 func (t *logTX) WriteRevision() int64 {
 	return t.treeTX.writeRevision
 }
 
-func (t *logTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
-	stx, err := t.tx.Prepare(selectQueuedLeavesSql)
+func (t *logTX) TreeSize() int64 {
+	return t.treeSize
+}
+
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	leaves, err := t.dequeueLeaves(ctx, limit, nowNanos)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.recordQueuedLeaves(ctx)
+
+	return leaves, nil
+}
+
+func (t *logTX) DequeueOverdueLeaves(ctx context.Context, limit int, queuedBeforeNanos, nowNanos int64) ([]trillian.LogLeaf, error) {
+	// The leaves of a pre-ordered log are integrated in order, so none are overdue
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, nil
+	}
+
+	// Queue times are kept to the second, so one is overdue if it was queued in a second
+	// that started before queuedBeforeNanos
+	queuedBefore := (queuedBeforeNanos + int64(time.Second) - 1) / int64(time.Second)
+	leaves, messageIDs, err := t.selectQueuedLeaves(ctx, selectOverdueLeavesSql, "", nowNanos, queuedBefore, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(leaves) > 0 {
+		if err := t.removeSequencedLeaves(ctx, leaves, messageIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	t.recordQueuedLeaves(ctx)
+
+	return leaves, nil
+}
+
+func (t *logTX) OldestQueuedLeafNanos(ctx context.Context, nowNanos int64) (int64, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return 0, nil
+	}
+
+	// Entries dequeued by the transaction have already been deleted
+	var oldest sql.NullInt64
+
+	if err := t.tx.QueryRow(ctx, selectOldestQueuedLeafSql, t.ls.logID.TreeID, nowNanos).Scan(&oldest); err != nil {
+		logging.Warningf(ctx, "Failed to find oldest queued leaf: %s", err)
+		return 0, err
+	}
+
+	return oldest.Int64 * int64(time.Second), nil
+}
+
+// recordQueuedLeaves updates the queue depth metric with the number of leaves left queued
+// once the dequeued leaves are removed. Failing to count them isn't an error for the caller.
+func (t *logTX) recordQueuedLeaves(ctx context.Context) {
+	var queued int64
+
+	if err := t.tx.QueryRow(ctx, selectQueuedLeafCountSql, t.ls.logID.TreeID).Scan(&queued); err != nil {
+		logging.Warningf(ctx, "Failed to count queued leaves: %s", err)
+		return
+	}
+
+	storage.QueuedLeaves.Set(float64(queued), "mysql", strconv.FormatInt(t.ls.logID.TreeID, 10))
+}
+
+func (t *logTX) dequeueLeaves(ctx context.Context, limit int, nowNanos int64) ([]trillian.LogLeaf, error) {
+	leaves, messageIDs, err := t.selectQueuedLeaves(ctx, selectQueuedLeavesSql, selectQueuedSequencedLeavesSql, nowNanos, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The convention is that if leaf processing succeeds (by committing this tx)
+	// then the unsequenced entries for them are removed
+	if len(leaves) > 0 {
+		if err := t.removeSequencedLeaves(ctx, leaves, messageIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return leaves, nil
+}
+
+func (t *logTX) ClaimLeaves(ctx context.Context, claim storage.LeafClaim, nowNanos int64, limit int) ([]trillian.LogLeaf, error) {
+	leaves, messageIDs, err := t.selectQueuedLeaves(ctx, selectClaimableLeavesSql, selectClaimableSequencedLeavesSql, claim.Owner, nowNanos, nowNanos, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(leaves) == 0 {
+		return leaves, nil
+	}
 
+	tmpl, err := t.ls.getClaimUnsequencedStmt(len(leaves))
 	if err != nil {
-		glog.Warningf("Failed to prepare dequeue select: %s", err)
+		logging.Warningf(ctx, "Failed to get claim statement for queued work: %s", err)
+		return nil, err
+	}
+
+	args := []interface{}{claim.Owner, claim.ExpiryNanos}
+	for i, leaf := range leaves {
+		args = append(args, []byte(leaf.LeafHash), messageIDs[i])
+	}
+	args = append(args, t.ls.logID.TreeID)
+
+	// The entries were locked as they were selected so they're all still there, but the row
+	// count isn't checked as MySQL doesn't count those the owner already held to the expiry
+	if _, err := t.tx.Stmt(ctx, tmpl).Exec(ctx, args...); err != nil {
+		logging.Warningf(ctx, "Failed to claim queued work: %s", err)
 		return nil, err
 	}
 
-	leaves := make([]trillian.LogLeaf, 0, limit)
-	rows, err := stx.Query(t.ls.logID.TreeID, limit)
+	return leaves, nil
+}
+
+func (t *logTX) DequeueClaimedLeaves(ctx context.Context, owner string, limit int) ([]trillian.LogLeaf, error) {
+	// The entries are locked as they're selected, so the claims can't be taken before the
+	// transaction ends
+	leaves, messageIDs, err := t.selectQueuedLeaves(ctx, selectClaimedLeavesSql, selectClaimedSequencedLeavesSql, owner, limit)
 
 	if err != nil {
-		glog.Warningf("Failed to select rows for work: %s", err)
 		return nil, err
 	}
 
+	if len(leaves) > 0 {
+		if err := t.removeSequencedLeaves(ctx, leaves, messageIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	t.recordQueuedLeaves(ctx)
+
+	return leaves, nil
+}
+
+// selectQueuedLeaves returns the queued leaves selected by query, or by sequencedQuery for a
+// pre-ordered log, and their message IDs. Both are given the tree ID, then for sequencedQuery
+// the tree size, then args. The leaves of a pre-ordered log follow on from the current tree
+// size, stopping at the first missing sequence number.
+func (t *logTX) selectQueuedLeaves(ctx context.Context, query, sequencedQuery string, args ...interface{}) ([]trillian.LogLeaf, [][]byte, error) {
+	preordered := t.ls.treeType == trillian.TreeType_PREORDERED_LOG
+	var treeSize int64
+
+	if preordered {
+		root, err := t.LatestSignedLogRoot(ctx)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		treeSize = root.TreeSize
+		query = sequencedQuery
+		args = append([]interface{}{t.ls.logID.TreeID, treeSize}, args...)
+	} else {
+		args = append([]interface{}{t.ls.logID.TreeID}, args...)
+	}
+
+	stx, err := t.tx.Prepare(ctx, query)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to prepare dequeue select: %s", err)
+		return nil, nil, err
+	}
+
+	rows, err := stx.Query(ctx, args...)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to select rows for work: %s", err)
+		return nil, nil, err
+	}
+
 	defer rows.Close()
 
+	leaves := make([]trillian.LogLeaf, 0)
+	messageIDs := make([][]byte, 0)
+
 	for rows.Next() {
 		var leafHash []byte
+		var messageID []byte
 		var payload []byte
 		var signedEntryTimestampBytes []byte
+		var sequenceNumber, queueTimestamp int64
+
+		// Queries for normal logs select when the entry was queued, in seconds, in place of
+		// the sequence number.
+		dest := []interface{}{&leafHash, &messageID, &payload, &signedEntryTimestampBytes}
+		if preordered {
+			dest = append(dest, &sequenceNumber)
+		} else {
+			dest = append(dest, &queueTimestamp)
+		}
 
-		err := rows.Scan(&leafHash, &payload, &signedEntryTimestampBytes)
+		if err := rows.Scan(dest...); err != nil {
+			logging.Warningf(ctx, "Error scanning work rows: %s", err)
+			return nil, nil, err
+		}
 
-		if err != nil {
-			glog.Warningf("Error scanning work rows: %s", err)
-			return nil, err
+		// The tree can only grow up to a gap, the leaves after it wait until it's filled
+		if preordered && sequenceNumber != treeSize+int64(len(leaves)) {
+			break
 		}
 
 		if len(leafHash) != t.ts.hashSizeBytes {
-			return nil, errors.New("Dequeued a leaf with incorrect hash size")
+			return nil, nil, errors.New("Dequeued a leaf with incorrect hash size")
 		}
 
 		signedEntryTimestamp, err := decodeSignedTimestamp(signedEntryTimestampBytes)
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		leaf := trillian.LogLeaf{
 			Leaf: trillian.Leaf{
 				LeafHash:  leafHash,
 				LeafValue: payload,
-				ExtraData: nil,
 			},
 			SignedEntryTimestamp: signedEntryTimestamp,
-			SequenceNumber:       0,
+			SequenceNumber:       sequenceNumber,
+			QueueTimestampNanos:  queueTimestamp * int64(time.Second),
 		}
 		leaves = append(leaves, leaf)
+		messageIDs = append(messageIDs, messageID)
 	}
 
 	if rows.Err() != nil {
-		return nil, rows.Err()
+		return nil, nil, rows.Err()
 	}
 
-	// The convention is that if leaf processing succeeds (by committing this tx)
-	// then the unsequenced entries for them are removed
-	if len(leaves) > 0 {
-		err = t.removeSequencedLeaves(leaves)
-	}
-
-	if err != nil {
-		return nil, err
-	}
+	// The rows must be closed before the deletes can run on the same connection
+	rows.Close()
 
-	return leaves, nil
+	return leaves, messageIDs, nil
 }
 
-func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType == trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrPreordered
+	}
+
 	// Don't accept batches if any of the leaves are invalid.
 	for _, leaf := range leaves {
 		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
-			return fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
+			return nil, fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
 		}
 
 		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
-			return errors.New("Queued leaf cannot have an empty signature")
+			return nil, errors.New("Queued leaf cannot have an empty signature")
 		}
 	}
 
-	for _, leaf := range leaves {
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	leafRows := make([][]interface{}, 0, len(leaves))
+	entryRows := make([][]interface{}, 0, len(leaves))
+	// The leaves of this batch that will be inserted, by hash. Nothing is inserted until the
+	// whole batch has been looked at so repeats within it have to be found here.
+	batchLeaves := make(map[string]*trillian.LogLeaf)
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		// If the log doesn't allow duplicates then resubmitting a leaf returns the copy that's
+		// already there.
+		if !t.ls.allowDuplicates {
+			if first, ok := batchLeaves[string(leaf.LeafHash)]; ok {
+				existingLeaves[i] = &trillian.LogLeaf{
+					Leaf:                 trillian.Leaf{LeafHash: first.LeafHash, LeafValue: first.LeafValue},
+					SignedEntryTimestamp: first.SignedEntryTimestamp,
+					SequenceNumber:       -1,
+				}
+				continue
+			}
+
+			existing, err := t.getExistingLeaf(ctx, leaf.LeafHash)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				existingLeaves[i] = existing
+				continue
+			}
+
+			batchLeaves[string(leaf.LeafHash)] = leaf
+		}
+
 		// Create the unsequenced leaf data entry. We don't use INSERT IGNORE because this
 		// can suppress errors unrelated to key collisions. We don't use REPLACE because
 		// if there's ever a hash collision it will do the wrong thing and it also
 		// causes a DELETE / INSERT, which is undesirable.
-		_, err := t.tx.Exec(insertUnsequencedLeafSql, t.ls.logID.TreeID,
-			[]byte(leaf.LeafHash), leaf.LeafValue)
-
-		if err != nil {
-			glog.Warningf("Error inserting into LeafData: %s", err)
-			return err
-		}
+		leafRows = append(leafRows, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, identityHashValue(leaf.LeafIdentityHash)})
 
 		// Create the work queue entry
 		// Message ids only need to guard against duplicates for the time that entries are
@@ -296,16 +663,17 @@ func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
 		hasher := sha256.New()
 
 		// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
-		// the fixed id will collide if dups submitted when not allowed so the insert won't succeed
-		// and everything will get rolled back
+		// The fixed id will collide if the same leaf is queued concurrently by another
+		// transaction that we couldn't see above, so the insert won't succeed and everything
+		// will get rolled back
 		messageIdBytes := make([]byte, 8)
 
 		if t.ls.allowDuplicates {
 			_, err := rand.Read(messageIdBytes)
 
 			if err != nil {
-				glog.Warningf("Failed to get a random message id: %s", err)
-				return err
+				logging.Warningf(ctx, "Failed to get a random message id: %s", err)
+				return nil, err
 			}
 		}
 
@@ -317,64 +685,272 @@ func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
 		signedTimestampBytes, err := EncodeSignedTimestamp(leaf.SignedEntryTimestamp)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// TODO: We shouldn't really need both payload and signed timestamp fields in unsequenced
 		// I think payload is currently unused
-		_, err = t.tx.Exec(insertUnsequencedEntrySql,
-			t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, signedTimestampBytes)
+		entryRows = append(entryRows, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, signedTimestampBytes, leaf.Priority, leaf.NotBeforeNanos})
+	}
+
+	if err := t.insertLeaves(ctx, leafRows, insertUnsequencedEntrySql, "(?,?,?,?,?,?,?)", entryRows); err != nil {
+		return nil, err
+	}
+
+	return existingLeaves, nil
+}
+
+// insertLeaves writes the LeafData rows for a batch of leaves and then their entries in the
+// Unsequenced queue, using entrySql.
+func (t *logTX) insertLeaves(ctx context.Context, leafRows [][]interface{}, entrySql, entryRowSql string, entryRows [][]interface{}) error {
+	if len(leafRows) == 0 {
+		return nil
+	}
+
+	if err := t.insertRows(ctx, insertUnsequencedLeafSql, "(?,?,?,?)", leafRows); err != nil {
+		logging.Warningf(ctx, "Error inserting into LeafData: %s", err)
+		return err
+	}
+
+	if err := t.insertRows(ctx, entrySql, entryRowSql, entryRows); err != nil {
+		logging.Warningf(ctx, "Error inserting into Unsequenced: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// identityHashValue is the value stored for a leaf identity hash, leaves without one have
+// NULL so they're never found by it
+func identityHashValue(identityHash trillian.Hash) interface{} {
+	if len(identityHash) == 0 {
+		return nil
+	}
+
+	return []byte(identityHash)
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) ([]*trillian.LogLeaf, error) {
+	if t.ls.treeType != trillian.TreeType_PREORDERED_LOG {
+		return nil, storage.ErrNotPreordered
+	}
+
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return nil, fmt.Errorf("Sequenced leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SequenceNumber < 0 {
+			return nil, fmt.Errorf("Sequenced leaf must have a sequence number >= 0, got %d", leaf.SequenceNumber)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return nil, errors.New("Sequenced leaf cannot have an empty signature")
+		}
+	}
+
+	root, err := t.LatestSignedLogRoot(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existingLeaves := make([]*trillian.LogLeaf, len(leaves))
+	leafRows := make([][]interface{}, 0, len(leaves))
+	entryRows := make([][]interface{}, 0, len(leaves))
+	// The leaves of this batch that will be inserted, by sequence number, as the positions
+	// they fill aren't in the database until the whole batch is inserted
+	batchLeaves := make(map[int64]*trillian.LogLeaf)
+
+	for i := range leaves {
+		leaf := &leaves[i]
+
+		// A position can only be filled once, whether or not it has been integrated yet
+		if first, ok := batchLeaves[leaf.SequenceNumber]; ok {
+			existingLeaves[i] = &trillian.LogLeaf{
+				Leaf:                 trillian.Leaf{LeafHash: first.LeafHash, LeafValue: first.LeafValue},
+				SignedEntryTimestamp: first.SignedEntryTimestamp,
+				SequenceNumber:       first.SequenceNumber,
+			}
+			continue
+		}
+
+		existing, err := t.getLeafAtSequenceNumber(ctx, leaf.SequenceNumber, root.TreeSize)
 
 		if err != nil {
-			glog.Warningf("Error inserting into Unsequenced: %s", err)
-			return err
+			return nil, err
+		}
+
+		if existing != nil {
+			existingLeaves[i] = existing
+			continue
+		}
+
+		batchLeaves[leaf.SequenceNumber] = leaf
+		leafRows = append(leafRows, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.LeafValue, identityHashValue(leaf.LeafIdentityHash)})
+
+		// The message id only needs to be unique for each position, the unique index on
+		// sequence number catches concurrent additions of the same one
+		sequenceBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(sequenceBytes, uint64(leaf.SequenceNumber))
+
+		hasher := sha256.New()
+		hasher.Write(sequenceBytes)
+		hasher.Write(t.ls.logID.LogID)
+		hasher.Write(leaf.LeafHash)
+		messageId := hasher.Sum(nil)
+
+		signedTimestampBytes, err := EncodeSignedTimestamp(leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return nil, err
 		}
+
+		entryRows = append(entryRows, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, signedTimestampBytes, leaf.SequenceNumber})
 	}
 
-	return nil
+	if err := t.insertLeaves(ctx, leafRows, insertSequencedEntrySql, "(?,?,?,?,?,?)", entryRows); err != nil {
+		return nil, err
+	}
+
+	return existingLeaves, nil
+}
+
+// getLeafAtSequenceNumber returns the leaf that has been added to a pre-ordered log at seq, or
+// nil if there isn't one. Leaves below treeSize have already been integrated.
+func (t *logTX) getLeafAtSequenceNumber(ctx context.Context, seq, treeSize int64) (*trillian.LogLeaf, error) {
+	if seq < treeSize {
+		sequenced, err := t.GetLeavesByIndex(ctx, []int64{seq})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sequenced) != 1 {
+			return nil, fmt.Errorf("expected one leaf at sequence number %d below tree size %d, got %d", seq, treeSize, len(sequenced))
+		}
+
+		return &sequenced[0], nil
+	}
+
+	var leafHash []byte
+	var leafValue []byte
+	var signedTimestampBytes []byte
+
+	err := t.tx.QueryRow(ctx, selectQueuedLeafBySequenceSql, t.ls.logID.TreeID, seq).Scan(&leafHash, &leafValue, &signedTimestampBytes)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to look up queued leaf: %s", err)
+		return nil, err
+	}
+
+	signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.LogLeaf{
+		Leaf: trillian.Leaf{
+			LeafHash:  leafHash,
+			LeafValue: leafValue,
+		},
+		SignedEntryTimestamp: signedEntryTimestamp,
+		SequenceNumber:       seq,
+	}, nil
+}
+
+// getExistingLeaf returns the leaf in the log with leafHash, or nil if there isn't one. If the
+// leaf has been sequenced more than once the earliest copy is returned.
+func (t *logTX) getExistingLeaf(ctx context.Context, leafHash trillian.Hash) (*trillian.LogLeaf, error) {
+	sequenced, err := t.GetLeavesByHash(ctx, []trillian.Hash{leafHash}, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sequenced) > 0 {
+		return &sequenced[0], nil
+	}
+
+	var leafValue []byte
+	var signedTimestampBytes []byte
+
+	err = t.tx.QueryRow(ctx, selectQueuedLeafByHashSql, t.ls.logID.TreeID, []byte(leafHash)).Scan(&leafValue, &signedTimestampBytes)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to look up queued leaf: %s", err)
+		return nil, err
+	}
+
+	signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.LogLeaf{
+		Leaf: trillian.Leaf{
+			LeafHash:  leafHash,
+			LeafValue: leafValue,
+		},
+		SignedEntryTimestamp: signedEntryTimestamp,
+		SequenceNumber:       -1,
+	}, nil
 }
 
-func (t *logTX) GetSequencedLeafCount() (int64, error) {
+func (t *logTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
 	var sequencedLeafCount int64
-	err := t.tx.QueryRow(selectSequencedLeafCountSql).Scan(&sequencedLeafCount)
+	err := t.tx.QueryRow(ctx, selectSequencedLeafCountSql).Scan(&sequencedLeafCount)
 
 	if err != nil {
-		glog.Warningf("Error getting sequenced leaf count: %s", err)
+		logging.Warningf(ctx, "Error getting sequenced leaf count: %s", err)
 	}
 
 	return sequencedLeafCount, err
 }
 
-func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]trillian.LogLeaf, error) {
 	tmpl, err := t.ls.getLeavesByIndexStmt(len(leaves))
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.Stmt(ctx, tmpl)
 	args := make([]interface{}, 0)
 	for _, nodeID := range leaves {
 		args = append(args, interface{}(int64(nodeID)))
 	}
 	args = append(args, interface{}(t.ls.logID.TreeID))
-	rows, err := stx.Query(args...)
+	rows, err := stx.Query(ctx, args...)
 	if err != nil {
-		glog.Warningf("Failed to get leaves by idx: %s", err)
+		logging.Warningf(ctx, "Failed to get leaves by idx: %s", err)
 		return nil, err
 	}
 
 	ret := make([]trillian.LogLeaf, len(leaves))
 	num := 0
 
-	var signedTimestampBytes []byte
+	var signedTimestampBytes, identityHash []byte
 
 	defer rows.Close()
 	for rows.Next() {
-		if err := rows.Scan(&ret[num].LeafHash, &ret[num].LeafValue, &ret[num].SequenceNumber,
+		if err := rows.Scan(&ret[num].LeafHash, &ret[num].LeafValue, &identityHash, &ret[num].SequenceNumber,
 			&signedTimestampBytes); err != nil {
-			glog.Warningf("Failed to scan merkle leaves: %s", err)
+			logging.Warningf(ctx, "Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
 
+		ret[num].LeafIdentityHash = identityHash
+
 		signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
 
 		if err != nil {
@@ -396,38 +972,56 @@ func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
 	return ret, nil
 }
 
-func (t *logTX) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
 	tmpl, err := t.ls.getLeavesByHashStmt(len(leafHashes), orderBySequence)
 
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.Stmt(tmpl)
+
+	return t.getLeavesByHashes(ctx, tmpl, leafHashes, "hash")
+}
+
+func (t *logTX) GetLeavesByIdentityHash(ctx context.Context, identityHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByIdentityHashStmt(len(identityHashes), orderBySequence)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getLeavesByHashes(ctx, tmpl, identityHashes, "identity hash")
+}
+
+// getLeavesByHashes returns the sequenced leaves selected by tmpl, which looks them up by one
+// of their hashes, what
+func (t *logTX) getLeavesByHashes(ctx context.Context, tmpl *sql.Stmt, hashes []trillian.Hash, what string) ([]trillian.LogLeaf, error) {
+	stx := t.tx.Stmt(ctx, tmpl)
 	args := make([]interface{}, 0)
-	for _, hash := range leafHashes {
+	for _, hash := range hashes {
 		args = append(args, interface{}([]byte(hash)))
 	}
 	args = append(args, interface{}(t.ls.logID.TreeID))
-	rows, err := stx.Query(args...)
+	rows, err := stx.Query(ctx, args...)
 	if err != nil {
-		glog.Warningf("Failed to get leaves by hash: %s", err)
+		logging.Warningf(ctx, "Failed to get leaves by %s: %s", what, err)
 		return nil, err
 	}
 
 	// The tree could include duplicates so we don't know how many results will be returned
 	ret := make([]trillian.LogLeaf, 0)
 
-	var signedTimestampBytes []byte
+	var signedTimestampBytes, identityHash []byte
 
 	defer rows.Close()
 	for rows.Next() {
 		leaf := trillian.LogLeaf{}
 
-		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &leaf.SequenceNumber, &signedTimestampBytes); err != nil {
-			glog.Warningf("Failed to scan merkle leaves: %s", err)
+		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &identityHash, &leaf.SequenceNumber, &signedTimestampBytes); err != nil {
+			logging.Warningf(ctx, "Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
 
+		leaf.LeafIdentityHash = identityHash
 		signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
 
 		if err != nil {
@@ -446,24 +1040,104 @@ func (t *logTX) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool
 	return ret, nil
 }
 
-func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
-	var timestamp, treeSize, treeRevision int64
-	var rootHash, rootSignatureBytes []byte
-	var rootSignature trillian.DigitallySigned
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]trillian.LogLeaf, error) {
+	if start < 0 || count <= 0 {
+		return nil, fmt.Errorf("invalid leaf range start=%d count=%d", start, count)
+	}
 
-	err := t.tx.QueryRow(
-		selectLatestSignedLogRootSql, t.ls.logID.TreeID).Scan(
-		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes)
+	rows, err := t.tx.Query(ctx, selectLeavesByRangeSql, start, start+count, t.ls.logID.TreeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to get leaves by range: %s", err)
+		return nil, err
+	}
+
+	ret := make([]trillian.LogLeaf, 0, count)
+
+	var signedTimestampBytes, identityHash []byte
+
+	defer rows.Close()
+	for rows.Next() {
+		leaf := trillian.LogLeaf{}
+
+		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &identityHash, &leaf.SequenceNumber, &signedTimestampBytes); err != nil {
+			logging.Warningf(ctx, "Failed to scan merkle leaves: %s", err)
+			return nil, err
+		}
+
+		leaf.LeafIdentityHash = identityHash
+
+		// Sequence numbers are allocated without gaps so anything else means we've lost data
+		if got, want := leaf.SequenceNumber, start+int64(len(ret)); got != want {
+			return nil, fmt.Errorf("expected leaf with sequence number %d, but got %d", want, got)
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf.SignedEntryTimestamp = signedEntryTimestamp
+
+		if got, want := len(leaf.LeafHash), t.ls.hashSizeBytes; got != want {
+			return nil, fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.Warningf(ctx, "Failed to read leaves by range: %s", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	root, err := t.readSignedLogRoot(ctx, selectLatestSignedLogRootSql, t.ls.logID.TreeID)
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
 		return trillian.SignedLogRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed root: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *logTX) GetSignedLogRoot(ctx context.Context, timestampNanos int64) (trillian.SignedLogRoot, error) {
+	root, err := t.readSignedLogRoot(ctx, selectSignedLogRootSql, t.ls.logID.TreeID, timestampNanos)
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, storage.ErrLogRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed root %d: %v", timestampNanos, err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedLogRoot reads the single signed root selected by query
+func (t *logTX) readSignedLogRoot(ctx context.Context, query string, args ...interface{}) (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, metadata []byte
+	var rootSignature trillian.DigitallySigned
+
+	err := t.tx.QueryRow(ctx, query, args...).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &metadata)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
 	}
 
 	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
 
 	if err != nil {
-		glog.Warningf("Failed to unmarshall root signature: %v", err)
+		logging.Warningf(ctx, "Failed to unmarshall root signature: %v", err)
 		return trillian.SignedLogRoot{}, err
 	}
 
@@ -474,30 +1148,129 @@ func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
 		Signature:      &rootSignature,
 		LogId:          t.ls.logID.LogID,
 		TreeSize:       treeSize,
+		Metadata:       metadata,
 	}, nil
 }
 
-func (t *logTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+func (t *logTX) GetCosignatures(ctx context.Context, rootTimestampNanos int64) ([]trillian.Cosignature, error) {
+	rows, err := t.tx.Query(ctx, selectCosignaturesSql, t.ls.logID.TreeID, rootTimestampNanos)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read cosignatures: %v", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	cosignatures := []trillian.Cosignature{}
+
+	for rows.Next() {
+		var witnessID string
+		var signatureBytes []byte
+
+		if err := rows.Scan(&witnessID, &signatureBytes); err != nil {
+			logging.Warningf(ctx, "Failed to scan cosignature: %v", err)
+			return nil, err
+		}
+
+		var signature trillian.DigitallySigned
+
+		if err := proto.Unmarshal(signatureBytes, &signature); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal cosignature from %s: %v", witnessID, err)
+			return nil, err
+		}
+
+		cosignatures = append(cosignatures, trillian.Cosignature{WitnessId: witnessID, Signature: &signature})
+	}
+
+	return cosignatures, rows.Err()
+}
+
+func (t *logTX) AddCosignature(ctx context.Context, rootTimestampNanos int64, cosignature trillian.Cosignature) error {
+	signatureBytes, err := proto.Marshal(cosignature.Signature)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal cosignature: %v %v", cosignature.Signature, err)
+		return err
+	}
+
+	_, err = t.tx.Exec(ctx, insertCosignatureSql, t.ls.logID.TreeID, rootTimestampNanos, cosignature.WitnessId, signatureBytes)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to store cosignature from %s: %v", cosignature.WitnessId, err)
+	}
+
+	return err
+}
+
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root trillian.SignedLogRoot) error {
 	signatureBytes, err := proto.Marshal(root.Signature)
 
 	if err != nil {
-		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
 		return err
 	}
 
-	res, err := t.tx.Exec(insertTreeHeadSql, t.ls.logID.TreeID, root.TimestampNanos, root.TreeSize,
-		root.RootHash, root.TreeRevision, signatureBytes)
+	res, err := t.tx.Exec(ctx, insertTreeHeadSql, t.ls.logID.TreeID, root.TimestampNanos, root.TreeSize,
+		root.RootHash, root.TreeRevision, signatureBytes, root.Metadata)
 
 	if err != nil {
-		glog.Warningf("Failed to store signed root: %s", err)
+		logging.Warningf(ctx, "Failed to store signed root: %s", err)
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
 	}
 
-	return checkResultOkAndRowCountIs(res, err, 1)
+	t.rootStored(root.TreeRevision)
+	return nil
 }
 
-func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
-	// TODO: In theory we can do this with CASE / WHEN in one SQL statement but it's more fiddly
-	// and can be implemented later if necessary
+func (t *logTX) GetSequencerCheckpoint(ctx context.Context) (*storage.SequencerCheckpoint, error) {
+	var revision int64
+	var compactTree []byte
+
+	err := t.tx.QueryRow(ctx, selectSequencerCheckpointSql, t.ls.logID.TreeID).Scan(&revision, &compactTree)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read sequencer checkpoint: %s", err)
+		return nil, err
+	}
+
+	var p storage.CompactRangeProto
+	if err := proto.Unmarshal(compactTree, &p); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal sequencer checkpoint: %s", err)
+		return nil, err
+	}
+
+	return &storage.SequencerCheckpoint{TreeRevision: revision, CompactTree: &p}, nil
+}
+
+func (t *logTX) StoreSequencerCheckpoint(ctx context.Context, checkpoint storage.SequencerCheckpoint) error {
+	if checkpoint.CompactTree == nil {
+		return errors.New("sequencer checkpoint has no compact tree state")
+	}
+
+	compactTree, err := proto.Marshal(checkpoint.CompactTree)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal sequencer checkpoint: %s", err)
+		return err
+	}
+
+	if _, err := t.tx.Exec(ctx, insertSequencerCheckpointSql, t.ls.logID.TreeID, checkpoint.TreeRevision, compactTree); err != nil {
+		logging.Warningf(ctx, "Failed to store sequencer checkpoint: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf) error {
+	rows := make([][]interface{}, 0, len(leaves))
+
 	for _, leaf := range leaves {
 		// This should fail on insert but catch it early
 		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
@@ -510,35 +1283,34 @@ func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
 			return err
 		}
 
-		_, err = t.tx.Exec(insertSequencedLeafSql, t.ls.logID.TreeID, []byte(leaf.LeafHash),
-			leaf.SequenceNumber, signedTimestampBytes)
+		rows = append(rows, []interface{}{t.ls.logID.TreeID, []byte(leaf.LeafHash), leaf.SequenceNumber, signedTimestampBytes})
+	}
 
-		if err != nil {
-			glog.Warningf("Failed to update sequenced leaves: %s", err)
-			return err
-		}
+	if err := t.insertRows(ctx, insertSequencedLeafSql, "(?,?,?,?)", rows); err != nil {
+		logging.Warningf(ctx, "Failed to update sequenced leaves: %s", err)
+		return err
 	}
 
 	return nil
 }
 
-func (t *logTX) removeSequencedLeaves(leaves []trillian.LogLeaf) error {
+func (t *logTX) removeSequencedLeaves(ctx context.Context, leaves []trillian.LogLeaf, messageIDs [][]byte) error {
 	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(leaves))
 	if err != nil {
-		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
+		logging.Warningf(ctx, "Failed to get delete statement for sequenced work: %s", err)
 		return err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.Stmt(ctx, tmpl)
 	args := make([]interface{}, 0)
-	for _, leaf := range leaves {
-		args = append(args, interface{}([]byte(leaf.LeafHash)))
+	for i, leaf := range leaves {
+		args = append(args, interface{}([]byte(leaf.LeafHash)), interface{}(messageIDs[i]))
 	}
 	args = append(args, interface{}(t.ls.logID.TreeID))
-	result, err := stx.Exec(args...)
+	result, err := stx.Exec(ctx, args...)
 
 	if err != nil {
 		// Error is handled by checkResultOkAndRowCountIs() below
-		glog.Warningf("Failed to delete sequenced work: %s", err)
+		logging.Warningf(ctx, "Failed to delete sequenced work: %s", err)
 	}
 
 	err = checkResultOkAndRowCountIs(result, err, int64(len(leaves)))
@@ -550,8 +1322,8 @@ func (t *logTX) removeSequencedLeaves(leaves []trillian.LogLeaf) error {
 	return nil
 }
 
-func (t *logTX) getActiveLogIDsInternal(sql string) ([]trillian.LogID, error) {
-	rows, err := t.tx.Query(sql)
+func (t *logTX) getActiveLogIDsInternal(ctx context.Context, sql string) ([]trillian.LogID, error) {
+	rows, err := t.tx.Query(ctx, sql)
 
 	if err != nil {
 		return nil, err
@@ -580,12 +1352,12 @@ func (t *logTX) getActiveLogIDsInternal(sql string) ([]trillian.LogID, error) {
 }
 
 // GetActiveLogIDs returns a list of the IDs of all configured logs
-func (t *logTX) GetActiveLogIDs() ([]trillian.LogID, error) {
-	return t.getActiveLogIDsInternal(selectActiveLogsSql)
+func (t *logTX) GetActiveLogIDs(ctx context.Context) ([]trillian.LogID, error) {
+	return t.getActiveLogIDsInternal(ctx, selectActiveLogsSql)
 }
 
 // GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
 // that have queued unsequenced leaves that need to be integrated
-func (t *logTX) GetActiveLogIDsWithPendingWork() ([]trillian.LogID, error) {
-	return t.getActiveLogIDsInternal(selectActiveLogsWithUnsequencedSql)
+func (t *logTX) GetActiveLogIDsWithPendingWork(ctx context.Context) ([]trillian.LogID, error) {
+	return t.getActiveLogIDsInternal(ctx, selectActiveLogsWithUnsequencedSql)
 }
@@ -3,12 +3,15 @@ package mysql
 import (
 	"database/sql"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
-	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/logging"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/sqltrace"
+	"golang.org/x/net/context"
 )
 
 const insertMapHeadSQL string = `INSERT INTO MapHead(TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData)
@@ -18,15 +21,62 @@ const selectLatestSignedMapRootSql string = `SELECT MapHeadTimestamp, RootHash,
 		 FROM MapHead WHERE TreeId=?
 		 ORDER BY MapHeadTimestamp DESC LIMIT 1`
 
+const selectSignedMapRootByRevisionSql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=? AND MapRevision=?`
+
 const insertMapLeafSQL string = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, TheData) VALUES (?, ?, ?, ?)`
 
-// Note that MapRevision is stored negated, hence the odd equality check below:
-const selectMapLeafSQL string = `SELECT KeyHash, MAX(MapRevision), TheData
+// Note that MapRevision is stored negated, hence the odd equality check below. The most
+// recent revision of each key at or before the requested one has the smallest stored value.
+const selectMapLeafSQL string = `SELECT l.KeyHash, l.MapRevision, l.TheData
+	 FROM MapLeaf l
+	 INNER JOIN (SELECT KeyHash, MIN(MapRevision) AS MapRevision
+							 FROM MapLeaf
+							 WHERE KeyHash IN (` + placeholderSql + `) AND
+										 TreeId = ? AND
+										 MapRevision >= ?
+							 GROUP BY KeyHash) AS x
+	 ON l.KeyHash = x.KeyHash AND l.MapRevision = x.MapRevision
+	 WHERE l.TreeId = ?`
+
+// Note that MapRevision is stored negated so the range is inverted and sorting in
+// descending order returns the oldest revision first. This uses the primary key on
+// (TreeId, KeyHash, MapRevision).
+const selectMapLeafHistorySQL string = `SELECT MapRevision, TheData
 	 FROM MapLeaf
-	 WHERE KeyHash IN (` + placeholderSql + `) AND
-	       TreeId = ? AND
-				 MapRevision >= ?
-	 GROUP BY KeyHash`
+	 WHERE TreeId = ? AND
+	       KeyHash = ? AND
+	       MapRevision >= ? AND
+	       MapRevision <= ?
+	 ORDER BY MapRevision DESC`
+
+// Keys set more than once keep their first row
+const insertMapKeySQL string = `INSERT INTO MapKey(TreeId, TheKey) VALUES (?, ?)
+	 ON DUPLICATE KEY UPDATE TreeId = TreeId`
+
+const selectMapKeysSQL string = `SELECT TheKey
+	 FROM MapKey
+	 WHERE TreeId = ? AND
+	       TheKey >= ?
+	 ORDER BY TheKey
+	 LIMIT ?`
+
+const selectMapKeysBeforeSQL string = `SELECT TheKey
+	 FROM MapKey
+	 WHERE TreeId = ? AND
+	       TheKey >= ? AND
+	       TheKey < ?
+	 ORDER BY TheKey
+	 LIMIT ?`
+
+const insertMapMutationSQL string = `INSERT INTO MapMutation(TreeId, MapRevision, TheData) VALUES (?, ?, ?)`
+
+const selectMapMutationsSQL string = `SELECT MapRevision, TheData
+	 FROM MapMutation
+	 WHERE TreeId = ? AND
+	       MapRevision >= ?
+	 ORDER BY MapRevision
+	 LIMIT ?`
 
 type mySQLMapStorage struct {
 	mySQLTreeStorage
@@ -38,10 +88,19 @@ func (m *mySQLMapStorage) MapID() trillian.MapID {
 	return m.mapID
 }
 
+// NewMapStorage creates a MapStorage for the map in the MySQL database identified by dbURL,
+// which it has its own connections to.
 func NewMapStorage(id trillian.MapID, dbURL string) (storage.MapStorage, error) {
-	// TODO(al): pass this through/configure from DB
-	th := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
-	ts, err := newTreeStorage(id.TreeID, dbURL, th.Size(), cache.PopulateMapSubtreeNodes(th))
+	db, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMapStorage(id, db, dbLimits{}, nil)
+}
+
+func newMapStorage(id trillian.MapID, db *sql.DB, limits dbLimits, shards *subtreeShards) (storage.MapStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, db, limits, shards, cache.PopulateMapSubtreeNodes)
 	if err != nil {
 		glog.Warningf("Couldn't create a new treeStorage: %s", err)
 		return nil, err
@@ -60,8 +119,8 @@ func NewMapStorage(id trillian.MapID, dbURL string) (storage.MapStorage, error)
 	return &s, nil
 }
 
-func (m *mySQLMapStorage) Begin() (storage.MapTX, error) {
-	ttx, err := m.beginTreeTx()
+func (m *mySQLMapStorage) beginInternal(ctx context.Context) (*mapTX, error) {
+	ttx, err := m.beginTreeTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +129,7 @@ func (m *mySQLMapStorage) Begin() (storage.MapTX, error) {
 		ms:     m,
 	}
 
-	root, err := ret.LatestSignedMapRoot()
+	root, err := ret.LatestSignedMapRoot(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -80,12 +139,64 @@ func (m *mySQLMapStorage) Begin() (storage.MapTX, error) {
 	return ret, nil
 }
 
-func (m *mySQLMapStorage) Snapshot() (storage.ReadOnlyMapTX, error) {
-	tx, err := m.Begin()
+func (m *mySQLMapStorage) Begin(ctx context.Context) (storage.MapTX, error) {
+	tx, err := m.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (m *mySQLMapStorage) Snapshot(ctx context.Context) (storage.ReadOnlyMapTX, error) {
+	tx, err := m.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, err
+}
+
+// SnapshotForTree starts a read-only transaction pinned to a revision of the map, or to the
+// latest one if revision is < 0.
+func (m *mySQLMapStorage) SnapshotForTree(ctx context.Context, revision int64) (storage.ReadOnlyMapTreeTX, error) {
+	ttx, err := m.beginTreeTx(ctx, snapshotTxOptions)
 	if err != nil {
 		return nil, err
 	}
-	return tx.(storage.ReadOnlyMapTX), err
+	tx := &mapTX{
+		treeTX: ttx,
+		ms:     m,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var root trillian.SignedMapRoot
+	if revision < 0 {
+		root, err = tx.LatestSignedMapRoot(ctx)
+	} else {
+		root, err = tx.GetSignedMapRoot(ctx, revision)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = root.MapRevision
+
+	return tx, nil
 }
 
 type mapTX struct {
@@ -97,7 +208,7 @@ func (t *mapTX) WriteRevision() int64 {
 	return t.treeTX.writeRevision
 }
 
-func (m *mapTX) Set(keyHash trillian.Hash, value trillian.MapLeaf) error {
+func (m *mapTX) Set(ctx context.Context, keyHash trillian.Hash, value trillian.MapLeaf) error {
 	// TODO(al): consider storing some sort of value which represents the group of keys being set in this Tx.
 	//           That way, if this attempt partially fails (i.e. because some subset of the in-the-future merkle
 	//           nodes do get written), we can enforce that future map update attempts are a complete replay of
@@ -107,26 +218,29 @@ func (m *mapTX) Set(keyHash trillian.Hash, value trillian.MapLeaf) error {
 		return nil
 	}
 
-	stmt, err := m.tx.Prepare(insertMapLeafSQL)
+	stmt, err := m.tx.Prepare(ctx, insertMapLeafSQL)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	// Note: MapRevision is stored negated:
-	_, err = stmt.Exec(m.ms.mapID.TreeID, []byte(keyHash), -m.writeRevision, flatValue)
+	_, err = stmt.Exec(ctx, m.ms.mapID.TreeID, []byte(keyHash), -m.writeRevision, flatValue)
+	if isDuplicateKeyError(err) {
+		return storage.ErrMapRevisionConflict
+	}
 	return err
 }
 
-func (m *mapTX) Get(revision int64, keyHashes []trillian.Hash) ([]trillian.MapLeaf, error) {
+func (m *mapTX) Get(ctx context.Context, revision int64, keyHashes []trillian.Hash) ([]trillian.MapLeaf, error) {
 	stmt, err := m.ms.getStmt(selectMapLeafSQL, len(keyHashes), "?", "?")
 	if err != nil {
 		return nil, err
 	}
-	stx := m.tx.Stmt(stmt)
+	stx := m.tx.Stmt(ctx, stmt)
 	defer stx.Close()
 
-	args := make([]interface{}, 0, len(keyHashes)+2)
+	args := make([]interface{}, 0, len(keyHashes)+3)
 	for _, k := range keyHashes {
 		args = append(args, []byte(k[:]))
 	}
@@ -134,10 +248,11 @@ func (m *mapTX) Get(revision int64, keyHashes []trillian.Hash) ([]trillian.MapLe
 	// Note: MapRevision is negated when stored to cause more recent revisions to
 	// appear earlier in query results.
 	args = append(args, -revision)
+	args = append(args, m.ms.mapID.TreeID)
 
-	glog.Infof("args size %d", len(args))
+	logging.Infof(ctx, "args size %d", len(args))
 
-	rows, err := stx.Query(args...)
+	rows, err := stx.Query(ctx, args...)
 	// It's possible there are no values for any of these keys yet
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -169,33 +284,171 @@ func (m *mapTX) Get(revision int64, keyHashes []trillian.Hash) ([]trillian.MapLe
 		ret = append(ret, mapLeaf)
 		nr++
 	}
-	glog.Infof("%d rows, %d empty", nr, er)
+	logging.Infof(ctx, "%d rows, %d empty", nr, er)
 	return ret, nil
 }
 
-func (m *mapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
-	var timestamp, mapRevision int64
-	var rootHash, rootSignatureBytes []byte
-	var rootSignature trillian.DigitallySigned
-	var mapperMetaBytes []byte
-	var mapperMeta *trillian.MapperMetadata
+func (m *mapTX) GetHistory(ctx context.Context, keyHash trillian.Hash, startRevision, endRevision int64) ([]storage.MapLeafRevision, error) {
+	stmt, err := m.tx.Prepare(ctx, selectMapLeafHistorySQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	// Note: MapRevision is stored negated
+	rows, err := stmt.Query(ctx, m.ms.mapID.TreeID, []byte(keyHash), -endRevision, -startRevision)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read history of map key: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]storage.MapLeafRevision, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return nil, err
+		}
+		mapLeaf.KeyHash = keyHash
+		ret = append(ret, storage.MapLeafRevision{Revision: -mapRevision, Leaf: mapLeaf})
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (m *mapTX) GetKeys(ctx context.Context, start, end trillian.Key, count int) ([]trillian.Key, error) {
+	query, args := selectMapKeysSQL, []interface{}{m.ms.mapID.TreeID, []byte(start)}
+	if end != nil {
+		query, args = selectMapKeysBeforeSQL, append(args, []byte(end))
+	}
+	args = append(args, count)
+
+	stmt, err := m.tx.Prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
 
-	stmt, err := m.tx.Prepare(selectLatestSignedMapRootSql)
+	rows, err := stmt.Query(ctx, args...)
+	if err != nil {
+		glog.Warningf("Failed to read map keys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]trillian.Key, 0)
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		ret = append(ret, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (m *mapTX) GetMutations(ctx context.Context, startRevision int64, count int) ([]trillian.MapMutation, error) {
+	stmt, err := m.tx.Prepare(ctx, selectMapMutationsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(ctx, m.ms.mapID.TreeID, startRevision, count)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map mutations: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]trillian.MapMutation, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+		var mutation trillian.MapMutation
+		if err := proto.Unmarshal(flatData, &mutation); err != nil {
+			return nil, err
+		}
+		mutation.MapRevision = mapRevision
+		ret = append(ret, mutation)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (m *mapTX) LatestSignedMapRoot(ctx context.Context) (trillian.SignedMapRoot, error) {
+	stmt, err := m.tx.Prepare(ctx, selectLatestSignedMapRootSql)
 	if err != nil {
 		return trillian.SignedMapRoot{}, err
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRow(m.ms.mapID.TreeID).Scan(
-		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
+	root, err := m.readSignedMapRoot(stmt.QueryRow(ctx, m.ms.mapID.TreeID))
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
 		return trillian.SignedMapRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed map root: %v", err)
+		return trillian.SignedMapRoot{}, err
 	}
 
-	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
+	return root, nil
+}
+
+func (m *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (trillian.SignedMapRoot, error) {
+	stmt, err := m.tx.Prepare(ctx, selectSignedMapRootByRevisionSql)
 	if err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+	defer stmt.Close()
+
+	root, err := m.readSignedMapRoot(stmt.QueryRow(ctx, m.ms.mapID.TreeID, revision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, storage.ErrMapRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed map root at revision %d: %v", revision, err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedMapRoot scans a MapHead row. It returns sql.ErrNoRows if there is no row.
+func (m *mapTX) readSignedMapRoot(row *sqltrace.Row) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	if err := row.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes); err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
 		glog.Warningf("Failed to unmarshal root signature: %v", err)
 		return trillian.SignedMapRoot{}, err
 	}
@@ -220,10 +473,10 @@ func (m *mapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
 	return ret, nil
 }
 
-func (m *mapTX) StoreSignedMapRoot(root trillian.SignedMapRoot) error {
+func (m *mapTX) StoreSignedMapRoot(ctx context.Context, root trillian.SignedMapRoot) error {
 	signatureBytes, err := proto.Marshal(root.Signature)
 	if err != nil {
-		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
 		return err
 	}
 
@@ -232,23 +485,76 @@ func (m *mapTX) StoreSignedMapRoot(root trillian.SignedMapRoot) error {
 	if root.Metadata != nil {
 		mapperMetaBytes, err = proto.Marshal(root.Metadata)
 		if err != nil {
-			glog.Warning("Failed to marshal MetaData: %v %v", root.Metadata, err)
+			logging.Warningf(ctx, "Failed to marshal MetaData: %v %v", root.Metadata, err)
 			return err
 		}
 	}
 
-	stmt, err := m.tx.Prepare(insertMapHeadSQL)
+	stmt, err := m.tx.Prepare(ctx, insertMapHeadSQL)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	// TODO(al): store transactionLogHead too
-	res, err := stmt.Exec(m.ms.mapID.TreeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
+	res, err := stmt.Exec(ctx, m.ms.mapID.TreeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
 
+	if isDuplicateKeyError(err) {
+		return storage.ErrMapRevisionConflict
+	}
 	if err != nil {
-		glog.Warningf("Failed to store signed map root: %s", err)
+		logging.Warningf(ctx, "Failed to store signed map root: %s", err)
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+
+	m.rootStored(root.MapRevision)
+	return nil
+}
+
+func (m *mapTX) StoreKey(ctx context.Context, key trillian.Key) error {
+	stmt, err := m.tx.Prepare(ctx, insertMapKeySQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(ctx, m.ms.mapID.TreeID, []byte(key)); err != nil {
+		glog.Warningf("Failed to store map key: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *mapTX) StoreMutation(ctx context.Context, mutation trillian.MapMutation) error {
+	flatData, err := proto.Marshal(&mutation)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal map mutation: %v", err)
+		return err
+	}
+
+	stmt, err := m.tx.Prepare(ctx, insertMapMutationSQL)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(ctx, m.ms.mapID.TreeID, mutation.MapRevision, flatData)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to store map mutation: %s", err)
 	}
 
 	return checkResultOkAndRowCountIs(res, err, 1)
 }
+
+// isDuplicateKeyError returns true if err is from a write that failed because a row with the
+// same unique key already exists
+func isDuplicateKeyError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	// ER_DUP_ENTRY
+	return ok && mysqlErr.Number == 1062
+}
@@ -0,0 +1,289 @@
+package mysql
+
+import (
+	"github.com/google/trillian/storage/migrate"
+)
+
+// migrations upgrade the MySQL schema from one version to the next. storage.sql creates
+// the schema at the latest version, so a migration added here must also change it and the
+// version it records. Migrations are never changed once released, as databases may already
+// have them applied.
+//
+// Migration 1 is the schema from before it was versioned. Its tables are only created if
+// they don't exist, so a database made by an earlier storage.sql, which has no SchemaVersion
+// table, is brought up to date by the migrations after it.
+var migrations = []migrate.Migration{
+	{
+		Version:     1,
+		Description: "Initial schema",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                INTEGER NOT NULL,
+  KeyId                 VARBINARY(255) NOT NULL,
+  TreeType              ENUM('LOG', 'MAP')  NOT NULL,
+  LeafHasherType        ENUM('SHA256') NOT NULL,
+  TreeHasherType        ENUM('SHA256') NOT NULL,
+  AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  INTEGER NOT NULL,
+  ReadOnlyRequests        BOOLEAN,
+  SigningEnabled          BOOLEAN,
+  SequencingEnabled       BOOLEAN,
+  SequenceIntervalSeconds INTEGER,
+  SignIntervalSeconds     INTEGER,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               INTEGER NOT NULL,
+  SubtreeId            VARBINARY(255) NOT NULL,
+  Nodes                VARBINARY(32768) NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               INTEGER NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             VARBINARY(255) NOT NULL,
+  RootSignature        VARBINARY(255) NOT NULL,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  UNIQUE INDEX TreeRevisionIdx(TreeId, TreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               INTEGER NOT NULL,
+  LeafHash             VARBINARY(255) NOT NULL,
+  TheData              BLOB NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash),
+  INDEX LeafHashIdx(LeafHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               INTEGER NOT NULL,
+  SequenceNumber       BIGINT UNSIGNED NOT NULL,
+  LeafHash             VARBINARY(255) NOT NULL,
+  SignedEntryTimestamp BLOB NOT NULL,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(LeafHash) REFERENCES LeafData(LeafHash)
+)`,
+			`CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               INTEGER NOT NULL,
+  LeafHash             VARBINARY(255) NOT NULL,
+  MessageId            BINARY(32) NOT NULL,
+  Payload              BLOB NOT NULL,
+  QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  SignedEntryTimestamp BLOB,
+  PRIMARY KEY (TreeId, LeafHash, MessageId)
+)`,
+			`CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                INTEGER NOT NULL,
+  KeyHash               VARBINARY(255) NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  TheData               BLOB NOT NULL,
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               INTEGER NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             VARBINARY(255) NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        VARBINARY(255) NOT NULL,
+  MapperData           BLOB,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  UNIQUE INDEX TreeRevisionIdx(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add tree lifecycle fields",
+		Statements: []string{
+			// MySQL won't change the type of a column in a foreign key while the keys are
+			// checked, every TreeId is changed before they're checked again
+			"SET FOREIGN_KEY_CHECKS = 0",
+			"ALTER TABLE Trees MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE TreeControl MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE Subtree MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE TreeHead MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE LeafData MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE SequencedLeafData MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE Unsequenced MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE MapLeaf MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"ALTER TABLE MapHead MODIFY COLUMN TreeId BIGINT NOT NULL",
+			"SET FOREIGN_KEY_CHECKS = 1",
+			"ALTER TABLE Trees ADD COLUMN TreeState ENUM('ACTIVE', 'FROZEN') NOT NULL DEFAULT 'ACTIVE'",
+			"ALTER TABLE Trees ADD COLUMN DisplayName VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE Trees ADD COLUMN Description VARCHAR(1024) NOT NULL DEFAULT ''",
+			"ALTER TABLE Trees ADD COLUMN CreateTimeMillis BIGINT NOT NULL DEFAULT 0",
+			"ALTER TABLE Trees ADD COLUMN UpdateTimeMillis BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add tree soft deletion",
+		Statements: []string{
+			"ALTER TABLE Trees ADD COLUMN Deleted BOOLEAN NOT NULL DEFAULT 0",
+			"ALTER TABLE Trees ADD COLUMN DeleteTimeMillis BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add tree hash algorithms",
+		Statements: []string{
+			"ALTER TABLE Trees MODIFY COLUMN LeafHasherType ENUM('SHA256', 'SHA512_256', 'BLAKE2B_256') NOT NULL",
+			"ALTER TABLE Trees MODIFY COLUMN TreeHasherType ENUM('SHA256', 'SHA512_256', 'BLAKE2B_256') NOT NULL",
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add pre-ordered logs",
+		Statements: []string{
+			"ALTER TABLE Trees MODIFY COLUMN TreeType ENUM('LOG', 'MAP', 'PREORDERED_LOG') NOT NULL",
+			"ALTER TABLE Unsequenced ADD COLUMN SequenceNumber BIGINT",
+			"CREATE UNIQUE INDEX SequenceNumberIdx ON Unsequenced(TreeId, SequenceNumber)",
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add cosignatures",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  WitnessId            VARCHAR(255) NOT NULL,
+  Signature            VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, WitnessId),
+  FOREIGN KEY(TreeId, TreeHeadTimestamp) REFERENCES TreeHead(TreeId, TreeHeadTimestamp) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add tree usage",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId                  BIGINT NOT NULL,
+  Requests                BIGINT NOT NULL DEFAULT 0,
+  BytesIn                 BIGINT NOT NULL DEFAULT 0,
+  BytesOut                BIGINT NOT NULL DEFAULT 0,
+  LeavesQueued            BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis        BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add map mutations",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  TheData              MEDIUMBLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "Add subtree shard maps",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS SubtreeShard(
+  TreeId               BIGINT NOT NULL,
+  ShardIndex           INTEGER NOT NULL,
+  ShardName            VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, ShardIndex),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "Add tree head metadata",
+		Statements: []string{
+			"ALTER TABLE TreeHead ADD COLUMN Metadata BLOB",
+		},
+	},
+	{
+		Version:     11,
+		Description: "Add leaf identity hashes",
+		Statements: []string{
+			"ALTER TABLE LeafData ADD COLUMN LeafIdentityHash VARBINARY(255)",
+			"CREATE INDEX LeafIdentityHashIdx ON LeafData(TreeId, LeafIdentityHash)",
+		},
+	},
+	{
+		Version:     12,
+		Description: "Add map key index",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               BIGINT NOT NULL,
+  TheKey               VARBINARY(767) NOT NULL,
+  PRIMARY KEY(TreeId, TheKey),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "Add sequencer checkpoints",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  CompactTree          BLOB NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "Add queue claims",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN ClaimOwner VARCHAR(255)",
+			"ALTER TABLE Unsequenced ADD COLUMN ClaimExpiry BIGINT",
+		},
+	},
+	{
+		Version:     15,
+		Description: "Add queue priorities",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN Priority INT NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     16,
+		Description: "Add queue not-before times",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN NotBefore BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+}
+
+// migrateDialect records the schema version in the same way as storage.sql. MySQL commits
+// schema changes straight away, so a migration that fails part way through must be fixed by
+// hand before it's run again.
+var migrateDialect = migrate.SQLDialect{
+	CreateVersionTable: `CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL,
+  Description          VARCHAR(255) NOT NULL,
+  AppliedTimeMillis    BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+)`,
+	InsertVersion: "INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(?, ?, ?)",
+	Lock:          "SELECT GET_LOCK('trillian_migrate', 60)",
+	// The connection goes back to the pool, so foreign key checks are turned back on in case
+	// a migration that turns them off failed before it could
+	Unlock: "SET FOREIGN_KEY_CHECKS = 1, @released = RELEASE_LOCK('trillian_migrate')",
+}
@@ -0,0 +1,155 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	"github.com/google/trillian/util"
+)
+
+// ProviderName is the name the MySQL storage system is registered under.
+const ProviderName = "mysql"
+
+func init() {
+	if err := storage.RegisterProvider(ProviderName, newMySQLProvider); err != nil {
+		panic(err)
+	}
+}
+
+// mySQLProvider creates MySQL backed storage for trees in a single database. The storage
+// it creates shares one pool of connections to the database.
+type mySQLProvider struct {
+	dbURL string
+
+	mu     sync.Mutex
+	db     *sql.DB
+	config storage.ConnectionConfig
+	limits dbLimits
+	shards *subtreeShards
+}
+
+func newMySQLProvider(dbURL string) (storage.Provider, error) {
+	return &mySQLProvider{dbURL: dbURL}, nil
+}
+
+// SetConnectionConfig limits the connections to the database, see storage.ConnectionConfig.
+// The storage for each tree keeps the limits it was created with, apart from the size of
+// the pool, which changes straight away.
+func (m *mySQLProvider) SetConnectionConfig(config storage.ConnectionConfig) error {
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 || config.ConnMaxLifetime < 0 || config.QueryTimeout < 0 {
+		return fmt.Errorf("mysql: connection limits must be >= 0: %+v", config)
+	}
+
+	if config.Breaker.Failures < 0 || config.Breaker.OpenDuration < 0 {
+		return fmt.Errorf("mysql: circuit breaker settings must be >= 0: %+v", config.Breaker)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = config
+	m.limits = dbLimits{queryTimeout: config.QueryTimeout}
+
+	if config.Breaker.Failures > 0 {
+		m.limits.breaker = storage.NewCircuitBreaker(config.Breaker, ProviderName, util.SystemTimeSource{})
+	}
+
+	if m.db != nil {
+		applyConnectionConfig(m.db, config)
+	}
+
+	if m.shards != nil {
+		m.shards.setConfig(config)
+	}
+
+	return nil
+}
+
+// SetSubtreeShards gives the DSNs of the databases named in the shard maps of trees, see
+// subtree_shards.go. Trees created after it's called have their subtrees spread over all of
+// the shards.
+func (m *mySQLProvider) SetSubtreeShards(dsns map[string]string) error {
+	for name, dsn := range dsns {
+		if len(name) == 0 || len(dsn) == 0 {
+			return fmt.Errorf("mysql: subtree shard %q must have a name and DSN", name)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(dsns) == 0 {
+		m.shards = nil
+	} else {
+		m.shards = newSubtreeShards(dsns, m.config)
+	}
+
+	return nil
+}
+
+// getShards returns the subtree shards given to SetSubtreeShards, or nil if there aren't any
+func (m *mySQLProvider) getShards() *subtreeShards {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.shards
+}
+
+// getDB returns the database shared by all the storage, opening it the first time
+func (m *mySQLProvider) getDB() (*sql.DB, dbLimits, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.db == nil {
+		db, err := openDB(m.dbURL)
+		if err != nil {
+			return nil, dbLimits{}, err
+		}
+
+		applyConnectionConfig(db, m.config)
+		m.db = db
+	}
+
+	return m.db, m.limits, nil
+}
+
+func (m *mySQLProvider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	db, limits, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogStorage(id, db, limits, m.getShards())
+}
+
+func (m *mySQLProvider) MapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	db, limits, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMapStorage(id, db, limits, m.getShards())
+}
+
+func (m *mySQLProvider) AdminStorage() (storage.AdminStorage, error) {
+	db, limits, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return &mySQLAdminStorage{db: db, limits: limits, shards: m.getShards()}, nil
+}
+
+// Migrations returns the database shared by the storage and the migrations for its schema
+func (m *mySQLProvider) Migrations() (migrate.Database, []migrate.Migration, error) {
+	db, _, err := m.getDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return migrate.NewSQLDatabase(db, migrateDialect), migrations, nil
+}
@@ -0,0 +1,12 @@
+# MySQL / MariaDB version of the subtree shard schema
+
+-- Shards only store the subtrees of trees with a shard map, see subtree_shards.go. The
+-- trees are kept in the main database, so unlike in storage.sql the table doesn't
+-- reference them.
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            VARBINARY(255) NOT NULL,
+  Nodes                VARBINARY(32768) NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision)
+);
@@ -8,19 +8,26 @@
 -- Tree parameters should not be changed after creation. Doing so can
 -- render the data in the tree unusable or inconsistent.
 CREATE TABLE IF NOT EXISTS Trees(
-  TreeId                INTEGER NOT NULL,
+  TreeId                BIGINT NOT NULL,
   KeyId                 VARBINARY(255) NOT NULL,
-  TreeType              ENUM('LOG', 'MAP')  NOT NULL,
-  LeafHasherType        ENUM('SHA256') NOT NULL,
-  TreeHasherType        ENUM('SHA256') NOT NULL,
+  TreeType              ENUM('LOG', 'MAP', 'PREORDERED_LOG')  NOT NULL,
+  LeafHasherType        ENUM('SHA256', 'SHA512_256', 'BLAKE2B_256') NOT NULL,
+  TreeHasherType        ENUM('SHA256', 'SHA512_256', 'BLAKE2B_256') NOT NULL,
   AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT 0,
+  TreeState             ENUM('ACTIVE', 'FROZEN') NOT NULL DEFAULT 'ACTIVE',
+  DisplayName           VARCHAR(255) NOT NULL DEFAULT '',
+  Description           VARCHAR(1024) NOT NULL DEFAULT '',
+  CreateTimeMillis      BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis      BIGINT NOT NULL DEFAULT 0,
+  Deleted               BOOLEAN NOT NULL DEFAULT 0,
+  DeleteTimeMillis      BIGINT NOT NULL DEFAULT 0,
   PRIMARY KEY(TreeId)
 );
 
 -- This table contains tree parameters that can be changed at runtime such as for
 -- administrative purposes.
 CREATE TABLE IF NOT EXISTS TreeControl(
-  TreeId                  INTEGER NOT NULL,
+  TreeId                  BIGINT NOT NULL,
   ReadOnlyRequests        BOOLEAN,
   SigningEnabled          BOOLEAN,
   SequencingEnabled       BOOLEAN,
@@ -30,8 +37,21 @@ CREATE TABLE IF NOT EXISTS TreeControl(
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
 );
 
+-- Totals of what clients have used each tree for, added to periodically by the servers so
+-- that tree owners can be billed or limited
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId                  BIGINT NOT NULL,
+  Requests                BIGINT NOT NULL DEFAULT 0,
+  BytesIn                 BIGINT NOT NULL DEFAULT 0,
+  BytesOut                BIGINT NOT NULL DEFAULT 0,
+  LeavesQueued            BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis        BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS Subtree(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   SubtreeId            VARBINARY(255) NOT NULL,
   Nodes                VARBINARY(32768) NOT NULL,
   SubtreeRevision      INTEGER NOT NULL,  -- negated because DESC indexes aren't supported :/
@@ -42,17 +62,41 @@ CREATE TABLE IF NOT EXISTS Subtree(
 -- The TreeRevisionIdx is used to enforce that there is only one STH at any
 -- tree revision
 CREATE TABLE IF NOT EXISTS TreeHead(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   TreeHeadTimestamp    BIGINT,
   TreeSize             BIGINT,
   RootHash             VARBINARY(255) NOT NULL,
   RootSignature        VARBINARY(255) NOT NULL,
   TreeRevision         BIGINT,
+  -- Opaque metadata the root was signed with, if any
+  Metadata             BLOB,
   PRIMARY KEY(TreeId, TreeHeadTimestamp),
   UNIQUE INDEX TreeRevisionIdx(TreeId, TreeRevision),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
+-- The sequencer's checkpoint for each log: the state of the compact merkle tree, as a
+-- serialized CompactRangeProto, stored in the same transaction as the STH at TreeRevision.
+-- There's one row per log, each new checkpoint replaces the last.
+CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  CompactTree          BLOB NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A witness's cosignature over one of the STHs in TreeHead, each witness has at most one
+-- for any STH
+CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  WitnessId            VARCHAR(255) NOT NULL,
+  Signature            VARBINARY(1024) NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, WitnessId),
+  FOREIGN KEY(TreeId, TreeHeadTimestamp) REFERENCES TreeHead(TreeId, TreeHeadTimestamp) ON DELETE CASCADE
+);
+
 
 -- ---------------------------------------------
 -- Log specific stuff here
@@ -65,11 +109,14 @@ CREATE TABLE IF NOT EXISTS TreeHead(
 -- A leaf that has not been sequenced has a row in this table. If duplicate leaves
 -- are allowed they will all reference this row.
 CREATE TABLE IF NOT EXISTS LeafData(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   LeafHash             VARBINARY(255) NOT NULL,
   TheData              BLOB NOT NULL,
+  -- Optional hash chosen by the application to find leaves by, not covered by the tree
+  LeafIdentityHash     VARBINARY(255),
   PRIMARY KEY(TreeId, LeafHash),
   INDEX LeafHashIdx(LeafHash),
+  INDEX LeafIdentityHashIdx(TreeId, LeafIdentityHash),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
@@ -79,7 +126,7 @@ CREATE TABLE IF NOT EXISTS LeafData(
 -- on the log parameters and we can't insert into this table until we have the sequence number
 -- which is not available at the time we queue the entry.
 CREATE TABLE IF NOT EXISTS SequencedLeafData(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   SequenceNumber       BIGINT UNSIGNED NOT NULL,
   LeafHash             VARBINARY(255) NOT NULL,
   SignedEntryTimestamp BLOB NOT NULL,
@@ -89,7 +136,7 @@ CREATE TABLE IF NOT EXISTS SequencedLeafData(
 );
 
 CREATE TABLE IF NOT EXISTS Unsequenced(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   LeafHash             VARBINARY(255) NOT NULL,
   -- SHA256("queueId"|TreeId|leafHash)
   -- We want this to be unique per entry per log, but queryable by FEs so that
@@ -98,7 +145,21 @@ CREATE TABLE IF NOT EXISTS Unsequenced(
   Payload              BLOB NOT NULL,
   QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   SignedEntryTimestamp BLOB,
-  PRIMARY KEY (TreeId, LeafHash, MessageId)
+  -- Only set for leaves added to pre-ordered logs, where it's the position chosen by the
+  -- application. Entries without one don't conflict as NULLs are distinct.
+  SequenceNumber       BIGINT,
+  -- The sequencer holding a claim on the entry and when the claim expires, in nanoseconds
+  -- since the epoch. Both are NULL for entries that have never been claimed.
+  ClaimOwner           VARCHAR(255),
+  ClaimExpiry          BIGINT,
+  -- Entries with a higher priority are dequeued first. Entries of pre-ordered logs are
+  -- dequeued in sequence order and are always 0.
+  Priority             INT NOT NULL DEFAULT 0,
+  -- Entries aren't dequeued before this time, in nanoseconds since the epoch. It's 0 for
+  -- entries that can be dequeued straight away, including all those of pre-ordered logs.
+  NotBefore            BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (TreeId, LeafHash, MessageId),
+  UNIQUE INDEX SequenceNumberIdx(TreeId, SequenceNumber)
 );
 
 
@@ -107,19 +168,20 @@ CREATE TABLE IF NOT EXISTS Unsequenced(
 -- ---------------------------------------------
 
 CREATE TABLE IF NOT EXISTS MapLeaf(
-  TreeId                INTEGER NOT NULL,
+  TreeId                BIGINT NOT NULL,
   KeyHash               VARBINARY(255) NOT NULL,
   -- MapRevision is stored negated to invert ordering in the primary key index
   -- st. more recent revisions come first.
   MapRevision           BIGINT NOT NULL,
   TheData               BLOB NOT NULL,
+  -- The primary key also serves reads of the history of a key across revisions.
   PRIMARY KEY(TreeId, KeyHash, MapRevision),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
 
 CREATE TABLE IF NOT EXISTS MapHead(
-  TreeId               INTEGER NOT NULL,
+  TreeId               BIGINT NOT NULL,
   MapHeadTimestamp     BIGINT,
   RootHash             VARBINARY(255) NOT NULL,
   MapRevision          BIGINT,
@@ -130,3 +192,60 @@ CREATE TABLE IF NOT EXISTS MapHead(
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
+-- MapMutation holds the values written at each revision of a map, so the map can be
+-- rebuilt by replaying them in revision order. It's written in the same transaction as
+-- the MapHead for the revision.
+CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  -- One revision can set many values, more than fit in a BLOB
+  TheData              MEDIUMBLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- MapKey is an index of the keys which have been set in each map, so keys can be listed
+-- in order, e.g. those starting with a prefix. Only keys up to 767 bytes long can be set.
+CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               BIGINT NOT NULL,
+  TheKey               VARBINARY(767) NOT NULL,
+  PRIMARY KEY(TreeId, TheKey),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The shard map of a tree whose subtrees are stored in other databases, see
+-- subtree_shards.go. Shards are named rather than given by DSN so that credentials aren't
+-- kept here. The Subtree table in each shard is created by shard_storage.sql.
+CREATE TABLE IF NOT EXISTS SubtreeShard(
+  TreeId               BIGINT NOT NULL,
+  ShardIndex           INTEGER NOT NULL,
+  ShardName            VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, ShardIndex),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Each migration applied to the schema, see migrations.go. This file creates the schema at
+-- the version recorded here.
+CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL,
+  Description          VARCHAR(255) NOT NULL,
+  AppliedTimeMillis    BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+);
+
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(1, 'Initial schema', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(2, 'Add tree lifecycle fields', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(3, 'Add tree soft deletion', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(4, 'Add tree hash algorithms', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(5, 'Add pre-ordered logs', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(6, 'Add cosignatures', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(7, 'Add tree usage', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(8, 'Add map mutations', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(9, 'Add subtree shard maps', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(10, 'Add tree head metadata', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(11, 'Add leaf identity hashes', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(12, 'Add map key index', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(13, 'Add sequencer checkpoints', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(14, 'Add queue claims', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(15, 'Add queue priorities', 0);
+INSERT IGNORE INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(16, 'Add queue not-before times', 0);
@@ -6,22 +6,26 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"reflect"
 	"runtime/debug"
-	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	"golang.org/x/net/context"
 )
 
 // TODO(al): add checking to all the Commit() calls in here.
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
+var allTables = []string{"Unsequenced", "Cosignature", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "TreeUsage", "SubtreeShard", "Trees", "MapLeaf", "MapHead", "MapMutation", "MapKey", "SequencerCheckpoint"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
@@ -121,7 +125,7 @@ func TestOpenStateCommit(t *testing.T) {
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
 	s := prepareTestLogStorage(logID, t)
-	tx, err := s.Begin()
+	tx, err := s.Begin(context.Background())
 
 	if err != nil {
 		t.Fatalf("Failed to set up db transaction")
@@ -143,7 +147,7 @@ func TestOpenStateRollback(t *testing.T) {
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
 	s := prepareTestLogStorage(logID, t)
-	tx, err := s.Begin()
+	tx, err := s.Begin(context.Background())
 
 	if err != nil {
 		t.Fatalf("Failed to set up db transaction")
@@ -160,6 +164,22 @@ func TestOpenStateRollback(t *testing.T) {
 	}
 }
 
+func TestTreeHashAlgorithm(t *testing.T) {
+	logID := createLogID("TestTreeHashAlgorithm")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE Trees SET TreeHasherType='SHA512_256' WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to set hasher type of tree: %v", err)
+	}
+
+	s := prepareTestLogStorage(logID, t)
+
+	if got, want := s.HashAlgorithm(), trillian.HashAlgorithm_SHA512_256; got != want {
+		t.Fatalf("Log storage has hash algorithm %v, want %v", got, want)
+	}
+}
+
 func forceWriteRevision(rev int64, tx storage.TreeTX) {
 	mtx, ok := tx.(*logTX)
 	if !ok {
@@ -169,6 +189,7 @@ func forceWriteRevision(rev int64, tx storage.TreeTX) {
 }
 
 func TestNodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestNodeRoundTrip")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -183,18 +204,18 @@ func TestNodeRoundTrip(t *testing.T) {
 	}
 
 	{
-		tx, err := s.Begin()
+		tx, err := s.Begin(ctx)
 		forceWriteRevision(writeRevision, tx)
 		if err != nil {
 			t.Fatalf("Failed to Begin: %s", err)
 		}
 
 		// Need to read nodes before attempting to write
-		if _, err := tx.GetMerkleNodes(99, nodeIDsToRead); err != nil {
+		if _, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil {
 			t.Fatalf("Failed to read nodes: %s", err)
 		}
 
-		if err := tx.SetMerkleNodes(nodesToStore); err != nil {
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
 			t.Fatalf("Failed to store nodes: %s", err)
 		}
 
@@ -204,13 +225,13 @@ func TestNodeRoundTrip(t *testing.T) {
 	}
 
 	{
-		tx, err := s.Begin()
+		tx, err := s.Begin(ctx)
 
 		if err != nil {
 			t.Fatalf("Failed to Begin: %s", err)
 		}
 
-		readNodes, err := tx.GetMerkleNodes(100, nodeIDsToRead)
+		readNodes, err := tx.GetMerkleNodes(ctx, 100, nodeIDsToRead)
 		if err != nil {
 			t.Fatalf("Failed to retrieve nodes: %s", err)
 		}
@@ -218,60 +239,741 @@ func TestNodeRoundTrip(t *testing.T) {
 			t.Fatalf("Read back different nodes from the ones stored: %s", err)
 		}
 
-		if err := tx.Commit(); err != nil {
-			t.Fatalf("Failed to commit read: %s", err)
-		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit read: %s", err)
+		}
+	}
+}
+
+func TestPruneSubtrees(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestPruneSubtrees")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	nodesToStore := createSomeNodes("TestPruneSubtrees", logID.logID.TreeID)
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	// Write the same nodes at two revisions, the second time with different hashes.
+	for _, rev := range []int64{100, 110} {
+		tx, err := s.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Failed to Begin: %s", err)
+		}
+		forceWriteRevision(rev, tx)
+		if _, err := tx.GetMerkleNodes(ctx, rev-1, nodeIDsToRead); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+		for i := range nodesToStore {
+			h := sha256.Sum256([]byte{byte(i), byte(rev)})
+			nodesToStore[i].Hash = h[:]
+		}
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
+			t.Fatalf("Failed to store nodes: %s", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit nodes: %s", err)
+		}
+	}
+
+	for _, test := range []struct {
+		horizon  int64
+		wantRows int64
+	}{
+		// Nothing is superseded at or before revision 105.
+		{105, 0},
+		// The revision 100 subtree is superseded by the one at 110.
+		{110, 1},
+		// It's already gone.
+		{200, 0},
+	} {
+		tx, err := s.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Failed to Begin: %s", err)
+		}
+		stats, err := tx.(storage.SubtreePruner).PruneSubtrees(ctx, test.horizon)
+		if err != nil {
+			t.Fatalf("PruneSubtrees(%d)=_,%v, want no error", test.horizon, err)
+		}
+		if got, want := stats.Rows, test.wantRows; got != want {
+			t.Errorf("PruneSubtrees(%d) deleted %d rows, want %d", test.horizon, got, want)
+		}
+		if (stats.Rows > 0) != (stats.Bytes > 0) {
+			t.Errorf("PruneSubtrees(%d) deleted %d rows of %d bytes", test.horizon, stats.Rows, stats.Bytes)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit prune: %s", err)
+		}
+	}
+
+	// The latest revision must still be readable.
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to Begin: %s", err)
+	}
+	readNodes, err := tx.GetMerkleNodes(ctx, 110, nodeIDsToRead)
+	if err != nil {
+		t.Fatalf("Failed to retrieve nodes: %s", err)
+	}
+	if err := nodesAreEqual(readNodes, nodesToStore); err != nil {
+		t.Fatalf("Read back different nodes from the ones stored: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit read: %s", err)
+	}
+}
+
+func TestSubtreeShardFor(t *testing.T) {
+	counts := make([]int, 3)
+	for i := 0; i < 300; i++ {
+		prefix := []byte(fmt.Sprintf("prefix %d", i))
+		shard := subtreeShardFor(prefix, len(counts))
+		if shard < 0 || shard >= len(counts) {
+			t.Fatalf("subtreeShardFor(%x, %d)=%d, want in [0, %d)", prefix, len(counts), shard, len(counts))
+		}
+		if again := subtreeShardFor(prefix, len(counts)); again != shard {
+			t.Fatalf("subtreeShardFor(%x, %d) returned %d then %d", prefix, len(counts), shard, again)
+		}
+		counts[shard]++
+	}
+
+	for shard, count := range counts {
+		if count == 0 {
+			t.Errorf("No subtrees stored in shard %d of %d", shard, len(counts))
+		}
+	}
+}
+
+func TestShardedNodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDBOrDie()
+	defer db.Close()
+
+	// Both shards are the test database, which has a Subtree table like a shard's
+	p, err := newMySQLProvider("test:zaphod@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatalf("Failed to create provider: %s", err)
+	}
+	shards := map[string]string{"a": "test:zaphod@tcp(127.0.0.1:3306)/test", "b": "test:zaphod@tcp(127.0.0.1:3306)/test"}
+	if err := storage.SetSubtreeShards(p, shards); err != nil {
+		t.Fatalf("Failed to set subtree shards: %s", err)
+	}
+
+	as, err := p.AdminStorage()
+	if err != nil {
+		t.Fatalf("Failed to open admin storage: %s", err)
+	}
+	atx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	tree, err := atx.CreateTree(&trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("TestShardedNodeRoundTrip"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+
+	var numShards int
+	if err := db.QueryRow("SELECT COUNT(*) FROM SubtreeShard WHERE TreeId=?", tree.TreeId).Scan(&numShards); err != nil {
+		t.Fatalf("Failed to read shard map: %v", err)
+	}
+	if got, want := numShards, len(shards); got != want {
+		t.Fatalf("Tree created with %d shards, want %d", got, want)
+	}
+
+	s, err := p.LogStorage(trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId})
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %s", err)
+	}
+
+	nodesToStore := createSomeNodes("TestShardedNodeRoundTrip", tree.TreeId)
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		forceWriteRevision(100, tx)
+		if _, err := tx.GetMerkleNodes(ctx, 99, nodeIDsToRead); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+		if err := tx.SetMerkleNodes(ctx, nodesToStore); err != nil {
+			t.Fatalf("Failed to store nodes: %s", err)
+		}
+		commit(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		readNodes, err := tx.GetMerkleNodes(ctx, 100, nodeIDsToRead)
+		if err != nil {
+			t.Fatalf("Failed to retrieve nodes: %s", err)
+		}
+		if err := nodesAreEqual(readNodes, nodesToStore); err != nil {
+			t.Fatalf("Read back different nodes from the ones stored: %s", err)
+		}
+		commit(tx, t)
+	}
+
+	atx, err = as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	if _, err := atx.SoftDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to soft delete tree: %v", err)
+	}
+	if err := atx.HardDeleteTree(tree.TreeId); err != nil {
+		t.Fatalf("Failed to hard delete tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+
+	var numSubtrees int
+	if err := db.QueryRow("SELECT COUNT(*) FROM Subtree WHERE TreeId=?", tree.TreeId).Scan(&numSubtrees); err != nil {
+		t.Fatalf("Failed to count subtrees: %v", err)
+	}
+	if numSubtrees != 0 {
+		t.Errorf("%d subtrees left after the tree was deleted, want 0", numSubtrees)
+	}
+}
+
+func TestAdminTreeLifecycle(t *testing.T) {
+	as, err := NewAdminStorage("test:zaphod@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatalf("Failed to open admin storage: %s", err)
+	}
+
+	atx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	tree, err := atx.CreateTree(&trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("TestAdminTreeLifecycle"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+		DisplayName:   "Lifecycle",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+	if tree.TreeId <= 0 || tree.TreeState != trillian.TreeState_ACTIVE || tree.CreateTimeMillis == 0 {
+		t.Fatalf("Created tree has unexpected settings: %v", tree)
+	}
+
+	rtx, err := as.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	got, err := rtx.GetTree(tree.TreeId)
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+	if !proto.Equal(tree, got) {
+		t.Errorf("GetTree()=%v, want %v", got, tree)
+	}
+	trees, err := rtx.ListTrees(false)
+	if err != nil {
+		t.Fatalf("Failed to list trees: %v", err)
+	}
+	found := false
+	for _, listed := range trees {
+		found = found || proto.Equal(tree, listed)
+	}
+	if !found {
+		t.Errorf("ListTrees()=%v, want it to include %v", trees, tree)
+	}
+	if err := rtx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin snapshot: %v", err)
+	}
+
+	updateTree := func(f func(*trillian.Tree)) (*trillian.Tree, error) {
+		atx, err := as.Begin(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to begin admin tx: %v", err)
+		}
+		updated, err := atx.UpdateTree(tree.TreeId, f)
+		if err != nil {
+			atx.Rollback()
+			return nil, err
+		}
+		return updated, atx.Commit()
+	}
+
+	updated, err := updateTree(func(tree *trillian.Tree) { tree.DisplayName = "Renamed" })
+	if err != nil {
+		t.Fatalf("Failed to update tree: %v", err)
+	}
+	if updated.DisplayName != "Renamed" || updated.UpdateTimeMillis < tree.UpdateTimeMillis {
+		t.Errorf("Updated tree has unexpected settings: %v", updated)
+	}
+	if _, err := updateTree(func(tree *trillian.Tree) { tree.TreeType = trillian.TreeType_MAP }); err == nil {
+		t.Error("Allowed the tree type to be changed")
+	}
+
+	// The log accepts writes until it's frozen
+	ls, err := NewLogStorage(trillian.LogID{LogID: tree.KeyId, TreeID: tree.TreeId}, "test:zaphod@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %s", err)
+	}
+	tx := beginLogTx(ls, t)
+	commit(tx, t)
+
+	if _, err := updateTree(func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_FROZEN }); err != nil {
+		t.Fatalf("Failed to freeze tree: %v", err)
+	}
+	if _, err := ls.Begin(context.Background()); err != storage.ErrReadOnly {
+		t.Errorf("Begin() on frozen log returned %v, want %v", err, storage.ErrReadOnly)
+	}
+	snapshot, err := ls.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read frozen log: %v", err)
+	}
+	if err := snapshot.Commit(); err != nil {
+		t.Fatalf("Failed to commit snapshot of frozen log: %v", err)
+	}
+	if _, err := updateTree(func(tree *trillian.Tree) { tree.TreeState = trillian.TreeState_ACTIVE }); err == nil {
+		t.Error("Allowed a frozen tree to be made active")
+	}
+
+	// runAdmin runs f in an admin transaction, which is committed whatever f returns
+	runAdmin := func(f func(storage.AdminTX) error) error {
+		atx, err := as.Begin(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to begin admin tx: %v", err)
+		}
+		err = f(atx)
+		if err := atx.Commit(); err != nil {
+			t.Fatalf("Failed to commit admin tx: %v", err)
+		}
+		return err
+	}
+	softDelete := func(atx storage.AdminTX) error {
+		_, err := atx.SoftDeleteTree(tree.TreeId)
+		return err
+	}
+	undelete := func(atx storage.AdminTX) error {
+		_, err := atx.UndeleteTree(tree.TreeId)
+		return err
+	}
+	hardDelete := func(atx storage.AdminTX) error {
+		return atx.HardDeleteTree(tree.TreeId)
+	}
+	isListed := func(includeDeleted bool) bool {
+		rtx, err := as.Snapshot(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to start admin snapshot: %v", err)
+		}
+		defer rtx.Commit()
+		trees, err := rtx.ListTrees(includeDeleted)
+		if err != nil {
+			t.Fatalf("Failed to list trees: %v", err)
+		}
+		for _, listed := range trees {
+			if listed.TreeId == tree.TreeId {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := runAdmin(hardDelete); err != storage.ErrTreeNotDeleted {
+		t.Errorf("HardDeleteTree() on live tree returned %v, want %v", err, storage.ErrTreeNotDeleted)
+	}
+	if err := runAdmin(softDelete); err != nil {
+		t.Fatalf("Failed to delete tree: %v", err)
+	}
+	if err := runAdmin(softDelete); err != storage.ErrTreeDeleted {
+		t.Errorf("SoftDeleteTree() on deleted tree returned %v, want %v", err, storage.ErrTreeDeleted)
+	}
+
+	// Deleted trees can't be used and are only listed on request
+	if _, err := ls.Begin(context.Background()); err != storage.ErrTreeDeleted {
+		t.Errorf("Begin() on deleted log returned %v, want %v", err, storage.ErrTreeDeleted)
+	}
+	if _, err := ls.Snapshot(context.Background()); err != storage.ErrTreeDeleted {
+		t.Errorf("Snapshot() on deleted log returned %v, want %v", err, storage.ErrTreeDeleted)
+	}
+	if isListed(false) || !isListed(true) {
+		t.Errorf("Deleted tree listed=%v, listed with deleted=%v, want false, true", isListed(false), isListed(true))
+	}
+
+	if err := runAdmin(undelete); err != nil {
+		t.Fatalf("Failed to undelete tree: %v", err)
+	}
+	if err := runAdmin(undelete); err != storage.ErrTreeNotDeleted {
+		t.Errorf("UndeleteTree() on live tree returned %v, want %v", err, storage.ErrTreeNotDeleted)
+	}
+	snapshot, err = ls.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read undeleted log: %v", err)
+	}
+	if err := snapshot.Commit(); err != nil {
+		t.Fatalf("Failed to commit snapshot of undeleted log: %v", err)
+	}
+
+	if err := runAdmin(softDelete); err != nil {
+		t.Fatalf("Failed to delete tree: %v", err)
+	}
+	for _, want := range []error{nil, storage.ErrTreeNotFound} {
+		if err := runAdmin(hardDelete); err != want {
+			t.Errorf("HardDeleteTree()=%v, want %v", err, want)
+		}
+	}
+
+	rtx, err = as.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start admin snapshot: %v", err)
+	}
+	if _, err := rtx.GetTree(tree.TreeId); err != storage.ErrTreeNotFound {
+		t.Errorf("GetTree() after delete returned %v, want %v", err, storage.ErrTreeNotFound)
+	}
+	rtx.Commit()
+}
+
+// Explicit test for node id conversion to / from protos.
+func TestAdminTreeUsage(t *testing.T) {
+	as, err := NewAdminStorage("test:zaphod@tcp(127.0.0.1:3306)/test")
+	if err != nil {
+		t.Fatalf("Failed to open admin storage: %s", err)
+	}
+
+	atx, err := as.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin admin tx: %v", err)
+	}
+	tree, err := atx.CreateTree(&trillian.Tree{
+		TreeType:      trillian.TreeType_LOG,
+		KeyId:         []byte("TestAdminTreeUsage"),
+		HashAlgorithm: trillian.HashAlgorithm_SHA256,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	if err := atx.Commit(); err != nil {
+		t.Fatalf("Failed to commit admin tx: %v", err)
+	}
+
+	getUsage := func() *trillian.TreeUsage {
+		rtx, err := as.Snapshot(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to start admin snapshot: %v", err)
+		}
+		defer rtx.Commit()
+		usage, err := rtx.GetTreeUsage(tree.TreeId)
+		if err != nil {
+			t.Fatalf("Failed to read tree usage: %v", err)
+		}
+		return usage
+	}
+
+	if got, want := getUsage(), (&trillian.TreeUsage{TreeId: tree.TreeId}); !proto.Equal(got, want) {
+		t.Errorf("GetTreeUsage()=%v before any usage was added, want %v", got, want)
+	}
+
+	// Usage is added to the totals each time
+	for i := 0; i < 2; i++ {
+		atx, err := as.Begin(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to begin admin tx: %v", err)
+		}
+		if err := atx.AddTreeUsage(&trillian.TreeUsage{TreeId: tree.TreeId, Requests: 3, BytesIn: 100, BytesOut: 1000, LeavesQueued: 2, RecentQps: 1.5}); err != nil {
+			t.Fatalf("Failed to add tree usage: %v", err)
+		}
+		if err := atx.Commit(); err != nil {
+			t.Fatalf("Failed to commit admin tx: %v", err)
+		}
+	}
+
+	usage := getUsage()
+	if usage.UpdateTimeMillis == 0 {
+		t.Errorf("GetTreeUsage()=%v, want an update time", usage)
+	}
+	usage.UpdateTimeMillis = 0
+	if want := (&trillian.TreeUsage{TreeId: tree.TreeId, Requests: 6, BytesIn: 200, BytesOut: 2000, LeavesQueued: 4}); !proto.Equal(usage, want) {
+		t.Errorf("GetTreeUsage()=%v, want %v", usage, want)
+	}
+}
+
+func TestNodeIDSerialization(t *testing.T) {
+	nodeID := storage.NodeID{[]byte("hello"), 3, 40}
+	serializedBytes, err := encodeNodeID(nodeID)
+
+	if err != nil {
+		t.Fatalf("Failed to serialize NodeID: %v, %v", nodeID, err)
+	}
+
+	nodeID2, err := decodeNodeID(serializedBytes)
+
+	if err != nil {
+		t.Fatalf("Failed to deserialize NodeID: %v, %v", nodeID, err)
+	}
+
+	t.Logf("1:\n%v (%s)\n2:\n%v (%s)", nodeID, nodeID.String(), *nodeID2, nodeID2.String())
+
+	if expected, got := nodeID.String(), nodeID2.String(); expected != got {
+		t.Errorf("Round trip of nodeID failed: %v %v", expected, got)
+	}
+}
+
+func TestQueueDuplicateLeafReturnsExisting(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestQueueDuplicateLeafReturnsExisting")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	leaves := createTestLeaves(5, 10)
+
+	existing, err := tx.QueueLeaves(ctx, leaves)
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	for i, e := range existing {
+		if e != nil {
+			t.Fatalf("Leaf %d reported as a duplicate when first queued: %v", i, e)
+		}
+	}
+
+	// These have the same hashes as the leaves already queued
+	leaves2 := createTestLeaves(5, 12)
+
+	existing, err = tx.QueueLeaves(ctx, leaves2)
+
+	if err != nil {
+		t.Fatalf("Failed to queue duplicate leaves: %v", err)
+	}
+
+	if got, want := len(existing), len(leaves2); got != want {
+		t.Fatalf("Got %d results for duplicate leaves, want %d", got, want)
+	}
+
+	for i, e := range existing {
+		if e == nil {
+			t.Fatalf("Leaf %d was not reported as a duplicate", i)
+		}
+
+		if !bytes.Equal(e.LeafHash, leaves[i].LeafHash) || !bytes.Equal(e.LeafValue, leaves[i].LeafValue) {
+			t.Errorf("Got existing leaf %v for leaf %d, want %v", e, i, leaves[i])
+		}
+
+		// The existing leaves are still queued so they have no sequence number yet
+		if got, want := e.SequenceNumber, int64(-1); got != want {
+			t.Errorf("Got sequence number %d for queued duplicate, want %d", got, want)
+		}
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM Unsequenced WHERE TreeID=?", logID.logID.TreeID).Scan(&count); err != nil {
+		t.Fatalf("Could not query row count")
+	}
+
+	if count != len(leaves) {
+		t.Fatalf("Expected %d unsequenced rows but got: %d", len(leaves), count)
+	}
+}
+
+func TestQueueDuplicateLeafInBatchReturnsFirst(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestQueueDuplicateLeafInBatchReturnsFirst")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer failIfTXStillOpen(t, "TestQueueDuplicateLeafInBatchReturnsFirst", tx)
+
+	leaves := createTestLeaves(3, 10)
+	leaves = append(leaves, leaves[1])
+
+	existing, err := tx.QueueLeaves(ctx, leaves)
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	for i, e := range existing[:3] {
+		if e != nil {
+			t.Fatalf("Leaf %d reported as a duplicate when first queued: %v", i, e)
+		}
+	}
+
+	if e := existing[3]; e == nil || !bytes.Equal(e.LeafHash, leaves[1].LeafHash) || e.SequenceNumber != -1 {
+		t.Fatalf("Got existing leaf %v for repeat within the batch, want queued copy of %v", e, leaves[1])
+	}
+
+	commit(tx, t)
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM Unsequenced WHERE TreeID=?", logID.logID.TreeID).Scan(&count); err != nil {
+		t.Fatalf("Could not query row count")
+	}
+
+	if got, want := count, 3; got != want {
+		t.Fatalf("Expected %d unsequenced rows but got: %d", want, got)
+	}
+}
+
+func TestQueueDuplicateLeafAllowed(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestQueueDuplicateLeafAllowed")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE Trees SET AllowsDuplicateLeaves=1 WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to allow duplicates for test tree: %v", err)
+	}
+
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestQueueDuplicateLeafAllowed", tx)
+
+		leaves := createTestLeaves(1, 10)
+
+		for i := 0; i < 2; i++ {
+			existing, err := tx.QueueLeaves(ctx, leaves)
+
+			if err != nil {
+				t.Fatalf("Failed to queue leaves: %v", err)
+			}
+
+			if existing[0] != nil {
+				t.Fatalf("Leaf reported as a duplicate when duplicates are allowed: %v", existing[0])
+			}
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		// Both copies should be dequeued and removed from the queue
+		tx2 := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestQueueDuplicateLeafAllowed", tx2)
+
+		leaves2, err := tx2.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if got, want := len(leaves2), 2; got != want {
+			t.Fatalf("Dequeued %d leaves but expected to get %d", got, want)
+		}
+
+		commit(tx2, t)
+	}
+}
+
+func TestAddSequencedLeavesNotPreordered(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestAddSequencedLeavesNotPreordered")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	if _, err := tx.AddSequencedLeaves(ctx, createTestLeaves(1, 0)); err != storage.ErrNotPreordered {
+		t.Fatalf("AddSequencedLeaves()=%v for normal log, want %v", err, storage.ErrNotPreordered)
 	}
 }
 
-// Explicit test for node id conversion to / from protos.
-func TestNodeIDSerialization(t *testing.T) {
-	nodeID := storage.NodeID{[]byte("hello"), 3, 40}
-	serializedBytes, err := encodeNodeID(nodeID)
+func TestAddSequencedLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestAddSequencedLeaves")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
 
-	if err != nil {
-		t.Fatalf("Failed to serialize NodeID: %v, %v", nodeID, err)
+	if _, err := db.Exec("UPDATE Trees SET TreeType='PREORDERED_LOG' WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to make test tree pre-ordered: %v", err)
 	}
 
-	nodeID2, err := decodeNodeID(serializedBytes)
+	s := prepareTestLogStorage(logID, t)
+
+	if got, want := s.TreeType(), trillian.TreeType_PREORDERED_LOG; got != want {
+		t.Fatalf("Got tree type %v, want %v", got, want)
+	}
+
+	tx := beginLogTx(s, t)
+	defer failIfTXStillOpen(t, "TestAddSequencedLeaves", tx)
+
+	if _, err := tx.QueueLeaves(ctx, createTestLeaves(1, 0)); err != storage.ErrPreordered {
+		t.Fatalf("QueueLeaves()=%v for pre-ordered log, want %v", err, storage.ErrPreordered)
+	}
+
+	// Leave a gap at 2, integration should stop there
+	leaves := createTestLeaves(4, 0)
+	added := []trillian.LogLeaf{leaves[3], leaves[0], leaves[1]}
+
+	existing, err := tx.AddSequencedLeaves(ctx, added)
 
 	if err != nil {
-		t.Fatalf("Failed to deserialize NodeID: %v, %v", nodeID, err)
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
 	}
 
-	t.Logf("1:\n%v (%s)\n2:\n%v (%s)", nodeID, nodeID.String(), *nodeID2, nodeID2.String())
+	for i, e := range existing {
+		if e != nil {
+			t.Fatalf("Leaf %d reported as already present when first added: %v", i, e)
+		}
+	}
 
-	if expected, got := nodeID.String(), nodeID2.String(); expected != got {
-		t.Errorf("Round trip of nodeID failed: %v %v", expected, got)
+	// A different leaf at a position that's taken gets the existing leaf back
+	existing, err = tx.AddSequencedLeaves(ctx, []trillian.LogLeaf{{Leaf: leaves[2].Leaf, SignedEntryTimestamp: leaves[2].SignedEntryTimestamp, SequenceNumber: 1}})
+
+	if err != nil {
+		t.Fatalf("Failed to add sequenced leaves: %v", err)
 	}
-}
 
-func TestQueueDuplicateLeafFails(t *testing.T) {
-	logID := createLogID("TestQueueDuplicateLeafFails")
-	db := prepareTestLogDB(logID, t)
-	defer db.Close()
-	s := prepareTestLogStorage(logID, t)
-	tx := beginLogTx(s, t)
-	defer tx.Commit()
+	if existing[0] == nil || !bytes.Equal(existing[0].LeafHash, leaves[1].LeafHash) || existing[0].SequenceNumber != 1 {
+		t.Fatalf("Got existing leaf %v, want %v", existing[0], leaves[1])
+	}
 
-	leaves := createTestLeaves(5, 10)
+	dequeued, err := tx.DequeueLeaves(ctx, 99, time.Now().UnixNano())
 
-	if err := tx.QueueLeaves(leaves); err != nil {
-		t.Fatalf("Failed to queue leaves: %v", err)
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
 	}
 
-	leaves2 := createTestLeaves(5, 12)
+	if got, want := len(dequeued), 2; got != want {
+		t.Fatalf("Dequeued %d leaves but expected to get %d", got, want)
+	}
 
-	if err := tx.QueueLeaves(leaves2); err == nil {
-		t.Fatal("Allowed duplicate leaves to be inserted")
+	for i, leaf := range dequeued {
+		if got, want := leaf.SequenceNumber, int64(i); got != want {
+			t.Errorf("Dequeued leaf with sequence number %d, want %d", got, want)
+		}
 
-		if !strings.Contains(err.Error(), "Duplicate") {
-			t.Fatalf("Got the wrong type of error: %v", err)
+		if !bytes.Equal(leaf.LeafHash, leaves[i].LeafHash) {
+			t.Errorf("Dequeued leaf %v at position %d, want %v", leaf, i, leaves[i])
 		}
 	}
+
+	commit(tx, t)
 }
 
 func TestQueueLeavesMissingSignatureRejected(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestQueueLeavesMissingSignatureRejected")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -282,7 +984,7 @@ func TestQueueLeavesMissingSignatureRejected(t *testing.T) {
 	leaves := createTestLeaves(leavesToInsert, 1)
 	leaves[0].SignedEntryTimestamp.Signature = nil
 
-	if err := tx.QueueLeaves(leaves); err == nil {
+	if _, err := tx.QueueLeaves(ctx, leaves); err == nil {
 		t.Fatalf("Accepted a leaf with nil signature: %v", err)
 	}
 
@@ -290,6 +992,7 @@ func TestQueueLeavesMissingSignatureRejected(t *testing.T) {
 }
 
 func TestQueueLeaves(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestQueueLeaves")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -299,7 +1002,7 @@ func TestQueueLeaves(t *testing.T) {
 
 	leaves := createTestLeaves(leavesToInsert, 20)
 
-	if err := tx.QueueLeaves(leaves); err != nil {
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
 		t.Fatalf("Failed to queue leaves: %v", err)
 	}
 
@@ -318,7 +1021,56 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestQueueAndSequenceLeavesInSeveralInserts(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestQueueAndSequenceLeavesInSeveralInserts")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer failIfTXStillOpen(t, "TestQueueAndSequenceLeavesInSeveralInserts", tx)
+
+	// More leaves than fit in one multi-row insert
+	numLeaves := int64(maxRowsPerInsert*2 + 1)
+	leaves := createTestLeaves(numLeaves, 0)
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+		t.Fatalf("Failed to update sequenced leaves: %v", err)
+	}
+
+	sequenced, err := tx.GetLeavesByRange(ctx, 0, numLeaves)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves: %v", err)
+	}
+
+	if got, want := int64(len(sequenced)), numLeaves; got != want {
+		t.Fatalf("Got %d sequenced leaves, want %d", got, want)
+	}
+
+	for i, leaf := range sequenced {
+		checkLeafContents(leaf, int64(i), leaves[i].LeafHash, leaves[i].LeafValue, t)
+	}
+
+	commit(tx, t)
+
+	var count int64
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM Unsequenced WHERE TreeID=?", logID.logID.TreeID).Scan(&count); err != nil {
+		t.Fatalf("Could not query row count")
+	}
+
+	if count != numLeaves {
+		t.Fatalf("Expected %d unsequenced rows but got: %d", numLeaves, count)
+	}
+}
+
 func TestDequeueLeavesNoneQueued(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestDequeueLeavesNoneQueued")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -326,7 +1078,7 @@ func TestDequeueLeavesNoneQueued(t *testing.T) {
 	tx := beginLogTx(s, t)
 	defer tx.Commit()
 
-	leaves, err := tx.DequeueLeaves(999)
+	leaves, err := tx.DequeueLeaves(ctx, 999, time.Now().UnixNano())
 
 	if err != nil {
 		t.Fatalf("Didn't expect an error on dequeue with no work to be done: %v", err)
@@ -338,6 +1090,7 @@ func TestDequeueLeavesNoneQueued(t *testing.T) {
 }
 
 func TestDequeueLeaves(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestDequeueLeaves")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -349,7 +1102,7 @@ func TestDequeueLeaves(t *testing.T) {
 
 		leaves := createTestLeaves(leavesToInsert, 20)
 
-		if err := tx.QueueLeaves(leaves); err != nil {
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
 			t.Fatalf("Failed to queue leaves: %v", err)
 		}
 
@@ -360,7 +1113,7 @@ func TestDequeueLeaves(t *testing.T) {
 		// Now try to dequeue them
 		tx2 := beginLogTx(s, t)
 		defer failIfTXStillOpen(t, "TestDequeLeaves", tx2)
-		leaves2, err := tx2.DequeueLeaves(99)
+		leaves2, err := tx2.DequeueLeaves(ctx, 99, time.Now().UnixNano())
 
 		if err != nil {
 			t.Fatalf("Failed to dequeue leaves: %v", err)
@@ -380,107 +1133,358 @@ func TestDequeueLeaves(t *testing.T) {
 		tx3 := beginLogTx(s, t)
 		defer tx3.Rollback()
 
-		leaves3, err := tx3.DequeueLeaves(99)
+		leaves3, err := tx3.DequeueLeaves(ctx, 99, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves (second time): %v", err)
+		}
+
+		if len(leaves3) != 0 {
+			t.Fatalf("Dequeued %d leaves but expected to get none", len(leaves3))
+		}
+	}
+}
+
+func TestDequeueLeavesTwoBatches(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestDequeueLeavesTwoBatches")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	leavesToDequeue1 := 3
+	leavesToDequeue2 := 2
+
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches", tx)
+
+		leaves := createTestLeaves(leavesToInsert, 20)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	{
+		// Now try to dequeue some of them
+		tx2 := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx2", tx2)
+		leaves2, err := tx2.DequeueLeaves(ctx, leavesToDequeue1, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if len(leaves2) != leavesToDequeue1 {
+			t.Fatalf("Dequeued %d leaves but expected to get %d", len(leaves2), leavesToInsert)
+		}
+
+		ensureAllLeafHashesDistinct(leaves2, t)
+
+		tx2.Commit()
+
+		// Now try to dequeue the rest of them
+		tx3 := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx3", tx3)
+		leaves3, err := tx3.DequeueLeaves(ctx, leavesToDequeue2, time.Now().UnixNano())
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if len(leaves3) != leavesToDequeue2 {
+			t.Fatalf("Dequeued %d leaves but expected to get %d", len(leaves3), leavesToDequeue2)
+		}
+
+		ensureAllLeafHashesDistinct(leaves3, t)
+
+		// Plus the union of the leaf batches should all have distinct hashes
+		leaves4 := append(leaves2, leaves3...)
+		ensureAllLeafHashesDistinct(leaves4, t)
+
+		tx3.Commit()
+	}
+
+	{
+		// If we dequeue again then we should now get nothing
+		tx4 := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx4", tx4)
+
+		leaves5, err := tx4.DequeueLeaves(ctx, 99, time.Now().UnixNano())
 
 		if err != nil {
 			t.Fatalf("Failed to dequeue leaves (second time): %v", err)
 		}
 
-		if len(leaves3) != 0 {
-			t.Fatalf("Dequeued %d leaves but expected to get none", len(leaves3))
-		}
+		if len(leaves5) != 0 {
+			t.Fatalf("Dequeued %d leaves but expected to get none", len(leaves5))
+		}
+
+		tx4.Commit()
+	}
+}
+
+func TestDequeueLeavesByPriority(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestDequeueLeavesByPriority")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(4, 20)
+	for i, priority := range []int32{-1, 0, -1, 1} {
+		leaves[i].Priority = priority
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesByPriority", tx)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	dequeued, err := tx.DequeueLeaves(ctx, 2, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	// The leaves with the two highest priorities come first
+	if len(dequeued) != 2 || !bytes.Equal(dequeued[0].LeafHash, leaves[3].LeafHash) || !bytes.Equal(dequeued[1].LeafHash, leaves[1].LeafHash) {
+		t.Fatalf("Dequeued %v, want leaves 3 and 1", dequeued)
+	}
+}
+
+func TestDequeueLeavesNotBefore(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestDequeueLeavesNotBefore")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(3, 20)
+	leaves[0].NotBeforeNanos = 2000
+
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueLeavesNotBefore", tx)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	// The first leaf is held back until its time
+	dequeued, err := tx.DequeueLeaves(ctx, 10, 1000)
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	if len(dequeued) != 2 || bytes.Equal(dequeued[0].LeafHash, leaves[0].LeafHash) || bytes.Equal(dequeued[1].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("Dequeued %v at 1000, want leaves 1 and 2", dequeued)
+	}
+
+	dequeued, err = tx.DequeueLeaves(ctx, 10, 2000)
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	if len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("Dequeued %v at 2000, want leaf 0", dequeued)
+	}
+}
+
+func TestOldestQueuedLeaf(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestOldestQueuedLeaf")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	leaves := createTestLeaves(3, 20)
+	// The last leaf can't be integrated yet so it isn't counted
+	leaves[2].NotBeforeNanos = math.MaxInt64
+
+	// Queue times are only kept to the second
+	before := time.Now().Truncate(time.Second).UnixNano()
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestOldestQueuedLeaf", tx)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	oldest, err := tx.OldestQueuedLeafNanos(ctx, now)
+	if err != nil || oldest < before || oldest > now {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v, want a time from %d to %d", oldest, err, before, now)
+	}
+
+	dequeued, err := tx.DequeueLeaves(ctx, 10, now)
+	if err != nil || len(dequeued) != 2 || dequeued[0].QueueTimestampNanos != oldest {
+		t.Fatalf("DequeueLeaves()=%v %v, want 2 leaves queued at %d", dequeued, err, oldest)
+	}
+
+	// Leaves the transaction dequeued aren't counted
+	if last, err := tx.OldestQueuedLeafNanos(ctx, now); err != nil || last != 0 {
+		t.Fatalf("OldestQueuedLeafNanos()=%d %v with only a held back leaf queued, want 0", last, err)
+	}
+}
+
+func TestDequeueOverdueLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestDequeueOverdueLeaves")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	// There are more higher priority leaves queued since than the sequencer could take in
+	// by dequeuing as usual, however many batches it dequeued
+	const limit = 2
+	leaves := createTestLeaves(3+11*limit+1, 20)
+	for i := range leaves[3:] {
+		leaves[3+i].Priority = 1
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestDequeueOverdueLeaves", tx)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			t.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		commit(tx, t)
+	}
+
+	// The first leaves were queued 2, 3 and 4 hours ago
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("UPDATE Unsequenced SET QueueTimestamp=TIMESTAMPADD(HOUR, ?, QueueTimestamp) WHERE TreeId=? AND LeafHash=?", -(i + 2), logID.logID.TreeID, []byte(leaves[i].LeafHash)); err != nil {
+			t.Fatalf("Failed to backdate leaf %d: %v", i, err)
+		}
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	now := time.Now()
+	queuedBefore := now.Add(-time.Hour).UnixNano()
+	dequeued, err := tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now.UnixNano())
+	if err != nil || len(dequeued) != 2 || !bytes.Equal(dequeued[0].LeafHash, leaves[2].LeafHash) || !bytes.Equal(dequeued[1].LeafHash, leaves[1].LeafHash) {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v, want leaves 2 and 1", dequeued, err)
+	}
+
+	// Leaves the transaction dequeued aren't returned again
+	dequeued, err = tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now.UnixNano())
+	if err != nil || len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafHash, leaves[0].LeafHash) {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v, want leaf 0", dequeued, err)
+	}
+
+	if dequeued, err := tx.DequeueOverdueLeaves(ctx, limit, queuedBefore, now.UnixNano()); err != nil || len(dequeued) != 0 {
+		t.Fatalf("DequeueOverdueLeaves()=%v %v with only recent leaves queued, want none", dequeued, err)
 	}
 }
 
-func TestDequeueLeavesTwoBatches(t *testing.T) {
-	logID := createLogID("TestDequeueLeavesTwoBatches")
+func TestClaimLeaves(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestClaimLeaves")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
 	s := prepareTestLogStorage(logID, t)
 
-	leavesToDequeue1 := 3
-	leavesToDequeue2 := 2
-
 	{
 		tx := beginLogTx(s, t)
-		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches", tx)
-
-		leaves := createTestLeaves(leavesToInsert, 20)
+		defer failIfTXStillOpen(t, "TestClaimLeaves", tx)
 
-		if err := tx.QueueLeaves(leaves); err != nil {
+		if _, err := tx.QueueLeaves(ctx, createTestLeaves(leavesToInsert, 20)); err != nil {
 			t.Fatalf("Failed to queue leaves: %v", err)
 		}
 
 		commit(tx, t)
 	}
 
-	{
-		// Now try to dequeue some of them
-		tx2 := beginLogTx(s, t)
-		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx2", tx2)
-		leaves2, err := tx2.DequeueLeaves(leavesToDequeue1)
-
-		if err != nil {
-			t.Fatalf("Failed to dequeue leaves: %v", err)
-		}
-
-		if len(leaves2) != leavesToDequeue1 {
-			t.Fatalf("Dequeued %d leaves but expected to get %d", len(leaves2), leavesToInsert)
-		}
-
-		ensureAllLeafHashesDistinct(leaves2, t)
-
-		tx2.Commit()
-
-		// Now try to dequeue the rest of them
-		tx3 := beginLogTx(s, t)
-		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx3", tx3)
-		leaves3, err := tx3.DequeueLeaves(leavesToDequeue2)
+	claim := func(owner string, expiry, now int64, limit int) []trillian.LogLeaf {
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestClaimLeaves-"+owner, tx)
 
+		claimed, err := tx.ClaimLeaves(ctx, storage.LeafClaim{Owner: owner, ExpiryNanos: expiry}, now, limit)
 		if err != nil {
-			t.Fatalf("Failed to dequeue leaves: %v", err)
+			t.Fatalf("ClaimLeaves(%s) = %v", owner, err)
 		}
 
-		if len(leaves3) != leavesToDequeue2 {
-			t.Fatalf("Dequeued %d leaves but expected to get %d", len(leaves3), leavesToDequeue2)
-		}
+		commit(tx, t)
+		return claimed
+	}
 
-		ensureAllLeafHashesDistinct(leaves3, t)
+	a := claim("a", 200, 0, 3)
+	if len(a) != 3 {
+		t.Fatalf("Owner a claimed %d leaves, want 3", len(a))
+	}
 
-		// Plus the union of the leaf batches should all have distinct hashes
-		leaves4 := append(leaves2, leaves3...)
-		ensureAllLeafHashesDistinct(leaves4, t)
+	// Another owner only gets the leaves a doesn't hold
+	b := claim("b", 100, 0, 99)
+	if len(b) != leavesToInsert-3 {
+		t.Fatalf("Owner b claimed %d leaves, want %d", len(b), leavesToInsert-3)
+	}
+	ensureAllLeafHashesDistinct(append(a, b...), t)
 
-		tx3.Commit()
+	// Once b's claims expire c can take them, but not a's
+	if c := claim("c", 300, 150, 99); len(c) != len(b) {
+		t.Fatalf("Owner c claimed %d leaves, want the %d b held", len(c), len(b))
 	}
 
 	{
-		// If we dequeue again then we should now get nothing
-		tx4 := beginLogTx(s, t)
-		defer failIfTXStillOpen(t, "TestDequeueLeavesTwoBatches-tx4", tx4)
-
-		leaves5, err := tx4.DequeueLeaves(99)
+		tx := beginLogTx(s, t)
+		defer failIfTXStillOpen(t, "TestClaimLeaves-dequeue", tx)
 
-		if err != nil {
-			t.Fatalf("Failed to dequeue leaves (second time): %v", err)
+		if dequeued, err := tx.DequeueClaimedLeaves(ctx, "b", 99); err != nil || len(dequeued) != 0 {
+			t.Fatalf("DequeueClaimedLeaves(b) = %v %v, want nothing after c took the leaves", dequeued, err)
 		}
 
-		if len(leaves5) != 0 {
-			t.Fatalf("Dequeued %d leaves but expected to get none", len(leaves5))
+		dequeued, err := tx.DequeueClaimedLeaves(ctx, "a", 99)
+		if err != nil || len(dequeued) != 3 {
+			t.Fatalf("DequeueClaimedLeaves(a) = %v %v, want a's 3 leaves", dequeued, err)
 		}
 
-		tx4.Commit()
+		commit(tx, t)
+	}
+
+	// a's leaves are gone and c still holds the rest
+	if d := claim("d", 400, 200, 99); len(d) != 0 {
+		t.Fatalf("Owner d claimed %d leaves, want none", len(d))
 	}
 }
 
 func TestGetLeavesByHashNotPresent(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestGetLeavesByHashNotPresent")
 	s := prepareTestLogStorage(logID, t)
 	tx := beginLogTx(s, t)
 	defer tx.Commit()
 
 	hashes := []trillian.Hash{trillian.Hash("thisdoesn'texist")}
-	leaves, err := tx.GetLeavesByHash(hashes, false)
+	leaves, err := tx.GetLeavesByHash(ctx, hashes, false)
 
 	if err != nil {
 		t.Fatalf("Error getting leaves by hash: %v", err)
@@ -492,12 +1496,13 @@ func TestGetLeavesByHashNotPresent(t *testing.T) {
 }
 
 func TestGetLeavesByIndexNotPresent(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestGetLeavesByIndexNotPresent")
 	s := prepareTestLogStorage(logID, t)
 	tx := beginLogTx(s, t)
 	defer tx.Commit()
 
-	_, err := tx.GetLeavesByIndex([]int64{99999})
+	_, err := tx.GetLeavesByIndex(ctx, []int64{99999})
 
 	if err == nil {
 		t.Fatalf("Returned ok for leaf index when nothing inserted: %v", err)
@@ -505,6 +1510,7 @@ func TestGetLeavesByIndexNotPresent(t *testing.T) {
 }
 
 func TestGetLeavesByHash(t *testing.T) {
+	ctx := context.Background()
 	// Create fake leaf as if it had been sequenced
 	logID := createLogID("TestGetLeavesByHash")
 	db := prepareTestLogDB(logID, t)
@@ -525,7 +1531,7 @@ func TestGetLeavesByHash(t *testing.T) {
 	defer tx.Commit()
 
 	hashes := []trillian.Hash{dummyHash}
-	leaves, err := tx.GetLeavesByHash(hashes, false)
+	leaves, err := tx.GetLeavesByHash(ctx, hashes, false)
 
 	if err != nil {
 		t.Fatalf("Unexpected error getting leaf by hash: %v", err)
@@ -538,7 +1544,72 @@ func TestGetLeavesByHash(t *testing.T) {
 	checkLeafContents(leaves[0], sequenceNumber, dummyHash, data, t)
 }
 
+func TestGetLeavesByIdentityHash(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestGetLeavesByIdentityHash")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer failIfTXStillOpen(t, "TestGetLeavesByIdentityHash", tx)
+
+	// The first two leaves hold the same entry, the last has no identity hash
+	leaves := createTestLeaves(4, 0)
+	leaves[0].LeafIdentityHash = []byte("entry A")
+	leaves[1].LeafIdentityHash = []byte("entry A")
+	leaves[2].LeafIdentityHash = []byte("entry B")
+
+	if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+		t.Fatalf("Failed to update sequenced leaves: %v", err)
+	}
+
+	for _, test := range []struct {
+		identityHashes []trillian.Hash
+		want           []int64
+	}{
+		{[]trillian.Hash{[]byte("entry A")}, []int64{0, 1}},
+		{[]trillian.Hash{[]byte("entry B"), []byte("entry A")}, []int64{0, 1, 2}},
+		{[]trillian.Hash{[]byte("entry C")}, []int64{}},
+	} {
+		found, err := tx.GetLeavesByIdentityHash(ctx, test.identityHashes, true)
+
+		if err != nil {
+			t.Fatalf("Failed to get leaves by identity hashes %s: %v", test.identityHashes, err)
+		}
+
+		if len(found) != len(test.want) {
+			t.Errorf("Got %d leaves for identity hashes %s, expected %d", len(found), test.identityHashes, len(test.want))
+			continue
+		}
+
+		for i, leaf := range found {
+			checkLeafContents(leaf, test.want[i], leaves[test.want[i]].LeafHash, leaves[test.want[i]].LeafValue, t)
+
+			if got, want := string(leaf.LeafIdentityHash), string(leaves[test.want[i]].LeafIdentityHash); got != want {
+				t.Errorf("Leaf %d has identity hash %q, expected %q", leaf.SequenceNumber, got, want)
+			}
+		}
+	}
+
+	byIndex, err := tx.GetLeavesByIndex(ctx, []int64{3})
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves by index: %v", err)
+	}
+
+	if len(byIndex[0].LeafIdentityHash) != 0 {
+		t.Errorf("Leaf queued without an identity hash has %q", byIndex[0].LeafIdentityHash)
+	}
+
+	commit(tx, t)
+}
+
 func TestGetLeavesByIndex(t *testing.T) {
+	ctx := context.Background()
 	// Create fake leaf as if it had been sequenced
 	logID := createLogID("TestGetLeavesByIndex")
 	db := prepareTestLogDB(logID, t)
@@ -557,7 +1628,7 @@ func TestGetLeavesByIndex(t *testing.T) {
 	tx := beginLogTx(s, t)
 	defer tx.Commit()
 
-	leaves, err := tx.GetLeavesByIndex([]int64{sequenceNumber})
+	leaves, err := tx.GetLeavesByIndex(ctx, []int64{sequenceNumber})
 
 	if err != nil {
 		t.Fatalf("Unexpected error getting leaf by index: %v", err)
@@ -570,6 +1641,59 @@ func TestGetLeavesByIndex(t *testing.T) {
 	checkLeafContents(leaves[0], sequenceNumber, dummyHash, data, t)
 }
 
+func TestGetLeavesByRange(t *testing.T) {
+	ctx := context.Background()
+	// Create fake leaves as if they had been sequenced
+	logID := createLogID("TestGetLeavesByRange")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	signedTimestampBytes, err := EncodeSignedTimestamp(signedTimestamp)
+
+	if err != nil {
+		t.Fatalf("Failed to encode timestamp")
+	}
+
+	for seq := int64(0); seq < 5; seq++ {
+		hash := sha256.Sum256([]byte{byte(seq)})
+		createFakeLeaf(db, logID.logID, hash[:], []byte{byte(seq)}, signedTimestampBytes, seq, t)
+	}
+
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	for _, test := range []struct {
+		start, count, wantCount int64
+	}{
+		{0, 5, 5},
+		{1, 2, 2},
+		// Ranges are cut short where the sequenced leaves end
+		{3, 10, 2},
+		{5, 1, 0},
+	} {
+		leaves, err := tx.GetLeavesByRange(ctx, test.start, test.count)
+
+		if err != nil {
+			t.Fatalf("Unexpected error getting leaves by range %d+%d: %v", test.start, test.count, err)
+		}
+
+		if got, want := int64(len(leaves)), test.wantCount; got != want {
+			t.Fatalf("Got %d leaves for range %d+%d but expected %d", got, test.start, test.count, want)
+		}
+
+		for i, leaf := range leaves {
+			seq := test.start + int64(i)
+			hash := sha256.Sum256([]byte{byte(seq)})
+			checkLeafContents(leaf, seq, hash[:], []byte{byte(seq)}, t)
+		}
+	}
+
+	if _, err := tx.GetLeavesByRange(ctx, -1, 1); err == nil {
+		t.Fatalf("Returned ok for negative range start")
+	}
+}
+
 func openTestDBOrDie() *sql.DB {
 	db, err := sql.Open("mysql", "test:zaphod@tcp(127.0.0.1:3306)/test")
 	if err != nil {
@@ -580,6 +1704,7 @@ func openTestDBOrDie() *sql.DB {
 }
 
 func TestLatestSignedRootNoneWritten(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestLatestSignedLogRootNoneWritten")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -587,7 +1712,7 @@ func TestLatestSignedRootNoneWritten(t *testing.T) {
 	tx := beginLogTx(s, t)
 	defer tx.Rollback()
 
-	root, err := tx.LatestSignedLogRoot()
+	root, err := tx.LatestSignedLogRoot(ctx)
 
 	if err != nil {
 		t.Fatalf("Failed to read an empty log root: %v", err)
@@ -599,6 +1724,7 @@ func TestLatestSignedRootNoneWritten(t *testing.T) {
 }
 
 func TestLatestSignedLogRoot(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestLatestSignedLogRoot")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -607,9 +1733,9 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	defer tx.Rollback()
 
 	// TODO: Tidy up the log id as it looks silly chained 3 times like this
-	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}, Metadata: []byte("metadata")}
 
-	if err := tx.StoreSignedLogRoot(root); err != nil {
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
 		t.Fatalf("Failed to store signed root: %v", err)
 	}
 
@@ -620,7 +1746,7 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	{
 		tx2 := beginLogTx(s, t)
 		defer tx2.Rollback()
-		root2, err := tx2.LatestSignedLogRoot()
+		root2, err := tx2.LatestSignedLogRoot(ctx)
 
 		if err != nil {
 			t.Fatalf("Failed to read back new log root: %v", err)
@@ -633,6 +1759,7 @@ func TestLatestSignedLogRoot(t *testing.T) {
 }
 
 func TestGetTreeRevisionAtNonExistentSizeError(t *testing.T) {
+	ctx := context.Background()
 	// Have to set all this up though we won't actually write anything
 	logID := createLogID("TestGetTreeRevisionAtSize")
 	db := prepareTestLogDB(logID, t)
@@ -641,16 +1768,17 @@ func TestGetTreeRevisionAtNonExistentSizeError(t *testing.T) {
 	tx := beginLogTx(s, t)
 	defer tx.Commit()
 
-	if _, err := tx.GetTreeRevisionAtSize(0); err == nil {
+	if _, err := tx.GetTreeRevisionAtSize(ctx, 0); err == nil {
 		t.Fatalf("Returned a tree revision for 0 sized tree")
 	}
 
-	if _, err := tx.GetTreeRevisionAtSize(-427); err == nil {
+	if _, err := tx.GetTreeRevisionAtSize(ctx, -427); err == nil {
 		t.Fatalf("Returned a tree revision for -ve sized tree")
 	}
 }
 
 func TestGetTreeRevisionAtSize(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestGetTreeRevisionAtSize")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -663,11 +1791,11 @@ func TestGetTreeRevisionAtSize(t *testing.T) {
 		root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 		root2 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 198765, TreeSize: 27, TreeRevision: 11, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-		if err := tx.StoreSignedLogRoot(root); err != nil {
+		if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
 			t.Fatalf("Failed to store signed root: %v", err)
 		}
 
-		if err := tx.StoreSignedLogRoot(root2); err != nil {
+		if err := tx.StoreSignedLogRoot(ctx, root2); err != nil {
 			t.Fatalf("Failed to store signed root2: %v", err)
 		}
 
@@ -679,13 +1807,13 @@ func TestGetTreeRevisionAtSize(t *testing.T) {
 		defer tx.Commit()
 
 		// First two are legit tree head sizes and should work
-		treeRevision1, err := tx.GetTreeRevisionAtSize(16)
+		treeRevision1, err := tx.GetTreeRevisionAtSize(ctx, 16)
 
 		if err != nil {
 			t.Fatalf("Failed to get tree revision1: %v", err)
 		}
 
-		treeRevision2, err := tx.GetTreeRevisionAtSize(27)
+		treeRevision2, err := tx.GetTreeRevisionAtSize(ctx, 27)
 
 		if err != nil {
 			t.Fatalf("Failed to get tree revision2: %v", err)
@@ -696,7 +1824,7 @@ func TestGetTreeRevisionAtSize(t *testing.T) {
 		}
 
 		// But an intermediate value shouldn't work
-		treeRevision3, err := tx.GetTreeRevisionAtSize(21)
+		treeRevision3, err := tx.GetTreeRevisionAtSize(ctx, 21)
 
 		if err == nil {
 			t.Fatalf("Unexpectedly returned revision for nonexistent tree size: %d", treeRevision3)
@@ -704,7 +1832,83 @@ func TestGetTreeRevisionAtSize(t *testing.T) {
 	}
 }
 
+func TestSnapshotForTree(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestSnapshotForTree")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	{
+		tx := beginLogTx(s, t)
+
+		root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+		if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+
+		tx.Commit()
+	}
+
+	if _, err := s.SnapshotForTree(ctx, 21); err == nil {
+		t.Fatalf("Started snapshot for a tree size without a root")
+	}
+
+	snapshot, err := s.SnapshotForTree(ctx, 16)
+
+	if err != nil {
+		t.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	defer snapshot.Commit()
+
+	if got, want := snapshot.ReadRevision(), int64(5); got != want {
+		t.Fatalf("Snapshot pinned to revision %d, expected %d", got, want)
+	}
+
+	if got, want := snapshot.TreeSize(), int64(16); got != want {
+		t.Fatalf("Snapshot has tree size %d, expected %d", got, want)
+	}
+
+	// Sizes without a root are served from the first tree that has one and is at least as big
+	{
+		smaller, err := s.SnapshotForTree(ctx, 10)
+
+		if err != nil {
+			t.Fatalf("Failed to start snapshot for a tree size without a root: %v", err)
+		}
+
+		if rev, size := smaller.ReadRevision(), smaller.TreeSize(); rev != 5 || size != 16 {
+			t.Errorf("Snapshot for tree size 10 pinned to revision %d of size %d, expected revision 5 of size 16", rev, size)
+		}
+
+		smaller.Commit()
+	}
+
+	// Anything written while the snapshot is open mustn't be visible through it, and the
+	// writer mustn't have to wait for the snapshot to finish
+	{
+		tx := beginLogTx(s, t)
+
+		root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 198765, TreeSize: 27, TreeRevision: 11, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+		if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit while snapshot was open: %v", err)
+		}
+	}
+
+	if rev, err := snapshot.GetTreeRevisionAtSize(ctx, 27); err == nil {
+		t.Fatalf("Snapshot saw revision %d written after it started", rev)
+	}
+}
+
 func TestGetTreeRevisionMultipleSameSize(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestGetTreeRevisionAtSize")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -719,11 +1923,11 @@ func TestGetTreeRevisionMultipleSameSize(t *testing.T) {
 		root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 11, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 		root2 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 198765, TreeSize: 16, TreeRevision: 13, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-		if err := tx.StoreSignedLogRoot(root); err != nil {
+		if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
 			t.Fatalf("Failed to store signed root: %v", err)
 		}
 
-		if err := tx.StoreSignedLogRoot(root2); err != nil {
+		if err := tx.StoreSignedLogRoot(ctx, root2); err != nil {
 			t.Fatalf("Failed to store signed root2: %v", err)
 		}
 
@@ -735,7 +1939,7 @@ func TestGetTreeRevisionMultipleSameSize(t *testing.T) {
 		defer tx.Commit()
 
 		// We should get back the highest revision at size 16
-		treeRevision, err := tx.GetTreeRevisionAtSize(16)
+		treeRevision, err := tx.GetTreeRevisionAtSize(ctx, 16)
 
 		if err != nil {
 			t.Fatalf("Failed to get tree revision: %v", err)
@@ -748,6 +1952,7 @@ func TestGetTreeRevisionMultipleSameSize(t *testing.T) {
 }
 
 func TestDuplicateSignedLogRoot(t *testing.T) {
+	ctx := context.Background()
 	logID := createLogID("TestDuplicateSignedLogRoot")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
@@ -758,58 +1963,160 @@ func TestDuplicateSignedLogRoot(t *testing.T) {
 	// TODO: Tidy up the log id as it looks silly chained 3 times like this
 	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-	if err := tx.StoreSignedLogRoot(root); err != nil {
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	// Shouldn't be able to do it again
+	if err := tx.StoreSignedLogRoot(ctx, root); err == nil {
+		t.Fatalf("Allowed duplicate signed root")
+	}
+}
+
+func TestLogRootUpdate(t *testing.T) {
+	ctx := context.Background()
+	// Write two roots for a log and make sure the one with the newest timestamp supersedes
+	logID := createLogID("TestLatestSignedLogRoot")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	// TODO: Tidy up the log id as it looks silly chained 3 times like this
+	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	// TODO: Tidy up the log id as it looks silly chained 3 times like this
+	root2 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98766, TreeSize: 16, TreeRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	if err := tx.StoreSignedLogRoot(ctx, root2); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit new log roots: %v", err)
+	}
+
+	tx = beginLogTx(s, t)
+	root3, err := tx.LatestSignedLogRoot(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to read back new log root: %v", err)
+	}
+
+	if !proto.Equal(&root2, &root3) {
+		t.Fatalf("Root round trip failed: <%v> and: <%v>", root, root2)
+	}
+}
+
+func TestGetSignedLogRootNotFound(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestGetSignedLogRootNotFound")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	if _, err := tx.GetSignedLogRoot(ctx, 98765); err != storage.ErrLogRootNotFound {
+		t.Fatalf("Got %v reading a root that was never written, want %v", err, storage.ErrLogRootNotFound)
+	}
+}
+
+func TestCosignatures(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestCosignatures")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	// TODO: Tidy up the log id as it looks silly chained 3 times like this
+	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
 		t.Fatalf("Failed to store signed root: %v", err)
 	}
 
-	// Shouldn't be able to do it again
-	if err := tx.StoreSignedLogRoot(root); err == nil {
-		t.Fatalf("Allowed duplicate signed root")
+	witness2 := trillian.Cosignature{WitnessId: "witness2", Signature: &trillian.DigitallySigned{Signature: []byte("sig2")}}
+	witness1 := trillian.Cosignature{WitnessId: "witness1", Signature: &trillian.DigitallySigned{Signature: []byte("sig1")}}
+	// A witness signing again replaces its earlier cosignature
+	witness1Again := trillian.Cosignature{WitnessId: "witness1", Signature: &trillian.DigitallySigned{Signature: []byte("sig1 again")}}
+
+	for _, cosignature := range []trillian.Cosignature{witness2, witness1, witness1Again} {
+		if err := tx.AddCosignature(ctx, root.TimestampNanos, cosignature); err != nil {
+			t.Fatalf("Failed to add cosignature: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit cosignatures: %v", err)
+	}
+
+	{
+		tx2 := beginLogTx(s, t)
+		defer tx2.Rollback()
+
+		root2, err := tx2.GetSignedLogRoot(ctx, root.TimestampNanos)
+
+		if err != nil {
+			t.Fatalf("Failed to read back log root: %v", err)
+		}
+
+		if !proto.Equal(&root, &root2) {
+			t.Fatalf("Root round trip failed: <%v> and: <%v>", root, root2)
+		}
+
+		cosignatures, err := tx2.GetCosignatures(ctx, root.TimestampNanos)
+
+		if err != nil {
+			t.Fatalf("Failed to read cosignatures: %v", err)
+		}
+
+		want := []trillian.Cosignature{witness1Again, witness2}
+
+		if got := len(cosignatures); got != len(want) {
+			t.Fatalf("Got %d cosignatures, want %d", got, len(want))
+		}
+
+		for i := range want {
+			if !proto.Equal(&want[i], &cosignatures[i]) {
+				t.Errorf("Cosignature %d mismatch: <%v> and: <%v>", i, want[i], cosignatures[i])
+			}
+		}
+
+		// Other roots have no cosignatures
+		if cosignatures, err := tx2.GetCosignatures(ctx, root.TimestampNanos+1); err != nil || len(cosignatures) != 0 {
+			t.Fatalf("Got cosignatures %v, %v for a root that wasn't cosigned", cosignatures, err)
+		}
 	}
 }
 
-func TestLogRootUpdate(t *testing.T) {
-	// Write two roots for a log and make sure the one with the newest timestamp supersedes
-	logID := createLogID("TestLatestSignedLogRoot")
+func TestAddCosignatureNoRoot(t *testing.T) {
+	ctx := context.Background()
+	logID := createLogID("TestAddCosignatureNoRoot")
 	db := prepareTestLogDB(logID, t)
 	defer db.Close()
 	s := prepareTestLogStorage(logID, t)
 	tx := beginLogTx(s, t)
-	defer tx.Commit()
-
-	// TODO: Tidy up the log id as it looks silly chained 3 times like this
-	root := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
-
-	if err := tx.StoreSignedLogRoot(root); err != nil {
-		t.Fatalf("Failed to store signed root: %v", err)
-	}
-
-	// TODO: Tidy up the log id as it looks silly chained 3 times like this
-	root2 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98766, TreeSize: 16, TreeRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
-
-	if err := tx.StoreSignedLogRoot(root2); err != nil {
-		t.Fatalf("Failed to store signed root: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("Failed to commit new log roots: %v", err)
-	}
-
-	tx = beginLogTx(s, t)
-	root3, err := tx.LatestSignedLogRoot()
+	defer tx.Rollback()
 
-	if err != nil {
-		t.Fatalf("Failed to read back new log root: %v", err)
-	}
+	cosignature := trillian.Cosignature{WitnessId: "witness", Signature: &trillian.DigitallySigned{Signature: []byte("sig")}}
 
-	if !proto.Equal(&root2, &root3) {
-		t.Fatalf("Root round trip failed: <%v> and: <%v>", root, root2)
+	if err := tx.AddCosignature(ctx, 98765, cosignature); err == nil {
+		t.Fatalf("Allowed cosignature of a root that was never written")
 	}
 }
 
 // ---- MapStorage tests below:
 
 func TestLatestSignedMapRootNoneWritten(t *testing.T) {
+	ctx := context.Background()
 	mapID := createMapID("TestLatestSignedMapRootNoneWritten")
 	db := prepareTestMapDB(mapID, t)
 	defer db.Close()
@@ -817,7 +2124,7 @@ func TestLatestSignedMapRootNoneWritten(t *testing.T) {
 	tx := beginMapTx(s, t)
 	defer tx.Rollback()
 
-	root, err := tx.LatestSignedMapRoot()
+	root, err := tx.LatestSignedMapRoot(ctx)
 
 	if err != nil {
 		t.Fatalf("Failed to read an empty map root: %v", err)
@@ -829,6 +2136,7 @@ func TestLatestSignedMapRootNoneWritten(t *testing.T) {
 }
 
 func TestLatestSignedMapRoot(t *testing.T) {
+	ctx := context.Background()
 	mapID := createMapID("TestLatestSignedMapRoot")
 	db := prepareTestMapDB(mapID, t)
 	defer db.Close()
@@ -839,7 +2147,7 @@ func TestLatestSignedMapRoot(t *testing.T) {
 	// TODO: Tidy up the map id as it looks silly chained 3 times like this
 	root := trillian.SignedMapRoot{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-	if err := tx.StoreSignedMapRoot(root); err != nil {
+	if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
 		t.Fatalf("Failed to store signed root: %v", err)
 	}
 
@@ -850,7 +2158,7 @@ func TestLatestSignedMapRoot(t *testing.T) {
 	{
 		tx2 := beginMapTx(s, t)
 		defer tx2.Rollback()
-		root2, err := tx2.LatestSignedMapRoot()
+		root2, err := tx2.LatestSignedMapRoot(ctx)
 
 		if err != nil {
 			t.Fatalf("Failed to read back new map root: %v", err)
@@ -863,6 +2171,7 @@ func TestLatestSignedMapRoot(t *testing.T) {
 }
 
 func TestDuplicateSignedMapRoot(t *testing.T) {
+	ctx := context.Background()
 	mapID := createMapID("TestDuplicateSignedMapRoot")
 	db := prepareTestMapDB(mapID, t)
 	defer db.Close()
@@ -873,17 +2182,18 @@ func TestDuplicateSignedMapRoot(t *testing.T) {
 	// TODO: Tidy up the map id as it looks silly chained 3 times like this
 	root := trillian.SignedMapRoot{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-	if err := tx.StoreSignedMapRoot(root); err != nil {
+	if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
 		t.Fatalf("Failed to store signed map root: %v", err)
 	}
 
 	// Shouldn't be able to do it again
-	if err := tx.StoreSignedMapRoot(root); err == nil {
+	if err := tx.StoreSignedMapRoot(ctx, root); err == nil {
 		t.Fatalf("Allowed duplicate signed map root")
 	}
 }
 
 func TestMapRootUpdate(t *testing.T) {
+	ctx := context.Background()
 	// Write two roots for a map and make sure the one with the newest timestamp supersedes
 	mapID := createMapID("TestLatestSignedMapRoot")
 	db := prepareTestMapDB(mapID, t)
@@ -895,14 +2205,14 @@ func TestMapRootUpdate(t *testing.T) {
 	// TODO: Tidy up the map id as it looks silly chained 3 times like this
 	root := trillian.SignedMapRoot{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-	if err := tx.StoreSignedMapRoot(root); err != nil {
+	if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
 		t.Fatalf("Failed to store signed map root: %v", err)
 	}
 
 	// TODO: Tidy up the map id as it looks silly chained 3 times like this
 	root2 := trillian.SignedMapRoot{MapId: mapID.mapID.MapID, TimestampNanos: 98766, MapRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
 
-	if err := tx.StoreSignedMapRoot(root2); err != nil {
+	if err := tx.StoreSignedMapRoot(ctx, root2); err != nil {
 		t.Fatalf("Failed to store signed map root: %v", err)
 	}
 
@@ -911,7 +2221,7 @@ func TestMapRootUpdate(t *testing.T) {
 	}
 
 	tx = beginMapTx(s, t)
-	root3, err := tx.LatestSignedMapRoot()
+	root3, err := tx.LatestSignedMapRoot(ctx)
 
 	if err != nil {
 		t.Fatalf("Failed to read back new map root: %v", err)
@@ -922,6 +2232,100 @@ func TestMapRootUpdate(t *testing.T) {
 	}
 }
 
+func TestGetSignedMapRootByRevision(t *testing.T) {
+	ctx := context.Background()
+	mapID := createMapID("TestGetSignedMapRootByRevision")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+	tx := beginMapTx(s, t)
+
+	roots := []trillian.SignedMapRoot{
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}},
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98766, MapRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty2")}},
+	}
+
+	for _, root := range roots {
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed map root: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit new map roots: %v", err)
+	}
+
+	tx = beginMapTx(s, t)
+	defer tx.Commit()
+
+	for _, root := range roots {
+		got, err := tx.GetSignedMapRoot(ctx, root.MapRevision)
+
+		if err != nil {
+			t.Fatalf("Failed to read map root at revision %d: %v", root.MapRevision, err)
+		}
+
+		if !proto.Equal(&root, &got) {
+			t.Fatalf("Read back map root %v at revision %d but expected %v", got, root.MapRevision, root)
+		}
+	}
+
+	if _, err := tx.GetSignedMapRoot(ctx, 7); err != storage.ErrMapRootNotFound {
+		t.Fatalf("Expected %v for a revision without a root, got: %v", storage.ErrMapRootNotFound, err)
+	}
+}
+
+func TestMapSnapshotForTree(t *testing.T) {
+	ctx := context.Background()
+	mapID := createMapID("TestMapSnapshotForTree")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+	tx := beginMapTx(s, t)
+
+	roots := []trillian.SignedMapRoot{
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}},
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98766, MapRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty2")}},
+	}
+
+	for _, root := range roots {
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed map root: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit new map roots: %v", err)
+	}
+
+	for _, test := range []struct {
+		revision int64
+		want     int64
+	}{
+		{revision: 5, want: 5},
+		{revision: 6, want: 6},
+		{revision: -1, want: 6},
+	} {
+		snapshot, err := s.SnapshotForTree(ctx, test.revision)
+
+		if err != nil {
+			t.Fatalf("Failed to start snapshot at revision %d: %v", test.revision, err)
+		}
+
+		if got := snapshot.ReadRevision(); got != test.want {
+			t.Errorf("Snapshot for revision %d pinned to revision %d, expected %d", test.revision, got, test.want)
+		}
+
+		if err := snapshot.Commit(); err != nil {
+			t.Fatalf("Failed to commit snapshot: %v", err)
+		}
+	}
+
+	if _, err := s.SnapshotForTree(ctx, 7); err != storage.ErrMapRootNotFound {
+		t.Fatalf("Expected %v for a snapshot at a revision without a root, got: %v", storage.ErrMapRootNotFound, err)
+	}
+}
+
 var keyHash = trillian.Hash([]byte("A Key Hash"))
 var mapLeaf = trillian.MapLeaf{
 	KeyHash:   keyHash,
@@ -931,6 +2335,7 @@ var mapLeaf = trillian.MapLeaf{
 }
 
 func TestMapSetGetRoundTrip(t *testing.T) {
+	ctx := context.Background()
 	cleanTestDB()
 
 	mapID := createMapID("TestMapSetGetRoundTrip")
@@ -943,7 +2348,7 @@ func TestMapSetGetRoundTrip(t *testing.T) {
 	{
 		tx := beginMapTx(s, t)
 
-		if err := tx.Set(keyHash, mapLeaf); err != nil {
+		if err := tx.Set(ctx, keyHash, mapLeaf); err != nil {
 			t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
 		}
 		if err := tx.Commit(); err != nil {
@@ -954,7 +2359,7 @@ func TestMapSetGetRoundTrip(t *testing.T) {
 	{
 		tx := beginMapTx(s, t)
 
-		readValues, err := tx.Get(readRev, []trillian.Hash{keyHash})
+		readValues, err := tx.Get(ctx, readRev, []trillian.Hash{keyHash})
 		if err != nil {
 			t.Fatalf("Failed to get %v:  %v", keyHash, err)
 		}
@@ -971,6 +2376,7 @@ func TestMapSetGetRoundTrip(t *testing.T) {
 }
 
 func TestMapSetSameKeyInSameRevisionFails(t *testing.T) {
+	ctx := context.Background()
 	cleanTestDB()
 
 	mapID := createMapID("TestMapSetSameKeyInSameRevisionFails")
@@ -981,7 +2387,7 @@ func TestMapSetSameKeyInSameRevisionFails(t *testing.T) {
 	{
 		tx := beginMapTx(s, t)
 
-		if err := tx.Set(keyHash, mapLeaf); err != nil {
+		if err := tx.Set(ctx, keyHash, mapLeaf); err != nil {
 			t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
 		}
 		if err := tx.Commit(); err != nil {
@@ -992,7 +2398,7 @@ func TestMapSetSameKeyInSameRevisionFails(t *testing.T) {
 	{
 		tx := beginMapTx(s, t)
 
-		if err := tx.Set(keyHash, mapLeaf); err == nil {
+		if err := tx.Set(ctx, keyHash, mapLeaf); err == nil {
 			t.Fatalf("Unexpectedly succeeded in setting %v to %v", keyHash, mapLeaf)
 		}
 		if err := tx.Commit(); err != nil {
@@ -1002,6 +2408,7 @@ func TestMapSetSameKeyInSameRevisionFails(t *testing.T) {
 }
 
 func TestMapGetUnknownKey(t *testing.T) {
+	ctx := context.Background()
 	cleanTestDB()
 
 	mapID := createMapID("TestMapGetUnknownKey")
@@ -1012,7 +2419,7 @@ func TestMapGetUnknownKey(t *testing.T) {
 	{
 		tx := beginMapTx(s, t)
 
-		readValues, err := tx.Get(1, []trillian.Hash{[]byte("This doesn't exist.")})
+		readValues, err := tx.Get(ctx, 1, []trillian.Hash{[]byte("This doesn't exist.")})
 		if err != nil {
 			t.Fatalf("Read returned error %v", err)
 		}
@@ -1026,6 +2433,7 @@ func TestMapGetUnknownKey(t *testing.T) {
 }
 
 func TestMapSetGetMultipleRevisions(t *testing.T) {
+	ctx := context.Background()
 	cleanTestDB()
 
 	// Write two roots for a map and make sure the one with the newest timestamp supersedes
@@ -1049,7 +2457,7 @@ func TestMapSetGetMultipleRevisions(t *testing.T) {
 		tx := beginMapTx(s, t)
 		mysqlMapTX := tx.(*mapTX)
 		mysqlMapTX.treeTX.writeRevision = int64(i)
-		if err := tx.Set(keyHash, values[i]); err != nil {
+		if err := tx.Set(ctx, keyHash, values[i]); err != nil {
 			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[i], err)
 		}
 		if err := tx.Commit(); err != nil {
@@ -1060,7 +2468,7 @@ func TestMapSetGetMultipleRevisions(t *testing.T) {
 	for i := 0; i < numRevs; i++ {
 		tx := beginMapTx(s, t)
 
-		readValues, err := tx.Get(int64(i), []trillian.Hash{keyHash})
+		readValues, err := tx.Get(ctx, int64(i), []trillian.Hash{keyHash})
 		if err != nil {
 			t.Fatalf("At rev %d failed to get %v:  %v", i, keyHash, err)
 		}
@@ -1076,7 +2484,322 @@ func TestMapSetGetMultipleRevisions(t *testing.T) {
 	}
 }
 
+func TestMapGetBetweenRevisions(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB()
+
+	mapID := createMapID("TestMapGetBetweenRevisions")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+
+	// The key is only written at even revisions
+	values := make(map[int64]trillian.MapLeaf)
+	for _, rev := range []int64{0, 2} {
+		values[rev] = trillian.MapLeaf{
+			KeyHash:   keyHash,
+			LeafHash:  []byte(fmt.Sprintf("A Hash %d", rev)),
+			LeafValue: []byte(fmt.Sprintf("A Value %d", rev)),
+		}
+
+		tx := beginMapTx(s, t)
+		tx.(*mapTX).treeTX.writeRevision = rev
+		if err := tx.Set(ctx, keyHash, values[rev]); err != nil {
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[rev], err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	// Reads should see the value from the most recent write at or before the revision
+	for readRev, wantRev := range map[int64]int64{0: 0, 1: 0, 2: 2, 3: 2} {
+		tx := beginMapTx(s, t)
+
+		readValues, err := tx.Get(ctx, readRev, []trillian.Hash{keyHash})
+		if err != nil {
+			t.Fatalf("At rev %d failed to get %v:  %v", readRev, keyHash, err)
+		}
+		if got, want := len(readValues), 1; got != want {
+			t.Fatalf("At rev %d got %d values, expected %d", readRev, got, want)
+		}
+		if got, want := readValues[0], values[wantRev]; !proto.Equal(&got, &want) {
+			t.Fatalf("At rev %d read back %v, but expected %v", readRev, got, want)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("At rev %d failed to commit: %v", readRev, err)
+		}
+	}
+}
+
+func TestMapGetHistory(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB()
+
+	mapID := createMapID("TestMapGetHistory")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+
+	values := make(map[int64]trillian.MapLeaf)
+	for _, rev := range []int64{1, 3, 4} {
+		values[rev] = trillian.MapLeaf{
+			KeyHash:   keyHash,
+			LeafHash:  []byte(fmt.Sprintf("A Hash %d", rev)),
+			LeafValue: []byte(fmt.Sprintf("A Value %d", rev)),
+		}
+
+		tx := beginMapTx(s, t)
+		tx.(*mapTX).treeTX.writeRevision = rev
+		if err := tx.Set(ctx, keyHash, values[rev]); err != nil {
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[rev], err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	// Reads of the whole range and a part of it
+	for _, test := range []struct {
+		startRev, endRev int64
+		wantRevs         []int64
+	}{
+		{0, 10, []int64{1, 3, 4}},
+		{2, 3, []int64{3}},
+		{5, 10, []int64{}},
+	} {
+		tx := beginMapTx(s, t)
+
+		history, err := tx.GetHistory(ctx, keyHash, test.startRev, test.endRev)
+		if err != nil {
+			t.Fatalf("Failed to get history of %v for revisions %d to %d: %v", keyHash, test.startRev, test.endRev, err)
+		}
+		if got, want := len(history), len(test.wantRevs); got != want {
+			t.Fatalf("Got %d history entries for revisions %d to %d, expected %d", got, test.startRev, test.endRev, want)
+		}
+		for i, h := range history {
+			if got, want := h.Revision, test.wantRevs[i]; got != want {
+				t.Fatalf("Got revision %d for entry %d, expected %d", got, i, want)
+			}
+			if got, want := h.Leaf, values[h.Revision]; !proto.Equal(&got, &want) {
+				t.Fatalf("At rev %d read back %v, but expected %v", h.Revision, got, want)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+}
+
+func TestMapMutations(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB()
+
+	mapID := createMapID("TestMapMutations")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+
+	mutations := make(map[int64]trillian.MapMutation)
+	for _, rev := range []int64{1, 3, 4} {
+		mutations[rev] = trillian.MapMutation{
+			MapRevision: rev,
+			KeyValue:    []*trillian.KeyValue{{Key: []byte(fmt.Sprintf("key %d", rev)), Value: &trillian.MapLeaf{LeafValue: []byte(fmt.Sprintf("A Value %d", rev))}}},
+		}
+
+		tx := beginMapTx(s, t)
+		tx.(*mapTX).treeTX.writeRevision = rev
+		if err := tx.StoreMutation(ctx, mutations[rev]); err != nil {
+			t.Fatalf("Failed to store mutation at revision %d: %v", rev, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	// There can only be one mutation for each revision
+	tx := beginMapTx(s, t)
+	if err := tx.StoreMutation(ctx, mutations[3]); err == nil {
+		t.Fatal("Incorrectly stored a second mutation for revision 3")
+	}
+	tx.Rollback()
+
+	for _, test := range []struct {
+		startRev int64
+		count    int
+		wantRevs []int64
+	}{
+		{0, 10, []int64{1, 3, 4}},
+		{2, 1, []int64{3}},
+		{5, 10, []int64{}},
+	} {
+		tx := beginMapTx(s, t)
+
+		got, err := tx.GetMutations(ctx, test.startRev, test.count)
+		if err != nil {
+			t.Fatalf("Failed to get %d mutations from revision %d: %v", test.count, test.startRev, err)
+		}
+		if got, want := len(got), len(test.wantRevs); got != want {
+			t.Fatalf("Got %d mutations from revision %d, expected %d", got, test.startRev, want)
+		}
+		for i, mutation := range got {
+			if got, want := mutation.MapRevision, test.wantRevs[i]; got != want {
+				t.Fatalf("Got revision %d for mutation %d, expected %d", got, i, want)
+			}
+			if want := mutations[mutation.MapRevision]; !proto.Equal(&mutation, &want) {
+				t.Fatalf("At rev %d read back %v, but expected %v", mutation.MapRevision, mutation, want)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB()
+
+	mapID := createMapID("TestMapKeys")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+
+	// Storing a key that's already there does nothing
+	tx := beginMapTx(s, t)
+	for _, key := range []string{"b/2", "a", "b/1", "c", "b/1", "b"} {
+		if err := tx.StoreKey(ctx, trillian.Key(key)); err != nil {
+			t.Fatalf("Failed to store key %q: %v", key, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	for _, test := range []struct {
+		start, end trillian.Key
+		count      int
+		want       []string
+	}{
+		{trillian.Key{}, nil, 10, []string{"a", "b", "b/1", "b/2", "c"}},
+		{trillian.Key("b/"), trillian.Key("b0"), 10, []string{"b/1", "b/2"}},
+		{trillian.Key("b"), nil, 2, []string{"b", "b/1"}},
+		{trillian.Key("b/2"), trillian.Key("c"), 10, []string{"b/2"}},
+		{trillian.Key("d"), nil, 10, []string{}},
+	} {
+		tx := beginMapTx(s, t)
+
+		got, err := tx.GetKeys(ctx, test.start, test.end, test.count)
+		if err != nil {
+			t.Fatalf("Failed to get %d keys from %q to %q: %v", test.count, test.start, test.end, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("Got %d keys from %q to %q, expected %d", len(got), test.start, test.end, len(test.want))
+		}
+		for i, key := range got {
+			if string(key) != test.want[i] {
+				t.Errorf("Got key %q at %d from %q to %q, expected %q", key, i, test.start, test.end, test.want[i])
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+}
+
+// The migration test upgrades a database of its own, as it drops every table first. Like the
+// test database it must already exist.
+const migrateTestDSN = "test:zaphod@tcp(127.0.0.1:3306)/test_migrate"
+
+// Tables in an order they can be dropped in without breaking foreign keys
+var dropTables = []string{"Unsequenced", "Subtree", "SequencedLeafData", "Cosignature", "SequencerCheckpoint", "TreeHead", "LeafData", "MapMutation", "MapKey", "MapLeaf", "MapHead", "TreeControl", "TreeUsage", "SubtreeShard", "Trees", "SchemaVersion"}
+
+// schemaOf describes the columns and indexes of every table in the database called schema,
+// one line for each, in an order that doesn't depend on the order they were added in
+func schemaOf(db *sql.DB, schema string, t *testing.T) []string {
+	var lines []string
+
+	for _, query := range []string{
+		`SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COALESCE(COLUMN_DEFAULT, 'NULL')
+		 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME, COLUMN_NAME`,
+		`SELECT TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX, COLUMN_NAME, NON_UNIQUE
+		 FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`,
+	} {
+		rows, err := db.Query(query, schema)
+		if err != nil {
+			t.Fatalf("Failed to read schema of %s: %v", schema, err)
+		}
+
+		for rows.Next() {
+			var a, b, c, d, e string
+			if err := rows.Scan(&a, &b, &c, &d, &e); err != nil {
+				t.Fatalf("Failed to read schema of %s: %v", schema, err)
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s %s %s", a, b, c, d, e))
+		}
+		rows.Close()
+	}
+
+	return lines
+}
+
+func TestMigrateFromUnversionedSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("mysql", migrateTestDSN)
+	if err != nil {
+		t.Fatalf("Failed to open migration test database: %v", err)
+	}
+	defer db.Close()
+
+	for _, table := range dropTables {
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table)); err != nil {
+			t.Fatalf("Failed to drop %s: %v", table, err)
+		}
+	}
+
+	// Create the tables storage.sql did before the schema was versioned, which has no
+	// SchemaVersion table, and a tree with a sequenced leaf as they were written then
+	for _, statement := range migrations[0].Statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to create unversioned schema: %v", err)
+		}
+	}
+
+	for _, statement := range []string{
+		`INSERT INTO Trees(TreeId, KeyId, TreeType, LeafHasherType, TreeHasherType) VALUES(1, "key", "LOG", "SHA256", "SHA256")`,
+		`INSERT INTO LeafData(TreeId, LeafHash, TheData) VALUES(1, "hash", "data")`,
+		`INSERT INTO SequencedLeafData(TreeId, SequenceNumber, LeafHash, SignedEntryTimestamp) VALUES(1, 0, "hash", "sig")`,
+		`INSERT INTO TreeHead(TreeId, TreeHeadTimestamp, TreeSize, RootHash, RootSignature, TreeRevision) VALUES(1, 1, 1, "root", "sig", 0)`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to write unversioned tree: %v", err)
+		}
+	}
+
+	applied, err := migrate.Run(ctx, migrate.NewSQLDatabase(db, migrateDialect), migrations)
+	if err != nil || applied != len(migrations) {
+		t.Fatalf("Got %d applied and error %v, want %d applied", applied, err, len(migrations))
+	}
+
+	// The tree is kept, with the defaults of the columns added since
+	var treeState string
+	var leaves int
+	if err := db.QueryRow("SELECT TreeState FROM Trees WHERE TreeId = 1").Scan(&treeState); err != nil || treeState != "ACTIVE" {
+		t.Errorf("Got tree state %q and error %v after migrating, want ACTIVE", treeState, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId = 1").Scan(&leaves); err != nil || leaves != 1 {
+		t.Errorf("Got %d leaves and error %v after migrating, want 1", leaves, err)
+	}
+
+	// The schema is the same as the test database's, which was created by storage.sql
+	got, want := schemaOf(db, "test_migrate", t), schemaOf(db, "test", t)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Migrated schema differs from storage.sql:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
 func TestGetActiveLogIDs(t *testing.T) {
+	ctx := context.Background()
 	// Have to wipe everything to ensure we start with zero log trees configured
 	cleanTestDB()
 
@@ -1088,7 +2811,7 @@ func TestGetActiveLogIDs(t *testing.T) {
 	s := prepareTestLogStorage(logID, t)
 	tx := beginLogTx(s, t)
 
-	logIDs, err := tx.GetActiveLogIDs()
+	logIDs, err := tx.GetActiveLogIDs(ctx)
 
 	if err != nil {
 		t.Fatalf("Failed to get log ids: %v", err)
@@ -1100,6 +2823,7 @@ func TestGetActiveLogIDs(t *testing.T) {
 }
 
 func TestGetActiveLogIDsWithPendingWork(t *testing.T) {
+	ctx := context.Background()
 	// Have to wipe everything to ensure we start with zero log trees configured
 	cleanTestDB()
 	logID := createLogID("TestGetActiveLogIDsWithPendingWork")
@@ -1109,7 +2833,7 @@ func TestGetActiveLogIDsWithPendingWork(t *testing.T) {
 	s := prepareTestLogStorage(logID, t)
 	tx := beginLogTx(s, t)
 
-	logIDs, err := tx.GetActiveLogIDsWithPendingWork()
+	logIDs, err := tx.GetActiveLogIDsWithPendingWork(ctx)
 	tx.Commit()
 
 	if err != nil || len(logIDs) != 0 {
@@ -1122,7 +2846,7 @@ func TestGetActiveLogIDsWithPendingWork(t *testing.T) {
 
 		leaves := createTestLeaves(leavesToInsert, 2)
 
-		if err := tx.QueueLeaves(leaves); err != nil {
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
 			t.Fatalf("Failed to queue leaves: %v", err)
 		}
 
@@ -1132,7 +2856,7 @@ func TestGetActiveLogIDsWithPendingWork(t *testing.T) {
 	// We should now see the logID that we just created work for
 	tx = beginLogTx(s, t)
 
-	logIDs, err = tx.GetActiveLogIDsWithPendingWork()
+	logIDs, err = tx.GetActiveLogIDsWithPendingWork(ctx)
 	tx.Commit()
 
 	if err != nil || len(logIDs) != 1 {
@@ -1167,7 +2891,7 @@ func createTestDB() {
 	}
 }
 
-func prepareTestLogStorage(logID logIDAndTest, t *testing.T) storage.LogStorage {
+func prepareTestLogStorage(logID logIDAndTest, t testing.TB) storage.LogStorage {
 	s, err := NewLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test")
 	if err != nil {
 		t.Fatalf("Failed to open log storage: %s", err)
@@ -1189,7 +2913,7 @@ func prepareTestMapStorage(mapID mapIDAndTest, t *testing.T) storage.MapStorage
 // predictable environment. For obvious reasons this should only be allowed to run
 // against test databases. This method panics if any of the deletions fails to make
 // sure tests can't inadvertently succeed.
-func prepareTestTreeDB(treeID int64, t *testing.T) *sql.DB {
+func prepareTestTreeDB(treeID int64, t testing.TB) *sql.DB {
 	db := openTestDBOrDie()
 
 	// Wipe out anything that was there for this tree id
@@ -1207,7 +2931,7 @@ func prepareTestTreeDB(treeID int64, t *testing.T) *sql.DB {
 // predictable environment. For obvious reasons this should only be allowed to run
 // against test databases. This method panics if any of the deletions fails to make
 // sure tests can't inadvertently succeed.
-func prepareTestLogDB(logID logIDAndTest, t *testing.T) *sql.DB {
+func prepareTestLogDB(logID logIDAndTest, t testing.TB) *sql.DB {
 	db := prepareTestTreeDB(logID.logID.TreeID, t)
 
 	// Now put back the tree row for this log id
@@ -1262,7 +2986,7 @@ func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
 	for l := int64(0); l < n; l++ {
 		lv := fmt.Sprintf("Leaf %d", l)
 		leaf := trillian.LogLeaf{trillian.Leaf{
-			hasher.Digest([]byte(lv)), []byte(lv), []byte(fmt.Sprintf("Extra %d", l))}, signedTimestamp, int64(startSeq + l)}
+			hasher.Digest([]byte(lv)), []byte(lv), []byte(fmt.Sprintf("Extra %d", l)), nil}, signedTimestamp, int64(startSeq + l)}
 		leaves = append(leaves, leaf)
 	}
 
@@ -1270,14 +2994,14 @@ func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
 }
 
 // Convenience methods to avoid copying out "if err != nil { blah }" all over the place
-func commit(tx storage.LogTX, t *testing.T) {
+func commit(tx storage.LogTX, t testing.TB) {
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("Failed to commit tx: %v", err)
 	}
 }
 
-func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
-	tx, err := s.Begin()
+func beginLogTx(s storage.LogStorage, t testing.TB) storage.LogTX {
+	tx, err := s.Begin(context.Background())
 
 	if err != nil {
 		t.Fatalf("Failed to begin log tx: %v", err)
@@ -1287,7 +3011,7 @@ func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
 }
 
 func beginMapTx(s storage.MapStorage, t *testing.T) storage.MapTX {
-	tx, err := s.Begin()
+	tx, err := s.Begin(context.Background())
 
 	if err != nil {
 		t.Fatalf("Failed to begin map tx: %v", err)
@@ -1314,3 +3038,64 @@ func TestMain(m *testing.M) {
 	createTestDB()
 	os.Exit(m.Run())
 }
+
+// createBenchmarkLeaves creates n leaves whose hashes differ from those made for every other
+// value of batch
+func createBenchmarkLeaves(n, batch int) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0, n)
+	hasher := trillian.NewSHA256()
+
+	for l := 0; l < n; l++ {
+		lv := fmt.Sprintf("Leaf %d/%d", batch, l)
+		leaves = append(leaves, trillian.LogLeaf{
+			Leaf:                 trillian.Leaf{LeafHash: hasher.Digest([]byte(lv)), LeafValue: []byte(lv)},
+			SignedEntryTimestamp: signedTimestamp,
+			SequenceNumber:       int64(batch*n + l),
+		})
+	}
+
+	return leaves
+}
+
+// The benchmarks measure the write path of the sequencer, which queues a batch of leaves and
+// then sequences it, for a range of batch sizes. Like the tests they need the test database,
+// run them with go test -run=NONE -bench=. in this directory.
+func benchmarkQueueAndSequenceLeaves(b *testing.B, batchSize int) {
+	ctx := context.Background()
+	logID := createLogID(fmt.Sprintf("BenchmarkQueueAndSequenceLeaves%d", batchSize))
+	db := prepareTestLogDB(logID, b)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, b)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		leaves := createBenchmarkLeaves(batchSize, i)
+		b.StartTimer()
+
+		tx := beginLogTx(s, b)
+
+		if _, err := tx.QueueLeaves(ctx, leaves); err != nil {
+			b.Fatalf("Failed to queue leaves: %v", err)
+		}
+
+		if err := tx.UpdateSequencedLeaves(ctx, leaves); err != nil {
+			b.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		commit(tx, b)
+	}
+}
+
+func BenchmarkQueueAndSequenceLeaves10(b *testing.B) {
+	benchmarkQueueAndSequenceLeaves(b, 10)
+}
+
+func BenchmarkQueueAndSequenceLeaves100(b *testing.B) {
+	benchmarkQueueAndSequenceLeaves(b, 100)
+}
+
+func BenchmarkQueueAndSequenceLeaves1000(b *testing.B) {
+	benchmarkQueueAndSequenceLeaves(b, 1000)
+}
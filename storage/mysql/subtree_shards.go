@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+)
+
+// The subtrees of a tree can be spread over other databases, its shards, so that a very large
+// map isn't limited by the capacity of one database. The tree's shard map, its rows in
+// SubtreeShard, names its shards in order and each subtree is stored in the shard picked by a
+// hash of its prefix. The names are given DSNs by SetSubtreeShards so that credentials aren't
+// kept in the database. A tree without a shard map keeps its subtrees in the main database.
+//
+// The shard map has to be in place before anything is written to the tree and can't be
+// changed afterwards, as that would move where its subtrees are looked for.
+
+const selectSubtreeShardsSql string = "SELECT ShardName FROM SubtreeShard WHERE TreeId=? ORDER BY ShardIndex"
+const insertSubtreeShardSql string = "INSERT INTO SubtreeShard(TreeId, ShardIndex, ShardName) VALUES(?, ?, ?)"
+
+// Subtrees written at or after a transaction's write revision were left by transactions that
+// failed to commit after writing to the shards, as the main database is committed last.
+const deleteUncommittedSubtreesSql string = "DELETE FROM Subtree WHERE TreeId=? AND SubtreeRevision>=?"
+const deleteTreeSubtreesSql string = "DELETE FROM Subtree WHERE TreeId=?"
+
+// subtreeShardFor returns which of numShards shards stores the subtree with prefix
+func subtreeShardFor(prefix []byte, numShards int) int {
+	h := fnv.New32a()
+	h.Write(prefix)
+	return int(h.Sum32() % uint32(numShards))
+}
+
+// queryer is implemented by both databases and transactions
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// subtreeShards opens the shard databases named in shard maps, sharing each between the
+// storage for all the trees that use it
+type subtreeShards struct {
+	mu     sync.Mutex
+	dsns   map[string]string
+	dbs    map[string]*sql.DB
+	config storage.ConnectionConfig
+}
+
+func newSubtreeShards(dsns map[string]string, config storage.ConnectionConfig) *subtreeShards {
+	return &subtreeShards{dsns: dsns, dbs: make(map[string]*sql.DB), config: config}
+}
+
+// names returns the names of all the shards, sorted, which is the shard map given to new trees
+func (s *subtreeShards) names() []string {
+	names := make([]string, 0, len(s.dsns))
+	for name := range s.dsns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// setConfig changes the connection limits of the shard databases, including those already open
+func (s *subtreeShards) setConfig(config storage.ConnectionConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+	for _, db := range s.dbs {
+		applyConnectionConfig(db, config)
+	}
+}
+
+// get returns the database of the shard with name, opening it the first time
+func (s *subtreeShards) get(name string) (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if db, ok := s.dbs[name]; ok {
+		return db, nil
+	}
+
+	dsn, ok := s.dsns[name]
+	if !ok {
+		return nil, fmt.Errorf("mysql: unknown subtree shard %s", name)
+	}
+
+	db, err := openDB(dsn)
+	if err != nil {
+		glog.Warningf("Could not open subtree shard %s: %s", name, err)
+		return nil, err
+	}
+
+	applyConnectionConfig(db, s.config)
+	s.dbs[name] = db
+
+	return db, nil
+}
+
+// getTreeShards returns the databases of the tree's shards in order, or nil if it doesn't
+// have a shard map. The shard map is read through q, from the main database.
+func getTreeShards(q queryer, shards *subtreeShards, treeID int64) ([]*sql.DB, error) {
+	rows, err := q.Query(selectSubtreeShardsSql, treeID)
+	if err != nil {
+		glog.Warningf("Failed to read shard map of tree %d: %s", treeID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		glog.Warningf("Failed to read shard map of tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	if shards == nil {
+		return nil, fmt.Errorf("mysql: tree %d has subtree shards but none are configured", treeID)
+	}
+
+	dbs := make([]*sql.DB, 0, len(names))
+	for _, name := range names {
+		db, err := shards.get(name)
+		if err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+
+	return dbs, nil
+}
@@ -6,21 +6,35 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/sqltrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
 )
 
 // These statements are fixed
-const insertSubtreeMultiSql string = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSql
-const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature)
-		 VALUES(?,?,?,?,?,?)`
+// A subtree can be written more than once at the same revision if it's flushed from a
+// size limited cache and then updated again in the same transaction.
+const insertSubtreeMultiSql string = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSql +
+	` ON DUPLICATE KEY UPDATE Nodes=VALUES(Nodes)`
+const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,Metadata)
+		 VALUES(?,?,?,?,?,?,?)`
 const selectTreeRevisionAtSizeSql string = "SELECT TreeRevision FROM TreeHead WHERE TreeId=? AND TreeSize=? ORDER BY TreeRevision DESC LIMIT 1"
-const selectActiveLogsSql string = "select TreeId, KeyId from Trees where TreeType='LOG'"
-const selectActiveLogsWithUnsequencedSql string = "SELECT DISTINCT t.TreeId, t.KeyId from Trees t INNER JOIN Unsequenced u WHERE TreeType='LOG' AND t.TreeId=u.TreeId"
+const selectTreeRevisionCoveringSizeSql string = "SELECT TreeRevision, TreeSize FROM TreeHead WHERE TreeId=? AND TreeSize>=? ORDER BY TreeSize, TreeRevision DESC LIMIT 1"
+const selectActiveLogsSql string = "select TreeId, KeyId from Trees where TreeType IN ('LOG','PREORDERED_LOG') AND TreeState='ACTIVE' AND Deleted=FALSE"
+const selectActiveLogsWithUnsequencedSql string = "SELECT DISTINCT t.TreeId, t.KeyId from Trees t INNER JOIN Unsequenced u WHERE TreeType IN ('LOG','PREORDERED_LOG') AND TreeState='ACTIVE' AND Deleted=FALSE AND t.TreeId=u.TreeId"
+const selectTreeStateSql string = "SELECT TreeState,Deleted FROM Trees WHERE TreeId=?"
+const selectTreeHasherTypeSql string = "SELECT TreeHasherType FROM Trees WHERE TreeId=?"
 
 const selectSubtreeSql string = `SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
 				 FROM (SELECT n.SubtreeId, max(n.SubtreeRevision) AS MaxRevision
@@ -33,15 +47,46 @@ const selectSubtreeSql string = `SELECT x.SubtreeId, x.MaxRevision, Subtree.Node
 														Subtree.SubtreeRevision = x.MaxRevision AND
 														Subtree.TreeId = ?`
 
+// The pruning statements pick out the subtree revisions which are older than the newest
+// revision of the same subtree at or before the horizon, and so can't be read at the
+// horizon or any later revision.
+const supersededSubtreesSql string = `FROM Subtree s
+				 INNER JOIN (SELECT SubtreeId, max(SubtreeRevision) AS KeepRevision
+							 FROM Subtree
+							 WHERE TreeId = ? AND SubtreeRevision <= ?
+							 GROUP BY SubtreeId) AS k
+				 ON s.SubtreeId = k.SubtreeId
+				 WHERE s.TreeId = ? AND s.SubtreeRevision < k.KeepRevision`
+const selectSupersededSubtreesSizeSql string = `SELECT COUNT(*), COALESCE(SUM(LENGTH(s.Nodes)), 0) ` + supersededSubtreesSql
+const deleteSupersededSubtreesSql string = `DELETE s ` + supersededSubtreesSql
+
 const placeholderSql string = "<placeholder>"
 
+// maxRowsPerInsert is the most rows written by one multi-row INSERT. Bigger batches are split
+// so that statements stay well inside MySQL's limits of 65535 placeholders and
+// max_allowed_packet bytes, and so that only a few sizes of statement get prepared.
+const maxRowsPerInsert = 256
+
 // mySQLTreeStorage is shared between the mySQLLog- and (forthcoming) mySQLMap-
 // Storage implementations, and contains functionality which is common to both,
 type mySQLTreeStorage struct {
 	treeID          int64
 	db              *sql.DB
+	hashAlgorithm   trillian.HashAlgorithm
 	hashSizeBytes   int
 	populateSubtree storage.PopulateSubtreeFunc
+	cacheLimits     cache.CacheLimits
+	limits          dbLimits
+	// storeInternalNodes is true if subtrees are stored with their internal nodes
+	storeInternalNodes bool
+	// subtreeKeyVersion is the version of the suffix keys subtrees are written with
+	subtreeKeyVersion int32
+	// sharedCache is read through by transactions before they read subtrees from the
+	// database, or nil if there isn't one
+	sharedCache *cache.SharedSubtreeCache
+	// shards are the databases the tree's subtrees are spread over, or nil if they're kept
+	// in db, see subtree_shards.go
+	shards []*sql.DB
 
 	// Must hold the mutex before manipulating the statement map. Sharing a lock because
 	// it only needs to be held while the statements are built, not while they execute and
@@ -67,23 +112,61 @@ func openDB(dbURL string) (*sql.DB, error) {
 	return db, nil
 }
 
-func newTreeStorage(treeID int64, dbURL string, hashSizeBytes int, populateSubtree storage.PopulateSubtreeFunc) (mySQLTreeStorage, error) {
-	db, err := openDB(dbURL)
+// newTreeStorage creates the tree hasher configured for the tree, which is passed to
+// populateFactory to build the function used to rebuild subtrees. The database can be
+// shared with the storage for other trees. shards opens the databases named by the tree's
+// shard map, if it has one, and can be nil if no shards are configured.
+func newTreeStorage(treeID int64, db *sql.DB, limits dbLimits, shards *subtreeShards, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc) (mySQLTreeStorage, error) {
+	hashAlgorithm, err := getTreeHashAlgorithm(db, treeID)
+	if err != nil {
+		return mySQLTreeStorage{}, err
+	}
+
+	treeShards, err := getTreeShards(db, shards, treeID)
+	if err != nil {
+		return mySQLTreeStorage{}, err
+	}
+
+	th, err := merkle.NewTreeHasher(hashAlgorithm)
 	if err != nil {
+		glog.Warningf("Failed to create tree hasher for tree %d: %s", treeID, err)
 		return mySQLTreeStorage{}, err
 	}
 
 	s := mySQLTreeStorage{
 		treeID:          treeID,
 		db:              db,
-		hashSizeBytes:   hashSizeBytes,
-		populateSubtree: populateSubtree,
+		hashAlgorithm:   hashAlgorithm,
+		hashSizeBytes:   th.Size(),
+		populateSubtree: populateFactory(th),
+		limits:          limits,
+		shards:          treeShards,
 		statements:      make(map[string]map[int]*sql.Stmt),
 	}
 
 	return s, nil
 }
 
+// getTreeHashAlgorithm reads the hash algorithm the tree was created with.
+// TODO: Trees without a row default to SHA256 like the other tree properties until
+// everything creates trees through the admin API.
+func getTreeHashAlgorithm(db *sql.DB, treeID int64) (trillian.HashAlgorithm, error) {
+	var treeHasherType string
+	if err := db.QueryRow(selectTreeHasherTypeSql, treeID).Scan(&treeHasherType); err == sql.ErrNoRows {
+		return trillian.HashAlgorithm_SHA256, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read hasher type for tree %d: %s", treeID, err)
+		return 0, err
+	}
+
+	v, ok := trillian.HashAlgorithm_value[treeHasherType]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash algorithm %s for tree %d", treeHasherType, treeID)
+	}
+
+	return trillian.HashAlgorithm(v), nil
+}
+
 // expandPlaceholderSql expands an sql statement by adding a specified number of '?'
 // placeholder slots. At most one placeholder will be expanded.
 func expandPlaceholderSql(sql string, num int, first, rest string) string {
@@ -178,34 +261,199 @@ func (m *mySQLTreeStorage) getSubtreeStmt(num int) (*sql.Stmt, error) {
 	return m.getStmt(selectSubtreeSql, num, "?", "?")
 }
 
-func (m *mySQLTreeStorage) setSubtreeStmt(num int) (*sql.Stmt, error) {
-	return m.getStmt(insertSubtreeMultiSql, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
+// HashAlgorithm returns the hash algorithm the tree was created with.
+func (m *mySQLTreeStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return m.hashAlgorithm
+}
+
+// SetSubtreeCacheLimits sets the limits for the subtree caches of transactions started
+// after this call. By default the caches are unlimited.
+func (m *mySQLTreeStorage) SetSubtreeCacheLimits(limits cache.CacheLimits) {
+	m.cacheLimits = limits
 }
 
-func (m *mySQLTreeStorage) beginTreeTx() (treeTX, error) {
-	t, err := m.db.Begin()
+// SetStoreInternalNodes sets whether subtrees written by transactions started after this
+// call are stored with their internal nodes. By default only their leaves are stored.
+func (m *mySQLTreeStorage) SetStoreInternalNodes(store bool) {
+	m.storeInternalNodes = store
+}
+
+// SetSubtreeKeyVersion sets the version of the suffix key encoding that subtrees written by
+// transactions started after this call use. By default they use storage.SuffixKeyV0.
+func (m *mySQLTreeStorage) SetSubtreeKeyVersion(version int32) {
+	m.subtreeKeyVersion = version
+}
+
+// SetSharedSubtreeCache sets the cache that transactions started after this call read
+// subtrees through. By default subtrees are only cached by each transaction.
+func (m *mySQLTreeStorage) SetSharedSubtreeCache(c *cache.SharedSubtreeCache) {
+	m.sharedCache = c
+}
+
+// snapshotTxOptions start the transactions returned by SnapshotForTree. Every read in a
+// REPEATABLE READ transaction sees the snapshot taken by its first one, and being plain
+// reads of a snapshot they don't take any locks.
+var snapshotTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// beginTreeTx starts a transaction traced as a span of ctx, which lasts until the
+// transaction is committed or rolled back. A nil opts uses the driver's defaults. It fails
+// with storage.ErrUnhealthy if the database is failing.
+func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, opts *sql.TxOptions) (treeTX, error) {
+	if err := m.limits.allow(); err != nil {
+		return treeTX{}, err
+	}
+
+	ctx, span := monitoring.StartSpan(ctx, "mysql.TX", attribute.Int64("treeid", m.treeID))
+	conn, err := m.limits.conn(ctx, m.db)
+	if err != nil {
+		logging.Warningf(ctx, "Could not get connection for tree TX: %s", err)
+		monitoring.EndSpan(span, err)
+		return treeTX{}, err
+	}
+	t, err := conn.BeginTx(ctx, opts)
 	if err != nil {
-		glog.Warningf("Could not start tree TX: %s", err)
+		logging.Warningf(ctx, "Could not start tree TX: %s", err)
+		conn.Close()
+		monitoring.EndSpan(span, err)
 		return treeTX{}, err
 	}
+	subtreeCache := cache.NewSubtreeCacheWithLimits(m.populateSubtree, m.cacheLimits)
+	subtreeCache.SetStoreInternalNodes(m.storeInternalNodes)
+	subtreeCache.SetSubtreeKeyVersion(m.subtreeKeyVersion)
 	return treeTX{
-		tx:            t,
+		tx:            sqltrace.NewTx(t, span, "mysql", m.limits.txOptions()),
+		conn:          conn,
+		ctx:           ctx,
+		span:          span,
 		ts:            m,
-		subtreeCache:  cache.NewSubtreeCache(m.populateSubtree),
+		subtreeCache:  subtreeCache,
 		writeRevision: -1,
+		readRevision:  -1,
+		subtreeMutex:  new(sync.Mutex),
+		opts:          opts,
+		shards:        make([]shardTX, len(m.shards)),
+		started:       time.Now(),
 	}, nil
 }
 
 type treeTX struct {
-	closed        bool
-	tx            *sql.Tx
+	closed bool
+	tx     *sqltrace.Tx
+	// conn is the connection the transaction runs on, which is returned to the pool once
+	// it's over
+	conn *sql.Conn
+	// ctx is the context the transaction was started with, which cached subtrees are
+	// written back under when it's committed
+	ctx           context.Context
+	span          trace.Span
 	ts            *mySQLTreeStorage
 	subtreeCache  cache.SubtreeCache
 	writeRevision int64
+	// readRevision is the revision a transaction started by SnapshotForTree is pinned to,
+	// or -1 for other transactions
+	readRevision int64
+	// writtenPrefixes are the prefixes of the subtrees written through the transaction
+	writtenPrefixes [][]byte
+	// storedRoot is true if the root for writeRevision has been stored
+	storedRoot bool
+	// subtreeMutex serializes the subtree reads and writes made on behalf of the
+	// subtree cache, which may happen concurrently, as tx can only be used for one
+	// query at a time.
+	subtreeMutex *sync.Mutex
+	// opts are the options the transaction was started with, which it starts the
+	// transactions on the tree's shards with too
+	opts *sql.TxOptions
+	// shards holds a transaction for each of the tree's shards, which is started when the
+	// shard is first used. They're guarded by subtreeMutex.
+	shards []shardTX
+	// wroteSubtrees is true if any subtrees have been written through the transaction
+	wroteSubtrees bool
+	// started is when the transaction began, for recording how long it was open
+	started time.Time
+}
+
+// shardTX is a transaction on one of a tree's shards
+type shardTX struct {
+	tx *sqltrace.Tx
+	// cleaned is true once the subtrees left at the write revision by transactions that
+	// failed to commit have been removed from the shard
+	cleaned bool
+}
+
+// shardTx returns the transaction on shard i, starting it if it hasn't been used yet. If
+// write is true subtrees left at the write revision by earlier transactions are removed
+// first, so that they aren't mistaken for ones written by this transaction. Callers must
+// hold subtreeMutex.
+func (t *treeTX) shardTx(ctx context.Context, i int, write bool) (*sqltrace.Tx, error) {
+	s := &t.shards[i]
+
+	if s.tx == nil {
+		tx, err := t.ts.shards[i].BeginTx(t.ctx, t.opts)
+		if err != nil {
+			logging.Warningf(ctx, "Could not start TX on subtree shard %d: %s", i, err)
+			return nil, err
+		}
+		s.tx = sqltrace.NewTx(tx, t.span, "mysql", sqltrace.Options{QueryTimeout: t.ts.limits.queryTimeout})
+	}
+
+	if write && !s.cleaned {
+		if _, err := s.tx.Exec(ctx, deleteUncommittedSubtreesSql, t.ts.treeID, t.writeRevision); err != nil {
+			logging.Warningf(ctx, "Failed to remove uncommitted subtrees from shard %d: %s", i, err)
+			return nil, err
+		}
+		s.cleaned = true
+	}
+
+	return s.tx, nil
+}
+
+// commitShards commits the transactions on the tree's shards, rolling back those that are
+// left if one fails. If any subtrees were written every shard is cleaned first, see shardTx.
+func (t *treeTX) commitShards() error {
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	var err error
+	if t.wroteSubtrees {
+		for i := range t.shards {
+			if _, err = t.shardTx(t.ctx, i, true); err != nil {
+				break
+			}
+		}
+	}
+
+	for i := range t.shards {
+		s := &t.shards[i]
+		if s.tx == nil {
+			continue
+		}
+
+		if err != nil {
+			s.tx.Rollback()
+		} else if err = s.tx.Commit(); err != nil {
+			logging.Warningf(t.ctx, "Failed to commit subtree shard %d: %s", i, err)
+		}
+		s.tx = nil
+	}
+
+	return err
+}
+
+// rollbackShards rolls back the transactions on the tree's shards
+func (t *treeTX) rollbackShards() {
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	for i := range t.shards {
+		if t.shards[i].tx != nil {
+			t.shards[i].tx.Rollback()
+			t.shards[i].tx = nil
+		}
+	}
 }
 
-func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
-	s, err := t.getSubtrees(treeRevision, []storage.NodeID{nodeID})
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []storage.NodeID{nodeID})
 	if err != nil {
 		return nil, err
 	}
@@ -219,18 +467,89 @@ func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage
 	}
 }
 
-func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
 	if len(nodeIDs) == 0 {
 		return nil, nil
 	}
 
+	_, span := monitoring.StartSpan(t.tx.WithSpan(ctx), "mysql.getSubtrees", attribute.Int("subtrees", len(nodeIDs)))
+	defer span.End()
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	if len(t.shards) > 0 {
+		return t.getShardedSubtrees(ctx, treeRevision, nodeIDs)
+	}
+
+	args, err := t.subtreeArgs(treeRevision, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	tmpl, err := t.ts.getSubtreeStmt(len(nodeIDs))
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.Stmt(tmpl)
+	stx := t.tx.Stmt(ctx, tmpl)
 	defer stx.Close()
 
+	rows, err := stx.Query(ctx, args...)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+
+	return scanSubtrees(rows, len(nodeIDs))
+}
+
+// getShardedSubtrees reads each subtree from the shard it's stored in, making one query on
+// each shard. Callers must hold subtreeMutex.
+func (t *treeTX) getShardedSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	byShard := make([][]storage.NodeID, len(t.shards))
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+
+		i := subtreeShardFor(nodeID.Path[:nodeID.PrefixLenBits/8], len(t.shards))
+		byShard[i] = append(byShard[i], nodeID)
+	}
+
+	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+	for i, ids := range byShard {
+		if len(ids) == 0 {
+			continue
+		}
+
+		args, err := t.subtreeArgs(treeRevision, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := t.shardTx(ctx, i, false)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := tx.Query(ctx, expandPlaceholderSql(selectSubtreeSql, len(ids), "?", "?"), args...)
+		if err != nil {
+			logging.Warningf(ctx, "Failed to get merkle subtrees from shard %d: %s", i, err)
+			return nil, err
+		}
+
+		subtrees, err := scanSubtrees(rows, len(ids))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, subtrees...)
+	}
+
+	return ret, nil
+}
+
+// subtreeArgs returns the arguments of selectSubtreeSql to read nodeIDs at treeRevision
+func (t *treeTX) subtreeArgs(treeRevision int64, nodeIDs []storage.NodeID) ([]interface{}, error) {
 	args := make([]interface{}, 0, len(nodeIDs)+3)
 
 	// populate args with nodeIDs
@@ -248,11 +567,12 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 	args = append(args, interface{}(treeRevision))
 	args = append(args, interface{}(t.ts.treeID))
 
-	rows, err := stx.Query(args...)
-	if err != nil {
-		glog.Warningf("Failed to get merkle subtrees: %s", err)
-		return nil, err
-	}
+	return args, nil
+}
+
+// scanSubtrees reads the subtrees selected by selectSubtreeSql, of which there are at most
+// num, and closes rows
+func scanSubtrees(rows *sqltrace.Rows, num int) ([]*storage.SubtreeProto, error) {
 	defer rows.Close()
 
 	if rows.Err() != nil {
@@ -261,7 +581,7 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 		return nil, rows.Err()
 	}
 
-	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+	ret := make([]*storage.SubtreeProto, 0, num)
 
 	for rows.Next() {
 
@@ -283,50 +603,120 @@ func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) ([]*s
 		ret = append(ret, &subtree)
 	}
 
-	// The InternalNodes cache is nil here, but the SubtreeCache (which called
-	// this method) will re-populate it.
+	// The InternalNodes cache is nil here unless the subtrees were stored with their
+	// internal nodes, otherwise the SubtreeCache (which called this method) will
+	// re-populate it.
 	return ret, nil
 }
 
-func (t *treeTX) storeSubtrees(subtrees []*storage.SubtreeProto) error {
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storage.SubtreeProto) error {
 	if len(subtrees) == 0 {
-		glog.Warning("attempted to store 0 subtrees...")
+		logging.Warningf(ctx, "attempted to store 0 subtrees...")
 		return nil
 	}
 
-	// TODO(al): probably need to be able to batch this in the case where we have
-	// a really large number of subtrees to store.
-	args := make([]interface{}, 0, len(subtrees))
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	t.wroteSubtrees = true
+
+	rows := make([][]interface{}, 0, len(subtrees))
 	for _, s := range subtrees {
 		if s.Prefix == nil {
 			panic(fmt.Errorf("nil prefix on %v", s))
 		}
-		// Ensure we're not storing the internal nodes, since we'll just recalculate
-		// them when we read this subtree back.
-		s.InternalNodes = nil
+		t.writtenPrefixes = append(t.writtenPrefixes, s.Prefix)
+		// The SubtreeCache has already removed the internal nodes unless it was told to
+		// store them, otherwise they're recalculated when this subtree is read back.
 		subtreeBytes, err := proto.Marshal(s)
 		if err != nil {
 			return err
 		}
-		args = append(args, t.ts.treeID)
-		args = append(args, s.Prefix)
-		args = append(args, subtreeBytes)
-		args = append(args, t.writeRevision)
+		rows = append(rows, []interface{}{t.ts.treeID, s.Prefix, subtreeBytes, t.writeRevision})
 	}
 
-	tmpl, err := t.ts.setSubtreeStmt(len(subtrees))
-	if err != nil {
+	if len(t.shards) > 0 {
+		return t.storeShardedSubtrees(ctx, rows)
+	}
+
+	if err := t.insertRows(ctx, insertSubtreeMultiSql, "(?, ?, ?, ?)", rows); err != nil {
+		logging.Warningf(ctx, "Failed to set merkle subtrees: %s", err)
 		return err
 	}
-	stx := t.tx.Stmt(tmpl)
-	defer stx.Close()
+	return nil
+}
+
+// storeShardedSubtrees writes each of rows, the arguments of insertSubtreeMultiSql for one
+// subtree, to the shard the subtree is stored in. Callers must hold subtreeMutex.
+func (t *treeTX) storeShardedSubtrees(ctx context.Context, rows [][]interface{}) error {
+	byShard := make([][][]interface{}, len(t.shards))
+	for _, row := range rows {
+		i := subtreeShardFor(row[1].([]byte), len(t.shards))
+		byShard[i] = append(byShard[i], row)
+	}
+
+	for i, shardRows := range byShard {
+		if len(shardRows) == 0 {
+			continue
+		}
+
+		tx, err := t.shardTx(ctx, i, true)
+		if err != nil {
+			return err
+		}
+
+		err = forEachRowBatch(shardRows, func(num int, args []interface{}) error {
+			_, err := tx.Exec(ctx, expandPlaceholderSql(insertSubtreeMultiSql, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)"), args...)
+			return err
+		})
+		if err != nil {
+			logging.Warningf(ctx, "Failed to set merkle subtrees in shard %d: %s", i, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertRows writes rows using statement, a multi-row INSERT with placeholderSql where its
+// VALUES go. rowSql holds the parameter placeholders for one row and each of rows holds the
+// arguments for one. The rows are written maxRowsPerInsert at a time, in order.
+func (t *treeTX) insertRows(ctx context.Context, statement, rowSql string, rows [][]interface{}) error {
+	return forEachRowBatch(rows, func(num int, args []interface{}) error {
+		tmpl, err := t.ts.getStmt(statement, num, "VALUES"+rowSql, rowSql)
+		if err != nil {
+			return err
+		}
+
+		stx := t.tx.Stmt(ctx, tmpl)
+		_, err = stx.Exec(ctx, args...)
+		stx.Close()
 
-	r, err := stx.Exec(args...)
-	if err != nil {
-		glog.Warningf("Failed to set merkle subtrees: %s", err)
 		return err
+	})
+}
+
+// forEachRowBatch calls insert with the arguments of up to maxRowsPerInsert of rows at a
+// time, in order, and the number of rows they're for
+func forEachRowBatch(rows [][]interface{}, insert func(num int, args []interface{}) error) error {
+	for len(rows) > 0 {
+		num := len(rows)
+		if num > maxRowsPerInsert {
+			num = maxRowsPerInsert
+		}
+
+		args := make([]interface{}, 0, num*len(rows[0]))
+		for _, row := range rows[:num] {
+			args = append(args, row...)
+		}
+
+		if err := insert(num, args); err != nil {
+			return err
+		}
+
+		rows = rows[num:]
 	}
-	_, err = r.RowsAffected()
+
 	return nil
 }
 
@@ -351,44 +741,136 @@ func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
 	return nil
 }
 
+// PruneSubtrees implements storage.SubtreePruner.
+func (t *treeTX) PruneSubtrees(ctx context.Context, horizon int64) (storage.PruneStats, error) {
+	var stats storage.PruneStats
+	if horizon <= 0 {
+		return stats, nil
+	}
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	if len(t.shards) == 0 {
+		return t.pruneSubtreesIn(ctx, t.tx, horizon)
+	}
+
+	for i := range t.shards {
+		tx, err := t.shardTx(ctx, i, false)
+		if err != nil {
+			return storage.PruneStats{}, err
+		}
+
+		shardStats, err := t.pruneSubtreesIn(ctx, tx, horizon)
+		if err != nil {
+			return storage.PruneStats{}, err
+		}
+		stats.Rows += shardStats.Rows
+		stats.Bytes += shardStats.Bytes
+	}
+
+	return stats, nil
+}
+
+// pruneSubtreesIn deletes the superseded subtree revisions stored in the database of tx.
+// Callers must hold subtreeMutex.
+func (t *treeTX) pruneSubtreesIn(ctx context.Context, tx *sqltrace.Tx, horizon int64) (storage.PruneStats, error) {
+	var stats storage.PruneStats
+
+	treeID := t.ts.treeID
+	if err := tx.QueryRow(ctx, selectSupersededSubtreesSizeSql, treeID, horizon, treeID).Scan(&stats.Rows, &stats.Bytes); err != nil {
+		logging.Warningf(ctx, "Failed to size superseded subtrees: %s", err)
+		return storage.PruneStats{}, err
+	}
+	if stats.Rows == 0 {
+		return stats, nil
+	}
+
+	r, err := tx.Exec(ctx, deleteSupersededSubtreesSql, treeID, horizon, treeID)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to delete superseded subtrees: %s", err)
+		return storage.PruneStats{}, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return storage.PruneStats{}, err
+	}
+	if n != stats.Rows {
+		return storage.PruneStats{}, fmt.Errorf("deleted %d superseded subtrees, expected %d", n, stats.Rows)
+	}
+	return stats, nil
+}
+
 // GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
 // It is an error to request tree sizes larger than the currently published tree size.
-// TODO: This only works for sizes where there is a stored tree head. This is deliberate atm
-// as serving proofs at intermediate tree sizes is complicated and will be implemented later.
-func (t *treeTX) GetTreeRevisionAtSize(treeSize int64) (int64, error) {
+// This only works for sizes where there is a stored tree head, snapshots for other sizes
+// are pinned to the revision from getTreeRevisionCoveringSize.
+func (t *treeTX) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
 	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
 	if treeSize <= 0 {
 		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
 	}
 
 	var treeRevision int64
-	err := t.tx.QueryRow(selectTreeRevisionAtSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision)
+	err := t.tx.QueryRow(ctx, selectTreeRevisionAtSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision)
 
 	return treeRevision, err
 }
 
-func (t *treeTX) GetMerkleNodes(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
-	err := t.subtreeCache.Preload(nodeIDs, func(ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
-		return t.getSubtrees(treeRevision, ids)
-	})
+// getTreeRevisionCoveringSize returns the latest revision of the smallest tree with a stored
+// tree head that has at least treeSize leaves, and the size of that tree.
+func (t *treeTX) getTreeRevisionCoveringSize(ctx context.Context, treeSize int64) (int64, int64, error) {
+	if treeSize <= 0 {
+		return 0, 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision, size int64
+	err := t.tx.QueryRow(ctx, selectTreeRevisionCoveringSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision, &size)
+
+	return treeRevision, size, err
+}
+
+// readableShared returns true if reads at treeRevision can go through the shared subtree
+// cache, which they can if the revision was committed before the transaction started
+func (t *treeTX) readableShared(treeRevision int64) bool {
+	if t.readRevision >= 0 {
+		return treeRevision <= t.readRevision
+	}
+	return treeRevision < t.writeRevision
+}
+
+// rootStored records that the root for revision has been stored, so that the shared subtree
+// cache is told about the revision once it's committed
+func (t *treeTX) rootStored(revision int64) {
+	if revision == t.writeRevision {
+		t.storedRoot = true
+	}
+}
+
+// subtreesAt returns a function reading subtrees at treeRevision, through the shared
+// subtree cache if it can be used
+func (t *treeTX) subtreesAt(treeRevision int64) cache.GetSubtreesFunc {
+	getSubtrees := func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	}
+	if t.readableShared(treeRevision) {
+		getSubtrees = t.ts.sharedCache.Wrap(t.ts.treeID, treeRevision, t.ts.populateSubtree, getSubtrees)
+	}
+	return getSubtrees
+}
+
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	hashes, err := t.subtreeCache.GetNodeHashes(ctx, nodeIDs, t.subtreesAt(treeRevision))
 	if err != nil {
 		return nil, err
 	}
 
 	ret := make([]storage.Node, 0, len(nodeIDs))
 
-	for _, nodeID := range nodeIDs {
-		h, err := t.subtreeCache.GetNodeHash(
-			nodeID,
-			func(n storage.NodeID) (*storage.SubtreeProto, error) {
-				return t.getSubtree(treeRevision, n)
-			})
-		if err != nil {
-			return nil, err
-		}
+	for i, h := range hashes {
 		if h != nil {
 			ret = append(ret, storage.Node{
-				NodeID: nodeID,
+				NodeID: nodeIDs[i],
 				Hash:   h,
 			})
 		}
@@ -397,12 +879,17 @@ func (t *treeTX) GetMerkleNodes(treeRevision int64, nodeIDs []storage.NodeID) ([
 	return ret, nil
 }
 
-func (t *treeTX) SetMerkleNodes(nodes []storage.Node) error {
+func (t *treeTX) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	return t.subtreeCache.GetProofPath(ctx, leafID, treeSize, t.subtreesAt(treeRevision))
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
 	for _, n := range nodes {
-		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
-			func(nID storage.NodeID) (*storage.SubtreeProto, error) {
-				return t.getSubtree(t.writeRevision, nID)
-			})
+		err := t.subtreeCache.SetNodeHash(ctx, n.NodeID, n.Hash,
+			func(ctx context.Context, nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			},
+			t.storeSubtrees)
 		if err != nil {
 			return err
 		}
@@ -412,13 +899,28 @@ func (t *treeTX) SetMerkleNodes(nodes []storage.Node) error {
 
 func (t *treeTX) Commit() error {
 	if t.writeRevision > -1 {
-		t.subtreeCache.Flush(t.storeSubtrees)
+		t.subtreeCache.Flush(t.ctx, t.storeSubtrees)
 	}
 	t.closed = true
-	err := t.tx.Commit()
+	// The shards are committed first. Subtrees are only read at revisions up to the latest
+	// committed root, so they aren't seen until this commits too, and if it fails they're
+	// removed by the next transaction to write at the revision, see shardTx.
+	err := t.commitShards()
+	if err == nil {
+		err = t.tx.Commit()
+	} else {
+		t.tx.Rollback()
+	}
+	if err == nil && t.storedRoot {
+		t.ts.sharedCache.Committed(t.ts.treeID, t.writeRevision, t.writtenPrefixes)
+	}
+	t.conn.Close()
+	t.ts.limits.done(t.ctx, err)
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "mysql", "commit")
+	monitoring.EndSpan(t.span, err)
 
 	if err != nil {
-		glog.Warningf("TX commit error: %$s", err)
+		logging.Warningf(t.ctx, "TX commit error: %$s", err)
 	}
 
 	return err
@@ -426,15 +928,50 @@ func (t *treeTX) Commit() error {
 
 func (t *treeTX) Rollback() error {
 	t.closed = true
+	t.rollbackShards()
 	err := t.tx.Rollback()
+	t.conn.Close()
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "mysql", "rollback")
+	t.span.SetAttributes(attribute.Bool("rollback", true))
+	monitoring.EndSpan(t.span, err)
 
 	if err != nil {
-		glog.Warningf("TX rollback error: %s", err)
+		logging.Warningf(t.ctx, "TX rollback error: %s", err)
 	}
 
 	return err
 }
 
+// checkTreeState returns storage.ErrTreeDeleted if the tree has been deleted and, for
+// writable transactions, storage.ErrReadOnly if it has been frozen. Trees can be frozen
+// or deleted through the admin API at any time so this is checked whenever a transaction
+// is started.
+func (t *treeTX) checkTreeState(ctx context.Context, write bool) error {
+	var state string
+	var deleted bool
+	err := t.tx.QueryRow(ctx, selectTreeStateSql, t.ts.treeID).Scan(&state, &deleted)
+
+	switch {
+	case err == sql.ErrNoRows:
+		// Storage can currently be opened for trees that don't have a Trees row.
+		return nil
+	case err != nil:
+		logging.Warningf(ctx, "Failed to read state of tree %d: %s", t.ts.treeID, err)
+		return err
+	case deleted:
+		return storage.ErrTreeDeleted
+	case write && state == trillian.TreeState_FROZEN.String():
+		return storage.ErrReadOnly
+	}
+
+	return nil
+}
+
+// ReadRevision returns the tree revision a snapshot transaction is pinned to.
+func (t *treeTX) ReadRevision() int64 {
+	return t.readRevision
+}
+
 func (t *treeTX) IsOpen() bool {
 	return !t.closed
 }
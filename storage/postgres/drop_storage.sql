@@ -0,0 +1,17 @@
+-- Caution - this removes all tables in our schema
+
+DROP TABLE IF EXISTS Unsequenced;
+DROP TABLE IF EXISTS Subtree;
+DROP TABLE IF EXISTS SequencedLeafData;
+DROP TABLE IF EXISTS Cosignature;
+DROP TABLE IF EXISTS SequencerCheckpoint;
+DROP TABLE IF EXISTS TreeHead;
+DROP TABLE IF EXISTS LeafData;
+DROP TABLE IF EXISTS MapMutation;
+DROP TABLE IF EXISTS MapKey;
+DROP TABLE IF EXISTS MapLeaf;
+DROP TABLE IF EXISTS MapHead;
+DROP TABLE IF EXISTS TreeControl;
+DROP TABLE IF EXISTS TreeUsage;
+DROP TABLE IF EXISTS Trees;
+DROP TABLE IF EXISTS SchemaVersion;
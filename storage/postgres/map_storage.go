@@ -0,0 +1,481 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/sqltrace"
+	"github.com/lib/pq"
+	"golang.org/x/net/context"
+)
+
+const insertMapHeadSQL string = `INSERT INTO MapHead(TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData)
+	VALUES($1, $2, $3, $4, $5, $6)`
+
+const selectLatestSignedMapRootSql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=$1
+		 ORDER BY MapHeadTimestamp DESC LIMIT 1`
+
+const selectSignedMapRootByRevisionSql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=$1 AND MapRevision=$2`
+
+const insertMapLeafSQL string = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, TheData) VALUES ($1, $2, $3, $4)`
+
+// Note that MapRevision is stored negated, hence the odd equality check below. DISTINCT ON
+// picks the first row for each key, which is the most recent revision at or before the
+// requested one.
+const selectMapLeafSQL string = `SELECT DISTINCT ON (KeyHash) KeyHash, MapRevision, TheData
+	 FROM MapLeaf
+	 WHERE KeyHash IN (` + placeholderSql + `) AND
+	       TreeId = ? AND
+	       MapRevision >= ?
+	 ORDER BY KeyHash, MapRevision`
+
+// Note that MapRevision is stored negated so the range is inverted and sorting in
+// descending order returns the oldest revision first. This uses the primary key on
+// (TreeId, KeyHash, MapRevision).
+const selectMapLeafHistorySQL string = `SELECT MapRevision, TheData
+	 FROM MapLeaf
+	 WHERE TreeId = $1 AND
+	       KeyHash = $2 AND
+	       MapRevision >= $3 AND
+	       MapRevision <= $4
+	 ORDER BY MapRevision DESC`
+
+// Keys set more than once keep their first row
+const insertMapKeySQL string = `INSERT INTO MapKey(TreeId, TheKey) VALUES ($1, $2)
+	 ON CONFLICT DO NOTHING`
+
+const selectMapKeysSQL string = `SELECT TheKey
+	 FROM MapKey
+	 WHERE TreeId = $1 AND
+	       TheKey >= $2
+	 ORDER BY TheKey
+	 LIMIT $3`
+
+const selectMapKeysBeforeSQL string = `SELECT TheKey
+	 FROM MapKey
+	 WHERE TreeId = $1 AND
+	       TheKey >= $2 AND
+	       TheKey < $3
+	 ORDER BY TheKey
+	 LIMIT $4`
+
+const insertMapMutationSQL string = `INSERT INTO MapMutation(TreeId, MapRevision, TheData) VALUES ($1, $2, $3)`
+
+const selectMapMutationsSQL string = `SELECT MapRevision, TheData
+	 FROM MapMutation
+	 WHERE TreeId = $1 AND
+	       MapRevision >= $2
+	 ORDER BY MapRevision
+	 LIMIT $3`
+
+type pgMapStorage struct {
+	*pgTreeStorage
+
+	mapID trillian.MapID
+}
+
+func (p *pgMapStorage) MapID() trillian.MapID {
+	return p.mapID
+}
+
+// NewMapStorage creates a MapStorage for the specified map backed by the PostgreSQL
+// database at dbURL.
+func NewMapStorage(id trillian.MapID, dbURL string) (storage.MapStorage, error) {
+	ts, err := newTreeStorage(id.TreeID, dbURL, cache.PopulateMapSubtreeNodes)
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+
+	s := pgMapStorage{
+		pgTreeStorage: ts,
+		mapID:         id,
+	}
+
+	return &s, nil
+}
+
+func (p *pgMapStorage) beginInternal(ctx context.Context) (*mapTX, error) {
+	ttx, err := p.beginTreeTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := &mapTX{
+		treeTX: ttx,
+		ms:     p,
+	}
+
+	root, err := ret.LatestSignedMapRoot(ctx)
+	if err != nil {
+		ttx.Rollback()
+		return nil, err
+	}
+
+	ret.treeTX.writeRevision = root.MapRevision + 1
+
+	return ret, nil
+}
+
+func (p *pgMapStorage) Begin(ctx context.Context) (storage.MapTX, error) {
+	tx, err := p.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (p *pgMapStorage) Snapshot(ctx context.Context) (storage.ReadOnlyMapTX, error) {
+	tx, err := p.beginInternal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, err
+}
+
+// SnapshotForTree starts a read-only transaction pinned to a revision of the map, or to the
+// latest one if revision is < 0.
+func (p *pgMapStorage) SnapshotForTree(ctx context.Context, revision int64) (storage.ReadOnlyMapTreeTX, error) {
+	ttx, err := p.beginTreeTx(ctx, snapshotTxOptions)
+	if err != nil {
+		return nil, err
+	}
+	tx := &mapTX{
+		treeTX: ttx,
+		ms:     p,
+	}
+
+	if err := tx.checkTreeState(ctx, false); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var root trillian.SignedMapRoot
+	if revision < 0 {
+		root, err = tx.LatestSignedMapRoot(ctx)
+	} else {
+		root, err = tx.GetSignedMapRoot(ctx, revision)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.treeTX.readRevision = root.MapRevision
+
+	return tx, nil
+}
+
+type mapTX struct {
+	treeTX
+	ms *pgMapStorage
+}
+
+func (t *mapTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *mapTX) Set(ctx context.Context, keyHash trillian.Hash, value trillian.MapLeaf) error {
+	flatValue, err := proto.Marshal(&value)
+	if err != nil {
+		return err
+	}
+
+	// Note: MapRevision is stored negated:
+	_, err = t.tx.Exec(ctx, insertMapLeafSQL, t.ms.mapID.TreeID, []byte(keyHash), -t.writeRevision, flatValue)
+	if isUniqueViolation(err) {
+		return storage.ErrMapRevisionConflict
+	}
+	return err
+}
+
+func (t *mapTX) Get(ctx context.Context, revision int64, keyHashes []trillian.Hash) ([]trillian.MapLeaf, error) {
+	if len(keyHashes) == 0 {
+		return nil, nil
+	}
+
+	stmt, err := t.ms.getStmt(ctx, selectMapLeafSQL, len(keyHashes), "?", "?")
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(ctx, stmt)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(keyHashes)+2)
+	for _, k := range keyHashes {
+		args = append(args, []byte(k[:]))
+	}
+	args = append(args, t.ms.mapID.TreeID)
+	// Note: MapRevision is negated when stored to cause more recent revisions to
+	// appear earlier in query results.
+	args = append(args, -revision)
+
+	rows, err := stx.Query(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]trillian.MapLeaf, 0, len(keyHashes))
+	for rows.Next() {
+		var mapKeyHash []byte
+		var mapRevision int64
+		var flatData []byte
+		if err := rows.Scan(&mapKeyHash, &mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+		if len(flatData) == 0 {
+			continue
+		}
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return nil, err
+		}
+		mapLeaf.KeyHash = mapKeyHash
+		ret = append(ret, mapLeaf)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetHistory(ctx context.Context, keyHash trillian.Hash, startRevision, endRevision int64) ([]storage.MapLeafRevision, error) {
+	// Note: MapRevision is stored negated
+	rows, err := t.tx.Query(ctx, selectMapLeafHistorySQL, t.ms.mapID.TreeID, []byte(keyHash), -endRevision, -startRevision)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read history of map key: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]storage.MapLeafRevision, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+		var mapLeaf trillian.MapLeaf
+		if err := proto.Unmarshal(flatData, &mapLeaf); err != nil {
+			return nil, err
+		}
+		mapLeaf.KeyHash = keyHash
+		ret = append(ret, storage.MapLeafRevision{Revision: -mapRevision, Leaf: mapLeaf})
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetKeys(ctx context.Context, start, end trillian.Key, count int) ([]trillian.Key, error) {
+	query, args := selectMapKeysSQL, []interface{}{t.ms.mapID.TreeID, []byte(start)}
+	if end != nil {
+		query, args = selectMapKeysBeforeSQL, append(args, []byte(end))
+	}
+	args = append(args, count)
+
+	rows, err := t.tx.Query(ctx, query, args...)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map keys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]trillian.Key, 0)
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		ret = append(ret, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) GetMutations(ctx context.Context, startRevision int64, count int) ([]trillian.MapMutation, error) {
+	rows, err := t.tx.Query(ctx, selectMapMutationsSQL, t.ms.mapID.TreeID, startRevision, count)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to read map mutations: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]trillian.MapMutation, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+		var mutation trillian.MapMutation
+		if err := proto.Unmarshal(flatData, &mutation); err != nil {
+			return nil, err
+		}
+		mutation.MapRevision = mapRevision
+		ret = append(ret, mutation)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) LatestSignedMapRoot(ctx context.Context) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, t.tx.QueryRow(ctx, selectLatestSignedMapRootSql, t.ms.mapID.TreeID))
+
+	// It's possible there are no roots for this tree yet
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, nil
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read latest signed map root: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+func (t *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (trillian.SignedMapRoot, error) {
+	root, err := t.readSignedMapRoot(ctx, t.tx.QueryRow(ctx, selectSignedMapRootByRevisionSql, t.ms.mapID.TreeID, revision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, storage.ErrMapRootNotFound
+	} else if err != nil {
+		logging.Warningf(ctx, "Failed to read signed map root at revision %d: %v", revision, err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	return root, nil
+}
+
+// readSignedMapRoot scans a MapHead row. It returns sql.ErrNoRows if there is no row.
+func (t *mapTX) readSignedMapRoot(ctx context.Context, row *sqltrace.Row) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	if err := row.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes); err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
+		logging.Warningf(ctx, "Failed to unmarshal root signature: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if len(mapperMetaBytes) != 0 {
+		mapperMeta = &trillian.MapperMetadata{}
+		if err := proto.Unmarshal(mapperMetaBytes, mapperMeta); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal Metadata; %v", err)
+			return trillian.SignedMapRoot{}, err
+		}
+	}
+
+	ret := trillian.SignedMapRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		MapRevision:    mapRevision,
+		Signature:      &rootSignature,
+		MapId:          t.ms.mapID.MapID,
+		Metadata:       mapperMeta,
+	}
+
+	return ret, nil
+}
+
+func (t *mapTX) StoreSignedMapRoot(ctx context.Context, root trillian.SignedMapRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	var mapperMetaBytes []byte
+
+	if root.Metadata != nil {
+		mapperMetaBytes, err = proto.Marshal(root.Metadata)
+		if err != nil {
+			logging.Warningf(ctx, "Failed to marshal MetaData: %v %v", root.Metadata, err)
+			return err
+		}
+	}
+
+	res, err := t.tx.Exec(ctx, insertMapHeadSQL, t.ms.mapID.TreeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
+
+	if isUniqueViolation(err) {
+		return storage.ErrMapRevisionConflict
+	}
+	if err != nil {
+		logging.Warningf(ctx, "Failed to store signed map root: %s", err)
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+
+	t.rootStored(root.MapRevision)
+	return nil
+}
+
+func (t *mapTX) StoreKey(ctx context.Context, key trillian.Key) error {
+	if _, err := t.tx.Exec(ctx, insertMapKeySQL, t.ms.mapID.TreeID, []byte(key)); err != nil {
+		logging.Warningf(ctx, "Failed to store map key: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *mapTX) StoreMutation(ctx context.Context, mutation trillian.MapMutation) error {
+	flatData, err := proto.Marshal(&mutation)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to marshal map mutation: %v", err)
+		return err
+	}
+
+	res, err := t.tx.Exec(ctx, insertMapMutationSQL, t.ms.mapID.TreeID, mutation.MapRevision, flatData)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to store map mutation: %s", err)
+	}
+
+	return checkResultOkAndRowCountIs(res, err, 1)
+}
+
+// isUniqueViolation returns true if err is from a write that failed because a row with the
+// same unique key already exists
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "unique_violation"
+}
@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"github.com/google/trillian/storage/migrate"
+)
+
+// migrations upgrade the PostgreSQL schema from one version to the next. storage.sql creates
+// the schema at the latest version, so a migration added here must also change it and the
+// version it records. Migrations are never changed once released, as databases may already
+// have them applied.
+//
+// Migration 1 is the schema from before it was versioned. Its tables are only created if
+// they don't exist, so a database made by an earlier storage.sql, which has no SchemaVersion
+// table, is brought up to date by the migrations after it.
+var migrations = []migrate.Migration{
+	{
+		Version:     1,
+		Description: "Initial schema",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT NOT NULL,
+  KeyId                 BYTEA NOT NULL,
+  TreeType              VARCHAR(16) NOT NULL CHECK (TreeType IN ('LOG', 'MAP')),
+  LeafHasherType        VARCHAR(16) NOT NULL CHECK (LeafHasherType IN ('SHA256')),
+  TreeHasherType        VARCHAR(16) NOT NULL CHECK (TreeHasherType IN ('SHA256')),
+  AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  BIGINT NOT NULL,
+  ReadOnlyRequests        BOOLEAN,
+  SigningEnabled          BOOLEAN,
+  SequencingEnabled       BOOLEAN,
+  SequenceIntervalSeconds INTEGER,
+  SignIntervalSeconds     INTEGER,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
+)`,
+			`CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            BYTEA NOT NULL,
+  Nodes                BYTEA NOT NULL,
+  SubtreeRevision      BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BYTEA NOT NULL,
+  RootSignature        BYTEA NOT NULL,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  UNIQUE(TreeId, TreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT NOT NULL,
+  LeafHash             BYTEA NOT NULL,
+  TheData              BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT NOT NULL,
+  SequenceNumber       BIGINT NOT NULL CHECK (SequenceNumber >= 0),
+  LeafHash             BYTEA NOT NULL,
+  SignedEntryTimestamp BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafHash) REFERENCES LeafData(TreeId, LeafHash)
+)`,
+			`CREATE INDEX IF NOT EXISTS SequencedLeafHashIdx ON SequencedLeafData(TreeId, LeafHash)`,
+			`CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT NOT NULL,
+  LeafHash             BYTEA NOT NULL,
+  MessageId            BYTEA NOT NULL,
+  Payload              BYTEA NOT NULL,
+  QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  SignedEntryTimestamp BYTEA,
+  PRIMARY KEY (TreeId, LeafHash, MessageId)
+)`,
+			`CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                BIGINT NOT NULL,
+  KeyHash               BYTEA NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  TheData               BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			`CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               BIGINT NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BYTEA NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        BYTEA NOT NULL,
+  MapperData           BYTEA,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  UNIQUE(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add tree lifecycle fields",
+		Statements: []string{
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS TreeState VARCHAR(16) NOT NULL DEFAULT 'ACTIVE' CHECK (TreeState IN ('ACTIVE', 'FROZEN'))",
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS DisplayName VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS Description VARCHAR(1024) NOT NULL DEFAULT ''",
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS CreateTimeMillis BIGINT NOT NULL DEFAULT 0",
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS UpdateTimeMillis BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add tree soft deletion",
+		Statements: []string{
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS Deleted BOOLEAN NOT NULL DEFAULT FALSE",
+			"ALTER TABLE Trees ADD COLUMN IF NOT EXISTS DeleteTimeMillis BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add tree hash algorithms",
+		Statements: []string{
+			// The checks keep the names PostgreSQL gives those declared with their columns in
+			// storage.sql
+			"ALTER TABLE Trees DROP CONSTRAINT trees_leafhashertype_check",
+			"ALTER TABLE Trees ADD CONSTRAINT trees_leafhashertype_check CHECK (LeafHasherType IN ('SHA256', 'SHA512_256', 'BLAKE2B_256'))",
+			"ALTER TABLE Trees DROP CONSTRAINT trees_treehashertype_check",
+			"ALTER TABLE Trees ADD CONSTRAINT trees_treehashertype_check CHECK (TreeHasherType IN ('SHA256', 'SHA512_256', 'BLAKE2B_256'))",
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add pre-ordered logs",
+		Statements: []string{
+			"ALTER TABLE Trees DROP CONSTRAINT trees_treetype_check",
+			"ALTER TABLE Trees ADD CONSTRAINT trees_treetype_check CHECK (TreeType IN ('LOG', 'MAP', 'PREORDERED_LOG'))",
+			"ALTER TABLE Unsequenced ADD COLUMN IF NOT EXISTS SequenceNumber BIGINT",
+			"ALTER TABLE Unsequenced ADD UNIQUE(TreeId, SequenceNumber)",
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add cosignatures",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  WitnessId            VARCHAR(255) NOT NULL,
+  Signature            BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, WitnessId),
+  FOREIGN KEY(TreeId, TreeHeadTimestamp) REFERENCES TreeHead(TreeId, TreeHeadTimestamp) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add tree usage",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId                  BIGINT NOT NULL,
+  Requests                BIGINT NOT NULL DEFAULT 0,
+  BytesIn                 BIGINT NOT NULL DEFAULT 0,
+  BytesOut                BIGINT NOT NULL DEFAULT 0,
+  LeavesQueued            BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis        BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add map mutations",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  TheData              BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "Add tree head metadata",
+		Statements: []string{
+			"ALTER TABLE TreeHead ADD COLUMN IF NOT EXISTS Metadata BYTEA",
+		},
+	},
+	{
+		Version:     10,
+		Description: "Add leaf identity hashes",
+		Statements: []string{
+			"ALTER TABLE LeafData ADD COLUMN IF NOT EXISTS LeafIdentityHash BYTEA",
+			"CREATE INDEX IF NOT EXISTS LeafIdentityHashIdx ON LeafData(TreeId, LeafIdentityHash)",
+		},
+	},
+	{
+		Version:     11,
+		Description: "Add map key index",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               BIGINT NOT NULL,
+  TheKey               BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, TheKey),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "Add sequencer checkpoints",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  CompactTree          BYTEA NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "Add queue claims",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN IF NOT EXISTS ClaimOwner VARCHAR(255)",
+			"ALTER TABLE Unsequenced ADD COLUMN IF NOT EXISTS ClaimExpiry BIGINT",
+		},
+	},
+	{
+		Version:     14,
+		Description: "Add queue priorities",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN IF NOT EXISTS Priority INTEGER NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		Version:     15,
+		Description: "Add queue not-before times",
+		Statements: []string{
+			"ALTER TABLE Unsequenced ADD COLUMN IF NOT EXISTS NotBefore BIGINT NOT NULL DEFAULT 0",
+		},
+	},
+}
+
+// migrateDialect records the schema version in the same way as storage.sql. Each migration
+// is applied in a transaction, so one that fails leaves the schema as it was.
+var migrateDialect = migrate.SQLDialect{
+	CreateVersionTable: `CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL,
+  Description          VARCHAR(255) NOT NULL,
+  AppliedTimeMillis    BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+)`,
+	InsertVersion: "INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES($1, $2, $3)",
+	// The key is arbitrary, it just mustn't be used for any other advisory lock
+	Lock:          "SELECT 1 FROM pg_advisory_lock(7461726)",
+	Unlock:        "SELECT pg_advisory_unlock(7461726)",
+	Transactional: true,
+}
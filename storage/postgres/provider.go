@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	// Load the PostgreSQL driver for database/sql
+	_ "github.com/lib/pq"
+)
+
+// ProviderName is the name the PostgreSQL storage system is registered under.
+const ProviderName = "postgres"
+
+func init() {
+	if err := storage.RegisterProvider(ProviderName, newPGProvider); err != nil {
+		panic(err)
+	}
+}
+
+// pgProvider creates PostgreSQL backed storage for trees in a single database.
+type pgProvider struct {
+	dbURL string
+}
+
+func newPGProvider(dbURL string) (storage.Provider, error) {
+	return &pgProvider{dbURL: dbURL}, nil
+}
+
+func (p *pgProvider) LogStorage(id trillian.LogID) (storage.LogStorage, error) {
+	return NewLogStorage(id, p.dbURL)
+}
+
+func (p *pgProvider) MapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	return NewMapStorage(id, p.dbURL)
+}
+
+func (p *pgProvider) AdminStorage() (storage.AdminStorage, error) {
+	return NewAdminStorage(p.dbURL)
+}
+
+// Migrations returns the database and the migrations for its schema
+func (p *pgProvider) Migrations() (migrate.Database, []migrate.Migration, error) {
+	db, err := openDB(p.dbURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return migrate.NewSQLDatabase(db, migrateDialect), migrations, nil
+}
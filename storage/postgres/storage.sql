@@ -0,0 +1,252 @@
+-- PostgreSQL version of the tree schema
+
+-- ---------------------------------------------
+-- Tree stuff here
+-- ---------------------------------------------
+
+
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT NOT NULL,
+  KeyId                 BYTEA NOT NULL,
+  TreeType              VARCHAR(16) NOT NULL CHECK (TreeType IN ('LOG', 'MAP', 'PREORDERED_LOG')),
+  LeafHasherType        VARCHAR(16) NOT NULL CHECK (LeafHasherType IN ('SHA256', 'SHA512_256', 'BLAKE2B_256')),
+  TreeHasherType        VARCHAR(16) NOT NULL CHECK (TreeHasherType IN ('SHA256', 'SHA512_256', 'BLAKE2B_256')),
+  AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT FALSE,
+  TreeState             VARCHAR(16) NOT NULL DEFAULT 'ACTIVE' CHECK (TreeState IN ('ACTIVE', 'FROZEN')),
+  DisplayName           VARCHAR(255) NOT NULL DEFAULT '',
+  Description           VARCHAR(1024) NOT NULL DEFAULT '',
+  CreateTimeMillis      BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis      BIGINT NOT NULL DEFAULT 0,
+  Deleted               BOOLEAN NOT NULL DEFAULT FALSE,
+  DeleteTimeMillis      BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId)
+);
+
+-- This table contains tree parameters that can be changed at runtime such as for
+-- administrative purposes.
+CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  BIGINT NOT NULL,
+  ReadOnlyRequests        BOOLEAN,
+  SigningEnabled          BOOLEAN,
+  SequencingEnabled       BOOLEAN,
+  SequenceIntervalSeconds INTEGER,
+  SignIntervalSeconds     INTEGER,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
+);
+
+-- Totals of what clients have used each tree for, added to periodically by the servers so
+-- that tree owners can be billed or limited
+CREATE TABLE IF NOT EXISTS TreeUsage(
+  TreeId                  BIGINT NOT NULL,
+  Requests                BIGINT NOT NULL DEFAULT 0,
+  BytesIn                 BIGINT NOT NULL DEFAULT 0,
+  BytesOut                BIGINT NOT NULL DEFAULT 0,
+  LeavesQueued            BIGINT NOT NULL DEFAULT 0,
+  UpdateTimeMillis        BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            BYTEA NOT NULL,
+  Nodes                BYTEA NOT NULL,
+  SubtreeRevision      BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The unique constraint on TreeRevision is used to enforce that there is only one
+-- STH at any tree revision
+CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BYTEA NOT NULL,
+  RootSignature        BYTEA NOT NULL,
+  TreeRevision         BIGINT,
+  -- Opaque metadata the root was signed with, if any
+  Metadata             BYTEA,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  UNIQUE(TreeId, TreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The sequencer's checkpoint for each log: the state of the compact merkle tree, as a
+-- serialized CompactRangeProto, stored in the same transaction as the STH at TreeRevision.
+-- There's one row per log, each new checkpoint replaces the last.
+CREATE TABLE IF NOT EXISTS SequencerCheckpoint(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  CompactTree          BYTEA NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A witness's cosignature over one of the STHs in TreeHead, each witness has at most one
+-- for any STH
+CREATE TABLE IF NOT EXISTS Cosignature(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT NOT NULL,
+  WitnessId            VARCHAR(255) NOT NULL,
+  Signature            BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp, WitnessId),
+  FOREIGN KEY(TreeId, TreeHeadTimestamp) REFERENCES TreeHead(TreeId, TreeHeadTimestamp) ON DELETE CASCADE
+);
+
+
+-- ---------------------------------------------
+-- Log specific stuff here
+-- ---------------------------------------------
+
+-- A leaf that has not been sequenced has a row in this table. If duplicate leaves
+-- are allowed they will all reference this row.
+CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT NOT NULL,
+  LeafHash             BYTEA NOT NULL,
+  TheData              BYTEA NOT NULL,
+  -- Optional hash chosen by the application to find leaves by, not covered by the tree
+  LeafIdentityHash     BYTEA,
+  PRIMARY KEY(TreeId, LeafHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS LeafIdentityHashIdx ON LeafData(TreeId, LeafIdentityHash);
+
+-- When a leaf is sequenced a row is added to this table. If logs allow duplicates then
+-- multiple rows will exist with different sequence numbers. The signed timestamp
+-- will be communicated via the unsequenced table as this might need to be unique, depending
+-- on the log parameters and we can't insert into this table until we have the sequence number
+-- which is not available at the time we queue the entry.
+CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT NOT NULL,
+  SequenceNumber       BIGINT NOT NULL CHECK (SequenceNumber >= 0),
+  LeafHash             BYTEA NOT NULL,
+  SignedEntryTimestamp BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafHash) REFERENCES LeafData(TreeId, LeafHash)
+);
+
+CREATE INDEX IF NOT EXISTS SequencedLeafHashIdx ON SequencedLeafData(TreeId, LeafHash);
+
+CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT NOT NULL,
+  LeafHash             BYTEA NOT NULL,
+  -- SHA256("queueId"|TreeId|leafHash)
+  -- We want this to be unique per entry per log, but queryable by FEs so that
+  -- we can try to stomp dupe submissions.
+  MessageId            BYTEA NOT NULL,
+  Payload              BYTEA NOT NULL,
+  QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  SignedEntryTimestamp BYTEA,
+  -- Only set for leaves added to pre-ordered logs, where it's the position chosen by the
+  -- application. Entries without one don't conflict as NULLs are distinct.
+  SequenceNumber       BIGINT,
+  -- The sequencer holding a claim on the entry and when the claim expires, in nanoseconds
+  -- since the epoch. Both are NULL for entries that have never been claimed.
+  ClaimOwner           VARCHAR(255),
+  ClaimExpiry          BIGINT,
+  -- Entries with a higher priority are dequeued first. Entries of pre-ordered logs are
+  -- dequeued in sequence order and are always 0.
+  Priority             INTEGER NOT NULL DEFAULT 0,
+  -- Entries aren't dequeued before this time, in nanoseconds since the epoch. It's 0 for
+  -- entries that can be dequeued straight away, including all those of pre-ordered logs.
+  NotBefore            BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (TreeId, LeafHash, MessageId),
+  UNIQUE(TreeId, SequenceNumber)
+);
+
+
+-- ---------------------------------------------
+-- Map specific stuff here
+-- ---------------------------------------------
+
+CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                BIGINT NOT NULL,
+  KeyHash               BYTEA NOT NULL,
+  -- MapRevision is stored negated to invert ordering in the primary key index
+  -- st. more recent revisions come first.
+  MapRevision           BIGINT NOT NULL,
+  TheData               BYTEA NOT NULL,
+  -- The primary key also serves reads of the history of a key across revisions.
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+
+CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               BIGINT NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BYTEA NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        BYTEA NOT NULL,
+  MapperData           BYTEA,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  UNIQUE(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- MapMutation holds the values written at each revision of a map, so the map can be
+-- rebuilt by replaying them in revision order. It's written in the same transaction as
+-- the MapHead for the revision.
+CREATE TABLE IF NOT EXISTS MapMutation(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  TheData              BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- MapKey is an index of the keys which have been set in each map, so keys can be listed
+-- in order, e.g. those starting with a prefix.
+CREATE TABLE IF NOT EXISTS MapKey(
+  TreeId               BIGINT NOT NULL,
+  TheKey               BYTEA NOT NULL,
+  PRIMARY KEY(TreeId, TheKey),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+
+-- Each migration applied to the schema, see migrations.go. This file creates the schema at
+-- the version recorded here.
+CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL,
+  Description          VARCHAR(255) NOT NULL,
+  AppliedTimeMillis    BIGINT NOT NULL,
+  PRIMARY KEY(Version)
+);
+
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(1, 'Initial schema', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(2, 'Add tree lifecycle fields', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(3, 'Add tree soft deletion', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(4, 'Add tree hash algorithms', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(5, 'Add pre-ordered logs', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(6, 'Add cosignatures', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(7, 'Add tree usage', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(8, 'Add map mutations', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(9, 'Add tree head metadata', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(10, 'Add leaf identity hashes', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(11, 'Add map key index', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(12, 'Add sequencer checkpoints', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(13, 'Add queue claims', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(14, 'Add queue priorities', 0)
+  ON CONFLICT DO NOTHING;
+INSERT INTO SchemaVersion(Version, Description, AppliedTimeMillis) VALUES(15, 'Add queue not-before times', 0)
+  ON CONFLICT DO NOTHING;
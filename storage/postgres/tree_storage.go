@@ -0,0 +1,665 @@
+package postgres
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/logging"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/sqltrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// These statements are fixed
+const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,Metadata)
+		 VALUES($1,$2,$3,$4,$5,$6,$7)`
+const selectTreeRevisionAtSizeSql string = "SELECT TreeRevision FROM TreeHead WHERE TreeId=$1 AND TreeSize=$2 ORDER BY TreeRevision DESC LIMIT 1"
+const selectTreeRevisionCoveringSizeSql string = "SELECT TreeRevision, TreeSize FROM TreeHead WHERE TreeId=$1 AND TreeSize>=$2 ORDER BY TreeSize, TreeRevision DESC LIMIT 1"
+const selectActiveLogsSql string = "SELECT TreeId, KeyId FROM Trees WHERE TreeType IN ('LOG','PREORDERED_LOG') AND TreeState='ACTIVE' AND Deleted=FALSE"
+const selectActiveLogsWithUnsequencedSql string = `SELECT DISTINCT t.TreeId, t.KeyId FROM Trees t
+		 INNER JOIN Unsequenced u ON t.TreeId=u.TreeId
+		 WHERE t.TreeType IN ('LOG','PREORDERED_LOG') AND t.TreeState='ACTIVE' AND t.Deleted=FALSE`
+const selectTreeStateSql string = "SELECT TreeState,Deleted FROM Trees WHERE TreeId=$1"
+const selectTreeHasherTypeSql string = "SELECT TreeHasherType FROM Trees WHERE TreeId=$1"
+
+// pruneSubtreesSql deletes the subtree revisions which are older than the newest revision
+// of the same subtree at or before the horizon, and so can't be read at the horizon or any
+// later revision. It returns the number and total size of the deleted rows.
+const pruneSubtreesSql string = `WITH d AS (
+				 DELETE FROM Subtree s
+				 USING (SELECT SubtreeId, max(SubtreeRevision) AS KeepRevision
+							 FROM Subtree
+							 WHERE TreeId = $1 AND SubtreeRevision <= $2
+							 GROUP BY SubtreeId) AS k
+				 WHERE s.TreeId = $1 AND s.SubtreeId = k.SubtreeId AND s.SubtreeRevision < k.KeepRevision
+				 RETURNING s.Nodes)
+		 SELECT COUNT(*), COALESCE(SUM(octet_length(Nodes)), 0) FROM d`
+
+// These statements need to be expanded to provide the correct number of parameter placeholders
+// for a particular case. They're written with '?' placeholders, which are renumbered into
+// the $n form that PostgreSQL requires when the statement is prepared.
+// A subtree can be written more than once at the same revision if it's flushed from a
+// size limited cache and then updated again in the same transaction.
+const insertSubtreeMultiSql string = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSql +
+	` ON CONFLICT (TreeId, SubtreeId, SubtreeRevision) DO UPDATE SET Nodes=EXCLUDED.Nodes`
+const selectSubtreeSql string = `SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
+				 FROM (SELECT n.SubtreeId, max(n.SubtreeRevision) AS MaxRevision
+							 FROM Subtree n
+							 WHERE n.SubtreeId IN (` + placeholderSql + `) AND
+										 n.TreeId = ? AND
+										 n.SubtreeRevision <= ?
+							 GROUP BY n.SubtreeId) AS x
+				 INNER JOIN Subtree ON Subtree.SubtreeId = x.SubtreeId AND
+														Subtree.SubtreeRevision = x.MaxRevision AND
+														Subtree.TreeId = ?`
+
+const placeholderSql string = "<placeholder>"
+
+// pgTreeStorage is shared between the pgLog- and pgMap- Storage implementations, and
+// contains functionality which is common to both.
+type pgTreeStorage struct {
+	treeID          int64
+	db              *sql.DB
+	hashAlgorithm   trillian.HashAlgorithm
+	hashSizeBytes   int
+	populateSubtree storage.PopulateSubtreeFunc
+	cacheLimits     cache.CacheLimits
+	// storeInternalNodes is true if subtrees are stored with their internal nodes
+	storeInternalNodes bool
+	// subtreeKeyVersion is the version of the suffix keys subtrees are written with
+	subtreeKeyVersion int32
+	// sharedCache is read through by transactions before they read subtrees from the
+	// database, or nil if there isn't one
+	sharedCache *cache.SharedSubtreeCache
+
+	// Must hold the mutex before manipulating the statement map. Sharing a lock because
+	// it only needs to be held while the statements are built, not while they execute and
+	// this will be a short time. These maps are from the number of placeholder '?'
+	// in the query to the statement that should be used.
+	statementMutex sync.Mutex
+	statements     map[string]map[int]*sql.Stmt
+}
+
+func openDB(dbURL string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		// Don't log uri as it could contain credentials
+		glog.Warningf("Could not open PostgreSQL database, check config: %s", err)
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// newTreeStorage opens the database and creates the tree hasher configured for the tree,
+// which is passed to populateFactory to build the function used to rebuild subtrees.
+func newTreeStorage(treeID int64, dbURL string, populateFactory func(merkle.TreeHasher) storage.PopulateSubtreeFunc) (*pgTreeStorage, error) {
+	db, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	hashAlgorithm, err := getTreeHashAlgorithm(db, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	th, err := merkle.NewTreeHasher(hashAlgorithm)
+	if err != nil {
+		glog.Warningf("Failed to create tree hasher for tree %d: %s", treeID, err)
+		return nil, err
+	}
+
+	s := &pgTreeStorage{
+		treeID:          treeID,
+		db:              db,
+		hashAlgorithm:   hashAlgorithm,
+		hashSizeBytes:   th.Size(),
+		populateSubtree: populateFactory(th),
+		statements:      make(map[string]map[int]*sql.Stmt),
+	}
+
+	return s, nil
+}
+
+// getTreeHashAlgorithm reads the hash algorithm the tree was created with.
+// TODO: Trees without a row default to SHA256 like the other tree properties until
+// everything creates trees through the admin API.
+func getTreeHashAlgorithm(db *sql.DB, treeID int64) (trillian.HashAlgorithm, error) {
+	var treeHasherType string
+	if err := db.QueryRow(selectTreeHasherTypeSql, treeID).Scan(&treeHasherType); err == sql.ErrNoRows {
+		return trillian.HashAlgorithm_SHA256, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read hasher type for tree %d: %s", treeID, err)
+		return 0, err
+	}
+
+	v, ok := trillian.HashAlgorithm_value[treeHasherType]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash algorithm %s for tree %d", treeHasherType, treeID)
+	}
+
+	return trillian.HashAlgorithm(v), nil
+}
+
+// expandPlaceholderSql expands an sql statement by adding a specified number of '?'
+// placeholder slots. At most one placeholder will be expanded.
+func expandPlaceholderSql(sql string, num int, first, rest string) string {
+	if num <= 0 {
+		panic(fmt.Errorf("Trying to expand SQL placeholder with <= 0 parameters: %s", sql))
+	}
+
+	parameters := first + strings.Repeat(","+rest, num-1)
+
+	return strings.Replace(sql, placeholderSql, parameters, 1)
+}
+
+// numberPlaceholders replaces each '?' in an sql statement with the numbered $n
+// parameter marker used by PostgreSQL. None of our statements contain a literal '?'.
+func numberPlaceholders(sql string) string {
+	var b bytes.Buffer
+	n := 0
+
+	for _, r := range sql {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func decodeSignedTimestamp(ctx context.Context, signedEntryTimestampBytes []byte) (trillian.SignedEntryTimestamp, error) {
+	var signedEntryTimestamp trillian.SignedEntryTimestamp
+
+	if err := proto.Unmarshal(signedEntryTimestampBytes, &signedEntryTimestamp); err != nil {
+		logging.Warningf(ctx, "Failed to decode SignedTimestamp: %s", err)
+		return trillian.SignedEntryTimestamp{}, err
+	}
+
+	return signedEntryTimestamp, nil
+}
+
+func encodeSignedTimestamp(ctx context.Context, signedEntryTimestamp trillian.SignedEntryTimestamp) ([]byte, error) {
+	marshalled, err := proto.Marshal(&signedEntryTimestamp)
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to encode SignedTimestamp: %s", err)
+		return nil, err
+	}
+
+	return marshalled, err
+}
+
+// getStmt creates and caches sql.Stmt structs based on the passed in statement
+// and number of bound arguments.
+func (p *pgTreeStorage) getStmt(ctx context.Context, statement string, num int, first, rest string) (*sql.Stmt, error) {
+	p.statementMutex.Lock()
+	defer p.statementMutex.Unlock()
+
+	if p.statements[statement] != nil {
+		if p.statements[statement][num] != nil {
+			return p.statements[statement][num], nil
+		}
+	} else {
+		p.statements[statement] = make(map[int]*sql.Stmt)
+	}
+
+	s, err := p.db.Prepare(numberPlaceholders(expandPlaceholderSql(statement, num, first, rest)))
+
+	if err != nil {
+		logging.Warningf(ctx, "Failed to prepare statement %d: %s", num, err)
+		return nil, err
+	}
+
+	p.statements[statement][num] = s
+
+	return s, nil
+}
+
+func (p *pgTreeStorage) getSubtreeStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	return p.getStmt(ctx, selectSubtreeSql, num, "?", "?")
+}
+
+func (p *pgTreeStorage) setSubtreeStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	return p.getStmt(ctx, insertSubtreeMultiSql, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
+}
+
+// HashAlgorithm returns the hash algorithm the tree was created with.
+func (p *pgTreeStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return p.hashAlgorithm
+}
+
+// SetSubtreeCacheLimits sets the limits for the subtree caches of transactions started
+// after this call. By default the caches are unlimited.
+func (p *pgTreeStorage) SetSubtreeCacheLimits(limits cache.CacheLimits) {
+	p.cacheLimits = limits
+}
+
+// SetStoreInternalNodes sets whether subtrees written by transactions started after this
+// call are stored with their internal nodes. By default only their leaves are stored.
+func (p *pgTreeStorage) SetStoreInternalNodes(store bool) {
+	p.storeInternalNodes = store
+}
+
+// SetSubtreeKeyVersion sets the version of the suffix key encoding that subtrees written by
+// transactions started after this call use. By default they use storage.SuffixKeyV0.
+func (p *pgTreeStorage) SetSubtreeKeyVersion(version int32) {
+	p.subtreeKeyVersion = version
+}
+
+// SetSharedSubtreeCache sets the cache that transactions started after this call read
+// subtrees through. By default subtrees are only cached by each transaction.
+func (p *pgTreeStorage) SetSharedSubtreeCache(c *cache.SharedSubtreeCache) {
+	p.sharedCache = c
+}
+
+// snapshotTxOptions start the transactions returned by SnapshotForTree. Every read in a
+// REPEATABLE READ transaction sees the snapshot taken by its first one, and being plain
+// reads of a snapshot they don't take any locks.
+var snapshotTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// beginTreeTx starts a transaction traced as a span of ctx, which lasts until the
+// transaction is committed or rolled back. A nil opts uses the driver's defaults.
+func (p *pgTreeStorage) beginTreeTx(ctx context.Context, opts *sql.TxOptions) (treeTX, error) {
+	ctx, span := monitoring.StartSpan(ctx, "postgres.TX", attribute.Int64("treeid", p.treeID))
+	t, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		logging.Warningf(ctx, "Could not start tree TX: %s", err)
+		monitoring.EndSpan(span, err)
+		return treeTX{}, err
+	}
+	subtreeCache := cache.NewSubtreeCacheWithLimits(p.populateSubtree, p.cacheLimits)
+	subtreeCache.SetStoreInternalNodes(p.storeInternalNodes)
+	subtreeCache.SetSubtreeKeyVersion(p.subtreeKeyVersion)
+	return treeTX{
+		tx:            sqltrace.NewTx(t, span, "postgres", sqltrace.Options{}),
+		ctx:           ctx,
+		span:          span,
+		ts:            p,
+		subtreeCache:  subtreeCache,
+		writeRevision: -1,
+		readRevision:  -1,
+		subtreeMutex:  new(sync.Mutex),
+		started:       time.Now(),
+	}, nil
+}
+
+type treeTX struct {
+	closed bool
+	tx     *sqltrace.Tx
+	// ctx is the context the transaction was started with, which cached subtrees are
+	// written back under when it's committed
+	ctx           context.Context
+	span          trace.Span
+	ts            *pgTreeStorage
+	subtreeCache  cache.SubtreeCache
+	writeRevision int64
+	// readRevision is the revision a transaction started by SnapshotForTree is pinned to,
+	// or -1 for other transactions
+	readRevision int64
+	// writtenPrefixes are the prefixes of the subtrees written through the transaction
+	writtenPrefixes [][]byte
+	// storedRoot is true if the root for writeRevision has been stored
+	storedRoot bool
+	// subtreeMutex serializes the subtree reads and writes made on behalf of the
+	// subtree cache, which may happen concurrently, as tx can only be used for one
+	// query at a time.
+	subtreeMutex *sync.Mutex
+	// started is when the transaction began, for recording how long it was open
+	started time.Time
+}
+
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []storage.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]*storage.SubtreeProto, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+
+	_, span := monitoring.StartSpan(t.tx.WithSpan(ctx), "postgres.getSubtrees", attribute.Int("subtrees", len(nodeIDs)))
+	defer span.End()
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	tmpl, err := t.ts.getSubtreeStmt(ctx, len(nodeIDs))
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(ctx, tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(nodeIDs)+3)
+
+	// populate args with nodeIDs
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+
+		nodeIdBytes := nodeID.Path[:nodeID.PrefixLenBits/8]
+
+		args = append(args, interface{}(nodeIdBytes))
+	}
+
+	args = append(args, interface{}(t.ts.treeID))
+	args = append(args, interface{}(treeRevision))
+	args = append(args, interface{}(t.ts.treeID))
+
+	rows, err := stx.Query(ctx, args...)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*storage.SubtreeProto, 0, len(nodeIDs))
+
+	for rows.Next() {
+		var subtreeIDBytes []byte
+		var subtreeRev int64
+		var nodesRaw []byte
+		if err := rows.Scan(&subtreeIDBytes, &subtreeRev, &nodesRaw); err != nil {
+			logging.Warningf(ctx, "Failed to scan merkle subtree: %s", err)
+			return nil, err
+		}
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+			logging.Warningf(ctx, "Failed to unmarshal SubtreeProto: %s", err)
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+	}
+
+	if rows.Err() != nil {
+		logging.Warningf(ctx, "Failed to read merkle subtrees: %s", rows.Err())
+		return nil, rows.Err()
+	}
+
+	// The InternalNodes cache is nil here unless the subtrees were stored with their
+	// internal nodes, otherwise the SubtreeCache (which called this method) will
+	// re-populate it.
+	return ret, nil
+}
+
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storage.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		logging.Warningf(ctx, "attempted to store 0 subtrees...")
+		return nil
+	}
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	args := make([]interface{}, 0, len(subtrees)*4)
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		t.writtenPrefixes = append(t.writtenPrefixes, s.Prefix)
+		// The SubtreeCache has already removed the internal nodes unless it was told to
+		// store them, otherwise they're recalculated when this subtree is read back.
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		args = append(args, t.ts.treeID)
+		args = append(args, s.Prefix)
+		args = append(args, subtreeBytes)
+		args = append(args, t.writeRevision)
+	}
+
+	tmpl, err := t.ts.setSubtreeStmt(ctx, len(subtrees))
+	if err != nil {
+		return err
+	}
+	stx := t.tx.Stmt(ctx, tmpl)
+	defer stx.Close()
+
+	r, err := stx.Exec(ctx, args...)
+	if err != nil {
+		logging.Warningf(ctx, "Failed to set merkle subtrees: %s", err)
+		return err
+	}
+
+	return checkResultOkAndRowCountIs(r, err, int64(len(subtrees)))
+}
+
+func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
+	// The Exec() might have just failed
+	if err != nil {
+		return err
+	}
+
+	// Otherwise we have to look at the result of the operation
+	rowsAffected, rowsError := res.RowsAffected()
+
+	if rowsError != nil {
+		return rowsError
+	}
+
+	if rowsAffected != count {
+		return errors.New(fmt.Sprintf("Expected %d row(s) to be affected but saw: %d", count,
+			rowsAffected))
+	}
+
+	return nil
+}
+
+// PruneSubtrees implements storage.SubtreePruner.
+func (t *treeTX) PruneSubtrees(ctx context.Context, horizon int64) (storage.PruneStats, error) {
+	var stats storage.PruneStats
+	if horizon <= 0 {
+		return stats, nil
+	}
+
+	t.subtreeMutex.Lock()
+	defer t.subtreeMutex.Unlock()
+
+	if err := t.tx.QueryRow(ctx, pruneSubtreesSql, t.ts.treeID, horizon).Scan(&stats.Rows, &stats.Bytes); err != nil {
+		logging.Warningf(ctx, "Failed to delete superseded subtrees: %s", err)
+		return storage.PruneStats{}, err
+	}
+	return stats, nil
+}
+
+// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
+// It is an error to request tree sizes larger than the currently published tree size.
+// This only works for sizes where there is a stored tree head, the same as the MySQL
+// implementation.
+func (t *treeTX) GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error) {
+	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
+	if treeSize <= 0 {
+		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision int64
+	err := t.tx.QueryRow(ctx, selectTreeRevisionAtSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision)
+
+	return treeRevision, err
+}
+
+// getTreeRevisionCoveringSize returns the revision and size of the tree that a snapshot for
+// treeSize is pinned to, the same as the MySQL implementation.
+func (t *treeTX) getTreeRevisionCoveringSize(ctx context.Context, treeSize int64) (int64, int64, error) {
+	if treeSize <= 0 {
+		return 0, 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision, size int64
+	err := t.tx.QueryRow(ctx, selectTreeRevisionCoveringSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision, &size)
+
+	return treeRevision, size, err
+}
+
+// readableShared returns true if reads at treeRevision can go through the shared subtree
+// cache, which they can if the revision was committed before the transaction started
+func (t *treeTX) readableShared(treeRevision int64) bool {
+	if t.readRevision >= 0 {
+		return treeRevision <= t.readRevision
+	}
+	return treeRevision < t.writeRevision
+}
+
+// rootStored records that the root for revision has been stored, so that the shared subtree
+// cache is told about the revision once it's committed
+func (t *treeTX) rootStored(revision int64) {
+	if revision == t.writeRevision {
+		t.storedRoot = true
+	}
+}
+
+// subtreesAt returns a function reading subtrees at treeRevision, through the shared
+// subtree cache if it can be used
+func (t *treeTX) subtreesAt(treeRevision int64) cache.GetSubtreesFunc {
+	getSubtrees := func(ctx context.Context, ids []storage.NodeID) ([]*storage.SubtreeProto, error) {
+		return t.getSubtrees(ctx, treeRevision, ids)
+	}
+	if t.readableShared(treeRevision) {
+		getSubtrees = t.ts.sharedCache.Wrap(t.ts.treeID, treeRevision, t.ts.populateSubtree, getSubtrees)
+	}
+	return getSubtrees
+}
+
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	hashes, err := t.subtreeCache.GetNodeHashes(ctx, nodeIDs, t.subtreesAt(treeRevision))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	for i, h := range hashes {
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeIDs[i],
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) GetProofPath(ctx context.Context, treeRevision int64, leafID storage.NodeID, treeSize int64) ([]storage.Node, error) {
+	return t.subtreeCache.GetProofPath(ctx, leafID, treeSize, t.subtreesAt(treeRevision))
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(ctx, n.NodeID, n.Hash,
+			func(ctx context.Context, nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			},
+			t.storeSubtrees)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeTX) Commit() error {
+	if t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(t.ctx, t.storeSubtrees); err != nil {
+			logging.Warningf(t.ctx, "TX commit flush error: %s", err)
+			t.Rollback()
+			return err
+		}
+	}
+	t.closed = true
+	err := t.tx.Commit()
+	if err == nil && t.storedRoot {
+		t.ts.sharedCache.Committed(t.ts.treeID, t.writeRevision, t.writtenPrefixes)
+	}
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "postgres", "commit")
+	monitoring.EndSpan(t.span, err)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "TX commit error: %s", err)
+	}
+
+	return err
+}
+
+func (t *treeTX) Rollback() error {
+	t.closed = true
+	err := t.tx.Rollback()
+	storage.TXDuration.Observe(time.Since(t.started).Seconds(), "postgres", "rollback")
+	t.span.SetAttributes(attribute.Bool("rollback", true))
+	monitoring.EndSpan(t.span, err)
+
+	if err != nil {
+		logging.Warningf(t.ctx, "TX rollback error: %s", err)
+	}
+
+	return err
+}
+
+// checkTreeState returns storage.ErrTreeDeleted if the tree has been deleted and, for
+// writable transactions, storage.ErrReadOnly if it has been frozen. Trees can be frozen
+// or deleted through the admin API at any time so this is checked whenever a transaction
+// is started.
+func (t *treeTX) checkTreeState(ctx context.Context, write bool) error {
+	var state string
+	var deleted bool
+	err := t.tx.QueryRow(ctx, selectTreeStateSql, t.ts.treeID).Scan(&state, &deleted)
+
+	switch {
+	case err == sql.ErrNoRows:
+		// Storage can currently be opened for trees that don't have a Trees row.
+		return nil
+	case err != nil:
+		logging.Warningf(ctx, "Failed to read state of tree %d: %s", t.ts.treeID, err)
+		return err
+	case deleted:
+		return storage.ErrTreeDeleted
+	case write && state == trillian.TreeState_FROZEN.String():
+		return storage.ErrReadOnly
+	}
+
+	return nil
+}
+
+// ReadRevision returns the tree revision a snapshot transaction is pinned to.
+func (t *treeTX) ReadRevision() int64 {
+	return t.readRevision
+}
+
+func (t *treeTX) IsOpen() bool {
+	return !t.closed
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage/migrate"
+)
+
+// Provider is implemented by storage systems that can create LogStorage and MapStorage
+// instances for individual trees. Implementations make themselves available by calling
+// RegisterProvider, usually from an init() function in their package.
+type Provider interface {
+	// LogStorage returns a LogStorage for the specified log.
+	LogStorage(id trillian.LogID) (LogStorage, error)
+	// MapStorage returns a MapStorage for the specified map.
+	MapStorage(id trillian.MapID) (MapStorage, error)
+	// AdminStorage returns an AdminStorage for managing the metadata of all the trees.
+	AdminStorage() (AdminStorage, error)
+}
+
+// ConnectionConfig limits the connections a Provider makes to its database, how long it
+// waits for them and when it stops sending work to the database because it's failing. Zero
+// values leave the limits of the database driver, which are to open as many connections as
+// there are concurrent transactions and to wait for them, and queries, for as long as the
+// requests they're for allow.
+type ConnectionConfig struct {
+	// MaxOpenConns is the most connections open to the database at once
+	MaxOpenConns int
+	// MaxIdleConns is the most connections kept open while they aren't being used
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection can be reused for before it's closed
+	ConnMaxLifetime time.Duration
+	// QueryTimeout is the longest a transaction waits for a connection and that each query
+	// can run
+	QueryTimeout time.Duration
+	// Breaker says when to shed load with ErrUnhealthy, see CircuitBreaker
+	Breaker CircuitBreakerConfig
+}
+
+// ConnectionConfigSetter is implemented by Providers whose database connections can be
+// limited. It applies to storage created by the Provider after it's called.
+type ConnectionConfigSetter interface {
+	SetConnectionConfig(config ConnectionConfig) error
+}
+
+// SetConnectionConfig limits the connections p makes to its database. It returns an error
+// if p is for a storage system that can't be limited, unless config doesn't set any limits.
+func SetConnectionConfig(p Provider, config ConnectionConfig) error {
+	if setter, ok := p.(ConnectionConfigSetter); ok {
+		return setter.SetConnectionConfig(config)
+	}
+
+	if config != (ConnectionConfig{}) {
+		return fmt.Errorf("storage: provider %T doesn't support connection limits", p)
+	}
+
+	return nil
+}
+
+// SubtreeShardSetter is implemented by Providers that can spread the subtrees of a tree over
+// other databases, so that the tree isn't limited by the capacity of one. Which shards a tree
+// uses is kept with its metadata, by name, and shards maps those names to the DSNs of the
+// databases, which are in the same format as the Provider's own. It applies to storage
+// created by the Provider after it's called.
+type SubtreeShardSetter interface {
+	SetSubtreeShards(shards map[string]string) error
+}
+
+// SetSubtreeShards tells p where the subtree shards named in tree metadata are. It returns an
+// error if p is for a storage system that can't shard subtrees, unless shards is empty.
+func SetSubtreeShards(p Provider, shards map[string]string) error {
+	if setter, ok := p.(SubtreeShardSetter); ok {
+		return setter.SetSubtreeShards(shards)
+	}
+
+	if len(shards) != 0 {
+		return fmt.Errorf("storage: provider %T doesn't support subtree shards", p)
+	}
+
+	return nil
+}
+
+// ParseSubtreeShards parses a comma separated list of name=dsn subtree shards, as passed to
+// SetSubtreeShards. Only the first = of each separates the name, so DSNs can contain them.
+func ParseSubtreeShards(list string) (map[string]string, error) {
+	shards := make(map[string]string)
+	if len(list) == 0 {
+		return shards, nil
+	}
+
+	for _, shard := range strings.Split(list, ",") {
+		parts := strings.SplitN(shard, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("storage: subtree shard %q isn't of the form name=dsn", shard)
+		}
+
+		if _, exists := shards[parts[0]]; exists {
+			return nil, fmt.Errorf("storage: subtree shard %s given more than once", parts[0])
+		}
+
+		shards[parts[0]] = parts[1]
+	}
+
+	return shards, nil
+}
+
+// Migratable is implemented by Providers whose database schema can be upgraded in place.
+// Migrations returns the database to migrate and all the migrations for the storage system,
+// for use with the migrate package.
+type Migratable interface {
+	Migrations() (migrate.Database, []migrate.Migration, error)
+}
+
+// Migrations returns the database of p and the migrations for its storage system. It returns
+// an error if p is for a storage system that doesn't support migrations.
+func Migrations(p Provider) (migrate.Database, []migrate.Migration, error) {
+	if m, ok := p.(Migratable); ok {
+		return m.Migrations()
+	}
+
+	return nil, nil, fmt.Errorf("storage: provider %T doesn't support schema migrations", p)
+}
+
+// NewProviderFunc creates a Provider connected to the storage described by dsn. The format
+// of dsn is specific to the storage system.
+type NewProviderFunc func(dsn string) (Provider, error)
+
+var providersMutex sync.RWMutex
+var providers = make(map[string]NewProviderFunc)
+
+// RegisterProvider makes a storage system available by the provided name. It returns an
+// error if a storage system has already been registered with the same name.
+func RegisterProvider(name string, f NewProviderFunc) error {
+	if f == nil {
+		return fmt.Errorf("storage: nil NewProviderFunc for provider %s", name)
+	}
+
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	if _, exists := providers[name]; exists {
+		return fmt.Errorf("storage: provider %s already registered", name)
+	}
+
+	providers[name] = f
+	return nil
+}
+
+// NewProvider creates a Provider using the storage system registered under name, connected
+// to the storage described by dsn.
+func NewProvider(name, dsn string) (Provider, error) {
+	providersMutex.RLock()
+	f, ok := providers[name]
+	providersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("storage: unknown provider %s", name)
+	}
+
+	return f(dsn)
+}
+
+// Providers returns the sorted names of all registered storage systems.
+func Providers() []string {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+type fakeProvider struct {
+	dsn string
+}
+
+func (f fakeProvider) LogStorage(id trillian.LogID) (LogStorage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fakeProvider) MapStorage(id trillian.MapID) (MapStorage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fakeProvider) AdminStorage() (AdminStorage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newFakeProvider(dsn string) (Provider, error) {
+	return fakeProvider{dsn: dsn}, nil
+}
+
+func TestRegisterProviderRejectsDuplicates(t *testing.T) {
+	if err := RegisterProvider("TestRegisterProviderRejectsDuplicates", newFakeProvider); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	if err := RegisterProvider("TestRegisterProviderRejectsDuplicates", newFakeProvider); err == nil {
+		t.Fatal("Registered a duplicate provider name")
+	}
+}
+
+func TestRegisterProviderRejectsNil(t *testing.T) {
+	if err := RegisterProvider("TestRegisterProviderRejectsNil", nil); err == nil {
+		t.Fatal("Registered a nil provider func")
+	}
+
+	for _, name := range Providers() {
+		if name == "TestRegisterProviderRejectsNil" {
+			t.Fatal("Nil provider func was registered")
+		}
+	}
+}
+
+func TestNewProviderUnknownName(t *testing.T) {
+	_, err := NewProvider("TestNewProviderUnknownName", "dsn")
+
+	if err == nil || !strings.Contains(err.Error(), "TestNewProviderUnknownName") {
+		t.Fatalf("Expected unknown provider error but got: %v", err)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	if err := RegisterProvider("TestNewProvider", newFakeProvider); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	p, err := NewProvider("TestNewProvider", "a dsn")
+
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	if expected, got := (fakeProvider{dsn: "a dsn"}), p; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("Expected provider %v but got %v", expected, got)
+	}
+
+	found := false
+	for _, name := range Providers() {
+		if name == "TestNewProvider" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("Registered provider missing from Providers(): %v", Providers())
+	}
+}
+
+type limitedProvider struct {
+	fakeProvider
+	config ConnectionConfig
+}
+
+func (l *limitedProvider) SetConnectionConfig(config ConnectionConfig) error {
+	l.config = config
+	return nil
+}
+
+func TestSetConnectionConfig(t *testing.T) {
+	config := ConnectionConfig{MaxOpenConns: 10, QueryTimeout: time.Second}
+
+	p := &limitedProvider{}
+
+	if err := SetConnectionConfig(p, config); err != nil || p.config != config {
+		t.Fatalf("Got config %+v and error %v, want %+v", p.config, err, config)
+	}
+
+	if err := SetConnectionConfig(fakeProvider{}, config); err == nil {
+		t.Fatal("Set connection limits on a provider that doesn't support them")
+	}
+
+	if err := SetConnectionConfig(fakeProvider{}, ConnectionConfig{}); err != nil {
+		t.Fatalf("Failed to set no connection limits: %v", err)
+	}
+}
+
+type shardedProvider struct {
+	fakeProvider
+	shards map[string]string
+}
+
+func (s *shardedProvider) SetSubtreeShards(shards map[string]string) error {
+	s.shards = shards
+	return nil
+}
+
+func TestSetSubtreeShards(t *testing.T) {
+	shards := map[string]string{"a": "dsn-a"}
+
+	p := &shardedProvider{}
+
+	if err := SetSubtreeShards(p, shards); err != nil || !reflect.DeepEqual(p.shards, shards) {
+		t.Fatalf("Got shards %v and error %v, want %v", p.shards, err, shards)
+	}
+
+	if err := SetSubtreeShards(fakeProvider{}, shards); err == nil {
+		t.Fatal("Set subtree shards on a provider that doesn't support them")
+	}
+
+	if err := SetSubtreeShards(fakeProvider{}, nil); err != nil {
+		t.Fatalf("Failed to set no subtree shards: %v", err)
+	}
+}
+
+func TestParseSubtreeShards(t *testing.T) {
+	for _, test := range []struct {
+		list    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{list: "", want: map[string]string{}},
+		{list: "a=user:pw@tcp(a:3306)/trees", want: map[string]string{"a": "user:pw@tcp(a:3306)/trees"}},
+		{list: "a=dsn-a,b=dsn-b?x=y", want: map[string]string{"a": "dsn-a", "b": "dsn-b?x=y"}},
+		{list: "a", wantErr: true},
+		{list: "=dsn", wantErr: true},
+		{list: "a=", wantErr: true},
+		{list: "a=dsn,", wantErr: true},
+		{list: "a=dsn-1,a=dsn-2", wantErr: true},
+	} {
+		got, err := ParseSubtreeShards(test.list)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseSubtreeShards(%q)=%v, want error", test.list, got)
+			}
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseSubtreeShards(%q)=%v, %v, want %v", test.list, got, err, test.want)
+		}
+	}
+}
+
+func TestMigrationsNotSupported(t *testing.T) {
+	if _, _, err := Migrations(fakeProvider{}); err == nil {
+		t.Fatal("Got migrations from a provider that doesn't support them")
+	}
+}
@@ -0,0 +1,216 @@
+// Package sqltrace wraps SQL transactions so that each statement run through them is traced
+// as part of the transaction, and abandoned if the context of the request it's run for is
+// cancelled or it runs for longer than the transaction's query timeout.
+package sqltrace
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// Options limit and observe the statements run through a Tx
+type Options struct {
+	// QueryTimeout is the longest each statement can run for. If it's 0 statements are only
+	// abandoned when the context they're run with is done.
+	QueryTimeout time.Duration
+	// Done is called, if it's set, with the context each statement was run with and its
+	// outcome. For queries that return rows that's whether they started, errors reading the
+	// rows aren't included.
+	Done func(ctx context.Context, err error)
+}
+
+// Tx is a sql.Tx whose statements are traced. Statements prepared outside the transaction
+// and used with Stmt are traced without their text.
+type Tx struct {
+	*sql.Tx
+	span   trace.Span
+	system string
+	opts   Options
+}
+
+// NewTx wraps tx so its statements are traced as children of span, which should cover
+// the whole transaction, and limited by opts. system names the database, e.g. mysql.
+func NewTx(tx *sql.Tx, span trace.Span, system string, opts Options) *Tx {
+	return &Tx{Tx: tx, span: span, system: system, opts: opts}
+}
+
+// WithSpan returns a context for work done as part of the transaction on behalf of a
+// request with context ctx. It's cancelled along with ctx, and spans started with it are
+// children of the transaction's span.
+func (t *Tx) WithSpan(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(ctx, t.span)
+}
+
+// statement is a statement that's being run through a Tx
+type statement struct {
+	tx *Tx
+	// ctx is the context the statement was run with, before its timeout was added
+	ctx    context.Context
+	span   trace.Span
+	cancel context.CancelFunc
+}
+
+// startStatement returns the context to run a statement with and the statement, which must
+// be finished once it's done
+func (t *Tx) startStatement(ctx context.Context, op, query string) (context.Context, *statement) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", t.system)}
+
+	if len(query) > 0 {
+		attrs = append(attrs, attribute.String("db.statement", query))
+	}
+
+	s := &statement{tx: t, ctx: ctx, cancel: func() {}}
+	stmtCtx, span := monitoring.StartSpan(t.WithSpan(ctx), t.system+"."+op, attrs...)
+	s.span = span
+
+	if t.opts.QueryTimeout > 0 {
+		stmtCtx, s.cancel = context.WithTimeout(stmtCtx, t.opts.QueryTimeout)
+	}
+
+	return stmtCtx, s
+}
+
+// end ends the statement's span and reports its outcome, but leaves its context running
+// for rows that are still to be read
+func (s *statement) end(err error) {
+	monitoring.EndSpan(s.span, err)
+
+	if s.tx.opts.Done != nil {
+		s.tx.opts.Done(s.ctx, err)
+	}
+}
+
+// finish ends the statement and releases its context
+func (s *statement) finish(err error) {
+	s.end(err)
+	s.cancel()
+}
+
+// rows returns the rows of a query, which release its context when they're done with
+func (s *statement) rows(rows *sql.Rows, err error) (*Rows, error) {
+	s.end(err)
+
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+
+	return &Rows{Rows: rows, cancel: s.cancel}, nil
+}
+
+// Exec runs a statement that doesn't return rows
+func (t *Tx) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, s := t.startStatement(ctx, "Exec", query)
+	r, err := t.Tx.ExecContext(ctx, query, args...)
+	s.finish(err)
+	return r, err
+}
+
+// Query runs a statement that returns rows. The span ends before the rows are read.
+func (t *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, s := t.startStatement(ctx, "Query", query)
+	return s.rows(t.Tx.QueryContext(ctx, query, args...))
+}
+
+// QueryRow runs a statement that returns at most one row. Errors are reported when the row
+// is scanned, so the span doesn't record them.
+func (t *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
+	ctx, s := t.startStatement(ctx, "QueryRow", query)
+	row := t.Tx.QueryRowContext(ctx, query, args...)
+	s.span.End()
+	return &Row{row: row, stmt: s}
+}
+
+// Prepare creates a prepared statement for use within the transaction
+func (t *Tx) Prepare(ctx context.Context, query string) (*Stmt, error) {
+	s, err := t.Tx.PrepareContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{Stmt: s, tx: t, query: query}, nil
+}
+
+// Stmt returns a transaction specific version of a statement prepared on the database
+func (t *Tx) Stmt(ctx context.Context, s *sql.Stmt) *Stmt {
+	return &Stmt{Stmt: t.Tx.StmtContext(ctx, s), tx: t}
+}
+
+// Stmt is a prepared statement whose executions are traced as part of its transaction
+type Stmt struct {
+	*sql.Stmt
+	tx *Tx
+	// query is the statement text if it's known
+	query string
+}
+
+// Exec runs the statement, which doesn't return rows
+func (s *Stmt) Exec(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	ctx, stmt := s.tx.startStatement(ctx, "Exec", s.query)
+	r, err := s.Stmt.ExecContext(ctx, args...)
+	stmt.finish(err)
+	return r, err
+}
+
+// Query runs the statement, which returns rows. The span ends before the rows are read.
+func (s *Stmt) Query(ctx context.Context, args ...interface{}) (*Rows, error) {
+	ctx, stmt := s.tx.startStatement(ctx, "Query", s.query)
+	return stmt.rows(s.Stmt.QueryContext(ctx, args...))
+}
+
+// QueryRow runs the statement, which returns at most one row
+func (s *Stmt) QueryRow(ctx context.Context, args ...interface{}) *Row {
+	ctx, stmt := s.tx.startStatement(ctx, "QueryRow", s.query)
+	row := s.Stmt.QueryRowContext(ctx, args...)
+	stmt.span.End()
+	return &Row{row: row, stmt: stmt}
+}
+
+// Rows are the results of a query. The query's timeout is released once they've all been
+// read or they're closed.
+type Rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+// Next prepares the next row for reading, see sql.Rows
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+
+	r.cancel()
+	return false
+}
+
+// Close closes the rows, see sql.Rows
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+// Row is the result of a query for a single row
+type Row struct {
+	row  *sql.Row
+	stmt *statement
+}
+
+// Scan copies the columns of the row into dest, see sql.Row. The outcome of the query is
+// reported when it's scanned.
+func (r *Row) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+
+	if r.stmt.tx.opts.Done != nil {
+		r.stmt.tx.opts.Done(r.stmt.ctx, err)
+	}
+
+	r.stmt.cancel()
+	return err
+}
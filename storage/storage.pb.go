@@ -11,6 +11,7 @@ It is generated from these files:
 It has these top-level messages:
 	NodeIDProto
 	SubtreeProto
+	CompactRangeProto
 */
 package storage
 
@@ -54,6 +55,9 @@ type SubtreeProto struct {
 	// This structure is only used in RAM as a cache, the internal nodes of
 	// the subtree are not generally stored.
 	InternalNodes map[string][]byte `protobuf:"bytes,5,rep,name=internal_nodes,json=internalNodes" json:"internal_nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Version of the encoding of the keys of leaves and internal_nodes, see
+	// storage/suffix_key.go. Subtrees written before keys were versioned use 0.
+	KeyVersion int32 `protobuf:"varint,6,opt,name=key_version,json=keyVersion" json:"key_version,omitempty"`
 }
 
 func (m *SubtreeProto) Reset()                    { *m = SubtreeProto{} }
@@ -75,32 +79,55 @@ func (m *SubtreeProto) GetInternalNodes() map[string][]byte {
 	return nil
 }
 
+// CompactRangeProto is the serialized form of a compact range: the roots of the perfect
+// subtrees that cover the leaves in [begin, end) with as few subtrees as possible. A range
+// that starts at 0 is the state of a compact merkle tree of size end.
+type CompactRangeProto struct {
+	// index of the first leaf in the range
+	Begin int64 `protobuf:"varint,1,opt,name=begin" json:"begin,omitempty"`
+	// index of the leaf after the last one in the range
+	End int64 `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+	// subtree root hashes, ordered from left to right
+	Hashes [][]byte `protobuf:"bytes,3,rep,name=hashes,proto3" json:"hashes,omitempty"`
+}
+
+func (m *CompactRangeProto) Reset()                    { *m = CompactRangeProto{} }
+func (m *CompactRangeProto) String() string            { return proto.CompactTextString(m) }
+func (*CompactRangeProto) ProtoMessage()               {}
+func (*CompactRangeProto) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
 func init() {
 	proto.RegisterType((*NodeIDProto)(nil), "storage.NodeIDProto")
 	proto.RegisterType((*SubtreeProto)(nil), "storage.SubtreeProto")
+	proto.RegisterType((*CompactRangeProto)(nil), "storage.CompactRangeProto")
 }
 
 func init() { proto.RegisterFile("github.com/google/trillian/storage/storage.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 304 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x91, 0xdf, 0x4a, 0xc3, 0x30,
-	0x14, 0xc6, 0xd9, 0x5f, 0xdd, 0xd9, 0xa6, 0x12, 0x44, 0xca, 0xbc, 0x99, 0xbb, 0x90, 0x5e, 0xb5,
-	0xa2, 0x37, 0xce, 0x2b, 0x11, 0x05, 0x0b, 0x43, 0xa5, 0x3e, 0x40, 0x49, 0xdd, 0xb1, 0x0d, 0xc6,
-	0xa4, 0xa4, 0xe9, 0x70, 0xaf, 0xeb, 0x93, 0xd8, 0x26, 0x19, 0x14, 0xf4, 0xc6, 0xab, 0x9c, 0xef,
-	0xcb, 0x39, 0xbf, 0xc3, 0x97, 0xc0, 0x45, 0xc6, 0x74, 0x5e, 0xa5, 0xc1, 0x9b, 0xfc, 0x0c, 0x33,
-	0x29, 0x33, 0x8e, 0xa1, 0x56, 0x8c, 0x73, 0x46, 0x45, 0x58, 0x6a, 0xa9, 0x68, 0x86, 0xbb, 0x33,
-	0x28, 0x94, 0xd4, 0x92, 0xec, 0x39, 0xb9, 0x88, 0x60, 0xfc, 0x24, 0xd7, 0x18, 0xdd, 0xbf, 0x18,
-	0x9f, 0x40, 0xbf, 0xa0, 0x3a, 0xf7, 0x3a, 0xf3, 0x8e, 0x3f, 0x89, 0x4d, 0x4d, 0xce, 0xe1, 0xb0,
-	0x50, 0xf8, 0xce, 0xbe, 0x12, 0x8e, 0x22, 0x49, 0x99, 0x2e, 0xbd, 0x6e, 0x7d, 0x3d, 0x88, 0xa7,
-	0xd6, 0x5e, 0xa1, 0xb8, 0xab, 0xcd, 0xc5, 0x77, 0x17, 0x26, 0xaf, 0x55, 0xaa, 0x15, 0xa2, 0x85,
-	0x9d, 0xc0, 0xd0, 0x76, 0x38, 0x9c, 0x53, 0xe4, 0x18, 0x06, 0x6b, 0x2c, 0xea, 0x2d, 0x16, 0x63,
-	0x05, 0x39, 0x85, 0x91, 0x92, 0x52, 0x27, 0x39, 0x2d, 0x73, 0xaf, 0x67, 0x06, 0xf6, 0x1b, 0xe3,
-	0xb1, 0xd6, 0x64, 0x09, 0x43, 0x8e, 0x74, 0x83, 0xa5, 0xd7, 0x9f, 0xf7, 0xfc, 0xf1, 0xe5, 0x59,
-	0xb0, 0xcb, 0xd3, 0xde, 0x18, 0xac, 0x4c, 0xcf, 0x83, 0xd0, 0x6a, 0x1b, 0xbb, 0x01, 0xf2, 0x0c,
-	0x07, 0x4c, 0x68, 0x54, 0x82, 0xf2, 0x44, 0xd4, 0x51, 0x4b, 0x6f, 0x60, 0x10, 0xfe, 0xdf, 0x88,
-	0xc8, 0xf5, 0x36, 0xaf, 0xe2, 0x48, 0x53, 0xd6, 0xf6, 0x66, 0x4b, 0x18, 0xb7, 0xf6, 0x90, 0x23,
-	0xe8, 0x7d, 0xe0, 0xd6, 0x44, 0x1c, 0xc5, 0x4d, 0xd9, 0xe4, 0xdb, 0x50, 0x5e, 0xa1, 0xc9, 0x37,
-	0x89, 0xad, 0xb8, 0xe9, 0x5e, 0x77, 0x66, 0xb7, 0x40, 0x7e, 0xf3, 0xff, 0x43, 0x48, 0x87, 0xe6,
-	0xff, 0xae, 0x7e, 0x02, 0x00, 0x00, 0xff, 0xff, 0xce, 0x67, 0x36, 0x28, 0xf3, 0x01, 0x00, 0x00,
+	// 371 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0xcb, 0x8b, 0xd4, 0x40,
+	0x10, 0xc6, 0xc9, 0xf6, 0x26, 0xba, 0x95, 0x59, 0x1f, 0xcd, 0x22, 0x61, 0x3d, 0x18, 0xe7, 0x20,
+	0x39, 0x65, 0x44, 0x2f, 0xae, 0x27, 0xf1, 0x01, 0x0e, 0x2c, 0x2a, 0xbd, 0xe0, 0x35, 0x74, 0x26,
+	0x65, 0xd2, 0x4c, 0xa6, 0x3b, 0x74, 0xf7, 0x0c, 0xe6, 0xec, 0x3f, 0x2e, 0xfd, 0x18, 0x18, 0xd0,
+	0xcb, 0x9e, 0x52, 0xdf, 0x47, 0xf5, 0x2f, 0x5d, 0x5f, 0x35, 0xbc, 0xee, 0x85, 0x1d, 0xf6, 0x6d,
+	0xbd, 0x51, 0xbb, 0x55, 0xaf, 0x54, 0x3f, 0xe2, 0xca, 0x6a, 0x31, 0x8e, 0x82, 0xcb, 0x95, 0xb1,
+	0x4a, 0xf3, 0x1e, 0x8f, 0xdf, 0x7a, 0xd2, 0xca, 0x2a, 0xfa, 0x20, 0xca, 0xe5, 0x1a, 0xf2, 0x6f,
+	0xaa, 0xc3, 0xf5, 0xe7, 0x1f, 0xde, 0xa7, 0x70, 0x3e, 0x71, 0x3b, 0x14, 0x49, 0x99, 0x54, 0x0b,
+	0xe6, 0x6b, 0xfa, 0x0a, 0x1e, 0x4f, 0x1a, 0x7f, 0x89, 0xdf, 0xcd, 0x88, 0xb2, 0x69, 0x85, 0x35,
+	0xc5, 0x59, 0x99, 0x54, 0x29, 0xbb, 0x0c, 0xf6, 0x2d, 0xca, 0x8f, 0xc2, 0x9a, 0xe5, 0x1f, 0x02,
+	0x8b, 0xbb, 0x7d, 0x6b, 0x35, 0x62, 0x80, 0x3d, 0x83, 0x2c, 0x74, 0x44, 0x5c, 0x54, 0xf4, 0x0a,
+	0xd2, 0x0e, 0x27, 0x3b, 0x44, 0x4c, 0x10, 0xf4, 0x39, 0x5c, 0x68, 0xa5, 0x6c, 0x33, 0x70, 0x33,
+	0x14, 0xc4, 0x1f, 0x78, 0xe8, 0x8c, 0xaf, 0xdc, 0x0c, 0xf4, 0x06, 0xb2, 0x11, 0xf9, 0x01, 0x4d,
+	0x71, 0x5e, 0x92, 0x2a, 0x7f, 0xf3, 0xb2, 0x3e, 0xce, 0x73, 0xfa, 0xc7, 0xfa, 0xd6, 0xf7, 0x7c,
+	0x91, 0x56, 0xcf, 0x2c, 0x1e, 0xa0, 0xdf, 0xe1, 0x91, 0x90, 0x16, 0xb5, 0xe4, 0x63, 0x23, 0x55,
+	0x87, 0xa6, 0x48, 0x3d, 0xa2, 0xfa, 0x3f, 0x62, 0x1d, 0x7b, 0x5d, 0x2a, 0x91, 0x74, 0x29, 0x4e,
+	0x3d, 0xfa, 0x02, 0xf2, 0x2d, 0xce, 0xcd, 0x01, 0xb5, 0x11, 0x4a, 0x16, 0x99, 0x1f, 0x02, 0xb6,
+	0x38, 0xff, 0x0c, 0xce, 0xf5, 0x0d, 0xe4, 0x27, 0x17, 0xa1, 0x4f, 0x80, 0x6c, 0x71, 0xf6, 0x19,
+	0x5c, 0x30, 0x57, 0xba, 0x00, 0x0e, 0x7c, 0xdc, 0xa3, 0x0f, 0x60, 0xc1, 0x82, 0x78, 0x7f, 0xf6,
+	0x2e, 0xb9, 0xfe, 0x00, 0xf4, 0xdf, 0x0b, 0xdc, 0x87, 0xb0, 0xbc, 0x83, 0xa7, 0x9f, 0xd4, 0x6e,
+	0xe2, 0x1b, 0xcb, 0xb8, 0xec, 0xe3, 0x26, 0xae, 0x20, 0x6d, 0xb1, 0x17, 0xd2, 0x23, 0x08, 0x0b,
+	0xc2, 0x61, 0x51, 0x76, 0x1e, 0x41, 0x98, 0x2b, 0xdd, 0xc6, 0x5c, 0xfc, 0x68, 0x0a, 0x52, 0x12,
+	0xb7, 0xb1, 0xa0, 0xda, 0xcc, 0xbf, 0x9a, 0xb7, 0x7f, 0x07, 0x00, 0x87, 0xec, 0xdd, 0x41, 0x69,
+	0x02, 0x00, 0x00,
 }
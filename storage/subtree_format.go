@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// SubtreeFormat is a way of writing a SubtreeProto outside of storage, e.g. for test
+// fixtures and exported subtrees.
+type SubtreeFormat string
+
+const (
+	// SubtreeFormatBinary is the binary protocol buffer encoding, with map entries sorted
+	// by key so the same subtree is always written the same way.
+	SubtreeFormatBinary SubtreeFormat = "binary"
+	// SubtreeFormatCanonical is a JSON encoding with hex encoded hashes and keys in sorted
+	// order. It doesn't depend on the protocol buffer library, so it's stable across
+	// versions and languages, and it's readable and diffs well.
+	SubtreeFormatCanonical SubtreeFormat = "canonical"
+	// SubtreeFormatText is the protocol buffer text format. It's only supported so older
+	// files can be converted, it isn't guaranteed to be stable.
+	SubtreeFormatText SubtreeFormat = "text"
+)
+
+// canonicalSubtree is the structure of the canonical JSON encoding of a SubtreeProto. The
+// map keys are the suffix keys of the subtree's key version and the values are hex encoded.
+type canonicalSubtree struct {
+	Prefix        string            `json:"prefix"`
+	Depth         int32             `json:"depth"`
+	RootHash      string            `json:"root_hash,omitempty"`
+	Leaves        map[string]string `json:"leaves,omitempty"`
+	InternalNodes map[string]string `json:"internal_nodes,omitempty"`
+	KeyVersion    int32             `json:"key_version,omitempty"`
+}
+
+// MarshalSubtree writes st in the specified format.
+func MarshalSubtree(st *SubtreeProto, format SubtreeFormat) ([]byte, error) {
+	switch format {
+	case SubtreeFormatBinary:
+		var b proto.Buffer
+		b.SetDeterministic(true)
+		if err := b.Marshal(st); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case SubtreeFormatCanonical:
+		c := canonicalSubtree{
+			Prefix:        hex.EncodeToString(st.Prefix),
+			Depth:         st.Depth,
+			RootHash:      hex.EncodeToString(st.RootHash),
+			Leaves:        hexEncodeNodes(st.Leaves),
+			InternalNodes: hexEncodeNodes(st.InternalNodes),
+			KeyVersion:    st.KeyVersion,
+		}
+		// encoding/json writes map keys in sorted order
+		b, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case SubtreeFormatText:
+		var b bytes.Buffer
+		if err := proto.MarshalText(&b, st); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown subtree format: %q", format)
+	}
+}
+
+// UnmarshalSubtree reads a subtree written in the specified format.
+func UnmarshalSubtree(data []byte, format SubtreeFormat) (*SubtreeProto, error) {
+	st := &SubtreeProto{}
+
+	switch format {
+	case SubtreeFormatBinary:
+		if err := proto.Unmarshal(data, st); err != nil {
+			return nil, err
+		}
+	case SubtreeFormatCanonical:
+		var c canonicalSubtree
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		var err error
+		if st.Prefix, err = hex.DecodeString(c.Prefix); err != nil {
+			return nil, fmt.Errorf("invalid subtree prefix: %v", err)
+		}
+		if st.RootHash, err = hex.DecodeString(c.RootHash); err != nil {
+			return nil, fmt.Errorf("invalid subtree root hash: %v", err)
+		}
+		if len(st.RootHash) == 0 {
+			st.RootHash = nil
+		}
+		st.Depth = c.Depth
+		st.KeyVersion = c.KeyVersion
+		if st.Leaves, err = hexDecodeNodes(c.Leaves); err != nil {
+			return nil, fmt.Errorf("invalid subtree leaf: %v", err)
+		}
+		if st.InternalNodes, err = hexDecodeNodes(c.InternalNodes); err != nil {
+			return nil, fmt.Errorf("invalid subtree internal node: %v", err)
+		}
+	case SubtreeFormatText:
+		if err := proto.UnmarshalText(string(data), st); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown subtree format: %q", format)
+	}
+
+	return st, nil
+}
+
+func hexEncodeNodes(nodes map[string][]byte) map[string]string {
+	if len(nodes) == 0 {
+		return nil
+	}
+	r := make(map[string]string, len(nodes))
+	for k, v := range nodes {
+		r[k] = hex.EncodeToString(v)
+	}
+	return r
+}
+
+func hexDecodeNodes(nodes map[string]string) (map[string][]byte, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	r := make(map[string][]byte, len(nodes))
+	for k, v := range nodes {
+		h, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		r[k] = h
+	}
+	return r, nil
+}
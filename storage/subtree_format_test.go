@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func testSubtree() *SubtreeProto {
+	return &SubtreeProto{
+		Prefix:        []byte{0x01, 0x02},
+		Depth:         8,
+		RootHash:      []byte{0xaa, 0xbb},
+		Leaves:        map[string][]byte{"CAA=": {0x01}, "CAE=": {0x02}, "CAI=": {0x03}},
+		InternalNodes: map[string][]byte{"BwA=": {0x04}, "BQA=": {0xaa, 0xbb}},
+	}
+}
+
+func TestSubtreeFormatRoundTrip(t *testing.T) {
+	for _, format := range []SubtreeFormat{SubtreeFormatBinary, SubtreeFormatCanonical, SubtreeFormatText} {
+		v1 := testSubtree()
+		if err := ConvertSubtreeKeys(v1, SuffixKeyV1); err != nil {
+			t.Fatalf("failed to convert subtree keys: %v", err)
+		}
+		for _, st := range []*SubtreeProto{testSubtree(), v1, {Prefix: []byte{}, Depth: 8}} {
+			b, err := MarshalSubtree(st, format)
+			if err != nil {
+				t.Fatalf("%s: failed to marshal subtree: %v", format, err)
+			}
+
+			got, err := UnmarshalSubtree(b, format)
+			if err != nil {
+				t.Fatalf("%s: failed to unmarshal subtree: %v", format, err)
+			}
+
+			if !proto.Equal(got, st) {
+				t.Errorf("%s: got subtree %v after round trip, expected %v", format, got, st)
+			}
+		}
+	}
+}
+
+func TestSubtreeFormatIsDeterministic(t *testing.T) {
+	for _, format := range []SubtreeFormat{SubtreeFormatBinary, SubtreeFormatCanonical} {
+		want, err := MarshalSubtree(testSubtree(), format)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal subtree: %v", format, err)
+		}
+
+		// Map iteration order is random so a few attempts should catch unsorted output
+		for i := 0; i < 20; i++ {
+			got, err := MarshalSubtree(testSubtree(), format)
+			if err != nil {
+				t.Fatalf("%s: failed to marshal subtree: %v", format, err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: marshalled the same subtree as %x and %x", format, got, want)
+			}
+		}
+	}
+}
+
+func TestUnmarshalCanonicalSubtreeRejectsBadHex(t *testing.T) {
+	tests := []string{
+		`{"prefix": "zz", "depth": 8}`,
+		`{"prefix": "", "depth": 8, "root_hash": "abc"}`,
+		`{"prefix": "", "depth": 8, "leaves": {"CAA=": "xx"}}`,
+		`{"prefix": "", "depth": 8, "internal_nodes": {"BwA=": "xx"}}`,
+	}
+
+	for _, test := range tests {
+		if st, err := UnmarshalSubtree([]byte(test), SubtreeFormatCanonical); err == nil {
+			t.Errorf("Unmarshalled %s as %v, expected an error", test, st)
+		}
+	}
+}
+
+func TestSubtreeFormatRejectsUnknownFormat(t *testing.T) {
+	if _, err := MarshalSubtree(testSubtree(), "xml"); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Got error %v marshalling to an unknown format, expected one about the format", err)
+	}
+
+	if _, err := UnmarshalSubtree([]byte{}, "xml"); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Got error %v unmarshalling an unknown format, expected one about the format", err)
+	}
+}
+
+func TestCanonicalSubtreeFixturesAreCanonical(t *testing.T) {
+	for _, file := range []string{"log_good_subtree_5.json", "log_good_subtree_55.json", "map_good_subtree.json"} {
+		b, err := ioutil.ReadFile("../testdata/" + file)
+		if err != nil {
+			t.Fatalf("Failed to read test data: %v", err)
+		}
+
+		st, err := UnmarshalSubtree(b, SubtreeFormatCanonical)
+		if err != nil {
+			t.Fatalf("%s: failed to unmarshal subtree: %v", file, err)
+		}
+
+		got, err := MarshalSubtree(st, SubtreeFormatCanonical)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal subtree: %v", file, err)
+		}
+
+		if !bytes.Equal(got, b) {
+			t.Errorf("%s isn't in canonical form, regenerate it with cmd/subtree_convert", file)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// A suffix key identifies a node within a subtree in the Leaves and InternalNodes maps of
+// a SubtreeProto. It encodes two bytes: the number of bits from the root of the subtree
+// down to the node, from 1 to 8, and those bits, left aligned with the rest zero. So the
+// node reached by going right, left then right from the root is {3, 0xa0}, and a leaf of
+// a depth 8 subtree is {8, index}.
+//
+// The encoding of the keys is versioned by the KeyVersion of the subtree, so that it can
+// change without rewriting the subtrees already stored. Subtrees of every version can be
+// read, and are converted to the version used in memory as they are.
+const (
+	// SuffixKeyV0 is the standard base64 encoding of the two bytes, e.g. "Ba4=" for
+	// {5, 0xae}. It's what subtrees were written with before keys were versioned, so
+	// subtrees without a KeyVersion use it.
+	SuffixKeyV0 int32 = 0
+	// SuffixKeyV1 is the lowercase hex encoding of the two bytes, e.g. "05ae" for
+	// {5, 0xae}. Keys sort by depth and then from left to right, and are readable in dumps
+	// of the subtree.
+	SuffixKeyV1 int32 = 1
+	// MaxSuffixKeyVersion is the newest version that can be read and written.
+	MaxSuffixKeyVersion = SuffixKeyV1
+)
+
+// EncodeSuffixKey returns the key of the suffix with the given bits and path in version of
+// the encoding.
+func EncodeSuffixKey(version int32, bits, path byte) (string, error) {
+	b := []byte{bits, path}
+	switch version {
+	case SuffixKeyV0:
+		return base64.StdEncoding.EncodeToString(b), nil
+	case SuffixKeyV1:
+		return hex.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("unknown suffix key version %d", version)
+	}
+}
+
+// DecodeSuffixKey returns the bits and path of a suffix key in version of the encoding.
+func DecodeSuffixKey(version int32, key string) (byte, byte, error) {
+	var b []byte
+	var err error
+	switch version {
+	case SuffixKeyV0:
+		b, err = base64.StdEncoding.DecodeString(key)
+	case SuffixKeyV1:
+		b, err = hex.DecodeString(key)
+	default:
+		return 0, 0, fmt.Errorf("unknown suffix key version %d", version)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid version %d suffix key %q: %v", version, key, err)
+	}
+	if len(b) != 2 {
+		return 0, 0, fmt.Errorf("invalid version %d suffix key %q: got %d bytes, want 2", version, key, len(b))
+	}
+	return b[0], b[1], nil
+}
+
+// ConvertSubtreeKeys rewrites the keys of the leaves and internal nodes of st in version
+// of the encoding, and sets its KeyVersion. The maps are replaced rather than changed, so
+// copies of st that share them aren't affected. It does nothing if st already uses
+// version.
+func ConvertSubtreeKeys(st *SubtreeProto, version int32) error {
+	if st.KeyVersion == version {
+		return nil
+	}
+
+	leaves, err := convertSuffixKeys(st.Leaves, st.KeyVersion, version)
+	if err != nil {
+		return err
+	}
+	internalNodes, err := convertSuffixKeys(st.InternalNodes, st.KeyVersion, version)
+	if err != nil {
+		return err
+	}
+
+	st.Leaves = leaves
+	st.InternalNodes = internalNodes
+	st.KeyVersion = version
+	return nil
+}
+
+func convertSuffixKeys(nodes map[string][]byte, from, to int32) (map[string][]byte, error) {
+	if nodes == nil {
+		// Check the version even though there's nothing to convert.
+		_, err := EncodeSuffixKey(to, 0, 0)
+		return nil, err
+	}
+
+	r := make(map[string][]byte, len(nodes))
+	for k, v := range nodes {
+		bits, path, err := DecodeSuffixKey(from, k)
+		if err != nil {
+			return nil, err
+		}
+		key, err := EncodeSuffixKey(to, bits, path)
+		if err != nil {
+			return nil, err
+		}
+		r[key] = v
+	}
+	return r, nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuffixKeyEncodings(t *testing.T) {
+	for _, test := range []struct {
+		version    int32
+		bits, path byte
+		want       string
+	}{
+		{SuffixKeyV0, 5, 0xae, "Ba4="},
+		{SuffixKeyV0, 8, 0x00, "CAA="},
+		{SuffixKeyV1, 5, 0xae, "05ae"},
+		{SuffixKeyV1, 8, 0x00, "0800"},
+	} {
+		got, err := EncodeSuffixKey(test.version, test.bits, test.path)
+		if err != nil {
+			t.Fatalf("EncodeSuffixKey(%d, %d, %x): %v", test.version, test.bits, test.path, err)
+		}
+		if got != test.want {
+			t.Errorf("EncodeSuffixKey(%d, %d, %x): got %q, want %q", test.version, test.bits, test.path, got, test.want)
+		}
+
+		bits, path, err := DecodeSuffixKey(test.version, got)
+		if err != nil {
+			t.Fatalf("DecodeSuffixKey(%d, %q): %v", test.version, got, err)
+		}
+		if bits != test.bits || path != test.path {
+			t.Errorf("DecodeSuffixKey(%d, %q): got %d, %x, want %d, %x", test.version, got, bits, path, test.bits, test.path)
+		}
+	}
+}
+
+func TestSuffixKeyRejectsBadKeys(t *testing.T) {
+	for _, test := range []struct {
+		version int32
+		key     string
+	}{
+		{SuffixKeyV0, "not base64"},
+		{SuffixKeyV0, "CA=="},
+		{SuffixKeyV0, "05ae"},
+		{SuffixKeyV1, "Ba4="},
+		{SuffixKeyV1, "05"},
+		{MaxSuffixKeyVersion + 1, "05ae"},
+		{-1, "Ba4="},
+	} {
+		if bits, path, err := DecodeSuffixKey(test.version, test.key); err == nil {
+			t.Errorf("DecodeSuffixKey(%d, %q): got %d, %x, want an error", test.version, test.key, bits, path)
+		}
+	}
+
+	if k, err := EncodeSuffixKey(MaxSuffixKeyVersion+1, 5, 0xae); err == nil {
+		t.Errorf("EncodeSuffixKey of an unknown version: got %q, want an error", k)
+	}
+}
+
+func TestConvertSubtreeKeys(t *testing.T) {
+	v0 := testSubtree()
+	leaves := v0.Leaves
+
+	v1 := testSubtree()
+	if err := ConvertSubtreeKeys(v1, SuffixKeyV1); err != nil {
+		t.Fatalf("Failed to convert to version 1: %v", err)
+	}
+	if got, want := v1.KeyVersion, SuffixKeyV1; got != want {
+		t.Errorf("Got key version %d after converting, want %d", got, want)
+	}
+	if got, want := v1.Leaves, map[string][]byte{"0800": {0x01}, "0801": {0x02}, "0802": {0x03}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got leaves %v after converting, want %v", got, want)
+	}
+	if got, want := v1.InternalNodes, map[string][]byte{"0700": {0x04}, "0500": {0xaa, 0xbb}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got internal nodes %v after converting, want %v", got, want)
+	}
+
+	if err := ConvertSubtreeKeys(v1, SuffixKeyV0); err != nil {
+		t.Fatalf("Failed to convert back to version 0: %v", err)
+	}
+	if !reflect.DeepEqual(v1, v0) {
+		t.Errorf("Got %v after converting back, want %v", v1, v0)
+	}
+	if !reflect.DeepEqual(v0.Leaves, leaves) {
+		t.Errorf("Converting a subtree changed the maps it had before")
+	}
+}
+
+func TestConvertSubtreeKeysFailures(t *testing.T) {
+	st := testSubtree()
+	if err := ConvertSubtreeKeys(st, MaxSuffixKeyVersion+1); err == nil {
+		t.Errorf("Converted to an unknown version")
+	}
+	if err := ConvertSubtreeKeys(&SubtreeProto{}, MaxSuffixKeyVersion+1); err == nil {
+		t.Errorf("Converted an empty subtree to an unknown version")
+	}
+
+	// Keys that don't match the version they claim aren't converted
+	st.KeyVersion = SuffixKeyV1
+	if err := ConvertSubtreeKeys(st, SuffixKeyV0); err == nil {
+		t.Errorf("Converted version 0 keys that claimed to be version 1")
+	}
+	if got, want := st.KeyVersion, SuffixKeyV1; got != want {
+		t.Errorf("Got key version %d after a failed conversion, want %d", got, want)
+	}
+}
@@ -7,14 +7,17 @@ import (
 	"github.com/google/trillian"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/storage"
+	_ "github.com/google/trillian/storage/cassandra"
+	_ "github.com/google/trillian/storage/cloudspanner"
 	"github.com/google/trillian/storage/mysql"
+	_ "github.com/google/trillian/storage/postgres"
 )
 
 var logIdFlag = flag.String("logid", "logId", "The log id to use")
 var treeIdFlag = flag.Int64("treeid", 3, "The tree id to use")
-var storageTypeFlag = flag.String("storage_type", "mysql", "Which type of storage to use")
-var mysqlUriFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
-	"uri to use with mysql storage")
+var storageTypeFlag = flag.String("storage_type", mysql.ProviderName, "Which type of storage to use")
+var storageUriFlag = flag.String("storage_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
+	"uri to use with the selected storage type")
 var serverPortFlag = flag.Int("port", 8090, "Port to serve log requests on")
 
 func GetLogIdFromFlagsOrDie() trillian.LogID {
@@ -35,18 +38,13 @@ func GetLogStorageProviderFromFlags() server.LogStorageProviderFunc {
 
 // GetStorageFromFlags returns a configured storage instance, this can fail with an error
 func GetStorageFromFlags(treeId trillian.LogID) (storage.LogStorage, error) {
-	switch {
-	case *storageTypeFlag == "mysql":
-		store, err := mysql.NewLogStorage(treeId, *mysqlUriFlag)
+	provider, err := storage.NewProvider(*storageTypeFlag, *storageUriFlag)
 
-		if err != nil {
-			panic(err)
-		}
-
-		return store, err
+	if err != nil {
+		return nil, fmt.Errorf("Unknown storage type: %s (registered: %v): %v", *storageTypeFlag, storage.Providers(), err)
 	}
 
-	return nil, fmt.Errorf("Unknown storage type: %s", *storageTypeFlag)
+	return provider.LogStorage(treeId)
 }
 
 // GetStorageFromFlagsOrDie returns a configured storage instance, errors are fatal and if it
@@ -3,10 +3,12 @@ package main
 import (
 	"encoding/hex"
 	"flag"
+
 	_ "github.com/go-sql-driver/mysql"
 	log "github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage/tools"
+	"golang.org/x/net/context"
 )
 
 var fetchLeavesFlag = flag.Int("fetch_leaves", 1, "Number of entries to fetch")
@@ -31,13 +33,14 @@ func main() {
 	treeId := tools.GetLogIdFromFlagsOrDie()
 	storage := tools.GetStorageFromFlagsOrDie(treeId)
 
-	tx, err := storage.Begin()
+	ctx := context.Background()
+	tx, err := storage.Begin(ctx)
 
 	if err != nil {
 		panic(err)
 	}
 
-	leafCount, err := tx.GetSequencedLeafCount()
+	leafCount, err := tx.GetSequencedLeafCount(ctx)
 
 	if err != nil {
 		panic(err)
@@ -52,7 +55,7 @@ func main() {
 			panic(err)
 		}
 
-		fetchedLeaves, err := tx.GetLeavesByHash([]trillian.Hash{hash}, false)
+		fetchedLeaves, err := tx.GetLeavesByHash(ctx, []trillian.Hash{hash}, false)
 
 		if err != nil {
 			panic(err)
@@ -71,7 +74,7 @@ func main() {
 			leaves = append(leaves, int64(leafNumber))
 		}
 
-		fetchedLeaves, err := tx.GetLeavesByIndex(leaves)
+		fetchedLeaves, err := tx.GetLeavesByIndex(ctx, leaves)
 
 		if err != nil {
 			panic(err)
@@ -10,6 +10,7 @@ import (
 	log "github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage/tools"
+	"golang.org/x/net/context"
 )
 
 var numInsertionsFlag = flag.Int("num_insertions", 10, "Number of entries to insert in the tree")
@@ -39,7 +40,8 @@ func main() {
 	treeId := tools.GetLogIdFromFlagsOrDie()
 	storage := tools.GetStorageFromFlagsOrDie(treeId)
 
-	tx, err := storage.Begin()
+	ctx := context.Background()
+	tx, err := storage.Begin(ctx)
 
 	if err != nil {
 		panic(err)
@@ -75,7 +77,7 @@ func main() {
 		leaves = append(leaves, leaf)
 
 		if len(leaves) >= *queueBatchSizeFlag {
-			err = tx.QueueLeaves(leaves)
+			_, err = tx.QueueLeaves(ctx, leaves)
 			leaves = leaves[:0] // starting new batch
 
 			if err != nil {
@@ -86,7 +88,7 @@ func main() {
 
 	// There might be some leaves left over that didn't get queued yet
 	if len(leaves) > 0 {
-		err = tx.QueueLeaves(leaves)
+		_, err = tx.QueueLeaves(ctx, leaves)
 
 		if err != nil {
 			panic(err)
@@ -1,16 +1,30 @@
 package storage
 
+import (
+	"golang.org/x/net/context"
+)
+
 // ReadOnlyTreeTX represents a read-only transaction on a TreeStorage.
 type ReadOnlyTreeTX interface {
 	NodeReader
 	Commit() error
 }
 
+// TreeSnapshot is implemented by read-only transactions that are pinned to a single
+// revision of a tree.
+type TreeSnapshot interface {
+	// ReadRevision returns the tree revision the transaction is pinned to. Proofs served
+	// through the transaction should read their nodes at this revision.
+	ReadRevision() int64
+}
+
 // TreeTX represents an in-process tree-modifying transaction.
 // The transaction must end with a call to Commit or Rollback.
 // After a call to Commit or Rollback, all operations on the transaction will fail.
 // After a call to Commit or Rollback implementations must be in a clean state and have
 // released any resources owned by the TreeTX.
+// Operations on the transaction take the context of the request they're made for and are
+// abandoned, returning an error, if it's cancelled or its deadline passes.
 type TreeTX interface {
 	NodeReaderWriter
 
@@ -33,9 +47,15 @@ type TreeTX interface {
 type NodeReader interface {
 	// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
 	// It is an error to request tree sizes larger than the currently published tree size.
-	GetTreeRevisionAtSize(treeSize int64) (int64, error)
+	GetTreeRevisionAtSize(ctx context.Context, treeSize int64) (int64, error)
 	// GetMerkleNodes looks up the set of nodes identified by ids, at treeRevision, and returns them.
-	GetMerkleNodes(treeRevision int64, ids []NodeID) ([]Node, error)
+	GetMerkleNodes(ctx context.Context, treeRevision int64, ids []NodeID) ([]Node, error)
+	// GetProofPath returns the nodes of the inclusion proof for the leaf with leafID at
+	// treeRevision, from the leaf up, reading the subtrees they're in together. treeSize is
+	// the number of leaves in a log tree, or 0 for a sparse map tree where the proof holds
+	// every sibling on the path to the root. Unlike GetMerkleNodes there's a node for every
+	// element of the proof: those that haven't been written have a nil Hash.
+	GetProofPath(ctx context.Context, treeRevision int64, leafID NodeID, treeSize int64) ([]Node, error)
 }
 
 // NodeReaderWriter provides a read-write interface into the stored tree nodes.
@@ -43,5 +63,23 @@ type NodeReaderWriter interface {
 	NodeReader
 
 	// SetMerkleNodes stores the provided nodes, at the transaction's writeRevision.
-	SetMerkleNodes(nodes []Node) error
+	SetMerkleNodes(ctx context.Context, nodes []Node) error
+}
+
+// PruneStats describes the subtree revisions deleted by a call to PruneSubtrees.
+type PruneStats struct {
+	// Rows is the number of subtree revisions deleted.
+	Rows int64
+	// Bytes is the total size of the serialized subtree data deleted.
+	Bytes int64
+}
+
+// SubtreePruner is implemented by transactions on tree storage which can garbage collect
+// subtree revisions that are no longer needed.
+type SubtreePruner interface {
+	// PruneSubtrees deletes the subtree revisions which have been superseded by a later
+	// revision of the same subtree at or before horizon. The tree can still be read at
+	// any revision >= horizon afterwards, but reads at earlier revisions may fail to
+	// find nodes.
+	PruneSubtrees(ctx context.Context, horizon int64) (PruneStats, error)
 }
@@ -11,6 +11,16 @@ import (
 // ErrReadOnly is returned when storage operations are not allowed because a resource is read only
 var ErrReadOnly = errors.New("storage: Operation not allowed because resource is read only")
 
+// ErrLogRootNotFound is returned when there is no signed log root with the requested timestamp
+var ErrLogRootNotFound = errors.New("storage: Signed log root not found")
+
+// ErrPreordered is returned when leaves are queued to a pre-ordered log, which only accepts
+// leaves with sequence numbers assigned by the caller
+var ErrPreordered = errors.New("storage: Leaves cannot be queued to a pre-ordered log")
+
+// ErrNotPreordered is returned when sequenced leaves are added to a log that isn't pre-ordered
+var ErrNotPreordered = errors.New("storage: Sequenced leaves can only be added to a pre-ordered log")
+
 // Node represents a single node in a Merkle tree.
 type Node struct {
 	NodeID       NodeID
@@ -19,6 +29,11 @@ type Node struct {
 }
 
 // NodeID uniquely identifies a Node within a versioned MerkleTree.
+//
+// A NodeID is a value: nothing changes it once it's been created, including its methods,
+// and those that derive other NodeIDs return new ones with their own Path. This makes them
+// safe to copy, keep and share between goroutines. The fields are exported for storage to
+// read, and must not be modified: use Prefix, Sibling or WithBit instead.
 type NodeID struct {
 	// path is effectively a BigEndian bit set, with path[0] being the MSB
 	// (identifying the root child), and successive bits identifying the lower
@@ -42,16 +57,17 @@ func bytesForBits(numBits int) int {
 	return numBytes
 }
 
-// NewEmptyNodeID creates a new zero-length NodeID with sufficient underlying
-// capacity to store a maximum of maxLenBits.
+// NewNodeIDFromHash creates a NodeID for the leaf at the path given by h, which is copied.
 func NewNodeIDFromHash(h trillian.Hash) NodeID {
 	return NodeID{
-		Path:          h,
+		Path:          append([]byte{}, h...),
 		PathLenBits:   len(h) * 8,
 		PrefixLenBits: len(h) * 8,
 	}
 }
 
+// NewEmptyNodeID creates a new zero-length NodeID with sufficient underlying
+// capacity to store a maximum of maxLenBits.
 func NewEmptyNodeID(maxLenBits int) NodeID {
 	return NodeID{
 		Path:          make([]byte, bytesForBits(maxLenBits)),
@@ -72,7 +88,7 @@ func NewNodeIDWithPrefix(prefix uint64, prefixLenBits, nodeIDLenBits, maxLenBits
 	bit := maxLenBits - prefixLenBits
 	for i := 0; i < prefixLenBits; i++ {
 		if prefix&1 != 0 {
-			p.SetBit(bit, 1)
+			p.setBit(bit, 1)
 		}
 		bit++
 		prefix >>= 1
@@ -117,8 +133,9 @@ func NewNodeIDForTreeCoords(depth int64, index int64, maxPathBits int) (NodeID,
 	return r, nil
 }
 
-// SetBit sets the ith bit to true if b is non-zero, and false otherwise.
-func (n *NodeID) SetBit(i int, b uint) {
+// setBit sets the ith bit to true if b is non-zero, and false otherwise. It's only used
+// while building a new NodeID, before anything else can see it.
+func (n *NodeID) setBit(i int, b uint) {
 	// TODO(al): investigate whether having lookup tables for these might be
 	// faster.
 	bIndex := (n.PathLenBits - i - 1) / 8
@@ -129,54 +146,132 @@ func (n *NodeID) SetBit(i int, b uint) {
 	}
 }
 
-// Bit returns 1 if the ith bit is true, and false otherwise.
-func (n *NodeID) Bit(i int) uint {
+// copyNodeID returns a NodeID like n with its own copy of the path.
+func copyNodeID(n NodeID) NodeID {
+	n.Path = append([]byte{}, n.Path...)
+	return n
+}
+
+// WithBit returns a copy of n with the ith bit set to true if b is non-zero, and false
+// otherwise. Bits are numbered from the end of the path, as for Bit.
+func (n NodeID) WithBit(i int, b uint) NodeID {
+	r := copyNodeID(n)
+	r.setBit(i, b)
+	return r
+}
+
+// Bit returns 1 if the ith bit is true, and false otherwise. Bits are numbered from the
+// end of the path, so the first bit of the prefix is PathLenBits-1.
+func (n NodeID) Bit(i int) uint {
 	bIndex := (n.PathLenBits - i - 1) / 8
 	return uint((n.Path[bIndex] >> uint(i%8)) & 0x01)
 }
 
+// PrefixBit returns the bit of the prefix at depth, which is 0 for the child of the root
+// the node is under and PrefixLenBits-1 for the node itself.
+func (n NodeID) PrefixBit(depth int) uint {
+	return n.Bit(n.PathLenBits - depth - 1)
+}
+
+// ForEachBit calls f with each bit of the prefix in turn, from the root down, along with
+// its depth as for PrefixBit.
+func (n NodeID) ForEachBit(f func(depth int, bit uint)) {
+	for depth := 0; depth < n.PrefixLenBits; depth++ {
+		f(depth, n.PrefixBit(depth))
+	}
+}
+
+// Prefix returns the ancestor of n whose prefix is the first bits of n's, or a copy of n if
+// bits is PrefixLenBits. The bits of the path after the prefix are zero.
+func (n NodeID) Prefix(bits int) NodeID {
+	if bits < 0 || bits > n.PrefixLenBits {
+		panic(fmt.Errorf("prefix of %d bits of a %d bit node ID", bits, n.PrefixLenBits))
+	}
+
+	r := NodeID{Path: make([]byte, len(n.Path)), PathLenBits: n.PathLenBits}
+	copy(r.Path, n.Path[:bytesForBits(bits)])
+	if bits%8 != 0 {
+		r.Path[bits/8] &= byte(0xff << uint(8-bits%8))
+	}
+	r.PrefixLenBits = bits
+	return r
+}
+
+// Parent returns the node immediately above n. It panics if n is the root.
+func (n NodeID) Parent() NodeID {
+	if n.PrefixLenBits == 0 {
+		panic(errors.New("the root has no parent"))
+	}
+	return n.Prefix(n.PrefixLenBits - 1)
+}
+
+// Sibling returns the other child of n's parent. It panics if n is the root.
+func (n NodeID) Sibling() NodeID {
+	if n.PrefixLenBits == 0 {
+		panic(errors.New("the root has no sibling"))
+	}
+	bi := n.PathLenBits - n.PrefixLenBits
+	return n.WithBit(bi, n.Bit(bi)^1)
+}
+
+// Compare returns -1, 0 or 1 as the prefix of n is before, the same as or after that of
+// other, in the order the nodes are visited by a pre-order traversal of the tree. So nodes
+// come after their ancestors and before the nodes to their right.
+func (n NodeID) Compare(other NodeID) int {
+	for depth := 0; depth < n.PrefixLenBits && depth < other.PrefixLenBits; depth++ {
+		if a, b := n.PrefixBit(depth), other.PrefixBit(depth); a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case n.PrefixLenBits < other.PrefixLenBits:
+		return -1
+	case n.PrefixLenBits > other.PrefixLenBits:
+		return 1
+	}
+	return 0
+}
+
 // String returns a string representation of the binary value of the NodeID.
 // The left-most bit is the MSB (i.e. nearer the root of the tree).
-func (n *NodeID) String() string {
+func (n NodeID) String() string {
 	var r bytes.Buffer
-	limit := n.PathLenBits - n.PrefixLenBits
-	for i := n.PathLenBits - 1; i >= limit; i-- {
-		r.WriteRune(rune('0' + n.Bit(i)))
-	}
+	n.ForEachBit(func(_ int, bit uint) {
+		r.WriteRune(rune('0' + bit))
+	})
 	return r.String()
 }
 
-func (n *NodeID) Siblings() []NodeID {
+// Siblings returns the siblings of n and of each of its ancestors, from the bottom of the
+// tree up, which are the nodes needed to build a proof for it.
+func (n NodeID) Siblings() []NodeID {
 	r := make([]NodeID, n.PrefixLenBits, n.PrefixLenBits)
-	l := n.PrefixLenBits
-	// Index of the bit to twiddle:
-	bi := n.PathLenBits - n.PrefixLenBits
-	for i := 0; i < len(r); i++ {
-		r[i].PrefixLenBits = l - i
-		r[i].Path = make([]byte, len(n.Path))
-		r[i].PathLenBits = n.PathLenBits
-		copy(r[i].Path, n.Path)
-		r[i].SetBit(bi, n.Bit(bi)^1)
-		bi++
+	for i := range r {
+		r[i] = n.Prefix(n.PrefixLenBits - i).Sibling()
 	}
 	return r
 }
 
-func (n *NodeID) AsProto() *NodeIDProto {
-	return &NodeIDProto{Path: n.Path, PrefixLenBits: int32(n.PrefixLenBits)}
+func (n NodeID) AsProto() *NodeIDProto {
+	return &NodeIDProto{Path: append([]byte{}, n.Path...), PrefixLenBits: int32(n.PrefixLenBits)}
 }
 
+// NewNodeIDFromProto creates a NodeID from a copy of the path in p.
 func NewNodeIDFromProto(p NodeIDProto) *NodeID {
 	return &NodeID{
-		Path:          p.Path,
+		Path:          append([]byte{}, p.Path...),
 		PrefixLenBits: int(p.PrefixLenBits),
 		PathLenBits:   len(p.Path) * 8,
 	}
 }
 
 // Equivalent return true iff the other represents the same path prefix as this NodeID.
-func (n *NodeID) Equivalent(other NodeID) bool {
-	return n.String() == other.String()
+func (n NodeID) Equivalent(other NodeID) bool {
+	return n.Compare(other) == 0
 }
 
 // PopulateSubtreeFunc is a function which knows how to re-populate a subtree
@@ -75,17 +75,138 @@ func TestNewNodeIDForTreeCoords(t *testing.T) {
 	}
 }
 
-func TestSetBit(t *testing.T) {
+func TestWithBit(t *testing.T) {
 	n := NewNodeIDWithPrefix(0, 0, 0, 64)
-	n.SetBit(27, 1)
-	if got, want := n.Path, []byte{0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
+	n1 := n.WithBit(27, 1)
+	if got, want := n1.Path, []byte{0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
 		t.Fatalf("Expected Path of %v, but got %v", want, got)
 	}
-
-	n.SetBit(27, 0)
 	if got, want := n.Path, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
+		t.Fatalf("Expected original Path of %v, but got %v", want, got)
+	}
+
+	n2 := n1.WithBit(27, 0)
+	if got, want := n2.Path, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}; !bytes.Equal(got, want) {
 		t.Fatalf("Expected Path of %v, but got %v", want, got)
 	}
+	if got, want := n1.Bit(27), uint(1); got != want {
+		t.Fatalf("Expected bit 27 of the first copy to still be %d, but got %d", want, got)
+	}
+}
+
+func TestNewNodeIDFromHashCopies(t *testing.T) {
+	h := []byte{0x12, 0x34}
+	n := NewNodeIDFromHash(h)
+	h[0] = 0xff
+	if got, want := n.String(), "0001001000110100"; got != want {
+		t.Fatalf("Expected '%s', got '%s'", want, got)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	n := NewNodeIDFromHash([]byte{0xab, 0xcd})
+	for _, v := range []struct {
+		bits     int
+		wantPath []byte
+		want     string
+	}{
+		{16, []byte{0xab, 0xcd}, "1010101111001101"},
+		{12, []byte{0xab, 0xc0}, "101010111100"},
+		{8, []byte{0xab, 0x00}, "10101011"},
+		{3, []byte{0xa0, 0x00}, "101"},
+		{0, []byte{0x00, 0x00}, ""},
+	} {
+		p := n.Prefix(v.bits)
+		if got, want := p.String(), v.want; got != want {
+			t.Errorf("Prefix(%d): expected '%s', got '%s'", v.bits, want, got)
+		}
+		if got, want := p.Path, v.wantPath; !bytes.Equal(got, want) {
+			t.Errorf("Prefix(%d): expected Path of %x, got %x", v.bits, want, got)
+		}
+		if got, want := p.PathLenBits, n.PathLenBits; got != want {
+			t.Errorf("Prefix(%d): expected PathLenBits of %d, got %d", v.bits, want, got)
+		}
+	}
+
+	if got, want := n.String(), "1010101111001101"; got != want {
+		t.Fatalf("Prefix changed the original to '%s'", got)
+	}
+}
+
+func TestPrefixOutOfRange(t *testing.T) {
+	n := NewNodeIDFromHash([]byte{0xab}).Prefix(4)
+	for _, bits := range []int{-1, 5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Prefix(%d) of a 4 bit node ID didn't panic", bits)
+				}
+			}()
+			n.Prefix(bits)
+		}()
+	}
+}
+
+func TestParentAndSibling(t *testing.T) {
+	n := NewNodeIDFromHash([]byte{0xab})
+	if got, want := n.Parent().String(), "1010101"; got != want {
+		t.Errorf("Expected parent '%s', got '%s'", want, got)
+	}
+	if got, want := n.Sibling().String(), "10101010"; got != want {
+		t.Errorf("Expected sibling '%s', got '%s'", want, got)
+	}
+	if got, want := n.Parent().Sibling().String(), "1010100"; got != want {
+		t.Errorf("Expected parent's sibling '%s', got '%s'", want, got)
+	}
+	if !n.Sibling().Sibling().Equivalent(n) {
+		t.Errorf("Sibling of the sibling of %v isn't the same node", n)
+	}
+	if got, want := n.String(), "10101011"; got != want {
+		t.Errorf("Parent and Sibling changed the original to '%s'", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	n := NewNodeIDFromHash([]byte{0xab})
+	for _, v := range []struct {
+		a, b NodeID
+		want int
+	}{
+		{n, n, 0},
+		{n, NewNodeIDFromHash([]byte{0xab}), 0},
+		// Same prefix with a different path length
+		{n.Prefix(4), NewNodeIDFromHash([]byte{0xa0, 0x00}).Prefix(4), 0},
+		// Ancestors come first
+		{n.Prefix(4), n, -1},
+		{n, n.Parent(), 1},
+		{NewEmptyNodeID(8), n, -1},
+		// Left before right
+		{n.Sibling(), n, -1},
+		{n, n.Sibling(), 1},
+		{n.Prefix(2).Sibling(), n, 1},
+		{n.Prefix(2).Sibling(), n.Prefix(1), 1},
+	} {
+		if got := v.a.Compare(v.b); got != v.want {
+			t.Errorf("Compare(%v, %v): got %d, want %d", v.a, v.b, got, v.want)
+		}
+	}
+}
+
+func TestForEachBit(t *testing.T) {
+	n := NewNodeIDFromHash([]byte{0xa5, 0x80}).Prefix(10)
+	var got bytes.Buffer
+	n.ForEachBit(func(depth int, bit uint) {
+		if want := uint(got.Len()); depth != int(want) {
+			t.Fatalf("Expected depth %d, got %d", want, depth)
+		}
+		if bit != n.PrefixBit(depth) {
+			t.Fatalf("Expected bit %d at depth %d to match PrefixBit", bit, depth)
+		}
+		fmt.Fprint(&got, bit)
+	})
+	if want := "1010010110"; got.String() != want {
+		t.Fatalf("Expected bits '%s', got '%s'", want, got.String())
+	}
 }
 
 func TestBit(t *testing.T) {
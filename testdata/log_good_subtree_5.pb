@@ -1,40 +0,0 @@
-prefix: "\000\000\000\000\000\000\000"
-root_hash: "Yt/\x11O\xdb\xc4\"~\xb4\x17\\\x84\xf8\xd4\xff\x98-Cb\xf6\xd4\x86^-2z\xe4\xfa\xc7\xad\xbc"
-depth: 8
-leaves: <
-  key: "CAA="
-  value: "<\261\224\212\242\377\263\367\327\220?%\232\364\326n}\022\343^\270\017\206\366.>\256{\033w\202\341"
->
-leaves: <
-  key: "CAE="
-  value: "F4m\370\2261\264c\366}\0249\r\345\377!\212\260\371\301\333\370\303\332W\257:l\n\300]\326"
->
-leaves: <
-  key: "CAI="
-  value: "\214\246\261\277\354\206\233\224\260\336\266\311\315p12\303\222\264\217:\333\2623ejb\252]\226\263\030"
->
-leaves: <
-  key: "CAM="
-  value: "\223\216\353\331y=.\014f\276\301\224\361u\2236\343\n +e\017E\220BtG\253\355;\244\022"
->
-leaves: <
-  key: "CAQ="
-  value: "\345\2218\2056\205\356]?.\326<do\177V\230N\023\021\\\271\022\315\342\006\370\324;\013j\236"
->
-internal_nodes: <
-  key: "BQA="
-  value: "Yt/\021O\333\304\"~\264\027\\\204\370\324\377\230-Cb\366\324\206^-2z\344\372\307\255\274"
->
-internal_nodes: <
-  key: "BgA="
-  value: "\233U\352T6\202\2248\237\007m\205\256\345\316\334\tf\331\366\016{A\307\342\222ljq\346>\250"
->
-internal_nodes: <
-  key: "BwA="
-  value: "+\245`\331\211u\016.\222P\213\2605\275?\266\335\2006v)\302\363A\t\333\361[aM\217\020"
->
-internal_nodes: <
-  key: "BwI="
-  value: "\002 \323(iT]\335)&\276\240\201\263\365 \026?nm\016\270\351\032:\313;\354t\3058N"
->
-
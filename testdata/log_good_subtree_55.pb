@@ -1,439 +0,0 @@
-prefix: "\000\000\000\000\000\000\000"
-depth: 8
-root_hash: "pOA\x9c\xb8\x18\x0b\xe8\xa3Ijf\xae\xab\xea\xab\xa4\xbe\x03\xa4\x16%\x13\xee5\xde\xfd\xb1\x01fA."
-leaves: <
-  key: "CA0="
-  value: "<\261\224\212\242\377\263\367\327\220?%\232\364\326n}\022\343^\270\017\206\366.>\256{\033w\202\341"
->
-leaves: <
-  key: "CA4="
-  value: "DH\032u\365\311\265\016p\005\214\320\341\225\311\356\332\262\377\367Z \027\177\372\235\306(\201\237\3517"
->
-leaves: <
-  key: "CA8="
-  value: "F4m\370\2261\264c\366}\0249\r\345\377!\212\260\371\301\333\370\303\332W\257:l\n\300]\326"
->
-leaves: <
-  key: "CAA="
-  value: "<\261\224\212\242\377\263\367\327\220?%\232\364\326n}\022\343^\270\017\206\366.>\256{\033w\202\341"
->
-leaves: <
-  key: "CAE="
-  value: "F4m\370\2261\264c\366}\0249\r\345\377!\212\260\371\301\333\370\303\332W\257:l\n\300]\326"
->
-leaves: <
-  key: "CAI="
-  value: "\214\246\261\277\354\206\233\224\260\336\266\311\315p12\303\222\264\217:\333\2623ejb\252]\226\263\030"
->
-leaves: <
-  key: "CAM="
-  value: "\223\216\353\331y=.\014f\276\301\224\361u\2236\343\n +e\017E\220BtG\253\355;\244\022"
->
-leaves: <
-  key: "CAQ="
-  value: "\345\2218\2056\205\356]?.\326<do\177V\230N\023\021\\\271\022\315\342\006\370\324;\013j\236"
->
-leaves: <
-  key: "CAU="
-  value: "\004CZ\251b\001\307\233\3044F\366\303\027\002\216\276\361\242,@!L\346\363\363\2469\257\"\326K"
->
-leaves: <
-  key: "CAY="
-  value: "\010\205\3505\250I\343\313\373\247\010\360\0265G\323\357`\270k\024\306\034\262\250\375\360_y\242p\322"
->
-leaves: <
-  key: "CAc="
-  value: "\r\3276!L\005o'^i\377\346\241\332D\321q\257\306\216\256\333\356I\337\225sR>\005YI"
->
-leaves: <
-  key: "CAg="
-  value: "\025i\266[~=\201\247\0300\273\177u\010\255\225\206\240\236\377DTeIY\226y\312O\237\335\252"
->
-leaves: <
-  key: "CAk="
-  value: "\027\302Q\223P\345\276E\273\210\034F\323g\020\212\247\007o\034\200\373\206q\026\344\321\313\036\253*\177"
->
-leaves: <
-  key: "CAo="
-  value: "\035\302f]\230\016\355\030\300\336\377b\355\005i%\362\215\025]\314\177\353 j)\347\022\313\263\006?"
->
-leaves: <
-  key: "CAs="
-  value: "\035\346f\336\357\357\357\305C/)\2542\220\342\010\361\023\274\017\223\000M\210/}\033\244\255\205\254\177"
->
-leaves: <
-  key: "CAw="
-  value: "7V\317\362\330\014\005\347\360 _BS\354\325\233\320\213U\216R\361\300\017K\376\017R\364\207x\345"
->
-leaves: <
-  key: "CB0="
-  value: "\216\261y\001\221\312\211\201\342\244\227&>.\376\247K\3569\242\357\030\246\202\222Wk\200f\276\217\303"
->
-leaves: <
-  key: "CB4="
-  value: "\220\2769\222\320tTOuW\320\224\207}>z3\010\352\274rj\372Y\253\301\370eW\022\231\337"
->
-leaves: <
-  key: "CB8="
-  value: "\221\310\271.\nx/\343\312-\014??;\261r\217ViB\235\237\242\306\325\"\213\305\236\3441\233"
->
-leaves: <
-  key: "CBA="
-  value: "S\301\321n\211\r1)\206\331\303-\020\237m@\000\364\242F\201\016\343\225\377\314\364{Y\310\"$"
->
-leaves: <
-  key: "CBE="
-  value: "Yf\014\322\014b\013L\271]\002\025\016L\264\2754\261\352\247\347{`k\302\2357\035\311\022\034&"
->
-leaves: <
-  key: "CBI="
-  value: "Y\373o\200>}a8\230\320\242\217\034\2723\206\2167\001\021{\270\3237~^<?\220\022\r9"
->
-leaves: <
-  key: "CBM="
-  value: "c-\213k\254\221\202\273\\Qy\331\303\205\254)\023\216:\003\034\0006\306\315\035\300NI\307\355Y"
->
-leaves: <
-  key: "CBQ="
-  value: "cX\347\324\331u\256pKsh\000H\007\177\2722\244UK\356\250\373\353\335\223\003\344\377X\300\356"
->
-leaves: <
-  key: "CBU="
-  value: "j\355\255GL\251{^\023g\360B\013\257\301\353sG\316\034\325H\021&\2363O\201\244\334\227\375"
->
-leaves: <
-  key: "CBY="
-  value: "k\335\257\375\222r3\376\336wL\324Z4-\324\307\224\257x\006\357\325\364\027\355\236HP\010\306\036"
->
-leaves: <
-  key: "CBc="
-  value: "u\206\2322\306\355k@\016i\316i\367\262[F\033\261\036\337YX\371No\327\213\022\\8\237\234"
->
-leaves: <
-  key: "CBg="
-  value: "z\324\000\025\261\032 \203nC\376\332Dwq%\225\260>h(\376tn&\225t\256\031O\316\014"
->
-leaves: <
-  key: "CBk="
-  value: "~Z\270\300~K\004\200\033\2721\030\r\315\304g\324\356<V\257k\000\250a\324\370\204\314\203\020n"
->
-leaves: <
-  key: "CBo="
-  value: "\201U\324\211$L\217\353\3245{\345#\217!\272\261A\020\226\0000\307\276\\t\261\007(\022\364e"
->
-leaves: <
-  key: "CBs="
-  value: "\201\365\260\226\347;\366\227\333\326\177m\373\005\200\326\377-\345\305P\337\246\202\007`\374a\3677\360>"
->
-leaves: <
-  key: "CBw="
-  value: "\214\246\261\277\354\206\233\224\260\336\266\311\315p12\303\222\264\217:\333\2623ejb\252]\226\263\030"
->
-leaves: <
-  key: "CC0="
-  value: "\3205\032\343FT\003\200~\266\344\203\277\237n#/u\247\265\367\3723H\324\271\227\210\275J\366_"
->
-leaves: <
-  key: "CC4="
-  value: "\320\202a\220\\\037\241h\365\200\315\341i\333\3068\317\336\235\372q\020O\270\252\305\266\247\006h\363_"
->
-leaves: <
-  key: "CC8="
-  value: "\337\010YEB}\310\004\312=\367Z\346e\016\362A\252O>skj\302]|{ \202ZK\310"
->
-leaves: <
-  key: "CCA="
-  value: "\222KAS\273~\204\027\342+\337\003R\274\274\211=\215\205\036\177\202\341\347\032kh\212Y\326\337\002"
->
-leaves: <
-  key: "CCE="
-  value: "\223\216\353\331y=.\014f\276\301\224\361u\2236\343\n +e\017E\220BtG\253\355;\244\022"
->
-leaves: <
-  key: "CCI="
-  value: "\2360}\353\247\2669q\375\306\376I\315\302\227\253\260\340y.\306\014\305Sp\316\212f+Eh\316"
->
-leaves: <
-  key: "CCM="
-  value: "\247\222\327<0\251e\340\251\353e\202\202\314\267\013#\017\203c\255\250\022\222\247\262\345>[\346<\253"
->
-leaves: <
-  key: "CCQ="
-  value: "\247\227\300\026R\224R^s\367F[\247\273a\261\237\277\243bj0\3504\315R\325\010\306|^\211"
->
-leaves: <
-  key: "CCU="
-  value: "\251\270\t\021|\362\362\373o\327\213\351\373\337\021=\213ey_1M\031\253\215\246\022:\326\"\332\204"
->
-leaves: <
-  key: "CCY="
-  value: "\257.\234a~~\322UJ\3255\364 d\331w\323\363\202\3476\210\340\350K\334\275\357\363i'\273"
->
-leaves: <
-  key: "CCc="
-  value: "\261\177C\031\0259\033\343\037!\231\330\260\3665\270\0374\021-M\203\225Z\347\255k\357d\035\311\254"
->
-leaves: <
-  key: "CCg="
-  value: "\263\001/\206\377\365\254\244\334\024\373]a\344\350\366J\361\302R\035n\036\340\236\0220I\264\336p\227"
->
-leaves: <
-  key: "CCk="
-  value: "\265\274`\"\376\351Q\242\343V\224\355Rp\3404\346\205|#\273\1773\026\177Pk\230F\026\242]"
->
-leaves: <
-  key: "CCo="
-  value: "\270\375\034r\020\321l.E\270\247\357\241\374\215\364*\325\261;,L\277\026\350['\356\345DY\372"
->
-leaves: <
-  key: "CCs="
-  value: "\274\023z\305b\370G\231XK \322\337\374\332\351i\221`\027\272B\016\341\343\276Ou}>\235\320"
->
-leaves: <
-  key: "CCw="
-  value: "\310\007^\177L7\346\033oQ\205x\177\000\270\361\376s\t\331\352\270\253L\305en$\254-\033\277"
->
-leaves: <
-  key: "CDA="
-  value: "\341\200\002\3700\341\256\210*n\037\214\017SL\305\203\2139\376\274\353\001m\362\330\221\250/\026az"
->
-leaves: <
-  key: "CDE="
-  value: "\343\345kq\314\354\232\347\321\265>\016\026\335\217\306j\237S\347\264\025S\356T=\302U\014\231\355\321"
->
-leaves: <
-  key: "CDI="
-  value: "\345\2218\2056\205\356]?.\326<do\177V\230N\023\021\\\271\022\315\342\006\370\324;\013j\236"
->
-leaves: <
-  key: "CDM="
-  value: "\353\026\203\305w\345I\360U\247\306\302\347\207+b\333oc\260\001^\340V$\225\\-\203}*\004"
->
-leaves: <
-  key: "CDQ="
-  value: "\367\250\223\366B\255\321\370\177w\004X\037\330'\036-\375F@v\271@\003p\230AV\354\2231\264"
->
-leaves: <
-  key: "CDU="
-  value: "\371\261\021\310\2641\244\240\352z\306;\242\301p^\331\367\014\370\225\221\321\355\354\204\365\264\275\214\252\351"
->
-leaves: <
-  key: "CDY="
-  value: "\377\250nT\305\037\253r\275\376\250'\031\345k&\022\323\351\370\326\203\265\322sCe#\210G.K"
->
-internal_nodes: <
-  key: "AgA="
-  value: "pOA\234\270\030\013\350\243Ijf\256\253\352\253\244\276\003\244\026%\023\3565\336\375\261\001fA."
->
-internal_nodes: <
-  key: "AwA="
-  value: "\234\026K\342nP\\`\244_\003y\250%\364\257g\252\313F\256{\357\2626\252H\250iI\213\355"
->
-internal_nodes: <
-  key: "AyA="
-  value: "h\030\n\277=\241\301\375r\345\246X\223\031\205\377B\346\340,\215\201[\321/\021\021\205f\336 8"
->
-internal_nodes: <
-  key: "BAA="
-  value: "O\220\234z\006\357|\266\014\036\232B\257WL\031x#h\326\nP\311\250\334\274\303\214=#*$"
->
-internal_nodes: <
-  key: "BBA="
-  value: "\327\370T\232x,\341\210\3218\337\312\311\020\275\373FH\227\316\0050KL\344\343g\207\264\251\275\363"
->
-internal_nodes: <
-  key: "BCA="
-  value: "B\346\224\031\232\313\023\212\246\272s\215\200}\n{oDG\214\264\342\0301Ii\232\r>\321\022\034"
->
-internal_nodes: <
-  key: "BQA="
-  value: "\253G\262P\372\372u\270\032\226\027-\016*\203\354\333N\013\2031\025\206\306R\354cS\032\331\250\274"
->
-internal_nodes: <
-  key: "BQg="
-  value: "\306\261\344\374{|\020\200\307W\304\n\225K\304\356\030\235\247\216\242\242U\377\252\277'I\017\037wl"
->
-internal_nodes: <
-  key: "BRA="
-  value: "\013\033W\220\343\017\006\304!\034>\313Q\362\021\276x<\200\240\322_\212\342\277)#$\324\313\307\036"
->
-internal_nodes: <
-  key: "BRg="
-  value: "\344\0101~\3354\244\237\006\257\345\362\010\301|?G\336\202\233\314\000\207\327\3104\313+\213\027O\217"
->
-internal_nodes: <
-  key: "BSA="
-  value: "}\205&jc\355\270PL\326O\256,\256\\0\355\225iI\373\340\244\364&\371\373\350q\001\010\022"
->
-internal_nodes: <
-  key: "BSg="
-  value: "H\307\331~\301\216\370\234\242}4\341\005\257,\270\233\213\350G8\255Ie\300^\322\235U\370\205M"
->
-internal_nodes: <
-  key: "BTA="
-  value: "pi\201\243,EZ\001\000\330\221U\233oC\215\231\274\260\212\223Cm\221\036\270\023f0\036\250\201"
->
-internal_nodes: <
-  key: "BgA="
-  value: "\233U\352T6\202\2248\237\007m\205\256\345\316\334\tf\331\366\016{A\307\342\222ljq\346>\250"
->
-internal_nodes: <
-  key: "BgQ="
-  value: "\024G\272\306R|\242\246\366\241\372\021\212Q\236\2254\220\257\340\357\354y\274\307\375\305\315\213S\206\273"
->
-internal_nodes: <
-  key: "Bgg="
-  value: "\227\212\313.8o\237\240\342h\377\344v\363x\213_U\236\343\010\235!\2245sU\021\334\010X\340"
->
-internal_nodes: <
-  key: "Bgw="
-  value: "\342\306C!\312\350\005~n\313\242\251\035\273\301)~4\032m\n}AW\377\260\320\324A\203\032z"
->
-internal_nodes: <
-  key: "BhA="
-  value: "7\031:C\306\024]\305 \t}Q#i\305\375\007\274\251\226\355\006\365\306\303\275\245\016cX\231\206"
->
-internal_nodes: <
-  key: "BhQ="
-  value: "\345\200Ur\310\314\250\264\352\262%\010\362\362\275\025\\\371\310:p+lp\202\360\030psf\370^"
->
-internal_nodes: <
-  key: "Bhg="
-  value: "\351\362\263\336E2nTB\257\324x\345\300\342w`\214\246\345\037\250=\355\255H\030\032\244M\"\223"
->
-internal_nodes: <
-  key: "Bhw="
-  value: "+$\217\364\317\203\273!R\357\247\323C\371C\305\310g\334\321\205x\307:\355\"\251\025\021\220\307j"
->
-internal_nodes: <
-  key: "BiA="
-  value: "K\001\206\322H2\300\262r\2752G@nOs\214\3549P?\241\356\345\372>\034\347\313\343\220\313"
->
-internal_nodes: <
-  key: "BiQ="
-  value: "\201o\341\3778u\t\"o\246\014#\215\371\177\006P\350\000\256\205\020\014\364 \231\240L%c\0032"
->
-internal_nodes: <
-  key: "Big="
-  value: "g\214w:oH\364\016\352@\254\317\271^J{\023\331@\276-=\275{\274I(A|\371\021\344"
->
-internal_nodes: <
-  key: "Biw="
-  value: "'>mG5\264!\351\246\253\246\325\3764h\275DM\233w\377F\207i>gb\002\362\005\177\230"
->
-internal_nodes: <
-  key: "BjA="
-  value: "\220O\366W\364\312a\370\016\220\360@\225\255-53\266{xt\257\322\025\2630\365\3269N\363\007"
->
-internal_nodes: <
-  key: "BjQ="
-  value: "xr7\313\334@\303\0259\203\370f\240ti[\225N\022\340\337\273seLh\242xk\271\236\221"
->
-internal_nodes: <
-  key: "Bw4="
-  value: "\312vs\002\351%\333#\326c\354\320}\3330;\226\006\203*\351@\005O\230\365\364\344\0226\371\266"
->
-internal_nodes: <
-  key: "BwA="
-  value: "+\245`\331\211u\016.\222P\213\2605\275?\266\335\2006v)\302\363A\t\333\361[aM\217\020"
->
-internal_nodes: <
-  key: "BwI="
-  value: "\002 \323(iT]\335)&\276\240\201\263\365 \026?nm\016\270\351\032:\313;\354t\3058N"
->
-internal_nodes: <
-  key: "BwQ="
-  value: "\316\3700\0304\216`h\324]\023\210\375s\363\255\360K\370]%;\271\260\267\255\005oM\273\366\333"
->
-internal_nodes: <
-  key: "BwY="
-  value: "\202\270u\217\323\256\217jB\303\303-\203\241\313p\363R\022\031\231\256\351ps\001\312\340\316\177\262\014"
->
-internal_nodes: <
-  key: "Bwg="
-  value: "5\036\3214\037\372V\250\000\302\214DC\211 Z\233\210ad\024'pb4\341!|\226q\0102"
->
-internal_nodes: <
-  key: "Bwo="
-  value: "\025\010\020:\0023\261\202\244\037\243q\356\335\na\277\336\313>]\207 \235\271!\254\243\222\222\\\211"
->
-internal_nodes: <
-  key: "Bww="
-  value: "\266\326[C:KWZ\212%MU1zw\rf\0170\305\217&K\303c\322\303vRR\372\214"
->
-internal_nodes: <
-  key: "Bx4="
-  value: "\230y\200\276\201\242\340\007\305\261E\013\230\031\231\376\233\3614\320A\3253\345_\367an\006q\225r"
->
-internal_nodes: <
-  key: "BxA="
-  value: "\243\374b\332\236U\375\347R\374\222w\037=T\276\266\314q>\356\367-C_\270\317\254\177\363\342*"
->
-internal_nodes: <
-  key: "BxI="
-  value: "\026\251qH\235\303\272\235\362\304D\0250\256b\376MxZ%\342_\021\202F\213w4\224\2536\203"
->
-internal_nodes: <
-  key: "BxQ="
-  value: "E\200.\277;\302b\360\334<\334\242\036K\021E\366\243T>\353\024\244\276i\245g\307'\026D\256"
->
-internal_nodes: <
-  key: "BxY="
-  value: "(-l\372\031\313\r69\030w\\3{\335\321u\224\323b\264\247\356\030P\353e\305g\027\327\232"
->
-internal_nodes: <
-  key: "Bxg="
-  value: "\270\316\030\t\334&\210\013%\314M7\3668#\210\373\222qI\030)/j\373\212L\351&C\320H"
->
-internal_nodes: <
-  key: "Bxo="
-  value: "8?\302tok3Q\207\207+,\2518&)%\303\260\221\322\230`T\024\320\201\306\0076)v"
->
-internal_nodes: <
-  key: "Bxw="
-  value: "\343K\260\356\023\276\367\224\034\305\323`\217\230\355\361z\010\362|g\\\"\250\034\372\347J\241\366\365U"
->
-internal_nodes: <
-  key: "By4="
-  value: "\207\244=x\347>\335\271k\t5\305\353\232\006\r\235\272x\237\277\335\276\035\207\275g\207\"\234/\333"
->
-internal_nodes: <
-  key: "ByA="
-  value: "\232u\032\013\2167\244/\273.\363\2701\266\210\312\3443\243\326%\t:\021\022[\364xml\254\200"
->
-internal_nodes: <
-  key: "ByI="
-  value: "\360S\237\321\020{D\213Iwm\005\226\226\243\357'\tS\017\204\"R\343\273\374\353\260{W\324a"
->
-internal_nodes: <
-  key: "ByQ="
-  value: "\000\"]\330\036\213\t\321\237\202\005-\221\034\266D(E\300 \022\317`kI\301]lO\005\364T"
->
-internal_nodes: <
-  key: "ByY="
-  value: "UT\267y\364\350D\311\366D\276\357\025>\361!N\376\001\302\3643\300\323\005\260\200\373\222\302\237t"
->
-internal_nodes: <
-  key: "Byg="
-  value: "x*\024 \231\370\267\001\032\270i\262C\320\355BL\213\034\026\234\255\334Rqc\002\267\025\312\252\260"
->
-internal_nodes: <
-  key: "Byo="
-  value: "]\343\206!|\254\\\243V\\\215\006\246\227m\376p\n\336{\341bj\353m\211\240\211?l\300\241"
->
-internal_nodes: <
-  key: "Byw="
-  value: "\333\356\312\360J\t%x\316\371\306\226oJ\n\013#\271~N\312\302\233?\232`@\210\021\240\237X"
->
-internal_nodes: <
-  key: "BzA="
-  value: "\224(O5m\244p\327\204#\324c\303\272\220\0029\"\366[\266O\267!\355\350\365\364\244\212v\214"
->
-internal_nodes: <
-  key: "BzI="
-  value: "G\326\375\214\022\300*\223\3555\334B\320\256e\230\363\032T\274>\210\3376\234lPI\245\340\336\n"
->
-internal_nodes: <
-  key: "BzQ="
-  value: "J\002\226\017\266\226\326\000v\t\372]\341\205T\007\256\342\211w|6\244\300Q\263\265O2\353]\271"
->
@@ -1,2030 +0,0 @@
-depth: 8
-root_hash: "\xed\x1en\xbcl\xb90\x9d\x98\xf3\x1a\xd0\xaf\x8f\xe6\x9e}\xda?\r\xfd\xbd\x8b\x1c\x82\x19\xa0\xf4\xa0Ih\xa7"
-internal_nodes {
-  key: "A+A="
-  value: "]\356\300KM\306\220a\3102\355\317=:\315\213/\036\026\327\361\266-\220\315\nRn\017\237\255^"
-}
-internal_nodes {
-  key: "A0A="
-  value: "\346\302\313A7\005\323\330\324\2103 \217\335P0d\350o\\W\363\324&9nn=?\362\307y"
-}
-internal_nodes {
-  key: "A2A="
-  value: "P\375\016\341\261\347\342=\211\254(-\356\276\301c\t/\224=pi\307\240<\032\242$f\200\323$"
-}
-internal_nodes {
-  key: "A4A="
-  value: "\251$\262[\335\013\351\262\203\365\362\202G\374\265y\030m9\215l\266\261\027\372\324\233\240|\035\346\375"
-}
-internal_nodes {
-  key: "A6A="
-  value: "\232\026\203D^HGdJSb#%\237\342\360\3260\036\321\221q4>-\020yl\034\275I\217"
-}
-internal_nodes {
-  key: "A8A="
-  value: "\341\240w\372S\210R\220\000\357\023\342E\365\253\027\324\362d\363\356\371}A\316\376\276\005\022\031\203g"
-}
-internal_nodes {
-  key: "AAA="
-  value: "\355\036n\274l\2710\235\230\363\032\320\257\217\346\236}\332?\r\375\275\213\034\202\031\240\364\240Ih\247"
-}
-internal_nodes {
-  key: "AQA="
-  value: "\330\372\327\233\210u\253On\223s\273@\362\346O_\035>\364\242\230\322\204\227\206\305v\220\303\350$"
-}
-internal_nodes {
-  key: "AYA="
-  value: "\022\273\253[\330\237\254\262\257u\231\245\363\306\376n\273B\333\343\251\363\270\310\005~\252\321\214\324V\323"
-}
-internal_nodes {
-  key: "AgA="
-  value: "\221\351r\246\311\201\223\331f\356o>\337c\251\331x\317\310}\003\3630\312x\232\240b\t\376\214i"
-}
-internal_nodes {
-  key: "AkA="
-  value: "\253\334\013\"\370t$\334\274\327\235\004\246\224\305\000W\254>\027\353\321\267\271\312\177\253aA\241v\000"
-}
-internal_nodes {
-  key: "AoA="
-  value: "\314\336+\341\035e\226|\200e\324C~N\345\205\2106\230\326\021\310X\007\026\324i\201\233\202\250\223"
-}
-internal_nodes {
-  key: "AsA="
-  value: "\013\n\330\227\007*\203\2154l\032B\036\227:E\310\327;Fn)\001\007\tr\216\320bB\002\374"
-}
-internal_nodes {
-  key: "AwA="
-  value: "\320y\231\222\017w\265TXT)&d\304]2\330\324\033\037\361KX\253\027\021Y\021\203\233\365\254"
-}
-internal_nodes {
-  key: "AyA="
-  value: "\232\362\242V\254\034\007\267a\306\227\343P\261\024>\236s\274Sq\305r\2527\365\375\230\223\300]\220"
-}
-internal_nodes {
-  key: "B+4="
-  value: "\364\242\371;\003N\371P\312\340\251\252\232\000\306\337\244\346\331\002\335\366\305Cz\000\321\314%H\\\266"
-}
-internal_nodes {
-  key: "B+A="
-  value: "H\237\254mr\377\014tJ\255\313.\311`\214aP\340>\360\325uMW\373ol\256\013R\0231"
-}
-internal_nodes {
-  key: "B+I="
-  value: "\342h\014)\365\214\334\307\243\333(v\265\010\353c\202\335\025\371\351\370+v\320\037\001\321\3629TY"
-}
-internal_nodes {
-  key: "B+Q="
-  value: "q\014\201T\261=$\364\321\325\257p\031`\306\014\nS&\264Wl\351\314\301\337\226\301\244\260\214\346"
-}
-internal_nodes {
-  key: "B+Y="
-  value: "\365R\314(\276p\211Df\252/j<\306#\t=\003r\234\345`h\224\267\275\211\'k+-r"
-}
-internal_nodes {
-  key: "B+g="
-  value: "P\303i\227\031D\014\240\252\374#\265\212+\325>2@\364\333\177\022\n6R7\314T]\220b\253"
-}
-internal_nodes {
-  key: "B+o="
-  value: "+\256oK\231MFn\325\005\305\230\177\210N\220\217\370Or\242U\366\230\313\033@\241\314\025\0073"
-}
-internal_nodes {
-  key: "B+w="
-  value: "\035V\033\362\0072\354ZF\nJ\341\241q\355+,\336\303\'\024L\335\240\250?\300Q\212E\363\027"
-}
-internal_nodes {
-  key: "B/4="
-  value: "\217`\335?7\037\375\305\3112\014\250p1D3u\016\364\n#\010t?\265\300\216\036q\017\035\341"
-}
-internal_nodes {
-  key: "B/A="
-  value: "K\0106&\335\301\243\013\206\325\216\215\\\204Q\227c54\214\257\364SJ\347\374\2611Pa^\324"
-}
-internal_nodes {
-  key: "B/I="
-  value: "\206p\002B\204\227!\224\322\273\020\016L\016-\301h\nQ\203\007%\250\271\236\266\267\303\212s\355\365"
-}
-internal_nodes {
-  key: "B/Q="
-  value: "!\346\361\253\024\345qXa|\"\371s,\275\213\t\214K\202^V\240\277\247\2310\247\365\231;r"
-}
-internal_nodes {
-  key: "B/Y="
-  value: "9\r\204\227\302\330W\234\235\262]\376\0204\034\r\220 \350\026\005b.\323\024-\004lR\017 \267"
-}
-internal_nodes {
-  key: "B/g="
-  value: "\235VC-\244B\007\007\257\016e\274\342o\032PR1\251\354i\234a\032p\257q]\013\215\266\337"
-}
-internal_nodes {
-  key: "B/o="
-  value: "\316&Z;\\[g\357hw=\026\243q\002\265\"\251hV\2371k\242d\370\217\345xTGQ"
-}
-internal_nodes {
-  key: "B/w="
-  value: "\234\343\323Q\327\037\266\345\264\371\325;\253#\275\253\370\343\035cT6~(\024\016\200\2515\350-W"
-}
-internal_nodes {
-  key: "B04="
-  value: "\224u\017\021\276\007\236\207\036\245i\206\227\3436\365\312\205\256@j\354J\213IK\021\023\330\271\022\213"
-}
-internal_nodes {
-  key: "B0A="
-  value: "\004\227\005\266.\204\360Z*!\030\333\243\2360\'`\361\2268\222\373\027\224l\3365\320\271\004i]"
-}
-internal_nodes {
-  key: "B0I="
-  value: "\354\022\253\362\236\355\336\326\303\023$\'\357\335\265\367]i\334\231x\316\250\361\244\263\006)\312|[\273"
-}
-internal_nodes {
-  key: "B0Q="
-  value: "\336j\031\203!\326:\254\366q\233hD\213\377]\321\253\343\250\325)\2437,6\036\223\024FC\221"
-}
-internal_nodes {
-  key: "B0Y="
-  value: "\335\"\300@b\n\206;\213\260\270_6\351\005%O*\304\000\374\362#\372:\327\034\230w\010\214L"
-}
-internal_nodes {
-  key: "B0g="
-  value: "|y.@\222\223l#\246I\2435\202n44\217-\302*\222\332\031\r[\201~\302iOXF"
-}
-internal_nodes {
-  key: "B0o="
-  value: "+\200\271\240\262\344\365\\\346a\250\325\212%\365w\212A\006\206O\253\210\375{\3531\r\322\275\300\200"
-}
-internal_nodes {
-  key: "B0w="
-  value: "\326\327\311\240\252\276\021w \223a\344\307]\177\0038v\3737\000\006*\230\314\324\276\3124\366L\001"
-}
-internal_nodes {
-  key: "B14="
-  value: "pdwV\n\302Ct\323\235\313\233 ,\361\337\237>\354\273\250\030\317\360{\370\024\306}\345\317P"
-}
-internal_nodes {
-  key: "B1A="
-  value: "vi\022\226\372\354k\350\2622\222\3514A\260\205\"\r+*wCG2pR.\235r\000\356\317"
-}
-internal_nodes {
-  key: "B1I="
-  value: "v\376\016\252\177\363\247\010H\257Y\240\201\264\010v\214\001\357\210L\t\264L\235\371\0300\017R\251\373"
-}
-internal_nodes {
-  key: "B1Q="
-  value: "\013S\2354\230\252\027\325\271\206y\355\251#H\177\353{\377\007\024<\013\366F\320\255\304\232x\214\241"
-}
-internal_nodes {
-  key: "B1Y="
-  value: "!\313\215p\233\356\330l\244p\313\264\340\264\031\201$-\263\241\274\217\332\367\225Z T\341\264\n\313"
-}
-internal_nodes {
-  key: "B1g="
-  value: "\242\177\'o\344#\362\221C\'$\365I\376\320\345K\024\201\371hT\014\264\257\334\212\323\212X\363\003"
-}
-internal_nodes {
-  key: "B1o="
-  value: "\244\206\363,\372\254\261\372\301\216\332&d\277H9G\255\327\177z\344\241\013\304\217\320+A\315\300\001"
-}
-internal_nodes {
-  key: "B1w="
-  value: "\274W\231u\222\005\315\212=\n\352\353\034w\000\303\017\301\374\017\307\276\325^\366\202^k\005\353\321\373"
-}
-internal_nodes {
-  key: "B24="
-  value: "\2717\301[R\352\235\375P\005\353\372\223\273\003d\325xD\346a\\Z\303\212\013y\202\tZ#\357"
-}
-internal_nodes {
-  key: "B2A="
-  value: "*X\266\t\244\t\225\320saP6B\236\236\302\326\\-\251\270\211F\r\312\317\004\227\002\274\254\370"
-}
-internal_nodes {
-  key: "B2I="
-  value: "\251\307\355!z\306\332\017\265aX\036\375\222%Q\347\264q_O\224^\013#\033N\306s\366\300\374"
-}
-internal_nodes {
-  key: "B2Q="
-  value: "\371\333\363\314\375H!\330XB\357\335JY\317\253\275\270\317\311\266\221SUDD<\323\270T\254f"
-}
-internal_nodes {
-  key: "B2Y="
-  value: "\343mIO\230\031k|\302;\344\221v\336d8\327g\205\355\020w[<j\216\226\250\246a\255\227"
-}
-internal_nodes {
-  key: "B2g="
-  value: "\031;Q\215_\312&\353\250(\361!w?V\337\016\360z8\256bK\222;\272L\352g\005\245\310"
-}
-internal_nodes {
-  key: "B2o="
-  value: "\006E\n\332\242\375D`C^\364\3165\023\344l\0307K\265`*\3525\207\031\\\020\363Ty\210"
-}
-internal_nodes {
-  key: "B2w="
-  value: "!\'^b\263\266@\327\027.s\221\240\205\252>\3722)\366j\000\371\276)\267\344y\213HN\r"
-}
-internal_nodes {
-  key: "B34="
-  value: "9\257\005J\242\001\355m\0256\0326\305\212\234\237\n\363\232\376S<\346\360o,\245\275\263I\216l"
-}
-internal_nodes {
-  key: "B3A="
-  value: "b\247V\221\307>I\342 Og\262\250\200\3651}k\206\257\343}\266\272z\211\224\207\244m\3338"
-}
-internal_nodes {
-  key: "B3I="
-  value: "\243\274\270\305@\367\252\332.vI\333\263\0376\324\tO\002\271`P1j\n\032\340\010;\305\006\367"
-}
-internal_nodes {
-  key: "B3Q="
-  value: "\337\3653S2\332\233\3142\027P\r7\370\221\205\024\230\320\311OCN\300i\243\\Kw\214N\252"
-}
-internal_nodes {
-  key: "B3Y="
-  value: "\213\335?\"9\205\350\277\204\222W\354\270\355\273zj\2066\3033\347\350-g5#\307\202\325\200\206"
-}
-internal_nodes {
-  key: "B3g="
-  value: "\017u \220\300\270\256i|\234)\032\337`\341\205\016ZrC\023\237`NI\315\276;\034\254\270\374"
-}
-internal_nodes {
-  key: "B3o="
-  value: "B\274]\350]A=m\005\262\210\227\342\245M[\026*V\230\017\032\303\005\345\236\265\211\004R\351\344"
-}
-internal_nodes {
-  key: "B3w="
-  value: "+?\227\n\321\035x\245\014P\300j\275\265\020\234\365\203g[\372\244\345\363aZQ\215\363\3323\242"
-}
-internal_nodes {
-  key: "B44="
-  value: "#\025%~N!\022z\257E!\315\207>2#ts\223LzSj\375\021\351\002U \372 \337"
-}
-internal_nodes {
-  key: "B4A="
-  value: "\312g\360\306\346\235L\366rc\'\273\256\010K\342\375\3428\273 &\331\340\002\211\323\013\033\321iM"
-}
-internal_nodes {
-  key: "B4I="
-  value: "\340\230\'\\e\303\021\201\374\210s\364w\t&\224x\330\376\233\177_ \260\323@\0367\232\232\177\362"
-}
-internal_nodes {
-  key: "B4Q="
-  value: "\257.\377G\326\007\222\204Iw\032|\244\310\245tU\206\230=8w\315\352\346t\257\355\3374\005\010"
-}
-internal_nodes {
-  key: "B4Y="
-  value: "\242B\027G\001\245\306\361/\320\236F\221\213\245\010\014\317Q.\336\036\r\235\336\223\010\2429\220\3138"
-}
-internal_nodes {
-  key: "B4g="
-  value: "\210C\336\303\037f\201\364c\337\006RY\340\2274\206\263\226t\371\032\355rN\2763M\031(X0"
-}
-internal_nodes {
-  key: "B4o="
-  value: "\333\255*1\2245\274\372\242j\200\353\315\261\2736~o\274\350\023\207\230d\0261\35614\253\367T"
-}
-internal_nodes {
-  key: "B4w="
-  value: "d9\371\264\227h\036J\t\001\010y3\343\010IQ\007\327wE2~\020k\026\231\324\306\367i9"
-}
-internal_nodes {
-  key: "B54="
-  value: "N\262\002\203\312MW!HG\363}\350`a\\\201\'8\312NH\t\2747\016\206\375\nL7-"
-}
-internal_nodes {
-  key: "B5A="
-  value: "7\220\340\230\250B\275@6c\020|\262\277\224\203C j\013\010\250]Xog\326\377s\261N\\"
-}
-internal_nodes {
-  key: "B5I="
-  value: ".2\315\006\002d\260Y\232\307\2125%\ra\222v\001\337]\035z\354\363\225\032\013\251\214\371\211H"
-}
-internal_nodes {
-  key: "B5Q="
-  value: "@U\350\215\364\031\322\2378ho\227>~\256\276\220\335\\u?\362\204\027^9&e\003\317[\300"
-}
-internal_nodes {
-  key: "B5Y="
-  value: "\317\210\26240m\0052\200\257Pn\231F\272\026\3410\014\257i\225,s\344\177\344\002g\"\226\366"
-}
-internal_nodes {
-  key: "B5g="
-  value: "~\347q\031\305\3757\251\221\006\177C\310S\366\240_\216\361}\241f\227Yq\363\035U\222g\313\202"
-}
-internal_nodes {
-  key: "B5o="
-  value: "\263\203\222\2317\032d\210K\216h\252\177\177\000\035\344\364\203\257\226\0249\263\315\033\024v;\271\365\356"
-}
-internal_nodes {
-  key: "B5w="
-  value: "\276j\350\344I>!\001l\210\034\255U\177\300m\357\020. [\331\202n\373b\034\313\235\3358\341"
-}
-internal_nodes {
-  key: "B64="
-  value: "\007\317\245\235\021\255/G\320\277Bcp\277\232\010\253\206\316\202\334X1c\277\361\005\020\005\233c\235"
-}
-internal_nodes {
-  key: "B6A="
-  value: "9{\211\376\363\347\361c}/\001\337\"\324\200f\016a\277\233z]\222v\350\221\255\3146\215X\343"
-}
-internal_nodes {
-  key: "B6I="
-  value: "b\266%\205e/\214\207\201Ry\226\016\357\327\261]\370\227\000\325\'\267\326\373x\375d$\305\353o"
-}
-internal_nodes {
-  key: "B6Q="
-  value: "\315&]d\3738\031\034\360\215r\010\346O\005\342h\0044\247\232tX\323\300;dQ\016\037\344\r"
-}
-internal_nodes {
-  key: "B6Y="
-  value: "P\320\237\260U\222i\252\220b\014Q\224\005\366>\257\025qg\010\202\215\242\230\213\200\220\240\251^\014"
-}
-internal_nodes {
-  key: "B6g="
-  value: "E\343c=\334\374\323Gu\205<!\0066\221\215\220\367\375\002JT\211\332\032<\032:\266\241\370\225"
-}
-internal_nodes {
-  key: "B6o="
-  value: "W\331\273\327\352\037ap2\346t.g\350\021\004\t+Cq\264^|\237\345\212\301\310\261\204\302\371"
-}
-internal_nodes {
-  key: "B6w="
-  value: "5\002\017\247\376\033\215\314`\243\232\361\242\315\010\313B\370\334@\274\326\374\003z\215\253\215\302\370r\317"
-}
-internal_nodes {
-  key: "B74="
-  value: "\317$\307\2372\r\271:>\013\034\216\336\202\007\211n\244[\374A\260Q!\373\034\265Mw\261\316\323"
-}
-internal_nodes {
-  key: "B7A="
-  value: "\302\204c\030\355T\r\357\255\352#\267\216\355k\221\207V<\301\320\327N\356=\026\304]\337\022\355E"
-}
-internal_nodes {
-  key: "B7I="
-  value: "\030\237\203\346\320\375\363\220\257\300aH\3203\025Ds\253Cs\304\350\022\371\347lm\2671\273 i"
-}
-internal_nodes {
-  key: "B7Q="
-  value: "\314\362\315\233\375\033u\377\336\256\t|\264\273<\304T\202\262\346F\013<\341\366\230\253\361\036\231\367\371"
-}
-internal_nodes {
-  key: "B7Y="
-  value: ")\355\252\207\177\306^\003R\0307G\314\035Yz4\341\324][~\227\205\021\'b\352_\204\r\020"
-}
-internal_nodes {
-  key: "B7g="
-  value: "\210\323\275\377|\036\013\3647m\377\275\217\202\313\037\026\325\007\003\253<\036 \2341\031\024a\240^\037"
-}
-internal_nodes {
-  key: "B7o="
-  value: "M\204\230do.v\201\013Iqc\340\2569<\366|\331\203f\000\233\236\354vy:\343\256\3752"
-}
-internal_nodes {
-  key: "B7w="
-  value: "\352 \033;<\317\246\352\234\344\337\261\355\330\322_\2271\344H\316\366\211_\207\202o\332\301y\212\371"
-}
-internal_nodes {
-  key: "B84="
-  value: "\322\023\020\317\r\377\324\316\370k\347\217@\246\215\t9\244Y\362\255_c\355\017\330\332N^\021\201@"
-}
-internal_nodes {
-  key: "B8A="
-  value: "t\244\324\250y\376\376\220\240\336\332\013\320\300Q\275e\277\221\360\351\311\343\333\367*\023\354\373\030\331\344"
-}
-internal_nodes {
-  key: "B8I="
-  value: "C|\246\303\211\231M\353tI~\223C%=\202\305\035\023L\3345)\212\245i\245\345\304P\ru"
-}
-internal_nodes {
-  key: "B8Q="
-  value: "\217_\035\2201\341\263\237o\340\264\367U\300\227\352\305\205m\315pfB\034\332\033\333z\233n\221\235"
-}
-internal_nodes {
-  key: "B8Y="
-  value: "\357\001\020U\336\254B\016mp\211\2650\036`\253\225\023\0349FJ\235\276w!\365\244\243\025\177@"
-}
-internal_nodes {
-  key: "B8g="
-  value: ")/|\250\207\002\200]\351\317\016C\016\241\340\275Q\272\247r#G\002\212x\016|\325\361\373\245\346"
-}
-internal_nodes {
-  key: "B8o="
-  value: "\356\313\033\025\026\364@MCB\263\003!EL\322EY\324\220S\223&\235\231\321\326C*\024>\335"
-}
-internal_nodes {
-  key: "B8w="
-  value: "l4\252H\340\034\236\257R\\\364\337:\2138\230j\376\265\330\317~\333d\302\2529\006J#\377\001"
-}
-internal_nodes {
-  key: "B94="
-  value: "\376$\342L\301\340a\211>p$\214\332\010\\\333\237Dzc\363\353\312m\333p\000\027|\262\302\310"
-}
-internal_nodes {
-  key: "B9A="
-  value: "R`\260o\3542g\317TmK\033\033\374\233\214_\302?\263\237\277C\274]\022\236\342\251\240J "
-}
-internal_nodes {
-  key: "B9I="
-  value: ">\\<pe\200\372\377\323\001\277\252\232\346j\232\254\253G\006\347\271\323\322w\024\301\330I\007\364\003"
-}
-internal_nodes {
-  key: "B9Q="
-  value: "v%\217\003W\227\241e2\221uW\22271%f\335~\372\372\002]I\370:\240\373%\030\333N"
-}
-internal_nodes {
-  key: "B9Y="
-  value: "\323T~-~p\036\032\010K\373)\350\324\367S-B\222X\314\274\023\314\0369\207\027\377\326\\\330"
-}
-internal_nodes {
-  key: "B9g="
-  value: "I\264,A<\343\361\246\366Y\255Y\237\304Y\357\262\001\365\000\006\375!\224\202\331*\317\341\206\300\375"
-}
-internal_nodes {
-  key: "B9o="
-  value: "U\242\007\273\2771\215\334qJQN\352\351\317O\272#\261\202\317]#\302\374\r\222Z\204\255\221\347"
-}
-internal_nodes {
-  key: "B9w="
-  value: "&\021N\246\271\220\233\375\260Vy}1`\200\301\350\364\232d\364\031wN-%\357K>1\004e"
-}
-internal_nodes {
-  key: "BAA="
-  value: "\205{\312C\345>\225P/<\231\200\004\237\375\0002I\2428\257+\021\0133\320v;\0027\317j"
-}
-internal_nodes {
-  key: "BBA="
-  value: "\236\016\227\332\273\240\254\023\363Yk\342f\334bQ\234\202\254z\230W\327\267\020\266\354^\210\"-\006"
-}
-internal_nodes {
-  key: "BCA="
-  value: "cK\211\351W\253\317\215\022\343\242D\335r\231\340\224\360\377\372g8fL\226I\0333\034\002\347I"
-}
-internal_nodes {
-  key: "BDA="
-  value: "\360eP\372`3\306\232\026\3234,\323;\034\277\215\021\202K\025\246\307\307\371\211\255\363W[\237\033"
-}
-internal_nodes {
-  key: "BEA="
-  value: "l\251\021lz\300\345\307\253\n\010\014UJk(\3150\323M\351\223\243\217\245j\326\021j\320\333l"
-}
-internal_nodes {
-  key: "BFA="
-  value: "\217\035\257^\320\320\240\244\334\263\014\332\n\252\207+\330c\261H\273\223mj,\010nb\303\203?\031"
-}
-internal_nodes {
-  key: "BGA="
-  value: "\332\235\3117wK\366\242+\020\300\231\204Y\276\266\267\014\016\201]\342:K\027\257\264hKKV["
-}
-internal_nodes {
-  key: "BHA="
-  value: "\265\346\337n\016\031\367\272\374\222\243\327_\036\261\2773.\211\323`\330\323M\023\336\315\233v\236\"\237"
-}
-internal_nodes {
-  key: "BIA="
-  value: "\231\223\316\316\204\241W\213\353\2673\376\374\346\344\322\377=\210\327U!e\035\214z?\257\023\025Hk"
-}
-internal_nodes {
-  key: "BJA="
-  value: "\311\352\025\310U\311=;d\315\3333\237\231s\267\221\212\274\001\261Q\203>\370i\316y\200\326I\302"
-}
-internal_nodes {
-  key: "BKA="
-  value: "\341\037\256\250>\260ZN\326\036\327\344]\234\251\311\254\216x\234wN\345\021\331L\256\234\014rK\247"
-}
-internal_nodes {
-  key: "BLA="
-  value: "\346\251\\l\252\\\327dX\037D >\374\010\337h\205\027\212\202\261\316\037\317S8\030;\017e@"
-}
-internal_nodes {
-  key: "BMA="
-  value: "K\370\310\206`\314b\'\314T\254\014,\310\340\310\031\241?\342\304\223;\322\317\210I\t\366[U\206"
-}
-internal_nodes {
-  key: "BNA="
-  value: "\301A\253+;\005(\313\325[\314HM\202|\304\023ki\2376x\200\022\346|\276\231h\266\n\356"
-}
-internal_nodes {
-  key: "BOA="
-  value: "\242\'\253x\321\231\311\221q5^\343\273\306\226\033.x\320\334\260\325\234\034\240\213e\216\353\372\2521"
-}
-internal_nodes {
-  key: "BPA="
-  value: "Y\'|\'\032Xt\343C\007K\237\366\313\253#o|u\005\003A\320\326?L\306\007\332\355\023E"
-}
-internal_nodes {
-  key: "BQA="
-  value: "\002\023Y\027\031\247\271\345\005K\257%\333\373i\305\023\246\327\360s\244\2773\205T\221\247\013\r\031s"
-}
-internal_nodes {
-  key: "BQg="
-  value: "q\304 \304\032a\005Y\200\210n\0307\372R-\372\316\002\347\'<\373\r\262\345\216\222\000\210\270\314"
-}
-internal_nodes {
-  key: "BRA="
-  value: "\277\306\212\353\322\213q\211\312\243\300^x\247,\320\314[1\2576\375\327\300\225M\310\035\205kZ\245"
-}
-internal_nodes {
-  key: "BRg="
-  value: "\357\244e\226\226\267s\262\272\205{\311\214\005\236\354\251BI\347\314\014\353Q\212\\\227\221\342\343\350\330"
-}
-internal_nodes {
-  key: "BSA="
-  value: "\220*\220\376\317Y\311<\327\276=\002\017\350\300x\373\373\025\213\005\222\377\303x\007!\367\374c\341_"
-}
-internal_nodes {
-  key: "BSg="
-  value: "\226\210\311\nK\024\\\254U\374\244\244\3121\255WuY*1&W\310\201\252\322;\2512\351\227\t"
-}
-internal_nodes {
-  key: "BTA="
-  value: "\272\252l\273\020{P\247\016a\017\230\225\354\007PG\204\3067\242Z\330\277V\021\230C\005\254[\333"
-}
-internal_nodes {
-  key: "BTg="
-  value: "a\033\rq\004z\345<>\306 \304\313\231N\237\231\370=[\350\331\030R\"Q\251\026\244\005:/"
-}
-internal_nodes {
-  key: "BUA="
-  value: "\253fN\215z\033P\\\221uO;H\240\233\034\371\254z\250\267P\367\364\207\206Io\251G#\347"
-}
-internal_nodes {
-  key: "BUg="
-  value: "\246CIR\311\251\214#\037\033\256#\333)\363r[\377\366\237\332_H\370\344;\265\356\035\253\301\345"
-}
-internal_nodes {
-  key: "BVA="
-  value: "zy+\256\000:6A\350\017\335\r\211\375\261@\254%\034X\367\274\372@\357\354\'\020\345\005\217C"
-}
-internal_nodes {
-  key: "BVg="
-  value: "\216\357\335\246}\t\210\026:f\222\220\010\313\312\340fM\017\235\225gAb\227\271K\342\305n\003\204"
-}
-internal_nodes {
-  key: "BWA="
-  value: "{\321\223\np\256\017}\261f^\253J\225\361a)\022\250!@\344\211\010\371\302\307o\266Z\367\240"
-}
-internal_nodes {
-  key: "BWg="
-  value: "\233\257N\004\247\305\'\206\302\231\355\262\016\252k\203\264\023\256\276$\222u{7\252CO\327\266I\275"
-}
-internal_nodes {
-  key: "BXA="
-  value: "\277u\355\034y1\377\'v\302\310\233v\341&\020\035\200>\277R\211\302\2545H\345\373@t\034\026"
-}
-internal_nodes {
-  key: "BXg="
-  value: "\355\020!qH4\204\344\310[\301\3419i\215\343\000\245\344\375\r\247\355\214\014<\203gd\247\201\024"
-}
-internal_nodes {
-  key: "BYA="
-  value: "t\372S\271\032\303\377\305|\245>\210\262\263\t\313\267\224}Sg)\242\256\207\275\366\027\260?\221\310"
-}
-internal_nodes {
-  key: "BYg="
-  value: "+\316\2768\306b\265\025F\204F\344\313S\307\001\345i\264nX\024{\30367q\010H\264s;"
-}
-internal_nodes {
-  key: "BZA="
-  value: "k\002\025\332\207\377\177\225\277q$V5\2636s\256\361\267~e/p_m\330\327\233\325\235\233\225"
-}
-internal_nodes {
-  key: "BZg="
-  value: "\033}\334\266L\272\n\300\274q\303%\031\220\027\352,g\005Zv\223\304\374\277\373\036\357\325\230\256\206"
-}
-internal_nodes {
-  key: "BaA="
-  value: "<\201g\276\350\037\375\265\2430v\264\023\346\233I\237\246\"nt5Gx\273\336\177\020\246\345\350\346"
-}
-internal_nodes {
-  key: "Bag="
-  value: "Q\031\221\010\024\311/s\237I\243[U\303\273\"\n$\317\032\233\'\225\024\202\037\023\005E5.,"
-}
-internal_nodes {
-  key: "BbA="
-  value: "#Px\035t\217oA\310\341\2514\007\323\273tn\223\264\"\344M\304\277h\r\272{\340\274\2037"
-}
-internal_nodes {
-  key: "Bbg="
-  value: "R\340\257\024\014\367\255\202\\\021B/\341\230\310\234\317Y\303\n\262\376\003\275\236\006\321=\237or\226"
-}
-internal_nodes {
-  key: "BcA="
-  value: "\306\242\322\245\247V\231\200\206\021G\005\357\207 ]\236\367\3266\340~\272\273\001\222\362q\266\244\367\331"
-}
-internal_nodes {
-  key: "Bcg="
-  value: "Q_%\337\215\227\226\t\322\360\322\326\r\222\236\345\3227\326 \014\245\303\242o\2418:\000p:\314"
-}
-internal_nodes {
-  key: "BdA="
-  value: "S\\\276\326@r\213\006\277~\351\264\"\265v\006\326\016\342\262=j\201G\246tjq\224\300\250\266"
-}
-internal_nodes {
-  key: "Bdg="
-  value: "\301Z\313\220\006Jt\360h\025\352\260\311$\276V-\270\372\222l#\225FX\352\303\030\211\206\355C"
-}
-internal_nodes {
-  key: "BeA="
-  value: "\026\256\241\350\333\027_(\223\304\030t\333\243\221f?\317\264\004>\0325\230\321\230\251\267\363\2561\357"
-}
-internal_nodes {
-  key: "Beg="
-  value: "UL\242\332\241\271\343\2455\3125u,\335\267\227{\325\221D\002K\345B*\240\314\255\255\245\250A"
-}
-internal_nodes {
-  key: "BfA="
-  value: "5\275\310\306\235_\345\373\231R\340#H\235\256\351=]\345\205s\335\321\254E\260\007\253\363\"\016-"
-}
-internal_nodes {
-  key: "Bfg="
-  value: "\322\004\210\376\350]~\271q\031\267Iq\220\321\360?\377\303\323\331\322\304\232\203\200xbFMr/"
-}
-internal_nodes {
-  key: "BgA="
-  value: "\376\344\016\253CZ\334\336\270\311\361\027\271\367\260\\\222\326d\021\022\177\356\217w4\250\370[\363\2711"
-}
-internal_nodes {
-  key: "BgQ="
-  value: "\244\010\0056k\035\226\351\372t\013\366*\303\202\236\021N\340\252\306)\353\365\315;\rP\004<,\344"
-}
-internal_nodes {
-  key: "Bgg="
-  value: "L\353\021)\215\210\217\361\271o\355\331\377\003P\236\361\322\nF[\331\250*\246\357\223Rq:\330\365"
-}
-internal_nodes {
-  key: "Bgw="
-  value: "\2675\250\006\277\021\264\211O\312\255\315\353m\366u!Jc/\2233\307\235\010\2114\240\371\310\214\246"
-}
-internal_nodes {
-  key: "BhA="
-  value: "\232\227\023\017\2428\023\207\332\372\271\235 yI\247]7\317:l\265\023\341\376`\235i\'\033\356$"
-}
-internal_nodes {
-  key: "BhQ="
-  value: "\0340\303s+m\034*\020^p\2279\317?\330\363\017)\253\313\302\306U\272#\363\226i\206&8"
-}
-internal_nodes {
-  key: "Bhg="
-  value: "_{\310\320\253\274\372\006\207\353S\253\337P\323%\004=\006<mI\235E.\272\364\224\213\206\025Z"
-}
-internal_nodes {
-  key: "Bhw="
-  value: "\237Oa/\366\021E#\013\300J\3460\237\027pE\214\351R\r\267\316\032ax\343\225\201\351\263\213"
-}
-internal_nodes {
-  key: "BiA="
-  value: "N*\237`\334\205\225\362\2668\016\314d9y<\256\313m\017DW\007\331\313\212\272\036\302(\354["
-}
-internal_nodes {
-  key: "BiQ="
-  value: "\037%%\272\305m\251}i|\230\025\003\327\216\351\'KSk\274w[\334k\276G\342\217t\315\245"
-}
-internal_nodes {
-  key: "Big="
-  value: "0\206\3779{\032\351_\224\3575\033\321\323\227\360\312\340\346?\246FQ\304\240\240\324\"\033\345\323D"
-}
-internal_nodes {
-  key: "Biw="
-  value: "[\205T\257\245\323\214\317\224`Y\374\316VQ\273_\244g>\346\016N[P\325\233\343\242\032\2028"
-}
-internal_nodes {
-  key: "BjA="
-  value: "\036\230\0374\272\2217M\321\372\2504\334\267i\366\354\217X\351}\235\2445\311\313I\016\342\266\240\266"
-}
-internal_nodes {
-  key: "BjQ="
-  value: "\"\n\025X\221\274\016i\207$\222\007b\244\300\261~@\031\377T\230\2052\014\343\026\000K\3638\307"
-}
-internal_nodes {
-  key: "Bjg="
-  value: "=\035\306\260\343\323\373-/\313\357U\334\356!\032\270\316\215b\367\240\377\237.B\301\023\213\247\356a"
-}
-internal_nodes {
-  key: "Bjw="
-  value: "\021\372\265\002/\317\376]TJ\276\3321\021\177\300\214\353\2140K\322\200\020\315\346\t\001\271\374?\007"
-}
-internal_nodes {
-  key: "BkA="
-  value: "\270f\244\227,k\367:|<s\245v\206\342\351I\267\235\306>c\000\003\336\234w\201\357\370dC"
-}
-internal_nodes {
-  key: "BkQ="
-  value: "J\033OP\331{g\007\346\211/V\020b\317>-\2708|Ut8\357!\363?\333\253\021\034\002"
-}
-internal_nodes {
-  key: "Bkg="
-  value: "u5\250\0309\340s\332\365\201td\227]+\034q\340&\260\216\221\033\236\242q\301\306N\265\371\251"
-}
-internal_nodes {
-  key: "Bkw="
-  value: "\220\rR1\2754tr\371HT\360\324\016F\220\202^\236\232\r\363u\264-\332g\027\337\243C\000"
-}
-internal_nodes {
-  key: "BlA="
-  value: "?\264\023\310r\316\316\276\363\211Y\263<\207\306\"5y\254Z\0362q\325\372CGp\334\'`%"
-}
-internal_nodes {
-  key: "BlQ="
-  value: ";1I\006\362J@\353\264\027\357_\336\324\246\344\0104U*\373\0101\315\324[\357\222\350\226Q\256"
-}
-internal_nodes {
-  key: "Blg="
-  value: "^*^\266\277\tx \260\223\232P\021\004D\001\263@R\255&%\022\264\332\361F7\266\335\344\215"
-}
-internal_nodes {
-  key: "Blw="
-  value: "\335\333h\016\350\334\365Tq\346lw\220\271\027\006\023\020$\243\337j\242nS\2132\203\233`\215\'"
-}
-internal_nodes {
-  key: "BmA="
-  value: "/);O\242\027J]D\234\211v\355\215\230\025|\033\235#\357\226q\205\331\250e\351g\245\3663"
-}
-internal_nodes {
-  key: "BmQ="
-  value: "\367\365\035\304e\226\004\232\270\n\244\224g#\341\313\024\205\352L\016qK\352@]`\2263\246\251&"
-}
-internal_nodes {
-  key: "Bmg="
-  value: "&\036\027K:\207\007\372o\365\021\366\246u\270u\317\222\321\341\254\210\212\035N\276\254;u\227\322>"
-}
-internal_nodes {
-  key: "Bmw="
-  value: "GrJ\372rt0\271\232@\353\361\237l\215eW\014\347pd\343\211H\246\267\3260/\344\240\272"
-}
-internal_nodes {
-  key: "BnA="
-  value: "\241\260\020\226\361\2602\215\233\203\320\000|\'MZ\232,\021\226<\377\217\274\236\022\255\000\335\227@7"
-}
-internal_nodes {
-  key: "BnQ="
-  value: "\370\035\244\231\225\342\250\311i\307\301\333V<Y\nm\263I\025[9{e\356\323\334\235%\374\313\033"
-}
-internal_nodes {
-  key: "Bng="
-  value: "\365\233p\223\031\365\334?\343\335.\200\3051\232\311\237+\211(\010\304\311\014\237\316\343DC\362\303\263"
-}
-internal_nodes {
-  key: "Bnw="
-  value: "\022\211\303\345\317J\315\036XU\345\005\016\234\036T\255u\324\255\347k+\025\240C\336\010\t\177\227x"
-}
-internal_nodes {
-  key: "BoA="
-  value: "Jn\213\344\366B\017\360\3622f\363W\221D\202)\333\327[\315\256\333\304\204\362G\262?N\\\232"
-}
-internal_nodes {
-  key: "BoQ="
-  value: "mb\022y\315\272\247\020\336\312\320\t\347\352X]M\232\307\331\250\014\301\220\020 (\03698\264~"
-}
-internal_nodes {
-  key: "Bog="
-  value: "\326YUeX\230\355\316nJ\265\374\375\032(F\265\357\014\356\232Qu\333N\312\r\372\357\2724\333"
-}
-internal_nodes {
-  key: "Bow="
-  value: "\007\325FK\257\303\264(\014\004\372\375\200W\331e\277gW\"4\322\364g\263\341\\\201\267\361\247\240"
-}
-internal_nodes {
-  key: "BpA="
-  value: "ci\371\316l\323\316b\023\013iaX|k\200\0303{\260RI\000w\334/\362\262\262\200\324\375"
-}
-internal_nodes {
-  key: "BpQ="
-  value: "UTh\272:\"\372\201`\223[[\222\366\312\003\365\275\224\321:\210\322\227L\344\337\016\"\231\233\365"
-}
-internal_nodes {
-  key: "Bpg="
-  value: "\260i\325V\356ySb\255\211\355\305]\364E;q\023\260_\220\311\325g/\377\270.\203Kh\253"
-}
-internal_nodes {
-  key: "Bpw="
-  value: "\004\263Q\243\241\323uh\217\036i\204\t\203\274fWX\371|\3406\304-h\336X\013\363\327\255\230"
-}
-internal_nodes {
-  key: "BqA="
-  value: "\007\244g\273i\022}\037\363R\270cZ;{\234u\2421\025\237\026\323\257\265\r\270d\341\371m\367"
-}
-internal_nodes {
-  key: "BqQ="
-  value: "\256{\023\242\336\353\325m\033\274/\234\r\215B\266i.\334k\223n\376\270\001Sw$4D\325!"
-}
-internal_nodes {
-  key: "Bqg="
-  value: "\217l\246\316\216\225\352\333\300Z\275\264\336\027A\211@\377\252H\210 \226\203/\353\271\001\233\036O\233"
-}
-internal_nodes {
-  key: "Bqw="
-  value: "\356\310\302\361\211\271RnT\267\303\351\003\332P\035\263\265p\002jn\372\013\205\244\277\302\267_\002B"
-}
-internal_nodes {
-  key: "BrA="
-  value: "\305\037\317\236\335C\372\220\035\241\032\2277\374\320\005b&\212\205j\363\247\\\031\346\331;\357Ur\307"
-}
-internal_nodes {
-  key: "BrQ="
-  value: "\350\256p\210\250\326\3078\007\255\271\364\215/9\361\n\207\347\241\241\323\235\240\360\357i\333\275\361\005\237"
-}
-internal_nodes {
-  key: "Brg="
-  value: "\331\033!\n\027\"\247)\353\310\000\332\027\031\374\270\276\350\024\356\261\341\341\021zS\357\337\340?\204\371"
-}
-internal_nodes {
-  key: "Brw="
-  value: "\307\005\243\357\255$\206\367\207\030g%\346\334\334\207or<\010C\322K\207\315M(\373&\371[\016"
-}
-internal_nodes {
-  key: "BsA="
-  value: "\342vu\277\202\001 g\360\301\013)\326\263\253O\230\241\010\014\325\216\230f\345Mn\207\001Q\274\232"
-}
-internal_nodes {
-  key: "BsQ="
-  value: "*\261\033\213\372\032\032\234\224:\225\315\226\246Yi\246\340\212@\257\362\374\252\377~1\'\327\254\006\201"
-}
-internal_nodes {
-  key: "Bsg="
-  value: "\201jS\014{&\027c}\314\322\2031=\357 2~\204\337\221\224\240y\224}\300\326\307\266\306o"
-}
-internal_nodes {
-  key: "Bsw="
-  value: "Ke\327]t\273\372\375=m\372\233o\006\006+f^\326\306\355\310E\366\364\337:\210\235\2008\336"
-}
-internal_nodes {
-  key: "BtA="
-  value: "\020\037\'\260\232\010\035\355\315~,\345\305\216\177\032\254X\177a\276\264[Z\271T\316\367T\0210\244"
-}
-internal_nodes {
-  key: "BtQ="
-  value: "C\226\267\236\312l.=\035>\304\202\340\237\3705\310F\004k\3322Y\204B\325\021[\\\021\306\266"
-}
-internal_nodes {
-  key: "Btg="
-  value: "\311f\002\210\315JP\355h\010\2648\223\256,\306L;R\262\\\002\010Y\002X_\344o\270as"
-}
-internal_nodes {
-  key: "Btw="
-  value: "\362A\376\361\224\327\250X\235\327\261\313\245\371\267\205\271\034\244\225H?%B,\347R-\343U\203\235"
-}
-internal_nodes {
-  key: "BuA="
-  value: "\315\021s\314\305\003\332\372\265%\254\203\353\326X\375tg{h\317\204\377\352A\262\261\265Q\205S{"
-}
-internal_nodes {
-  key: "BuQ="
-  value: "\030\214n\027\013\314r\207:\000\234\r\014mO\004\237\220\236^b2\003\217\216\006\335\337{\255\020F"
-}
-internal_nodes {
-  key: "Bug="
-  value: "}\017U\302\204\314?\246-\205\224\224CP\264\254A!\376PN\005\211u]\254\370\266\310<\203\255"
-}
-internal_nodes {
-  key: "Buw="
-  value: "\341\344\211\267K\372\327H\205~\302\372\200aX\260JK\217}\250^?\245#}-\2550\200\3504"
-}
-internal_nodes {
-  key: "BvA="
-  value: "\370L\274f\356\365\020\362[\\\\nW\003\350\342hQ\010*\276\325fj\236\252\221\2357\330\0368"
-}
-internal_nodes {
-  key: "BvQ="
-  value: "~\337\005\255\342\006T\363D-/\265B\004#>\033q\003\330\210S}\352\245\374cc\t\313\017\305"
-}
-internal_nodes {
-  key: "Bvg="
-  value: "\3071\237\037?\326pERu1\2300\005-\352\366\245[Iq\345\270\016\267E\023\272e\241\221\365"
-}
-internal_nodes {
-  key: "Bvw="
-  value: "\200/s\363\356\356\365\353\245\014z\032\022\342\304\340\262\204\373\263\213m\231\022\2601\003\315\365\220kI"
-}
-internal_nodes {
-  key: "Bw4="
-  value: "Dz\225\266\230\217\004x\004\255k\3321\"\222e\203\357\275$\244b[O\217\247B\016\333)\022\364"
-}
-internal_nodes {
-  key: "BwA="
-  value: "\007\036j\251(\336\226\374U\332XE\371g\255\223\362\034]\000\357\232\325&\020\343\357\364\224o2j"
-}
-internal_nodes {
-  key: "BwI="
-  value: "\002\021\275\324\\\364<\253\260\330H\"6\267\017w\355\262c\004D:\334\037\201\024\223\244\202FH\263"
-}
-internal_nodes {
-  key: "BwQ="
-  value: "H\210\325\0333\032.!\366y\342\214\243\252\353PQ\361x\201\217&\304bd\353\375\254f\177\330\313"
-}
-internal_nodes {
-  key: "BwY="
-  value: "w\225\0046\272\204\307\245{\227>\3554\035\002d\034\337\004SC\365\300\3302\2735\320\326\363\032+"
-}
-internal_nodes {
-  key: "Bwg="
-  value: "2XG\204\266R\200\321\020\252\325\2100\022&\305\227V\035\371\275\363\241#\257P\033\026)\216\211\375"
-}
-internal_nodes {
-  key: "Bwo="
-  value: "Y<\312=\214$\351*\267\276r\250u\024\242Em\323\227\240\267\003\324\014P\231\235Q\273\364+("
-}
-internal_nodes {
-  key: "Bww="
-  value: "\223\0349\205\006\013\265/\212\332~\261\2769Rs\370C\323\254\006\336\022\374\000j\250\202\277k+O"
-}
-internal_nodes {
-  key: "Bx4="
-  value: "\2756|\017\\\207\342.\377\024bN\245\372\200\315\320\244\234\006\302\360NBmv0\027\213F\376;"
-}
-internal_nodes {
-  key: "BxA="
-  value: "\317\231j$Y\353\322a(\335XW?*Uu\266\312!\315c\020\205\274\231\221\035xH\023\220\014"
-}
-internal_nodes {
-  key: "BxI="
-  value: "\200\001\252\362~V\003\001\010w\0061\030\221\250\213&<\023c\254\n~X\314i\311N\3349\024\312"
-}
-internal_nodes {
-  key: "BxQ="
-  value: "n\3142\366\215DF{\007\330\363\272sU\030\254\361\002\331<?\3344\022\255\323\336\007\003\316\205\354"
-}
-internal_nodes {
-  key: "BxY="
-  value: "q,8)[\341\261ev`\305y\315FT\331#%\034\305\310\302\371\353>\261\363\270 \312;\375"
-}
-internal_nodes {
-  key: "Bxg="
-  value: "\307?\353\372\234\3229\311\362\214\316\353B\231\007mx\263\210\2753~\023\321\2744|\313\001#\267\021"
-}
-internal_nodes {
-  key: "Bxo="
-  value: "U\347P\272\223\236\024\020\366|\"\257\276\223\321\323\010t\305\340\350W\267\231\000\315\276\374\364I9\212"
-}
-internal_nodes {
-  key: "Bxw="
-  value: "\264UY\036\376\2011Q\373\275\255\246\376T\240\237O\335\313\220S[M\\\340\031\246\372\234\373;\016"
-}
-internal_nodes {
-  key: "By4="
-  value: "\253@\323\205\306\262\351yRSCtpE\007\346\037\360\312\267\022\010\332\347\234~\t9\254yZ\305"
-}
-internal_nodes {
-  key: "ByA="
-  value: "\256\200\034S8\023\220mQv\024\365\333g\007\227H\260\262\367\210\353\002\2563\345pY=*\343\352"
-}
-internal_nodes {
-  key: "ByI="
-  value: "\322\234\n\320\206\345\317\321\2609%\277_St?\272\305\202\251\373(I;G \373\235\002\r\n\276"
-}
-internal_nodes {
-  key: "ByQ="
-  value: "\016\350\220\360\n\271q\001\245Q\rb\373\033\013\032i\360\205\226l\310\202,\306b@\000\3152\225Q"
-}
-internal_nodes {
-  key: "ByY="
-  value: "RK\216\210T\rK\225\210\225\241\000)V\356\0243\314\371\013\261k\346\235\243\360\272\304\235,\220\004"
-}
-internal_nodes {
-  key: "Byg="
-  value: "\272\256\027\334 \\\\\202\207M\261\216>\007\302s\361\306w\304\003\n\326\205I\321C\r\247M\177K"
-}
-internal_nodes {
-  key: "Byo="
-  value: "?Y\024\314\rK_\2540\310/\241J5\027\311\006\200D\004O\377\001\014\234\341\235\026\313O\210}"
-}
-internal_nodes {
-  key: "Byw="
-  value: "I\305O\331_#E\0229\263\247W\252\361\261P\314\224\263\335 Cc~\376\373\376m{\217\022\345"
-}
-internal_nodes {
-  key: "Bz4="
-  value: "\266[\266:\220z\203:\377\330\0130Kc\037\230%\377\r\004\017\323\260\1775;$\r\014M\021s"
-}
-internal_nodes {
-  key: "BzA="
-  value: "\3150/\267D\351\340Vb\030\375o\240\257\354~OVr\025\203\032\347\324\334\357\345S\276%\031\231"
-}
-internal_nodes {
-  key: "BzI="
-  value: "\213\317_\240\367\271\345\257&\207\373\211\033>\324\327L\267\242\3760\212\246\230)w\271{QW\322\222"
-}
-internal_nodes {
-  key: "BzQ="
-  value: "s\030\001$\302W\036\257T\335\3061r\304\350u\003\361\246\r\203<0D\263\"w\201D:/\267"
-}
-internal_nodes {
-  key: "BzY="
-  value: ",\325\256k(\265F\034\367\020\212>\3056\357DBc(\242\243\301\324C\257-\321\343\240\276\200\272"
-}
-internal_nodes {
-  key: "Bzg="
-  value: "\276\"`\360h\305Z\206\226\231:f\"\346w\322N%\214\314=\361\212\307\254\006n\005\256\204\032\252"
-}
-internal_nodes {
-  key: "Bzo="
-  value: "\r\364\321m\343_\0350\365e\257\211\332\362\022f\202.\370$\316\002\214\263\010\270\333\001\211i\267\344"
-}
-internal_nodes {
-  key: "Bzw="
-  value: "\305\360\362d\221\334\032\245\351e\351\237r\352c\361\364T1\310\007\016l\016@\020x\320\240\230GK"
-}
-leaves {
-  key: "CA0="
-  value: "+\261\016\316\271\007\235o\244e\255\253kV|\035\r<\347\010\371\000\374It~+8]\200~\275"
-}
-leaves {
-  key: "CA4="
-  value: "j\244)P\2741\240^f\332\'\264?\243x\264\350\'\357\232\316\321\334\354\325\253\2403\002[4\'"
-}
-leaves {
-  key: "CA8="
-  value: "\257\364\024dA$\224\263\236\361\002\255\223\362\003\274\336\265\325m\370\022AWx\030\030\223\216\317\275N"
-}
-leaves {
-  key: "CAA="
-  value: "\213E=}\274\324\223m\324\375\372\021b\320\266\237\257C\304\334\265\242\320u\270\226\020n\351\364\213N"
-}
-leaves {
-  key: "CAE="
-  value: "ed\245\304\352%\350\325\216Z\371\354\246fOi\312I\177\245iC\360\345\240Lyk\361\277\275\231"
-}
-leaves {
-  key: "CAI="
-  value: "f7\177\362\372Q\010P\235\001@\362\0259\010^]\375+\177\242!\342\360:\034 \366\252\360\331\020"
-}
-leaves {
-  key: "CAM="
-  value: "\270\300\266\363\rD\033\207\0101Lv\2317&\236\242\347N\037}\030L@-\346\304f\236\310Uw"
-}
-leaves {
-  key: "CAQ="
-  value: "\025\334\373\213\032\374_\350\360\220\257*m\227\226\232\024\232\226\3126YLuLg_\002\370,Vu"
-}
-leaves {
-  key: "CAU="
-  value: "}\257\327[\332\205\242\236Z\\\241G\240\016\312\005\300\2743\275pV5\225\232>\246\376\355!T\224"
-}
-leaves {
-  key: "CAY="
-  value: "p\353>\222\272\003\226H\243\261\013\260*\313\247l\034I\256v8}\362\360V*`\336u\236\021\026"
-}
-leaves {
-  key: "CAc="
-  value: "\315>Xo\013~\222\256\366\372!\214\371\r)B\3379\030\341\020\374\366\006hT\016\326\nf\305\302"
-}
-leaves {
-  key: "CAg="
-  value: "\250\326\334|\211(\212*\331\341\tn\246w\250.\177\237\005\322O\"\036W\2704p\257\301\207\353\236"
-}
-leaves {
-  key: "CAk="
-  value: "\365\366\276\301\336sSqb\035[WQ\233oW\311lo6\006c_\264\021\353\t\t\344\350sK"
-}
-leaves {
-  key: "CAo="
-  value: "%tD\215A\257b\004\253f\017|A\315\360\352F\004\030\002p\357\205>\034\337Zb\262\3265\212"
-}
-leaves {
-  key: "CAs="
-  value: "\210\366\017\262\'*6/\362\362\037\204\226(\332\303\307\3175\030U4v\3723\254%\356\241\377\264\004"
-}
-leaves {
-  key: "CAw="
-  value: "\2306\276\004\363\201\271`\240\362\372\266\221\222\177p0}\214\025\245\321d\257\016\311\252\311W\337\005\213"
-}
-leaves {
-  key: "CB0="
-  value: "|\233\246\260\016<@\346\253\363\206\247\'\351\313\004\360z}\227\306\313/V\305\260T\'\206\334\210c"
-}
-leaves {
-  key: "CB4="
-  value: "\326Uf\303\030\2212\265\013\331\203\'\265E\023\376\250s\270\242\312u\377\022iB\361QY\222\\\310"
-}
-leaves {
-  key: "CB8="
-  value: "\010\346I\345c<i\236>;\210\022|u\020-\357\'H\020\262\031\023Gm\244\263\235p\001\013Y"
-}
-leaves {
-  key: "CBA="
-  value: "\230\255\033\236D\351\322I\001(\257\364\302^\301\306\273\327?h\257\330J\307(6\226\301\204^=\211"
-}
-leaves {
-  key: "CBE="
-  value: "\001\020x\200\221.\233\347\225\214N\031A\030%t\"{\003+\240\2400)\253\372\313u\356;\271\031"
-}
-leaves {
-  key: "CBI="
-  value: "\177\211za\211\265S\t\007\224\342\210\r\303Y\311\252;\333\336\207\345_5~\350E\314o\374\233S"
-}
-leaves {
-  key: "CBM="
-  value: "k\014\252D\345\033\216\261U\024\3013\3175\006j\230\342\020\316\'m\366\036\004\206\007\276\030\305A\331"
-}
-leaves {
-  key: "CBQ="
-  value: "^\247\332\364\3063\237\377\304<?\021o\021\356\247M\273\',]\336\336@\355\307\274\034\220\224\022K"
-}
-leaves {
-  key: "CBU="
-  value: "\237l6\"a\227I\331$j\244\347\375\364\247\221\003\021\006\277`\365>%\346\242E_\261x\317\016"
-}
-leaves {
-  key: "CBY="
-  value: "&\027]\241)\017\303\316W9p\235y\360\263l\207{\032d_\317{\004\241\363\310Y\013\3419\361"
-}
-leaves {
-  key: "CBc="
-  value: "CF\262\272$n\372\236D\010\262\371>\240[\253e\356\341d_\272\037\310L\017\356\214an\333\202"
-}
-leaves {
-  key: "CBg="
-  value: "\251\251yB\030\356\324\276\365\207\310\207\004\230s\304\220\315\256\311\324\274h\355\305\004\377\372cK\360t"
-}
-leaves {
-  key: "CBk="
-  value: "\270\233\236\3226\010%\220\352\222\243\307}\216&luU\323\265\262o\033+Q=\356k\216wVM"
-}
-leaves {
-  key: "CBo="
-  value: "~L\rR\334K\332\225\0247n\337\323\213\246}\030C\346?e\371\361\314\036\361\204\271n\023\034\240"
-}
-leaves {
-  key: "CBs="
-  value: "j\212gU\234\244\305~\322\276\023\223\363\315#n\244\027qA\246\300\330l\254\312\371\207\243\2246\364"
-}
-leaves {
-  key: "CBw="
-  value: "u\002\341Cb<\326*RWC\244\246\377\235A\242\3565\0220J=\252\0208\323\037{\014\360\262"
-}
-leaves {
-  key: "CC0="
-  value: "\226\256P3*\255\241\017\306\316\216%Tp\203>\357\200\223\257\017\274\210\252\374\330\322lO\302My"
-}
-leaves {
-  key: "CC4="
-  value: "2gc<\317\343\352\345[\304\237\320\277\031c)\207\224\306b\346\355\'\216\006\235\362)k\212\307\200"
-}
-leaves {
-  key: "CC8="
-  value: "C\203\034\343\350|\277\220\240\273\031\302\240\360\005^L\350\320\276\026\333\025\353N\300\211\245\261\301MH"
-}
-leaves {
-  key: "CCA="
-  value: "\025\237\367\231mo\027\246\016\321\231\241]R\006u\211\363\323\300\014en\352}W5\320\223\301\375I"
-}
-leaves {
-  key: "CCE="
-  value: "\272\202\373\370*\000M\2748\375\255x\330V\'\225\265\214\247\272\321BW\006\020\037mAj\177i\323"
-}
-leaves {
-  key: "CCI="
-  value: "c_\314\006\344\301\247\245%\021\252i\035S\306v\230v%\237\261\233Q]\030C\223yhHX\244"
-}
-leaves {
-  key: "CCM="
-  value: "\261\222\366\343\004\233:0c\"\007\372\227\343\342\2051\225\277F\241\021V\2518b\013\314\314\361\336\274"
-}
-leaves {
-  key: "CCQ="
-  value: "\013uJ\221\243\245\211y\256\035s4\316\271\022\272V\027\350\344Z4o*Xw\263\375\334\220\221\013"
-}
-leaves {
-  key: "CCU="
-  value: "L\272\021,S\274\367JT\202\233!\017*\334\203\030C\r\222[cCa\303`+E\256\242\004\036"
-}
-leaves {
-  key: "CCY="
-  value: "&\222\336\316\322;\001;\346.E3\014\313)\234*\377\250T\001T\374(\nA\337>(p^\366"
-}
-leaves {
-  key: "CCc="
-  value: "\236\004\337N\r\216JG\343\355\270\260\304\007\tG9S\217\006\206\301\037\206\351X\305_\320_)\367"
-}
-leaves {
-  key: "CCg="
-  value: "1\027]8\246\001!\332\251\240.\n[\224\352\323\312\025#\2625 \261/\337t\214?\026XF\022"
-}
-leaves {
-  key: "CCk="
-  value: "u\234\\\232\374\320\033\273h\002%\331\035\010\007\230\010hN0.\214\010K\021o\352\337\274b\303\236"
-}
-leaves {
-  key: "CCo="
-  value: "\264\362\270!!c2Y\332~\037\"i\353\023n\307\007jl\320\026\031\303\317##tZ\312\000\001"
-}
-leaves {
-  key: "CCs="
-  value: "T\370\207\305\031\026$\223\225\304\003\272\025;q|[O\362\236\341&\005\377\303C~\340\336u\335\315"
-}
-leaves {
-  key: "CCw="
-  value: "1\020\3573t/\225e\206%<\351\037\'\255\373\216\260S\345\271\254\013e\241\367\014a\306\326\345\356"
-}
-leaves {
-  key: "CD0="
-  value: "B\005\245\007/\277~\n\363\024\345\274\377\027\t\256dO\351\256\271\3035\315s\350\341\001\321=Y\370"
-}
-leaves {
-  key: "CD4="
-  value: "\016\304B%\374\242T\241\275\3145x\203\365W{\0312\025\342\343\333\301\3505\t\205\215D\235\362\203"
-}
-leaves {
-  key: "CD8="
-  value: "38\274\327\263\221\t\352P\216\261G\237^[RP\265|\017\251\013^\3644\355\305c\254BI\364"
-}
-leaves {
-  key: "CDA="
-  value: "\254\2311\277H\227\337\r\230\340s\001\370\305o\200\306\351\025#\321\226{@>\034\351\003\375\303C\227"
-}
-leaves {
-  key: "CDE="
-  value: "\217\370\334\304X\305k\240B&\311\306\244\323\262\"\226\311\003\277S/\\\333\276n\214l+\004@\262"
-}
-leaves {
-  key: "CDI="
-  value: "N\256\007\002rN\030\007\257\250P\024\000\003\245\032F\350l[{+$*\317C \337\030&\017."
-}
-leaves {
-  key: "CDM="
-  value: "\252\251b]\357?\370P\206\241n\301v\375\224\266[\230\370\272\230\325\277\030U\317D\273\353<\200\254"
-}
-leaves {
-  key: "CDU="
-  value: "\224v\325Ix\313Ig\215\201!\374\177x\273\3749\000(\211\315\3210q\261i\0205\340)\216\030"
-}
-leaves {
-  key: "CDY="
-  value: "\231<E\230\nv\261G@\340\n\213\315\344\336D\210\354\245\tv!\237`\317\235O\027\n\034l\355"
-}
-leaves {
-  key: "CDc="
-  value: "\203e3\000\227\025\016\220\237\243\310\331\365\026\t\344[){Y\354\366\206\225\026\231\272L\242E\215_"
-}
-leaves {
-  key: "CDg="
-  value: "\363y\347!\220\375\350\206\313\340\314\000=\3361Q\t\371\017\0030\267L\350\250\304\360\023\244\311\253 "
-}
-leaves {
-  key: "CDk="
-  value: "\273\220\212\242\236\277\263\316\"\245\223`-\261\320\001\364sG\300c\267\022xnm\206{\372Z\262$"
-}
-leaves {
-  key: "CDo="
-  value: "\272!|\311\375\241h\217\252\327\216G\001\240\232D\200\324\324\345\274\033s\241\304\340\"\215?\314\300\253"
-}
-leaves {
-  key: "CDs="
-  value: "V|I\373\313\\,^\200R\010\223\354d\332\022\270W\351\275\010=\237\253\355\031\317\016\205L\253K"
-}
-leaves {
-  key: "CDw="
-  value: "&\226y\230\224\021\210l\255S\336\336\"o\206\274\351\005\341\355_\033+\212\361O\366\"\347\301\324\212"
-}
-leaves {
-  key: "CE0="
-  value: "\210a\033vnR\225\300\2462\247{\243\227\372\203\223\247\224$\274P\241d\307\204\263#\264\250\2350"
-}
-leaves {
-  key: "CE4="
-  value: "a\007-\030\350\r0\327\306\351H\330\242\273\326S\372\237\266\321.\310\025axk\267\346\312eJ\025"
-}
-leaves {
-  key: "CE8="
-  value: "\276\310\31757\210\366\230\013\277B\033\316\340|\035\030\346\363\371x\246\014\007W\377\204^q\276\342\326"
-}
-leaves {
-  key: "CEA="
-  value: "\201,\035\242m\2574\202PH\245\337\2179\313WT\302\006\273\364\374C\357\334\325;\323\002k\251\274"
-}
-leaves {
-  key: "CEE="
-  value: "\216u\217\273\363b\277o\226w\202\262\204\202\372\341\037\240\032,e\320\320 \352\366\221\3744\021\272\360"
-}
-leaves {
-  key: "CEI="
-  value: "\373Y7\364\017 \247\372\311\177\231\345\364\273\357\351\275\r7\357i\026\240\274@\272\244\226,^;\031"
-}
-leaves {
-  key: "CEM="
-  value: "\263a\377\3772E-Vh\227\207\257\314\344\372\377\024\306-\203\3620\320\215YPj\265a\246\204\216"
-}
-leaves {
-  key: "CEQ="
-  value: "4>:9\277\265\262q\017|\217\001\361\342o\267F\317\003\3736+\346\246j\360\034\237\350\005y\261"
-}
-leaves {
-  key: "CEU="
-  value: "\367\220K\251\305,\223a\324\257\274\374\006JY\231}7\247\330\330Y\244\214\343dF_\323\005\241\024"
-}
-leaves {
-  key: "CEY="
-  value: "\210]\377\206p\357T\264\031y\3623bD\343\330\206\242\270\027\033X\255\275k\356-?\246\342\032\310"
-}
-leaves {
-  key: "CEc="
-  value: "\313K7_\222\325\"\246\277\243\227\210\n\357V?\322\341Z\263\\Tp7m\316\310J\276T\001 "
-}
-leaves {
-  key: "CEg="
-  value: "|\251\240\357\025;=Q\200\235)\332\264\221\300\356-\370\327a\252\024P#C\214&\217M/\215\027"
-}
-leaves {
-  key: "CEo="
-  value: "\310^\031\216\204\372\304\277\323Z\233\270\234\304{\267\336\227\234\177\317\227\203\241v\342[f?\356\264\247"
-}
-leaves {
-  key: "CEs="
-  value: "U{\\\347\337\334\367\216\226mz\352\311MB\026\243\\\217bB,\034tt;Ou\255\0312%"
-}
-leaves {
-  key: "CEw="
-  value: "H?k\000\002.0^\201{\302C\0204t\252\266S*4Y\300\3572\211;\337v)T7\362"
-}
-leaves {
-  key: "CF0="
-  value: "\253\t\346\313=&Q\307\266w\3704[\215i\203\037\237\256\007\346\025B\\6\273\205\251\035g\241\364"
-}
-leaves {
-  key: "CF4="
-  value: "U\021\200\00435\021dX\n\271\004\266\3628\201\307\235.\010eX\235\306)\355\325\014@%X\017"
-}
-leaves {
-  key: "CF8="
-  value: "\263\321b\215\245\213\344H\226\3700\354S\025\207\223<\253h\277\254\350\014\271\332\033\215`\355D\020\310"
-}
-leaves {
-  key: "CFE="
-  value: "n\331\317\261H\362\360\220\215OC\276\001\316\277\370\270\270\357A3(X\263\367Nx\272\276X\272\274"
-}
-leaves {
-  key: "CFI="
-  value: ">{@\370W\377\300x\273\021\220\240\271x4\227\264\0022\360\227\006\204\3447)\317\034M*\316j"
-}
-leaves {
-  key: "CFM="
-  value: "\030\344;\177\017f\000Wb\221\324\376\3266\341\237y\264V\025x\354(fR<\365BW\320\343!"
-}
-leaves {
-  key: "CFQ="
-  value: "a\331\213\224\236}+\020J\220\027\330\206\"\224\270\315$7\351\271\266\251\3061,\315\346\211\027k\234"
-}
-leaves {
-  key: "CFU="
-  value: "\230\263\034L\'k\273$>\223k\324\2124\247\001\373\363\347\314\305\254\271\371\230\223\242\251\213\356h%"
-}
-leaves {
-  key: "CFY="
-  value: "\036\006\333?-\224\256E\336B\201\353\237c\306I\312\212\270b^fX(/\342\263\345\246\237l\270"
-}
-leaves {
-  key: "CFc="
-  value: "\271~\226R\350%T \201] w&e\332\207/+\'\336\277\027\377\302\344x\303\271d\356\340\205"
-}
-leaves {
-  key: "CFg="
-  value: "]P\257\326*\002\250\0056\307\3446\232\332\331\276\302\213\272\210<\235\231\321\344\336\317lX\274b\020"
-}
-leaves {
-  key: "CFk="
-  value: "y\333Z%\022\257\370\033w6\227\254w\354\250\035\366\314#W\001\346U:!\023\005|)*\333\253"
-}
-leaves {
-  key: "CFo="
-  value: "\025<\324\031n\034\266\223\330\351\360\312\225\031\307\226\222\355mk]\327\276=\247\201\246d\324\333l\313"
-}
-leaves {
-  key: "CFs="
-  value: "\370V3\252k\217\343-&a\026zU\275\265%[E}\210\0355-\343\370\027\261\035\241\'\246\311"
-}
-leaves {
-  key: "CFw="
-  value: "\274\243x\341L%\333\020upg\315\222?\335\274\231\231\2058\n\372\321\t\366\277\013\307W\234\330^"
-}
-leaves {
-  key: "CG0="
-  value: "l\210\326\206,|\317\247\024\271\260\364\210\022\307\233[\331\205\254\256\034\307\327\304\361\272\247\356\244n\003"
-}
-leaves {
-  key: "CG4="
-  value: "\346C\245\022\016\221\352\274\275hk\205h\216\037K\353\233<\330\221\365\265\260\260N\\\0228\265\242&"
-}
-leaves {
-  key: "CG8="
-  value: "\222C\215$\232K\245\007G\373\030\242\036}:\253oq\354\316_\212}\376\'\205B\371a\272~g"
-}
-leaves {
-  key: "CGA="
-  value: "\267^\334#\272\243\365\031w\216L\014 T^\'s\241\201\325\353\305[#z\324\027\271\321\235Q\263"
-}
-leaves {
-  key: "CGE="
-  value: "7\305+\356|\243_\300\370\266\352?J\007\341\243\272s\240]\2200v\030\032\321&\177\262V\214\336"
-}
-leaves {
-  key: "CGI="
-  value: "\022\022\352\365\035\032\367\032$\236\322\342pu:\236\001\263)\316x\277\220\212\200\324\027yT\016\342\321"
-}
-leaves {
-  key: "CGM="
-  value: "MzG6\300\260\"\326>^\2161Y]^\330\250^\364\026MK\030\276\n{\206s)Tk\317"
-}
-leaves {
-  key: "CGQ="
-  value: "\346\241j\255\227\305\203\321\214\377\316vK\332\017\323\255\306=a|P\006,PD\205d0\313\340\355"
-}
-leaves {
-  key: "CGU="
-  value: "\323\332+\335\233\361,\247\315\220\242\010\211\375\036\216\"h\031\235s\037 <v\346\266\220\006\217\363i"
-}
-leaves {
-  key: "CGY="
-  value: "\336\201\211\311\001K\215\327N\"\\\023$\023\316\360Q#\035/\204\001\036{\344\250\n\024\331\236\250u"
-}
-leaves {
-  key: "CGc="
-  value: "AJ\237<\232\311\213\272?\323Y\317DU\353\276\352x\377\345oZ\327\036\315\3222\353\237z\2067"
-}
-leaves {
-  key: "CGg="
-  value: "L\210\222kU\265~\245\315\253\321\314\031\260\212P\226\023pt\366M\375\222\3009\347)\335\365\004\260"
-}
-leaves {
-  key: "CGk="
-  value: "\343\333\357Fz\200l8\003]\254\247\177M\367W\366\263\026 .\334\305S\003\277\212;+wC\217"
-}
-leaves {
-  key: "CGo="
-  value: "\203\030\020J\007\377@E\'\323\270\363\346\331 \345\374{PWAv\347\253S\216\250\244\243\362\037\301"
-}
-leaves {
-  key: "CGs="
-  value: "Y\340\320\026;G\006{\262(\207\207\"\246\336)\231\203\323\316\344\253\321\242@.\231\341\032@NH"
-}
-leaves {
-  key: "CGw="
-  value: "\343\005\334Q\307\310\242\334-u#\004\307\013\025\362R\315|\347x\262I\334U@l\305\205Jo\027"
-}
-leaves {
-  key: "CH0="
-  value: "\270\020f ~E\334\177\365\340\354\222b\353\265\302H\315Z@\260Y\311\337\tV8h\"~]\'"
-}
-leaves {
-  key: "CH4="
-  value: "R,\247\241T\024m@\271A,\274\223o!+\211\320)\032\344\317\312\342M4X\343W\357%\004"
-}
-leaves {
-  key: "CH8="
-  value: "\225\035\314\227R\324\332\321\333\340&A\342S\025^\"$e\326O\260\365bn\365\003\003\312\025\276\001"
-}
-leaves {
-  key: "CHA="
-  value: "\252jj\364 \327\367\233\013q\274J\334\243\030Z\001\0006\024o\260\365\275LX\204\241\033\027\231\203"
-}
-leaves {
-  key: "CHE="
-  value: "&\362)\302\006\253\251\"\235\233\031\261q\007<\020\200\212\371>\242i\272\366\312/A\321\236\202\2651"
-}
-leaves {
-  key: "CHI="
-  value: "\372\324>\020\021\313B\310\272\256\225h\027\330~\216\254\242\277\221\233\202\3124+\367\267\202\325O2\375"
-}
-leaves {
-  key: "CHM="
-  value: "bp\202\275HkD%C5\254a\347\236\216\373\316\257\220\354/\210<s\255\325%\361\010\354\332\334"
-}
-leaves {
-  key: "CHQ="
-  value: "\224c\300\233\220:S\377.\031\260\311S\372O\217\332\004y\031\025&\353\036\315\306\3746\234\363\220}"
-}
-leaves {
-  key: "CHU="
-  value: "\030\032I\223\202\023\364\362\"\004\346\357\256w\347\257\344D\334\353\254)\302\350\200\251\211\274\217(\223\\"
-}
-leaves {
-  key: "CHY="
-  value: "I\352\346\003\000pu\205E\'?Z\033\207\307\235(\301\252<,\254U\036\027\227\251v\001\"\223\002"
-}
-leaves {
-  key: "CHc="
-  value: "\332\330\277\204:\3670\200`/N\224\262\312&\340\304F\001\313\3144\004\341\256\355>\373\312m\237\327"
-}
-leaves {
-  key: "CHg="
-  value: "\236\033fPZ]3\007\371\367\325\237\003z~\334\321\221\240w\301\210\345\366\304\255\207\022-\254b8"
-}
-leaves {
-  key: "CHk="
-  value: "\344\013K\374\2541\322{\353\343U\325g\261\031\215P\020\026\210a\333\247\215\202 \373\272I\3665e"
-}
-leaves {
-  key: "CHo="
-  value: "\253\346\353\260\313\242\263\325\005\331LU?\227j\323\347\252K\333\257\010\2030%A\217Y\305l\350\225"
-}
-leaves {
-  key: "CHs="
-  value: "\222\314\340\250p\336J\035T\035\244\'\3702@V0Dl\275M\013\335*\373\271o\026e)\000G"
-}
-leaves {
-  key: "CHw="
-  value: "s\227\274\320\257m\327\240D\245\304p\207\207\371;\322\"\022;BD\210k\364\n\277*\306\352\377\342"
-}
-leaves {
-  key: "CI0="
-  value: "\203h\322\273\031\323r\372?\244zP\327]G}\367\353\036\216\203\016&\301q\014\341\220\260\004j\363"
-}
-leaves {
-  key: "CI4="
-  value: "\332\317\343\366\276\364\026\337*\207\304\326t2\220\234\314\311\362\214\206wu\344\n\311\336\367\0029\343S"
-}
-leaves {
-  key: "CI8="
-  value: "\226`FC/\220\240\302\007\271S?\334\021\250\220\355K\031\244\246\253\027\204\346+\324\036\027\036N\022"
-}
-leaves {
-  key: "CIA="
-  value: "\332\254:\224\350E\033G\256\246\261\351aOO\245g\230\360\2238\357\224]?*\017\034N3w\203"
-}
-leaves {
-  key: "CIE="
-  value: "\312\364,\032\205{\rY\311\351\253M\0136\3420\034\356\335\207pt\342~\031@\301:\330\017R\260"
-}
-leaves {
-  key: "CII="
-  value: "\035N]m\037\200\026\333\321\303\206\223a\310!\3216\272\270\276\352\034\353\264\363\303\311\220\314fb3"
-}
-leaves {
-  key: "CIM="
-  value: "H\177-\025+\215\323\350\332\206\021{\330\203\240\027\\\014\213\250\321\245\t\370\037\320T\250\321\007\206\313"
-}
-leaves {
-  key: "CIQ="
-  value: "\236H\254\035*\203\261\332\360\237\315L\336\240Sg\31122O\216\241\255E\007\344\023\276\266Y\353\177"
-}
-leaves {
-  key: "CIU="
-  value: "\370\"\010\253s\3208}\376\010?\203\224./\260\212+\325V\323.\202\3254\366C\021\277\223\204\367"
-}
-leaves {
-  key: "CIY="
-  value: "\223\344\225\356\322\255\3531\352\215bN\241\263\306G\350\213\350:\256\253\203I\334/Y\320\3133\006\221"
-}
-leaves {
-  key: "CIc="
-  value: "\010\007\177\225\330_j8\351\214\343$1J\226\3610C\266\343\233n\003\010\325\373\217\250\361\262h\000"
-}
-leaves {
-  key: "CIg="
-  value: "\304\366C\332Hy\3348\210\362\362\254\255J\204\036\272\031\014\347\026\000v\334\312\022\367\342X\374>\200"
-}
-leaves {
-  key: "CIk="
-  value: "Y\227t\203\360a\226\032h5wVfv\244\244\260\263=3\226\301lq\023\340\001\227p~\370{"
-}
-leaves {
-  key: "CIo="
-  value: "\021\252K\222\320\201\206\306\305\301\203\305\211\276\004{\322\010\211\214\211\223\355\342I\362\241\240\331\354M\203"
-}
-leaves {
-  key: "CIs="
-  value: "\t)\260\023BE}\312P\225bB\200\205&\331\270\225\253\351\275i\311\316\304\021\326\370S\247$X"
-}
-leaves {
-  key: "CIw="
-  value: ">\317O\026)\272\304\276\2668\027l\n\220\2709V\245S\343@U\002\023X\270c\213\326\267\234H"
-}
-leaves {
-  key: "CJ0="
-  value: "\374\354\267\360\225V\226 \321\3713W\253US\021\263\322G\306D\026\367\261K\317W\017\3247!l"
-}
-leaves {
-  key: "CJ4="
-  value: "\330\317=\244\262\024\276\202<\222\305e\322\036Z\371\013\\9:<78?U\001V\372}\241\027\327"
-}
-leaves {
-  key: "CJ8="
-  value: "\314x\334\237C\354\337\220\032\225\003\026\020\005\020\016\017\033\216h\003\013\222m[r\024sQ\361\242\013"
-}
-leaves {
-  key: "CJA="
-  value: "\322\2418\\h\247\254\270@\037\373\213\023\341\332\333r\277D\310\253\221\374T8b&ZQ\272\362f"
-}
-leaves {
-  key: "CJE="
-  value: "\231 \370\317\322\3507\214\375\024\227\262\370K\260@\277-\3570/\325\251P\274m\320\271\205\214\352H"
-}
-leaves {
-  key: "CJI="
-  value: "\310\213\263c\271\'\303\234\332\326\277v8<\tA\205\303\373\215O=\325\014g\321\n&\260\025\376s"
-}
-leaves {
-  key: "CJM="
-  value: "\261\034\223\300\313S\213\002U\271\350#G$o=\\I\233W\207\267\305\242z\262\004\344\025\201^T"
-}
-leaves {
-  key: "CJQ="
-  value: "\236\351##\370\365q\220\371\035\360M\261\206/.\205\321A\351\\\221\277/\235\334\037\333\025\301R\311"
-}
-leaves {
-  key: "CJU="
-  value: "b\314\017cj\311\2114\267\006\243\361\022+\253\370\\\214a|\323\226\373\255\270\025\204\334\033\362\310\213"
-}
-leaves {
-  key: "CJY="
-  value: "<\236az2\267\227\277\200/I<\310\0348\375\343\227\326\341R\232!\005c}\365\021\321\0006\350"
-}
-leaves {
-  key: "CJg="
-  value: "\271\007z\030\220B*\001\201\273\321k=\272V\327\023,\236\004z.\246M\327\204\240\005^q\007\216"
-}
-leaves {
-  key: "CJk="
-  value: "Q\342\316\235\254\035G\346\354\014\345\377T j\365\202b\356g!\323\375|\365\031\010\335\014x\236\336"
-}
-leaves {
-  key: "CJo="
-  value: "uY=`\247\330\352\233\035\272\260\035\r7\313\n\255\353\226`\274js>\022\003dd4\335Iv"
-}
-leaves {
-  key: "CJs="
-  value: "c\323\200\362\255.\020}D}hm=\031\236\264\002\023\266\027B\374\265\227\261\306\263\2177\343\327Z"
-}
-leaves {
-  key: "CJw="
-  value: "x\031W\252\371\354\306qzX \341\202q\037\324Sr\266\254\230\232\362\270\376\217\232,\325\262\024="
-}
-leaves {
-  key: "CK0="
-  value: ">\365\343\267\361\345\301-P\210\331f\247\034=\375\306-U\312\372\250Lj\351\227q\235\232\372\234\376"
-}
-leaves {
-  key: "CK4="
-  value: ")\335\3350x\372\346\250\031\005\360\306e\261\266\243^\236\313\3616\"\230\177\336V\025\350M\252=\266"
-}
-leaves {
-  key: "CK8="
-  value: "\212\007\211\026\271\365\353\232\375L\352\331\357\3661\353\201\307FB|\243\266p\027i\366!\370U\276\250"
-}
-leaves {
-  key: "CKA="
-  value: "hT;\355\216\217\'\2078n\305lC\2349|\327\303\241W1\257\355$\306\007\3173\346\364\023\006"
-}
-leaves {
-  key: "CKE="
-  value: "\321$\022\254BC\272\274\325\034\364@\243\3740\022d@C\032\216\317\246;.\355\232\234\341\352\372q"
-}
-leaves {
-  key: "CKI="
-  value: "\313T\275\336~B\276\241\007\221c)<n]`\t\206U8\367g$g\215\361\356\'\351\351\257a"
-}
-leaves {
-  key: "CKM="
-  value: "!\376\276v\377\227co\036.\205j\025\004\247\"\330\333\314/D>\310\350\001\001\310\343\327o\376\365"
-}
-leaves {
-  key: "CKQ="
-  value: "V\350\253[\037v\3346r\033\352\274(^\230\236\020\231l\247\220\350{\200#\276i\345\026\327\'\306"
-}
-leaves {
-  key: "CKU="
-  value: "\340\200Z\225WO\004\221\353\304kD\352\353\301\267\276}\017\244\261NAd\035\250\357\326\375\222\337R"
-}
-leaves {
-  key: "CKY="
-  value: "9\335\367\244\026\2422\266\254e\357\267.M1\373\305\224\274\200b\227\331\016\"Xn\265\360y1\274"
-}
-leaves {
-  key: "CKc="
-  value: "l9.\217\202\243\246\n\253\003\r\341E\206\347\013VO;\032\373\351\301\260S\243\314\244\020\rma"
-}
-leaves {
-  key: "CKg="
-  value: "O\300\212;6\346\375\222\014\2011U\313\007\350\233Xk\"\250\002F\035\032\346ISq\202\237p\337"
-}
-leaves {
-  key: "CKk="
-  value: ",\324\204\366\032X\2639%,\306\233\375\314\324\201\375\264\250\267\303,\2747\243\336yJ]e\251\255"
-}
-leaves {
-  key: "CKo="
-  value: "\210h\260\354\177\316\'/\364\234O\003\272\352+\336\366X\024\304+\203\003\354\020\327\0062\276\371\230\232"
-}
-leaves {
-  key: "CKs="
-  value: "\350\326G\341e\371O\312\333\036R\374\201\025#\237\206ka\371\372\370\211\270v3\345l\334H\206\001"
-}
-leaves {
-  key: "CKw="
-  value: "\267\253\030wq!\3170\033\'s\356\014t0\377\243\rn\247f\271\321N\004\225C7o\235o\004"
-}
-leaves {
-  key: "CL0="
-  value: "\360\346\022~\274\211\347E\202Y\304\347C,4\225O\022\037\034\226]o\205\3330pU\230`< "
-}
-leaves {
-  key: "CL4="
-  value: "\256.\000\275O\202\252\255\215\025\361\334\236\377\225\003B\376\361\313\307\320\177\275\017\342\321S\3362\302\020"
-}
-leaves {
-  key: "CL8="
-  value: "C\342\217D\216\016*\\\256\312&.\334r\365\036\344\254\257\335\306\275\355USZ\310\032D\202\031\240"
-}
-leaves {
-  key: "CLA="
-  value: "~ z#_c4\315\004z\264\234\224\352\353\226\014\tE\240\217\237\2633?\312\233\014JV1\303"
-}
-leaves {
-  key: "CLE="
-  value: "\321\215wg\366\315\230\\\3019\203^\237\206\266\332S\250N\334\010\361\364\251!\337w\177\006\217\020,"
-}
-leaves {
-  key: "CLI="
-  value: "\031\343\2246\207\342-\303\333\032\232\3476\277A\321PWC\305\367\016\202\344x\341\213-\\\331R\357"
-}
-leaves {
-  key: "CLM="
-  value: "P\203\346\002\226\235S\256\273!\177\262\235\353\025s.\336Z\030/\301 \027\265t:0\263B\r\377"
-}
-leaves {
-  key: "CLQ="
-  value: "\350Wb\006\244\177\035\017#\347\2467\330Z\275/oD\017ZR\010w_\346\320B1\276\276+\260"
-}
-leaves {
-  key: "CLU="
-  value: "\256\304T\235\321\372\313\023D\203\"\203/\353\250\020(\315\026\367\017\325\221%R1*\265\336\017G\201"
-}
-leaves {
-  key: "CLY="
-  value: "7$\255\277\344V\277\212\304t\310\031\234\026\257lbW\301\361[2\025\202\230\314\362\352G_\t\303"
-}
-leaves {
-  key: "CLc="
-  value: "\220\373\222\305\266\324\374\342\234\0018\024\234\213\272\013P\272\373\204D\242 4\277\357t}\240\007\351\r"
-}
-leaves {
-  key: "CLg="
-  value: "\375\177(f\227\353CL\037L\273A\177\035\260\255D\327=<\275\253{\033\'\314#\264\275<\020>"
-}
-leaves {
-  key: "CLk="
-  value: "s\332.\211\217\376\301\313Yt\016\325\214{\226Ve\237\036UI\351{\325\267}#\261\006\373\347}"
-}
-leaves {
-  key: "CLo="
-  value: "2\310\331\232?o\343\225u\003+\354K\322\223\352\243*\266\310\027\266t\210\213eQ\364\215\377\026R"
-}
-leaves {
-  key: "CLs="
-  value: "\340\237\366\n\347\314\3332{\207(\255;\232>\356D\r+\254r\'\200\037\244\216\330Q2\212\016\252"
-}
-leaves {
-  key: "CLw="
-  value: "\005\344M\264;\231,\227\021\207\312\307\343\203\347A\344\\J\032f\rBy\250;)\212n\346\210H"
-}
-leaves {
-  key: "CM0="
-  value: "\260\026\"\205\310\005\211\305\203\272\377\230,(N\347U\233;\351\030<\010\"\364\276\314.\242~\037\323"
-}
-leaves {
-  key: "CM4="
-  value: "=\360\220U>)w\352\231\305\365\221\311I]4]\274\017Par}L\317\262\254\327\266\371p\256"
-}
-leaves {
-  key: "CM8="
-  value: "v\357\344\325O,\230\362\016\256%\341p\010\353\336\371\3264\270o\237\204\277d\373/\3302\277\307\215"
-}
-leaves {
-  key: "CMA="
-  value: "\257\246\255\272}\245j\377j~\346\235;F\367e\243 \357\035\243b\206:X\202\357\270)\020\367\265"
-}
-leaves {
-  key: "CME="
-  value: "w4\337@S\001\254\275\020\236#\363C\345\324\026]\340\r\004\267qH\270\367\310\236\371\267W\217\374"
-}
-leaves {
-  key: "CMI="
-  value: "d\313\372\344\267f4\212\220\306,\266\320\342\027t\312\340\327\311\002\215\300@\020\2440\237\263\322\255$"
-}
-leaves {
-  key: "CMM="
-  value: "Cb\332\371\360.\217\tR\275\350{\0011\262\201\241\'\016\244Q\235T\270\371\373\013\3320\217#\253"
-}
-leaves {
-  key: "CMQ="
-  value: "A\266j\244~B\312Y\204*\201\276gk-;\355\256t\267\247\251\026g\245\256xK0\322\364\221"
-}
-leaves {
-  key: "CMU="
-  value: "nD>f\001Vlv\266\n\334\210\003\2167\212A\325\345\262\331\317j\332\201\203h\352\266\n\035\316"
-}
-leaves {
-  key: "CMY="
-  value: "\216\267\256Pc3\nq\002\275\233s\000P\231\037\221\024\255\243cu\340\360\002kc\267\307\206\356\352"
-}
-leaves {
-  key: "CMc="
-  value: "\265S\246\3174\345\345\257t\264D\214\315\245\344\350\\v\010\260K\355\017\202\274X\210\307\253\360\261P"
-}
-leaves {
-  key: "CMg="
-  value: "\331\201\216\031\277\312\315h,\005\253\272=\206_?fu\225\023g*\301a3.\336\363.\333\\\267"
-}
-leaves {
-  key: "CMk="
-  value: "\027\231*|\001\370\007\300^\\\253R\264\240\217\361\321f(\316\241y[s\236\036\263v\310\261\316\027"
-}
-leaves {
-  key: "CMo="
-  value: "zke\226\021\354v#Uw\014\270\303O\321V\2472\024\303tF\345{\303L>\023\221\255\203\024"
-}
-leaves {
-  key: "CMs="
-  value: "\r\232\037J\254\273\016\254\334\000\t\357\342\233\205\222\307\350\206z2\200\356EwXs\261\301\324\225/"
-}
-leaves {
-  key: "CMw="
-  value: "\377\374\367\037M]\210P\230\371\357\326\250,&\352\232P%\212\205\2250\2450}\304\340\316\274KR"
-}
-leaves {
-  key: "CN0="
-  value: "%jB\301o\315\020\277\252\272\261\010\261o\004\010\265EzK\352\216\010\250\n6\030s\033;\017\346"
-}
-leaves {
-  key: "CN4="
-  value: "\026W\245\243\240\254@\325\374\304\370\\mk\363\363N\023\024Q6N8\245\346\022\030\236\312\226\251\252"
-}
-leaves {
-  key: "CN8="
-  value: "\3346f\321o\247\265\222\334\002\006\246vY0%\272n=!\010m\350w\322\303f\2622\261\300\313"
-}
-leaves {
-  key: "CNA="
-  value: "\354\240\274@8\224\263\365o;\241\202\263\260\007\304\271\335tMMD0j\016C\264e\364@v\304"
-}
-leaves {
-  key: "CNE="
-  value: "\204|4\021\003\307\240\273\214\006\273\376?\217\255i\346\334`\377\347+\020^\266I\325Y\001\010\264\016"
-}
-leaves {
-  key: "CNI="
-  value: "|_\2646\301\221i=Z\327\223\316zO}Ts\256\365\346\343\030\274~\322\332Z\375\306\216\316G"
-}
-leaves {
-  key: "CNM="
-  value: "\223RU\000\312\223\341\004p\032!\363L\215N\302\320\211\243\334\255\301NN\327\344\241\240\314\375\232\252"
-}
-leaves {
-  key: "CNQ="
-  value: "\317\3710K\022\204%\347\263\242t\372\222H\350\233\372\211oa;\361\203\256~\331\251>\367[\233\222"
-}
-leaves {
-  key: "CNU="
-  value: "\214\253i\305\240\230o^\222\373\031AV\246\213Y\363I\366\375M\n(o8\215\274\201\346\237\276\222"
-}
-leaves {
-  key: "CNY="
-  value: "\343\377\"\210.\201\247\270X\256\177\2170\2605\'\370\346hL\255\331\037\240\013\210\'\004\003\374\336F"
-}
-leaves {
-  key: "CNc="
-  value: "S\252x\314\231[\"\227t\337\034\211\000\030\302\347\314cU\257C\205N\247\212U0\347\326.\237?"
-}
-leaves {
-  key: "CNg="
-  value: "\316\346<G\366\014l\373\252_\024\247\257\211\334l\212Z\215\322\2014\230\325[\312\361\245\245I\303R"
-}
-leaves {
-  key: "CNk="
-  value: "J\323v.\376$Tj\0057.\203eU\240\310\350\265O|\243o\034S\370\177\302\010\177\357`\276"
-}
-leaves {
-  key: "CNo="
-  value: "\213\225\022\322]\301\263`L\333>l\\\344\321\221\013\233\203\334\257\357\264jL\360\036>I\214\336M"
-}
-leaves {
-  key: "CNs="
-  value: "\332\337\353\231\221\022\003;uHhR\177\274g\232\344\203\024\212\024\217\303L\363\275\345\334\221\302\273A"
-}
-leaves {
-  key: "CNw="
-  value: "\245\360\027\201\211\331\354\352\242j\244\365\350o3\375\243\264v0q\261\204x\267D\266\216\033\375\2239"
-}
-leaves {
-  key: "CO0="
-  value: "\361\347\340\r%\307\207\030&4Q\233\3074;\321*\231J\322\022\262?\366@\366/{s\306D\262"
-}
-leaves {
-  key: "CO4="
-  value: "\030F\263Mn\300t\240\347\\\303\005Y\333\366\317\347\263\324\034\375~\275\013\274\014<m(\301\322a"
-}
-leaves {
-  key: "CO8="
-  value: "\026m\222g\036\225\237ci?^+\204\352\332t\220\\{Dwn\020\327s\260\001\322\242\362\324\026"
-}
-leaves {
-  key: "COA="
-  value: "\325`<Q\037\332\337\320\246\'\257_\365=\031IYxJCy)\r\326\245\203H\265\374\265\320\324"
-}
-leaves {
-  key: "COE="
-  value: "\361nY\304\256V\301\311\257\30259\t:\026Z#$\013=\373\352\356\210\305\035/\241\251\215*\215"
-}
-leaves {
-  key: "COI="
-  value: "\256\366\273\323G\026r\234\317 *\244\\4\357\226X\237\213\340\371\365\257\"\300\342\374\312\313a\237>"
-}
-leaves {
-  key: "COM="
-  value: "\311L\'\3731&\306\373\217\230\226;\370%\r\353\222\227\212\230\217\217\274\316![\250Ud8\300\260"
-}
-leaves {
-  key: "COQ="
-  value: "+M\\\352_\200\001\370Y\355,e\032*\265U\2473\253\202\231\265\275B9\246\006\345b|q\207"
-}
-leaves {
-  key: "COU="
-  value: "@v\267\376\267c\322\274\327\217\r\342\226\024\314p\243?\220\307\204\007\252\215\0242\355Zi F\026"
-}
-leaves {
-  key: "COY="
-  value: "\215\337]\202.\036)>\372\245!m\303\207`n\251\270P\344\322\326>P{\210\342\332\261\006\016\371"
-}
-leaves {
-  key: "COc="
-  value: "U\033\327\313\nhT<\244\222G\304(\201h\257\211FZ\001\\\324\377\341\315\030sJ\234\005;0"
-}
-leaves {
-  key: "COg="
-  value: "h\222\320g?\217\3609Al\211\373\241\347\316\241z\342D\337xob\315\312[\347R\210\037\005\272"
-}
-leaves {
-  key: "COk="
-  value: "=@\353#``\345>\205\235\315\010\370vi1\335\007\376\022\267\240\350\3737\022\346\232,\3700~"
-}
-leaves {
-  key: "COo="
-  value: "\244W\275K\302\005\243,\"\360\306\001\255\021\207\207\346\333\302{\274\220\014\225Mb\243l\346\322\014\262"
-}
-leaves {
-  key: "COs="
-  value: "\371\251\"\322c\3272(\247\264~\014\324\\\313\324\262\024N0d\021/\214\021\017\036\357\350\315T\251"
-}
-leaves {
-  key: "COw="
-  value: "$z\"`Y\002\232\362d#L\207\243\237V\260\260\255\305~\257\035\205}\204\023;\304\016\217}B"
-}
-leaves {
-  key: "CP0="
-  value: "\377[7$Ws\317*q\343\016Nd\206LN\241\300\221xS\352\351\247y\305\265\240\007\006+\006"
-}
-leaves {
-  key: "CP4="
-  value: "\274\376$\3625Id\325\362\022\354\330\025\272;\313\264\313C\314\362d\261\255\320\024\343\364oi\017\352"
-}
-leaves {
-  key: "CP8="
-  value: "\270\320\315\237\364\223E\242,i\"\236m2\177>\235\205\214\327\262&\311\2563\202^W\225m@Q"
-}
-leaves {
-  key: "CPA="
-  value: "1Y\340\303\271PUTA\260];D\035\325s\034\303\356\220\237,9Ki\212\273u\236\312\247_"
-}
-leaves {
-  key: "CPE="
-  value: "\220\352\257uc\253WGA\271\232\334\357\376\326$\303\014B\273\215\311\275\223\353eX:\271\356\301\t"
-}
-leaves {
-  key: "CPI="
-  value: "%a\007HT\305u\355\310\357\253\246M3\230\030+\361]\346\006\306G)\321\000\247\034\020\262\203\233"
-}
-leaves {
-  key: "CPM="
-  value: "\260\3729\025\211\035\274\304\223\246tO}~\'G\005\250\362\013\322\207\346^\277\345\3000\226\213\374\010"
-}
-leaves {
-  key: "CPQ="
-  value: "\370hI\"\242\201\370\350-^\220\036\233\345$\302\006i\332\366\206T\251\240\346\007?\221f\254\374\354"
-}
-leaves {
-  key: "CPU="
-  value: "\245\022\276\314\213\337F\324\334|\305o\256\335\305n\206\336\255\'-\363O\215\220f\230\302<\315u\317"
-}
-leaves {
-  key: "CPY="
-  value: "\365\016\037\027\013w\270u\013\031\300\023$\017\331\\\321\340\220\255oZT3\370y\354q\354\303a\272"
-}
-leaves {
-  key: "CPc="
-  value: "\024\020\177G\273\353\261_\331\206O\t\270\213\'\217\362T\rL4\025\307\232\315\371\3523\345\025\350\363"
-}
-leaves {
-  key: "CPg="
-  value: "v~|\262\234\316\317\036\216\014\330\211#tL9\350?\232\221\361\235*\201\326\351\010\025\341\332{\""
-}
-leaves {
-  key: "CPk="
-  value: "n\\\270a\334{<\372\256|\\<R<M\344\211\000\272\361\0037\237\245\023\n\257=\3509\217\034"
-}
-leaves {
-  key: "CPo="
-  value: "E\266PU.\374}!\361\366\370\324\255\035+c\r\363\324\2067#\311\202\310\257\275<\341\225\321\301"
-}
-leaves {
-  key: "CPs="
-  value: "w\000\214\376edG\355\032\254\005f\337@\3612B\200O\261^V_\347`\022\230\0367\2149\264"
-}
-leaves {
-  key: "CPw="
-  value: "\334\334W1X\'P\254\350E\235W\223\030\351\203\3755\204\243&|\335*n\307 \033\363\232p2"
-}
@@ -40,15 +40,19 @@ type SignatureAlgorithm int32
 const (
 	SignatureAlgorithm_ECDSA SignatureAlgorithm = 0
 	SignatureAlgorithm_RSA   SignatureAlgorithm = 1
+	// ED25519 signatures are made over the message itself rather than a digest of it.
+	SignatureAlgorithm_ED25519 SignatureAlgorithm = 2
 )
 
 var SignatureAlgorithm_name = map[int32]string{
 	0: "ECDSA",
 	1: "RSA",
+	2: "ED25519",
 }
 var SignatureAlgorithm_value = map[string]int32{
-	"ECDSA": 0,
-	"RSA":   1,
+	"ECDSA":   0,
+	"RSA":     1,
+	"ED25519": 2,
 }
 
 func (x SignatureAlgorithm) String() string {
@@ -59,14 +63,20 @@ func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescript
 type HashAlgorithm int32
 
 const (
-	HashAlgorithm_SHA256 HashAlgorithm = 0
+	HashAlgorithm_SHA256      HashAlgorithm = 0
+	HashAlgorithm_SHA512_256  HashAlgorithm = 1
+	HashAlgorithm_BLAKE2B_256 HashAlgorithm = 2
 )
 
 var HashAlgorithm_name = map[int32]string{
 	0: "SHA256",
+	1: "SHA512_256",
+	2: "BLAKE2B_256",
 }
 var HashAlgorithm_value = map[string]int32{
-	"SHA256": 0,
+	"SHA256":      0,
+	"SHA512_256":  1,
+	"BLAKE2B_256": 2,
 }
 
 func (x HashAlgorithm) String() string {
@@ -74,6 +84,63 @@ func (x HashAlgorithm) String() string {
 }
 func (HashAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
 
+// TreeType defines the kind of data structure a tree holds.
+type TreeType int32
+
+const (
+	TreeType_UNKNOWN_TREE_TYPE TreeType = 0
+	TreeType_LOG               TreeType = 1
+	TreeType_MAP               TreeType = 2
+	// A pre-ordered log is one where the application assigns each leaf's index when it is
+	// added, for example when mirroring another log. The log only builds the tree.
+	TreeType_PREORDERED_LOG TreeType = 3
+)
+
+var TreeType_name = map[int32]string{
+	0: "UNKNOWN_TREE_TYPE",
+	1: "LOG",
+	2: "MAP",
+	3: "PREORDERED_LOG",
+}
+var TreeType_value = map[string]int32{
+	"UNKNOWN_TREE_TYPE": 0,
+	"LOG":               1,
+	"MAP":               2,
+	"PREORDERED_LOG":    3,
+}
+
+func (x TreeType) String() string {
+	return proto.EnumName(TreeType_name, int32(x))
+}
+func (TreeType) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+
+// TreeState defines the stages of a tree's lifecycle.
+type TreeState int32
+
+const (
+	TreeState_UNKNOWN_TREE_STATE TreeState = 0
+	// An active tree accepts reads and writes.
+	TreeState_ACTIVE TreeState = 1
+	// A frozen tree can still be read but will not accept any new leaves.
+	TreeState_FROZEN TreeState = 2
+)
+
+var TreeState_name = map[int32]string{
+	0: "UNKNOWN_TREE_STATE",
+	1: "ACTIVE",
+	2: "FROZEN",
+}
+var TreeState_value = map[string]int32{
+	"UNKNOWN_TREE_STATE": 0,
+	"ACTIVE":             1,
+	"FROZEN":             2,
+}
+
+func (x TreeState) String() string {
+	return proto.EnumName(TreeState_name, int32(x))
+}
+func (TreeState) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+
 type DigitallySigned struct {
 	SignatureAlgorithm SignatureAlgorithm `protobuf:"varint,1,opt,name=signature_algorithm,json=signatureAlgorithm,enum=trillian.SignatureAlgorithm" json:"signature_algorithm,omitempty"`
 	HashAlgorithm      HashAlgorithm      `protobuf:"varint,2,opt,name=hash_algorithm,json=hashAlgorithm,enum=trillian.HashAlgorithm" json:"hash_algorithm,omitempty"`
@@ -110,10 +177,13 @@ type SignedLogRoot struct {
 	RootHash       []byte `protobuf:"bytes,2,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
 	// TreeSize is the number of entries in the tree.
 	TreeSize int64 `protobuf:"varint,3,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
-	// TODO(al): define serialised format for the signature scheme.
+	// Signature is over the root serialized as a LogRootV1, see merkle/verify/log_root.go.
 	Signature    *DigitallySigned `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
 	LogId        []byte           `protobuf:"bytes,5,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	TreeRevision int64            `protobuf:"varint,6,opt,name=tree_revision,json=treeRevision" json:"tree_revision,omitempty"`
+	// Metadata is opaque data attached to the root by the personality when it's signed, e.g. to
+	// bind the root to a position in another tree. It's covered by the signature.
+	Metadata []byte `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 }
 
 func (m *SignedLogRoot) Reset()                    { *m = SignedLogRoot{} }
@@ -128,6 +198,27 @@ func (m *SignedLogRoot) GetSignature() *DigitallySigned {
 	return nil
 }
 
+// Cosignature is a witness's signature over a SignedLogRoot. It's made over the same data as
+// the log's own signature, so a witness cosigns a root to show it has checked that root is
+// consistent with the others it has seen from the log.
+type Cosignature struct {
+	// Identifies the witness, the log uses this to find the witness's public key.
+	WitnessId string           `protobuf:"bytes,1,opt,name=witness_id,json=witnessId" json:"witness_id,omitempty"`
+	Signature *DigitallySigned `protobuf:"bytes,2,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *Cosignature) Reset()                    { *m = Cosignature{} }
+func (m *Cosignature) String() string            { return proto.CompactTextString(m) }
+func (*Cosignature) ProtoMessage()               {}
+func (*Cosignature) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+
+func (m *Cosignature) GetSignature() *DigitallySigned {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 type MapperMetadata struct {
 	SourceLogId                  []byte `protobuf:"bytes,1,opt,name=source_log_id,json=sourceLogId,proto3" json:"source_log_id,omitempty"`
 	HighestFullyCompletedSeq     int64  `protobuf:"varint,2,opt,name=highest_fully_completed_seq,json=highestFullyCompletedSeq" json:"highest_fully_completed_seq,omitempty"`
@@ -137,7 +228,7 @@ type MapperMetadata struct {
 func (m *MapperMetadata) Reset()                    { *m = MapperMetadata{} }
 func (m *MapperMetadata) String() string            { return proto.CompactTextString(m) }
 func (*MapperMetadata) ProtoMessage()               {}
-func (*MapperMetadata) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+func (*MapperMetadata) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
 
 // SignedMapRoot represents a commitment by a Map to a particular tree.
 type SignedMapRoot struct {
@@ -153,7 +244,7 @@ type SignedMapRoot struct {
 func (m *SignedMapRoot) Reset()                    { *m = SignedMapRoot{} }
 func (m *SignedMapRoot) String() string            { return proto.CompactTextString(m) }
 func (*SignedMapRoot) ProtoMessage()               {}
-func (*SignedMapRoot) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+func (*SignedMapRoot) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{5} }
 
 func (m *SignedMapRoot) GetMetadata() *MapperMetadata {
 	if m != nil {
@@ -169,54 +260,111 @@ func (m *SignedMapRoot) GetSignature() *DigitallySigned {
 	return nil
 }
 
+// Tree holds the metadata and settings for a log or map.
+type Tree struct {
+	// tree_id is assigned by the server when the tree is created.
+	TreeId    int64     `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	TreeType  TreeType  `protobuf:"varint,2,opt,name=tree_type,json=treeType,enum=trillian.TreeType" json:"tree_type,omitempty"`
+	TreeState TreeState `protobuf:"varint,3,opt,name=tree_state,json=treeState,enum=trillian.TreeState" json:"tree_state,omitempty"`
+	// key_id identifies the key used to sign the tree's roots. For logs this is the
+	// public log ID.
+	KeyId                []byte        `protobuf:"bytes,4,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	HashAlgorithm        HashAlgorithm `protobuf:"varint,5,opt,name=hash_algorithm,json=hashAlgorithm,enum=trillian.HashAlgorithm" json:"hash_algorithm,omitempty"`
+	AllowDuplicateLeaves bool          `protobuf:"varint,6,opt,name=allow_duplicate_leaves,json=allowDuplicateLeaves" json:"allow_duplicate_leaves,omitempty"`
+	// display_name and description are free form text for operators and are the only
+	// settings which can be changed after the tree has been created.
+	DisplayName string `protobuf:"bytes,7,opt,name=display_name,json=displayName" json:"display_name,omitempty"`
+	Description string `protobuf:"bytes,8,opt,name=description" json:"description,omitempty"`
+	// Epoch milliseconds, set by the server.
+	CreateTimeMillis int64 `protobuf:"varint,9,opt,name=create_time_millis,json=createTimeMillis" json:"create_time_millis,omitempty"`
+	UpdateTimeMillis int64 `protobuf:"varint,10,opt,name=update_time_millis,json=updateTimeMillis" json:"update_time_millis,omitempty"`
+	// deleted is set when the tree has been soft deleted. Deleted trees aren't served and
+	// their data is removed once the grace period after delete_time_millis has passed,
+	// until then they can be undeleted.
+	Deleted          bool  `protobuf:"varint,11,opt,name=deleted" json:"deleted,omitempty"`
+	DeleteTimeMillis int64 `protobuf:"varint,12,opt,name=delete_time_millis,json=deleteTimeMillis" json:"delete_time_millis,omitempty"`
+}
+
+func (m *Tree) Reset()                    { *m = Tree{} }
+func (m *Tree) String() string            { return proto.CompactTextString(m) }
+func (*Tree) ProtoMessage()               {}
+func (*Tree) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{6} }
+
 func init() {
 	proto.RegisterType((*DigitallySigned)(nil), "trillian.DigitallySigned")
 	proto.RegisterType((*SignedEntryTimestamp)(nil), "trillian.SignedEntryTimestamp")
 	proto.RegisterType((*SignedLogRoot)(nil), "trillian.SignedLogRoot")
+	proto.RegisterType((*Cosignature)(nil), "trillian.Cosignature")
 	proto.RegisterType((*MapperMetadata)(nil), "trillian.MapperMetadata")
 	proto.RegisterType((*SignedMapRoot)(nil), "trillian.SignedMapRoot")
+	proto.RegisterType((*Tree)(nil), "trillian.Tree")
 	proto.RegisterEnum("trillian.TreeHasherPreimageType", TreeHasherPreimageType_name, TreeHasherPreimageType_value)
 	proto.RegisterEnum("trillian.SignatureAlgorithm", SignatureAlgorithm_name, SignatureAlgorithm_value)
 	proto.RegisterEnum("trillian.HashAlgorithm", HashAlgorithm_name, HashAlgorithm_value)
+	proto.RegisterEnum("trillian.TreeType", TreeType_name, TreeType_value)
+	proto.RegisterEnum("trillian.TreeState", TreeState_name, TreeState_value)
 }
 
 func init() { proto.RegisterFile("github.com/google/trillian/trillian.proto", fileDescriptor1) }
 
 var fileDescriptor1 = []byte{
-	// 549 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x54, 0x5d, 0x8f, 0xd2, 0x40,
-	0x14, 0xdd, 0x8a, 0x20, 0x5c, 0x3e, 0x16, 0xc7, 0x2f, 0x0c, 0x24, 0x2a, 0x3e, 0xb8, 0xf2, 0x00,
-	0x09, 0x2a, 0xc6, 0x07, 0x4d, 0x08, 0xcb, 0xba, 0x9b, 0x2c, 0x86, 0x4c, 0x79, 0x9f, 0xcc, 0x2e,
-	0x63, 0x3b, 0x49, 0xdb, 0xe9, 0x4e, 0x07, 0x13, 0xfc, 0x13, 0xfe, 0x1f, 0x1f, 0xfc, 0x3b, 0xfe,
-	0x0b, 0xe3, 0x4c, 0x69, 0x4b, 0x81, 0x97, 0x4d, 0xf4, 0x6d, 0xee, 0xe9, 0xb9, 0x67, 0xce, 0xbd,
-	0xa7, 0x2d, 0xbc, 0x76, 0xb8, 0x72, 0x57, 0x57, 0xfd, 0x6b, 0xe1, 0x0f, 0x1c, 0x21, 0x1c, 0x8f,
-	0x0d, 0x94, 0xe4, 0x9e, 0xc7, 0x69, 0x90, 0x1d, 0xfa, 0xa1, 0x14, 0x4a, 0xa0, 0x72, 0x5a, 0x77,
-	0x7f, 0x59, 0x70, 0x7c, 0xca, 0x75, 0x27, 0xf5, 0xbc, 0xb5, 0xcd, 0x9d, 0x80, 0x2d, 0xd1, 0x0c,
-	0x1e, 0x44, 0xfa, 0x44, 0xd5, 0x4a, 0x32, 0x42, 0x3d, 0x47, 0x48, 0x2d, 0xec, 0xb7, 0xac, 0xe7,
-	0xd6, 0x49, 0x63, 0xd8, 0xe9, 0x67, 0x5a, 0x76, 0x4a, 0x1a, 0xa7, 0x1c, 0x8c, 0xa2, 0x03, 0x0c,
-	0x7d, 0x82, 0x86, 0x4b, 0x23, 0x37, 0xa7, 0x74, 0x27, 0x56, 0x7a, 0xb2, 0x55, 0x3a, 0xd7, 0xcf,
-	0xb7, 0x22, 0x75, 0x37, 0x5f, 0xa2, 0x0e, 0x54, 0x32, 0xd5, 0x56, 0x41, 0xb7, 0xd6, 0xf0, 0x16,
-	0xe8, 0xfe, 0xb0, 0xe0, 0xe1, 0xc6, 0xf7, 0x34, 0x50, 0x72, 0xbd, 0xe0, 0x3e, 0x8b, 0x14, 0xf5,
-	0x43, 0xf4, 0x0a, 0x8e, 0x55, 0x5a, 0x90, 0x80, 0x06, 0x22, 0x8a, 0x27, 0x28, 0xe0, 0x46, 0x06,
-	0x7f, 0x31, 0x28, 0x7a, 0x04, 0x25, 0x4f, 0x38, 0x84, 0x2f, 0x63, 0x5f, 0x35, 0x5c, 0xd4, 0xd5,
-	0xc5, 0x12, 0xbd, 0xdf, 0xbf, 0xb6, 0x3a, 0x7c, 0xba, 0x75, 0xbc, 0xb7, 0xb3, 0xbc, 0xa3, 0xdf,
-	0x16, 0xd4, 0x37, 0xe8, 0xa5, 0x70, 0xb0, 0x10, 0xea, 0xf6, 0x56, 0xda, 0x50, 0x91, 0xba, 0x81,
-	0x98, 0x05, 0x24, 0x6e, 0xca, 0x06, 0x30, 0xfb, 0x31, 0x0f, 0x95, 0x64, 0x8c, 0x44, 0xfc, 0xfb,
-	0xc6, 0x50, 0x01, 0x97, 0x0d, 0x60, 0xeb, 0x7a, 0xd7, 0xed, 0xdd, 0xdb, 0xbb, 0xcd, 0x4d, 0x5f,
-	0xcc, 0x4f, 0xff, 0x12, 0xea, 0xf1, 0x65, 0x92, 0x7d, 0xe3, 0x11, 0x17, 0x41, 0xab, 0x14, 0x5f,
-	0x58, 0x33, 0x20, 0x4e, 0xb0, 0xee, 0x4f, 0x0b, 0x1a, 0x33, 0x1a, 0x86, 0x4c, 0xce, 0x98, 0xa2,
-	0x4b, 0xaa, 0x28, 0xea, 0x42, 0x3d, 0x12, 0x2b, 0x79, 0xcd, 0x48, 0xa2, 0x6a, 0xc5, 0xaa, 0xd5,
-	0x0d, 0x78, 0x19, 0x6b, 0x7f, 0x84, 0xb6, 0xcb, 0x1d, 0x57, 0x0f, 0x4e, 0xbe, 0xae, 0xb4, 0x29,
-	0xa2, 0xdf, 0xd8, 0xd0, 0x63, 0x8a, 0x2d, 0x49, 0xc4, 0x6e, 0xe2, 0xb9, 0x0b, 0xb8, 0x95, 0x50,
-	0xce, 0x0c, 0x63, 0x92, 0x12, 0x6c, 0x76, 0x83, 0xa6, 0xf0, 0x2c, 0x6d, 0x0f, 0xa9, 0x54, 0x9c,
-	0x1e, 0x4a, 0x6c, 0xb6, 0xd3, 0x49, 0x68, 0xf3, 0x94, 0x95, 0x97, 0xe9, 0xfe, 0xc9, 0x62, 0xd2,
-	0x23, 0xfc, 0xc7, 0x98, 0xde, 0x42, 0xd9, 0x4f, 0xb6, 0x91, 0xbc, 0x36, 0xad, 0x6d, 0x10, 0xbb,
-	0xdb, 0xc2, 0x19, 0xf3, 0x9f, 0xf2, 0xf3, 0x69, 0x98, 0xcb, 0x4f, 0x57, 0x7a, 0xc7, 0x2f, 0xa0,
-	0x66, 0xe0, 0xbd, 0xf8, 0xaa, 0x1a, 0x4b, 0xd3, 0xeb, 0x0d, 0xe0, 0xf1, 0x42, 0xa7, 0x69, 0x4c,
-	0x33, 0x39, 0x97, 0x8c, 0xfb, 0xd4, 0x61, 0x8b, 0x75, 0x68, 0x34, 0xef, 0xe3, 0xb3, 0x09, 0x19,
-	0x7d, 0x18, 0x0d, 0xc9, 0x1c, 0x4f, 0x2f, 0x66, 0xe3, 0xcf, 0xd3, 0xe6, 0x51, 0xef, 0x04, 0xd0,
-	0xe1, 0x27, 0x8f, 0x2a, 0x50, 0x9c, 0x4e, 0x4e, 0xed, 0x71, 0xf3, 0x08, 0xdd, 0x83, 0x02, 0xd6,
-	0x07, 0xab, 0xd7, 0x86, 0xfa, 0xce, 0x27, 0x8d, 0x00, 0x4a, 0xf6, 0xf9, 0x78, 0xf8, 0x6e, 0xd4,
-	0x3c, 0xba, 0x2a, 0xc5, 0xff, 0xa0, 0x37, 0x7f, 0x03, 0x00, 0x00, 0xff, 0xff, 0x69, 0x4c, 0x6d,
-	0xe8, 0xb0, 0x04, 0x00, 0x00,
+	// 922 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0x5b, 0x8f, 0xdb, 0x44,
+	0x14, 0xae, 0x93, 0xdd, 0x5c, 0x4e, 0x2e, 0x6b, 0xa6, 0xed, 0x36, 0xd0, 0x45, 0x2c, 0xe1, 0x81,
+	0x12, 0xa1, 0x5d, 0x35, 0x34, 0x8b, 0x2a, 0x2e, 0x92, 0x9b, 0x78, 0x77, 0xa3, 0x26, 0xd9, 0x68,
+	0x1c, 0x40, 0xf0, 0x62, 0x4d, 0xe3, 0xc1, 0x19, 0xd5, 0xce, 0xb8, 0xf6, 0xa4, 0x55, 0xfa, 0xca,
+	0x0f, 0xe0, 0xff, 0xf0, 0xc0, 0x0f, 0xe3, 0x01, 0xa1, 0x99, 0xb1, 0xe3, 0x24, 0xcb, 0x43, 0x2b,
+	0x78, 0x9b, 0xf3, 0x9d, 0xe3, 0xef, 0x7c, 0xe7, 0x32, 0x63, 0xf8, 0xc2, 0x67, 0x62, 0xb1, 0x7a,
+	0x71, 0x36, 0xe7, 0xe1, 0xb9, 0xcf, 0xb9, 0x1f, 0xd0, 0x73, 0x11, 0xb3, 0x20, 0x60, 0x64, 0xb9,
+	0x39, 0x9c, 0x45, 0x31, 0x17, 0x1c, 0x55, 0x32, 0xbb, 0xfd, 0xa7, 0x01, 0x47, 0x03, 0xe6, 0x33,
+	0x41, 0x82, 0x60, 0xed, 0x30, 0x7f, 0x49, 0x3d, 0x34, 0x86, 0xbb, 0x09, 0xf3, 0x97, 0x44, 0xac,
+	0x62, 0xea, 0x92, 0xc0, 0xe7, 0x31, 0x13, 0x8b, 0xb0, 0x65, 0x9c, 0x1a, 0x8f, 0x9a, 0xdd, 0x93,
+	0xb3, 0x0d, 0x97, 0x93, 0x05, 0x59, 0x59, 0x0c, 0x46, 0xc9, 0x2d, 0x0c, 0x7d, 0x0f, 0xcd, 0x05,
+	0x49, 0x16, 0x5b, 0x4c, 0x05, 0xc5, 0xf4, 0x20, 0x67, 0xba, 0x26, 0xc9, 0x22, 0x27, 0x69, 0x2c,
+	0xb6, 0x4d, 0x74, 0x02, 0xd5, 0x0d, 0x6b, 0xab, 0x78, 0x6a, 0x3c, 0xaa, 0xe3, 0x1c, 0x68, 0xff,
+	0x6e, 0xc0, 0x3d, 0xad, 0xdb, 0x5e, 0x8a, 0x78, 0x3d, 0x63, 0x21, 0x4d, 0x04, 0x09, 0x23, 0xf4,
+	0x39, 0x1c, 0x89, 0xcc, 0x70, 0x97, 0x64, 0xc9, 0x13, 0x55, 0x41, 0x11, 0x37, 0x37, 0xf0, 0x44,
+	0xa2, 0xe8, 0x3e, 0x94, 0x02, 0xee, 0xbb, 0xcc, 0x53, 0xba, 0xea, 0xf8, 0x30, 0xe0, 0xfe, 0xd0,
+	0x43, 0x5f, 0xef, 0xa7, 0xad, 0x75, 0x3f, 0xcc, 0x15, 0xef, 0xf5, 0x6c, 0x5b, 0xd1, 0x6f, 0x05,
+	0x68, 0x68, 0x74, 0xc4, 0x7d, 0xcc, 0xb9, 0x78, 0x77, 0x29, 0x0f, 0xa1, 0x1a, 0x73, 0x2e, 0x5c,
+	0xd9, 0x80, 0x54, 0x4d, 0x45, 0x02, 0xb2, 0x3f, 0xd2, 0x29, 0x62, 0x4a, 0xdd, 0x84, 0xbd, 0xd5,
+	0x82, 0x8a, 0xb8, 0x22, 0x01, 0x87, 0xbd, 0xa5, 0xbb, 0x6a, 0x0f, 0xde, 0x5d, 0xed, 0x56, 0xf5,
+	0x87, 0xdb, 0xd5, 0x7f, 0x06, 0x0d, 0x95, 0x2c, 0xa6, 0xaf, 0x59, 0xc2, 0xf8, 0xb2, 0x55, 0x52,
+	0x09, 0xeb, 0x12, 0xc4, 0x29, 0x86, 0x3e, 0x82, 0x4a, 0x48, 0x05, 0xf1, 0x88, 0x20, 0xad, 0xb2,
+	0x56, 0x9b, 0xd9, 0x6d, 0x0a, 0xb5, 0x3e, 0xcf, 0xd3, 0x7c, 0x0c, 0xf0, 0x86, 0x89, 0x25, 0x4d,
+	0x12, 0x99, 0x4a, 0x56, 0x5f, 0xc5, 0xd5, 0x14, 0xd9, 0x6f, 0x76, 0xe1, 0x3d, 0x9a, 0xfd, 0x87,
+	0x01, 0xcd, 0x31, 0x89, 0x22, 0x1a, 0x8f, 0xd3, 0xcc, 0xa8, 0x0d, 0x8d, 0x84, 0xaf, 0xe2, 0x39,
+	0x75, 0xd3, 0xc2, 0x0c, 0x25, 0xad, 0xa6, 0xc1, 0x91, 0x2a, 0xef, 0x3b, 0x78, 0xb8, 0x60, 0xfe,
+	0x82, 0x26, 0xc2, 0xfd, 0x75, 0x15, 0x04, 0x6b, 0x77, 0xce, 0xc3, 0x28, 0xa0, 0x82, 0x7a, 0x6e,
+	0x42, 0x5f, 0x29, 0x05, 0x45, 0xdc, 0x4a, 0x43, 0x2e, 0x65, 0x44, 0x3f, 0x0b, 0x70, 0xe8, 0x2b,
+	0x64, 0xc3, 0x27, 0xd9, 0xe7, 0x11, 0x89, 0x05, 0x23, 0xb7, 0x29, 0xf4, 0x80, 0x4e, 0xd2, 0xb0,
+	0x69, 0x16, 0xb5, 0x4d, 0xd3, 0xfe, 0xdb, 0xc8, 0x36, 0x65, 0x4c, 0xa2, 0xff, 0x71, 0x53, 0x9e,
+	0x6c, 0xcd, 0x45, 0x6f, 0x6e, 0x2b, 0x6f, 0xe6, 0x6e, 0xb7, 0xf2, 0x89, 0xfd, 0xa7, 0x15, 0x0a,
+	0x49, 0xb4, 0xb5, 0x42, 0x21, 0x89, 0x86, 0x1e, 0xfa, 0x14, 0xea, 0x12, 0xde, 0xdb, 0xa0, 0x5a,
+	0x48, 0xa2, 0x6c, 0x81, 0xda, 0x7f, 0x15, 0xe1, 0x60, 0x16, 0x53, 0x8a, 0x1e, 0x40, 0x59, 0xad,
+	0x5b, 0x3a, 0xad, 0x22, 0x2e, 0x49, 0x73, 0xe8, 0xa1, 0xf3, 0x74, 0xe9, 0xc5, 0x3a, 0xa2, 0xe9,
+	0xbb, 0x81, 0x72, 0x51, 0xf2, 0xdb, 0xd9, 0x3a, 0xa2, 0xfa, 0x22, 0xc8, 0x13, 0xea, 0x02, 0xe8,
+	0x5b, 0x22, 0x88, 0xd0, 0xd7, 0xa4, 0xd9, 0xbd, 0xbb, 0xfb, 0x85, 0x23, 0x5d, 0xb8, 0x2a, 0xb2,
+	0xa3, 0x2c, 0xe0, 0x25, 0x5d, 0xcb, 0xe4, 0x07, 0xba, 0x80, 0x97, 0x74, 0x3d, 0xf4, 0xfe, 0xe5,
+	0xe1, 0x3a, 0x7c, 0xaf, 0x87, 0xeb, 0x09, 0x1c, 0x93, 0x20, 0xe0, 0x6f, 0x5c, 0x6f, 0x15, 0x05,
+	0x6c, 0x4e, 0x04, 0x75, 0x03, 0x4a, 0x5e, 0xd3, 0x44, 0xb5, 0xa2, 0x82, 0xef, 0x29, 0xef, 0x20,
+	0x73, 0x8e, 0x94, 0x4f, 0xb6, 0xcd, 0x63, 0x49, 0x14, 0x90, 0xb5, 0xbb, 0x24, 0x21, 0x55, 0x17,
+	0xab, 0x8a, 0x6b, 0x29, 0x36, 0x21, 0x21, 0x45, 0xa7, 0x50, 0xf3, 0x68, 0x32, 0x8f, 0x59, 0x24,
+	0x64, 0x63, 0x2b, 0x69, 0x44, 0x0e, 0xa1, 0x2f, 0x01, 0xcd, 0x63, 0x2a, 0x33, 0xca, 0xbd, 0x71,
+	0x43, 0x29, 0x37, 0x69, 0x55, 0x55, 0x6b, 0x4d, 0xed, 0x91, 0x2f, 0xe5, 0x58, 0xe1, 0x32, 0x7a,
+	0x15, 0x79, 0xfb, 0xd1, 0xa0, 0xa3, 0xb5, 0x67, 0x2b, 0xba, 0x05, 0x65, 0x8f, 0xaa, 0x1d, 0x6e,
+	0xd5, 0x54, 0x1d, 0x99, 0x29, 0x79, 0xf4, 0x71, 0x87, 0xa7, 0xae, 0x79, 0xb4, 0x27, 0xe7, 0xe9,
+	0x9c, 0xc3, 0xb1, 0x9c, 0x86, 0x6c, 0x21, 0x8d, 0xa7, 0x31, 0x65, 0x21, 0xf1, 0xf5, 0x0c, 0xef,
+	0xc3, 0x07, 0xf8, 0xb2, 0xef, 0x5e, 0x3c, 0xbd, 0xe8, 0xba, 0x53, 0x6c, 0x0f, 0xc7, 0xd6, 0x95,
+	0x6d, 0xde, 0xe9, 0xf4, 0x00, 0xdd, 0xfe, 0xe5, 0xa0, 0x2a, 0x1c, 0xda, 0xfd, 0x81, 0x63, 0x99,
+	0x77, 0x50, 0x19, 0x8a, 0xd8, 0xb1, 0x4c, 0x03, 0xd5, 0xa0, 0x6c, 0x0f, 0xba, 0xbd, 0xde, 0xe3,
+	0xa7, 0x66, 0xa1, 0xf3, 0x2d, 0x34, 0x76, 0xc6, 0x84, 0x00, 0x4a, 0xce, 0xb5, 0xd5, 0xed, 0x5d,
+	0x98, 0x77, 0x50, 0x13, 0xc0, 0xb9, 0xb6, 0x7a, 0x8f, 0xbb, 0xae, 0xb4, 0x0d, 0x74, 0x04, 0xb5,
+	0x67, 0x23, 0xeb, 0xb9, 0xdd, 0x7d, 0xa6, 0x80, 0x42, 0xe7, 0x0a, 0x2a, 0xd9, 0x96, 0x49, 0x5d,
+	0x3f, 0x4c, 0x9e, 0x4f, 0x6e, 0x7e, 0x9a, 0xb8, 0x33, 0x6c, 0xdb, 0xee, 0xec, 0xe7, 0xa9, 0xad,
+	0xd3, 0x8e, 0x6e, 0xae, 0x4c, 0x43, 0x1e, 0xc6, 0xd6, 0xd4, 0x2c, 0x20, 0x04, 0xcd, 0x29, 0xb6,
+	0x6f, 0xf0, 0xc0, 0xc6, 0xf6, 0xc0, 0x95, 0xce, 0x62, 0xe7, 0x1b, 0xa8, 0x6e, 0x96, 0x0f, 0x1d,
+	0x03, 0xda, 0x61, 0x72, 0x66, 0xd6, 0x4c, 0x52, 0x01, 0x94, 0xac, 0xfe, 0x6c, 0xf8, 0xa3, 0x6d,
+	0x1a, 0xf2, 0x7c, 0x89, 0x6f, 0x7e, 0xb1, 0x27, 0x66, 0xe1, 0x45, 0x49, 0xfd, 0xb7, 0xbf, 0xfa,
+	0x67, 0x00, 0xd8, 0x47, 0xf5, 0x92, 0xe4, 0x07, 0x00, 0x00,
 }
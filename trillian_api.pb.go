@@ -15,7 +15,10 @@ It has these top-level messages:
 	NodeProto
 	ProofProto
 	QueueLeavesRequest
+	QueuedLeaf
 	QueueLeavesResponse
+	AddSequencedLeavesRequest
+	AddSequencedLeavesResponse
 	GetInclusionProofRequest
 	GetInclusionProofResponse
 	GetInclusionProofByHashRequest
@@ -24,12 +27,20 @@ It has these top-level messages:
 	GetConsistencyProofResponse
 	GetLeavesByHashRequest
 	GetLeavesByHashResponse
+	GetLeavesByIdentityHashRequest
+	GetLeavesByIdentityHashResponse
 	GetLeavesByIndexRequest
 	GetLeavesByIndexResponse
+	GetLeavesByRangeRequest
+	GetLeavesByRangeResponse
+	StreamLeavesRequest
+	StreamLeavesResponse
 	GetSequencedLeafCountRequest
 	GetSequencedLeafCountResponse
 	GetLatestSignedLogRootRequest
 	GetLatestSignedLogRootResponse
+	AddCosignatureRequest
+	AddCosignatureResponse
 	GetEntryAndProofRequest
 	GetEntryAndProofResponse
 	MapLeaf
@@ -41,17 +52,52 @@ It has these top-level messages:
 	SetMapLeavesResponse
 	GetSignedMapRootRequest
 	GetSignedMapRootResponse
+	GetSignedMapRootByRevisionRequest
+	GetSignedMapRootByRevisionResponse
+	GetLeafHistoryRequest
+	MapLeafRevision
+	GetLeafHistoryResponse
+	MapMutation
+	StreamMutationsRequest
+	StreamMutationsResponse
+	GetMapLeavesByPrefixRequest
+	GetMapLeavesByPrefixResponse
+	CreateTreeRequest
+	CreateTreeResponse
+	ListTreesRequest
+	ListTreesResponse
+	GetTreeRequest
+	GetTreeResponse
+	UpdateTreeRequest
+	UpdateTreeResponse
+	FreezeTreeRequest
+	FreezeTreeResponse
+	DeleteTreeRequest
+	DeleteTreeResponse
+	UndeleteTreeRequest
+	UndeleteTreeResponse
+	TreeUsage
+	GetTreeUsageRequest
+	GetTreeUsageResponse
+	AuditEvent
+	ListAuditEventsRequest
+	ListAuditEventsResponse
+	ReloadConfigRequest
+	ReloadConfigResponse
 	DigitallySigned
 	SignedEntryTimestamp
 	SignedLogRoot
+	Cosignature
 	MapperMetadata
 	SignedMapRoot
+	Tree
 */
 package trillian
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import _ "google.golang.org/genproto/googleapis/api/annotations"
 
 import (
 	context "golang.org/x/net/context"
@@ -91,6 +137,106 @@ func (x TrillianApiStatusCode) String() string {
 }
 func (TrillianApiStatusCode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+// QueuedLeafStatus is the outcome of queueing one of the leaves in a QueueLeavesRequest.
+type QueuedLeafStatus int32
+
+const (
+	QueuedLeafStatus_UNKNOWN_QUEUED_LEAF_STATUS QueuedLeafStatus = 0
+	// The leaf was queued for integration into the log.
+	QueuedLeafStatus_QUEUED QueuedLeafStatus = 1
+	// The leaf was not queued because the log doesn't allow duplicates and already contains
+	// a leaf with the same leaf hash.
+	QueuedLeafStatus_DUPLICATE QueuedLeafStatus = 2
+	// The leaf was not queued because it is invalid.
+	QueuedLeafStatus_REJECTED QueuedLeafStatus = 3
+)
+
+var QueuedLeafStatus_name = map[int32]string{
+	0: "UNKNOWN_QUEUED_LEAF_STATUS",
+	1: "QUEUED",
+	2: "DUPLICATE",
+	3: "REJECTED",
+}
+var QueuedLeafStatus_value = map[string]int32{
+	"UNKNOWN_QUEUED_LEAF_STATUS": 0,
+	"QUEUED":                     1,
+	"DUPLICATE":                  2,
+	"REJECTED":                   3,
+}
+
+func (x QueuedLeafStatus) String() string {
+	return proto.EnumName(QueuedLeafStatus_name, int32(x))
+}
+func (QueuedLeafStatus) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+// LeafRejectionCode says why a leaf was REJECTED, so clients can handle each reason without
+// parsing the reason text.
+type LeafRejectionCode int32
+
+const (
+	LeafRejectionCode_UNKNOWN_LEAF_REJECTION_CODE LeafRejectionCode = 0
+	// The leaf, or a field it must have, is missing.
+	LeafRejectionCode_MISSING_FIELD LeafRejectionCode = 1
+	// A field of the leaf has a value that's never valid, e.g. a negative leaf index.
+	LeafRejectionCode_INVALID_FIELD LeafRejectionCode = 2
+	// The leaf value and extra data together are bigger than the log allows.
+	LeafRejectionCode_TOO_LARGE LeafRejectionCode = 3
+	// The log's validation hook rejected the leaf, e.g. because its value isn't a well formed
+	// entry for the application using the log.
+	LeafRejectionCode_FAILED_VALIDATION LeafRejectionCode = 4
+)
+
+var LeafRejectionCode_name = map[int32]string{
+	0: "UNKNOWN_LEAF_REJECTION_CODE",
+	1: "MISSING_FIELD",
+	2: "INVALID_FIELD",
+	3: "TOO_LARGE",
+	4: "FAILED_VALIDATION",
+}
+var LeafRejectionCode_value = map[string]int32{
+	"UNKNOWN_LEAF_REJECTION_CODE": 0,
+	"MISSING_FIELD":               1,
+	"INVALID_FIELD":               2,
+	"TOO_LARGE":                   3,
+	"FAILED_VALIDATION":           4,
+}
+
+func (x LeafRejectionCode) String() string {
+	return proto.EnumName(LeafRejectionCode_name, int32(x))
+}
+func (LeafRejectionCode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+// AuditEventType says what kind of action an AuditEvent records.
+type AuditEventType int32
+
+const (
+	AuditEventType_UNKNOWN_AUDIT_EVENT_TYPE AuditEventType = 0
+	// An RPC to the TrillianAdmin service, whether or not it succeeded.
+	AuditEventType_ADMIN_RPC AuditEventType = 1
+	// A use of a tree's private key to sign something.
+	AuditEventType_KEY_USED AuditEventType = 2
+	// A new log root that has been signed and stored.
+	AuditEventType_LOG_ROOT_SIGNED AuditEventType = 3
+)
+
+var AuditEventType_name = map[int32]string{
+	0: "UNKNOWN_AUDIT_EVENT_TYPE",
+	1: "ADMIN_RPC",
+	2: "KEY_USED",
+	3: "LOG_ROOT_SIGNED",
+}
+var AuditEventType_value = map[string]int32{
+	"UNKNOWN_AUDIT_EVENT_TYPE": 0,
+	"ADMIN_RPC":                1,
+	"KEY_USED":                 2,
+	"LOG_ROOT_SIGNED":          3,
+}
+
+func (x AuditEventType) String() string {
+	return proto.EnumName(AuditEventType_name, int32(x))
+}
+func (AuditEventType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
 // All operations return a TrillianApiStatus.
 // TODO(Martin2112): Most of the operations are not fully defined yet. They will be implemented soon
 type TrillianApiStatus struct {
@@ -111,6 +257,15 @@ type LeafProto struct {
 	LeafData  []byte `protobuf:"bytes,2,opt,name=leaf_data,json=leafData,proto3" json:"leaf_data,omitempty"`
 	ExtraData []byte `protobuf:"bytes,3,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
 	LeafIndex int64  `protobuf:"varint,4,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
+	// leaf_identity_hash is an optional hash chosen by the application that identifies the
+	// entry the leaf holds, e.g. a hash of a certificate that's logged with a timestamp. Unlike
+	// leaf_hash it isn't covered by the tree, it's only an index to find leaves by.
+	LeafIdentityHash []byte `protobuf:"bytes,5,opt,name=leaf_identity_hash,json=leafIdentityHash,proto3" json:"leaf_identity_hash,omitempty"`
+	// not_before_nanos is the earliest time a queued leaf is integrated, in nanoseconds since
+	// the epoch, e.g. to spread integration over a log's merge delay or to hold an entry back
+	// until an embargo ends. Leaves with 0 are integrated as soon as possible. It can't be set
+	// for leaves added to pre-ordered logs, which are integrated in order.
+	NotBeforeNanos int64 `protobuf:"varint,6,opt,name=not_before_nanos,json=notBeforeNanos" json:"not_before_nanos,omitempty"`
 }
 
 func (m *LeafProto) Reset()                    { *m = LeafProto{} }
@@ -149,6 +304,10 @@ func (m *ProofProto) GetProofNode() []*NodeProto {
 type QueueLeavesRequest struct {
 	LogId  int64        `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	Leaves []*LeafProto `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+	// Leaves queued with a higher priority are integrated before those with a lower one,
+	// e.g. a mirror backfilling a log can use a negative priority. A priority outside the
+	// range configured for the log is limited to it, and 0 is used if none is configured.
+	Priority int32 `protobuf:"varint,3,opt,name=priority" json:"priority,omitempty"`
 }
 
 func (m *QueueLeavesRequest) Reset()                    { *m = QueueLeavesRequest{} }
@@ -163,16 +322,57 @@ func (m *QueueLeavesRequest) GetLeaves() []*LeafProto {
 	return nil
 }
 
-// TODO(Martin2112): This will eventually contain the signed timestamps and stuff that we return for
-// the queued leaves
+// QueuedLeaf is the result for one of the leaves in a QueueLeavesRequest or
+// AddSequencedLeavesRequest.
+type QueuedLeaf struct {
+	Status QueuedLeafStatus `protobuf:"varint,1,opt,name=status,enum=trillian.QueuedLeafStatus" json:"status,omitempty"`
+	// For a DUPLICATE, the copy of the leaf already in the log. Its leaf_index is -1 if it has
+	// not been sequenced yet. For AddSequencedLeaves a DUPLICATE is a leaf whose index is
+	// already taken, and this is the leaf at that index.
+	ExistingLeaf *LeafProto `protobuf:"bytes,2,opt,name=existing_leaf,json=existingLeaf" json:"existing_leaf,omitempty"`
+	// For a DUPLICATE, the timestamp the existing leaf was queued with.
+	ExistingTimestamp *SignedEntryTimestamp `protobuf:"bytes,3,opt,name=existing_timestamp,json=existingTimestamp" json:"existing_timestamp,omitempty"`
+	// For a REJECTED leaf, why it was rejected.
+	Reason string `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
+	// For a REJECTED leaf, the kind of problem with it.
+	RejectionCode LeafRejectionCode `protobuf:"varint,5,opt,name=rejection_code,json=rejectionCode,enum=trillian.LeafRejectionCode" json:"rejection_code,omitempty"`
+	// For a REJECTED leaf, the name of the field at fault if there's one in particular, e.g.
+	// leaf_value.
+	RejectedField string `protobuf:"bytes,6,opt,name=rejected_field,json=rejectedField" json:"rejected_field,omitempty"`
+	// For a TOO_LARGE leaf, the most bytes of leaf value and extra data the log accepts.
+	MaxLeafSize int64 `protobuf:"varint,7,opt,name=max_leaf_size,json=maxLeafSize" json:"max_leaf_size,omitempty"`
+}
+
+func (m *QueuedLeaf) Reset()                    { *m = QueuedLeaf{} }
+func (m *QueuedLeaf) String() string            { return proto.CompactTextString(m) }
+func (*QueuedLeaf) ProtoMessage()               {}
+func (*QueuedLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *QueuedLeaf) GetExistingLeaf() *LeafProto {
+	if m != nil {
+		return m.ExistingLeaf
+	}
+	return nil
+}
+
+func (m *QueuedLeaf) GetExistingTimestamp() *SignedEntryTimestamp {
+	if m != nil {
+		return m.ExistingTimestamp
+	}
+	return nil
+}
+
+// The status only reports failures affecting the whole request. The outcome for each leaf is
+// in leaves, in the same order as the request.
 type QueueLeavesResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*QueuedLeaf      `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
 }
 
 func (m *QueueLeavesResponse) Reset()                    { *m = QueueLeavesResponse{} }
 func (m *QueueLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*QueueLeavesResponse) ProtoMessage()               {}
-func (*QueueLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*QueueLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *QueueLeavesResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -181,6 +381,58 @@ func (m *QueueLeavesResponse) GetStatus() *TrillianApiStatus {
 	return nil
 }
 
+func (m *QueueLeavesResponse) GetLeaves() []*QueuedLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+// Adds leaves to a pre-ordered log. Each leaf's leaf_index is the position it will have in
+// the log.
+type AddSequencedLeavesRequest struct {
+	LogId  int64        `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	Leaves []*LeafProto `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *AddSequencedLeavesRequest) Reset()                    { *m = AddSequencedLeavesRequest{} }
+func (m *AddSequencedLeavesRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesRequest) ProtoMessage()               {}
+func (*AddSequencedLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *AddSequencedLeavesRequest) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+// The status only reports failures affecting the whole request. The outcome for each leaf is
+// in leaves, in the same order as the request.
+type AddSequencedLeavesResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*QueuedLeaf      `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *AddSequencedLeavesResponse) Reset()                    { *m = AddSequencedLeavesResponse{} }
+func (m *AddSequencedLeavesResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesResponse) ProtoMessage()               {}
+func (*AddSequencedLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *AddSequencedLeavesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *AddSequencedLeavesResponse) GetLeaves() []*QueuedLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
 type GetInclusionProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex int64 `protobuf:"varint,2,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -190,7 +442,7 @@ type GetInclusionProofRequest struct {
 func (m *GetInclusionProofRequest) Reset()                    { *m = GetInclusionProofRequest{} }
 func (m *GetInclusionProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofRequest) ProtoMessage()               {}
-func (*GetInclusionProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*GetInclusionProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 type GetInclusionProofResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -200,7 +452,7 @@ type GetInclusionProofResponse struct {
 func (m *GetInclusionProofResponse) Reset()                    { *m = GetInclusionProofResponse{} }
 func (m *GetInclusionProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofResponse) ProtoMessage()               {}
-func (*GetInclusionProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*GetInclusionProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *GetInclusionProofResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -226,7 +478,7 @@ type GetInclusionProofByHashRequest struct {
 func (m *GetInclusionProofByHashRequest) Reset()                    { *m = GetInclusionProofByHashRequest{} }
 func (m *GetInclusionProofByHashRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofByHashRequest) ProtoMessage()               {}
-func (*GetInclusionProofByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*GetInclusionProofByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 type GetInclusionProofByHashResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -238,7 +490,7 @@ type GetInclusionProofByHashResponse struct {
 func (m *GetInclusionProofByHashResponse) Reset()                    { *m = GetInclusionProofByHashResponse{} }
 func (m *GetInclusionProofByHashResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetInclusionProofByHashResponse) ProtoMessage()               {}
-func (*GetInclusionProofByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*GetInclusionProofByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *GetInclusionProofByHashResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -263,7 +515,7 @@ type GetConsistencyProofRequest struct {
 func (m *GetConsistencyProofRequest) Reset()                    { *m = GetConsistencyProofRequest{} }
 func (m *GetConsistencyProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetConsistencyProofRequest) ProtoMessage()               {}
-func (*GetConsistencyProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*GetConsistencyProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 type GetConsistencyProofResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -273,7 +525,7 @@ type GetConsistencyProofResponse struct {
 func (m *GetConsistencyProofResponse) Reset()                    { *m = GetConsistencyProofResponse{} }
 func (m *GetConsistencyProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetConsistencyProofResponse) ProtoMessage()               {}
-func (*GetConsistencyProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*GetConsistencyProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *GetConsistencyProofResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -298,7 +550,7 @@ type GetLeavesByHashRequest struct {
 func (m *GetLeavesByHashRequest) Reset()                    { *m = GetLeavesByHashRequest{} }
 func (m *GetLeavesByHashRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashRequest) ProtoMessage()               {}
-func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 type GetLeavesByHashResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -308,7 +560,7 @@ type GetLeavesByHashResponse struct {
 func (m *GetLeavesByHashResponse) Reset()                    { *m = GetLeavesByHashResponse{} }
 func (m *GetLeavesByHashResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashResponse) ProtoMessage()               {}
-func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *GetLeavesByHashResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -324,6 +576,41 @@ func (m *GetLeavesByHashResponse) GetLeaves() []*LeafProto {
 	return nil
 }
 
+type GetLeavesByIdentityHashRequest struct {
+	LogId            int64    `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	LeafIdentityHash [][]byte `protobuf:"bytes,2,rep,name=leaf_identity_hash,json=leafIdentityHash,proto3" json:"leaf_identity_hash,omitempty"`
+	OrderBySequence  bool     `protobuf:"varint,3,opt,name=order_by_sequence,json=orderBySequence" json:"order_by_sequence,omitempty"`
+}
+
+func (m *GetLeavesByIdentityHashRequest) Reset()                    { *m = GetLeavesByIdentityHashRequest{} }
+func (m *GetLeavesByIdentityHashRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByIdentityHashRequest) ProtoMessage()               {}
+func (*GetLeavesByIdentityHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+type GetLeavesByIdentityHashResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*LeafProto       `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *GetLeavesByIdentityHashResponse) Reset()                    { *m = GetLeavesByIdentityHashResponse{} }
+func (m *GetLeavesByIdentityHashResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByIdentityHashResponse) ProtoMessage()               {}
+func (*GetLeavesByIdentityHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *GetLeavesByIdentityHashResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLeavesByIdentityHashResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
 type GetLeavesByIndexRequest struct {
 	LogId     int64   `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex []int64 `protobuf:"varint,2,rep,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -332,7 +619,7 @@ type GetLeavesByIndexRequest struct {
 func (m *GetLeavesByIndexRequest) Reset()                    { *m = GetLeavesByIndexRequest{} }
 func (m *GetLeavesByIndexRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexRequest) ProtoMessage()               {}
-func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 type GetLeavesByIndexResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -342,7 +629,7 @@ type GetLeavesByIndexResponse struct {
 func (m *GetLeavesByIndexResponse) Reset()                    { *m = GetLeavesByIndexResponse{} }
 func (m *GetLeavesByIndexResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexResponse) ProtoMessage()               {}
-func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
 func (m *GetLeavesByIndexResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -358,6 +645,96 @@ func (m *GetLeavesByIndexResponse) GetLeaves() []*LeafProto {
 	return nil
 }
 
+// GetLeavesByRangeRequest asks for a page of the sequenced leaves from start_index, in index
+// order. Only leaves covered by the latest signed log root are returned.
+type GetLeavesByRangeRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The sequence number of the first leaf to return.
+	StartIndex int64 `protobuf:"varint,2,opt,name=start_index,json=startIndex" json:"start_index,omitempty"`
+	// The maximum number of leaves to return over all the pages. Zero means all leaves up to
+	// the tree size.
+	Count int64 `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+	// The next_page_token of the previous page, empty for the first page. The rest of the
+	// pages are read from the range of the first request, start_index and count are ignored.
+	PageToken []byte `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+}
+
+func (m *GetLeavesByRangeRequest) Reset()                    { *m = GetLeavesByRangeRequest{} }
+func (m *GetLeavesByRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeRequest) ProtoMessage()               {}
+func (*GetLeavesByRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+// GetLeavesByRangeResponse holds a page of leaves, which the server may limit to fewer than
+// asked for.
+type GetLeavesByRangeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*LeafProto       `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+	// Opaque token to request the next page with, empty if this is the last page.
+	NextPageToken []byte `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+}
+
+func (m *GetLeavesByRangeResponse) Reset()                    { *m = GetLeavesByRangeResponse{} }
+func (m *GetLeavesByRangeResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeResponse) ProtoMessage()               {}
+func (*GetLeavesByRangeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *GetLeavesByRangeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLeavesByRangeResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+// StreamLeavesRequest asks for a contiguous range of sequenced leaves to be streamed back.
+// Only leaves covered by the latest signed log root are returned.
+type StreamLeavesRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The sequence number of the first leaf to return.
+	StartIndex int64 `protobuf:"varint,2,opt,name=start_index,json=startIndex" json:"start_index,omitempty"`
+	// The maximum number of leaves to return. Zero means all leaves up to the tree size.
+	Count int64 `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+	// The maximum number of leaves in each response message. Zero means use the server
+	// default, and the server may also reduce this.
+	ChunkSize int32 `protobuf:"varint,4,opt,name=chunk_size,json=chunkSize" json:"chunk_size,omitempty"`
+}
+
+func (m *StreamLeavesRequest) Reset()                    { *m = StreamLeavesRequest{} }
+func (m *StreamLeavesRequest) String() string            { return proto.CompactTextString(m) }
+func (*StreamLeavesRequest) ProtoMessage()               {}
+func (*StreamLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+// StreamLeavesResponse carries the next chunk of leaves, in sequence number order.
+type StreamLeavesResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*LeafProto       `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *StreamLeavesResponse) Reset()                    { *m = StreamLeavesResponse{} }
+func (m *StreamLeavesResponse) String() string            { return proto.CompactTextString(m) }
+func (*StreamLeavesResponse) ProtoMessage()               {}
+func (*StreamLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *StreamLeavesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StreamLeavesResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
 type GetSequencedLeafCountRequest struct {
 	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 }
@@ -365,7 +742,7 @@ type GetSequencedLeafCountRequest struct {
 func (m *GetSequencedLeafCountRequest) Reset()                    { *m = GetSequencedLeafCountRequest{} }
 func (m *GetSequencedLeafCountRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountRequest) ProtoMessage()               {}
-func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
 
 type GetSequencedLeafCountResponse struct {
 	Status    *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -375,7 +752,7 @@ type GetSequencedLeafCountResponse struct {
 func (m *GetSequencedLeafCountResponse) Reset()                    { *m = GetSequencedLeafCountResponse{} }
 func (m *GetSequencedLeafCountResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountResponse) ProtoMessage()               {}
-func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
 func (m *GetSequencedLeafCountResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -391,17 +768,19 @@ type GetLatestSignedLogRootRequest struct {
 func (m *GetLatestSignedLogRootRequest) Reset()                    { *m = GetLatestSignedLogRootRequest{} }
 func (m *GetLatestSignedLogRootRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootRequest) ProtoMessage()               {}
-func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
 type GetLatestSignedLogRootResponse struct {
 	Status        *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
 	SignedLogRoot *SignedLogRoot     `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// The cosignatures that witnesses have submitted for signed_log_root.
+	Cosignatures []*Cosignature `protobuf:"bytes,3,rep,name=cosignatures" json:"cosignatures,omitempty"`
 }
 
 func (m *GetLatestSignedLogRootResponse) Reset()                    { *m = GetLatestSignedLogRootResponse{} }
 func (m *GetLatestSignedLogRootResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootResponse) ProtoMessage()               {}
-func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
 func (m *GetLatestSignedLogRootResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -417,6 +796,48 @@ func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
 	return nil
 }
 
+func (m *GetLatestSignedLogRootResponse) GetCosignatures() []*Cosignature {
+	if m != nil {
+		return m.Cosignatures
+	}
+	return nil
+}
+
+type AddCosignatureRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The timestamp of the signed log root being cosigned, which identifies it in the log.
+	RootTimestampNanos int64        `protobuf:"varint,2,opt,name=root_timestamp_nanos,json=rootTimestampNanos" json:"root_timestamp_nanos,omitempty"`
+	Cosignature        *Cosignature `protobuf:"bytes,3,opt,name=cosignature" json:"cosignature,omitempty"`
+}
+
+func (m *AddCosignatureRequest) Reset()                    { *m = AddCosignatureRequest{} }
+func (m *AddCosignatureRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddCosignatureRequest) ProtoMessage()               {}
+func (*AddCosignatureRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *AddCosignatureRequest) GetCosignature() *Cosignature {
+	if m != nil {
+		return m.Cosignature
+	}
+	return nil
+}
+
+type AddCosignatureResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *AddCosignatureResponse) Reset()                    { *m = AddCosignatureResponse{} }
+func (m *AddCosignatureResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddCosignatureResponse) ProtoMessage()               {}
+func (*AddCosignatureResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *AddCosignatureResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
 type GetEntryAndProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex int64 `protobuf:"varint,2,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -426,7 +847,7 @@ type GetEntryAndProofRequest struct {
 func (m *GetEntryAndProofRequest) Reset()                    { *m = GetEntryAndProofRequest{} }
 func (m *GetEntryAndProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofRequest) ProtoMessage()               {}
-func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
 
 type GetEntryAndProofResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -437,7 +858,7 @@ type GetEntryAndProofResponse struct {
 func (m *GetEntryAndProofResponse) Reset()                    { *m = GetEntryAndProofResponse{} }
 func (m *GetEntryAndProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofResponse) ProtoMessage()               {}
-func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
 
 func (m *GetEntryAndProofResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -475,7 +896,7 @@ type MapLeaf struct {
 func (m *MapLeaf) Reset()                    { *m = MapLeaf{} }
 func (m *MapLeaf) String() string            { return proto.CompactTextString(m) }
 func (*MapLeaf) ProtoMessage()               {}
-func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
 
 type KeyValue struct {
 	Key   []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
@@ -485,7 +906,7 @@ type KeyValue struct {
 func (m *KeyValue) Reset()                    { *m = KeyValue{} }
 func (m *KeyValue) String() string            { return proto.CompactTextString(m) }
 func (*KeyValue) ProtoMessage()               {}
-func (*KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *KeyValue) GetValue() *MapLeaf {
 	if m != nil {
@@ -495,14 +916,17 @@ func (m *KeyValue) GetValue() *MapLeaf {
 }
 
 type KeyValueInclusion struct {
-	KeyValue  *KeyValue `protobuf:"bytes,1,opt,name=key_value,json=keyValue" json:"key_value,omitempty"`
-	Inclusion [][]byte  `protobuf:"bytes,2,rep,name=inclusion,proto3" json:"inclusion,omitempty"`
+	// key_value holds the requested key. Its value is unset if the key has no value in the map.
+	KeyValue *KeyValue `protobuf:"bytes,1,opt,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	// inclusion proves the value, or that there's no value, starting with the sibling of the
+	// leaf and ending with the child of the root.
+	Inclusion [][]byte `protobuf:"bytes,2,rep,name=inclusion,proto3" json:"inclusion,omitempty"`
 }
 
 func (m *KeyValueInclusion) Reset()                    { *m = KeyValueInclusion{} }
 func (m *KeyValueInclusion) String() string            { return proto.CompactTextString(m) }
 func (*KeyValueInclusion) ProtoMessage()               {}
-func (*KeyValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+func (*KeyValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
 func (m *KeyValueInclusion) GetKeyValue() *KeyValue {
 	if m != nil {
@@ -520,18 +944,21 @@ type GetMapLeavesRequest struct {
 func (m *GetMapLeavesRequest) Reset()                    { *m = GetMapLeavesRequest{} }
 func (m *GetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetMapLeavesRequest) ProtoMessage()               {}
-func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
 
 type GetMapLeavesResponse struct {
 	Status   *TrillianApiStatus   `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
 	KeyValue []*KeyValueInclusion `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
 	MapRoot  *SignedMapRoot       `protobuf:"bytes,3,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+	// map_root_stale is set when the latest root was read and it's older than the map's
+	// max root age.
+	MapRootStale bool `protobuf:"varint,4,opt,name=map_root_stale,json=mapRootStale" json:"map_root_stale,omitempty"`
 }
 
 func (m *GetMapLeavesResponse) Reset()                    { *m = GetMapLeavesResponse{} }
 func (m *GetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetMapLeavesResponse) ProtoMessage()               {}
-func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
 
 func (m *GetMapLeavesResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -563,7 +990,7 @@ type SetMapLeavesRequest struct {
 func (m *SetMapLeavesRequest) Reset()                    { *m = SetMapLeavesRequest{} }
 func (m *SetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetMapLeavesRequest) ProtoMessage()               {}
-func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
 func (m *SetMapLeavesRequest) GetKeyValue() []*KeyValue {
 	if m != nil {
@@ -587,7 +1014,7 @@ type SetMapLeavesResponse struct {
 func (m *SetMapLeavesResponse) Reset()                    { *m = SetMapLeavesResponse{} }
 func (m *SetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetMapLeavesResponse) ProtoMessage()               {}
-func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
 
 func (m *SetMapLeavesResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -610,17 +1037,19 @@ type GetSignedMapRootRequest struct {
 func (m *GetSignedMapRootRequest) Reset()                    { *m = GetSignedMapRootRequest{} }
 func (m *GetSignedMapRootRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSignedMapRootRequest) ProtoMessage()               {}
-func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
 
 type GetSignedMapRootResponse struct {
 	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
 	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+	// map_root_stale is set when map_root is older than the map's max root age.
+	MapRootStale bool `protobuf:"varint,3,opt,name=map_root_stale,json=mapRootStale" json:"map_root_stale,omitempty"`
 }
 
 func (m *GetSignedMapRootResponse) Reset()                    { *m = GetSignedMapRootResponse{} }
 func (m *GetSignedMapRootResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSignedMapRootResponse) ProtoMessage()               {}
-func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
 
 func (m *GetSignedMapRootResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -636,112 +1065,807 @@ func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*TrillianApiStatus)(nil), "trillian.TrillianApiStatus")
-	proto.RegisterType((*LeafProto)(nil), "trillian.LeafProto")
-	proto.RegisterType((*NodeProto)(nil), "trillian.NodeProto")
-	proto.RegisterType((*ProofProto)(nil), "trillian.ProofProto")
-	proto.RegisterType((*QueueLeavesRequest)(nil), "trillian.QueueLeavesRequest")
-	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
-	proto.RegisterType((*GetInclusionProofRequest)(nil), "trillian.GetInclusionProofRequest")
-	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
-	proto.RegisterType((*GetInclusionProofByHashRequest)(nil), "trillian.GetInclusionProofByHashRequest")
-	proto.RegisterType((*GetInclusionProofByHashResponse)(nil), "trillian.GetInclusionProofByHashResponse")
-	proto.RegisterType((*GetConsistencyProofRequest)(nil), "trillian.GetConsistencyProofRequest")
-	proto.RegisterType((*GetConsistencyProofResponse)(nil), "trillian.GetConsistencyProofResponse")
-	proto.RegisterType((*GetLeavesByHashRequest)(nil), "trillian.GetLeavesByHashRequest")
-	proto.RegisterType((*GetLeavesByHashResponse)(nil), "trillian.GetLeavesByHashResponse")
-	proto.RegisterType((*GetLeavesByIndexRequest)(nil), "trillian.GetLeavesByIndexRequest")
-	proto.RegisterType((*GetLeavesByIndexResponse)(nil), "trillian.GetLeavesByIndexResponse")
-	proto.RegisterType((*GetSequencedLeafCountRequest)(nil), "trillian.GetSequencedLeafCountRequest")
-	proto.RegisterType((*GetSequencedLeafCountResponse)(nil), "trillian.GetSequencedLeafCountResponse")
-	proto.RegisterType((*GetLatestSignedLogRootRequest)(nil), "trillian.GetLatestSignedLogRootRequest")
-	proto.RegisterType((*GetLatestSignedLogRootResponse)(nil), "trillian.GetLatestSignedLogRootResponse")
-	proto.RegisterType((*GetEntryAndProofRequest)(nil), "trillian.GetEntryAndProofRequest")
-	proto.RegisterType((*GetEntryAndProofResponse)(nil), "trillian.GetEntryAndProofResponse")
-	proto.RegisterType((*MapLeaf)(nil), "trillian.MapLeaf")
-	proto.RegisterType((*KeyValue)(nil), "trillian.KeyValue")
-	proto.RegisterType((*KeyValueInclusion)(nil), "trillian.KeyValueInclusion")
-	proto.RegisterType((*GetMapLeavesRequest)(nil), "trillian.GetMapLeavesRequest")
-	proto.RegisterType((*GetMapLeavesResponse)(nil), "trillian.GetMapLeavesResponse")
-	proto.RegisterType((*SetMapLeavesRequest)(nil), "trillian.SetMapLeavesRequest")
-	proto.RegisterType((*SetMapLeavesResponse)(nil), "trillian.SetMapLeavesResponse")
-	proto.RegisterType((*GetSignedMapRootRequest)(nil), "trillian.GetSignedMapRootRequest")
-	proto.RegisterType((*GetSignedMapRootResponse)(nil), "trillian.GetSignedMapRootResponse")
-	proto.RegisterEnum("trillian.TrillianApiStatusCode", TrillianApiStatusCode_name, TrillianApiStatusCode_value)
+type GetSignedMapRootByRevisionRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	// revision is the map revision the root was created for.
+	Revision int64 `protobuf:"varint,2,opt,name=revision" json:"revision,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *GetSignedMapRootByRevisionRequest) Reset()                    { *m = GetSignedMapRootByRevisionRequest{} }
+func (m *GetSignedMapRootByRevisionRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetSignedMapRootByRevisionRequest) ProtoMessage()               {}
+func (*GetSignedMapRootByRevisionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion3
+type GetSignedMapRootByRevisionResponse struct {
+	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+}
 
-// Client API for TrillianLog service
+func (m *GetSignedMapRootByRevisionResponse) Reset()                    { *m = GetSignedMapRootByRevisionResponse{} }
+func (m *GetSignedMapRootByRevisionResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetSignedMapRootByRevisionResponse) ProtoMessage()               {}
+func (*GetSignedMapRootByRevisionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
 
-type TrillianLogClient interface {
-	// Corresponds to the LeafQueuer API
-	QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error)
-	// No direct equivalent at the storage level
-	GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error)
-	GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error)
-	GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error)
-	// Corresponds to the LogRootReader API
-	GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error)
-	// Corresponds to the LeafReader API
-	GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error)
-	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
-	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
-	GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error)
+func (m *GetSignedMapRootByRevisionResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
 }
 
-type trillianLogClient struct {
-	cc *grpc.ClientConn
+func (m *GetSignedMapRootByRevisionResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
 }
 
-func NewTrillianLogClient(cc *grpc.ClientConn) TrillianLogClient {
-	return &trillianLogClient{cc}
+type GetLeafHistoryRequest struct {
+	MapId int64  `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	Key   []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// start_revision and end_revision give the inclusive range of revisions to return
+	// values for. A negative end_revision means the latest revision of the map.
+	StartRevision int64 `protobuf:"varint,3,opt,name=start_revision,json=startRevision" json:"start_revision,omitempty"`
+	EndRevision   int64 `protobuf:"varint,4,opt,name=end_revision,json=endRevision" json:"end_revision,omitempty"`
 }
 
-func (c *trillianLogClient) QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error) {
-	out := new(QueueLeavesResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/QueueLeaves", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *GetLeafHistoryRequest) Reset()                    { *m = GetLeafHistoryRequest{} }
+func (m *GetLeafHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLeafHistoryRequest) ProtoMessage()               {}
+func (*GetLeafHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+// MapLeafRevision is a value of a key as it was set at a revision of the map.
+type MapLeafRevision struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision" json:"revision,omitempty"`
+	// key_value holds the value and its inclusion proof at revision.
+	KeyValue *KeyValueInclusion `protobuf:"bytes,2,opt,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	// map_root is the root the inclusion proof can be checked against.
+	MapRoot *SignedMapRoot `protobuf:"bytes,3,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
 }
 
-func (c *trillianLogClient) GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error) {
-	out := new(GetInclusionProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProof", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *MapLeafRevision) Reset()                    { *m = MapLeafRevision{} }
+func (m *MapLeafRevision) String() string            { return proto.CompactTextString(m) }
+func (*MapLeafRevision) ProtoMessage()               {}
+func (*MapLeafRevision) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *MapLeafRevision) GetKeyValue() *KeyValueInclusion {
+	if m != nil {
+		return m.KeyValue
 	}
-	return out, nil
+	return nil
 }
 
-func (c *trillianLogClient) GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error) {
-	out := new(GetInclusionProofByHashResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProofByHash", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *MapLeafRevision) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
 	}
-	return out, nil
+	return nil
 }
 
-func (c *trillianLogClient) GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error) {
-	out := new(GetConsistencyProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetConsistencyProof", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type GetLeafHistoryResponse struct {
+	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	History []*MapLeafRevision `protobuf:"bytes,2,rep,name=history" json:"history,omitempty"`
 }
 
-func (c *trillianLogClient) GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error) {
+func (m *GetLeafHistoryResponse) Reset()                    { *m = GetLeafHistoryResponse{} }
+func (m *GetLeafHistoryResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLeafHistoryResponse) ProtoMessage()               {}
+func (*GetLeafHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *GetLeafHistoryResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLeafHistoryResponse) GetHistory() []*MapLeafRevision {
+	if m != nil {
+		return m.History
+	}
+	return nil
+}
+
+// MapMutation is the set of values written to a map by one SetLeaves request. Replaying
+// the mutations of a map in revision order rebuilds its leaves at each revision.
+type MapMutation struct {
+	// map_revision is the revision of the map the values were written at.
+	MapRevision int64       `protobuf:"varint,1,opt,name=map_revision,json=mapRevision" json:"map_revision,omitempty"`
+	KeyValue    []*KeyValue `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
+}
+
+func (m *MapMutation) Reset()                    { *m = MapMutation{} }
+func (m *MapMutation) String() string            { return proto.CompactTextString(m) }
+func (*MapMutation) ProtoMessage()               {}
+func (*MapMutation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *MapMutation) GetKeyValue() []*KeyValue {
+	if m != nil {
+		return m.KeyValue
+	}
+	return nil
+}
+
+// StreamMutationsRequest asks for the mutations of a map from a revision onwards to be
+// streamed back. Only mutations up to the latest signed map root are returned.
+type StreamMutationsRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	// The revision of the first mutation to return.
+	StartRevision int64 `protobuf:"varint,2,opt,name=start_revision,json=startRevision" json:"start_revision,omitempty"`
+	// The maximum number of mutations in each response message. Zero means use the server
+	// default, and the server may also reduce this.
+	ChunkSize int32 `protobuf:"varint,3,opt,name=chunk_size,json=chunkSize" json:"chunk_size,omitempty"`
+}
+
+func (m *StreamMutationsRequest) Reset()                    { *m = StreamMutationsRequest{} }
+func (m *StreamMutationsRequest) String() string            { return proto.CompactTextString(m) }
+func (*StreamMutationsRequest) ProtoMessage()               {}
+func (*StreamMutationsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+// StreamMutationsResponse carries the next chunk of mutations, in revision order.
+type StreamMutationsResponse struct {
+	Status    *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Mutations []*MapMutation     `protobuf:"bytes,2,rep,name=mutations" json:"mutations,omitempty"`
+}
+
+func (m *StreamMutationsResponse) Reset()                    { *m = StreamMutationsResponse{} }
+func (m *StreamMutationsResponse) String() string            { return proto.CompactTextString(m) }
+func (*StreamMutationsResponse) ProtoMessage()               {}
+func (*StreamMutationsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+func (m *StreamMutationsResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StreamMutationsResponse) GetMutations() []*MapMutation {
+	if m != nil {
+		return m.Mutations
+	}
+	return nil
+}
+
+// GetMapLeavesByPrefixRequest asks for the values of the keys starting with key_prefix, in
+// key order. The keys are listed a page at a time.
+type GetMapLeavesByPrefixRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	// key_prefix is the prefix of the keys to return, all the keys are returned if it's empty.
+	KeyPrefix []byte `protobuf:"bytes,2,opt,name=key_prefix,json=keyPrefix,proto3" json:"key_prefix,omitempty"`
+	// The revision to read the map at, or -1 for the latest. Later pages are read at the
+	// revision of the first, so this is ignored when page_token is set.
+	Revision int64 `protobuf:"varint,3,opt,name=revision" json:"revision,omitempty"`
+	// include_proofs asks for the inclusion proof of each value to be returned.
+	IncludeProofs bool `protobuf:"varint,4,opt,name=include_proofs,json=includeProofs" json:"include_proofs,omitempty"`
+	// The maximum number of keys to look at for this page. Zero means use the server
+	// default, and the server may also reduce this.
+	PageSize int32 `protobuf:"varint,5,opt,name=page_size,json=pageSize" json:"page_size,omitempty"`
+	// page_token is the next_page_token of the previous response, unset for the first page.
+	// It must be sent with the same map_id and key_prefix.
+	PageToken []byte `protobuf:"bytes,6,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+}
+
+func (m *GetMapLeavesByPrefixRequest) Reset()                    { *m = GetMapLeavesByPrefixRequest{} }
+func (m *GetMapLeavesByPrefixRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeavesByPrefixRequest) ProtoMessage()               {}
+func (*GetMapLeavesByPrefixRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+type GetMapLeavesByPrefixResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// key_value holds the keys of the page which have values at the revision, in key order.
+	// A page can hold fewer than page_size values, even none, and still not be the last.
+	KeyValue []*KeyValueInclusion `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	// map_root is the root of the revision read, which the proofs can be checked against.
+	MapRoot *SignedMapRoot `protobuf:"bytes,3,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+	// next_page_token is set when there are more keys to look at, and is sent in the
+	// request for the next page.
+	NextPageToken []byte `protobuf:"bytes,4,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// map_root_stale is set when the latest root was read and it's older than the map's
+	// max root age.
+	MapRootStale bool `protobuf:"varint,5,opt,name=map_root_stale,json=mapRootStale" json:"map_root_stale,omitempty"`
+}
+
+func (m *GetMapLeavesByPrefixResponse) Reset()                    { *m = GetMapLeavesByPrefixResponse{} }
+func (m *GetMapLeavesByPrefixResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeavesByPrefixResponse) ProtoMessage()               {}
+func (*GetMapLeavesByPrefixResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+func (m *GetMapLeavesByPrefixResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetMapLeavesByPrefixResponse) GetKeyValue() []*KeyValueInclusion {
+	if m != nil {
+		return m.KeyValue
+	}
+	return nil
+}
+
+func (m *GetMapLeavesByPrefixResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type CreateTreeRequest struct {
+	// tree holds the settings for the new tree. tree_id, tree_state and the timestamps
+	// are assigned by the server and must not be set.
+	Tree *Tree `protobuf:"bytes,1,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *CreateTreeRequest) Reset()                    { *m = CreateTreeRequest{} }
+func (m *CreateTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateTreeRequest) ProtoMessage()               {}
+func (*CreateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+func (m *CreateTreeRequest) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type CreateTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *CreateTreeResponse) Reset()                    { *m = CreateTreeResponse{} }
+func (m *CreateTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateTreeResponse) ProtoMessage()               {}
+func (*CreateTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *CreateTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *CreateTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type ListTreesRequest struct {
+	// show_deleted includes trees that have been soft deleted in the response.
+	ShowDeleted bool `protobuf:"varint,1,opt,name=show_deleted,json=showDeleted" json:"show_deleted,omitempty"`
+}
+
+func (m *ListTreesRequest) Reset()                    { *m = ListTreesRequest{} }
+func (m *ListTreesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListTreesRequest) ProtoMessage()               {}
+func (*ListTreesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{54} }
+
+type ListTreesResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   []*Tree            `protobuf:"bytes,2,rep,name=tree" json:"tree,omitempty"`
+}
+
+func (m *ListTreesResponse) Reset()                    { *m = ListTreesResponse{} }
+func (m *ListTreesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListTreesResponse) ProtoMessage()               {}
+func (*ListTreesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{55} }
+
+func (m *ListTreesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ListTreesResponse) GetTree() []*Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type GetTreeRequest struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *GetTreeRequest) Reset()                    { *m = GetTreeRequest{} }
+func (m *GetTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeRequest) ProtoMessage()               {}
+func (*GetTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{56} }
+
+type GetTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *GetTreeResponse) Reset()                    { *m = GetTreeResponse{} }
+func (m *GetTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeResponse) ProtoMessage()               {}
+func (*GetTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{57} }
+
+func (m *GetTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type UpdateTreeRequest struct {
+	// tree identifies the tree to update by its tree_id and holds the new values for
+	// the mutable settings, display_name and description. All other fields are ignored.
+	Tree *Tree `protobuf:"bytes,1,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *UpdateTreeRequest) Reset()                    { *m = UpdateTreeRequest{} }
+func (m *UpdateTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdateTreeRequest) ProtoMessage()               {}
+func (*UpdateTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{58} }
+
+func (m *UpdateTreeRequest) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type UpdateTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *UpdateTreeResponse) Reset()                    { *m = UpdateTreeResponse{} }
+func (m *UpdateTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*UpdateTreeResponse) ProtoMessage()               {}
+func (*UpdateTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{59} }
+
+func (m *UpdateTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *UpdateTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type FreezeTreeRequest struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *FreezeTreeRequest) Reset()                    { *m = FreezeTreeRequest{} }
+func (m *FreezeTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*FreezeTreeRequest) ProtoMessage()               {}
+func (*FreezeTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{60} }
+
+type FreezeTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *FreezeTreeResponse) Reset()                    { *m = FreezeTreeResponse{} }
+func (m *FreezeTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*FreezeTreeResponse) ProtoMessage()               {}
+func (*FreezeTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{61} }
+
+func (m *FreezeTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *FreezeTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type DeleteTreeRequest struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *DeleteTreeRequest) Reset()                    { *m = DeleteTreeRequest{} }
+func (m *DeleteTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteTreeRequest) ProtoMessage()               {}
+func (*DeleteTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{62} }
+
+type DeleteTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *DeleteTreeResponse) Reset()                    { *m = DeleteTreeResponse{} }
+func (m *DeleteTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteTreeResponse) ProtoMessage()               {}
+func (*DeleteTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{63} }
+
+func (m *DeleteTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *DeleteTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+type UndeleteTreeRequest struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *UndeleteTreeRequest) Reset()                    { *m = UndeleteTreeRequest{} }
+func (m *UndeleteTreeRequest) String() string            { return proto.CompactTextString(m) }
+func (*UndeleteTreeRequest) ProtoMessage()               {}
+func (*UndeleteTreeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{64} }
+
+type UndeleteTreeResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tree   *Tree              `protobuf:"bytes,2,opt,name=tree" json:"tree,omitempty"`
+}
+
+func (m *UndeleteTreeResponse) Reset()                    { *m = UndeleteTreeResponse{} }
+func (m *UndeleteTreeResponse) String() string            { return proto.CompactTextString(m) }
+func (*UndeleteTreeResponse) ProtoMessage()               {}
+func (*UndeleteTreeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{65} }
+
+func (m *UndeleteTreeResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *UndeleteTreeResponse) GetTree() *Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+// TreeUsage totals what clients have used a tree for, so that operators can bill or limit
+// its owner.
+type TreeUsage struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// requests is the number of RPCs made for the tree.
+	Requests int64 `protobuf:"varint,2,opt,name=requests" json:"requests,omitempty"`
+	// bytes_in and bytes_out are the encoded sizes of the requests and their responses.
+	BytesIn  int64 `protobuf:"varint,3,opt,name=bytes_in,json=bytesIn" json:"bytes_in,omitempty"`
+	BytesOut int64 `protobuf:"varint,4,opt,name=bytes_out,json=bytesOut" json:"bytes_out,omitempty"`
+	// leaves_queued is the number of leaves submitted to the tree, including duplicates.
+	LeavesQueued int64 `protobuf:"varint,5,opt,name=leaves_queued,json=leavesQueued" json:"leaves_queued,omitempty"`
+	// update_time_millis is when usage was last added to storage.
+	UpdateTimeMillis int64 `protobuf:"varint,6,opt,name=update_time_millis,json=updateTimeMillis" json:"update_time_millis,omitempty"`
+	// recent_qps is the rate of requests for the tree handled by the server that answered,
+	// over its last accounting period. It isn't stored.
+	RecentQps float64 `protobuf:"fixed64,7,opt,name=recent_qps,json=recentQps" json:"recent_qps,omitempty"`
+}
+
+func (m *TreeUsage) Reset()                    { *m = TreeUsage{} }
+func (m *TreeUsage) String() string            { return proto.CompactTextString(m) }
+func (*TreeUsage) ProtoMessage()               {}
+func (*TreeUsage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{66} }
+
+type GetTreeUsageRequest struct {
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *GetTreeUsageRequest) Reset()                    { *m = GetTreeUsageRequest{} }
+func (m *GetTreeUsageRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeUsageRequest) ProtoMessage()               {}
+func (*GetTreeUsageRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{67} }
+
+type GetTreeUsageResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Usage  *TreeUsage         `protobuf:"bytes,2,opt,name=usage" json:"usage,omitempty"`
+}
+
+func (m *GetTreeUsageResponse) Reset()                    { *m = GetTreeUsageResponse{} }
+func (m *GetTreeUsageResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeUsageResponse) ProtoMessage()               {}
+func (*GetTreeUsageResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{68} }
+
+func (m *GetTreeUsageResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetTreeUsageResponse) GetUsage() *TreeUsage {
+	if m != nil {
+		return m.Usage
+	}
+	return nil
+}
+
+// AuditEvent records an administrative or signing action taken by a server, for operators
+// who need to show who did what to their trees.
+type AuditEvent struct {
+	// index is the position of the event in the audit trail, assigned by the trail.
+	Index          int64          `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	TimestampNanos int64          `protobuf:"varint,2,opt,name=timestamp_nanos,json=timestampNanos" json:"timestamp_nanos,omitempty"`
+	Type           AuditEventType `protobuf:"varint,3,opt,name=type,enum=trillian.AuditEventType" json:"type,omitempty"`
+	// tree_id is the tree the action was for, zero if it wasn't for a single tree.
+	TreeId int64 `protobuf:"varint,4,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+	// actor is the client that made an RPC, empty for actions the server takes itself.
+	Actor string `protobuf:"bytes,5,opt,name=actor" json:"actor,omitempty"`
+	// method is the full name of the RPC, for ADMIN_RPC events.
+	Method string `protobuf:"bytes,6,opt,name=method" json:"method,omitempty"`
+	// error describes why the action failed, empty if it succeeded.
+	Error string `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+	// key_id and digest are the key used and what was signed, for KEY_USED events.
+	KeyId  []byte `protobuf:"bytes,8,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Digest []byte `protobuf:"bytes,9,opt,name=digest,proto3" json:"digest,omitempty"`
+	// tree_size, root_hash and tree_revision describe the root, for LOG_ROOT_SIGNED events.
+	TreeSize     int64  `protobuf:"varint,10,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
+	RootHash     []byte `protobuf:"bytes,11,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	TreeRevision int64  `protobuf:"varint,12,opt,name=tree_revision,json=treeRevision" json:"tree_revision,omitempty"`
+}
+
+func (m *AuditEvent) Reset()                    { *m = AuditEvent{} }
+func (m *AuditEvent) String() string            { return proto.CompactTextString(m) }
+func (*AuditEvent) ProtoMessage()               {}
+func (*AuditEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{69} }
+
+type ListAuditEventsRequest struct {
+	// start_index is the index of the first event to consider.
+	StartIndex int64 `protobuf:"varint,1,opt,name=start_index,json=startIndex" json:"start_index,omitempty"`
+	// max_events limits the number of events returned, the server picks a limit if it's
+	// zero.
+	MaxEvents int32 `protobuf:"varint,2,opt,name=max_events,json=maxEvents" json:"max_events,omitempty"`
+	// tree_id only returns the events for one tree if it's set.
+	TreeId int64 `protobuf:"varint,3,opt,name=tree_id,json=treeId" json:"tree_id,omitempty"`
+}
+
+func (m *ListAuditEventsRequest) Reset()                    { *m = ListAuditEventsRequest{} }
+func (m *ListAuditEventsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditEventsRequest) ProtoMessage()               {}
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{70} }
+
+type ListAuditEventsResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Events []*AuditEvent      `protobuf:"bytes,2,rep,name=events" json:"events,omitempty"`
+	// next_index is the start_index to continue from. It's the same as the request's if
+	// there are no more events yet.
+	NextIndex int64 `protobuf:"varint,3,opt,name=next_index,json=nextIndex" json:"next_index,omitempty"`
+}
+
+func (m *ListAuditEventsResponse) Reset()                    { *m = ListAuditEventsResponse{} }
+func (m *ListAuditEventsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditEventsResponse) ProtoMessage()               {}
+func (*ListAuditEventsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{71} }
+
+func (m *ListAuditEventsResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ListAuditEventsResponse) GetEvents() []*AuditEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+type ReloadConfigRequest struct {
+}
+
+func (m *ReloadConfigRequest) Reset()                    { *m = ReloadConfigRequest{} }
+func (m *ReloadConfigRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReloadConfigRequest) ProtoMessage()               {}
+func (*ReloadConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{72} }
+
+type ReloadConfigResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *ReloadConfigResponse) Reset()                    { *m = ReloadConfigResponse{} }
+func (m *ReloadConfigResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReloadConfigResponse) ProtoMessage()               {}
+func (*ReloadConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{73} }
+
+func (m *ReloadConfigResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*TrillianApiStatus)(nil), "trillian.TrillianApiStatus")
+	proto.RegisterType((*LeafProto)(nil), "trillian.LeafProto")
+	proto.RegisterType((*NodeProto)(nil), "trillian.NodeProto")
+	proto.RegisterType((*ProofProto)(nil), "trillian.ProofProto")
+	proto.RegisterType((*QueueLeavesRequest)(nil), "trillian.QueueLeavesRequest")
+	proto.RegisterType((*QueuedLeaf)(nil), "trillian.QueuedLeaf")
+	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
+	proto.RegisterType((*AddSequencedLeavesRequest)(nil), "trillian.AddSequencedLeavesRequest")
+	proto.RegisterType((*AddSequencedLeavesResponse)(nil), "trillian.AddSequencedLeavesResponse")
+	proto.RegisterType((*GetInclusionProofRequest)(nil), "trillian.GetInclusionProofRequest")
+	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
+	proto.RegisterType((*GetInclusionProofByHashRequest)(nil), "trillian.GetInclusionProofByHashRequest")
+	proto.RegisterType((*GetInclusionProofByHashResponse)(nil), "trillian.GetInclusionProofByHashResponse")
+	proto.RegisterType((*GetConsistencyProofRequest)(nil), "trillian.GetConsistencyProofRequest")
+	proto.RegisterType((*GetConsistencyProofResponse)(nil), "trillian.GetConsistencyProofResponse")
+	proto.RegisterType((*GetLeavesByHashRequest)(nil), "trillian.GetLeavesByHashRequest")
+	proto.RegisterType((*GetLeavesByHashResponse)(nil), "trillian.GetLeavesByHashResponse")
+	proto.RegisterType((*GetLeavesByIdentityHashRequest)(nil), "trillian.GetLeavesByIdentityHashRequest")
+	proto.RegisterType((*GetLeavesByIdentityHashResponse)(nil), "trillian.GetLeavesByIdentityHashResponse")
+	proto.RegisterType((*GetLeavesByIndexRequest)(nil), "trillian.GetLeavesByIndexRequest")
+	proto.RegisterType((*GetLeavesByIndexResponse)(nil), "trillian.GetLeavesByIndexResponse")
+	proto.RegisterType((*GetLeavesByRangeRequest)(nil), "trillian.GetLeavesByRangeRequest")
+	proto.RegisterType((*GetLeavesByRangeResponse)(nil), "trillian.GetLeavesByRangeResponse")
+	proto.RegisterType((*StreamLeavesRequest)(nil), "trillian.StreamLeavesRequest")
+	proto.RegisterType((*StreamLeavesResponse)(nil), "trillian.StreamLeavesResponse")
+	proto.RegisterType((*GetSequencedLeafCountRequest)(nil), "trillian.GetSequencedLeafCountRequest")
+	proto.RegisterType((*GetSequencedLeafCountResponse)(nil), "trillian.GetSequencedLeafCountResponse")
+	proto.RegisterType((*GetLatestSignedLogRootRequest)(nil), "trillian.GetLatestSignedLogRootRequest")
+	proto.RegisterType((*GetLatestSignedLogRootResponse)(nil), "trillian.GetLatestSignedLogRootResponse")
+	proto.RegisterType((*AddCosignatureRequest)(nil), "trillian.AddCosignatureRequest")
+	proto.RegisterType((*AddCosignatureResponse)(nil), "trillian.AddCosignatureResponse")
+	proto.RegisterType((*GetEntryAndProofRequest)(nil), "trillian.GetEntryAndProofRequest")
+	proto.RegisterType((*GetEntryAndProofResponse)(nil), "trillian.GetEntryAndProofResponse")
+	proto.RegisterType((*MapLeaf)(nil), "trillian.MapLeaf")
+	proto.RegisterType((*KeyValue)(nil), "trillian.KeyValue")
+	proto.RegisterType((*KeyValueInclusion)(nil), "trillian.KeyValueInclusion")
+	proto.RegisterType((*GetMapLeavesRequest)(nil), "trillian.GetMapLeavesRequest")
+	proto.RegisterType((*GetMapLeavesResponse)(nil), "trillian.GetMapLeavesResponse")
+	proto.RegisterType((*SetMapLeavesRequest)(nil), "trillian.SetMapLeavesRequest")
+	proto.RegisterType((*SetMapLeavesResponse)(nil), "trillian.SetMapLeavesResponse")
+	proto.RegisterType((*GetSignedMapRootRequest)(nil), "trillian.GetSignedMapRootRequest")
+	proto.RegisterType((*GetSignedMapRootResponse)(nil), "trillian.GetSignedMapRootResponse")
+	proto.RegisterType((*GetSignedMapRootByRevisionRequest)(nil), "trillian.GetSignedMapRootByRevisionRequest")
+	proto.RegisterType((*GetSignedMapRootByRevisionResponse)(nil), "trillian.GetSignedMapRootByRevisionResponse")
+	proto.RegisterType((*GetLeafHistoryRequest)(nil), "trillian.GetLeafHistoryRequest")
+	proto.RegisterType((*MapLeafRevision)(nil), "trillian.MapLeafRevision")
+	proto.RegisterType((*GetLeafHistoryResponse)(nil), "trillian.GetLeafHistoryResponse")
+	proto.RegisterType((*MapMutation)(nil), "trillian.MapMutation")
+	proto.RegisterType((*StreamMutationsRequest)(nil), "trillian.StreamMutationsRequest")
+	proto.RegisterType((*StreamMutationsResponse)(nil), "trillian.StreamMutationsResponse")
+	proto.RegisterType((*GetMapLeavesByPrefixRequest)(nil), "trillian.GetMapLeavesByPrefixRequest")
+	proto.RegisterType((*GetMapLeavesByPrefixResponse)(nil), "trillian.GetMapLeavesByPrefixResponse")
+	proto.RegisterType((*CreateTreeRequest)(nil), "trillian.CreateTreeRequest")
+	proto.RegisterType((*CreateTreeResponse)(nil), "trillian.CreateTreeResponse")
+	proto.RegisterType((*ListTreesRequest)(nil), "trillian.ListTreesRequest")
+	proto.RegisterType((*ListTreesResponse)(nil), "trillian.ListTreesResponse")
+	proto.RegisterType((*GetTreeRequest)(nil), "trillian.GetTreeRequest")
+	proto.RegisterType((*GetTreeResponse)(nil), "trillian.GetTreeResponse")
+	proto.RegisterType((*UpdateTreeRequest)(nil), "trillian.UpdateTreeRequest")
+	proto.RegisterType((*UpdateTreeResponse)(nil), "trillian.UpdateTreeResponse")
+	proto.RegisterType((*FreezeTreeRequest)(nil), "trillian.FreezeTreeRequest")
+	proto.RegisterType((*FreezeTreeResponse)(nil), "trillian.FreezeTreeResponse")
+	proto.RegisterType((*DeleteTreeRequest)(nil), "trillian.DeleteTreeRequest")
+	proto.RegisterType((*DeleteTreeResponse)(nil), "trillian.DeleteTreeResponse")
+	proto.RegisterType((*UndeleteTreeRequest)(nil), "trillian.UndeleteTreeRequest")
+	proto.RegisterType((*UndeleteTreeResponse)(nil), "trillian.UndeleteTreeResponse")
+	proto.RegisterType((*TreeUsage)(nil), "trillian.TreeUsage")
+	proto.RegisterType((*GetTreeUsageRequest)(nil), "trillian.GetTreeUsageRequest")
+	proto.RegisterType((*GetTreeUsageResponse)(nil), "trillian.GetTreeUsageResponse")
+	proto.RegisterType((*AuditEvent)(nil), "trillian.AuditEvent")
+	proto.RegisterType((*ListAuditEventsRequest)(nil), "trillian.ListAuditEventsRequest")
+	proto.RegisterType((*ListAuditEventsResponse)(nil), "trillian.ListAuditEventsResponse")
+	proto.RegisterType((*ReloadConfigRequest)(nil), "trillian.ReloadConfigRequest")
+	proto.RegisterType((*ReloadConfigResponse)(nil), "trillian.ReloadConfigResponse")
+	proto.RegisterEnum("trillian.TrillianApiStatusCode", TrillianApiStatusCode_name, TrillianApiStatusCode_value)
+	proto.RegisterEnum("trillian.QueuedLeafStatus", QueuedLeafStatus_name, QueuedLeafStatus_value)
+	proto.RegisterEnum("trillian.LeafRejectionCode", LeafRejectionCode_name, LeafRejectionCode_value)
+	proto.RegisterEnum("trillian.AuditEventType", AuditEventType_name, AuditEventType_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion3
+
+// Client API for TrillianLog service
+
+type TrillianLogClient interface {
+	// Corresponds to the LeafQueuer API
+	QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error)
+	AddSequencedLeaves(ctx context.Context, in *AddSequencedLeavesRequest, opts ...grpc.CallOption) (*AddSequencedLeavesResponse, error)
+	// No direct equivalent at the storage level
+	GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error)
+	GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error)
+	GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error)
+	// Corresponds to the LogRootReader API
+	GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error)
+	// Witnesses submit their cosignatures over signed log roots with this. Only cosignatures
+	// from witnesses known to the log and that verify against a root it has signed are kept.
+	AddCosignature(ctx context.Context, in *AddCosignatureRequest, opts ...grpc.CallOption) (*AddCosignatureResponse, error)
+	// Corresponds to the LeafReader API
+	GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error)
+	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
+	// Reads a range of leaves a page at a time, for bulk readers that can't stream.
+	GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error)
+	// Streams a range of leaves in chunks, for clients that need to fetch many leaves.
+	// It isn't served by the REST gateway, GetLeavesByRange pages through leaves instead.
+	StreamLeaves(ctx context.Context, in *StreamLeavesRequest, opts ...grpc.CallOption) (TrillianLog_StreamLeavesClient, error)
+	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
+	// Finds the sequenced leaves that were queued with any of the identity hashes, so that
+	// applications can return the existing entry for a resubmission without scanning the log.
+	GetLeavesByIdentityHash(ctx context.Context, in *GetLeavesByIdentityHashRequest, opts ...grpc.CallOption) (*GetLeavesByIdentityHashResponse, error)
+	GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error)
+}
+
+type trillianLogClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrillianLogClient(cc *grpc.ClientConn) TrillianLogClient {
+	return &trillianLogClient{cc}
+}
+
+func (c *trillianLogClient) QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error) {
+	out := new(QueueLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/QueueLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) AddSequencedLeaves(ctx context.Context, in *AddSequencedLeavesRequest, opts ...grpc.CallOption) (*AddSequencedLeavesResponse, error) {
+	out := new(AddSequencedLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/AddSequencedLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error) {
+	out := new(GetInclusionProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error) {
+	out := new(GetInclusionProofByHashResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProofByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error) {
+	out := new(GetConsistencyProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetConsistencyProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error) {
 	out := new(GetLatestSignedLogRootResponse)
 	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLatestSignedLogRoot", in, out, c.cc, opts...)
 	if err != nil {
@@ -750,395 +1874,1142 @@ func (c *trillianLogClient) GetLatestSignedLogRoot(ctx context.Context, in *GetL
 	return out, nil
 }
 
-func (c *trillianLogClient) GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
-	out := new(GetSequencedLeafCountResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetSequencedLeafCount", in, out, c.cc, opts...)
+func (c *trillianLogClient) AddCosignature(ctx context.Context, in *AddCosignatureRequest, opts ...grpc.CallOption) (*AddCosignatureResponse, error) {
+	out := new(AddCosignatureResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/AddCosignature", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
+	out := new(GetSequencedLeafCountResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetSequencedLeafCount", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
+	out := new(GetLeavesByIndexResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndex", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error) {
+	out := new(GetLeavesByRangeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByRange", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) StreamLeaves(ctx context.Context, in *StreamLeavesRequest, opts ...grpc.CallOption) (TrillianLog_StreamLeavesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TrillianLog_serviceDesc.Streams[0], c.cc, "/trillian.TrillianLog/StreamLeaves", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogStreamLeavesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_StreamLeavesClient interface {
+	Recv() (*StreamLeavesResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogStreamLeavesClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogStreamLeavesClient) Recv() (*StreamLeavesResponse, error) {
+	m := new(StreamLeavesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
+	out := new(GetLeavesByHashResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByIdentityHash(ctx context.Context, in *GetLeavesByIdentityHashRequest, opts ...grpc.CallOption) (*GetLeavesByIdentityHashResponse, error) {
+	out := new(GetLeavesByIdentityHashResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIdentityHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error) {
+	out := new(GetEntryAndProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetEntryAndProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TrillianLog service
+
+type TrillianLogServer interface {
+	// Corresponds to the LeafQueuer API
+	QueueLeaves(context.Context, *QueueLeavesRequest) (*QueueLeavesResponse, error)
+	AddSequencedLeaves(context.Context, *AddSequencedLeavesRequest) (*AddSequencedLeavesResponse, error)
+	// No direct equivalent at the storage level
+	GetInclusionProof(context.Context, *GetInclusionProofRequest) (*GetInclusionProofResponse, error)
+	GetInclusionProofByHash(context.Context, *GetInclusionProofByHashRequest) (*GetInclusionProofByHashResponse, error)
+	GetConsistencyProof(context.Context, *GetConsistencyProofRequest) (*GetConsistencyProofResponse, error)
+	// Corresponds to the LogRootReader API
+	GetLatestSignedLogRoot(context.Context, *GetLatestSignedLogRootRequest) (*GetLatestSignedLogRootResponse, error)
+	// Witnesses submit their cosignatures over signed log roots with this. Only cosignatures
+	// from witnesses known to the log and that verify against a root it has signed are kept.
+	AddCosignature(context.Context, *AddCosignatureRequest) (*AddCosignatureResponse, error)
+	// Corresponds to the LeafReader API
+	GetSequencedLeafCount(context.Context, *GetSequencedLeafCountRequest) (*GetSequencedLeafCountResponse, error)
+	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
+	// Reads a range of leaves a page at a time, for bulk readers that can't stream.
+	GetLeavesByRange(context.Context, *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error)
+	// Streams a range of leaves in chunks, for clients that need to fetch many leaves.
+	// It isn't served by the REST gateway, GetLeavesByRange pages through leaves instead.
+	StreamLeaves(*StreamLeavesRequest, TrillianLog_StreamLeavesServer) error
+	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
+	// Finds the sequenced leaves that were queued with any of the identity hashes, so that
+	// applications can return the existing entry for a resubmission without scanning the log.
+	GetLeavesByIdentityHash(context.Context, *GetLeavesByIdentityHashRequest) (*GetLeavesByIdentityHashResponse, error)
+	GetEntryAndProof(context.Context, *GetEntryAndProofRequest) (*GetEntryAndProofResponse, error)
+}
+
+func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
+	s.RegisterService(&_TrillianLog_serviceDesc, srv)
+}
+
+func _TrillianLog_QueueLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueueLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).QueueLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/QueueLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).QueueLeaves(ctx, req.(*QueueLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_AddSequencedLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddSequencedLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddSequencedLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddSequencedLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddSequencedLeaves(ctx, req.(*AddSequencedLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetInclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetInclusionProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetInclusionProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetInclusionProof(ctx, req.(*GetInclusionProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetInclusionProofByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetInclusionProofByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, req.(*GetInclusionProofByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConsistencyProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetConsistencyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetConsistencyProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetConsistencyProof(ctx, req.(*GetConsistencyProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLatestSignedLogRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestSignedLogRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, req.(*GetLatestSignedLogRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_AddCosignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCosignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddCosignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddCosignature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddCosignature(ctx, req.(*AddCosignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetSequencedLeafCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSequencedLeafCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetSequencedLeafCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, req.(*GetSequencedLeafCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByIndex",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, req.(*GetLeavesByIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByRange(ctx, req.(*GetLeavesByRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_StreamLeaves_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLeavesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).StreamLeaves(m, &trillianLogStreamLeavesServer{stream})
+}
+
+type TrillianLog_StreamLeavesServer interface {
+	Send(*StreamLeavesResponse) error
+	grpc.ServerStream
+}
+
+type trillianLogStreamLeavesServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogStreamLeavesServer) Send(m *StreamLeavesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, req.(*GetLeavesByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByIdentityHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByIdentityHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByIdentityHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByIdentityHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByIdentityHash(ctx, req.(*GetLeavesByIdentityHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetEntryAndProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntryAndProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetEntryAndProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetEntryAndProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetEntryAndProof(ctx, req.(*GetEntryAndProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TrillianLog_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianLog",
+	HandlerType: (*TrillianLogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueueLeaves",
+			Handler:    _TrillianLog_QueueLeaves_Handler,
+		},
+		{
+			MethodName: "AddSequencedLeaves",
+			Handler:    _TrillianLog_AddSequencedLeaves_Handler,
+		},
+		{
+			MethodName: "GetInclusionProof",
+			Handler:    _TrillianLog_GetInclusionProof_Handler,
+		},
+		{
+			MethodName: "GetInclusionProofByHash",
+			Handler:    _TrillianLog_GetInclusionProofByHash_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProof",
+			Handler:    _TrillianLog_GetConsistencyProof_Handler,
+		},
+		{
+			MethodName: "GetLatestSignedLogRoot",
+			Handler:    _TrillianLog_GetLatestSignedLogRoot_Handler,
+		},
+		{
+			MethodName: "AddCosignature",
+			Handler:    _TrillianLog_AddCosignature_Handler,
+		},
+		{
+			MethodName: "GetSequencedLeafCount",
+			Handler:    _TrillianLog_GetSequencedLeafCount_Handler,
+		},
+		{
+			MethodName: "GetLeavesByIndex",
+			Handler:    _TrillianLog_GetLeavesByIndex_Handler,
+		},
+		{
+			MethodName: "GetLeavesByRange",
+			Handler:    _TrillianLog_GetLeavesByRange_Handler,
+		},
+		{
+			MethodName: "GetLeavesByHash",
+			Handler:    _TrillianLog_GetLeavesByHash_Handler,
+		},
+		{
+			MethodName: "GetLeavesByIdentityHash",
+			Handler:    _TrillianLog_GetLeavesByIdentityHash_Handler,
+		},
+		{
+			MethodName: "GetEntryAndProof",
+			Handler:    _TrillianLog_GetEntryAndProof_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLeaves",
+			Handler:       _TrillianLog_StreamLeaves_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: fileDescriptor0,
+}
+
+// Client API for TrillianMap service
+
+type TrillianMapClient interface {
+	GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
+	SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error)
+	GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error)
+	// GetSignedMapRootByRevision returns the root created by the map at an earlier revision,
+	// so values and proofs from GetLeaves at that revision can be checked against it.
+	GetSignedMapRootByRevision(ctx context.Context, in *GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*GetSignedMapRootByRevisionResponse, error)
+	// GetLeafHistory returns the values a key was set to over a range of revisions, with
+	// the proofs of inclusion at each of them.
+	GetLeafHistory(ctx context.Context, in *GetLeafHistoryRequest, opts ...grpc.CallOption) (*GetLeafHistoryResponse, error)
+	// StreamMutations returns the values set by each SetLeaves request from a revision
+	// onwards, so the map can be rebuilt and its roots checked independently. It isn't
+	// served by the REST gateway.
+	StreamMutations(ctx context.Context, in *StreamMutationsRequest, opts ...grpc.CallOption) (TrillianMap_StreamMutationsClient, error)
+	// GetLeavesByPrefix returns the values of the keys starting with a prefix, for
+	// applications that keep hierarchical keys in the map.
+	GetLeavesByPrefix(ctx context.Context, in *GetMapLeavesByPrefixRequest, opts ...grpc.CallOption) (*GetMapLeavesByPrefixResponse, error)
+}
+
+type trillianMapClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrillianMapClient(cc *grpc.ClientConn) TrillianMapClient {
+	return &trillianMapClient{cc}
+}
+
+func (c *trillianMapClient) GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error) {
+	out := new(GetMapLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error) {
+	out := new(SetMapLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/SetLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error) {
+	out := new(GetSignedMapRootResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetSignedMapRoot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetSignedMapRootByRevision(ctx context.Context, in *GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*GetSignedMapRootByRevisionResponse, error) {
+	out := new(GetSignedMapRootByRevisionResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetSignedMapRootByRevision", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetLeafHistory(ctx context.Context, in *GetLeafHistoryRequest, opts ...grpc.CallOption) (*GetLeafHistoryResponse, error) {
+	out := new(GetLeafHistoryResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetLeafHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) StreamMutations(ctx context.Context, in *StreamMutationsRequest, opts ...grpc.CallOption) (TrillianMap_StreamMutationsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TrillianMap_serviceDesc.Streams[0], c.cc, "/trillian.TrillianMap/StreamMutations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianMapStreamMutationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianMap_StreamMutationsClient interface {
+	Recv() (*StreamMutationsResponse, error)
+	grpc.ClientStream
+}
+
+type trillianMapStreamMutationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianMapStreamMutationsClient) Recv() (*StreamMutationsResponse, error) {
+	m := new(StreamMutationsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianMapClient) GetLeavesByPrefix(ctx context.Context, in *GetMapLeavesByPrefixRequest, opts ...grpc.CallOption) (*GetMapLeavesByPrefixResponse, error) {
+	out := new(GetMapLeavesByPrefixResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetLeavesByPrefix", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TrillianMap service
+
+type TrillianMapServer interface {
+	GetLeaves(context.Context, *GetMapLeavesRequest) (*GetMapLeavesResponse, error)
+	SetLeaves(context.Context, *SetMapLeavesRequest) (*SetMapLeavesResponse, error)
+	GetSignedMapRoot(context.Context, *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error)
+	// GetSignedMapRootByRevision returns the root created by the map at an earlier revision,
+	// so values and proofs from GetLeaves at that revision can be checked against it.
+	GetSignedMapRootByRevision(context.Context, *GetSignedMapRootByRevisionRequest) (*GetSignedMapRootByRevisionResponse, error)
+	// GetLeafHistory returns the values a key was set to over a range of revisions, with
+	// the proofs of inclusion at each of them.
+	GetLeafHistory(context.Context, *GetLeafHistoryRequest) (*GetLeafHistoryResponse, error)
+	// StreamMutations returns the values set by each SetLeaves request from a revision
+	// onwards, so the map can be rebuilt and its roots checked independently. It isn't
+	// served by the REST gateway.
+	StreamMutations(*StreamMutationsRequest, TrillianMap_StreamMutationsServer) error
+	// GetLeavesByPrefix returns the values of the keys starting with a prefix, for
+	// applications that keep hierarchical keys in the map.
+	GetLeavesByPrefix(context.Context, *GetMapLeavesByPrefixRequest) (*GetMapLeavesByPrefixResponse, error)
+}
+
+func RegisterTrillianMapServer(s *grpc.Server, srv TrillianMapServer) {
+	s.RegisterService(&_TrillianMap_serviceDesc, srv)
+}
+
+func _TrillianMap_GetLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeaves(ctx, req.(*GetMapLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_SetLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMapLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).SetLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/SetLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).SetLeaves(ctx, req.(*SetMapLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetSignedMapRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignedMapRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetSignedMapRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetSignedMapRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetSignedMapRoot(ctx, req.(*GetSignedMapRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetSignedMapRootByRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSignedMapRootByRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetSignedMapRootByRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetSignedMapRootByRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetSignedMapRootByRevision(ctx, req.(*GetSignedMapRootByRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetLeafHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeafHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeafHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, req.(*GetLeafHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_StreamMutations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMutationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianMapServer).StreamMutations(m, &trillianMapStreamMutationsServer{stream})
+}
+
+type TrillianMap_StreamMutationsServer interface {
+	Send(*StreamMutationsResponse) error
+	grpc.ServerStream
+}
+
+type trillianMapStreamMutationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianMapStreamMutationsServer) Send(m *StreamMutationsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianMap_GetLeavesByPrefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeavesByPrefixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeavesByPrefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeavesByPrefix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeavesByPrefix(ctx, req.(*GetMapLeavesByPrefixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TrillianMap_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianMap",
+	HandlerType: (*TrillianMapServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLeaves",
+			Handler:    _TrillianMap_GetLeaves_Handler,
+		},
+		{
+			MethodName: "SetLeaves",
+			Handler:    _TrillianMap_SetLeaves_Handler,
+		},
+		{
+			MethodName: "GetSignedMapRoot",
+			Handler:    _TrillianMap_GetSignedMapRoot_Handler,
+		},
+		{
+			MethodName: "GetSignedMapRootByRevision",
+			Handler:    _TrillianMap_GetSignedMapRootByRevision_Handler,
+		},
+		{
+			MethodName: "GetLeafHistory",
+			Handler:    _TrillianMap_GetLeafHistory_Handler,
+		},
+		{
+			MethodName: "GetLeavesByPrefix",
+			Handler:    _TrillianMap_GetLeavesByPrefix_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMutations",
+			Handler:       _TrillianMap_StreamMutations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: fileDescriptor0,
+}
+
+// Client API for TrillianAdmin service
+
+type TrillianAdminClient interface {
+	CreateTree(ctx context.Context, in *CreateTreeRequest, opts ...grpc.CallOption) (*CreateTreeResponse, error)
+	ListTrees(ctx context.Context, in *ListTreesRequest, opts ...grpc.CallOption) (*ListTreesResponse, error)
+	GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (*GetTreeResponse, error)
+	UpdateTree(ctx context.Context, in *UpdateTreeRequest, opts ...grpc.CallOption) (*UpdateTreeResponse, error)
+	// FreezeTree stops a tree accepting any further writes. Frozen trees can't be
+	// made active again.
+	FreezeTree(ctx context.Context, in *FreezeTreeRequest, opts ...grpc.CallOption) (*FreezeTreeResponse, error)
+	// DeleteTree soft deletes a tree. It stops being served straight away but its data
+	// is only removed after a grace period, during which it can be undeleted.
+	DeleteTree(ctx context.Context, in *DeleteTreeRequest, opts ...grpc.CallOption) (*DeleteTreeResponse, error)
+	// UndeleteTree restores a soft deleted tree whose data hasn't been removed yet.
+	UndeleteTree(ctx context.Context, in *UndeleteTreeRequest, opts ...grpc.CallOption) (*UndeleteTreeResponse, error)
+	// GetTreeUsage returns the usage of a tree since it was created. It includes usage
+	// the answering server hasn't added to storage yet, but not other servers'.
+	GetTreeUsage(ctx context.Context, in *GetTreeUsageRequest, opts ...grpc.CallOption) (*GetTreeUsageResponse, error)
+	// ListAuditEvents returns events from the server's audit trail in the order they were
+	// recorded. It fails if the server doesn't keep an audit trail.
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	// ReloadConfig makes the server read its config file again and apply the settings that
+	// can change while it's running, as it does on SIGHUP. The rest only change on restart.
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+}
+
+type trillianAdminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrillianAdminClient(cc *grpc.ClientConn) TrillianAdminClient {
+	return &trillianAdminClient{cc}
+}
+
+func (c *trillianAdminClient) CreateTree(ctx context.Context, in *CreateTreeRequest, opts ...grpc.CallOption) (*CreateTreeResponse, error) {
+	out := new(CreateTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/CreateTree", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) ListTrees(ctx context.Context, in *ListTreesRequest, opts ...grpc.CallOption) (*ListTreesResponse, error) {
+	out := new(ListTreesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/ListTrees", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
-	out := new(GetLeavesByIndexResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndex", in, out, c.cc, opts...)
+func (c *trillianAdminClient) GetTree(ctx context.Context, in *GetTreeRequest, opts ...grpc.CallOption) (*GetTreeResponse, error) {
+	out := new(GetTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/GetTree", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
-	out := new(GetLeavesByHashResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, c.cc, opts...)
+func (c *trillianAdminClient) UpdateTree(ctx context.Context, in *UpdateTreeRequest, opts ...grpc.CallOption) (*UpdateTreeResponse, error) {
+	out := new(UpdateTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/UpdateTree", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error) {
-	out := new(GetEntryAndProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetEntryAndProof", in, out, c.cc, opts...)
+func (c *trillianAdminClient) FreezeTree(ctx context.Context, in *FreezeTreeRequest, opts ...grpc.CallOption) (*FreezeTreeResponse, error) {
+	out := new(FreezeTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/FreezeTree", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for TrillianLog service
-
-type TrillianLogServer interface {
-	// Corresponds to the LeafQueuer API
-	QueueLeaves(context.Context, *QueueLeavesRequest) (*QueueLeavesResponse, error)
-	// No direct equivalent at the storage level
-	GetInclusionProof(context.Context, *GetInclusionProofRequest) (*GetInclusionProofResponse, error)
-	GetInclusionProofByHash(context.Context, *GetInclusionProofByHashRequest) (*GetInclusionProofByHashResponse, error)
-	GetConsistencyProof(context.Context, *GetConsistencyProofRequest) (*GetConsistencyProofResponse, error)
-	// Corresponds to the LogRootReader API
-	GetLatestSignedLogRoot(context.Context, *GetLatestSignedLogRootRequest) (*GetLatestSignedLogRootResponse, error)
-	// Corresponds to the LeafReader API
-	GetSequencedLeafCount(context.Context, *GetSequencedLeafCountRequest) (*GetSequencedLeafCountResponse, error)
-	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
-	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
-	GetEntryAndProof(context.Context, *GetEntryAndProofRequest) (*GetEntryAndProofResponse, error)
+func (c *trillianAdminClient) DeleteTree(ctx context.Context, in *DeleteTreeRequest, opts ...grpc.CallOption) (*DeleteTreeResponse, error) {
+	out := new(DeleteTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/DeleteTree", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
-	s.RegisterService(&_TrillianLog_serviceDesc, srv)
+func (c *trillianAdminClient) UndeleteTree(ctx context.Context, in *UndeleteTreeRequest, opts ...grpc.CallOption) (*UndeleteTreeResponse, error) {
+	out := new(UndeleteTreeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/UndeleteTree", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func _TrillianLog_QueueLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(QueueLeavesRequest)
-	if err := dec(in); err != nil {
+func (c *trillianAdminClient) GetTreeUsage(ctx context.Context, in *GetTreeUsageRequest, opts ...grpc.CallOption) (*GetTreeUsageResponse, error) {
+	out := new(GetTreeUsageResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/GetTreeUsage", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(TrillianLogServer).QueueLeaves(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/QueueLeaves",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).QueueLeaves(ctx, req.(*QueueLeavesRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _TrillianLog_GetInclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetInclusionProofRequest)
-	if err := dec(in); err != nil {
+func (c *trillianAdminClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/ListAuditEvents", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(TrillianLogServer).GetInclusionProof(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetInclusionProof",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetInclusionProof(ctx, req.(*GetInclusionProofRequest))
+	return out, nil
+}
+
+func (c *trillianAdminClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	out := new(ReloadConfigResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianAdmin/ReloadConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _TrillianLog_GetInclusionProofByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetInclusionProofByHashRequest)
+// Server API for TrillianAdmin service
+
+type TrillianAdminServer interface {
+	CreateTree(context.Context, *CreateTreeRequest) (*CreateTreeResponse, error)
+	ListTrees(context.Context, *ListTreesRequest) (*ListTreesResponse, error)
+	GetTree(context.Context, *GetTreeRequest) (*GetTreeResponse, error)
+	UpdateTree(context.Context, *UpdateTreeRequest) (*UpdateTreeResponse, error)
+	// FreezeTree stops a tree accepting any further writes. Frozen trees can't be
+	// made active again.
+	FreezeTree(context.Context, *FreezeTreeRequest) (*FreezeTreeResponse, error)
+	// DeleteTree soft deletes a tree. It stops being served straight away but its data
+	// is only removed after a grace period, during which it can be undeleted.
+	DeleteTree(context.Context, *DeleteTreeRequest) (*DeleteTreeResponse, error)
+	// UndeleteTree restores a soft deleted tree whose data hasn't been removed yet.
+	UndeleteTree(context.Context, *UndeleteTreeRequest) (*UndeleteTreeResponse, error)
+	// GetTreeUsage returns the usage of a tree since it was created. It includes usage
+	// the answering server hasn't added to storage yet, but not other servers'.
+	GetTreeUsage(context.Context, *GetTreeUsageRequest) (*GetTreeUsageResponse, error)
+	// ListAuditEvents returns events from the server's audit trail in the order they were
+	// recorded. It fails if the server doesn't keep an audit trail.
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	// ReloadConfig makes the server read its config file again and apply the settings that
+	// can change while it's running, as it does on SIGHUP. The rest only change on restart.
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+}
+
+func RegisterTrillianAdminServer(s *grpc.Server, srv TrillianAdminServer) {
+	s.RegisterService(&_TrillianAdmin_serviceDesc, srv)
+}
+
+func _TrillianAdmin_CreateTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, in)
+		return srv.(TrillianAdminServer).CreateTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetInclusionProofByHash",
+		FullMethod: "/trillian.TrillianAdmin/CreateTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, req.(*GetInclusionProofByHashRequest))
+		return srv.(TrillianAdminServer).CreateTree(ctx, req.(*CreateTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetConsistencyProofRequest)
+func _TrillianAdmin_ListTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTreesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetConsistencyProof(ctx, in)
+		return srv.(TrillianAdminServer).ListTrees(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetConsistencyProof",
+		FullMethod: "/trillian.TrillianAdmin/ListTrees",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetConsistencyProof(ctx, req.(*GetConsistencyProofRequest))
+		return srv.(TrillianAdminServer).ListTrees(ctx, req.(*ListTreesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLatestSignedLogRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLatestSignedLogRootRequest)
+func _TrillianAdmin_GetTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, in)
+		return srv.(TrillianAdminServer).GetTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+		FullMethod: "/trillian.TrillianAdmin/GetTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, req.(*GetLatestSignedLogRootRequest))
+		return srv.(TrillianAdminServer).GetTree(ctx, req.(*GetTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetSequencedLeafCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetSequencedLeafCountRequest)
+func _TrillianAdmin_UpdateTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, in)
+		return srv.(TrillianAdminServer).UpdateTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetSequencedLeafCount",
+		FullMethod: "/trillian.TrillianAdmin/UpdateTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, req.(*GetSequencedLeafCountRequest))
+		return srv.(TrillianAdminServer).UpdateTree(ctx, req.(*UpdateTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLeavesByIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLeavesByIndexRequest)
+func _TrillianAdmin_FreezeTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FreezeTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, in)
+		return srv.(TrillianAdminServer).FreezeTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLeavesByIndex",
+		FullMethod: "/trillian.TrillianAdmin/FreezeTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, req.(*GetLeavesByIndexRequest))
+		return srv.(TrillianAdminServer).FreezeTree(ctx, req.(*FreezeTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLeavesByHashRequest)
+func _TrillianAdmin_DeleteTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLeavesByHash(ctx, in)
+		return srv.(TrillianAdminServer).DeleteTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLeavesByHash",
+		FullMethod: "/trillian.TrillianAdmin/DeleteTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLeavesByHash(ctx, req.(*GetLeavesByHashRequest))
+		return srv.(TrillianAdminServer).DeleteTree(ctx, req.(*DeleteTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetEntryAndProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetEntryAndProofRequest)
+func _TrillianAdmin_UndeleteTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UndeleteTreeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetEntryAndProof(ctx, in)
+		return srv.(TrillianAdminServer).UndeleteTree(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetEntryAndProof",
+		FullMethod: "/trillian.TrillianAdmin/UndeleteTree",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetEntryAndProof(ctx, req.(*GetEntryAndProofRequest))
+		return srv.(TrillianAdminServer).UndeleteTree(ctx, req.(*UndeleteTreeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-var _TrillianLog_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "trillian.TrillianLog",
-	HandlerType: (*TrillianLogServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "QueueLeaves",
-			Handler:    _TrillianLog_QueueLeaves_Handler,
-		},
-		{
-			MethodName: "GetInclusionProof",
-			Handler:    _TrillianLog_GetInclusionProof_Handler,
-		},
-		{
-			MethodName: "GetInclusionProofByHash",
-			Handler:    _TrillianLog_GetInclusionProofByHash_Handler,
-		},
-		{
-			MethodName: "GetConsistencyProof",
-			Handler:    _TrillianLog_GetConsistencyProof_Handler,
-		},
-		{
-			MethodName: "GetLatestSignedLogRoot",
-			Handler:    _TrillianLog_GetLatestSignedLogRoot_Handler,
-		},
-		{
-			MethodName: "GetSequencedLeafCount",
-			Handler:    _TrillianLog_GetSequencedLeafCount_Handler,
-		},
-		{
-			MethodName: "GetLeavesByIndex",
-			Handler:    _TrillianLog_GetLeavesByIndex_Handler,
-		},
-		{
-			MethodName: "GetLeavesByHash",
-			Handler:    _TrillianLog_GetLeavesByHash_Handler,
-		},
-		{
-			MethodName: "GetEntryAndProof",
-			Handler:    _TrillianLog_GetEntryAndProof_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: fileDescriptor0,
-}
-
-// Client API for TrillianMap service
-
-type TrillianMapClient interface {
-	GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
-	SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error)
-	GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error)
-}
-
-type trillianMapClient struct {
-	cc *grpc.ClientConn
-}
-
-func NewTrillianMapClient(cc *grpc.ClientConn) TrillianMapClient {
-	return &trillianMapClient{cc}
-}
-
-func (c *trillianMapClient) GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error) {
-	out := new(GetMapLeavesResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetLeaves", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *trillianMapClient) SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error) {
-	out := new(SetMapLeavesResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianMap/SetLeaves", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *trillianMapClient) GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error) {
-	out := new(GetSignedMapRootResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetSignedMapRoot", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-// Server API for TrillianMap service
-
-type TrillianMapServer interface {
-	GetLeaves(context.Context, *GetMapLeavesRequest) (*GetMapLeavesResponse, error)
-	SetLeaves(context.Context, *SetMapLeavesRequest) (*SetMapLeavesResponse, error)
-	GetSignedMapRoot(context.Context, *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error)
-}
-
-func RegisterTrillianMapServer(s *grpc.Server, srv TrillianMapServer) {
-	s.RegisterService(&_TrillianMap_serviceDesc, srv)
-}
-
-func _TrillianMap_GetLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetMapLeavesRequest)
+func _TrillianAdmin_GetTreeUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeUsageRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianMapServer).GetLeaves(ctx, in)
+		return srv.(TrillianAdminServer).GetTreeUsage(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianMap/GetLeaves",
+		FullMethod: "/trillian.TrillianAdmin/GetTreeUsage",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianMapServer).GetLeaves(ctx, req.(*GetMapLeavesRequest))
+		return srv.(TrillianAdminServer).GetTreeUsage(ctx, req.(*GetTreeUsageRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianMap_SetLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetMapLeavesRequest)
+func _TrillianAdmin_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianMapServer).SetLeaves(ctx, in)
+		return srv.(TrillianAdminServer).ListAuditEvents(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianMap/SetLeaves",
+		FullMethod: "/trillian.TrillianAdmin/ListAuditEvents",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianMapServer).SetLeaves(ctx, req.(*SetMapLeavesRequest))
+		return srv.(TrillianAdminServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianMap_GetSignedMapRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetSignedMapRootRequest)
+func _TrillianAdmin_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianMapServer).GetSignedMapRoot(ctx, in)
+		return srv.(TrillianAdminServer).ReloadConfig(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianMap/GetSignedMapRoot",
+		FullMethod: "/trillian.TrillianAdmin/ReloadConfig",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianMapServer).GetSignedMapRoot(ctx, req.(*GetSignedMapRootRequest))
+		return srv.(TrillianAdminServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-var _TrillianMap_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "trillian.TrillianMap",
-	HandlerType: (*TrillianMapServer)(nil),
+var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianAdmin",
+	HandlerType: (*TrillianAdminServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "GetLeaves",
-			Handler:    _TrillianMap_GetLeaves_Handler,
+			MethodName: "CreateTree",
+			Handler:    _TrillianAdmin_CreateTree_Handler,
 		},
 		{
-			MethodName: "SetLeaves",
-			Handler:    _TrillianMap_SetLeaves_Handler,
+			MethodName: "ListTrees",
+			Handler:    _TrillianAdmin_ListTrees_Handler,
 		},
 		{
-			MethodName: "GetSignedMapRoot",
-			Handler:    _TrillianMap_GetSignedMapRoot_Handler,
+			MethodName: "GetTree",
+			Handler:    _TrillianAdmin_GetTree_Handler,
+		},
+		{
+			MethodName: "UpdateTree",
+			Handler:    _TrillianAdmin_UpdateTree_Handler,
+		},
+		{
+			MethodName: "FreezeTree",
+			Handler:    _TrillianAdmin_FreezeTree_Handler,
+		},
+		{
+			MethodName: "DeleteTree",
+			Handler:    _TrillianAdmin_DeleteTree_Handler,
+		},
+		{
+			MethodName: "UndeleteTree",
+			Handler:    _TrillianAdmin_UndeleteTree_Handler,
+		},
+		{
+			MethodName: "GetTreeUsage",
+			Handler:    _TrillianAdmin_GetTreeUsage_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _TrillianAdmin_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _TrillianAdmin_ReloadConfig_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -1148,85 +3019,225 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("github.com/google/trillian/trillian_api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1270 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbc, 0x58, 0x6d, 0x73, 0xdb, 0x44,
-	0x10, 0x8e, 0xac, 0xbc, 0xd8, 0xeb, 0xa6, 0x71, 0x2e, 0x09, 0x71, 0x94, 0xa4, 0x4d, 0xaf, 0xd0,
-	0xa4, 0x61, 0x48, 0x18, 0x77, 0x60, 0xe0, 0x13, 0x34, 0x21, 0x03, 0xa1, 0x29, 0x29, 0x72, 0x87,
-	0xe9, 0xc0, 0x0c, 0x1a, 0xc5, 0xba, 0x38, 0x02, 0x5b, 0x32, 0x96, 0x1c, 0x62, 0xe8, 0xd0, 0x99,
-	0x32, 0xf0, 0x13, 0x18, 0xbe, 0xf0, 0x8d, 0x3f, 0xc1, 0xbf, 0xe3, 0x5e, 0xf4, 0x76, 0x7a, 0xb1,
-	0x53, 0x52, 0xf2, 0x4d, 0xb7, 0xbb, 0xf7, 0xec, 0xb3, 0x7b, 0x7b, 0xab, 0x95, 0xe0, 0x9d, 0xb6,
-	0xed, 0x9f, 0x0d, 0x4e, 0x76, 0x5a, 0x6e, 0x77, 0xb7, 0xed, 0xba, 0xed, 0x0e, 0xd9, 0xf5, 0xfb,
-	0x76, 0xa7, 0x63, 0x9b, 0x4e, 0xf4, 0x60, 0x98, 0x3d, 0x7b, 0xa7, 0xd7, 0x77, 0x7d, 0x17, 0x95,
-	0x43, 0x99, 0x76, 0xff, 0x12, 0x1b, 0xc5, 0x26, 0xfc, 0x23, 0xcc, 0x3f, 0x0d, 0x24, 0x0f, 0x7b,
-	0x76, 0xd3, 0x37, 0xfd, 0x81, 0x87, 0x3e, 0x86, 0xaa, 0xc7, 0x9f, 0x8c, 0x96, 0x6b, 0x91, 0xba,
-	0xb2, 0xa1, 0x6c, 0xdd, 0x6c, 0xdc, 0xde, 0x89, 0xb6, 0x66, 0x76, 0xec, 0x53, 0x33, 0x1d, 0xbc,
-	0xe8, 0x19, 0x6d, 0x40, 0xd5, 0x22, 0x5e, 0xab, 0x6f, 0xf7, 0x7c, 0xdb, 0x75, 0xea, 0x25, 0x8a,
-	0x50, 0xd1, 0x93, 0x22, 0xfc, 0xab, 0x02, 0x95, 0x23, 0x62, 0x9e, 0x3e, 0xe1, 0xdc, 0x57, 0xa1,
-	0xd2, 0xa1, 0x0b, 0xe3, 0xcc, 0xf4, 0xce, 0xb8, 0xbf, 0x1b, 0x7a, 0x99, 0x09, 0x3e, 0xa3, 0xeb,
-	0x48, 0x69, 0x99, 0xbe, 0xc9, 0xa1, 0x02, 0xe5, 0x27, 0x74, 0x8d, 0xd6, 0x01, 0xc8, 0x85, 0xdf,
-	0x37, 0x85, 0x56, 0xe5, 0xda, 0x0a, 0x97, 0x84, 0x6a, 0xbe, 0xd7, 0x76, 0x2c, 0x72, 0x51, 0x9f,
-	0xa4, 0x6a, 0x55, 0xe7, 0x68, 0x87, 0x4c, 0x80, 0x4f, 0xa1, 0xf2, 0x05, 0xe5, 0x2b, 0x48, 0x2c,
-	0xc3, 0x8c, 0x43, 0x17, 0x86, 0x6d, 0x05, 0x14, 0xa6, 0xd9, 0xf2, 0xd0, 0x62, 0x04, 0xb8, 0x82,
-	0xb3, 0x0b, 0x08, 0x30, 0x01, 0x67, 0x77, 0x17, 0x66, 0xb9, 0xb2, 0x4f, 0xce, 0x6d, 0x8f, 0x05,
-	0xab, 0x72, 0x27, 0x37, 0x98, 0x50, 0x0f, 0x64, 0xd8, 0x00, 0xa0, 0x3e, 0xdc, 0x20, 0x5a, 0x99,
-	0x94, 0x92, 0x22, 0x85, 0x1a, 0x00, 0x3d, 0x66, 0x6c, 0x30, 0x08, 0xea, 0x4f, 0xdd, 0xaa, 0x36,
-	0x16, 0xe2, 0xec, 0x47, 0x84, 0xf5, 0x0a, 0x37, 0x63, 0x6b, 0xfc, 0x0c, 0xd0, 0x97, 0x03, 0x32,
-	0x20, 0x34, 0xa5, 0xe7, 0xc4, 0xd3, 0xc9, 0x0f, 0x03, 0xe2, 0xf9, 0x68, 0x09, 0xa6, 0x3b, 0x6e,
-	0x3b, 0x0c, 0x48, 0xd5, 0xa7, 0xe8, 0x8a, 0xc6, 0xf3, 0x36, 0x15, 0x73, 0xbb, 0x2c, 0x78, 0x74,
-	0x24, 0x7a, 0x60, 0x82, 0x3f, 0x87, 0x05, 0x09, 0xd9, 0xeb, 0xb9, 0x8e, 0x47, 0xd0, 0x03, 0x98,
-	0x16, 0xe7, 0xcd, 0xa1, 0xab, 0x8d, 0xd5, 0x11, 0xe5, 0xa1, 0x07, 0xa6, 0xb8, 0x0b, 0xf5, 0x4f,
-	0x89, 0x7f, 0xe8, 0xb4, 0x3a, 0x03, 0x96, 0x16, 0x9e, 0x92, 0x31, 0x5c, 0xe5, 0x5c, 0x95, 0xd2,
-	0xb9, 0xa2, 0x47, 0xe3, 0xf7, 0x09, 0x31, 0x3c, 0xfb, 0x27, 0x12, 0x64, 0xbe, 0xcc, 0x04, 0x4d,
-	0xba, 0xc6, 0xcf, 0x61, 0x25, 0xc7, 0xdd, 0x15, 0x02, 0x40, 0xdb, 0x30, 0xc5, 0x73, 0xce, 0x89,
-	0x54, 0x1b, 0x8b, 0xf1, 0x9e, 0xf8, 0x78, 0x75, 0x61, 0x82, 0xff, 0x52, 0xe0, 0x56, 0xc6, 0xfd,
-	0xde, 0x90, 0x15, 0xcd, 0x98, 0x98, 0xa5, 0xdb, 0x50, 0xca, 0xde, 0x86, 0xc2, 0x88, 0x29, 0xbf,
-	0x79, 0xb7, 0x6f, 0x91, 0xbe, 0x71, 0x32, 0x34, 0x3c, 0xe6, 0xc4, 0x69, 0x11, 0x5e, 0xf5, 0x65,
-	0x7d, 0x8e, 0x2b, 0xf6, 0x86, 0xcd, 0x40, 0x8c, 0x5f, 0x2a, 0x70, 0xbb, 0x90, 0xdf, 0x6b, 0x4a,
-	0x92, 0x3a, 0x2e, 0x49, 0xbf, 0x29, 0xa0, 0x51, 0x12, 0xfb, 0xd4, 0x9b, 0xed, 0xf9, 0x94, 0xd7,
-	0xf0, 0x32, 0x45, 0x71, 0x0f, 0xe6, 0x4e, 0xed, 0xbe, 0xe7, 0x1b, 0x71, 0x26, 0x44, 0x65, 0xcc,
-	0x72, 0xf1, 0xd3, 0x30, 0x1d, 0x5b, 0x50, 0xf3, 0x48, 0xcb, 0x75, 0x2c, 0x23, 0x9d, 0xb2, 0x9b,
-	0x42, 0x1e, 0x5a, 0xe2, 0x5f, 0x60, 0x35, 0x97, 0xc6, 0x75, 0x15, 0xcb, 0x05, 0xbc, 0x41, 0xfd,
-	0x8b, 0x3b, 0xf6, 0x5f, 0x6a, 0x44, 0x95, 0x6a, 0x24, 0xb7, 0x0c, 0xd4, 0xfc, 0x32, 0xf8, 0x19,
-	0x96, 0x33, 0x9e, 0xaf, 0x12, 0xf5, 0x2b, 0x35, 0x97, 0x63, 0xc9, 0x39, 0xbf, 0xd2, 0xaf, 0xd8,
-	0x0f, 0x54, 0xb9, 0xa1, 0x3f, 0xe7, 0x1d, 0x26, 0x05, 0x78, 0x6d, 0xe1, 0xbc, 0x07, 0x6b, 0xd4,
-	0x7b, 0x98, 0x5a, 0x8b, 0x19, 0xec, 0xbb, 0x03, 0xc7, 0x1f, 0x1d, 0x13, 0xf6, 0x60, 0xbd, 0x60,
-	0xdb, 0x55, 0x98, 0x87, 0x99, 0x6a, 0x31, 0xa8, 0x64, 0xe7, 0xe4, 0xd8, 0xf8, 0x7d, 0xee, 0xf4,
-	0xc8, 0xf4, 0x29, 0xb1, 0xa6, 0xdd, 0x76, 0xa8, 0x5f, 0xb7, 0xad, 0xbb, 0xee, 0x38, 0xb2, 0x7f,
-	0x88, 0xb6, 0x96, 0xbb, 0xf1, 0x2a, 0x74, 0x3f, 0x82, 0x39, 0x8f, 0xa3, 0x19, 0xcc, 0x2b, 0xbd,
-	0x14, 0x7e, 0x70, 0x6f, 0x96, 0xe3, 0xdd, 0xb2, 0xbb, 0x59, 0x2f, 0xb9, 0xc4, 0x1d, 0x5e, 0x4b,
-	0x07, 0x8e, 0xdf, 0x1f, 0x3e, 0x74, 0xac, 0xff, 0xfb, 0xdd, 0xf2, 0xb7, 0xc2, 0x2b, 0x2d, 0xe5,
-	0xee, 0x9a, 0xda, 0x05, 0xda, 0x84, 0x49, 0xc6, 0x93, 0xb3, 0x2a, 0xa8, 0x49, 0x6e, 0x80, 0x5f,
-	0xc0, 0xcc, 0x63, 0xb3, 0xc7, 0xa4, 0x68, 0x05, 0xca, 0xdf, 0x93, 0x61, 0x72, 0xc4, 0x9a, 0xa1,
-	0x6b, 0x69, 0xc2, 0xca, 0x7d, 0xe1, 0x84, 0x59, 0x3a, 0x37, 0x3b, 0x03, 0x12, 0x4e, 0x58, 0x4c,
-	0xf2, 0x15, 0x13, 0xa4, 0x06, 0xb0, 0xc9, 0xd4, 0x00, 0x86, 0x0f, 0xa0, 0xfc, 0x88, 0x0c, 0x85,
-	0x69, 0x0d, 0x54, 0xea, 0x31, 0x70, 0xce, 0x1e, 0x69, 0x1c, 0x53, 0x02, 0x56, 0xc4, 0x3c, 0x1f,
-	0x07, 0x12, 0xb0, 0xd6, 0x85, 0x1e, 0x9f, 0xc0, 0x7c, 0x08, 0x13, 0xbd, 0xb0, 0xd0, 0x2e, 0x54,
-	0x58, 0x44, 0x02, 0x41, 0x64, 0x1a, 0xc5, 0x08, 0xa1, 0xbd, 0xce, 0xc2, 0x16, 0x04, 0xd6, 0xa0,
-	0x62, 0x87, 0xbb, 0x83, 0xa6, 0x19, 0x0b, 0xf0, 0xd7, 0xb0, 0x40, 0x4f, 0x54, 0x38, 0x96, 0x87,
-	0xa8, 0xae, 0xd9, 0x4b, 0x14, 0x0f, 0x5d, 0xd1, 0xe2, 0x09, 0x82, 0x11, 0x28, 0x3c, 0x18, 0x0d,
-	0xca, 0xa9, 0x21, 0x30, 0x5a, 0xe3, 0x7f, 0x14, 0x58, 0x94, 0xc1, 0xaf, 0x52, 0x2a, 0x1f, 0x24,
-	0x03, 0x17, 0x7d, 0x69, 0x35, 0x1b, 0x78, 0x94, 0xa8, 0x44, 0x06, 0x1a, 0x50, 0x66, 0xc1, 0xf0,
-	0xeb, 0xa5, 0xe6, 0x5f, 0x2f, 0xca, 0x91, 0x5f, 0xaf, 0x99, 0xae, 0x78, 0xc0, 0x7f, 0x2a, 0xb0,
-	0xd0, 0xbc, 0x7c, 0x62, 0x76, 0xb3, 0xe4, 0x46, 0x9f, 0xca, 0x87, 0x50, 0xa5, 0x3b, 0x7b, 0xf4,
-	0x75, 0x15, 0xcd, 0xf0, 0xd5, 0x46, 0x5d, 0x2a, 0x05, 0xaa, 0x7c, 0x4c, 0x7c, 0x93, 0xe9, 0x75,
-	0x10, 0xc6, 0xbc, 0xba, 0x5e, 0xc0, 0x62, 0xf3, 0xb5, 0x65, 0x35, 0x99, 0x9b, 0xd2, 0x25, 0x73,
-	0xf3, 0x2e, 0x6f, 0x3a, 0xb2, 0x72, 0x64, 0x7a, 0xd8, 0x87, 0x4f, 0x3d, 0xbb, 0xe5, 0x9a, 0x79,
-	0x6f, 0x6f, 0xc3, 0x52, 0xee, 0x57, 0x1c, 0x9a, 0x86, 0xd2, 0xf1, 0xa3, 0xda, 0x04, 0xaa, 0xc0,
-	0xd4, 0x81, 0xae, 0x1f, 0xeb, 0x35, 0xa5, 0xf1, 0x72, 0x06, 0xaa, 0xa1, 0x31, 0x6d, 0xb6, 0xe8,
-	0x08, 0xaa, 0x89, 0x2f, 0x02, 0xb4, 0x16, 0x3b, 0xcb, 0x7e, 0x82, 0x68, 0xeb, 0x05, 0x5a, 0x11,
-	0x30, 0x9e, 0x40, 0xdf, 0xc2, 0x7c, 0x66, 0x0a, 0x45, 0x38, 0xde, 0x55, 0xf4, 0xc1, 0xa0, 0xdd,
-	0x1d, 0x69, 0x13, 0xe1, 0xf7, 0xf8, 0x09, 0xe5, 0x4d, 0xb9, 0x68, 0x6b, 0x04, 0x82, 0x34, 0x84,
-	0x69, 0xf7, 0x2f, 0x61, 0x19, 0x79, 0xb4, 0x78, 0x1f, 0x49, 0xcf, 0x92, 0xe8, 0x4d, 0x09, 0xa3,
-	0x60, 0xe2, 0xd5, 0xde, 0x1a, 0x63, 0x15, 0x79, 0xe9, 0x8a, 0x89, 0x31, 0xfb, 0x1a, 0x46, 0x9b,
-	0x12, 0x44, 0xf1, 0x1b, 0x5e, 0xdb, 0x1a, 0x6f, 0x18, 0xb9, 0xfb, 0x0e, 0x96, 0x72, 0x67, 0x14,
-	0x74, 0x4f, 0x02, 0x29, 0x9c, 0x7d, 0xb4, 0xcd, 0xb1, 0x76, 0x91, 0xaf, 0x6f, 0xa0, 0x96, 0x1e,
-	0xe2, 0xd0, 0x1d, 0x99, 0x6b, 0xce, 0xc4, 0xa8, 0xe1, 0x51, 0x26, 0x11, 0xf8, 0x33, 0x98, 0x4b,
-	0xcd, 0xbb, 0x68, 0x23, 0x77, 0x63, 0xf2, 0xfc, 0xef, 0x8c, 0xb0, 0x48, 0xd1, 0x96, 0x26, 0x82,
-	0x14, 0xed, 0xbc, 0xe1, 0x24, 0x45, 0x3b, 0x77, 0xa0, 0xc0, 0x13, 0x8d, 0xdf, 0x4b, 0xf1, 0x25,
-	0xa4, 0xb7, 0x99, 0x5e, 0xc2, 0x4a, 0xc4, 0x04, 0xad, 0x4b, 0x10, 0xe9, 0x46, 0xad, 0xdd, 0x2a,
-	0x52, 0x47, 0xd4, 0x29, 0x5a, 0x33, 0x0f, 0xad, 0x39, 0x1a, 0xad, 0x99, 0x8f, 0x26, 0x12, 0x21,
-	0x75, 0x9e, 0x54, 0x22, 0xf2, 0x1a, 0x66, 0x2a, 0x11, 0xb9, 0x0d, 0x12, 0x4f, 0xec, 0xed, 0xc2,
-	0x4a, 0xcb, 0xed, 0xee, 0x88, 0xff, 0x5a, 0x3b, 0xf2, 0xef, 0xac, 0xbd, 0x5a, 0xa2, 0xa9, 0xf1,
-	0x31, 0xe8, 0x89, 0x72, 0x32, 0xcd, 0x55, 0x0f, 0xfe, 0x0d, 0x00, 0x00, 0xff, 0xff, 0x15, 0x03,
-	0xd9, 0xfc, 0x4f, 0x13, 0x00, 0x00,
+	// 3505 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x3b, 0xc9, 0x72, 0x1b, 0x47,
+	0x96, 0x2a, 0x80, 0x1b, 0x1e, 0xb8, 0x00, 0x49, 0x52, 0x02, 0x8b, 0x14, 0x45, 0x96, 0x36, 0x8a,
+	0x92, 0x05, 0x9b, 0x0e, 0x8f, 0x97, 0xcb, 0x0c, 0x17, 0x88, 0xc6, 0x88, 0x9b, 0x8a, 0xa0, 0x1c,
+	0xb6, 0x0f, 0xe5, 0x22, 0x2a, 0x09, 0x96, 0x09, 0x54, 0x41, 0x55, 0x05, 0x99, 0xb0, 0xbc, 0x7b,
+	0x66, 0x62, 0x62, 0x22, 0xc6, 0xe3, 0x09, 0x5f, 0x66, 0xc2, 0xd1, 0x87, 0xee, 0xf0, 0xa5, 0x2f,
+	0xfd, 0x2f, 0xdd, 0x7d, 0xec, 0x43, 0x5f, 0x7c, 0xea, 0x53, 0xff, 0x40, 0x47, 0x74, 0xe4, 0x52,
+	0x7b, 0x15, 0x40, 0x99, 0x12, 0x23, 0xfa, 0x86, 0x7a, 0xef, 0x65, 0xbe, 0x25, 0x5f, 0xbe, 0x7c,
+	0xf9, 0x5e, 0x02, 0x5e, 0x69, 0xe8, 0xce, 0x71, 0xe7, 0xf0, 0x7e, 0xdd, 0x6c, 0x95, 0x1b, 0xa6,
+	0xd9, 0x68, 0xe2, 0xb2, 0x63, 0xe9, 0xcd, 0xa6, 0xae, 0x1a, 0xde, 0x0f, 0x45, 0x6d, 0xeb, 0xf7,
+	0xdb, 0x96, 0xe9, 0x98, 0x68, 0xc4, 0x85, 0x89, 0x77, 0xce, 0x30, 0x90, 0x0d, 0x12, 0xe7, 0x38,
+	0x5e, 0x6d, 0xeb, 0x65, 0xd5, 0x30, 0x4c, 0x47, 0x75, 0x74, 0xd3, 0xb0, 0x19, 0x56, 0xfa, 0x04,
+	0x8a, 0x35, 0x4e, 0xbf, 0xda, 0xd6, 0xf7, 0x1d, 0xd5, 0xe9, 0xd8, 0xe8, 0x5f, 0x20, 0x6f, 0xd3,
+	0x5f, 0x4a, 0xdd, 0xd4, 0x70, 0x49, 0x58, 0x10, 0x96, 0xc6, 0x57, 0xae, 0xdd, 0xf7, 0x26, 0x8e,
+	0x8d, 0x58, 0x37, 0x35, 0x2c, 0x83, 0xed, 0xfd, 0x46, 0x0b, 0x90, 0xd7, 0xb0, 0x5d, 0xb7, 0xf4,
+	0x36, 0x61, 0x56, 0xca, 0x2c, 0x08, 0x4b, 0x39, 0x39, 0x08, 0x92, 0xfe, 0x24, 0x40, 0x6e, 0x0b,
+	0xab, 0x47, 0x7b, 0x54, 0xb3, 0x59, 0xc8, 0x35, 0xb1, 0x7a, 0xa4, 0x1c, 0xab, 0xf6, 0x31, 0xe5,
+	0x37, 0x2a, 0x8f, 0x10, 0xc0, 0xbb, 0xaa, 0x7d, 0xec, 0x21, 0x35, 0xd5, 0x51, 0x4b, 0x19, 0x1f,
+	0xb9, 0xa1, 0x3a, 0x2a, 0xba, 0x0a, 0x80, 0x4f, 0x1d, 0x4b, 0x65, 0xd8, 0x2c, 0xc5, 0xe6, 0x28,
+	0xc4, 0x45, 0xd3, 0xb1, 0xba, 0xa1, 0xe1, 0xd3, 0xd2, 0xc0, 0x82, 0xb0, 0x94, 0x95, 0xe9, 0x6c,
+	0x55, 0x02, 0x40, 0xf7, 0x00, 0x31, 0xb4, 0x86, 0x0d, 0x47, 0x77, 0xba, 0x4c, 0x80, 0x41, 0x3a,
+	0x4b, 0x81, 0x92, 0x71, 0x04, 0x15, 0x64, 0x09, 0x0a, 0x86, 0xe9, 0x28, 0x87, 0xf8, 0xc8, 0xb4,
+	0xb0, 0x62, 0xa8, 0x86, 0x69, 0x97, 0x86, 0xe8, 0x94, 0xe3, 0x86, 0xe9, 0xac, 0x51, 0xf0, 0x0e,
+	0x81, 0x4a, 0x47, 0x90, 0xdb, 0x31, 0x35, 0xcc, 0x94, 0xbb, 0x02, 0xc3, 0x86, 0xa9, 0x61, 0x45,
+	0xd7, 0xb8, 0x6a, 0x43, 0xe4, 0xb3, 0xaa, 0x11, 0xc5, 0x28, 0x82, 0x32, 0xe5, 0x8a, 0x11, 0x00,
+	0x65, 0x76, 0x1d, 0xc6, 0x28, 0xd2, 0xc2, 0x4f, 0x75, 0x9b, 0x18, 0x31, 0x4b, 0x39, 0x8d, 0x12,
+	0xa0, 0xcc, 0x61, 0x92, 0x02, 0xb0, 0x67, 0x99, 0x26, 0xb7, 0x62, 0x58, 0x59, 0x21, 0xaa, 0xec,
+	0x0a, 0x40, 0x9b, 0x10, 0x2b, 0x64, 0x8a, 0x52, 0x66, 0x21, 0xbb, 0x94, 0x5f, 0x99, 0xf4, 0x57,
+	0xd5, 0x13, 0x58, 0xce, 0x51, 0x32, 0xf2, 0x2d, 0x39, 0x80, 0x1e, 0x75, 0x70, 0x07, 0x6f, 0x61,
+	0xf5, 0x29, 0xb6, 0x65, 0xfc, 0xa4, 0x83, 0x6d, 0x07, 0x4d, 0xc3, 0x50, 0xd3, 0x6c, 0xb8, 0x0a,
+	0x65, 0xe5, 0xc1, 0xa6, 0xd9, 0xa8, 0x6a, 0xe8, 0x2e, 0x0c, 0x35, 0x29, 0x5d, 0x7c, 0x72, 0x6f,
+	0xa9, 0x65, 0x4e, 0x82, 0x44, 0x18, 0x69, 0x5b, 0xba, 0x69, 0xe9, 0x4e, 0x97, 0xaa, 0x36, 0x28,
+	0x7b, 0xdf, 0xd2, 0x5f, 0x33, 0x00, 0x94, 0xad, 0x46, 0xc6, 0xa1, 0x15, 0x18, 0x62, 0xbe, 0xc5,
+	0x5d, 0x51, 0xf4, 0xe7, 0xf5, 0xa9, 0x98, 0x27, 0xca, 0x9c, 0x12, 0xbd, 0x05, 0x63, 0xf8, 0x54,
+	0xb7, 0x1d, 0xdd, 0x68, 0x28, 0xc4, 0x04, 0xd4, 0xbe, 0x29, 0x22, 0x8d, 0xba, 0x94, 0x94, 0xdb,
+	0x36, 0x20, 0x6f, 0xa4, 0xa3, 0xb7, 0xb0, 0xed, 0xa8, 0xad, 0x36, 0x15, 0x31, 0xbf, 0x32, 0xef,
+	0x0f, 0xdf, 0xd7, 0x1b, 0x06, 0xd6, 0x2a, 0x86, 0x63, 0x75, 0x6b, 0x2e, 0x95, 0x5c, 0x74, 0x47,
+	0x7a, 0x20, 0x74, 0x19, 0x86, 0x2c, 0xac, 0xda, 0xa6, 0x41, 0xbd, 0x2f, 0x27, 0xf3, 0x2f, 0xb4,
+	0x06, 0xe3, 0x16, 0xfe, 0x18, 0xd7, 0xc9, 0x6e, 0x60, 0xfb, 0x6c, 0x90, 0x2a, 0x37, 0x1b, 0x96,
+	0x50, 0x76, 0x69, 0xe8, 0x1e, 0x1b, 0xb3, 0x82, 0x9f, 0xe8, 0xa6, 0x3b, 0x07, 0xd6, 0x94, 0x23,
+	0x1d, 0x37, 0x35, 0xea, 0x8e, 0x39, 0x97, 0x0c, 0x6b, 0x0f, 0x08, 0x10, 0x49, 0x30, 0xd6, 0x52,
+	0x4f, 0xa9, 0x19, 0x14, 0x5b, 0xff, 0x14, 0x97, 0x86, 0xe9, 0xaa, 0xe5, 0x5b, 0xea, 0x29, 0xb5,
+	0x9c, 0xfe, 0x29, 0x96, 0x4e, 0x61, 0x32, 0xb4, 0xd0, 0x76, 0xdb, 0x34, 0x6c, 0x8c, 0x5e, 0x0f,
+	0x99, 0x3e, 0xbf, 0x32, 0xdb, 0x23, 0x0a, 0x78, 0xb6, 0xbf, 0x17, 0xf1, 0x83, 0xa9, 0xa4, 0xf5,
+	0x72, 0x1d, 0x41, 0x52, 0x60, 0x66, 0x55, 0xd3, 0xf6, 0x89, 0x6b, 0x19, 0x75, 0xac, 0xb9, 0x02,
+	0xbc, 0x30, 0x4f, 0x93, 0xbe, 0x04, 0x31, 0x89, 0xc1, 0xc5, 0x69, 0xd8, 0x82, 0xd2, 0x26, 0x76,
+	0xaa, 0x46, 0xbd, 0xd9, 0x21, 0xbb, 0x96, 0xee, 0xd8, 0x3e, 0x0a, 0x86, 0xb7, 0x72, 0x26, 0xba,
+	0x95, 0x67, 0x21, 0xe7, 0x58, 0x18, 0xb3, 0xd5, 0x64, 0x81, 0x61, 0x84, 0x00, 0xe8, 0x52, 0x7e,
+	0x06, 0x33, 0x09, 0xec, 0xce, 0xa3, 0xee, 0x32, 0x0c, 0xd2, 0x90, 0xc0, 0x37, 0x51, 0x40, 0x5b,
+	0x3f, 0xfa, 0xc8, 0x8c, 0x44, 0xfa, 0x95, 0x00, 0xf3, 0x31, 0xf6, 0x6b, 0x34, 0x80, 0xf6, 0xd1,
+	0x39, 0x74, 0x08, 0x64, 0xe2, 0x87, 0x40, 0xaa, 0xc6, 0x68, 0x19, 0x8a, 0xa6, 0xa5, 0x61, 0x4b,
+	0x39, 0xec, 0x2a, 0x36, 0x5f, 0x67, 0xba, 0xdd, 0x46, 0xe4, 0x09, 0x8a, 0x58, 0xeb, 0xba, 0xcb,
+	0x2f, 0x7d, 0x23, 0xc0, 0xb5, 0x54, 0xf9, 0x5e, 0x90, 0x91, 0xb2, 0xfd, 0x8c, 0xf4, 0xef, 0x02,
+	0x88, 0x9b, 0xd8, 0x59, 0x37, 0x0d, 0x5b, 0xb7, 0x1d, 0x6c, 0xd4, 0xbb, 0x67, 0x71, 0x8a, 0x5b,
+	0x30, 0x71, 0xa4, 0x5b, 0xb6, 0xa3, 0xf8, 0x96, 0x60, 0x9e, 0x31, 0x46, 0xc1, 0x35, 0xd7, 0x1c,
+	0x4b, 0x50, 0xb0, 0x71, 0xdd, 0x34, 0x34, 0x25, 0x6a, 0xb2, 0x71, 0x06, 0x77, 0x29, 0xa5, 0x2f,
+	0x60, 0x36, 0x51, 0x8c, 0x8b, 0x72, 0x96, 0x53, 0xb8, 0xbc, 0x89, 0x1d, 0xb6, 0x23, 0x7f, 0x89,
+	0x8f, 0x64, 0x43, 0x3e, 0x92, 0xe8, 0x06, 0xd9, 0x64, 0x37, 0x78, 0x06, 0x57, 0x62, 0x9c, 0xcf,
+	0xa3, 0xf5, 0x73, 0x45, 0xa4, 0xff, 0x65, 0x7b, 0xc4, 0xe5, 0x1e, 0x4c, 0x32, 0xfa, 0xe8, 0x9f,
+	0x9c, 0xb0, 0x30, 0x43, 0xc4, 0x13, 0x96, 0xe7, 0x31, 0xc8, 0xb7, 0x6c, 0x5f, 0x24, 0xcb, 0x74,
+	0x61, 0x96, 0xd9, 0x0d, 0x2d, 0x0b, 0x0d, 0x76, 0xcf, 0x19, 0x29, 0xb3, 0xa1, 0x48, 0x29, 0x7d,
+	0x06, 0xa5, 0xf8, 0x84, 0x17, 0xa6, 0xce, 0x7f, 0x08, 0x21, 0x7d, 0x64, 0xd5, 0x68, 0xe0, 0x3e,
+	0xfa, 0x5c, 0xa3, 0xc9, 0xb7, 0xe5, 0x84, 0x42, 0x3f, 0x50, 0x10, 0x8b, 0xfd, 0x53, 0x30, 0x58,
+	0x37, 0x3b, 0x86, 0xc3, 0xb7, 0x34, 0xfb, 0x20, 0x66, 0x68, 0xab, 0x0d, 0xac, 0x38, 0xe6, 0x09,
+	0x66, 0xa9, 0xc6, 0xa8, 0x9c, 0x23, 0x90, 0x1a, 0x01, 0x48, 0x3f, 0x09, 0x50, 0x8a, 0x0b, 0x72,
+	0x51, 0x76, 0x20, 0x91, 0xcb, 0xc0, 0xa7, 0x8e, 0x12, 0x10, 0x91, 0xa5, 0xea, 0x63, 0x04, 0xbc,
+	0xe7, 0x89, 0xf9, 0x8d, 0x00, 0x93, 0xfb, 0x8e, 0x85, 0xd5, 0xd6, 0x99, 0xd2, 0x80, 0x5f, 0x6e,
+	0xab, 0xfa, 0x71, 0xc7, 0x38, 0x61, 0x91, 0x71, 0x80, 0x26, 0x9f, 0x39, 0x0a, 0xe1, 0xa9, 0xd0,
+	0x54, 0x58, 0x86, 0x0b, 0x73, 0x97, 0x37, 0x60, 0x6e, 0x13, 0x3b, 0xc1, 0x4c, 0xe5, 0x68, 0x9d,
+	0x48, 0xdc, 0xdb, 0x0c, 0x92, 0x0d, 0x57, 0x53, 0x86, 0x9d, 0x47, 0x72, 0x77, 0x63, 0x31, 0x03,
+	0x06, 0x52, 0x10, 0x3a, 0xb7, 0xf4, 0x4f, 0x94, 0xe9, 0x96, 0xea, 0x60, 0xdb, 0x61, 0xb9, 0xf0,
+	0x96, 0xd9, 0x90, 0x4d, 0xb3, 0x9f, 0xb0, 0xbf, 0xe7, 0xb1, 0x2f, 0x69, 0xe0, 0x79, 0xc4, 0xfd,
+	0x67, 0x98, 0xb0, 0xe9, 0x6c, 0x0a, 0xe1, 0x6a, 0x99, 0xa6, 0xc3, 0x0f, 0xa0, 0x2b, 0xd1, 0x9c,
+	0xdd, 0x65, 0x37, 0x66, 0x07, 0x3f, 0xd1, 0xdb, 0x30, 0x5a, 0x37, 0x09, 0x48, 0x75, 0x3a, 0x16,
+	0xb6, 0x4b, 0x59, 0xba, 0x5e, 0xd3, 0xfe, 0xe8, 0x75, 0x1f, 0x2b, 0x87, 0x48, 0xa5, 0xff, 0x17,
+	0x60, 0x7a, 0x55, 0xd3, 0x82, 0x04, 0xbd, 0x1d, 0xf7, 0x55, 0x98, 0x22, 0x12, 0xfa, 0xf7, 0x0b,
+	0x7e, 0x9b, 0x64, 0x56, 0x46, 0x04, 0xe7, 0xdd, 0x20, 0xe8, 0x8d, 0x12, 0xbd, 0x09, 0xf9, 0x00,
+	0x4b, 0x7e, 0x1d, 0x49, 0x11, 0x2e, 0x48, 0x29, 0x6d, 0xc3, 0xe5, 0xa8, 0x68, 0xe7, 0x30, 0xb3,
+	0xd4, 0xa4, 0x01, 0x8d, 0x5e, 0x7b, 0x56, 0x0d, 0xed, 0x65, 0xa7, 0xb2, 0x3c, 0x6c, 0x45, 0xd8,
+	0x5d, 0x50, 0x76, 0x82, 0x6e, 0xc3, 0x00, 0xbd, 0x3a, 0x66, 0xd3, 0xaf, 0x8e, 0x94, 0x40, 0xfa,
+	0x12, 0x86, 0xb7, 0xd5, 0x36, 0x81, 0xa2, 0x19, 0x18, 0x39, 0xc1, 0xdd, 0x60, 0x21, 0x63, 0xf8,
+	0x04, 0x77, 0x43, 0x75, 0x8c, 0xc4, 0xfc, 0xd6, 0xb5, 0xd2, 0x53, 0xb5, 0xd9, 0xc1, 0x6e, 0x1d,
+	0x83, 0x40, 0x1e, 0x13, 0x40, 0xa4, 0xcc, 0x31, 0x10, 0x29, 0x73, 0x48, 0x15, 0x18, 0x79, 0x88,
+	0xbb, 0x8c, 0xb4, 0x00, 0xd9, 0x13, 0xdc, 0xe5, 0xcc, 0xc9, 0x4f, 0x74, 0x1b, 0x06, 0xd9, 0xb4,
+	0x4c, 0xe7, 0xa2, 0xaf, 0x08, 0x97, 0x5a, 0x66, 0x78, 0xe9, 0x10, 0x8a, 0xee, 0x34, 0x5e, 0x7e,
+	0x8c, 0xca, 0x90, 0x23, 0x1a, 0xb1, 0x19, 0x98, 0xa5, 0x91, 0x3f, 0x83, 0x4b, 0x2f, 0x8f, 0x9c,
+	0xf0, 0x5f, 0x68, 0x0e, 0x72, 0xba, 0x3b, 0x9a, 0xa7, 0x26, 0x3e, 0x40, 0xfa, 0x00, 0x26, 0x37,
+	0xb1, 0xc3, 0x18, 0x87, 0x23, 0x7c, 0x4b, 0x6d, 0x07, 0x9c, 0xa7, 0xa5, 0xb6, 0xab, 0x9a, 0xab,
+	0x0c, 0x9b, 0x85, 0x2a, 0x23, 0xc2, 0x48, 0xa4, 0x24, 0xe2, 0x7d, 0x4b, 0x7f, 0x16, 0x60, 0x2a,
+	0x3c, 0xf9, 0x79, 0x5c, 0xe5, 0xad, 0xa0, 0xe2, 0x2c, 0x7a, 0xcf, 0xc6, 0x15, 0xf7, 0x0c, 0x15,
+	0xb0, 0xc0, 0x0a, 0x8c, 0x10, 0x65, 0x68, 0x10, 0xca, 0x26, 0x07, 0xa1, 0x6d, 0xb5, 0x4d, 0x83,
+	0xd0, 0x70, 0x8b, 0xfd, 0x40, 0x37, 0x60, 0xdc, 0x1d, 0xa3, 0xd8, 0x8e, 0xda, 0x74, 0x2f, 0x30,
+	0xa3, 0x9c, 0x60, 0x9f, 0xc0, 0xa4, 0xff, 0x23, 0x07, 0xe4, 0xd9, 0xcd, 0x57, 0x8e, 0xab, 0xd0,
+	0x7b, 0xed, 0xde, 0x86, 0x7c, 0x4b, 0x6d, 0xb7, 0xb1, 0xe5, 0xd7, 0xd3, 0xf2, 0x2b, 0xa5, 0x90,
+	0xc3, 0xb4, 0xb1, 0xb5, 0x8d, 0x1d, 0x95, 0xe0, 0x65, 0x60, 0xc4, 0xd4, 0x07, 0xbf, 0x84, 0xa9,
+	0xfd, 0x17, 0x66, 0xfb, 0xa0, 0x05, 0x33, 0x67, 0xb3, 0xa0, 0xf4, 0x2a, 0x0d, 0x4d, 0x61, 0x64,
+	0x4f, 0xf3, 0x48, 0xbf, 0x65, 0xe1, 0x25, 0x32, 0xe4, 0x82, 0xe5, 0x4e, 0x58, 0xf9, 0x6c, 0xc2,
+	0xca, 0x3f, 0x86, 0xc5, 0xa8, 0xa8, 0x6b, 0x5d, 0xb7, 0x10, 0xd8, 0xc7, 0x0d, 0x82, 0x7b, 0x26,
+	0x13, 0xd9, 0x33, 0xff, 0x2d, 0x80, 0xd4, 0x6b, 0xe2, 0x8b, 0x5e, 0xc5, 0xff, 0x12, 0x60, 0x9a,
+	0x65, 0xaa, 0x47, 0xef, 0xea, 0xb6, 0x63, 0x5a, 0xdd, 0xb3, 0x86, 0x08, 0x2f, 0xde, 0xdd, 0x84,
+	0x71, 0x96, 0x16, 0x46, 0x02, 0xc5, 0x18, 0x85, 0xba, 0xaa, 0xa1, 0x45, 0x18, 0xc5, 0x86, 0xe6,
+	0x13, 0xb1, 0xea, 0x70, 0x1e, 0x1b, 0x9a, 0x4b, 0x22, 0xfd, 0x28, 0xc0, 0x84, 0x1b, 0x23, 0xdd,
+	0x61, 0x41, 0x63, 0x0a, 0x61, 0x63, 0x46, 0x43, 0x86, 0xf0, 0x52, 0x43, 0x06, 0xc9, 0x96, 0x2f,
+	0x47, 0x4d, 0x75, 0x9e, 0xe5, 0x7a, 0x1d, 0x86, 0x8f, 0xd9, 0x3c, 0x3c, 0x56, 0xcc, 0xc4, 0x4f,
+	0x0a, 0xd7, 0x2f, 0x5c, 0x4a, 0x49, 0x85, 0xfc, 0xb6, 0xda, 0xde, 0xee, 0xb0, 0xbe, 0x02, 0x31,
+	0x2a, 0xd5, 0x23, 0x6c, 0x21, 0x12, 0x54, 0x3c, 0x03, 0x3e, 0x6f, 0x50, 0x92, 0x3a, 0x70, 0x99,
+	0x25, 0xe4, 0x2e, 0x97, 0x7e, 0x61, 0x2f, 0xee, 0x00, 0x99, 0x24, 0x07, 0x08, 0xdf, 0x03, 0xb2,
+	0xd1, 0x7b, 0xc0, 0xb7, 0x02, 0x5c, 0x89, 0xf1, 0x3d, 0x9f, 0x7d, 0x73, 0x2d, 0x77, 0x26, 0xae,
+	0xf8, 0x74, 0xc8, 0xc2, 0x2e, 0x1f, 0xd9, 0xa7, 0x93, 0xfe, 0x20, 0xd0, 0x1a, 0x8d, 0x17, 0x57,
+	0xd7, 0xba, 0x7b, 0x16, 0x3e, 0xd2, 0x4f, 0xfb, 0x98, 0xe0, 0x2a, 0x00, 0x31, 0x72, 0x9b, 0xd2,
+	0xf2, 0xcd, 0x41, 0xcc, 0xce, 0x06, 0xf7, 0x3a, 0x45, 0x89, 0xf5, 0xe8, 0x71, 0xad, 0x61, 0x85,
+	0xe6, 0x41, 0x36, 0x3f, 0x89, 0xc6, 0x38, 0x94, 0x26, 0x4a, 0x36, 0x49, 0x67, 0xe8, 0x75, 0x8e,
+	0x1a, 0x6f, 0x90, 0x57, 0xf0, 0xd5, 0x06, 0x2b, 0x41, 0x85, 0xaf, 0xa3, 0x43, 0xd1, 0xeb, 0xe8,
+	0xff, 0x64, 0x60, 0x2e, 0x59, 0xa9, 0x7f, 0x9c, 0x03, 0x3b, 0xe1, 0x4e, 0x3b, 0x90, 0x70, 0xa7,
+	0x4d, 0x08, 0xef, 0x83, 0x09, 0xe1, 0xfd, 0x4d, 0x28, 0xae, 0x5b, 0x58, 0x75, 0x70, 0xcd, 0xc2,
+	0xde, 0xed, 0x41, 0x82, 0x01, 0xc7, 0xc2, 0x6e, 0xd6, 0x35, 0x1e, 0xb4, 0x01, 0xc6, 0x32, 0xc5,
+	0x49, 0x2d, 0x40, 0xc1, 0x81, 0xe7, 0xb1, 0x9f, 0xcb, 0x2e, 0xd3, 0x83, 0xdd, 0x1b, 0x50, 0xd8,
+	0xd2, 0x59, 0xad, 0xd1, 0xdb, 0x85, 0x8b, 0x30, 0x6a, 0x1f, 0x9b, 0x9f, 0x28, 0x1a, 0x6e, 0x62,
+	0x07, 0x33, 0x47, 0x1c, 0x91, 0xf3, 0x04, 0xb6, 0xc1, 0x40, 0x52, 0x13, 0x8a, 0x81, 0x61, 0x2f,
+	0x46, 0xc8, 0x6c, 0xaa, 0x90, 0x77, 0x60, 0x7c, 0x13, 0x3b, 0x41, 0x4b, 0x5e, 0x81, 0x61, 0x82,
+	0xf1, 0xb7, 0xc9, 0x10, 0xf9, 0xac, 0x6a, 0xd2, 0xc7, 0x30, 0xe1, 0x91, 0xbe, 0x6c, 0xdb, 0xbd,
+	0x09, 0xc5, 0x83, 0xb6, 0xf6, 0xcb, 0xd6, 0x38, 0x38, 0xf0, 0x65, 0xcb, 0x79, 0x0f, 0x8a, 0x0f,
+	0x2c, 0x8c, 0x3f, 0xc5, 0x67, 0xb2, 0x60, 0x0b, 0x50, 0x90, 0xfa, 0x02, 0x84, 0x63, 0x4e, 0x75,
+	0x56, 0xe1, 0x82, 0xd4, 0x2f, 0x5b, 0xb8, 0xfb, 0x30, 0x79, 0x60, 0x68, 0x67, 0x17, 0xcf, 0x84,
+	0xa9, 0x30, 0xfd, 0xcb, 0x16, 0xf0, 0x2f, 0x02, 0xe4, 0xc8, 0xe7, 0x81, 0xad, 0x36, 0x70, 0xaa,
+	0x5c, 0xec, 0x78, 0xa0, 0xb2, 0xdb, 0x7e, 0xc2, 0xc8, 0xbe, 0xc9, 0x0d, 0xf7, 0xb0, 0xeb, 0x60,
+	0x5b, 0xd1, 0xdd, 0xa3, 0x63, 0x98, 0x7e, 0x57, 0x0d, 0x72, 0x24, 0x30, 0x94, 0xd9, 0x71, 0x78,
+	0x3a, 0xc5, 0x68, 0x77, 0x3b, 0x0e, 0x69, 0x68, 0xb3, 0x32, 0x97, 0xf2, 0x84, 0xb6, 0xc8, 0x68,
+	0x18, 0xcc, 0xca, 0xa3, 0x0c, 0xc8, 0xda, 0x66, 0xa4, 0xbe, 0xdd, 0xa1, 0x9e, 0x4e, 0x4b, 0x23,
+	0x4a, 0x8b, 0x68, 0xe0, 0x36, 0xd9, 0x0b, 0x0c, 0x43, 0x0a, 0x23, 0xdb, 0x14, 0x4e, 0x4e, 0x19,
+	0x0b, 0xd7, 0xb1, 0xe1, 0x28, 0x4f, 0xda, 0x36, 0xed, 0x6a, 0x0a, 0x72, 0x8e, 0x41, 0x1e, 0xb5,
+	0x6d, 0xb2, 0x1a, 0x7c, 0x6f, 0x53, 0x75, 0xfb, 0xae, 0xc6, 0x53, 0x98, 0x0a, 0xd3, 0x9f, 0x67,
+	0x35, 0xee, 0xc0, 0x60, 0x87, 0xcc, 0x12, 0x6f, 0x3c, 0xfb, 0x0c, 0x18, 0x85, 0xf4, 0x73, 0x06,
+	0x60, 0xb5, 0xa3, 0xe9, 0x4e, 0xe5, 0x29, 0x36, 0x1c, 0x52, 0xb4, 0x0c, 0x76, 0xf0, 0xd9, 0x07,
+	0xba, 0x0d, 0x13, 0xc9, 0xd5, 0xa2, 0x71, 0x27, 0x5c, 0x29, 0xba, 0x07, 0x03, 0x4e, 0xb7, 0xcd,
+	0xf2, 0x99, 0xf1, 0xe0, 0xdd, 0xcd, 0x67, 0x51, 0xeb, 0xb6, 0x89, 0x43, 0x74, 0xdb, 0x21, 0x17,
+	0x18, 0x08, 0xb9, 0xc0, 0x14, 0x0c, 0xaa, 0x75, 0xc7, 0xb4, 0xe8, 0x32, 0xe5, 0x64, 0xf6, 0x41,
+	0xba, 0xd9, 0x2d, 0xec, 0x1c, 0x9b, 0x6e, 0xa7, 0x99, 0x7f, 0x11, 0x6a, 0x6c, 0x59, 0xa6, 0x45,
+	0x17, 0x21, 0x27, 0xb3, 0x0f, 0x92, 0x9b, 0x90, 0x03, 0x59, 0xd7, 0x4a, 0x23, 0xf4, 0x64, 0x1c,
+	0x3c, 0xc1, 0xdd, 0xaa, 0x46, 0x26, 0xd1, 0xf4, 0x06, 0xb6, 0x9d, 0x52, 0x8e, 0x82, 0xf9, 0x57,
+	0xb8, 0x14, 0x04, 0x91, 0x1e, 0xdf, 0x2c, 0xe4, 0xe8, 0x11, 0x4a, 0xab, 0x27, 0x79, 0x56, 0x3d,
+	0x21, 0x00, 0xf7, 0xb1, 0x04, 0x1d, 0xe9, 0xe5, 0x34, 0xa3, 0xcc, 0xb7, 0x1c, 0xba, 0xa9, 0x18,
+	0x4c, 0x7a, 0x02, 0x97, 0xc9, 0x19, 0xe4, 0x9b, 0xc1, 0x3b, 0xc0, 0x22, 0x75, 0x64, 0x21, 0x56,
+	0x47, 0xbe, 0x0a, 0x40, 0x3a, 0xe8, 0x98, 0x8e, 0xa2, 0x76, 0x1f, 0x94, 0x73, 0x2d, 0xf5, 0x94,
+	0x4d, 0x13, 0x34, 0x62, 0x36, 0xe4, 0x51, 0x3f, 0x0a, 0x70, 0x25, 0xc6, 0xf3, 0x9c, 0x8d, 0x67,
+	0x4f, 0x88, 0x48, 0x97, 0xd1, 0xe7, 0x21, 0x73, 0x1a, 0x22, 0x36, 0x4d, 0x51, 0x98, 0x5a, 0x4c,
+	0xb4, 0x1c, 0x81, 0xb0, 0xde, 0xc8, 0x34, 0x4c, 0xca, 0xb8, 0x69, 0xaa, 0xda, 0xba, 0x69, 0x1c,
+	0xe9, 0x0d, 0x6e, 0x0d, 0xe9, 0x21, 0x4c, 0x85, 0xc1, 0xe7, 0x10, 0x78, 0x79, 0x19, 0xa6, 0x13,
+	0x9f, 0x0b, 0xa1, 0x21, 0xc8, 0xec, 0x3e, 0x2c, 0x5c, 0x42, 0x39, 0x18, 0xac, 0xc8, 0xf2, 0xae,
+	0x5c, 0x10, 0x96, 0x3f, 0x84, 0x42, 0xf4, 0x3d, 0x07, 0x9a, 0x07, 0xf1, 0x60, 0xe7, 0xe1, 0xce,
+	0xee, 0x7b, 0x3b, 0xca, 0xa3, 0x83, 0xca, 0x41, 0x65, 0x43, 0xd9, 0xaa, 0xac, 0x3e, 0x50, 0xf6,
+	0x6b, 0xab, 0xb5, 0x83, 0xfd, 0xc2, 0x25, 0x04, 0x30, 0xc4, 0xe0, 0x05, 0x01, 0x8d, 0x41, 0x6e,
+	0xe3, 0x60, 0x6f, 0xab, 0xba, 0xbe, 0x5a, 0xab, 0x14, 0x32, 0x68, 0x14, 0x46, 0xe4, 0xca, 0xbf,
+	0x56, 0xd6, 0x6b, 0x95, 0x8d, 0x42, 0x76, 0xf9, 0x2b, 0x01, 0x8a, 0xb1, 0x07, 0x15, 0xe8, 0x1a,
+	0xcc, 0xba, 0xd3, 0xd3, 0x79, 0xd9, 0x80, 0xea, 0xee, 0x8e, 0xb2, 0xbe, 0xbb, 0x51, 0x29, 0x5c,
+	0x42, 0x45, 0x18, 0xdb, 0xae, 0xee, 0xef, 0x57, 0x77, 0x36, 0x95, 0x07, 0xd5, 0xca, 0x16, 0x61,
+	0x53, 0x84, 0xb1, 0xea, 0xce, 0xe3, 0xd5, 0xad, 0xea, 0x06, 0x07, 0x65, 0x08, 0xe7, 0xda, 0xee,
+	0xae, 0xb2, 0xb5, 0x2a, 0x6f, 0x56, 0x0a, 0x59, 0x34, 0x0d, 0xc5, 0x07, 0xab, 0xd5, 0xad, 0xca,
+	0x86, 0x42, 0xc9, 0x56, 0xc9, 0x84, 0x85, 0x81, 0xe5, 0x8f, 0x60, 0x3c, 0xbc, 0x07, 0xd1, 0x1c,
+	0x94, 0x5c, 0xf6, 0xab, 0x07, 0x1b, 0xd5, 0x9a, 0x52, 0x79, 0x5c, 0xd9, 0xa9, 0x29, 0xb5, 0xf7,
+	0xf7, 0x08, 0xef, 0x31, 0xc8, 0xad, 0x6e, 0x6c, 0x57, 0x77, 0x14, 0x79, 0x6f, 0xbd, 0x20, 0x10,
+	0x7d, 0x1e, 0x56, 0xde, 0x57, 0x0e, 0xf6, 0x2b, 0x84, 0xe5, 0x24, 0x4c, 0x6c, 0xed, 0x6e, 0x2a,
+	0xf2, 0xee, 0x6e, 0x4d, 0xd9, 0xaf, 0x6e, 0xee, 0x10, 0x25, 0x57, 0xfe, 0x36, 0x0e, 0x79, 0xd7,
+	0xdc, 0x5b, 0x66, 0x03, 0x99, 0x90, 0x0f, 0xbc, 0xea, 0x40, 0x73, 0x91, 0x67, 0x0a, 0xa1, 0x1a,
+	0x92, 0x78, 0x35, 0x05, 0xcb, 0x96, 0x5f, 0xba, 0xfe, 0xcd, 0x1f, 0x7f, 0xfe, 0x21, 0x73, 0xf5,
+	0x1d, 0x61, 0x59, 0x2a, 0x95, 0x9f, 0xbe, 0x56, 0x6e, 0x9a, 0x0d, 0xbb, 0xfc, 0x8c, 0x55, 0x7c,
+	0x3f, 0x2f, 0xf3, 0x46, 0xcf, 0x77, 0x02, 0xa0, 0xf8, 0x63, 0x0b, 0x74, 0x3d, 0xe0, 0xa6, 0x69,
+	0x6f, 0x3d, 0xc4, 0x1b, 0xbd, 0x89, 0xb8, 0x18, 0xaf, 0x50, 0x31, 0x6e, 0x4b, 0x52, 0x9a, 0x0c,
+	0x65, 0xb7, 0xd3, 0xa9, 0xbd, 0x23, 0x2c, 0xa3, 0xff, 0x14, 0xa0, 0x18, 0x6b, 0xf7, 0x23, 0xc9,
+	0x67, 0x95, 0xf6, 0x32, 0x43, 0xbc, 0xde, 0x93, 0x86, 0x4b, 0xb3, 0x4c, 0xa5, 0xb9, 0x81, 0x12,
+	0xa4, 0x61, 0x77, 0xa7, 0xb2, 0x57, 0xf9, 0x44, 0xbf, 0x61, 0xcd, 0xc0, 0xa4, 0x97, 0x07, 0x68,
+	0xa9, 0x07, 0xb3, 0x50, 0x63, 0x5c, 0xbc, 0x73, 0x06, 0x4a, 0x2e, 0xdc, 0x0a, 0x15, 0xee, 0x1e,
+	0x5a, 0xee, 0x2f, 0x1c, 0xe9, 0x10, 0x93, 0xc8, 0x8a, 0xbe, 0x17, 0x60, 0x32, 0xe1, 0x49, 0x00,
+	0xba, 0x11, 0x62, 0x9b, 0xf2, 0x70, 0x41, 0xbc, 0xd9, 0x87, 0x8a, 0x0b, 0x76, 0x8f, 0x0a, 0x76,
+	0x0b, 0xdd, 0x48, 0x15, 0xac, 0xee, 0x0f, 0x45, 0x3f, 0xf0, 0x32, 0x47, 0xbc, 0x63, 0x84, 0x6e,
+	0x87, 0xf8, 0xa5, 0x37, 0xa3, 0xc4, 0xa5, 0xfe, 0x84, 0x5c, 0xb6, 0x5b, 0x54, 0xb6, 0x05, 0x34,
+	0x1f, 0x97, 0x8d, 0x1c, 0x37, 0x76, 0xb9, 0x49, 0x07, 0xa3, 0x2f, 0x60, 0x3c, 0xdc, 0x57, 0x41,
+	0xd7, 0x42, 0xfe, 0x1b, 0x6f, 0x06, 0x89, 0x0b, 0xe9, 0x04, 0x9c, 0xf9, 0x1d, 0xca, 0xfc, 0xba,
+	0x94, 0xc0, 0x3c, 0xd8, 0x70, 0x22, 0x8e, 0xfd, 0x3d, 0xab, 0x93, 0xc5, 0xbb, 0x7e, 0xe8, 0x56,
+	0x48, 0xd7, 0xd4, 0x6e, 0xa2, 0x78, 0xbb, 0x2f, 0x5d, 0x7f, 0x93, 0xf0, 0x2d, 0xc7, 0xfa, 0xaa,
+	0xcf, 0xa0, 0x10, 0xed, 0xb5, 0xa3, 0xc5, 0xb0, 0xe1, 0x13, 0x1a, 0xfb, 0xa2, 0xd4, 0x8b, 0x84,
+	0x8b, 0xb0, 0x40, 0x45, 0x10, 0x51, 0x7a, 0xe4, 0xf9, 0x5a, 0x80, 0x42, 0xb4, 0xc3, 0x9d, 0xc2,
+	0x3d, 0xd8, 0x86, 0x17, 0xa5, 0x5e, 0x24, 0x67, 0x36, 0x80, 0x45, 0xd9, 0x3d, 0x82, 0xd1, 0x60,
+	0xe7, 0x18, 0x05, 0x22, 0x6a, 0x42, 0x57, 0x5b, 0x9c, 0x4f, 0x43, 0x73, 0xb6, 0x97, 0x5e, 0x15,
+	0xd0, 0x57, 0x02, 0xbd, 0xa0, 0x06, 0x1f, 0xaa, 0xa0, 0x85, 0x44, 0x91, 0x83, 0x41, 0x62, 0xb1,
+	0x07, 0x05, 0x9f, 0x7c, 0x89, 0xea, 0x24, 0xa1, 0x85, 0x54, 0x9d, 0xdc, 0x90, 0xf0, 0xeb, 0xf0,
+	0x23, 0x86, 0xf0, 0x93, 0xd8, 0xe4, 0xb5, 0x8b, 0x3f, 0x68, 0x11, 0xef, 0x9c, 0x81, 0x92, 0x8b,
+	0xf6, 0x1a, 0x15, 0xed, 0x2e, 0xba, 0xd3, 0x4b, 0xb4, 0xd0, 0x0b, 0x18, 0xf4, 0x6f, 0x6c, 0xf5,
+	0x43, 0x8d, 0xc2, 0xc8, 0xea, 0x27, 0xf5, 0x2c, 0x45, 0xa9, 0x17, 0x49, 0x78, 0x53, 0xa2, 0xc5,
+	0xb8, 0x38, 0x98, 0x0c, 0x50, 0x54, 0x43, 0x63, 0x95, 0xb2, 0x95, 0xaf, 0x87, 0xfd, 0xf3, 0x77,
+	0x5b, 0x6d, 0xa3, 0x26, 0xe4, 0x3c, 0x65, 0x83, 0xde, 0x90, 0xd0, 0x01, 0x13, 0xe7, 0xd3, 0xd0,
+	0x49, 0x5b, 0xa0, 0xa5, 0xb6, 0xed, 0xf2, 0x33, 0x56, 0xf8, 0xf3, 0xb6, 0x80, 0x09, 0xb9, 0xfd,
+	0x24, 0x6e, 0xfb, 0xbd, 0xb9, 0x25, 0x75, 0x6d, 0xdc, 0xd3, 0x5e, 0x4a, 0xe5, 0x46, 0x62, 0x10,
+	0xdf, 0x73, 0xa1, 0x02, 0x59, 0xc4, 0xea, 0x49, 0xed, 0x18, 0x51, 0xea, 0x45, 0x92, 0xb4, 0xe7,
+	0xc2, 0x02, 0x84, 0xe2, 0xf0, 0x4f, 0xec, 0x29, 0x5d, 0x4a, 0xff, 0x02, 0xdd, 0x4d, 0x67, 0x15,
+	0x6b, 0x9f, 0x88, 0xf7, 0xce, 0x46, 0xcc, 0x25, 0xbc, 0x4b, 0x25, 0xbc, 0x89, 0xae, 0xa7, 0x49,
+	0x78, 0xd8, 0xf5, 0x2e, 0x24, 0xe8, 0x19, 0x2d, 0x49, 0x05, 0x4a, 0xf5, 0xc1, 0xe3, 0x22, 0xb1,
+	0xdf, 0x21, 0x2e, 0xa4, 0x13, 0xf4, 0xb7, 0x11, 0xeb, 0x34, 0x73, 0x56, 0x1f, 0xc0, 0x44, 0xa4,
+	0x90, 0x1d, 0x8c, 0x21, 0xc9, 0xb5, 0x75, 0x71, 0xb1, 0x07, 0x45, 0x20, 0x40, 0x7d, 0xc7, 0x12,
+	0xac, 0x70, 0x1d, 0x17, 0xdd, 0x4c, 0x76, 0xe6, 0x48, 0xf1, 0x5a, 0xbc, 0xd5, 0x8f, 0x2c, 0x29,
+	0xcd, 0x4a, 0xf2, 0xc6, 0xf2, 0xa1, 0x5b, 0xeb, 0x5e, 0xf9, 0xdd, 0x08, 0x8c, 0x79, 0x57, 0x0e,
+	0xad, 0xa5, 0x1b, 0xe8, 0x23, 0x00, 0xbf, 0x44, 0x8a, 0x02, 0xd7, 0x96, 0x58, 0xc5, 0x55, 0x9c,
+	0x4b, 0x46, 0x72, 0x31, 0xae, 0x50, 0x31, 0x8a, 0x52, 0x8e, 0x88, 0xe1, 0x58, 0x18, 0xdb, 0xef,
+	0xd0, 0xb2, 0x0a, 0x7a, 0x0f, 0x72, 0x5e, 0x79, 0x13, 0x05, 0x9e, 0xa7, 0x47, 0x4b, 0xa5, 0xe2,
+	0x6c, 0x22, 0x8e, 0x4f, 0x5f, 0xa4, 0xd3, 0xe7, 0x91, 0x3f, 0x3d, 0xfa, 0x10, 0x86, 0x79, 0x49,
+	0x02, 0x95, 0x42, 0xb6, 0x0a, 0x0a, 0x3d, 0x93, 0x80, 0xe1, 0x53, 0xce, 0xd2, 0x29, 0xa7, 0xd1,
+	0xa4, 0x37, 0x65, 0xf9, 0x19, 0xbf, 0xaa, 0x7e, 0x8e, 0x4c, 0x00, 0xbf, 0xac, 0x18, 0xb4, 0x4b,
+	0xac, 0x4a, 0x29, 0xce, 0x25, 0x23, 0xc3, 0x7e, 0x28, 0x96, 0x22, 0x5c, 0xee, 0xbb, 0xac, 0xb8,
+	0x99, 0x4e, 0x00, 0xfc, 0x52, 0x61, 0x90, 0x61, 0xac, 0xdc, 0x28, 0xce, 0x25, 0x23, 0x39, 0x43,
+	0x89, 0x32, 0x9c, 0x93, 0xc4, 0x04, 0xb5, 0xca, 0x47, 0x94, 0x1e, 0x69, 0x00, 0x7e, 0xe9, 0x2f,
+	0xc8, 0x2c, 0x56, 0x3e, 0x14, 0xe7, 0x92, 0x91, 0x61, 0x1b, 0x2e, 0x27, 0xda, 0xd0, 0x86, 0xd1,
+	0x60, 0x05, 0x2f, 0x18, 0x76, 0x13, 0x2a, 0x81, 0xe2, 0x7c, 0x1a, 0x9a, 0xf3, 0xba, 0x41, 0x79,
+	0xcd, 0x4b, 0x73, 0x49, 0x8a, 0x75, 0xf8, 0x08, 0xd4, 0x86, 0xd1, 0x60, 0xa1, 0x2a, 0x72, 0xb2,
+	0x44, 0x0b, 0x5e, 0xe2, 0x7c, 0x1a, 0x9a, 0x33, 0x5d, 0xa4, 0x4c, 0x67, 0xd1, 0x4c, 0x22, 0x53,
+	0xca, 0xa1, 0x0d, 0x13, 0x91, 0x3a, 0x46, 0x30, 0x82, 0x24, 0x97, 0x55, 0xc4, 0xc5, 0x1e, 0x14,
+	0x9c, 0x75, 0x89, 0xb2, 0x46, 0xa8, 0x40, 0x58, 0xab, 0x84, 0x80, 0x17, 0x59, 0xd0, 0x31, 0x8c,
+	0x06, 0xab, 0x10, 0x41, 0x1d, 0x13, 0x8a, 0x16, 0xe2, 0x7c, 0x1a, 0x9a, 0x33, 0x9a, 0xa1, 0x8c,
+	0x26, 0xa5, 0x22, 0x61, 0x54, 0xa7, 0xb8, 0xb2, 0x45, 0x09, 0xd7, 0xca, 0x30, 0x53, 0x37, 0x5b,
+	0xf7, 0xd9, 0xff, 0xa4, 0xee, 0x87, 0xff, 0x3e, 0xb5, 0x56, 0x08, 0x54, 0x2f, 0xe8, 0x93, 0x9f,
+	0x3d, 0xe1, 0x70, 0x88, 0xa2, 0x5e, 0xff, 0xfb, 0x00, 0x2b, 0x1b, 0xbe, 0xc3, 0xbf, 0x35, 0x00,
+	0x00,
 }
@@ -48,6 +48,9 @@ type Leaf struct {
 	LeafValue []byte
 	// ExtraData holds related contextual data, but this data is not included in any hash.
 	ExtraData []byte
+	// LeafIdentityHash optionally identifies the entry the leaf holds, so leaves can be looked
+	// up by it. It isn't covered by the tree.
+	LeafIdentityHash Hash
 }
 
 // LogLeaf represents data behind Log leaves.
@@ -58,6 +61,15 @@ type LogLeaf struct {
 	SignedEntryTimestamp SignedEntryTimestamp
 	// Sequencenumber holds the position in the log this leaf has been assigned to.
 	SequenceNumber int64
+	// Priority is the priority the leaf was queued with. Leaves with a higher priority are
+	// dequeued first.
+	Priority int32
+	// NotBeforeNanos is the earliest time the leaf can be integrated, in nanoseconds since the
+	// epoch, or 0 if it can be straight away. It's only set for leaves being queued.
+	NotBeforeNanos int64
+	// QueueTimestampNanos is when storage queued the leaf, in nanoseconds since the epoch. It's
+	// set on the leaves of normal logs as they're dequeued, and 0 otherwise.
+	QueueTimestampNanos int64
 }
 
 // Key is a map key.
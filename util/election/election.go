@@ -0,0 +1,45 @@
+package election
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNotMaster is returned by VerifyMaster when this instance isn't master for the tree
+var ErrNotMaster = errors.New("election: not master")
+
+// Election decides which of a set of replicas is master for each tree. Only the master for
+// a tree should sequence and sign it, so that replicas don't assign conflicting sequence
+// numbers or produce duplicate STHs.
+type Election interface {
+	// IsMaster returns true if this instance currently holds mastership for treeID. It
+	// should not block. An instance that isn't yet taking part in the election for a tree
+	// joins it and reports false until it wins.
+	IsMaster(treeID int64) (bool, error)
+	// VerifyMaster returns nil if this instance still holds mastership for treeID, checking
+	// with the source of truth rather than a cached state, so it may block. Sequencers call
+	// it before committing, in case mastership was lost while they were working.
+	VerifyMaster(ctx context.Context, treeID int64) error
+	// Close gives up any mastership held by this instance and leaves all elections
+	Close() error
+}
+
+// SingleNode is an Election for deployments with only one sequencer, which is always the
+// master for every tree.
+type SingleNode struct{}
+
+// IsMaster always returns true
+func (s SingleNode) IsMaster(treeID int64) (bool, error) {
+	return true, nil
+}
+
+// VerifyMaster always returns nil
+func (s SingleNode) VerifyMaster(ctx context.Context, treeID int64) error {
+	return nil
+}
+
+// Close does nothing as there is no election to leave
+func (s SingleNode) Close() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/election"
+	"golang.org/x/net/context"
+)
+
+// retryInterval is how long to wait before campaigning again after an etcd failure
+const retryInterval = time.Second
+
+// ErrClosed is returned by an Election after Close has been called
+var ErrClosed = errors.New("etcd: election is closed")
+
+// Election is an election.Election where the master for each tree is chosen using etcd. All
+// instances using the same key prefix compete, and mastership is held through a lease that
+// lapses if the master stops refreshing it.
+type Election struct {
+	client     *clientv3.Client
+	instanceID string
+	prefix     string
+	ttlSeconds int
+
+	mutex  sync.Mutex
+	trees  map[int64]*treeElection
+	closed bool
+}
+
+// treeElection tracks this instance's part in the election for a single tree
+type treeElection struct {
+	mutex  sync.Mutex
+	master bool
+	// leaderKey and leaderRev are the key this instance holds mastership through and the
+	// revision it was created at, while it's master. The key is deleted when the lease
+	// lapses, so finding it at that revision shows mastership hasn't been lost since.
+	leaderKey string
+	leaderRev int64
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func (t *treeElection) setMaster(key string, rev int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.master = true
+	t.leaderKey = key
+	t.leaderRev = rev
+}
+
+func (t *treeElection) clearMaster() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.master = false
+	t.leaderKey = ""
+	t.leaderRev = 0
+}
+
+func (t *treeElection) isMaster() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.master
+}
+
+func (t *treeElection) leader() (string, int64, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.leaderKey, t.leaderRev, t.master
+}
+
+// NewElection creates an Election that campaigns as instanceID under keys starting with
+// prefix. A master that fails to refresh its lease for ttlSeconds loses mastership.
+func NewElection(client *clientv3.Client, instanceID, prefix string, ttlSeconds int) (*Election, error) {
+	if len(instanceID) == 0 {
+		return nil, errors.New("etcd: an instance id is required")
+	}
+
+	if ttlSeconds <= 0 {
+		return nil, fmt.Errorf("etcd: lease ttl must be > 0 but was %d", ttlSeconds)
+	}
+
+	return &Election{client: client, instanceID: instanceID, prefix: prefix, ttlSeconds: ttlSeconds, trees: make(map[int64]*treeElection)}, nil
+}
+
+// IsMaster returns true if this instance has won the election for treeID. The first call
+// for a tree starts campaigning for it in the background.
+func (e *Election) IsMaster(treeID int64) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return false, ErrClosed
+	}
+
+	t, ok := e.trees[treeID]
+
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		t = &treeElection{cancel: cancel, done: make(chan struct{})}
+		e.trees[treeID] = t
+
+		go e.campaign(ctx, treeID, t)
+		return false, nil
+	}
+
+	return t.isMaster(), nil
+}
+
+// VerifyMaster reads the key this instance won the election for treeID with back from etcd,
+// and returns election.ErrNotMaster unless it's still there at the revision it was created at.
+// A master whose lease has lapsed but hasn't noticed yet fails this check.
+func (e *Election) VerifyMaster(ctx context.Context, treeID int64) error {
+	e.mutex.Lock()
+	t, ok := e.trees[treeID]
+	closed := e.closed
+	e.mutex.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	if !ok {
+		return election.ErrNotMaster
+	}
+
+	key, rev, master := t.leader()
+
+	if !master {
+		return election.ErrNotMaster
+	}
+
+	// Reads are linearizable by default, so a key deleted by another member is seen as gone
+	resp, err := e.client.Get(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("etcd: failed to read leader key for tree %d: %v", treeID, err)
+	}
+
+	if len(resp.Kvs) != 1 || resp.Kvs[0].CreateRevision != rev {
+		return election.ErrNotMaster
+	}
+
+	return nil
+}
+
+// Close resigns from all the elections this instance is taking part in.
+func (e *Election) Close() error {
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return nil
+	}
+	e.closed = true
+	trees := e.trees
+	e.mutex.Unlock()
+
+	for _, t := range trees {
+		t.cancel()
+		<-t.done
+	}
+
+	return nil
+}
+
+// campaign runs the election for a tree until ctx is cancelled, campaigning again whenever
+// mastership is lost.
+func (e *Election) campaign(ctx context.Context, treeID int64, t *treeElection) {
+	defer close(t.done)
+
+	key := fmt.Sprintf("%s/%d", e.prefix, treeID)
+
+	for {
+		if err := e.campaignOnce(ctx, key, t); err != nil && ctx.Err() == nil {
+			glog.Warningf("Election for tree %d failed: %v", treeID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// campaignOnce waits to become master for key and then holds mastership until the lease
+// is lost or ctx is cancelled.
+func (e *Election) campaignOnce(ctx context.Context, key string, t *treeElection) error {
+	// The session isn't tied to ctx so that closing it can still revoke the lease after ctx
+	// has been cancelled
+	session, err := concurrency.NewSession(e.client, concurrency.WithTTL(e.ttlSeconds))
+
+	if err != nil {
+		return err
+	}
+
+	defer session.Close()
+
+	etcdElection := concurrency.NewElection(session, key)
+
+	if err := etcdElection.Campaign(ctx, e.instanceID); err != nil {
+		return err
+	}
+
+	glog.Infof("Instance %s became master for %s", e.instanceID, key)
+	t.setMaster(etcdElection.Key(), etcdElection.Rev())
+	defer t.clearMaster()
+
+	select {
+	case <-session.Done():
+		return fmt.Errorf("lost lease for %s", key)
+	case <-ctx.Done():
+		// Hand over promptly rather than leaving the other instances to wait for the lease
+		// to expire
+		t.clearMaster()
+		resignCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(e.ttlSeconds))
+		defer cancel()
+
+		return etcdElection.Resign(resignCtx)
+	}
+}
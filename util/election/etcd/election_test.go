@@ -0,0 +1,347 @@
+package etcd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/integration"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/election"
+	"golang.org/x/net/context"
+)
+
+const (
+	testPrefix = "trillian/master"
+	testTreeID = int64(1)
+	// waitTimeout bounds how long a test waits for an election to change hands, which
+	// takes at least retryInterval when an instance has to campaign again
+	waitTimeout = 10 * time.Second
+)
+
+// newTestCluster starts an etcd server for a single test, which is stopped when it ends
+func newTestCluster(t *testing.T) (*integration.ClusterV3, *clientv3.Client) {
+	cluster := integration.NewClusterV3(t, &integration.ClusterConfig{Size: 1})
+	return cluster, cluster.RandClient()
+}
+
+func newTestElection(t *testing.T, client *clientv3.Client, instanceID string) *Election {
+	e, err := NewElection(client, instanceID, testPrefix, 5)
+	if err != nil {
+		t.Fatalf("NewElection() = %v", err)
+	}
+
+	return e
+}
+
+// waitForMaster waits until IsMaster returns want for e, failing the test if it doesn't
+// within waitTimeout
+func waitForMaster(t *testing.T, e *Election, want bool) {
+	deadline := time.Now().Add(waitTimeout)
+
+	for {
+		got, err := e.IsMaster(testTreeID)
+		if err != nil {
+			t.Fatalf("IsMaster() = %v", err)
+		}
+
+		if got == want {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("IsMaster() = %v after %v, want %v", got, waitTimeout, want)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// masterKey returns the key e holds mastership of the test tree through, and the revision
+// it was created at
+func masterKey(t *testing.T, e *Election) (string, int64) {
+	e.mutex.Lock()
+	te, ok := e.trees[testTreeID]
+	e.mutex.Unlock()
+
+	if !ok {
+		t.Fatal("No election for the test tree")
+	}
+
+	key, rev, master := te.leader()
+	if !master {
+		t.Fatal("Not master for the test tree")
+	}
+
+	return key, rev
+}
+
+// revokeLease revokes the lease the current master of the test tree holds mastership
+// through, as happens when it fails to refresh the lease in time
+func revokeLease(t *testing.T, client *clientv3.Client) {
+	ctx := context.Background()
+
+	resp, err := client.Get(ctx, fmt.Sprintf("%s/%d", testPrefix, testTreeID), clientv3.WithFirstCreate()...)
+	if err != nil {
+		t.Fatalf("Failed to read leader key: %v", err)
+	}
+
+	if len(resp.Kvs) != 1 {
+		t.Fatalf("Found %d leader keys, want 1", len(resp.Kvs))
+	}
+
+	if _, err := client.Revoke(ctx, clientv3.LeaseID(resp.Kvs[0].Lease)); err != nil {
+		t.Fatalf("Failed to revoke lease: %v", err)
+	}
+}
+
+func TestNewElectionRejectsBadConfig(t *testing.T) {
+	for _, test := range []struct {
+		instanceID string
+		ttlSeconds int
+	}{
+		{instanceID: "", ttlSeconds: 5},
+		{instanceID: "a", ttlSeconds: 0},
+		{instanceID: "a", ttlSeconds: -1},
+	} {
+		if _, err := NewElection(nil, test.instanceID, testPrefix, test.ttlSeconds); err == nil {
+			t.Errorf("NewElection(%q, %d) = nil, want error", test.instanceID, test.ttlSeconds)
+		}
+	}
+}
+
+func TestElectionWon(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	e := newTestElection(t, client, "a")
+	defer e.Close()
+
+	// The first call only joins the election
+	if isMaster, err := e.IsMaster(testTreeID); err != nil || isMaster {
+		t.Fatalf("IsMaster() = %v, %v before campaigning, want false, nil", isMaster, err)
+	}
+
+	waitForMaster(t, e, true)
+
+	if err := e.VerifyMaster(context.Background(), testTreeID); err != nil {
+		t.Errorf("VerifyMaster() = %v, want nil", err)
+	}
+
+	if err := e.VerifyMaster(context.Background(), testTreeID+1); err != election.ErrNotMaster {
+		t.Errorf("VerifyMaster() for a tree it isn't campaigning for = %v, want %v", err, election.ErrNotMaster)
+	}
+}
+
+func TestElectionOnlyOneMaster(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	a := newTestElection(t, client, "a")
+	defer a.Close()
+	waitForMaster(t, a, true)
+
+	b := newTestElection(t, client, "b")
+	defer b.Close()
+	b.IsMaster(testTreeID)
+
+	// Give b time to campaign, it should wait behind a
+	time.Sleep(500 * time.Millisecond)
+
+	if isMaster, _ := b.IsMaster(testTreeID); isMaster {
+		t.Error("IsMaster() = true for a second instance")
+	}
+
+	if err := b.VerifyMaster(context.Background(), testTreeID); err != election.ErrNotMaster {
+		t.Errorf("VerifyMaster() for a second instance = %v, want %v", err, election.ErrNotMaster)
+	}
+}
+
+func TestElectionLostLeadership(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	a := newTestElection(t, client, "a")
+	defer a.Close()
+	waitForMaster(t, a, true)
+
+	b := newTestElection(t, client, "b")
+	defer b.Close()
+	b.IsMaster(testTreeID)
+
+	// Losing the lease hands mastership to the instance waiting behind a
+	revokeLease(t, client)
+	waitForMaster(t, b, true)
+	waitForMaster(t, a, false)
+
+	if err := a.VerifyMaster(context.Background(), testTreeID); err != election.ErrNotMaster {
+		t.Errorf("VerifyMaster() after losing leadership = %v, want %v", err, election.ErrNotMaster)
+	}
+
+	if err := b.VerifyMaster(context.Background(), testTreeID); err != nil {
+		t.Errorf("VerifyMaster() for the new master = %v, want nil", err)
+	}
+}
+
+func TestElectionVerifiesRevisionAfterReelection(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	e := newTestElection(t, client, "a")
+	defer e.Close()
+	waitForMaster(t, e, true)
+
+	oldKey, oldRev := masterKey(t, e)
+
+	// Having lost the lease the instance campaigns again, and wins as it's the only one
+	revokeLease(t, client)
+	waitForMaster(t, e, false)
+	waitForMaster(t, e, true)
+
+	key, rev := masterKey(t, e)
+	if rev <= oldRev {
+		t.Errorf("Got leader key %s at revision %d after re-election, want later than %s at %d", key, rev, oldKey, oldRev)
+	}
+
+	if err := e.VerifyMaster(context.Background(), testTreeID); err != nil {
+		t.Errorf("VerifyMaster() after re-election = %v, want nil", err)
+	}
+}
+
+func TestElectionFailsVerifyAtStaleRevision(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	e := newTestElection(t, client, "a")
+	defer e.Close()
+	waitForMaster(t, e, true)
+
+	ctx := context.Background()
+	key, _ := masterKey(t, e)
+
+	resp, err := client.Get(ctx, key)
+	if err != nil || len(resp.Kvs) != 1 {
+		t.Fatalf("Failed to read leader key: %v", err)
+	}
+
+	// The key is recreated under the same lease, so the instance still believes it's master
+	// but the key it won with is gone
+	if _, err := client.Delete(ctx, key); err != nil {
+		t.Fatalf("Failed to delete leader key: %v", err)
+	}
+
+	if _, err := client.Put(ctx, key, string(resp.Kvs[0].Value), clientv3.WithLease(clientv3.LeaseID(resp.Kvs[0].Lease))); err != nil {
+		t.Fatalf("Failed to recreate leader key: %v", err)
+	}
+
+	if isMaster, _ := e.IsMaster(testTreeID); !isMaster {
+		t.Fatal("IsMaster() = false, want the instance to still believe it's master")
+	}
+
+	if err := e.VerifyMaster(ctx, testTreeID); err != election.ErrNotMaster {
+		t.Errorf("VerifyMaster() at a stale revision = %v, want %v", err, election.ErrNotMaster)
+	}
+}
+
+func TestElectionClosed(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	e := newTestElection(t, client, "a")
+	waitForMaster(t, e, true)
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	if _, err := e.IsMaster(testTreeID); err != ErrClosed {
+		t.Errorf("IsMaster() after Close() = %v, want %v", err, ErrClosed)
+	}
+
+	if err := e.VerifyMaster(context.Background(), testTreeID); err != ErrClosed {
+		t.Errorf("VerifyMaster() after Close() = %v, want %v", err, ErrClosed)
+	}
+
+	// Closing resigns, so the key is gone without waiting for the lease to expire
+	resp, err := client.Get(context.Background(), testPrefix, clientv3.WithPrefix())
+	if err != nil {
+		t.Fatalf("Failed to read leader keys: %v", err)
+	}
+
+	if len(resp.Kvs) != 0 {
+		t.Errorf("Found %d leader keys after Close(), want 0", len(resp.Kvs))
+	}
+}
+
+func TestSequencerDoesNotCommitWhenVerifyMasterFails(t *testing.T) {
+	cluster, client := newTestCluster(t)
+	defer cluster.Terminate(t)
+
+	e := newTestElection(t, client, "a")
+	defer e.Close()
+	waitForMaster(t, e, true)
+
+	ctx := context.Background()
+
+	p, err := storage.NewProvider(memory.ProviderName, t.Name())
+	if err != nil {
+		t.Fatalf("Failed to create memory storage: %v", err)
+	}
+
+	s, err := p.LogStorage(trillian.LogID{LogID: []byte("elected"), TreeID: testTreeID})
+	if err != nil {
+		t.Fatalf("Failed to create log storage: %v", err)
+	}
+
+	hasher, err := merkle.NewTreeHasher(trillian.HashAlgorithm_SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	km := crypto.NewPEMKeyManager()
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, s, km)
+	sequencer.SetMastershipCheck(func(ctx context.Context) error {
+		return e.VerifyMaster(ctx, testTreeID)
+	})
+
+	if err := sequencer.SignRoot(ctx); err != nil {
+		t.Fatalf("SignRoot() as master = %v", err)
+	}
+
+	// Mastership lapses without the instance noticing, so only the check with etcd stops it
+	key, _ := masterKey(t, e)
+	if _, err := client.Delete(ctx, key); err != nil {
+		t.Fatalf("Failed to delete leader key: %v", err)
+	}
+
+	if err := sequencer.SignRoot(ctx); err != election.ErrNotMaster {
+		t.Errorf("SignRoot() after losing mastership = %v, want %v", err, election.ErrNotMaster)
+	}
+
+	tx, err := s.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSignedLogRoot() = %v", err)
+	}
+
+	if got, want := root.TreeRevision, int64(1); got != want {
+		t.Errorf("Got root at revision %d, want %d from the only root signed as master", got, want)
+	}
+}
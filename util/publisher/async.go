@@ -0,0 +1,70 @@
+package publisher
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+)
+
+// publishDrops counts the roots an Async publisher dropped because its queue was full.
+var publishDrops = expvar.NewInt("publisher_drops")
+
+// ErrQueueFull is returned by an Async publisher for a root it dropped because too many
+// were already waiting to be published.
+var ErrQueueFull = errors.New("publisher: queue is full, root dropped")
+
+// Async wraps a Publisher so that roots are published in the background, one at a time in
+// the order they were queued, and a slow destination never holds up the signer. Roots that
+// arrive while the queue is full are dropped, they can still be read through the API.
+type Async struct {
+	publisher Publisher
+	queue     chan func() error
+}
+
+// NewAsync creates an Async publisher that holds up to queueSize roots waiting to be
+// published by p, and starts publishing them.
+func NewAsync(p Publisher, queueSize int) (*Async, error) {
+	if queueSize < 1 {
+		return nil, fmt.Errorf("publish queue size must be >= 1 but was %d", queueSize)
+	}
+
+	a := &Async{publisher: p, queue: make(chan func() error, queueSize)}
+	go a.run()
+
+	return a, nil
+}
+
+// PublishLogRoot queues root to be published
+func (a *Async) PublishLogRoot(root trillian.SignedLogRoot) error {
+	return a.enqueue(func() error {
+		return a.publisher.PublishLogRoot(root)
+	})
+}
+
+// PublishMapRoot queues root to be published
+func (a *Async) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return a.enqueue(func() error {
+		return a.publisher.PublishMapRoot(root)
+	})
+}
+
+func (a *Async) enqueue(publish func() error) error {
+	select {
+	case a.queue <- publish:
+		return nil
+	default:
+		publishDrops.Add(1)
+		return ErrQueueFull
+	}
+}
+
+func (a *Async) run() {
+	for publish := range a.queue {
+		if err := publish(); err != nil {
+			glog.Warningf("Failed to publish root: %v", err)
+		}
+	}
+}
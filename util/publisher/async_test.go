@@ -0,0 +1,90 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+// blockingPublisher sends each log root it's given to roots, then blocks until release is
+// closed
+type blockingPublisher struct {
+	roots   chan trillian.SignedLogRoot
+	release chan struct{}
+}
+
+func newBlockingPublisher() *blockingPublisher {
+	return &blockingPublisher{roots: make(chan trillian.SignedLogRoot), release: make(chan struct{})}
+}
+
+func (b *blockingPublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	b.roots <- root
+	<-b.release
+	return nil
+}
+
+func (b *blockingPublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return nil
+}
+
+func TestNewAsyncRejectsBadConfig(t *testing.T) {
+	if _, err := NewAsync(None{}, 0); err == nil {
+		t.Errorf("Created async publisher with no queue")
+	}
+}
+
+func TestAsyncPublishesInOrder(t *testing.T) {
+	p := newBlockingPublisher()
+	close(p.release)
+	a, err := NewAsync(p, 2)
+	if err != nil {
+		t.Fatalf("Failed to create async publisher: %v", err)
+	}
+
+	for rev := int64(1); rev <= 2; rev++ {
+		root := logRoot
+		root.TreeRevision = rev
+		if err := a.PublishLogRoot(root); err != nil {
+			t.Fatalf("Failed to queue root %d: %v", rev, err)
+		}
+	}
+
+	for rev := int64(1); rev <= 2; rev++ {
+		select {
+		case root := <-p.roots:
+			if root.TreeRevision != rev {
+				t.Errorf("Published root %d, want %d", root.TreeRevision, rev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Root %d wasn't published", rev)
+		}
+	}
+}
+
+func TestAsyncDropsWhenQueueIsFull(t *testing.T) {
+	p := newBlockingPublisher()
+	defer close(p.release)
+	a, err := NewAsync(p, 1)
+	if err != nil {
+		t.Fatalf("Failed to create async publisher: %v", err)
+	}
+	drops := publishDrops.Value()
+
+	// The worker is held up publishing the first root and the second fills the queue, so
+	// the third is dropped rather than holding up the caller
+	if err := a.PublishLogRoot(logRoot); err != nil {
+		t.Fatalf("Failed to queue root: %v", err)
+	}
+	<-p.roots
+	if err := a.PublishLogRoot(logRoot); err != nil {
+		t.Fatalf("Failed to queue root: %v", err)
+	}
+	if err := a.PublishLogRoot(logRoot); err != ErrQueueFull {
+		t.Errorf("PublishLogRoot()=%v with a full queue, want %v", err, ErrQueueFull)
+	}
+
+	if got, want := publishDrops.Value(), drops+1; got != want {
+		t.Errorf("Got %d drops, want %d", got, want)
+	}
+}
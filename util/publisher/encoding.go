@@ -0,0 +1,33 @@
+package publisher
+
+import (
+	"bytes"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+// Published roots are encoded as a JSON object with a single field naming the type of
+// root, e.g. {"logRoot":{...}}, so that log and map roots can share a destination.
+func encodeLogRoot(root trillian.SignedLogRoot) ([]byte, error) {
+	return encodeRoot("logRoot", &root)
+}
+
+func encodeMapRoot(root trillian.SignedMapRoot) ([]byte, error) {
+	return encodeRoot("mapRoot", &root)
+}
+
+func encodeRoot(field string, root proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"` + field + `":`)
+
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, root); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,61 @@
+package publisher
+
+import (
+	"os"
+	"sync"
+
+	"github.com/google/trillian"
+)
+
+// FilePublisher appends each root to a file as a line of JSON
+type FilePublisher struct {
+	path string
+	// mu stops concurrent publishes interleaving their writes
+	mu sync.Mutex
+}
+
+// NewFilePublisher creates a FilePublisher that appends to the file at path, creating it if
+// necessary.
+func NewFilePublisher(path string) *FilePublisher {
+	return &FilePublisher{path: path}
+}
+
+// PublishLogRoot appends root to the file
+func (f *FilePublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	data, err := encodeLogRoot(root)
+
+	if err != nil {
+		return err
+	}
+
+	return f.appendLine(data)
+}
+
+// PublishMapRoot appends root to the file
+func (f *FilePublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	data, err := encodeMapRoot(root)
+
+	if err != nil {
+		return err
+	}
+
+	return f.appendLine(data)
+}
+
+func (f *FilePublisher) appendLine(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
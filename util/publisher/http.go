@@ -0,0 +1,63 @@
+package publisher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/trillian"
+)
+
+// HTTPPublisher POSTs each root as JSON to a URL, e.g. a gossip endpoint. Any response other
+// than a 2xx status is treated as a failure.
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPPublisher creates an HTTPPublisher that sends roots to url using client
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	return &HTTPPublisher{url: url, client: client}
+}
+
+// PublishLogRoot POSTs root to the URL
+func (h *HTTPPublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	data, err := encodeLogRoot(root)
+
+	if err != nil {
+		return err
+	}
+
+	return h.post(data)
+}
+
+// PublishMapRoot POSTs root to the URL
+func (h *HTTPPublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	data, err := encodeMapRoot(root)
+
+	if err != nil {
+		return err
+	}
+
+	return h.post(data)
+}
+
+func (h *HTTPPublisher) post(data []byte) error {
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	// Drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("publishing to %s failed: %s", h.url, resp.Status)
+	}
+
+	return nil
+}
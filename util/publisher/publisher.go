@@ -0,0 +1,99 @@
+package publisher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+// Publisher distributes signed roots to interested parties, e.g. by writing them to a file or
+// sending them to a gossip endpoint. It's called by the signer after each new root has been
+// committed to storage so failing to publish a root doesn't undo it.
+type Publisher interface {
+	// PublishLogRoot makes a new signed log root available
+	PublishLogRoot(root trillian.SignedLogRoot) error
+	// PublishMapRoot makes a new signed map root available
+	PublishMapRoot(root trillian.SignedMapRoot) error
+}
+
+// None is a Publisher for deployments where roots are only read through the API
+type None struct{}
+
+// PublishLogRoot does nothing
+func (n None) PublishLogRoot(root trillian.SignedLogRoot) error {
+	return nil
+}
+
+// PublishMapRoot does nothing
+func (n None) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return nil
+}
+
+// Multi publishes each root to all of its Publishers. A failure of one doesn't stop the root
+// being published to the rest, the first error is returned.
+type Multi []Publisher
+
+// PublishLogRoot publishes root to every Publisher
+func (m Multi) PublishLogRoot(root trillian.SignedLogRoot) error {
+	var firstErr error
+
+	for _, p := range m {
+		if err := p.PublishLogRoot(root); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// PublishMapRoot publishes root to every Publisher
+func (m Multi) PublishMapRoot(root trillian.SignedMapRoot) error {
+	var firstErr error
+
+	for _, p := range m {
+		if err := p.PublishMapRoot(root); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// NewFromURIs creates a Publisher for a comma separated list of destinations. Each one is
+// either a file:// URI, which roots are appended to, or an http:// or https:// URL that roots
+// are POSTed to with the given request timeout. An empty list publishes nowhere. Other
+// destinations can be supported by implementing Publisher.
+func NewFromURIs(uris string, timeout time.Duration) (Publisher, error) {
+	if len(uris) == 0 {
+		return None{}, nil
+	}
+
+	publishers := Multi{}
+
+	for _, uri := range strings.Split(uris, ",") {
+		u, err := url.Parse(uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("bad publisher URI %q: %v", uri, err)
+		}
+
+		switch u.Scheme {
+		case "file":
+			if len(u.Path) == 0 {
+				return nil, fmt.Errorf("publisher URI %q has no path", uri)
+			}
+
+			publishers = append(publishers, NewFilePublisher(u.Path))
+		case "http", "https":
+			publishers = append(publishers, NewHTTPPublisher(uri, &http.Client{Timeout: timeout}))
+		default:
+			return nil, fmt.Errorf("unknown scheme for publisher URI %q", uri)
+		}
+	}
+
+	return publishers, nil
+}
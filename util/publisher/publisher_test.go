@@ -0,0 +1,195 @@
+package publisher
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+var logRoot = trillian.SignedLogRoot{LogId: []byte("log"), TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte("root"), Signature: &trillian.DigitallySigned{Signature: []byte("signed")}}
+var mapRoot = trillian.SignedMapRoot{MapId: []byte("map"), TimestampNanos: 98765, MapRevision: 3, RootHash: []byte("root"), Signature: &trillian.DigitallySigned{Signature: []byte("signed")}}
+
+// recordingPublisher counts publishes and fails the first failures of them
+type recordingPublisher struct {
+	logRoots int
+	mapRoots int
+	failures int
+}
+
+func (r *recordingPublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	r.logRoots++
+	return r.result()
+}
+
+func (r *recordingPublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	r.mapRoots++
+	return r.result()
+}
+
+func (r *recordingPublisher) result() error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("publish failed")
+	}
+
+	return nil
+}
+
+// publishedLogRoot decodes a published log root
+func publishedLogRoot(data string, t *testing.T) trillian.SignedLogRoot {
+	prefix := `{"logRoot":`
+
+	if !strings.HasPrefix(data, prefix) || !strings.HasSuffix(data, "}") {
+		t.Fatalf("Published data is not a log root: %s", data)
+	}
+
+	var root trillian.SignedLogRoot
+
+	if err := jsonpb.UnmarshalString(strings.TrimSuffix(strings.TrimPrefix(data, prefix), "}"), &root); err != nil {
+		t.Fatalf("Failed to decode published root %s: %v", data, err)
+	}
+
+	return root
+}
+
+func TestMultiPublishesToAll(t *testing.T) {
+	failing := &recordingPublisher{failures: 2}
+	working := &recordingPublisher{}
+	m := Multi{failing, working}
+
+	if err := m.PublishLogRoot(logRoot); err == nil {
+		t.Errorf("Multi hid a publish failure")
+	}
+
+	if err := m.PublishMapRoot(mapRoot); err == nil {
+		t.Errorf("Multi hid a publish failure")
+	}
+
+	if err := m.PublishLogRoot(logRoot); err != nil {
+		t.Errorf("Multi failed to publish: %v", err)
+	}
+
+	if working.logRoots != 2 || working.mapRoots != 1 {
+		t.Errorf("Got %d log and %d map roots, want 2 and 1", working.logRoots, working.mapRoots)
+	}
+}
+
+func TestNewFromURIs(t *testing.T) {
+	p, err := NewFromURIs("", time.Second)
+
+	if _, ok := p.(None); err != nil || !ok {
+		t.Errorf("Got %v, %v for no URIs, want None", p, err)
+	}
+
+	p, err = NewFromURIs("file:///tmp/roots,http://localhost/roots,https://localhost/roots", time.Second)
+
+	if err != nil {
+		t.Fatalf("Failed to create publishers: %v", err)
+	}
+
+	if got, want := len(p.(Multi)), 3; got != want {
+		t.Errorf("Got %d publishers, want %d", got, want)
+	}
+
+	for _, bad := range []string{"pubsub://topic", "/tmp/roots", "file://", "http://localhost,:bad"} {
+		if _, err := NewFromURIs(bad, time.Second); err == nil {
+			t.Errorf("Created publisher for bad URIs: %s", bad)
+		}
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publisher")
+
+	if err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "roots")
+	p := NewFilePublisher(path)
+
+	for _, publish := range []func() error{
+		func() error { return p.PublishLogRoot(logRoot) },
+		func() error { return p.PublishMapRoot(mapRoot) },
+	} {
+		if err := publish(); err != nil {
+			t.Fatalf("Failed to publish root: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		t.Fatalf("Failed to open published roots: %v", err)
+	}
+
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("Got %d published lines, want %d", got, want)
+	}
+
+	if root := publishedLogRoot(lines[0], t); !proto.Equal(&root, &logRoot) {
+		t.Errorf("Published root %v, want %v", root, logRoot)
+	}
+
+	if !strings.HasPrefix(lines[1], `{"mapRoot":`) {
+		t.Errorf("Published data is not a map root: %s", lines[1])
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	bodies := []string{}
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Got %s request, want POST", r.Method)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := NewHTTPPublisher(server.URL, http.DefaultClient)
+
+	if err := p.PublishLogRoot(logRoot); err != nil {
+		t.Fatalf("Failed to publish root: %v", err)
+	}
+
+	if len(bodies) != 1 {
+		t.Fatalf("Got %d requests, want 1", len(bodies))
+	}
+
+	if root := publishedLogRoot(bodies[0], t); !proto.Equal(&root, &logRoot) {
+		t.Errorf("Published root %v, want %v", root, logRoot)
+	}
+
+	status = http.StatusServiceUnavailable
+
+	if err := p.PublishMapRoot(mapRoot); err == nil {
+		t.Errorf("Publish succeeded when the server returned %d", status)
+	}
+}
@@ -0,0 +1,82 @@
+package publisher
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+)
+
+var (
+	// rootsPublished counts the roots successfully published by a Retrying publisher.
+	rootsPublished = expvar.NewInt("publisher_roots_published")
+	// publishRetries counts the attempts to publish a root after the first one failed.
+	publishRetries = expvar.NewInt("publisher_retries")
+	// publishFailures counts the roots that were given up on after all attempts failed.
+	publishFailures = expvar.NewInt("publisher_failures")
+)
+
+// Retrying wraps a Publisher so that failures are retried, backing off exponentially between
+// attempts, and keeps metrics on how publishing is going.
+type Retrying struct {
+	publisher Publisher
+	attempts  int
+	backoff   time.Duration
+	// sleep is replaced in tests
+	sleep func(time.Duration)
+}
+
+// NewRetrying creates a Retrying publisher that tries to publish each root up to attempts
+// times, waiting backoff after the first failure and doubling the wait after each one after
+// that.
+func NewRetrying(p Publisher, attempts int, backoff time.Duration) (*Retrying, error) {
+	if attempts < 1 {
+		return nil, fmt.Errorf("publish attempts must be >= 1 but was %d", attempts)
+	}
+
+	if backoff < 0 {
+		return nil, fmt.Errorf("publish backoff must be >= 0 but was %v", backoff)
+	}
+
+	return &Retrying{publisher: p, attempts: attempts, backoff: backoff, sleep: time.Sleep}, nil
+}
+
+// PublishLogRoot publishes root, retrying on failure
+func (r *Retrying) PublishLogRoot(root trillian.SignedLogRoot) error {
+	return r.retry(fmt.Sprintf("log root %d of log %s", root.TreeRevision, root.LogId), func() error {
+		return r.publisher.PublishLogRoot(root)
+	})
+}
+
+// PublishMapRoot publishes root, retrying on failure
+func (r *Retrying) PublishMapRoot(root trillian.SignedMapRoot) error {
+	return r.retry(fmt.Sprintf("map root %d of map %s", root.MapRevision, root.MapId), func() error {
+		return r.publisher.PublishMapRoot(root)
+	})
+}
+
+func (r *Retrying) retry(desc string, publish func() error) error {
+	backoff := r.backoff
+	var err error
+
+	for attempt := 1; attempt <= r.attempts; attempt++ {
+		if attempt > 1 {
+			publishRetries.Add(1)
+			r.sleep(backoff)
+			backoff *= 2
+		}
+
+		if err = publish(); err == nil {
+			rootsPublished.Add(1)
+			return nil
+		}
+
+		glog.Warningf("Attempt %d to publish %s failed: %v", attempt, desc, err)
+	}
+
+	publishFailures.Add(1)
+
+	return err
+}
@@ -0,0 +1,69 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRetrying(p Publisher, attempts int, t *testing.T) (*Retrying, *[]time.Duration) {
+	r, err := NewRetrying(p, attempts, time.Second)
+
+	if err != nil {
+		t.Fatalf("Failed to create retrying publisher: %v", err)
+	}
+
+	sleeps := []time.Duration{}
+	r.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	return r, &sleeps
+}
+
+func TestNewRetryingRejectsBadConfig(t *testing.T) {
+	if _, err := NewRetrying(None{}, 0, time.Second); err == nil {
+		t.Errorf("Created retrying publisher with no attempts")
+	}
+
+	if _, err := NewRetrying(None{}, 1, -time.Second); err == nil {
+		t.Errorf("Created retrying publisher with negative backoff")
+	}
+}
+
+func TestRetryingBacksOff(t *testing.T) {
+	p := &recordingPublisher{failures: 2}
+	r, sleeps := newTestRetrying(p, 3, t)
+	published, retries, failures := rootsPublished.Value(), publishRetries.Value(), publishFailures.Value()
+
+	if err := r.PublishLogRoot(logRoot); err != nil {
+		t.Fatalf("Failed to publish root: %v", err)
+	}
+
+	if got, want := p.logRoots, 3; got != want {
+		t.Errorf("Made %d attempts, want %d", got, want)
+	}
+
+	if got, want := *sleeps, []time.Duration{time.Second, time.Second * 2}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Slept for %v, want %v", got, want)
+	}
+
+	if rootsPublished.Value() != published+1 || publishRetries.Value() != retries+2 || publishFailures.Value() != failures {
+		t.Errorf("Metrics not updated for a retried publish")
+	}
+}
+
+func TestRetryingGivesUp(t *testing.T) {
+	p := &recordingPublisher{failures: 5}
+	r, _ := newTestRetrying(p, 3, t)
+	failures := publishFailures.Value()
+
+	if err := r.PublishMapRoot(mapRoot); err == nil {
+		t.Fatalf("Publish succeeded after every attempt failed")
+	}
+
+	if got, want := p.mapRoots, 3; got != want {
+		t.Errorf("Made %d attempts, want %d", got, want)
+	}
+
+	if got, want := publishFailures.Value(), failures+1; got != want {
+		t.Errorf("Got %d failures, want %d", got, want)
+	}
+}
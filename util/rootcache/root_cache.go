@@ -0,0 +1,151 @@
+// Package rootcache keeps the latest signed root of each tree in memory, so requests for
+// the current root of a busy log or map don't have to read it from storage.
+package rootcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/publisher"
+)
+
+// Lookups of cached roots, by tree type. The hit rate is hits / (hits + misses).
+var (
+	cacheHits   = monitoring.NewCounter("root_cache_hits", "Latest root requests answered from the root cache", "type")
+	cacheMisses = monitoring.NewCounter("root_cache_misses", "Latest root requests that had to be read from storage", "type")
+)
+
+const (
+	logType = "log"
+	mapType = "map"
+)
+
+type logEntry struct {
+	root         trillian.SignedLogRoot
+	cosignatures []trillian.Cosignature
+	expires      time.Time
+}
+
+type mapEntry struct {
+	root    trillian.SignedMapRoot
+	expires time.Time
+}
+
+// Cache holds the latest signed log root, along with its cosignatures, or signed map root of
+// each tree it's been given. Roots are replaced as soon as this process signs a newer one.
+// Other processes can sign roots without the cache knowing, so each root is only used for
+// ttl after it was added, which bounds how stale an answer can be.
+type Cache struct {
+	ttl        time.Duration
+	timeSource util.TimeSource
+	mu         sync.Mutex
+	logRoots   map[int64]logEntry
+	mapRoots   map[int64]mapEntry
+}
+
+// NewCache creates a Cache that keeps each root for ttl, as measured by timeSource
+func NewCache(ttl time.Duration, timeSource util.TimeSource) *Cache {
+	return &Cache{ttl: ttl, timeSource: timeSource, logRoots: make(map[int64]logEntry), mapRoots: make(map[int64]mapEntry)}
+}
+
+// LogRoot returns the cached latest root of a log and its cosignatures, or false if there
+// isn't one that's still fresh
+func (c *Cache) LogRoot(treeID int64) (trillian.SignedLogRoot, []trillian.Cosignature, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.logRoots[treeID]
+
+	if !ok || !c.timeSource.Now().Before(e.expires) {
+		cacheMisses.Inc(logType)
+		return trillian.SignedLogRoot{}, nil, false
+	}
+
+	cacheHits.Inc(logType)
+	return e.root, append([]trillian.Cosignature{}, e.cosignatures...), true
+}
+
+// SetLogRoot caches root as the latest root of a log. It's ignored if a newer root is
+// already cached, which can happen if this process signs one while root is being read.
+func (c *Cache) SetLogRoot(treeID int64, root trillian.SignedLogRoot, cosignatures []trillian.Cosignature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.logRoots[treeID]; ok && e.root.TimestampNanos > root.TimestampNanos {
+		return
+	}
+
+	c.logRoots[treeID] = logEntry{root: root, cosignatures: append([]trillian.Cosignature{}, cosignatures...), expires: c.timeSource.Now().Add(c.ttl)}
+}
+
+// InvalidateLog removes any cached root of a log, e.g. because it's been cosigned
+func (c *Cache) InvalidateLog(treeID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.logRoots, treeID)
+}
+
+// MapRoot returns the cached latest root of a map, or false if there isn't one that's still
+// fresh
+func (c *Cache) MapRoot(treeID int64) (trillian.SignedMapRoot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.mapRoots[treeID]
+
+	if !ok || !c.timeSource.Now().Before(e.expires) {
+		cacheMisses.Inc(mapType)
+		return trillian.SignedMapRoot{}, false
+	}
+
+	cacheHits.Inc(mapType)
+	return e.root, true
+}
+
+// SetMapRoot caches root as the latest root of a map. It's ignored if a root for a later
+// revision is already cached.
+func (c *Cache) SetMapRoot(treeID int64, root trillian.SignedMapRoot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.mapRoots[treeID]; ok && e.root.MapRevision > root.MapRevision {
+		return
+	}
+
+	c.mapRoots[treeID] = mapEntry{root: root, expires: c.timeSource.Now().Add(c.ttl)}
+}
+
+// InvalidateMap removes any cached root of a map
+func (c *Cache) InvalidateMap(treeID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.mapRoots, treeID)
+}
+
+// Publisher returns a Publisher that caches every root published to it as the latest root
+// of a tree. Roots don't say which tree they're for so there's one for each tree.
+func (c *Cache) Publisher(treeID int64) publisher.Publisher {
+	return treePublisher{cache: c, treeID: treeID}
+}
+
+type treePublisher struct {
+	cache  *Cache
+	treeID int64
+}
+
+// PublishLogRoot caches a newly signed log root, which can't have been cosigned yet
+func (p treePublisher) PublishLogRoot(root trillian.SignedLogRoot) error {
+	p.cache.SetLogRoot(p.treeID, root, nil)
+	return nil
+}
+
+// PublishMapRoot caches a newly signed map root
+func (p treePublisher) PublishMapRoot(root trillian.SignedMapRoot) error {
+	p.cache.SetMapRoot(p.treeID, root)
+	return nil
+}
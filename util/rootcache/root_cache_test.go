@@ -0,0 +1,138 @@
+package rootcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+var fakeTime = time.Date(2016, 6, 28, 13, 40, 12, 45, time.UTC)
+
+var logRoot1 = trillian.SignedLogRoot{TimestampNanos: 1000, RootHash: []byte("root1"), TreeSize: 7, TreeRevision: 1}
+var logRoot2 = trillian.SignedLogRoot{TimestampNanos: 2000, RootHash: []byte("root2"), TreeSize: 9, TreeRevision: 2}
+var mapRoot1 = trillian.SignedMapRoot{TimestampNanos: 1000, RootHash: []byte("root1"), MapRevision: 1}
+var mapRoot2 = trillian.SignedMapRoot{TimestampNanos: 2000, RootHash: []byte("root2"), MapRevision: 2}
+
+var cosignatures = []trillian.Cosignature{{WitnessId: "witness", Signature: &trillian.DigitallySigned{Signature: []byte("sig")}}}
+
+func TestLogRoot(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	c := NewCache(time.Second, ts)
+
+	if _, _, ok := c.LogRoot(1); ok {
+		t.Fatal("Found a root in an empty cache")
+	}
+
+	c.SetLogRoot(1, logRoot1, cosignatures)
+	root, cosigs, ok := c.LogRoot(1)
+
+	if !ok || !reflect.DeepEqual(root, logRoot1) || !reflect.DeepEqual(cosigs, cosignatures) {
+		t.Errorf("Got root %v with cosignatures %v (%v), want %v with %v", root, cosigs, ok, logRoot1, cosignatures)
+	}
+
+	if _, _, ok := c.LogRoot(2); ok {
+		t.Error("Found a root for a different log")
+	}
+
+	ts.FakeTime = fakeTime.Add(time.Second)
+
+	if _, _, ok := c.LogRoot(1); ok {
+		t.Error("Found a root after it expired")
+	}
+}
+
+func TestSetLogRootKeepsNewerRoot(t *testing.T) {
+	c := NewCache(time.Second, util.FakeTimeSource{FakeTime: fakeTime})
+
+	c.SetLogRoot(1, logRoot2, nil)
+	c.SetLogRoot(1, logRoot1, nil)
+
+	if root, _, _ := c.LogRoot(1); !reflect.DeepEqual(root, logRoot2) {
+		t.Errorf("Got root %v, want the newer %v", root, logRoot2)
+	}
+
+	// The same root can be replaced, e.g. with more cosignatures
+	c.SetLogRoot(1, logRoot2, cosignatures)
+
+	if _, cosigs, _ := c.LogRoot(1); !reflect.DeepEqual(cosigs, cosignatures) {
+		t.Errorf("Got cosignatures %v, want %v", cosigs, cosignatures)
+	}
+}
+
+func TestInvalidateLog(t *testing.T) {
+	c := NewCache(time.Second, util.FakeTimeSource{FakeTime: fakeTime})
+
+	c.SetLogRoot(1, logRoot1, nil)
+	c.InvalidateLog(1)
+
+	if _, _, ok := c.LogRoot(1); ok {
+		t.Error("Found a root after it was invalidated")
+	}
+}
+
+func TestMapRoot(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	c := NewCache(time.Second, ts)
+
+	c.SetMapRoot(1, mapRoot2)
+	c.SetMapRoot(1, mapRoot1)
+
+	if root, ok := c.MapRoot(1); !ok || !reflect.DeepEqual(root, mapRoot2) {
+		t.Errorf("Got root %v (%v), want the newer %v", root, ok, mapRoot2)
+	}
+
+	ts.FakeTime = fakeTime.Add(time.Second)
+
+	if _, ok := c.MapRoot(1); ok {
+		t.Error("Found a root after it expired")
+	}
+
+	c.SetMapRoot(1, mapRoot2)
+	c.InvalidateMap(1)
+
+	if _, ok := c.MapRoot(1); ok {
+		t.Error("Found a root after it was invalidated")
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	c := NewCache(time.Second, util.FakeTimeSource{FakeTime: fakeTime})
+	c.SetLogRoot(1, logRoot1, cosignatures)
+
+	if err := c.Publisher(1).PublishLogRoot(logRoot2); err != nil {
+		t.Fatalf("Failed to publish log root: %v", err)
+	}
+
+	if root, cosigs, ok := c.LogRoot(1); !ok || !reflect.DeepEqual(root, logRoot2) || len(cosigs) != 0 {
+		t.Errorf("Got root %v with cosignatures %v (%v), want %v without any", root, cosigs, ok, logRoot2)
+	}
+
+	if err := c.Publisher(2).PublishMapRoot(mapRoot1); err != nil {
+		t.Fatalf("Failed to publish map root: %v", err)
+	}
+
+	if root, ok := c.MapRoot(2); !ok || !reflect.DeepEqual(root, mapRoot1) {
+		t.Errorf("Got root %v (%v), want %v", root, ok, mapRoot1)
+	}
+}
+
+func TestHitAndMissMetrics(t *testing.T) {
+	c := NewCache(time.Second, util.FakeTimeSource{FakeTime: fakeTime})
+	hits, misses := cacheHits.Value(logType), cacheMisses.Value(logType)
+
+	c.LogRoot(1)
+	c.SetLogRoot(1, logRoot1, nil)
+	c.LogRoot(1)
+	c.LogRoot(1)
+
+	if got, want := cacheHits.Value(logType), hits+2; got != want {
+		t.Errorf("Got %v hits, want %v", got, want)
+	}
+
+	if got, want := cacheMisses.Value(logType), misses+1; got != want {
+		t.Errorf("Got %v misses, want %v", got, want)
+	}
+}
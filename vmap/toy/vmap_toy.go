@@ -13,6 +13,7 @@ import (
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/mysql"
 	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -56,15 +57,16 @@ func main() {
 	numBatches := testVecs[testIndex].numBatches
 	expectedRootB64 := testVecs[testIndex].expectedRootB64
 
+	ctx := context.Background()
 	var root trillian.Hash
 	for x := 0; x < numBatches; x++ {
-		tx, err := ms.Begin()
+		tx, err := ms.Begin(ctx)
 		if err != nil {
 			glog.Fatalf("Failed to Begin() a new tx: %v", err)
 		}
-		w, err := merkle.NewSparseMerkleTreeWriter(tx.WriteRevision(), hasher,
+		w, err := merkle.NewSparseMerkleTreeWriter(ctx, tx.WriteRevision(), hasher,
 			func() (storage.TreeTX, error) {
-				return ms.Begin()
+				return ms.Begin(ctx)
 			})
 		if err != nil {
 			glog.Fatalf("Failed to create new SMTWriter: %v", err)
@@ -91,7 +93,7 @@ func main() {
 		}
 		glog.Infof("CalculateRoot (%d), root: %s", x, base64.StdEncoding.EncodeToString(root))
 
-		if err := tx.StoreSignedMapRoot(trillian.SignedMapRoot{
+		if err := tx.StoreSignedMapRoot(ctx, trillian.SignedMapRoot{
 			TimestampNanos: time.Now().UnixNano(),
 			RootHash:       root,
 			MapId:          mapID.MapID,